@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/spf13/cobra"
+)
+
+// loadRunConfig loads and merges the configuration for a command that calls
+// the API. Load failures are non-fatal so the CLI works without a config file,
+// except keyring and passphrase failures, since api.key: keyring or an
+// encrypted api.key means the key is expected there, and invalid PPLX_*
+// environment variables.
+func loadRunConfig(cmd *cobra.Command) (*config.ConfigData, error) {
+	cfg, sources, err := config.LoadAndMergeConfigWithSources(cmd, configFilePath, runtimeProfile)
+	runConfigSources = sources
+	if err != nil {
+		if errors.Is(err, clerrors.ErrKeyringUnavailable) || errors.Is(err, clerrors.ErrKeyringKeyNotFound) ||
+			errors.Is(err, clerrors.ErrWrongPassphrase) || errors.Is(err, clerrors.ErrPassphraseRequired) ||
+			errors.Is(err, clerrors.ErrInvalidEncryptedKey) {
+			return nil, err //nolint:wrapcheck // already a ConfigError
+		}
+		var verr *clerrors.ValidationError
+		if errors.As(err, &verr) {
+			return nil, err //nolint:wrapcheck // names the offending variable
+		}
+		return config.NewConfigData(), nil
+	}
+	return cfg, nil
+}
+
+// resolveAPIKey returns the API key: PPLX_API_KEY when set, otherwise api.key
+// from cfg, which LoadAndMergeConfig has already read from the keyring when
+// it is "keyring".
+func resolveAPIKey(cfg *config.ConfigData) (string, error) {
+	if key := os.Getenv("PPLX_API_KEY"); key != "" {
+		return key, nil
+	}
+	if cfg != nil && cfg.API.Key != "" && !config.IsKeyringRef(cfg.API.Key) {
+		return cfg.API.Key, nil
+	}
+	return "", clerrors.NewConfigError(
+		"PPLX_API_KEY environment variable is not set and no api.key is configured", nil)
+}
+
+// newAPIClient returns a client for apiKey set up from globalOpts: the
+// --base-url or api.base_url endpoint, the api.proxy_url proxy, the timeouts,
+// and the retry policy. wrap is passed to retry.Configure. Invalid URLs are
+// rejected before any call is made.
+func newAPIClient(apiKey string, wrap ...func(http.RoundTripper) http.RoundTripper) (*perplexity.Client, error) {
+	if err := config.ValidateHTTPURL("base-url", globalOpts.BaseURL); err != nil {
+		return nil, err //nolint:wrapcheck // names the offending value
+	}
+	if _, err := config.ParseProxyURL(globalOpts.ProxyURL); err != nil {
+		return nil, err //nolint:wrapcheck // names the offending value
+	}
+	timeouts := globalOpts.Timeouts()
+	tlsConf, err := config.LoadTLSConfig(globalOpts.TLS)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // names the offending option
+	}
+	timeouts.TLS = tlsConf
+
+	client := perplexity.NewClient(apiKey)
+	if endpoint := config.EndpointURL(globalOpts.BaseURL); endpoint != "" {
+		client.SetEndpoint(endpoint)
+	}
+	retry.Configure(client, retry.NewPolicy(globalOpts.MaxRetries, globalOpts.RetryBackoff), timeouts, wrap...)
+	return client, nil
+}
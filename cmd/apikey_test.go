@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// withGlobalOpts restores globalOpts after the test.
+func withGlobalOpts(t *testing.T) {
+	t.Helper()
+	saved := *globalOpts
+	t.Cleanup(func() { *globalOpts = saved })
+}
+
+func TestNewAPIClient_BaseURL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	withGlobalOpts(t)
+
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(mockCompletionResponseJSON()))
+	}))
+	defer srv.Close()
+
+	globalOpts.BaseURL = srv.URL + "/v1"
+	globalOpts.MaxRetries = 0
+	client, err := newAPIClient("test-key")
+	if err != nil {
+		t.Fatalf("newAPIClient() error = %v", err)
+	}
+
+	// Query and batch send the request directly.
+	if _, err := client.SendCompletionRequest(newTestRequest()); err != nil {
+		t.Fatalf("query request failed: %v", err)
+	}
+
+	// Chat sends it through pkg/chat.
+	c := chat.NewChatWithOptions(client, "", chat.Options{
+		Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+	})
+	if err := c.AddUserMessage("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Run(); err != nil {
+		t.Fatalf("chat request failed: %v", err)
+	}
+
+	if len(paths) != 2 || paths[0] != "/v1/chat/completions" || paths[1] != "/v1/chat/completions" {
+		t.Errorf("server saw %v, want two requests to /v1/chat/completions", paths)
+	}
+}
+
+func TestNewAPIClient_InvalidURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		proxy   string
+		wantVal string
+	}{
+		{"bad base scheme", "ftp://gateway.example", "", "ftp://gateway.example"},
+		{"base without host", "https://", "", "https://"},
+		{"bad proxy", "", "socks://proxy.example", "socks://proxy.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withGlobalOpts(t)
+			globalOpts.BaseURL = tt.base
+			globalOpts.ProxyURL = tt.proxy
+
+			_, err := newAPIClient("test-key")
+			var verr *clerrors.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("error = %v, want a ValidationError", err)
+			}
+			if verr.Value != tt.wantVal {
+				t.Errorf("Value = %q, want %q", verr.Value, tt.wantVal)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/citations"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/sgaunet/pplx/pkg/warnings"
+	"github.com/spf13/cobra"
+)
+
+// Flags of the brief command.
+var (
+	briefSince    string
+	briefDomains  []string
+	briefTemplate string
+	briefOutput   string
+)
+
+// briefSinceValues are the periods --since accepts, in help order.
+var briefSinceValues = []string{"day", "week", "month"}
+
+// briefFilePerms is the permission of files written by brief --output.
+const briefFilePerms = 0o600
+
+// briefSystemPrompt asks for the answer in the shape of a briefing.
+const briefSystemPrompt = "You write concise news briefings. Answer with a bulleted list of the most " +
+	"significant developments, most important first, one or two sentences per bullet, each citing its " +
+	"sources. Do not add an introduction or a conclusion."
+
+var briefCmd = &cobra.Command{
+	Use:   "brief <topic>",
+	Short: "Print a news briefing on a topic",
+	Long: `Ask what happened on a topic recently and print the answer as a bulleted
+briefing followed by its source links.
+
+The search settings come from the news template: week recency, the news
+domains, and related questions. A user template named news in
+~/.config/pplx/templates/ replaces it, and --template uses another one; when
+the template does not exist, the briefing falls back on week recency and
+related questions with a warning. --since and --domains override the
+template. Model and sampling options come from the config file and flags as
+for query.
+
+The briefing is markdown: it is rendered like query answers (see --render),
+or written as is to the --output file.
+
+Examples:
+  pplx brief "European energy policy"
+  pplx brief rust --since day --domains blog.rust-lang.org,lwn.net
+  pplx brief "AI regulation" --since month -o briefing.md`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		topic := strings.TrimSpace(args[0])
+		if topic == "" {
+			return clerrors.NewValidationError("topic", args[0], "cannot be empty")
+		}
+
+		cfg, err := loadRunConfig(cmd)
+		if err != nil {
+			return err
+		}
+		config.ApplyToGlobals(cfg, globalOpts)
+		applyColorTheme()
+		if err := resolveModel(); err != nil {
+			return err
+		}
+
+		tmpl, err := loadBriefTemplate(briefTemplate)
+		if err != nil {
+			return err
+		}
+		opts := newChatOptions()
+		opts.Stream = false
+		if err := applyBriefTemplate(&opts, tmpl, cmd); err != nil {
+			return err
+		}
+
+		apiKey, err := resolveAPIKey(cfg)
+		if err != nil {
+			return err
+		}
+		client, err := newAPIClient(apiKey)
+		if err != nil {
+			return err
+		}
+		c := chat.NewChatWithOptions(client, briefSystemPrompt, opts)
+		for _, hook := range requestHooks(cfg) {
+			c.Use(hook)
+		}
+		c.Use(usageHook{source: usage.SourceBrief})
+		if err := c.AddUserMessage(briefQuestion(topic, opts.SearchRecency)); err != nil {
+			return clerrors.NewAPIError("failed to add user message", err)
+		}
+
+		res, err := sendBrief(c)
+		if err != nil {
+			return err
+		}
+		briefing := formatBriefing(topic, opts.SearchRecency, res, time.Now())
+		return writeBriefing(cmd.OutOrStdout(), briefing)
+	},
+}
+
+// addBriefFlags registers the flags of the brief command.
+func addBriefFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&briefSince, "since", "",
+		"Period to cover: "+strings.Join(briefSinceValues, ", ")+" (default: the template's search.recency)")
+	cmd.Flags().StringSliceVar(&briefDomains, "domains", nil,
+		"Search only these domains instead of the template's search.domains")
+	cmd.Flags().StringVar(&briefTemplate, "template", config.TemplateNews,
+		"Template supplying the search settings")
+	cmd.Flags().StringVarP(&briefOutput, "output", "o", "",
+		"Write the briefing as markdown to a file instead of stdout")
+	cmd.Flags().StringVar(&globalOpts.Render, "render", globalOpts.Render,
+		"Briefing rendering: markdown, plain, or raw (default: markdown on a terminal, plain when piped)")
+	if err := cmd.RegisterFlagCompletionFunc("since",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return briefSinceValues, cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'since' flag: %v\n", err)
+	}
+}
+
+// loadBriefTemplate loads the template called name. A template that does not
+// exist is replaced by briefFallbackTemplate with a warning; one that cannot
+// be read or parsed is an error.
+func loadBriefTemplate(name string) (*config.ConfigData, error) {
+	tmpl, err := config.LoadTemplate(name)
+	if err == nil {
+		return tmpl, nil
+	}
+	if !config.IsTemplateNotFoundError(err) {
+		return nil, clerrors.NewConfigError("failed to load template "+name, err)
+	}
+	warnings.Add(warnings.CodeConfig,
+		fmt.Sprintf("template %q not found, briefing with week recency and no domain filter", name),
+		"use a built-in template (research, creative, news, full-example) or a file in ~/.config/pplx/templates/")
+	return briefFallbackTemplate(), nil
+}
+
+// briefFallbackTemplate returns the search settings used when the brief
+// template does not exist.
+func briefFallbackTemplate() *config.ConfigData {
+	tmpl := &config.ConfigData{}
+	tmpl.Search.Recency = "week"
+	tmpl.Output.ReturnRelated = true
+	return tmpl
+}
+
+// applyBriefTemplate sets the recency, domains and related questions of opts
+// from tmpl, with --since and --domains taking precedence when given.
+func applyBriefTemplate(opts *chat.Options, tmpl *config.ConfigData, cmd *cobra.Command) error {
+	if tmpl.Search.Recency != "" {
+		opts.SearchRecency = tmpl.Search.Recency
+	}
+	if len(tmpl.Search.Domains) > 0 {
+		opts.SearchDomains = tmpl.Search.Domains
+	}
+	opts.ReturnRelated = opts.ReturnRelated || tmpl.Output.ReturnRelated
+
+	if cmd.Flags().Changed("since") {
+		if !slices.Contains(briefSinceValues, briefSince) {
+			return clerrors.NewValidationError("since", briefSince,
+				"must be one of: "+strings.Join(briefSinceValues, ", "))
+		}
+		opts.SearchRecency = briefSince
+	}
+	if cmd.Flags().Changed("domains") {
+		opts.SearchDomains = briefDomains
+	}
+	if opts.SearchRecency == "" {
+		opts.SearchRecency = "week"
+	}
+	return nil
+}
+
+// briefQuestion returns the question asked for a briefing on topic covering
+// the past since.
+func briefQuestion(topic, since string) string {
+	return fmt.Sprintf("What happened regarding %s in the past %s?", topic, since)
+}
+
+// sendBrief sends the question of c with a spinner and returns the answer.
+func sendBrief(c *chat.Chat) (*perplexity.CompletionResponse, error) {
+	var spinner *pterm.SpinnerPrinter
+	if showSpinner() {
+		spinner, _ = pterm.DefaultSpinner.Start("Waiting for response from perplexity...")
+	}
+	res, err := c.Send()
+	if err != nil {
+		if spinner != nil {
+			spinner.Fail("Request failed")
+		}
+		return nil, clerrors.NewAPIError("failed to get briefing", retry.ClassifyTimeout(err))
+	}
+	if spinner != nil {
+		spinner.Success("Response received")
+	}
+	return res, nil
+}
+
+// formatBriefing returns the markdown briefing of res on topic: a title, the
+// period covered, the answer with its citation markers turned into links,
+// and the list of sources.
+func formatBriefing(topic, since string, res *perplexity.CompletionResponse, now time.Time) string {
+	sources := citations.FromResponse(res)
+	var content string
+	if len(res.Choices) > 0 {
+		content = strings.TrimSpace(res.Choices[0].Message.Content)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Briefing: %s\n\n", topic)
+	fmt.Fprintf(&b, "_Past %s, as of %s_\n\n", since, now.Format(time.DateOnly))
+	b.WriteString(citations.Rewrite(content, citations.StyleInline, sources))
+	b.WriteString("\n")
+	if len(sources) > 0 {
+		b.WriteString("\n## Sources\n\n")
+		for i, source := range sources {
+			title := source.Title
+			if title == "" {
+				title = source.URL
+			}
+			fmt.Fprintf(&b, "%d. [%s](%s)\n", i+1, title, source.URL)
+		}
+	}
+	return b.String()
+}
+
+// writeBriefing writes briefing to the --output file, or renders it to out.
+func writeBriefing(out io.Writer, briefing string) error {
+	if briefOutput != "" {
+		if err := os.WriteFile(briefOutput, []byte(briefing), briefFilePerms); err != nil {
+			return clerrors.NewIOError("cannot write "+briefOutput, err)
+		}
+		return nil
+	}
+	renderer, err := render.ForStdout(globalOpts.Render)
+	if err != nil {
+		return clerrors.NewValidationError("render", globalOpts.Render, err.Error())
+	}
+	if _, err := io.WriteString(out, renderer.Render(briefing)); err != nil {
+		return clerrors.NewIOError("failed to write briefing", err)
+	}
+	return nil
+}
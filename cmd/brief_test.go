@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/warnings"
+	"github.com/spf13/cobra"
+)
+
+// newBriefTestCommand returns a command with the brief flags, and resets
+// them when the test ends.
+func newBriefTestCommand(t *testing.T) *cobra.Command {
+	t.Helper()
+	withGlobalOpts(t)
+	t.Cleanup(func() {
+		briefSince, briefDomains, briefTemplate, briefOutput = "", nil, config.TemplateNews, ""
+	})
+	cmd := &cobra.Command{Use: "brief"}
+	addBriefFlags(cmd)
+	return cmd
+}
+
+func TestLoadBriefTemplate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	warnings.Default.Reset()
+	t.Cleanup(warnings.Default.Reset)
+
+	tmpl, err := loadBriefTemplate(config.TemplateNews)
+	if err != nil {
+		t.Fatalf("loadBriefTemplate(news) error = %v", err)
+	}
+	if tmpl.Search.Recency != "week" || !slices.Contains(tmpl.Search.Domains, "reuters.com") {
+		t.Errorf("news template search = %+v, want week recency and the news domains", tmpl.Search)
+	}
+	if warnings.Default.Len() != 0 {
+		t.Errorf("warnings = %+v, want none", warnings.Default.All())
+	}
+
+	tmpl, err = loadBriefTemplate("missing")
+	if err != nil {
+		t.Fatalf("loadBriefTemplate(missing) error = %v", err)
+	}
+	if tmpl.Search.Recency != "week" || len(tmpl.Search.Domains) != 0 || !tmpl.Output.ReturnRelated {
+		t.Errorf("fallback template = %+v, want week recency, related questions, no domains", tmpl)
+	}
+	if all := warnings.Default.All(); len(all) != 1 || all[0].Code != warnings.CodeConfig {
+		t.Errorf("warnings = %+v, want one config warning", all)
+	}
+}
+
+func TestApplyBriefTemplate(t *testing.T) {
+	tmpl := &config.ConfigData{}
+	tmpl.Search.Recency = "week"
+	tmpl.Search.Domains = []string{"reuters.com", "apnews.com"}
+	tmpl.Output.ReturnRelated = true
+
+	tests := []struct {
+		name        string
+		flags       map[string]string
+		wantRecency string
+		wantDomains []string
+		wantErr     bool
+	}{
+		{"template", nil, "week", []string{"reuters.com", "apnews.com"}, false},
+		{"since", map[string]string{"since": "day"}, "day", []string{"reuters.com", "apnews.com"}, false},
+		{"domains", map[string]string{"domains": "lwn.net"}, "week", []string{"lwn.net"}, false},
+		{"invalid since", map[string]string{"since": "year"}, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newBriefTestCommand(t)
+			for name, value := range tt.flags {
+				if err := cmd.Flags().Set(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			opts := chat.Options{SearchRecency: "month", SearchDomains: []string{"example.com"}}
+			err := applyBriefTemplate(&opts, tmpl, cmd)
+			if tt.wantErr {
+				var valErr *clerrors.ValidationError
+				if !errors.As(err, &valErr) || valErr.Field != "since" {
+					t.Errorf("applyBriefTemplate() error = %v, want a since ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyBriefTemplate() error = %v", err)
+			}
+			if opts.SearchRecency != tt.wantRecency || !slices.Equal(opts.SearchDomains, tt.wantDomains) {
+				t.Errorf("recency, domains = %q, %v, want %q, %v",
+					opts.SearchRecency, opts.SearchDomains, tt.wantRecency, tt.wantDomains)
+			}
+			if !opts.ReturnRelated {
+				t.Error("ReturnRelated = false, want the template's true")
+			}
+		})
+	}
+}
+
+func TestFormatBriefing(t *testing.T) {
+	res := &perplexity.CompletionResponse{
+		Choices: []perplexity.Choice{{Message: perplexity.Message{
+			Content: "- Rust 2.0 was announced [1].\n- A CVE was fixed [2].",
+		}}},
+		SearchResults: &[]perplexity.SearchResult{
+			{Title: "Rust blog", URL: "https://blog.rust-lang.org/a"},
+			{URL: "https://lwn.net/b"},
+		},
+	}
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+
+	got := formatBriefing("rust", "week", res, now)
+	for _, want := range []string{
+		"# Briefing: rust\n",
+		"_Past week, as of 2026-10-17_",
+		"- Rust 2.0 was announced [1](https://blog.rust-lang.org/a).",
+		"## Sources\n\n1. [Rust blog](https://blog.rust-lang.org/a)\n2. [https://lwn.net/b](https://lwn.net/b)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("briefing does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestBriefCmd_WritesOutput(t *testing.T) {
+	newBriefTestCommand(t)
+	disableSpinner(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("PPLX_API_KEY", "test-key")
+
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(mockCompletionResponseJSON()))
+	}))
+	defer srv.Close()
+	globalOpts.BaseURL = srv.URL
+	globalOpts.MaxRetries = 0
+	briefOutput = filepath.Join(t.TempDir(), "briefing.md")
+
+	if err := briefCmd.RunE(briefCmd, []string{"go"}); err != nil {
+		t.Fatalf("brief error = %v", err)
+	}
+	if body["search_recency_filter"] != "week" || body["return_related_questions"] != true {
+		t.Errorf("request = %v, want the news template settings", body)
+	}
+	domains, _ := body["search_domain_filter"].([]any)
+	if !slices.Contains(domains, any("reuters.com")) {
+		t.Errorf("search_domain_filter = %v, want the news domains", domains)
+	}
+	data, err := os.ReadFile(briefOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# Briefing: go\n") || !strings.Contains(string(data), "Hello") {
+		t.Errorf("briefing file = %q, want the titled answer", data)
+	}
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/cache"
+	clerrors "github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/console"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/spf13/cobra"
+)
+
+// cacheTimeLayout formats the entry times of `pplx cache stats`.
+const cacheTimeLayout = "2006-01-02 15:04"
+
+// Response cache flags of the query command.
+var (
+	queryCache   bool
+	queryNoCache bool
+	cacheJSON    bool
+)
+
+// responseCache is the cache and key of the running query; nil when caching
+// is off.
+var responseCache *queryResponseCache
+
+// queryResponseCache pairs the cache with the key of the current request.
+type queryResponseCache struct {
+	cache *cache.Cache
+	key   string
+}
+
+// addCacheFlags registers --cache and --no-cache.
+func addCacheFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&queryCache, "cache", false,
+		"Serve identical queries from the on-disk response cache (lifetime: output.cache_ttl, default 1h)")
+	cmd.Flags().BoolVar(&queryNoCache, "no-cache", false,
+		"Send a fresh request even if a cached response exists, then refresh the cache")
+}
+
+// cacheTTL reports whether the response cache is enabled, by --cache or
+// output.cache_ttl, and how long entries are served.
+func cacheTTL(cfg *config.ConfigData) (time.Duration, bool) {
+	ttl := cfg.Output.CacheTTL
+	if !queryCache && ttl <= 0 {
+		return 0, false
+	}
+	if ttl <= 0 {
+		ttl = cache.DefaultTTL
+	}
+	return ttl, true
+}
+
+// serveFromCache prints to out the cached response to req when caching is
+// enabled and a fresh entry exists, and reports whether it did. It also prepares
+// responseCache so that the response to a cache miss is stored. Cache
+// failures never fail the query; they are logged and the request is sent.
+func serveFromCache(cfg *config.ConfigData, req *perplexity.CompletionRequest, out io.Writer) (bool, error) {
+	responseCache = nil
+	ttl, enabled := cacheTTL(cfg)
+	// The cache key does not cover --choices, so several candidates are never cached.
+	if !enabled || queryChoices > 1 {
+		return false, nil
+	}
+
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		logger.Warn("response cache unavailable", "error", err)
+		return false, nil
+	}
+	key, err := cache.Key(req)
+	if err != nil {
+		logger.Warn("response cache unavailable", "error", err)
+		return false, nil
+	}
+	responseCache = &queryResponseCache{cache: cache.New(dir), key: key}
+
+	if queryNoCache {
+		return false, nil
+	}
+	entry, ok := responseCache.cache.Get(key, ttl)
+	if !ok {
+		return false, nil
+	}
+
+	fmt.Fprintf(chatter(os.Stderr), "(cached, %s old)\n", formatCacheAge(entry.Age(time.Now())))
+	return true, renderCachedResponse(&entry.Response, out)
+}
+
+// renderCachedResponse prints a cached response to out the way a fresh one
+// is printed. Streaming queries replay the complete answer at once.
+func renderCachedResponse(res *perplexity.CompletionResponse, out io.Writer) error {
+	filter, err := citationFilter()
+	if err != nil {
+		return err
+	}
+	filter.Apply(res)
+	if queryExtract != "" {
+		if err := writeExtracted(out, res, 0); err != nil {
+			return err
+		}
+		return saveResponseImages(res)
+	}
+	if globalOpts.OutputJSON {
+		err = writeJSONResult(out, res, 0)
+	} else {
+		style, styleErr := citationStyle()
+		if styleErr != nil {
+			return styleErr
+		}
+		renderer, renderErr := render.ForStdout(globalOpts.Render)
+		if renderErr != nil {
+			return clerrors.NewValidationError("render", globalOpts.Render, renderErr.Error())
+		}
+		if err = console.RenderAnswerWithCitations(res, out, renderer, style); err == nil {
+			err = renderSearches(out, res)
+		}
+	}
+	if err != nil {
+		return clerrors.NewIOError("failed to render response", err)
+	}
+	return saveResponseImages(res)
+}
+
+// storeCachedResponse saves res for the running query when caching is
+// enabled. Streaming queries only read from the cache.
+func storeCachedResponse(res *perplexity.CompletionResponse) {
+	if responseCache == nil || globalOpts.Stream || res == nil {
+		return
+	}
+	if err := responseCache.cache.Put(responseCache.key, res); err != nil {
+		logger.Warn("failed to store response in cache", "error", err)
+	}
+}
+
+// formatCacheAge formats the age of a cache entry in its largest whole unit.
+func formatCacheAge(age time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < day:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age/day))
+	}
+}
+
+// defaultResponseCache opens the response cache in its default location.
+func defaultResponseCache() (*cache.Cache, error) {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil, clerrors.NewIOError("cannot locate response cache", err)
+	}
+	return cache.New(dir), nil
+}
+
+// cacheCmd groups the response cache commands.
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the query response cache",
+	Long: `Query responses are cached on disk (~/.cache/pplx/responses) when
+pplx query runs with --cache or output.cache_ttl is set in the config file.
+An identical query (same model, messages, and options) is then answered from
+the cache while the entry is younger than output.cache_ttl (default 1h).
+Use --no-cache to force a fresh request.`,
+}
+
+// cacheClearCmd removes every cached response.
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached responses",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		c, err := defaultResponseCache()
+		if err != nil {
+			return err
+		}
+		removed, err := c.Clear()
+		if err != nil {
+			return clerrors.NewIOError("failed to clear response cache", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed %d cached response(s).\n", removed)
+		return nil
+	},
+}
+
+// cacheStatsCmd shows the size of the cache.
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the number and size of cached responses",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		c, err := defaultResponseCache()
+		if err != nil {
+			return err
+		}
+		stats, err := c.Stats()
+		if err != nil {
+			return clerrors.NewIOError("failed to read response cache", err)
+		}
+		return writeCacheStats(cmd.OutOrStdout(), stats, cacheJSON)
+	},
+}
+
+// writeCacheStats prints stats as text or JSON.
+func writeCacheStats(out io.Writer, stats cache.Stats, asJSON bool) error {
+	if asJSON {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal cache stats: %w", err)
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err //nolint:wrapcheck // stdout write
+	}
+
+	fmt.Fprintf(out, "Directory: %s\n", stats.Dir)
+	fmt.Fprintf(out, "Entries:   %d\n", stats.Entries)
+	fmt.Fprintf(out, "Size:      %s\n", config.FormatFileSize(stats.Bytes))
+	if stats.Entries > 0 {
+		fmt.Fprintf(out, "Oldest:    %s\n", stats.Oldest.Local().Format(cacheTimeLayout))
+		fmt.Fprintf(out, "Newest:    %s\n", stats.Newest.Local().Format(cacheTimeLayout))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheStatsCmd.Flags().BoolVar(&cacheJSON, "json", false, "Output as JSON")
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/cache"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+// setCacheFlags sets --cache and --no-cache for one test.
+func setCacheFlags(t *testing.T, enabled, noCache bool) {
+	t.Helper()
+	origCache, origNoCache, origStream := queryCache, queryNoCache, globalOpts.Stream
+	queryCache, queryNoCache, globalOpts.Stream = enabled, noCache, false
+	t.Cleanup(func() {
+		queryCache, queryNoCache, globalOpts.Stream = origCache, origNoCache, origStream
+		responseCache = nil
+	})
+}
+
+// discardStdout redirects os.Stdout for the rest of the test.
+func discardStdout(t *testing.T) {
+	t.Helper()
+	orig := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, r)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		_ = w.Close()
+		<-done
+		os.Stdout = orig
+	})
+}
+
+func TestCacheTTL(t *testing.T) {
+	tests := []struct {
+		name        string
+		flag        bool
+		configured  time.Duration
+		wantTTL     time.Duration
+		wantEnabled bool
+	}{
+		{"disabled", false, 0, 0, false},
+		{"flag uses default", true, 0, cache.DefaultTTL, true},
+		{"config enables", false, 10 * time.Minute, 10 * time.Minute, true},
+		{"flag with config ttl", true, 24 * time.Hour, 24 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCacheFlags(t, tt.flag, false)
+			cfg := config.NewConfigData()
+			cfg.Output.CacheTTL = tt.configured
+			ttl, enabled := cacheTTL(cfg)
+			if ttl != tt.wantTTL || enabled != tt.wantEnabled {
+				t.Errorf("cacheTTL() = %v, %v; want %v, %v", ttl, enabled, tt.wantTTL, tt.wantEnabled)
+			}
+		})
+	}
+}
+
+func TestFormatCacheAge(t *testing.T) {
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{42 * time.Second, "42s"},
+		{12*time.Minute + 30*time.Second, "12m"},
+		{3 * time.Hour, "3h"},
+		{50 * time.Hour, "2d"},
+	}
+	for _, tt := range tests {
+		if got := formatCacheAge(tt.age); got != tt.want {
+			t.Errorf("formatCacheAge(%v) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCache_HitSkipsRequest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	disableSpinner(t)
+	discardStdout(t)
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(mockCompletionResponseJSON()))
+	}))
+	defer srv.Close()
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	cfg := config.NewConfigData()
+	query := func() {
+		t.Helper()
+		req := newTestRequest()
+		served, err := serveFromCache(cfg, req, os.Stdout)
+		if err != nil {
+			t.Fatalf("serveFromCache() error: %v", err)
+		}
+		if !served {
+			if err := handleNonStreamingResponse(context.Background(), client, req, os.Stdout); err != nil {
+				t.Fatalf("handleNonStreamingResponse() error: %v", err)
+			}
+		}
+	}
+
+	setCacheFlags(t, true, false)
+	query()
+	query()
+	if got := requests.Load(); got != 1 {
+		t.Errorf("API requests with --cache = %d, want 1", got)
+	}
+
+	queryNoCache = true
+	query()
+	if got := requests.Load(); got != 2 {
+		t.Errorf("API requests with --no-cache = %d, want 2", got)
+	}
+
+	queryCache, queryNoCache = false, false
+	query()
+	if got := requests.Load(); got != 3 {
+		t.Errorf("API requests without caching = %d, want 3", got)
+	}
+}
+
+func TestStoreCachedResponse_SkipsStreaming(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	setCacheFlags(t, true, false)
+
+	if _, err := serveFromCache(config.NewConfigData(), newTestRequest(), io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	globalOpts.Stream = true
+	storeCachedResponse(&perplexity.CompletionResponse{Model: "sonar"})
+
+	stats, err := responseCache.cache.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 0 {
+		t.Errorf("streaming response was cached: %d entries", stats.Entries)
+	}
+}
+
+func TestWriteCacheStats(t *testing.T) {
+	stats := cache.Stats{
+		Dir:     "/home/u/.cache/pplx/responses",
+		Entries: 2,
+		Bytes:   2048,
+		Oldest:  time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+		Newest:  time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC),
+	}
+
+	var text bytes.Buffer
+	if err := writeCacheStats(&text, stats, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Entries:   2", "Size:      2.00 KB", "Oldest:"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text stats missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeCacheStats(&out, stats, true); err != nil {
+		t.Fatal(err)
+	}
+	var decoded cache.Stats
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.Entries != 2 {
+		t.Errorf("JSON stats = %s, err = %v", out.String(), err)
+	}
+}
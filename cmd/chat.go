@@ -1,124 +1,376 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/pterm/pterm"
-	"github.com/sgaunet/perplexity-go/v2"
 	"github.com/sgaunet/pplx/pkg/chat"
 	"github.com/sgaunet/pplx/pkg/config"
 	"github.com/sgaunet/pplx/pkg/console"
 	clerrors "github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/sgaunet/pplx/pkg/warnings"
 	"github.com/spf13/cobra"
 )
 
+// Context window flags of the chat command.
+var (
+	chatMaxContextTokens int
+	chatSummarizeOnTrim  bool
+)
+
+// chatDryRun is the --dry-run flag of the chat command.
+var chatDryRun bool
+
+// chatDryRunPrompt stands in for the first question in chat --dry-run output.
+const chatDryRunPrompt = "<first question>"
+
 var chatCmd = &cobra.Command{
 	Use:   "chat",
 	Short: "chat subcommand is an interactive chat with the Perplexity API",
 	Long: `With chat subcommand you can interactively chat with the Perplexity API.
 You can ask questions and get answers from the API. As long as you don't enter an empty question,
- the chat will continue.`,
+ the chat will continue.
+
+Lines starting with "/" are commands and are not sent to the API:
+  /model [name]          Show or switch the model for the next messages
+  /system [text]         Replace the system message (empty removes it)
+  /clear                 Forget the conversation history
+  /save <file> [format]  Save the transcript as markdown, json or html
+                         (default: by file extension)
+  /tokens                Show cumulative token usage and estimated cost
+  /retry                 Resend the last user message
+  /rewind [n]            Forget the last n questions and answers (default: 1)
+  /fork <name>           Save the conversation as session <name>.json and
+                         continue in a copy
+  /help                  List commands
+
+Saved JSON transcripts and forked sessions can be converted later with
+"pplx chat export".
+
+With --max-context-tokens, the oldest question/answer pairs are left out of
+requests once the estimated prompt would exceed the limit, so long sessions
+keep working. The system message and the latest question are always sent.
+--summarize-on-trim replaces the left-out turns with a short summary built
+from their text.
+
+On a terminal, questions are read with line editing: the arrow keys move
+in the line and recall earlier lines, kept across sessions in
+~/.local/share/pplx/chat_history, and Ctrl-R recalls the latest line
+containing the text typed so far (again for older ones). Enter sends the
+question; end a line with \ to continue on the next one, or open a code
+block with ` + "```" + ` to send everything up to the closing ` + "```" + `.
+Pasted lines are kept together. An empty line, Ctrl-D or Ctrl-C quits.
+--no-readline reads questions as on dumb terminals and pipes instead: lines
+are joined until an empty one.
+
+After each answer, the conversation is autosaved to
+~/.local/share/pplx/autosave.json, and the file is removed when chat ends
+with an empty line, Ctrl-D or Ctrl-C. When chat finds an autosave left by a
+crash, a closed terminal or an error, it offers to resume that conversation,
+with its system message and options. --resume-last resumes it without
+asking, and --no-autosave neither saves nor offers one. The autosave is a
+JSON transcript that "pplx chat export" also reads.
+
+With --dry-run, chat prints the request its first message would send, with
+every option and where it came from, and exits without prompting.`,
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		// Load configuration from file and merge with CLI flags
-		cfg, err := config.LoadAndMergeConfig(cmd, configFilePath, runtimeProfile)
+		// (non-fatal except for keyring errors: continue with CLI flags only)
+		cfg, err := loadRunConfig(cmd)
 		if err != nil {
-			// Non-fatal: continue with CLI flags only
-			cfg = config.NewConfigData()
+			return err
 		}
 
 		// Apply configuration to global variables
 		config.ApplyToGlobals(cfg, globalOpts)
+		applyColorTheme()
+		if err := resolveModel(); err != nil {
+			return err
+		}
+		if err := resolveJSONSchemaFile(); err != nil {
+			return err
+		}
+		if err := resolveStreamFormat(); err != nil {
+			return err
+		}
+
+		if chatDryRun {
+			c := chat.NewChatWithOptions(nil, cfg.Defaults.SystemPrompt, newChatOptions())
+			if err := c.AddUserMessage(chatDryRunPrompt); err != nil {
+				return clerrors.NewAPIError("failed to add user message", err)
+			}
+			req, err := c.Request()
+			if err != nil {
+				return clerrors.NewValidationError("request", "", err.Error())
+			}
+			return writeDryRun(cmd.OutOrStdout(), cfg, runConfigSources, req)
+		}
 
-		// Check env var PPLX_API_KEY exists
-		if os.Getenv("PPLX_API_KEY") == "" {
-			return clerrors.NewConfigError("PPLX_API_KEY environment variable is not set", nil)
+		apiKey, err := resolveAPIKey(cfg)
+		if err != nil {
+			return err
 		}
 
-		client := perplexity.NewClient(os.Getenv("PPLX_API_KEY"))
-		client.SetHTTPTimeout(globalOpts.Timeout)
+		renderer, err := render.ForStdout(globalOpts.Render)
+		if err != nil {
+			return clerrors.NewValidationError("render", globalOpts.Render, err.Error())
+		}
+		style, err := citationStyle()
+		if err != nil {
+			return err
+		}
+		filter, err := citationFilter()
+		if err != nil {
+			return err
+		}
+		if _, err := streamStyle(); err != nil {
+			return err
+		}
+		if chatMaxContextTokens < 0 {
+			return clerrors.NewValidationError("max-context-tokens", fmt.Sprint(chatMaxContextTokens),
+				"cannot be negative")
+		}
+		if err := validateAutoContinue(); err != nil {
+			return err
+		}
 
-		systemMessage, err := console.Input("system message (optional - enter to skip)")
+		client, err := newAPIClient(apiKey)
 		if err != nil {
-			return clerrors.NewIOError("failed to read system message", err)
-		}
-		// Create chat options
-		chatOptions := chat.Options{
-			Model:            globalOpts.Model,
-			FrequencyPenalty: globalOpts.FrequencyPenalty,
-			MaxTokens:        globalOpts.MaxTokens,
-			PresencePenalty:  globalOpts.PresencePenalty,
-			Temperature:      globalOpts.Temperature,
-			TopK:             globalOpts.TopK,
-			TopP:             globalOpts.TopP,
-			SearchDomains:    globalOpts.SearchDomains,
-			SearchRecency:    globalOpts.SearchRecency,
-			LocationLat:      globalOpts.LocationLat,
-			LocationLon:      globalOpts.LocationLon,
-			LocationCountry:  globalOpts.LocationCountry,
-			ReturnImages:     globalOpts.ReturnImages,
-			ReturnRelated:    globalOpts.ReturnRelated,
-			Stream:           globalOpts.Stream,
-			ImageDomains:     globalOpts.ImageDomains,
-			ImageFormats:     globalOpts.ImageFormats,
-			// Response format options
-			ResponseFormatJSONSchema: globalOpts.ResponseFormatJSONSchema,
-			ResponseFormatRegex:      globalOpts.ResponseFormatRegex,
-			// Search mode options
-			SearchMode:        globalOpts.SearchMode,
-			SearchContextSize: globalOpts.SearchContextSize,
-			// Date filtering options
-			SearchAfterDate:   globalOpts.SearchAfterDate,
-			SearchBeforeDate:  globalOpts.SearchBeforeDate,
-			LastUpdatedAfter:  globalOpts.LastUpdatedAfter,
-			LastUpdatedBefore: globalOpts.LastUpdatedBefore,
-			// Deep research options
-			ReasoningEffort: globalOpts.ReasoningEffort,
-		}
-		c := chat.NewChatWithOptions(client, systemMessage, chatOptions)
+			return err
+		}
 
-		// discussion loop
-	loop:
-		for {
-			prompt, err := console.Input("Ask anything (enter to quit)")
+		// A resumed conversation keeps its system message and options.
+		autosavePath := chatAutosavePath()
+		saved := autosaveToResume(autosavePath, os.Stdin, os.Stdout)
+		input := newChatReader()
+		opts := newChatOptions()
+		systemMessage := ""
+		if saved != nil {
+			opts = saved.Options
+			opts.Warnings = warnings.Default
+		} else {
+			label := "system message (optional - enter to skip)"
+			if cfg.Defaults.SystemPrompt != "" {
+				label = "system message (optional - enter to use defaults.system_prompt)"
+			}
+			systemMessage, err = input.Read(label)
 			if err != nil {
-				return clerrors.NewIOError("failed to read prompt", err)
+				return clerrors.NewIOError("failed to read system message", err)
 			}
-			if prompt == "" {
-				break loop
+			if systemMessage == "" {
+				systemMessage = cfg.Defaults.SystemPrompt
 			}
-			err = c.AddUserMessage(prompt)
-			if err != nil {
-				return clerrors.NewAPIError("failed to add user message", err)
+		}
+		// With --stream, each answer is rendered as it arrives by a renderer
+		// created per answer.
+		var stream *render.StreamRenderer
+		if opts.Stream {
+			opts.OnStream = func(_, answer string) {
+				if err := stream.Update(answer); err != nil {
+					logger.Error("failed to render streaming content", "error", err)
+				}
+			}
+		}
+		var c *chat.Chat
+		if saved != nil {
+			if c, err = saved.Resume(client, opts); err != nil {
+				return clerrors.NewIOError("failed to resume the autosaved conversation", err)
+			}
+			pterm.Info.Printfln("Resumed the conversation saved %s (%d messages)",
+				saved.Saved.Local().Format(time.DateTime), len(saved.Messages))
+		} else {
+			c = chat.NewChatWithOptions(client, systemMessage, opts)
+		}
+		for _, hook := range requestHooks(cfg) {
+			c.Use(hook)
+		}
+		c.Use(usageHook{source: usage.SourceChat})
+
+		// Ctrl-C while an answer is on its way interrupts that answer only:
+		// the part received is kept and the next question is asked. A second
+		// Ctrl-C exits. The warnings of each answer are printed after it.
+		send := func(overrides ...chat.OptionOverride) error {
+			defer flushWarnings()
+			ctx, stop := interruptContext(cmd.Context())
+			defer stop()
+			if opts.Stream {
+				streamOut, smooth := streamOutput(os.Stdout)
+				stream = render.NewStreamRenderer(renderer, streamOut)
+				response, err := c.SendContext(ctx, overrides...)
+				if err := stream.Flush(); err != nil {
+					logger.Error("failed to render streaming content", "error", err)
+				}
+				finishStream(smooth, errors.Is(err, clerrors.ErrRequestInterrupted))
+				if errors.Is(err, clerrors.ErrRequestInterrupted) {
+					fmt.Println()
+					fmt.Println(interruptedMarker)
+					return nil
+				}
+				if err != nil {
+					return clerrors.NewAPIError("failed to run chat", retry.ClassifyTimeout(err))
+				}
+				reportTrimmed(c.Trimmed())
+				renderStreamedMetadata(os.Stdout, response, renderer, style)
+				reportChatFormat(c, response)
+				return nil
 			}
+
 			// Print spinner while waiting for the response
 			spinnerInfo, _ := pterm.DefaultSpinner.Start("Waiting after the response from perplexity...")
-			response, err := c.Run()
+			response, err := c.SendContext(ctx, overrides...)
+			if errors.Is(err, clerrors.ErrRequestInterrupted) {
+				spinnerInfo.Fail("Interrupted")
+				return nil
+			}
 			if err != nil {
-				return clerrors.NewAPIError("failed to run chat", err)
+				return clerrors.NewAPIError("failed to run chat", retry.ClassifyTimeout(err))
 			}
 			spinnerInfo.Success("Response received")
+			reportTrimmed(c.Trimmed())
 
-			err = c.AddAgentMessage(response.GetLastContent())
-			if err != nil {
-				return clerrors.NewAPIError("failed to add agent message", err)
+			// Only the printed answer is filtered: the conversation keeps the
+			// markers the model wrote.
+			filter.Apply(response)
+			if err := console.RenderAnswerWithCitations(response, os.Stdout, renderer, style); err != nil {
+				return clerrors.NewIOError("failed to render response", err)
 			}
-			err = console.RenderAsMarkdown(response, os.Stdout)
-			if err != nil {
-				return clerrors.NewIOError("failed to render markdown", err)
+			if err := renderSearches(os.Stdout, response); err != nil {
+				return err
 			}
-			err = console.RenderCitations(response, os.Stdout)
+			reportChatFormat(c, response)
+			return nil
+		}
+
+		// discussion loop
+	loop:
+		for {
+			prompt, err := input.Read("Ask anything (enter to quit, /help for commands)")
 			if err != nil {
-				return clerrors.NewIOError("failed to render citations", err)
+				return clerrors.NewIOError("failed to read prompt", err)
 			}
-			err = console.RenderImages(response, os.Stdout)
+			if prompt == "" {
+				break loop
+			}
+
+			// /with sets search options for this message only.
+			question, overrides, isWith, err := chat.ParseWith(prompt)
 			if err != nil {
-				return clerrors.NewIOError("failed to render images", err)
+				pterm.Error.Println(err)
+				continue
 			}
-			err = console.RenderRelatedQuestions(response, os.Stdout)
+			if isWith {
+				prompt = question
+			} else if chat.IsCommand(prompt) {
+				// Slash commands are handled locally and never sent to the API.
+				action, err := c.Execute(prompt, os.Stdout)
+				if err != nil {
+					pterm.Error.Println(err)
+					continue
+				}
+				switch action {
+				case chat.ActionResend:
+					if err := send(); err != nil {
+						return err
+					}
+				case chat.ActionFork:
+					// The session is saved; a failed copy keeps this one going.
+					if fork, err := c.Clone(); err != nil {
+						pterm.Error.Printfln("failed to fork the conversation, continuing in this one: %v", err)
+					} else {
+						c = fork
+					}
+				}
+				autosaveChat(c, autosavePath)
+				continue
+			}
+
+			err = c.AddUserMessage(prompt)
 			if err != nil {
-				return clerrors.NewIOError("failed to render related questions", err)
+				return clerrors.NewAPIError("failed to add user message", err)
+			}
+			if err := send(overrides...); err != nil {
+				return err
 			}
+			autosaveChat(c, autosavePath)
 		}
+		discardChatAutosave(c, autosavePath)
 		return nil
 	},
 }
+
+// newChatOptions builds the chat options from the merged global options.
+func newChatOptions() chat.Options {
+	return chat.Options{
+		Model:            globalOpts.Model,
+		FrequencyPenalty: globalOpts.FrequencyPenalty,
+		MaxTokens:        globalOpts.MaxTokens,
+		PresencePenalty:  globalOpts.PresencePenalty,
+		Temperature:      globalOpts.Temperature,
+		TopK:             globalOpts.TopK,
+		TopP:             globalOpts.TopP,
+		SearchDomains:    globalOpts.SearchDomains,
+		ExcludeDomains:   globalOpts.ExcludeDomains,
+		SearchRecency:    globalOpts.SearchRecency,
+		LocationLat:      globalOpts.LocationLat,
+		LocationLon:      globalOpts.LocationLon,
+		LocationCountry:  globalOpts.LocationCountry,
+		ReturnImages:     globalOpts.ReturnImages,
+		ReturnRelated:    globalOpts.ReturnRelated,
+		Stream:           globalOpts.Stream,
+		ImageDomains:     globalOpts.ImageDomains,
+		ImageFormats:     globalOpts.ImageFormats,
+		ImageConflictPolicy: globalOpts.ImageConflictPolicy,
+		StrictOptions:       globalOpts.StrictOptions,
+		// Response format options
+		ResponseFormatJSONSchema: globalOpts.ResponseFormatJSONSchema,
+		ResponseFormatRegex:      globalOpts.ResponseFormatRegex,
+		// Search mode options
+		SearchMode:        globalOpts.SearchMode,
+		SearchContextSize: globalOpts.SearchContextSize,
+		// Date filtering options
+		SearchAfterDate:   globalOpts.SearchAfterDate,
+		SearchBeforeDate:  globalOpts.SearchBeforeDate,
+		LastUpdatedAfter:  globalOpts.LastUpdatedAfter,
+		LastUpdatedBefore: globalOpts.LastUpdatedBefore,
+		// Deep research options
+		ReasoningEffort: globalOpts.ReasoningEffort,
+		Language:        globalOpts.Language,
+		// Context window options
+		MaxContextTokens: chatMaxContextTokens,
+		SummarizeOnTrim:  chatSummarizeOnTrim,
+		AutoContinue:     globalOpts.AutoContinue,
+		ModelFallbacks:   globalOpts.ModelFallbacks,
+		Warnings:         warnings.Default,
+	}
+}
+
+// addChatContextFlags registers the context window flags of the chat command.
+func addChatContextFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&chatMaxContextTokens, "max-context-tokens", 0,
+		"Estimated prompt tokens to keep; older messages are left out of requests (0 = unlimited)")
+	cmd.Flags().BoolVar(&chatSummarizeOnTrim, "summarize-on-trim", false,
+		"Replace messages left out by --max-context-tokens with a conversation summary")
+	cmd.Flags().BoolVar(&chatDryRun, "dry-run", false,
+		"Print the request of the first message, with option sources, without calling the API")
+}
+
+// reportTrimmed tells the user how many older messages the last request left out.
+func reportTrimmed(dropped int) {
+	if dropped == 0 {
+		return
+	}
+	if chatSummarizeOnTrim {
+		pterm.Info.Printfln("Summarized the %d oldest messages to fit --max-context-tokens %d",
+			dropped, chatMaxContextTokens)
+		return
+	}
+	pterm.Info.Printfln("Left out the %d oldest messages to fit --max-context-tokens %d",
+		dropped, chatMaxContextTokens)
+}
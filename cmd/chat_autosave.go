@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/warnings"
+	"github.com/spf13/cobra"
+)
+
+// Autosave flags of the chat command.
+var (
+	chatResumeLast bool
+	chatNoAutosave bool
+)
+
+// addChatAutosaveFlags registers the autosave flags of the chat command.
+func addChatAutosaveFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&chatResumeLast, "resume-last", false,
+		"Resume the autosaved conversation without asking")
+	cmd.Flags().BoolVar(&chatNoAutosave, "no-autosave", false,
+		"Neither autosave the conversation nor offer to resume the last one")
+	cmd.MarkFlagsMutuallyExclusive("resume-last", "no-autosave")
+}
+
+// chatAutosavePath returns the autosave file of the chat command, or "" when
+// autosave is disabled or its location is unknown.
+func chatAutosavePath() string {
+	if chatNoAutosave {
+		return ""
+	}
+	path, err := chat.DefaultAutosavePath()
+	if err != nil {
+		logger.Warn("chat autosave disabled", "error", err)
+		return ""
+	}
+	return path
+}
+
+// autosaveToResume returns the autosave at path the user chose to resume, or
+// nil. The user is asked on a terminal unless --resume-last is given, and a
+// declined autosave is removed. A corrupt autosave is reported as a warning
+// and ignored.
+func autosaveToResume(path string, in io.Reader, out io.Writer) *chat.Autosave {
+	if path == "" {
+		return nil
+	}
+	saved, err := chat.ReadAutosave(path)
+	if err != nil {
+		warnings.Add(warnings.CodeAutosave, fmt.Sprintf("ignoring the chat autosave: %v", err),
+			"it is replaced after the next answer")
+		flushWarnings()
+		return nil
+	}
+	if saved == nil || len(saved.Messages) == 0 {
+		if chatResumeLast {
+			pterm.Info.Println("No autosaved conversation to resume.")
+		}
+		return nil
+	}
+	if chatResumeLast {
+		return saved
+	}
+	if f, ok := in.(*os.File); ok && !render.IsTerminal(f) {
+		return nil
+	}
+	if !confirmResume(in, out, saved) {
+		// Declined: the next start should not offer it again.
+		if err := chat.RemoveAutosave(path); err != nil {
+			logger.Warn("failed to remove the chat autosave", "error", err)
+		}
+		return nil
+	}
+	return saved
+}
+
+// confirmResume asks whether to resume saved and reports a yes.
+func confirmResume(in io.Reader, out io.Writer, saved *chat.Autosave) bool {
+	fmt.Fprintf(out, "Resume the unfinished conversation from %s (%d messages, model %s)? [y/N] ",
+		saved.Saved.Local().Format(time.DateTime), len(saved.Messages), saved.Options.Model)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	return strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+}
+
+// autosaveChat saves the conversation to path, or removes the autosave when
+// the conversation is empty. Failures are logged, never fatal.
+func autosaveChat(c *chat.Chat, path string) {
+	if path == "" {
+		return
+	}
+	var err error
+	if len(c.Transcript().Messages) == 0 {
+		err = c.DiscardAutosave(path)
+	} else {
+		err = c.WriteAutosave(path)
+	}
+	if err != nil {
+		logger.Warn("failed to autosave the conversation", "error", err)
+	}
+}
+
+// discardChatAutosave removes the autosave of a session that ended cleanly.
+func discardChatAutosave(c *chat.Chat, path string) {
+	if path == "" {
+		return
+	}
+	if err := c.DiscardAutosave(path); err != nil {
+		logger.Warn("failed to remove the chat autosave", "error", err)
+	}
+}
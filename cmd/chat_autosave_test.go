@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// writeTestAutosave saves a one-turn conversation to a temporary autosave
+// and returns its path.
+func writeTestAutosave(t *testing.T) string {
+	t.Helper()
+	c := chat.NewChatWithOptions(nil, "Be brief.", chat.Options{Model: "sonar"})
+	_ = c.AddUserMessage("What is Go?")
+	_ = c.AddAgentMessage("A language.")
+	path := filepath.Join(t.TempDir(), "autosave.json")
+	if err := c.WriteAutosave(path); err != nil {
+		t.Fatalf("WriteAutosave() error = %v", err)
+	}
+	return path
+}
+
+func TestAutosaveToResume(t *testing.T) {
+	tests := []struct {
+		name       string
+		resumeLast bool
+		answer     string
+		wantResume bool
+		wantKept   bool
+	}{
+		{name: "yes", answer: "y\n", wantResume: true, wantKept: true},
+		{name: "YES", answer: "YES\n", wantResume: true, wantKept: true},
+		{name: "default no", answer: "\n", wantResume: false, wantKept: false},
+		{name: "resume-last skips the prompt", resumeLast: true, wantResume: true, wantKept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := chatResumeLast
+			t.Cleanup(func() { chatResumeLast = old })
+			chatResumeLast = tt.resumeLast
+			path := writeTestAutosave(t)
+
+			var out bytes.Buffer
+			saved := autosaveToResume(path, strings.NewReader(tt.answer), &out)
+			if (saved != nil) != tt.wantResume {
+				t.Fatalf("autosaveToResume() = %v, want a resume: %v", saved, tt.wantResume)
+			}
+			if tt.resumeLast != (out.Len() == 0) {
+				t.Errorf("prompt = %q, want one unless --resume-last", out.String())
+			}
+			if _, err := os.Stat(path); (err == nil) != tt.wantKept {
+				t.Errorf("autosave kept = %v, want %v", err == nil, tt.wantKept)
+			}
+			if saved != nil && (len(saved.Messages) != 2 || saved.SystemPrompt != "Be brief.") {
+				t.Errorf("resumed %+v, want the saved conversation", saved.Transcript)
+			}
+		})
+	}
+}
+
+func TestAutosaveToResume_Corrupt(t *testing.T) {
+	warnings.Default.Reset()
+	t.Cleanup(warnings.Default.Reset)
+	path := filepath.Join(t.TempDir(), "autosave.json")
+	if err := os.WriteFile(path, []byte(`{"messages": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runCapturingStdout(t, func() error {
+		if saved := autosaveToResume(path, strings.NewReader("y\n"), os.Stdout); saved != nil {
+			t.Errorf("autosaveToResume() = %+v, want a corrupt autosave ignored", saved)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("the corrupt autosave was removed: %v", err)
+	}
+}
+
+func TestAutosaveChat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pplx", "autosave.json")
+	c := chat.NewChatWithOptions(nil, "", chat.Options{Model: "sonar"})
+	_ = c.AddUserMessage("q")
+	_ = c.AddAgentMessage("a")
+
+	autosaveChat(c, path)
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("no autosave after a turn: %v", err)
+	}
+	c.Clear()
+	autosaveChat(c, path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the autosave of a cleared conversation was kept: %v", err)
+	}
+}
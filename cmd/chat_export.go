@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/sgaunet/pplx/pkg/chat"
+	clerrors "github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/spf13/cobra"
+)
+
+// Flags of the chat export command.
+var (
+	chatExportFormat string
+	chatExportOutput string
+)
+
+// chatExportFilePerms is the permission of files written by chat export.
+const chatExportFilePerms = 0o600
+
+// chatExportCmd converts a transcript saved with /save as JSON.
+var chatExportCmd = &cobra.Command{
+	Use:   "export <transcript.json>",
+	Short: "Convert a saved JSON chat transcript to markdown, json or html",
+	Long: `Convert a chat transcript saved with "/save <file>.json" to another format.
+
+JSON transcripts keep every detail of the session: the system prompt, the
+model, a timestamp per message, the sources of each answer, and per-answer
+token usage. Export renders them as:
+
+  markdown  ## User / ## Assistant sections, sources as footnotes
+  json      the transcript itself, re-indented
+  html      a standalone page with basic styling
+
+The format defaults to the extension of --output, or markdown on stdout.
+
+Examples:
+  pplx chat export session.json
+  pplx chat export session.json --format html -o session.html`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := chat.FormatMarkdown
+		if chatExportOutput != "" {
+			format = chat.FormatForPath(chatExportOutput)
+		}
+		if chatExportFormat != "" {
+			var err error
+			if format, err = chat.ParseFormat(chatExportFormat); err != nil {
+				return clerrors.NewValidationError("format", chatExportFormat, err.Error())
+			}
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			return clerrors.NewIOError("cannot open transcript", err)
+		}
+		defer func() { _ = f.Close() }()
+		transcript, err := chat.ReadTranscript(f)
+		if err != nil {
+			return clerrors.NewValidationError("transcript", args[0], err.Error())
+		}
+
+		if chatExportOutput == "" {
+			if err := transcript.Write(cmd.OutOrStdout(), format); err != nil {
+				return clerrors.NewIOError("failed to write transcript", err)
+			}
+			return nil
+		}
+		var buf bytes.Buffer
+		if err := transcript.Write(&buf, format); err != nil {
+			return clerrors.NewIOError("failed to write transcript", err)
+		}
+		if err := os.WriteFile(chatExportOutput, buf.Bytes(), chatExportFilePerms); err != nil {
+			return clerrors.NewIOError("cannot write "+chatExportOutput, err)
+		}
+		return nil
+	},
+}
+
+// addChatExportFlags registers the flags of the chat export command.
+func addChatExportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&chatExportFormat, "format", "",
+		"Output format: markdown, json, or html (default: by --output extension, else markdown)")
+	cmd.Flags().StringVarP(&chatExportOutput, "output", "o", "",
+		"Write the transcript to a file instead of stdout")
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTranscriptJSON = `{
+  "model": "sonar",
+  "system_prompt": "Be brief.",
+  "started": "2026-03-14T09:30:00Z",
+  "messages": [
+    {"role": "user", "content": "What is Go?", "timestamp": "2026-03-14T09:30:05Z"},
+    {"role": "assistant", "content": "A language [1].", "timestamp": "2026-03-14T09:30:08Z",
+     "model": "sonar", "sources": [{"title": "Go", "url": "https://go.dev"}]}
+  ]
+}`
+
+func TestChatExport(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "session.json")
+	if err := os.WriteFile(input, []byte(testTranscriptJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chatExportFormat, chatExportOutput = "", "" })
+
+	t.Run("markdown to stdout", func(t *testing.T) {
+		chatExportFormat, chatExportOutput = "", ""
+		var out strings.Builder
+		chatExportCmd.SetOut(&out)
+		t.Cleanup(func() { chatExportCmd.SetOut(nil) })
+		if err := chatExportCmd.RunE(chatExportCmd, []string{input}); err != nil {
+			t.Fatalf("RunE() error: %v", err)
+		}
+		for _, want := range []string{"## User", "A language [^1].", "[^1]: Go - https://go.dev", "_2026-03-14T09:30:05Z_"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("output missing %q:\n%s", want, out.String())
+			}
+		}
+	})
+
+	t.Run("format from output extension", func(t *testing.T) {
+		chatExportFormat, chatExportOutput = "", filepath.Join(dir, "session.html")
+		if err := chatExportCmd.RunE(chatExportCmd, []string{input}); err != nil {
+			t.Fatalf("RunE() error: %v", err)
+		}
+		data, err := os.ReadFile(chatExportOutput)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), "<!DOCTYPE html>") {
+			t.Errorf("expected an HTML page, got:\n%s", data)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		chatExportFormat, chatExportOutput = "pdf", ""
+		err := chatExportCmd.RunE(chatExportCmd, []string{input})
+		if getExitCode(err) != exitCodeValidation {
+			t.Errorf("err = %v, want a validation error", err)
+		}
+	})
+}
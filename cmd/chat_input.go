@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/sgaunet/pplx/pkg/console"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/spf13/cobra"
+)
+
+// chatNoReadline is the --no-readline flag of the chat command.
+var chatNoReadline bool
+
+// addChatInputFlags registers the input flags of the chat command.
+func addChatInputFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&chatNoReadline, "no-readline", false,
+		"Read questions without line editing or history, ending each with an empty line")
+}
+
+// newChatReader returns the reader of the chat questions: the line editor
+// with persistent history on a terminal, or the plain reader with
+// --no-readline, on a dumb terminal, or when stdin or stdout is not a
+// terminal.
+func newChatReader() console.Reader {
+	if chatNoReadline || os.Getenv("TERM") == "dumb" || !render.IsTerminal(os.Stdout) {
+		return console.PlainReader{}
+	}
+	path, err := console.DefaultHistoryPath()
+	if err != nil {
+		logger.Warn("chat history disabled", "error", err)
+	}
+	history, err := console.LoadHistory(path)
+	if err != nil {
+		logger.Warn("chat history not loaded, starting an empty one", "error", err)
+		history, _ = console.LoadHistory("")
+	}
+	editor, err := console.NewEditor(history)
+	if err != nil {
+		if !errors.Is(err, console.ErrNotTerminal) {
+			logger.Warn("line editing disabled", "error", err)
+		}
+		return console.PlainReader{}
+	}
+	return editor
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/output/color"
+	"github.com/spf13/cobra"
+)
+
+// colorMode is --color: whether pplx writes ANSI colors, given as auto,
+// always or never.
+var colorMode string
+
+// addColorFlag registers --color on cmd and its subcommands.
+func addColorFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&colorMode, "color", string(color.ModeAuto),
+		"Color output: "+strings.Join(color.Modes(), ", ")+
+			" (auto colors terminals and honors $NO_COLOR and $CLICOLOR_FORCE)")
+	if err := cmd.RegisterFlagCompletionFunc("color",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return color.Modes(), cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'color' flag: %v\n", err)
+	}
+}
+
+// applyColorMode validates --color and puts it into effect.
+func applyColorMode() error {
+	mode, err := color.ParseMode(colorMode)
+	if err != nil {
+		return clerrors.NewValidationError("color", colorMode,
+			"must be one of: "+strings.Join(color.Modes(), ", "))
+	}
+	color.Configure(mode)
+	return nil
+}
+
+// applyColorTheme selects the theme of output.theme, once the config is
+// applied to globalOpts. The theme is validated with the config, so an
+// unknown one falls back to the default.
+func applyColorTheme() {
+	theme, err := color.ParseTheme(globalOpts.Theme)
+	if err != nil {
+		theme = color.ThemeDefault
+	}
+	color.SetTheme(theme)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/output/color"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// withColor puts --color=mode and the theme into effect for the test.
+func withColor(t *testing.T, mode color.Mode, theme color.Theme) {
+	t.Helper()
+	saved := colorMode
+	t.Cleanup(func() {
+		colorMode = saved
+		color.Configure(color.ModeAuto)
+		color.SetTheme(color.ThemeDefault)
+	})
+	color.Configure(mode)
+	color.SetTheme(theme)
+}
+
+func TestApplyColorMode(t *testing.T) {
+	withColor(t, color.ModeAuto, color.ThemeDefault)
+
+	colorMode = "never"
+	if err := applyColorMode(); err != nil || color.CurrentMode() != color.ModeNever {
+		t.Errorf("applyColorMode() = %v, mode %q; want never", err, color.CurrentMode())
+	}
+	colorMode = "sometimes"
+	if err := applyColorMode(); err == nil || getExitCode(err) != 2 {
+		t.Errorf("applyColorMode() = %v, want a validation error", err)
+	}
+}
+
+func TestApplyColorTheme(t *testing.T) {
+	withGlobalOpts(t)
+	withColor(t, color.ModeAuto, color.ThemeDefault)
+
+	globalOpts.Theme = "high-contrast"
+	applyColorTheme()
+	if color.CurrentTheme() != color.ThemeHighContrast {
+		t.Errorf("theme = %q, want high-contrast", color.CurrentTheme())
+	}
+	globalOpts.Theme = ""
+	applyColorTheme()
+	if color.CurrentTheme() != color.ThemeDefault {
+		t.Errorf("theme = %q, want default", color.CurrentTheme())
+	}
+}
+
+// coloredSnapshots writes the warnings block, a config diff, and an answer
+// saved with --output, each colored as it would be in the current mode.
+func coloredSnapshots(t *testing.T) map[string]string {
+	t.Helper()
+	snapshots := map[string]string{}
+
+	var buf bytes.Buffer
+	list := []warnings.Warning{{Code: warnings.CodeTruncated, Message: "cut off"}}
+	if err := warnings.Write(&buf, list, color.Enabled(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	snapshots["warnings"] = buf.String()
+
+	buf.Reset()
+	changes := []config.ConfigChange{{Section: "defaults", Key: "defaults.model", Old: "sonar", New: "sonar-pro",
+		Changed: true}}
+	printConfigDiff(&buf, changes, color.Enabled(&buf))
+	snapshots["diff"] = buf.String()
+
+	queryOutput = "answer.md"
+	globalOpts.Render = string(render.ModeMarkdown)
+	renderer, err := answerRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots["answer"] = renderer.Render("# Findings\n\nGo is **fast** [1].")
+	return snapshots
+}
+
+func TestColorOff_NoEscapeSequences(t *testing.T) {
+	tests := []struct {
+		name string
+		mode color.Mode
+		env  map[string]string
+	}{
+		{"--color=never", color.ModeNever, map[string]string{"CLICOLOR_FORCE": "1"}},
+		{"NO_COLOR", color.ModeAuto, map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}},
+		{"piped", color.ModeAuto, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withAnswerOutputFlags(t)
+			t.Setenv("NO_COLOR", "")
+			t.Setenv("CLICOLOR_FORCE", "")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			withColor(t, tt.mode, color.ThemeHighContrast)
+
+			for name, out := range coloredSnapshots(t) {
+				if strings.Contains(out, "\x1b") {
+					t.Errorf("%s has escape sequences:\n%q", name, out)
+				}
+			}
+		})
+	}
+}
+
+func TestColorAlways_UsesTheme(t *testing.T) {
+	withAnswerOutputFlags(t)
+	t.Setenv("NO_COLOR", "1")
+	withColor(t, color.ModeAlways, color.ThemeHighContrast)
+
+	snapshots := coloredSnapshots(t)
+	theme := color.ThemeHighContrast
+	want := map[string]string{
+		"warnings": theme.Paint(color.Warning, "⚠ Warning:"),
+		"diff":     theme.Paint(color.Heading, "defaults"),
+		"answer":   theme.Paint(color.Citation, "[1]"),
+	}
+	for name, w := range want {
+		if !strings.Contains(snapshots[name], w) {
+			t.Errorf("%s = %q, want it to contain %q", name, snapshots[name], w)
+		}
+	}
+	if !strings.Contains(snapshots["diff"], theme.Paint(color.Success, "sonar-pro")) {
+		t.Errorf("diff = %q, want the new value in the success color", snapshots["diff"])
+	}
+}
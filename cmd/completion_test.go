@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/completion"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// flagCompletions invokes the completion function registered for flag on cmd.
+func flagCompletions(t *testing.T, cmd *cobra.Command, flag string) []string {
+	t.Helper()
+	fn, ok := cmd.GetFlagCompletionFunc(flag)
+	if !ok {
+		t.Fatalf("no completion registered for --%s on %s", flag, cmd.Name())
+	}
+	got, directive := fn(cmd, nil, "")
+	if directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("--%s directive = %v, want NoFileComp", flag, directive)
+	}
+	return got
+}
+
+// useConfigDir points config discovery at a temporary directory holding
+// content as config.yaml, or no config file when content is empty.
+func useConfigDir(t *testing.T, content string) {
+	t.Helper()
+	dir := t.TempDir()
+	if content != "" {
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldPaths := config.ConfigPaths
+	config.ConfigPaths = []string{dir}
+	t.Cleanup(func() { config.ConfigPaths = oldPaths })
+}
+
+func TestQueryFlagCompletions(t *testing.T) {
+	// An empty home directory has no model cache, so the known models are used.
+	t.Setenv("HOME", t.TempDir())
+
+	tests := []struct {
+		flag string
+		want []string
+	}{
+		{"model", completion.KnownModels()},
+		{"search-recency", []string{"hour", "day", "week", "month", "year"}},
+		{"search-mode", []string{"web", "academic"}},
+		{"search-context-size", []string{"low", "medium", "high"}},
+		{"reasoning-effort", []string{"low", "medium", "high"}},
+		{"image-formats", []string{"jpg", "jpeg", "png", "gif", "webp", "svg", "bmp"}},
+	}
+	for _, tt := range tests {
+		for _, cmd := range []*cobra.Command{queryCmd, chatCmd} {
+			if got := flagCompletions(t, cmd, tt.flag); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s --%s completions = %v, want %v", cmd.Name(), tt.flag, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestProfileFlagCompletion(t *testing.T) {
+	t.Run("profiles from config file", func(t *testing.T) {
+		useConfigDir(t, "profiles:\n  work:\n    name: work\n  research:\n    name: research\n")
+		want := []string{"default", "research", "work"}
+		if got := flagCompletions(t, queryCmd, "profile"); !reflect.DeepEqual(got, want) {
+			t.Errorf("--profile completions = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("no config file", func(t *testing.T) {
+		useConfigDir(t, "")
+		want := []string{"default"}
+		if got := flagCompletions(t, queryCmd, "profile"); !reflect.DeepEqual(got, want) {
+			t.Errorf("--profile completions = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestModelFlagCompletion_Aliases(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useConfigDir(t, "models:\n  aliases:\n    fast: sonar\n    deep: sonar-deep-research\n")
+
+	want := append(completion.KnownModels(), "deep", "fast")
+	if got := flagCompletions(t, queryCmd, "model"); !reflect.DeepEqual(got, want) {
+		t.Errorf("--model completions = %v, want %v", got, want)
+	}
+}
+
+func TestConfigSetCompletion(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	complete := configSetCmd.ValidArgsFunction
+
+	keys, _ := complete(configSetCmd, nil, "")
+	if !reflect.DeepEqual(keys, config.AllKeys()) {
+		t.Errorf("key completions = %v, want config.AllKeys()", keys)
+	}
+	for _, key := range []string{"defaults.model", "search.recency", "output.reasoning_effort", "api.timeout"} {
+		if !slices.Contains(keys, key) {
+			t.Errorf("key completions missing %s", key)
+		}
+	}
+
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"search.mode", []string{"web", "academic"}},
+		{"output.stream", []string{"true", "false"}},
+		{"defaults.model", completion.KnownModels()},
+		{"defaults.temperature", nil},
+	}
+	for _, tt := range tests {
+		got, _ := complete(configSetCmd, []string{tt.key}, "")
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("value completions for %s = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
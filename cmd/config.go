@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -40,7 +42,12 @@ var (
 	profileName       string
 	optionsSection    string
 	optionsFormat     string
+	validateFormat    string
+	validateStrict    bool
 	optionsValidation bool
+	// Config show flags.
+	showResolved bool
+	showTrace    bool
 	// Config init flags.
 	initTemplate     string
 	initWithExamples bool
@@ -50,10 +57,15 @@ var (
 	initInteractive  bool
 	initDryRun       bool
 	initUpdate       bool
+	initProject      bool
+	// initPresets holds the wizard answers given as flags.
+	initPresets WizardPresets
+	initStream  bool
 	// Config get flags.
-	getUnmask   bool
-	getJSON     bool
-	getProfile  string
+	getUnmask  bool
+	getJSON    bool
+	getProfile string
+	getRaw     bool
 	// Config set flags.
 	setProfile    string
 	setNoValidate bool
@@ -70,7 +82,8 @@ var (
 	// Profile diff flags.
 	profileDiffJSON bool
 	// Profile show flags.
-	profileShowJSON bool
+	profileShowJSON     bool
+	profileShowResolved bool
 )
 
 // saveConfigData saves configuration data to a file.
@@ -85,27 +98,44 @@ func saveConfigData(data *config.ConfigData) error {
 		return fmt.Errorf("failed to marshal config for %s: %w", configPath, err)
 	}
 
+	if err := backupConfig(configPath); err != nil {
+		return err
+	}
 	if err := os.WriteFile(configPath, yamlData, configFilePermission); err != nil {
 		return fmt.Errorf("failed to save config to %s: %w", configPath, err)
 	}
 
-	// Verify permissions after writing
-	if err := verifyConfigPermissions(configPath); err != nil {
-		// Don't fail the save operation, just log the warning
-		logger.Warn("config file permissions check failed", "error", err)
-	}
+	// Verify permissions after writing; a warning does not fail the save.
+	warnConfigPermissions(configPath)
 
 	return nil
 }
 
+// saveProfiles writes the profiles and active profile of data to the config
+// file, leaving its other keys untouched.
+func saveProfiles(data *config.ConfigData) error {
+	configPath := configWritePath()
+	if err := backupConfig(configPath); err != nil {
+		return err
+	}
+	if err := config.SaveProfiles(configPath, data); err != nil {
+		return fmt.Errorf("failed to save profiles: %w", err)
+	}
+	warnConfigPermissions(configPath)
+	return nil
+}
+
 // configCmd represents the config command.
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage pplx configuration",
 	Long: `Manage pplx configuration files and profiles.
 
-Configuration files are stored in ~/.config/pplx/
-Supported filenames: config.yaml, pplx.yaml, config.yml, pplx.yml
+Configuration files are stored in $XDG_CONFIG_HOME/pplx/ or ~/.config/pplx/
+(also ~/Library/Application Support/pplx/ on macOS and %APPDATA%\pplx\ on
+Windows). Supported filenames: config.yaml, pplx.yaml, config.yml, pplx.yml
+PPLX_CONFIG names a config file to use instead, and a .pplx.yaml file in the
+current directory is merged on top of the user config.
 
 Use subcommands to initialize, view, validate, or edit configuration.`,
 }
@@ -114,7 +144,12 @@ Use subcommands to initialize, view, validate, or edit configuration.`,
 var configInitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize a new configuration file",
-	Long: `Create a new configuration file at ~/.config/pplx/config.yaml
+	Long: `Create a new configuration file at ~/.config/pplx/config.yaml, or
+$XDG_CONFIG_HOME/pplx/config.yaml when XDG_CONFIG_HOME is set.
+
+Besides the built-in templates (research, creative, news, full-example),
+--template accepts the name of any YAML file in ~/.config/pplx/templates/.
+A user template replaces the built-in of the same name.
 
 Examples:
   # Create a minimal config with defaults
@@ -142,13 +177,49 @@ Examples:
   pplx config init --interactive --update
 
   # Preview what the wizard would produce without writing the file
-  pplx config init --interactive --dry-run`,
+  pplx config init --interactive --dry-run
+
+  # Create a commented .pplx.yaml with per-project defaults in the current directory
+  pplx config init --project
+
+  # Answer the wizard with flags, without prompting (for scripts)
+  pplx config init --use-case research --model sonar-pro --recency week --api-key-env PPLX_API_KEY
+
+  # Accept every wizard default without prompting
+  pplx config init --yes
+
+Any of --use-case, --model, --stream, --search-mode, --recency,
+--context-size, --api-key-env, or --yes runs the wizard without a terminal:
+the given answers are used and every other question takes its default.
+
+--project writes .pplx.yaml in the current directory instead: a project
+config, merged on top of the user config whenever pplx runs in this
+directory or below it. It cannot hold API keys.`,
 	RunE: runConfigInit,
 }
 
-// loadOrCreateConfig loads configuration based on init flags.
+// loadOrCreateConfig loads configuration based on init flags. New files are
+// written at the current schema version.
 func loadOrCreateConfig() (*config.ConfigData, error) {
+	cfg, err := buildInitConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Version = config.CurrentConfigVersion
+	return cfg, nil
+}
+
+// buildInitConfig returns the template, wizard, or minimal configuration
+// selected by the init flags.
+func buildInitConfig() (*config.ConfigData, error) {
 	switch {
+	case initPresets.IsSet():
+		if initTemplate != "" {
+			return nil, clerrors.NewValidationError("template", initTemplate,
+				"cannot be combined with wizard answer flags; use --use-case instead")
+		}
+		return loadOrCreateConfigInteractive()
+
 	case initInteractive:
 		return loadOrCreateConfigInteractive()
 
@@ -170,23 +241,23 @@ func loadOrCreateConfig() (*config.ConfigData, error) {
 	}
 }
 
-// loadOrCreateConfigInteractive handles the interactive wizard path, including --update mode.
+// loadOrCreateConfigInteractive handles the wizard path, including --update
+// mode. The wizard prompts unless answers were preset with flags.
 func loadOrCreateConfigInteractive() (*config.ConfigData, error) {
+	wizard := NewWizardState()
 	if initUpdate {
 		existing, err := loadExistingConfigForUpdate()
 		if err != nil {
 			return nil, err
 		}
-		wizard := NewWizardStateWithExisting(existing)
-		cfg, err := wizard.Run()
-		if err != nil {
-			return nil, fmt.Errorf("wizard failed: %w", err)
-		}
-		return cfg, nil
+		wizard = NewWizardStateWithExisting(existing)
 	}
 
-	wizard := NewWizardState()
-	cfg, err := wizard.Run()
+	run := wizard.Run
+	if initPresets.IsSet() {
+		run = func() (*config.ConfigData, error) { return wizard.RunNonInteractive(initPresets) }
+	}
+	cfg, err := run()
 	if err != nil {
 		return nil, fmt.Errorf("wizard failed: %w", err)
 	}
@@ -208,7 +279,8 @@ func loadExistingConfigForUpdate() (*config.ConfigData, error) {
 
 // generateYAMLContent generates YAML content from config.
 func generateYAMLContent(cfg *config.ConfigData) (string, error) {
-	if initWithExamples || initWithProfiles || initTemplate != "" || initInteractive {
+	wizard := initInteractive || initPresets.IsSet()
+	if initWithExamples || initWithProfiles || initTemplate != "" || wizard {
 		opts := config.DefaultAnnotationOptions()
 		opts.IncludeExamples = initWithExamples
 
@@ -216,7 +288,7 @@ func generateYAMLContent(cfg *config.ConfigData) (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("failed to generate annotated config: %w", err)
 		}
-		if !initInteractive {
+		if !wizard {
 			fmt.Println("Generated annotated configuration with descriptions")
 		}
 		return annotated, nil
@@ -231,8 +303,14 @@ func generateYAMLContent(cfg *config.ConfigData) (string, error) {
 }
 
 // runConfigInit implements the config init command logic.
-func runConfigInit(_ *cobra.Command, _ []string) error {
+func runConfigInit(cmd *cobra.Command, _ []string) error {
+	if initProject {
+		return runConfigInitProject(cmd)
+	}
 	configPath := resolveInitConfigPath()
+	if cmd != nil && cmd.Flags().Changed("stream") {
+		initPresets.Stream = &initStream
+	}
 
 	// --dry-run skips all filesystem checks and just prints the generated YAML.
 	if initDryRun {
@@ -254,6 +332,47 @@ func runConfigInit(_ *cobra.Command, _ []string) error {
 	return writeInitConfig(configPath)
 }
 
+// runConfigInitProject writes the project config scaffold in the current
+// directory. It takes --force and --dry-run; the options building a user
+// config do not apply.
+func runConfigInitProject(cmd *cobra.Command) error {
+	if cmd != nil {
+		for _, name := range []string{
+			"config", "template", "with-examples", "with-profiles", "interactive", "update", "check-env",
+			"use-case", "model", "stream", "search-mode", "recency", "context-size", "api-key-env", "yes",
+		} {
+			if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+				return clerrors.NewValidationError(name, flag.Value.String(), "cannot be combined with --project")
+			}
+		}
+	}
+
+	if initDryRun {
+		fmt.Println("--- dry-run: generated project configuration (not written to disk) ---")
+		fmt.Print(config.ProjectConfigScaffold)
+		fmt.Println("--- end dry-run ---")
+		return nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return clerrors.NewIOError("get working directory", err)
+	}
+	projectPath := filepath.Join(cwd, config.ProjectConfigName)
+	if _, err := os.Stat(projectPath); err == nil {
+		if !initForce {
+			return fmt.Errorf("%w at %s (use --force to overwrite)", clerrors.ErrConfigFileExists, projectPath)
+		}
+		fmt.Printf("Overwriting existing project configuration at %s\n", projectPath)
+	}
+
+	if err := os.WriteFile(projectPath, []byte(config.ProjectConfigScaffold), config.ProjectFilePermission); err != nil {
+		return fmt.Errorf("failed to write project config file to %s: %w", projectPath, err)
+	}
+	fmt.Printf("Project configuration file created at %s\n", projectPath)
+	return nil
+}
+
 // resolveInitConfigPath returns the config path from flag or default.
 func resolveInitConfigPath() string {
 	if configFilePath != "" {
@@ -298,6 +417,9 @@ func writeInitConfig(configPath string) error {
 		return err
 	}
 
+	if err := backupConfig(configPath); err != nil {
+		return err
+	}
 	if err := os.WriteFile(configPath, []byte(yamlContent), configFilePermission); err != nil {
 		return fmt.Errorf("failed to write config file to %s: %w", configPath, err)
 	}
@@ -353,25 +475,31 @@ func checkEnvironment() {
 		fmt.Printf("✓ PERPLEXITY_BASE_URL found: %s\n", baseURL)
 	}
 
+	for _, key := range config.AllKeys() {
+		if name := config.EnvVarName(key); os.Getenv(name) != "" && key != "api.key" {
+			fmt.Printf("✓ %s overrides %s\n", name, key)
+		}
+	}
+
 	fmt.Println()
 }
 
 // verifyConfigPermissions checks file permissions and warns if they are too permissive.
 // It returns an error only if the file cannot be accessed.
 func verifyConfigPermissions(path string) error {
-	info, err := os.Stat(path)
-	if err != nil {
+	if _, err := os.Stat(path); err != nil {
 		return fmt.Errorf("failed to check file permissions for %s: %w", path, err)
 	}
-
-	mode := info.Mode().Perm()
-	// Check if group or other have any permissions (0077 = group/other rwx bits)
-	if mode&0077 != 0 {
+	issue, err := config.CheckPermission(path, config.KindConfigFile, config.SecureFileMode)
+	if err != nil {
+		return err //nolint:wrapcheck // already names the file
+	}
+	if issue != nil {
 		logger.Warn("config file has insecure permissions",
 			"path", path,
-			"current_permissions", fmt.Sprintf("%#o", mode),
+			"current_permissions", fmt.Sprintf("%#o", issue.Mode),
 			"recommended_permissions", "0600",
-			"fix_command", "chmod 600 "+path,
+			"fix_command", "pplx config secure",
 			"reason", "file may contain API keys")
 	}
 
@@ -382,8 +510,19 @@ func verifyConfigPermissions(path string) error {
 var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current configuration",
-	Long:  `Display the current configuration, either from file or defaults.`,
-	RunE: func(_ *cobra.Command, _ []string) error {
+	Long: `Display the current configuration, either from file or defaults.
+
+Without --config, the project config (.pplx.yaml in the current directory or
+a parent, up to the git root) is merged on top of the user config, as for
+queries.
+
+--resolved prints the config file as loaded, with the files it lists under
+include: merged in. --trace also annotates every value with the file it came
+from, marking the values of the project config with (project).`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if showTrace && jsonOutput {
+			return clerrors.NewValidationError("trace", "true", "cannot be combined with --json")
+		}
 		loader := config.NewLoader()
 
 		if configFilePath != "" {
@@ -394,9 +533,19 @@ var configShowCmd = &cobra.Command{
 			if err := loader.Load(); err != nil {
 				return fmt.Errorf("failed to load config from default locations: %w", err)
 			}
+			if project, ok := config.FindProjectConfig(); ok {
+				if err := loader.MergeProject(project); err != nil {
+					return fmt.Errorf("failed to load project config from %s: %w", project, err)
+				}
+			}
+		}
+
+		if showResolved || showTrace {
+			return showResolvedConfig(cmd.OutOrStdout(), loader)
 		}
 
 		cfg := loader.Data()
+		out := cmd.OutOrStdout()
 
 		// If profile specified, show only that profile
 		if profileName != "" {
@@ -411,13 +560,13 @@ var configShowCmd = &cobra.Command{
 				if err != nil {
 					return fmt.Errorf("failed to marshal profile %q to JSON: %w", profileName, err)
 				}
-				fmt.Println(string(data))
+				fmt.Fprintln(out, string(data))
 			} else {
 				data, err := yaml.Marshal(profile)
 				if err != nil {
 					return fmt.Errorf("failed to marshal profile %q to YAML: %w", profileName, err)
 				}
-				fmt.Print(string(data))
+				fmt.Fprint(out, string(data))
 			}
 			return nil
 		}
@@ -425,23 +574,27 @@ var configShowCmd = &cobra.Command{
 		// Show full config - MASK API KEY
 		cfgCopy := *cfg
 
-		// Mask API key if present
+		// Mask API key and MCP auth token if present
 		if cfgCopy.API.Key != "" {
-			cfgCopy.API.Key = security.MaskAPIKey(cfgCopy.API.Key)
+			cfgCopy.API.Key = maskSecret(cfgCopy.API.Key)
 		}
+		if cfgCopy.API.MCPAuthToken != "" {
+			cfgCopy.API.MCPAuthToken = security.MaskAPIKey(cfgCopy.API.MCPAuthToken)
+		}
+		cfgCopy.API.Keys = maskSecretMap(cfgCopy.API.Keys)
 
 		if jsonOutput {
 			data, err := json.MarshalIndent(cfgCopy, "", "  ")
 			if err != nil {
 				return fmt.Errorf("failed to marshal config to JSON: %w", err)
 			}
-			fmt.Println(string(data))
+			fmt.Fprintln(out, string(data))
 		} else {
 			data, err := yaml.Marshal(cfgCopy)
 			if err != nil {
 				return fmt.Errorf("failed to marshal config to YAML: %w", err)
 			}
-			fmt.Print(string(data))
+			fmt.Fprint(out, string(data))
 		}
 
 		return nil
@@ -452,8 +605,22 @@ var configShowCmd = &cobra.Command{
 var configValidateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate configuration file",
-	Long:  `Check the configuration file for syntax errors and invalid values.`,
-	RunE: func(_ *cobra.Command, _ []string) error {
+	Long: `Check the configuration file for syntax errors and invalid values.
+
+Every violation is reported, one per line, prefixed with the file path. The
+command exits non-zero when any violation is found. Use --format json for
+machine-readable output in CI.
+
+Keys that no option reads, such as a misspelled "temprature:", are
+violations too, with the closest valid key suggested. With --strict=false
+they are only warnings, as when the config is loaded by other commands.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if validateFormat != validateFormatText && validateFormat != validateFormatJSON {
+			return clerrors.NewValidationError("format", validateFormat, "must be text or json")
+		}
+		// Violations are the report, not a usage mistake.
+		cmd.SilenceUsage = true
+
 		loader := config.NewLoader()
 
 		if configFilePath != "" {
@@ -469,18 +636,120 @@ var configValidateCmd = &cobra.Command{
 		cfg := loader.Data()
 
 		// Validate configuration
-		validator := config.NewValidator()
-		if err := validator.Validate(cfg); err != nil {
-			fmt.Println("Configuration validation failed:")
-			fmt.Println(err.Error())
+		validator := config.NewValidator().WithKeys(loader.Origins())
+		_ = validator.Validate(cfg)
+		violations := validator.Errors()
+
+		path := loader.Viper().ConfigFileUsed()
+		if path == "" {
+			path = defaultsConfigSource
+		}
+		if validateStrict {
+			violations = append(violations, unknownKeyViolations(path, loader.UnknownKeys())...)
+		} else {
+			for _, u := range loader.UnknownKeys() {
+				logger.Warn(u.Message(), "file", u.File, "line", u.Line)
+			}
+		}
+		if err := writeValidationReport(cmd.OutOrStdout(), path, violations, validateFormat); err != nil {
+			return err
+		}
+		if len(violations) > 0 {
 			return clerrors.ErrValidationFailed
 		}
-
-		fmt.Println("Configuration is valid ✓")
 		return nil
 	},
 }
 
+// unknownKeyViolations turns the unknown keys of the config file at path
+// into violations, located by line, and by file for keys of included files.
+func unknownKeyViolations(path string, unknown []config.UnknownKey) clerrors.ValidationErrors {
+	violations := make(clerrors.ValidationErrors, 0, len(unknown))
+	for _, u := range unknown {
+		location := fmt.Sprintf("line %d", u.Line)
+		if u.File != path {
+			location = fmt.Sprintf("%s:%d", u.File, u.Line)
+		}
+		violations = append(violations, clerrors.ValidationError{
+			Field:   u.Path,
+			Message: fmt.Sprintf("%s (%s)", u.Message(), location),
+		})
+	}
+	return violations
+}
+
+// Output formats of config validate.
+const (
+	validateFormatText = "text"
+	validateFormatJSON = "json"
+)
+
+// defaultsConfigSource names the validated source when no config file exists.
+const defaultsConfigSource = "(defaults)"
+
+// configViolation is one config validate finding in JSON output.
+type configViolation struct {
+	File    string `json:"file"`
+	Section string `json:"section"`
+	Field   string `json:"field"`
+	Value   string `json:"value,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// validationReport is the JSON output of config validate.
+type validationReport struct {
+	File       string            `json:"file"`
+	Valid      bool              `json:"valid"`
+	Violations []configViolation `json:"violations"`
+}
+
+// writeValidationReport writes the violations found in the config file at
+// path, either as text lines or as a JSON document.
+func writeValidationReport(out io.Writer, path string, errs clerrors.ValidationErrors, format string) error {
+	violations := make([]configViolation, 0, len(errs))
+	for _, e := range errs {
+		section, _, _ := strings.Cut(e.Field, ".")
+		violations = append(violations, configViolation{
+			File:    path,
+			Section: section,
+			Field:   e.Field,
+			Value:   e.Value,
+			Rule:    e.Rule,
+			Message: e.Message,
+		})
+	}
+
+	if format == validateFormatJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		report := validationReport{File: path, Valid: len(violations) == 0, Violations: violations}
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("failed to encode validation report: %w", err)
+		}
+		return nil
+	}
+
+	if len(violations) == 0 {
+		_, err := fmt.Fprintf(out, "%s: configuration is valid ✓\n", path)
+		return err //nolint:wrapcheck // stdout write
+	}
+	for _, v := range violations {
+		line := fmt.Sprintf("%s: %s", v.File, v.Field)
+		if v.Value != "" {
+			line += fmt.Sprintf(" = %q", v.Value)
+		}
+		line += ": " + v.Message
+		if v.Rule != "" {
+			line += fmt.Sprintf(" [rule: %s]", v.Rule)
+		}
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err //nolint:wrapcheck // stdout write
+		}
+	}
+	return nil
+}
+
 // configEditCmd opens the configuration file in an editor.
 var configEditCmd = &cobra.Command{
 	Use:   "edit",
@@ -521,7 +790,7 @@ var configEditCmd = &cobra.Command{
 			return nil
 		}
 
-		validator := config.NewValidator()
+		validator := config.NewValidator().WithKeys(loader.Origins())
 		if err := validator.Validate(loader.Data()); err != nil {
 			logger.Warn("configuration validation failed", "error", err)
 			return nil
@@ -538,7 +807,7 @@ var configOptionsCmd = &cobra.Command{
 	Short: "List all configuration options",
 	Long: `Display all available configuration options with their metadata.
 
-Options can be filtered by section (defaults, search, output, api) and
+Options can be filtered by section (defaults, search, output, api, prompts) and
 formatted as a table (default), JSON, or YAML.
 
 Examples:
@@ -560,7 +829,7 @@ Examples:
 }
 
 // runConfigOptions implements the config options command.
-func runConfigOptions(_ *cobra.Command, _ []string) error {
+func runConfigOptions(cmd *cobra.Command, _ []string) error {
 	// Create metadata registry
 	registry := config.NewMetadataRegistry()
 
@@ -569,7 +838,8 @@ func runConfigOptions(_ *cobra.Command, _ []string) error {
 	if optionsSection != "" {
 		options = registry.GetBySection(optionsSection)
 		if len(options) == 0 {
-			return fmt.Errorf("%w: %s (valid: defaults, search, output, api)", clerrors.ErrUnknownSection, optionsSection)
+			return fmt.Errorf("%w: %s (valid: %s)", clerrors.ErrUnknownSection, optionsSection,
+				strings.Join(registry.ListSections(), ", "))
 		}
 	} else {
 		options = registry.GetAll()
@@ -588,7 +858,7 @@ func runConfigOptions(_ *cobra.Command, _ []string) error {
 		return fmt.Errorf("failed to format options as %s: %w", optionsFormat, err)
 	}
 
-	fmt.Print(output)
+	fmt.Fprint(cmd.OutOrStdout(), output)
 	return nil
 }
 
@@ -599,45 +869,78 @@ var configPathCmd = &cobra.Command{
 	Long: `Display the active configuration file path and the full search order.
 
 The search order shows all locations where pplx looks for configuration files,
-with status indicators for each location.
+with status indicators for each location: $XDG_CONFIG_HOME/pplx, ~/.config/pplx,
+the platform's application data directory, then .pplx.yaml in the current
+directory or the nearest parent holding one, up to the root of the git
+repository, which is merged on top of the user config. When PPLX_CONFIG is
+set, it is the only file read.
 
 Examples:
   # Show active config and search paths
   pplx config path
 
+  # List every path searched, with an exists/missing marker
+  pplx config path --all
+
   # Validate configuration and show details
   pplx config path --check`,
 	RunE: runConfigPath,
 }
 
-var pathCheckFlag bool
+var (
+	pathCheckFlag bool
+	pathAllFlag   bool
+)
 
 // runConfigPath implements the config path command logic.
 func runConfigPath(_ *cobra.Command, _ []string) error {
+	if pathAllFlag {
+		printSearchPaths(os.Stdout, config.SearchPaths())
+		return nil
+	}
+
 	// Find active config file
 	activeConfig, err := config.FindConfigFile()
 	hasConfig := err == nil
+	project, hasProject := config.FindProjectConfig()
 
 	fmt.Println("Configuration File Search Order:")
 	fmt.Println()
 
-	// Get possible filenames
-	filenames := []string{"config.yaml", "pplx.yaml", "config.yml", "pplx.yml"}
-
-	// Iterate through search paths
-	for _, basePath := range config.ConfigPaths {
-		expandedPath := os.ExpandEnv(basePath)
-		fmt.Printf("📁 %s\n", expandedPath)
+	if envPath := os.Getenv(config.ConfigPathEnv); envPath != "" {
+		fmt.Printf("📌 %s\n", config.ConfigPathEnv)
+		fmt.Printf("   %s %s\n", getPathStatus(envPath, activeConfig), envPath)
+		fmt.Println()
+	} else {
+		// Iterate through search paths
+		for _, dir := range config.ConfigDirs() {
+			fmt.Printf("📁 %s\n", dir)
+
+			for _, filename := range config.ConfigFileNames() {
+				fullPath := filepath.Join(dir, filename)
+				status := getPathStatus(fullPath, activeConfig)
+				fmt.Printf("   %s %s\n", status, filename)
+			}
+			fmt.Println()
+		}
 
-		for _, filename := range filenames {
-			fullPath := filepath.Join(expandedPath, filename)
-			status := getPathStatus(fullPath, activeConfig)
-			fmt.Printf("   %s %s\n", status, filename)
+		projectPath := project
+		if !hasProject {
+			if cwd, err := os.Getwd(); err == nil {
+				projectPath = filepath.Join(cwd, config.ProjectConfigName)
+			}
+		}
+		if projectPath != "" {
+			fmt.Printf("📁 %s (project, merged on top)\n", filepath.Dir(projectPath))
+			fmt.Printf("   %s %s\n", getPathStatus(projectPath, project), config.ProjectConfigName)
+			fmt.Println()
 		}
-		fmt.Println()
 	}
 
 	// Show active configuration summary
+	if hasProject {
+		fmt.Printf("✓ Project configuration: %s\n", project)
+	}
 	if hasConfig {
 		fmt.Printf("✓ Active configuration: %s\n", activeConfig)
 	} else {
@@ -669,7 +972,7 @@ func runConfigPath(_ *cobra.Command, _ []string) error {
 		}
 
 		// Validate configuration
-		validator := config.NewValidator()
+		validator := config.NewValidator().WithKeys(loader.Origins())
 		if err := validator.Validate(cfg); err != nil {
 			fmt.Printf("  Status:   ✗ INVALID\n")
 			fmt.Println()
@@ -683,6 +986,18 @@ func runConfigPath(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// printSearchPaths writes every searched config path, one per line, with
+// an exists/missing marker and the kind of the path.
+func printSearchPaths(w io.Writer, paths []config.SearchPath) {
+	for _, p := range paths {
+		marker := "✗ missing"
+		if p.Exists {
+			marker = "✓ exists "
+		}
+		fmt.Fprintf(w, "%s  %-7s  %s\n", marker, p.Kind, p.Path)
+	}
+}
+
 // getPathStatus returns a status indicator for a config file path.
 func getPathStatus(path, activeConfig string) string {
 	info, err := os.Stat(path)
@@ -705,7 +1020,7 @@ func getPathStatus(path, activeConfig string) string {
 var configProfileCmd = &cobra.Command{
 	Use:   "profile",
 	Short: "Manage configuration profiles",
-	Long:  `Create, list, switch, and delete configuration profiles.`,
+	Long:  `Create, list, switch, rename, copy, and delete configuration profiles.`,
 }
 
 // configProfileListCmd lists all profiles.
@@ -713,12 +1028,12 @@ var configProfileListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all profiles",
 	RunE: func(_ *cobra.Command, _ []string) error {
-		loader := config.NewLoader()
-		if err := loader.Load(); err != nil {
+		data, err := loadConfigData(configFilePath)
+		if err != nil {
 			return fmt.Errorf("failed to load config for profile list: %w", err)
 		}
 
-		pm := config.NewProfileManager(loader.Data())
+		pm := config.NewProfileManager(data)
 		profiles := pm.ListProfiles()
 
 		activeProfile := pm.GetActiveProfileName()
@@ -742,7 +1057,7 @@ var configProfileCreateCmd = &cobra.Command{
 	Short: "Create a new profile",
 	Long: `Create a new configuration profile.
 
-Profiles can be created empty, from a built-in template, or copied from an
+Profiles can be created empty, from a built-in or user template, or copied from an
 existing profile. An optional description can be attached.
 
 Examples:
@@ -754,12 +1069,12 @@ Examples:
 	RunE: func(_ *cobra.Command, args []string) error {
 		name := args[0]
 
-		loader := config.NewLoader()
-		if err := loader.Load(); err != nil {
+		data, err := loadConfigData(configFilePath)
+		if err != nil {
 			return fmt.Errorf("failed to load config for profile creation: %w", err)
 		}
 
-		pm := config.NewProfileManager(loader.Data())
+		pm := config.NewProfileManager(data)
 
 		switch {
 		case createCopyFrom != "":
@@ -768,7 +1083,7 @@ Examples:
 			}
 			// Apply description override after clone.
 			if createDescription != "" {
-				profile, loadErr := pm.LoadProfile(name)
+				profile, loadErr := pm.RawProfile(name)
 				if loadErr != nil {
 					return fmt.Errorf("failed to load cloned profile %q: %w", name, loadErr)
 				}
@@ -795,7 +1110,7 @@ Examples:
 			}
 		}
 
-		if err := saveConfigData(loader.Data()); err != nil {
+		if err := saveProfiles(data); err != nil {
 			return err
 		}
 
@@ -958,18 +1273,17 @@ var configProfileSwitchCmd = &cobra.Command{
 	RunE: func(_ *cobra.Command, args []string) error {
 		profileName := args[0]
 
-		loader := config.NewLoader()
-		if err := loader.Load(); err != nil {
+		data, err := loadConfigData(configFilePath)
+		if err != nil {
 			return fmt.Errorf("failed to load config for profile switch: %w", err)
 		}
 
-		pm := config.NewProfileManager(loader.Data())
+		pm := config.NewProfileManager(data)
 		if err := pm.SetActiveProfile(profileName); err != nil {
 			return fmt.Errorf("failed to switch to profile %q: %w", profileName, err)
 		}
 
-		// Save config
-		if err := saveConfigData(loader.Data()); err != nil {
+		if err := saveProfiles(data); err != nil {
 			return err
 		}
 
@@ -982,24 +1296,36 @@ var configProfileSwitchCmd = &cobra.Command{
 var configProfileDeleteCmd = &cobra.Command{
 	Use:   "delete [name]",
 	Short: "Delete a profile",
-	Args:  cobra.ExactArgs(1),
+	Long: `Delete a configuration profile after asking for confirmation.
+
+The active profile cannot be deleted unless --force is given, in which case
+the active profile is reset to 'default'. Profiles that other profiles
+extend cannot be deleted.
+
+Examples:
+  pplx config profile delete old-research
+  pplx config profile delete research --force`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(_ *cobra.Command, args []string) error {
 		name := args[0]
 
-		loader := config.NewLoader()
-		if err := loader.Load(); err != nil {
+		data, err := loadConfigData(configFilePath)
+		if err != nil {
 			return fmt.Errorf("failed to load config for profile deletion: %w", err)
 		}
 
-		data := loader.Data()
 		pm := config.NewProfileManager(data)
 
-		profile, err := pm.LoadProfile(name)
+		profile, err := pm.RawProfile(name)
 		if err != nil {
 			return fmt.Errorf("failed to load profile %q: %w", name, err)
 		}
 
 		isActive := data.ActiveProfile == name
+		if isActive && !deleteForceFlag {
+			return fmt.Errorf("%w: '%s' (switch to another profile first, or use --force)",
+				clerrors.ErrDeleteActiveProfile, name)
+		}
 
 		if !deleteForceFlag {
 			// Print a brief summary so the user knows what they are deleting.
@@ -1007,9 +1333,6 @@ var configProfileDeleteCmd = &cobra.Command{
 			if profile.Description != "" {
 				fmt.Printf("Description: %s\n", profile.Description)
 			}
-			if isActive {
-				fmt.Println("Warning: this is the active profile. Deleting it will switch to 'default'.")
-			}
 			fmt.Print("Confirm (y/N): ")
 
 			scanner := bufio.NewScanner(os.Stdin)
@@ -1031,18 +1354,86 @@ var configProfileDeleteCmd = &cobra.Command{
 			return fmt.Errorf("failed to delete profile %q: %w", name, err)
 		}
 
-		if err := saveConfigData(data); err != nil {
+		if err := saveProfiles(data); err != nil {
 			return err
 		}
 
 		if isActive {
-			fmt.Printf("Active profile deleted; switched to '%s'.\n", config.DefaultProfileName)
+			logger.Warn("deleted the active profile, active profile reset",
+				"profile", name, "active_profile", config.DefaultProfileName)
 		}
 		fmt.Printf("Profile '%s' deleted successfully\n", name)
 		return nil
 	},
 }
 
+// configProfileRenameCmd renames a profile.
+var configProfileRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename a profile",
+	Long: `Rename a configuration profile.
+
+The active profile and the extends of profiles inheriting from it follow the
+new name.
+
+Examples:
+  pplx config profile rename research academic`,
+	Args: cobra.ExactArgs(2), //nolint:mnd // old and new name
+	RunE: func(_ *cobra.Command, args []string) error {
+		oldName, newName := args[0], args[1]
+
+		data, err := loadConfigData(configFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to load config for profile rename: %w", err)
+		}
+
+		pm := config.NewProfileManager(data)
+		if err := pm.RenameProfile(oldName, newName); err != nil {
+			return fmt.Errorf("failed to rename profile %q to %q: %w", oldName, newName, err)
+		}
+
+		if err := saveProfiles(data); err != nil {
+			return err
+		}
+
+		fmt.Printf("Profile '%s' renamed to '%s'\n", oldName, newName)
+		return nil
+	},
+}
+
+// configProfileCopyCmd copies a profile under a new name.
+var configProfileCopyCmd = &cobra.Command{
+	Use:   "copy <src> <dst>",
+	Short: "Copy a profile",
+	Long: `Copy a configuration profile under a new name.
+
+The copy keeps the settings, description, and extends of the source profile.
+
+Examples:
+  pplx config profile copy research research-2`,
+	Args: cobra.ExactArgs(2), //nolint:mnd // source and destination name
+	RunE: func(_ *cobra.Command, args []string) error {
+		src, dst := args[0], args[1]
+
+		data, err := loadConfigData(configFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to load config for profile copy: %w", err)
+		}
+
+		pm := config.NewProfileManager(data)
+		if _, err := pm.CloneProfile(dst, src); err != nil {
+			return fmt.Errorf("failed to copy profile %q to %q: %w", src, dst, err)
+		}
+
+		if err := saveProfiles(data); err != nil {
+			return err
+		}
+
+		fmt.Printf("Profile '%s' copied to '%s'\n", src, dst)
+		return nil
+	},
+}
+
 // configProfileDiffCmd compares two profiles or a profile against the base config.
 var configProfileDiffCmd = &cobra.Command{
 	Use:   "diff <profile1> [profile2]",
@@ -1103,9 +1494,14 @@ var configProfileShowCmd = &cobra.Command{
 	Short: "Show all settings in a profile",
 	Long: `Display the full configuration for a named profile.
 
+By default the profile is shown as written in the config file. With
+--resolved, settings inherited through "extends" are merged in, so the
+output shows every override the profile applies to the base config.
+
 Examples:
   pplx config profile show research
-  pplx config profile show research --json`,
+  pplx config profile show research --json
+  pplx config profile show deep-research --resolved`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(_ *cobra.Command, args []string) error {
 		name := args[0]
@@ -1116,7 +1512,11 @@ Examples:
 		}
 
 		pm := config.NewProfileManager(data)
-		profile, err := pm.LoadProfile(name)
+		load := pm.RawProfile
+		if profileShowResolved {
+			load = pm.LoadProfile
+		}
+		profile, err := load(name)
 		if err != nil {
 			return fmt.Errorf("failed to load profile %q: %w", name, err)
 		}
@@ -1139,6 +1539,109 @@ Examples:
 	},
 }
 
+// isSecretConfigKey reports whether the value of key must be masked on display.
+func isSecretConfigKey(key string) bool {
+	return key == "api.key" || key == "api.mcp_auth_token" || key == "api.keys"
+}
+
+// maskSecretMap returns a copy of keys, such as api.keys, with every value
+// masked. Nil stays nil.
+func maskSecretMap(keys map[string]string) map[string]string {
+	if keys == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(keys))
+	for id, key := range keys {
+		masked[id] = maskSecret(key)
+	}
+	return masked
+}
+
+// maskSecret masks a secret value for display. The "keyring" reference and
+// encrypted keys are not secrets and are shown as is.
+func maskSecret(value string) string {
+	if config.IsKeyringRef(value) || config.IsEncryptedKey(value) {
+		return value
+	}
+	return security.MaskAPIKey(value)
+}
+
+// showResolvedConfig prints to w the loaded document with its includes merged
+// and secrets masked. With --trace every value is annotated with its file.
+func showResolvedConfig(w io.Writer, loader *config.Loader) error {
+	doc := loader.Resolved()
+	if doc == nil {
+		fmt.Fprintln(os.Stderr, "No configuration file found, using defaults.")
+		return nil
+	}
+	doc = maskResolvedSecrets(doc)
+
+	var (
+		data []byte
+		err  error
+	)
+	switch {
+	case showTrace:
+		data, err = config.TraceDocument(doc, projectOrigins(loader.Origins()))
+	case jsonOutput:
+		data, err = json.MarshalIndent(doc, "", "  ")
+		data = append(data, '\n')
+	default:
+		data, err = yaml.Marshal(doc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal resolved config: %w", err)
+	}
+	fmt.Fprint(w, string(data))
+	return nil
+}
+
+// projectOrigins returns origins with the project config, when one was
+// found, labelled as such. origins itself is not modified.
+func projectOrigins(origins map[string]string) map[string]string {
+	project, ok := config.FindProjectConfig()
+	if !ok {
+		return origins
+	}
+	labelled := make(map[string]string, len(origins))
+	for key, file := range origins {
+		if file == project {
+			file += " (project)"
+		}
+		labelled[key] = file
+	}
+	return labelled
+}
+
+// maskResolvedSecrets returns doc with api.key, api.mcp_auth_token and the
+// values of api.keys masked.
+// doc itself is not modified.
+func maskResolvedSecrets(doc map[string]any) map[string]any {
+	api, ok := doc["api"].(map[string]any)
+	if !ok {
+		return doc
+	}
+	masked := maps.Clone(api)
+	for _, key := range []string{"key", "mcp_auth_token"} {
+		if value, ok := masked[key].(string); ok && value != "" {
+			masked[key] = maskSecret(value)
+		}
+	}
+	if keys, ok := masked["keys"].(map[string]any); ok {
+		maskedKeys := make(map[string]any, len(keys))
+		for id, value := range keys {
+			if s, isString := value.(string); isString {
+				value = maskSecret(s)
+			}
+			maskedKeys[id] = value
+		}
+		masked["keys"] = maskedKeys
+	}
+	out := maps.Clone(doc)
+	out["api"] = masked
+	return out
+}
+
 // loadConfigData loads configuration from a specific path or the default location.
 // When path is empty the standard search order is used.
 func loadConfigData(path string) (*config.ConfigData, error) {
@@ -1176,15 +1679,21 @@ func enumSuggestionsForKey(key string) []string {
 var configGetCmd = &cobra.Command{
 	Use:   "get <key>",
 	Short: "Get a configuration value",
-	Long: `Print the current value of a configuration option.
+	Long: `Print the effective value of a configuration option.
 
 Keys use dot-notation: <section>.<name> (e.g. api.key, defaults.model).
 A section-only key (e.g. "defaults") prints all options in that section as YAML.
 
+By default the value is shown as pplx would use it: environment variables
+are expanded and the active profile (or --profile) is merged in. Use --raw to
+print the literal value stored in the file instead; combined with --profile,
+--raw prints the profile's own override (or nothing if it has none).
+
 Examples:
   pplx config get defaults.model
   pplx config get api.key
-  pplx config get api.key --unmask
+  pplx config get api.key --unmask --raw
+  pplx config get defaults.temperature --profile research
   pplx config get search --json`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(_ *cobra.Command, args []string) error {
@@ -1195,25 +1704,18 @@ Examples:
 			return err
 		}
 
-		// If a specific profile is requested, apply its overrides.
-		if getProfile != "" {
-			pm := config.NewProfileManager(cfg)
-			merged, mergeErr := pm.MergeProfile(getProfile)
-			if mergeErr != nil {
-				return fmt.Errorf("failed to load profile %q: %w", getProfile, mergeErr)
-			}
-			cfg = merged
-		}
-
-		val, err := config.GetValue(cfg, key)
+		val, err := lookupConfigValue(cfg, key)
 		if err != nil {
 			return clerrors.NewConfigError(fmt.Sprintf("unknown key %q", key), err)
 		}
 
-		// Mask the API key unless the caller explicitly opted out.
-		if key == "api.key" && !getUnmask {
-			if strVal, ok := val.(string); ok {
-				val = security.MaskAPIKey(strVal)
+		// Mask secrets unless the caller explicitly opted out.
+		if isSecretConfigKey(key) && !getUnmask {
+			switch v := val.(type) {
+			case string:
+				val = maskSecret(v)
+			case map[string]string:
+				val = maskSecretMap(v)
 			}
 		}
 
@@ -1236,25 +1738,66 @@ Examples:
 			return nil
 		}
 
+		if val == nil {
+			return nil
+		}
 		fmt.Printf("%v\n", val)
 		return nil
 	},
 }
 
+// lookupConfigValue resolves key according to the --raw and --profile get flags.
+func lookupConfigValue(cfg *config.ConfigData, key string) (any, error) {
+	if getRaw {
+		if getProfile == "" {
+			return config.GetValue(cfg, key) //nolint:wrapcheck // wrapped by caller
+		}
+		profile, err := config.NewProfileManager(cfg).RawProfile(getProfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load profile %q: %w", getProfile, err)
+		}
+		return config.GetProfileValue(profile, key) //nolint:wrapcheck // wrapped by caller
+	}
+
+	config.ExpandEnvVars(cfg)
+
+	profileName := getProfile
+	if profileName == "" {
+		profileName = cfg.ActiveProfile
+	}
+	if profileName != "" && profileName != config.DefaultProfileName {
+		merged, err := config.NewProfileManager(cfg).MergeProfile(profileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load profile %q: %w", profileName, err)
+		}
+		cfg = merged
+	}
+
+	return config.GetValue(cfg, key) //nolint:wrapcheck // wrapped by caller
+}
+
 // configSetCmd sets a configuration value and persists it to disk.
 var configSetCmd = &cobra.Command{
 	Use:   "set <key> <value>",
 	Short: "Set a configuration value",
 	Long: `Set a configuration option and save it to the config file.
 
-The value is validated unless --no-validate is provided. For enum fields a
-"Did you mean?" suggestion is shown when the value is close to a valid one.
+The value is converted to the option's type (bool, int, float, duration, or
+a comma-separated list) and validated unless --no-validate is provided. For
+enum fields a "Did you mean?" suggestion is shown when the value is close to a
+valid one, and unknown keys list the closest option names.
+
+Only the edited key is rewritten: other keys, their order, and comments in the
+config file are preserved. With --profile the value is stored as an override
+in that profile.
 
 Examples:
   pplx config set defaults.model sonar-pro
   pplx config set defaults.temperature 0.7
   pplx config set search.recency week
-  pplx config set api.key pplx-my-secret-key`,
+  pplx config set search.domains example.com,golang.org
+  pplx config set api.timeout 45s
+  pplx config set defaults.model sonar-reasoning --profile research`,
 	Args: cobra.ExactArgs(configSetArgCount),
 	RunE: func(_ *cobra.Command, args []string) error {
 		key, value := args[0], args[1]
@@ -1265,23 +1808,26 @@ Examples:
 			cfg = config.NewConfigData()
 		}
 
-		// Profile-scoped set is not yet implemented at the SetValue level
-		// (profiles use pointer-based types). Surface a clear error.
-		if setProfile != "" {
-			return clerrors.NewConfigError(
-				"setting values in a specific profile via --profile is not yet supported; "+
-					"edit the profile section directly with: pplx config edit",
-				nil,
-			)
+		keyPath, err := configFileKeyPath(key, setProfile)
+		if err != nil {
+			return clerrors.NewConfigError(fmt.Sprintf("cannot set %q", key), err)
 		}
 
-		if err := config.SetValue(cfg, key, value); err != nil {
+		if setProfile != "" {
+			profile, loadErr := config.NewProfileManager(cfg).RawProfile(setProfile)
+			if loadErr != nil {
+				return clerrors.NewConfigError(fmt.Sprintf("cannot set %q", key), loadErr)
+			}
+			err = config.SetProfileValue(profile, key, value)
+		} else {
+			err = config.SetValue(cfg, key, value)
+		}
+		if err != nil {
 			return clerrors.NewConfigError(fmt.Sprintf("cannot set %q", key), err)
 		}
 
 		if !setNoValidate {
-			validator := config.NewValidator()
-			if valErr := validator.Validate(cfg); valErr != nil {
+			if valErr := validateAfterEdit(cfg, setProfile); valErr != nil {
 				// For enum-constrained fields, offer a typo suggestion.
 				if valid := enumSuggestionsForKey(key); valid != nil {
 					if suggestion := config.SuggestEnum(value, valid, enumSuggestMaxDistance); suggestion != "" {
@@ -1292,47 +1838,46 @@ Examples:
 			}
 		}
 
-		// Ensure the config directory and file exist before saving.
-		configPath, findErr := config.FindConfigFile()
-		if findErr != nil {
-			// No file found — create one at the default path.
-			configPath = config.GetDefaultConfigPath()
-			if err := os.MkdirAll(filepath.Dir(configPath), configDirPermission); err != nil {
-				return fmt.Errorf("failed to create config directory: %w", err)
-			}
-			if err := os.WriteFile(configPath, []byte{}, configFilePermission); err != nil {
-				return fmt.Errorf("failed to create config file at %s: %w", configPath, err)
-			}
+		typed, err := config.CoerceValue(key, value)
+		if err != nil {
+			return clerrors.NewConfigError(fmt.Sprintf("cannot set %q", key), err)
 		}
-		_ = configPath // saveConfigData resolves the path itself via FindConfigFile.
 
-		if err := saveConfigData(cfg); err != nil {
+		configPath := configWritePath()
+		if err := backupConfig(configPath); err != nil {
 			return err
 		}
+		if err := config.SetFileValue(configPath, keyPath, typed); err != nil {
+			return err //nolint:wrapcheck // SetFileValue errors already name the file
+		}
+		warnConfigPermissions(configPath)
 
 		fmt.Printf("Set %s = %s\n", key, value)
 		return nil
 	},
 }
 
-// configUnsetCmd removes a value from the configuration by resetting it to the zero value.
+// configUnsetCmd removes a value from the configuration file.
 var configUnsetCmd = &cobra.Command{
 	Use:   "unset <key>",
 	Short: "Remove a configuration value",
-	Long: `Reset a configuration option to its zero value (empty string, 0, false, etc.).
+	Long: `Remove a configuration option from the config file so its default applies.
 
-Required fields (such as api.key) cannot be unset.
+With --profile the override is removed from that profile, so the base
+configuration value applies again. Required fields (such as api.key) cannot
+be unset.
 
 Examples:
   pplx config unset defaults.model
-  pplx config unset search.recency`,
+  pplx config unset search.recency
+  pplx config unset defaults.temperature --profile research`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(_ *cobra.Command, args []string) error {
 		key := args[0]
 
 		// Refuse to unset required fields.
 		reg := config.NewMetadataRegistry()
-		if meta, err := reg.GetOption(key); err == nil && meta.Required {
+		if meta, err := reg.GetOption(key); err == nil && meta.Required && unsetProfile == "" {
 			return clerrors.NewConfigError(
 				fmt.Sprintf("cannot unset required field %q", key), nil)
 		}
@@ -1342,41 +1887,95 @@ Examples:
 			return err
 		}
 
-		// Profile-scoped unset follows the same limitation as set.
-		if unsetProfile != "" {
-			return clerrors.NewConfigError(
-				"unsetting values in a specific profile via --profile is not yet supported",
-				nil,
-			)
+		keyPath, err := configFileKeyPath(key, unsetProfile)
+		if err != nil {
+			return clerrors.NewConfigError(fmt.Sprintf("cannot unset %q", key), err)
 		}
 
 		// Capture the previous value for display before clearing it.
-		prev, getErr := config.GetValue(cfg, key)
-		if getErr != nil {
-			return clerrors.NewConfigError(fmt.Sprintf("unknown key %q", key), getErr)
+		var prev any
+		if unsetProfile != "" {
+			profile, loadErr := config.NewProfileManager(cfg).RawProfile(unsetProfile)
+			if loadErr != nil {
+				return clerrors.NewConfigError(fmt.Sprintf("cannot unset %q", key), loadErr)
+			}
+			if prev, err = config.GetProfileValue(profile, key); err == nil {
+				err = config.UnsetProfileValue(profile, key)
+			}
+		} else if prev, err = config.GetValue(cfg, key); err == nil {
+			err = config.UnsetValue(cfg, key)
+		}
+		if err != nil {
+			return clerrors.NewConfigError(fmt.Sprintf("cannot unset %q", key), err)
 		}
 
-		// Mask the API key in display output.
+		// Mask secrets in display output.
 		displayPrev := fmt.Sprintf("%v", prev)
-		if key == "api.key" {
+		if isSecretConfigKey(key) {
 			if strVal, ok := prev.(string); ok {
-				displayPrev = security.MaskAPIKey(strVal)
+				displayPrev = maskSecret(strVal)
 			}
 		}
 
-		if err := config.SetValue(cfg, key, ""); err != nil {
-			return clerrors.NewConfigError(fmt.Sprintf("cannot unset %q", key), err)
-		}
-
-		if err := saveConfigData(cfg); err != nil {
+		configPath := configWritePath()
+		if err := backupConfig(configPath); err != nil {
 			return err
 		}
+		if err := config.UnsetFileValue(configPath, keyPath); err != nil {
+			return err //nolint:wrapcheck // UnsetFileValue errors already name the file
+		}
 
 		fmt.Printf("Unset %s (was: %s)\n", key, displayPrev)
 		return nil
 	},
 }
 
+// configFileKeyPath maps a "section.name" key to its path in the YAML file,
+// nesting it under profiles.<profile> when profile is set. A nested name
+// such as api.tls.ca_file maps to a nested mapping.
+func configFileKeyPath(key, profile string) ([]string, error) {
+	if err := config.CheckKey(key); err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by caller
+	}
+	path := strings.Split(key, ".")
+	if profile == "" {
+		return path, nil
+	}
+	return append([]string{"profiles", profile}, path...), nil
+}
+
+// configWritePath returns the file edited by config set/unset: the --config
+// file when given, otherwise the discovered config file or the default path.
+func configWritePath() string {
+	if configFilePath != "" {
+		return configFilePath
+	}
+	if path, err := config.FindConfigFile(); err == nil {
+		return path
+	}
+	return config.GetDefaultConfigPath()
+}
+
+// validateAfterEdit validates cfg, merging profile first so that profile
+// overrides are checked with the same rules as base values.
+func validateAfterEdit(cfg *config.ConfigData, profile string) error {
+	if profile != "" {
+		merged, err := config.NewProfileManager(cfg).MergeProfile(profile)
+		if err != nil {
+			return fmt.Errorf("failed to load profile %q: %w", profile, err)
+		}
+		cfg = merged
+	}
+	return config.NewValidator().Validate(cfg) //nolint:wrapcheck // ValidationErrors are user-facing as-is
+}
+
+// warnConfigPermissions logs a warning when the config file permissions are too open.
+func warnConfigPermissions(path string) {
+	if err := verifyConfigPermissions(path); err != nil {
+		logger.Warn("config file permissions check failed", "error", err)
+	}
+}
+
 // configResetCmd resets one or all configuration values to their defaults.
 var configResetCmd = &cobra.Command{
 	Use:   "reset [key]",
@@ -1413,7 +2012,13 @@ Examples:
 				defaultStr = fmt.Sprintf("%v", meta.Default)
 			}
 
-			if setErr := config.SetValue(cfg, key, defaultStr); setErr != nil {
+			var setErr error
+			if meta.Default == nil {
+				setErr = config.UnsetValue(cfg, key)
+			} else {
+				setErr = config.SetValue(cfg, key, defaultStr)
+			}
+			if setErr != nil {
 				return clerrors.NewConfigError(
 					fmt.Sprintf("cannot reset %q to default %q", key, defaultStr), setErr)
 			}
@@ -1530,31 +2135,45 @@ var configMigrateCmd = &cobra.Command{
 	Short: "Migrate configuration to latest version",
 	Long: `Check the configuration schema version and apply any pending migrations.
 
+Each migration step upgrades the file by one version, for example by renaming
+keys of older releases (search.recency_filter → search.recency) or rewriting
+values they accepted (recency "daily" → "day", timeout 30 → "30s"). The file
+is edited in place, keeping its comments, and the original is saved next to it
+as config.yaml.bak, and in backups/ like every rewrite. Every change is listed.
+
 If the configuration is already at the latest version, no changes are made.
 
 Examples:
-  pplx config migrate`,
-	RunE: func(_ *cobra.Command, _ []string) error {
-		cfg, err := loadConfigData(configFilePath)
-		if err != nil {
-			return fmt.Errorf("failed to load config: %w", err)
+  pplx config migrate
+  pplx config migrate --config ./old-config.yaml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		configPath := configWritePath()
+		if _, err := os.Stat(configPath); err != nil {
+			return clerrors.NewConfigError("no configuration file to migrate at "+configPath, err)
+		}
+
+		if version, err := config.FileVersion(configPath); err == nil && version < config.CurrentConfigVersion {
+			if err := backupConfig(configPath); err != nil {
+				return err
+			}
 		}
-
-		migrated, summary, err := config.MigrateConfig(cfg)
+		result, err := config.MigrateFile(configPath)
 		if err != nil {
-			return fmt.Errorf("migration failed: %w", err)
+			return clerrors.NewConfigError("migration failed", err)
 		}
 
-		if !migrated {
-			fmt.Println("Configuration is already at the latest version.")
+		out := cmd.OutOrStdout()
+		if !result.Migrated() {
+			fmt.Fprintf(out, "Configuration is already at the latest version (%d).\n", result.ToVersion)
 			return nil
 		}
 
-		if err := saveConfigData(cfg); err != nil {
-			return err
+		fmt.Fprintf(out, "Migrated %s from version %d to %d:\n", configPath, result.FromVersion, result.ToVersion)
+		for _, change := range result.Changes {
+			fmt.Fprintf(out, "  - %s\n", change)
 		}
-
-		fmt.Printf("Migration complete: %s\n", summary)
+		fmt.Fprintf(out, "Backup written to %s\n", result.BackupPath)
 		return nil
 	},
 }
@@ -1567,6 +2186,8 @@ func init() {
 	registerGetSetUnsetResetFlags()
 	registerProfileFlags()
 	registerDoctorFlags()
+	registerSecureFlags()
+	registerConfigDiffFlags()
 	registerConfigFlagCompletions()
 }
 
@@ -1583,7 +2204,10 @@ func registerConfigSubcommands() {
 	configProfileCmd.AddCommand(configProfileListCmd)
 	configProfileCmd.AddCommand(configProfileCreateCmd)
 	configProfileCmd.AddCommand(configProfileSwitchCmd)
+	configProfileCmd.AddCommand(configProfileUseCmd)
 	configProfileCmd.AddCommand(configProfileDeleteCmd)
+	configProfileCmd.AddCommand(configProfileRenameCmd)
+	configProfileCmd.AddCommand(configProfileCopyCmd)
 	configProfileCmd.AddCommand(configProfileDiffCmd)
 	configProfileCmd.AddCommand(configProfileShowCmd)
 	configProfileCmd.AddCommand(configProfileEditCmd)
@@ -1591,12 +2215,21 @@ func registerConfigSubcommands() {
 	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configSetKeyCmd)
+	configCmd.AddCommand(configUnsetKeyCmd)
+	configCmd.AddCommand(configEncryptKeyCmd)
+	configCmd.AddCommand(configDecryptKeyCmd)
 	configCmd.AddCommand(configResetCmd)
 
 	configCmd.AddCommand(configExportCmd)
 	configCmd.AddCommand(configImportCmd)
 	configCmd.AddCommand(configMigrateCmd)
+	configCmd.AddCommand(configBackupsCmd)
+	configCmd.AddCommand(configRestoreCmd)
+	configBackupsCmd.AddCommand(configBackupsListCmd)
 	configCmd.AddCommand(configDoctorCmd)
+	configCmd.AddCommand(configSecureCmd)
+	configCmd.AddCommand(configDiffCmd)
 }
 
 // registerConfigFlags registers flags for the existing config subcommands.
@@ -1605,7 +2238,7 @@ func registerConfigFlags() {
 
 	configInitCmd.Flags().StringVarP(
 		&initTemplate, "template", "t", "",
-		"Template to use (research, creative, news, full-example)")
+		"Template to use (research, creative, news, full-example, or a file in ~/.config/pplx/templates)")
 	configInitCmd.Flags().BoolVar(
 		&initWithExamples, "with-examples", false,
 		"Include example profiles in configuration")
@@ -1627,17 +2260,58 @@ func registerConfigFlags() {
 	configInitCmd.Flags().BoolVar(
 		&initUpdate, "update", false,
 		"Update existing config: load current values and only change what you specify")
+	configInitCmd.Flags().BoolVar(
+		&initProject, "project", false,
+		"Write a commented .pplx.yaml with per-project defaults in the current directory")
+	configInitCmd.Flags().StringVar(
+		&initPresets.UseCase, "use-case", "",
+		"Wizard answer without prompting: research, creative, news, general, or custom")
+	configInitCmd.Flags().StringVar(
+		&initPresets.Model, "model", "",
+		"Wizard answer without prompting: default model")
+	configInitCmd.Flags().BoolVar(
+		&initStream, "stream", true,
+		"Wizard answer without prompting: enable streaming")
+	configInitCmd.Flags().StringVar(
+		&initPresets.SearchMode, "search-mode", "",
+		"Wizard answer without prompting: search mode (web, academic)")
+	configInitCmd.Flags().StringVar(
+		&initPresets.Recency, "recency", "",
+		"Wizard answer without prompting: search recency (hour, day, week, month, year)")
+	configInitCmd.Flags().StringVar(
+		&initPresets.ContextSize, "context-size", "",
+		"Wizard answer without prompting: search context size (low, medium, high)")
+	configInitCmd.Flags().StringVar(
+		&initPresets.APIKeyEnv, "api-key-env", "",
+		"Wizard answer without prompting: environment variable the config reads the API key from")
+	configInitCmd.Flags().BoolVarP(
+		&initPresets.Yes, "yes", "y", false,
+		"Run the wizard without prompting, accepting the default of every question not given as a flag")
 
 	configPathCmd.Flags().BoolVarP(
 		&pathCheckFlag, "check", "c", false,
 		"Validate configuration and show details")
+	configPathCmd.Flags().BoolVar(
+		&pathAllFlag, "all", false,
+		"List every path searched, with an exists/missing marker")
 
 	configShowCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
 	configShowCmd.Flags().StringVar(&profileName, "profile", "", "Show specific profile")
+	configShowCmd.Flags().BoolVar(&showResolved, "resolved", false,
+		"Show the config file with its includes merged")
+	configShowCmd.Flags().BoolVar(&showTrace, "trace", false,
+		"With --resolved, annotate each value with the file it came from")
+
+	configValidateCmd.Flags().StringVar(
+		&validateFormat, "format", validateFormatText,
+		"Output format (text, json)")
+	configValidateCmd.Flags().BoolVar(
+		&validateStrict, "strict", true,
+		"Report unknown keys as errors (--strict=false only warns)")
 
 	configOptionsCmd.Flags().StringVarP(
 		&optionsSection, "section", "s", "",
-		"Filter by section (defaults, search, output, api)")
+		"Filter by section (defaults, search, output, api, prompts)")
 	configOptionsCmd.Flags().StringVarP(
 		&optionsFormat, "format", "f", "table",
 		"Output format (table, json, yaml)")
@@ -1653,9 +2327,10 @@ func registerConfigFlags() {
 // registerGetSetUnsetResetFlags registers flags for the get, set, unset, and reset subcommands.
 func registerGetSetUnsetResetFlags() {
 	// Flags for get command
-	configGetCmd.Flags().BoolVar(&getUnmask, "unmask", false, "Show the full API key without masking")
+	configGetCmd.Flags().BoolVar(&getUnmask, "unmask", false, "Show secrets such as the API key without masking")
 	configGetCmd.Flags().BoolVar(&getJSON, "json", false, "Output value as JSON")
 	configGetCmd.Flags().StringVar(&getProfile, "profile", "", "Read value from specific profile (merged)")
+	configGetCmd.Flags().BoolVar(&getRaw, "raw", false, "Print the literal file value without env expansion or profile merge")
 
 	// Flags for set command
 	configSetCmd.Flags().StringVar(&setProfile, "profile", "", "Set value in specific profile")
@@ -1673,7 +2348,7 @@ func registerProfileFlags() {
 	// Flags for profile create command.
 	configProfileCreateCmd.Flags().StringVar(
 		&createFromTemplate, "from-template", "",
-		"Create profile from a built-in template (research, creative, news, full-example)")
+		"Create profile from a template (research, creative, news, full-example, or a user template)")
 	configProfileCreateCmd.Flags().StringVar(
 		&createCopyFrom, "copy-from", "",
 		"Copy an existing profile as the basis for the new profile")
@@ -1684,7 +2359,12 @@ func registerProfileFlags() {
 	// Flags for profile delete command.
 	configProfileDeleteCmd.Flags().BoolVarP(
 		&deleteForceFlag, "force", "f", false,
-		"Skip confirmation prompt")
+		"Skip confirmation prompt and allow deleting the active profile")
+
+	// Flags for profile use command.
+	configProfileUseCmd.Flags().BoolVar(
+		&profileUseShow, "show", false,
+		"Print the effective configuration after switching")
 
 	// Flags for profile diff command.
 	configProfileDiffCmd.Flags().BoolVar(
@@ -1695,6 +2375,9 @@ func registerProfileFlags() {
 	configProfileShowCmd.Flags().BoolVar(
 		&profileShowJSON, "json", false,
 		"Output profile as JSON")
+	configProfileShowCmd.Flags().BoolVar(
+		&profileShowResolved, "resolved", false,
+		"Merge settings inherited through extends")
 }
 
 // registerDoctorFlags registers flags for the config doctor subcommand.
@@ -1708,7 +2391,7 @@ func registerConfigFlagCompletions() {
 	// Template name completion for config init --template
 	if err := configInitCmd.RegisterFlagCompletionFunc("template",
 		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
-			return completion.TemplateNames(), cobra.ShellCompDirectiveNoFileComp
+			return completion.TemplateCompletions(), cobra.ShellCompDirectiveNoFileComp
 		}); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'template' flag: %v\n", err)
 	}
@@ -1790,6 +2473,14 @@ func registerProfileArgCompletions() {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
+	// config profile use [name]
+	configProfileUseCmd.ValidArgsFunction = func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completion.ProfileNames(), cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
 	// config profile delete <name>
 	configProfileDeleteCmd.ValidArgsFunction = func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
 		if len(args) == 0 {
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+// configBackupsCmd groups the commands on config file backups.
+var configBackupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "Manage backups of the config file",
+	Long: `Commands rewriting the config file (init --force, set, unset, profile
+create/delete/rename/copy/switch, migrate, encrypt-key, and others) first copy
+it to backups/config-<timestamp>.yaml next to it, with the same file mode.
+The most recent output.config_backups backups are kept (default 10).
+
+Use 'pplx config restore' to put a backup back.`,
+}
+
+// configBackupsListCmd lists the backups of the config file.
+var configBackupsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List backups of the config file, newest first",
+	Long: `List the backups of the config file, newest first, with the timestamp
+'pplx config restore' takes.
+
+Examples:
+  pplx config backups list
+  pplx config backups list --config ./config.yaml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		configPath := configWritePath()
+		backups, err := config.ListBackups(configPath)
+		if err != nil {
+			return clerrors.NewIOError("list config backups", err)
+		}
+		printBackups(cmd.OutOrStdout(), configPath, backups)
+		return nil
+	},
+}
+
+// configRestoreCmd replaces the config file with one of its backups.
+var configRestoreCmd = &cobra.Command{
+	Use:   "restore <timestamp|latest>",
+	Short: "Restore the config file from a backup",
+	Long: `Replace the config file with one of its backups, named by the timestamp
+'pplx config backups list' shows, or "latest" for the most recent one.
+
+The backup is loaded and validated first: a backup that does not parse or
+fails validation is rejected and the config file is left untouched. The
+replaced config file is itself saved as a new backup, so a restore can be
+undone by restoring that one.
+
+Examples:
+  pplx config restore latest
+  pplx config restore 20250114-093012.511`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath := configWritePath()
+		restored, displaced, err := config.RestoreBackup(configPath, args[0], configBackupLimit(configPath))
+		if err != nil {
+			return clerrors.NewConfigError("cannot restore "+configPath, err)
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "Restored %s from backup %s\n", configPath, restored.Timestamp)
+		if displaced != "" {
+			fmt.Fprintf(out, "Previous configuration saved to %s\n", displaced)
+		}
+		warnConfigPermissions(configPath)
+		return nil
+	},
+}
+
+// printBackups writes the backups of configPath as a table.
+func printBackups(w io.Writer, configPath string, backups []config.Backup) {
+	if len(backups) == 0 {
+		fmt.Fprintf(w, "No backups of %s in %s\n", configPath, config.BackupDir(configPath))
+		return
+	}
+	fmt.Fprintf(w, "Backups of %s:\n", configPath)
+	for _, b := range backups {
+		fmt.Fprintf(w, "  %-20s  %8s  %s\n", b.Timestamp, config.FormatFileSize(b.Size), b.Path)
+	}
+}
+
+// backupConfig copies the config file at path to its backups before a
+// command rewrites it. A missing file is not backed up.
+func backupConfig(path string) error {
+	backup, err := config.BackupConfigFile(path, configBackupLimit(path))
+	if err != nil {
+		return clerrors.NewIOError("back up config file "+path, err)
+	}
+	if backup != "" {
+		logger.Debug("config file backed up", "path", path, "backup", backup)
+	}
+	return nil
+}
+
+// configBackupLimit returns output.config_backups of the config file at
+// path; a file that does not load keeps the default.
+func configBackupLimit(path string) int {
+	loader := config.NewLoader()
+	if err := loader.LoadFrom(path); err != nil {
+		return config.DefaultConfigBackups
+	}
+	if keep := loader.Data().Output.ConfigBackups; keep > 0 {
+		return keep
+	}
+	return config.DefaultConfigBackups
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+func TestConfigBackupAndRestore(t *testing.T) {
+	// Note: Cannot use t.Parallel() because the commands read global flag variables.
+
+	configPath := filepath.Join(setupTempConfigDir(t), "config.yaml")
+	copyTestFixture(t, "profile_config.yaml", configPath)
+	original, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldPath := configFilePath
+	configFilePath = configPath
+	t.Cleanup(func() { configFilePath = oldPath })
+
+	if _, err := runCapturingStdout(t, func() error {
+		return configSetCmd.RunE(configSetCmd, []string{"defaults.max_tokens", "2048"})
+	}); err != nil {
+		t.Fatalf("config set: %v", err)
+	}
+	backups, err := config.ListBackups(configPath)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups after config set = %v, %v, want one", backups, err)
+	}
+	if content, _ := os.ReadFile(backups[0].Path); !bytes.Equal(content, original) {
+		t.Error("backup does not hold the config file as it was before config set")
+	}
+
+	var out bytes.Buffer
+	configBackupsListCmd.SetOut(&out)
+	if err := configBackupsListCmd.RunE(configBackupsListCmd, nil); err != nil {
+		t.Fatalf("config backups list: %v", err)
+	}
+	if !strings.Contains(out.String(), backups[0].Timestamp) {
+		t.Errorf("config backups list output %q does not list %s", out.String(), backups[0].Timestamp)
+	}
+
+	out.Reset()
+	configRestoreCmd.SetOut(&out)
+	if err := configRestoreCmd.RunE(configRestoreCmd, []string{config.LatestBackup}); err != nil {
+		t.Fatalf("config restore latest: %v", err)
+	}
+	if content, _ := os.ReadFile(configPath); !bytes.Equal(content, original) {
+		t.Error("config restore latest did not bring back the original config file")
+	}
+	if !strings.Contains(out.String(), "Previous configuration saved to") {
+		t.Errorf("config restore output = %q, want the path of the displaced file", out.String())
+	}
+}
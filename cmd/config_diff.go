@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/output/color"
+	"github.com/spf13/cobra"
+)
+
+// Prefixes of config diff arguments that do not name a file.
+const (
+	diffProfilePrefix  = "profile:"
+	diffTemplatePrefix = "template:"
+)
+
+// Output formats of config diff.
+const (
+	diffFormatTable = "table"
+	diffFormatJSON  = "json"
+)
+
+// diffArgs is the number of configurations config diff compares.
+const diffArgs = 2
+
+// diffDefault is how an unset option is shown.
+const diffDefault = "default"
+
+// Config diff flags.
+var (
+	diffFormat  string
+	diffAll     bool
+	diffExplain bool
+)
+
+// configDiffCmd compares two configurations option by option.
+var configDiffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "Compare two configurations option by option",
+	Long: `Show the options that differ between two configurations, grouped by
+section, as old → new.
+
+Each argument is one of:
+  <path>            a config file
+  profile:<name>    a profile of the current config, merged with its base
+  template:<name>   a built-in or user template (research, creative, news, full-example)
+
+Unset options take their default and are shown as "default". --all also
+lists the options that are the same on both sides. --explain adds the
+description of each option. Secrets are masked.
+
+Examples:
+  # What would adopting the research template change?
+  pplx config diff ~/.config/pplx/config.yaml template:research
+
+  # Compare two profiles of the current config
+  pplx config diff profile:research profile:creative
+
+  # Machine-readable change list
+  pplx config diff old.yaml new.yaml --format json`,
+	Args: cobra.ExactArgs(diffArgs),
+	RunE: runConfigDiff,
+}
+
+func runConfigDiff(cmd *cobra.Command, args []string) error {
+	if diffFormat != diffFormatTable && diffFormat != diffFormatJSON {
+		return clerrors.NewValidationError("format", diffFormat, "must be table or json")
+	}
+
+	left, err := loadDiffSide(args[0])
+	if err != nil {
+		return err
+	}
+	right, err := loadDiffSide(args[1])
+	if err != nil {
+		return err
+	}
+
+	changes := config.CompareConfigs(left, right, diffAll)
+	registry := config.NewMetadataRegistry()
+	for i := range changes {
+		c := &changes[i]
+		if isSecretConfigKey(c.Key) {
+			c.Old, c.New = maskDiffSecret(c.Old), maskDiffSecret(c.New)
+		}
+		if diffExplain {
+			if opt, err := registry.GetOption(c.Key); err == nil {
+				c.Description = opt.Description
+			}
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if diffFormat == diffFormatJSON {
+		data, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode diff: %w", err)
+		}
+		_, _ = fmt.Fprintln(out, string(data))
+		return nil
+	}
+	printConfigDiff(out, changes, color.Enabled(out))
+	return nil
+}
+
+// loadDiffSide loads the configuration a config diff argument names.
+func loadDiffSide(arg string) (*config.ConfigData, error) {
+	if name, ok := strings.CutPrefix(arg, diffProfilePrefix); ok {
+		data, err := loadConfigData(configFilePath)
+		if err != nil {
+			return nil, err
+		}
+		merged, err := config.NewProfileManager(data).MergeProfile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge profile %q: %w", name, err)
+		}
+		return merged, nil
+	}
+	if name, ok := strings.CutPrefix(arg, diffTemplatePrefix); ok {
+		cfg, err := config.LoadTemplate(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load template %q: %w", name, err)
+		}
+		return cfg, nil
+	}
+	return loadConfigData(arg)
+}
+
+// maskDiffSecret masks a secret option value; unset values stay nil.
+func maskDiffSecret(v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	return maskSecret(s)
+}
+
+// printConfigDiff prints changes grouped by section, in the colors of the
+// current theme when colored is set.
+func printConfigDiff(w io.Writer, changes []config.ConfigChange, colored bool) {
+	if len(changes) == 0 {
+		_, _ = fmt.Fprintln(w, "No differences found.")
+		return
+	}
+
+	width := 0
+	for _, c := range changes {
+		width = max(width, len(c.Key)-len(c.Section)-1)
+	}
+
+	section := ""
+	for _, c := range changes {
+		if c.Section != section {
+			if section != "" {
+				_, _ = fmt.Fprintln(w)
+			}
+			section = c.Section
+			heading := section
+			if colored {
+				heading = color.CurrentTheme().Paint(color.Heading, heading)
+			}
+			_, _ = fmt.Fprintln(w, heading)
+		}
+		name := strings.TrimPrefix(c.Key, c.Section+".")
+		oldVal, newVal := formatDiffValue(c.Old), formatDiffValue(c.New)
+		if !c.Changed {
+			_, _ = fmt.Fprintf(w, "    %-*s  %s\n", width, name, oldVal)
+		} else {
+			if colored {
+				theme := color.CurrentTheme()
+				oldVal, newVal = theme.Paint(color.Error, oldVal), theme.Paint(color.Success, newVal)
+			}
+			_, _ = fmt.Fprintf(w, "  ~ %-*s  %s → %s\n", width, name, oldVal, newVal)
+		}
+		if c.Description != "" {
+			_, _ = fmt.Fprintf(w, "    %-*s  %s\n", width, "", c.Description)
+		}
+	}
+}
+
+// formatDiffValue formats an option value for the diff table.
+func formatDiffValue(v any) string {
+	if v == nil {
+		return diffDefault
+	}
+	if list, ok := v.([]string); ok {
+		return strings.Join(list, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+// registerConfigDiffFlags registers flags for the config diff subcommand.
+func registerConfigDiffFlags() {
+	configDiffCmd.Flags().StringVar(&diffFormat, "format", diffFormatTable, "Output format (table, json)")
+	configDiffCmd.Flags().BoolVar(&diffAll, "all", false, "Also list options that are the same on both sides")
+	configDiffCmd.Flags().BoolVar(&diffExplain, "explain", false, "Show the description of each option")
+}
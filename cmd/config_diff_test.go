@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+func TestLoadDiffSide(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := `defaults:
+  model: sonar
+profiles:
+  deep:
+    defaults:
+      model: sonar-deep-research
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	orig := configFilePath
+	configFilePath = path
+	t.Cleanup(func() { configFilePath = orig })
+
+	tests := []struct {
+		arg       string
+		wantModel string
+		wantErr   bool
+	}{
+		{arg: path, wantModel: "sonar"},
+		{arg: "profile:deep", wantModel: "sonar-deep-research"},
+		{arg: "template:research", wantModel: "sonar"},
+		{arg: "profile:missing", wantErr: true},
+		{arg: "template:missing", wantErr: true},
+		{arg: filepath.Join(dir, "missing.yaml"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			cfg, err := loadDiffSide(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadDiffSide(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			}
+			if err == nil && cfg.Defaults.Model != tt.wantModel {
+				t.Errorf("model = %q, want %q", cfg.Defaults.Model, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestPrintConfigDiff(t *testing.T) {
+	changes := []config.ConfigChange{
+		{Section: "defaults", Key: "defaults.model", Old: "sonar", New: "sonar-pro", Changed: true,
+			Description: "Model to use for queries"},
+		{Section: "defaults", Key: "defaults.top_k", Changed: false},
+		{Section: "search", Key: "search.domains", New: []string{"arxiv.org", "nature.com"}, Changed: true},
+	}
+	var out bytes.Buffer
+	printConfigDiff(&out, changes, false)
+
+	want := []string{
+		"defaults\n",
+		"  ~ model    sonar → sonar-pro\n",
+		"             Model to use for queries\n",
+		"    top_k    default\n",
+		"\nsearch\n",
+		"  ~ domains  default → arxiv.org, nature.com\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(out.String(), w) {
+			t.Errorf("output %q does not contain %q", out.String(), w)
+		}
+	}
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Error("output is colored without a terminal")
+	}
+
+	out.Reset()
+	printConfigDiff(&out, nil, false)
+	if out.String() != "No differences found.\n" {
+		t.Errorf("empty diff output = %q", out.String())
+	}
+}
@@ -3,10 +3,14 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/sgaunet/pplx/pkg/clerrors"
 	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/i18n"
 	"github.com/spf13/cobra"
 )
 
@@ -17,8 +21,6 @@ const (
 	doctorSymbolFail = "\u2717" // ✗
 	// doctorSymbolWarn is the symbol shown for a warning check.
 	doctorSymbolWarn = "\u26a0" // ⚠
-	// configFilePermMode is the expected permission mode for the config file.
-	configFilePermMode = 0o600
 )
 
 var (
@@ -33,7 +35,7 @@ var configDoctorCmd = &cobra.Command{
 
 Checks performed:
   - Config file existence
-  - File permissions (warns if not 0600)
+  - File permissions of the config and data files (see pplx config secure)
   - YAML syntax validity
   - Field validation
   - Profile integrity (active profile exists)
@@ -47,21 +49,27 @@ Examples:
 	RunE: runConfigDoctor,
 }
 
-func runConfigDoctor(_ *cobra.Command, _ []string) error {
+func runConfigDoctor(cmd *cobra.Command, _ []string) error {
 	// Resolve config path: prefer --config flag, then auto-discover.
 	path := configFilePath // set by the persistent --config flag on configCmd
 
 	checks := config.RunHealthChecks(path)
 
 	if doctorJSON {
-		return printDoctorJSON(checks)
+		return printDoctorJSON(cmd.OutOrStdout(), checks)
 	}
 
-	return printDoctorTable(checks, path)
+	// Apply --fix before printing results so the permission status reflects reality.
+	if doctorFix {
+		applyFixes(checks, path)
+		// Re-run checks so the output reflects any fixes.
+		checks = config.RunHealthChecks(path)
+	}
+	return printDoctorTable(cmd.OutOrStdout(), i18n.T("doctor.title.config"), checks)
 }
 
 // printDoctorJSON serialises the health checks as a JSON array.
-func printDoctorJSON(checks []config.HealthCheck) error {
+func printDoctorJSON(w io.Writer, checks []config.HealthCheck) error {
 	type jsonCheck struct {
 		Name   string `json:"name"`
 		Status string `json:"status"`
@@ -77,7 +85,7 @@ func printDoctorJSON(checks []config.HealthCheck) error {
 		}
 	}
 
-	enc := json.NewEncoder(os.Stdout)
+	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 	if err := enc.Encode(out); err != nil {
 		return fmt.Errorf("encoding health checks as JSON: %w", err)
@@ -85,32 +93,24 @@ func printDoctorJSON(checks []config.HealthCheck) error {
 	return nil
 }
 
-// printDoctorTable renders the health checks in human-readable table format.
-func printDoctorTable(checks []config.HealthCheck, resolvedPath string) error {
-	fmt.Println("Configuration Health Check")
-	fmt.Println()
-
-	// Apply --fix before printing results so the permission status reflects reality.
-	if doctorFix {
-		applyFixes(checks, resolvedPath)
-		// Re-run checks so the output reflects any fixes.
-		checks = config.RunHealthChecks(resolvedPath)
-	}
+// printDoctorTable renders the health checks under title in human-readable
+// table format. Returns clerrors.ErrHealthChecksFailed when a check failed.
+func printDoctorTable(w io.Writer, title string, checks []config.HealthCheck) error {
+	fmt.Fprintln(w, title)
+	fmt.Fprintln(w)
 
 	// Determine the longest check name for alignment.
 	maxLen := 0
 	for _, c := range checks {
-		if len(c.Name) > maxLen {
-			maxLen = len(c.Name)
-		}
+		maxLen = max(maxLen, utf8.RuneCountInString(checkName(c)))
 	}
 
 	passed, failed, warned := 0, 0, 0
 	for _, c := range checks {
 		sym := symbolFor(c.Status)
 		// Left-pad the name so details align.
-		label := fmt.Sprintf("  %-*s", maxLen, c.Name+":")
-		fmt.Printf("%s %s %s\n", label, sym, c.Detail)
+		label := fmt.Sprintf("  %-*s", maxLen+1, checkName(c)+":")
+		fmt.Fprintf(w, "%s %s %s\n", label, sym, c.Detail)
 
 		switch c.Status {
 		case config.CheckPass:
@@ -122,13 +122,13 @@ func printDoctorTable(checks []config.HealthCheck, resolvedPath string) error {
 		}
 	}
 
-	fmt.Println()
+	fmt.Fprintln(w)
 	total := len(checks)
-	fmt.Printf("%d/%d checks passed", passed, total)
+	fmt.Fprint(w, i18n.T("doctor.summary.passed", passed, total))
 	if warned > 0 {
-		fmt.Printf(", %d warning(s)", warned)
+		fmt.Fprint(w, i18n.T("doctor.summary.warnings", warned))
 	}
-	fmt.Println(".")
+	fmt.Fprintln(w, ".")
 
 	if failed > 0 {
 		return fmt.Errorf("%w: %d check(s) failed", clerrors.ErrHealthChecksFailed, failed)
@@ -136,8 +136,21 @@ func printDoctorTable(checks []config.HealthCheck, resolvedPath string) error {
 	return nil
 }
 
-// applyFixes attempts to fix auto-correctable issues.
-// Currently handles file permission correction (chmod 0600).
+// checkName returns the name of c in the language of messages. Names stay
+// in English in the checks themselves, as JSON output and applyFixes use
+// them.
+func checkName(c config.HealthCheck) string {
+	key := "doctor.check." + strings.ReplaceAll(strings.ToLower(c.Name), " ", "_")
+	if name, ok := i18n.Lookup(key); ok {
+		return name
+	}
+	return c.Name
+}
+
+// applyFixes attempts to fix auto-correctable issues: the permission
+// problems of the File Permissions check, fixed as pplx config secure does.
+// The api.key of a project config is left to pplx config secure, which asks
+// before moving it.
 func applyFixes(checks []config.HealthCheck, configPath string) {
 	for _, c := range checks {
 		if c.Name == "File Permissions" && c.Status == config.CheckWarn {
@@ -146,7 +159,7 @@ func applyFixes(checks []config.HealthCheck, configPath string) {
 	}
 }
 
-// fixFilePermissions sets the config file permissions to 0600.
+// fixFilePermissions restricts the permissions of the config and data files.
 // If configPath is empty it auto-discovers the config file first.
 func fixFilePermissions(configPath string) {
 	path, err := resolveConfigPath(configPath)
@@ -154,10 +167,17 @@ func fixFilePermissions(configPath string) {
 		fmt.Fprintf(os.Stderr, "fix: cannot locate config file: %v\n", err)
 		return
 	}
-	if err := os.Chmod(path, configFilePermMode); err != nil {
-		fmt.Fprintf(os.Stderr, "fix: failed to chmod %s: %v\n", path, err)
-	} else {
-		fmt.Printf("fix: set permissions to 0600 on %s\n", path)
+	report, err := config.CheckSecurity(config.DefaultSecurityPaths(path))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fix: %v\n", err)
+		return
+	}
+	for _, p := range report.Permissions {
+		if err := p.Fix(); err != nil {
+			fmt.Fprintf(os.Stderr, "fix: %v\n", err)
+		} else {
+			fmt.Printf("fix: %s\n", p.Change())
+		}
 	}
 }
 
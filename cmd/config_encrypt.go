@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// configEncryptKeyCmd encrypts api.key in the config file with a passphrase.
+var configEncryptKeyCmd = &cobra.Command{
+	Use:   "encrypt-key",
+	Short: "Encrypt api.key in the config file with a passphrase",
+	Long: `Encrypt the api.key value of the config file with a key derived from a
+passphrase (scrypt, then AES-256-GCM) and store it as "enc:v1:<base64>".
+
+pplx decrypts the key in memory when it loads the config: it reads the
+passphrase from PPLX_CONFIG_PASSPHRASE, or asks for it when run in a
+terminal. Without either, commands fail early with a configuration error.
+PPLX_API_KEY still takes precedence when set, and no passphrase is needed
+then. The key is encrypted in the backups of the config file as well.
+
+The passphrase is read from PPLX_CONFIG_PASSPHRASE when set, otherwise it
+is asked twice on the terminal without echo.
+
+Examples:
+  pplx config encrypt-key
+  PPLX_CONFIG_PASSPHRASE=... pplx config encrypt-key`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		configPath := configWritePath()
+		key, err := readFileAPIKey(configPath)
+		if err != nil {
+			return err
+		}
+		switch {
+		case config.IsEncryptedKey(key):
+			return clerrors.NewConfigError("api.key is already encrypted in "+configPath, nil)
+		case config.IsKeyringRef(key):
+			return clerrors.NewConfigError(
+				"api.key is stored in the system keyring, not in "+configPath+"; nothing to encrypt", nil)
+		}
+
+		passphrase, err := newPassphrase(cmd.InOrStdin(), cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+		encrypted, err := config.EncryptAPIKey(key, passphrase)
+		if err != nil {
+			return err //nolint:wrapcheck // already a typed error
+		}
+		if err := backupConfig(configPath); err != nil {
+			return err
+		}
+		// The backups, this one included, would otherwise keep the key in clear.
+		redacted, err := config.RedactBackups(configPath, key, encrypted)
+		if err != nil {
+			return clerrors.NewIOError("redact backups of "+configPath, err)
+		}
+		if err := config.SetFileValue(configPath, apiKeyPath, encrypted); err != nil {
+			return err //nolint:wrapcheck // SetFileValue errors already name the file
+		}
+		warnConfigPermissions(configPath)
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Encrypted api.key in %s\n", configPath)
+		if redacted > 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "Encrypted api.key in %d backup(s) in %s\n",
+				redacted, config.BackupDir(configPath))
+		}
+		return nil
+	},
+}
+
+// configDecryptKeyCmd stores api.key in clear again.
+var configDecryptKeyCmd = &cobra.Command{
+	Use:   "decrypt-key",
+	Short: "Decrypt api.key in the config file",
+	Long: `Decrypt an api.key encrypted with "pplx config encrypt-key" and store it in
+clear in the config file again.
+
+The passphrase is read from PPLX_CONFIG_PASSPHRASE when set, otherwise it
+is asked on the terminal without echo.
+
+Examples:
+  pplx config decrypt-key`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		configPath := configWritePath()
+		value, err := readFileAPIKey(configPath)
+		if err != nil {
+			return err
+		}
+		if !config.IsEncryptedKey(value) {
+			return clerrors.NewConfigError("api.key is not encrypted in "+configPath, nil)
+		}
+
+		passphrase, err := existingPassphrase(cmd.InOrStdin(), cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+		key, err := config.DecryptAPIKey(value, passphrase)
+		if err != nil {
+			return err //nolint:wrapcheck // already a ConfigError
+		}
+		if err := backupConfig(configPath); err != nil {
+			return err
+		}
+		if err := config.SetFileValue(configPath, apiKeyPath, key); err != nil {
+			return err //nolint:wrapcheck // SetFileValue errors already name the file
+		}
+		warnConfigPermissions(configPath)
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Decrypted api.key in %s\n", configPath)
+		return nil
+	},
+}
+
+// readFileAPIKey returns api.key as written in the config file at path.
+func readFileAPIKey(path string) (string, error) {
+	cfg, err := loadConfigData(path)
+	if err != nil {
+		return "", err
+	}
+	if cfg.API.Key == "" {
+		return "", clerrors.NewConfigError("no api.key is set in "+path, nil)
+	}
+	return cfg.API.Key, nil
+}
+
+// newPassphrase returns the passphrase to encrypt api.key with:
+// PPLX_CONFIG_PASSPHRASE, or a passphrase typed twice on the terminal.
+func newPassphrase(in io.Reader, prompt io.Writer) (string, error) {
+	if passphrase := os.Getenv(config.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	first, err := readPassphrase(in, prompt, "New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	second, err := readPassphrase(in, prompt, "Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if first != second {
+		return "", clerrors.NewValidationError("passphrase", "", "passphrases do not match")
+	}
+	return first, nil
+}
+
+// existingPassphrase returns the passphrase of an encrypted api.key:
+// PPLX_CONFIG_PASSPHRASE, or a passphrase typed on the terminal.
+func existingPassphrase(in io.Reader, prompt io.Writer) (string, error) {
+	if passphrase := os.Getenv(config.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	return readPassphrase(in, prompt, "Passphrase: ")
+}
+
+// readPassphrase reads a non-empty passphrase from the terminal in without
+// echo. It fails with clerrors.ErrPassphraseRequired when in is not a
+// terminal, since a passphrase must not be piped in by accident.
+func readPassphrase(in io.Reader, prompt io.Writer, label string) (string, error) {
+	f, ok := in.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return "", clerrors.NewConfigError("cannot ask for the passphrase",
+			fmt.Errorf("%w: stdin is not a terminal; set %s", clerrors.ErrPassphraseRequired, config.PassphraseEnv))
+	}
+	fmt.Fprint(prompt, label)
+	raw, err := term.ReadPassword(int(f.Fd()))
+	fmt.Fprintln(prompt)
+	if err != nil {
+		return "", clerrors.NewConfigError("cannot read the passphrase", err)
+	}
+	if len(raw) == 0 {
+		return "", clerrors.NewValidationError("passphrase", "", "passphrase cannot be empty")
+	}
+	return string(raw), nil
+}
+
+// terminalPassphrasePrompt returns the prompt LoadAndMergeConfig uses to ask
+// for the passphrase of an encrypted api.key, or nil when stdin is not a
+// terminal so that non-interactive runs fail early instead of hanging.
+func terminalPassphrasePrompt() func() (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	return func() (string, error) {
+		passphrase, err := readPassphrase(os.Stdin, os.Stderr, "Passphrase for api.key: ")
+		var verr *clerrors.ValidationError
+		if errors.As(err, &verr) {
+			return "", nil // empty: reported as a missing passphrase
+		}
+		return passphrase, err
+	}
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+func TestConfigEncryptDecryptKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("api:\n  key: pplx-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	orig := configFilePath
+	configFilePath = path
+	t.Cleanup(func() { configFilePath = orig })
+	t.Setenv(config.PassphraseEnv, "correct horse")
+
+	var out bytes.Buffer
+	configEncryptKeyCmd.SetOut(&out)
+	configDecryptKeyCmd.SetOut(&out)
+	t.Cleanup(func() {
+		configEncryptKeyCmd.SetOut(nil)
+		configDecryptKeyCmd.SetOut(nil)
+	})
+
+	if err := configEncryptKeyCmd.RunE(configEncryptKeyCmd, nil); err != nil {
+		t.Fatalf("encrypt-key error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "pplx-secret") || !strings.Contains(string(data), config.EncryptedKeyPrefix) {
+		t.Fatalf("config after encrypt-key:\n%s\nwant an encrypted api.key", data)
+	}
+	if err := configEncryptKeyCmd.RunE(configEncryptKeyCmd, nil); err == nil {
+		t.Error("second encrypt-key succeeded, want an already encrypted error")
+	}
+
+	t.Setenv(config.PassphraseEnv, "battery staple")
+	if err := configDecryptKeyCmd.RunE(configDecryptKeyCmd, nil); !errors.Is(err, clerrors.ErrWrongPassphrase) {
+		t.Fatalf("decrypt-key with a wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+
+	t.Setenv(config.PassphraseEnv, "correct horse")
+	if err := configDecryptKeyCmd.RunE(configDecryptKeyCmd, nil); err != nil {
+		t.Fatalf("decrypt-key error: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.Contains(string(data), "key: pplx-secret") {
+		t.Errorf("config after decrypt-key:\n%s\nwant the key in clear", data)
+	}
+}
+
+func TestConfigEncryptKey_RedactsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("api:\n  key: pplx-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	orig := configFilePath
+	configFilePath = path
+	t.Cleanup(func() { configFilePath = orig })
+	t.Setenv(config.PassphraseEnv, "correct horse")
+	configEncryptKeyCmd.SetOut(io.Discard)
+	t.Cleanup(func() { configEncryptKeyCmd.SetOut(nil) })
+
+	// An earlier rewrite left a backup with the key in clear.
+	if err := backupConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := configEncryptKeyCmd.RunE(configEncryptKeyCmd, nil); err != nil {
+		t.Fatalf("encrypt-key error: %v", err)
+	}
+
+	backups, err := config.ListBackups(path)
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("backups = %v, %v, want the earlier one and the one of encrypt-key", backups, err)
+	}
+	err = filepath.WalkDir(config.BackupDir(path), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(string(data), "pplx-secret") {
+			t.Errorf("%s holds the key in clear:\n%s", p, data)
+		}
+		if !strings.Contains(string(data), config.EncryptedKeyPrefix) {
+			t.Errorf("%s:\n%s\nwant the encrypted api.key", p, data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigEncryptKey_NoPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("api:\n  key: pplx-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	orig := configFilePath
+	configFilePath = path
+	t.Cleanup(func() { configFilePath = orig })
+	t.Setenv(config.PassphraseEnv, "")
+
+	// stdin is not a terminal under go test.
+	configEncryptKeyCmd.SetIn(strings.NewReader("piped\n"))
+	t.Cleanup(func() { configEncryptKeyCmd.SetIn(nil) })
+	if err := configEncryptKeyCmd.RunE(configEncryptKeyCmd, nil); !errors.Is(err, clerrors.ErrPassphraseRequired) {
+		t.Errorf("encrypt-key error = %v, want ErrPassphraseRequired", err)
+	}
+}
+
+func TestQueryRunE_EncryptedKeyPassphrase(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PPLX_API_KEY", "")
+	withGlobalOpts(t)
+	globalOpts.UserPrompt = "hi"
+
+	encrypted, err := config.EncryptAPIKey("pplx-secret", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("api:\n  key: "+encrypted+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	orig, origPrompt := configFilePath, config.PassphrasePrompt
+	configFilePath = path
+	// Non-interactive use: no terminal to ask for the passphrase.
+	config.PassphrasePrompt = nil
+	t.Cleanup(func() { configFilePath, config.PassphrasePrompt = orig, origPrompt })
+
+	tests := []struct {
+		name       string
+		passphrase string
+		want       error
+	}{
+		{"wrong passphrase", "battery staple", clerrors.ErrWrongPassphrase},
+		{"no passphrase", "", clerrors.ErrPassphraseRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(config.PassphraseEnv, tt.passphrase)
+			err := queryCmd.RunE(queryCmd, []string{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("query error = %v, want %v", err, tt.want)
+			}
+			if got := getExitCode(err); got != exitCodeConfiguration {
+				t.Errorf("exit code = %d, want %d", got, exitCodeConfiguration)
+			}
+		})
+	}
+
+	// The same file with a malformed api.key.
+	if err := os.WriteFile(path, []byte("api:\n  key: "+config.EncryptedKeyPrefix+"!!\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.PassphraseEnv, "correct horse")
+	if err := queryCmd.RunE(queryCmd, []string{}); !errors.Is(err, clerrors.ErrInvalidEncryptedKey) {
+		t.Errorf("query with a malformed api.key error = %v, want ErrInvalidEncryptedKey", err)
+	}
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// apiKeyPath is the config file path of api.key.
+var apiKeyPath = []string{"api", "key"}
+
+// configSetKeyCmd stores the API key in the OS keyring.
+var configSetKeyCmd = &cobra.Command{
+	Use:   "set-key",
+	Short: "Store the API key in the system keyring",
+	Long: `Store the Perplexity API key in the OS keyring (macOS Keychain, Windows
+Credential Manager, or the Secret Service on Linux) under the service "pplx",
+and set api.key to "keyring" in the config file so that pplx reads it from
+there. The key never touches the config file.
+
+The key is read from the terminal without echo, or from stdin when piped.
+PPLX_API_KEY still takes precedence when set.
+
+Examples:
+  pplx config set-key
+  op read op://vault/perplexity/key | pplx config set-key`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		key, err := readAPIKeyInput(cmd.InOrStdin(), cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+		if err := config.StoreAPIKey(key); err != nil {
+			return err //nolint:wrapcheck // already a ConfigError
+		}
+
+		configPath := configWritePath()
+		if err := backupConfig(configPath); err != nil {
+			return err
+		}
+		if err := config.SetFileValue(configPath, apiKeyPath, config.KeyringValue); err != nil {
+			return err //nolint:wrapcheck // SetFileValue errors already name the file
+		}
+		warnConfigPermissions(configPath)
+
+		fmt.Fprintf(cmd.OutOrStdout(), "API key stored in the system keyring (service %q)\n", config.KeyringService)
+		fmt.Fprintf(cmd.OutOrStdout(), "Set api.key = %s in %s\n", config.KeyringValue, configPath)
+		return nil
+	},
+}
+
+// configUnsetKeyCmd removes the API key from the OS keyring.
+var configUnsetKeyCmd = &cobra.Command{
+	Use:   "unset-key",
+	Short: "Remove the API key from the system keyring",
+	Long: `Remove the Perplexity API key from the OS keyring and, when api.key is
+"keyring", remove api.key from the config file.
+
+Examples:
+  pplx config unset-key`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		out := cmd.OutOrStdout()
+		err := config.DeleteAPIKey()
+		switch {
+		case errors.Is(err, clerrors.ErrKeyringKeyNotFound):
+			fmt.Fprintln(out, "No API key stored in the system keyring")
+		case err != nil:
+			return err //nolint:wrapcheck // already a ConfigError
+		default:
+			fmt.Fprintln(out, "API key removed from the system keyring")
+		}
+
+		cfg, loadErr := loadConfigData(configFilePath)
+		if loadErr != nil || !config.IsKeyringRef(cfg.API.Key) {
+			return nil
+		}
+		configPath := configWritePath()
+		if err := backupConfig(configPath); err != nil {
+			return err
+		}
+		if err := config.UnsetFileValue(configPath, apiKeyPath); err != nil {
+			return err //nolint:wrapcheck // UnsetFileValue errors already name the file
+		}
+		fmt.Fprintf(out, "Unset api.key in %s\n", configPath)
+		return nil
+	},
+}
+
+// readAPIKeyInput reads an API key from the terminal without echo, or the
+// first line of in when it is not a terminal.
+func readAPIKeyInput(in io.Reader, prompt io.Writer) (string, error) {
+	var key string
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(prompt, "Perplexity API key: ")
+		raw, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(prompt)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", clerrors.ErrFailedToReadAPIKey, err)
+		}
+		key = string(raw)
+	} else {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("%w: %w", clerrors.ErrFailedToReadAPIKey, err)
+		}
+		key = line
+	}
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", clerrors.NewValidationError("api-key", "", "API key cannot be empty")
+	}
+	return key, nil
+}
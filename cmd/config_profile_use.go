@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	huh "charm.land/huh/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// profileUseShow prints the effective configuration after switching.
+var profileUseShow bool
+
+// configProfileUseCmd switches the active profile, letting the user pick it
+// from a preview of every profile when no name is given.
+var configProfileUseCmd = &cobra.Command{
+	Use:   "use [name]",
+	Short: "Switch profile, choosing from a preview when no name is given",
+	Long: `Switch the active profile.
+
+Without a name, every profile is listed with its effective model,
+temperature, search mode, and recency, and you pick one: with the arrow keys
+in a terminal, or by typing its number or name otherwise. An empty answer
+keeps the current profile. --show prints the resulting effective
+configuration, with secrets masked.
+
+Examples:
+  pplx config profile use
+  pplx config profile use research
+  pplx config profile use research --show`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := loadConfigData(configFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to load config for profile use: %w", err)
+		}
+		pm := config.NewProfileManager(data)
+		out := cmd.OutOrStdout()
+
+		var name string
+		if len(args) == 1 {
+			name = args[0]
+		} else {
+			if len(data.Profiles) == 0 {
+				fmt.Fprintln(out, "No profiles are defined in the config file.")
+				fmt.Fprintln(out, "Create one with: pplx config profile create <name>")
+				return nil
+			}
+			summaries, err := profileSummaries(pm)
+			if err != nil {
+				return err
+			}
+			name, err = chooseProfile(cmd.InOrStdin(), out, summaries, pm.GetActiveProfileName())
+			if errors.Is(err, errWizardAborted) {
+				fmt.Fprintln(out, "Aborted.")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if name == "" {
+				fmt.Fprintf(out, "Keeping profile '%s'\n", pm.GetActiveProfileName())
+				return nil
+			}
+		}
+
+		if err := pm.SetActiveProfile(name); err != nil {
+			return fmt.Errorf("failed to switch to profile %q: %w", name, err)
+		}
+		if err := saveProfiles(data); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Switched to profile '%s'\n", name)
+
+		if profileUseShow {
+			return printEffectiveConfig(out, pm, name)
+		}
+		return nil
+	},
+}
+
+// profileSummary is one line of the profile picker.
+type profileSummary struct {
+	Name        string
+	Description string
+	Model       string
+	Temperature float64
+	Mode        string
+	Recency     string
+}
+
+// String returns the summary as "model sonar, temperature 0.3, search academic,
+// recency week", with "-" for unset settings.
+func (s profileSummary) String() string {
+	orDash := func(v string) string {
+		if v == "" {
+			return "-"
+		}
+		return v
+	}
+	return fmt.Sprintf("model %s, temperature %s, search %s, recency %s",
+		orDash(s.Model), strconv.FormatFloat(s.Temperature, 'f', -1, 64), orDash(s.Mode), orDash(s.Recency))
+}
+
+// profileSummaries returns the summary of every profile, default first and
+// the others sorted by name, with the settings each would make effective:
+// its own, inherited ones, and the base config.
+func profileSummaries(pm *config.ProfileManager) ([]profileSummary, error) {
+	names := pm.ListProfiles()
+	slices.Sort(names[1:])
+
+	summaries := make([]profileSummary, 0, len(names))
+	for _, name := range names {
+		merged, err := pm.MergeProfile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve profile %q: %w", name, err)
+		}
+		profile, err := pm.RawProfile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load profile %q: %w", name, err)
+		}
+		summaries = append(summaries, profileSummary{
+			Name:        name,
+			Description: profile.Description,
+			Model:       merged.Defaults.Model,
+			Temperature: merged.Defaults.Temperature,
+			Mode:        merged.Search.Mode,
+			Recency:     merged.Search.Recency,
+		})
+	}
+	return summaries, nil
+}
+
+// chooseProfile asks the user to pick one of summaries and returns its name,
+// or "" when the user keeps the current profile. A terminal gets an arrow-key
+// menu, starting on active; other input is read as a profile number or name.
+func chooseProfile(in io.Reader, out io.Writer, summaries []profileSummary, active string) (string, error) {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) && os.Getenv("ACCESSIBLE") == "" {
+		return selectProfile(f, out, summaries, active)
+	}
+
+	fmt.Fprintln(out, "Profiles:")
+	for i, s := range summaries {
+		marker := " "
+		if s.Name == active {
+			marker = "*"
+		}
+		fmt.Fprintf(out, "%s %d) %s: %s\n", marker, i+1, s.Name, s)
+		if s.Description != "" {
+			fmt.Fprintf(out, "     %s\n", s.Description)
+		}
+	}
+	fmt.Fprintf(out, "Profile [1-%d, empty keeps '%s']: ", len(summaries), active)
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read profile choice: %w", err)
+	}
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return "", nil
+	}
+	if n, err := strconv.Atoi(answer); err == nil {
+		if n < 1 || n > len(summaries) {
+			return "", clerrors.NewValidationError("profile", answer,
+				fmt.Sprintf("must be between 1 and %d", len(summaries)))
+		}
+		return summaries[n-1].Name, nil
+	}
+	for _, s := range summaries {
+		if s.Name == answer {
+			return answer, nil
+		}
+	}
+	return "", fmt.Errorf("%w: '%s'", clerrors.ErrProfileNotFound, answer)
+}
+
+// selectProfile shows the arrow-key profile menu on a terminal.
+func selectProfile(in io.Reader, out io.Writer, summaries []profileSummary, active string) (string, error) {
+	options := make([]huh.Option[string], 0, len(summaries))
+	for _, s := range summaries {
+		options = append(options, huh.NewOption(s.Name+": "+s.String(), s.Name))
+	}
+	choice := active
+	form := huh.NewForm(huh.NewGroup(
+		huh.NewSelect[string]().
+			Title("Switch to profile").
+			Options(options...).
+			Value(&choice),
+	))
+	wizard := &WizardState{input: in, output: out}
+	if err := wizard.runForm(form); err != nil {
+		return "", err
+	}
+	if choice == active {
+		return "", nil
+	}
+	return choice, nil
+}
+
+// printEffectiveConfig prints the configuration in effect with profile name
+// active, as YAML with secrets masked.
+func printEffectiveConfig(out io.Writer, pm *config.ProfileManager, name string) error {
+	merged, err := pm.MergeProfile(name)
+	if err != nil {
+		return fmt.Errorf("failed to resolve profile %q: %w", name, err)
+	}
+	merged.Profiles = nil
+	if merged.API.Key != "" {
+		merged.API.Key = maskSecret(merged.API.Key)
+	}
+	if merged.API.MCPAuthToken != "" {
+		merged.API.MCPAuthToken = maskSecret(merged.API.MCPAuthToken)
+	}
+	merged.API.Keys = maskSecretMap(merged.API.Keys)
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to marshal effective config to YAML: %w", err)
+	}
+	fmt.Fprintln(out, "\nEffective configuration:")
+	_, err = out.Write(data)
+	return err //nolint:wrapcheck // writing to stdout
+}
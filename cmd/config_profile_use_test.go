@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestChooseProfile(t *testing.T) {
+	summaries := []profileSummary{
+		{Name: "default", Model: "sonar", Temperature: 0.5},
+		{Name: "creative", Model: "sonar", Temperature: 0.9},
+		{Name: "research", Description: "Academic research", Model: "sonar-pro", Temperature: 0.3, Mode: "academic"},
+	}
+
+	var validationErr *clerrors.ValidationError
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr func(error) bool
+	}{
+		{"by number", "2\n", "creative", nil},
+		{"by name", "research\n", "research", nil},
+		{"empty keeps current", "\n", "", nil},
+		{"no input keeps current", "", "", nil},
+		{"number out of range", "4\n", "", func(err error) bool { return errors.As(err, &validationErr) }},
+		{"unknown name", "news\n", "", func(err error) bool { return errors.Is(err, clerrors.ErrProfileNotFound) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := chooseProfile(strings.NewReader(tt.input), &out, summaries, "research")
+			if (tt.wantErr == nil && err != nil) || (tt.wantErr != nil && !tt.wantErr(err)) {
+				t.Fatalf("chooseProfile() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("chooseProfile() = %q, want %q", got, tt.want)
+			}
+			listing := out.String()
+			for _, want := range []string{
+				"  1) default: model sonar, temperature 0.5, search -, recency -",
+				"* 3) research: model sonar-pro, temperature 0.3, search academic, recency -",
+				"Academic research",
+			} {
+				if !strings.Contains(listing, want) {
+					t.Errorf("listing missing %q:\n%s", want, listing)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigProfileUse(t *testing.T) {
+	// Note: Cannot use t.Parallel() because the command reads global flag variables.
+	configPath := filepath.Join(setupTempConfigDir(t), "config.yaml")
+	copyTestFixture(t, "profile_config.yaml", configPath)
+	oldPath := configFilePath
+	configFilePath = configPath
+	t.Cleanup(func() {
+		configFilePath = oldPath
+		profileUseShow = false
+		configProfileUseCmd.SetIn(nil)
+		configProfileUseCmd.SetOut(nil)
+	})
+
+	var out bytes.Buffer
+	configProfileUseCmd.SetOut(&out)
+	configProfileUseCmd.SetIn(strings.NewReader("creative\n"))
+	if err := configProfileUseCmd.RunE(configProfileUseCmd, nil); err != nil {
+		t.Fatalf("profile use: %v", err)
+	}
+	data, err := loadConfigData(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.ActiveProfile != "creative" {
+		t.Errorf("active profile = %q, want creative", data.ActiveProfile)
+	}
+
+	out.Reset()
+	profileUseShow = true
+	if err := configProfileUseCmd.RunE(configProfileUseCmd, []string{"research"}); err != nil {
+		t.Fatalf("profile use research --show: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "Switched to profile 'research'") ||
+		!strings.Contains(got, "temperature: 0.3") || !strings.Contains(got, "mode: academic") {
+		t.Errorf("--show output = %q, want the research settings", got)
+	}
+
+	if err := configProfileUseCmd.RunE(configProfileUseCmd, []string{"missing"}); !errors.Is(err, clerrors.ErrProfileNotFound) {
+		t.Errorf("profile use missing = %v, want ErrProfileNotFound", err)
+	}
+}
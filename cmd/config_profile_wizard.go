@@ -120,7 +120,7 @@ Examples:
 		}
 
 		pm := config.NewProfileManager(data)
-		profile, err := pm.LoadProfile(name)
+		profile, err := pm.RawProfile(name)
 		if err != nil {
 			return fmt.Errorf("failed to load profile %q: %w", name, err)
 		}
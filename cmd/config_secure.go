@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// secureDryRun is the --dry-run flag of config secure.
+var secureDryRun bool
+
+// configSecureCmd fixes the permission problems pplx doctor reports.
+var configSecureCmd = &cobra.Command{
+	Use:   "secure",
+	Short: "Restrict the permissions of the config and data files",
+	Long: `Find the files of pplx that other users can read and fix them:
+
+  - the config file is set to 0600 and its directory, when it is a pplx
+    config directory such as ~/.config/pplx, to 0700
+  - the backups of the config file are set to 0600
+  - the usage log, chat history and autosave under ~/.local/share/pplx are
+    set to 0600, and the directories there to 0700
+  - an api.key found in a project config (.pplx.yaml) that everyone can
+    read is moved to the user config, after confirmation
+
+pplx doctor reports the same problems under File Permissions. A summary of
+every change is printed; --dry-run prints the changes without making them.
+
+Examples:
+  pplx config secure --dry-run
+  pplx config secure`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		path := configWritePath()
+		report, err := config.CheckSecurity(config.DefaultSecurityPaths(path))
+		if err != nil {
+			return clerrors.NewIOError("failed to check file permissions", err)
+		}
+		return secureFiles(cmd.InOrStdin(), cmd.OutOrStdout(), report, path, secureDryRun)
+	},
+}
+
+// secureFiles fixes the findings of report and prints a summary of the
+// changes, or only lists them with dryRun. The api.key of a project config
+// is moved to the user config at configPath when in confirms it.
+func secureFiles(in io.Reader, out io.Writer, report config.SecurityReport, configPath string, dryRun bool) error {
+	if report.Empty() {
+		fmt.Fprintln(out, "No permission problems found.")
+		return nil
+	}
+	fmt.Fprintf(out, "Found %d problem(s):\n", len(report.Issues()))
+	for _, issue := range report.Issues() {
+		fmt.Fprintf(out, "  %s\n", issue)
+	}
+	fmt.Fprintln(out)
+
+	moveKey := ""
+	if report.ProjectKey != nil {
+		moveKey = fmt.Sprintf("move api.key from %s to %s", report.ProjectKey.Path, configPath)
+	}
+	if dryRun {
+		fmt.Fprintln(out, "Would make these changes:")
+		for _, p := range report.Permissions {
+			fmt.Fprintf(out, "  %s\n", p.Change())
+		}
+		if moveKey != "" {
+			fmt.Fprintf(out, "  %s (after confirmation)\n", moveKey)
+		}
+		return nil
+	}
+
+	var changes []string
+	err := func() error {
+		for _, p := range report.Permissions {
+			if err := p.Fix(); err != nil {
+				return err //nolint:wrapcheck // already an IOError
+			}
+			changes = append(changes, p.Change())
+		}
+		if moveKey == "" {
+			return nil
+		}
+		if !confirmSecure(in, out, strings.ToUpper(moveKey[:1])+moveKey[1:]+"?") {
+			fmt.Fprintf(out, "Kept api.key in %s.\n", report.ProjectKey.Path)
+			return nil
+		}
+		if err := backupConfig(configPath); err != nil {
+			return err
+		}
+		if err := config.MoveProjectKey(report.ProjectKey.Path, configPath); err != nil {
+			return err //nolint:wrapcheck // already names the files
+		}
+		changes = append(changes, moveKey)
+		return nil
+	}()
+
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No changes made.")
+	} else {
+		fmt.Fprintf(out, "Made %d change(s):\n", len(changes))
+		for _, change := range changes {
+			fmt.Fprintf(out, "  %s\n", change)
+		}
+	}
+	return err
+}
+
+// confirmSecure asks question and reports a yes.
+func confirmSecure(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	return strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+}
+
+// registerSecureFlags registers the flags of config secure.
+func registerSecureFlags() {
+	configSecureCmd.Flags().BoolVar(&secureDryRun, "dry-run", false, "List the changes without making them")
+}
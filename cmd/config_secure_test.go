@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+// secureFixture writes a config file and a data file others can read, and a
+// world-readable project config holding an API key, and returns the paths.
+func secureFixture(t *testing.T) (config.SecurityPaths, string) {
+	t.Helper()
+	dir := t.TempDir()
+	paths := config.SecurityPaths{
+		ConfigFile:  filepath.Join(dir, "pplx", "config.yaml"),
+		DataDir:     filepath.Join(dir, "data"),
+		ProjectFile: filepath.Join(dir, "repo", config.ProjectConfigName),
+	}
+	for path, content := range map[string]string{
+		paths.ConfigFile: "version: 2\n",
+		filepath.Join(paths.DataDir, "usage.jsonl"): "{}\n",
+		paths.ProjectFile: "api:\n  key: pplx-secret\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths, filepath.Join(paths.DataDir, "usage.jsonl")
+}
+
+func TestSecureFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		dryRun    bool
+		answer    string
+		wantOut   []string
+		wantFixed bool
+		wantMoved bool
+	}{
+		{
+			name:    "dry run",
+			dryRun:  true,
+			wantOut: []string{"Found 3 problem(s):", "Would make these changes:", "chmod 0600", "(after confirmation)"},
+		},
+		{
+			name:      "confirmed",
+			answer:    "y\n",
+			wantOut:   []string{"Made 3 change(s):", "chmod 0600", "move api.key from"},
+			wantFixed: true,
+			wantMoved: true,
+		},
+		{
+			name:      "declined",
+			answer:    "n\n",
+			wantOut:   []string{"Kept api.key in", "Made 2 change(s):"},
+			wantFixed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			paths, dataFile := secureFixture(t)
+			report, err := config.CheckSecurity(paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			if err := secureFiles(strings.NewReader(tt.answer), &out, report, paths.ConfigFile, tt.dryRun); err != nil {
+				t.Fatalf("secureFiles() error = %v\n%s", err, out.String())
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+
+			for _, path := range []string{paths.ConfigFile, dataFile} {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := config.OwnerOnly(info.Mode()); got != tt.wantFixed {
+					t.Errorf("%s mode = %04o, want fixed: %v", path, info.Mode().Perm(), tt.wantFixed)
+				}
+			}
+			project, err := os.ReadFile(paths.ProjectFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if moved := !strings.Contains(string(project), "pplx-secret"); moved != tt.wantMoved {
+				t.Errorf("project config = %q, want the key moved: %v", project, tt.wantMoved)
+			}
+			user, _ := os.ReadFile(paths.ConfigFile)
+			if got := strings.Contains(string(user), "pplx-secret"); got != tt.wantMoved {
+				t.Errorf("user config = %q, want the key: %v", user, tt.wantMoved)
+			}
+		})
+	}
+}
+
+func TestSecureFiles_NothingToDo(t *testing.T) {
+	var out bytes.Buffer
+	if err := secureFiles(strings.NewReader(""), &out, config.SecurityReport{}, "config.yaml", false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "No permission problems found.") {
+		t.Errorf("output = %q", out.String())
+	}
+}
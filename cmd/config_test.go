@@ -1,14 +1,20 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/sgaunet/pplx/pkg/clerrors"
 	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/spf13/cobra"
 )
 
 // setupTempConfigDir creates a temporary directory for config testing.
@@ -379,6 +385,81 @@ func TestConfigValidate(t *testing.T) {
 	}
 }
 
+func TestWriteValidationReport(t *testing.T) {
+	t.Parallel()
+
+	errs := clerrors.ValidationErrors{
+		{Field: "search.recency", Value: "dayly", Message: "not valid", Rule: "Valid values: day, week"},
+		{Field: "output.reasoning_effort", Value: "high", Message: "deep-research only"},
+	}
+
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		if err := writeValidationReport(&buf, "/tmp/config.yaml", errs, validateFormatText); err != nil {
+			t.Fatal(err)
+		}
+		want := "/tmp/config.yaml: search.recency = \"dayly\": not valid [rule: Valid values: day, week]\n" +
+			"/tmp/config.yaml: output.reasoning_effort = \"high\": deep-research only\n"
+		if buf.String() != want {
+			t.Errorf("text report =\n%s\nwant\n%s", buf.String(), want)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		if err := writeValidationReport(&buf, "/tmp/config.yaml", errs, validateFormatJSON); err != nil {
+			t.Fatal(err)
+		}
+		var report validationReport
+		if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+			t.Fatalf("invalid JSON report: %v", err)
+		}
+		if report.Valid || len(report.Violations) != 2 {
+			t.Fatalf("report = %+v, want 2 violations", report)
+		}
+		got := report.Violations[0]
+		if got.File != "/tmp/config.yaml" || got.Section != "search" || got.Field != "search.recency" ||
+			got.Value != "dayly" || got.Rule != "Valid values: day, week" {
+			t.Errorf("violation = %+v", got)
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		if err := writeValidationReport(&buf, "/tmp/config.yaml", nil, validateFormatJSON); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), `"valid": true`) || !strings.Contains(buf.String(), `"violations": []`) {
+			t.Errorf("valid report = %s", buf.String())
+		}
+	})
+}
+
+func TestUnknownKeyViolations(t *testing.T) {
+	t.Parallel()
+
+	unknown := []config.UnknownKey{
+		{File: "/cfg/config.yaml", Line: 3, Path: "defaults.temprature", Key: "temprature", Suggestion: "temperature"},
+		{File: "/cfg/shared.yaml", Line: 2, Path: "search.recenncy", Key: "recenncy", Suggestion: "recency"},
+	}
+	got := unknownKeyViolations("/cfg/config.yaml", unknown)
+	want := []string{
+		"unknown option 'temprature' (did you mean 'temperature'?) (line 3)",
+		"unknown option 'recenncy' (did you mean 'recency'?) (/cfg/shared.yaml:2)",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unknownKeyViolations() = %+v, want %d", got, len(want))
+	}
+	for i, w := range want {
+		if got[i].Field != unknown[i].Path || got[i].Message != w {
+			t.Errorf("violation %d = %+v, want message %q", i, got[i], w)
+		}
+	}
+}
+
 // TestConfigPath tests the config path command.
 func TestConfigPath(t *testing.T) {
 	// Note: Cannot use t.Parallel() because subtests modify global config.ConfigPaths
@@ -1022,3 +1103,307 @@ func TestConfigInitVerifiesPermissions(t *testing.T) {
 		t.Errorf("Config file has wrong permissions: got %#o, want %#o", mode, configFilePermission)
 	}
 }
+
+// TestConfigInitWizardPresets tests that wizard answer flags write a config
+// without prompting, and that they cannot be combined with --template.
+func TestConfigInitWizardPresets(t *testing.T) {
+	// Note: Cannot run in parallel due to shared global state
+
+	tempDir := setupTempConfigDir(t)
+	configPath := filepath.Join(tempDir, "config.yaml")
+	configFilePath = configPath
+
+	initTemplate = ""
+	initForce = false
+	initWithExamples = false
+	initInteractive = false
+	initPresets = WizardPresets{UseCase: "general", Model: "sonar-pro", Recency: "week", APIKeyEnv: "TEAM_KEY"}
+	t.Cleanup(func() { initPresets = WizardPresets{} })
+
+	if _, err := runCapturingStdout(t, func() error { return runConfigInit(nil, nil) }); err != nil {
+		t.Fatalf("runConfigInit() error = %v", err)
+	}
+
+	loader := config.NewLoader()
+	if err := loader.LoadFrom(configPath); err != nil {
+		t.Fatalf("generated config does not load: %v", err)
+	}
+	cfg := loader.Data()
+	if cfg.Defaults.Model != "sonar-pro" || cfg.Search.Recency != "week" || !cfg.Output.Stream {
+		t.Errorf("config = %+v %+v %+v", cfg.Defaults, cfg.Search, cfg.Output)
+	}
+
+	initForce = true
+	initTemplate = config.TemplateNews
+	t.Cleanup(func() { initForce, initTemplate = false, "" })
+	if err := runConfigInit(nil, nil); err == nil {
+		t.Error("runConfigInit() accepted --template with wizard answer flags")
+	}
+}
+
+func TestConfigInitProject(t *testing.T) {
+	// Note: Cannot run in parallel due to shared global state
+	dir := t.TempDir()
+	t.Chdir(dir)
+	initProject, initForce, initDryRun = true, false, false
+	t.Cleanup(func() { initProject, initForce, initDryRun = false, false, false })
+	projectPath := filepath.Join(dir, config.ProjectConfigName)
+
+	out, err := runCapturingStdout(t, func() error { return runConfigInit(nil, nil) })
+	if err != nil {
+		t.Fatalf("runConfigInit() with --project error = %v", err)
+	}
+	if !strings.Contains(out, projectPath) {
+		t.Errorf("output = %q, want the path of the project config", out)
+	}
+	data, err := os.ReadFile(projectPath)
+	if err != nil || string(data) != config.ProjectConfigScaffold {
+		t.Fatalf("project config = %q, %v; want the scaffold", data, err)
+	}
+
+	if err := runConfigInit(nil, nil); !errors.Is(err, clerrors.ErrConfigFileExists) {
+		t.Errorf("runConfigInit() over an existing project config error = %v, want ErrConfigFileExists", err)
+	}
+	initForce = true
+	if _, err := runCapturingStdout(t, func() error { return runConfigInit(nil, nil) }); err != nil {
+		t.Errorf("runConfigInit() with --force error = %v", err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("template", "", "")
+	_ = cmd.Flags().Set("template", "research")
+	var valErr *clerrors.ValidationError
+	if err := runConfigInit(cmd, nil); !errors.As(err, &valErr) || valErr.Field != "template" {
+		t.Errorf("runConfigInit() with --project --template error = %v, want a template ValidationError", err)
+	}
+}
+
+// runCapturingStdout runs fn and returns what it printed to stdout.
+func runCapturingStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	outputChan := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		_, _ = io.Copy(&buf, r)
+		outputChan <- buf.String()
+	}()
+
+	err := fn()
+	_ = w.Close()
+	os.Stdout = oldStdout
+	return <-outputChan, err
+}
+
+// TestConfigSetGetUnset tests typed set, effective/raw get, and profile-scoped edits.
+func TestConfigSetGetUnset(t *testing.T) {
+	// Note: Cannot use t.Parallel() because the commands read global flag variables.
+
+	configPath := filepath.Join(setupTempConfigDir(t), "config.yaml")
+	copyTestFixture(t, "profile_config.yaml", configPath)
+
+	oldPath := configFilePath
+	configFilePath = configPath
+	t.Cleanup(func() {
+		configFilePath = oldPath
+		getRaw, getProfile, setProfile, unsetProfile = false, "", "", ""
+	})
+
+	get := func(key string) string {
+		t.Helper()
+		out, err := runCapturingStdout(t, func() error { return configGetCmd.RunE(configGetCmd, []string{key}) })
+		if err != nil {
+			t.Fatalf("config get %s: %v", key, err)
+		}
+		return strings.TrimSpace(out)
+	}
+	set := func(key, value string) error {
+		_, err := runCapturingStdout(t, func() error {
+			return configSetCmd.RunE(configSetCmd, []string{key, value})
+		})
+		return err
+	}
+
+	if err := set("defaults.max_tokens", "2048"); err != nil {
+		t.Fatalf("config set defaults.max_tokens: %v", err)
+	}
+	if err := set("api.timeout", "45s"); err != nil {
+		t.Fatalf("config set api.timeout: %v", err)
+	}
+
+	// Effective value merges the active "research" profile over the base.
+	if got := get("defaults.temperature"); got != "0.3" {
+		t.Errorf("effective defaults.temperature = %q, want 0.3", got)
+	}
+	getRaw = true
+	if got := get("defaults.temperature"); got != "0.5" {
+		t.Errorf("raw defaults.temperature = %q, want 0.5", got)
+	}
+	if got := get("defaults.max_tokens"); got != "2048" {
+		t.Errorf("raw defaults.max_tokens = %q, want 2048", got)
+	}
+	if got := get("api.timeout"); got != "45s" {
+		t.Errorf("raw api.timeout = %q, want 45s", got)
+	}
+	getRaw = false
+
+	// Profile-scoped set writes the override only.
+	setProfile = "creative"
+	if err := set("defaults.temperature", "1.1"); err != nil {
+		t.Fatalf("config set --profile creative: %v", err)
+	}
+	setProfile = ""
+	getProfile = "creative"
+	if got := get("defaults.temperature"); got != "1.1" {
+		t.Errorf("creative defaults.temperature = %q, want 1.1", got)
+	}
+	getProfile = ""
+
+	unsetProfile = "research"
+	if _, err := runCapturingStdout(t, func() error {
+		return configUnsetCmd.RunE(configUnsetCmd, []string{"defaults.temperature"})
+	}); err != nil {
+		t.Fatalf("config unset --profile research: %v", err)
+	}
+	unsetProfile = ""
+	if got := get("defaults.temperature"); got != "0.5" {
+		t.Errorf("defaults.temperature after profile unset = %q, want base 0.5", got)
+	}
+
+	// Comments and unrelated keys survive the edits.
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "# Configuration with profiles") ||
+		!strings.Contains(string(content), "Creative writing configuration") {
+		t.Errorf("config file lost unrelated content:\n%s", content)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			key, value, wantErr string
+		}{
+			{"defaults.temprature", "0.5", "did you mean: defaults.temperature"},
+			{"defaults.max_tokens", "many", "cannot parse"},
+			{"defaults.temperature", "5", "validation failed"},
+			{"search.recency", "weak", `Did you mean: "week"`},
+		}
+		for _, tt := range tests {
+			err := set(tt.key, tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("config set %s %s error = %v, want containing %q", tt.key, tt.value, err, tt.wantErr)
+			}
+		}
+
+		setProfile = "ghost"
+		if err := set("defaults.model", "sonar"); err == nil {
+			t.Error("config set --profile ghost expected error for missing profile")
+		}
+		setProfile = ""
+	})
+}
+
+// TestProfileSubcommands tests create, copy, rename, and delete against a fixture config.
+func TestProfileSubcommands(t *testing.T) {
+	// Note: Cannot use t.Parallel() because the commands read global flag variables.
+
+	configPath := filepath.Join(setupTempConfigDir(t), "config.yaml")
+	copyTestFixture(t, "profile_config.yaml", configPath)
+
+	oldPath := configFilePath
+	configFilePath = configPath
+	t.Cleanup(func() {
+		configFilePath = oldPath
+		createFromTemplate, createCopyFrom, createDescription = "", "", ""
+		deleteForceFlag = false
+	})
+
+	run := func(cmd *cobra.Command, args ...string) error {
+		t.Helper()
+		_, err := runCapturingStdout(t, func() error { return cmd.RunE(cmd, args) })
+		return err
+	}
+	load := func() *config.ConfigData {
+		t.Helper()
+		data, err := loadConfigData(configPath)
+		if err != nil {
+			t.Fatalf("loading %s: %v", configPath, err)
+		}
+		return data
+	}
+
+	createFromTemplate = "news"
+	if err := run(configProfileCreateCmd, "headlines"); err != nil {
+		t.Fatalf("profile create --from-template: %v", err)
+	}
+	createFromTemplate = ""
+	if err := run(configProfileCreateCmd, "bad name"); !errors.Is(err, clerrors.ErrProfileNameInvalid) {
+		t.Errorf("profile create with whitespace = %v, want ErrProfileNameInvalid", err)
+	}
+	if err := run(configProfileCreateCmd, "creative"); !errors.Is(err, clerrors.ErrProfileAlreadyExists) {
+		t.Errorf("profile create existing = %v, want ErrProfileAlreadyExists", err)
+	}
+
+	if err := run(configProfileCopyCmd, "creative", "creative-2"); err != nil {
+		t.Fatalf("profile copy: %v", err)
+	}
+	if err := run(configProfileRenameCmd, "research", "academic"); err != nil {
+		t.Fatalf("profile rename: %v", err)
+	}
+
+	data := load()
+	if data.Profiles["headlines"] == nil || data.Profiles["headlines"].Search.Recency == nil {
+		t.Error("headlines profile should be created from the news template")
+	}
+	copied := data.Profiles["creative-2"]
+	if copied == nil || copied.Defaults.Temperature == nil || *copied.Defaults.Temperature != 0.9 {
+		t.Errorf("creative-2 = %+v, want a copy of creative", copied)
+	}
+	if _, exists := data.Profiles["research"]; exists {
+		t.Error("research should be renamed")
+	}
+	if data.ActiveProfile != "academic" || data.Profiles["academic"].Name != "academic" {
+		t.Errorf("active profile = %q, want academic after rename", data.ActiveProfile)
+	}
+
+	// The active profile is only deleted with --force, which resets it.
+	if err := run(configProfileDeleteCmd, "academic"); !errors.Is(err, clerrors.ErrDeleteActiveProfile) {
+		t.Errorf("profile delete active = %v, want ErrDeleteActiveProfile", err)
+	}
+	deleteForceFlag = true
+	if err := run(configProfileDeleteCmd, "academic"); err != nil {
+		t.Fatalf("profile delete --force: %v", err)
+	}
+	data = load()
+	if _, exists := data.Profiles["academic"]; exists {
+		t.Error("academic should be deleted")
+	}
+	if data.ActiveProfile != config.DefaultProfileName {
+		t.Errorf("active profile = %q, want %q", data.ActiveProfile, config.DefaultProfileName)
+	}
+
+	// Keys outside profiles are preserved.
+	if data.Defaults.Temperature != 0.5 || data.Defaults.Model != "sonar" {
+		t.Errorf("base defaults changed: %+v", data.Defaults)
+	}
+}
+
+func TestPrintSearchPaths(t *testing.T) {
+	var buf bytes.Buffer
+	printSearchPaths(&buf, []config.SearchPath{
+		{Path: "/home/u/.config/pplx/config.yaml", Kind: config.SearchPathUser, Exists: true},
+		{Path: "/home/u/.config/pplx/pplx.yaml", Kind: config.SearchPathUser},
+		{Path: "/work/.pplx.yaml", Kind: config.SearchPathProject},
+	})
+	want := "✓ exists   user     /home/u/.config/pplx/config.yaml\n" +
+		"✗ missing  user     /home/u/.config/pplx/pplx.yaml\n" +
+		"✗ missing  project  /work/.pplx.yaml\n"
+	if buf.String() != want {
+		t.Errorf("printSearchPaths() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
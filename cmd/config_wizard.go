@@ -5,11 +5,19 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
 	huh "charm.land/huh/v2"
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
 	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/i18n"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/validation"
 )
 
 const (
@@ -18,6 +26,19 @@ const (
 	// Default selection for skippable menus.
 	choiceSkip = "skip"
 
+	// Use cases without a template.
+	useCaseGeneral = "general"
+	useCaseCustom  = "custom"
+
+	// Defaults of the wizard questions.
+	defaultWizardUseCase = useCaseGeneral
+	defaultWizardModel   = "sonar"
+
+	// API key storage choices.
+	apiKeyStorageEnv       = "env"
+	apiKeyStorageKeyring   = "keyring"
+	apiKeyStoragePlaintext = "plaintext"
+
 	// Validation range constants.
 	minTemperature     = 0.0
 	maxTemperature     = 2.0
@@ -43,6 +64,16 @@ var (
 	errWizardAborted = errors.New("wizard cancelled by user")
 )
 
+// builtinUseCases are the use cases the wizard always offers, in order.
+// Their labels and descriptions are the i18n messages
+// "wizard.use_case.<name>" and "wizard.use_case.<name>.description".
+var builtinUseCases = []string{
+	config.TemplateResearch, config.TemplateCreative, config.TemplateNews, useCaseGeneral, useCaseCustom,
+}
+
+// envVarNamePattern matches the names --api-key-env accepts.
+var envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // WizardState manages the state and flow of the interactive configuration wizard.
 type WizardState struct {
 	// I/O for form rendering and testing.
@@ -62,7 +93,32 @@ type WizardState struct {
 	enableStream   bool
 	searchFilters  []string
 	apiKey         string
+	keyStorage     string
 	customSettings map[string]any
+	// apiKeyEnv names the environment variable the config reads the API
+	// key from, written as ${NAME}.
+	apiKeyEnv string
+}
+
+// WizardPresets holds wizard answers given as flags, for provisioning
+// scripts that have no terminal. Empty fields take the wizard default.
+type WizardPresets struct {
+	UseCase     string
+	Model       string
+	Stream      *bool
+	SearchMode  string
+	Recency     string
+	ContextSize string
+	APIKeyEnv   string
+	// Yes accepts the default of every question.
+	Yes bool
+}
+
+// IsSet reports whether any answer was preset, in which case the wizard
+// runs without prompting.
+func (p WizardPresets) IsSet() bool {
+	return p.Yes || p.UseCase != "" || p.Model != "" || p.Stream != nil ||
+		p.SearchMode != "" || p.Recency != "" || p.ContextSize != "" || p.APIKeyEnv != ""
 }
 
 // NewWizardState creates a new wizard state with default values.
@@ -150,78 +206,242 @@ func (w *WizardState) Run() (*config.ConfigData, error) {
 	return w.config, nil
 }
 
+// RunNonInteractive builds the configuration from presets without reading
+// input. Questions without a preset take their default: the general use
+// case, the sonar model, streaming on, and no search filters. In --update
+// mode the defaults are the existing values instead.
+func (w *WizardState) RunNonInteractive(p WizardPresets) (*config.ConfigData, error) {
+	useCase := defaultWizardUseCase
+	if p.UseCase != "" {
+		useCase = p.UseCase
+	}
+	if err := w.applyUseCase(useCase); err != nil {
+		return nil, err
+	}
+
+	model := w.selectedModel
+	if model == "" {
+		model = defaultWizardModel
+	}
+	if p.Model != "" {
+		model = p.Model
+	}
+	if err := w.applyModel(model); err != nil {
+		return nil, err
+	}
+
+	stream := w.enableStream || w.existingConfig == nil
+	if p.Stream != nil {
+		stream = *p.Stream
+	}
+	w.applyStreaming(stream)
+
+	if p.SearchMode != "" || p.Recency != "" || p.ContextSize != "" {
+		w.searchFilters = []string{}
+		if err := w.applySearchCore(p.SearchMode, p.Recency, p.ContextSize); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.APIKeyEnv != "" {
+		if err := w.applyAPIKeyEnv(p.APIKeyEnv); err != nil {
+			return nil, err
+		}
+	}
+
+	w.buildConfiguration()
+	w.printSummary()
+	return w.config, nil
+}
+
+// applyUseCase sets the use case, which selects the template the
+// configuration starts from.
+func (w *WizardState) applyUseCase(useCase string) error {
+	valid := []string{config.TemplateResearch, config.TemplateCreative, config.TemplateNews, useCaseGeneral, useCaseCustom}
+	for _, tpl := range config.UserTemplates() {
+		if !slices.Contains(valid, tpl.Name) {
+			valid = append(valid, tpl.Name)
+		}
+	}
+	if slices.Contains(valid, useCase) {
+		w.useCase = useCase
+		return nil
+	}
+	return clerrors.NewValidationError("use-case", useCase, "must be one of: "+strings.Join(valid, ", "))
+}
+
+// applyModel sets the default model. Models missing from the registry are
+// accepted with a warning, since the API may know models pplx does not.
+func (w *WizardState) applyModel(model string) error {
+	if model == "" {
+		return clerrors.NewValidationError("model", model, "must not be empty")
+	}
+	if err := models.Check(model); err != nil {
+		logger.Warn("unknown model", "model", model, "error", err)
+	}
+	w.selectedModel = model
+	return nil
+}
+
+// applyStreaming sets whether responses are streamed.
+func (w *WizardState) applyStreaming(enable bool) {
+	w.enableStream = enable
+}
+
+// applySearchCore records the search mode, recency, and context size
+// filters. Empty values add no filter.
+func (w *WizardState) applySearchCore(mode, recency, contextSize string) error {
+	for _, f := range []struct{ field, kind, value string }{
+		{"search-mode", validation.KindSearchMode, mode},
+		{"recency", validation.KindRecency, recency},
+		{"context-size", validation.KindContextSize, contextSize},
+	} {
+		if f.value != "" && !validation.IsValid(f.kind, f.value) {
+			return clerrors.NewValidationError(f.field, f.value, "must be one of: "+validation.ValidList(f.kind))
+		}
+	}
+
+	if mode != "" {
+		w.searchFilters = append(w.searchFilters, "mode:"+mode)
+	}
+	if recency != "" {
+		w.searchFilters = append(w.searchFilters, "recency:"+recency)
+	}
+	if contextSize != "" {
+		w.searchFilters = append(w.searchFilters, "context:"+contextSize)
+	}
+	return nil
+}
+
+// applyAPIKeyEnv makes the configuration read the API key from the
+// environment variable name when it is loaded.
+func (w *WizardState) applyAPIKeyEnv(name string) error {
+	if !envVarNamePattern.MatchString(name) {
+		return clerrors.NewValidationError("api-key-env", name, "must be an environment variable name")
+	}
+	w.apiKeyEnv = name
+	return nil
+}
+
 // selectUseCase prompts the user to select their primary use case.
 func (w *WizardState) selectUseCase() error {
-	return w.runForm(huh.NewForm(
+	useCase := w.useCase
+	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewSelect[string]().
-				Title("Select Your Primary Use Case").
-				Description("Choose the configuration that best matches your needs.").
-				Options(
-					huh.NewOption("Research  - Academic and scholarly work with authoritative sources", config.TemplateResearch),
-					huh.NewOption("Creative  - Content generation, writing, and brainstorming", config.TemplateCreative),
-					huh.NewOption("News      - Current events tracking with reputable news sources", config.TemplateNews),
-					huh.NewOption("General   - Balanced configuration for everyday queries", "general"),
-					huh.NewOption("Custom    - Start from scratch with full customization", "custom"),
-				).
-				Value(&w.useCase),
+				Title(i18n.T("wizard.use_case.title")).
+				Description(i18n.T("wizard.use_case.description")).
+				Options(useCaseOptions()...).
+				Value(&useCase),
 		),
-	))
+	)); err != nil {
+		return err
+	}
+	return w.applyUseCase(useCase)
+}
+
+// useCaseOptions returns the use cases offered by the wizard: the built-in
+// ones, then the user templates, which replace a built-in of the same name.
+func useCaseOptions() []huh.Option[string] {
+	user := config.UserTemplates()
+	descriptions := make(map[string]string, len(user))
+	for _, tpl := range user {
+		descriptions[tpl.Name] = tpl.Description
+	}
+	options := make([]huh.Option[string], 0, len(builtinUseCases)+len(user))
+	for _, useCase := range builtinUseCases {
+		description := i18n.T("wizard.use_case." + useCase + ".description")
+		if d, ok := descriptions[useCase]; ok {
+			description = i18n.T("wizard.use_case.user_template", d)
+			delete(descriptions, useCase)
+		}
+		label := i18n.T("wizard.use_case." + useCase)
+		options = append(options, huh.NewOption(fmt.Sprintf("%-9s - %s", label, description), useCase))
+	}
+	for _, tpl := range user {
+		if _, ok := descriptions[tpl.Name]; ok {
+			options = append(options, huh.NewOption(fmt.Sprintf("%-9s - %s", tpl.Name, tpl.Description), tpl.Name))
+		}
+	}
+	return options
 }
 
 // selectModel prompts the user to select their preferred model.
 func (w *WizardState) selectModel() error {
-	if w.selectedModel == "" {
-		w.selectedModel = "sonar"
+	model := w.selectedModel
+	if model == "" {
+		model = defaultWizardModel
 	}
 
-	return w.runForm(huh.NewForm(
+	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewSelect[string]().
-				Title("Choose Your AI Model").
-				Options(
-					huh.NewOption("sonar               - Balanced performance (recommended)", "sonar"),
-					huh.NewOption("sonar-pro           - Enhanced capabilities", "sonar-pro"),
-					huh.NewOption("sonar-reasoning     - Advanced reasoning", "sonar-reasoning"),
-					huh.NewOption("sonar-deep-research - In-depth research and analysis", "sonar-deep-research"),
-				).
-				Value(&w.selectedModel),
+				Title(i18n.T("wizard.model.title")).
+				Options(modelOptions()...).
+				Value(&model),
 		),
-	))
+	)); err != nil {
+		return err
+	}
+	return w.applyModel(model)
+}
+
+// modelOptions lists the models of the model registry, padded so that the
+// descriptions line up.
+func modelOptions() []huh.Option[string] {
+	list := models.All()
+	width := 0
+	for _, m := range list {
+		width = max(width, len(m.Name))
+	}
+	options := make([]huh.Option[string], 0, len(list))
+	for _, m := range list {
+		label := fmt.Sprintf("%-*s - %s", width, m.Name, m.Description)
+		if m.Name == perplexity.DefaultModel {
+			label += i18n.T("wizard.model.recommended")
+		}
+		options = append(options, huh.NewOption(label, m.Name))
+	}
+	return options
 }
 
 // configureStreaming prompts the user to enable/disable streaming.
 func (w *WizardState) configureStreaming() error {
+	enable := w.enableStream
 	if w.existingConfig == nil {
-		w.enableStream = true // default to true for new configs
+		enable = true // default to true for new configs
 	}
 
-	return w.runForm(huh.NewForm(
+	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewConfirm().
-				Title("Enable Response Streaming?").
-				Description("Streaming displays responses as they're generated in real-time.").
-				Affirmative("Yes").
-				Negative("No").
-				Value(&w.enableStream),
+				Title(i18n.T("wizard.stream.title")).
+				Description(i18n.T("wizard.stream.description")).
+				Affirmative(i18n.T("wizard.yes")).
+				Negative(i18n.T("wizard.no")).
+				Value(&enable),
 		),
-	))
+	)); err != nil {
+		return err
+	}
+	w.applyStreaming(enable)
+	return nil
 }
 
 // selectSearchFilters prompts the user to configure search preferences.
 func (w *WizardState) selectSearchFilters() error {
 	configureSearch := w.existingConfig == nil
-	prompt := "Configure search preferences?"
+	prompt := i18n.T("wizard.search.configure")
 	if w.existingConfig != nil {
-		prompt = "Change search preferences?"
+		prompt = i18n.T("wizard.search.change")
 	}
 
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewConfirm().
 				Title(prompt).
-				Affirmative("Yes").
-				Negative("No").
+				Affirmative(i18n.T("wizard.yes")).
+				Negative(i18n.T("wizard.no")).
 				Value(&configureSearch),
 		),
 	)); err != nil {
@@ -255,48 +475,31 @@ func (w *WizardState) collectSearchCoreFilters() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewSelect[string]().
-				Title("Search Mode").
+				Title(i18n.T("wizard.search.mode")).
 				Options(
-					huh.NewOption("Web (default)", ""),
-					huh.NewOption("Academic - Scholarly sources only", "academic"),
+					huh.NewOption(i18n.T("wizard.search.mode.web"), ""),
+					huh.NewOption(i18n.T("wizard.search.mode.academic"), "academic"),
 				).
 				Value(&searchMode),
 			huh.NewSelect[string]().
-				Title("Recency Filter").
-				Options(
-					huh.NewOption("No filter (default)", ""),
-					huh.NewOption("Hour", "hour"),
-					huh.NewOption("Day", "day"),
-					huh.NewOption("Week", "week"),
-					huh.NewOption("Month", "month"),
-					huh.NewOption("Year", "year"),
-				).
+				Title(i18n.T("wizard.search.recency")).
+				Options(recencyOptions()...).
 				Value(&recency),
 			huh.NewSelect[string]().
-				Title("Context Size").
+				Title(i18n.T("wizard.search.context")).
 				Options(
-					huh.NewOption("No preference (default)", ""),
-					huh.NewOption("Low - Minimal context", "low"),
-					huh.NewOption("Medium - Balanced", "medium"),
-					huh.NewOption("High - Maximum context", "high"),
+					huh.NewOption(i18n.T("wizard.search.context.none"), ""),
+					huh.NewOption(i18n.T("wizard.search.context.low"), "low"),
+					huh.NewOption(i18n.T("wizard.search.context.medium"), "medium"),
+					huh.NewOption(i18n.T("wizard.search.context.high"), "high"),
 				).
 				Value(&contextSize),
-		).Title("Search Filters"),
+		).Title(i18n.T("wizard.search.title")),
 	)); err != nil {
 		return err
 	}
 
-	if searchMode == "academic" {
-		w.searchFilters = append(w.searchFilters, "mode:academic")
-	}
-	if recency != "" {
-		w.searchFilters = append(w.searchFilters, "recency:"+recency)
-	}
-	if contextSize != "" {
-		w.searchFilters = append(w.searchFilters, "context:"+contextSize)
-	}
-
-	return nil
+	return w.applySearchCore(searchMode, recency, contextSize)
 }
 
 // collectSearchDomains collects domain filter input.
@@ -305,8 +508,8 @@ func (w *WizardState) collectSearchDomains() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
-				Title("Domain Filter").
-				Description("Comma-separated domains (e.g. example.com,news.org). Leave empty to skip.").
+				Title(i18n.T("wizard.search.domains")).
+				Description(i18n.T("wizard.search.domains.description")).
 				Placeholder("example.com,news.org").
 				Value(&domains),
 		),
@@ -327,9 +530,9 @@ func (w *WizardState) promptLocation() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewConfirm().
-				Title("Set location preferences (country/lat/lon)?").
-				Affirmative("Yes").
-				Negative("No").
+				Title(i18n.T("wizard.location.confirm")).
+				Affirmative(i18n.T("wizard.yes")).
+				Negative(i18n.T("wizard.no")).
 				Value(&setLoc),
 		),
 	)); err != nil {
@@ -344,23 +547,23 @@ func (w *WizardState) promptLocation() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
-				Title("Country Code").
-				Description("2-letter ISO code (e.g. US, FR, DE). Leave empty to skip.").
+				Title(i18n.T("wizard.location.country")).
+				Description(i18n.T("wizard.location.country.description")).
 				Placeholder("US").
 				Value(&country),
 			huh.NewInput().
-				Title("Latitude").
-				Description("Value between -90 and 90. Leave empty to skip.").
+				Title(i18n.T("wizard.location.latitude")).
+				Description(i18n.T("wizard.location.latitude.description")).
 				Placeholder("37.7749").
 				Validate(validateOptionalFloat(minLatitude, maxLatitude)).
 				Value(&latStr),
 			huh.NewInput().
-				Title("Longitude").
-				Description("Value between -180 and 180. Leave empty to skip.").
+				Title(i18n.T("wizard.location.longitude")).
+				Description(i18n.T("wizard.location.longitude.description")).
 				Placeholder("-122.4194").
 				Validate(validateOptionalFloat(minLongitude, maxLongitude)).
 				Value(&lonStr),
-		).Title("Location Preferences"),
+		).Title(i18n.T("wizard.location.title")),
 	)); err != nil {
 		return err
 	}
@@ -385,9 +588,9 @@ func (w *WizardState) promptDateRange() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewConfirm().
-				Title("Set date range filters?").
-				Affirmative("Yes").
-				Negative("No").
+				Title(i18n.T("wizard.dates.confirm")).
+				Affirmative(i18n.T("wizard.yes")).
+				Negative(i18n.T("wizard.no")).
 				Value(&setDates),
 		),
 	)); err != nil {
@@ -402,18 +605,18 @@ func (w *WizardState) promptDateRange() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
-				Title("Results After Date").
-				Description("YYYY-MM-DD format. Leave empty to skip.").
+				Title(i18n.T("wizard.dates.after")).
+				Description(i18n.T("wizard.dates.description")).
 				Placeholder("2024-01-15").
 				Validate(validateOptionalDate).
 				Value(&afterDate),
 			huh.NewInput().
-				Title("Results Before Date").
-				Description("YYYY-MM-DD format. Leave empty to skip.").
+				Title(i18n.T("wizard.dates.before")).
+				Description(i18n.T("wizard.dates.description")).
 				Placeholder("2024-12-31").
 				Validate(validateOptionalDate).
 				Value(&beforeDate),
-		).Title("Date Range Filters"),
+		).Title(i18n.T("wizard.dates.title")),
 	)); err != nil {
 		return err
 	}
@@ -436,9 +639,9 @@ func (w *WizardState) configureAPIKey() error {
 		if err := w.runForm(huh.NewForm(
 			huh.NewGroup(
 				huh.NewConfirm().
-					Title(fmt.Sprintf("API key found in environment (%d chars). Use it?", len(envKey))).
-					Affirmative("Yes").
-					Negative("No").
+					Title(i18n.T("wizard.api_key.env_found", len(envKey))).
+					Affirmative(i18n.T("wizard.yes")).
+					Negative(i18n.T("wizard.no")).
 					Value(&useEnv),
 			),
 		)); err != nil {
@@ -450,35 +653,46 @@ func (w *WizardState) configureAPIKey() error {
 		}
 	}
 
-	var addKey bool
+	w.keyStorage = apiKeyStorageEnv
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
-			huh.NewConfirm().
-				Title("Add API key to configuration?").
-				Description("You can also set PERPLEXITY_API_KEY environment variable later.").
-				Affirmative("Yes").
-				Negative("No").
-				Value(&addKey),
+			huh.NewSelect[string]().
+				Title(i18n.T("wizard.api_key.storage")).
+				Options(
+					huh.NewOption(i18n.T("wizard.api_key.storage.env"), apiKeyStorageEnv),
+					huh.NewOption(i18n.T("wizard.api_key.storage.keyring"), apiKeyStorageKeyring),
+					huh.NewOption(i18n.T("wizard.api_key.storage.plaintext"), apiKeyStoragePlaintext),
+				).
+				Value(&w.keyStorage),
 		),
 	)); err != nil {
 		return err
 	}
 
-	if !addKey {
+	if w.keyStorage == apiKeyStorageEnv {
 		return nil
 	}
 
 	apiKeyInput := huh.NewInput().
-		Title("Perplexity API Key").
+		Title(i18n.T("wizard.api_key.input")).
 		Value(&w.apiKey)
 	// Only mask password in TUI mode; accessible mode requires plain text.
 	if !w.accessible {
 		apiKeyInput = apiKeyInput.EchoMode(huh.EchoModePassword)
 	}
 
-	return w.runForm(huh.NewForm(
+	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(apiKeyInput),
-	))
+	)); err != nil {
+		return err
+	}
+
+	if w.keyStorage == apiKeyStorageKeyring && w.apiKey != "" {
+		if err := config.StoreAPIKey(w.apiKey); err != nil {
+			return err //nolint:wrapcheck // already a ConfigError
+		}
+	}
+	return nil
 }
 
 // offerCustomization asks if the user wants basic additional customization.
@@ -487,9 +701,9 @@ func (w *WizardState) offerCustomization() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewConfirm().
-				Title("Configure advanced options (temperature, max tokens)?").
-				Affirmative("Yes").
-				Negative("No").
+				Title(i18n.T("wizard.basic.confirm")).
+				Affirmative(i18n.T("wizard.yes")).
+				Negative(i18n.T("wizard.no")).
 				Value(&customize),
 		),
 	)); err != nil {
@@ -504,18 +718,18 @@ func (w *WizardState) offerCustomization() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
-				Title("Temperature (0.0-2.0)").
-				Description("Controls randomness. Lower = more focused, higher = more creative.").
+				Title(i18n.T("wizard.basic.temperature")).
+				Description(i18n.T("wizard.basic.temperature.description")).
 				Placeholder("0.2").
 				Validate(validateOptionalFloat(minTemperature, maxTemperature)).
 				Value(&tempStr),
 			huh.NewInput().
-				Title("Max Tokens (1-100000)").
-				Description("Maximum number of tokens in the response.").
+				Title(i18n.T("wizard.basic.max_tokens")).
+				Description(i18n.T("wizard.basic.max_tokens.description")).
 				Placeholder("4000").
 				Validate(validateOptionalInt(minTokens, maxTokens)).
 				Value(&tokensStr),
-		).Title("Basic Customization"),
+		).Title(i18n.T("wizard.basic.title")),
 	)); err != nil {
 		return err
 	}
@@ -540,15 +754,15 @@ func (w *WizardState) offerAdvancedCustomization() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewSelect[string]().
-				Title("Extended Options (Optional)").
-				Description("Configure additional advanced options?").
+				Title(i18n.T("wizard.advanced.title")).
+				Description(i18n.T("wizard.advanced.description")).
 				Options(
-					huh.NewOption("Sampling parameters   (top-k, top-p, penalties)", "sampling"),
-					huh.NewOption("Output format         (return images, related questions)", "output"),
-					huh.NewOption("Response format       (JSON schema, regex constraint)", "response"),
-					huh.NewOption("Reasoning effort      (low / medium / high)", "reasoning"),
-					huh.NewOption("All of the above", "all"),
-					huh.NewOption("Skip", choiceSkip),
+					huh.NewOption(i18n.T("wizard.advanced.sampling"), "sampling"),
+					huh.NewOption(i18n.T("wizard.advanced.output"), "output"),
+					huh.NewOption(i18n.T("wizard.advanced.response"), "response"),
+					huh.NewOption(i18n.T("wizard.advanced.reasoning"), "reasoning"),
+					huh.NewOption(i18n.T("wizard.advanced.all"), "all"),
+					huh.NewOption(i18n.T("wizard.skip"), choiceSkip),
 				).
 				Value(&choice),
 		),
@@ -579,26 +793,26 @@ func (w *WizardState) configureSamplingParams() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
-				Title("Top-K (0-2048, 0=disabled)").
+				Title(i18n.T("wizard.sampling.top_k")).
 				Placeholder("0").
 				Validate(validateOptionalInt(minTopK, maxTopK)).
 				Value(&topKStr),
 			huh.NewInput().
-				Title("Top-P (0.0-1.0)").
+				Title(i18n.T("wizard.sampling.top_p")).
 				Placeholder("0.9").
 				Validate(validateOptionalFloat(minTopP, maxTopP)).
 				Value(&topPStr),
 			huh.NewInput().
-				Title("Frequency Penalty (0.0-2.0)").
+				Title(i18n.T("wizard.sampling.frequency_penalty")).
 				Placeholder("0.0").
 				Validate(validateOptionalFloat(minPenalty, maxPenalty)).
 				Value(&freqPenStr),
 			huh.NewInput().
-				Title("Presence Penalty (0.0-2.0)").
+				Title(i18n.T("wizard.sampling.presence_penalty")).
 				Placeholder("0.0").
 				Validate(validateOptionalFloat(minPenalty, maxPenalty)).
 				Value(&presPenStr),
-		).Title("Sampling Parameters"),
+		).Title(i18n.T("wizard.sampling.title")),
 	)); err != nil {
 		return err
 	}
@@ -639,16 +853,16 @@ func (w *WizardState) configureOutputFormat() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewConfirm().
-				Title("Return images in responses?").
-				Affirmative("Yes").
-				Negative("No").
+				Title(i18n.T("wizard.output.images")).
+				Affirmative(i18n.T("wizard.yes")).
+				Negative(i18n.T("wizard.no")).
 				Value(&returnImages),
 			huh.NewConfirm().
-				Title("Return related questions?").
-				Affirmative("Yes").
-				Negative("No").
+				Title(i18n.T("wizard.output.related")).
+				Affirmative(i18n.T("wizard.yes")).
+				Negative(i18n.T("wizard.no")).
 				Value(&returnRelated),
-		).Title("Output Format"),
+		).Title(i18n.T("wizard.output.title")),
 	)); err != nil {
 		return err
 	}
@@ -673,16 +887,16 @@ func (w *WizardState) configureResponseFormat() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
-				Title("Response Format JSON Schema").
-				Description("Leave empty to skip.").
+				Title(i18n.T("wizard.response.json_schema")).
+				Description(i18n.T("wizard.leave_empty")).
 				Placeholder(jsonPlaceholder).
 				Value(&jsonSchema),
 			huh.NewInput().
-				Title("Response Format Regex").
-				Description("Leave empty to skip.").
+				Title(i18n.T("wizard.response.regex")).
+				Description(i18n.T("wizard.leave_empty")).
 				Placeholder(regexPlaceholder).
 				Value(&regex),
-		).Title("Response Format Constraints"),
+		).Title(i18n.T("wizard.response.title")),
 	)); err != nil {
 		return err
 	}
@@ -708,12 +922,12 @@ func (w *WizardState) configureReasoningEffort() error {
 	if err := w.runForm(huh.NewForm(
 		huh.NewGroup(
 			huh.NewSelect[string]().
-				Title("Reasoning Effort Level").
+				Title(i18n.T("wizard.reasoning.title")).
 				Options(
-					huh.NewOption("Low    - Faster, less thorough", "low"),
-					huh.NewOption("Medium - Balanced (default)", "medium"),
-					huh.NewOption("High   - Slower, maximum depth", "high"),
-					huh.NewOption("Skip", choiceSkip),
+					huh.NewOption(i18n.T("wizard.reasoning.low"), "low"),
+					huh.NewOption(i18n.T("wizard.reasoning.medium"), "medium"),
+					huh.NewOption(i18n.T("wizard.reasoning.high"), "high"),
+					huh.NewOption(i18n.T("wizard.skip"), choiceSkip),
 				).
 				Value(&effort),
 		),
@@ -759,6 +973,22 @@ func (w *WizardState) runForm(f *huh.Form) error {
 	return nil
 }
 
+// recencyOptions builds the recency select options from the canonical list
+// in pkg/validation, preceded by a "no filter" entry.
+func recencyOptions() []huh.Option[string] {
+	values := validation.ValidValues(validation.KindRecency)
+	opts := make([]huh.Option[string], 0, len(values)+1)
+	opts = append(opts, huh.NewOption(i18n.T("wizard.search.recency.none"), ""))
+	for _, v := range values {
+		label, ok := i18n.Lookup("wizard.search.recency." + v)
+		if !ok {
+			label = strings.ToUpper(v[:1]) + v[1:]
+		}
+		opts = append(opts, huh.NewOption(label, v))
+	}
+	return opts
+}
+
 // --- Validation helpers for huh forms ---
 
 // validateOptionalFloat returns a validation function for optional float input within [lo, hi].
@@ -811,7 +1041,7 @@ func validateOptionalDate(s string) error {
 // loadTemplateIfApplicable loads a template config if useCase requires it.
 // Falls back to default config on error.
 func (w *WizardState) loadTemplateIfApplicable() {
-	if w.useCase == "custom" || w.useCase == "general" {
+	if w.useCase == useCaseCustom || w.useCase == useCaseGeneral {
 		return
 	}
 
@@ -937,8 +1167,14 @@ func (w *WizardState) buildConfiguration() {
 	// Layer 4: Apply custom settings (highest priority).
 	w.applyCustomSettings()
 
-	// Layer 5: Apply API key if provided.
-	if w.apiKey != "" {
+	// Layer 5: Apply API key if provided, or the variable to read it from.
+	switch {
+	case w.apiKeyEnv != "":
+		w.config.API.Key = "${" + w.apiKeyEnv + "}"
+	case w.apiKey == "":
+	case w.keyStorage == apiKeyStorageKeyring:
+		w.config.API.Key = config.KeyringValue
+	default:
 		w.config.API.Key = w.apiKey
 	}
 }
@@ -1006,48 +1242,57 @@ func splitDomains(value string) []string {
 func (w *WizardState) printSummary() {
 	_, _ = fmt.Fprintln(w.output)
 	_, _ = fmt.Fprintln(w.output, "═══════════════════════════════════════════════════════════════════")
-	_, _ = fmt.Fprintln(w.output, "Configuration Summary")
+	_, _ = fmt.Fprintln(w.output, i18n.T("wizard.summary.title"))
 	_, _ = fmt.Fprintln(w.output, "═══════════════════════════════════════════════════════════════════")
 	_, _ = fmt.Fprintln(w.output)
-	_, _ = fmt.Fprintf(w.output, "  Use Case:    %s\n", w.getUseCaseName())
-	_, _ = fmt.Fprintf(w.output, "  Model:       %s\n", w.selectedModel)
-	_, _ = fmt.Fprintf(w.output, "  Streaming:   %s\n", w.formatBool(w.enableStream))
+	w.printSummaryLine("wizard.summary.use_case", w.getUseCaseName())
+	w.printSummaryLine("wizard.summary.model", w.selectedModel)
+	w.printSummaryLine("wizard.summary.streaming", w.formatBool(w.enableStream))
 	if len(w.searchFilters) > 0 {
-		_, _ = fmt.Fprintf(w.output, "  Filters:     %d configured\n", len(w.searchFilters))
-	}
-	if w.apiKey != "" {
-		_, _ = fmt.Fprintln(w.output, "  API Key:     Configured")
+		w.printSummaryLine("wizard.summary.filters", i18n.T("wizard.summary.filters.value", len(w.searchFilters)))
+	}
+	switch {
+	case w.apiKeyEnv != "":
+		w.printSummaryLine("wizard.summary.api_key", "$"+w.apiKeyEnv)
+	case w.apiKey == "":
+	case w.keyStorage == apiKeyStorageKeyring:
+		w.printSummaryLine("wizard.summary.api_key", i18n.T("wizard.summary.api_key.keyring"))
+	default:
+		w.printSummaryLine("wizard.summary.api_key", i18n.T("wizard.summary.api_key.configured"))
 	}
 	if len(w.customSettings) > 0 {
-		_, _ = fmt.Fprintf(w.output, "  Advanced:    %d custom settings\n", len(w.customSettings))
+		w.printSummaryLine("wizard.summary.advanced", i18n.T("wizard.summary.advanced.value", len(w.customSettings)))
 	}
 	_, _ = fmt.Fprintln(w.output)
 	_, _ = fmt.Fprintln(w.output, "═══════════════════════════════════════════════════════════════════")
 	_, _ = fmt.Fprintln(w.output)
 }
 
+// printSummaryLine prints a line of the summary: the label message key,
+// padded so that the values line up, then value.
+func (w *WizardState) printSummaryLine(key, value string) {
+	_, _ = fmt.Fprintf(w.output, "  %-13s%s\n", i18n.T(key), value)
+}
+
 // getUseCaseName returns a human-readable name for the current use case.
 func (w *WizardState) getUseCaseName() string {
-	useCaseNames := map[string]string{
-		config.TemplateResearch: "Research",
-		config.TemplateCreative: "Creative",
-		config.TemplateNews:     "News",
-		"general":               "General",
-		"custom":                "Custom",
+	if slices.Contains(builtinUseCases, w.useCase) {
+		return i18n.T("wizard.use_case." + w.useCase)
 	}
-
-	if name, ok := useCaseNames[w.useCase]; ok {
-		return name
+	for _, tpl := range config.UserTemplates() {
+		if tpl.Name == w.useCase {
+			return i18n.T("wizard.use_case.user_template", tpl.Name)
+		}
 	}
-	return "Unknown"
+	return i18n.T("wizard.unknown")
 }
 
 // formatBool formats a boolean value for display.
 func (w *WizardState) formatBool(value bool) string {
 	if value {
-		return "Enabled"
+		return i18n.T("wizard.enabled")
 	}
-	return "Disabled"
+	return i18n.T("wizard.disabled")
 }
 
 // --- Date validation helpers (unchanged) ---
@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"errors"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"testing/iotest"
 
+	"github.com/sgaunet/pplx/pkg/clerrors"
 	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/zalando/go-keyring"
 )
 
 // newTestWizard creates a WizardState configured for accessible mode with injected I/O.
@@ -74,7 +79,8 @@ func TestSelectModel(t *testing.T) {
 		{name: "select sonar", input: "1\n", expectedModel: "sonar"},
 		{name: "select sonar-pro", input: "2\n", expectedModel: "sonar-pro"},
 		{name: "select sonar-reasoning", input: "3\n", expectedModel: "sonar-reasoning"},
-		{name: "select sonar-deep-research", input: "4\n", expectedModel: "sonar-deep-research"},
+		{name: "select sonar-reasoning-pro", input: "4\n", expectedModel: "sonar-reasoning-pro"},
+		{name: "select sonar-deep-research", input: "5\n", expectedModel: "sonar-deep-research"},
 	}
 
 	for _, tt := range tests {
@@ -178,8 +184,8 @@ func TestSelectSearchFiltersWithOptions(t *testing.T) {
 func TestConfigureAPIKeySkip(t *testing.T) {
 	t.Parallel()
 
-	// "n\n" to skip adding API key
-	w := newTestWizard("n\n")
+	// "1\n" to keep using the environment variable
+	w := newTestWizard("1\n")
 	err := w.configureAPIKey()
 
 	if err != nil {
@@ -195,8 +201,8 @@ func TestConfigureAPIKeySkip(t *testing.T) {
 func TestConfigureAPIKeyAdd(t *testing.T) {
 	t.Parallel()
 
-	// "y\n" to add key, then "test-api-key-123\n" as the key
-	w := newTestWizard("y\ntest-api-key-123\n")
+	// "3\n" to store in the config file, then "test-api-key-123\n" as the key
+	w := newTestWizard("3\ntest-api-key-123\n")
 	err := w.configureAPIKey()
 
 	if err != nil {
@@ -208,6 +214,27 @@ func TestConfigureAPIKeyAdd(t *testing.T) {
 	}
 }
 
+// TestConfigureAPIKeyKeyring tests storing the API key in the system keyring.
+// Not parallel: the keyring mock is process-wide.
+func TestConfigureAPIKeyKeyring(t *testing.T) {
+	keyring.MockInit()
+
+	w := newTestWizard("2\ntest-api-key-123\n")
+	if err := w.configureAPIKey(); err != nil {
+		t.Fatalf("configureAPIKey() error = %v", err)
+	}
+
+	stored, err := config.LoadAPIKey()
+	if err != nil || stored != "test-api-key-123" {
+		t.Errorf("keyring key = %q, %v; want test-api-key-123", stored, err)
+	}
+
+	w.buildConfiguration()
+	if w.config.API.Key != config.KeyringValue {
+		t.Errorf("API.Key = %q, want %q", w.config.API.Key, config.KeyringValue)
+	}
+}
+
 // TestOfferCustomizationSkip tests skipping customization.
 func TestOfferCustomizationSkip(t *testing.T) {
 	t.Parallel()
@@ -337,6 +364,175 @@ func TestBuildConfiguration(t *testing.T) {
 	}
 }
 
+// TestApplyWizardValues tests the apply functions behind each prompt.
+func TestApplyWizardValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		apply   func(w *WizardState) error
+		wantErr bool
+		check   func(w *WizardState) bool
+	}{
+		{
+			name:  "use case",
+			apply: func(w *WizardState) error { return w.applyUseCase(config.TemplateNews) },
+			check: func(w *WizardState) bool { return w.useCase == config.TemplateNews },
+		},
+		{
+			name:    "unknown use case",
+			apply:   func(w *WizardState) error { return w.applyUseCase("gaming") },
+			wantErr: true,
+		},
+		{
+			name:  "model",
+			apply: func(w *WizardState) error { return w.applyModel("sonar-pro") },
+			check: func(w *WizardState) bool { return w.selectedModel == "sonar-pro" },
+		},
+		{
+			name:    "empty model",
+			apply:   func(w *WizardState) error { return w.applyModel("") },
+			wantErr: true,
+		},
+		{
+			name:  "search filters",
+			apply: func(w *WizardState) error { return w.applySearchCore("academic", "week", "") },
+			check: func(w *WizardState) bool {
+				return strings.Join(w.searchFilters, " ") == "mode:academic recency:week"
+			},
+		},
+		{
+			name:    "invalid recency",
+			apply:   func(w *WizardState) error { return w.applySearchCore("", "decade", "") },
+			wantErr: true,
+		},
+		{
+			name:    "invalid context size",
+			apply:   func(w *WizardState) error { return w.applySearchCore("", "", "huge") },
+			wantErr: true,
+		},
+		{
+			name:  "api key env",
+			apply: func(w *WizardState) error { return w.applyAPIKeyEnv("TEAM_PPLX_KEY") },
+			check: func(w *WizardState) bool { return w.apiKeyEnv == "TEAM_PPLX_KEY" },
+		},
+		{
+			name:    "invalid api key env",
+			apply:   func(w *WizardState) error { return w.applyAPIKeyEnv("$KEY") },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := newTestWizard("")
+			err := tt.apply(w)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var verr *clerrors.ValidationError
+			if err != nil && !errors.As(err, &verr) {
+				t.Errorf("error %v is not a ValidationError", err)
+			}
+			if tt.check != nil && !tt.check(w) {
+				t.Errorf("state not applied: %+v", w)
+			}
+		})
+	}
+}
+
+// TestRunNonInteractive tests that presets build a config without reading input.
+func TestRunNonInteractive(t *testing.T) {
+	t.Parallel()
+
+	noStream := false
+	tests := []struct {
+		name     string
+		presets  WizardPresets
+		existing *config.ConfigData
+		check    func(t *testing.T, cfg *config.ConfigData)
+	}{
+		{
+			name:    "defaults",
+			presets: WizardPresets{Yes: true},
+			check: func(t *testing.T, cfg *config.ConfigData) {
+				t.Helper()
+				if cfg.Defaults.Model != defaultWizardModel || !cfg.Output.Stream || cfg.API.Key != "" {
+					t.Errorf("config = %+v, want the wizard defaults", cfg)
+				}
+			},
+		},
+		{
+			name: "presets",
+			presets: WizardPresets{
+				UseCase:   config.TemplateResearch,
+				Model:     "sonar-pro",
+				Stream:    &noStream,
+				Recency:   "month",
+				APIKeyEnv: "TEAM_PPLX_KEY",
+			},
+			check: func(t *testing.T, cfg *config.ConfigData) {
+				t.Helper()
+				if cfg.Defaults.Model != "sonar-pro" || cfg.Output.Stream {
+					t.Errorf("model/stream = %s/%v", cfg.Defaults.Model, cfg.Output.Stream)
+				}
+				if cfg.Search.Recency != "month" {
+					t.Errorf("recency = %q, want month", cfg.Search.Recency)
+				}
+				if cfg.API.Key != "${TEAM_PPLX_KEY}" {
+					t.Errorf("api key = %q, want ${TEAM_PPLX_KEY}", cfg.API.Key)
+				}
+			},
+		},
+		{
+			name:    "update keeps existing values",
+			presets: WizardPresets{Recency: "day"},
+			existing: func() *config.ConfigData {
+				cfg := config.NewConfigData()
+				cfg.Defaults.Model = "sonar-reasoning-pro"
+				cfg.Defaults.Temperature = 0.7
+				return cfg
+			}(),
+			check: func(t *testing.T, cfg *config.ConfigData) {
+				t.Helper()
+				if cfg.Defaults.Model != "sonar-reasoning-pro" || cfg.Defaults.Temperature != 0.7 {
+					t.Errorf("existing values lost: %+v", cfg.Defaults)
+				}
+				if cfg.Output.Stream || cfg.Search.Recency != "day" {
+					t.Errorf("stream/recency = %v/%q, want false/day", cfg.Output.Stream, cfg.Search.Recency)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Reading any input would fail the test.
+			w := newTestWizard("")
+			w.input = iotest.ErrReader(errors.New("unexpected read"))
+			if tt.existing != nil {
+				w.existingConfig = tt.existing
+				w.selectedModel = tt.existing.Defaults.Model
+				w.enableStream = tt.existing.Output.Stream
+			}
+
+			cfg, err := w.RunNonInteractive(tt.presets)
+			if err != nil {
+				t.Fatalf("RunNonInteractive() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+
+	if _, err := newTestWizard("").RunNonInteractive(WizardPresets{SearchMode: "images"}); err == nil {
+		t.Error("RunNonInteractive() accepted an invalid search mode")
+	}
+}
+
 // TestApplySearchFilters tests the search filter application logic.
 func TestApplySearchFilters(t *testing.T) {
 	t.Parallel()
@@ -687,3 +883,33 @@ func TestValidateOptionalInt(t *testing.T) {
 		})
 	}
 }
+
+// TestRunNonInteractive_UserTemplate tests that a template from the user
+// templates directory is accepted as a use case.
+func TestRunNonInteractive_UserTemplate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".config", "pplx", "templates")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	content := "# description: Team defaults\ndefaults:\n  temperature: 0.42\n"
+	if err := os.WriteFile(filepath.Join(dir, "team.yaml"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestWizard("")
+	cfg, err := w.RunNonInteractive(WizardPresets{UseCase: "team"})
+	if err != nil {
+		t.Fatalf("RunNonInteractive() error = %v", err)
+	}
+	if cfg.Defaults.Temperature != 0.42 {
+		t.Errorf("temperature = %v, want the user template's 0.42", cfg.Defaults.Temperature)
+	}
+	if name := w.getUseCaseName(); name != "team (user template)" {
+		t.Errorf("getUseCaseName() = %q", name)
+	}
+	if err := w.applyUseCase("gaming"); err == nil || !strings.Contains(err.Error(), "team") {
+		t.Errorf("applyUseCase() error = %v, want the user template listed", err)
+	}
+}
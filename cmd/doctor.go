@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	clerrors "github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/i18n"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/spf13/cobra"
+)
+
+// Output formats of the doctor command.
+const (
+	doctorFormatTable = "table"
+	doctorFormatJSON  = "json"
+)
+
+const (
+	// doctorOnlineTimeout bounds the connectivity check request.
+	doctorOnlineTimeout = 30 * time.Second
+	// doctorOnlinePrompt is the question of the connectivity check.
+	doctorOnlinePrompt = "ping"
+	// developmentVersion is the version of binaries not built by a release.
+	developmentVersion = "development"
+)
+
+// Flags of the doctor command.
+var (
+	doctorOnline bool
+	doctorFormat string
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the installation, configuration, and API access",
+	Long: `Run a series of checks and print pass, warn, or fail for each:
+
+  - Config file discovered (or --config)
+  - YAML syntax and field validation
+  - File permissions of the config and data files (see pplx config secure)
+  - Active profile exists
+  - API key set through PPLX_API_KEY, the config file, or the keyring
+  - Config version
+  - pplx version, Go version, and platform
+
+With --online, a one-token request is also sent to the API with the
+configured key and default model, and its latency is reported. It is billed
+like any other request.
+
+--format json prints the results as a JSON array to attach to bug reports;
+no secrets are included. The command exits non-zero when a check fails.
+
+Examples:
+  pplx doctor
+  pplx doctor --online
+  pplx doctor --format json`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+func runDoctor(cmd *cobra.Command, _ []string) error {
+	if doctorFormat != doctorFormatTable && doctorFormat != doctorFormatJSON {
+		return clerrors.NewValidationError("format", doctorFormat, "must be table or json")
+	}
+	// Failed checks are the report, not a usage mistake.
+	cmd.SilenceUsage = true
+
+	checks := config.RunHealthChecks(configFilePath)
+	checks = append(checks, checkBinaryVersion(version))
+	if doctorOnline {
+		checks = append(checks, checkOnline(cmd))
+	}
+
+	if doctorFormat == doctorFormatTable {
+		return printDoctorTable(cmd.OutOrStdout(), i18n.T("doctor.title.pplx"), checks)
+	}
+	if err := printDoctorJSON(cmd.OutOrStdout(), checks); err != nil {
+		return err
+	}
+	failed := 0
+	for _, c := range checks {
+		if c.Status == config.CheckFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d check(s) failed", clerrors.ErrHealthChecksFailed, failed)
+	}
+	return nil
+}
+
+// checkBinaryVersion reports the version of the running binary, the Go
+// version it was built with, and the platform. Development builds warn,
+// since bug reports should name a release.
+func checkBinaryVersion(v string) config.HealthCheck {
+	detail := fmt.Sprintf("%s (%s, %s/%s)", v, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if v == developmentVersion {
+		return config.HealthCheck{
+			Name:   "pplx Version",
+			Status: config.CheckWarn,
+			Detail: detail + ": not a release build",
+		}
+	}
+	return config.HealthCheck{Name: "pplx Version", Status: config.CheckPass, Detail: detail}
+}
+
+// checkOnline loads the run configuration, as query does, and checks that
+// the API answers a request sent with its key and default model. Retries
+// are disabled so that the latency is the one of a single call.
+func checkOnline(cmd *cobra.Command) config.HealthCheck {
+	cfg, err := loadRunConfig(cmd)
+	if err != nil {
+		return config.HealthCheck{Name: "API Connectivity", Status: config.CheckFail, Detail: err.Error()}
+	}
+	apiKey, err := resolveAPIKey(cfg)
+	if err != nil {
+		return config.HealthCheck{Name: "API Connectivity", Status: config.CheckFail, Detail: "skipped: no API key"}
+	}
+
+	if err := config.ValidateHTTPURL("api.base_url", cfg.API.BaseURL); err != nil {
+		return config.HealthCheck{Name: "API Connectivity", Status: config.CheckFail, Detail: err.Error()}
+	}
+	proxy, err := config.ParseProxyURL(cfg.API.ProxyURL)
+	if err != nil {
+		return config.HealthCheck{Name: "API Connectivity", Status: config.CheckFail, Detail: err.Error()}
+	}
+	tlsConf, err := config.LoadTLSConfig(cfg.API.TLS)
+	if err != nil {
+		return config.HealthCheck{Name: "API Connectivity", Status: config.CheckFail, Detail: err.Error()}
+	}
+
+	client := perplexity.NewClient(apiKey)
+	if endpoint := config.EndpointURL(cfg.API.BaseURL); endpoint != "" {
+		client.SetEndpoint(endpoint)
+	}
+	retry.Configure(client, retry.NewPolicy(0, 0), retry.Timeouts{
+		Connect:        cfg.API.ConnectTimeout,
+		ResponseHeader: cfg.API.ResponseHeaderTimeout,
+		Total:          doctorOnlineTimeout,
+		Proxy:          proxy,
+		TLS:            tlsConf,
+	})
+	model := models.Resolve(cfg.Defaults.Model, cfg.Models.Aliases)
+	if model == "" {
+		model = perplexity.DefaultModel
+	}
+	return checkConnectivity(client, model)
+}
+
+// checkConnectivity sends a one-token request for model with client and
+// reports how long the API took to answer.
+func checkConnectivity(client *perplexity.Client, model string) config.HealthCheck {
+	name := "API Connectivity"
+
+	msg := perplexity.NewMessages()
+	if err := msg.AddUserMessage(doctorOnlinePrompt); err != nil {
+		return config.HealthCheck{Name: name, Status: config.CheckFail, Detail: err.Error()}
+	}
+	req := perplexity.NewCompletionRequest(
+		perplexity.WithMessages(msg.GetMessages()),
+		perplexity.WithModel(model),
+		perplexity.WithMaxTokens(1),
+	)
+
+	start := time.Now()
+	_, err := client.SendCompletionRequest(req)
+	latency := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		return config.HealthCheck{
+			Name:   name,
+			Status: config.CheckFail,
+			Detail: fmt.Sprintf("%s request failed after %s: %v", model, latency, err),
+		}
+	}
+	return config.HealthCheck{
+		Name:   name,
+		Status: config.CheckPass,
+		Detail: fmt.Sprintf("%s answered in %s", model, latency),
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorOnline, "online", false,
+		"Also send a one-token request to check API connectivity and latency")
+	doctorCmd.Flags().StringVar(&doctorFormat, "format", doctorFormatTable, "Output format: table or json")
+	doctorCmd.Flags().StringVar(&configFilePath, "config", "", "Path to config file")
+
+	if err := doctorCmd.RegisterFlagCompletionFunc("format",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return []string{doctorFormatTable, doctorFormatJSON}, cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'format' flag: %v\n", err)
+	}
+}
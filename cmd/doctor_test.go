@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	clerrors "github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+func TestCheckBinaryVersion(t *testing.T) {
+	if got := checkBinaryVersion("v1.2.3"); got.Status != config.CheckPass || !strings.HasPrefix(got.Detail, "v1.2.3 (go") {
+		t.Errorf("release build = %+v, want pass with the Go version", got)
+	}
+	if got := checkBinaryVersion(developmentVersion); got.Status != config.CheckWarn {
+		t.Errorf("development build = %+v, want warn", got)
+	}
+}
+
+func TestCheckConnectivity(t *testing.T) {
+	var maxTokens int
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			MaxTokens int `json:"max_tokens"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		maxTokens = body.MaxTokens
+		_, _ = w.Write([]byte(mockCompletionResponseJSON()))
+	}))
+	defer ok.Close()
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(ok.URL)
+	got := checkConnectivity(client, "sonar")
+	if got.Status != config.CheckPass || !strings.HasPrefix(got.Detail, "sonar answered in ") {
+		t.Errorf("reachable API = %+v, want pass with latency", got)
+	}
+	if maxTokens != 1 {
+		t.Errorf("max_tokens = %d, want 1", maxTokens)
+	}
+
+	client.SetEndpoint(unauthorized.URL)
+	if got := checkConnectivity(client, "sonar"); got.Status != config.CheckFail {
+		t.Errorf("rejected key = %+v, want fail", got)
+	}
+}
+
+func TestRunDoctor_JSON(t *testing.T) {
+	t.Setenv("PPLX_API_KEY", "test-key")
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("version: 2\ndefaults:\n  model: sonar\n"), 0o400); err != nil {
+		t.Fatal(err)
+	}
+	oldPath, oldFormat, oldOnline := configFilePath, doctorFormat, doctorOnline
+	t.Cleanup(func() { configFilePath, doctorFormat, doctorOnline = oldPath, oldFormat, oldOnline })
+	configFilePath, doctorFormat, doctorOnline = path, doctorFormatJSON, false
+
+	var out bytes.Buffer
+	doctorCmd.SetOut(&out)
+	t.Cleanup(func() { doctorCmd.SetOut(nil) })
+	if err := runDoctor(doctorCmd, nil); err != nil {
+		t.Fatalf("runDoctor() error = %v\n%s", err, out.String())
+	}
+
+	var checks []struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &checks); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, out.String())
+	}
+	statuses := make(map[string]string, len(checks))
+	for _, c := range checks {
+		statuses[c.Name] = c.Status
+	}
+	for name, want := range map[string]string{
+		"Config File":      "pass",
+		"File Permissions": "pass", // 0400 is owner-only
+		"API Key":          "pass",
+		"pplx Version":     "warn",
+	} {
+		if statuses[name] != want {
+			t.Errorf("%s = %q, want %q", name, statuses[name], want)
+		}
+	}
+}
+
+func TestRunDoctor_FailedCheck(t *testing.T) {
+	oldPath, oldFormat := configFilePath, doctorFormat
+	t.Cleanup(func() { configFilePath, doctorFormat = oldPath, oldFormat })
+	configFilePath, doctorFormat = filepath.Join(t.TempDir(), "missing.yaml"), doctorFormatTable
+
+	var out bytes.Buffer
+	doctorCmd.SetOut(&out)
+	t.Cleanup(func() { doctorCmd.SetOut(nil) })
+	err := runDoctor(doctorCmd, nil)
+	if !errors.Is(err, clerrors.ErrHealthChecksFailed) {
+		t.Errorf("error = %v, want ErrHealthChecksFailed", err)
+	}
+	if !strings.Contains(out.String(), "Config File:") {
+		t.Errorf("table output missing the config check:\n%s", out.String())
+	}
+}
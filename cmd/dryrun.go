@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/security"
+)
+
+// dryRunTabPadding is the column padding of the dry-run options table.
+const dryRunTabPadding = 2
+
+// runConfigSources records where each config key of the running command got
+// its value; set by loadRunConfig and nil when no config could be loaded.
+var runConfigSources map[string]config.Source
+
+// dryRunSecretKeys are options whose values are masked in dry-run output.
+var dryRunSecretKeys = []string{"api.key", "api.mcp_auth_token"}
+
+// dryRunOption is one row of the dry-run options table.
+type dryRunOption struct {
+	Key    string
+	Value  any
+	Source config.Source
+}
+
+// writeDryRun prints the request that would be sent: the effective model,
+// every option set with its source, the request JSON, and the estimated
+// prompt tokens.
+func writeDryRun(
+	out io.Writer, cfg *config.ConfigData, sources map[string]config.Source, req *perplexity.CompletionRequest,
+) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	if fields := choicesFields(); fields != nil {
+		if data, err = addRequestFields(data, fields); err != nil {
+			return err
+		}
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	data = indented.Bytes()
+
+	fmt.Fprintln(out, "Dry run: the request was not sent.")
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Model: %s (%s)\n", req.Model, sourceOf(sources, "defaults.model"))
+	fmt.Fprintln(out)
+
+	options := dryRunOptions(cfg, sources)
+	if len(options) == 0 {
+		fmt.Fprintln(out, "Options: none set, using built-in defaults")
+	} else {
+		fmt.Fprintln(out, "Options:")
+		w := tabwriter.NewWriter(out, 0, 0, dryRunTabPadding, ' ', 0)
+		fmt.Fprintln(w, "  KEY\tVALUE\tSOURCE")
+		for _, opt := range options {
+			fmt.Fprintf(w, "  %s\t%v\t%s\n", opt.Key, opt.Value, opt.Source)
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("failed to write dry-run options: %w", err)
+		}
+	}
+	fmt.Fprintln(out)
+
+	fmt.Fprintln(out, "Request:")
+	fmt.Fprintln(out, string(data))
+	fmt.Fprintln(out)
+	_, err = fmt.Fprintf(out, "Estimated prompt tokens: %d\n", estimateRequestTokens(req))
+	return err //nolint:wrapcheck // stdout write
+}
+
+// dryRunOptions returns the config keys that are set or did not come from
+// the defaults, sorted by key.
+func dryRunOptions(cfg *config.ConfigData, sources map[string]config.Source) []dryRunOption {
+	var options []dryRunOption
+	for _, key := range config.AllKeys() {
+		value, err := config.GetValue(cfg, key)
+		if err != nil {
+			continue
+		}
+		source := sourceOf(sources, key)
+		if source == config.SourceDefault && (value == nil || reflect.ValueOf(value).IsZero()) {
+			continue
+		}
+		if slices.Contains(dryRunSecretKeys, key) {
+			value = security.MaskAPIKey(fmt.Sprint(value))
+		}
+		options = append(options, dryRunOption{Key: key, Value: value, Source: source})
+	}
+	return options
+}
+
+// sourceOf returns the source of key, SourceDefault when unknown.
+func sourceOf(sources map[string]config.Source, key string) config.Source {
+	if source, ok := sources[key]; ok {
+		return source
+	}
+	return config.SourceDefault
+}
+
+// estimateRequestTokens estimates the prompt tokens of req's text messages.
+// Attachments are not counted.
+func estimateRequestTokens(req *perplexity.CompletionRequest) int {
+	msgs := req.Messages
+	for _, mm := range req.MultimodalMessages {
+		msg := perplexity.Message{Role: mm.Role}
+		for _, content := range mm.Content {
+			if content.Text != nil {
+				msg.Content += *content.Text
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+	return chat.EstimateMessages(msgs)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+func TestWriteDryRun(t *testing.T) {
+	cfg := config.NewConfigData()
+	cfg.Defaults.Model = "sonar"
+	cfg.Defaults.Temperature = 0.7
+	cfg.API.Key = "pplx-abcdefghijklmnopqrstuvwxyz"
+	sources := map[string]config.Source{
+		"defaults.model":       config.SourceProfile,
+		"defaults.temperature": config.SourceFlag,
+		"api.key":              config.SourceEnv,
+		"search.recency":       config.SourceDefault,
+	}
+
+	var out bytes.Buffer
+	if err := writeDryRun(&out, cfg, sources, newTestRequest()); err != nil {
+		t.Fatalf("writeDryRun() error: %v", err)
+	}
+	got := out.String()
+
+	for _, want := range []string{
+		"Model: sonar (profile)",
+		"defaults.temperature  0.7",
+		"flag",
+		`"content": "test query"`,
+		"Estimated prompt tokens: ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "abcdefghijklmnopqrstuvwxyz") {
+		t.Errorf("API key not masked:\n%s", got)
+	}
+	if strings.Contains(got, "search.recency") {
+		t.Errorf("unset default option listed:\n%s", got)
+	}
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes returned by pplx. They are part of the CLI contract: scripts
+// depend on them, so existing values must not change.
+const (
+	exitCodeSuccess       = 0
+	exitCodeGeneral       = 1
+	exitCodeValidation    = 2
+	exitCodeConfiguration = 3
+	exitCodeAuth          = 4
+	exitCodeRateLimit     = 5
+	exitCodeTimeout       = 6
+	exitCodeAPI           = 7
+	exitCodeBudget        = 8
+	// exitCodeFormatMismatch reports an answer that does not match its
+	// response format, with --strict-format.
+	exitCodeFormatMismatch = 9
+	// exitCodeWarnings reports a run that succeeded with warnings, with
+	// --fail-on-warning.
+	exitCodeWarnings = 10
+)
+
+// validationSentinels are input errors reported with exitCodeValidation.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var validationSentinels = []error{
+	clerrors.ErrValidationFailed,
+	clerrors.ErrInvalidSearchRecency,
+	clerrors.ErrConflictingResponseFormats,
+	clerrors.ErrStreamResponseFormat,
+	clerrors.ErrResponseFormatNotSupported,
+	clerrors.ErrInvalidSearchMode,
+	clerrors.ErrInvalidSearchContextSize,
+	clerrors.ErrInvalidSearchAfterDate,
+	clerrors.ErrInvalidSearchBeforeDate,
+	clerrors.ErrInvalidLastUpdatedAfter,
+	clerrors.ErrInvalidLastUpdatedBefore,
+	clerrors.ErrInvalidReasoningEffort,
+	clerrors.ErrInvalidDate,
+	clerrors.ErrMissingPromptVariables,
+	clerrors.ErrInvalidPromptVariable,
+	clerrors.ErrInvalidBatchLine,
+	clerrors.ErrBatchValidationFailed,
+	clerrors.ErrInvalidGroupBy,
+	clerrors.ErrInvalidSince,
+	clerrors.ErrInvalidUsageFormat,
+	clerrors.ErrInvalidLogLevel,
+	clerrors.ErrInvalidLogFormat,
+	clerrors.ErrUnsupportedShell,
+	clerrors.ErrInvalidRenderMode,
+	clerrors.ErrUnknownOutputField,
+	clerrors.ErrInvalidCitationStyle,
+	clerrors.ErrInvalidTranscriptFormat,
+	clerrors.ErrInvalidDomain,
+	clerrors.ErrDomainConflict,
+	clerrors.ErrUnknownModel,
+	clerrors.ErrInvalidExtractPath,
+	clerrors.ErrExtractPathNotFound,
+	clerrors.ErrExtractNotJSON,
+	clerrors.ErrInvalidExportFormat,
+	clerrors.ErrInvalidJSONSchema,
+	clerrors.ErrInvalidCountry,
+}
+
+// configSentinels are configuration errors reported with exitCodeConfiguration.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var configSentinels = []error{
+	clerrors.ErrNoConfigFound,
+	clerrors.ErrConfigNotMapping,
+	clerrors.ErrConfigVersionTooNew,
+	clerrors.ErrIncludeNotFound,
+	clerrors.ErrIncludeCycle,
+	clerrors.ErrIncludeInvalid,
+	clerrors.ErrBackupNotFound,
+	clerrors.ErrInvalidBackup,
+	clerrors.ErrUnknownSection,
+	clerrors.ErrProfileNotFound,
+	clerrors.ErrProfileCycle,
+	clerrors.ErrProfileChainTooDeep,
+	clerrors.ErrKeyringUnavailable,
+	clerrors.ErrKeyringKeyNotFound,
+	clerrors.ErrPassphraseRequired,
+	clerrors.ErrWrongPassphrase,
+	clerrors.ErrInvalidEncryptedKey,
+	clerrors.ErrTemplateNotFound,
+	clerrors.ErrPromptNotFound,
+}
+
+// cobraUsagePrefixes start the messages of usage errors cobra reports
+// without a type of their own.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var cobraUsagePrefixes = []string{
+	"unknown command ",
+	"required flag(s) ",
+	"if any flags in the group ",
+}
+
+// usageError marks a command-line usage mistake: an unknown flag, a bad
+// flag value, or the wrong number of arguments.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+
+// Unwrap returns the underlying error.
+func (e *usageError) Unwrap() error { return e.err }
+
+// markUsageErrors wraps the flag and argument errors of cmd and its
+// subcommands in usageError, so that they exit with exitCodeValidation.
+func markUsageErrors(cmd *cobra.Command) {
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return &usageError{err: err}
+	})
+	if args := cmd.Args; args != nil {
+		cmd.Args = func(c *cobra.Command, a []string) error {
+			if err := args(c, a); err != nil {
+				return &usageError{err: err}
+			}
+			return nil
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		markUsageErrors(sub)
+	}
+}
+
+// getExitCode maps an error returned by a command to the exit code
+// documented in the README. The most specific class wins: a timeout or an
+// authentication failure is reported as such even though it is also an
+// API error.
+func getExitCode(err error) int {
+	if err == nil {
+		return exitCodeSuccess
+	}
+	var configErr *clerrors.ConfigError
+	var timeoutErr *retry.TimeoutError
+	var apiErr *clerrors.APIError
+	var budgetErr *clerrors.BudgetExceededError
+	status := clerrors.StatusCode(err)
+
+	switch {
+	case errors.As(err, &configErr):
+		return exitCodeConfiguration
+	case errors.As(err, &budgetErr):
+		return exitCodeBudget
+	case errors.Is(err, clerrors.ErrFormatMismatch):
+		return exitCodeFormatMismatch
+	case errors.Is(err, clerrors.ErrWarnings):
+		return exitCodeWarnings
+	case isUsageError(err):
+		return exitCodeValidation
+	case errors.As(err, &timeoutErr):
+		return exitCodeTimeout
+	case errors.Is(err, perplexity.ErrUnauthorized),
+		status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return exitCodeAuth
+	case status == http.StatusTooManyRequests:
+		return exitCodeRateLimit
+	case errors.As(err, &apiErr):
+		return exitCodeAPI
+	case isAny(err, configSentinels):
+		return exitCodeConfiguration
+	}
+	return exitCodeGeneral
+}
+
+// isUsageError reports whether err is an invalid input or command line.
+func isUsageError(err error) bool {
+	var validationErr *clerrors.ValidationError
+	var validationErrs clerrors.ValidationErrors
+	var usageErr *usageError
+	if errors.As(err, &validationErr) || errors.As(err, &validationErrs) || errors.As(err, &usageErr) {
+		return true
+	}
+	if isAny(err, validationSentinels) {
+		return true
+	}
+	for _, prefix := range cobraUsagePrefixes {
+		if strings.HasPrefix(err.Error(), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAny reports whether err matches one of targets.
+func isAny(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
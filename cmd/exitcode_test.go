@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/spf13/cobra"
+)
+
+func TestGetExitCode_ErrorClasses(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, exitCodeSuccess},
+		{"validation sentinel", fmt.Errorf("%w: bogus", clerrors.ErrInvalidSearchMode), exitCodeValidation},
+		{"validation errors", clerrors.ValidationErrors{*clerrors.NewValidationError("f", "v", "bad")}, exitCodeValidation},
+		{"usage error", &usageError{err: errors.New("unknown flag: --nope")}, exitCodeValidation},
+		{"cobra unknown command", errors.New(`unknown command "nope" for "pplx"`), exitCodeValidation},
+		{"config sentinel", fmt.Errorf("load: %w", clerrors.ErrIncludeCycle), exitCodeConfiguration},
+		{"unauthorized", clerrors.NewAPIError("failed", perplexity.ErrUnauthorized), exitCodeAuth},
+		{"forbidden status", &clerrors.APIError{StatusCode: http.StatusForbidden}, exitCodeAuth},
+		{
+			name: "rate limit after retries",
+			err: clerrors.NewAPIError("failed", fmt.Errorf("%w after %d attempts: %w",
+				clerrors.ErrRetriesExhausted, 3, &clerrors.APIError{StatusCode: http.StatusTooManyRequests})),
+			want: exitCodeRateLimit,
+		},
+		{
+			name: "rate limit response",
+			err:  clerrors.NewAPIError("failed", perplexity.ParseErrorMessage([]byte(apiErrorJSON("slow down", "rate_limit", 429)))),
+			want: exitCodeRateLimit,
+		},
+		{
+			name: "timeout",
+			err:  clerrors.NewAPIError("failed", &retry.TimeoutError{Phase: retry.PhaseTotal, Err: errors.New("deadline")}),
+			want: exitCodeTimeout,
+		},
+		{"server error", &clerrors.APIError{StatusCode: http.StatusInternalServerError}, exitCodeAPI},
+		{"other API error", clerrors.NewAPIError("failed", errors.New("connection refused")), exitCodeAPI},
+		{"I/O error", clerrors.NewIOError("write", errors.New("broken pipe")), exitCodeGeneral},
+		{"format mismatch", fmt.Errorf("%w: line 1", clerrors.ErrFormatMismatch), exitCodeFormatMismatch},
+		{"warnings", fmt.Errorf("%w: 2 warnings", clerrors.ErrWarnings), exitCodeWarnings},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getExitCode(tt.err); got != tt.want {
+				t.Errorf("getExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkUsageErrors(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	sub := &cobra.Command{Use: "sub", Args: cobra.ExactArgs(1), RunE: func(*cobra.Command, []string) error { return nil }}
+	sub.Flags().Int("n", 0, "")
+	root.AddCommand(sub)
+	markUsageErrors(root)
+	root.SilenceErrors, root.SilenceUsage = true, true
+
+	for _, args := range [][]string{{"sub"}, {"sub", "x", "--n", "abc"}, {"sub", "x", "--nope"}} {
+		root.SetArgs(args)
+		err := root.Execute()
+		if got := getExitCode(err); got != exitCodeValidation {
+			t.Errorf("%v: exit code = %d, want %d (err: %v)", args, got, exitCodeValidation, err)
+		}
+	}
+}
+
+// TestHandleNonStreamingResponse_ExitCodes checks the exit code of the errors
+// the query command returns for failed API calls.
+func TestHandleNonStreamingResponse_ExitCodes(t *testing.T) {
+	disableSpinner(t)
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   int
+	}{
+		{"401", http.StatusUnauthorized, "", exitCodeAuth},
+		{"429", http.StatusTooManyRequests, apiErrorJSON("rate limited", "rate_limit_error", 429), exitCodeRateLimit},
+		{"500", http.StatusInternalServerError, apiErrorJSON("boom", "server_error", 500), exitCodeAPI},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			client := perplexity.NewClient("test-key")
+			client.SetEndpoint(srv.URL)
+			retry.Configure(client, retry.NewPolicy(0, time.Millisecond), retry.Timeouts{Total: 5 * time.Second})
+
+			err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+			if got := getExitCode(err); got != tt.want {
+				t.Errorf("exit code = %d, want %d (err: %v)", got, tt.want, err)
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		srv := newStalledServer(t)
+		client := perplexity.NewClient("test-key")
+		client.SetEndpoint(srv.URL)
+		retry.Configure(client, retry.Policy{}, retry.Timeouts{Total: 20 * time.Millisecond})
+
+		err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+		if got := getExitCode(err); got != exitCodeTimeout {
+			t.Errorf("exit code = %d, want %d (err: %v)", got, exitCodeTimeout, err)
+		}
+	})
+}
+
+func TestQueryRunE_ExitCodes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	origPrompt, origMode := globalOpts.UserPrompt, globalOpts.SearchMode
+	t.Cleanup(func() { globalOpts.UserPrompt, globalOpts.SearchMode = origPrompt, origMode })
+	globalOpts.UserPrompt = "test"
+
+	t.Run("missing API key is a config error", func(t *testing.T) {
+		t.Setenv("PPLX_API_KEY", "")
+		err := queryCmd.RunE(queryCmd, []string{})
+		if got := getExitCode(err); got != exitCodeConfiguration {
+			t.Errorf("exit code = %d, want %d (err: %v)", got, exitCodeConfiguration, err)
+		}
+	})
+
+	t.Run("invalid flag value is a validation error", func(t *testing.T) {
+		t.Setenv("PPLX_API_KEY", "test-key")
+		globalOpts.SearchMode = "bogus"
+		t.Cleanup(func() { globalOpts.SearchMode = origMode })
+		err := queryCmd.RunE(queryCmd, []string{})
+		if got := getExitCode(err); got != exitCodeValidation {
+			t.Errorf("exit code = %d, want %d (err: %v)", got, exitCodeValidation, err)
+		}
+	})
+}
+
+// newStalledServer returns a server that never answers before the test ends.
+func newStalledServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-done
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(done) })
+	return srv
+}
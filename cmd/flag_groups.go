@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// addRequestFlagGroups declares the flag combinations of the request flags
+// shared by query and chat that cannot work together. Cobra reports a
+// violation as a usage error before the config is loaded.
+//
+// --stream and --json are allowed together: the answer is streamed from the
+// API but printed once, as a complete JSON document.
+func addRequestFlagGroups(cmd *cobra.Command) {
+	cmd.MarkFlagsMutuallyExclusive("response-format-json-schema", "response-format-regex")
+	cmd.MarkFlagsMutuallyExclusive("response-format-json-schema", "json-schema-file")
+	cmd.MarkFlagsMutuallyExclusive("json-schema-file", "response-format-regex")
+	cmd.MarkFlagsRequiredTogether("location-lat", "location-lon")
+}
+
+// addQueryFlagGroups declares the flag combinations specific to query.
+func addQueryFlagGroups(cmd *cobra.Command) {
+	addRequestFlagGroups(cmd)
+	cmd.MarkFlagsMutuallyExclusive("batch", "user-prompt")
+	cmd.MarkFlagsMutuallyExclusive("batch", "user-prompt-file")
+	cmd.MarkFlagsMutuallyExclusive("batch", "compare")
+	cmd.MarkFlagsMutuallyExclusive("compare", "stream")
+	cmd.MarkFlagsMutuallyExclusive("export-request", "dry-run")
+	cmd.MarkFlagsMutuallyExclusive("export-request", "batch")
+	cmd.MarkFlagsMutuallyExclusive("export-request", "compare")
+}
+
+// validateFlagCombinations checks the flag combinations flag groups cannot
+// express because they depend on the value of --model. It runs before the
+// config is loaded and only judges models given on the command line that
+// the model registry knows, since config aliases are not resolved yet.
+func validateFlagCombinations(cmd *cobra.Command, _ []string) error {
+	if !cmd.Flags().Changed("model") {
+		return nil
+	}
+	info, ok := models.Lookup(globalOpts.Model)
+	if !ok {
+		return nil
+	}
+	hasResponseFormat := cmd.Flags().Changed("response-format-json-schema") ||
+		cmd.Flags().Changed("json-schema-file") || cmd.Flags().Changed("response-format-regex")
+	if hasResponseFormat && !info.SupportsResponseFormat {
+		return clerrors.NewValidationError("model", globalOpts.Model,
+			"does not support --response-format-json-schema or --response-format-regex")
+	}
+	if cmd.Flags().Changed("reasoning-effort") && !info.SupportsReasoningEffort {
+		return clerrors.NewValidationError("reasoning-effort", globalOpts.ReasoningEffort,
+			"is only supported by "+reasoningModels())
+	}
+	return nil
+}
+
+// reasoningModels lists the models of the registry that accept
+// reasoning_effort, for error messages.
+func reasoningModels() string {
+	var names []string
+	for _, m := range models.All() {
+		if m.SupportsReasoningEffort {
+			names = append(names, m.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// newFlagGroupsCommand returns a command with the request flags and flag
+// groups of query (or chat), whose RunE does nothing.
+func newFlagGroupsCommand(t *testing.T, query bool) *cobra.Command {
+	t.Helper()
+	withGlobalOpts(t)
+	savedBatch, savedCompare := queryBatchFile, queryCompare
+	t.Cleanup(func() { queryBatchFile, queryCompare = savedBatch, savedCompare })
+
+	cmd := &cobra.Command{Use: "query", RunE: func(*cobra.Command, []string) error { return nil }}
+	addChatFlags(cmd)
+	addSearchFlags(cmd)
+	addFormatFlags(cmd)
+	addResearchFlags(cmd)
+	addResponseFlags(cmd)
+	if query {
+		addOutputFlags(cmd)
+		cmd.PersistentFlags().StringVarP(&globalOpts.UserPrompt, "user-prompt", "p", "", "")
+		cmd.PersistentFlags().StringVarP(&globalOpts.SystemPrompt, "sys-prompt", "s", "", "")
+		addPromptFileFlags(cmd)
+		addBatchFlags(cmd)
+		addCompareFlags(cmd)
+		addExportFlags(cmd)
+		addQueryFlagGroups(cmd)
+	} else {
+		addRequestFlagGroups(cmd)
+	}
+	cmd.PreRunE = validateFlagCombinations
+	markUsageErrors(cmd)
+	cmd.SilenceErrors, cmd.SilenceUsage = true, true
+	return cmd
+}
+
+func TestFlagGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   bool
+		args    []string
+		wantErr string
+	}{
+		{"json schema and regex", true,
+			[]string{"--response-format-json-schema", "{}", "--response-format-regex", "a+"}, "none of the others"},
+		{"json schema and regex in chat", false,
+			[]string{"--response-format-json-schema", "{}", "--response-format-regex", "a+"}, "none of the others"},
+		{"json schema and schema file", true,
+			[]string{"--response-format-json-schema", "{}", "--json-schema-file", "s.json"}, "none of the others"},
+		{"schema file and regex in chat", false,
+			[]string{"--json-schema-file", "s.json", "--response-format-regex", "a+"}, "none of the others"},
+		{"latitude without longitude", true, []string{"--location-lat", "48.8"}, "must all be set"},
+		{"longitude without latitude in chat", false, []string{"--location-lon", "2.3"}, "must all be set"},
+		{"batch and user prompt", true, []string{"--batch", "in.jsonl", "-p", "hi"}, "none of the others"},
+		{"export and dry run", true, []string{"--export-request", "openai", "--dry-run", "-p", "hi"}, "none of the others"},
+		{"sys prompt and system prompt file", true,
+			[]string{"-s", "Be brief", "--system-prompt-file", "s.txt", "-p", "hi"}, "none of the others"},
+		{"sys prompt and the former system prompt file flag", true,
+			[]string{"-s", "Be brief", "--sys-prompt-file", "s.txt", "-p", "hi"}, "none of the others"},
+		{"batch and user prompt file", true,
+			[]string{"--batch", "in.jsonl", "--user-prompt-file", "p.txt"}, "none of the others"},
+		{"reasoning effort on a model without it", true,
+			[]string{"-m", "sonar", "--reasoning-effort", "high", "-p", "hi"}, "reasoning-effort"},
+		{"reasoning effort in chat", false, []string{"-m", "sonar-pro", "--reasoning-effort", "low"}, "reasoning-effort"},
+		{"location pair", true, []string{"--location-lat", "48.8", "--location-lon", "2.3", "-p", "hi"}, ""},
+		{"reasoning effort on deep research", true,
+			[]string{"-m", "sonar-deep-research", "--reasoning-effort", "high", "-p", "hi"}, ""},
+		{"reasoning effort on an alias", true, []string{"-m", "deep", "--reasoning-effort", "high", "-p", "hi"}, ""},
+		{"stream with json", true, []string{"--stream", "--json", "-p", "hi"}, ""},
+		{"compare and stream", true, []string{"--compare", "sonar,sonar-pro", "--stream", "-p", "hi"}, "none of the others"},
+		{"batch and compare", true, []string{"--batch", "in.jsonl", "--compare", "sonar,sonar-pro"}, "none of the others"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newFlagGroupsCommand(t, tt.query)
+			cmd.SetArgs(tt.args)
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Execute(%v) error: %v", tt.args, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Execute(%v) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			if got := getExitCode(err); got != exitCodeValidation {
+				t.Errorf("exit code = %d, want %d", got, exitCodeValidation)
+			}
+		})
+	}
+}
+
+func TestSystemPromptFileAlias(t *testing.T) {
+	for _, flag := range []string{"--system-prompt-file", "--sys-prompt-file"} {
+		t.Run(flag, func(t *testing.T) {
+			cmd := newFlagGroupsCommand(t, true)
+			cmd.SetArgs([]string{flag, "reviewer.txt", "-p", "hi"})
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+			if globalOpts.SystemPromptFile != "reviewer.txt" {
+				t.Errorf("SystemPromptFile = %q, want %q", globalOpts.SystemPromptFile, "reviewer.txt")
+			}
+		})
+	}
+}
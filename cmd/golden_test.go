@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/cmd/testutil"
+)
+
+// runGoldenQuery runs the query command against a fake API, with the flags
+// set by setFlags, and returns its result.
+func runGoldenQuery(t *testing.T, setFlags func()) testutil.Result {
+	t.Helper()
+	testutil.Isolate(t)
+	t.Setenv("PPLX_API_KEY", "test-key")
+	withGlobalOpts(t)
+	api := testutil.NewAPI(t)
+
+	globalOpts.BaseURL = api.URL
+	globalOpts.MaxRetries = 0
+	globalOpts.Stream = false
+	globalOpts.Quiet = true
+	globalOpts.Render = "plain"
+	if setFlags != nil {
+		setFlags()
+	}
+
+	res := testutil.Run(t, queryCmd, "When was Go released?")
+	if res.Err != nil {
+		t.Fatalf("query error = %v\nstderr:\n%s", res.Err, res.Stderr)
+	}
+	if n := len(api.Requests()); n != 1 {
+		t.Errorf("requests sent = %d, want 1", n)
+	}
+	return res
+}
+
+func TestGolden_Query(t *testing.T) {
+	tests := []struct {
+		name         string
+		golden       string
+		setFlags     func()
+		replacements []testutil.Replacement
+	}{
+		{
+			name:   "plain",
+			golden: "query_plain.txt",
+		},
+		{
+			name:         "json",
+			golden:       "query_json.json",
+			setFlags:     func() { globalOpts.OutputJSON = true },
+			replacements: []testutil.Replacement{testutil.Replace(`"elapsed_ms": \d+`, `"elapsed_ms": 0`)},
+		},
+		{
+			name:     "citations footnote",
+			golden:   "query_citations_footnote.txt",
+			setFlags: func() { globalOpts.Citations = "footnote" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runGoldenQuery(t, tt.setFlags)
+			testutil.Golden(t, tt.golden, res.Stdout, tt.replacements...)
+		})
+	}
+}
+
+func TestGolden_ConfigOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		golden  string
+		format  string
+		section string
+	}{
+		{name: "table", golden: "config_options_table.txt", format: "table"},
+		{name: "json", golden: "config_options_output.json", format: "json", section: "output"},
+		{name: "yaml", golden: "config_options_output.yaml", format: "yaml", section: "output"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Isolate(t)
+			oldFormat, oldSection, oldValidation := optionsFormat, optionsSection, optionsValidation
+			t.Cleanup(func() { optionsFormat, optionsSection, optionsValidation = oldFormat, oldSection, oldValidation })
+			optionsFormat, optionsSection, optionsValidation = tt.format, tt.section, true
+
+			res := testutil.Run(t, configOptionsCmd)
+			if res.Err != nil {
+				t.Fatalf("config options error = %v", res.Err)
+			}
+			testutil.Golden(t, tt.golden, res.Stdout)
+		})
+	}
+}
+
+func TestGolden_ConfigShow(t *testing.T) {
+	tests := []struct {
+		name   string
+		golden string
+		json   bool
+	}{
+		{name: "yaml", golden: "config_show.yaml"},
+		{name: "json", golden: "config_show.json", json: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Isolate(t)
+			t.Setenv("PERPLEXITY_API_KEY", "pplx-golden-secret-key")
+			oldPath, oldJSON, oldProfile := configFilePath, jsonOutput, profileName
+			oldResolved, oldTrace := showResolved, showTrace
+			t.Cleanup(func() {
+				configFilePath, jsonOutput, profileName = oldPath, oldJSON, oldProfile
+				showResolved, showTrace = oldResolved, oldTrace
+			})
+			configFilePath, jsonOutput, profileName = "testdata/valid_config.yaml", tt.json, ""
+			showResolved, showTrace = false, false
+
+			res := testutil.Run(t, configShowCmd)
+			if res.Err != nil {
+				t.Fatalf("config show error = %v", res.Err)
+			}
+			if strings.Contains(res.Stdout, "pplx-golden-secret-key") {
+				t.Errorf("config show prints the API key:\n%s", res.Stdout)
+			}
+			testutil.Golden(t, tt.golden, res.Stdout)
+		})
+	}
+}
+
+func TestGolden_Doctor(t *testing.T) {
+	home := testutil.Isolate(t)
+	t.Setenv("PPLX_API_KEY", "test-key")
+	oldPath, oldFormat, oldOnline := configFilePath, doctorFormat, doctorOnline
+	t.Cleanup(func() { configFilePath, doctorFormat, doctorOnline = oldPath, oldFormat, oldOnline })
+	configFilePath, doctorFormat, doctorOnline = "", doctorFormatTable, false
+	dir := filepath.Join(home, ".config", "pplx")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	copyTestFixture(t, "minimal_config.yaml", filepath.Join(dir, "config.yaml"))
+
+	res := testutil.Run(t, doctorCmd)
+	if res.Err != nil {
+		t.Fatalf("doctor error = %v\n%s", res.Err, res.Stdout)
+	}
+	testutil.Golden(t, "doctor.txt", res.Stdout,
+		testutil.ReplaceLiteral(home, "$HOME"),
+		testutil.ReplaceLiteral(runtime.Version(), "$GOVERSION"),
+		testutil.ReplaceLiteral(runtime.GOOS+"/"+runtime.GOARCH, "$GOOS/$GOARCH"),
+	)
+}
@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/history"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// historyTabPadding is the column padding of the history table.
+	historyTabPadding = 2
+	// historyTimeLayout formats the entry times of the history table.
+	historyTimeLayout = "2006-01-02 15:04"
+	// historyPromptWidth is how many characters of a prompt the history
+	// table shows.
+	historyPromptWidth = 60
+	// defaultHistoryLimit is how many entries pplx history lists by default.
+	defaultHistoryLimit = 20
+)
+
+// Prompt history flags of the query command.
+var (
+	queryLast      bool
+	queryNoHistory bool
+)
+
+// Flags of the history commands.
+var (
+	historyFuzzy bool
+	historyLimit int
+	historyJSON  bool
+	historyKeep  int
+)
+
+// historyRerunExcludedFlags are the query flags history rerun does not
+// accept: the prompt comes from the entry.
+var historyRerunExcludedFlags = []string{"user-prompt", "user-prompt-file", "last", "batch"}
+
+// historyCmd lists and searches the prompt history.
+var historyCmd = &cobra.Command{
+	Use:   "history [search]",
+	Short: "List and search the prompts of past queries",
+	Long: `List the user prompts of past queries, newest first, from the prompt history
+(~/.local/share/pplx/prompt_history.jsonl). With a search, only the prompts
+containing it are listed, ignoring case; with --fuzzy, prompts containing its
+characters in order match too, best matches first.
+
+Every query is recorded with its time and model, unless output.history is
+false or --no-history is given. Prompts are stored as given, without
+redaction: use --no-history for sensitive prompts. Batch queries are not
+recorded.
+
+Examples:
+  pplx history
+  pplx history kubernetes
+  pplx history --fuzzy "k8s ingrs"
+  pplx history rerun 42 -m sonar-pro
+  pplx query --last
+  pplx history prune --keep 500`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		entries, err := store.Entries()
+		if err != nil {
+			return clerrors.NewIOError("cannot read prompt history", err)
+		}
+		query := ""
+		if len(args) == 1 {
+			query = args[0]
+		}
+		matches := history.Search(entries, query, historyFuzzy)
+		if historyLimit > 0 && len(matches) > historyLimit {
+			matches = matches[:historyLimit]
+		}
+		if historyJSON {
+			data, err := json.MarshalIndent(matches, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal prompt history: %w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return nil
+		}
+		return writeHistoryTable(cmd.OutOrStdout(), matches, len(entries) > 0)
+	},
+}
+
+// historyRerunCmd runs the prompt of a history entry again.
+var historyRerunCmd = &cobra.Command{
+	Use:   "rerun <id> [query flags]",
+	Short: "Run the prompt of a past query again",
+	Long: `Send the prompt of the history entry numbered id again, to the model it was
+sent to. Every flag of pplx query except the prompt flags is accepted and
+overrides the entry: --model sends the prompt to another model.
+
+Examples:
+  pplx history rerun 42
+  pplx history rerun 42 -m sonar-pro --search-recency week`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil || id < 1 {
+			return clerrors.NewValidationError("id", args[0], "must be the number of a history entry")
+		}
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		entry, err := store.Find(id)
+		if err != nil {
+			return historyLookupError(err, "id", args[0])
+		}
+		if err := applyHistoryEntry(cmd.Flags(), entry); err != nil {
+			return err
+		}
+		if err := preRunQuery(cmd, nil); err != nil {
+			return err
+		}
+		return queryCmd.RunE(cmd, nil)
+	},
+}
+
+// historyPruneCmd drops the oldest entries of the prompt history.
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete all but the most recent prompts from the history",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if historyKeep < 0 {
+			return clerrors.NewValidationError("keep", strconv.Itoa(historyKeep), "must not be negative")
+		}
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		removed, err := store.Prune(historyKeep)
+		if err != nil {
+			return clerrors.NewIOError("cannot prune prompt history", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed %d prompt(s), kept the %d most recent.\n", removed, historyKeep)
+		return nil
+	},
+}
+
+// historyStore returns the store of the default prompt history file.
+func historyStore() (*history.Store, error) {
+	path, err := history.DefaultPath()
+	if err != nil {
+		return nil, clerrors.NewIOError("cannot locate prompt history", err)
+	}
+	return history.NewStore(path), nil
+}
+
+// historyLookupError turns the error of finding an entry into a validation
+// error of flag when there is no such entry, and an I/O error otherwise.
+func historyLookupError(err error, flag, value string) error {
+	switch {
+	case errors.Is(err, clerrors.ErrHistoryEntryNotFound):
+		return clerrors.NewValidationError(flag, value, "no prompt history entry has this number; see pplx history")
+	case errors.Is(err, clerrors.ErrHistoryEmpty):
+		return clerrors.NewValidationError(flag, value, "no prompt recorded yet")
+	default:
+		return clerrors.NewIOError("cannot read prompt history", err)
+	}
+}
+
+// applyHistoryEntry makes entry the prompt of the query, sent to the model
+// of entry unless --model is given. It runs before the --preset preset is
+// applied and the config is loaded, so the entry's model overrides both,
+// and flags given on the command line override the entry.
+func applyHistoryEntry(flags *pflag.FlagSet, entry history.Entry) error {
+	globalOpts.UserPrompt = entry.Prompt
+	if entry.Model == "" || flags.Changed("model") {
+		return nil
+	}
+	if err := flags.Set("model", entry.Model); err != nil {
+		return clerrors.NewValidationError("model", entry.Model, err.Error())
+	}
+	return nil
+}
+
+// applyLastPrompt makes the most recent prompt of the history the prompt of
+// the query under --last.
+func applyLastPrompt(cmd *cobra.Command, args []string) error {
+	if !queryLast {
+		return nil
+	}
+	if len(args) > 0 {
+		return clerrors.NewValidationError("last", "true",
+			"repeats the last prompt; do not give another as arguments")
+	}
+	store, err := historyStore()
+	if err != nil {
+		return err
+	}
+	entry, err := store.Last()
+	if err != nil {
+		return historyLookupError(err, "last", "true")
+	}
+	logger.Debug("repeating prompt from history", "id", entry.ID)
+	return applyHistoryEntry(cmd.Flags(), entry)
+}
+
+// recordPrompt adds the user prompt of the query to the prompt history,
+// unless output.history is false or --no-history is given. Failures are
+// logged: the history never fails a query.
+func recordPrompt(cfg *config.ConfigData, model string) {
+	if queryNoHistory || !cfg.Output.HistoryEnabled() {
+		return
+	}
+	store, err := historyStore()
+	if err == nil {
+		_, err = store.Add(model, globalOpts.UserPrompt)
+	}
+	if err != nil {
+		logger.Warn("failed to record prompt history", "error", err)
+	}
+}
+
+// writeHistoryTable prints matches as aligned columns. recorded tells an
+// empty history from a search matching nothing.
+func writeHistoryTable(out io.Writer, matches []history.Match, recorded bool) error {
+	if len(matches) == 0 {
+		msg := "No prompts recorded."
+		if recorded {
+			msg = "No prompts match."
+		}
+		_, err := fmt.Fprintln(out, msg)
+		return err //nolint:wrapcheck // stdout write
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, historyTabPadding, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tMODEL\tPROMPT")
+	for _, m := range matches {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n",
+			m.ID, m.Time.Local().Format(historyTimeLayout), m.Model, promptSummary(m.Prompt))
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write prompt history: %w", err)
+	}
+	return nil
+}
+
+// promptSummary returns the first line of prompt, cut to historyPromptWidth
+// characters.
+func promptSummary(prompt string) string {
+	line, _, more := strings.Cut(strings.TrimSpace(prompt), "\n")
+	runes := []rune(strings.TrimSpace(line))
+	if len(runes) > historyPromptWidth {
+		return string(runes[:historyPromptWidth-1]) + "…"
+	}
+	if more {
+		return string(runes) + " …"
+	}
+	return string(runes)
+}
+
+// addHistoryFlags registers --last and --no-history on query, after the
+// prompt and batch flags they exclude.
+func addHistoryFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&queryLast, "last", false,
+		"Repeat the most recent prompt of the history, to the same model unless --model is given")
+	cmd.Flags().BoolVar(&queryNoHistory, "no-history", false,
+		"Do not record this prompt in the prompt history")
+	cmd.MarkFlagsMutuallyExclusive("last", "user-prompt")
+	cmd.MarkFlagsMutuallyExclusive("last", "user-prompt-file")
+	cmd.MarkFlagsMutuallyExclusive("last", "batch")
+}
+
+// registerHistoryCommands adds the history commands. history rerun accepts
+// the flags of query, which must be registered first: the flags are shared,
+// so their completions and mutually exclusive groups apply to both
+// commands.
+func registerHistoryCommands(query *cobra.Command) {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyRerunCmd, historyPruneCmd)
+	historyCmd.Flags().BoolVar(&historyFuzzy, "fuzzy", false,
+		"Match prompts containing the characters of the search in order, best matches first")
+	historyCmd.Flags().IntVar(&historyLimit, "limit", defaultHistoryLimit, "Maximum number of prompts listed (0 for all)")
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "Output as JSON")
+	historyPruneCmd.Flags().IntVar(&historyKeep, "keep", history.DefaultKeep, "Number of most recent prompts kept")
+	query.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if !slices.Contains(historyRerunExcludedFlags, f.Name) {
+			historyRerunCmd.Flags().AddFlag(f)
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/history"
+)
+
+// withHistory points the prompt history at a temporary home directory and
+// records prompts in it, oldest first, each sent to model.
+func withHistory(t *testing.T, model string, prompts ...string) *history.Store {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	store, err := historyStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prompt := range prompts {
+		if _, err := store.Add(model, prompt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestPreRunQuery_Last(t *testing.T) {
+	tests := []struct {
+		name       string
+		prompts    []string
+		args       []string
+		wantErr    bool
+		wantModel  string
+		wantPrompt string
+	}{
+		{"repeats the last prompt and model", []string{"one", "two"}, []string{"--last"}, false, "sonar-pro", "two"},
+		{"flag overrides the model", []string{"one"}, []string{"--last", "-m", "sonar"}, false, "sonar", "one"},
+		{"empty history", nil, []string{"--last"}, true, "", ""},
+		{"prompt argument", []string{"one"}, []string{"--last", "other"}, true, "", ""},
+		{"excludes --user-prompt", []string{"one"}, []string{"--last", "-p", "hi"}, true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withHistory(t, "sonar-pro", tt.prompts...)
+			withPresetsDir(t)
+			cmd := newFlagGroupsCommand(t, true)
+			savedPreset, savedLast, savedNoHistory := queryPreset, queryLast, queryNoHistory
+			t.Cleanup(func() { queryPreset, queryLast, queryNoHistory = savedPreset, savedLast, savedNoHistory })
+			addPresetFlag(cmd)
+			addHistoryFlags(cmd)
+			cmd.PreRunE = preRunQuery
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantErr {
+				if err == nil || getExitCode(err) != 2 {
+					t.Errorf("Execute() error = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if globalOpts.Model != tt.wantModel || globalOpts.UserPrompt != tt.wantPrompt {
+				t.Errorf("model, prompt = %q, %q; want %q, %q",
+					globalOpts.Model, globalOpts.UserPrompt, tt.wantModel, tt.wantPrompt)
+			}
+		})
+	}
+}
+
+func TestRecordPrompt(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name      string
+		noHistory bool
+		history   *bool
+		want      int
+	}{
+		{"recorded by default", false, nil, 1},
+		{"--no-history", true, nil, 0},
+		{"output.history false", false, &disabled, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := withHistory(t, "")
+			withGlobalOpts(t)
+			saved := queryNoHistory
+			t.Cleanup(func() { queryNoHistory = saved })
+			queryNoHistory = tt.noHistory
+			globalOpts.UserPrompt = "What is Go?"
+
+			cfg := config.NewConfigData()
+			cfg.Output.History = tt.history
+			recordPrompt(cfg, "sonar")
+
+			entries, err := store.Entries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.want {
+				t.Fatalf("recorded %d prompts, want %d", len(entries), tt.want)
+			}
+			if tt.want > 0 && (entries[0].Prompt != "What is Go?" || entries[0].Model != "sonar") {
+				t.Errorf("entry = %+v", entries[0])
+			}
+		})
+	}
+}
+
+func TestWriteHistoryTable(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeHistoryTable(&out, nil, false); err != nil || out.String() != "No prompts recorded.\n" {
+		t.Errorf("writeHistoryTable() on an empty history = %q, %v", out.String(), err)
+	}
+	out.Reset()
+	if err := writeHistoryTable(&out, nil, true); err != nil || out.String() != "No prompts match.\n" {
+		t.Errorf("writeHistoryTable() without matches = %q, %v", out.String(), err)
+	}
+
+	out.Reset()
+	entry := history.Entry{ID: 7, Time: time.Now(), Model: "sonar", Prompt: "Explain Go\ngenerics"}
+	matches := []history.Match{{Entry: entry}}
+	if err := writeHistoryTable(&out, matches, true); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") || !strings.HasPrefix(lines[1], "7 ") ||
+		!strings.HasSuffix(lines[1], "sonar  Explain Go …") {
+		t.Errorf("writeHistoryTable() =\n%s", out.String())
+	}
+}
+
+func TestPromptSummary(t *testing.T) {
+	long := strings.Repeat("a", historyPromptWidth+10)
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"  What is Go?  ", "What is Go?"},
+		{"first line\nsecond line", "first line …"},
+		{long, strings.Repeat("a", historyPromptWidth-1) + "…"},
+	}
+	for _, tt := range tests {
+		if got := promptSummary(tt.in); got != tt.want {
+			t.Errorf("promptSummary(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryPrune(t *testing.T) {
+	store := withHistory(t, "", "a", "b", "c")
+	saved := historyKeep
+	t.Cleanup(func() { historyKeep = saved })
+
+	historyKeep = 1
+	var out bytes.Buffer
+	historyPruneCmd.SetOut(&out)
+	t.Cleanup(func() { historyPruneCmd.SetOut(nil) })
+	if err := historyPruneCmd.RunE(historyPruneCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Removed 2 prompt(s), kept the 1 most recent.\n" {
+		t.Errorf("output = %q", out.String())
+	}
+	if last, err := store.Last(); err != nil || last.Prompt != "c" {
+		t.Errorf("Last() after prune = %+v, %v", last, err)
+	}
+
+	historyKeep = -1
+	if err := historyPruneCmd.RunE(historyPruneCmd, nil); err == nil || getExitCode(err) != 2 {
+		t.Errorf("prune --keep -1 = %v, want a validation error", err)
+	}
+}
+
+func TestRegisterHistoryCommands_RerunFlags(t *testing.T) {
+	for _, name := range []string{"model", "search-recency", "no-history"} {
+		if historyRerunCmd.Flags().Lookup(name) == nil {
+			t.Errorf("history rerun is missing --%s", name)
+		}
+	}
+	for _, name := range historyRerunExcludedFlags {
+		if historyRerunCmd.Flags().Lookup(name) != nil {
+			t.Errorf("history rerun should not accept --%s", name)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/hooks"
+	"github.com/sgaunet/pplx/pkg/usage"
+)
+
+// requestHooks returns the request hooks configured in cfg: the system
+// prompt prefix (api.system_prompt_prefix), then the audit log
+// (api.audit_log), so that the audit log records requests as sent.
+func requestHooks(cfg *config.ConfigData) []hooks.Hook {
+	if cfg == nil {
+		return nil
+	}
+	var list []hooks.Hook
+	if cfg.API.SystemPromptPrefix != "" {
+		list = append(list, hooks.NewPromptPrefix(cfg.API.SystemPromptPrefix))
+	}
+	if cfg.API.AuditLog != "" {
+		list = append(list, hooks.NewAuditLog(cfg.API.AuditLog))
+	}
+	return list
+}
+
+// usageHook records the usage of every response in the usage log under
+// source, and the partial usage of interrupted requests. As a hook it sees each request of a chat turn, continuations of a
+// truncated answer included.
+type usageHook struct {
+	source string
+}
+
+func (usageHook) BeforeRequest(*perplexity.CompletionRequest) error { return nil }
+
+func (h usageHook) AfterResponse(res *perplexity.CompletionResponse, err error) {
+	switch {
+	case err == nil:
+		usage.Track(h.source, res)
+	case errors.Is(err, clerrors.ErrRequestInterrupted):
+		usage.TrackPartial(h.source, res)
+	}
+}
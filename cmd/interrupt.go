@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+)
+
+// interruptedMarker ends the partial answer of an interrupted request.
+const interruptedMarker = "— interrupted —"
+
+// interruptContext returns a context cancelled by the first SIGINT or
+// SIGTERM. Default signal handling is restored as soon as it is, so a second
+// Ctrl-C exits immediately. stop releases the signal handler.
+func interruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(parent, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
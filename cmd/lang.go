@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/i18n"
+	"github.com/spf13/cobra"
+)
+
+// messageLang is --lang: the language of the messages pplx shows, such as
+// the config wizard and doctor output. Answers are not affected; see
+// --language for those.
+var messageLang string
+
+// addLangFlag registers --lang on cmd and its subcommands.
+func addLangFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&messageLang, "lang", "",
+		"Language of messages: "+strings.Join(i18n.Languages(), ", ")+
+			" (default: $"+i18n.EnvLang+", then the locale from $LANG)")
+	if err := cmd.RegisterFlagCompletionFunc("lang",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return i18n.Languages(), cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'lang' flag: %v\n", err)
+	}
+}
+
+// validateLang rejects a --lang without a catalog. The language from the
+// environment is not checked: an unsupported locale selects English.
+func validateLang() error {
+	if messageLang == "" || i18n.Supported(messageLang) {
+		return nil
+	}
+	return clerrors.NewValidationError("lang", messageLang,
+		"must be one of: "+strings.Join(i18n.Languages(), ", "))
+}
+
+// applyLanguage selects the language of messages from --lang in args, the
+// environment, or the locale, and translates the command summaries. It
+// runs before the command line is parsed so that help is translated too.
+func applyLanguage(args []string) {
+	i18n.SetLanguage(i18n.Detect(langFromArgs(args)))
+	localizeCommandSummaries(configCmd)
+}
+
+// langFromArgs returns the value of --lang in args, or "" when it is not
+// given. Arguments after "--" are not flags.
+func langFromArgs(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "--lang="); ok {
+			return value
+		}
+		if arg == "--lang" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// localizeCommandSummaries replaces the summary of cmd and its subcommands
+// with the message "cmd.<command path>" of the selected language, such as
+// "cmd.config.profile.create", when the catalog has one.
+func localizeCommandSummaries(cmd *cobra.Command) {
+	if short, ok := i18n.Lookup(commandMessageKey(cmd)); ok {
+		cmd.Short = short
+	}
+	for _, sub := range cmd.Commands() {
+		localizeCommandSummaries(sub)
+	}
+}
+
+// commandMessageKey returns the catalog key of the summary of cmd.
+func commandMessageKey(cmd *cobra.Command) string {
+	path := strings.Fields(cmd.CommandPath())
+	return "cmd." + strings.Join(path[1:], ".")
+}
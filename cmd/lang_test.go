@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/i18n"
+	"github.com/spf13/cobra"
+)
+
+// useLanguage selects lang for messages and restores English, with its
+// command summaries, after the test.
+func useLanguage(t *testing.T, lang string) {
+	t.Helper()
+	i18n.SetLanguage(lang)
+	localizeCommandSummaries(configCmd)
+	t.Cleanup(func() {
+		i18n.SetLanguage(i18n.English)
+		localizeCommandSummaries(configCmd)
+	})
+}
+
+func TestCommandSummaries_MatchEnglishCatalog(t *testing.T) {
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		key := commandMessageKey(cmd)
+		if short, ok := i18n.Lookup(key); !ok || short != cmd.Short {
+			t.Errorf("%s: catalog message %q = %q, want the command summary %q", cmd.CommandPath(), key, short, cmd.Short)
+		}
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+	}
+	walk(configCmd)
+}
+
+func TestLocalizeCommandSummaries(t *testing.T) {
+	useLanguage(t, "fr")
+	if configProfileCreateCmd.Short != "Créer un nouveau profil" {
+		t.Errorf("config profile create summary = %q, want the French one", configProfileCreateCmd.Short)
+	}
+}
+
+func TestLangFromArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "absent", args: []string{"config", "show"}},
+		{name: "separate value", args: []string{"--lang", "fr", "config"}, want: "fr"},
+		{name: "equals", args: []string{"config", "--lang=fr"}, want: "fr"},
+		{name: "after --", args: []string{"query", "--", "--lang", "fr"}},
+		{name: "missing value", args: []string{"--lang"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := langFromArgs(tt.args); got != tt.want {
+				t.Errorf("langFromArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateLang(t *testing.T) {
+	oldLang := messageLang
+	t.Cleanup(func() { messageLang = oldLang })
+
+	for _, lang := range []string{"", "en", "fr", "fr_FR.UTF-8"} {
+		messageLang = lang
+		if err := validateLang(); err != nil {
+			t.Errorf("validateLang() with --lang %q error = %v", lang, err)
+		}
+	}
+	messageLang = "tlh"
+	if err := validateLang(); err == nil {
+		t.Error("validateLang() with an unsupported --lang should fail")
+	}
+}
+
+func TestPrintDoctorTable_French(t *testing.T) {
+	useLanguage(t, "fr")
+	checks := []config.HealthCheck{
+		{Name: "Config File", Status: config.CheckPass, Detail: "found"},
+		{Name: "File Permissions", Status: config.CheckWarn, Detail: "0644"},
+		{Name: "Custom Check", Status: config.CheckPass, Detail: "ok"},
+	}
+	var buf bytes.Buffer
+	if err := printDoctorTable(&buf, i18n.T("doctor.title.config"), checks); err != nil {
+		t.Fatalf("printDoctorTable() error = %v", err)
+	}
+	for _, want := range []string{
+		"Vérification de la configuration\n",
+		"  Fichier de configuration: ✓ found",
+		"  Permissions du fichier:   ⚠ 0644",
+		"  Custom Check:             ✓ ok",
+		"2/3 vérifications réussies, 1 avertissement(s).",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("doctor output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/models"
+)
+
+// maxTokensMax is the --max-tokens value standing for the output limit of
+// the model.
+const maxTokensMax = "max"
+
+// maxTokensValue is the value of --max-tokens: a number of tokens, 0 for the
+// model default, or max for the output limit of the model, stored as
+// models.MaxTokensModelLimit until resolveMaxTokens knows the model.
+type maxTokensValue struct {
+	p *int
+}
+
+// String returns the flag value as given.
+func (v maxTokensValue) String() string {
+	if v.p == nil {
+		return "0"
+	}
+	if *v.p == models.MaxTokensModelLimit {
+		return maxTokensMax
+	}
+	return strconv.Itoa(*v.p)
+}
+
+// Set parses a number of tokens or max.
+func (v maxTokensValue) Set(s string) error {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, maxTokensMax) {
+		*v.p = models.MaxTokensModelLimit
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%w: must be a number of tokens, 0 for the model default, or %s for the model's limit",
+			clerrors.ErrInvalidMaxTokens, maxTokensMax)
+	}
+	*v.p = n
+	return nil
+}
+
+// Type names the value in the help.
+func (v maxTokensValue) Type() string {
+	return "int"
+}
+
+// resolveMaxTokens replaces --max-tokens 0 with the default of the model and
+// --max-tokens max with its output limit, and rejects values above the
+// limit, so that the API never answers an oversized max_tokens with an
+// opaque 400. Models missing from the registry are not checked. It runs
+// once the model aliases are resolved.
+func resolveMaxTokens() error {
+	value := maxTokensValue{&globalOpts.MaxTokens}.String()
+	maxTokens, err := models.ResolveMaxTokens(globalOpts.Model, globalOpts.MaxTokens)
+	if err != nil {
+		return clerrors.NewValidationError("max-tokens", value, err.Error())
+	}
+	globalOpts.MaxTokens = maxTokens
+	return nil
+}
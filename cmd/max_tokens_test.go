@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/cmd/testutil"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/models"
+)
+
+func TestMaxTokensValue(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantStr string
+		wantErr bool
+	}{
+		{"2048", 2048, "2048", false},
+		{"0", 0, "0", false},
+		{"MAX", models.MaxTokensModelLimit, "max", false},
+		{"-3", 0, "", true},
+		{"lots", 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var n int
+			v := maxTokensValue{&n}
+			err := v.Set(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, clerrors.ErrInvalidMaxTokens) {
+					t.Errorf("Set(%q) error = %v, want ErrInvalidMaxTokens", tt.in, err)
+				}
+				return
+			}
+			if err != nil || n != tt.want || v.String() != tt.wantStr {
+				t.Errorf("Set(%q) = %d (%q), %v; want %d (%q)", tt.in, n, v.String(), err, tt.want, tt.wantStr)
+			}
+		})
+	}
+}
+
+func TestResolveModel_MaxTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     string
+		maxTokens int
+		want      int
+		wantErr   string
+	}{
+		{"unset is the model default", "sonar", 0, perplexity.DefaultMaxTokens, ""},
+		{"max is the model limit", "sonar-reasoning-pro", models.MaxTokensModelLimit, 32_000, ""},
+		{"alias resolved first", "deep", models.MaxTokensModelLimit, 64_000, ""},
+		{"above the limit", "sonar", 200_000, 0, "200000 requested, sonar writes at most 8000 tokens"},
+		{"max of an unknown model", "sonar-next", models.MaxTokensModelLimit, 0, "no known output limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withGlobalOpts(t)
+			globalOpts.Model = tt.model
+			globalOpts.MaxTokens = tt.maxTokens
+			globalOpts.ModelAliases = map[string]string{"deep": "sonar-deep-research"}
+
+			err := resolveModel()
+			if tt.wantErr != "" {
+				if err == nil || getExitCode(err) != 2 || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolveModel() error = %v, want a validation error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || globalOpts.MaxTokens != tt.want {
+				t.Errorf("resolveModel() = %v, max tokens %d; want %d", err, globalOpts.MaxTokens, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery_MaxTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxTokens int
+		want      float64
+		wantErr   bool
+	}{
+		{"model default", 0, perplexity.DefaultMaxTokens, false},
+		{"max", models.MaxTokensModelLimit, 8_000, false},
+		{"oversized", 200_000, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Isolate(t)
+			t.Setenv("PPLX_API_KEY", "test-key")
+			withGlobalOpts(t)
+			api := testutil.NewAPI(t)
+			globalOpts.BaseURL = api.URL
+			globalOpts.MaxRetries = 0
+			globalOpts.Quiet = true
+			globalOpts.Model = "sonar-pro"
+			globalOpts.MaxTokens = tt.maxTokens
+
+			res := testutil.Run(t, queryCmd, "When was Go released?")
+			if tt.wantErr {
+				if res.Err == nil || getExitCode(res.Err) != 2 || len(api.Requests()) != 0 {
+					t.Errorf("query error = %v after %d requests, want a validation error before any request",
+						res.Err, len(api.Requests()))
+				}
+				return
+			}
+			if res.Err != nil {
+				t.Fatalf("query error = %v\nstderr:\n%s", res.Err, res.Stderr)
+			}
+			if got := api.Requests()[0]["max_tokens"]; got != tt.want {
+				t.Errorf("max_tokens sent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChat_MaxTokens(t *testing.T) {
+	testutil.Isolate(t)
+	withGlobalOpts(t)
+	saved := chatDryRun
+	t.Cleanup(func() { chatDryRun = saved })
+	chatDryRun = true
+	globalOpts.Model = "sonar-deep-research"
+	globalOpts.MaxTokens = models.MaxTokensModelLimit
+
+	res := testutil.Run(t, chatCmd)
+	if res.Err != nil {
+		t.Fatalf("chat --dry-run error = %v", res.Err)
+	}
+	if !strings.Contains(res.Stdout, `"max_tokens": 64000`) {
+		t.Errorf("chat --dry-run request does not set the model limit:\n%s", res.Stdout)
+	}
+
+	globalOpts.Model = "sonar"
+	globalOpts.MaxTokens = 200_000
+	if res := testutil.Run(t, chatCmd); res.Err == nil || getExitCode(res.Err) != 2 {
+		t.Errorf("chat with an oversized --max-tokens error = %v, want a validation error", res.Err)
+	}
+}
+
+func TestValidateCompare_MaxTokens(t *testing.T) {
+	withGlobalOpts(t)
+	saved := queryCompare
+	t.Cleanup(func() { queryCompare = saved })
+	queryCompare = []string{"sonar-reasoning-pro", "sonar"}
+	globalOpts.MaxTokens = 16_000
+
+	err := validateCompare()
+	if err == nil || getExitCode(err) != 2 || !strings.Contains(err.Error(), "sonar writes at most 8000 tokens") {
+		t.Errorf("validateCompare() error = %v, want the limit of sonar", err)
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/mcp"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/spf13/cobra"
+)
+
+// MCP HTTP server flags.
+var (
+	mcpListenAddr   string
+	mcpDrainTimeout time.Duration
+	mcpMetrics      bool
+)
+
+var mcpHTTPCmd = &cobra.Command{
+	Use:   "mcp-http",
+	Short: "Start MCP server over streamable HTTP",
+	Long: `Start an MCP (Model Context Protocol) server that serves the same tools as
+mcp-stdio over the streamable HTTP transport, at http://<listen>/mcp.
+
+When api.mcp_auth_token is set in the config file, clients must send it as
+"Authorization: Bearer <token>"; the token is read at startup only. On SIGINT or SIGTERM the server stops
+accepting connections and gives in-flight requests --drain-timeout to finish.
+
+With --metrics, http://<listen>/metrics serves the counters of the usage log in
+the Prometheus text format, the same ones "pplx usage export --format
+prometheus" writes, behind the same authentication.
+
+` + mcpToolsHelp + `
+
+Examples:
+  pplx mcp-http
+  pplx mcp-http --listen 0.0.0.0:9000 --drain-timeout 30s
+  pplx mcp-http --metrics`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		server, cfg, err := newMCPServer(cmd)
+		if err != nil {
+			return err
+		}
+
+		token := cfg.API.MCPAuthToken
+		if token == "" && !isLoopbackAddr(mcpListenAddr) {
+			logger.Warn("MCP HTTP server is listening on a non-loopback address without authentication; "+
+				"set api.mcp_auth_token", "listen", mcpListenAddr)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		watchMCPConfig(ctx, server)
+
+		httpCfg := mcp.HTTPConfig{
+			Addr:         mcpListenAddr,
+			AuthToken:    token,
+			DrainTimeout: mcpDrainTimeout,
+		}
+		if mcpMetrics {
+			path, err := usage.DefaultPath()
+			if err != nil {
+				return clerrors.NewIOError("cannot locate usage log", err)
+			}
+			httpCfg.Metrics = usage.MetricsHandler(path)
+		}
+
+		fmt.Fprintf(os.Stderr, "MCP server listening on http://%s%s\n", mcpListenAddr, mcp.HTTPEndpoint)
+		if err := server.ServeHTTP(ctx, httpCfg); err != nil {
+			return clerrors.NewAPIError("MCP server error", err)
+		}
+		return nil
+	},
+}
+
+// isLoopbackAddr reports whether the host of a host:port address is a
+// loopback address or "localhost". An empty host listens on all interfaces.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -1,41 +1,66 @@
 package cmd
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
 	"github.com/sgaunet/pplx/pkg/mcp"
+	"github.com/sgaunet/pplx/pkg/ratelimit"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/warnings"
 	"github.com/spf13/cobra"
 )
 
+// MCP server flags.
+var (
+	mcpJobTTL      time.Duration
+	mcpMaxJobs     int
+	mcpSessionTTL  time.Duration
+	mcpMaxSessions int
+	mcpWatchConfig bool
+)
+
+// mcpToolsHelp describes the tools served by both MCP transports.
+const mcpToolsHelp = `Tools:
+  query            Run a query and return the answer synchronously
+  search           Run a web search and return only its search results
+  research_start   Start a query as a background job (default model: sonar-deep-research)
+  research_status  Poll a background job for its state and result
+  chat_create      Start a conversation kept by the server and return its session_id
+  chat_send        Send a message in a conversation and return the answer
+  chat_delete      End a conversation and free its history
+  export_request   Return the request of a query, as an OpenAI-compatible body, without sending it
+  set_profile      Switch the config profile whose defaults apply, for the rest of the session
+
+Finished research jobs are kept for --job-ttl; at most --max-research-jobs
+may be pending or running at once. Chat sessions idle for --chat-session-ttl
+are dropped; at most --max-chat-sessions are kept at once.
+
+Tool arguments a client omits default to the config file's defaults, search
+and output settings (with the active profile applied); set_profile applies
+another profile over them in memory, without changing the file. With --watch-config
+(the default) the file is reloaded when it changes; an invalid file is
+rejected with a logged error and the previous settings stay active.`
+
 var mcpStdioCmd = &cobra.Command{
 	Use:   "mcp-stdio",
 	Short: "Start MCP server in stdio mode",
-	Long:  `Start an MCP (Model Context Protocol) server that exposes Perplexity query functionality`,
-	RunE: func(_ *cobra.Command, _ []string) error {
-		// Check env var PPLX_API_KEY exists
-		apiKey := os.Getenv("PPLX_API_KEY")
-		if apiKey == "" {
-			return clerrors.NewConfigError("PPLX_API_KEY environment variable is not set", nil)
-		}
-
-		// Create server configuration
-		config := mcp.ServerConfig{
-			APIKey:  apiKey,
-			Version: version,
-			Name:    "Perplexity MCP Server",
-		}
+	Long: `Start an MCP (Model Context Protocol) server that exposes Perplexity query functionality.
 
-		// Create MCP server
-		server, err := mcp.NewServer(config)
+` + mcpToolsHelp,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		server, _, err := newMCPServer(cmd)
 		if err != nil {
-			return clerrors.NewConfigError("Failed to create MCP server", err)
+			return err
 		}
 
-		// Add query tool
-		if err := server.AddQueryTool(); err != nil {
-			return clerrors.NewConfigError("Failed to add query tool", err)
-		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		watchMCPConfig(ctx, server)
 
 		// Start the stdio server
 		if err := server.Start(); err != nil {
@@ -45,3 +70,127 @@ var mcpStdioCmd = &cobra.Command{
 		return nil
 	},
 }
+
+// newMCPServer creates an MCP server with every pplx tool registered, using
+// the API key (PPLX_API_KEY or api.key), the retry and job flags, and the
+// config file for tool argument defaults. The loaded config is returned for
+// transport settings. Shared by mcp-stdio and mcp-http.
+func newMCPServer(cmd *cobra.Command) (*mcp.MCPServer, *config.ConfigData, error) {
+	cfg, err := config.LoadAndMergeConfig(cmd, configFilePath, "")
+	if err != nil {
+		return nil, nil, clerrors.NewConfigError("failed to load configuration", err)
+	}
+
+	apiKey, err := resolveAPIKey(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// api.base_url holds --base-url when it is given.
+	if err := config.ValidateHTTPURL("api.base_url", cfg.API.BaseURL); err != nil {
+		return nil, nil, err //nolint:wrapcheck // names the offending value
+	}
+	proxy, err := config.ParseProxyURL(cfg.API.ProxyURL)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // names the offending value
+	}
+	tlsConf, err := config.LoadTLSConfig(cfg.API.TLS)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // names the offending option
+	}
+
+	// Create server configuration
+	retryPolicy := retry.NewPolicy(globalOpts.MaxRetries, globalOpts.RetryBackoff)
+	serverConfig := mcp.ServerConfig{
+		APIKey:      apiKey,
+		Keys:        cfg.API.Keys,
+		Version:     version,
+		Name:        "Perplexity MCP Server",
+		RetryPolicy: &retryPolicy,
+		Timeouts: retry.Timeouts{
+			Connect:        cfg.API.ConnectTimeout,
+			ResponseHeader: cfg.API.ResponseHeaderTimeout,
+			Proxy:          proxy,
+			TLS:            tlsConf,
+		},
+		Endpoint: config.EndpointURL(cfg.API.BaseURL),
+
+		JobTTL:            mcpJobTTL,
+		MaxConcurrentJobs: mcpMaxJobs,
+		SessionTTL:        mcpSessionTTL,
+		MaxSessions:       mcpMaxSessions,
+		Defaults:          cfg,
+		StrictModel:       globalOpts.StrictModel,
+		Hooks:             requestHooks(cfg),
+		RateLimiter:       ratelimit.New(cfg.API.RequestsPerMinute, cfg.API.Burst),
+
+		DailyBudgetUSD:      cfg.API.DailyBudgetUSD,
+		PerRequestMaxTokens: cfg.API.PerRequestMaxTokens,
+		StreamIdleTimeout:   cfg.API.StreamIdleTimeout,
+	}
+
+	// Create MCP server
+	server, err := mcp.NewServer(serverConfig)
+	if err != nil {
+		return nil, nil, clerrors.NewConfigError("Failed to create MCP server", err)
+	}
+
+	if err := server.RegisterTools(); err != nil {
+		return nil, nil, clerrors.NewConfigError("Failed to register MCP tools", err)
+	}
+
+	// The server runs until stopped: report the startup warnings now
+	// rather than on exit.
+	flushWarnings()
+	return server, cfg, nil
+}
+
+// watchMCPConfig reloads the server's tool defaults whenever the config file
+// changes, until ctx is cancelled. It does nothing when --watch-config is off
+// or no config file exists.
+func watchMCPConfig(ctx context.Context, server *mcp.MCPServer) {
+	if !mcpWatchConfig {
+		return
+	}
+
+	path := configFilePath
+	if path == "" {
+		found, err := config.FindConfigFile()
+		if err != nil {
+			if !errors.Is(err, clerrors.ErrNoConfigFound) {
+				warnings.Add(warnings.CodeConfig, fmt.Sprintf("cannot locate config file to watch: %v", err),
+					"pass --config with the file to watch")
+				flushWarnings()
+			}
+			return
+		}
+		path = found
+	}
+
+	go func() {
+		err := config.NewLoader().Watch(ctx, path, func(cfg *config.ConfigData) {
+			server.SetDefaults(resolveActiveProfile(cfg))
+			flushWarnings()
+		})
+		if err != nil {
+			warnings.Add(warnings.CodeConfig, fmt.Sprintf("config file watching of %s disabled: %v", path, err), "")
+			flushWarnings()
+		}
+	}()
+}
+
+// resolveActiveProfile returns cfg with its active profile applied. If the
+// profile cannot be applied the base config is used, as at startup.
+func resolveActiveProfile(cfg *config.ConfigData) *config.ConfigData {
+	if cfg.ActiveProfile == "" || cfg.ActiveProfile == config.DefaultProfileName {
+		return cfg
+	}
+	merged, err := config.NewProfileManager(cfg).MergeProfile(cfg.ActiveProfile)
+	if err != nil {
+		warnings.Add(warnings.CodeConfig,
+			fmt.Sprintf("failed to apply profile %s, using base config: %v", cfg.ActiveProfile, err),
+			"run pplx config profile list to see the available profiles")
+		return cfg
+	}
+	return merged
+}
@@ -9,8 +9,18 @@ import (
 	clerrors "github.com/sgaunet/pplx/pkg/clerrors"
 )
 
+// useDefaultConfigPath clears configFilePath for a test. Earlier tests may
+// leave it pointing at a removed temp dir.
+func useDefaultConfigPath(t *testing.T) {
+	t.Helper()
+	oldPath := configFilePath
+	configFilePath = ""
+	t.Cleanup(func() { configFilePath = oldPath })
+}
+
 func TestMcpStdioCmd_MissingAPIKey(t *testing.T) {
 	t.Setenv("PPLX_API_KEY", "")
+	useDefaultConfigPath(t)
 
 	err := mcpStdioCmd.RunE(mcpStdioCmd, []string{})
 	if err == nil {
@@ -25,6 +35,7 @@ func TestMcpStdioCmd_MissingAPIKey(t *testing.T) {
 
 func TestMcpStdioCmd_MissingAPIKey_Message(t *testing.T) {
 	t.Setenv("PPLX_API_KEY", "")
+	useDefaultConfigPath(t)
 
 	err := mcpStdioCmd.RunE(mcpStdioCmd, []string{})
 	if err == nil {
@@ -39,6 +50,8 @@ func TestMcpStdioCmd_MissingAPIKey_Message(t *testing.T) {
 func TestMcpStdioCmd_WithAPIKey_ReachesStart(t *testing.T) {
 	t.Setenv("PPLX_API_KEY", "test-api-key-for-mcp")
 
+	useDefaultConfigPath(t)
+
 	// Replace os.Stdin with a pipe whose write end is immediately closed.
 	// This causes ServeStdio to read EOF and return, unblocking the test.
 	origStdin := os.Stdin
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	clerrors "github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Roles of transcript messages.
+const (
+	roleSystem    = "system"
+	roleUser      = "user"
+	roleAssistant = "assistant"
+)
+
+// queryMessagesFile is the --messages-file flag.
+var queryMessagesFile string
+
+// queryMessages is the conversation loaded from --messages-file, without its
+// system message; empty when the flag is not set.
+var queryMessages []perplexity.Message
+
+// addMessagesFileFlags registers --messages-file on the query command.
+func addMessagesFileFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&queryMessagesFile, "messages-file", "",
+		"Send a conversation from a JSON or YAML file of {role, content} messages; --user-prompt is appended as the last turn")
+}
+
+// resolveMessagesFile loads --messages-file into queryMessages. A system
+// message in the file becomes the system prompt. The conversation must
+// alternate user and assistant turns and end with a user turn, counting
+// --user-prompt, which is appended as the final user message.
+func resolveMessagesFile() error {
+	queryMessages = nil
+	if queryMessagesFile == "" {
+		return nil
+	}
+	if len(globalOpts.Files) > 0 {
+		return clerrors.NewValidationError("messages-file", queryMessagesFile, "cannot be combined with --file")
+	}
+
+	maxSize := globalOpts.MaxPromptSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxPromptSize
+	}
+	data, err := readPromptFile("messages-file", queryMessagesFile, maxSize)
+	if err != nil {
+		return err
+	}
+
+	msgs, err := parseMessages(queryMessagesFile, []byte(data))
+	if err != nil {
+		return err
+	}
+	if err := validateTranscript(msgs, globalOpts.UserPrompt != ""); err != nil {
+		return clerrors.NewValidationError("messages-file", queryMessagesFile, err.Error())
+	}
+
+	if msgs[0].Role == roleSystem {
+		if globalOpts.SystemPrompt != "" {
+			return clerrors.NewValidationError("messages-file", queryMessagesFile,
+				"messages[0]: the file sets a system message, remove it or --sys-prompt")
+		}
+		globalOpts.SystemPrompt = msgs[0].Content
+		msgs = msgs[1:]
+	}
+	queryMessages = msgs
+	return nil
+}
+
+// parseMessages decodes a JSON (.json) or YAML array of messages.
+func parseMessages(path string, data []byte) ([]perplexity.Message, error) {
+	var msgs []perplexity.Message
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &msgs)
+	} else {
+		err = yaml.Unmarshal(data, &msgs)
+	}
+	if err != nil {
+		return nil, clerrors.NewValidationError("messages-file", path,
+			"must be an array of {role, content} messages: "+err.Error())
+	}
+	if len(msgs) == 0 {
+		return nil, clerrors.NewValidationError("messages-file", path, "contains no messages")
+	}
+	return msgs, nil
+}
+
+// validateTranscript checks the roles of msgs: an optional system message
+// first, then user and assistant messages in turn, starting with a user
+// message. The last message must be from the user unless userPromptFollows,
+// in which case it must be from the assistant. Errors name the index of the
+// offending message.
+func validateTranscript(msgs []perplexity.Message, userPromptFollows bool) error {
+	want := roleUser
+	for i, msg := range msgs {
+		switch {
+		case msg.Role == roleSystem && i == 0:
+			continue
+		case msg.Role == roleSystem:
+			return fmt.Errorf("messages[%d]: a system message is only allowed as the first message", i)
+		case msg.Role != roleUser && msg.Role != roleAssistant:
+			return fmt.Errorf("messages[%d]: unknown role %q, use system, user, or assistant", i, msg.Role)
+		case msg.Role != want:
+			return fmt.Errorf("messages[%d]: expected role %q, roles must alternate user/assistant starting with user",
+				i, want)
+		case strings.TrimSpace(msg.Content) == "":
+			return fmt.Errorf("messages[%d]: content is empty", i)
+		}
+		if want == roleUser {
+			want = roleAssistant
+		} else {
+			want = roleUser
+		}
+	}
+
+	last := len(msgs) - 1
+	switch {
+	case msgs[last].Role == roleSystem && userPromptFollows:
+		return nil
+	case msgs[last].Role == roleSystem:
+		return fmt.Errorf("messages[%d]: the conversation has no user message", last)
+	case userPromptFollows && msgs[last].Role != roleAssistant:
+		return fmt.Errorf("messages[%d]: must be an assistant message, --user-prompt is appended as the next user turn",
+			last)
+	case !userPromptFollows && msgs[last].Role != roleUser:
+		return fmt.Errorf("messages[%d]: the conversation ends with an assistant message, "+
+			"end it with a user message or pass --user-prompt", last)
+	}
+	return nil
+}
+
+// addTranscript adds queryMessages to msg.
+func addTranscript(msg *perplexity.Messages) error {
+	for i, m := range queryMessages {
+		var err error
+		if m.Role == roleUser {
+			err = msg.AddUserMessage(m.Content)
+		} else {
+			err = msg.AddAgentMessage(m.Content)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add message %d of --messages-file: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+// setMessagesFile sets --messages-file for one test and restores the flag,
+// the loaded messages, and globalOpts afterwards.
+func setMessagesFile(t *testing.T, name string) {
+	t.Helper()
+	oldFile, oldOpts := queryMessagesFile, *globalOpts
+	queryMessagesFile = ""
+	if name != "" {
+		queryMessagesFile = filepath.Join("testdata", "messages", name)
+	}
+	t.Cleanup(func() {
+		queryMessagesFile, queryMessages = oldFile, nil
+		*globalOpts = oldOpts
+	})
+}
+
+func TestResolveMessagesFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		userPrompt string
+		sysPrompt  string
+		wantRoles  []string
+		wantErr    string
+	}{
+		{
+			name:      "few-shot transcript ending with user",
+			file:      "few_shot.yaml",
+			wantRoles: []string{"system", "user", "assistant", "user"},
+		},
+		{
+			name:       "user prompt appended after assistant",
+			file:       "ends_with_assistant.json",
+			userPrompt: "Translate to French: good night",
+			wantRoles:  []string{"user", "assistant", "user"},
+		},
+		{
+			name:    "ends with assistant without user prompt",
+			file:    "ends_with_assistant.json",
+			wantErr: "messages[1]: the conversation ends with an assistant message",
+		},
+		{
+			name:       "user prompt after user message",
+			file:       "few_shot.yaml",
+			userPrompt: "Classify: it works",
+			wantErr:    "messages[3]: must be an assistant message",
+		},
+		{
+			name:    "empty file",
+			file:    "empty.yaml",
+			wantErr: "contains no messages",
+		},
+		{
+			name:    "roles out of order",
+			file:    "out_of_order.yaml",
+			wantErr: `messages[1]: expected role "assistant"`,
+		},
+		{
+			name:    "system message not first",
+			file:    "system_not_first.json",
+			wantErr: "messages[1]: a system message is only allowed as the first message",
+		},
+		{
+			name:      "system message conflicts with sys-prompt",
+			file:      "few_shot.yaml",
+			sysPrompt: "be verbose",
+			wantErr:   "remove it or --sys-prompt",
+		},
+		{
+			name:    "missing file",
+			file:    "missing.yaml",
+			wantErr: "file not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setMessagesFile(t, tt.file)
+			globalOpts.UserPrompt = tt.userPrompt
+			globalOpts.SystemPrompt = tt.sysPrompt
+			globalOpts.Files = nil
+
+			err := resolveMessagesFile()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveMessagesFile() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveMessagesFile() error: %v", err)
+			}
+
+			msg := perplexity.NewMessages(perplexity.WithSystemMessage(globalOpts.SystemPrompt))
+			if err := addTranscript(&msg); err != nil {
+				t.Fatal(err)
+			}
+			if tt.userPrompt != "" {
+				if err := addUserMessage(&msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var roles []string
+			for _, m := range msg.GetMessages() {
+				roles = append(roles, m.Role)
+			}
+			if strings.Join(roles, ",") != strings.Join(tt.wantRoles, ",") {
+				t.Errorf("roles = %v, want %v", roles, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestBuildBaseOptions_MessagesFile(t *testing.T) {
+	setMessagesFile(t, "few_shot.yaml")
+	globalOpts.UserPrompt, globalOpts.SystemPrompt, globalOpts.Files = "", "", nil
+	if err := resolveMessagesFile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateInputs(); err != nil {
+		t.Fatalf("validateInputs() rejected a transcript without --user-prompt: %v", err)
+	}
+
+	req, err := buildAllOptions()
+	if err != nil {
+		t.Fatalf("buildAllOptions() error: %v", err)
+	}
+	if len(req.Messages) != 4 {
+		t.Fatalf("got %d messages, want 4", len(req.Messages))
+	}
+	if got := req.Messages[3].Content; got != "Classify: the docs are outdated" {
+		t.Errorf("last message = %q", got)
+	}
+}
+
+func TestResolveMessagesFile_RejectsAttachments(t *testing.T) {
+	setMessagesFile(t, "few_shot.yaml")
+	globalOpts.Files = []string{"image.png"}
+	if err := resolveMessagesFile(); err == nil || !strings.Contains(err.Error(), "--file") {
+		t.Errorf("resolveMessagesFile() error = %v, want --file conflict", err)
+	}
+}
@@ -0,0 +1,436 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/completion"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// modelsTabPadding is the column padding of the models table.
+	modelsTabPadding = 2
+	// modelsContextUnit is the unit of the CONTEXT column (thousands of tokens).
+	modelsContextUnit = 1000
+)
+
+// Output formats of the models commands.
+const (
+	modelsFormatTable = "table"
+	modelsFormatJSON  = "json"
+)
+
+const (
+	// defaultProbeConcurrency is how many models models check probes at once.
+	defaultProbeConcurrency = 4
+	// defaultProbeTimeout bounds each probe request.
+	defaultProbeTimeout = 30 * time.Second
+	// probePrompt is the question of a probe request.
+	probePrompt = "ping"
+)
+
+// Outcomes of a model probe.
+const (
+	// probeAccessible: the model answered.
+	probeAccessible = "accessible"
+	// probeForbidden: the API rejected the key for this model (401 or 403).
+	probeForbidden = "forbidden"
+	// probeAPIError: the API answered with another error, such as an unknown
+	// model.
+	probeAPIError = "api_error"
+	// probeTimeout: the model did not answer within --timeout.
+	probeTimeout = "timeout"
+	// probeNetworkError: the API could not be reached.
+	probeNetworkError = "network_error"
+)
+
+// Flags of the models commands.
+var (
+	modelsJSON       bool
+	modelsFormat     string
+	probeModelNames  []string
+	probeConcurrency int
+	probeTimeoutFlag time.Duration
+)
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List supported models and check which ones the API key can use",
+	Long: `List the Perplexity models pplx knows about, or check which of them the
+configured API key can use.
+
+Without a subcommand, models runs models list.
+
+Examples:
+  pplx models list
+  pplx models list --format json
+  pplx models check
+  pplx models check --model sonar --model sonar-pro`,
+	Args: cobra.NoArgs,
+	RunE: runModelsList,
+}
+
+var modelsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List supported models and their capabilities",
+	Long: `List the Perplexity models pplx knows about: context window, whether
+streaming, response_format, and reasoning_effort are supported, and the
+relative cost tier.
+
+The MCP server serves the same table as the pplx://models resource.
+
+Examples:
+  pplx models list
+  pplx models list --format json`,
+	Args: cobra.NoArgs,
+	RunE: runModelsList,
+}
+
+var modelsCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check which models the API key can use",
+	Long: `Send a one-token request to each model, or to the --model subset, with
+the configured API key, and report for each one:
+
+  accessible     the model answered
+  forbidden      the API rejected the key for the model (401 or 403)
+  api_error      the API answered with another error, such as an unknown model
+  timeout        no answer within --timeout
+  network_error  the API could not be reached
+
+and the latency. At most --concurrency models are probed at once, without
+retries. Each probe is billed like any other request. The command exits
+non-zero when a model is not accessible.
+
+Examples:
+  pplx models check
+  pplx models check -m sonar -m sonar-deep-research --timeout 2m
+  pplx models check --format json`,
+	Args: cobra.NoArgs,
+	RunE: runModelsCheck,
+}
+
+// modelsOutputFormat returns --format, or json for the deprecated --json, and
+// rejects unknown formats.
+func modelsOutputFormat() (string, error) {
+	if modelsJSON {
+		return modelsFormatJSON, nil
+	}
+	if modelsFormat != modelsFormatTable && modelsFormat != modelsFormatJSON {
+		return "", clerrors.NewValidationError("format", modelsFormat, "must be table or json")
+	}
+	return modelsFormat, nil
+}
+
+func runModelsList(cmd *cobra.Command, _ []string) error {
+	format, err := modelsOutputFormat()
+	if err != nil {
+		return err
+	}
+	if format == modelsFormatJSON {
+		data, err := json.MarshalIndent(models.Catalog{Models: models.All()}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal models: %w", err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(data))
+		return nil
+	}
+	return writeModelsTable(cmd.OutOrStdout(), models.All())
+}
+
+// writeModelsTable prints models as aligned columns.
+func writeModelsTable(out io.Writer, list []models.Info) error {
+	w := tabwriter.NewWriter(out, 0, 0, modelsTabPadding, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tCONTEXT\tMAX OUTPUT\tSTREAMING\tRESPONSE FORMAT\tREASONING EFFORT\tCOST\tDESCRIPTION")
+	for _, m := range list {
+		fmt.Fprintf(w, "%s\t%dk\t%dk\t%s\t%s\t%s\t%s\t%s\n", m.Name, m.ContextWindow/modelsContextUnit,
+			m.MaxOutputTokens/modelsContextUnit,
+			yesNo(m.SupportsStreaming), yesNo(m.SupportsResponseFormat), yesNo(m.SupportsReasoningEffort),
+			m.CostTier, m.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write models table: %w", err)
+	}
+	return nil
+}
+
+// yesNo renders a capability flag for the models table.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// modelProbe is the result of probing one model.
+type modelProbe struct {
+	Model  string `json:"model"`
+	Status string `json:"status"`
+	// HTTPStatus is the status of the API response; 0 when none was received.
+	HTTPStatus int    `json:"http_status,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+func runModelsCheck(cmd *cobra.Command, _ []string) error {
+	format, err := modelsOutputFormat()
+	if err != nil {
+		return err
+	}
+	if probeConcurrency < 1 {
+		return clerrors.NewValidationError("concurrency", fmt.Sprint(probeConcurrency), "must be at least 1")
+	}
+	if probeTimeoutFlag <= 0 {
+		return clerrors.NewValidationError("timeout", probeTimeoutFlag.String(), "must be positive")
+	}
+
+	cfg, err := loadRunConfig(cmd)
+	if err != nil {
+		return err
+	}
+	apiKey, err := resolveAPIKey(cfg)
+	if err != nil {
+		return err
+	}
+	client, err := newProbeClient(cfg, apiKey, probeTimeoutFlag)
+	if err != nil {
+		return err
+	}
+
+	names := models.IDs()
+	if len(probeModelNames) > 0 {
+		names = make([]string, len(probeModelNames))
+		for i, name := range probeModelNames {
+			names[i] = models.Resolve(name, cfg.Models.Aliases)
+		}
+	}
+
+	// Inaccessible models are the report, not a usage mistake.
+	cmd.SilenceUsage = true
+	parent := cmd.Context()
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(parent, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	probes := probeModels(ctx, client, names, probeConcurrency, probeTimeoutFlag)
+
+	if format == modelsFormatJSON {
+		data, err := json.MarshalIndent(probes, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal model checks: %w", err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	} else if err := writeProbesTable(cmd.OutOrStdout(), probes); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, p := range probes {
+		if p.Status != probeAccessible {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d", clerrors.ErrModelsInaccessible, failed, len(probes))
+	}
+	return nil
+}
+
+// newProbeClient returns a client for apiKey set up from cfg without
+// retries, whose requests record their HTTP status (see probeStatusKey) and
+// are bounded by timeout.
+func newProbeClient(cfg *config.ConfigData, apiKey string, timeout time.Duration) (*perplexity.Client, error) {
+	if err := config.ValidateHTTPURL("api.base_url", cfg.API.BaseURL); err != nil {
+		return nil, err //nolint:wrapcheck // names the offending value
+	}
+	proxy, err := config.ParseProxyURL(cfg.API.ProxyURL)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // names the offending value
+	}
+	tlsConf, err := config.LoadTLSConfig(cfg.API.TLS)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // names the offending option
+	}
+
+	client := perplexity.NewClient(apiKey)
+	if endpoint := config.EndpointURL(cfg.API.BaseURL); endpoint != "" {
+		client.SetEndpoint(endpoint)
+	}
+	timeouts := retry.Timeouts{
+		Connect:        cfg.API.ConnectTimeout,
+		ResponseHeader: cfg.API.ResponseHeaderTimeout,
+		Total:          timeout,
+		Proxy:          proxy,
+		TLS:            tlsConf,
+	}
+	client.SetHTTPClient(&http.Client{
+		Timeout:   timeout,
+		Transport: statusRecorder{base: logger.NewHTTPTransport(timeouts.Transport())},
+	})
+	return client, nil
+}
+
+// probeStatusKey is the context key of the *int a probe request stores its
+// HTTP status in. The client drops the status of most error responses, and
+// it is what tells a forbidden model from an unreachable API.
+type probeStatusKey struct{}
+
+// statusRecorder is an http.RoundTripper storing the response status in the
+// *int found under probeStatusKey in the request context.
+type statusRecorder struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r statusRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if status, ok := req.Context().Value(probeStatusKey{}).(*int); ok && err == nil {
+		*status = resp.StatusCode
+	}
+	return resp, err //nolint:wrapcheck // transport errors are wrapped by http.Client
+}
+
+// probeModels probes names with client, at most concurrency at a time, each
+// bounded by timeout. Results are in the order of names. Models not probed
+// yet when ctx is done are reported as network errors.
+func probeModels(ctx context.Context, client *perplexity.Client, names []string,
+	concurrency int, timeout time.Duration,
+) []modelProbe {
+	probes := make([]modelProbe, len(names))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				probes[i] = modelProbe{Model: name, Status: probeNetworkError, Error: ctx.Err().Error()}
+				return
+			}
+			probes[i] = probeModel(ctx, client, name, timeout)
+		}()
+	}
+	wg.Wait()
+	return probes
+}
+
+// probeModel sends a one-token request for model and classifies the outcome.
+func probeModel(ctx context.Context, client *perplexity.Client, model string, timeout time.Duration) modelProbe {
+	var status int
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, probeStatusKey{}, &status), timeout)
+	defer cancel()
+
+	msg := perplexity.NewMessages()
+	if err := msg.AddUserMessage(probePrompt); err != nil {
+		return modelProbe{Model: model, Status: probeAPIError, Error: err.Error()}
+	}
+	req := perplexity.NewCompletionRequest(
+		perplexity.WithMessages(msg.GetMessages()),
+		perplexity.WithModel(model),
+		perplexity.WithMaxTokens(1),
+	)
+
+	start := time.Now()
+	_, err := client.SendCompletionRequestWithContext(ctx, req)
+	probe := modelProbe{
+		Model:      model,
+		HTTPStatus: status,
+		LatencyMS:  time.Since(start).Milliseconds(),
+		Status:     classifyProbe(err, status),
+	}
+	if err != nil {
+		probe.Error = err.Error()
+	}
+	return probe
+}
+
+// classifyProbe maps the error and HTTP status of a probe request to its
+// outcome.
+func classifyProbe(err error, status int) string {
+	var timeoutErr *retry.TimeoutError
+	switch {
+	case err == nil:
+		return probeAccessible
+	case status == http.StatusUnauthorized, status == http.StatusForbidden,
+		errors.Is(err, perplexity.ErrUnauthorized):
+		return probeForbidden
+	case errors.As(retry.ClassifyTimeout(err), &timeoutErr):
+		return probeTimeout
+	case status != 0:
+		return probeAPIError
+	}
+	return probeNetworkError
+}
+
+// writeProbesTable prints the results of models check as aligned columns.
+func writeProbesTable(out io.Writer, probes []modelProbe) error {
+	w := tabwriter.NewWriter(out, 0, 0, modelsTabPadding, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tSTATUS\tLATENCY\tDETAIL")
+	for _, p := range probes {
+		detail := p.Error
+		if p.HTTPStatus != 0 && p.Status != probeAccessible {
+			detail = fmt.Sprintf("HTTP %d %s", p.HTTPStatus, detail)
+		}
+		latency := time.Duration(p.LatencyMS) * time.Millisecond
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Model, p.Status, latency, detail)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write model checks: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(modelsCmd)
+	modelsCmd.AddCommand(modelsListCmd)
+	modelsCmd.AddCommand(modelsCheckCmd)
+
+	modelsCmd.PersistentFlags().StringVar(&modelsFormat, "format", modelsFormatTable, "Output format: table or json")
+	modelsCmd.PersistentFlags().BoolVar(&modelsJSON, "json", false, "Output as JSON")
+	if err := modelsCmd.PersistentFlags().MarkDeprecated("json", "use --format json"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to deprecate 'json' flag: %v\n", err)
+	}
+
+	modelsCheckCmd.Flags().StringSliceVarP(&probeModelNames, "model", "m", nil,
+		"Model or alias to check (repeatable; default: every known model)")
+	modelsCheckCmd.Flags().IntVar(&probeConcurrency, "concurrency", defaultProbeConcurrency,
+		"Maximum number of models probed at once")
+	modelsCheckCmd.Flags().DurationVar(&probeTimeoutFlag, "timeout", defaultProbeTimeout,
+		"Time limit of each probe request")
+	modelsCheckCmd.Flags().StringVar(&configFilePath, "config", "", "Path to config file")
+	modelsCheckCmd.Flags().StringVar(&runtimeProfile, "profile", "", "Use a named configuration profile")
+
+	if err := modelsCmd.RegisterFlagCompletionFunc("format",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return []string{modelsFormatTable, modelsFormatJSON}, cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'format' flag: %v\n", err)
+	}
+	if err := modelsCheckCmd.RegisterFlagCompletionFunc("model",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return completion.ModelNames(), cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'model' flag: %v\n", err)
+	}
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/models"
+)
+
+func TestWriteModelsTable(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeModelsTable(&buf, models.All()); err != nil {
+		t.Fatalf("writeModelsTable() error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(models.All())+1 {
+		t.Fatalf("got %d lines, want header plus %d models:\n%s", len(lines), len(models.All()), buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "MODEL") {
+		t.Errorf("header = %q", lines[0])
+	}
+
+	var deep string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "sonar-deep-research ") {
+			deep = line
+		}
+	}
+	for _, want := range []string{"128k", "yes  ", "high"} {
+		if !strings.Contains(deep, want) {
+			t.Errorf("sonar-deep-research row %q missing %q", deep, want)
+		}
+	}
+}
+
+func TestProbeModels(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		var body struct {
+			Model     string `json:"model"`
+			MaxTokens int    `json:"max_tokens"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.MaxTokens != 1 {
+			t.Errorf("max_tokens = %d, want 1", body.MaxTokens)
+		}
+		switch body.Model {
+		case "forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("<html>Forbidden</html>"))
+		case "unauthorized":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "unknown":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"message": "Invalid model", "code": 400}}`))
+		case "hung":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		default:
+			time.Sleep(20 * time.Millisecond)
+			_, _ = w.Write([]byte(mockCompletionResponseJSON()))
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.NewConfigData()
+	cfg.API.BaseURL = srv.URL
+	client, err := newProbeClient(cfg, "test-key", 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"sonar", "forbidden", "unauthorized", "unknown", "hung", "sonar-pro", "sonar-reasoning"}
+	probes := probeModels(context.Background(), client, names, 2, 200*time.Millisecond)
+
+	want := []string{probeAccessible, probeForbidden, probeForbidden, probeAPIError, probeTimeout,
+		probeAccessible, probeAccessible}
+	for i, p := range probes {
+		if p.Model != names[i] || p.Status != want[i] {
+			t.Errorf("probe %d = %+v, want %s %s", i, p, names[i], want[i])
+		}
+	}
+	if probes[1].HTTPStatus != http.StatusForbidden {
+		t.Errorf("forbidden probe HTTP status = %d, want 403", probes[1].HTTPStatus)
+	}
+	if maxInFlight > 2 {
+		t.Errorf("%d probes in flight, want at most 2", maxInFlight)
+	}
+
+	srv.Close()
+	if p := probeModels(context.Background(), client, []string{"sonar"}, 1, time.Second)[0]; p.Status != probeNetworkError {
+		t.Errorf("unreachable API = %+v, want %s", p, probeNetworkError)
+	}
+}
+
+func TestWriteProbesTable(t *testing.T) {
+	var buf bytes.Buffer
+	probes := []modelProbe{
+		{Model: "sonar", Status: probeAccessible, LatencyMS: 420},
+		{Model: "sonar-pro", Status: probeForbidden, HTTPStatus: http.StatusForbidden,
+			LatencyMS: 80, Error: "failed to unmarshal error response"},
+	}
+	if err := writeProbesTable(&buf, probes); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"MODEL", "sonar  ", "accessible", "420ms", "forbidden", "HTTP 403"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// presetTabPadding is the column padding of the presets table.
+const presetTabPadding = 2
+
+// queryPreset is the --preset flag of query.
+var queryPreset string
+
+// presetExcludedFlags are the query flags a preset cannot record: the
+// prompt of a single query, and the flags choosing the config, preset and
+// prompt from the history.
+var presetExcludedFlags = []string{"user-prompt", "user-prompt-file", "config", "preset", "last"}
+
+// presetCmd groups the preset commands.
+var presetCmd = &cobra.Command{
+	Use:   "preset",
+	Short: "Manage query presets, named sets of query flags",
+	Long: `A preset is a named set of query flags, saved in presets.yaml next to the
+config file and replayed with pplx query --preset. Unlike a profile it changes
+nothing in the config and applies only when asked for.
+
+A preset overrides the config file, the profile and PPLX_* environment
+variables; flags given on the command line override the preset.
+
+Examples:
+  pplx preset save papers -m sonar-pro --search-mode academic -r month --citations footnote
+  pplx query --preset papers -p "Advances in solid-state batteries"
+  pplx query --preset papers -m sonar -p "Same flags, another model"`,
+}
+
+// presetSaveCmd records the query flags given to it as a preset.
+var presetSaveCmd = &cobra.Command{
+	Use:   "save <name> [query flags]",
+	Short: "Save the query flags given on the command line as a preset",
+	Long: `Save the query flags given after the name as a preset, replacing a preset
+of the same name. Every flag of pplx query can be recorded, except the user
+prompt and --config.
+
+Example:
+  pplx preset save papers -m sonar-pro --search-mode academic -r month --citations footnote`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return savePreset(cmd.OutOrStdout(), config.PresetsPath(), args[0], cmd.LocalFlags())
+	},
+}
+
+// presetListCmd lists the presets.
+var presetListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List query presets and their flags",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return listPresets(cmd.OutOrStdout(), config.PresetsPath())
+	},
+}
+
+// presetShowCmd prints the flags of a preset.
+var presetShowCmd = &cobra.Command{
+	Use:               "show <name>",
+	Short:             "Show the flags of a query preset",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePresetArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		preset, err := config.FindPreset(config.PresetsPath(), args[0])
+		if err != nil {
+			return err //nolint:wrapcheck // names the preset
+		}
+		for _, arg := range preset.Args() {
+			fmt.Fprintln(cmd.OutOrStdout(), arg)
+		}
+		return nil
+	},
+}
+
+// presetDeleteCmd deletes a preset.
+var presetDeleteCmd = &cobra.Command{
+	Use:               "delete <name>",
+	Short:             "Delete a query preset",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePresetArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.DeletePreset(config.PresetsPath(), args[0]); err != nil {
+			return err //nolint:wrapcheck // names the preset or file
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Deleted preset %q.\n", args[0])
+		return nil
+	},
+}
+
+// savePreset stores the flags explicitly set in flags as the preset name of
+// the presets file at path.
+func savePreset(out io.Writer, path, name string, flags *pflag.FlagSet) error {
+	preset := config.RecordPreset(flags)
+	if len(preset) == 0 {
+		return clerrors.NewValidationError("preset", name,
+			"no query flags given; add the flags to record, e.g. pplx preset save "+name+" -m sonar-pro")
+	}
+	replaced, err := config.SavePreset(path, name, preset)
+	if err != nil {
+		return err //nolint:wrapcheck // names the preset or file
+	}
+
+	verb := "Saved"
+	if replaced {
+		verb = "Updated"
+	}
+	fmt.Fprintf(out, "%s preset %q in %s:\n", verb, name, path)
+	for _, arg := range preset.Args() {
+		fmt.Fprintf(out, "  %s\n", arg)
+	}
+	return nil
+}
+
+// listPresets prints every preset of the presets file at path with its flags.
+func listPresets(out io.Writer, path string) error {
+	presets, err := config.LoadPresets(path)
+	if err != nil {
+		return err //nolint:wrapcheck // names the file
+	}
+	if len(presets) == 0 {
+		_, err := fmt.Fprintln(out, "No presets saved. Create one with: pplx preset save <name> [query flags]")
+		return err //nolint:wrapcheck // stdout write
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, presetTabPadding, ' ', 0)
+	fmt.Fprintln(w, "NAME\tFLAGS")
+	for _, name := range presetNames(presets) {
+		fmt.Fprintf(w, "%s\t%s\n", name, strings.Join(presets[name].Args(), " "))
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write presets table: %w", err)
+	}
+	return nil
+}
+
+// presetNames returns the names of presets, sorted.
+func presetNames(presets map[string]config.Preset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// applyQueryPreset sets the flags of the --preset preset that the command
+// line does not set. It runs before the flag groups are checked and the
+// config is loaded, so a preset flag counts as given on the command line,
+// reported with the source "preset".
+func applyQueryPreset(cmd *cobra.Command) error {
+	if queryPreset == "" {
+		return nil
+	}
+	preset, err := config.FindPreset(config.PresetsPath(), queryPreset)
+	if err != nil {
+		return err //nolint:wrapcheck // names the preset
+	}
+	applied, err := config.ApplyPreset(cmd.Flags(), queryPreset, preset)
+	if err != nil {
+		return err //nolint:wrapcheck // names the flag
+	}
+	logger.Debug("applied preset", "preset", queryPreset, "flags", applied)
+	return nil
+}
+
+// preRunQuery applies the prompt of --last and the --preset preset, then
+// checks the flag combinations of query.
+func preRunQuery(cmd *cobra.Command, args []string) error {
+	if err := applyLastPrompt(cmd, args); err != nil {
+		return err
+	}
+	if err := applyQueryPreset(cmd); err != nil {
+		return err
+	}
+	return validateFlagCombinations(cmd, args)
+}
+
+// completePresetNames completes with the names of the saved presets.
+func completePresetNames(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	presets, err := config.LoadPresets(config.PresetsPath())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return presetNames(presets), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePresetArg completes the preset name argument of show and delete.
+func completePresetArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completePresetNames(cmd, args, toComplete)
+}
+
+// addPresetFlag registers --preset on cmd.
+func addPresetFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&queryPreset, "preset", "",
+		"Apply the flags of a preset saved with pplx preset save; flags given here override it")
+	if err := cmd.RegisterFlagCompletionFunc("preset", completePresetNames); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'preset' flag: %v\n", err)
+	}
+}
+
+// registerPresetCommands adds the preset commands. preset save accepts the
+// flags of query, which must be registered first: the flags are shared, so
+// their completions and mutually exclusive groups apply to both commands.
+func registerPresetCommands(query *cobra.Command) {
+	rootCmd.AddCommand(presetCmd)
+	presetCmd.AddCommand(presetSaveCmd, presetListCmd, presetShowCmd, presetDeleteCmd)
+	presetSaveCmd.SetGlobalNormalizationFunc(normalizeFlagAliases)
+	query.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if !slices.Contains(presetExcludedFlags, f.Name) {
+			presetSaveCmd.Flags().AddFlag(f)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/spf13/pflag"
+)
+
+// withPresetsDir points the user config directory, and so the presets file,
+// at a temporary directory, and returns the presets file.
+func withPresetsDir(t *testing.T) string {
+	t.Helper()
+	saved := config.ConfigPaths
+	t.Cleanup(func() { config.ConfigPaths = saved })
+	dir := t.TempDir()
+	config.ConfigPaths = []string{dir}
+	return filepath.Join(dir, config.PresetsFileName)
+}
+
+func TestSavePreset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), config.PresetsFileName)
+	flags := pflag.NewFlagSet("query", pflag.ContinueOnError)
+	flags.StringP("model", "m", "", "")
+	flags.StringSlice("search-domains", nil, "")
+	if err := flags.Parse([]string{"-m", "sonar-pro", "--search-domains", "arxiv.org,nature.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := savePreset(&out, path, "papers", flags); err != nil {
+		t.Fatalf("savePreset() error = %v", err)
+	}
+	want := "Saved preset \"papers\" in " + path + ":\n" +
+		"  --model=sonar-pro\n  --search-domains=arxiv.org\n  --search-domains=nature.com\n"
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := savePreset(&out, path, "papers", flags); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "Updated preset") {
+		t.Errorf("output = %q, want the preset updated", out.String())
+	}
+
+	empty := pflag.NewFlagSet("query", pflag.ContinueOnError)
+	err := savePreset(&out, path, "none", empty)
+	if err == nil || getExitCode(err) != 2 {
+		t.Errorf("savePreset() without flags = %v, want a validation error", err)
+	}
+}
+
+func TestListPresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), config.PresetsFileName)
+
+	var out bytes.Buffer
+	if err := listPresets(&out, path); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "No presets saved.") {
+		t.Errorf("output = %q", out.String())
+	}
+
+	for name, preset := range map[string]config.Preset{
+		"papers": {"model": {"sonar-pro"}, "search-mode": {"academic"}},
+		"news":   {"search-recency": {"day"}},
+	} {
+		if _, err := config.SavePreset(path, name, preset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.Reset()
+	if err := listPresets(&out, path); err != nil {
+		t.Fatal(err)
+	}
+	want := "NAME    FLAGS\n" +
+		"news    --search-recency=day\n" +
+		"papers  --model=sonar-pro --search-mode=academic\n"
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestPreRunQuery_Preset(t *testing.T) {
+	path := withPresetsDir(t)
+	preset := config.Preset{"model": {"sonar-pro"}, "sys-prompt-file": {"papers.txt"}}
+	if _, err := config.SavePreset(path, "papers", preset); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		args           []string
+		wantErr        bool
+		wantModel      string
+		wantPrompt     string
+		wantPromptFile string
+	}{
+		{"preset applied", []string{"--preset", "papers", "-p", "hi"}, false, "sonar-pro", "", "papers.txt"},
+		{"flag overrides preset", []string{"--preset", "papers", "-m", "sonar", "-p", "hi"}, false,
+			"sonar", "", "papers.txt"},
+		{"flag excludes preset flag", []string{"--preset", "papers", "-s", "Be brief", "-p", "hi"}, false,
+			"sonar-pro", "Be brief", ""},
+		{"unknown preset", []string{"--preset", "news", "-p", "hi"}, true, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newFlagGroupsCommand(t, true)
+			savedPreset := queryPreset
+			t.Cleanup(func() { queryPreset = savedPreset })
+			addPresetFlag(cmd)
+			cmd.PreRunE = preRunQuery
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "available: papers") {
+					t.Errorf("Execute() error = %v, want the available presets", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if globalOpts.Model != tt.wantModel || globalOpts.SystemPrompt != tt.wantPrompt ||
+				globalOpts.SystemPromptFile != tt.wantPromptFile {
+				t.Errorf("model, sys-prompt, system-prompt-file = %q, %q, %q; want %q, %q, %q",
+					globalOpts.Model, globalOpts.SystemPrompt, globalOpts.SystemPromptFile,
+					tt.wantModel, tt.wantPrompt, tt.wantPromptFile)
+			}
+		})
+	}
+}
+
+func TestRegisterPresetCommands_SaveFlags(t *testing.T) {
+	for _, name := range []string{"model", "search-domains", "system-prompt-file", "sys-prompt-file", "citations"} {
+		if presetSaveCmd.Flags().Lookup(name) == nil {
+			t.Errorf("preset save is missing --%s", name)
+		}
+	}
+	for _, name := range presetExcludedFlags {
+		if presetSaveCmd.Flags().Lookup(name) != nil {
+			t.Errorf("preset save should not record --%s", name)
+		}
+	}
+}
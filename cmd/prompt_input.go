@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	clerrors "github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+const (
+	// defaultMaxPromptSize is the default --max-prompt-size (256 KiB).
+	defaultMaxPromptSize = 256 * 1024
+
+	// stdinPrompt is the --user-prompt value that reads the prompt from stdin.
+	stdinPrompt = "-"
+
+	// promptContextSeparator separates a positional instruction from the piped
+	// or file input it applies to.
+	promptContextSeparator = "\n\n--- Input ---\n\n"
+)
+
+// applyDefaultSystemPrompt uses defaults.system_prompt when no system
+// prompt was given with a flag, a prompt template, or a messages file.
+func applyDefaultSystemPrompt(cfg *config.ConfigData) {
+	if globalOpts.SystemPrompt == "" && cfg != nil {
+		globalOpts.SystemPrompt = cfg.Defaults.SystemPrompt
+	}
+}
+
+// resolvePrompts fills globalOpts.UserPrompt and globalOpts.SystemPrompt from
+// the prompt flags, positional arguments, and stdin.
+//
+// The user prompt comes from --user-prompt, --user-prompt-file, or stdin when
+// --user-prompt is "-". Positional arguments form an instruction: on their own
+// they are the prompt, and combined with stdin or file input they are placed
+// first with the input appended as context.
+func resolvePrompts(args []string, stdin io.Reader) error {
+	maxSize := globalOpts.MaxPromptSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxPromptSize
+	}
+
+	if globalOpts.SystemPromptFile != "" {
+		text, err := readPromptFile("system-prompt-file", globalOpts.SystemPromptFile, maxSize)
+		if err != nil {
+			return err
+		}
+		globalOpts.SystemPrompt = text
+	}
+
+	instruction := strings.TrimSpace(strings.Join(args, " "))
+
+	var input string
+	switch {
+	case globalOpts.UserPromptFile != "":
+		text, err := readPromptFile("user-prompt-file", globalOpts.UserPromptFile, maxSize)
+		if err != nil {
+			return err
+		}
+		input = text
+	case globalOpts.UserPrompt == stdinPrompt:
+		text, err := readLimited("user-prompt", "stdin", stdin, maxSize)
+		if err != nil {
+			return err
+		}
+		input = text
+	default:
+		if instruction != "" && globalOpts.UserPrompt != "" {
+			return clerrors.NewValidationError("user-prompt", globalOpts.UserPrompt,
+				"use either --user-prompt or positional arguments, not both")
+		}
+		if instruction != "" {
+			globalOpts.UserPrompt = instruction
+		}
+		return nil
+	}
+
+	globalOpts.UserPrompt = combinePrompt(instruction, input)
+	if len(globalOpts.UserPrompt) > maxSize {
+		return promptTooLargeError("user-prompt", maxSize)
+	}
+	return nil
+}
+
+// combinePrompt joins an instruction and its input, omitting the separator
+// when either is empty.
+func combinePrompt(instruction, input string) string {
+	input = strings.TrimRight(input, "\n")
+	switch {
+	case instruction == "":
+		return input
+	case strings.TrimSpace(input) == "":
+		return instruction
+	default:
+		return instruction + promptContextSeparator + input
+	}
+}
+
+// readPromptFile reads a prompt from path, rejecting files larger than maxSize.
+func readPromptFile(field, path string, maxSize int) (string, error) {
+	f, err := os.Open(path) //nolint:gosec // path is supplied by the user on purpose
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", clerrors.NewValidationError(field, path, "file not found")
+		}
+		return "", clerrors.NewIOError("cannot read "+path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return readLimited(field, path, f, maxSize)
+}
+
+// readLimited reads at most maxSize bytes from r and fails if more remain, so
+// an oversized input is never loaded in full.
+func readLimited(field, source string, r io.Reader, maxSize int) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return "", clerrors.NewIOError("cannot read prompt from "+source,
+			fmt.Errorf("%w: %w", clerrors.ErrFailedToReadInput, err))
+	}
+	if len(data) > maxSize {
+		return "", promptTooLargeError(field, maxSize)
+	}
+	return string(data), nil
+}
+
+// promptTooLargeError reports a prompt exceeding --max-prompt-size.
+func promptTooLargeError(field string, maxSize int) error {
+	return clerrors.NewValidationError(field, "", fmt.Sprintf(
+		"prompt exceeds the %d byte limit; trim the input or raise --max-prompt-size", maxSize))
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePrompts(t *testing.T) {
+	dir := t.TempDir()
+	userFile := filepath.Join(dir, "user.md")
+	if err := os.WriteFile(userFile, []byte("file body\n"), 0o600); err != nil {
+		t.Fatalf("write user file: %v", err)
+	}
+	sysFile := filepath.Join(dir, "sys.txt")
+	if err := os.WriteFile(sysFile, []byte("be terse"), 0o600); err != nil {
+		t.Fatalf("write sys file: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		userPrompt string
+		userFile   string
+		sysFile    string
+		maxSize    int
+		args       []string
+		stdin      string
+		wantUser   string
+		wantSys    string
+		wantErr    string
+	}{
+		{
+			name:       "flag prompt unchanged",
+			userPrompt: "what is go",
+			wantUser:   "what is go",
+		},
+		{
+			name:     "positional args only",
+			args:     []string{"what", "is", "go"},
+			wantUser: "what is go",
+		},
+		{
+			name:       "flag and positional conflict",
+			userPrompt: "what is go",
+			args:       []string{"extra"},
+			wantErr:    "not both",
+		},
+		{
+			name:       "stdin only",
+			userPrompt: "-",
+			stdin:      "diff --git a b\n",
+			wantUser:   "diff --git a b",
+		},
+		{
+			name:       "positional instruction with stdin context",
+			userPrompt: "-",
+			args:       []string{"Review", "this"},
+			stdin:      "diff --git a b\n",
+			wantUser:   "Review this" + promptContextSeparator + "diff --git a b",
+		},
+		{
+			name:       "empty stdin keeps instruction",
+			userPrompt: "-",
+			args:       []string{"Review"},
+			wantUser:   "Review",
+		},
+		{
+			name:     "user prompt file with instruction",
+			userFile: userFile,
+			args:     []string{"Summarize"},
+			wantUser: "Summarize" + promptContextSeparator + "file body",
+		},
+		{
+			name:       "system prompt file",
+			userPrompt: "hi",
+			sysFile:    sysFile,
+			wantUser:   "hi",
+			wantSys:    "be terse",
+		},
+		{
+			name:     "missing user prompt file",
+			userFile: filepath.Join(dir, "missing.md"),
+			wantErr:  "file not found",
+		},
+		{
+			name:       "stdin over limit",
+			userPrompt: "-",
+			stdin:      strings.Repeat("x", 11),
+			maxSize:    10,
+			wantErr:    "exceeds the 10 byte limit",
+		},
+		{
+			name:       "stdin at limit",
+			userPrompt: "-",
+			stdin:      strings.Repeat("x", 10),
+			maxSize:    10,
+			wantUser:   strings.Repeat("x", 10),
+		},
+		{
+			name:       "combined prompt over limit",
+			userPrompt: "-",
+			args:       []string{"instruction"},
+			stdin:      strings.Repeat("x", 10),
+			maxSize:    10,
+			wantErr:    "exceeds the 10 byte limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := *globalOpts
+			t.Cleanup(func() { *globalOpts = orig })
+			globalOpts.UserPrompt = tt.userPrompt
+			globalOpts.UserPromptFile = tt.userFile
+			globalOpts.SystemPrompt = ""
+			globalOpts.SystemPromptFile = tt.sysFile
+			globalOpts.MaxPromptSize = tt.maxSize
+
+			err := resolvePrompts(tt.args, strings.NewReader(tt.stdin))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolvePrompts() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolvePrompts() unexpected error: %v", err)
+			}
+			if globalOpts.UserPrompt != tt.wantUser {
+				t.Errorf("UserPrompt = %q, want %q", globalOpts.UserPrompt, tt.wantUser)
+			}
+			if globalOpts.SystemPrompt != tt.wantSys {
+				t.Errorf("SystemPrompt = %q, want %q", globalOpts.SystemPrompt, tt.wantSys)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/prompts"
+	"github.com/spf13/cobra"
+)
+
+// promptsTabPadding is the column padding of the prompts table.
+const promptsTabPadding = 2
+
+// Prompt template flags of the query command.
+var (
+	queryPromptTemplate string
+	queryVars           []string
+)
+
+// addPromptTemplateFlags registers --prompt-template and --var.
+func addPromptTemplateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&queryPromptTemplate, "prompt-template", "",
+		"Render a prompt template from the prompts section of the config file")
+	cmd.Flags().StringArrayVar(&queryVars, "var", nil,
+		"Prompt template variable as key=value (repeatable)")
+
+	if err := cmd.RegisterFlagCompletionFunc("prompt-template", completePromptTemplates); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'prompt-template' flag: %v\n", err)
+	}
+}
+
+// applyPromptTemplate renders --prompt-template with the --var values into
+// globalOpts. The template's system text sets the system prompt. Its user
+// text becomes the user prompt; a prompt also given on the command line is
+// appended to it as input, like piped input after a positional instruction.
+func applyPromptTemplate(cfg *config.ConfigData) error {
+	if queryPromptTemplate == "" {
+		if len(queryVars) > 0 {
+			return clerrors.NewValidationError("var", queryVars[0], "requires --prompt-template")
+		}
+		return nil
+	}
+
+	tmpl, err := config.FindPrompt(cfg, queryPromptTemplate)
+	if err != nil {
+		return err //nolint:wrapcheck // sentinel error lists the available prompts
+	}
+	vars, err := prompts.ParseVars(queryVars)
+	if err != nil {
+		return err //nolint:wrapcheck // sentinel error names the invalid pair
+	}
+	system, user, err := tmpl.Render(queryPromptTemplate, vars)
+	if err != nil {
+		return err //nolint:wrapcheck // sentinel error lists the missing variables
+	}
+
+	if system != "" {
+		if globalOpts.SystemPrompt != "" {
+			return clerrors.NewValidationError("sys-prompt", "",
+				fmt.Sprintf("conflicts with prompt template %q, which sets the system prompt", queryPromptTemplate))
+		}
+		globalOpts.SystemPrompt = system
+	}
+	if user != "" {
+		globalOpts.UserPrompt = combinePrompt(user, globalOpts.UserPrompt)
+	}
+	return nil
+}
+
+// promptsCmd groups the prompt template commands.
+var promptsCmd = &cobra.Command{
+	Use:   "prompts",
+	Short: "Manage prompt templates",
+	Long: `Prompt templates are named prompts defined in the prompts section of the
+config file. Their system and user text may contain {{variable}} placeholders
+that are filled with --var when the template is used:
+
+  prompts:
+    review:
+      description: Review code in a given language
+      system: You are an expert {{language}} reviewer. Be {{tone}}.
+      user: Review this code for bugs and style issues.
+
+  git diff | pplx query --prompt-template review --var language=Go --var tone=concise -p -`,
+}
+
+// promptsListCmd lists the prompt templates of the config file.
+var promptsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List prompt templates and their variables",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cfg, err := loadConfigData(configFilePath)
+		if err != nil {
+			return err
+		}
+		return writePromptsTable(cmd.OutOrStdout(), cfg)
+	},
+}
+
+// writePromptsTable prints every prompt template with its required variables.
+func writePromptsTable(out io.Writer, cfg *config.ConfigData) error {
+	names := config.PromptNames(cfg)
+	if len(names) == 0 {
+		_, err := fmt.Fprintln(out, "No prompt templates defined.")
+		return err //nolint:wrapcheck // stdout write
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, promptsTabPadding, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVARIABLES\tDESCRIPTION")
+	for _, name := range names {
+		tmpl := cfg.Prompts[name]
+		vars, err := tmpl.Variables(name)
+		if err != nil {
+			return err //nolint:wrapcheck // sentinel error names the template
+		}
+		varList := "-"
+		if len(vars) > 0 {
+			varList = strings.Join(vars, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, varList, tmpl.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write prompts table: %w", err)
+	}
+	return nil
+}
+
+// completePromptTemplates completes --prompt-template with the names of the
+// templates in the config file.
+func completePromptTemplates(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := loadConfigData(configFilePath)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return config.PromptNames(cfg), cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(promptsCmd)
+	promptsCmd.AddCommand(promptsListCmd)
+	promptsCmd.PersistentFlags().StringVar(&configFilePath, "config", "", "Path to config file")
+}
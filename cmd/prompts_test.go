@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+// setPromptTemplateFlags sets the template flags for a test and restores
+// them and globalOpts afterwards.
+func setPromptTemplateFlags(t *testing.T, name string, vars ...string) {
+	t.Helper()
+	oldName, oldVars, oldOpts := queryPromptTemplate, queryVars, *globalOpts
+	queryPromptTemplate, queryVars = name, vars
+	t.Cleanup(func() {
+		queryPromptTemplate, queryVars = oldName, oldVars
+		*globalOpts = oldOpts
+	})
+}
+
+func promptsConfig() *config.ConfigData {
+	cfg := config.NewConfigData()
+	cfg.Prompts = map[string]*config.PromptTemplate{
+		"review": {
+			Description: "Code review",
+			System:      "You review {{language}} code.",
+			User:        "Review for {{focus}}.",
+		},
+	}
+	return cfg
+}
+
+func TestApplyPromptTemplate(t *testing.T) {
+	t.Run("renders system and user text", func(t *testing.T) {
+		setPromptTemplateFlags(t, "review", "language=Go", "focus=bugs")
+		globalOpts.SystemPrompt, globalOpts.UserPrompt = "", "func main() {}"
+
+		if err := applyPromptTemplate(promptsConfig()); err != nil {
+			t.Fatalf("applyPromptTemplate() error = %v", err)
+		}
+		if globalOpts.SystemPrompt != "You review Go code." {
+			t.Errorf("SystemPrompt = %q", globalOpts.SystemPrompt)
+		}
+		if !strings.HasPrefix(globalOpts.UserPrompt, "Review for bugs.") ||
+			!strings.HasSuffix(globalOpts.UserPrompt, "func main() {}") {
+			t.Errorf("UserPrompt = %q, want the template followed by the input", globalOpts.UserPrompt)
+		}
+	})
+
+	t.Run("missing variables", func(t *testing.T) {
+		setPromptTemplateFlags(t, "review")
+		err := applyPromptTemplate(promptsConfig())
+		if !errors.Is(err, clerrors.ErrMissingPromptVariables) || !strings.Contains(err.Error(), "focus, language") {
+			t.Errorf("applyPromptTemplate() error = %v, want missing focus and language", err)
+		}
+	})
+
+	t.Run("conflicting system prompt", func(t *testing.T) {
+		setPromptTemplateFlags(t, "review", "language=Go", "focus=bugs")
+		globalOpts.SystemPrompt = "Be brief"
+		var validationErr *clerrors.ValidationError
+		if err := applyPromptTemplate(promptsConfig()); !errors.As(err, &validationErr) {
+			t.Errorf("applyPromptTemplate() error = %v, want ValidationError", err)
+		}
+	})
+
+	t.Run("unknown template", func(t *testing.T) {
+		setPromptTemplateFlags(t, "nope")
+		if err := applyPromptTemplate(promptsConfig()); !errors.Is(err, clerrors.ErrPromptNotFound) {
+			t.Errorf("applyPromptTemplate() error = %v, want ErrPromptNotFound", err)
+		}
+	})
+
+	t.Run("var without template", func(t *testing.T) {
+		setPromptTemplateFlags(t, "", "language=Go")
+		var validationErr *clerrors.ValidationError
+		if err := applyPromptTemplate(promptsConfig()); !errors.As(err, &validationErr) {
+			t.Errorf("applyPromptTemplate() error = %v, want ValidationError", err)
+		}
+	})
+}
+
+func TestWritePromptsTable(t *testing.T) {
+	var out bytes.Buffer
+	if err := writePromptsTable(&out, promptsConfig()); err != nil {
+		t.Fatalf("writePromptsTable() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "review") || !strings.Contains(out.String(), "focus, language") {
+		t.Errorf("table missing template or variables:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := writePromptsTable(&out, config.NewConfigData()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "No prompt templates") {
+		t.Errorf("unexpected output for empty config: %q", out.String())
+	}
+}
+
+func TestApplyDefaultSystemPrompt(t *testing.T) {
+	cfg := promptsConfig()
+	cfg.Defaults.SystemPrompt = "Cite peer-reviewed sources."
+
+	tests := []struct {
+		name     string
+		template string
+		system   string
+		want     string
+	}{
+		{name: "no system prompt", want: "Cite peer-reviewed sources."},
+		{name: "explicit system prompt", system: "Be brief.", want: "Be brief."},
+		{name: "template system prompt", template: "review", want: "You review Go code."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var vars []string
+			if tt.template != "" {
+				vars = []string{"language=Go", "focus=bugs"}
+			}
+			setPromptTemplateFlags(t, tt.template, vars...)
+			globalOpts.SystemPrompt, globalOpts.UserPrompt = tt.system, "question"
+			if err := applyPromptTemplate(cfg); err != nil {
+				t.Fatal(err)
+			}
+			applyDefaultSystemPrompt(cfg)
+			if globalOpts.SystemPrompt != tt.want {
+				t.Errorf("SystemPrompt = %q, want %q", globalOpts.SystemPrompt, tt.want)
+			}
+		})
+	}
+}
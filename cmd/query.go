@@ -1,130 +1,275 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pterm/pterm"
 	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/citations"
 	"github.com/sgaunet/pplx/pkg/config"
 	"github.com/sgaunet/pplx/pkg/console"
 	clerrors "github.com/sgaunet/pplx/pkg/clerrors"
 	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/pplx"
+	"github.com/sgaunet/pplx/pkg/prompts"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/sgaunet/pplx/pkg/validation"
+	"github.com/sgaunet/pplx/pkg/warnings"
 	"github.com/spf13/cobra"
 )
 
+// queryJSONSchema is the --json-schema flag.
+var queryJSONSchema bool
+
+// queryExtract is the --extract path.
+var queryExtract string
+
 var queryCmd = &cobra.Command{
-	Use:   "query",
+	Use:   "query [instruction]",
 	Short: "",
-	Long:  ``,
-	RunE: func(cmd *cobra.Command, _ []string) error {
+	Long: `Send a single prompt to Perplexity and print the answer.
+
+The prompt can come from --user-prompt, from a file with --user-prompt-file,
+or from stdin with --user-prompt -. Positional arguments are an instruction:
+alone they are the prompt; combined with stdin or a file they are placed
+first and the input is appended as context.
+
+Examples:
+  pplx query -p "What is Go?"
+  git diff | pplx query -p - "Review this diff"
+  pplx query --user-prompt-file notes.md --system-prompt-file reviewer.txt
+  pplx query --prompt-template review --var language=Go -p "$(cat main.go)"
+  pplx query --messages-file few-shot.yaml -p "Classify: the build is green"
+
+--messages-file sends a conversation instead of a single prompt: a JSON or
+YAML array of {role, content} messages, with an optional system message
+first and user and assistant messages in turn. --user-prompt, if given, is
+appended as the final user message.
+
+With --json the answer is printed as a JSON document with a stable set of
+fields; --json-fields selects a subset and --json-schema prints its schema.
+
+With --batch, every line of a JSON lines file is a prompt object such as
+  {"user_prompt": "What is Go?", "system_prompt": "Be brief", "model": "sonar-pro"}
+Lines accept the argument names of the MCP query tool to override flags and
+configuration. Results are written as JSON lines in input order, with failed
+lines recorded as {"line": N, "error": "..."}. Ctrl-C stops new requests,
+finishes those in flight, and reports how many lines completed.
+
+  pplx query --batch prompts.jsonl --concurrency 8 --rate-limit 50 -o results.jsonl
+  pplx query --batch prompts.jsonl --dry-run
+
+With --repeat K the prompt is sent K times and every answer is printed with
+a usage summary; --seed-vary spreads the temperature of the runs.
+
+  pplx query -p "Name a fruit" --repeat 5 --temperature 0.7 --seed-vary
+
+Without --batch, --dry-run builds the request through the usual flag,
+profile, and config merge and prints it instead of sending it: the effective
+model, every option set and where it came from, the request JSON, and the
+estimated prompt tokens. No API key is needed.
+
+  pplx query --profile research --temperature 0.1 -p "What is Go?" --dry-run
+
+--export-request prints only the request body, in the OpenAI chat/completions
+format (Perplexity-only fields are dropped with a warning) or the Perplexity
+one, for replaying it against another endpoint.
+
+  pplx query -p "What is Go?" --export-request openai`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		applyQuiet(cmd)
+		out := cmd.OutOrStdout()
+		if queryJSONSchema {
+			_, err := out.Write(output.Schema())
+			if err != nil {
+				return clerrors.NewIOError("failed to write JSON schema", err)
+			}
+			return nil
+		}
+
 		// Step 1: Load and merge configuration
 		// Graceful degradation: If config load fails, continue with CLI flags only.
 		// Rationale: User may not have a config file yet, but CLI should still work.
 		// This allows the tool to be used immediately after installation without setup.
-		cfg, err := config.LoadAndMergeConfig(cmd, configFilePath, runtimeProfile)
+		// Keyring and passphrase errors are the exception (see loadRunConfig).
+		cfg, err := loadRunConfig(cmd)
 		if err != nil {
-			// Non-fatal: continue with CLI flags only
-			cfg = config.NewConfigData()
+			return err
 		}
 
 		// Apply merged config to global variables
 		// Design note: Uses globals for cobra flag compatibility - flags are bound to globals,
 		// and ApplyToGlobals ensures config values only apply when flags aren't set.
 		config.ApplyToGlobals(cfg, globalOpts)
+		applyColorTheme()
+
+		// Batch mode runs many prompts from a file and has its own flow.
+		if queryBatchFile != "" {
+			return runBatch(cmd, cfg)
+		}
+
+		// Export: build the request exactly as below, print it in another
+		// wire format, send nothing.
+		if queryExportRequest != "" {
+			return runExportRequest(cmd, args, cfg)
+		}
+
+		// Dry run: build the request exactly as below, print it, send nothing.
+		if queryDryRun {
+			req, err := prepareQueryRequest(cmd, args, cfg)
+			if err != nil {
+				return err
+			}
+			return writeDryRun(cmd.OutOrStdout(), cfg, runConfigSources, req)
+		}
 
 		// Step 2: Initialize API client
 		// API key checked here (not in config load) because it's required at runtime,
 		// but config file is optional. This provides fast feedback if key is missing.
 		// Fail fast principle: better to error immediately than during expensive API call.
-		if os.Getenv("PPLX_API_KEY") == "" {
-			return clerrors.NewConfigError("PPLX_API_KEY environment variable is not set", nil)
+		apiKey, err := resolveAPIKey(cfg)
+		if err != nil {
+			return err
 		}
 
-		client := perplexity.NewClient(os.Getenv("PPLX_API_KEY"))
-		client.SetHTTPTimeout(globalOpts.Timeout)
-
-		// Step 3: Validate inputs
-		// Early validation before expensive API call provides fast feedback on errors.
-		// Catches malformed options (invalid dates, conflicting flags) before network call.
-		if err := validateInputs(); err != nil {
+		client, err := newAPIClient(apiKey, choicesTransport()...)
+		if err != nil {
 			return err
 		}
 
-		// Step 4: Build request with all options
-		// Separated into dedicated function for testability and reusability.
-		// Allows testing request building logic independently from API calls.
-		req, err := buildAllOptions()
+		// Steps 3 and 4: Resolve and validate inputs, then build the request
+		req, err := prepareQueryRequest(cmd, args, cfg)
 		if err != nil {
 			return err
 		}
+		recordPrompt(cfg, req.Model)
+
+		// An identical earlier query may be answered from the response cache;
+		// --repeat and --compare ask for fresh answers.
+		if queryRepeat <= 1 && len(queryCompare) == 0 {
+			if served, err := serveFromCache(cfg, req, out); served || err != nil {
+				return err
+			}
+		}
+
+		// Cached answers are free; new requests must fit the daily budget.
+		if err := checkQueryBudget(cfg, req); err != nil {
+			return err
+		}
+
+		if queryRepeat > 1 {
+			return runRepeat(cmd.Context(), client, req)
+		}
+		if len(queryCompare) > 0 {
+			return runCompare(cmd.Context(), client, req)
+		}
+
+		// Ctrl-C cancels the request; a streamed answer keeps what arrived.
+		ctx, stop := interruptContext(cmd.Context())
+		defer stop()
 
 		// Step 5: Execute request (streaming or non-streaming)
 		// Different code paths because streaming requires goroutine coordination
 		// while non-streaming uses synchronous request-response pattern.
 		// Streaming: incremental rendering with channels and goroutines
 		// Non-streaming: spinner while waiting, then render complete response
-		if globalOpts.Stream {
-			return handleStreamingResponse(client, req)
+		// Several --choices are never streamed: they are printed one after another.
+		// Neither are answers saved with --output or cut with --max-lines.
+		if streamAnswer() {
+			return handleStreamingResponse(ctx, client, req, out)
 		}
-		return handleNonStreamingResponse(client, req)
+		return handleNonStreamingResponse(ctx, client, req, out)
 	},
 }
 
-// parseDateFilter parses a date string in either YYYY-MM-DD (ISO 8601) or MM/DD/YYYY format.
-// ISO 8601 is tried first; MM/DD/YYYY is the fallback.
-// Returns the parsed time and an error if neither format matches.
-func parseDateFilter(fieldName, dateStr string) (time.Time, error) {
-	if date, err := time.Parse("2006-01-02", dateStr); err == nil {
-		return date, nil
+// prepareQueryRequest resolves the prompts and builds the validated request.
+func prepareQueryRequest(
+	cmd *cobra.Command, args []string, cfg *config.ConfigData,
+) (*perplexity.CompletionRequest, error) {
+	// Step 3: Resolve and validate inputs
+	// Prompts may be read from files or stdin, so they are resolved first.
+	// Early validation before expensive API call provides fast feedback on errors.
+	// Catches malformed options (invalid dates, conflicting flags) before network call.
+	if err := resolvePrompts(args, cmd.InOrStdin()); err != nil {
+		return nil, err
 	}
-	if date, err := time.Parse("01/02/2006", dateStr); err == nil {
-		return date, nil
+	if err := applyPromptTemplate(cfg); err != nil {
+		return nil, err
 	}
-	return time.Time{}, clerrors.NewValidationError(
-		fieldName, dateStr, "invalid date format, use YYYY-MM-DD or MM/DD/YYYY",
-	)
+	if err := resolveMessagesFile(); err != nil {
+		return nil, err
+	}
+	applyDefaultSystemPrompt(cfg)
+	if err := validateInputs(); err != nil {
+		return nil, err
+	}
+
+	// Step 4: Build request with all options
+	// Separated into dedicated function for testability and reusability.
+	// Allows testing request building logic independently from API calls.
+	req, err := buildAllOptions()
+	if err != nil {
+		return nil, err
+	}
+	capMaxTokens(cfg, req)
+	return req, nil
+}
+
+// parseDateFilter parses a date filter with validation.ValidateDate, which
+// accepts ISO 8601, MM/DD/YYYY, relative dates such as 7d, and quarters.
+// Returns a ValidationError for fieldName when no format matches.
+func parseDateFilter(fieldName, dateStr string) (time.Time, error) {
+	date, err := validation.ValidateDate(dateStr)
+	if err != nil {
+		return time.Time{}, clerrors.NewValidationError(fieldName, dateStr, err.Error())
+	}
+	return date, nil
 }
 
-// validateStringEnum validates that a value is in the allowed set.
-// Returns nil if value is empty or valid, error otherwise.
-func validateStringEnum(fieldName, value string, validValues map[string]bool, validList string) error {
+// validateStringEnum validates that a value is in the canonical set for kind
+// (see pkg/validation). Returns nil if value is empty or valid, error otherwise.
+func validateStringEnum(fieldName, kind, value string) error {
 	if value == "" {
 		return nil
 	}
-	if !validValues[value] {
+	if !validation.IsValid(kind, value) {
 		return clerrors.NewValidationError(fieldName, value,
-			"must be one of: "+validList)
+			"must be one of: "+validation.ValidList(kind))
 	}
 	return nil
 }
 
-// buildBaseOptions creates the base completion request options.
-// These options are always included in every request.
-func buildBaseOptions() ([]perplexity.CompletionRequestOption, error) {
-	msg := perplexity.NewMessages(perplexity.WithSystemMessage(globalOpts.SystemPrompt))
-	if err := addUserMessage(&msg); err != nil {
+// buildMessages builds the conversation of the request: the system prompt
+// with the instruction of --language, the --messages-file transcript, then
+// the user prompt and its attachments.
+func buildMessages() (*perplexity.Messages, error) {
+	system := prompts.WithLanguage(globalOpts.SystemPrompt, globalOpts.Language)
+	msg := perplexity.NewMessages(perplexity.WithSystemMessage(system))
+	if err := addTranscript(&msg); err != nil {
 		return nil, err
 	}
-
-	return []perplexity.CompletionRequestOption{
-		perplexity.WithMessagesFromMessages(&msg),
-		perplexity.WithModel(globalOpts.Model),
-		perplexity.WithFrequencyPenalty(globalOpts.FrequencyPenalty),
-		perplexity.WithMaxTokens(globalOpts.MaxTokens),
-		perplexity.WithPresencePenalty(globalOpts.PresencePenalty),
-		perplexity.WithTemperature(globalOpts.Temperature),
-		perplexity.WithTopK(globalOpts.TopK),
-		perplexity.WithTopP(globalOpts.TopP),
-	}, nil
+	if len(queryMessages) == 0 || globalOpts.UserPrompt != "" {
+		if err := addUserMessage(&msg); err != nil {
+			return nil, err
+		}
+	}
+	return &msg, nil
 }
 
 // addUserMessage appends the user prompt (and any file attachments) to msg.
@@ -250,173 +395,93 @@ func mapAttachmentError(field, value string, err error) error {
 	return clerrors.NewIOError("failed to process "+value, err)
 }
 
-// buildSearchOptions creates search-related options for the completion request.
-// Returns options based on search flags (domains, recency, location).
-func buildSearchOptions() []perplexity.CompletionRequestOption {
-	var opts []perplexity.CompletionRequestOption
-
-	if len(globalOpts.SearchDomains) > 0 {
-		opts = append(opts, perplexity.WithSearchDomainFilter(globalOpts.SearchDomains))
+// validateInputs validates user inputs before building the request.
+// This centralizes all pre-request validation logic.
+func validateInputs() error {
+	if globalOpts.UserPrompt == "" && len(queryMessages) == 0 {
+		return clerrors.NewValidationError("user-prompt", "", "user prompt is required")
 	}
 
-	// Handle search recency - incompatible with images
-	if globalOpts.SearchRecency != "" {
-		if globalOpts.ReturnImages {
-			// User-facing notification (not a log message)
-			fmt.Printf("Note: When using --return-images, search-recency is automatically disabled\nProceeding with image search...\n")
-		} else {
-			opts = append(opts, perplexity.WithSearchRecencyFilter(globalOpts.SearchRecency))
-		}
+	if err := resolveModel(); err != nil {
+		return err
 	}
 
-	if globalOpts.LocationLat != 0 || globalOpts.LocationLon != 0 || globalOpts.LocationCountry != "" {
-		opts = append(opts, perplexity.WithUserLocation(globalOpts.LocationLat, globalOpts.LocationLon, globalOpts.LocationCountry))
+	if err := validateEnumFields(); err != nil {
+		return err
 	}
 
-	if globalOpts.SearchMode != "" {
-		opts = append(opts, perplexity.WithSearchMode(globalOpts.SearchMode))
+	if err := validateDomainFilter(); err != nil {
+		return err
 	}
 
-	if globalOpts.SearchContextSize != "" {
-		opts = append(opts, perplexity.WithSearchContextSize(globalOpts.SearchContextSize))
+	if err := validateDateFilters(); err != nil {
+		return err
 	}
 
-	return opts
-}
-
-// buildResponseOptions creates response enhancement options.
-// Handles streaming, images, and related questions.
-func buildResponseOptions() []perplexity.CompletionRequestOption {
-	var opts []perplexity.CompletionRequestOption
-
-	if globalOpts.ReturnImages {
-		opts = append(opts, perplexity.WithReturnImages(globalOpts.ReturnImages))
-		// When images are requested, explicitly disable search recency
-		opts = append(opts, perplexity.WithSearchRecencyFilter(""))
+	if err := validation.ValidateLanguage(globalOpts.Language); err != nil {
+		return clerrors.NewValidationError("language", globalOpts.Language, err.Error())
 	}
 
-	if globalOpts.ReturnRelated {
-		opts = append(opts, perplexity.WithReturnRelatedQuestions(globalOpts.ReturnRelated))
+	if err := validation.ValidateCountry(globalOpts.LocationCountry); err != nil {
+		return clerrors.NewValidationError("location-country", globalOpts.LocationCountry, err.Error())
 	}
 
-	if globalOpts.Stream {
-		opts = append(opts, perplexity.WithStream(globalOpts.Stream))
+	if err := validateImageSaving(); err != nil {
+		return err
 	}
 
-	return opts
-}
-
-// buildImageOptions creates image filtering options.
-// Validates image formats and warns about unsupported formats.
-func buildImageOptions() []perplexity.CompletionRequestOption {
-	var opts []perplexity.CompletionRequestOption
-
-	if len(globalOpts.ImageDomains) > 0 {
-		opts = append(opts, perplexity.WithImageDomainFilter(globalOpts.ImageDomains))
+	if err := resolveImageConflict(); err != nil {
+		return err
 	}
 
-	if len(globalOpts.ImageFormats) > 0 {
-		// Validate image formats
-		for _, format := range globalOpts.ImageFormats {
-			if !config.ValidImageFormats[format] {
-				logger.Warn("image format may not be supported",
-					"format", format,
-					"supported", "jpg, jpeg, png, gif, webp, svg, bmp")
-			}
+	if globalOpts.JSONFields != "" {
+		if _, err := output.ParseFields(globalOpts.JSONFields); err != nil {
+			return clerrors.NewValidationError("json-fields", globalOpts.JSONFields, err.Error())
 		}
-		opts = append(opts, perplexity.WithImageFormatFilter(globalOpts.ImageFormats))
+		globalOpts.OutputJSON = true
 	}
 
-	return opts
-}
-
-// buildResponseFormatOptions creates structured output format options.
-// Handles JSON schema and regex response formats (sonar models only).
-func buildResponseFormatOptions() ([]perplexity.CompletionRequestOption, error) {
-	var opts []perplexity.CompletionRequestOption
-
-	if globalOpts.ResponseFormatJSONSchema != "" {
-		var schema any
-		err := json.Unmarshal([]byte(globalOpts.ResponseFormatJSONSchema), &schema)
-		if err != nil {
-			return nil, clerrors.NewValidationError("response-format-json-schema",
-				globalOpts.ResponseFormatJSONSchema, fmt.Sprintf("invalid JSON schema: %v", err))
+	if queryExtract != "" {
+		if _, err := output.ParsePath(queryExtract); err != nil {
+			return clerrors.NewValidationError("extract", queryExtract, err.Error())
 		}
-		opts = append(opts, perplexity.WithJSONSchemaResponseFormat(schema))
 	}
 
-	if globalOpts.ResponseFormatRegex != "" {
-		opts = append(opts, perplexity.WithRegexResponseFormat(globalOpts.ResponseFormatRegex))
+	if err := validateChoices(); err != nil {
+		return err
 	}
 
-	return opts, nil
-}
-
-// buildDateFilterOptions creates date-based filtering options.
-// Parses and validates all date filter flags.
-func buildDateFilterOptions() ([]perplexity.CompletionRequestOption, error) {
-	var opts []perplexity.CompletionRequestOption
-
-	if globalOpts.SearchAfterDate != "" {
-		date, err := parseDateFilter("search-after-date", globalOpts.SearchAfterDate)
-		if err != nil {
-			return nil, err
-		}
-		opts = append(opts, perplexity.WithPublishedAfter(date))
+	if err := validateAnswerOutput(); err != nil {
+		return err
 	}
 
-	if globalOpts.SearchBeforeDate != "" {
-		date, err := parseDateFilter("search-before-date", globalOpts.SearchBeforeDate)
-		if err != nil {
-			return nil, err
-		}
-		opts = append(opts, perplexity.WithPublishedBefore(date))
+	if err := validateAutoContinue(); err != nil {
+		return err
 	}
 
-	if globalOpts.LastUpdatedAfter != "" {
-		date, err := parseDateFilter("last-updated-after", globalOpts.LastUpdatedAfter)
-		if err != nil {
-			return nil, err
-		}
-		opts = append(opts, perplexity.WithLastUpdatedAfterFilter(date))
+	if err := validateRepeat(); err != nil {
+		return err
 	}
 
-	if globalOpts.LastUpdatedBefore != "" {
-		date, err := parseDateFilter("last-updated-before", globalOpts.LastUpdatedBefore)
-		if err != nil {
-			return nil, err
-		}
-		opts = append(opts, perplexity.WithLastUpdatedBeforeFilter(date))
+	if err := validateCompare(); err != nil {
+		return err
 	}
 
-	return opts, nil
-}
-
-// buildDeepResearchOptions creates deep research options.
-// Handles reasoning effort for sonar-deep-research models.
-func buildDeepResearchOptions() []perplexity.CompletionRequestOption {
-	var opts []perplexity.CompletionRequestOption
+	if err := validateStrictFormat(); err != nil {
+		return err
+	}
 
-	if globalOpts.ReasoningEffort != "" {
-		// Warn if model doesn't support reasoning effort
-		if !strings.Contains(globalOpts.Model, "deep-research") {
-			logger.Warn("reasoning-effort only supported by sonar-deep-research model",
-				"current_model", globalOpts.Model)
+	if globalOpts.Render != "" {
+		if _, err := render.ParseMode(globalOpts.Render); err != nil {
+			return clerrors.NewValidationError("render", globalOpts.Render, err.Error())
 		}
-		opts = append(opts, perplexity.WithReasoningEffort(globalOpts.ReasoningEffort))
 	}
 
-	return opts
-}
-
-// validateInputs validates user inputs before building the request.
-// This centralizes all pre-request validation logic.
-func validateInputs() error {
-	if globalOpts.UserPrompt == "" {
-		return clerrors.NewValidationError("user-prompt", "", "user prompt is required")
+	if _, err := citationStyle(); err != nil {
+		return err
 	}
 
-	if err := validateEnumFields(); err != nil {
+	if _, err := streamStyle(); err != nil {
 		return err
 	}
 
@@ -427,6 +492,42 @@ func validateInputs() error {
 	return validateResponseFormats()
 }
 
+// resolveImageConflict applies output.image_conflict_policy when both
+// --search-recency and --return-images are set: the dropped option is
+// cleared with a note, or the query fails under the error policy.
+func resolveImageConflict() error {
+	res, err := validation.ResolveImageConflict(globalOpts.SearchRecency, globalOpts.ReturnImages,
+		globalOpts.ImageConflictPolicy)
+	if errors.Is(err, clerrors.ErrInvalidImageConflictPolicy) {
+		return clerrors.NewValidationError("output.image_conflict_policy", globalOpts.ImageConflictPolicy, err.Error())
+	}
+	if err != nil {
+		return clerrors.NewValidationError("search-recency", globalOpts.SearchRecency, err.Error())
+	}
+	if res.Warning != "" {
+		fmt.Fprintf(noteOutput(), "Note: %s\n", res.Warning)
+	}
+	globalOpts.SearchRecency, globalOpts.ReturnImages = res.Recency, res.ReturnImages
+	return nil
+}
+
+// resolveStreamFormat turns --stream off, with a warning, when the request
+// has a response format, which the API ignores in streamed answers. Under
+// output.strict_options the request is rejected instead.
+func resolveStreamFormat() error {
+	res, err := validation.ResolveStreamFormat(globalOpts.Stream, globalOpts.ResponseFormatJSONSchema,
+		globalOpts.ResponseFormatRegex, globalOpts.StrictOptions)
+	if err != nil {
+		return clerrors.NewValidationError("stream", "true", err.Error())
+	}
+	if res.Warning != "" {
+		warnings.Add(warnings.CodeStreamFormat, res.Warning,
+			"set output.strict_options: true to reject such requests instead")
+	}
+	globalOpts.Stream = res.Stream
+	return nil
+}
+
 // validateFiles checks every --file entry up front: that URLs are https, local
 // paths exist, and extensions are supported. The library re-validates size and
 // format during encoding, but fast-failing here keeps errors consistent with
@@ -452,30 +553,90 @@ func validateFiles() error {
 // validateEnumFields validates all enum-based configuration options.
 func validateEnumFields() error {
 	// Validate search recency
-	if err := validateStringEnum("search-recency", globalOpts.SearchRecency,
-		config.ValidSearchRecency, "day, week, month, year, hour"); err != nil {
+	if err := validateStringEnum("search-recency", validation.KindRecency,
+		globalOpts.SearchRecency); err != nil {
 		return err
 	}
 
 	// Validate search mode
-	if err := validateStringEnum("search-mode", globalOpts.SearchMode,
-		config.ValidSearchModes, "web, academic"); err != nil {
+	if err := validateStringEnum("search-mode", validation.KindSearchMode,
+		globalOpts.SearchMode); err != nil {
 		return err
 	}
 
 	// Validate search context size
-	if err := validateStringEnum("search-context-size", globalOpts.SearchContextSize,
-		config.ValidContextSizes, "low, medium, high"); err != nil {
+	if err := validateStringEnum("search-context-size", validation.KindContextSize,
+		globalOpts.SearchContextSize); err != nil {
 		return err
 	}
 
 	// Validate reasoning effort
-	return validateStringEnum("reasoning-effort", globalOpts.ReasoningEffort,
-		config.ValidReasoningEfforts, "low, medium, high")
+	return validateStringEnum("reasoning-effort", validation.KindReasoningEffort,
+		globalOpts.ReasoningEffort)
 }
 
-// validateResponseFormats validates response format options and model compatibility.
+// validateDateFilters parses the date filter flags (see parseDateFilter).
+func validateDateFilters() error {
+	dates := []struct{ field, value string }{
+		{"search-after-date", globalOpts.SearchAfterDate},
+		{"search-before-date", globalOpts.SearchBeforeDate},
+		{"last-updated-after", globalOpts.LastUpdatedAfter},
+		{"last-updated-before", globalOpts.LastUpdatedBefore},
+	}
+	for _, d := range dates {
+		if d.value == "" {
+			continue
+		}
+		if _, err := parseDateFilter(d.field, d.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateDomainFilter checks the syntax of --search-domains and
+// --exclude-domains and that no domain is in both.
+func validateDomainFilter() error {
+	if _, err := validation.DomainFilter(globalOpts.SearchDomains, nil); err != nil {
+		return clerrors.NewValidationError("search-domains", strings.Join(globalOpts.SearchDomains, ","), err.Error())
+	}
+	if _, err := validation.DomainFilter(globalOpts.SearchDomains, globalOpts.ExcludeDomains); err != nil {
+		return clerrors.NewValidationError("exclude-domains", strings.Join(globalOpts.ExcludeDomains, ","), err.Error())
+	}
+	return nil
+}
+
+// resolveModel expands the model aliases from the config in globalOpts.Model
+// and --fallback-models and checks the model against the model registry.
+// Unknown models are logged as a warning, since the API may know models this
+// build does not, unless --strict-model is set. --max-tokens is then checked
+// against the output limit of the model.
+func resolveModel() error {
+	globalOpts.Model = models.Resolve(globalOpts.Model, globalOpts.ModelAliases)
+	if len(globalOpts.ModelFallbacks) > 0 {
+		resolved := make([]string, len(globalOpts.ModelFallbacks))
+		for i, m := range globalOpts.ModelFallbacks {
+			resolved[i] = models.Resolve(strings.TrimSpace(m), globalOpts.ModelAliases)
+		}
+		globalOpts.ModelFallbacks = resolved
+	}
+	if err := models.Check(globalOpts.Model); err != nil {
+		if globalOpts.StrictModel {
+			return clerrors.NewValidationError("model", globalOpts.Model, err.Error())
+		}
+		warnings.Add(warnings.CodeUnknownModel, err.Error(),
+			"run pplx models to list the known models, or --strict-model to reject unknown ones")
+	}
+	return resolveMaxTokens()
+}
+
+// validateResponseFormats reads --json-schema-file, then validates response
+// format options and model compatibility.
 func validateResponseFormats() error {
+	if err := resolveJSONSchemaFile(); err != nil {
+		return err
+	}
+
 	// Validate response format mutual exclusivity
 	if globalOpts.ResponseFormatJSONSchema != "" && globalOpts.ResponseFormatRegex != "" {
 		return clerrors.NewValidationError("response-format", "",
@@ -489,136 +650,515 @@ func validateResponseFormats() error {
 			"response formats (JSON schema and regex) are only supported by sonar models")
 	}
 
-	return nil
+	if globalOpts.ResponseFormatJSONSchema != "" {
+		if _, _, err := validation.ValidateJSONSchema(globalOpts.ResponseFormatJSONSchema); err != nil {
+			return clerrors.NewValidationError("response-format-json-schema",
+				globalOpts.ResponseFormatJSONSchema, err.Error())
+		}
+	}
+
+	return resolveStreamFormat()
 }
 
-// buildAllOptions builds all completion request options using the builder aggregation pattern.
-// Each builder contributes a subset of options for its concern (search, format, dates, etc.),
-// making individual builders testable and allowing conditional inclusion based on flags.
-//
-// Design rationale: Separating builders by concern (rather than one monolithic function)
-// improves maintainability and testability. Each builder can be tested independently,
-// and new option categories can be added without modifying existing builders.
+// buildAllOptions builds the completion request of the query with
+// pplx.BuildRequest, the request builder shared with the MCP server, chat
+// sessions and the library facade. validateInputs has already checked the
+// flags and resolved the conflicts between them, so the errors and notes
+// name the flags.
 func buildAllOptions() (*perplexity.CompletionRequest, error) {
-	// Build base options first - always required, no conditionals
-	// These establish the request foundation: model, messages, temperature, etc.
-	// Must come first as other builders may depend on model selection
-	baseOpts, err := buildBaseOptions()
+	msg, err := buildMessages()
 	if err != nil {
 		return nil, err
 	}
+	req := pplx.QueryRequest{
+		Messages:                 msg,
+		Model:                    globalOpts.Model,
+		ModelFallbacks:           globalOpts.ModelFallbacks,
+		FrequencyPenalty:         globalOpts.FrequencyPenalty,
+		MaxTokens:                globalOpts.MaxTokens,
+		PresencePenalty:          globalOpts.PresencePenalty,
+		Temperature:              globalOpts.Temperature,
+		TopK:                     globalOpts.TopK,
+		TopP:                     globalOpts.TopP,
+		SearchDomains:            globalOpts.SearchDomains,
+		ExcludeDomains:           globalOpts.ExcludeDomains,
+		SearchRecency:            globalOpts.SearchRecency,
+		LocationLat:              globalOpts.LocationLat,
+		LocationLon:              globalOpts.LocationLon,
+		LocationCountry:          globalOpts.LocationCountry,
+		ReturnImages:             globalOpts.ReturnImages,
+		ReturnRelated:            globalOpts.ReturnRelated || relatedMenuEnabled(), // the menu needs the questions
+		Stream:                   globalOpts.Stream,
+		ImageDomains:             globalOpts.ImageDomains,
+		ImageFormats:             globalOpts.ImageFormats,
+		ResponseFormatJSONSchema: globalOpts.ResponseFormatJSONSchema,
+		ResponseFormatRegex:      globalOpts.ResponseFormatRegex,
+		SearchMode:               globalOpts.SearchMode,
+		SearchContextSize:        globalOpts.SearchContextSize,
+		SearchAfterDate:          globalOpts.SearchAfterDate,
+		SearchBeforeDate:         globalOpts.SearchBeforeDate,
+		LastUpdatedAfter:         globalOpts.LastUpdatedAfter,
+		LastUpdatedBefore:        globalOpts.LastUpdatedBefore,
+		ReasoningEffort:          globalOpts.ReasoningEffort,
+		Language:                 globalOpts.Language,
+		ImageConflictPolicy:      globalOpts.ImageConflictPolicy,
+		StrictOptions:            globalOpts.StrictOptions,
+	}
+	return pplx.BuildRequest(req, warnings.Default) //nolint:wrapcheck // a *clerrors.ValidationError
+}
 
-	// Append-only builders: these never fail, safe to chain unconditionally
-	// Each builder checks its relevant flags and adds options if set
-	// Order doesn't matter for these - they're independent concerns
-	opts := baseOpts
-	opts = append(opts, buildSearchOptions()...)      // Domain filters, recency, location
-	opts = append(opts, buildResponseOptions()...)    // Return images, related questions
-	opts = append(opts, buildImageOptions()...)       // Image domain/format filters
+// handleStreamingResponse processes a streaming completion request.
+// The producer (perplexity.Client.StreamCompletion) runs in a worker goroutine and closes
+// its channel when finished; the main goroutine consumes events in streamCompletion and
+// renders them incrementally. Consuming in the main goroutine guarantees all rendering completes before
+// this function returns — no goroutine leak, no use of out after the caller returns.
+// Cancelling ctx stops the stream: the answer received so far is kept (see interruptedStream).
+func handleStreamingResponse(ctx context.Context, client *perplexity.Client, req *perplexity.CompletionRequest,
+	out io.Writer,
+) error {
+	renderer, err := render.ForStdout(globalOpts.Render)
+	if err != nil {
+		return clerrors.NewValidationError("render", globalOpts.Render, err.Error())
+	}
+	style, err := citationStyle()
+	if err != nil {
+		return err
+	}
+	filter, err := citationFilter()
+	if err != nil {
+		return err
+	}
 
-	// Error-returning builders: handle validation during option creation
-	// These validate input format/syntax before API call to provide fast feedback
+	// JSON mode skips incremental rendering and only collects the final
+	// response: JSON clients expect complete, valid JSON — not streaming
+	// fragments. --extract likewise needs the whole answer.
+	var stream *render.StreamRenderer
+	var smooth *output.SmoothWriter
+	var onFirst, onContinued func(*perplexity.CompletionResponse, string)
+	var plan *citations.Plan
+	if !globalOpts.OutputJSON && queryExtract == "" {
+		// Console mode: render each paragraph as soon as it is complete,
+		// paced by --stream-style smooth.
+		var streamOut io.Writer
+		streamOut, smooth = streamOutput(out)
+		stream = render.NewStreamRenderer(renderer, streamOut)
+		onContinued = func(_ *perplexity.CompletionResponse, answer string) {
+			if plan != nil {
+				answer = plan.Renumber(answer)
+			}
+			if err := stream.Update(answer); err != nil {
+				logger.Error("failed to render streaming content", "error", err)
+			}
+		}
+		onFirst = func(response *perplexity.CompletionResponse, answer string) {
+			// The sources arrive with the first event; the markers of every
+			// later event are renumbered for them.
+			first := *response
+			first.Choices = nil
+			if !filter.IsZero() {
+				plan = filter.Plan(citations.FromResponse(response))
+				plan.Apply(&first)
+			}
+			console.PrepareRenderer(renderer, &first, style)
+			onContinued(response, answer)
+		}
+	}
 
-	// Format options: validates JSON schema syntax and checks mutual exclusivity
-	// (can't have both JSON schema and regex at same time)
-	formatOpts, err := buildResponseFormatOptions()
+	// pplx.Send streams the requests marked as streamed.
+	req.Stream = true
+	start := time.Now()
+	lastResponse, err := sendWithFallback(ctx, client, req, usage.SourceQuery, joinedEvents("", onFirst))
+	if err == nil {
+		// Continuations keep streaming into the same renderer, joined to the
+		// answer so far; the citation markers keep the first sources.
+		lastResponse = continueAnswer(req, lastResponse, usage.SourceQuery,
+			func(next *perplexity.CompletionRequest, answer string) (*perplexity.CompletionResponse, error) {
+				return streamCompletion(ctx, client, next, answer, onContinued)
+			})
+	}
+	if stream != nil {
+		if err := stream.Flush(); err != nil {
+			logger.Error("failed to render streaming content", "error", err)
+		}
+	}
+	finishStream(smooth, ctx.Err() != nil)
+	if plan == nil && !filter.IsZero() && lastResponse != nil {
+		plan = filter.Plan(citations.FromResponse(lastResponse))
+	}
+	if plan != nil {
+		plan.Apply(lastResponse)
+	}
+	if ctx.Err() != nil {
+		if err != nil {
+			// Usage of a completed first answer is already recorded.
+			lastResponse = chat.PartialUsage(req, lastResponse)
+			usage.TrackPartial(usage.SourceQuery, lastResponse)
+		}
+		return interruptedStream(out, lastResponse, time.Since(start))
+	}
 	if err != nil {
-		return nil, err
+		return clerrors.NewAPIError("failed to send streaming request", retry.ClassifyTimeout(err))
+	}
+	logCompletion(req.Model, time.Since(start), lastResponse)
+	if _, err := output.FirstContent(lastResponse); err != nil {
+		return err //nolint:wrapcheck // already an APIError
 	}
-	opts = append(opts, formatOpts...)
 
-	// Date options: validates MM/DD/YYYY format before sending to API
-	// Catches malformed dates early rather than getting API error
-	dateOpts, err := buildDateFilterOptions()
-	if err != nil {
-		return nil, err
+	switch {
+	case queryExtract != "":
+		if err := writeExtracted(out, lastResponse, time.Since(start)); err != nil {
+			return err
+		}
+	case globalOpts.OutputJSON:
+		if err := writeJSONResult(out, lastResponse, time.Since(start)); err != nil {
+			logger.Error("failed to render response", "error", err)
+		}
+	default:
+		renderStreamedMetadata(out, lastResponse, renderer, style)
+	}
+	if err := verifyResponseFormat(lastResponse); err != nil {
+		return err
 	}
-	opts = append(opts, dateOpts...)
+	if err := saveResponseImages(lastResponse); err != nil {
+		return err
+	}
+	return followRelated(client, req, lastResponse)
+}
 
-	// Deep research options: appended last for clarity (order doesn't matter functionally)
-	// Keeps research-specific options isolated from standard query options
-	opts = append(opts, buildDeepResearchOptions()...)
+// streamCompletion sends req as a streaming request with
+// pplx.StreamCompletion and returns its last, complete event. onEvent, if
+// set, receives every event with the answer so far: the streamed text
+// joined to prefix, the answer req continues. When the stream fails or ctx
+// is cancelled, the last event received, if any, is returned with the error.
+func streamCompletion(ctx context.Context, client *perplexity.Client, req *perplexity.CompletionRequest,
+	prefix string, onEvent func(*perplexity.CompletionResponse, string),
+) (*perplexity.CompletionResponse, error) {
+	res, err := pplx.StreamCompletion(ctx, client, req, joinedEvents(prefix, onEvent))
+	return res, err //nolint:wrapcheck // wrapped by the caller
+}
 
-	// Create request and run final validation
-	// This checks the complete option set for conflicts that span multiple builders
-	// Example: response format requires sonar model (checked across base + format builders)
-	req := perplexity.NewCompletionRequest(opts...)
-	if err := req.Validate(); err != nil {
-		return nil, clerrors.NewValidationError("request", "", err.Error())
+// joinedEvents returns the event callback passing each event to onEvent
+// with its answer joined to prefix, or nil when onEvent is nil.
+func joinedEvents(prefix string, onEvent func(*perplexity.CompletionResponse, string),
+) func(*perplexity.CompletionResponse) {
+	if onEvent == nil {
+		return nil
 	}
+	return func(response *perplexity.CompletionResponse) {
+		onEvent(response, chat.JoinContinuation(prefix, response.GetLastContent()))
+	}
+}
 
-	return req, nil
+// sendWithFallback sends req with pplx.Send through the --fallback-models
+// chain, passing the events of a streamed answer to onEvent and recording
+// its usage under source. When another model answers, a warning names it
+// and req is switched to it, so that continuations of the answer use the
+// same model.
+func sendWithFallback(ctx context.Context, client *perplexity.Client, req *perplexity.CompletionRequest,
+	source string, onEvent func(*perplexity.CompletionResponse),
+) (*perplexity.CompletionResponse, error) {
+	result, err := pplx.Send(ctx, client, req, pplx.SendOptions{
+		Fallbacks: globalOpts.ModelFallbacks,
+		OnEvent:   onEvent,
+		Source:    source,
+	})
+	if err != nil {
+		return result.Response, err //nolint:wrapcheck // wrapped by the caller
+	}
+	if result.FellBack() {
+		warnings.Add(warnings.CodeModelFallback, result.Note(),
+			"set --model or defaults.model to a model that is available")
+	}
+	return result.Response, nil
 }
 
-// handleStreamingResponse processes a streaming completion request.
-// The producer (perplexity.Client.StreamCompletion) runs in a worker goroutine and closes
-// responseChannel when finished; the main goroutine consumes events and renders them
-// incrementally. Consuming in the main goroutine guarantees all rendering completes before
-// this function returns — no goroutine leak, no use of os.Stdout after the caller returns.
-func handleStreamingResponse(client *perplexity.Client, req *perplexity.CompletionRequest) error {
-	responseChannel := make(chan perplexity.CompletionResponse)
-	streamErrCh := make(chan error, 1)
-
-	go func() {
-		streamErrCh <- client.StreamCompletion(req, responseChannel)
-	}()
-
-	var lastResponse *perplexity.CompletionResponse
-	if globalOpts.OutputJSON {
-		// JSON mode: skip incremental rendering, just collect the final response.
-		// JSON clients expect complete, valid JSON — not streaming fragments.
-		for response := range responseChannel {
-			lastResponse = &response
-		}
-	} else {
-		// Console mode: render tokens incrementally for a ChatGPT-style UX.
-		renderer := console.NewStreamingRenderer(os.Stdout)
-		for response := range responseChannel {
-			if err := renderer.RenderIncremental(&response); err != nil {
-				logger.Error("failed to render streaming content", "error", err)
-			}
-			// Only the final chunk carries complete metadata (citations, images, related questions).
-			lastResponse = &response
+// sendOnce sends req with pplx.Send to its model alone, without fallback
+// models, leaving the tracking of its usage to the caller.
+func sendOnce(ctx context.Context, client *perplexity.Client, req *perplexity.CompletionRequest,
+) (*perplexity.CompletionResponse, error) {
+	result, err := pplx.Send(ctx, client, req, pplx.SendOptions{})
+	return result.Response, err //nolint:wrapcheck // wrapped by the caller
+}
+
+// interruptedStream ends a streamed query interrupted by Ctrl-C. The answer
+// received so far, res, has already been rendered to out in console mode;
+// the interrupted marker follows it. In JSON mode the partial result is
+// written so that scripts still get valid JSON. Returns an error wrapping
+// clerrors.ErrRequestInterrupted.
+func interruptedStream(out io.Writer, res *perplexity.CompletionResponse, elapsed time.Duration) error {
+	received := 0
+	if content, err := output.FirstContent(res); err == nil {
+		received = len(content)
+	}
+	switch {
+	case globalOpts.OutputJSON && queryExtract == "" && received > 0:
+		if err := writeJSONResult(out, res, elapsed); err != nil {
+			logger.Error("failed to render response", "error", err)
 		}
+	case !globalOpts.OutputJSON && queryExtract == "":
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, interruptedMarker)
 	}
+	return fmt.Errorf("%w: %d characters of the answer received", clerrors.ErrRequestInterrupted, received)
+}
 
-	if err := <-streamErrCh; err != nil {
-		return clerrors.NewAPIError("failed to send streaming request", err)
+// renderStreamedMetadata prints to out what follows an answer rendered while
+// streaming: footnote references, citations, images, and related questions.
+func renderStreamedMetadata(out io.Writer, response *perplexity.CompletionResponse, renderer *render.Renderer,
+	style citations.Style,
+) {
+	// Visual separation between streaming content and metadata sections.
+	fmt.Fprintln(out)
+	if style == citations.StyleFootnote {
+		sources := citations.FromResponse(response)
+		content, _ := output.FirstContent(response)
+		if refs := citations.Footnotes(content, sources); refs != "" {
+			fmt.Fprintln(out, renderer.Render(refs))
+		}
+	}
+	if err := console.RenderMetadataWithCitations(response, out, style); err != nil {
+		logger.Error("failed to render response", "error", err)
 	}
+	if err := renderSearches(out, response); err != nil {
+		logger.Error("failed to render response", "error", err)
+	}
+}
 
-	if lastResponse != nil {
-		if !globalOpts.OutputJSON {
-			// Visual separation between streaming content and metadata sections.
-			fmt.Println()
-		}
-		if err := console.RenderResponse(lastResponse, os.Stdout, globalOpts.OutputJSON); err != nil {
-			logger.Error("failed to render response", "error", err)
+// handleNonStreamingResponse processes a standard (non-streaming) completion request
+// and prints the answer to out. Shows a spinner while waiting for the response
+// (unless JSON output is requested). Cancelling ctx abandons the request; nothing
+// has been received to keep.
+func handleNonStreamingResponse(ctx context.Context, client *perplexity.Client,
+	req *perplexity.CompletionRequest, out io.Writer,
+) error {
+	var renderer *render.Renderer
+	if !globalOpts.OutputJSON {
+		var err error
+		if renderer, err = answerRenderer(); err != nil {
+			return clerrors.NewValidationError("render", globalOpts.Render, err.Error())
 		}
 	}
-	return nil
-}
+	style, err := citationStyle()
+	if err != nil {
+		return err
+	}
+	filter, err := citationFilter()
+	if err != nil {
+		return err
+	}
 
-// handleNonStreamingResponse processes a standard (non-streaming) completion request.
-// Shows a spinner while waiting for the response (unless JSON output is requested).
-func handleNonStreamingResponse(client *perplexity.Client, req *perplexity.CompletionRequest) error {
 	var spinnerInfo *pterm.SpinnerPrinter
-	if !globalOpts.OutputJSON {
+	if showSpinner() {
 		spinnerInfo, _ = pterm.DefaultSpinner.Start("Waiting for response from perplexity...")
 	}
 
-	res, err := client.SendCompletionRequest(req)
+	start := time.Now()
+	res, err := sendWithFallback(ctx, client, req, usage.SourceQuery, nil)
+	if err != nil && ctx.Err() != nil {
+		if spinnerInfo != nil {
+			spinnerInfo.Fail("Interrupted")
+		}
+		return fmt.Errorf("%w: %w", clerrors.ErrRequestInterrupted, err)
+	}
 	if err != nil {
-		return clerrors.NewAPIError("failed to send completion request", err)
+		return clerrors.NewAPIError("failed to send completion request", retry.ClassifyTimeout(err))
 	}
+	res = continueAnswer(req, res, usage.SourceQuery,
+		func(next *perplexity.CompletionRequest, _ string) (*perplexity.CompletionResponse, error) {
+			return sendOnce(ctx, client, next)
+		})
+	elapsed := time.Since(start)
+	logCompletion(req.Model, elapsed, res)
+	if _, err := output.Contents(res); err != nil {
+		if spinnerInfo != nil {
+			spinnerInfo.Fail("Empty response")
+		}
+		return err //nolint:wrapcheck // already an APIError
+	}
+	storeCachedResponse(res)
+	filter.Apply(res)
 
-	if !globalOpts.OutputJSON {
+	if spinnerInfo != nil {
 		spinnerInfo.Success("Response received")
 	}
 
-	err = console.RenderResponse(res, os.Stdout, globalOpts.OutputJSON)
+	if queryExtract != "" {
+		if err := writeExtracted(out, res, elapsed); err != nil {
+			return err
+		}
+		if err := verifyResponseFormat(res); err != nil {
+			return err
+		}
+		return saveResponseImages(res)
+	}
+	// The answer is rendered whole before it is printed, so that it can be
+	// cut to --max-lines or shown through the pager.
+	var answer strings.Builder
+	switch {
+	case globalOpts.OutputJSON:
+		err = writeJSONResult(out, res, elapsed)
+	case len(res.Choices) > 1:
+		err = console.RenderChoicesWithCitations(res, &answer, renderer, style)
+	default:
+		err = console.RenderAnswerWithCitations(res, &answer, renderer, style)
+	}
 	if err != nil {
 		return clerrors.NewIOError("failed to render response", err)
 	}
+	if !globalOpts.OutputJSON {
+		if err := renderSearches(&answer, res); err != nil {
+			return err
+		}
+		if err := writeAnswer(ctx, out, answer.String()); err != nil {
+			return err
+		}
+	}
+	if err := verifyResponseFormat(res); err != nil {
+		return err
+	}
+
+	if err := saveResponseImages(res); err != nil {
+		return err
+	}
+	return followRelated(client, req, res)
+}
+
+// logCompletion records a finished query at debug level.
+func logCompletion(model string, elapsed time.Duration, res *perplexity.CompletionResponse) {
+	if res == nil {
+		logger.Debug("query completed", "model", model, "duration", elapsed)
+		return
+	}
+	logger.Debug("query completed",
+		"model", model,
+		"duration", elapsed,
+		"prompt_tokens", res.Usage.PromptTokens,
+		"completion_tokens", res.Usage.CompletionTokens)
+}
+
+// noteOutput returns where user-facing notes are printed: stdout, or stderr
+// in batch mode, where stdout carries the JSON lines results.
+func noteOutput() io.Writer {
+	if queryBatchFile != "" {
+		return chatter(os.Stderr)
+	}
+	return chatter(os.Stdout)
+}
+
+// citationStyle parses --citations.
+func citationStyle() (citations.Style, error) {
+	style, err := citations.ParseStyle(globalOpts.Citations)
+	if err != nil {
+		return "", clerrors.NewValidationError("citations", globalOpts.Citations, err.Error())
+	}
+	return style, nil
+}
+
+// citationFilter returns the filter of --citation-max-per-domain and
+// --citation-exclude-domains.
+func citationFilter() (citations.Filter, error) {
+	if globalOpts.CitationMaxPerDomain < 0 {
+		return citations.Filter{}, clerrors.NewValidationError("citation-max-per-domain",
+			strconv.Itoa(globalOpts.CitationMaxPerDomain), "must be 0 or more")
+	}
+	for _, domain := range globalOpts.CitationExcludeDomains {
+		if err := validation.ValidateDomain(strings.TrimSpace(domain)); err != nil {
+			return citations.Filter{}, clerrors.NewValidationError("citation-exclude-domains", domain, err.Error())
+		}
+	}
+	return citations.Filter{
+		MaxPerDomain:   globalOpts.CitationMaxPerDomain,
+		ExcludeDomains: globalOpts.CitationExcludeDomains,
+	}, nil
+}
+
+// streamStyle parses --stream-style.
+func streamStyle() (output.StreamStyle, error) {
+	style, err := output.ParseStreamStyle(globalOpts.StreamStyle)
+	if err != nil {
+		return "", clerrors.NewValidationError("stream-style", globalOpts.StreamStyle, err.Error())
+	}
+	return style, nil
+}
+
+// streamOutput returns the writer a streamed answer is rendered to. With
+// --stream-style smooth and out a terminal, it is a SmoothWriter on out,
+// also returned so that the caller closes it once the stream ends; otherwise
+// it is out itself, since paced output only slows down pipes and files.
+func streamOutput(out io.Writer) (io.Writer, *output.SmoothWriter) {
+	style, err := streamStyle()
+	if err != nil || style != output.StreamSmooth {
+		return out, nil
+	}
+	if f, ok := out.(*os.File); !ok || !render.IsTerminal(f) {
+		return out, nil
+	}
+	smooth := output.NewSmoothWriter(out, globalOpts.StreamCPS)
+	return smooth, smooth
+}
+
+// finishStream ends the paced output of a streamed answer: the rest is
+// written at the same pace, or at once when the stream was interrupted.
+func finishStream(smooth *output.SmoothWriter, interrupted bool) {
+	if smooth == nil {
+		return
+	}
+	if interrupted {
+		_ = smooth.Flush()
+	}
+	if err := smooth.Close(); err != nil {
+		logger.Error("failed to render streaming content", "error", err)
+	}
+}
 
+// writeJSONResult prints res to out as the stable JSON output, limited to --json-fields.
+func writeJSONResult(out io.Writer, res *perplexity.CompletionResponse, elapsed time.Duration) error {
+	fields, err := output.ParseFields(globalOpts.JSONFields)
+	if err != nil {
+		return clerrors.NewValidationError("json-fields", globalOpts.JSONFields, err.Error())
+	}
+	return jsonResult(res, elapsed).Write(out, fields) //nolint:wrapcheck // wrapped by caller
+}
+
+// jsonResult converts res into the stable JSON output, with format_match
+// set when --response-format-regex was given and could be checked. The
+// warnings not yet reported go in its warnings field rather than to stderr.
+func jsonResult(res *perplexity.CompletionResponse, elapsed time.Duration) *output.Result {
+	result := output.FromResponse(res, elapsed)
+	if m, err := regexFormatMatch(res); err == nil && m != nil {
+		result.FormatMatch = &m.Matched
+	}
+	result.Warnings = warnings.Default.Take()
+	return result
+}
+
+// writeExtracted prints to out the value at the --extract path of the answer,
+// or of the JSON output object with --json. When the answer is not JSON or the
+// path does not exist, the content is printed to stderr for debugging and a
+// validation error is returned.
+func writeExtracted(out io.Writer, res *perplexity.CompletionResponse, elapsed time.Duration) error {
+	content, err := output.FirstContent(res)
+	if err != nil {
+		return err //nolint:wrapcheck // already an APIError
+	}
+	data := []byte(content)
+	if globalOpts.OutputJSON {
+		fields, err := output.ParseFields(globalOpts.JSONFields)
+		if err != nil {
+			return clerrors.NewValidationError("json-fields", globalOpts.JSONFields, err.Error())
+		}
+		if data, err = jsonResult(res, elapsed).Marshal(fields); err != nil {
+			return clerrors.NewIOError("failed to render response", err)
+		}
+	}
+
+	value, err := output.Extract(data, queryExtract)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, string(data))
+		return clerrors.NewValidationError("extract", queryExtract, err.Error())
+	}
+	if _, err := fmt.Fprintln(out, value); err != nil {
+		return clerrors.NewIOError("failed to write extracted value", err)
+	}
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -77,7 +78,7 @@ func TestHandleNonStreamingResponse_Unauthorized(t *testing.T) {
 	client := perplexity.NewClient("invalid-key")
 	client.SetEndpoint(srv.URL)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err == nil {
 		t.Fatal("expected error for 401 response, got nil")
 	}
@@ -102,7 +103,7 @@ func TestHandleNonStreamingResponse_RateLimited(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	client.SetEndpoint(srv.URL)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err == nil {
 		t.Fatal("expected error for 429 response, got nil")
 	}
@@ -127,7 +128,7 @@ func TestHandleNonStreamingResponse_InternalServerError(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	client.SetEndpoint(srv.URL)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err == nil {
 		t.Fatal("expected error for 500 response, got nil")
 	}
@@ -152,7 +153,7 @@ func TestHandleNonStreamingResponse_ServiceUnavailable(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	client.SetEndpoint(srv.URL)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err == nil {
 		t.Fatal("expected error for 503 response, got nil")
 	}
@@ -186,7 +187,7 @@ func TestHandleNonStreamingResponse_NetworkTimeout(t *testing.T) {
 	client.SetEndpoint(srv.URL)
 	client.SetHTTPTimeout(50 * time.Millisecond)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err == nil {
 		t.Fatal("expected error for network timeout, got nil")
 	}
@@ -211,7 +212,7 @@ func TestHandleNonStreamingResponse_MalformedResponse(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	client.SetEndpoint(srv.URL)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err == nil {
 		t.Fatal("expected error for malformed JSON response, got nil")
 	}
@@ -235,7 +236,7 @@ func TestHandleNonStreamingResponse_InvalidAPIKey_ErrorMessage(t *testing.T) {
 	client := perplexity.NewClient("bad-key")
 	client.SetEndpoint(srv.URL)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err == nil {
 		t.Fatal("expected error for invalid API key, got nil")
 	}
@@ -260,7 +261,7 @@ func TestHandleNonStreamingResponse_Success(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	client.SetEndpoint(srv.URL)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err != nil {
 		t.Errorf("expected nil error for successful response, got: %v", err)
 	}
@@ -280,7 +281,7 @@ func TestHandleNonStreamingResponse_EmptyResponseBody(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	client.SetEndpoint(srv.URL)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err == nil {
 		t.Fatal("expected error for empty response body, got nil")
 	}
@@ -303,7 +304,7 @@ func TestHandleNonStreamingResponse_DeadEndpoint(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	client.SetEndpoint(srv.URL)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err == nil {
 		t.Fatal("expected error for dead endpoint, got nil")
 	}
@@ -349,7 +350,7 @@ func TestHandleNonStreamingResponse_AllErrorsAreAPIErrors(t *testing.T) {
 			client := perplexity.NewClient("test-key")
 			client.SetEndpoint(srv.URL)
 
-			err := handleNonStreamingResponse(client, newTestRequest())
+			err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 			if err == nil {
 				t.Fatalf("expected error for HTTP %d, got nil", code)
 			}
@@ -410,7 +411,7 @@ func TestDeadlineExceeded(t *testing.T) {
 	client.SetEndpoint(srv.URL)
 	client.SetHTTPTimeout(10 * time.Millisecond)
 
-	err := handleNonStreamingResponse(client, newTestRequest())
+	err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
 	if err == nil {
 		t.Fatal("expected timeout error, got nil")
 	}
@@ -465,3 +466,80 @@ func TestAPIErrorType_WithStatusCode(t *testing.T) {
 		t.Error("APIError.Error() returned empty string")
 	}
 }
+
+// TestHandleStreamingResponse_PrintsDeltas verifies that overlapping
+// cumulative SSE events are printed once each, and that a revision of
+// earlier text redraws the line instead of repeating the answer.
+func TestHandleStreamingResponse_PrintsDeltas(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	orig := *globalOpts
+	t.Cleanup(func() { *globalOpts = orig })
+	globalOpts.Render = "raw"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, answer := range []string{"Hel", "Hello", "Hello", "Hello wor", "Hello World", "Hello World!"} {
+			event, _ := json.Marshal(map[string]any{
+				"id": "stream-id", "model": "sonar",
+				"choices": []map[string]any{{"index": 0,
+					"message": map[string]string{"role": "assistant", "content": answer}}},
+			})
+			_, _ = w.Write([]byte("data: " + string(event) + "\n\n"))
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	out, err := runCapturingStdout(t, func() error {
+		return handleStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+	})
+	if err != nil {
+		t.Fatalf("handleStreamingResponse() error: %v", err)
+	}
+	if want := "Hello wor\rHello World!\n"; !strings.HasPrefix(out, want) {
+		t.Errorf("output = %q, want it to start with %q", out, want)
+	}
+}
+
+// TestHandleStreamingResponse_Interrupted verifies that cancelling the
+// context mid-stream keeps the partial answer, prints the interrupted
+// marker, and reports ErrRequestInterrupted.
+func TestHandleStreamingResponse_Interrupted(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	orig := *globalOpts
+	t.Cleanup(func() { *globalOpts = orig })
+	globalOpts.Render = "raw"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		event, _ := json.Marshal(map[string]any{
+			"id": "stream-id", "model": "sonar",
+			"choices": []map[string]any{{"index": 0,
+				"message": map[string]string{"role": "assistant", "content": "Partial answer"}}},
+		})
+		_, _ = w.Write([]byte("data: " + string(event) + "\n\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	out, err := runCapturingStdout(t, func() error {
+		return handleStreamingResponse(ctx, client, newTestRequest(), os.Stdout)
+	})
+	if !errors.Is(err, clerrors.ErrRequestInterrupted) {
+		t.Fatalf("handleStreamingResponse() error = %v, want ErrRequestInterrupted", err)
+	}
+	if !strings.Contains(out, "Partial answer") || !strings.HasSuffix(out, interruptedMarker+"\n") {
+		t.Errorf("output = %q, want the partial answer then %q", out, interruptedMarker)
+	}
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/batch"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/ratelimit"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/spf13/cobra"
+)
+
+// batchOutputPerms are the permissions of a --output file; results include
+// the prompts, which may be private, and answers may be too.
+const batchOutputPerms = 0o600
+
+// Batch mode flags of the query command.
+var (
+	queryBatchFile   string
+	queryOutput      string
+	queryConcurrency int
+	queryRateLimit   int
+	queryDryRun      bool
+)
+
+// addBatchFlags registers the batch mode flags on the query command.
+func addBatchFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&queryBatchFile, "batch", "",
+		"Run every prompt of a JSON lines file (- for stdin) and write JSON lines results")
+	cmd.Flags().StringVarP(&queryOutput, "output", "o", "",
+		"Write the answer, or the batch results, to a file instead of stdout")
+	cmd.Flags().IntVar(&queryConcurrency, "concurrency", batch.DefaultConcurrency,
+		"Maximum number of batch, --repeat, or --compare requests in flight")
+	cmd.Flags().IntVar(&queryRateLimit, "rate-limit", 0,
+		"Maximum number of batch requests started per minute (0 = api.requests_per_minute, unlimited when unset)")
+	cmd.Flags().BoolVar(&queryDryRun, "dry-run", false,
+		"Print the resolved request (with --batch: validate every line) without calling the API")
+}
+
+// runBatch implements `pplx query --batch`. Flags and configuration provide
+// the defaults of every line; each line may override them.
+func runBatch(cmd *cobra.Command, cfg *config.ConfigData) error {
+	if queryConcurrency < 1 {
+		return clerrors.NewValidationError("concurrency", fmt.Sprint(queryConcurrency), "must be at least 1")
+	}
+	if queryRateLimit < 0 {
+		return clerrors.NewValidationError("rate-limit", fmt.Sprint(queryRateLimit), "cannot be negative")
+	}
+	if queryPromptTemplate != "" {
+		return clerrors.NewValidationError("prompt-template", queryPromptTemplate, "cannot be combined with --batch")
+	}
+	if queryCache || queryNoCache {
+		return clerrors.NewValidationError("cache", "", "cannot be combined with --batch")
+	}
+	if queryMessagesFile != "" {
+		return clerrors.NewValidationError("messages-file", queryMessagesFile, "cannot be combined with --batch")
+	}
+	if queryExtract != "" {
+		return clerrors.NewValidationError("extract", queryExtract, "cannot be combined with --batch")
+	}
+	if queryChoices != 1 {
+		return clerrors.NewValidationError("choices", strconv.Itoa(queryChoices), "cannot be combined with --batch")
+	}
+	if querySaveImages != "" {
+		return clerrors.NewValidationError("save-images", querySaveImages, "cannot be combined with --batch")
+	}
+	if queryRepeat > 1 {
+		return clerrors.NewValidationError("repeat", strconv.Itoa(queryRepeat), "cannot be combined with --batch")
+	}
+
+	inputs, err := readBatchFile(queryBatchFile, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return clerrors.NewValidationError("batch", queryBatchFile, "no prompts found")
+	}
+	applyDefaultSystemPrompt(cfg)
+	jobs := buildBatchJobs(inputs)
+
+	if queryDryRun {
+		return reportBatchDryRun(cmd.OutOrStdout(), jobs)
+	}
+
+	apiKey, err := resolveAPIKey(cfg)
+	if err != nil {
+		return err
+	}
+	client, err := newAPIClient(apiKey)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if queryOutput != "" {
+		f, err := os.OpenFile(queryOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, batchOutputPerms) // #nosec G304
+		if err != nil {
+			return clerrors.NewIOError("cannot create batch output file", err)
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+
+	parent := cmd.Context()
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(parent, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	stderr := chatter(cmd.ErrOrStderr())
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			// Restore default handling so a second Ctrl-C exits immediately.
+			stop()
+			fmt.Fprintln(stderr, "Interrupted: finishing requests in flight...")
+		}
+	}()
+
+	send := func(ctx context.Context, req *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error) {
+		res, err := sendWithFallback(ctx, client, req, usage.SourceBatch, nil)
+		if err != nil {
+			return nil, retry.ClassifyTimeout(err) //nolint:wrapcheck // recorded verbatim in the batch output
+		}
+		res = continueAnswer(req, res, usage.SourceBatch,
+			func(next *perplexity.CompletionRequest, _ string) (*perplexity.CompletionResponse, error) {
+				return sendOnce(ctx, client, next)
+			})
+		if _, err := output.FirstContent(res); err != nil {
+			return nil, err //nolint:wrapcheck // recorded verbatim in the batch output
+		}
+		return res, nil
+	}
+
+	// --rate-limit takes precedence over the configured rate limit.
+	opts := batch.Options{Concurrency: queryConcurrency, RequestsPerMinute: queryRateLimit}
+	if queryRateLimit == 0 {
+		opts.Limiter = ratelimit.New(cfg.API.RequestsPerMinute, cfg.API.Burst)
+	}
+	summary, err := batch.Run(ctx, jobs, send, out, opts)
+	if err != nil {
+		return clerrors.NewIOError("failed to write batch results", err)
+	}
+
+	fmt.Fprintf(stderr, "Completed %d of %d lines (%d failed)\n", summary.Completed, summary.Total, summary.Failed)
+	if summary.Interrupted() {
+		return fmt.Errorf("%w: completed %d of %d lines", clerrors.ErrBatchInterrupted,
+			summary.Completed, summary.Total)
+	}
+	return nil
+}
+
+// readBatchFile parses the batch file at path, or stdin when path is "-".
+func readBatchFile(path string, stdin io.Reader) ([]batch.Input, error) {
+	if path == "-" {
+		inputs, err := batch.Read(stdin)
+		if err != nil {
+			return nil, clerrors.NewIOError("cannot read batch from stdin", err)
+		}
+		return inputs, nil
+	}
+
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, clerrors.NewValidationError("batch", path, "file not found")
+		}
+		return nil, clerrors.NewIOError("cannot open batch file", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	inputs, err := batch.Read(f)
+	if err != nil {
+		return nil, clerrors.NewIOError("cannot read batch file", err)
+	}
+	return inputs, nil
+}
+
+// buildBatchJobs validates every input and builds its request. Lines are
+// applied one at a time on top of the current options, through the same
+// validation and request builders as a single query; globalOpts is restored
+// afterwards.
+func buildBatchJobs(inputs []batch.Input) []batch.Job {
+	saved := *globalOpts
+	defer func() { *globalOpts = saved }()
+
+	base := saved
+	// Results are collected whole; streaming does not apply to batches.
+	base.Stream = false
+
+	jobs := make([]batch.Job, 0, len(inputs))
+	for _, in := range inputs {
+		job := batch.Job{Line: in.Number, Err: in.Err}
+		if job.Err == nil {
+			*globalOpts = base
+			in.Line.Apply(globalOpts)
+			job.Err = validateInputs()
+			if job.Err == nil {
+				job.Request, job.Err = buildAllOptions()
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// reportBatchDryRun lists the invalid lines of a batch and returns
+// clerrors.ErrBatchValidationFailed when there are any.
+func reportBatchDryRun(out io.Writer, jobs []batch.Job) error {
+	invalid := 0
+	for _, job := range jobs {
+		if job.Err != nil {
+			invalid++
+			fmt.Fprintf(out, "line %d: %v\n", job.Line, job.Err)
+		}
+	}
+	fmt.Fprintf(out, "%d of %d lines valid\n", len(jobs)-invalid, len(jobs))
+	if invalid > 0 {
+		return fmt.Errorf("%w: %d invalid line(s)", clerrors.ErrBatchValidationFailed, invalid)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/batch"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+// setBatchFlags sets the batch flags for a test and restores them afterwards.
+func setBatchFlags(t *testing.T, file string, dryRun bool) {
+	t.Helper()
+	oldFile, oldDryRun, oldConcurrency := queryBatchFile, queryDryRun, queryConcurrency
+	oldOpts := *globalOpts
+	queryBatchFile, queryDryRun, queryConcurrency = file, dryRun, 2
+	t.Cleanup(func() {
+		queryBatchFile, queryDryRun, queryConcurrency = oldFile, oldDryRun, oldConcurrency
+		*globalOpts = oldOpts
+	})
+}
+
+func TestRunBatch_DryRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompts.jsonl")
+	content := strings.Join([]string{
+		`{"user_prompt": "What is Go?"}`,
+		`{"user_prompt": "Recent news", "search_recency": "fortnight"}`,
+		`{"user_prompt": "Dated", "search_after_date": "2025-01-31"}`,
+		`{"prompt": "wrong key"}`,
+	}, "\n")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setBatchFlags(t, path, true)
+	t.Setenv("PPLX_API_KEY", "")
+
+	var out bytes.Buffer
+	queryCmd.SetOut(&out)
+	t.Cleanup(func() { queryCmd.SetOut(nil) })
+
+	err := runBatch(queryCmd, config.NewConfigData())
+	if !errors.Is(err, clerrors.ErrBatchValidationFailed) {
+		t.Fatalf("runBatch() error = %v, want ErrBatchValidationFailed", err)
+	}
+
+	report := out.String()
+	for _, want := range []string{"line 2:", "search-recency", "line 4:", "2 of 4 lines valid"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "line 1:") || strings.Contains(report, "line 3:") {
+		t.Errorf("valid lines reported as invalid:\n%s", report)
+	}
+}
+
+func TestRunBatch_Validation(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		setBatchFlags(t, filepath.Join(t.TempDir(), "missing.jsonl"), true)
+		var validationErr *clerrors.ValidationError
+		if err := runBatch(queryCmd, config.NewConfigData()); !errors.As(err, &validationErr) {
+			t.Errorf("runBatch() error = %v, want ValidationError", err)
+		}
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "empty.jsonl")
+		if err := os.WriteFile(path, []byte("\n\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		setBatchFlags(t, path, true)
+		var validationErr *clerrors.ValidationError
+		if err := runBatch(queryCmd, config.NewConfigData()); !errors.As(err, &validationErr) {
+			t.Errorf("runBatch() error = %v, want ValidationError", err)
+		}
+	})
+
+	t.Run("invalid concurrency", func(t *testing.T) {
+		setBatchFlags(t, "prompts.jsonl", true)
+		queryConcurrency = 0
+		var validationErr *clerrors.ValidationError
+		if err := runBatch(queryCmd, config.NewConfigData()); !errors.As(err, &validationErr) {
+			t.Errorf("runBatch() error = %v, want ValidationError", err)
+		}
+	})
+}
+
+func TestBuildBatchJobs_RestoresGlobalOptions(t *testing.T) {
+	setBatchFlags(t, "prompts.jsonl", true)
+	globalOpts.Model = "sonar"
+	globalOpts.Stream = true
+	globalOpts.UserPrompt = ""
+
+	model := "sonar-pro"
+	jobs := buildBatchJobs(batchInputs(t, `{"user_prompt": "a", "model": "sonar-pro"}`))
+	if jobs[0].Err != nil {
+		t.Fatalf("unexpected error: %v", jobs[0].Err)
+	}
+	if jobs[0].Request.Model != model || jobs[0].Request.Stream {
+		t.Errorf("request model=%q stream=%v, want %q without streaming",
+			jobs[0].Request.Model, jobs[0].Request.Stream, model)
+	}
+	if globalOpts.Model != "sonar" || !globalOpts.Stream || globalOpts.UserPrompt != "" {
+		t.Errorf("globalOpts not restored: %+v", globalOpts)
+	}
+}
+
+// batchInputs parses JSON lines for a test.
+func batchInputs(t *testing.T, lines ...string) []batch.Input {
+	t.Helper()
+	inputs, err := batch.Read(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inputs
+}
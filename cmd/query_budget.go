@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/usage"
+)
+
+// queryForce is --force: send the query even when it would exceed
+// api.daily_budget_usd.
+var queryForce bool
+
+// capMaxTokens lowers the max_tokens of req to api.per_request_max_tokens.
+func capMaxTokens(cfg *config.ConfigData, req *perplexity.CompletionRequest) {
+	if limit := cfg.API.PerRequestMaxTokens; limit > 0 && req.MaxTokens > limit {
+		req.MaxTokens = limit
+	}
+}
+
+// checkQueryBudget refuses req with a *clerrors.BudgetExceededError when its
+// projected cost, from its estimated prompt and max_tokens and times
+// --repeat, or summed over the --compare models, would take
+// today's spending over api.daily_budget_usd. With --force the query is
+// sent anyway, after a note.
+func checkQueryBudget(cfg *config.ConfigData, req *perplexity.CompletionRequest) error {
+	if cfg.API.DailyBudgetUSD <= 0 {
+		return nil
+	}
+	projected, _ := usage.ProjectCost(req.Model, estimateRequestTokens(req), req.MaxTokens)
+	projected *= float64(repeatCount())
+	if len(queryCompare) > 0 {
+		projected = 0
+		for _, model := range queryCompare {
+			cost, _ := usage.ProjectCost(model, estimateRequestTokens(req), req.MaxTokens)
+			projected += cost
+		}
+	}
+	err := usage.CheckBudget(cfg.API.DailyBudgetUSD, projected)
+	var budgetErr *clerrors.BudgetExceededError
+	switch {
+	case err == nil:
+		return nil
+	case !errors.As(err, &budgetErr):
+		// An unreadable usage log must not block queries.
+		fmt.Fprintf(noteOutput(), "Note: daily budget not checked: %v\n", err)
+		return nil
+	case queryForce:
+		fmt.Fprintf(noteOutput(), "Note: %v; sending anyway (--force)\n", err)
+		return nil
+	}
+	return err //nolint:wrapcheck // typed budget error
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+func TestCheckQueryBudget(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { queryForce = false })
+
+	cfg := config.NewConfigData()
+	req := newTestRequest()
+
+	if err := checkQueryBudget(cfg, req); err != nil {
+		t.Errorf("checkQueryBudget() without a budget error = %v", err)
+	}
+
+	// Any sonar request costs more than its $0.005 request fee.
+	cfg.API.DailyBudgetUSD = 0.001
+	err := checkQueryBudget(cfg, req)
+	var budgetErr *clerrors.BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("checkQueryBudget() error = %v, want BudgetExceededError", err)
+	}
+	if got := getExitCode(err); got != exitCodeBudget {
+		t.Errorf("exit code = %d, want %d", got, exitCodeBudget)
+	}
+
+	queryForce = true
+	globalOpts.Quiet = true
+	if err := checkQueryBudget(cfg, req); err != nil {
+		t.Errorf("checkQueryBudget() with --force error = %v", err)
+	}
+}
+
+func TestCapMaxTokens(t *testing.T) {
+	cfg := config.NewConfigData()
+	req := newTestRequest()
+	req.MaxTokens = 4000
+
+	capMaxTokens(cfg, req)
+	if req.MaxTokens != 4000 {
+		t.Errorf("MaxTokens without a cap = %d, want 4000", req.MaxTokens)
+	}
+	cfg.API.PerRequestMaxTokens = 1500
+	capMaxTokens(cfg, req)
+	if req.MaxTokens != 1500 {
+		t.Errorf("MaxTokens = %d, want 1500", req.MaxTokens)
+	}
+	req.MaxTokens = 500
+	capMaxTokens(cfg, req)
+	if req.MaxTokens != 500 {
+		t.Errorf("MaxTokens under the cap = %d, want 500", req.MaxTokens)
+	}
+}
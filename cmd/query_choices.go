@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// maxChoices bounds --choices, since every candidate is billed.
+const maxChoices = 10
+
+// queryChoices is the --choices number of candidate answers.
+var queryChoices = 1
+
+// validateChoices checks --choices. Several candidates cannot be combined
+// with --extract, which reads a single answer.
+func validateChoices() error {
+	if queryChoices < 1 || queryChoices > maxChoices {
+		return clerrors.NewValidationError("choices", strconv.Itoa(queryChoices),
+			fmt.Sprintf("must be between 1 and %d", maxChoices))
+	}
+	if queryChoices > 1 && queryExtract != "" {
+		return clerrors.NewValidationError("choices", strconv.Itoa(queryChoices), "cannot be combined with --extract")
+	}
+	return nil
+}
+
+// choicesFields returns the request fields asking for --choices candidates,
+// or nil for the default single answer. The client has no field for n, so
+// they are added to the request body by requestFieldsTransport.
+func choicesFields() map[string]any {
+	if queryChoices <= 1 {
+		return nil
+	}
+	return map[string]any{"n": queryChoices}
+}
+
+// choicesTransport returns the transport wrappers for newAPIClient that send
+// --choices, or none for a single answer.
+func choicesTransport() []func(http.RoundTripper) http.RoundTripper {
+	fields := choicesFields()
+	if fields == nil {
+		return nil
+	}
+	return []func(http.RoundTripper) http.RoundTripper{
+		func(base http.RoundTripper) http.RoundTripper {
+			return requestFieldsTransport{base: base, fields: fields}
+		},
+	}
+}
+
+// requestFieldsTransport adds fields to the JSON body of every POST request.
+type requestFieldsTransport struct {
+	base   http.RoundTripper
+	fields map[string]any
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t requestFieldsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.Body == nil {
+		return t.base.RoundTrip(req) //nolint:wrapcheck // transparent transport
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if body, err = addRequestFields(body, t.fields); err != nil {
+		return nil, err
+	}
+
+	next := req.Clone(req.Context())
+	next.Body = io.NopCloser(bytes.NewReader(body))
+	next.ContentLength = int64(len(body))
+	next.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return t.base.RoundTrip(next) //nolint:wrapcheck // transparent transport
+}
+
+// addRequestFields returns the JSON object body with fields set.
+func addRequestFields(body []byte, fields map[string]any) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, fmt.Errorf("failed to decode request body: %w", err)
+	}
+	for k, v := range fields {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request field %s: %w", k, err)
+		}
+		obj[k] = raw
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request body: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/retry"
+)
+
+// choicesResponseJSON returns a completion response with one choice per content.
+func choicesResponseJSON(contents ...string) string {
+	choices := make([]map[string]any, len(contents))
+	for i, c := range contents {
+		choices[i] = map[string]any{
+			"index": i, "finish_reason": "stop",
+			"message": map[string]string{"role": "assistant", "content": c},
+		}
+	}
+	b, _ := json.Marshal(map[string]any{"id": "test-id", "model": "sonar", "choices": choices})
+	return string(b)
+}
+
+func TestHandleNonStreamingResponse_EmptyResponses(t *testing.T) {
+	disableSpinner(t)
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{"zero choices", choicesResponseJSON(), clerrors.ErrNoChoices},
+		{"blank answer", choicesResponseJSON("  "), clerrors.ErrBlankAnswer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			client := perplexity.NewClient("test-key")
+			client.SetEndpoint(srv.URL)
+
+			err := handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+			var apiErr *clerrors.APIError
+			if !errors.As(err, &apiErr) || !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want an APIError wrapping %v", err, tt.wantErr)
+			}
+			if got := getExitCode(err); got != exitCodeAPI {
+				t.Errorf("exit code = %d, want %d", got, exitCodeAPI)
+			}
+		})
+	}
+}
+
+func TestHandleNonStreamingResponse_Choices(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	queryChoices = 3
+	t.Cleanup(func() { queryChoices = 1 })
+
+	var n float64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		n, _ = body["n"].(float64)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(choicesResponseJSON("First answer.", "Second answer.", "Third answer.")))
+	}))
+	defer srv.Close()
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	retry.Configure(client, retry.NewPolicy(0, 0), retry.Timeouts{}, choicesTransport()...)
+
+	globalOpts.OutputJSON = false
+	globalOpts.Render = "plain"
+	out, err := runCapturingStdout(t, func() error {
+		return handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+	})
+	if err != nil {
+		t.Fatalf("handleNonStreamingResponse() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("request n = %v, want 3", n)
+	}
+	for _, want := range []string{"Choice 1 of 3", "First answer.", "Choice 3 of 3", "Third answer."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	globalOpts.OutputJSON = true
+	out, err = runCapturingStdout(t, func() error {
+		return handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+	})
+	if err != nil {
+		t.Fatalf("handleNonStreamingResponse() with --json error = %v", err)
+	}
+	var doc struct {
+		Content string   `json:"content"`
+		Choices []string `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, out)
+	}
+	if doc.Content != "First answer." || len(doc.Choices) != 3 || doc.Choices[1] != "Second answer." {
+		t.Errorf("JSON output = %+v, want every choice", doc)
+	}
+}
+
+func TestValidateChoices(t *testing.T) {
+	t.Cleanup(func() { queryChoices, queryExtract = 1, "" })
+
+	for _, tt := range []struct {
+		choices int
+		extract string
+		wantErr bool
+	}{
+		{1, "", false},
+		{10, "", false},
+		{0, "", true},
+		{11, "", true},
+		{2, "a.b", true},
+		{1, "a.b", false},
+	} {
+		queryChoices, queryExtract = tt.choices, tt.extract
+		var verr *clerrors.ValidationError
+		if err := validateChoices(); errors.As(err, &verr) != tt.wantErr {
+			t.Errorf("validateChoices() with %d choices and extract %q = %v, want error %v",
+				tt.choices, tt.extract, err, tt.wantErr)
+		}
+	}
+}
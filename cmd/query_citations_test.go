@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+// citedServer answers with content citing three sources of a.example, one
+// of b.example and one of spam.example, streamed a word at a time when the
+// request asks for it.
+func citedServer(t *testing.T, id, content string) *perplexity.Client {
+	t.Helper()
+	results := []map[string]string{
+		{"title": "A1", "url": "https://a.example/1"},
+		{"title": "Spam", "url": "https://spam.example/x"},
+		{"title": "A2", "url": "https://a.example/2"},
+		{"title": "B", "url": "https://b.example"},
+		{"title": "A3", "url": "https://www.a.example/3"},
+	}
+	event := func(text string) []byte {
+		data, _ := json.Marshal(map[string]any{
+			"id": id, "model": "sonar", "search_results": results,
+			"choices": []map[string]any{{"index": 0, "finish_reason": "stop",
+				"message": map[string]string{"role": "assistant", "content": text}}},
+		})
+		return data
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body perplexity.CompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if !body.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(event(content))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		words := strings.SplitAfter(content, " ")
+		for i := range words {
+			_, _ = w.Write([]byte("data: " + string(event(strings.Join(words[:i+1], ""))) + "\n\n"))
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	t.Cleanup(srv.Close)
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	return client
+}
+
+const citedAnswer = "Rivers flow[1][3] to the sea [2] and evaporate [4][5]."
+
+func TestQuery_CitationFilter(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		name := "non-streaming"
+		if stream {
+			name = "streaming"
+		}
+		t.Run(name, func(t *testing.T) {
+			withGlobalOpts(t)
+			t.Setenv("HOME", t.TempDir())
+			disableSpinner(t)
+			client := citedServer(t, "cited-"+name, citedAnswer)
+			globalOpts.OutputJSON = false
+			globalOpts.Render = "plain"
+			globalOpts.CitationMaxPerDomain = 1
+			globalOpts.CitationExcludeDomains = []string{"spam.example"}
+
+			req := newTestRequest()
+			req.Stream = stream
+			out, err := runCapturingStdout(t, func() error {
+				if stream {
+					return handleStreamingResponse(context.Background(), client, req, os.Stdout)
+				}
+				return handleNonStreamingResponse(context.Background(), client, req, os.Stdout)
+			})
+			if err != nil {
+				t.Fatalf("query error = %v", err)
+			}
+			for _, want := range []string{
+				"Rivers flow[1] to the sea and evaporate [2][1].",
+				"[0]: A1 - https://a.example/1\n    https://a.example/2\n    https://www.a.example/3\n",
+				"[1]: B - https://b.example\n",
+			} {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %q:\n%s", want, out)
+				}
+			}
+			if strings.Contains(out, "spam.example") {
+				t.Errorf("output lists an excluded domain:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestQuery_CitationFilterJSON(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	disableSpinner(t)
+	client := citedServer(t, "cited-json", citedAnswer)
+	globalOpts.OutputJSON = true
+	globalOpts.CitationMaxPerDomain = 1
+
+	out, err := runCapturingStdout(t, func() error {
+		return handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+	})
+	if err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	var result struct {
+		Content       string `json:"content"`
+		SearchResults []struct {
+			URL       string   `json:"url"`
+			ExtraURLs []string `json:"extra_urls"`
+		} `json:"search_results"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if want := "Rivers flow[1] to the sea [2] and evaporate [3][1]."; result.Content != want {
+		t.Errorf("content = %q, want %q", result.Content, want)
+	}
+	if len(result.SearchResults) != 3 {
+		t.Fatalf("search results = %+v, want 3", result.SearchResults)
+	}
+	if got := result.SearchResults[0].ExtraURLs; len(got) != 2 || got[0] != "https://a.example/2" {
+		t.Errorf("extra_urls = %v, want the two later a.example URLs", got)
+	}
+	if got := result.SearchResults[1].ExtraURLs; got != nil {
+		t.Errorf("extra_urls of spam.example = %v, want none", got)
+	}
+}
+
+func TestCitationFilter_Invalid(t *testing.T) {
+	withGlobalOpts(t)
+	globalOpts.CitationMaxPerDomain = -1
+	if _, err := citationFilter(); getExitCode(err) != exitCodeValidation {
+		t.Errorf("--citation-max-per-domain -1 error = %v, want a validation error", err)
+	}
+	globalOpts.CitationMaxPerDomain = 0
+	globalOpts.CitationExcludeDomains = []string{"https://spam.example/path"}
+	if _, err := citationFilter(); getExitCode(err) != exitCodeValidation {
+		t.Errorf("--citation-exclude-domains with a URL error = %v, want a validation error", err)
+	}
+}
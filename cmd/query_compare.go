@@ -0,0 +1,387 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pterm/pterm"
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/citations"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/console"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/output/color"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/warnings"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// minCompareColumnWidth is the narrowest column of the side-by-side
+	// --compare layout; narrower terminals get the answers stacked.
+	minCompareColumnWidth = 40
+	// compareColumnSeparator separates the columns of the side-by-side layout.
+	compareColumnSeparator = " │ "
+)
+
+// queryCompare is --compare, the models the prompt is sent to.
+var queryCompare []string
+
+// compareResult is the JSON document of a model in the --compare --json
+// array: the stable output fields of its answer, or the error it failed with.
+type compareResult struct {
+	Model string `json:"model"`
+	*output.Result
+	Error string `json:"error,omitempty"`
+}
+
+// addCompareFlags registers --compare on cmd.
+func addCompareFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&queryCompare, "compare", nil,
+		"Send the prompt to each of these comma-separated models and print the answers side by side (never streamed)")
+}
+
+// validateCompare checks --compare and resolves the model aliases of its
+// list. Like --repeat, the answers are shown side by side, so options that
+// read or save a single answer are rejected.
+func validateCompare() error {
+	if len(queryCompare) == 0 {
+		return nil
+	}
+	value := strings.Join(queryCompare, ",")
+	if len(queryCompare) < 2 {
+		return clerrors.NewValidationError("compare", value, "requires at least two models")
+	}
+	switch {
+	case queryRepeat > 1:
+		return clerrors.NewValidationError("compare", value, "cannot be combined with --repeat")
+	case queryChoices > 1:
+		return clerrors.NewValidationError("compare", value, "cannot be combined with --choices")
+	case queryExtract != "":
+		return clerrors.NewValidationError("compare", value, "cannot be combined with --extract")
+	case querySaveImages != "":
+		return clerrors.NewValidationError("compare", value, "cannot be combined with --save-images")
+	case globalOpts.JSONFields != "":
+		return clerrors.NewValidationError("compare", value, "cannot be combined with --json-fields")
+	case queryConcurrency < 1:
+		return clerrors.NewValidationError("concurrency", strconv.Itoa(queryConcurrency), "must be at least 1")
+	}
+
+	resolved := make([]string, 0, len(queryCompare))
+	for _, name := range queryCompare {
+		model := models.Resolve(strings.TrimSpace(name), globalOpts.ModelAliases)
+		if model == "" {
+			return clerrors.NewValidationError("compare", value, "model names cannot be empty")
+		}
+		if slices.Contains(resolved, model) {
+			return clerrors.NewValidationError("compare", value, "lists "+model+" twice")
+		}
+		if err := models.Check(model); err != nil {
+			if globalOpts.StrictModel {
+				return clerrors.NewValidationError("compare", model, err.Error())
+			}
+			warnings.Add(warnings.CodeUnknownModel, err.Error(),
+				"run pplx models to list the known models, or --strict-model to reject unknown ones")
+		}
+		if err := models.CheckMaxTokens(model, globalOpts.MaxTokens); err != nil {
+			return clerrors.NewValidationError("max-tokens", strconv.Itoa(globalOpts.MaxTokens), err.Error())
+		}
+		resolved = append(resolved, model)
+	}
+	queryCompare = resolved
+	return nil
+}
+
+// compareRequests returns a copy of req for every --compare model.
+func compareRequests(req *perplexity.CompletionRequest) []*perplexity.CompletionRequest {
+	reqs := make([]*perplexity.CompletionRequest, len(queryCompare))
+	for i, model := range queryCompare {
+		next := *req
+		next.Model = model
+		reqs[i] = &next
+	}
+	return reqs
+}
+
+// runCompare implements `pplx query --compare`: it sends req to every listed
+// model, at most --concurrency at once, and prints the answers side by side
+// on a wide terminal, stacked otherwise, followed by the usage and latency
+// of each model; or a JSON array with --json. Answers are never streamed.
+// Ctrl-C cancels the requests still outstanding; the completed answers are
+// printed all the same.
+func runCompare(ctx context.Context, client *perplexity.Client, req *perplexity.CompletionRequest) error {
+	var renderer *render.Renderer
+	if !globalOpts.OutputJSON {
+		var err error
+		if renderer, err = render.ForStdout(globalOpts.Render); err != nil {
+			return clerrors.NewValidationError("render", globalOpts.Render, err.Error())
+		}
+	}
+	style, err := citationStyle()
+	if err != nil {
+		return err
+	}
+	filter, err := citationFilter()
+	if err != nil {
+		return err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	n := len(queryCompare)
+	var spinner *pterm.SpinnerPrinter
+	if showSpinner() {
+		spinner, _ = pterm.DefaultSpinner.Start(repeatProgress(0, n))
+	}
+	runs := sendRequests(ctx, compareRequests(req), queryConcurrency,
+		func(ctx context.Context, next *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error) {
+			return sendOnce(ctx, client, next)
+		},
+		func(done int) {
+			if spinner != nil {
+				spinner.UpdateText(repeatProgress(done, n))
+			}
+		})
+
+	completed := 0
+	for _, run := range runs {
+		if run.Err == nil {
+			completed++
+			filter.Apply(run.Response)
+		}
+	}
+	if spinner != nil {
+		spinner.Success(fmt.Sprintf("%d of %d models answered", completed, n))
+	}
+
+	switch {
+	case globalOpts.OutputJSON:
+		err = writeCompareJSON(os.Stdout, queryCompare, runs)
+	case render.IsTerminal(os.Stdout):
+		err = writeCompareRuns(os.Stdout, queryCompare, runs, renderer, style, render.Width(os.Stdout))
+	default:
+		err = writeCompareRuns(os.Stdout, queryCompare, runs, renderer, style, 0)
+	}
+	if err != nil {
+		return clerrors.NewIOError("failed to render responses", err)
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %d of %d models answered", clerrors.ErrRequestInterrupted, completed, n)
+	}
+	if completed == 0 {
+		return clerrors.NewAPIError("failed to send completion request", retry.ClassifyTimeout(runs[0].Err))
+	}
+	return nil
+}
+
+// writeCompareRuns prints the answer of every model, in columns when width
+// leaves at least minCompareColumnWidth to each, under a divider naming the
+// model otherwise, then a table of the usage and time of each model.
+func writeCompareRuns(w io.Writer, names []string, runs []repeatRun, renderer *render.Renderer,
+	style citations.Style, width int,
+) error {
+	columnWidth := 0
+	if width > 0 {
+		columnWidth = (width - len(compareColumnSeparator)*(len(runs)-1)) / len(runs)
+	}
+	var err error
+	if columnWidth >= minCompareColumnWidth {
+		err = writeCompareColumns(w, names, runs, renderer, style, columnWidth)
+	} else {
+		err = writeCompareStacked(w, names, runs, renderer, style)
+	}
+	if err != nil {
+		return err
+	}
+	return writeCompareSummary(w, names, runs)
+}
+
+// writeCompareStacked prints every answer under a divider naming its model.
+func writeCompareStacked(w io.Writer, names []string, runs []repeatRun, renderer *render.Renderer,
+	style citations.Style,
+) error {
+	for i, run := range runs {
+		if _, err := fmt.Fprintf(w, "\n──── %s ────\n\n", names[i]); err != nil {
+			return fmt.Errorf("error writing model divider to output: %w", err)
+		}
+		if err := writeCompareAnswer(w, run, renderer, style); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCompareColumns prints the answers side by side, each rendered at
+// columnWidth under a header naming its model.
+func writeCompareColumns(w io.Writer, names []string, runs []repeatRun, renderer *render.Renderer,
+	style citations.Style, columnWidth int,
+) error {
+	columns := make([][]string, len(runs))
+	header := make([]string, len(runs))
+	rule := make([]string, len(runs))
+	for i, run := range runs {
+		var buf bytes.Buffer
+		opts := renderer.Options()
+		opts.Width, opts.Hyperlinks = columnWidth, false
+		r := render.New(renderer.Mode(), opts)
+		if err := writeCompareAnswer(&buf, run, r, style); err != nil {
+			return err
+		}
+		columns[i] = wrapColumn(strings.TrimRight(buf.String(), "\n"), columnWidth)
+		header[i] = names[i]
+		rule[i] = strings.Repeat("─", columnWidth)
+	}
+
+	lines := []string{"", joinColumns(header, columnWidth), strings.Join(rule, "─┼─")}
+	height := 0
+	for _, column := range columns {
+		height = max(height, len(column))
+	}
+	for row := range height {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			if row < len(column) {
+				cells[i] = column[row]
+			}
+		}
+		lines = append(lines, joinColumns(cells, columnWidth))
+	}
+	if _, err := fmt.Fprintln(w, strings.Join(lines, "\n")); err != nil {
+		return fmt.Errorf("error writing answers to output: %w", err)
+	}
+	return nil
+}
+
+// writeCompareAnswer prints the answer of run with its citations, or why
+// it has none.
+func writeCompareAnswer(w io.Writer, run repeatRun, renderer *render.Renderer, style citations.Style) error {
+	if run.Err != nil {
+		if _, err := fmt.Fprintln(w, repeatRunError(run.Err)); err != nil {
+			return fmt.Errorf("error writing answer to output: %w", err)
+		}
+		return nil
+	}
+	if err := console.RenderAnswerWithCitations(run.Response, w, renderer, style); err != nil {
+		return err //nolint:wrapcheck // wrapped by the caller
+	}
+	return renderSearches(w, run.Response)
+}
+
+// writeCompareSummary prints a table of the usage and time of each model.
+func writeCompareSummary(w io.Writer, names []string, runs []repeatRun) error {
+	if _, err := fmt.Fprintln(w, "\nSummary:"); err != nil {
+		return fmt.Errorf("error writing summary to output: %w", err)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, dryRunTabPadding, ' ', 0)
+	fmt.Fprintln(tw, "  Model\tPrompt\tCompletion\tTotal\tTime")
+	for i, run := range runs {
+		if run.Err != nil {
+			fmt.Fprintf(tw, "  %s\t-\t-\t-\t%s\n", names[i], repeatRunError(run.Err))
+			continue
+		}
+		u := run.Response.Usage
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%s\n", names[i],
+			u.PromptTokens, u.CompletionTokens, u.TotalTokens, run.Elapsed.Round(time.Millisecond))
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("error writing summary to output: %w", err)
+	}
+	return nil
+}
+
+// writeCompareJSON prints the answers as an indented JSON array, one
+// document per model.
+func writeCompareJSON(w io.Writer, names []string, runs []repeatRun) error {
+	results := make([]compareResult, 0, len(runs))
+	for i, run := range runs {
+		result := compareResult{Model: names[i]}
+		if run.Err != nil {
+			result.Error = repeatRunError(run.Err)
+		} else {
+			result.Result = output.FromResponse(run.Response, run.Elapsed)
+		}
+		results = append(results, result)
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	if _, err := fmt.Fprintln(w, string(data)); err != nil {
+		return fmt.Errorf("error writing JSON to output: %w", err)
+	}
+	return nil
+}
+
+// joinColumns pads every cell to width and joins them with the separator.
+func joinColumns(cells []string, width int) string {
+	padded := make([]string, len(cells))
+	for i, cell := range cells {
+		padded[i] = cell + strings.Repeat(" ", max(width-visibleWidth(cell), 0))
+	}
+	return strings.TrimRight(strings.Join(padded, compareColumnSeparator), " ")
+}
+
+// wrapColumn splits text into lines no wider than width. Lines that fit are
+// kept as they are, escape sequences included; longer ones lose their
+// escape sequences and are wrapped at spaces, or cut when a word is longer
+// than width.
+func wrapColumn(text string, width int) []string {
+	var lines []string
+	for line := range strings.SplitSeq(text, "\n") {
+		if visibleWidth(line) <= width {
+			lines = append(lines, line)
+			continue
+		}
+		lines = append(lines, wrapLine(color.Strip(line), width)...)
+	}
+	return lines
+}
+
+// wrapLine wraps line, which has no escape sequences, at width.
+func wrapLine(line string, width int) []string {
+	var lines []string
+	var current []rune
+	for _, word := range strings.Fields(line) {
+		runes := []rune(word)
+		if len(current) > 0 && len(current)+1+len(runes) > width {
+			lines = append(lines, string(current))
+			current = nil
+		}
+		if len(current) > 0 {
+			current = append(current, ' ')
+		}
+		current = append(current, runes...)
+		for len(current) > width {
+			lines = append(lines, string(current[:width]))
+			current = current[width:]
+		}
+	}
+	if len(current) > 0 || len(lines) == 0 {
+		lines = append(lines, string(current))
+	}
+	return lines
+}
+
+// visibleWidth returns the number of characters s takes on screen.
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(color.Strip(s))
+}
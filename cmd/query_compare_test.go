@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/citations"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/render"
+)
+
+func withCompare(t *testing.T, models ...string) {
+	t.Helper()
+	queryCompare = models
+	t.Cleanup(func() { queryCompare = nil })
+}
+
+// newCompareServer answers every request with content naming the model of
+// the request, and fails the requests for the "broken" model.
+func newCompareServer(t *testing.T) *perplexity.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Model == "broken" {
+			http.Error(w, `{"error":"bad model"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"id":"id","model":%q,"choices":[{"index":0,"finish_reason":"stop",`+
+			`"message":{"role":"assistant","content":"Answer from %s"}}],`+
+			`"usage":{"prompt_tokens":10,"completion_tokens":%d,"total_tokens":%d}}`,
+			req.Model, req.Model, len(req.Model), 10+len(req.Model))
+	}))
+	t.Cleanup(srv.Close)
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	return client
+}
+
+func TestValidateCompare(t *testing.T) {
+	withGlobalOpts(t)
+	t.Cleanup(func() { queryChoices, queryExtract, queryRepeat = 1, "", 1 })
+
+	tests := []struct {
+		name     string
+		setup    func()
+		wantFlag string
+	}{
+		{"one model", func() { withCompare(t, "sonar") }, "compare"},
+		{"duplicate", func() { withCompare(t, "sonar", "sonar") }, "compare"},
+		{"duplicate alias", func() {
+			withCompare(t, "fast", "sonar")
+			globalOpts.ModelAliases = map[string]string{"fast": "sonar"}
+		}, "compare"},
+		{"with repeat", func() { withCompare(t, "sonar", "sonar-pro"); withRepeat(t, 2, false) }, "compare"},
+		{"with choices", func() { withCompare(t, "sonar", "sonar-pro"); queryChoices = 2 }, "compare"},
+		{"with extract", func() { withCompare(t, "sonar", "sonar-pro"); queryExtract = "a" }, "compare"},
+		{"unknown model with strict", func() {
+			withCompare(t, "sonar", "nope")
+			globalOpts.StrictModel = true
+		}, "compare"},
+		{"valid", func() { withCompare(t, "sonar", "sonar-pro") }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queryChoices, queryExtract, queryRepeat = 1, "", 1
+			globalOpts.ModelAliases, globalOpts.StrictModel = nil, false
+			tt.setup()
+			err := validateCompare()
+			if tt.wantFlag == "" {
+				if err != nil {
+					t.Errorf("validateCompare() error = %v", err)
+				}
+				return
+			}
+			var valErr *clerrors.ValidationError
+			if !errors.As(err, &valErr) || valErr.Field != tt.wantFlag {
+				t.Errorf("validateCompare() error = %v, want a %s ValidationError", err, tt.wantFlag)
+			}
+		})
+	}
+}
+
+func TestRunCompare(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	withCompare(t, "sonar", "sonar-pro", "broken")
+	globalOpts.Quiet = true // no spinner
+	client := newCompareServer(t)
+
+	globalOpts.Render = "plain"
+	out, err := runCapturingStdout(t, func() error { return runCompare(context.Background(), client, newTestRequest()) })
+	if err != nil {
+		t.Fatalf("runCompare() error = %v", err)
+	}
+	for _, want := range []string{
+		"──── sonar ────", "Answer from sonar\n", "──── sonar-pro ────", "Answer from sonar-pro",
+		"──── broken ────", "failed:", "Summary:", "Model", "sonar-pro  ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	globalOpts.OutputJSON = true
+	out, err = runCapturingStdout(t, func() error { return runCompare(context.Background(), client, newTestRequest()) })
+	if err != nil {
+		t.Fatalf("runCompare() with --json error = %v", err)
+	}
+	var results []struct {
+		Model   string `json:"model"`
+		Content string `json:"content"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, out)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3: %+v", len(results), results)
+	}
+	for i, model := range []string{"sonar", "sonar-pro"} {
+		if results[i].Model != model || results[i].Content != "Answer from "+model {
+			t.Errorf("results[%d] = %+v, want the answer of %s", i, results[i], model)
+		}
+	}
+	if results[2].Model != "broken" || results[2].Error == "" {
+		t.Errorf("results[2] = %+v, want an error", results[2])
+	}
+}
+
+func TestRunCompare_AllFailed(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	withCompare(t, "broken")
+	globalOpts.Quiet, globalOpts.OutputJSON = true, true
+	client := newCompareServer(t)
+
+	_, err := runCapturingStdout(t, func() error { return runCompare(context.Background(), client, newTestRequest()) })
+	var apiErr *clerrors.APIError
+	if !errors.As(err, &apiErr) {
+		t.Errorf("runCompare() error = %v, want an APIError", err)
+	}
+}
+
+func TestWriteCompareRuns_Columns(t *testing.T) {
+	names := []string{"sonar", "sonar-pro"}
+	runs := []repeatRun{
+		{Run: 1, Response: compareResponse("short answer"), Elapsed: time.Second},
+		{Run: 2, Response: compareResponse(strings.Repeat("word ", 30)), Elapsed: 2 * time.Second},
+	}
+	renderer := render.New(render.ModePlain, render.Options{})
+
+	var columns strings.Builder
+	if err := writeCompareRuns(&columns, names, runs, renderer, citations.StyleList, 100); err != nil {
+		t.Fatalf("writeCompareRuns() error = %v", err)
+	}
+	out := columns.String()
+	if !strings.Contains(out, "sonar"+strings.Repeat(" ", 47-len("sonar"))+compareColumnSeparator+"sonar-pro") {
+		t.Errorf("output has no column header:\n%s", out)
+	}
+	if !strings.Contains(out, "short answer") || strings.Contains(out, "────  sonar") {
+		t.Errorf("output is not in columns:\n%s", out)
+	}
+	for line := range strings.SplitSeq(out, "\n") {
+		if visibleWidth(line) > 100 {
+			t.Errorf("line wider than the terminal: %q", line)
+		}
+	}
+
+	var stacked strings.Builder
+	if err := writeCompareRuns(&stacked, names, runs, renderer, citations.StyleList, 60); err != nil {
+		t.Fatalf("writeCompareRuns() error = %v", err)
+	}
+	if !strings.Contains(stacked.String(), "──── sonar-pro ────") {
+		t.Errorf("narrow output is not stacked:\n%s", stacked.String())
+	}
+}
+
+func TestWrapColumn(t *testing.T) {
+	got := wrapColumn("fits\nthe quick brown fox jumps\n\x1b[1mbold\x1b[0m", 10)
+	want := []string{"fits", "the quick", "brown fox", "jumps", "\x1b[1mbold\x1b[0m"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("wrapColumn() = %q, want %q", got, want)
+	}
+	if got := wrapLine("abcdefghijkl", 5); strings.Join(got, "|") != "abcde|fghij|kl" {
+		t.Errorf("wrapLine() = %q, want a long word cut at the width", got)
+	}
+}
+
+func compareResponse(content string) *perplexity.CompletionResponse {
+	return &perplexity.CompletionResponse{
+		Choices: []perplexity.Choice{{Message: perplexity.Message{Role: "assistant", Content: content}}},
+		Usage:   perplexity.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// validateAutoContinue checks --auto-continue.
+func validateAutoContinue() error {
+	if globalOpts.AutoContinue < 0 || globalOpts.AutoContinue > chat.MaxAutoContinue {
+		return clerrors.NewValidationError("auto-continue", strconv.Itoa(globalOpts.AutoContinue),
+			fmt.Sprintf("must be between 0 and %d", chat.MaxAutoContinue))
+	}
+	return nil
+}
+
+// continueAnswer completes res, the answer to req, with up to
+// --auto-continue continuation requests when it was cut off at max_tokens.
+// send sends one continuation; the usage of each is tracked under source.
+// A failed continuation, or an answer still cut off after the last one, is
+// reported as a warning and the answer received so far returned.
+func continueAnswer(req *perplexity.CompletionRequest, res *perplexity.CompletionResponse, source string,
+	send chat.SendFunc,
+) *perplexity.CompletionResponse {
+	if globalOpts.AutoContinue <= 0 || !chat.Truncated(res) {
+		return res
+	}
+	merged, err := chat.Continue(req, res, globalOpts.AutoContinue,
+		func(next *perplexity.CompletionRequest, answer string) (*perplexity.CompletionResponse, error) {
+			continued, err := send(next, answer)
+			if err != nil {
+				return nil, err
+			}
+			usage.Track(source, continued)
+			return continued, nil
+		})
+	if err != nil {
+		warnings.Add(warnings.CodeTruncated, fmt.Sprintf("failed to continue the truncated answer: %v", err), "")
+	} else if chat.Truncated(merged) {
+		warnings.Add(warnings.CodeTruncated,
+			fmt.Sprintf("answer still cut off at max_tokens after %d continuations", globalOpts.AutoContinue),
+			"raise --max-tokens or --auto-continue")
+	}
+	return merged
+}
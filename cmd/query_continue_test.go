@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// continuationServer answers the first request with parts[0] cut off at
+// max_tokens and each continuation with the next part, the last one
+// complete. With stream set, every part is sent as SSE events growing a word
+// at a time.
+func continuationServer(t *testing.T, stream bool, parts ...string) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body perplexity.CompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if requests > 0 && body.Messages[len(body.Messages)-1].Content != "continue" {
+			t.Errorf("continuation request ends with %+v", body.Messages[len(body.Messages)-1])
+		}
+		part := parts[min(requests, len(parts)-1)]
+		requests++
+		reason := "length"
+		if requests >= len(parts) {
+			reason = "stop"
+		}
+		event := func(content, finish string) []byte {
+			choice := map[string]any{"index": 0, "finish_reason": finish,
+				"message": map[string]string{"role": "assistant", "content": content}}
+			data, _ := json.Marshal(map[string]any{
+				"id": "test-id", "model": "sonar", "choices": []map[string]any{choice},
+				"usage": map[string]int{"prompt_tokens": 10, "completion_tokens": 20, "total_tokens": 30},
+			})
+			return data
+		}
+		if !stream {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(event(part, reason))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		words := strings.SplitAfter(part, " ")
+		for i := range words {
+			finish := ""
+			if i == len(words)-1 {
+				finish = reason
+			}
+			_, _ = w.Write([]byte("data: " + string(event(strings.Join(words[:i+1], ""), finish)) + "\n\n"))
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestHandleNonStreamingResponse_AutoContinue(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	disableSpinner(t)
+	srv, requests := continuationServer(t, false, "Rivers flow to the", " sea. Then they", "Then they evaporate.")
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	globalOpts.OutputJSON = true
+	globalOpts.AutoContinue = 3
+	out, err := runCapturingStdout(t, func() error {
+		return handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+	})
+	if err != nil {
+		t.Fatalf("handleNonStreamingResponse() error = %v", err)
+	}
+	if *requests != 3 {
+		t.Errorf("sent %d requests, want 3", *requests)
+	}
+	var result struct {
+		Content string `json:"content"`
+		Usage   struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if want := "Rivers flow to the sea. Then they evaporate."; result.Content != want {
+		t.Errorf("content = %q, want %q", result.Content, want)
+	}
+	if result.Usage.PromptTokens != 30 || result.Usage.CompletionTokens != 60 {
+		t.Errorf("usage = %+v, want the sum of the three requests", result.Usage)
+	}
+}
+
+func TestHandleNonStreamingResponse_AutoContinueDisabled(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	disableSpinner(t)
+	srv, requests := continuationServer(t, false, "Cut off", " never sent.")
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	globalOpts.Render = "plain"
+	globalOpts.AutoContinue = 0
+	if _, err := runCapturingStdout(t, func() error {
+		return handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+	}); err != nil {
+		t.Fatalf("handleNonStreamingResponse() error = %v", err)
+	}
+	if *requests != 1 {
+		t.Errorf("sent %d requests, want no continuation", *requests)
+	}
+}
+
+func TestHandleStreamingResponse_AutoContinue(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	srv, requests := continuationServer(t, true, "One two three. Four fi", "Four five six.")
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	globalOpts.Render = "raw"
+	globalOpts.AutoContinue = 1
+	out, err := runCapturingStdout(t, func() error {
+		return handleStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+	})
+	if err != nil {
+		t.Fatalf("handleStreamingResponse() error = %v", err)
+	}
+	if *requests != 2 {
+		t.Errorf("sent %d requests, want 2", *requests)
+	}
+	// The continuation streams on the same line, without a redraw.
+	if want := "One two three. Four five six.\n"; !strings.HasPrefix(out, want) {
+		t.Errorf("output = %q, want it to start with %q", out, want)
+	}
+}
+
+func TestValidateAutoContinue(t *testing.T) {
+	withGlobalOpts(t)
+	for _, n := range []int{-1, 11} {
+		globalOpts.AutoContinue = n
+		var valErr *clerrors.ValidationError
+		if err := validateAutoContinue(); !errors.As(err, &valErr) || valErr.Field != "auto-continue" {
+			t.Errorf("validateAutoContinue(%d) error = %v, want an auto-continue ValidationError", n, err)
+		}
+	}
+	globalOpts.AutoContinue = 10
+	if err := validateAutoContinue(); err != nil {
+		t.Errorf("validateAutoContinue(10) error = %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/export"
+	"github.com/sgaunet/pplx/pkg/warnings"
+	"github.com/spf13/cobra"
+)
+
+// queryExportRequest is --export-request: the wire format to print the
+// request in instead of sending it.
+var queryExportRequest string
+
+// addExportFlags registers --export-request on the query command.
+func addExportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&queryExportRequest, "export-request", "",
+		"Print the request in this wire format without sending it: "+strings.Join(export.Formats(), ", "))
+}
+
+// runExportRequest implements --export-request: it builds the request as a
+// query would and prints it translated to the chosen format. The fields the
+// format cannot carry are reported as a warning.
+func runExportRequest(cmd *cobra.Command, args []string, cfg *config.ConfigData) error {
+	if !slices.Contains(export.Formats(), queryExportRequest) {
+		return clerrors.NewValidationError("export-request", queryExportRequest,
+			"must be one of: "+strings.Join(export.Formats(), ", "))
+	}
+	req, err := prepareQueryRequest(cmd, args, cfg)
+	if err != nil {
+		return err
+	}
+	payload, err := export.Request(req, queryExportRequest)
+	if err != nil {
+		return clerrors.NewValidationError("export-request", queryExportRequest, err.Error())
+	}
+	return writeExport(cmd.OutOrStdout(), payload)
+}
+
+// writeExport prints the body of payload, with the --choices field added.
+func writeExport(out io.Writer, payload *export.Payload) error {
+	body := payload.Body
+	if fields := choicesFields(); fields != nil {
+		data, err := addRequestFields(body, fields)
+		if err != nil {
+			return err
+		}
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, data, "", "  "); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = indented.Bytes()
+	}
+	if len(payload.Dropped) > 0 {
+		warnings.Add(warnings.CodeExportDropped,
+			fmt.Sprintf("the %s format drops Perplexity-only fields: %s",
+				payload.Format, strings.Join(payload.Dropped, ", ")),
+			"use --export-request "+export.FormatPerplexity+" to keep them")
+	}
+	if _, err := fmt.Fprintln(out, string(body)); err != nil {
+		return clerrors.NewIOError("failed to write request", err)
+	}
+	return nil
+}
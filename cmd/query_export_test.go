@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/export"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+func TestWriteExport(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		choices     int
+		wantN       any
+		wantWarning bool
+	}{
+		{"openai drops perplexity fields", export.FormatOpenAI, 1, nil, true},
+		{"perplexity keeps them", export.FormatPerplexity, 1, nil, false},
+		{"choices", export.FormatOpenAI, 3, float64(3), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings.Default.Reset()
+			t.Cleanup(warnings.Default.Reset)
+			saved := queryChoices
+			t.Cleanup(func() { queryChoices = saved })
+			queryChoices = tt.choices
+
+			req := newTestRequest()
+			perplexity.WithSearchRecencyFilter("week")(req)
+			payload, err := export.Request(req, tt.format)
+			if err != nil {
+				t.Fatalf("export.Request() error = %v", err)
+			}
+			var out bytes.Buffer
+			if err := writeExport(&out, payload); err != nil {
+				t.Fatalf("writeExport() error = %v", err)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(out.Bytes(), &body); err != nil {
+				t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+			}
+			if body["model"] != "sonar" || body["n"] != tt.wantN {
+				t.Errorf("body = %v, want model sonar and n %v", body, tt.wantN)
+			}
+			if got := warnings.Default.Len() == 1; got != tt.wantWarning {
+				t.Errorf("warnings = %+v, want a dropped fields warning: %v", warnings.Default.All(), tt.wantWarning)
+			}
+		})
+	}
+}
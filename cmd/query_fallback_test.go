@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// newFallbackServer answers requests for the model failing with status and
+// message, and the other requests with a completion naming their model. The
+// models requested are appended to sent.
+func newFallbackServer(t *testing.T, failing string, status int, message string, sent *[]string) *perplexity.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		*sent = append(*sent, body.Model)
+		w.Header().Set("Content-Type", "application/json")
+		if body.Model == failing {
+			w.WriteHeader(status)
+			_, _ = fmt.Fprint(w, apiErrorJSON(message, "invalid_request", status))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"id":"id","model":%q,"choices":[{"index":0,"finish_reason":"stop",`+
+			`"message":{"role":"assistant","content":"Hello"}}],`+
+			`"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`, body.Model)
+	}))
+	t.Cleanup(srv.Close)
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	return client
+}
+
+func TestHandleNonStreamingResponse_ModelFallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		message       string
+		wantSent      []string
+		wantModelUsed string
+		wantErr       bool
+	}{
+		{
+			name: "invalid model falls back", status: http.StatusBadRequest, message: "Invalid model 'sonar-pro'",
+			wantSent: []string{"sonar-pro", "sonar"}, wantModelUsed: "sonar",
+		},
+		{
+			name: "unavailable model falls back", status: http.StatusServiceUnavailable,
+			message:  "The model is currently unavailable",
+			wantSent: []string{"sonar-pro", "sonar"}, wantModelUsed: "sonar",
+		},
+		{
+			name: "authentication error does not fall back", status: http.StatusForbidden,
+			message:  "Invalid model access for this key",
+			wantSent: []string{"sonar-pro"}, wantErr: true,
+		},
+		{
+			name: "validation error does not fall back", status: http.StatusBadRequest,
+			message:  "max_tokens is too large",
+			wantSent: []string{"sonar-pro"}, wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withGlobalOpts(t)
+			disableSpinner(t)
+			warnings.Default.Reset()
+			t.Cleanup(warnings.Default.Reset)
+			globalOpts.ModelFallbacks = []string{"sonar"}
+
+			var sent []string
+			client := newFallbackServer(t, "sonar-pro", tt.status, tt.message, &sent)
+			req := newTestRequest()
+			req.Model = "sonar-pro"
+
+			var out bytes.Buffer
+			err := handleNonStreamingResponse(context.Background(), client, req, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handleNonStreamingResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(sent, tt.wantSent) {
+				t.Errorf("models sent = %q, want %q", sent, tt.wantSent)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var result output.Result
+			if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+				t.Fatalf("invalid JSON output: %v\n%s", err, out.String())
+			}
+			if result.ModelUsed != tt.wantModelUsed {
+				t.Errorf("model_used = %q, want %q", result.ModelUsed, tt.wantModelUsed)
+			}
+			if len(result.Warnings) != 1 || result.Warnings[0].Code != warnings.CodeModelFallback {
+				t.Errorf("warnings = %+v, want one %s warning", result.Warnings, warnings.CodeModelFallback)
+			}
+		})
+	}
+}
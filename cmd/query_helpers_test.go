@@ -1,7 +1,15 @@
 package cmd
 
 import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
 	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/validation"
+	"github.com/sgaunet/pplx/pkg/warnings"
 )
 
 func TestParseDateFilter(t *testing.T) {
@@ -23,6 +31,18 @@ func TestParseDateFilter(t *testing.T) {
 			dateStr:   "2024-01-15",
 			wantErr:   false,
 		},
+		{
+			name:      "relative - days",
+			fieldName: "test-date",
+			dateStr:   "7d",
+			wantErr:   false,
+		},
+		{
+			name:      "quarter",
+			fieldName: "test-date",
+			dateStr:   "2024-Q1",
+			wantErr:   false,
+		},
 		{
 			name:      "invalid format - European",
 			fieldName:      "test-date",
@@ -61,8 +81,6 @@ func TestParseDateFilter(t *testing.T) {
 }
 
 func TestValidateStringEnum(t *testing.T) {
-	validValues := map[string]bool{"foo": true, "bar": true, "baz": true}
-
 	tests := []struct {
 		name      string
 		fieldName string
@@ -70,21 +88,15 @@ func TestValidateStringEnum(t *testing.T) {
 		wantErr   bool
 	}{
 		{
-			name:      "valid value - foo",
+			name:      "valid value - web",
 			fieldName: "test-field",
-			value:     "foo",
+			value:     "web",
 			wantErr:   false,
 		},
 		{
-			name:      "valid value - bar",
+			name:      "valid value - academic",
 			fieldName: "test-field",
-			value:     "bar",
-			wantErr:   false,
-		},
-		{
-			name:      "valid value - baz",
-			fieldName: "test-field",
-			value:     "baz",
+			value:     "academic",
 			wantErr:   false,
 		},
 		{
@@ -100,16 +112,16 @@ func TestValidateStringEnum(t *testing.T) {
 			wantErr:   true,
 		},
 		{
-			name:      "case sensitive - FOO should fail",
+			name:      "case sensitive - WEB should fail",
 			fieldName: "test-field",
-			value:     "FOO",
+			value:     "WEB",
 			wantErr:   true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateStringEnum(tt.fieldName, tt.value, validValues, "foo, bar, baz")
+			err := validateStringEnum(tt.fieldName, validation.KindSearchMode, tt.value)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("validateStringEnum() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -117,31 +129,11 @@ func TestValidateStringEnum(t *testing.T) {
 	}
 }
 
-func TestBuildSearchOptions(t *testing.T) {
-	// Save original values
-	origSearchDomains := globalOpts.SearchDomains
-	origSearchRecency := globalOpts.SearchRecency
-	origReturnImages := globalOpts.ReturnImages
-	origLocationLat := globalOpts.LocationLat
-	origLocationLon := globalOpts.LocationLon
-	origLocationCountry := globalOpts.LocationCountry
-	origSearchMode := globalOpts.SearchMode
-	origSearchContextSize := globalOpts.SearchContextSize
+func TestBuildAllOptions_Search(t *testing.T) {
+	withGlobalOpts(t)
+	globalOpts.UserPrompt = "test"
 
-	// Restore original values after test
-	defer func() {
-		globalOpts.SearchDomains = origSearchDomains
-		globalOpts.SearchRecency = origSearchRecency
-		globalOpts.ReturnImages = origReturnImages
-		globalOpts.LocationLat = origLocationLat
-		globalOpts.LocationLon = origLocationLon
-		globalOpts.LocationCountry = origLocationCountry
-		globalOpts.SearchMode = origSearchMode
-		globalOpts.SearchContextSize = origSearchContextSize
-	}()
-
-	t.Run("empty options", func(t *testing.T) {
-		// Reset all search-related globals
+	reset := func() {
 		globalOpts.SearchDomains = []string{}
 		globalOpts.SearchRecency = ""
 		globalOpts.ReturnImages = false
@@ -150,168 +142,115 @@ func TestBuildSearchOptions(t *testing.T) {
 		globalOpts.LocationCountry = ""
 		globalOpts.SearchMode = ""
 		globalOpts.SearchContextSize = ""
+	}
 
-		opts := buildSearchOptions()
-		if len(opts) != 0 {
-			t.Errorf("buildSearchOptions() returned %d options, want 0", len(opts))
+	t.Run("empty options", func(t *testing.T) {
+		reset()
+		req, err := buildAllOptions()
+		if err != nil {
+			t.Fatalf("buildAllOptions() error = %v", err)
+		}
+		if len(req.SearchDomainFilter) != 0 || req.SearchRecencyFilter != "" || req.WebSearchOptions != nil {
+			t.Errorf("buildAllOptions() = %+v, want no search options", req)
 		}
 	})
 
 	t.Run("with search domains", func(t *testing.T) {
+		reset()
 		globalOpts.SearchDomains = []string{"example.com", "test.com"}
-		globalOpts.SearchRecency = ""
-		globalOpts.ReturnImages = false
-		globalOpts.LocationLat = 0
-		globalOpts.LocationLon = 0
-		globalOpts.LocationCountry = ""
-		globalOpts.SearchMode = ""
-		globalOpts.SearchContextSize = ""
-
-		opts := buildSearchOptions()
-		if len(opts) != 1 {
-			t.Errorf("buildSearchOptions() returned %d options, want 1", len(opts))
+		req, err := buildAllOptions()
+		if err != nil {
+			t.Fatalf("buildAllOptions() error = %v", err)
+		}
+		if strings.Join(req.SearchDomainFilter, ",") != "example.com,test.com" {
+			t.Errorf("search_domain_filter = %v", req.SearchDomainFilter)
 		}
 	})
 
 	t.Run("with search recency and no images", func(t *testing.T) {
-		globalOpts.SearchDomains = []string{}
+		reset()
 		globalOpts.SearchRecency = "week"
-		globalOpts.ReturnImages = false
-		globalOpts.LocationLat = 0
-		globalOpts.LocationLon = 0
-		globalOpts.LocationCountry = ""
-		globalOpts.SearchMode = ""
-		globalOpts.SearchContextSize = ""
-
-		opts := buildSearchOptions()
-		// Should have 1 option (search recency)
-		if len(opts) != 1 {
-			t.Errorf("buildSearchOptions() returned %d options, want 1", len(opts))
+		req, err := buildAllOptions()
+		if err != nil {
+			t.Fatalf("buildAllOptions() error = %v", err)
+		}
+		if req.SearchRecencyFilter != "week" {
+			t.Errorf("search_recency_filter = %q, want week", req.SearchRecencyFilter)
 		}
 	})
 
 	t.Run("with search recency and images - recency should be skipped", func(t *testing.T) {
-		globalOpts.SearchDomains = []string{}
+		reset()
 		globalOpts.SearchRecency = "week"
 		globalOpts.ReturnImages = true
-		globalOpts.LocationLat = 0
-		globalOpts.LocationLon = 0
-		globalOpts.LocationCountry = ""
-		globalOpts.SearchMode = ""
-		globalOpts.SearchContextSize = ""
 
-		opts := buildSearchOptions()
-		// Should have 0 options (recency skipped due to images)
-		if len(opts) != 0 {
-			t.Errorf("buildSearchOptions() returned %d options, want 0 (recency should be skipped with images)", len(opts))
+		// The default prefer_images policy drops the recency filter.
+		globalOpts.ImageConflictPolicy = ""
+		if err := resolveImageConflict(); err != nil {
+			t.Fatalf("resolveImageConflict() error = %v", err)
+		}
+		req, err := buildAllOptions()
+		if err != nil {
+			t.Fatalf("buildAllOptions() error = %v", err)
+		}
+		if req.SearchRecencyFilter != "" || !req.ReturnImages {
+			t.Errorf("recency %q, images %v; want images only", req.SearchRecencyFilter, req.ReturnImages)
 		}
 	})
 
 	t.Run("with location", func(t *testing.T) {
-		globalOpts.SearchDomains = []string{}
-		globalOpts.SearchRecency = ""
-		globalOpts.ReturnImages = false
+		reset()
 		globalOpts.LocationLat = 40.7128
 		globalOpts.LocationLon = -74.0060
 		globalOpts.LocationCountry = "US"
-		globalOpts.SearchMode = ""
-		globalOpts.SearchContextSize = ""
-
-		opts := buildSearchOptions()
-		if len(opts) != 1 {
-			t.Errorf("buildSearchOptions() returned %d options, want 1", len(opts))
+		req, err := buildAllOptions()
+		if err != nil {
+			t.Fatalf("buildAllOptions() error = %v", err)
 		}
-	})
-
-	t.Run("with search mode and context size", func(t *testing.T) {
-		globalOpts.SearchDomains = []string{}
-		globalOpts.SearchRecency = ""
-		globalOpts.ReturnImages = false
-		globalOpts.LocationLat = 0
-		globalOpts.LocationLon = 0
-		globalOpts.LocationCountry = ""
-		globalOpts.SearchMode = "web"
-		globalOpts.SearchContextSize = "medium"
-
-		opts := buildSearchOptions()
-		// Should have 2 options (mode + context size)
-		if len(opts) != 2 {
-			t.Errorf("buildSearchOptions() returned %d options, want 2", len(opts))
+		if req.WebSearchOptions == nil || req.WebSearchOptions.UserLocation == nil ||
+			req.WebSearchOptions.UserLocation.Country != "US" {
+			t.Errorf("web_search_options = %+v, want the location", req.WebSearchOptions)
 		}
 	})
 
 	t.Run("with all options", func(t *testing.T) {
+		reset()
 		globalOpts.SearchDomains = []string{"example.com"}
 		globalOpts.SearchRecency = "week"
-		globalOpts.ReturnImages = false
-		globalOpts.LocationLat = 40.7128
-		globalOpts.LocationLon = -74.0060
 		globalOpts.LocationCountry = "US"
 		globalOpts.SearchMode = "web"
 		globalOpts.SearchContextSize = "high"
-
-		opts := buildSearchOptions()
-		// Should have 5 options (domains, recency, location, mode, context)
-		if len(opts) != 5 {
-			t.Errorf("buildSearchOptions() returned %d options, want 5", len(opts))
-		}
-	})
-}
-
-func TestBuildDateFilterOptions(t *testing.T) {
-	// Save original values
-	origSearchAfterDate := globalOpts.SearchAfterDate
-	origSearchBeforeDate := globalOpts.SearchBeforeDate
-	origLastUpdatedAfter := globalOpts.LastUpdatedAfter
-	origLastUpdatedBefore := globalOpts.LastUpdatedBefore
-
-	// Restore original values after test
-	defer func() {
-		globalOpts.SearchAfterDate = origSearchAfterDate
-		globalOpts.SearchBeforeDate = origSearchBeforeDate
-		globalOpts.LastUpdatedAfter = origLastUpdatedAfter
-		globalOpts.LastUpdatedBefore = origLastUpdatedBefore
-	}()
-
-	t.Run("no date filters", func(t *testing.T) {
-		globalOpts.SearchAfterDate = ""
-		globalOpts.SearchBeforeDate = ""
-		globalOpts.LastUpdatedAfter = ""
-		globalOpts.LastUpdatedBefore = ""
-
-		opts, err := buildDateFilterOptions()
+		req, err := buildAllOptions()
 		if err != nil {
-			t.Errorf("buildDateFilterOptions() unexpected error = %v", err)
+			t.Fatalf("buildAllOptions() error = %v", err)
 		}
-		if len(opts) != 0 {
-			t.Errorf("buildDateFilterOptions() returned %d options, want 0", len(opts))
+		if len(req.SearchDomainFilter) != 1 || req.SearchRecencyFilter != "week" || req.SearchMode != "web" ||
+			req.WebSearchOptions == nil || req.WebSearchOptions.SearchContextSize != "high" {
+			t.Errorf("buildAllOptions() = %+v, want all the search options", req)
 		}
 	})
+}
 
-	t.Run("with valid search after date", func(t *testing.T) {
-		globalOpts.SearchAfterDate = "01/01/2024"
-		globalOpts.SearchBeforeDate = ""
-		globalOpts.LastUpdatedAfter = ""
-		globalOpts.LastUpdatedBefore = ""
+func TestBuildAllOptions_DateFilters(t *testing.T) {
+	withGlobalOpts(t)
+	globalOpts.UserPrompt = "test"
 
-		opts, err := buildDateFilterOptions()
+	t.Run("no date filters", func(t *testing.T) {
+		req, err := buildAllOptions()
 		if err != nil {
-			t.Errorf("buildDateFilterOptions() unexpected error = %v", err)
+			t.Fatalf("buildAllOptions() error = %v", err)
 		}
-		if len(opts) != 1 {
-			t.Errorf("buildDateFilterOptions() returned %d options, want 1", len(opts))
+		if req.PublishedAfter != "" || req.PublishedBefore != "" ||
+			req.LastUpdatedAfterFilter != "" || req.LastUpdatedBeforeFilter != "" {
+			t.Errorf("buildAllOptions() = %+v, want no date filters", req)
 		}
 	})
 
 	t.Run("with invalid date format", func(t *testing.T) {
 		globalOpts.SearchAfterDate = "01-01-2024"
-		globalOpts.SearchBeforeDate = ""
-		globalOpts.LastUpdatedAfter = ""
-		globalOpts.LastUpdatedBefore = ""
-
-		_, err := buildDateFilterOptions()
-		if err == nil {
-			t.Errorf("buildDateFilterOptions() expected error for invalid date format, got nil")
+		err := validateDateFilters()
+		if err == nil || getExitCode(err) != exitCodeValidation || !strings.Contains(err.Error(), "search-after-date") {
+			t.Errorf("validateDateFilters() error = %v, want a search-after-date validation error", err)
 		}
 	})
 
@@ -320,13 +259,17 @@ func TestBuildDateFilterOptions(t *testing.T) {
 		globalOpts.SearchBeforeDate = "12/31/2024"
 		globalOpts.LastUpdatedAfter = "06/01/2024"
 		globalOpts.LastUpdatedBefore = "06/30/2024"
-
-		opts, err := buildDateFilterOptions()
+		if err := validateDateFilters(); err != nil {
+			t.Fatalf("validateDateFilters() error = %v", err)
+		}
+		req, err := buildAllOptions()
 		if err != nil {
-			t.Errorf("buildDateFilterOptions() unexpected error = %v", err)
+			t.Fatalf("buildAllOptions() error = %v", err)
 		}
-		if len(opts) != 4 {
-			t.Errorf("buildDateFilterOptions() returned %d options, want 4", len(opts))
+		if req.PublishedAfter != "1/1/2024" || req.PublishedBefore != "12/31/2024" ||
+			req.LastUpdatedAfterFilter != "6/1/2024" || req.LastUpdatedBeforeFilter != "6/30/2024" {
+			t.Errorf("date filters = %q, %q, %q, %q", req.PublishedAfter, req.PublishedBefore,
+				req.LastUpdatedAfterFilter, req.LastUpdatedBeforeFilter)
 		}
 	})
 }
@@ -447,3 +390,210 @@ func TestValidateInputs(t *testing.T) {
 		}
 	})
 }
+
+func TestResolveModel(t *testing.T) {
+	saved := *globalOpts
+	defer func() { *globalOpts = saved }()
+
+	tests := []struct {
+		name      string
+		model     string
+		strict    bool
+		wantModel string
+		wantErr   bool
+	}{
+		{"alias expanded", "fast", false, "sonar", false},
+		{"known model", "sonar-pro", false, "sonar-pro", false},
+		{"unknown model warns", "sonar-prp", false, "sonar-prp", false},
+		{"unknown model strict", "sonar-prp", true, "sonar-prp", true},
+		{"alias to unknown model strict", "typo", true, "snoar", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			globalOpts.Model = tt.model
+			globalOpts.StrictModel = tt.strict
+			globalOpts.ModelAliases = map[string]string{"fast": "sonar", "typo": "snoar"}
+
+			err := resolveModel()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveModel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if globalOpts.Model != tt.wantModel {
+				t.Errorf("Model = %q, want %q", globalOpts.Model, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestBuildMessages_Language(t *testing.T) {
+	withGlobalOpts(t)
+	globalOpts.UserPrompt = "Quelle heure est-il ?"
+	globalOpts.Language = "fr"
+
+	tests := []struct {
+		system string
+		want   string
+	}{
+		{"", "Always answer in French."},
+		{"Be brief.", "Be brief.\n\nAlways answer in French."},
+		{"Answer in English.", "Answer in English."},
+	}
+	for _, tt := range tests {
+		globalOpts.SystemPrompt = tt.system
+		msg, err := buildMessages()
+		if err != nil {
+			t.Fatalf("buildMessages() error = %v", err)
+		}
+		first := msg.GetMessages()[0]
+		if first.Role != "system" || first.Content != tt.want {
+			t.Errorf("system %q: first message = %+v, want system message %q", tt.system, first, tt.want)
+		}
+	}
+
+	globalOpts.Language = "french"
+	if err := validateInputs(); err == nil {
+		t.Error("validateInputs() accepted an unknown language")
+	}
+}
+
+func TestResolveImageConflict_Policies(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantRecency string
+		wantImages  bool
+		wantErr     bool
+	}{
+		{"prefer_images", "", true, false},
+		{"prefer_recency", "week", false, false},
+		{"error", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			withGlobalOpts(t)
+			globalOpts.UserPrompt = "test"
+			globalOpts.SearchRecency = "week"
+			globalOpts.ReturnImages = true
+			globalOpts.ImageConflictPolicy = tt.policy
+
+			out, err := runCapturingStdout(t, validateInputs)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "image_conflict_policy") {
+					t.Errorf("validateInputs() error = %v, want a conflict error naming the policy", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateInputs() error = %v", err)
+			}
+			if globalOpts.SearchRecency != tt.wantRecency || globalOpts.ReturnImages != tt.wantImages {
+				t.Errorf("recency %q, images %v; want %q, %v",
+					globalOpts.SearchRecency, globalOpts.ReturnImages, tt.wantRecency, tt.wantImages)
+			}
+			if strings.Count(out, "Note:") != 1 || !strings.Contains(out, tt.policy) {
+				t.Errorf("output = %q, want one note naming %s", out, tt.policy)
+			}
+		})
+	}
+}
+
+func TestResolveStreamFormat_Policies(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		withGlobalOpts(t)
+		warnings.Default.Reset()
+		t.Cleanup(warnings.Default.Reset)
+		globalOpts.Stream = true
+		globalOpts.ResponseFormatJSONSchema = `{"type":"object"}`
+		globalOpts.StrictOptions = strict
+
+		err := resolveStreamFormat()
+		if strict {
+			if err == nil || !strings.Contains(err.Error(), "strict_options") {
+				t.Errorf("strict: resolveStreamFormat() error = %v, want an error naming strict_options", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("resolveStreamFormat() error = %v", err)
+		}
+		if globalOpts.Stream {
+			t.Error("streaming was not turned off for a request with a response format")
+		}
+		if all := warnings.Default.All(); len(all) != 1 || all[0].Code != warnings.CodeStreamFormat {
+			t.Errorf("warnings = %+v, want one %s warning", all, warnings.CodeStreamFormat)
+		}
+	}
+}
+
+func TestWriteExtracted(t *testing.T) {
+	res := &perplexity.CompletionResponse{
+		Model: "sonar",
+		Choices: []perplexity.Choice{
+			{Message: perplexity.Message{Content: `{"cities": [{"name": "Paris"}, {"name": "Lyon"}]}`}},
+		},
+	}
+	tests := []struct {
+		name     string
+		path     string
+		json     bool
+		want     string
+		wantCode int
+	}{
+		{"answer", "cities.1.name", false, "Lyon\n", exitCodeSuccess},
+		{"output object", "model", true, "sonar\n", exitCodeSuccess},
+		{"content of output object", "content", true, res.GetLastContent() + "\n", exitCodeSuccess},
+		{"missing path", "cities.2.name", false, "", exitCodeValidation},
+		{"answer with --json is a string", "content.cities", true, "", exitCodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withGlobalOpts(t)
+			oldExtract := queryExtract
+			t.Cleanup(func() { queryExtract = oldExtract })
+			queryExtract = tt.path
+			globalOpts.OutputJSON = tt.json
+
+			out, err := runCapturingStdout(t, func() error { return writeExtracted(os.Stdout, res, 0) })
+			if code := getExitCode(err); code != tt.wantCode {
+				t.Fatalf("exit code = %d (error %v), want %d", code, err, tt.wantCode)
+			}
+			if out != tt.want {
+				t.Errorf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamOutput(t *testing.T) {
+	withGlobalOpts(t)
+	file, err := os.CreateTemp(t.TempDir(), "answer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	tests := []struct {
+		name    string
+		style   string
+		out     io.Writer
+		wantErr bool
+	}{
+		{name: "instant", style: "instant", out: os.Stdout},
+		{name: "smooth to a buffer", style: "smooth", out: &bytes.Buffer{}},
+		{name: "smooth to a file", style: "smooth", out: file},
+		{name: "unknown style", style: "typewriter", out: os.Stdout, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			globalOpts.StreamStyle = tt.style
+			if _, err := streamStyle(); (err != nil) != tt.wantErr {
+				t.Fatalf("streamStyle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// Output that is not a terminal is never paced.
+			got, smooth := streamOutput(tt.out)
+			if got != tt.out || smooth != nil {
+				t.Errorf("streamOutput() = %T, %v, want the output itself", got, smooth)
+			}
+			finishStream(smooth, false)
+		})
+	}
+}
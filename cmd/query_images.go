@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/images"
+)
+
+var (
+	// querySaveImages is the --save-images directory; empty keeps images remote.
+	querySaveImages string
+	// queryMaxImages is the --max-images cap on saved images; zero is no cap.
+	queryMaxImages int
+)
+
+// validateImageSaving checks --save-images and --max-images. Saving images
+// implies --return-images.
+func validateImageSaving() error {
+	if queryMaxImages < 0 {
+		return clerrors.NewValidationError("max-images", strconv.Itoa(queryMaxImages), "must not be negative")
+	}
+	if querySaveImages != "" {
+		globalOpts.ReturnImages = true
+	}
+	return nil
+}
+
+// saveResponseImages downloads the images of res into the --save-images
+// directory and prints the manifest. Images that fail or are skipped are
+// listed with the reason and do not fail the query; only a directory that
+// cannot be created does.
+func saveResponseImages(res *perplexity.CompletionResponse) error {
+	if querySaveImages == "" || res == nil {
+		return nil
+	}
+	var urls []string
+	for _, img := range res.GetImages() {
+		if img.ImageURL != "" {
+			urls = append(urls, img.ImageURL)
+		}
+	}
+
+	out := imageManifestOutput()
+	if len(urls) == 0 {
+		fmt.Fprintln(out, "No images returned to save.")
+		return nil
+	}
+	results, err := images.Save(context.Background(), urls, images.Options{
+		Dir:     querySaveImages,
+		Name:    images.Slug(globalOpts.UserPrompt),
+		Formats: globalOpts.ImageFormats,
+		Max:     queryMaxImages,
+	})
+	if err != nil {
+		return clerrors.NewIOError("failed to save images", err)
+	}
+	writeImageManifest(out, results)
+	return nil
+}
+
+// imageManifestOutput returns where the saved images manifest is printed:
+// stdout after a console answer, stderr when stdout carries JSON or an
+// extracted value.
+func imageManifestOutput() io.Writer {
+	if globalOpts.OutputJSON || queryExtract != "" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// writeImageManifest prints the local path and source URL of each saved
+// image, then the images that were skipped or failed.
+func writeImageManifest(w io.Writer, results []images.Result) {
+	saved := 0
+	for _, r := range results {
+		if r.Err == nil {
+			saved++
+		}
+	}
+	fmt.Fprintf(w, "\nSaved %d of %d images:\n", saved, len(results))
+	for _, r := range results {
+		switch {
+		case r.Err == nil:
+			fmt.Fprintf(w, "  %s <- %s\n", r.Path, r.URL)
+		case errors.Is(r.Err, images.ErrFormatSkipped):
+			fmt.Fprintf(w, "  skipped %s: %v\n", r.URL, r.Err)
+		default:
+			fmt.Fprintf(w, "  failed  %s: %v\n", r.URL, r.Err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestHandleNonStreamingResponse_SaveImages(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	dir := filepath.Join(t.TempDir(), "images")
+	querySaveImages, queryMaxImages = dir, 0
+	t.Cleanup(func() { querySaveImages, queryMaxImages = "", 0 })
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/img/cat.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("\x89PNG\r\n\x1a\n fake"))
+	})
+	mux.HandleFunc("/chat/completions", func(w http.ResponseWriter, _ *http.Request) {
+		body := map[string]any{
+			"id": "test-id", "model": "sonar",
+			"choices": []map[string]any{{
+				"index": 0, "finish_reason": "stop",
+				"message": map[string]string{"role": "assistant", "content": "Cats."},
+			}},
+			"images": []map[string]any{
+				{"image_url": srv.URL + "/img/cat.png"},
+				{"image_url": srv.URL + "/img/gone.png"},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	})
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL + "/chat/completions")
+
+	globalOpts.OutputJSON = false
+	globalOpts.Render = "plain"
+	globalOpts.UserPrompt = "Show me cats"
+	out, err := runCapturingStdout(t, func() error {
+		return handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+	})
+	if err != nil {
+		t.Fatalf("handleNonStreamingResponse() error = %v, want failed images not to fail the query", err)
+	}
+
+	saved := filepath.Join(dir, "show-me-cats-1.png")
+	if _, err := os.Stat(saved); err != nil {
+		t.Errorf("image not saved: %v", err)
+	}
+	for _, want := range []string{
+		"Saved 1 of 2 images:",
+		saved + " <- " + srv.URL + "/img/cat.png",
+		"failed  " + srv.URL + "/img/gone.png",
+		"404",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("manifest missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestValidateImageSaving(t *testing.T) {
+	withGlobalOpts(t)
+	t.Cleanup(func() { querySaveImages, queryMaxImages = "", 0 })
+
+	querySaveImages, queryMaxImages = "out", -1
+	var valErr *clerrors.ValidationError
+	if err := validateImageSaving(); !errors.As(err, &valErr) || valErr.Field != "max-images" {
+		t.Errorf("validateImageSaving() error = %v, want a max-images ValidationError", err)
+	}
+
+	queryMaxImages = 2
+	globalOpts.ReturnImages = false
+	if err := validateImageSaving(); err != nil {
+		t.Fatalf("validateImageSaving() error = %v", err)
+	}
+	if !globalOpts.ReturnImages {
+		t.Error("--save-images did not enable --return-images")
+	}
+}
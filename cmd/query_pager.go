@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/output/color"
+	"github.com/sgaunet/pplx/pkg/output/pager"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/spf13/cobra"
+)
+
+// Flags of the query command limiting how much of the answer is shown.
+var (
+	queryNoPager  bool
+	queryMaxLines int
+)
+
+// truncationNotice ends an answer cut by --max-lines.
+const truncationNotice = "… %d more lines, use --output to save the full answer\n"
+
+// addPagerFlags registers --no-pager and --max-lines on the query command.
+func addPagerFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&queryNoPager, "no-pager", false,
+		"Print answers taller than the terminal directly instead of through $PAGER")
+	cmd.Flags().IntVar(&queryMaxLines, "max-lines", 0,
+		"Print at most this many lines of the answer (0 for no limit; never streamed)")
+}
+
+// validateAnswerOutput checks --max-lines and --output. Outside batch mode,
+// --output saves the printed answer, so it cannot be combined with --json
+// or --extract.
+func validateAnswerOutput() error {
+	if queryMaxLines < 0 {
+		return clerrors.NewValidationError("max-lines", strconv.Itoa(queryMaxLines), "cannot be negative")
+	}
+	if queryOutput == "" {
+		return nil
+	}
+	if globalOpts.OutputJSON {
+		return clerrors.NewValidationError("output", queryOutput, "cannot be combined with --json")
+	}
+	if queryExtract != "" {
+		return clerrors.NewValidationError("output", queryOutput, "cannot be combined with --extract")
+	}
+	return nil
+}
+
+// streamAnswer reports whether the answer is streamed. --output and
+// --max-lines need the whole answer, like several --choices.
+func streamAnswer() bool {
+	return globalOpts.Stream && queryChoices <= 1 && queryOutput == "" && queryMaxLines == 0
+}
+
+// answerRenderer builds the renderer of the answer: for stdout, or, with
+// --output, as if stdout were piped.
+func answerRenderer() (*render.Renderer, error) {
+	if queryOutput == "" {
+		return render.ForStdout(globalOpts.Render) //nolint:wrapcheck // wrapped by caller
+	}
+	colored := color.Decide(color.CurrentMode(), false, os.Getenv)
+	mode, err := render.Resolve(globalOpts.Render, colored)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by caller
+	}
+	opts := render.Options{Width: render.DefaultWidth, NoColor: !colored}
+	if colored {
+		opts.Theme = color.CurrentTheme()
+	}
+	return render.New(mode, opts), nil
+}
+
+// writeAnswer prints the rendered answer text to out. With --output it is
+// saved whole to the file. Otherwise it is cut to --max-lines, and shown
+// through the pager when out is a terminal too short for it, unless
+// --no-pager.
+func writeAnswer(ctx context.Context, out io.Writer, text string) error {
+	if queryOutput != "" {
+		if err := os.WriteFile(queryOutput, []byte(text), batchOutputPerms); err != nil {
+			return clerrors.NewIOError("cannot write "+queryOutput, err)
+		}
+		return nil
+	}
+
+	if shown, cut := pager.Truncate(text, queryMaxLines); cut > 0 {
+		text = shown + fmt.Sprintf(truncationNotice, cut)
+	}
+	if f, ok := out.(*os.File); ok && usePager(f, text) {
+		if err := pager.Page(ctx, pager.Command(globalOpts.Pager, os.Getenv), text, f, os.Stderr); err != nil {
+			return clerrors.NewIOError("failed to render response", err)
+		}
+		return nil
+	}
+	if _, err := io.WriteString(out, text); err != nil {
+		return clerrors.NewIOError("failed to render response", err)
+	}
+	return nil
+}
+
+// usePager reports whether text is shown through the pager: f is a
+// terminal, --no-pager is not given, and text is taller than the terminal.
+func usePager(f *os.File, text string) bool {
+	if queryNoPager || !render.IsTerminal(f) {
+		return false
+	}
+	height := render.Height(f)
+	return height > 0 && pager.Lines(text) > height
+}
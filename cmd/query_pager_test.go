@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// withAnswerOutputFlags resets --output, --max-lines and --no-pager when the
+// test ends.
+func withAnswerOutputFlags(t *testing.T) {
+	t.Helper()
+	withGlobalOpts(t)
+	t.Cleanup(func() { queryOutput, queryMaxLines, queryNoPager, queryExtract = "", 0, false, "" })
+}
+
+func TestValidateAnswerOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		maxLines  int
+		json      bool
+		extract   string
+		wantField string
+	}{
+		{"defaults", "", 0, false, "", ""},
+		{"max lines", "", 20, false, "", ""},
+		{"negative max lines", "", -1, false, "", "max-lines"},
+		{"output", "answer.md", 0, false, "", ""},
+		{"output with json", "answer.json", 0, true, "", "output"},
+		{"output with extract", "value.txt", 0, false, "items.0", "output"},
+		{"json without output", "", 0, true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withAnswerOutputFlags(t)
+			queryOutput, queryMaxLines, queryExtract = tt.output, tt.maxLines, tt.extract
+			globalOpts.OutputJSON = tt.json
+
+			err := validateAnswerOutput()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("validateAnswerOutput() error = %v", err)
+				}
+				return
+			}
+			var valErr *clerrors.ValidationError
+			if !errors.As(err, &valErr) || valErr.Field != tt.wantField {
+				t.Errorf("validateAnswerOutput() error = %v, want a %s ValidationError", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestStreamAnswer(t *testing.T) {
+	withAnswerOutputFlags(t)
+	globalOpts.Stream = true
+	if !streamAnswer() {
+		t.Error("streamAnswer() = false, want true with --stream")
+	}
+	queryMaxLines = 10
+	if streamAnswer() {
+		t.Error("streamAnswer() = true, want false with --max-lines")
+	}
+	queryMaxLines, queryOutput = 0, "answer.md"
+	if streamAnswer() {
+		t.Error("streamAnswer() = true, want false with --output")
+	}
+}
+
+func TestWriteAnswer(t *testing.T) {
+	withAnswerOutputFlags(t)
+	text := "one\ntwo\nthree\nfour\n"
+
+	queryMaxLines = 2
+	got, err := runCapturingStdout(t, func() error { return writeAnswer(context.Background(), os.Stdout, text) })
+	if err != nil {
+		t.Fatalf("writeAnswer() error = %v", err)
+	}
+	want := "one\ntwo\n… 2 more lines, use --output to save the full answer\n"
+	if got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+
+	queryOutput = filepath.Join(t.TempDir(), "answer.txt")
+	got, err = runCapturingStdout(t, func() error { return writeAnswer(context.Background(), os.Stdout, text) })
+	if err != nil {
+		t.Fatalf("writeAnswer() error = %v", err)
+	}
+	if got != "" {
+		t.Errorf("stdout = %q, want nothing with --output", got)
+	}
+	if data, _ := os.ReadFile(queryOutput); string(data) != text {
+		t.Errorf("--output file = %q, want the whole answer %q", data, text)
+	}
+}
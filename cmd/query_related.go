@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/citations"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/console"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/usage"
+)
+
+// queryInteractiveRelated is --interactive-related: offer the related
+// questions of the answer as follow-up queries.
+var queryInteractiveRelated bool
+
+// relatedMenuEnabled reports whether the related questions menu is shown
+// after the answer: --interactive-related on a terminal, for a single answer
+// printed as text.
+func relatedMenuEnabled() bool {
+	return queryInteractiveRelated && !globalOpts.OutputJSON && queryExtract == "" && queryChoices <= 1 &&
+		render.IsTerminal(os.Stdout) && render.IsTerminal(os.Stdin)
+}
+
+// relatedQuestions returns the related questions of res.
+func relatedQuestions(res *perplexity.CompletionResponse) []string {
+	if res == nil || res.RelatedQuestions == nil {
+		return nil
+	}
+	return *res.RelatedQuestions
+}
+
+// parseRelatedChoice parses a line typed at the related questions menu. An
+// empty line ends the menu; otherwise the line must be the number of one of
+// questions, which is returned.
+func parseRelatedChoice(line string, questions []string) (string, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", true, nil
+	}
+	n, err := strconv.Atoi(line)
+	if err != nil || n < 1 || n > len(questions) {
+		return "", false, clerrors.NewValidationError("choice", line,
+			fmt.Sprintf("enter a number between 1 and %d, or press Enter to finish", len(questions)))
+	}
+	return questions[n-1], false, nil
+}
+
+// followUpChat returns a chat holding the exchange of req and its answer
+// res, so that follow-up questions are asked in its context.
+func followUpChat(client *perplexity.Client, req *perplexity.CompletionRequest, res *perplexity.CompletionResponse,
+	opts chat.Options,
+) (*chat.Chat, error) {
+	messages := req.Messages
+	if len(messages) == 0 {
+		messages = textMessages(req.MultimodalMessages)
+	}
+	var system string
+	if len(messages) > 0 && messages[0].Role == "system" {
+		system, messages = messages[0].Content, messages[1:]
+	}
+
+	c := chat.NewChatWithOptions(client, system, opts)
+	for _, msg := range messages {
+		var err error
+		if msg.Role == "assistant" {
+			err = c.AddAgentMessage(msg.Content)
+		} else {
+			err = c.AddUserMessage(msg.Content)
+		}
+		if err != nil {
+			return nil, err //nolint:wrapcheck // chat errors describe the message
+		}
+	}
+	answer, err := output.FirstContent(res)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // already an APIError
+	}
+	if err := c.AddAgentMessage(answer); err != nil {
+		return nil, err //nolint:wrapcheck // chat errors describe the message
+	}
+	return c, nil
+}
+
+// textMessages keeps the text of multimodal messages; attachments are not
+// sent again with follow-up questions.
+func textMessages(multimodal []perplexity.MultimodalMessage) []perplexity.Message {
+	messages := make([]perplexity.Message, 0, len(multimodal))
+	for _, msg := range multimodal {
+		var parts []string
+		for _, content := range msg.Content {
+			if content.Text != nil {
+				parts = append(parts, *content.Text)
+			}
+		}
+		messages = append(messages, perplexity.Message{Role: msg.Role, Content: strings.Join(parts, "\n\n")})
+	}
+	return messages
+}
+
+// runRelatedMenu asks which of questions to follow up on, reading the
+// choice from in, and sends it with ask, which prints the answer and returns
+// its own related questions. It repeats until an empty line, the end of in,
+// or an answer without related questions.
+func runRelatedMenu(in io.Reader, out io.Writer, questions []string, ask func(string) ([]string, error)) error {
+	scanner := bufio.NewScanner(in)
+	for len(questions) > 0 {
+		if _, err := fmt.Fprintf(out, "\nFollow up on a related question [1-%d] (Enter to finish): ",
+			len(questions)); err != nil {
+			return clerrors.NewIOError("failed to write related questions menu", err)
+		}
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return clerrors.NewIOError("failed to read menu choice", err)
+			}
+			return nil
+		}
+		question, done, err := parseRelatedChoice(scanner.Text(), questions)
+		if done {
+			return nil
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(out, err)
+			continue
+		}
+		if _, err := fmt.Fprintf(out, "\n> %s\n\n", question); err != nil {
+			return clerrors.NewIOError("failed to write follow-up question", err)
+		}
+		if questions, err = ask(question); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// followRelated shows the related questions menu after the answer res to
+// req when --interactive-related applies. Follow-ups are sent as a chat
+// carrying the exchange so far and printed like the first answer.
+func followRelated(client *perplexity.Client, req *perplexity.CompletionRequest,
+	res *perplexity.CompletionResponse,
+) error {
+	if !relatedMenuEnabled() || len(relatedQuestions(res)) == 0 {
+		return nil
+	}
+	renderer, err := render.ForStdout(globalOpts.Render)
+	if err != nil {
+		return clerrors.NewValidationError("render", globalOpts.Render, err.Error())
+	}
+	style, err := citationStyle()
+	if err != nil {
+		return err
+	}
+	filter, err := citationFilter()
+	if err != nil {
+		return err
+	}
+
+	var stream *render.StreamRenderer
+	opts := newChatOptions()
+	opts.Model, opts.ReturnRelated = req.Model, true
+	if opts.Stream {
+		opts.OnStream = func(_, answer string) {
+			if err := stream.Update(answer); err != nil {
+				logger.Error("failed to render streaming content", "error", err)
+			}
+		}
+	}
+	c, err := followUpChat(client, req, res, opts)
+	if err != nil {
+		logger.Warn("cannot follow up on related questions", "error", err)
+		return nil
+	}
+	c.Use(usageHook{source: usage.SourceQuery})
+
+	ask := func(question string) ([]string, error) {
+		if err := c.AddUserMessage(question); err != nil {
+			return nil, clerrors.NewAPIError("failed to add user message", err)
+		}
+		if opts.Stream {
+			stream = render.NewStreamRenderer(renderer, os.Stdout)
+		}
+		next, err := sendFollowUp(c, stream, renderer, style, filter)
+		if err != nil {
+			return nil, err
+		}
+		return relatedQuestions(next), nil
+	}
+	return runRelatedMenu(os.Stdin, os.Stdout, relatedQuestions(res), ask)
+}
+
+// sendFollowUp sends the pending question of c and prints the answer,
+// streamed into stream when it is set, or else with its sources filtered.
+func sendFollowUp(c *chat.Chat, stream *render.StreamRenderer, renderer *render.Renderer,
+	style citations.Style, filter citations.Filter,
+) (*perplexity.CompletionResponse, error) {
+	if stream != nil {
+		res, err := c.Send()
+		if err != nil {
+			return nil, clerrors.NewAPIError("failed to send follow-up question", retry.ClassifyTimeout(err))
+		}
+		if err := stream.Flush(); err != nil {
+			logger.Error("failed to render streaming content", "error", err)
+		}
+		renderStreamedMetadata(os.Stdout, res, renderer, style)
+		return res, nil
+	}
+
+	var spinner *pterm.SpinnerPrinter
+	if showSpinner() {
+		spinner, _ = pterm.DefaultSpinner.Start("Waiting for response from perplexity...")
+	}
+	res, err := c.Send()
+	if err != nil {
+		if spinner != nil {
+			spinner.Fail("Request failed")
+		}
+		return nil, clerrors.NewAPIError("failed to send follow-up question", retry.ClassifyTimeout(err))
+	}
+	if spinner != nil {
+		spinner.Success("Response received")
+	}
+	filter.Apply(res)
+	if err := console.RenderAnswerWithCitations(res, os.Stdout, renderer, style); err != nil {
+		return nil, clerrors.NewIOError("failed to render response", err)
+	}
+	if err := renderSearches(os.Stdout, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestParseRelatedChoice(t *testing.T) {
+	questions := []string{"What is Go?", "Who made Go?"}
+	tests := []struct {
+		line     string
+		want     string
+		wantDone bool
+		wantErr  bool
+	}{
+		{"", "", true, false},
+		{"  \n", "", true, false},
+		{"1", "What is Go?", false, false},
+		{" 2 ", "Who made Go?", false, false},
+		{"0", "", false, true},
+		{"3", "", false, true},
+		{"two", "", false, true},
+	}
+	for _, tt := range tests {
+		got, done, err := parseRelatedChoice(tt.line, questions)
+		if got != tt.want || done != tt.wantDone || (err != nil) != tt.wantErr {
+			t.Errorf("parseRelatedChoice(%q) = %q, %v, %v; want %q, %v, error %v",
+				tt.line, got, done, err, tt.want, tt.wantDone, tt.wantErr)
+		}
+		var valErr *clerrors.ValidationError
+		if tt.wantErr && !errors.As(err, &valErr) {
+			t.Errorf("parseRelatedChoice(%q) error = %v, want a ValidationError", tt.line, err)
+		}
+	}
+}
+
+func TestRunRelatedMenu(t *testing.T) {
+	var asked []string
+	ask := func(question string) ([]string, error) {
+		asked = append(asked, question)
+		return []string{question + " next", "other"}, nil
+	}
+	var out bytes.Buffer
+	err := runRelatedMenu(strings.NewReader("2\nnine\n1\n\n3\n"), &out, []string{"first", "second"}, ask)
+	if err != nil {
+		t.Fatalf("runRelatedMenu() error = %v", err)
+	}
+	// "nine" is rejected and asked again; the empty line ends the menu.
+	if want := []string{"second", "second next"}; !slices.Equal(asked, want) {
+		t.Errorf("asked %q, want %q", asked, want)
+	}
+	if n := strings.Count(out.String(), "Follow up on a related question [1-2]"); n != 4 {
+		t.Errorf("menu shown %d times, want 4:\n%s", n, out.String())
+	}
+	if !strings.Contains(out.String(), "enter a number between 1 and 2") {
+		t.Errorf("invalid choice not reported:\n%s", out.String())
+	}
+}
+
+func TestRunRelatedMenu_Ends(t *testing.T) {
+	calls := 0
+	noMore := func(string) ([]string, error) { calls++; return nil, nil }
+	if err := runRelatedMenu(strings.NewReader("1\n1\n"), &bytes.Buffer{}, []string{"only"}, noMore); err != nil {
+		t.Fatalf("runRelatedMenu() error = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("asked %d questions, want the menu to end without related questions", calls)
+	}
+
+	if err := runRelatedMenu(strings.NewReader(""), &bytes.Buffer{}, []string{"only"}, noMore); err != nil {
+		t.Errorf("runRelatedMenu() at end of input error = %v", err)
+	}
+
+	sendErr := errors.New("boom")
+	failing := func(string) ([]string, error) { return nil, sendErr }
+	err := runRelatedMenu(strings.NewReader("1\n"), &bytes.Buffer{}, []string{"only"}, failing)
+	if !errors.Is(err, sendErr) {
+		t.Errorf("runRelatedMenu() error = %v, want %v", err, sendErr)
+	}
+}
+
+func TestFollowUpChat(t *testing.T) {
+	req := &perplexity.CompletionRequest{Model: "sonar", Messages: []perplexity.Message{
+		{Role: "system", Content: "Be brief"},
+		{Role: "user", Content: "What is Go?"},
+	}}
+	res := &perplexity.CompletionResponse{Choices: []perplexity.Choice{{
+		Message: perplexity.Message{Role: "assistant", Content: "A language."},
+	}}}
+
+	opts := chat.Options{Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0}
+	c, err := followUpChat(nil, req, res, opts)
+	if err != nil {
+		t.Fatalf("followUpChat() error = %v", err)
+	}
+	if err := c.AddUserMessage("Who made it?"); err != nil {
+		t.Fatalf("AddUserMessage() error = %v", err)
+	}
+	next, err := c.Request()
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	want := []perplexity.Message{
+		{Role: "system", Content: "Be brief"},
+		{Role: "user", Content: "What is Go?"},
+		{Role: "assistant", Content: "A language."},
+		{Role: "user", Content: "Who made it?"},
+	}
+	if !slices.Equal(next.Messages, want) {
+		t.Errorf("follow-up messages = %+v, want %+v", next.Messages, want)
+	}
+
+	multimodal := &perplexity.CompletionRequest{MultimodalMessages: []perplexity.MultimodalMessage{{
+		Role: "user",
+		Content: []perplexity.Content{
+			perplexity.NewTextContent("Describe it"),
+			perplexity.NewImageURLContent("https://x/y.png"),
+		},
+	}}}
+	if c, err = followUpChat(nil, multimodal, res, opts); err != nil {
+		t.Fatalf("followUpChat() with attachments error = %v", err)
+	}
+	if got := c.Messages.GetMessages(); len(got) != 2 || got[0].Content != "Describe it" {
+		t.Errorf("history = %+v, want the text of the attachment message", got)
+	}
+
+	if _, err := followUpChat(nil, req, &perplexity.CompletionResponse{}, opts); err == nil {
+		t.Error("followUpChat() without an answer returned no error")
+	}
+}
+
+func TestFollowRelated_NotATerminal(t *testing.T) {
+	withGlobalOpts(t)
+	globalOpts.OutputJSON = false
+	queryInteractiveRelated = true
+	t.Cleanup(func() { queryInteractiveRelated = false })
+
+	questions := []string{"next?"}
+	res := &perplexity.CompletionResponse{RelatedQuestions: &questions}
+	// Test output is not a terminal, so the menu is skipped without reading stdin.
+	if relatedMenuEnabled() {
+		t.Skip("test output is a terminal")
+	}
+	if err := followRelated(nil, newTestRequest(), res); err != nil {
+		t.Errorf("followRelated() error = %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/citations"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/console"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/usage"
+)
+
+const (
+	// maxRepeat bounds --repeat, since every run is billed.
+	maxRepeat = 20
+	// seedVaryStep is the temperature difference between consecutive runs
+	// with --seed-vary.
+	seedVaryStep = 0.05
+	// maxRequestTemperature is the highest temperature the API accepts; it
+	// must stay below 2.
+	maxRequestTemperature = 1.99
+)
+
+// Repeat flags of the query command.
+var (
+	// queryRepeat is --repeat, the number of times the prompt is sent.
+	queryRepeat = 1
+	// querySeedVary is --seed-vary: spread the temperature of the runs.
+	querySeedVary bool
+)
+
+// repeatRun is the outcome of one --repeat run.
+type repeatRun struct {
+	// Run numbers the run from 1.
+	Run         int
+	Temperature float64
+	Response    *perplexity.CompletionResponse
+	Elapsed     time.Duration
+	// Err is set when the run failed or was cancelled.
+	Err error
+}
+
+// repeatResult is the JSON document of a run in the --repeat --json array:
+// the stable output fields of the answer, or the error the run failed with.
+type repeatResult struct {
+	Run         int     `json:"run"`
+	Temperature float64 `json:"temperature"`
+	*output.Result
+	Error string `json:"error,omitempty"`
+}
+
+// validateRepeat checks --repeat and --seed-vary. The runs are compared
+// side by side, so options that read or save a single answer are rejected.
+func validateRepeat() error {
+	if queryRepeat < 1 || queryRepeat > maxRepeat {
+		return clerrors.NewValidationError("repeat", strconv.Itoa(queryRepeat),
+			fmt.Sprintf("must be between 1 and %d", maxRepeat))
+	}
+	if queryRepeat == 1 {
+		if querySeedVary {
+			return clerrors.NewValidationError("seed-vary", "true", "requires --repeat of 2 or more")
+		}
+		return nil
+	}
+	value := strconv.Itoa(queryRepeat)
+	switch {
+	case queryChoices > 1:
+		return clerrors.NewValidationError("repeat", value, "cannot be combined with --choices")
+	case queryExtract != "":
+		return clerrors.NewValidationError("repeat", value, "cannot be combined with --extract")
+	case querySaveImages != "":
+		return clerrors.NewValidationError("repeat", value, "cannot be combined with --save-images")
+	case globalOpts.JSONFields != "":
+		return clerrors.NewValidationError("repeat", value, "cannot be combined with --json-fields")
+	case queryConcurrency < 1:
+		return clerrors.NewValidationError("concurrency", strconv.Itoa(queryConcurrency), "must be at least 1")
+	}
+	return nil
+}
+
+// repeatCount returns how many requests the query sends.
+func repeatCount() int {
+	return max(queryRepeat, 1)
+}
+
+// repeatTemperature returns the temperature of run (from 0) of n. With
+// --seed-vary the runs are spread seedVaryStep apart around base, within
+// the range the API accepts; otherwise every run uses base.
+func repeatTemperature(base float64, run, n int) float64 {
+	if !querySeedVary {
+		return base
+	}
+	t := base + seedVaryStep*(float64(run)-float64(n-1)/2)
+	t = math.Round(t*100) / 100
+	return min(max(t, 0), maxRequestTemperature)
+}
+
+// runRepeat implements `pplx query --repeat`: it sends req --repeat times,
+// at most --concurrency at once, and prints every answer followed by a
+// usage summary, or a JSON array with --json. Ctrl-C cancels the requests
+// still outstanding; the completed runs are printed all the same.
+func runRepeat(ctx context.Context, client *perplexity.Client, req *perplexity.CompletionRequest) error {
+	var renderer *render.Renderer
+	if !globalOpts.OutputJSON {
+		var err error
+		if renderer, err = render.ForStdout(globalOpts.Render); err != nil {
+			return clerrors.NewValidationError("render", globalOpts.Render, err.Error())
+		}
+	}
+	style, err := citationStyle()
+	if err != nil {
+		return err
+	}
+	filter, err := citationFilter()
+	if err != nil {
+		return err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var spinner *pterm.SpinnerPrinter
+	if showSpinner() {
+		spinner, _ = pterm.DefaultSpinner.Start(repeatProgress(0, queryRepeat))
+	}
+	start := time.Now()
+	runs := sendRepeats(ctx, req, queryRepeat, queryConcurrency,
+		func(ctx context.Context, next *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error) {
+			return sendOnce(ctx, client, next)
+		},
+		func(done int) {
+			if spinner != nil {
+				spinner.UpdateText(repeatProgress(done, queryRepeat))
+			}
+		})
+	wall := time.Since(start)
+
+	completed := 0
+	for _, run := range runs {
+		if run.Err == nil {
+			completed++
+			filter.Apply(run.Response)
+		}
+	}
+	if spinner != nil {
+		spinner.Success(fmt.Sprintf("%d of %d runs completed", completed, len(runs)))
+	}
+
+	if globalOpts.OutputJSON {
+		err = writeRepeatJSON(os.Stdout, runs)
+	} else {
+		err = writeRepeatRuns(os.Stdout, runs, renderer, style, wall)
+	}
+	if err != nil {
+		return clerrors.NewIOError("failed to render responses", err)
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: completed %d of %d runs", clerrors.ErrRepeatInterrupted, completed, len(runs))
+	}
+	if completed == 0 {
+		return clerrors.NewAPIError("failed to send completion request", retry.ClassifyTimeout(runs[0].Err))
+	}
+	return nil
+}
+
+// repeatProgress is the spinner text after done of n runs completed.
+func repeatProgress(done, n int) string {
+	return fmt.Sprintf("Waiting for responses from perplexity... %d/%d complete", done, n)
+}
+
+// sendRepeats sends n copies of req with send, at most concurrency at once,
+// and returns the runs in order. progress is called from the calling
+// goroutine with the number of finished runs. Cancelling ctx cancels the
+// requests in flight and those not started, which fail with the context
+// error.
+func sendRepeats(ctx context.Context, req *perplexity.CompletionRequest, n, concurrency int,
+	send func(context.Context, *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error),
+	progress func(done int),
+) []repeatRun {
+	reqs := make([]*perplexity.CompletionRequest, n)
+	for i := range reqs {
+		next := *req
+		next.Temperature = repeatTemperature(req.Temperature, i, n)
+		reqs[i] = &next
+	}
+	return sendRequests(ctx, reqs, concurrency, send, progress)
+}
+
+// sendRequests sends every request of reqs with send, at most concurrency
+// at once, and returns their runs in the same order. progress and
+// cancellation work as for sendRepeats.
+func sendRequests(ctx context.Context, reqs []*perplexity.CompletionRequest, concurrency int,
+	send func(context.Context, *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error),
+	progress func(done int),
+) []repeatRun {
+	runs := make([]repeatRun, len(reqs))
+	finished := make(chan struct{}, len(reqs))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+
+	go func() {
+		for i := range runs {
+			runs[i] = repeatRun{Run: i + 1, Temperature: reqs[i].Temperature}
+			select {
+			case <-ctx.Done():
+				runs[i].Err = ctx.Err()
+				finished <- struct{}{}
+				continue
+			case sem <- struct{}{}:
+			}
+			wg.Add(1)
+			go func(run *repeatRun, req *perplexity.CompletionRequest) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				run.Response, run.Elapsed, run.Err = sendRun(ctx, req, send)
+				finished <- struct{}{}
+			}(&runs[i], reqs[i])
+		}
+		wg.Wait()
+		close(finished)
+	}()
+
+	done := 0
+	for range finished {
+		done++
+		progress(done)
+	}
+	return runs
+}
+
+// sendRun sends req, continued per --auto-continue, and tracks its usage.
+func sendRun(ctx context.Context, req *perplexity.CompletionRequest,
+	send func(context.Context, *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error),
+) (*perplexity.CompletionResponse, time.Duration, error) {
+	start := time.Now()
+	res, err := send(ctx, req)
+	if err != nil {
+		return nil, time.Since(start), retry.ClassifyTimeout(err) //nolint:wrapcheck // reported per run
+	}
+	usage.Track(usage.SourceQuery, res)
+	res = continueAnswer(req, res, usage.SourceQuery,
+		func(cont *perplexity.CompletionRequest, _ string) (*perplexity.CompletionResponse, error) {
+			return send(ctx, cont)
+		})
+	if _, err := output.FirstContent(res); err != nil {
+		return nil, time.Since(start), err //nolint:wrapcheck // already an APIError
+	}
+	return res, time.Since(start), nil
+}
+
+// writeRepeatRuns prints every run under a divider, then a table of the
+// usage and time of each run.
+func writeRepeatRuns(w io.Writer, runs []repeatRun, renderer *render.Renderer, style citations.Style,
+	wall time.Duration,
+) error {
+	for _, run := range runs {
+		if _, err := fmt.Fprintf(w, "\n──── Run %d of %d (temperature %g) ────\n\n",
+			run.Run, len(runs), run.Temperature); err != nil {
+			return fmt.Errorf("error writing run divider to output: %w", err)
+		}
+		if run.Err != nil {
+			if _, err := fmt.Fprintln(w, repeatRunError(run.Err)); err != nil {
+				return fmt.Errorf("error writing run to output: %w", err)
+			}
+			continue
+		}
+		if err := console.RenderAnswerWithCitations(run.Response, w, renderer, style); err != nil {
+			return err //nolint:wrapcheck // wrapped by the caller
+		}
+		if err := renderSearches(w, run.Response); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintln(w, "\nSummary:"); err != nil {
+		return fmt.Errorf("error writing summary to output: %w", err)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, dryRunTabPadding, ' ', 0)
+	fmt.Fprintln(tw, "  Run\tTemperature\tPrompt\tCompletion\tTotal\tTime")
+	var prompt, completion, total int
+	for _, run := range runs {
+		if run.Err != nil {
+			fmt.Fprintf(tw, "  %d\t%g\t-\t-\t-\t%s\n", run.Run, run.Temperature, repeatRunError(run.Err))
+			continue
+		}
+		u := run.Response.Usage
+		prompt, completion, total = prompt+u.PromptTokens, completion+u.CompletionTokens, total+u.TotalTokens
+		fmt.Fprintf(tw, "  %d\t%g\t%d\t%d\t%d\t%s\n", run.Run, run.Temperature,
+			u.PromptTokens, u.CompletionTokens, u.TotalTokens, run.Elapsed.Round(time.Millisecond))
+	}
+	fmt.Fprintf(tw, "  All\t\t%d\t%d\t%d\t%s wall time\n", prompt, completion, total, wall.Round(time.Millisecond))
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("error writing summary to output: %w", err)
+	}
+	return nil
+}
+
+// repeatRunError describes why a run has no answer.
+func repeatRunError(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return "cancelled"
+	}
+	return "failed: " + err.Error()
+}
+
+// writeRepeatJSON prints the runs as an indented JSON array.
+func writeRepeatJSON(w io.Writer, runs []repeatRun) error {
+	results := make([]repeatResult, 0, len(runs))
+	for _, run := range runs {
+		result := repeatResult{Run: run.Run, Temperature: run.Temperature}
+		if run.Err != nil {
+			result.Error = repeatRunError(run.Err)
+		} else {
+			result.Result = output.FromResponse(run.Response, run.Elapsed)
+		}
+		results = append(results, result)
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	if _, err := fmt.Fprintln(w, string(data)); err != nil {
+		return fmt.Errorf("error writing JSON to output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func withRepeat(t *testing.T, n int, seedVary bool) {
+	t.Helper()
+	queryRepeat, querySeedVary = n, seedVary
+	t.Cleanup(func() { queryRepeat, querySeedVary = 1, false })
+}
+
+func TestRepeatTemperature(t *testing.T) {
+	withRepeat(t, 5, false)
+	if got := repeatTemperature(0.7, 3, 5); got != 0.7 {
+		t.Errorf("without --seed-vary, temperature = %v, want 0.7", got)
+	}
+
+	querySeedVary = true
+	var got []float64
+	for run := range 5 {
+		got = append(got, repeatTemperature(0.7, run, 5))
+	}
+	want := []float64{0.6, 0.65, 0.7, 0.75, 0.8}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("temperatures = %v, want %v", got, want)
+			break
+		}
+	}
+	if low := repeatTemperature(0, 0, 5); low != 0 {
+		t.Errorf("temperature = %v, want it clamped to 0", low)
+	}
+	if high := repeatTemperature(1.98, 4, 5); high != maxRequestTemperature {
+		t.Errorf("temperature = %v, want it clamped to %v", high, maxRequestTemperature)
+	}
+}
+
+func TestValidateRepeat(t *testing.T) {
+	withGlobalOpts(t)
+	t.Cleanup(func() { queryChoices, queryExtract = 1, "" })
+
+	tests := []struct {
+		name     string
+		setup    func()
+		wantFlag string
+	}{
+		{"zero", func() { withRepeat(t, 0, false) }, "repeat"},
+		{"too many", func() { withRepeat(t, maxRepeat+1, false) }, "repeat"},
+		{"seed-vary alone", func() { withRepeat(t, 1, true) }, "seed-vary"},
+		{"with choices", func() { withRepeat(t, 3, false); queryChoices = 2 }, "repeat"},
+		{"with extract", func() { withRepeat(t, 3, false); queryExtract = "a" }, "repeat"},
+		{"valid", func() { withRepeat(t, 3, true) }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queryChoices, queryExtract = 1, ""
+			tt.setup()
+			err := validateRepeat()
+			if tt.wantFlag == "" {
+				if err != nil {
+					t.Errorf("validateRepeat() error = %v", err)
+				}
+				return
+			}
+			var valErr *clerrors.ValidationError
+			if !errors.As(err, &valErr) || valErr.Field != tt.wantFlag {
+				t.Errorf("validateRepeat() error = %v, want a %s ValidationError", err, tt.wantFlag)
+			}
+		})
+	}
+}
+
+func TestSendRepeats_Concurrency(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	withRepeat(t, 6, true)
+
+	var inFlight, peak atomic.Int32
+	send := func(_ context.Context, req *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return &perplexity.CompletionResponse{Model: "sonar", Choices: []perplexity.Choice{{
+			FinishReason: "stop",
+			Message:      perplexity.Message{Content: fmt.Sprintf("answer at %g", req.Temperature)},
+		}}}, nil
+	}
+	var progress []int
+	req := newTestRequest()
+	req.Temperature = 0.5
+	runs := sendRepeats(context.Background(), req, 6, 2, send, func(done int) { progress = append(progress, done) })
+
+	if peak.Load() > 2 {
+		t.Errorf("%d requests in flight, want at most 2", peak.Load())
+	}
+	if len(progress) != 6 || progress[5] != 6 {
+		t.Errorf("progress = %v, want 1 to 6", progress)
+	}
+	for i, run := range runs {
+		if run.Run != i+1 || run.Err != nil {
+			t.Fatalf("run %d = %+v", i, run)
+		}
+		if want := fmt.Sprintf("answer at %g", run.Temperature); run.Response.GetLastContent() != want {
+			t.Errorf("run %d answered %q, want it sent at temperature %g", run.Run,
+				run.Response.GetLastContent(), run.Temperature)
+		}
+	}
+	if runs[0].Temperature >= runs[5].Temperature {
+		t.Errorf("temperatures not spread: %v .. %v", runs[0].Temperature, runs[5].Temperature)
+	}
+}
+
+func TestSendRepeats_Cancel(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	send := func(ctx context.Context, _ *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error) {
+		if calls.Add(1) == 1 {
+			return &perplexity.CompletionResponse{Choices: []perplexity.Choice{{
+				Message: perplexity.Message{Content: "first"},
+			}}}, nil
+		}
+		cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	runs := sendRepeats(ctx, newTestRequest(), 5, 1, send, func(int) {})
+
+	if runs[0].Err != nil || runs[0].Response.GetLastContent() != "first" {
+		t.Errorf("completed run = %+v", runs[0])
+	}
+	for _, run := range runs[1:] {
+		if !errors.Is(run.Err, context.Canceled) {
+			t.Errorf("run %d error = %v, want it cancelled", run.Run, run.Err)
+		}
+	}
+	if calls.Load() > 3 {
+		t.Errorf("%d requests sent after cancellation", calls.Load())
+	}
+}
+
+func TestRunRepeat(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	withRepeat(t, 3, false)
+	globalOpts.Quiet = true // no spinner
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"id":"id-%d","model":"sonar","choices":[{"index":0,"finish_reason":"stop",`+
+			`"message":{"role":"assistant","content":"Answer %d"}}],`+
+			`"usage":{"prompt_tokens":10,"completion_tokens":%d,"total_tokens":%d}}`, n, n, n, 10+n)
+	}))
+	defer srv.Close()
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	globalOpts.OutputJSON = false
+	globalOpts.Render = "plain"
+	out, err := runCapturingStdout(t, func() error { return runRepeat(context.Background(), client, newTestRequest()) })
+	if err != nil {
+		t.Fatalf("runRepeat() error = %v", err)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("sent %d requests, want 3", requests.Load())
+	}
+	for _, want := range []string{"──── Run 1 of 3", "──── Run 3 of 3", "Summary:", "Completion", "All", "30"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	globalOpts.OutputJSON = true
+	out, err = runCapturingStdout(t, func() error { return runRepeat(context.Background(), client, newTestRequest()) })
+	if err != nil {
+		t.Fatalf("runRepeat() with --json error = %v", err)
+	}
+	var results []struct {
+		Run         int     `json:"run"`
+		Temperature float64 `json:"temperature"`
+		Content     string  `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, out)
+	}
+	if len(results) != 3 || results[2].Run != 3 || !strings.HasPrefix(results[0].Content, "Answer") {
+		t.Errorf("results = %+v", results)
+	}
+}
@@ -9,8 +9,8 @@ import (
 )
 
 // TestSearchRecencyImagesIncompatibility tests that when both search-recency
-// and return-images are specified, a clear message is shown explaining the
-// auto-correction behavior (Option 2 from issue #87)
+// and return-images are specified, a clear message is shown naming the
+// dropped option and the image conflict policy (prefer_images by default)
 func TestSearchRecencyImagesIncompatibility(t *testing.T) {
 	// Skip if API key is not set
 	if os.Getenv("PPLX_API_KEY") == "" {
@@ -50,17 +50,11 @@ func TestSearchRecencyImagesIncompatibility(t *testing.T) {
 	_ = err
 
 	// Check that the expected message is present
-	expectedMessage := "Note: When using --return-images, search-recency is automatically disabled"
+	expectedMessage := "search_recency dropped (image_conflict_policy: prefer_images)"
 	if !strings.Contains(output, expectedMessage) {
 		t.Errorf("Expected output to contain: %q\nGot: %s", expectedMessage, output)
 	}
 
-	// Also check for the second line
-	expectedMessage2 := "Proceeding with image search..."
-	if !strings.Contains(output, expectedMessage2) {
-		t.Errorf("Expected output to contain: %q\nGot: %s", expectedMessage2, output)
-	}
-
 	// Reset flags
 	globalOpts.SearchRecency = ""
 	globalOpts.ReturnImages = false
@@ -105,7 +99,7 @@ func TestSearchRecencyWithoutImages(t *testing.T) {
 	_ = err
 
 	// Check that the warning message is NOT present
-	unexpectedMessage := "Note: When using --return-images"
+	unexpectedMessage := "image_conflict_policy"
 	if strings.Contains(output, unexpectedMessage) {
 		t.Errorf("Did not expect output to contain: %q\nGot: %s", unexpectedMessage, output)
 	}
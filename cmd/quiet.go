@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/sgaunet/pplx/pkg/validation"
+	"github.com/spf13/cobra"
+)
+
+// addQuietFlags registers --quiet on the query command.
+func addQuietFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&globalOpts.Quiet, "quiet", false,
+		"Print only the response and errors: no spinner, notes, or progress messages")
+}
+
+// applyQuiet silences cmd under --quiet: cobra no longer echoes errors or
+// prints usage, which leaves the single line from printError, and notes
+// from shared packages go to io.Discard.
+func applyQuiet(cmd *cobra.Command) {
+	if !globalOpts.Quiet {
+		return
+	}
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	validation.DateNotes = io.Discard
+}
+
+// chatter returns w, or io.Discard under --quiet. Use it for anything
+// printed besides the response and errors.
+func chatter(w io.Writer) io.Writer {
+	if globalOpts.Quiet {
+		return io.Discard
+	}
+	return w
+}
+
+// showSpinner reports whether to animate a spinner while waiting for the API.
+func showSpinner() bool {
+	return !globalOpts.OutputJSON && !globalOpts.Quiet
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/output/color"
+)
+
+// queryStrictFormat is --strict-format: fail when the answer does not match
+// --response-format-regex.
+var queryStrictFormat bool
+
+// resolveJSONSchemaFile sets --response-format-json-schema to the content of
+// --json-schema-file when it is given, so long schemas need no shell quoting.
+func resolveJSONSchemaFile() error {
+	path := globalOpts.ResponseFormatJSONSchemaFile
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // path is supplied by the user on purpose
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return clerrors.NewValidationError("json-schema-file", path, "file not found")
+		}
+		return clerrors.NewIOError("cannot read "+path, err)
+	}
+	globalOpts.ResponseFormatJSONSchema = string(data)
+	return nil
+}
+
+// validateStrictFormat checks that --strict-format has a format it can
+// check: a --response-format-regex that Go can compile.
+func validateStrictFormat() error {
+	if !queryStrictFormat {
+		return nil
+	}
+	if globalOpts.ResponseFormatRegex == "" {
+		return clerrors.NewValidationError("strict-format", "true", "requires --response-format-regex")
+	}
+	if _, err := output.MatchRegex(globalOpts.ResponseFormatRegex, ""); err != nil {
+		return clerrors.NewValidationError("response-format-regex", globalOpts.ResponseFormatRegex, err.Error())
+	}
+	return nil
+}
+
+// regexFormatMatch checks the answer of res against --response-format-regex.
+// Returns nil when no regex was requested, and an error when Go cannot
+// compile it.
+func regexFormatMatch(res *perplexity.CompletionResponse) (*output.FormatMatch, error) {
+	if globalOpts.ResponseFormatRegex == "" {
+		return nil, nil //nolint:nilnil // no format to check
+	}
+	content, err := output.FirstContent(res)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // already an APIError
+	}
+	m, err := output.MatchRegex(globalOpts.ResponseFormatRegex, content)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // describes the pattern
+	}
+	return &m, nil
+}
+
+// verifyResponseFormat reports an answer of res that does not match
+// --response-format-regex: on stderr, where the answer departs from the
+// pattern, and with --strict-format an error wrapping
+// clerrors.ErrFormatMismatch. Without --strict-format the report is a note,
+// hidden by --quiet.
+func verifyResponseFormat(res *perplexity.CompletionResponse) error {
+	m, err := regexFormatMatch(res)
+	if err != nil {
+		logger.Warn("answer not checked against the response format regex", "error", err)
+		return nil
+	}
+	if m == nil || m.Matched {
+		return nil
+	}
+	content, _ := output.FirstContent(res)
+	w := io.Writer(os.Stderr)
+	if !queryStrictFormat {
+		w = chatter(w)
+	}
+	writeFormatMismatch(w, content, *m)
+	if queryStrictFormat {
+		return fmt.Errorf("%w: --response-format-regex %q", clerrors.ErrFormatMismatch,
+			globalOpts.ResponseFormatRegex)
+	}
+	return nil
+}
+
+// reportChatFormat shows where a chat answer departs from the regex
+// response format of the chat, as a note on stderr.
+func reportChatFormat(c *chat.Chat, response *perplexity.CompletionResponse) {
+	m, err := c.CheckFormat(response)
+	if err != nil {
+		logger.Warn("answer not checked against the response format regex", "error", err)
+		return
+	}
+	if m == nil || m.Matched {
+		return
+	}
+	content, _ := output.FirstContent(response)
+	writeFormatMismatch(chatter(os.Stderr), content, *m)
+}
+
+// writeFormatMismatch prints m with output.WriteMismatch, in color when
+// stderr is colored.
+func writeFormatMismatch(w io.Writer, content string, m output.FormatMatch) {
+	if err := output.WriteMismatch(w, content, m, color.Enabled(os.Stderr)); err != nil {
+		logger.Error("failed to report format mismatch", "error", err)
+	}
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// newAnswerServer returns a client whose requests are all answered with
+// content.
+func newAnswerServer(t *testing.T, content string) *perplexity.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"id":"id","model":"sonar","choices":[{"index":0,"finish_reason":"stop",`+
+			`"message":{"role":"assistant","content":%q}}],`+
+			`"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`, content)
+	}))
+	t.Cleanup(srv.Close)
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	return client
+}
+
+// captureStderr runs fn and returns what it printed to stderr.
+func captureStderr(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+	oldStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	outputChan := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		outputChan <- string(data)
+	}()
+	err := fn()
+	_ = w.Close()
+	os.Stderr = oldStderr
+	return <-outputChan, err
+}
+
+func TestValidateStrictFormat(t *testing.T) {
+	withGlobalOpts(t)
+	t.Cleanup(func() { queryStrictFormat = false })
+
+	tests := []struct {
+		name     string
+		strict   bool
+		regex    string
+		wantFlag string
+	}{
+		{"not strict", false, "", ""},
+		{"strict without regex", true, "", "strict-format"},
+		{"strict with a regex Go cannot compile", true, `(?=a)b`, "response-format-regex"},
+		{"strict with regex", true, `\d+`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queryStrictFormat, globalOpts.ResponseFormatRegex = tt.strict, tt.regex
+			err := validateStrictFormat()
+			if tt.wantFlag == "" {
+				if err != nil {
+					t.Errorf("validateStrictFormat() error = %v", err)
+				}
+				return
+			}
+			var valErr *clerrors.ValidationError
+			if !errors.As(err, &valErr) || valErr.Field != tt.wantFlag {
+				t.Errorf("validateStrictFormat() error = %v, want a %s ValidationError", err, tt.wantFlag)
+			}
+		})
+	}
+}
+
+func TestResolveJSONSchemaFile(t *testing.T) {
+	withGlobalOpts(t)
+	schema := `{"type":"object","properties":{"name":{"type":"string"}}}`
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(schema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	globalOpts.ResponseFormatJSONSchemaFile = path
+	if err := resolveJSONSchemaFile(); err != nil {
+		t.Fatalf("resolveJSONSchemaFile() error = %v", err)
+	}
+	if globalOpts.ResponseFormatJSONSchema != schema {
+		t.Errorf("ResponseFormatJSONSchema = %q, want the file content", globalOpts.ResponseFormatJSONSchema)
+	}
+
+	globalOpts.ResponseFormatJSONSchemaFile = filepath.Join(t.TempDir(), "missing.json")
+	var valErr *clerrors.ValidationError
+	if err := resolveJSONSchemaFile(); !errors.As(err, &valErr) || valErr.Field != "json-schema-file" {
+		t.Errorf("resolveJSONSchemaFile() error = %v, want a json-schema-file ValidationError", err)
+	}
+}
+
+func TestResponseFormat_JSONSchema(t *testing.T) {
+	withGlobalOpts(t)
+	warnings.Default.Reset()
+	t.Cleanup(warnings.Default.Reset)
+	globalOpts.UserPrompt = "test"
+	globalOpts.Model = "sonar"
+
+	globalOpts.ResponseFormatJSONSchema = `{"type":"object","properties":{"a":{}},"required":["a","b"]}`
+	err := validateResponseFormats()
+	if got := getExitCode(err); got != exitCodeValidation {
+		t.Errorf("exit code = %d, want %d for %v", got, exitCodeValidation, err)
+	}
+	if err == nil || !strings.Contains(err.Error(), `required entry "b" is not in properties`) {
+		t.Errorf("error = %v, want the schema problem", err)
+	}
+
+	globalOpts.ResponseFormatJSONSchema = `{"type":"object","propertees":{}}`
+	req, err := buildAllOptions()
+	if err != nil || req.ResponseFormat == nil || req.ResponseFormat.Type != "json_schema" {
+		t.Fatalf("buildAllOptions() = %+v, %v; want a json_schema response format", req, err)
+	}
+	if all := warnings.Default.All(); len(all) != 1 || all[0].Code != warnings.CodeJSONSchema {
+		t.Errorf("warnings = %+v, want one json_schema warning", all)
+	}
+}
+
+func TestHandleNonStreamingResponse_FormatMismatch(t *testing.T) {
+	withGlobalOpts(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { queryStrictFormat = false })
+	globalOpts.Quiet, globalOpts.Render = true, "plain"
+	client := newAnswerServer(t, "Answer: 42")
+
+	tests := []struct {
+		name      string
+		regex     string
+		strict    bool
+		wantMatch any
+		wantNote  bool
+		wantCode  int
+	}{
+		{"no regex", "", false, nil, false, exitCodeSuccess},
+		{"match", `Answer: \d+`, true, true, false, exitCodeSuccess},
+		{"mismatch", `Answer: [a-z]+`, false, false, false, exitCodeSuccess},
+		{"strict mismatch", `^Answer: [a-z]+$`, true, false, true, exitCodeFormatMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			globalOpts.ResponseFormatRegex, queryStrictFormat = tt.regex, tt.strict
+
+			globalOpts.OutputJSON = true
+			var out string
+			stderr, err := captureStderr(t, func() error {
+				var err error
+				out, err = runCapturingStdout(t, func() error {
+					return handleNonStreamingResponse(context.Background(), client, newTestRequest(), os.Stdout)
+				})
+				return err
+			})
+			if got := getExitCode(err); got != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (err: %v)", got, tt.wantCode, err)
+			}
+			var result map[string]any
+			if err := json.Unmarshal([]byte(out), &result); err != nil {
+				t.Fatalf("output is not JSON: %v\n%s", err, out)
+			}
+			if got, ok := result["format_match"]; !ok || got != tt.wantMatch {
+				t.Errorf("format_match = %v, want %v", got, tt.wantMatch)
+			}
+			// --quiet hides the report unless --strict-format makes it an error.
+			if got := strings.Contains(stderr, "column 9"); got != tt.wantNote {
+				t.Errorf("stderr = %q, want the mismatch report: %v", stderr, tt.wantNote)
+			}
+		})
+	}
+}
@@ -2,26 +2,28 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/sgaunet/pplx/pkg/citations"
 	"github.com/sgaunet/pplx/pkg/clerrors"
 	"github.com/sgaunet/pplx/pkg/completion"
 	"github.com/sgaunet/pplx/pkg/config"
 	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/mcp"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/output/color"
+	"github.com/sgaunet/pplx/pkg/render"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
-const (
-	// Exit codes for different error types.
-	exitCodeSuccess         = 0
-	exitCodeGeneral         = 1
-	exitCodeValidation      = 2
-	exitCodeAPI             = 3
-	exitCodeConfiguration   = 4
-	exitCodeIO              = 5
-)
+// logFilePermission is applied to a log file created by --log-file.
+const logFilePermission = 0o600
 
 var (
 	// globalOpts contains all global flag values for the application.
@@ -35,18 +37,34 @@ var rootCmd = &cobra.Command{
 	Long: `Program to interact with the Perplexity API.
 	
 	You can use it to chat with the AI or to query it.`,
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		if err := validateLang(); err != nil {
+			return err
+		}
+		if err := applyColorMode(); err != nil {
+			return err
+		}
+		return initLogger()
+	},
 }
 
+// logFile is the file opened for --log-file, closed when the command exits.
+var logFile *os.File
+
 // Execute runs the root command.
 func Execute() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
-
-	// Initialize logger before command execution
-	if err := initLogger(); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to initialize logger: %v\n", err)
-	}
+	markUsageErrors(rootCmd)
+	config.PassphrasePrompt = terminalPassphrasePrompt()
+	applyLanguage(os.Args[1:])
 
 	err := rootCmd.Execute()
+	if werr := reportWarnings(); err == nil {
+		err = werr
+	}
+	if logFile != nil {
+		_ = logFile.Close()
+	}
 	if err != nil {
 		printError(err)
 		exitCode := getExitCode(err)
@@ -54,13 +72,20 @@ func Execute() {
 	}
 }
 
-// initLogger initializes the logger with the configured level and format.
+// initLogger initializes the logger from the logging flags. It runs after
+// flag parsing, before every command. --verbose selects the debug level and
+// --log-file diverts logs from stderr so they cannot corrupt piped output.
 func initLogger() error {
 	// Parse log level
 	level, ok := logger.ParseLevel(globalOpts.LogLevel)
 	if !ok {
 		return fmt.Errorf("%w: %q, must be one of: %v", clerrors.ErrInvalidLogLevel, globalOpts.LogLevel, logger.ValidLevels())
 	}
+	if globalOpts.Verbose {
+		level = logger.LevelDebug
+	} else if globalOpts.Quiet {
+		level = logger.LevelError
+	}
 
 	// Parse log format
 	format, ok := logger.ParseFormat(globalOpts.LogFormat)
@@ -68,67 +93,136 @@ func initLogger() error {
 		return fmt.Errorf("%w: %q, must be one of: %v", clerrors.ErrInvalidLogFormat, globalOpts.LogFormat, logger.ValidFormats())
 	}
 
-	// Initialize logger
-	logger.Init(level, format, os.Stderr)
+	output := io.Writer(os.Stderr)
+	if globalOpts.LogFile != "" {
+		f, err := os.OpenFile(globalOpts.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, logFilePermission)
+		if err != nil {
+			return clerrors.NewIOError("cannot open log file", err)
+		}
+		logFile = f
+		output = f
+	}
+
+	logger.Init(level, format, output)
 	return nil
 }
 
 // printError prints error messages with appropriate formatting based on error type.
+// Failures that clerrors.Explain recognizes are followed by an explanation and
+// remediation hints, except under --quiet. Under --json the error is printed
+// as an errorReport instead.
 func printError(err error) {
+	if globalOpts.OutputJSON {
+		printErrorJSON(os.Stderr, err)
+		return
+	}
+
 	var validationErr *clerrors.ValidationError
 	var apiErr *clerrors.APIError
 	var configErr *clerrors.ConfigError
 	var ioErr *clerrors.IOError
 
+	label, shown := "Error", err
 	//nolint:gocritic // errors.As requires if-else chain, cannot use switch
 	if errors.As(err, &validationErr) {
-		fmt.Fprintf(os.Stderr, "❌ Validation Error: %v\n", validationErr)
+		label, shown = "Validation Error", validationErr
 	} else if errors.As(err, &apiErr) {
-		fmt.Fprintf(os.Stderr, "❌ API Error: %v\n", apiErr)
+		label, shown = "API Error", apiErr
 	} else if errors.As(err, &configErr) {
-		fmt.Fprintf(os.Stderr, "❌ Configuration Error: %v\n", configErr)
+		label, shown = "Configuration Error", configErr
 	} else if errors.As(err, &ioErr) {
-		fmt.Fprintf(os.Stderr, "❌ I/O Error: %v\n", ioErr)
-	} else {
-		fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+		label, shown = "I/O Error", ioErr
+	}
+	fmt.Fprintf(os.Stderr, "%s %v\n", color.Sprint(os.Stderr, color.Error, "❌ "+label+":"), shown)
+	if !globalOpts.Quiet {
+		printExplanation(os.Stderr, err)
 	}
 }
 
-// getExitCode maps error types to exit codes.
-func getExitCode(err error) int {
-	var validationErr *clerrors.ValidationError
-	var apiErr *clerrors.APIError
-	var configErr *clerrors.ConfigError
-	var ioErr *clerrors.IOError
+// printExplanation writes the explanation and hints for err, when
+// clerrors.Explain recognizes it.
+func printExplanation(w io.Writer, err error) {
+	explanation, ok := clerrors.Explain(err)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n", explanation.Explanation)
+	for _, hint := range explanation.Hints {
+		fmt.Fprintf(w, "  → %s\n", hint)
+	}
+}
 
-	//nolint:gocritic // errors.As requires if-else chain, cannot use switch
-	if errors.As(err, &validationErr) {
-		return exitCodeValidation
-	} else if errors.As(err, &apiErr) {
-		return exitCodeAPI
-	} else if errors.As(err, &configErr) {
-		return exitCodeConfiguration
-	} else if errors.As(err, &ioErr) {
-		return exitCodeIO
+// errorReport is the error printed under --json.
+type errorReport struct {
+	Error       string   `json:"error"`
+	ExitCode    int      `json:"exit_code"`
+	Category    string   `json:"category,omitempty"`
+	Explanation string   `json:"explanation,omitempty"`
+	Hints       []string `json:"hints,omitempty"`
+}
+
+// printErrorJSON writes err to w as an errorReport.
+func printErrorJSON(w io.Writer, err error) {
+	report := errorReport{Error: err.Error(), ExitCode: getExitCode(err)}
+	if explanation, ok := clerrors.Explain(err); ok {
+		report.Category = explanation.Category
+		report.Explanation = explanation.Explanation
+		report.Hints = explanation.Hints
 	}
-	return exitCodeGeneral
+	data, _ := json.Marshal(report) //nolint:errchkjson // strings and ints always marshal
+	fmt.Fprintf(w, "%s\n", data)
 }
 
 func addChatFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVarP(&globalOpts.Model, "model", "m", globalOpts.Model,
 		"List of models: https://docs.perplexity.ai/guides/model-cards")
 	cmd.PersistentFlags().Float64Var(&globalOpts.FrequencyPenalty, "frequency-penalty", globalOpts.FrequencyPenalty, "Frequency penalty")
-	cmd.PersistentFlags().IntVarP(&globalOpts.MaxTokens, "max-tokens", "T", globalOpts.MaxTokens, "Max tokens")
+	cmd.PersistentFlags().VarP(maxTokensValue{&globalOpts.MaxTokens}, "max-tokens", "T",
+		"Maximum number of tokens in the answer: 0 for the model default, max for the model's limit")
 	cmd.PersistentFlags().Float64Var(&globalOpts.PresencePenalty, "presence-penalty", globalOpts.PresencePenalty, "Presence penalty")
 	cmd.PersistentFlags().Float64VarP(&globalOpts.Temperature, "temperature", "t", globalOpts.Temperature, "Temperature")
 	cmd.PersistentFlags().IntVarP(&globalOpts.TopK, "top-k", "k", globalOpts.TopK, "Top K")
 	cmd.PersistentFlags().Float64Var(&globalOpts.TopP, "top-p", globalOpts.TopP, "Top P")
-	cmd.PersistentFlags().DurationVar(&globalOpts.Timeout, "timeout", globalOpts.Timeout, "HTTP timeout")
+	cmd.PersistentFlags().DurationVar(&globalOpts.Timeout, "timeout", globalOpts.Timeout,
+		"Total timeout of one API call, retries included (per turn in chat)")
+	cmd.PersistentFlags().DurationVar(&globalOpts.ConnectTimeout, "connect-timeout", globalOpts.ConnectTimeout,
+		"Timeout for connecting to the API (0 for no separate limit)")
+	cmd.PersistentFlags().BoolVar(&globalOpts.StrictModel, "strict-model", false,
+		"Fail on unknown models instead of warning")
+	cmd.PersistentFlags().StringSliceVar(&globalOpts.ModelFallbacks, "fallback-models", globalOpts.ModelFallbacks,
+		"Models to try, in order, when the API reports the model unavailable or invalid")
+	cmd.PersistentFlags().StringVar(&globalOpts.Language, "language", globalOpts.Language,
+		"Answer language as an ISO 639-1 code (e.g. fr, de, ja)")
+}
+
+func addRetryFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().IntVar(&globalOpts.MaxRetries, "max-retries", globalOpts.MaxRetries,
+		"Retries on rate limit (429) and server errors (5xx); 0 disables retries")
+	cmd.PersistentFlags().StringVar(&globalOpts.BaseURL, "base-url", globalOpts.BaseURL,
+		"Base URL of a Perplexity-compatible API (overrides api.base_url)")
+}
+
+func addMCPFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&mcpJobTTL, "job-ttl", mcp.DefaultJobTTL,
+		"How long finished research job results are kept for polling")
+	cmd.Flags().IntVar(&mcpMaxJobs, "max-research-jobs", mcp.DefaultMaxConcurrentJobs,
+		"Maximum number of research jobs pending or running at once")
+	cmd.Flags().DurationVar(&mcpSessionTTL, "chat-session-ttl", mcp.DefaultSessionTTL,
+		"How long an idle chat session is kept")
+	cmd.Flags().IntVar(&mcpMaxSessions, "max-chat-sessions", mcp.DefaultMaxSessions,
+		"Maximum number of chat sessions kept at once")
+	cmd.Flags().BoolVar(&mcpWatchConfig, "watch-config", true,
+		"Reload tool defaults when the config file changes")
+	cmd.Flags().BoolVar(&globalOpts.StrictModel, "strict-model", false,
+		"Reject tool calls with unknown models instead of warning")
+	cmd.Flags().StringVar(&configFilePath, "config", "", "Path to config file")
 }
 
 func addSearchFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringSliceVarP(&globalOpts.SearchDomains, "search-domains", "d", globalOpts.SearchDomains,
 		"Filter search results to specific domains")
+	cmd.PersistentFlags().StringSliceVar(&globalOpts.ExcludeDomains, "exclude-domains", globalOpts.ExcludeDomains,
+		"Exclude specific domains from search results")
 	cmd.PersistentFlags().StringVarP(&globalOpts.SearchRecency, "search-recency", "r", globalOpts.SearchRecency,
 		"Filter by time: day, week, month, year")
 	cmd.PersistentFlags().Float64Var(&globalOpts.LocationLat, "location-lat", globalOpts.LocationLat, "User location latitude")
@@ -137,9 +231,15 @@ func addSearchFlags(cmd *cobra.Command) {
 }
 
 func addResponseFlags(cmd *cobra.Command) {
-	cmd.PersistentFlags().BoolVarP(&globalOpts.ReturnImages, "return-images", "i", globalOpts.ReturnImages, "Include images in response (note: automatically disables --search-recency)")
+	cmd.PersistentFlags().BoolVarP(&globalOpts.ReturnImages, "return-images", "i", globalOpts.ReturnImages, "Include images in response (see output.image_conflict_policy when combined with --search-recency)")
 	cmd.PersistentFlags().BoolVarP(&globalOpts.ReturnRelated, "return-related", "q", globalOpts.ReturnRelated, "Include related questions")
+	cmd.PersistentFlags().BoolVar(&showSearches, "show-searches", false,
+		"Print the web searches the API ran, when it reports them")
 	cmd.PersistentFlags().BoolVarP(&globalOpts.Stream, "stream", "S", globalOpts.Stream, "Enable streaming responses")
+	cmd.PersistentFlags().StringVar(&globalOpts.StreamStyle, "stream-style", globalOpts.StreamStyle,
+		"How streamed answers are written on a terminal: instant, or smooth for a word-by-word typewriter effect")
+	cmd.PersistentFlags().IntVar(&globalOpts.AutoContinue, "auto-continue", globalOpts.AutoContinue,
+		"Send up to N follow-up requests to complete an answer cut off at max_tokens (0 disables)")
 }
 
 func addImageFlags(cmd *cobra.Command) {
@@ -153,11 +253,39 @@ func addFileFlags(cmd *cobra.Command) {
 		"Attach a file or https:// URL to the prompt (images: png,jpg,jpeg,webp,gif; documents: pdf,doc,docx,txt,rtf). Repeatable.")
 }
 
+// flagAliases maps former flag names to the flags that replaced them, so that
+// command lines and presets using the old names keep working.
+var flagAliases = map[string]string{
+	"sys-prompt-file": "system-prompt-file",
+}
+
+// normalizeFlagAliases resolves the names of flagAliases to their flags.
+func normalizeFlagAliases(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+	if target, ok := flagAliases[name]; ok {
+		name = target
+	}
+	return pflag.NormalizedName(name)
+}
+
+func addPromptFileFlags(cmd *cobra.Command) {
+	cmd.SetGlobalNormalizationFunc(normalizeFlagAliases)
+	cmd.PersistentFlags().StringVar(&globalOpts.SystemPromptFile, "system-prompt-file", "",
+		"Read the system prompt from a file (alias: --sys-prompt-file)")
+	cmd.PersistentFlags().StringVar(&globalOpts.UserPromptFile, "user-prompt-file", "",
+		"Read the user prompt from a file")
+	cmd.PersistentFlags().IntVar(&globalOpts.MaxPromptSize, "max-prompt-size", defaultMaxPromptSize,
+		"Maximum size in bytes of a prompt read from stdin or a file")
+	cmd.MarkFlagsMutuallyExclusive("sys-prompt", "system-prompt-file")
+	cmd.MarkFlagsMutuallyExclusive("user-prompt", "user-prompt-file")
+}
+
 func addFormatFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVar(&globalOpts.ResponseFormatJSONSchema, "response-format-json-schema",
 		globalOpts.ResponseFormatJSONSchema, "JSON schema for structured output (sonar model only)")
 	cmd.PersistentFlags().StringVar(&globalOpts.ResponseFormatRegex, "response-format-regex",
 		globalOpts.ResponseFormatRegex, "Regex pattern for structured output (sonar model only)")
+	cmd.PersistentFlags().StringVar(&globalOpts.ResponseFormatJSONSchemaFile, "json-schema-file", "",
+		"Read the --response-format-json-schema schema from a file")
 	cmd.PersistentFlags().StringVarP(&globalOpts.SearchMode, "search-mode", "a", globalOpts.SearchMode, "Search mode: web (default) or academic")
 	cmd.PersistentFlags().StringVarP(&globalOpts.SearchContextSize, "search-context-size", "c", globalOpts.SearchContextSize,
 		"Search context size: low, medium, or high")
@@ -165,13 +293,13 @@ func addFormatFlags(cmd *cobra.Command) {
 
 func addDateFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVar(&globalOpts.SearchAfterDate, "search-after-date", globalOpts.SearchAfterDate,
-		"Filter results published after date (MM/DD/YYYY)")
+		"Filter results published after date (YYYY-MM-DD, MM/DD/YYYY, 7d/3w/6m/1y, or 2024-Q1)")
 	cmd.PersistentFlags().StringVar(&globalOpts.SearchBeforeDate, "search-before-date", globalOpts.SearchBeforeDate,
-		"Filter results published before date (MM/DD/YYYY)")
+		"Filter results published before date (YYYY-MM-DD, MM/DD/YYYY, 7d/3w/6m/1y, or 2024-Q1)")
 	cmd.PersistentFlags().StringVar(&globalOpts.LastUpdatedAfter, "last-updated-after", globalOpts.LastUpdatedAfter,
-		"Filter results last updated after date (MM/DD/YYYY)")
+		"Filter results last updated after date (YYYY-MM-DD, MM/DD/YYYY, 7d/3w/6m/1y, or 2024-Q1)")
 	cmd.PersistentFlags().StringVar(&globalOpts.LastUpdatedBefore, "last-updated-before", globalOpts.LastUpdatedBefore,
-		"Filter results last updated before date (MM/DD/YYYY)")
+		"Filter results last updated before date (YYYY-MM-DD, MM/DD/YYYY, 7d/3w/6m/1y, or 2024-Q1)")
 }
 
 func addResearchFlags(cmd *cobra.Command) {
@@ -181,6 +309,21 @@ func addResearchFlags(cmd *cobra.Command) {
 
 func addOutputFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().BoolVar(&globalOpts.OutputJSON, "json", globalOpts.OutputJSON, "Output response in JSON format")
+	cmd.PersistentFlags().StringVar(&globalOpts.JSONFields, "json-fields", globalOpts.JSONFields,
+		"Comma-separated JSON fields to output (implies --json): "+strings.Join(output.Fields(), ", "))
+	addRenderFlag(cmd)
+}
+
+func addRenderFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&globalOpts.Render, "render", globalOpts.Render,
+		"Answer rendering: markdown, plain, or raw (default: markdown on a terminal, plain when piped)")
+	cmd.PersistentFlags().StringVar(&globalOpts.Citations, "citations", globalOpts.Citations,
+		"Citation style: list, inline, footnote, or none (default: list)")
+	cmd.PersistentFlags().IntVar(&globalOpts.CitationMaxPerDomain, "citation-max-per-domain",
+		globalOpts.CitationMaxPerDomain,
+		"Sources kept per domain; later ones are grouped beneath the last kept (default: 0, keep all)")
+	cmd.PersistentFlags().StringSliceVar(&globalOpts.CitationExcludeDomains, "citation-exclude-domains",
+		globalOpts.CitationExcludeDomains, "Domains whose sources and citation markers are dropped (comma-separated)")
 }
 
 func addLoggingFlags(cmd *cobra.Command) {
@@ -188,13 +331,17 @@ func addLoggingFlags(cmd *cobra.Command) {
 		"Log level (debug, info, warn, error)")
 	cmd.PersistentFlags().StringVar(&globalOpts.LogFormat, "log-format", globalOpts.LogFormat,
 		"Log format (text, json)")
+	cmd.PersistentFlags().BoolVar(&globalOpts.Verbose, "verbose", false,
+		"Debug logging, including API requests and responses (API key redacted)")
+	cmd.PersistentFlags().StringVar(&globalOpts.LogFile, "log-file", "",
+		"Append logs to this file instead of stderr")
 }
 
 func registerFlagCompletions(cmd *cobra.Command) {
 	// Model completion
 	if err := cmd.RegisterFlagCompletionFunc("model",
 		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
-			return completion.GetModels(), cobra.ShellCompDirectiveNoFileComp
+			return completion.ModelNames(), cobra.ShellCompDirectiveNoFileComp
 		}); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'model' flag: %v\n", err)
 	}
@@ -231,6 +378,30 @@ func registerFlagCompletions(cmd *cobra.Command) {
 		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'reasoning-effort' flag: %v\n", err)
 	}
 
+	// Answer language completion
+	if err := cmd.RegisterFlagCompletionFunc("language",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return completion.Languages(), cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'language' flag: %v\n", err)
+	}
+
+	// Render mode completion
+	if err := cmd.RegisterFlagCompletionFunc("render",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return render.Modes(), cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'render' flag: %v\n", err)
+	}
+
+	// Citation style completion
+	if err := cmd.RegisterFlagCompletionFunc("citations",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return citations.Styles(), cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'citations' flag: %v\n", err)
+	}
+
 	// Image formats completion
 	if err := cmd.RegisterFlagCompletionFunc("image-formats",
 		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
@@ -247,13 +418,16 @@ func registerFlagCompletions(cmd *cobra.Command) {
 		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'profile' flag: %v\n", err)
 	}
 
-	// Domain suggestions (for both search-domains and image-domains)
+	// Domain suggestions (for search-domains, exclude-domains and image-domains)
 	domainCompletion := func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
 		return completion.CommonDomains(), cobra.ShellCompDirectiveNoFileComp
 	}
 	if err := cmd.RegisterFlagCompletionFunc("search-domains", domainCompletion); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'search-domains' flag: %v\n", err)
 	}
+	if err := cmd.RegisterFlagCompletionFunc("exclude-domains", domainCompletion); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'exclude-domains' flag: %v\n", err)
+	}
 	if err := cmd.RegisterFlagCompletionFunc("image-domains", domainCompletion); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'image-domains' flag: %v\n", err)
 	}
@@ -281,23 +455,37 @@ func init() {
 	// Add logging flags to root command
 	addLoggingFlags(rootCmd)
 	registerLoggingFlagCompletions(rootCmd)
+	addWarningFlags(rootCmd)
+	addLangFlag(rootCmd)
+	addColorFlag(rootCmd)
 
 	rootCmd.AddCommand(chatCmd)
 	addChatFlags(chatCmd)
+	addRetryFlags(chatCmd)
 	addSearchFlags(chatCmd)
 	addResponseFlags(chatCmd)
 	addImageFlags(chatCmd)
 	addFormatFlags(chatCmd)
 	addDateFlags(chatCmd)
 	addResearchFlags(chatCmd)
+	addRenderFlag(chatCmd)
+	addChatContextFlags(chatCmd)
+	addChatInputFlags(chatCmd)
+	addChatAutosaveFlags(chatCmd)
+	addRequestFlagGroups(chatCmd)
+	chatCmd.PreRunE = validateFlagCombinations
+	chatCmd.AddCommand(chatExportCmd)
+	addChatExportFlags(chatExportCmd)
 	chatCmd.PersistentFlags().StringVar(&configFilePath, "config", "", "Path to config file")
 	chatCmd.PersistentFlags().StringVar(&runtimeProfile, "profile", "", "Use a named configuration profile")
 	registerFlagCompletions(chatCmd)
 
 	rootCmd.AddCommand(queryCmd)
 	queryCmd.PersistentFlags().StringVarP(&globalOpts.SystemPrompt, "sys-prompt", "s", "", "system prompt")
-	queryCmd.PersistentFlags().StringVarP(&globalOpts.UserPrompt, "user-prompt", "p", "", "user prompt")
+	queryCmd.PersistentFlags().StringVarP(&globalOpts.UserPrompt, "user-prompt", "p", "", "user prompt (- reads stdin)")
+	addPromptFileFlags(queryCmd)
 	addChatFlags(queryCmd)
+	addRetryFlags(queryCmd)
 	addSearchFlags(queryCmd)
 	addResponseFlags(queryCmd)
 	addImageFlags(queryCmd)
@@ -306,9 +494,61 @@ func init() {
 	addResearchFlags(queryCmd)
 	addOutputFlags(queryCmd)
 	addFileFlags(queryCmd)
+	queryCmd.Flags().BoolVar(&queryJSONSchema, "json-schema", false,
+		"Print the JSON Schema of --json output and exit")
+	queryCmd.Flags().StringVar(&queryExtract, "extract", "",
+		"Print only the value at this path of the JSON answer, e.g. items.0.name (of the output object with --json)")
+	queryCmd.Flags().IntVar(&queryChoices, "choices", 1,
+		"Number of candidate answers to request and print (1-10; never streamed)")
+	queryCmd.Flags().IntVar(&queryRepeat, "repeat", 1,
+		"Send the prompt this many times and print every answer with a usage summary (never streamed)")
+	queryCmd.Flags().BoolVar(&querySeedVary, "seed-vary", false,
+		"With --repeat, spread the temperature of the runs 0.05 apart around --temperature")
+	queryCmd.Flags().BoolVar(&queryInteractiveRelated, "interactive-related", false,
+		"On a terminal, offer the related questions as follow-up queries (implies --return-related)")
+	queryCmd.Flags().BoolVar(&queryStrictFormat, "strict-format", false,
+		"Exit with code 9 when the answer does not match --response-format-regex")
+	queryCmd.Flags().BoolVar(&queryForce, "force", false,
+		"Send the query even when it would exceed api.daily_budget_usd")
+	queryCmd.Flags().StringVar(&querySaveImages, "save-images", "",
+		"Download returned images into this directory and print a manifest (implies --return-images)")
+	queryCmd.Flags().IntVar(&queryMaxImages, "max-images", 0,
+		"Maximum number of images saved by --save-images (0 for no limit)")
+	addBatchFlags(queryCmd)
+	addPagerFlags(queryCmd)
+	addCompareFlags(queryCmd)
+	addExportFlags(queryCmd)
+	addQueryFlagGroups(queryCmd)
+	queryCmd.PreRunE = preRunQuery
+	addPromptTemplateFlags(queryCmd)
+	addCacheFlags(queryCmd)
+	addMessagesFileFlags(queryCmd)
+	addQuietFlags(queryCmd)
 	queryCmd.PersistentFlags().StringVar(&configFilePath, "config", "", "Path to config file")
 	queryCmd.PersistentFlags().StringVar(&runtimeProfile, "profile", "", "Use a named configuration profile")
+	addPresetFlag(queryCmd)
+	addHistoryFlags(queryCmd)
 	registerFlagCompletions(queryCmd)
+	registerPresetCommands(queryCmd)
+	registerHistoryCommands(queryCmd)
+
+	rootCmd.AddCommand(briefCmd)
+	addChatFlags(briefCmd)
+	addRetryFlags(briefCmd)
+	addBriefFlags(briefCmd)
+	briefCmd.PersistentFlags().StringVar(&configFilePath, "config", "", "Path to config file")
+	briefCmd.PersistentFlags().StringVar(&runtimeProfile, "profile", "", "Use a named configuration profile")
 
 	rootCmd.AddCommand(mcpStdioCmd)
+	addRetryFlags(mcpStdioCmd)
+	addMCPFlags(mcpStdioCmd)
+
+	rootCmd.AddCommand(mcpHTTPCmd)
+	addRetryFlags(mcpHTTPCmd)
+	addMCPFlags(mcpHTTPCmd)
+	mcpHTTPCmd.Flags().StringVar(&mcpListenAddr, "listen", mcp.DefaultHTTPAddr, "Address (host:port) to listen on")
+	mcpHTTPCmd.Flags().DurationVar(&mcpDrainTimeout, "drain-timeout", mcp.DefaultDrainTimeout,
+		"How long in-flight requests may finish after SIGINT/SIGTERM")
+	mcpHTTPCmd.Flags().BoolVar(&mcpMetrics, "metrics", false,
+		"Serve usage counters in the Prometheus format at "+mcp.MetricsEndpoint)
 }
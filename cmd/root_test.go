@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/sgaunet/perplexity-go/v2"
 	"github.com/sgaunet/pplx/pkg/clerrors"
 )
 
@@ -22,19 +25,19 @@ func TestGetExitCode(t *testing.T) {
 			expected: exitCodeValidation,
 		},
 		{
-			name:     "APIError returns exit code 3",
+			name:     "APIError returns exit code 7",
 			err:      clerrors.NewAPIError("message", nil),
 			expected: exitCodeAPI,
 		},
 		{
-			name:     "ConfigError returns exit code 4",
+			name:     "ConfigError returns exit code 3",
 			err:      clerrors.NewConfigError("message", nil),
 			expected: exitCodeConfiguration,
 		},
 		{
-			name:     "IOError returns exit code 5",
+			name:     "IOError returns exit code 1",
 			err:      clerrors.NewIOError("message", nil),
-			expected: exitCodeIO,
+			expected: exitCodeGeneral,
 		},
 		{
 			name:     "Generic error returns exit code 1",
@@ -47,19 +50,19 @@ func TestGetExitCode(t *testing.T) {
 			expected: exitCodeValidation,
 		},
 		{
-			name:     "Wrapped APIError returns exit code 3",
+			name:     "Wrapped APIError returns exit code 7",
 			err:      fmt.Errorf("wrapper: %w", clerrors.NewAPIError("message", nil)),
 			expected: exitCodeAPI,
 		},
 		{
-			name:     "Wrapped ConfigError returns exit code 4",
+			name:     "Wrapped ConfigError returns exit code 3",
 			err:      fmt.Errorf("wrapper: %w", clerrors.NewConfigError("message", nil)),
 			expected: exitCodeConfiguration,
 		},
 		{
-			name:     "Wrapped IOError returns exit code 5",
+			name:     "Wrapped IOError returns exit code 1",
 			err:      fmt.Errorf("wrapper: %w", clerrors.NewIOError("message", nil)),
-			expected: exitCodeIO,
+			expected: exitCodeGeneral,
 		},
 	}
 
@@ -140,6 +143,39 @@ func TestPrintError(t *testing.T) {
 	}
 }
 
+func TestPrintExplanation(t *testing.T) {
+	var buf bytes.Buffer
+	printExplanation(&buf, clerrors.NewAPIError("failed to send completion request", perplexity.ErrUnauthorized))
+	if !strings.Contains(buf.String(), "rejected the API key") || !strings.Contains(buf.String(), "→ Set PPLX_API_KEY") {
+		t.Errorf("explanation = %q, want the auth explanation and hints", buf.String())
+	}
+
+	buf.Reset()
+	printExplanation(&buf, errors.New("something went wrong"))
+	if buf.Len() != 0 {
+		t.Errorf("explanation of an unknown failure = %q, want none", buf.String())
+	}
+}
+
+func TestPrintErrorJSON(t *testing.T) {
+	var buf bytes.Buffer
+	printErrorJSON(&buf, &clerrors.APIError{StatusCode: 429, Message: "Too Many Requests"})
+	var report errorReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if report.ExitCode != exitCodeRateLimit || report.Category != clerrors.CategoryRateLimit ||
+		report.Explanation == "" || len(report.Hints) == 0 {
+		t.Errorf("report = %+v, want the rate limit explanation", report)
+	}
+
+	buf.Reset()
+	printErrorJSON(&buf, errors.New("boom"))
+	if got := strings.TrimSpace(buf.String()); got != `{"error":"boom","exit_code":1}` {
+		t.Errorf("report = %s, want no explanation fields", got)
+	}
+}
+
 func TestExitCodeConstants(t *testing.T) {
 	// Verify exit code constants have expected values
 	tests := []struct {
@@ -150,9 +186,11 @@ func TestExitCodeConstants(t *testing.T) {
 		{"exitCodeSuccess", exitCodeSuccess, 0},
 		{"exitCodeGeneral", exitCodeGeneral, 1},
 		{"exitCodeValidation", exitCodeValidation, 2},
-		{"exitCodeAPI", exitCodeAPI, 3},
-		{"exitCodeConfiguration", exitCodeConfiguration, 4},
-		{"exitCodeIO", exitCodeIO, 5},
+		{"exitCodeConfiguration", exitCodeConfiguration, 3},
+		{"exitCodeAuth", exitCodeAuth, 4},
+		{"exitCodeRateLimit", exitCodeRateLimit, 5},
+		{"exitCodeTimeout", exitCodeTimeout, 6},
+		{"exitCodeAPI", exitCodeAPI, 7},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/console"
+)
+
+// showSearches is --show-searches: print the web searches the API ran after
+// the answer.
+var showSearches bool
+
+// renderSearches writes the "Searches performed" section of res to w under
+// --show-searches. Nothing is written when the API reported no searches.
+func renderSearches(w io.Writer, res *perplexity.CompletionResponse) error {
+	if !showSearches || res == nil {
+		return nil
+	}
+	if err := console.RenderSearchQueries(res, w); err != nil {
+		return clerrors.NewIOError("failed to render search queries", err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/searches"
+)
+
+func TestRenderSearches(t *testing.T) {
+	searches.Record("render-searches", []string{"go 1.25", "go generics"})
+	res := &perplexity.CompletionResponse{ID: "render-searches"}
+	t.Cleanup(func() { showSearches = false })
+
+	var buf bytes.Buffer
+	if err := renderSearches(&buf, res); err != nil || buf.Len() != 0 {
+		t.Errorf("without --show-searches: %q, %v; want nothing", buf.String(), err)
+	}
+
+	showSearches = true
+	if err := renderSearches(&buf, res); err != nil {
+		t.Fatalf("renderSearches() error = %v", err)
+	}
+	if want := "\n🔎 Searches performed:\n1. go 1.25\n2. go generics\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := renderSearches(&buf, &perplexity.CompletionResponse{ID: "no-searches"}); err != nil || buf.Len() != 0 {
+		t.Errorf("without searches: %q, %v; want the section omitted", buf.String(), err)
+	}
+}
@@ -0,0 +1,80 @@
+package testutil
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Answer is the content of the completion served by NewAPI. It cites both
+// sources, so that every citation style has something to show.
+const Answer = "Go was designed at Google [1] and released in 2009 [2]."
+
+// completionJSON is the completion served by NewAPI. Every field the
+// commands print is fixed, so that their output is the same on every run.
+const completionJSON = `{
+	"id": "golden-request",
+	"model": "sonar",
+	"created": 1700000000,
+	"object": "chat.completion",
+	"usage": {"prompt_tokens": 12, "completion_tokens": 18, "total_tokens": 30},
+	"citations": ["https://go.dev/doc/faq", "https://go.dev/blog/go1"],
+	"search_results": [
+		{"title": "Go FAQ", "url": "https://go.dev/doc/faq", "date": "2024-01-02"},
+		{"title": "Go version 1 is released", "url": "https://go.dev/blog/go1", "date": "2012-03-28"}
+	],
+	"choices": [{
+		"index": 0,
+		"finish_reason": "stop",
+		"message": {"role": "assistant", "content": "` + Answer + `"}
+	}]
+}`
+
+// API is a fake Perplexity endpoint. It answers every request with the same
+// completion and records the request bodies.
+type API struct {
+	// URL is the base URL of the endpoint, to use as the API base URL.
+	URL string
+
+	mu       sync.Mutex
+	requests []map[string]any
+}
+
+// NewAPI starts a fake Perplexity endpoint, closed when t ends.
+func NewAPI(t testing.TB) *API {
+	t.Helper()
+	api := &API{}
+	srv := httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(srv.Close)
+	api.URL = srv.URL
+	return api
+}
+
+// Requests returns the decoded bodies of the requests received so far.
+func (a *API) Requests() []map[string]any {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]map[string]any(nil), a.requests...)
+}
+
+func (a *API) serve(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	a.requests = append(a.requests, body)
+	a.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, completionJSON)
+}
@@ -0,0 +1,63 @@
+package testutil
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// update rewrites the golden files from the output of the tests.
+var update = flag.Bool("update", false, "rewrite the golden files under testdata/golden")
+
+// GoldenDir is the directory of the golden files, relative to the package
+// under test.
+const GoldenDir = "testdata/golden"
+
+// Replacement rewrites the parts of an output that change from run to run,
+// such as durations or temporary paths, into a stable placeholder.
+type Replacement func(string) string
+
+// Replace returns a Replacement of the matches of the regular expression
+// pattern by with, which may refer to submatches as in
+// regexp.Regexp.ReplaceAllString.
+func Replace(pattern, with string) Replacement {
+	re := regexp.MustCompile(pattern)
+	return func(s string) string { return re.ReplaceAllString(s, with) }
+}
+
+// ReplaceLiteral returns a Replacement of every occurrence of old by with.
+func ReplaceLiteral(old, with string) Replacement {
+	return func(s string) string { return strings.ReplaceAll(s, old, with) }
+}
+
+// Golden compares got, after the replacements, with the golden file name
+// under GoldenDir. With -update, the golden file is written instead.
+func Golden(t testing.TB, name, got string, replacements ...Replacement) {
+	t.Helper()
+	for _, replace := range replacements {
+		got = replace(got)
+	}
+
+	path := filepath.Join(GoldenDir, name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("cannot create the golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o600); err != nil {
+			t.Fatalf("cannot update %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path) //nolint:gosec // path of a golden file of the tests
+	if err != nil {
+		t.Fatalf("cannot read %s (run the tests with -update to create it): %v", path, err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run the tests with -update to accept it)\ngot:\n%s\nwant:\n%s",
+			path, got, want)
+	}
+}
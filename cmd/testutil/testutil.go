@@ -0,0 +1,103 @@
+// Package testutil runs the commands of pplx in tests: it isolates them from
+// the environment of the developer, serves canned answers in place of the
+// Perplexity API, captures what they print, and compares it with golden
+// files.
+//
+// Golden files live under testdata/golden of the package under test. Run the
+// tests with -update to rewrite them from the current output:
+//
+//	go test ./cmd -run Golden -update
+package testutil
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// envPrefixes are the prefixes of the environment variables cleared by
+// Isolate: the settings of pplx and the variables of the Perplexity API.
+var envPrefixes = []string{"PPLX_", "PERPLEXITY_"}
+
+// Isolate points HOME and the XDG base directories at a temporary directory
+// and clears the PPLX_* and PERPLEXITY_* variables, so that no config file,
+// key, cache, or history of the developer is read or written. It returns the
+// temporary home directory. The environment is restored when t ends.
+func Isolate(t testing.TB) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, dir := range []string{"XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME"} {
+		t.Setenv(dir, filepath.Join(home, strings.ToLower(strings.TrimPrefix(dir, "XDG_"))))
+	}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, prefix := range envPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				t.Setenv(name, "") // restores the value when t ends
+				_ = os.Unsetenv(name)
+			}
+		}
+	}
+	return home
+}
+
+// Result is what a command printed and the error it returned.
+type Result struct {
+	Stdout string
+	Stderr string
+	Err    error
+}
+
+// Run calls the RunE function of cmd with args, as cobra does once the
+// flags are parsed, and captures its output. Flags are not parsed: tests set
+// the variables the flags are bound to. Stdout is what the command wrote to
+// cmd.OutOrStdout(); Stderr is what it wrote to cmd.ErrOrStderr() followed
+// by what was written to os.Stderr, where warnings and progress go.
+func Run(t testing.TB, cmd *cobra.Command, args ...string) Result {
+	t.Helper()
+	if cmd.RunE == nil {
+		t.Fatalf("command %q has no RunE", cmd.Name())
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	defer func() {
+		cmd.SetOut(nil)
+		cmd.SetErr(nil)
+	}()
+
+	var err error
+	osStderr := captureStderr(t, func() { err = cmd.RunE(cmd, args) })
+	return Result{Stdout: stdout.String(), Stderr: stderr.String() + osStderr, Err: err}
+}
+
+// captureStderr runs fn and returns what it wrote to os.Stderr.
+func captureStderr(t testing.TB, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("cannot capture stderr: %v", err)
+	}
+	saved := os.Stderr
+	os.Stderr = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	defer func() {
+		_ = w.Close()
+		os.Stderr = saved
+	}()
+	fn()
+	_ = w.Close()
+	os.Stderr = saved
+	return <-done
+}
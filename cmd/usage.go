@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	clerrors "github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/spf13/cobra"
+)
+
+// usageTabPadding is the column padding of the usage table.
+const usageTabPadding = 2
+
+var (
+	usageSince   string
+	usageModel   string
+	usageGroupBy string
+	usageJSON    bool
+
+	usageExportFormat string
+	usageExportOutput string
+)
+
+// usageReport is the JSON form of `pplx usage`.
+type usageReport struct {
+	Groups []usage.Summary `json:"groups"`
+	Total  usage.Summary   `json:"total"`
+}
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show token usage and estimated cost",
+	Long: `Aggregate the usage log that query (single and batch), chat, and the MCP server append to
+after every successful request (~/.local/share/pplx/usage.jsonl).
+
+Costs are estimates: the API-reported cost is used when the response includes
+it, otherwise a built-in per-model price table. Requests for models missing
+from the table are counted but not priced.
+
+Examples:
+  pplx usage
+  pplx usage --since 7d --group-by day
+  pplx usage --since 2025-01-01 --model sonar-pro
+  pplx usage --group-by model --json
+
+See "pplx usage export" to export the log as CSV or Prometheus metrics.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		since, err := usage.ParseSince(usageSince, time.Now())
+		if err != nil {
+			return clerrors.NewValidationError("since", usageSince, err.Error())
+		}
+
+		path, err := usage.DefaultPath()
+		if err != nil {
+			return clerrors.NewIOError("cannot locate usage log", err)
+		}
+		records, err := usage.ReadFile(path)
+		if err != nil {
+			return clerrors.NewIOError("cannot read usage log", err)
+		}
+
+		groups, total, err := usage.Summarize(records, usage.Filter{Since: since, Model: usageModel}, usageGroupBy)
+		if err != nil {
+			return clerrors.NewValidationError("group-by", usageGroupBy, err.Error())
+		}
+
+		if usageJSON {
+			data, err := json.MarshalIndent(usageReport{Groups: groups, Total: total}, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal usage report: %w", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		return writeUsageTable(os.Stdout, groups, total)
+	},
+}
+
+var usageExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the usage log as CSV or Prometheus metrics",
+	Long: `Export the usage log for spreadsheets or monitoring.
+
+--format csv writes one row per day and model with the columns date, model,
+requests, prompt_tokens, completion_tokens and est_cost_usd.
+
+--format prometheus writes per-model counters in the Prometheus text format:
+pplx_requests_total{model}, pplx_tokens_total{model,kind} with kind prompt or
+completion, and pplx_cost_usd_total{model}. Point --output at a .prom file in
+the directory of the node_exporter textfile collector; the file is replaced
+atomically, so the collector never reads a partial export.
+
+Without --output, the export is written to stdout.
+
+Examples:
+  pplx usage export --format csv --output usage.csv
+  pplx usage export --format csv --since 30d --model sonar-pro
+  pplx usage export --format prometheus --output /var/lib/node_exporter/pplx.prom`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if !slices.Contains(usage.ValidExportFormats(), usageExportFormat) {
+			return clerrors.NewValidationError("format", usageExportFormat,
+				"must be one of: "+strings.Join(usage.ValidExportFormats(), ", "))
+		}
+		since, err := usage.ParseSince(usageSince, time.Now())
+		if err != nil {
+			return clerrors.NewValidationError("since", usageSince, err.Error())
+		}
+
+		path, err := usage.DefaultPath()
+		if err != nil {
+			return clerrors.NewIOError("cannot locate usage log", err)
+		}
+		records, err := usage.ReadFile(path)
+		if err != nil {
+			return clerrors.NewIOError("cannot read usage log", err)
+		}
+
+		filter := usage.Filter{Since: since, Model: usageModel}
+		if usageExportOutput == "" {
+			if err := usage.Export(os.Stdout, records, filter, usageExportFormat); err != nil {
+				return clerrors.NewIOError("cannot export usage", err)
+			}
+			return nil
+		}
+		if err := usage.ExportFile(usageExportOutput, records, filter, usageExportFormat); err != nil {
+			return clerrors.NewIOError("cannot export usage", err)
+		}
+		return nil
+	},
+}
+
+// writeUsageTable prints the summaries as aligned columns followed by a total row.
+func writeUsageTable(out io.Writer, groups []usage.Summary, total usage.Summary) error {
+	if total.Requests == 0 {
+		_, err := fmt.Fprintln(out, "No usage recorded.")
+		return err //nolint:wrapcheck // stdout write
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, usageTabPadding, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tREQUESTS\tPROMPT\tCOMPLETION\tTOTAL\tCOST (USD)")
+	rows := groups
+	if usageGroupBy != usage.GroupNone {
+		rows = append(rows, total)
+	}
+	for _, s := range rows {
+		cost := fmt.Sprintf("%.4f", s.Cost)
+		if s.Unpriced > 0 {
+			cost += "*"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n",
+			s.Key, s.Requests, s.PromptTokens, s.CompletionTokens, s.TotalTokens, cost)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write usage table: %w", err)
+	}
+
+	if total.Unpriced > 0 {
+		fmt.Fprintf(out, "* %d request(s) used models without a known price and are not included in the cost.\n",
+			total.Unpriced)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+	usageCmd.Flags().StringVar(&usageSince, "since", "",
+		"Only include requests since a date (YYYY-MM-DD), a number of days (7d), or a duration (12h)")
+	usageCmd.Flags().StringVar(&usageModel, "model", "", "Only include requests for this model")
+	usageCmd.Flags().StringVar(&usageGroupBy, "group-by", "", "Group results by day or model")
+	usageCmd.Flags().BoolVar(&usageJSON, "json", false, "Output as JSON")
+
+	usageCmd.AddCommand(usageExportCmd)
+	usageExportCmd.Flags().StringVar(&usageExportFormat, "format", usage.FormatCSV,
+		"Export format: "+strings.Join(usage.ValidExportFormats(), ", "))
+	usageExportCmd.Flags().StringVarP(&usageExportOutput, "output", "o", "",
+		"Write the export to a file instead of stdout")
+	usageExportCmd.Flags().StringVar(&usageSince, "since", "",
+		"Only include requests since a date (YYYY-MM-DD), a number of days (7d), or a duration (12h)")
+	usageExportCmd.Flags().StringVar(&usageModel, "model", "", "Only include requests for this model")
+	if err := usageExportCmd.RegisterFlagCompletionFunc("format",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return usage.ValidExportFormats(), cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'format' flag: %v\n", err)
+	}
+
+	if err := usageCmd.RegisterFlagCompletionFunc("group-by",
+		func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			return usage.ValidGroupings(), cobra.ShellCompDirectiveNoFileComp
+		}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to register completion for 'group-by' flag: %v\n", err)
+	}
+}
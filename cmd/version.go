@@ -6,7 +6,7 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var version = "development"
+var version = developmentVersion
 
 // versionCmd represents the version command.
 var versionCmd = &cobra.Command{
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/output/color"
+	"github.com/sgaunet/pplx/pkg/warnings"
+	"github.com/spf13/cobra"
+)
+
+// failOnWarning is --fail-on-warning: exit with code 10 when the run
+// reported warnings.
+var failOnWarning bool
+
+// addWarningFlags registers --fail-on-warning on cmd and its subcommands.
+func addWarningFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&failOnWarning, "fail-on-warning", false,
+		"Exit with code 10 when the run reports warnings, such as an unknown model")
+}
+
+// flushWarnings prints the warnings collected since the last flush to
+// stderr, in color when stderr is colored. Nothing is printed under --quiet.
+func flushWarnings() {
+	_ = warnings.Write(chatter(os.Stderr), warnings.Default.Take(), color.Enabled(os.Stderr))
+}
+
+// reportWarnings flushes the warnings of the run when the command ends.
+// Under --fail-on-warning it returns clerrors.ErrWarnings when the run
+// reported any, even if they were printed earlier.
+func reportWarnings() error {
+	flushWarnings()
+	if n := warnings.Default.Len(); failOnWarning && n > 0 {
+		return fmt.Errorf("%w (%d, --fail-on-warning)", clerrors.ErrWarnings, n)
+	}
+	return nil
+}
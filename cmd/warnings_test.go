@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+func TestReportWarnings(t *testing.T) {
+	tests := []struct {
+		name       string
+		warn       bool
+		fail       bool
+		quiet      bool
+		wantCode   int
+		wantStderr bool
+	}{
+		{"no warnings", false, true, false, exitCodeSuccess, false},
+		{"warnings", true, false, false, exitCodeSuccess, true},
+		{"fail on warning", true, true, false, exitCodeWarnings, true},
+		{"quiet still fails", true, true, true, exitCodeWarnings, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withGlobalOpts(t)
+			warnings.Default.Reset()
+			t.Cleanup(warnings.Default.Reset)
+			savedFail := failOnWarning
+			t.Cleanup(func() { failOnWarning = savedFail })
+			failOnWarning, globalOpts.Quiet = tt.fail, tt.quiet
+			if tt.warn {
+				warnings.Add(warnings.CodeUnknownModel, `unknown model "sonar-prp"`, "run pplx models")
+			}
+
+			stderr, err := captureStderr(t, reportWarnings)
+			if got := getExitCode(err); got != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (err: %v)", got, tt.wantCode, err)
+			}
+			if got := strings.Contains(stderr, "[unknown_model]"); got != tt.wantStderr {
+				t.Errorf("stderr = %q, want the warning printed: %v", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestJSONResult_Warnings(t *testing.T) {
+	warnings.Default.Reset()
+	t.Cleanup(warnings.Default.Reset)
+	warnings.Add(warnings.CodeImageFormat, `image format "tiff" may not be supported`, "")
+
+	result := jsonResult(nil, 0)
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != warnings.CodeImageFormat {
+		t.Errorf("Warnings = %+v, want the image format warning", result.Warnings)
+	}
+	// Warnings in the JSON output are not printed again when the run ends.
+	stderr, _ := captureStderr(t, reportWarnings)
+	if stderr != "" {
+		t.Errorf("stderr = %q, want nothing", stderr)
+	}
+}
@@ -0,0 +1,165 @@
+// Package batch runs many prompts from a JSON lines file against the API.
+//
+// Each input line is a JSON object with a user_prompt, an optional
+// system_prompt, and optional per-line overrides of the query options, using
+// the same argument names as the MCP query tool. Requests run on a worker
+// pool, optionally rate limited, and results are written as JSON lines in
+// input order. A failing line is recorded as an error object instead of
+// aborting the run.
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+// maxLineSize caps the size in bytes of a single input line.
+const maxLineSize = 4 << 20
+
+// Line is one prompt of a batch file. Pointer and slice fields are
+// overrides: nil leaves the option set by flags and configuration unchanged.
+type Line struct {
+	UserPrompt   string  `json:"user_prompt"`
+	SystemPrompt *string `json:"system_prompt,omitempty"`
+
+	Model            *string  `json:"model,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopK             *int     `json:"top_k,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+
+	SearchDomains     []string `json:"search_domains,omitempty"`
+	ExcludeDomains    []string `json:"exclude_domains,omitempty"`
+	SearchRecency     *string  `json:"search_recency,omitempty"`
+	SearchMode        *string  `json:"search_mode,omitempty"`
+	SearchContextSize *string  `json:"search_context_size,omitempty"`
+	LocationLat       *float64 `json:"location_lat,omitempty"`
+	LocationLon       *float64 `json:"location_lon,omitempty"`
+	LocationCountry   *string  `json:"location_country,omitempty"`
+
+	ReturnImages  *bool    `json:"return_images,omitempty"`
+	ReturnRelated *bool    `json:"return_related,omitempty"`
+	ImageDomains  []string `json:"image_domains,omitempty"`
+	ImageFormats  []string `json:"image_formats,omitempty"`
+
+	ResponseFormatJSONSchema *string `json:"response_format_json_schema,omitempty"`
+	ResponseFormatRegex      *string `json:"response_format_regex,omitempty"`
+
+	SearchAfterDate   *string `json:"search_after_date,omitempty"`
+	SearchBeforeDate  *string `json:"search_before_date,omitempty"`
+	LastUpdatedAfter  *string `json:"last_updated_after,omitempty"`
+	LastUpdatedBefore *string `json:"last_updated_before,omitempty"`
+
+	ReasoningEffort *string `json:"reasoning_effort,omitempty"`
+}
+
+// Input is a parsed line of a batch file. Number is the 1-based line number
+// in the file; Err is set when the line could not be parsed.
+type Input struct {
+	Number int
+	Line   *Line
+	Err    error
+}
+
+// Read parses a batch file. Blank lines are skipped; a malformed line yields
+// an Input carrying clerrors.ErrInvalidBatchLine so that it is reported in
+// the output rather than aborting the run. Unknown fields are rejected to
+// catch misspelled option names.
+func Read(r io.Reader) ([]Input, error) {
+	var inputs []Input
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+	number := 0
+	for scanner.Scan() {
+		number++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		line, err := parseLine(raw)
+		inputs = append(inputs, Input{Number: number, Line: line, Err: err})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+	return inputs, nil
+}
+
+// parseLine decodes one JSON object.
+func parseLine(raw []byte) (*Line, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var line Line
+	if err := dec.Decode(&line); err != nil {
+		return nil, fmt.Errorf("%w: %s", clerrors.ErrInvalidBatchLine, err.Error())
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("%w: unexpected data after the JSON object", clerrors.ErrInvalidBatchLine)
+	}
+	if strings.TrimSpace(line.UserPrompt) == "" {
+		return nil, fmt.Errorf("%w: user_prompt is required", clerrors.ErrInvalidBatchLine)
+	}
+	return &line, nil
+}
+
+// Apply overlays the line's prompts and overrides onto opts.
+func (l *Line) Apply(opts *config.GlobalOptions) {
+	opts.UserPrompt = l.UserPrompt
+	setIf(&opts.SystemPrompt, l.SystemPrompt)
+
+	setIf(&opts.Model, l.Model)
+	setIf(&opts.FrequencyPenalty, l.FrequencyPenalty)
+	setIf(&opts.MaxTokens, l.MaxTokens)
+	setIf(&opts.PresencePenalty, l.PresencePenalty)
+	setIf(&opts.Temperature, l.Temperature)
+	setIf(&opts.TopK, l.TopK)
+	setIf(&opts.TopP, l.TopP)
+
+	if l.SearchDomains != nil {
+		opts.SearchDomains = l.SearchDomains
+	}
+	if l.ExcludeDomains != nil {
+		opts.ExcludeDomains = l.ExcludeDomains
+	}
+	setIf(&opts.SearchRecency, l.SearchRecency)
+	setIf(&opts.SearchMode, l.SearchMode)
+	setIf(&opts.SearchContextSize, l.SearchContextSize)
+	setIf(&opts.LocationLat, l.LocationLat)
+	setIf(&opts.LocationLon, l.LocationLon)
+	setIf(&opts.LocationCountry, l.LocationCountry)
+
+	setIf(&opts.ReturnImages, l.ReturnImages)
+	setIf(&opts.ReturnRelated, l.ReturnRelated)
+	if l.ImageDomains != nil {
+		opts.ImageDomains = l.ImageDomains
+	}
+	if l.ImageFormats != nil {
+		opts.ImageFormats = l.ImageFormats
+	}
+
+	setIf(&opts.ResponseFormatJSONSchema, l.ResponseFormatJSONSchema)
+	setIf(&opts.ResponseFormatRegex, l.ResponseFormatRegex)
+
+	setIf(&opts.SearchAfterDate, l.SearchAfterDate)
+	setIf(&opts.SearchBeforeDate, l.SearchBeforeDate)
+	setIf(&opts.LastUpdatedAfter, l.LastUpdatedAfter)
+	setIf(&opts.LastUpdatedBefore, l.LastUpdatedBefore)
+
+	setIf(&opts.ReasoningEffort, l.ReasoningEffort)
+}
+
+// setIf assigns *src to *dst when src is set.
+func setIf[T any](dst *T, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
@@ -0,0 +1,87 @@
+package batch
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+func TestRead(t *testing.T) {
+	input := strings.Join([]string{
+		`{"user_prompt": "What is Go?"}`,
+		``,
+		`{"user_prompt": "Summarize", "system_prompt": "Be brief", "model": "sonar-pro"}`,
+		`not json`,
+		`{"user_prompt": "x", "temprature": 0.5}`,
+		`{"system_prompt": "no user prompt"}`,
+		`{"user_prompt": "a"} {"user_prompt": "b"}`,
+	}, "\n")
+
+	inputs, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+
+	var numbers []int
+	for _, in := range inputs {
+		numbers = append(numbers, in.Number)
+	}
+	if want := []int{1, 3, 4, 5, 6, 7}; !slices.Equal(numbers, want) {
+		t.Fatalf("line numbers = %v, want %v", numbers, want)
+	}
+
+	if inputs[0].Err != nil || inputs[0].Line.UserPrompt != "What is Go?" {
+		t.Errorf("line 1 = %+v, %v", inputs[0].Line, inputs[0].Err)
+	}
+	if inputs[1].Err != nil || *inputs[1].Line.SystemPrompt != "Be brief" || *inputs[1].Line.Model != "sonar-pro" {
+		t.Errorf("line 3 = %+v, %v", inputs[1].Line, inputs[1].Err)
+	}
+	for _, in := range inputs[2:] {
+		if !errors.Is(in.Err, clerrors.ErrInvalidBatchLine) {
+			t.Errorf("line %d error = %v, want ErrInvalidBatchLine", in.Number, in.Err)
+		}
+	}
+	if !strings.Contains(inputs[3].Err.Error(), "temprature") {
+		t.Errorf("unknown field error = %v, want the field name", inputs[3].Err)
+	}
+}
+
+func TestLineApply(t *testing.T) {
+	opts := &config.GlobalOptions{
+		Model:         "sonar",
+		SystemPrompt:  "default system",
+		Temperature:   0.2,
+		SearchDomains: []string{"go.dev"},
+		ReturnRelated: true,
+	}
+
+	model := "sonar-pro"
+	temperature := 0.0
+	related := false
+	line := &Line{
+		UserPrompt:    "What is Go?",
+		Model:         &model,
+		Temperature:   &temperature,
+		ReturnRelated: &related,
+		ImageFormats:  []string{"png"},
+	}
+	line.Apply(opts)
+
+	if opts.UserPrompt != "What is Go?" {
+		t.Errorf("UserPrompt = %q", opts.UserPrompt)
+	}
+	if opts.Model != "sonar-pro" || opts.Temperature != 0 || opts.ReturnRelated {
+		t.Errorf("overrides not applied: model=%q temperature=%v related=%v",
+			opts.Model, opts.Temperature, opts.ReturnRelated)
+	}
+	if opts.SystemPrompt != "default system" || !slices.Equal(opts.SearchDomains, []string{"go.dev"}) {
+		t.Errorf("unset fields changed: system=%q domains=%v", opts.SystemPrompt, opts.SearchDomains)
+	}
+	if !slices.Equal(opts.ImageFormats, []string{"png"}) {
+		t.Errorf("ImageFormats = %v", opts.ImageFormats)
+	}
+}
@@ -0,0 +1,172 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/ratelimit"
+)
+
+// DefaultConcurrency is the number of requests in flight when Options does
+// not set one.
+const DefaultConcurrency = 4
+
+// Job is a request prepared from an input line. A job with Err set is
+// reported as failed without calling the API.
+type Job struct {
+	Line    int
+	Request *perplexity.CompletionRequest
+	Err     error
+}
+
+// Result is one line of batch output: the stable JSON document of the answer
+// on success, or the error that line failed with.
+type Result struct {
+	Line int `json:"line"`
+	*output.Result
+	Error string `json:"error,omitempty"`
+}
+
+// SendFunc sends a request to the API.
+type SendFunc func(ctx context.Context, req *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error)
+
+// Options tunes a batch run.
+type Options struct {
+	// Concurrency is the maximum number of requests in flight;
+	// values below 1 select DefaultConcurrency.
+	Concurrency int
+	// RequestsPerMinute caps the rate at which requests start; 0 is unlimited.
+	RequestsPerMinute int
+	// Limiter, when set, is used instead of RequestsPerMinute. It may be
+	// shared with other callers.
+	Limiter *ratelimit.Limiter
+}
+
+// Summary reports the outcome of a run.
+type Summary struct {
+	// Total is the number of jobs given to Run.
+	Total int
+	// Completed is the number of lines written, failed ones included.
+	Completed int
+	// Failed is the number of completed lines that recorded an error.
+	Failed int
+}
+
+// Interrupted reports whether the run stopped before every line completed.
+func (s Summary) Interrupted() bool {
+	return s.Completed < s.Total
+}
+
+// Run sends the jobs' requests with send and writes one Result per job to w,
+// in job order, as soon as all earlier jobs have completed.
+//
+// Cancelling ctx stops new requests from starting; requests already in
+// flight run to completion and are written. Because requests start in job
+// order, the written lines are always a prefix of the input. The returned
+// error reports a failure to write to w; request failures are recorded in
+// the output instead.
+func Run(ctx context.Context, jobs []Job, send SendFunc, w io.Writer, opts Options) (Summary, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+
+	limiter := opts.Limiter
+	if limiter == nil {
+		limiter = ratelimit.New(opts.RequestsPerMinute, 1)
+	}
+
+	results := make([]Result, len(jobs))
+	finished := make(chan int, len(jobs))
+	go func() {
+		dispatch(ctx, jobs, send, results, finished, concurrency, limiter)
+		close(finished)
+	}()
+
+	summary := Summary{Total: len(jobs)}
+	ready := make([]bool, len(jobs))
+	var writeErr error
+	for i := range finished {
+		ready[i] = true
+		for summary.Completed < len(jobs) && ready[summary.Completed] {
+			res := results[summary.Completed]
+			if res.Error != "" {
+				summary.Failed++
+			}
+			if writeErr == nil {
+				writeErr = writeResult(w, res)
+			}
+			summary.Completed++
+		}
+	}
+	return summary, writeErr
+}
+
+// dispatch starts the jobs in order, at most concurrency at a time, and
+// sends each job's index to finished once its result is stored. It returns
+// after every started request has completed.
+func dispatch(ctx context.Context, jobs []Job, send SendFunc, results []Result, finished chan<- int,
+	concurrency int, limiter *ratelimit.Limiter,
+) {
+	// In-flight requests must survive cancellation so their answers are kept.
+	reqCtx := context.WithoutCancel(ctx)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for i, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if job.Err != nil {
+			results[i] = Result{Line: job.Line, Error: job.Err.Error()}
+			finished <- i
+			continue
+		}
+		if limiter.Wait(ctx) != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			resp, err := send(reqCtx, job.Request)
+			if err != nil {
+				results[i] = Result{Line: job.Line, Error: err.Error()}
+			} else {
+				results[i] = Result{Line: job.Line, Result: output.FromResponse(resp, time.Since(start))}
+			}
+			finished <- i
+		}()
+	}
+}
+
+// writeResult writes res as a single JSON line.
+func writeResult(w io.Writer, res Result) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch result for line %d: %w", res.Line, err)
+	}
+	data = append(data, '\n')
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write batch result: %w", err)
+	}
+	return nil
+}
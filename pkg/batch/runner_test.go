@@ -0,0 +1,188 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/ratelimit"
+)
+
+// newJobs returns n jobs whose request model encodes the job index.
+func newJobs(n int) []Job {
+	jobs := make([]Job, n)
+	for i := range jobs {
+		jobs[i] = Job{
+			Line:    i + 1,
+			Request: perplexity.NewCompletionRequest(perplexity.WithModel(fmt.Sprintf("m%d", i+1))),
+		}
+	}
+	return jobs
+}
+
+// echoSend answers with the request model as content after a delay that is
+// longest for the first requests, so they complete out of order.
+func echoSend(n int) SendFunc {
+	return func(_ context.Context, req *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error) {
+		var i int
+		_, _ = fmt.Sscanf(req.Model, "m%d", &i)
+		time.Sleep(time.Duration(n-i) * time.Millisecond)
+		if i == 3 {
+			return nil, errors.New("boom")
+		}
+		return &perplexity.CompletionResponse{
+			Model:   req.Model,
+			Choices: []perplexity.Choice{{Message: perplexity.Message{Content: req.Model}}},
+		}, nil
+	}
+}
+
+// decodeResults parses JSON lines output.
+func decodeResults(t *testing.T, data string) []map[string]any {
+	t.Helper()
+	var results []map[string]any
+	for line := range strings.SplitSeq(strings.TrimSpace(data), "\n") {
+		var res map[string]any
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func TestRun_PreservesOrderAndRecordsFailures(t *testing.T) {
+	const n = 8
+	jobs := newJobs(n)
+	jobs[5] = Job{Line: 6, Err: errors.New("invalid line")}
+
+	var buf bytes.Buffer
+	summary, err := Run(context.Background(), jobs, echoSend(n), &buf, Options{Concurrency: 4})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if summary != (Summary{Total: n, Completed: n, Failed: 2}) || summary.Interrupted() {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	results := decodeResults(t, buf.String())
+	if len(results) != n {
+		t.Fatalf("got %d results, want %d", len(results), n)
+	}
+	for i, res := range results {
+		if line := int(res["line"].(float64)); line != i+1 {
+			t.Errorf("result %d has line %d", i, line)
+		}
+		switch i + 1 {
+		case 3:
+			if res["error"] != "boom" || res["content"] != nil {
+				t.Errorf("line 3 = %v, want only the error", res)
+			}
+		case 6:
+			if res["error"] != "invalid line" {
+				t.Errorf("line 6 = %v, want the validation error", res)
+			}
+		default:
+			if res["content"] != fmt.Sprintf("m%d", i+1) || res["error"] != nil {
+				t.Errorf("line %d = %v", i+1, res)
+			}
+		}
+	}
+}
+
+func TestRun_LimitsConcurrency(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	send := func(_ context.Context, _ *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error) {
+		cur := inFlight.Add(1)
+		for {
+			old := peak.Load()
+			if cur <= old || peak.CompareAndSwap(old, cur) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		inFlight.Add(-1)
+		return &perplexity.CompletionResponse{}, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := Run(context.Background(), newJobs(12), send, &buf, Options{Concurrency: 3}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if got := peak.Load(); got > 3 {
+		t.Errorf("peak concurrency = %d, want at most 3", got)
+	}
+}
+
+func TestRun_CancelFinishesInFlight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	var started atomic.Int32
+	send := func(reqCtx context.Context, _ *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error) {
+		if started.Add(1) == 2 {
+			cancel()
+		}
+		<-release
+		if reqCtx.Err() != nil {
+			return nil, reqCtx.Err()
+		}
+		return &perplexity.CompletionResponse{}, nil
+	}
+	go func() {
+		<-ctx.Done()
+		close(release)
+	}()
+
+	var buf bytes.Buffer
+	summary, err := Run(ctx, newJobs(10), send, &buf, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if summary.Completed != 2 || summary.Failed != 0 || !summary.Interrupted() {
+		t.Errorf("summary = %+v, want 2 completed without failures", summary)
+	}
+	if results := decodeResults(t, buf.String()); len(results) != 2 {
+		t.Errorf("got %d results, want 2", len(results))
+	}
+}
+
+func TestRun_RateLimited(t *testing.T) {
+	start := time.Now()
+	var buf bytes.Buffer
+	summary, err := Run(context.Background(), newJobs(3), echoSend(0), &buf,
+		Options{Concurrency: 3, RequestsPerMinute: 60 * 50}) // one start every 20ms
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if summary.Completed != 3 {
+		t.Errorf("summary = %+v, want 3 completed", summary)
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("3 starts took %v, want at least 40ms of spacing", elapsed)
+	}
+
+	// A shared limiter takes precedence and is charged for every start.
+	limiter := ratelimit.New(1, 5)
+	if _, err := Run(context.Background(), newJobs(3), echoSend(0), &buf,
+		Options{Limiter: limiter, RequestsPerMinute: 1}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if s := limiter.Stats(); s.Allowed != 3 || s.Delayed != 0 {
+		t.Errorf("limiter stats = %+v, want 3 allowed without delay", s)
+	}
+
+	// Cancellation stops a run waiting on the limiter.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	summary, _ = Run(ctx, newJobs(2), echoSend(0), &buf, Options{Limiter: ratelimit.New(1, 1)})
+	if !summary.Interrupted() {
+		t.Errorf("summary = %+v, want an interrupted run", summary)
+	}
+}
@@ -0,0 +1,214 @@
+// Package cache stores completion responses on disk so that repeating an
+// identical query can be answered without another API request.
+//
+// Entries live in ~/.cache/pplx/responses, one JSON file per request, named
+// after a SHA-256 hash of the complete request (model, messages, and every
+// option). Whether a request is streamed does not change its key, so a
+// streaming query can replay the answer of an earlier non-streaming one.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+const (
+	// DefaultTTL is how long entries are served when no TTL is configured.
+	DefaultTTL = time.Hour
+
+	// keyVersion is mixed into every key so that a change to the entry
+	// format invalidates older entries instead of misreading them.
+	keyVersion = "v1"
+
+	// entryExt is the file extension of cache entries.
+	entryExt = ".json"
+
+	// File permissions for the cache.
+	cacheDirPerms  = 0o700
+	cacheFilePerms = 0o600
+)
+
+// Entry is a cached response with the time it was stored.
+type Entry struct {
+	Created  time.Time                     `json:"created"`
+	Response perplexity.CompletionResponse `json:"response"`
+}
+
+// Age returns how long ago the entry was stored.
+func (e *Entry) Age(now time.Time) time.Duration {
+	return now.Sub(e.Created)
+}
+
+// Stats summarizes the content of a cache directory.
+type Stats struct {
+	Dir     string    `json:"dir"`
+	Entries int       `json:"entries"`
+	Bytes   int64     `json:"bytes"`
+	Oldest  time.Time `json:"oldest,omitzero"`
+	Newest  time.Time `json:"newest,omitzero"`
+}
+
+// Cache is an on-disk response cache.
+type Cache struct {
+	dir string
+	// now returns the current time; replaced in tests.
+	now func() time.Time
+}
+
+// New returns a cache storing entries in dir. The directory is created on
+// the first write.
+func New(dir string) *Cache {
+	return &Cache{dir: dir, now: time.Now}
+}
+
+// DefaultDir returns the cache location, ~/.cache/pplx/responses.
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory for response cache: %w", err)
+	}
+	return filepath.Join(home, ".cache", "pplx", "responses"), nil
+}
+
+// Dir returns the directory holding the entries.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Key returns the cache key of req. Requests that differ only in streaming
+// share a key.
+func Key(req *perplexity.CompletionRequest) (string, error) {
+	keyed := *req
+	keyed.Stream = false
+	data, err := json.Marshal(&keyed)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request for cache key: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(keyVersion+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Get returns the entry stored under key if it is younger than ttl. A
+// missing, expired, or unreadable entry is a miss, not an error.
+func (c *Cache) Get(key string, ttl time.Duration) (*Entry, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if ttl > 0 && entry.Age(c.now()) > ttl {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Put stores response under key, replacing any previous entry. The entry is
+// written to a temporary file and renamed, so readers never see a partial
+// entry.
+func (c *Cache) Put(key string, response *perplexity.CompletionResponse) error {
+	if err := os.MkdirAll(c.dir, cacheDirPerms); err != nil {
+		return fmt.Errorf("failed to create cache directory %s: %w", c.dir, err)
+	}
+	data, err := json.Marshal(Entry{Created: c.now(), Response: *response})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // no-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck,gosec // write error takes precedence
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), cacheFilePerms); err != nil {
+		return fmt.Errorf("failed to set cache entry permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+	return nil
+}
+
+// Clear removes every entry and returns how many were removed. A missing
+// cache directory is an empty cache.
+func (c *Cache) Clear() (int, error) {
+	files, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, file := range files {
+		if err := os.Remove(filepath.Join(c.dir, file.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Stats returns the number, total size, and age range of the entries.
+func (c *Cache) Stats() (Stats, error) {
+	stats := Stats{Dir: c.dir}
+	files, err := c.entries()
+	if err != nil {
+		return stats, err
+	}
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil {
+			continue // removed concurrently
+		}
+		stats.Entries++
+		stats.Bytes += info.Size()
+		modified := info.ModTime()
+		if stats.Oldest.IsZero() || modified.Before(stats.Oldest) {
+			stats.Oldest = modified
+		}
+		if modified.After(stats.Newest) {
+			stats.Newest = modified
+		}
+	}
+	return stats, nil
+}
+
+// entries lists the entry files of the cache directory.
+func (c *Cache) entries() ([]fs.DirEntry, error) {
+	all, err := os.ReadDir(c.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory %s: %w", c.dir, err)
+	}
+	files := make([]fs.DirEntry, 0, len(all))
+	for _, entry := range all {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), entryExt) {
+			files = append(files, entry)
+		}
+	}
+	return files, nil
+}
+
+// path returns the file of the entry stored under key.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+entryExt)
+}
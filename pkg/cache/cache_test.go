@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+func newRequest(prompt string, stream bool) *perplexity.CompletionRequest {
+	msgs := perplexity.NewMessages()
+	_ = msgs.AddUserMessage(prompt)
+	return perplexity.NewCompletionRequest(
+		perplexity.WithMessages(msgs.GetMessages()),
+		perplexity.WithStream(stream),
+	)
+}
+
+func newResponse(content string) *perplexity.CompletionResponse {
+	return &perplexity.CompletionResponse{
+		Model: "sonar",
+		Choices: []perplexity.Choice{
+			{Message: perplexity.Message{Role: "assistant", Content: content}},
+		},
+	}
+}
+
+func TestKey(t *testing.T) {
+	a, err := Key(newRequest("What is Go?", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed, err := Key(newRequest("What is Go?", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Key(newRequest("What is Rust?", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a != streamed {
+		t.Errorf("streaming changed the key: %s != %s", a, streamed)
+	}
+	if a == other {
+		t.Error("different prompts share a key")
+	}
+	if len(a) != 64 {
+		t.Errorf("key length = %d, want 64 hex characters", len(a))
+	}
+}
+
+func TestPutGet(t *testing.T) {
+	c := New(filepath.Join(t.TempDir(), "responses"))
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	if _, ok := c.Get("k", time.Hour); ok {
+		t.Fatal("Get() on empty cache reported a hit")
+	}
+	if err := c.Put("k", newResponse("Go is a language.")); err != nil {
+		t.Fatalf("Put() error: %v", err)
+	}
+
+	now = now.Add(30 * time.Minute)
+	entry, ok := c.Get("k", time.Hour)
+	if !ok {
+		t.Fatal("Get() missed a fresh entry")
+	}
+	if got := entry.Response.GetLastContent(); got != "Go is a language." {
+		t.Errorf("content = %q", got)
+	}
+	if age := entry.Age(now); age != 30*time.Minute {
+		t.Errorf("Age() = %v, want 30m", age)
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok := c.Get("k", time.Hour); ok {
+		t.Error("Get() served an expired entry")
+	}
+	if _, ok := c.Get("k", 0); !ok {
+		t.Error("Get() with no TTL missed the entry")
+	}
+}
+
+func TestGet_CorruptEntryIsMiss(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "k.json"), []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := New(dir).Get("k", time.Hour); ok {
+		t.Error("Get() reported a hit for a corrupt entry")
+	}
+}
+
+func TestStatsAndClear(t *testing.T) {
+	c := New(filepath.Join(t.TempDir(), "responses"))
+
+	stats, err := c.Stats()
+	if err != nil || stats.Entries != 0 {
+		t.Fatalf("Stats() on missing dir = %+v, %v; want empty", stats, err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		if err := c.Put(key, newResponse(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err = c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 2 || stats.Bytes == 0 || stats.Oldest.IsZero() || stats.Newest.IsZero() {
+		t.Errorf("Stats() = %+v, want 2 entries", stats)
+	}
+
+	removed, err := c.Clear()
+	if err != nil || removed != 2 {
+		t.Fatalf("Clear() = %d, %v; want 2", removed, err)
+	}
+	if _, ok := c.Get("a", 0); ok {
+		t.Error("entry survived Clear()")
+	}
+}
@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+// autosaveDirPerms is the permission of the directory of the autosave file.
+const autosaveDirPerms = 0o700
+
+// Autosave is a chat session saved after each turn, so that it can be
+// resumed after a crash or a closed terminal. It is a JSON transcript with
+// the session options and usage added: ReadTranscript and "pplx chat
+// export" read it like a session saved with /fork.
+type Autosave struct {
+	Transcript
+
+	// Options are the session options, including a model switched with
+	// /model. Callbacks and the warnings collector are not saved.
+	Options Options `json:"options"`
+	// Totals is the usage of the session so far.
+	Totals Totals `json:"totals"`
+	// Saved is when the file was written.
+	Saved time.Time `json:"saved"`
+}
+
+// DefaultAutosavePath returns the autosave location,
+// ~/.local/share/pplx/autosave.json.
+func DefaultAutosavePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory for chat autosave: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "pplx", "autosave.json"), nil
+}
+
+// Autosave returns the state of the session to save.
+func (c *Chat) Autosave() Autosave {
+	return Autosave{
+		Transcript: c.Transcript(),
+		Options:    c.options.clone(),
+		Totals:     c.totals,
+		Saved:      c.now(),
+	}
+}
+
+// WriteAutosave saves the session to path through a temporary file renamed
+// into place, so a crash while writing never leaves a partial file.
+func (c *Chat) WriteAutosave(path string) error {
+	data, err := json.MarshalIndent(c.Autosave(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode chat autosave: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), autosaveDirPerms); err != nil {
+		return fmt.Errorf("failed to create chat autosave directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write chat autosave: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write chat autosave: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write chat autosave: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write chat autosave: %w", err)
+	}
+	return nil
+}
+
+// ReadAutosave reads the autosave at path. A missing file yields nil and no
+// error. A file that is not a valid autosave, such as a truncated or edited
+// one, yields an error and should be ignored.
+func ReadAutosave(path string) (*Autosave, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read chat autosave: %w", err)
+	}
+	var a Autosave
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("failed to read chat autosave %s: %w", path, err)
+	}
+	if err := a.validate(); err != nil {
+		return nil, fmt.Errorf("failed to read chat autosave %s: %w", path, err)
+	}
+	return &a, nil
+}
+
+// validate checks that the messages alternate user and assistant turns
+// starting with a question, as Resume requires.
+func (a *Autosave) validate() error {
+	if a.Saved.IsZero() {
+		return errors.New("no save time")
+	}
+	for i, msg := range a.Messages {
+		want := "user"
+		if i%2 == 1 {
+			want = "assistant"
+		}
+		if msg.Role != want {
+			return fmt.Errorf("message %d has role %q, want %q", i+1, msg.Role, want)
+		}
+	}
+	return nil
+}
+
+// Resume returns a chat continuing the saved session with client and
+// options. Callers usually pass the saved Options with their callbacks set.
+func (a *Autosave) Resume(client *perplexity.Client, options Options) (*Chat, error) {
+	c := NewChatWithOptions(client, a.SystemPrompt, options)
+	history := make([]perplexity.Message, 0, len(a.Messages))
+	for _, msg := range a.Messages {
+		history = append(history, perplexity.Message{Role: msg.Role, Content: msg.Content})
+		c.times = append(c.times, msg.Timestamp)
+		if msg.Role == "assistant" {
+			c.replies = append(c.replies, reply{
+				model:    msg.Model,
+				sources:  slices.Clone(msg.Sources),
+				searches: slices.Clone(msg.SearchQueries),
+				usage:    msg.Usage,
+			})
+		}
+	}
+	if err := c.rebuild(a.SystemPrompt, history); err != nil {
+		return nil, err
+	}
+	if !a.Started.IsZero() {
+		c.started = a.Started
+	}
+	c.totals = a.Totals
+	return c, nil
+}
+
+// DiscardAutosave removes the autosave at path when it holds this session,
+// written by WriteAutosave or resumed. Call it when the session ends
+// cleanly. An autosave of another session is kept.
+func (c *Chat) DiscardAutosave(path string) error {
+	a, err := ReadAutosave(path)
+	if err != nil || a == nil || !a.Started.Equal(c.started) {
+		return nil //nolint:nilerr // not this session's autosave
+	}
+	return RemoveAutosave(path)
+}
+
+// RemoveAutosave removes the autosave at path, if any.
+func RemoveAutosave(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove chat autosave: %w", err)
+	}
+	return nil
+}
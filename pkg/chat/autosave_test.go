@@ -0,0 +1,139 @@
+package chat
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// autosavedChat returns a chat holding the two turns of testTranscript.
+func autosavedChat(t *testing.T) *Chat {
+	t.Helper()
+	saved := &Autosave{Transcript: *testTranscript(), Options: Options{Model: "sonar-pro", Temperature: 0.3}}
+	c, err := saved.Resume(nil, saved.Options)
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	c.now = func() time.Time { return time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC) }
+	return c
+}
+
+func TestAutosave_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pplx", "autosave.json")
+	c := autosavedChat(t)
+	c.options.Warnings = warnings.New()
+	c.options.OnStream = func(string, string) {}
+	c.totals = Totals{Requests: 2, TotalTokens: 80, Cost: 0.01}
+
+	if err := c.WriteAutosave(path); err != nil {
+		t.Fatalf("WriteAutosave() error = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != transcriptFilePerms {
+		t.Fatalf("autosave file = %v, %v; want mode %o", info, err, transcriptFilePerms)
+	}
+	saved, err := ReadAutosave(path)
+	if err != nil || saved == nil {
+		t.Fatalf("ReadAutosave() = %v, %v", saved, err)
+	}
+	if saved.Options.Model != "sonar-pro" || saved.Options.Temperature != 0.3 || saved.Totals != c.totals {
+		t.Errorf("options %+v, totals %+v not restored", saved.Options, saved.Totals)
+	}
+
+	resumed, err := saved.Resume(nil, saved.Options)
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if got, want := resumed.Transcript(), c.Transcript(); !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed transcript = %+v\nwant %+v", got, want)
+	}
+	if resumed.Totals() != c.totals {
+		t.Errorf("resumed totals = %+v, want %+v", resumed.Totals(), c.totals)
+	}
+	if err := resumed.AddUserMessage("and then?"); err != nil {
+		t.Errorf("the resumed conversation does not accept a question: %v", err)
+	}
+}
+
+func TestReadAutosave_Missing(t *testing.T) {
+	saved, err := ReadAutosave(filepath.Join(t.TempDir(), "autosave.json"))
+	if saved != nil || err != nil {
+		t.Errorf("ReadAutosave() = %v, %v; want nil, nil", saved, err)
+	}
+}
+
+func TestReadAutosave_Corrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", ""},
+		{"truncated", `{"model":"sonar","messages":[{"role":"user","con`},
+		{"null", "null"},
+		{"not an object", `["user"]`},
+		{"no save time", `{"messages":[]}`},
+		{"wrong type", `{"saved":"2026-03-14T10:00:00Z","messages":"hello"}`},
+		{"answer first", `{"saved":"2026-03-14T10:00:00Z","messages":[{"role":"assistant","content":"hi"}]}`},
+		{"unknown role", `{"saved":"2026-03-14T10:00:00Z","messages":[{"role":"system","content":"hi"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "autosave.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if saved, err := ReadAutosave(path); err == nil {
+				t.Errorf("ReadAutosave() = %+v, want an error", saved)
+			}
+		})
+	}
+}
+
+func FuzzReadAutosave(f *testing.F) {
+	f.Add(`{"saved":"2026-03-14T10:00:00Z","messages":[{"role":"user","content":"hi"}]}`)
+	f.Add(`{"saved":"2026-03-14T10:00:00Z","messages":[{"role":"user"},{"role":"assistant","usage":{}}]}`)
+	f.Add(`{"options":{"Model":"sonar","SearchDomains":null},"totals":{"Requests":-1}}`)
+	f.Fuzz(func(t *testing.T, content string) {
+		path := filepath.Join(t.TempDir(), "autosave.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		saved, err := ReadAutosave(path)
+		if err != nil {
+			return
+		}
+		if _, err := saved.Resume(nil, saved.Options); err != nil {
+			t.Errorf("Resume() of a valid autosave error = %v", err)
+		}
+	})
+}
+
+func TestDiscardAutosave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autosave.json")
+	c := autosavedChat(t)
+	if err := c.WriteAutosave(path); err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewChatWithOptions(nil, "", Options{Model: "sonar"})
+	if err := other.DiscardAutosave(path); err != nil {
+		t.Fatalf("DiscardAutosave() error = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("the autosave of another session was removed: %v", err)
+	}
+
+	fork, err := c.Clone()
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	if err := fork.DiscardAutosave(path); err != nil {
+		t.Fatalf("DiscardAutosave() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the session's own autosave was kept: %v", err)
+	}
+}
@@ -2,15 +2,26 @@
 package chat
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/citations"
 	"github.com/sgaunet/pplx/pkg/clerrors"
-	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/hooks"
 	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/pplx"
+	"github.com/sgaunet/pplx/pkg/prompts"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/searches"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/sgaunet/pplx/pkg/warnings"
 )
 
 // Options contains all configuration options for a chat session.
@@ -23,6 +34,7 @@ type Options struct {
 	TopK             int
 	TopP             float64
 	SearchDomains    []string
+	ExcludeDomains   []string
 	SearchRecency    string
 	LocationLat      float64
 	LocationLon      float64
@@ -32,6 +44,13 @@ type Options struct {
 	Stream           bool
 	ImageDomains     []string
 	ImageFormats     []string
+	// ImageConflictPolicy decides which of SearchRecency and ReturnImages
+	// is sent when both are set; see validation.ResolveImageConflict.
+	ImageConflictPolicy string
+	// StrictOptions rejects requests combining Stream with a response
+	// format instead of sending them unstreamed with a warning; see
+	// validation.ResolveStreamFormat.
+	StrictOptions bool
 	
 	// Response format options
 	ResponseFormatJSONSchema string
@@ -49,13 +68,82 @@ type Options struct {
 	
 	// Deep research options
 	ReasoningEffort string
+
+	// Language is the ISO 639-1 code of the answer language. Its instruction
+	// is added to the system message of each request unless the system
+	// message already names a language.
+	Language string
+
+	// Context window options
+	// MaxContextTokens caps the estimated prompt size; the oldest
+	// user/assistant pairs are left out of requests to fit. Zero disables it.
+	MaxContextTokens int
+	// SummarizeOnTrim replaces the left-out turns with a conversation summary.
+	SummarizeOnTrim bool
+
+	// ModelFallbacks are the models a request is sent again with, in order,
+	// when the API reports Model unavailable or invalid.
+	ModelFallbacks []string
+
+	// AutoContinue is how many continuation requests are sent at most when
+	// an answer is cut off at max_tokens; see Continue. Zero disables it.
+	AutoContinue int
+
+	// OnStream receives the answer as it streams in when Stream is set.
+	OnStream StreamCallback `json:"-"`
+
+	// Warnings collects the non-fatal issues of the session, such as a
+	// reasoning effort the model ignores. When nil they are logged.
+	Warnings *warnings.Collector `json:"-"`
 }
 
+// StreamCallback receives a streamed answer as it arrives: delta is the text
+// added since the previous call and cumulative the whole answer so far. When
+// the API revises text it already sent, delta is the whole of cumulative and
+// the answer should be drawn again.
+type StreamCallback func(delta, cumulative string)
+
 // Chat represents a chat session with the Perplexity API.
 type Chat struct {
 	Messages perplexity.Messages
 	client   *perplexity.Client
 	options  Options
+	// replies holds per-answer details, one entry per assistant message.
+	replies []reply
+	// times holds when each history message was added, in history order.
+	times   []time.Time
+	started time.Time
+	// now returns the current time; tests replace it for stable transcripts.
+	now    func() time.Time
+	totals Totals
+	// trimmed is how many history messages the last request left out.
+	trimmed int
+	// hooks run around every request Run sends.
+	hooks hooks.Chain
+	// warned holds the request warnings already reported, which are
+	// reported once per session, not on every message.
+	warned map[warnings.Warning]bool
+}
+
+// reply records details of an assistant message that the message history
+// does not keep, for transcripts.
+type reply struct {
+	model    string
+	sources  []citations.Source
+	searches []string
+	usage    *perplexity.Usage
+}
+
+// Totals is the cumulative token usage of a chat session.
+type Totals struct {
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	// Cost is the estimated cost in USD of the priced requests.
+	Cost float64
+	// Unpriced counts requests whose model had no known price.
+	Unpriced int
 }
 
 // NewChat creates a new chat instance with individual parameters for backward compatibility.
@@ -83,6 +171,8 @@ func NewChatWithOptions(client *perplexity.Client, systemMessage string, options
 		Messages: msg,
 		client:   client,
 		options:  options,
+		started:  time.Now(),
+		now:      time.Now,
 	}
 }
 
@@ -92,6 +182,7 @@ func (c *Chat) AddUserMessage(message string) error {
 	if err != nil {
 		return fmt.Errorf("error adding user message: %w", err)
 	}
+	c.times = append(c.times, c.now())
 	return nil
 }
 
@@ -101,210 +192,411 @@ func (c *Chat) AddAgentMessage(message string) error {
 	if err != nil {
 		return fmt.Errorf("error adding agent message: %w", err)
 	}
+	c.replies = append(c.replies, reply{model: c.options.Model})
+	c.times = append(c.times, c.now())
 	return nil
 }
 
-// Run executes the chat request with the configured options.
-func (c *Chat) Run() (*perplexity.CompletionResponse, error) {
-	opts, err := c.buildRequestOptions()
+// Send runs the request for the pending user message and, on success, adds
+// the answer to the conversation and its usage to the session totals.
+// overrides apply to this request only; see Run.
+func (c *Chat) Send(overrides ...OptionOverride) (*perplexity.CompletionResponse, error) {
+	return c.SendContext(context.Background(), overrides...)
+}
+
+// SendContext is Send with a context cancelling the request. When ctx is
+// cancelled while an answer is streaming, the part received so far is added
+// to the conversation and returned, with usage estimated when the API sent
+// none, along with an error wrapping clerrors.ErrRequestInterrupted. When
+// nothing was received, the pending user message is removed instead.
+func (c *Chat) SendContext(ctx context.Context, overrides ...OptionOverride) (*perplexity.CompletionResponse, error) {
+	defer c.withOverrides(overrides)()
+	req, err := c.Request()
 	if err != nil {
 		return nil, err
 	}
-
-	req := perplexity.NewCompletionRequest(opts...)
-	err = req.Validate()
+	response, err := c.send(ctx, req, "")
+	if errors.Is(err, clerrors.ErrRequestInterrupted) {
+		return c.keepPartial(response, err)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error validating completion request: %w", err)
+		return nil, err
+	}
+	c.totals.add(response)
+	if c.options.AutoContinue > 0 && Truncated(response) {
+		// Each continuation is added to the totals as the request it is.
+		response, err = Continue(req, response, c.options.AutoContinue,
+			func(next *perplexity.CompletionRequest, answer string) (*perplexity.CompletionResponse, error) {
+				res, err := c.send(ctx, next, answer)
+				if err == nil {
+					c.totals.add(res)
+				}
+				return res, err
+			})
+		if err != nil {
+			c.options.Warnings.Add(warnings.CodeTruncated,
+				fmt.Sprintf("failed to continue the truncated answer: %v", err), "")
+		} else if Truncated(response) {
+			c.options.Warnings.Add(warnings.CodeTruncated, fmt.Sprintf(
+				"answer still cut off at max_tokens after %d continuations", c.options.AutoContinue),
+				"raise --max-tokens or --auto-continue")
+		}
+	}
+	if err := c.addAnswer(response); err != nil {
+		return nil, err
 	}
+	return response, nil
+}
 
-	res, err := c.client.SendCompletionRequest(req)
-	if err != nil {
-		return nil, fmt.Errorf("error sending completion request: %w", err)
+// keepPartial ends a request interrupted with err: the partial answer of
+// response, if any, is added to the conversation; otherwise the question is
+// removed so that the conversation still alternates.
+func (c *Chat) keepPartial(response *perplexity.CompletionResponse, err error) (*perplexity.CompletionResponse, error) {
+	if content, contentErr := output.FirstContent(response); contentErr == nil && content != "" {
+		c.totals.add(response)
+		if addErr := c.addAnswer(response); addErr != nil {
+			return nil, addErr
+		}
+		return response, err
 	}
-	return res, nil
+	if history := c.history(); len(history) > 0 && history[len(history)-1].Role == "user" {
+		_ = c.TruncateLast(1)
+	}
+	return nil, err
 }
 
-func (c *Chat) buildRequestOptions() ([]perplexity.CompletionRequestOption, error) {
-	opts := []perplexity.CompletionRequestOption{
-		perplexity.WithMessages(c.Messages.GetMessages()),
-		perplexity.WithModel(c.options.Model),
-		perplexity.WithFrequencyPenalty(c.options.FrequencyPenalty),
-		perplexity.WithMaxTokens(c.options.MaxTokens),
-		perplexity.WithPresencePenalty(c.options.PresencePenalty),
-		perplexity.WithTemperature(c.options.Temperature),
-		perplexity.WithTopK(c.options.TopK),
-		perplexity.WithTopP(c.options.TopP),
+// addAnswer adds the answer of response to the conversation with the
+// details transcripts need.
+func (c *Chat) addAnswer(response *perplexity.CompletionResponse) error {
+	content, err := output.FirstContent(response)
+	if err != nil {
+		return err //nolint:wrapcheck // already an APIError
+	}
+	if err := c.AddAgentMessage(content); err != nil {
+		return err
 	}
 
-	if err := c.addSearchOptions(&opts); err != nil {
-		return nil, err
+	last := &c.replies[len(c.replies)-1]
+	if response.Model != "" {
+		last.model = response.Model
 	}
-	c.addResponseOptions(&opts)
-	c.addImageOptions(&opts)
-	if err := c.addFormatOptions(&opts); err != nil {
-		return nil, err
+	last.sources = citations.FromResponse(response)
+	last.searches = searches.Lookup(response.ID)
+	responseUsage := response.Usage
+	last.usage = &responseUsage
+	return nil
+}
+
+// Model returns the model used for the next request.
+func (c *Chat) Model() string {
+	return c.options.Model
+}
+
+// SetModel switches the model used for subsequent requests.
+func (c *Chat) SetModel(model string) {
+	c.options.Model = model
+}
+
+// SetSystemMessage replaces the system message, keeping the conversation.
+// An empty message removes it.
+func (c *Chat) SetSystemMessage(message string) error {
+	return c.rebuild(message, c.history())
+}
+
+// Clear removes every user and assistant message, keeping the system message
+// and the session usage totals.
+func (c *Chat) Clear() {
+	c.Messages = perplexity.NewMessages(perplexity.WithSystemMessage(c.Messages.GetSystemMessage()))
+	c.replies = nil
+	c.times = nil
+}
+
+// PrepareRetry removes the last answer so that Send resends the last user
+// message. Returns clerrors.ErrNothingToRetry when no message has been sent.
+func (c *Chat) PrepareRetry() error {
+	history := c.history()
+	if len(history) == 0 {
+		return clerrors.ErrNothingToRetry
 	}
-	if err := c.addModeOptions(&opts); err != nil {
-		return nil, err
+	if history[len(history)-1].Role != "assistant" {
+		// The last request failed; its user message is still pending.
+		return nil
 	}
-	if err := c.addDateOptions(&opts); err != nil {
-		return nil, err
+	if err := c.rebuild(c.Messages.GetSystemMessage(), history[:len(history)-1]); err != nil {
+		return err
+	}
+	if len(c.replies) > 0 {
+		c.replies = c.replies[:len(c.replies)-1]
+	}
+	if len(c.times) >= len(history) {
+		c.times = c.times[:len(history)-1]
 	}
-	if err := c.addResearchOptions(&opts); err != nil {
+	return nil
+}
+
+// TruncateLast removes the last n user/assistant exchanges, keeping the
+// system message. A trailing question without an answer counts as an
+// exchange. Returns clerrors.ErrChatCommandUsage when n is not positive and
+// clerrors.ErrRewindPastStart when the conversation has fewer than n
+// exchanges; the conversation is unchanged on error.
+func (c *Chat) TruncateLast(n int) error {
+	if n < 1 {
+		return fmt.Errorf("%w: the number of exchanges to remove must be positive", clerrors.ErrChatCommandUsage)
+	}
+	history := c.history()
+	exchanges := (len(history) + 1) / 2
+	if n > exchanges {
+		return fmt.Errorf("%w: %d exchange(s) requested, %d in the conversation",
+			clerrors.ErrRewindPastStart, n, exchanges)
+	}
+	// History alternates user and assistant messages starting with a user
+	// message, so keeping whole exchanges keeps the alternation valid.
+	keep := 2 * (exchanges - n)
+	if err := c.rebuild(c.Messages.GetSystemMessage(), history[:keep]); err != nil {
+		return err
+	}
+	c.replies = c.replies[:min(len(c.replies), keep/2)]
+	c.times = c.times[:min(len(c.times), keep)]
+	return nil
+}
+
+// Clone returns an independent copy of the conversation with the same
+// client, options, hooks and usage totals. Changes to either chat do not
+// affect the other. It fails when the history cannot be copied, such as a
+// history that does not alternate between user and assistant messages; c is
+// left as it is.
+func (c *Chat) Clone() (*Chat, error) {
+	clone := *c
+	// Messages shares its slice with c; rebuild gives the clone its own.
+	if err := clone.rebuild(c.Messages.GetSystemMessage(), c.history()); err != nil {
 		return nil, err
 	}
+	clone.replies = slices.Clone(c.replies)
+	clone.times = slices.Clone(c.times)
+	clone.hooks = slices.Clone(c.hooks)
+	clone.warned = maps.Clone(c.warned)
+	clone.options = c.options.clone()
+	return &clone, nil
+}
+
+// Totals returns the cumulative usage of the session.
+func (c *Chat) Totals() Totals {
+	return c.totals
+}
 
-	return opts, nil
+// Trimmed returns how many history messages the last request left out to fit
+// Options.MaxContextTokens. The conversation itself keeps every message.
+func (c *Chat) Trimmed() int {
+	return c.trimmed
 }
 
-// addSearchOptions adds search-related options to the completion request.
-// Validates search recency against API-supported time windows.
-func (c *Chat) addSearchOptions(opts *[]perplexity.CompletionRequestOption) error {
-	if len(c.options.SearchDomains) > 0 {
-		*opts = append(*opts, perplexity.WithSearchDomainFilter(c.options.SearchDomains))
+// contextMessages returns the messages to send, trimmed to fit
+// Options.MaxContextTokens, and how many history messages were left out.
+func (c *Chat) contextMessages() ([]perplexity.Message, int) {
+	var system []perplexity.Message
+	if msg := prompts.WithLanguage(c.Messages.GetSystemMessage(), c.options.Language); msg != "" {
+		system = []perplexity.Message{{Role: "system", Content: msg}}
 	}
-	if c.options.SearchRecency != "" {
-		// Validation map: API only accepts these exact time window strings
-		// Rationale: Centralized here rather than in validator package because
-		// these constraints come from the Perplexity API specification, not our domain logic.
-		// Keeping validation close to API call makes it easier to update when API changes.
-		if !config.ValidSearchRecency[c.options.SearchRecency] {
-			return fmt.Errorf("%w: '%s'. Must be one of: day, week, month, year, hour",
-				clerrors.ErrInvalidSearchRecency, c.options.SearchRecency)
+	return fitContext(system, c.history(), c.options.MaxContextTokens, c.options.SummarizeOnTrim)
+}
+
+// history returns the user and assistant messages, without the system message.
+func (c *Chat) history() []perplexity.Message {
+	var history []perplexity.Message
+	for _, msg := range c.Messages.GetMessages() {
+		if msg.Role != "system" {
+			history = append(history, msg)
 		}
-		*opts = append(*opts, perplexity.WithSearchRecencyFilter(c.options.SearchRecency))
 	}
-	if c.options.LocationLat != 0 || c.options.LocationLon != 0 || c.options.LocationCountry != "" {
-		*opts = append(*opts, perplexity.WithUserLocation(
-			c.options.LocationLat, c.options.LocationLon, c.options.LocationCountry))
+	return history
+}
+
+// rebuild replaces the conversation with system followed by history.
+// Reply details are left untouched.
+func (c *Chat) rebuild(system string, history []perplexity.Message) error {
+	msgs := perplexity.NewMessages(perplexity.WithSystemMessage(system))
+	for _, msg := range history {
+		var err error
+		if msg.Role == "assistant" {
+			err = msgs.AddAgentMessage(msg.Content)
+		} else {
+			err = msgs.AddUserMessage(msg.Content)
+		}
+		if err != nil {
+			return fmt.Errorf("error rebuilding chat history: %w", err)
+		}
 	}
+	c.Messages = msgs
 	return nil
 }
 
-func (c *Chat) addResponseOptions(opts *[]perplexity.CompletionRequestOption) {
-	if c.options.ReturnImages {
-		*opts = append(*opts, perplexity.WithReturnImages(c.options.ReturnImages))
-	}
-	if c.options.ReturnRelated {
-		*opts = append(*opts, perplexity.WithReturnRelatedQuestions(c.options.ReturnRelated))
+// add accumulates the usage of response.
+func (t *Totals) add(response *perplexity.CompletionResponse) {
+	t.Requests++
+	t.PromptTokens += response.Usage.PromptTokens
+	t.CompletionTokens += response.Usage.CompletionTokens
+	t.TotalTokens += response.Usage.TotalTokens
+	if cost, priced := usage.EstimateCost(response.Model, response.Usage); priced {
+		t.Cost += cost
+	} else {
+		t.Unpriced++
 	}
 }
 
-func (c *Chat) addImageOptions(opts *[]perplexity.CompletionRequestOption) {
-	if len(c.options.ImageDomains) > 0 {
-		*opts = append(*opts, perplexity.WithImageDomainFilter(c.options.ImageDomains))
-	}
-	if len(c.options.ImageFormats) > 0 {
-		*opts = append(*opts, perplexity.WithImageFormatFilter(c.options.ImageFormats))
+// Use registers hook to run around every request the session sends, after
+// the hooks registered before it.
+func (c *Chat) Use(hook hooks.Hook) {
+	c.hooks = append(c.hooks, hook)
+}
+
+// Run executes the chat request with the configured options. overrides are
+// applied on top of a copy of the options for this request only, and the
+// merged options are validated before anything is sent. Registered hooks see
+// the request before it is sent and its result; a hook error aborts the
+// request.
+func (c *Chat) Run(overrides ...OptionOverride) (*perplexity.CompletionResponse, error) {
+	return c.RunContext(context.Background(), overrides...)
+}
+
+// RunContext is Run with a context cancelling the request. An interrupted
+// stream returns the partial response, as SendContext describes.
+func (c *Chat) RunContext(ctx context.Context, overrides ...OptionOverride) (*perplexity.CompletionResponse, error) {
+	defer c.withOverrides(overrides)()
+	req, err := c.Request()
+	if err != nil {
+		return nil, err
 	}
+	return c.send(ctx, req, "")
 }
 
-func (c *Chat) addFormatOptions(opts *[]perplexity.CompletionRequestOption) error {
-	if c.options.ResponseFormatJSONSchema != "" && c.options.ResponseFormatRegex != "" {
-		return clerrors.ErrConflictingResponseFormats
+// send sends req through the registered hooks. When req continues an answer,
+// answer is the answer so far, which streamed text is joined to. A stream
+// cancelled through ctx returns its partial response with an error wrapping
+// clerrors.ErrRequestInterrupted; hooks see both.
+func (c *Chat) send(ctx context.Context, req *perplexity.CompletionRequest,
+	answer string,
+) (*perplexity.CompletionResponse, error) {
+	if err := c.hooks.Before(req); err != nil {
+		return nil, err //nolint:wrapcheck // wraps clerrors.ErrRequestAborted
 	}
-	if c.options.ResponseFormatJSONSchema != "" || c.options.ResponseFormatRegex != "" {
-		if !strings.HasPrefix(c.options.Model, "sonar") {
-			return clerrors.ErrResponseFormatNotSupported
-		}
+
+	start := time.Now()
+	result, err := pplx.Send(ctx, c.client, req, pplx.SendOptions{
+		Fallbacks: c.options.ModelFallbacks,
+		OnEvent:   c.streamed(answer),
+	})
+	res := result.Response
+	if err == nil && result.FellBack() {
+		c.options.Warnings.Add(warnings.CodeModelFallback, result.Note(), "")
 	}
-	if c.options.ResponseFormatJSONSchema != "" {
-		var schema any
-		err := json.Unmarshal([]byte(c.options.ResponseFormatJSONSchema), &schema)
-		if err != nil {
-			return fmt.Errorf("invalid JSON schema: %w", err)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w: %w", clerrors.ErrRequestInterrupted, err)
+		if res != nil {
+			res = PartialUsage(req, res)
 		}
-		*opts = append(*opts, perplexity.WithJSONSchemaResponseFormat(schema))
+	} else if err != nil {
+		res = nil
 	}
-	if c.options.ResponseFormatRegex != "" {
-		*opts = append(*opts, perplexity.WithRegexResponseFormat(c.options.ResponseFormatRegex))
+	c.hooks.After(res, err)
+	if err != nil {
+		logger.Debug("chat request failed", "model", req.Model, "duration", time.Since(start), "error", err)
+		return res, fmt.Errorf("error sending completion request: %w", err)
 	}
-	return nil
+	logger.Debug("chat request completed",
+		"model", req.Model,
+		"duration", time.Since(start),
+		"prompt_tokens", res.Usage.PromptTokens,
+		"completion_tokens", res.Usage.CompletionTokens)
+	return res, nil
 }
 
-// addModeOptions adds search mode and context size options.
-// Validates against API-supported mode and context size values.
-func (c *Chat) addModeOptions(opts *[]perplexity.CompletionRequestOption) error {
-	if c.options.SearchMode != "" {
-		// Search mode validation: API supports two distinct search behaviors
-		// "web" = general internet search (default, broader results)
-		// "academic" = scholarly sources only (research papers, journals)
-		if !config.ValidSearchModes[c.options.SearchMode] {
-			return fmt.Errorf("%w: '%s'. Must be one of: web, academic", clerrors.ErrInvalidSearchMode, c.options.SearchMode)
+// streamed returns the callback passing each new piece of a streamed answer
+// to the OnStream callback, or nil without one. The answer of a continuation
+// request is streamed joined to prefix, the answer it continues.
+func (c *Chat) streamed(prefix string) func(*perplexity.CompletionResponse) {
+	if c.options.OnStream == nil {
+		return nil
+	}
+	answer := prefix
+	return func(event *perplexity.CompletionResponse) {
+		var content string
+		if len(event.Choices) > 0 {
+			content = JoinContinuation(prefix, event.Choices[0].Message.Content)
+		}
+		if strings.HasPrefix(answer, content) {
+			return // nothing new
 		}
-		*opts = append(*opts, perplexity.WithSearchMode(c.options.SearchMode))
-	}
-	if c.options.SearchContextSize != "" {
-		// Context size validation: controls how much search context to include in the model prompt
-		// "low" = minimal context (faster, cheaper)
-		// "medium" = balanced context (default)
-		// "high" = maximum context (slower, more comprehensive)
-		if !config.ValidContextSizes[c.options.SearchContextSize] {
-			return fmt.Errorf("%w: '%s'. Must be one of: low, medium, high",
-				clerrors.ErrInvalidSearchContextSize, c.options.SearchContextSize)
+		delta, ok := render.Delta(answer, content)
+		if !ok {
+			delta = content
 		}
-		*opts = append(*opts, perplexity.WithSearchContextSize(c.options.SearchContextSize))
+		answer = content
+		c.options.OnStream(delta, content)
 	}
-	return nil
 }
 
-// addDateOptions adds date filter options for search results.
-// Parses and validates dates in MM/DD/YYYY format (required by Perplexity API).
-func (c *Chat) addDateOptions(opts *[]perplexity.CompletionRequestOption) error {
-	// Date format: MM/DD/YYYY is required by Perplexity API
-	// Using Go reference time "01/02/2006" (January 2, 2006 at 3:04:05 PM MST)
-	// Examples: "12/31/2024", "01/01/2025"
-	if c.options.SearchAfterDate != "" {
-		date, err := time.Parse("01/02/2006", c.options.SearchAfterDate)
-		if err != nil {
-			return fmt.Errorf("%w: '%s'. Use MM/DD/YYYY", clerrors.ErrInvalidSearchAfterDate, c.options.SearchAfterDate)
-		}
-		*opts = append(*opts, perplexity.WithPublishedAfter(date))
+// Request builds and validates the request for the current conversation
+// without sending it, with pplx.BuildRequest, and records how many messages
+// were trimmed to fit the context window.
+func (c *Chat) Request() (*perplexity.CompletionRequest, error) {
+	messages, trimmed := c.contextMessages()
+	if trimmed > 0 {
+		logger.Debug("trimmed chat history to fit context window",
+			"dropped_messages", trimmed, "max_context_tokens", c.options.MaxContextTokens)
 	}
-	if c.options.SearchBeforeDate != "" {
-		date, err := time.Parse("01/02/2006", c.options.SearchBeforeDate)
-		if err != nil {
-			return fmt.Errorf("%w: '%s'. Use MM/DD/YYYY", clerrors.ErrInvalidSearchBeforeDate, c.options.SearchBeforeDate)
-		}
-		*opts = append(*opts, perplexity.WithPublishedBefore(date))
+	c.trimmed = trimmed
+
+	warns := warnings.New()
+	req, err := pplx.BuildRequest(c.queryRequest(messages), warns)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // a ValidationError wrapping the clerrors sentinel of the check
 	}
-	if c.options.LastUpdatedAfter != "" {
-		date, err := time.Parse("01/02/2006", c.options.LastUpdatedAfter)
-		if err != nil {
-			return fmt.Errorf("%w: '%s'. Use MM/DD/YYYY", clerrors.ErrInvalidLastUpdatedAfter, c.options.LastUpdatedAfter)
+	for _, w := range warns.All() {
+		if !c.warned[w] {
+			if c.warned == nil {
+				c.warned = make(map[warnings.Warning]bool)
+			}
+			c.warned[w] = true
+			c.options.Warnings.Add(w.Code, w.Message, w.Hint)
 		}
-		*opts = append(*opts, perplexity.WithLastUpdatedAfterFilter(date))
 	}
-	if c.options.LastUpdatedBefore != "" {
-		date, err := time.Parse("01/02/2006", c.options.LastUpdatedBefore)
-		if err != nil {
-			return fmt.Errorf("%w: '%s'. Use MM/DD/YYYY", clerrors.ErrInvalidLastUpdatedBefore, c.options.LastUpdatedBefore)
-		}
-		*opts = append(*opts, perplexity.WithLastUpdatedBeforeFilter(date))
-	}
-	return nil
+	return req, nil
 }
 
-// addResearchOptions adds deep research model options.
-// Validates reasoning effort level and warns if used with incompatible model.
-func (c *Chat) addResearchOptions(opts *[]perplexity.CompletionRequestOption) error {
-	if c.options.ReasoningEffort != "" {
-		// Reasoning effort validation: controls depth of analysis for deep-research models
-		// "low" = faster, less thorough (suitable for simple queries)
-		// "medium" = balanced analysis (default)
-		// "high" = maximum depth (slower, comprehensive for complex research tasks)
-		if !config.ValidReasoningEfforts[c.options.ReasoningEffort] {
-			return fmt.Errorf("%w: '%s'. Must be one of: low, medium, high",
-				clerrors.ErrInvalidReasoningEffort, c.options.ReasoningEffort)
-		}
-		// Warning instead of error: allows user to set reasoning-effort in config/flags
-		// before switching models. Feature degrades gracefully (parameter ignored by API)
-		// rather than failing hard. This improves UX when experimenting with different models.
-		if !strings.Contains(c.options.Model, "deep-research") {
-			logger.Warn("reasoning-effort only supported by sonar-deep-research model",
-				"current_model", c.options.Model)
-		}
-		*opts = append(*opts, perplexity.WithReasoningEffort(c.options.ReasoningEffort))
+// queryRequest returns the request of the session options for messages.
+func (c *Chat) queryRequest(messages []perplexity.Message) pplx.QueryRequest {
+	o := c.options
+	return pplx.QueryRequest{
+		Conversation:             messages,
+		Model:                    o.Model,
+		FrequencyPenalty:         o.FrequencyPenalty,
+		MaxTokens:                o.MaxTokens,
+		PresencePenalty:          o.PresencePenalty,
+		Temperature:              o.Temperature,
+		TopK:                     o.TopK,
+		TopP:                     o.TopP,
+		SearchDomains:            o.SearchDomains,
+		ExcludeDomains:           o.ExcludeDomains,
+		SearchRecency:            o.SearchRecency,
+		LocationLat:              o.LocationLat,
+		LocationLon:              o.LocationLon,
+		LocationCountry:          o.LocationCountry,
+		ReturnImages:             o.ReturnImages,
+		ReturnRelated:            o.ReturnRelated,
+		Stream:                   o.Stream,
+		ImageDomains:             o.ImageDomains,
+		ImageFormats:             o.ImageFormats,
+		ResponseFormatJSONSchema: o.ResponseFormatJSONSchema,
+		ResponseFormatRegex:      o.ResponseFormatRegex,
+		SearchMode:               o.SearchMode,
+		SearchContextSize:        o.SearchContextSize,
+		SearchAfterDate:          o.SearchAfterDate,
+		SearchBeforeDate:         o.SearchBeforeDate,
+		LastUpdatedAfter:         o.LastUpdatedAfter,
+		LastUpdatedBefore:        o.LastUpdatedBefore,
+		ReasoningEffort:          o.ReasoningEffort,
+		Language:                 o.Language,
+		ImageConflictPolicy:      o.ImageConflictPolicy,
+		StrictOptions:            o.StrictOptions,
 	}
-	return nil
 }
@@ -1,6 +1,7 @@
 package chat
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -8,6 +9,8 @@ import (
 
 	"github.com/sgaunet/perplexity-go/v2"
 	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/hooks"
+	"github.com/sgaunet/pplx/pkg/warnings"
 )
 
 // mockCompletionResponseJSON returns a minimal valid completion response JSON.
@@ -128,6 +131,128 @@ func TestRun_Success(t *testing.T) {
 	}
 }
 
+func TestRequest_BuildsWithoutSending(t *testing.T) {
+	opts := Options{Model: "sonar-pro", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0, SearchRecency: "week"}
+	c := NewChatWithOptions(nil, "system", opts)
+	_ = c.AddUserMessage("test question")
+
+	req, err := c.Request()
+	if err != nil {
+		t.Fatalf("Request() error: %v", err)
+	}
+	if req.Model != "sonar-pro" || len(req.Messages) != 2 || req.SearchRecencyFilter != "week" {
+		t.Errorf("Request() = %+v", req)
+	}
+
+	opts.SearchRecency = "decade"
+	c = NewChatWithOptions(nil, "", opts)
+	_ = c.AddUserMessage("test question")
+	if _, err := c.Request(); err == nil {
+		t.Error("Request() accepted an invalid recency")
+	}
+}
+
+func TestRequest_Language(t *testing.T) {
+	opts := Options{Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0, Language: "fr"}
+	c := NewChatWithOptions(nil, "", opts)
+	_ = c.AddUserMessage("test question")
+
+	req, err := c.Request()
+	if err != nil {
+		t.Fatalf("Request() error: %v", err)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Content != "Always answer in French." {
+		t.Errorf("messages = %+v, want the language instruction as system message", req.Messages)
+	}
+	if c.Messages.GetSystemMessage() != "" {
+		t.Errorf("the instruction was stored in the conversation")
+	}
+
+	// An explicit language in the system message wins.
+	if err := c.SetSystemMessage("Antworte auf Deutsch."); err != nil {
+		t.Fatal(err)
+	}
+	if req, _ = c.Request(); req.Messages[0].Content != "Antworte auf Deutsch." {
+		t.Errorf("system message = %q, want it unchanged", req.Messages[0].Content)
+	}
+
+	opts.Language = "klingon"
+	c = NewChatWithOptions(nil, "", opts)
+	_ = c.AddUserMessage("test question")
+	if _, err := c.Request(); !errors.Is(err, clerrors.ErrInvalidLanguage) {
+		t.Errorf("Request() error = %v, want ErrInvalidLanguage", err)
+	}
+}
+
+func TestRequest_ImageConflictPolicy(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantRecency string
+		wantImages  bool
+		wantErr     error
+	}{
+		{"prefer_images", "", true, nil},
+		{"prefer_recency", "week", false, nil},
+		{"error", "", false, clerrors.ErrImageRecencyConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			warns := warnings.New()
+			c := NewChatWithOptions(nil, "", Options{
+				Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+				SearchRecency: "week", ReturnImages: true, ImageConflictPolicy: tt.policy, Warnings: warns,
+			})
+			_ = c.AddUserMessage("test question")
+
+			req, err := c.Request()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Request() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if req.SearchRecencyFilter != tt.wantRecency || req.ReturnImages != tt.wantImages {
+				t.Errorf("request recency %q, images %v; want %q, %v",
+					req.SearchRecencyFilter, req.ReturnImages, tt.wantRecency, tt.wantImages)
+			}
+			if _, err := c.Request(); err != nil {
+				t.Fatalf("second Request() error = %v", err)
+			}
+			if got := warns.All(); len(got) != 1 || got[0].Code != warnings.CodeImageConflict {
+				t.Errorf("warnings = %v, want the dropped option reported once", got)
+			}
+		})
+	}
+}
+
+func TestRequest_StreamWithResponseFormat(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		warns := warnings.New()
+		c := NewChatWithOptions(nil, "", Options{
+			Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+			Stream: true, ResponseFormatJSONSchema: `{"type":"object"}`, StrictOptions: strict, Warnings: warns,
+		})
+		_ = c.AddUserMessage("test question")
+
+		req, err := c.Request()
+		if strict {
+			if !errors.Is(err, clerrors.ErrStreamResponseFormat) {
+				t.Errorf("strict: Request() error = %v, want ErrStreamResponseFormat", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Request() error = %v", err)
+		}
+		if req.Stream {
+			t.Error("streaming was not turned off for a request with a response format")
+		}
+		if got := warns.All(); len(got) != 1 || got[0].Code != warnings.CodeStreamFormat {
+			t.Errorf("warnings = %v, want turning streaming off reported", got)
+		}
+	}
+}
+
 func TestRun_APIError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
@@ -152,7 +277,14 @@ func TestRun_APIError(t *testing.T) {
 	}
 }
 
-func TestAddSearchOptions(t *testing.T) {
+// sendable returns opts with the sampling options the API requires, so that
+// the request they build passes validation.
+func sendable(opts Options) Options {
+	opts.TopP, opts.FrequencyPenalty = 0.9, 1.0
+	return opts
+}
+
+func TestRequest_SearchOptions(t *testing.T) {
 	tests := []struct {
 		name    string
 		opts    Options
@@ -175,9 +307,10 @@ func TestAddSearchOptions(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name:    "valid recency year",
+			// pplx accepts it, but the client library does not send it.
+			name:    "recency year rejected by the client",
 			opts:    Options{Model: "sonar", SearchRecency: "year"},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name:    "valid recency hour",
@@ -209,11 +342,9 @@ func TestAddSearchOptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client := perplexity.NewClient("test-key")
-			c := NewChatWithOptions(client, "", tt.opts)
-
-			var opts []perplexity.CompletionRequestOption
-			err := c.addSearchOptions(&opts)
+			c := NewChatWithOptions(perplexity.NewClient("test-key"), "", sendable(tt.opts))
+			_ = c.AddUserMessage("test")
+			_, err := c.Request()
 
 			if tt.wantErr {
 				if err == nil {
@@ -231,7 +362,7 @@ func TestAddSearchOptions(t *testing.T) {
 	}
 }
 
-func TestAddFormatOptions(t *testing.T) {
+func TestRequest_FormatOptions(t *testing.T) {
 	tests := []struct {
 		name    string
 		opts    Options
@@ -290,6 +421,15 @@ func TestAddFormatOptions(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "JSON schema with a required entry that is not a property",
+			opts: Options{
+				Model:                    "sonar",
+				ResponseFormatJSONSchema: `{"type":"object","properties":{},"required":["name"]}`,
+			},
+			wantErr: true,
+			errIs:   clerrors.ErrInvalidJSONSchema,
+		},
 		{
 			name:    "no format options",
 			opts:    Options{Model: "sonar"},
@@ -299,11 +439,9 @@ func TestAddFormatOptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client := perplexity.NewClient("test-key")
-			c := NewChatWithOptions(client, "", tt.opts)
-
-			var opts []perplexity.CompletionRequestOption
-			err := c.addFormatOptions(&opts)
+			c := NewChatWithOptions(perplexity.NewClient("test-key"), "", sendable(tt.opts))
+			_ = c.AddUserMessage("test")
+			_, err := c.Request()
 
 			if tt.wantErr {
 				if err == nil {
@@ -321,7 +459,7 @@ func TestAddFormatOptions(t *testing.T) {
 	}
 }
 
-func TestAddModeOptions(t *testing.T) {
+func TestRequest_ModeOptions(t *testing.T) {
 	tests := []struct {
 		name    string
 		opts    Options
@@ -374,11 +512,9 @@ func TestAddModeOptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client := perplexity.NewClient("test-key")
-			c := NewChatWithOptions(client, "", tt.opts)
-
-			var opts []perplexity.CompletionRequestOption
-			err := c.addModeOptions(&opts)
+			c := NewChatWithOptions(perplexity.NewClient("test-key"), "", sendable(tt.opts))
+			_ = c.AddUserMessage("test")
+			_, err := c.Request()
 
 			if tt.wantErr {
 				if err == nil {
@@ -396,7 +532,7 @@ func TestAddModeOptions(t *testing.T) {
 	}
 }
 
-func TestAddDateOptions(t *testing.T) {
+func TestRequest_DateOptions(t *testing.T) {
 	tests := []struct {
 		name    string
 		opts    Options
@@ -424,8 +560,13 @@ func TestAddDateOptions(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name:    "invalid search after date format",
+			name:    "valid search after date ISO 8601",
 			opts:    Options{Model: "sonar", SearchAfterDate: "2024-01-15"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid search after date format",
+			opts:    Options{Model: "sonar", SearchAfterDate: "2024-13-45"},
 			wantErr: true,
 			errIs:   clerrors.ErrInvalidSearchAfterDate,
 		},
@@ -461,11 +602,9 @@ func TestAddDateOptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client := perplexity.NewClient("test-key")
-			c := NewChatWithOptions(client, "", tt.opts)
-
-			var opts []perplexity.CompletionRequestOption
-			err := c.addDateOptions(&opts)
+			c := NewChatWithOptions(perplexity.NewClient("test-key"), "", sendable(tt.opts))
+			_ = c.AddUserMessage("test")
+			_, err := c.Request()
 
 			if tt.wantErr {
 				if err == nil {
@@ -483,7 +622,7 @@ func TestAddDateOptions(t *testing.T) {
 	}
 }
 
-func TestAddResearchOptions(t *testing.T) {
+func TestRequest_ResearchOptions(t *testing.T) {
 	tests := []struct {
 		name    string
 		opts    Options
@@ -512,9 +651,10 @@ func TestAddResearchOptions(t *testing.T) {
 			errIs:   clerrors.ErrInvalidReasoningEffort,
 		},
 		{
-			name:    "non-deep-research model warns but succeeds",
+			// pplx only warns, but the client library does not send it.
+			name:    "non-deep-research model rejected by the client",
 			opts:    Options{Model: "sonar", ReasoningEffort: "high"},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name:    "empty effort",
@@ -525,11 +665,9 @@ func TestAddResearchOptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client := perplexity.NewClient("test-key")
-			c := NewChatWithOptions(client, "", tt.opts)
-
-			var opts []perplexity.CompletionRequestOption
-			err := c.addResearchOptions(&opts)
+			c := NewChatWithOptions(perplexity.NewClient("test-key"), "", sendable(tt.opts))
+			_ = c.AddUserMessage("test")
+			_, err := c.Request()
 
 			if tt.wantErr {
 				if err == nil {
@@ -547,91 +685,72 @@ func TestAddResearchOptions(t *testing.T) {
 	}
 }
 
-func TestAddResponseOptions(t *testing.T) {
-	t.Run("return images true", func(t *testing.T) {
-		client := perplexity.NewClient("test-key")
-		c := NewChatWithOptions(client, "", Options{Model: "sonar", ReturnImages: true})
-		var opts []perplexity.CompletionRequestOption
-		c.addResponseOptions(&opts)
-		if len(opts) != 1 {
-			t.Errorf("expected 1 option for ReturnImages=true, got %d", len(opts))
-		}
-	})
-
-	t.Run("return related true", func(t *testing.T) {
-		client := perplexity.NewClient("test-key")
-		c := NewChatWithOptions(client, "", Options{Model: "sonar", ReturnRelated: true})
-		var opts []perplexity.CompletionRequestOption
-		c.addResponseOptions(&opts)
-		if len(opts) != 1 {
-			t.Errorf("expected 1 option for ReturnRelated=true, got %d", len(opts))
-		}
-	})
-
-	t.Run("both false", func(t *testing.T) {
-		client := perplexity.NewClient("test-key")
-		c := NewChatWithOptions(client, "", Options{Model: "sonar"})
-		var opts []perplexity.CompletionRequestOption
-		c.addResponseOptions(&opts)
-		if len(opts) != 0 {
-			t.Errorf("expected 0 options for defaults, got %d", len(opts))
-		}
-	})
-}
-
-func TestAddImageOptions(t *testing.T) {
-	t.Run("image domains", func(t *testing.T) {
-		client := perplexity.NewClient("test-key")
-		c := NewChatWithOptions(client, "", Options{
-			Model:        "sonar",
-			ImageDomains: []string{"example.com"},
+func TestRequest_ResponseOptions(t *testing.T) {
+	tests := []struct {
+		name                    string
+		opts                    Options
+		wantImages, wantRelated bool
+	}{
+		{"return images true", Options{Model: "sonar", ReturnImages: true}, true, false},
+		{"return related true", Options{Model: "sonar", ReturnRelated: true}, false, true},
+		{"both false", Options{Model: "sonar"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChatWithOptions(perplexity.NewClient("test-key"), "", sendable(tt.opts))
+			_ = c.AddUserMessage("test")
+			req, err := c.Request()
+			if err != nil {
+				t.Fatalf("Request() error = %v", err)
+			}
+			if req.ReturnImages != tt.wantImages || req.ReturnRelatedQuestions != tt.wantRelated {
+				t.Errorf("images %v, related %v; want %v, %v",
+					req.ReturnImages, req.ReturnRelatedQuestions, tt.wantImages, tt.wantRelated)
+			}
 		})
-		var opts []perplexity.CompletionRequestOption
-		c.addImageOptions(&opts)
-		if len(opts) != 1 {
-			t.Errorf("expected 1 option for ImageDomains, got %d", len(opts))
-		}
-	})
+	}
+}
 
-	t.Run("image formats", func(t *testing.T) {
-		client := perplexity.NewClient("test-key")
-		c := NewChatWithOptions(client, "", Options{
-			Model:        "sonar",
-			ImageFormats: []string{"png", "jpg"},
-		})
-		var opts []perplexity.CompletionRequestOption
-		c.addImageOptions(&opts)
-		if len(opts) != 1 {
-			t.Errorf("expected 1 option for ImageFormats, got %d", len(opts))
-		}
-	})
+func TestRequest_ImageOptions(t *testing.T) {
+	c := NewChatWithOptions(perplexity.NewClient("test-key"), "", sendable(Options{
+		Model:        "sonar",
+		ImageDomains: []string{"example.com"},
+		ImageFormats: []string{"png", "jpg"},
+	}))
+	_ = c.AddUserMessage("test")
+	req, err := c.Request()
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if len(req.ImageDomainFilter) != 1 || len(req.ImageFormatFilter) != 2 {
+		t.Errorf("image domains %v, formats %v", req.ImageDomainFilter, req.ImageFormatFilter)
+	}
 
-	t.Run("empty", func(t *testing.T) {
-		client := perplexity.NewClient("test-key")
-		c := NewChatWithOptions(client, "", Options{Model: "sonar"})
-		var opts []perplexity.CompletionRequestOption
-		c.addImageOptions(&opts)
-		if len(opts) != 0 {
-			t.Errorf("expected 0 options for empty image opts, got %d", len(opts))
-		}
-	})
+	c = NewChatWithOptions(perplexity.NewClient("test-key"), "", sendable(Options{Model: "sonar"}))
+	_ = c.AddUserMessage("test")
+	if req, err = c.Request(); err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if req.ImageDomainFilter != nil || req.ImageFormatFilter != nil {
+		t.Errorf("empty image options sent domains %v, formats %v", req.ImageDomainFilter, req.ImageFormatFilter)
+	}
 }
 
-func TestBuildRequestOptions_Defaults(t *testing.T) {
+func TestRequest_Defaults(t *testing.T) {
 	client := perplexity.NewClient("test-key")
-	c := NewChatWithOptions(client, "sys", Options{Model: "sonar"})
+	c := NewChatWithOptions(client, "sys", sendable(Options{Model: "sonar"}))
 	_ = c.AddUserMessage("test")
 
-	opts, err := c.buildRequestOptions()
+	req, err := c.Request()
 	if err != nil {
 		t.Fatalf("expected no error with defaults, got %v", err)
 	}
-	if len(opts) == 0 {
-		t.Error("expected non-empty options slice")
+	if len(req.Messages) != 2 || req.Model != "sonar" {
+		t.Errorf("request model %q with %d messages, want sonar with 2", req.Model, len(req.Messages))
 	}
 }
 
-func TestBuildRequestOptions_AllOptions(t *testing.T) {
+func TestRequest_AllOptions(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	opts := Options{
 		Model:             "sonar",
@@ -648,7 +767,7 @@ func TestBuildRequestOptions_AllOptions(t *testing.T) {
 		LocationCountry:   "FR",
 		ReturnImages:      true,
 		ReturnRelated:     true,
-		ImageDomains:      []string{"img.example.com"},
+		ImageDomains:      []string{"example.org"},
 		ImageFormats:      []string{"png"},
 		SearchMode:        "web",
 		SearchContextSize: "medium",
@@ -657,19 +776,19 @@ func TestBuildRequestOptions_AllOptions(t *testing.T) {
 		LastUpdatedAfter:  "03/01/2024",
 		LastUpdatedBefore: "06/30/2024",
 	}
-	c := NewChatWithOptions(client, "system", opts)
+	c := NewChatWithOptions(client, "system", sendable(opts))
 	_ = c.AddUserMessage("test")
 
-	result, err := c.buildRequestOptions()
+	req, err := c.Request()
 	if err != nil {
 		t.Fatalf("expected no error with all options, got %v", err)
 	}
-	if len(result) == 0 {
-		t.Error("expected non-empty options slice")
+	if req.SearchMode != "web" || len(req.SearchDomainFilter) != 1 {
+		t.Errorf("request search mode %q, domains %v", req.SearchMode, req.SearchDomainFilter)
 	}
 }
 
-func TestBuildRequestOptions_InvalidRecency(t *testing.T) {
+func TestRequest_InvalidRecency(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	c := NewChatWithOptions(client, "", Options{
 		Model:         "sonar",
@@ -677,7 +796,7 @@ func TestBuildRequestOptions_InvalidRecency(t *testing.T) {
 	})
 	_ = c.AddUserMessage("test")
 
-	_, err := c.buildRequestOptions()
+	_, err := c.Request()
 	if err == nil {
 		t.Fatal("expected error for invalid recency")
 	}
@@ -686,7 +805,7 @@ func TestBuildRequestOptions_InvalidRecency(t *testing.T) {
 	}
 }
 
-func TestBuildRequestOptions_InvalidSearchMode(t *testing.T) {
+func TestRequest_InvalidSearchMode(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	c := NewChatWithOptions(client, "", Options{
 		Model:      "sonar",
@@ -694,7 +813,7 @@ func TestBuildRequestOptions_InvalidSearchMode(t *testing.T) {
 	})
 	_ = c.AddUserMessage("test")
 
-	_, err := c.buildRequestOptions()
+	_, err := c.Request()
 	if err == nil {
 		t.Fatal("expected error for invalid search mode")
 	}
@@ -703,7 +822,7 @@ func TestBuildRequestOptions_InvalidSearchMode(t *testing.T) {
 	}
 }
 
-func TestBuildRequestOptions_InvalidDate(t *testing.T) {
+func TestRequest_InvalidDate(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	c := NewChatWithOptions(client, "", Options{
 		Model:           "sonar",
@@ -711,7 +830,7 @@ func TestBuildRequestOptions_InvalidDate(t *testing.T) {
 	})
 	_ = c.AddUserMessage("test")
 
-	_, err := c.buildRequestOptions()
+	_, err := c.Request()
 	if err == nil {
 		t.Fatal("expected error for invalid date")
 	}
@@ -720,7 +839,7 @@ func TestBuildRequestOptions_InvalidDate(t *testing.T) {
 	}
 }
 
-func TestBuildRequestOptions_ConflictingFormats(t *testing.T) {
+func TestRequest_ConflictingFormats(t *testing.T) {
 	client := perplexity.NewClient("test-key")
 	c := NewChatWithOptions(client, "", Options{
 		Model:                    "sonar",
@@ -729,7 +848,7 @@ func TestBuildRequestOptions_ConflictingFormats(t *testing.T) {
 	})
 	_ = c.AddUserMessage("test")
 
-	_, err := c.buildRequestOptions()
+	_, err := c.Request()
 	if err == nil {
 		t.Fatal("expected error for conflicting formats")
 	}
@@ -737,3 +856,201 @@ func TestBuildRequestOptions_ConflictingFormats(t *testing.T) {
 		t.Errorf("expected ErrConflictingResponseFormats, got %v", err)
 	}
 }
+
+// abortHook fails every request.
+type abortHook struct{ after bool }
+
+func (h *abortHook) BeforeRequest(*perplexity.CompletionRequest) error { return errors.New("denied") }
+
+func (h *abortHook) AfterResponse(*perplexity.CompletionResponse, error) { h.after = true }
+
+func TestRun_Hooks(t *testing.T) {
+	calls := 0
+	var system string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body perplexity.CompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		system = body.Messages[0].Content
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(mockCompletionResponseJSON()))
+	}))
+	defer srv.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	c := NewChatWithOptions(client, "system", Options{Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0})
+	_ = c.AddUserMessage("test question")
+
+	c.Use(hooks.NewPromptPrefix("Be brief."))
+	if _, err := c.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if system != "Be brief.\n\nsystem" {
+		t.Errorf("sent system message = %q", system)
+	}
+	if c.Messages.GetMessages()[0].Content != "system" {
+		t.Error("hook modified the conversation")
+	}
+
+	abort := &abortHook{}
+	c.Use(abort)
+	_, err := c.Run()
+	if !errors.Is(err, clerrors.ErrRequestAborted) {
+		t.Errorf("Run() error = %v, want ErrRequestAborted", err)
+	}
+	if calls != 1 || abort.after {
+		t.Error("aborted request was sent")
+	}
+}
+
+// sseServer serves a streaming completion whose events carry the given
+// cumulative answers, and records whether the request asked to stream.
+func sseServer(t *testing.T, answers []string, streamed *bool) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body perplexity.CompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		*streamed = body.Stream
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, answer := range answers {
+			event, _ := json.Marshal(map[string]any{
+				"id": "stream-id", "model": "sonar", "object": "chat.completion.chunk",
+				"choices": []map[string]any{{"index": 0,
+					"message": map[string]string{"role": "assistant", "content": answer},
+					"delta":   map[string]string{"role": "assistant", "content": ""}}},
+			})
+			_, _ = w.Write([]byte("data: " + string(event) + "\n\n"))
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+}
+
+func TestRun_Stream(t *testing.T) {
+	var streamed bool
+	// Overlapping cumulative chunks, a repeat, and a revision of earlier text.
+	srv := sseServer(t, []string{"Hel", "Hello", "Hello", "Hello wor", "Hello World", "Hello World!"}, &streamed)
+	defer srv.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	type call struct{ delta, cumulative string }
+	var calls []call
+	c := NewChatWithOptions(client, "", Options{
+		Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0, Stream: true,
+		OnStream: func(delta, cumulative string) { calls = append(calls, call{delta, cumulative}) },
+	})
+	_ = c.AddUserMessage("test question")
+
+	resp, err := c.Send()
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !streamed {
+		t.Error("request did not ask to stream")
+	}
+	if resp.GetLastContent() != "Hello World!" {
+		t.Errorf("response = %q, want the last cumulative content", resp.GetLastContent())
+	}
+
+	want := []call{
+		{"Hel", "Hel"},
+		{"lo", "Hello"},
+		{" wor", "Hello wor"},
+		{"Hello World", "Hello World"}, // revision: the whole answer again
+		{"!", "Hello World!"},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d = %q, want %q", i, calls[i], want[i])
+		}
+	}
+	if got := c.Messages.GetMessages(); got[len(got)-1].Content != "Hello World!" {
+		t.Errorf("history = %+v, want the streamed answer last", got)
+	}
+}
+
+func TestRun_StreamNoEvents(t *testing.T) {
+	var streamed bool
+	srv := sseServer(t, nil, &streamed)
+	defer srv.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	c := NewChatWithOptions(client, "", Options{Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0, Stream: true})
+	_ = c.AddUserMessage("test question")
+
+	if _, err := c.Run(); !errors.Is(err, clerrors.ErrNoResponse) {
+		t.Errorf("Run() error = %v, want ErrNoResponse", err)
+	}
+}
+
+func TestSend_ZeroChoices(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","model":"sonar","choices":[]}`))
+	}))
+	defer srv.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	c := NewChatWithOptions(client, "", Options{Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0})
+	_ = c.AddUserMessage("test question")
+
+	if _, err := c.Send(); !errors.Is(err, clerrors.ErrNoChoices) {
+		t.Errorf("Send() error = %v, want ErrNoChoices", err)
+	}
+	if got := c.Messages.GetMessages(); got[len(got)-1].Role != "user" {
+		t.Errorf("history = %+v, want no empty answer recorded", got)
+	}
+}
+
+func TestRun_ModelFallback(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body.Model)
+		w.Header().Set("Content-Type", "application/json")
+		if body.Model == "sonar-pro" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"Invalid model 'sonar-pro'","type":"invalid_model","code":400}}`))
+			return
+		}
+		_, _ = w.Write([]byte(mockCompletionResponseJSON()))
+	}))
+	defer srv.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	warns := warnings.New()
+	c := NewChatWithOptions(client, "", Options{
+		Model:            "sonar-pro",
+		ModelFallbacks:   []string{"sonar"},
+		MaxTokens:        100,
+		TopP:             0.9,
+		FrequencyPenalty: 1.0,
+		Warnings:         warns,
+	})
+	_ = c.AddUserMessage("test")
+
+	if _, err := c.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(sent) != 2 || sent[0] != "sonar-pro" || sent[1] != "sonar" {
+		t.Errorf("models sent = %q, want [sonar-pro sonar]", sent)
+	}
+	if all := warns.All(); len(all) != 1 || all[0].Code != warnings.CodeModelFallback {
+		t.Errorf("warnings = %+v, want one %s warning", all, warnings.CodeModelFallback)
+	}
+	if c.Model() != "sonar-pro" {
+		t.Errorf("Model() = %q, want the session model kept", c.Model())
+	}
+}
@@ -0,0 +1,198 @@
+package chat
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/models"
+)
+
+// transcriptFilePerms is the permission of files written by /save.
+const transcriptFilePerms = 0o600
+
+// Action tells the chat loop what to do after a command.
+type Action int
+
+const (
+	// ActionNone means the command was fully handled.
+	ActionNone Action = iota
+	// ActionResend means the pending user message must be sent with Send.
+	ActionResend
+	// ActionFork means the conversation was saved as a named session and the
+	// chat loop must continue in a copy made with Clone.
+	ActionFork
+)
+
+// command describes a slash command for /help.
+type command struct {
+	name  string
+	usage string
+	help  string
+}
+
+// commands lists the slash commands in /help order.
+var commands = []command{
+	{"/model", "/model [name]", "Show or switch the model for the next messages"},
+	{"/system", "/system [text]", "Replace the system message (empty removes it)"},
+	{"/clear", "/clear", "Forget the conversation history"},
+	{"/save", "/save <file> [format]", "Save the transcript as markdown, json or html (default: by file extension)"},
+	{"/tokens", "/tokens", "Show cumulative token usage and estimated cost"},
+	{"/retry", "/retry", "Resend the last user message"},
+	{"/rewind", "/rewind [n]", "Forget the last n questions and answers (default: 1)"},
+	{"/fork", "/fork <name>", "Save the conversation as session <name>.json and continue in a copy"},
+	{"/with", "/with key=value... <question>", "Ask with search options for this message only (see below)"},
+	{"/help", "/help", "List commands"},
+}
+
+// IsCommand reports whether input is a slash command rather than a prompt.
+func IsCommand(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), "/")
+}
+
+// Execute runs the slash command in input, writing feedback to out.
+// Commands are never sent to the API. Errors wrap clerrors.ErrUnknownChatCommand,
+// clerrors.ErrChatCommandUsage, clerrors.ErrNothingToRetry,
+// clerrors.ErrRewindPastStart or clerrors.ErrMaxTokensExceeded (a /model
+// whose output limit is below max_tokens), or report a failed /save or /fork;
+// none of them invalidate the session.
+func (c *Chat) Execute(input string, out io.Writer) (Action, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(input), " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "/model":
+		if arg != "" {
+			if err := models.CheckMaxTokens(arg, c.options.MaxTokens); err != nil {
+				return ActionNone, err //nolint:wrapcheck // message names the limit
+			}
+			c.SetModel(arg)
+		}
+		fmt.Fprintf(out, "Model: %s\n", c.Model())
+	case "/system":
+		if err := c.SetSystemMessage(arg); err != nil {
+			return ActionNone, err
+		}
+		if arg == "" {
+			fmt.Fprintln(out, "System message removed.")
+		} else {
+			fmt.Fprintln(out, "System message replaced.")
+		}
+	case "/clear":
+		c.Clear()
+		fmt.Fprintln(out, "Conversation cleared.")
+	case "/save":
+		if arg == "" {
+			return ActionNone, fmt.Errorf("%w: /save <file> [format]", clerrors.ErrChatCommandUsage)
+		}
+		path, format := saveArgs(arg)
+		if err := c.saveTranscript(path, format); err != nil {
+			return ActionNone, err
+		}
+		fmt.Fprintf(out, "Transcript saved to %s\n", path)
+	case "/tokens":
+		c.writeTotals(out)
+	case "/retry":
+		if err := c.PrepareRetry(); err != nil {
+			return ActionNone, err
+		}
+		return ActionResend, nil
+	case "/rewind":
+		n := 1
+		if arg != "" {
+			var err error
+			if n, err = strconv.Atoi(arg); err != nil {
+				return ActionNone, fmt.Errorf("%w: /rewind [n]", clerrors.ErrChatCommandUsage)
+			}
+		}
+		if err := c.TruncateLast(n); err != nil {
+			return ActionNone, err
+		}
+		fmt.Fprintf(out, "Rewound %d exchange(s).\n", n)
+	case "/fork":
+		if arg == "" {
+			return ActionNone, fmt.Errorf("%w: /fork <name>", clerrors.ErrChatCommandUsage)
+		}
+		path := sessionPath(arg)
+		if err := c.saveTranscript(path, FormatJSON); err != nil {
+			return ActionNone, err
+		}
+		fmt.Fprintf(out, "Session saved to %s; continuing in a copy.\n", path)
+		return ActionFork, nil
+	case "/help":
+		writeHelp(out)
+	default:
+		return ActionNone, fmt.Errorf("%w: %s (type /help for a list)", clerrors.ErrUnknownChatCommand, name)
+	}
+	return ActionNone, nil
+}
+
+// saveArgs splits the argument of /save into the file and the format. The
+// format is the last word when it names one; otherwise it follows from the
+// file extension and the whole argument is the file.
+func saveArgs(arg string) (string, Format) {
+	if i := strings.LastIndexAny(arg, " \t"); i > 0 {
+		name := strings.ToLower(arg[i+1:])
+		if slices.Contains(Formats(), name) {
+			return strings.TrimSpace(arg[:i]), Format(name)
+		}
+	}
+	return arg, FormatForPath(arg)
+}
+
+// sessionPath returns the file a session named name is saved to: name itself
+// when it has an extension, name.json otherwise. The JSON transcript can be
+// exported later with "pplx chat export".
+func sessionPath(name string) string {
+	if filepath.Ext(name) != "" {
+		return name
+	}
+	return name + ".json"
+}
+
+// saveTranscript writes the transcript to path in format.
+func (c *Chat) saveTranscript(path string, format Format) error {
+	var b strings.Builder
+	t := c.Transcript()
+	if err := t.Write(&b, format); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(b.String()), transcriptFilePerms); err != nil {
+		return fmt.Errorf("failed to save transcript: %w", err)
+	}
+	return nil
+}
+
+// writeTotals prints the session usage.
+func (c *Chat) writeTotals(out io.Writer) {
+	t := c.Totals()
+	fmt.Fprintf(out, "Requests: %d\n", t.Requests)
+	fmt.Fprintf(out, "Prompt tokens: %d\n", t.PromptTokens)
+	fmt.Fprintf(out, "Completion tokens: %d\n", t.CompletionTokens)
+	fmt.Fprintf(out, "Total tokens: %d\n", t.TotalTokens)
+	fmt.Fprintf(out, "Estimated cost: $%.4f", t.Cost)
+	if t.Unpriced > 0 {
+		fmt.Fprintf(out, " (%d request(s) with unknown pricing not included)", t.Unpriced)
+	}
+	fmt.Fprintln(out)
+}
+
+// writeHelp prints the command list.
+func writeHelp(out io.Writer) {
+	width := 0
+	for _, cmd := range commands {
+		width = max(width, len(cmd.usage))
+	}
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-*s  %s\n", width, cmd.usage, cmd.help)
+	}
+	fmt.Fprintln(out, "\n/with options:")
+	for _, o := range overrides {
+		fmt.Fprintf(out, "  %-*s  %s\n", width, o.key, o.help)
+	}
+}
@@ -0,0 +1,444 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// newCommandTestChat returns a chat backed by a server that answers every
+// request with a cited response and counts the requests it receives.
+func newCommandTestChat(t *testing.T) (*Chat, *atomic.Int32, *[]perplexity.Message) {
+	t.Helper()
+	var calls atomic.Int32
+	var lastMessages []perplexity.Message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct {
+			Messages []perplexity.Message `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		lastMessages = req.Messages
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"id": "test-id",
+			"model": "sonar",
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+			"choices": [{"index": 0, "finish_reason": "stop",
+				"message": {"role": "assistant", "content": "Answer"}}],
+			"search_results": [{"title": "Go", "url": "https://go.dev"}]
+		}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	return NewChatWithOptions(client, "be brief", Options{
+		Model:            "sonar",
+		MaxTokens:        100,
+		TopP:             0.9,
+		FrequencyPenalty: 1.0,
+		Temperature:      0.7,
+	}), &calls, &lastMessages
+}
+
+func ask(t *testing.T, c *Chat, prompt string) {
+	t.Helper()
+	if err := c.AddUserMessage(prompt); err != nil {
+		t.Fatalf("AddUserMessage() error: %v", err)
+	}
+	if _, err := c.Send(); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+}
+
+func TestIsCommand(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"/help", true},
+		{"  /model sonar-pro", true},
+		{"what is /dev/null?", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsCommand(tt.input); got != tt.want {
+			t.Errorf("IsCommand(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestExecute_CommandsAreNotSent(t *testing.T) {
+	c, calls, _ := newCommandTestChat(t)
+	var out bytes.Buffer
+
+	for _, input := range []string{"/help", "/model sonar-pro", "/system new rules", "/tokens", "/clear"} {
+		action, err := c.Execute(input, &out)
+		if err != nil {
+			t.Fatalf("Execute(%q) error: %v", input, err)
+		}
+		if action != ActionNone {
+			t.Errorf("Execute(%q) action = %v, want ActionNone", input, action)
+		}
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("commands made %d API requests, want 0", n)
+	}
+	if c.Model() != "sonar-pro" {
+		t.Errorf("Model() = %q, want sonar-pro", c.Model())
+	}
+	if got := c.Messages.GetSystemMessage(); got != "new rules" {
+		t.Errorf("system message = %q, want %q", got, "new rules")
+	}
+}
+
+func TestExecute_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr error
+	}{
+		{"/nope", clerrors.ErrUnknownChatCommand},
+		{"/save", clerrors.ErrChatCommandUsage},
+		{"/retry", clerrors.ErrNothingToRetry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			c, _, _ := newCommandTestChat(t)
+			_, err := c.Execute(tt.input, &bytes.Buffer{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Execute(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecute_ModelChecksMaxTokens(t *testing.T) {
+	c, _, _ := newCommandTestChat(t)
+	c.options.MaxTokens = 32_000
+
+	if _, err := c.Execute("/model sonar-pro", &bytes.Buffer{}); !errors.Is(err, clerrors.ErrMaxTokensExceeded) {
+		t.Errorf("Execute(/model sonar-pro) error = %v, want ErrMaxTokensExceeded", err)
+	}
+	if c.Model() != "sonar" {
+		t.Errorf("Model() = %q after a rejected switch, want sonar", c.Model())
+	}
+	if _, err := c.Execute("/model sonar-reasoning-pro", &bytes.Buffer{}); err != nil {
+		t.Errorf("Execute(/model sonar-reasoning-pro) error: %v", err)
+	}
+	if c.Model() != "sonar-reasoning-pro" {
+		t.Errorf("Model() = %q, want sonar-reasoning-pro", c.Model())
+	}
+}
+
+func TestExecute_SystemKeepsHistory(t *testing.T) {
+	c, _, sent := newCommandTestChat(t)
+	ask(t, c, "first")
+
+	if _, err := c.Execute("/system", &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute(/system) error: %v", err)
+	}
+	ask(t, c, "second")
+
+	var roles []string
+	for _, msg := range *sent {
+		roles = append(roles, msg.Role)
+	}
+	if got := strings.Join(roles, ","); got != "user,assistant,user" {
+		t.Errorf("sent roles = %s, want user,assistant,user", got)
+	}
+}
+
+func TestExecute_Clear(t *testing.T) {
+	c, _, sent := newCommandTestChat(t)
+	ask(t, c, "first")
+
+	if _, err := c.Execute("/clear", &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute(/clear) error: %v", err)
+	}
+	ask(t, c, "second")
+
+	if len(*sent) != 2 || (*sent)[0].Role != "system" || (*sent)[1].Content != "second" {
+		t.Errorf("after /clear sent %+v, want system message and second prompt only", *sent)
+	}
+	if c.Totals().Requests != 2 {
+		t.Errorf("Totals().Requests = %d, want 2 (clear keeps usage)", c.Totals().Requests)
+	}
+}
+
+func TestExecute_Retry(t *testing.T) {
+	c, calls, sent := newCommandTestChat(t)
+	ask(t, c, "question")
+
+	action, err := c.Execute("/retry", &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Execute(/retry) error: %v", err)
+	}
+	if action != ActionResend {
+		t.Fatalf("action = %v, want ActionResend", action)
+	}
+	if _, err := c.Send(); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("API requests = %d, want 2", n)
+	}
+	last := (*sent)[len(*sent)-1]
+	if last.Role != "user" || last.Content != "question" {
+		t.Errorf("retry sent %+v, want the last user message", last)
+	}
+	if got := len(c.history()); got != 2 {
+		t.Errorf("history has %d messages after retry, want 2", got)
+	}
+}
+
+func TestExecute_Tokens(t *testing.T) {
+	c, _, _ := newCommandTestChat(t)
+	ask(t, c, "one")
+	ask(t, c, "two")
+
+	var out bytes.Buffer
+	if _, err := c.Execute("/tokens", &out); err != nil {
+		t.Fatalf("Execute(/tokens) error: %v", err)
+	}
+	for _, want := range []string{"Requests: 2", "Prompt tokens: 20", "Completion tokens: 10", "Total tokens: 30"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("/tokens output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestExecute_SaveIncludesCitations(t *testing.T) {
+	c, _, _ := newCommandTestChat(t)
+	c.started = time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	c.now = func() time.Time { return c.started.Add(time.Minute) }
+	ask(t, c, "What is Go?")
+
+	path := filepath.Join(t.TempDir(), "chat.md")
+	if _, err := c.Execute("/save "+path, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute(/save) error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	want := `# Chat transcript
+
+- Model: sonar
+- Started: 2026-01-02T03:04:00Z
+
+## System
+
+be brief
+
+## User
+
+_2026-01-02T03:05:00Z_
+
+What is Go?
+
+## Assistant (sonar)
+
+_2026-01-02T03:05:00Z_
+
+Answer
+
+[^1]: Go - https://go.dev
+`
+	if string(data) != want {
+		t.Errorf("transcript =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestExecute_SaveFormats(t *testing.T) {
+	c, _, _ := newCommandTestChat(t)
+	ask(t, c, "What is Go?")
+	dir := t.TempDir()
+
+	tests := []struct {
+		arg  string
+		file string
+		want string
+	}{
+		{"session.json", "session.json", `"system_prompt": "be brief"`},
+		{"session.html", "session.html", "<!DOCTYPE html>"},
+		{"session.txt html", "session.txt", `<a href="https://go.dev">Go</a>`},
+	}
+	for _, tt := range tests {
+		if _, err := c.Execute("/save "+filepath.Join(dir, tt.arg), &bytes.Buffer{}); err != nil {
+			t.Fatalf("Execute(/save %s) error: %v", tt.arg, err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error: %v", tt.file, err)
+		}
+		if !strings.Contains(string(data), tt.want) {
+			t.Errorf("/save %s: output missing %q:\n%s", tt.arg, tt.want, data)
+		}
+	}
+}
+
+func TestExecute_Rewind(t *testing.T) {
+	c, _, sent := newCommandTestChat(t)
+	ask(t, c, "one")
+	ask(t, c, "two")
+	ask(t, c, "three")
+
+	if _, err := c.Execute("/rewind 2", &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute(/rewind 2) error: %v", err)
+	}
+	if got := len(c.history()); got != 2 {
+		t.Fatalf("history has %d messages after rewind, want 2", got)
+	}
+	if got := c.Messages.GetSystemMessage(); got != "be brief" {
+		t.Errorf("system message = %q, want it kept", got)
+	}
+	if len(c.replies) != 1 || len(c.times) != 2 {
+		t.Errorf("replies = %d, times = %d, want 1 and 2", len(c.replies), len(c.times))
+	}
+
+	ask(t, c, "four")
+	var contents []string
+	for _, msg := range *sent {
+		contents = append(contents, msg.Content)
+	}
+	if got := strings.Join(contents, ","); got != "be brief,one,Answer,four" {
+		t.Errorf("sent %s, want be brief,one,Answer,four", got)
+	}
+}
+
+func TestExecute_RewindPendingQuestion(t *testing.T) {
+	c, _, _ := newCommandTestChat(t)
+	ask(t, c, "one")
+	if err := c.AddUserMessage("unanswered"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Execute("/rewind", &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute(/rewind) error: %v", err)
+	}
+	if h := c.history(); len(h) != 2 || h[1].Role != "assistant" {
+		t.Errorf("history = %+v, want the first exchange only", h)
+	}
+}
+
+func TestExecute_RewindErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		asked   int
+		wantErr error
+	}{
+		{"/rewind", 0, clerrors.ErrRewindPastStart},
+		{"/rewind 3", 2, clerrors.ErrRewindPastStart},
+		{"/rewind 0", 1, clerrors.ErrChatCommandUsage},
+		{"/rewind -1", 1, clerrors.ErrChatCommandUsage},
+		{"/rewind two", 1, clerrors.ErrChatCommandUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			c, _, _ := newCommandTestChat(t)
+			for range tt.asked {
+				ask(t, c, "question")
+			}
+			_, err := c.Execute(tt.input, &bytes.Buffer{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Execute(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+			if got := len(c.history()); got != 2*tt.asked {
+				t.Errorf("history has %d messages, want %d (unchanged)", got, 2*tt.asked)
+			}
+		})
+	}
+}
+
+func TestExecute_Fork(t *testing.T) {
+	c, _, _ := newCommandTestChat(t)
+	ask(t, c, "What is Go?")
+	path := filepath.Join(t.TempDir(), "branch")
+
+	action, err := c.Execute("/fork "+path, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Execute(/fork) error: %v", err)
+	}
+	if action != ActionFork {
+		t.Fatalf("action = %v, want ActionFork", action)
+	}
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	transcript, err := ReadTranscript(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadTranscript() error: %v", err)
+	}
+	if len(transcript.Messages) != 2 {
+		t.Errorf("saved session has %d messages, want 2", len(transcript.Messages))
+	}
+
+	// The copy continues independently of the saved conversation.
+	fork, err := c.Clone()
+	if err != nil {
+		t.Fatalf("Clone() error: %v", err)
+	}
+	ask(t, fork, "What is Rust?")
+	if got := len(c.history()); got != 2 {
+		t.Errorf("original history has %d messages after the fork answered, want 2", got)
+	}
+	if got := len(fork.history()); got != 4 {
+		t.Errorf("fork history has %d messages, want 4", got)
+	}
+	if fork.Messages.GetSystemMessage() != "be brief" {
+		t.Errorf("fork lost the system message")
+	}
+}
+
+func TestExecute_ForkEmpty(t *testing.T) {
+	c, _, _ := newCommandTestChat(t)
+	path := filepath.Join(t.TempDir(), "empty.json")
+
+	if _, err := c.Execute("/fork "+path, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute(/fork) error: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer f.Close()
+	transcript, err := ReadTranscript(f)
+	if err != nil {
+		t.Fatalf("ReadTranscript() error: %v", err)
+	}
+	if len(transcript.Messages) != 0 || transcript.SystemPrompt != "be brief" {
+		t.Errorf("saved session = %+v, want the system prompt and no messages", transcript)
+	}
+
+	fork, err := c.Clone()
+	if err != nil {
+		t.Fatalf("Clone() error: %v", err)
+	}
+	if len(fork.history()) != 0 {
+		t.Errorf("fork of an empty conversation has %d messages", len(fork.history()))
+	}
+	ask(t, fork, "first")
+	if len(c.history()) != 0 {
+		t.Errorf("original gained messages from its fork")
+	}
+
+	if _, err := c.Execute("/fork", &bytes.Buffer{}); !errors.Is(err, clerrors.ErrChatCommandUsage) {
+		t.Errorf("Execute(/fork) without a name error = %v, want ErrChatCommandUsage", err)
+	}
+}
@@ -0,0 +1,140 @@
+package chat
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+const (
+	// charsPerToken approximates how many characters one token covers in
+	// English text. Estimates only need to be close enough to stay below the
+	// context window, so no model-specific tokenizer is used.
+	charsPerToken = 4
+
+	// messageOverheadTokens approximates the tokens each message costs for its
+	// role and delimiters.
+	messageOverheadTokens = 4
+
+	// summaryShare is the fraction of the context budget a conversation
+	// summary may use in SummarizeOnTrim mode (1/summaryShare).
+	summaryShare = 4
+
+	// summaryRequest is the user message introducing a conversation summary.
+	// The API requires the first message after the system message to be a
+	// user message, so the summary is sent as the answer to it.
+	summaryRequest = "Summarize our conversation so far."
+
+	// summaryHeading starts a conversation summary message.
+	summaryHeading = "Conversation summary:\n"
+
+	// ellipsis marks truncated summary text.
+	ellipsis = "…"
+)
+
+// EstimateTokens returns an approximate token count for text.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// PartialUsage returns res with estimated token usage when the API reported
+// none, as for a stream interrupted before its final event: the prompt is
+// estimated from the messages of req and the completion from the answer
+// received. res is returned unchanged when it has usage or no content.
+func PartialUsage(req *perplexity.CompletionRequest, res *perplexity.CompletionResponse) *perplexity.CompletionResponse {
+	if res == nil || res.Usage.TotalTokens > 0 || len(res.Choices) == 0 {
+		return res
+	}
+	partial := *res
+	partial.Usage.PromptTokens = EstimateMessages(req.Messages)
+	partial.Usage.CompletionTokens = EstimateTokens(res.Choices[0].Message.Content)
+	partial.Usage.TotalTokens = partial.Usage.PromptTokens + partial.Usage.CompletionTokens
+	return &partial
+}
+
+// EstimateMessages returns the approximate prompt tokens of msgs, including
+// the per-message overhead.
+func EstimateMessages(msgs []perplexity.Message) int {
+	total := 0
+	for _, msg := range msgs {
+		total += EstimateTokens(msg.Content) + messageOverheadTokens
+	}
+	return total
+}
+
+// fitContext returns the messages to send so that the estimated prompt fits
+// in maxTokens, and how many history messages were left out. The system
+// message and the pending user message are always kept; the oldest
+// user/assistant pairs are dropped first. With summarize, the dropped turns
+// are replaced by a locally built summary. A maxTokens of zero disables
+// trimming.
+func fitContext(
+	system []perplexity.Message, history []perplexity.Message, maxTokens int, summarize bool,
+) ([]perplexity.Message, int) {
+	if maxTokens <= 0 || EstimateMessages(system)+EstimateMessages(history) <= maxTokens {
+		return append(system, history...), 0
+	}
+
+	budget := maxTokens - EstimateMessages(system)
+	summaryTokens := 0
+	if summarize {
+		summaryTokens = max(budget/summaryShare, 0)
+	}
+
+	drop := 0
+	for drop+2 < len(history) {
+		reserve := 0
+		if drop > 0 {
+			reserve = summaryTokens
+		}
+		if EstimateMessages(history[drop:])+reserve <= budget {
+			break
+		}
+		drop += 2 // one user/assistant pair
+	}
+	if drop == 0 {
+		return append(system, history...), 0
+	}
+
+	msgs := append([]perplexity.Message{}, system...)
+	if summarize {
+		msgs = append(msgs,
+			perplexity.Message{Role: "user", Content: summaryRequest},
+			perplexity.Message{Role: "assistant", Content: summarizeTurns(history[:drop],
+				summaryTokens-EstimateTokens(summaryRequest)-2*messageOverheadTokens)},
+		)
+	}
+	return append(msgs, history[drop:]...), drop
+}
+
+// summarizeTurns concatenates msgs into a summary whose text is at most
+// maxTokens estimated tokens, truncating each turn to an equal share.
+func summarizeTurns(msgs []perplexity.Message, maxTokens int) string {
+	maxChars := max(maxTokens, 0) * charsPerToken
+	perTurn := max((maxChars-utf8.RuneCountInString(summaryHeading))/max(len(msgs), 1)-1, 0)
+
+	var b strings.Builder
+	b.WriteString(summaryHeading)
+	for _, msg := range msgs {
+		speaker := "User: "
+		if msg.Role == "assistant" {
+			speaker = "Assistant: "
+		}
+		line := speaker + strings.Join(strings.Fields(msg.Content), " ")
+		b.WriteString(truncate(line, perTurn) + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	return string(runes[:n-1]) + ellipsis
+}
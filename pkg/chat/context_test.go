@@ -0,0 +1,178 @@
+package chat
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+// turns builds a history of n user/assistant pairs followed by a pending
+// user message, each message being size characters long.
+func turns(n, size int) []perplexity.Message {
+	var msgs []perplexity.Message
+	for i := range n {
+		msgs = append(msgs,
+			perplexity.Message{Role: "user", Content: strings.Repeat(string(rune('a'+i)), size)},
+			perplexity.Message{Role: "assistant", Content: strings.Repeat(string(rune('A'+i)), size)},
+		)
+	}
+	return append(msgs, perplexity.Message{Role: "user", Content: strings.Repeat("?", size)})
+}
+
+func TestEstimateTokens(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"héllo wörld", 3}, // counted in runes, not bytes
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFitContext(t *testing.T) {
+	t.Parallel()
+
+	system := []perplexity.Message{{Role: "system", Content: strings.Repeat("s", 40)}} // 14 tokens
+	// Each 40-character message is 10 tokens plus 4 of overhead.
+
+	tests := []struct {
+		name        string
+		system      []perplexity.Message
+		history     []perplexity.Message
+		maxTokens   int
+		summarize   bool
+		wantDropped int
+		wantLen     int
+	}{
+		{name: "unlimited", system: system, history: turns(3, 40), maxTokens: 0, wantLen: 8},
+		{name: "fits", system: system, history: turns(3, 40), maxTokens: 1000, wantLen: 8},
+		{name: "drops oldest pair", system: system, history: turns(3, 40), maxTokens: 14 + 5*14, wantDropped: 2, wantLen: 6},
+		{name: "drops two pairs", system: system, history: turns(3, 40), maxTokens: 14 + 4*14, wantDropped: 4, wantLen: 4},
+		{name: "keeps pending message", system: system, history: turns(3, 40), maxTokens: 1, wantDropped: 6, wantLen: 2},
+		{name: "no system message", history: turns(2, 40), maxTokens: 3 * 14, wantDropped: 2, wantLen: 3},
+		{name: "only pending message", system: system, history: turns(0, 400), maxTokens: 10, wantLen: 2},
+		{name: "summary replaces dropped turns", system: system, history: turns(3, 40), maxTokens: 110,
+			summarize: true, wantDropped: 2, wantLen: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			msgs, dropped := fitContext(tt.system, tt.history, tt.maxTokens, tt.summarize)
+			if dropped != tt.wantDropped || len(msgs) != tt.wantLen {
+				t.Fatalf("fitContext() dropped %d and kept %d messages, want %d and %d",
+					dropped, len(msgs), tt.wantDropped, tt.wantLen)
+			}
+
+			if len(tt.system) > 0 && msgs[0] != tt.system[0] {
+				t.Errorf("system message not kept first: %+v", msgs[0])
+			}
+			if last := msgs[len(msgs)-1]; last != tt.history[len(tt.history)-1] {
+				t.Errorf("pending message not kept last: %+v", last)
+			}
+			// The API requires alternating roles starting with a user message.
+			want := "user"
+			for _, msg := range msgs[len(tt.system):] {
+				if msg.Role != want {
+					t.Fatalf("roles do not alternate: %+v", msgs)
+				}
+				want = map[string]string{"user": "assistant", "assistant": "user"}[want]
+			}
+		})
+	}
+}
+
+func TestFitContext_Summary(t *testing.T) {
+	t.Parallel()
+
+	history := turns(3, 400) // 104 tokens per message
+	maxTokens := 700
+	msgs, dropped := fitContext(nil, history, maxTokens, true)
+	if dropped != 2 {
+		t.Fatalf("dropped = %d, want 2", dropped)
+	}
+
+	summary := msgs[1].Content
+	if msgs[0].Content != summaryRequest || msgs[1].Role != "assistant" ||
+		!strings.HasPrefix(summary, summaryHeading) {
+		t.Fatalf("unexpected summary messages: %+v", msgs[:2])
+	}
+	if !strings.Contains(summary, "User: aaa") || !strings.Contains(summary, "Assistant: AAA") {
+		t.Errorf("summary should quote the dropped turns:\n%s", summary)
+	}
+	if strings.Contains(summary, "bbb") {
+		t.Errorf("summary should only cover dropped turns:\n%s", summary)
+	}
+	if got := EstimateMessages(msgs); got > maxTokens {
+		t.Errorf("estimated prompt = %d tokens, want at most %d", got, maxTokens)
+	}
+}
+
+func TestSummarizeTurns_Truncates(t *testing.T) {
+	t.Parallel()
+
+	got := summarizeTurns(turns(2, 400)[:4], 40)
+	if EstimateTokens(got) > 40 {
+		t.Errorf("summary is %d tokens, want at most 40:\n%s", EstimateTokens(got), got)
+	}
+	if strings.Count(got, ellipsis) != 4 {
+		t.Errorf("every turn should be truncated:\n%s", got)
+	}
+}
+
+func TestRun_TrimsContext(t *testing.T) {
+	var sent struct {
+		Messages []perplexity.Message `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &sent)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(mockCompletionResponseJSON()))
+	}))
+	defer srv.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	c := NewChatWithOptions(client, "system", Options{
+		Model:            "sonar",
+		MaxTokens:        100,
+		TopP:             0.9,
+		FrequencyPenalty: 1.0,
+		MaxContextTokens: 40,
+	})
+	for _, msg := range turns(3, 40) {
+		if msg.Role == "user" {
+			_ = c.AddUserMessage(msg.Content)
+		} else {
+			_ = c.AddAgentMessage(msg.Content)
+		}
+	}
+
+	if _, err := c.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if c.Trimmed() == 0 || len(sent.Messages) != len(c.Messages.GetMessages())-c.Trimmed() {
+		t.Errorf("sent %d messages with %d trimmed, conversation has %d",
+			len(sent.Messages), c.Trimmed(), len(c.Messages.GetMessages()))
+	}
+	if len(c.history()) != 7 {
+		t.Errorf("conversation should keep every message, has %d", len(c.history()))
+	}
+}
@@ -0,0 +1,193 @@
+package chat
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/logger"
+)
+
+const (
+	// ContinuePrompt is the user message that asks for the rest of an answer
+	// cut off at max_tokens.
+	ContinuePrompt = "continue"
+	// MaxAutoContinue bounds Options.AutoContinue, since every continuation
+	// is billed as a request of its own.
+	MaxAutoContinue = 10
+	// finishReasonLength is the finish reason of an answer cut off at
+	// max_tokens.
+	finishReasonLength = "length"
+	// minSeamOverlap is the shortest text repeated at the start of a
+	// continuation that is taken as a repeat of the end of the answer rather
+	// than a coincidence.
+	minSeamOverlap = 12
+	// maxSeamOverlap bounds the search for a repeated text at the seam.
+	maxSeamOverlap = 1000
+)
+
+// SendFunc sends a continuation request. answer is the answer so far, which
+// the continuation request ends with; streaming senders join what arrives
+// to it with JoinContinuation.
+type SendFunc func(req *perplexity.CompletionRequest, answer string) (*perplexity.CompletionResponse, error)
+
+// Truncated reports whether response holds a single answer that stopped at
+// max_tokens. Responses with several candidate answers are never continued.
+func Truncated(response *perplexity.CompletionResponse) bool {
+	return response != nil && len(response.Choices) == 1 && response.Choices[0].FinishReason == finishReasonLength
+}
+
+// Continue sends up to n continuation requests while the answer of response
+// to req is cut off at max_tokens, and returns response with the
+// continuations joined to its answer and their usage added to its own. The
+// sources, images, and related questions are those of the first response,
+// which the answer's citation markers refer to.
+//
+// When a continuation fails, the answer received so far is returned with the
+// error. An answer still cut off after n continuations is returned as is;
+// Truncated reports it.
+func Continue(req *perplexity.CompletionRequest, response *perplexity.CompletionResponse, n int,
+	send SendFunc,
+) (*perplexity.CompletionResponse, error) {
+	merged := response
+	for i := 0; i < n && Truncated(merged); i++ {
+		answer := merged.Choices[0].Message.Content
+		next, err := send(ContinuationRequest(req, answer), answer)
+		if err != nil {
+			return merged, err
+		}
+		merged = mergeContinuation(merged, next)
+		logger.Debug("continued truncated answer", "continuation", i+1, "finish_reason", merged.Choices[0].FinishReason)
+	}
+	return merged, nil
+}
+
+// ContinuationRequest returns a copy of req that asks for the rest of
+// answer: the conversation of req, answer as an assistant message, and
+// ContinuePrompt.
+func ContinuationRequest(req *perplexity.CompletionRequest, answer string) *perplexity.CompletionRequest {
+	next := *req
+	if len(req.MultimodalMessages) > 0 {
+		next.MultimodalMessages = append(slices.Clone(req.MultimodalMessages),
+			perplexity.MultimodalMessage{
+				Role: "assistant", Content: []perplexity.Content{perplexity.NewTextContent(answer)},
+			},
+			perplexity.MultimodalMessage{
+				Role: "user", Content: []perplexity.Content{perplexity.NewTextContent(ContinuePrompt)},
+			})
+		return &next
+	}
+	next.Messages = append(slices.Clone(req.Messages),
+		perplexity.Message{Role: "assistant", Content: answer},
+		perplexity.Message{Role: "user", Content: ContinuePrompt})
+	return &next
+}
+
+// mergeContinuation returns a copy of response with the answer of next
+// joined to its answer, the finish reason of next, and the usage of both.
+func mergeContinuation(response, next *perplexity.CompletionResponse) *perplexity.CompletionResponse {
+	merged := *response
+	merged.Choices = slices.Clone(response.Choices)
+	if len(next.Choices) > 0 {
+		merged.Choices[0].Message.Content = JoinContinuation(merged.Choices[0].Message.Content,
+			next.Choices[0].Message.Content)
+		merged.Choices[0].FinishReason = next.Choices[0].FinishReason
+	} else {
+		merged.Choices[0].FinishReason = ""
+	}
+	merged.Usage = addUsage(response.Usage, next.Usage)
+	return &merged
+}
+
+// addUsage returns the sum of two usages. A cost is only summed when both
+// usages report it.
+func addUsage(a, b perplexity.Usage) perplexity.Usage {
+	sum := perplexity.Usage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+	}
+	if a.Cost != nil && b.Cost != nil {
+		sum.Cost = &perplexity.Cost{
+			InputTokensCost:  addCost(a.Cost.InputTokensCost, b.Cost.InputTokensCost),
+			OutputTokensCost: addCost(a.Cost.OutputTokensCost, b.Cost.OutputTokensCost),
+			RequestCost:      addCost(a.Cost.RequestCost, b.Cost.RequestCost),
+			TotalCost:        addCost(a.Cost.TotalCost, b.Cost.TotalCost),
+		}
+	}
+	return sum
+}
+
+func addCost(a, b *float64) *float64 {
+	if a == nil || b == nil {
+		return nil
+	}
+	sum := *a + *b
+	return &sum
+}
+
+// JoinContinuation joins next, the answer to a continuation request, to
+// prev, the answer it continues, without repeating text at the seam. A
+// continuation may repeat the end of prev, or start the sentence prev was
+// cut off in over again; the repeated text is kept once.
+//
+// next may be a partial, streamed answer: while it could still be the start
+// of the sentence prev was cut off in, prev is returned unchanged.
+func JoinContinuation(prev, next string) string {
+	if next == "" {
+		return prev
+	}
+	if n := seamOverlap(prev, next); n > 0 {
+		return prev + next[n:]
+	}
+	start := lastSentenceStart(prev)
+	fragment := strings.TrimLeft(prev[start:], " ")
+	if fragment != "" {
+		trimmed := strings.TrimLeft(next, " ")
+		if strings.HasPrefix(trimmed, fragment) {
+			return prev[:len(prev)-len(fragment)] + trimmed
+		}
+		if strings.HasPrefix(fragment, trimmed) {
+			return prev
+		}
+	}
+	if startsNewSentence(prev, next) {
+		return prev + " " + next
+	}
+	return prev + next
+}
+
+// startsNewSentence reports whether next starts a sentence right after the
+// end of the sentence of prev, without the space between them.
+func startsNewSentence(prev, next string) bool {
+	if prev == "" || !strings.ContainsAny(prev[len(prev)-1:], ".!?") {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(next)
+	return unicode.IsUpper(r)
+}
+
+// seamOverlap returns the length of the longest start of next, of at least
+// minSeamOverlap bytes, that prev ends with, or zero.
+func seamOverlap(prev, next string) int {
+	for n := min(len(prev), len(next), maxSeamOverlap); n >= minSeamOverlap; n-- {
+		if strings.HasSuffix(prev, next[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// lastSentenceStart returns the offset in text of the sentence text ends in:
+// the text after the last sentence end or line break.
+func lastSentenceStart(text string) int {
+	start := strings.LastIndex(text, "\n") + 1
+	for _, end := range []string{". ", "! ", "? ", ": "} {
+		if i := strings.LastIndex(text, end); i >= 0 && i+len(end) > start {
+			start = i + len(end)
+		}
+	}
+	return start
+}
@@ -0,0 +1,239 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+func TestJoinContinuation(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next string
+		want       string
+	}{
+		{"plain continuation", "The answer is for", "ty-two.", "The answer is forty-two."},
+		{"space kept", "First point.", " Second point.", "First point. Second point."},
+		{"missing space added", "First point.", "Second point.", "First point. Second point."},
+		{"repeated end", "Alpha. The quick brown fox jumps", "brown fox jumps over the dog.",
+			"Alpha. The quick brown fox jumps over the dog."},
+		{"sentence restarted", "Alpha. Beta gamma del", "Beta gamma delta epsilon.",
+			"Alpha. Beta gamma delta epsilon."},
+		{"sentence restarted after space", "Alpha. Beta gam", " Beta gamma.", "Alpha. Beta gamma."},
+		{"line restarted", "# Title\n- item one\n- item t", "- item two\n- item three",
+			"# Title\n- item one\n- item two\n- item three"},
+		{"partial restart waits", "Alpha. Beta gamma del", "Beta ga", "Alpha. Beta gamma del"},
+		{"short overlap is not a repeat", "I like the", "the cat", "I like thethe cat"},
+		{"empty continuation", "Alpha", "", "Alpha"},
+		{"empty answer", "", "Alpha", "Alpha"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JoinContinuation(tt.prev, tt.next); got != tt.want {
+				t.Errorf("JoinContinuation(%q, %q) = %q, want %q", tt.prev, tt.next, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	choice := func(reason string) perplexity.Choice { return perplexity.Choice{FinishReason: reason} }
+	tests := []struct {
+		name     string
+		response *perplexity.CompletionResponse
+		want     bool
+	}{
+		{"nil", nil, false},
+		{"stop", &perplexity.CompletionResponse{Choices: []perplexity.Choice{choice("stop")}}, false},
+		{"length", &perplexity.CompletionResponse{Choices: []perplexity.Choice{choice("length")}}, true},
+		{"several choices", &perplexity.CompletionResponse{
+			Choices: []perplexity.Choice{choice("length"), choice("length")},
+		}, false},
+	}
+	for _, tt := range tests {
+		if got := Truncated(tt.response); got != tt.want {
+			t.Errorf("%s: Truncated() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContinuationRequest(t *testing.T) {
+	req := &perplexity.CompletionRequest{
+		Model:    "sonar",
+		Messages: []perplexity.Message{{Role: "user", Content: "Tell me a story"}},
+	}
+	next := ContinuationRequest(req, "Once upon")
+	want := []perplexity.Message{
+		{Role: "user", Content: "Tell me a story"},
+		{Role: "assistant", Content: "Once upon"},
+		{Role: "user", Content: ContinuePrompt},
+	}
+	if len(next.Messages) != len(want) {
+		t.Fatalf("Messages = %+v, want %+v", next.Messages, want)
+	}
+	for i := range want {
+		if next.Messages[i] != want[i] {
+			t.Errorf("message %d = %+v, want %+v", i, next.Messages[i], want[i])
+		}
+	}
+	if len(req.Messages) != 1 || next.Model != "sonar" {
+		t.Errorf("original request changed or options lost: %+v", req.Messages)
+	}
+
+	multimodal := &perplexity.CompletionRequest{MultimodalMessages: []perplexity.MultimodalMessage{
+		{Role: "user", Content: []perplexity.Content{perplexity.NewTextContent("Describe it")}},
+	}}
+	next = ContinuationRequest(multimodal, "It is")
+	if n := len(next.MultimodalMessages); n != 3 || *next.MultimodalMessages[1].Content[0].Text != "It is" ||
+		*next.MultimodalMessages[2].Content[0].Text != ContinuePrompt {
+		t.Errorf("MultimodalMessages = %+v", next.MultimodalMessages)
+	}
+}
+
+// answer returns a response with a single answer and its usage.
+func answer(content, reason string, tokens int, cost float64) *perplexity.CompletionResponse {
+	return &perplexity.CompletionResponse{
+		Model: "sonar",
+		Choices: []perplexity.Choice{{FinishReason: reason,
+			Message: perplexity.Message{Role: "assistant", Content: content}}},
+		Usage: perplexity.Usage{PromptTokens: 10, CompletionTokens: tokens, TotalTokens: 10 + tokens,
+			Cost: &perplexity.Cost{TotalCost: &cost}},
+	}
+}
+
+func TestContinue(t *testing.T) {
+	req := &perplexity.CompletionRequest{Model: "sonar", Messages: []perplexity.Message{{Role: "user", Content: "q"}}}
+	parts := []*perplexity.CompletionResponse{
+		answer("middle and", "length", 5, 0.02),
+		answer(" the end.", "stop", 3, 0.01),
+	}
+	var answers []string
+	send := func(next *perplexity.CompletionRequest, sofar string) (*perplexity.CompletionResponse, error) {
+		answers = append(answers, sofar)
+		if last := next.Messages[len(next.Messages)-2]; last.Content != sofar {
+			t.Errorf("continuation request carries %q, want %q", last.Content, sofar)
+		}
+		res := parts[0]
+		parts = parts[1:]
+		return res, nil
+	}
+
+	got, err := Continue(req, answer("Start, ", "length", 7, 0.03), 5, send)
+	if err != nil {
+		t.Fatalf("Continue() error = %v", err)
+	}
+	if content := got.Choices[0].Message.Content; content != "Start, middle and the end." {
+		t.Errorf("answer = %q", content)
+	}
+	if got.Choices[0].FinishReason != "stop" {
+		t.Errorf("finish reason = %q, want stop", got.Choices[0].FinishReason)
+	}
+	if got.Usage.PromptTokens != 30 || got.Usage.CompletionTokens != 15 || got.Usage.TotalTokens != 45 {
+		t.Errorf("usage = %+v, want the sum of the three requests", got.Usage)
+	}
+	if cost := *got.Usage.Cost.TotalCost; cost < 0.0599 || cost > 0.0601 {
+		t.Errorf("total cost = %v, want 0.06", cost)
+	}
+	if len(answers) != 2 || answers[1] != "Start, middle and" {
+		t.Errorf("answers passed to send = %q", answers)
+	}
+}
+
+func TestContinue_Limits(t *testing.T) {
+	req := &perplexity.CompletionRequest{Model: "sonar", Messages: []perplexity.Message{{Role: "user", Content: "q"}}}
+	calls := 0
+	send := func(*perplexity.CompletionRequest, string) (*perplexity.CompletionResponse, error) {
+		calls++
+		return answer(" more", "length", 1, 0), nil
+	}
+
+	got, err := Continue(req, answer("text", "length", 1, 0), 2, send)
+	if err != nil || calls != 2 {
+		t.Fatalf("Continue() = %v after %d calls, want 2 calls", err, calls)
+	}
+	if !Truncated(got) || got.Choices[0].Message.Content != "text more more" {
+		t.Errorf("answer = %+v, want the still truncated answer", got.Choices[0])
+	}
+
+	calls = 0
+	if _, err := Continue(req, answer("done", "stop", 1, 0), 2, send); err != nil || calls != 0 {
+		t.Errorf("complete answer was continued: %d calls, error %v", calls, err)
+	}
+
+	sendErr := errors.New("boom")
+	failing := func(*perplexity.CompletionRequest, string) (*perplexity.CompletionResponse, error) {
+		return nil, sendErr
+	}
+	got, err = Continue(req, answer("partial", "length", 1, 0), 2, failing)
+	if !errors.Is(err, sendErr) || got.Choices[0].Message.Content != "partial" {
+		t.Errorf("Continue() = %+v, %v, want the partial answer and the error", got, err)
+	}
+}
+
+func TestSend_AutoContinueStream(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body perplexity.CompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		requests++
+		answers, reason := []string{"The first", "The first part. The sec"}, "length"
+		if requests > 1 {
+			if last := body.Messages[len(body.Messages)-1]; last.Content != ContinuePrompt {
+				t.Errorf("continuation ends with %q, want %q", last.Content, ContinuePrompt)
+			}
+			answers, reason = []string{"The second", "The second part."}, "stop"
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i, content := range answers {
+			choice := map[string]any{"index": 0, "message": map[string]string{"role": "assistant", "content": content}}
+			if i == len(answers)-1 {
+				choice["finish_reason"] = reason
+			}
+			event, _ := json.Marshal(map[string]any{
+				"id": "stream-id", "model": "sonar", "choices": []map[string]any{choice},
+				"usage": map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+			})
+			_, _ = w.Write([]byte("data: " + string(event) + "\n\n"))
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	var streamed []string
+	c := NewChatWithOptions(client, "", Options{
+		Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0, Stream: true, AutoContinue: 3,
+		OnStream: func(_, cumulative string) { streamed = append(streamed, cumulative) },
+	})
+	_ = c.AddUserMessage("question")
+
+	resp, err := c.Send()
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	const want = "The first part. The second part."
+	if requests != 2 || resp.GetLastContent() != want {
+		t.Errorf("Send() = %q after %d requests, want %q after 2", resp.GetLastContent(), requests, want)
+	}
+	if last := streamed[len(streamed)-1]; last != want {
+		t.Errorf("last streamed answer = %q, want %q", last, want)
+	}
+	for i := 1; i < len(streamed); i++ {
+		if len(streamed[i]) < len(streamed[i-1]) {
+			t.Errorf("streamed answer went back from %q to %q", streamed[i-1], streamed[i])
+		}
+	}
+	totals := c.Totals()
+	if totals.Requests != 2 || totals.CompletionTokens != 10 {
+		t.Errorf("Totals() = %+v, want both requests counted", totals)
+	}
+	if got := c.Messages.GetMessages(); got[len(got)-1].Content != want || len(got) != 2 {
+		t.Errorf("history = %+v, want one joined answer", got)
+	}
+}
@@ -0,0 +1,24 @@
+package chat
+
+import (
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/output"
+)
+
+// CheckFormat checks the answer of response against the regex response
+// format of the chat. Returns nil when the chat has none, and an error when
+// Go cannot compile the pattern; see output.MatchRegex.
+func (c *Chat) CheckFormat(response *perplexity.CompletionResponse) (*output.FormatMatch, error) {
+	if c.options.ResponseFormatRegex == "" {
+		return nil, nil //nolint:nilnil // no format to check
+	}
+	content, err := output.FirstContent(response)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // already an APIError
+	}
+	m, err := output.MatchRegex(c.options.ResponseFormatRegex, content)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // describes the pattern
+	}
+	return &m, nil
+}
@@ -0,0 +1,35 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+func TestCheckFormat(t *testing.T) {
+	answer := func(content string) *perplexity.CompletionResponse {
+		return &perplexity.CompletionResponse{
+			Choices: []perplexity.Choice{{Message: perplexity.Message{Role: "assistant", Content: content}}},
+		}
+	}
+	client := perplexity.NewClient("test-key")
+
+	c := NewChatWithOptions(client, "", Options{Model: "sonar"})
+	if m, err := c.CheckFormat(answer("anything")); m != nil || err != nil {
+		t.Errorf("CheckFormat() without a regex = %v, %v, want nil", m, err)
+	}
+
+	c = NewChatWithOptions(client, "", Options{Model: "sonar", ResponseFormatRegex: `\d{4}-\d{2}`})
+	m, err := c.CheckFormat(answer("2024-xx"))
+	if err != nil || m == nil || m.Matched || m.Offset != 5 {
+		t.Errorf("CheckFormat() = %+v, %v, want a mismatch at 5", m, err)
+	}
+	if m, err := c.CheckFormat(answer("2024-06")); err != nil || m == nil || !m.Matched {
+		t.Errorf("CheckFormat() = %+v, %v, want a match", m, err)
+	}
+
+	c = NewChatWithOptions(client, "", Options{Model: "sonar", ResponseFormatRegex: `(?=a)`})
+	if _, err := c.CheckFormat(answer("a")); err == nil {
+		t.Error("CheckFormat() with a regex Go cannot compile: error = nil")
+	}
+}
@@ -0,0 +1,112 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// stallingSSEServer streams the given cumulative answers, then waits for
+// the client to go away without finishing the stream.
+func stallingSSEServer(t *testing.T, answers []string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, answer := range answers {
+			event, _ := json.Marshal(map[string]any{
+				"id": "stall-id", "model": "sonar",
+				"choices": []map[string]any{{"index": 0,
+					"message": map[string]string{"role": "assistant", "content": answer}}},
+			})
+			_, _ = w.Write([]byte("data: " + string(event) + "\n\n"))
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSendContext_InterruptedKeepsPartialAnswer(t *testing.T) {
+	srv := stallingSSEServer(t, []string{"Hello", "Hello wor"})
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewChatWithOptions(client, "", Options{
+		Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0, Stream: true,
+		OnStream: func(_, cumulative string) {
+			if cumulative == "Hello wor" {
+				cancel()
+			}
+		},
+	})
+	_ = c.AddUserMessage("say hello")
+
+	resp, err := c.SendContext(ctx)
+	if !errors.Is(err, clerrors.ErrRequestInterrupted) {
+		t.Fatalf("SendContext() error = %v, want ErrRequestInterrupted", err)
+	}
+	if resp == nil || resp.GetLastContent() != "Hello wor" {
+		t.Fatalf("SendContext() response = %+v, want the partial answer", resp)
+	}
+	history := c.history()
+	if len(history) != 2 || history[1].Content != "Hello wor" {
+		t.Errorf("history = %+v, want the question and the partial answer", history)
+	}
+	if totals := c.Totals(); totals.Requests != 1 || totals.CompletionTokens == 0 {
+		t.Errorf("totals = %+v, want the estimated usage of the partial answer", totals)
+	}
+}
+
+func TestSendContext_InterruptedBeforeAnswer(t *testing.T) {
+	srv := stallingSSEServer(t, nil)
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := NewChatWithOptions(client, "", Options{
+		Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0, Stream: true,
+	})
+	_ = c.AddUserMessage("say hello")
+
+	if _, err := c.SendContext(ctx); !errors.Is(err, clerrors.ErrRequestInterrupted) {
+		t.Fatalf("SendContext() error = %v, want ErrRequestInterrupted", err)
+	}
+	if history := c.history(); len(history) != 0 {
+		t.Errorf("history = %+v, want the unanswered question removed", history)
+	}
+	if err := c.AddUserMessage("next question"); err != nil {
+		t.Errorf("AddUserMessage() after the interruption error = %v", err)
+	}
+}
+
+func TestPartialUsage(t *testing.T) {
+	req := &perplexity.CompletionRequest{Messages: []perplexity.Message{{Role: "user", Content: "12345678"}}}
+	res := &perplexity.CompletionResponse{Choices: []perplexity.Choice{{Message: perplexity.Message{Content: "abcd"}}}}
+
+	got := PartialUsage(req, res)
+	if got.Usage.PromptTokens != EstimateMessages(req.Messages) || got.Usage.CompletionTokens != EstimateTokens("abcd") ||
+		got.Usage.TotalTokens != got.Usage.PromptTokens+got.Usage.CompletionTokens {
+		t.Errorf("PartialUsage() usage = %+v, want estimates", got.Usage)
+	}
+	if res.Usage.TotalTokens != 0 {
+		t.Error("PartialUsage() modified its argument")
+	}
+
+	res.Usage = perplexity.Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3}
+	if got := PartialUsage(req, res); got.Usage != res.Usage {
+		t.Errorf("PartialUsage() = %+v, want the reported usage kept", got.Usage)
+	}
+	if PartialUsage(req, nil) != nil {
+		t.Error("PartialUsage(nil) != nil")
+	}
+}
@@ -0,0 +1,190 @@
+package chat
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/validation"
+)
+
+// OptionOverride changes the Options of a single request; see Run and Send.
+// The session Options are left as they are.
+type OptionOverride func(*Options)
+
+// override describes a key accepted by /with: parse validates a value and
+// returns the override that sets it.
+type override struct {
+	key   string
+	help  string
+	parse func(value string) (OptionOverride, error)
+}
+
+// overrides lists the keys accepted by /with in help order.
+var overrides = []override{
+	{"recency", "search recency (" + validation.ValidList(validation.KindRecency) + ")",
+		func(v string) (OptionOverride, error) {
+			if err := validation.ValidateRecency(v); err != nil {
+				return nil, err //nolint:wrapcheck // already wraps a sentinel
+			}
+			return func(o *Options) { o.SearchRecency = v }, nil
+		}},
+	{"mode", "search mode (" + validation.ValidList(validation.KindSearchMode) + ")",
+		func(v string) (OptionOverride, error) {
+			if err := validation.ValidateSearchMode(v); err != nil {
+				return nil, err //nolint:wrapcheck // already wraps a sentinel
+			}
+			return func(o *Options) { o.SearchMode = v }, nil
+		}},
+	{"context", "search context size (" + validation.ValidList(validation.KindContextSize) + ")",
+		func(v string) (OptionOverride, error) {
+			if err := validation.ValidateContextSize(v); err != nil {
+				return nil, err //nolint:wrapcheck // already wraps a sentinel
+			}
+			return func(o *Options) { o.SearchContextSize = v }, nil
+		}},
+	{"domains", "comma-separated domains to search", func(v string) (OptionOverride, error) {
+		domains, err := parseDomains(v)
+		if err != nil {
+			return nil, err
+		}
+		return func(o *Options) { o.SearchDomains = domains }, nil
+	}},
+	{"exclude", "comma-separated domains to leave out", func(v string) (OptionOverride, error) {
+		domains, err := parseDomains(v)
+		if err != nil {
+			return nil, err
+		}
+		return func(o *Options) { o.ExcludeDomains = domains }, nil
+	}},
+	{"after", "only sources published after this date", func(v string) (OptionOverride, error) {
+		if _, err := parseDate(v, clerrors.ErrInvalidSearchAfterDate); err != nil {
+			return nil, err
+		}
+		return func(o *Options) { o.SearchAfterDate = v }, nil
+	}},
+	{"before", "only sources published before this date", func(v string) (OptionOverride, error) {
+		if _, err := parseDate(v, clerrors.ErrInvalidSearchBeforeDate); err != nil {
+			return nil, err
+		}
+		return func(o *Options) { o.SearchBeforeDate = v }, nil
+	}},
+	{"effort", "reasoning effort (" + validation.ValidList(validation.KindReasoningEffort) + ")",
+		func(v string) (OptionOverride, error) {
+			if err := validation.ValidateReasoningEffort(v); err != nil {
+				return nil, err //nolint:wrapcheck // already wraps a sentinel
+			}
+			return func(o *Options) { o.ReasoningEffort = v }, nil
+		}},
+	{"language", "answer language (ISO 639-1 code)", func(v string) (OptionOverride, error) {
+		if err := validation.ValidateLanguage(v); err != nil {
+			return nil, err //nolint:wrapcheck // already wraps a sentinel
+		}
+		return func(o *Options) { o.Language = v }, nil
+	}},
+	{"model", "model for this message", func(v string) (OptionOverride, error) {
+		return func(o *Options) { o.Model = v }, nil
+	}},
+}
+
+// ParseOverride validates value for the /with key and returns the override
+// that sets it. Errors wrap clerrors.ErrChatCommandUsage for an unknown key
+// or an empty value, or the sentinel of the validator of the key.
+func ParseOverride(key, value string) (OptionOverride, error) {
+	i := slices.IndexFunc(overrides, func(o override) bool { return o.key == key })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: unknown /with option %q (valid: %s)",
+			clerrors.ErrChatCommandUsage, key, strings.Join(OverrideKeys(), ", "))
+	}
+	if value == "" {
+		return nil, fmt.Errorf("%w: /with option %s needs a value", clerrors.ErrChatCommandUsage, key)
+	}
+	return overrides[i].parse(value)
+}
+
+// OverrideKeys returns the keys accepted by /with.
+func OverrideKeys() []string {
+	keys := make([]string, len(overrides))
+	for i, o := range overrides {
+		keys[i] = o.key
+	}
+	return keys
+}
+
+// ParseWith parses a "/with key=value... question" line. The key=value
+// words at the start set options for this message only and the rest of the
+// line is the question. ok is false when input is not a /with command.
+// Every value is validated, so an invalid line fails before any request.
+func ParseWith(input string) (question string, opts []OptionOverride, ok bool, err error) {
+	name, rest, _ := strings.Cut(strings.TrimSpace(input), " ")
+	if name != "/with" {
+		return "", nil, false, nil
+	}
+	rest = strings.TrimSpace(rest)
+	for {
+		word, tail, _ := strings.Cut(rest, " ")
+		key, value, isOption := strings.Cut(word, "=")
+		if !isOption || word == "" {
+			break
+		}
+		opt, err := ParseOverride(key, value)
+		if err != nil {
+			return "", nil, true, err
+		}
+		opts = append(opts, opt)
+		rest = strings.TrimSpace(tail)
+	}
+	if rest == "" {
+		return "", nil, true, fmt.Errorf("%w: /with key=value... <question>", clerrors.ErrChatCommandUsage)
+	}
+	return rest, opts, true, nil
+}
+
+// parseDomains splits a comma-separated list of domains and validates each.
+func parseDomains(value string) ([]string, error) {
+	var domains []string
+	for _, domain := range strings.Split(value, ",") {
+		domain = strings.TrimSpace(domain)
+		if err := validation.ValidateDomain(domain); err != nil {
+			return nil, err //nolint:wrapcheck // already wraps clerrors.ErrInvalidDomain
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
+
+// clone returns a copy of o that shares no slices with it.
+func (o Options) clone() Options {
+	o.SearchDomains = slices.Clone(o.SearchDomains)
+	o.ExcludeDomains = slices.Clone(o.ExcludeDomains)
+	o.ImageDomains = slices.Clone(o.ImageDomains)
+	o.ImageFormats = slices.Clone(o.ImageFormats)
+	return o
+}
+
+// withOverrides applies overrides to a copy of the session options for the
+// duration of one request. The returned function restores the session
+// options and must be called when the request is done.
+func (c *Chat) withOverrides(opts []OptionOverride) func() {
+	if len(opts) == 0 {
+		return func() {}
+	}
+	session := c.options
+	merged := session.clone()
+	for _, opt := range opts {
+		opt(&merged)
+	}
+	c.options = merged
+	return func() { c.options = session }
+}
+
+// parseDate parses a date filter value and wraps fieldErr on failure.
+func parseDate(value string, fieldErr error) (time.Time, error) {
+	date, err := validation.ValidateDate(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", fieldErr, err)
+	}
+	return date, nil
+}
@@ -0,0 +1,156 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// newOverrideTestChat returns a chat backed by a server that records the
+// search options of the last request and counts requests.
+func newOverrideTestChat(t *testing.T, opts Options) (*Chat, *atomic.Int32, *map[string]any) {
+	t.Helper()
+	var calls atomic.Int32
+	var last map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		last = nil
+		_ = json.NewDecoder(r.Body).Decode(&last)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(mockCompletionResponseJSON()))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	return NewChatWithOptions(client, "system", opts), &calls, &last
+}
+
+func TestParseWith(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		wantQuestion string
+		wantOpts     int
+		wantOK       bool
+		wantErr      error
+	}{
+		{"not /with", "what is Go?", "", 0, false, nil},
+		{"other command", "/model sonar", "", 0, false, nil},
+		{"options and question", "/with recency=week mode=academic what is new in Go?",
+			"what is new in Go?", 2, true, nil},
+		{"no options", "/with what is Go?", "what is Go?", 0, true, nil},
+		{"equals in question", "/with mode=web is 1+1=2?", "is 1+1=2?", 1, true, nil},
+		{"missing question", "/with recency=week", "", 0, true, clerrors.ErrChatCommandUsage},
+		{"bare", "/with", "", 0, true, clerrors.ErrChatCommandUsage},
+		{"unknown key", "/with colour=red hi", "", 0, true, clerrors.ErrChatCommandUsage},
+		{"empty value", "/with recency= hi", "", 0, true, clerrors.ErrChatCommandUsage},
+		{"invalid recency", "/with recency=fortnight hi", "", 0, true, clerrors.ErrInvalidSearchRecency},
+		{"invalid mode", "/with mode=deep hi", "", 0, true, clerrors.ErrInvalidSearchMode},
+		{"invalid date", "/with after=someday hi", "", 0, true, clerrors.ErrInvalidSearchAfterDate},
+		{"invalid domain", "/with domains=https://go.dev hi", "", 0, true, clerrors.ErrInvalidDomain},
+		{"invalid language", "/with language=xx hi", "", 0, true, clerrors.ErrInvalidLanguage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question, opts, ok, err := ParseWith(tt.input)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("ParseWith(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+			if question != tt.wantQuestion || len(opts) != tt.wantOpts || ok != tt.wantOK {
+				t.Errorf("ParseWith(%q) = %q, %d overrides, %v; want %q, %d, %v",
+					tt.input, question, len(opts), ok, tt.wantQuestion, tt.wantOpts, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRun_OverridesDoNotMutateSession(t *testing.T) {
+	opts := Options{
+		Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+		SearchRecency: "month", SearchDomains: []string{"go.dev"},
+	}
+	c, calls, last := newOverrideTestChat(t, opts)
+	before := c.options.clone()
+
+	_, overrides, _, err := ParseWith("/with recency=week mode=academic domains=arxiv.org,acm.org q")
+	if err != nil {
+		t.Fatalf("ParseWith() error = %v", err)
+	}
+	// An override that edits a slice in place must not reach the session.
+	overrides = append(overrides, func(o *Options) { o.ExcludeDomains = append(o.ExcludeDomains, "x.com") })
+	_ = c.AddUserMessage("what is new?")
+	if _, err := c.Run(overrides...); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("sent %d requests, want 1", calls.Load())
+	}
+	if (*last)["search_recency_filter"] != "week" || (*last)["search_mode"] != "academic" {
+		t.Errorf("request = %v, want the overridden recency and mode", *last)
+	}
+	if !reflect.DeepEqual(c.options, before) {
+		t.Errorf("session options = %+v, want unchanged %+v", c.options, before)
+	}
+
+	if _, err := c.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if (*last)["search_recency_filter"] != "month" || (*last)["search_mode"] == "academic" {
+		t.Errorf("request after overrides = %v, want the session options", *last)
+	}
+}
+
+func TestSend_Overrides(t *testing.T) {
+	c, _, last := newOverrideTestChat(t, Options{Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0})
+	model, err := ParseOverride("model", "sonar-pro")
+	if err != nil {
+		t.Fatalf("ParseOverride() error = %v", err)
+	}
+	_ = c.AddUserMessage("hi")
+	if _, err := c.Send(model); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if (*last)["model"] != "sonar-pro" {
+		t.Errorf("request model = %v, want sonar-pro", (*last)["model"])
+	}
+	if c.Model() != "sonar" {
+		t.Errorf("session model = %q, want sonar", c.Model())
+	}
+	if got := len(c.Messages.GetMessages()); got != 3 {
+		t.Errorf("conversation has %d messages, want the answer added", got)
+	}
+}
+
+func TestRun_InvalidOverrideNotSent(t *testing.T) {
+	c, calls, _ := newOverrideTestChat(t, Options{Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0})
+	_ = c.AddUserMessage("hi")
+	// Overrides built outside ParseOverride are validated with the request.
+	_, err := c.Run(func(o *Options) { o.SearchMode = "deep" })
+	if !errors.Is(err, clerrors.ErrInvalidSearchMode) {
+		t.Errorf("Run() error = %v, want ErrInvalidSearchMode", err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("sent %d requests, want none", calls.Load())
+	}
+	if c.options.SearchMode != "" {
+		t.Errorf("session search mode = %q, want it restored", c.options.SearchMode)
+	}
+}
+
+func TestOverrideKeys(t *testing.T) {
+	keys := OverrideKeys()
+	for _, key := range []string{"recency", "mode", "context", "domains", "after", "before", "effort"} {
+		if !slices.Contains(keys, key) {
+			t.Errorf("OverrideKeys() = %v, missing %s", keys, key)
+		}
+	}
+}
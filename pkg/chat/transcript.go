@@ -0,0 +1,188 @@
+package chat
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/citations"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Format is a transcript export format.
+type Format string
+
+// Supported transcript formats.
+const (
+	FormatMarkdown Format = "markdown"
+	FormatJSON     Format = "json"
+	FormatHTML     Format = "html"
+)
+
+// timeLayout is how markdown transcripts print timestamps.
+const timeLayout = time.RFC3339
+
+//go:embed transcript.html.tmpl
+var htmlTemplateText string
+
+// htmlTemplate renders FormatHTML transcripts.
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"timestamp": func(t time.Time) string { return t.Format(timeLayout) },
+}).Parse(htmlTemplateText))
+
+// Formats returns the supported format names in display order.
+func Formats() []string {
+	return []string{string(FormatMarkdown), string(FormatJSON), string(FormatHTML)}
+}
+
+// ParseFormat validates a format name; empty selects FormatMarkdown.
+// Returns clerrors.ErrInvalidTranscriptFormat for unknown formats.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(s))); format {
+	case "", "md":
+		return FormatMarkdown, nil
+	case FormatMarkdown, FormatJSON, FormatHTML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: %q. Must be one of: %s",
+			clerrors.ErrInvalidTranscriptFormat, s, strings.Join(Formats(), ", "))
+	}
+}
+
+// FormatForPath picks the format matching the extension of path:
+// .json, .html or .htm, and markdown for anything else.
+func FormatForPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".html", ".htm":
+		return FormatHTML
+	default:
+		return FormatMarkdown
+	}
+}
+
+// Transcript is a chat session prepared for export. Its JSON encoding is
+// what FormatJSON writes and ReadTranscript reads back.
+type Transcript struct {
+	Model        string              `json:"model"`
+	SystemPrompt string              `json:"system_prompt,omitempty"`
+	Started      time.Time           `json:"started"`
+	Messages     []TranscriptMessage `json:"messages"`
+}
+
+// TranscriptMessage is one user or assistant turn of a Transcript. Model,
+// Sources, SearchQueries and Usage are only set on answers; SearchQueries
+// only when the API reported the searches it ran.
+type TranscriptMessage struct {
+	Role          string             `json:"role"`
+	Content       string             `json:"content"`
+	Timestamp     time.Time          `json:"timestamp"`
+	Model         string             `json:"model,omitempty"`
+	Sources       []citations.Source `json:"sources,omitempty"`
+	SearchQueries []string           `json:"search_queries,omitempty"`
+	Usage         *perplexity.Usage  `json:"usage,omitempty"`
+}
+
+// Transcript returns the conversation with the details kept for each turn.
+func (c *Chat) Transcript() Transcript {
+	t := Transcript{
+		Model:        c.options.Model,
+		SystemPrompt: c.Messages.GetSystemMessage(),
+		Started:      c.started,
+	}
+	answer := 0
+	for i, msg := range c.history() {
+		m := TranscriptMessage{Role: msg.Role, Content: msg.Content}
+		if i < len(c.times) {
+			m.Timestamp = c.times[i]
+		}
+		if msg.Role == "assistant" {
+			if answer < len(c.replies) {
+				r := c.replies[answer]
+				m.Model, m.Sources, m.SearchQueries, m.Usage = r.model, r.sources, r.searches, r.usage
+			}
+			answer++
+		}
+		t.Messages = append(t.Messages, m)
+	}
+	return t
+}
+
+// ReadTranscript decodes a transcript written in FormatJSON.
+func ReadTranscript(r io.Reader) (*Transcript, error) {
+	var t Transcript
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	return &t, nil
+}
+
+// Write writes t to w in format.
+func (t *Transcript) Write(w io.Writer, format Format) error {
+	var err error
+	switch format {
+	case FormatJSON:
+		err = t.writeJSON(w)
+	case FormatHTML:
+		err = htmlTemplate.Execute(w, t)
+	default:
+		_, err = io.WriteString(w, t.markdown())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	return nil
+}
+
+// WriteTranscript writes the conversation as markdown. Each answer is
+// followed by the sources it cited, as footnotes.
+func (c *Chat) WriteTranscript(w io.Writer) error {
+	t := c.Transcript()
+	return t.Write(w, FormatMarkdown)
+}
+
+func (t *Transcript) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t) //nolint:wrapcheck // wrapped by Write
+}
+
+// markdown renders t with a "## User" or "## Assistant" section per turn.
+func (t *Transcript) markdown() string {
+	var b strings.Builder
+	b.WriteString("# Chat transcript\n\n")
+	fmt.Fprintf(&b, "- Model: %s\n", t.Model)
+	if !t.Started.IsZero() {
+		fmt.Fprintf(&b, "- Started: %s\n", t.Started.Format(timeLayout))
+	}
+
+	if t.SystemPrompt != "" {
+		fmt.Fprintf(&b, "\n## System\n\n%s\n", t.SystemPrompt)
+	}
+
+	footnotes := 0
+	for _, msg := range t.Messages {
+		switch {
+		case msg.Role != "assistant":
+			b.WriteString("\n## User\n")
+		case msg.Model != "":
+			fmt.Fprintf(&b, "\n## Assistant (%s)\n", msg.Model)
+		default:
+			b.WriteString("\n## Assistant\n")
+		}
+		if !msg.Timestamp.IsZero() {
+			fmt.Fprintf(&b, "\n_%s_\n", msg.Timestamp.Format(timeLayout))
+		}
+		content := citations.MarkdownFootnotes(msg.Content, msg.Sources, footnotes)
+		fmt.Fprintf(&b, "\n%s\n", strings.TrimRight(content, "\n"))
+		footnotes += len(msg.Sources)
+	}
+	return b.String()
+}
@@ -0,0 +1,192 @@
+package chat
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/citations"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/searches"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testTranscript is a two-turn session with cited answers.
+func testTranscript() *Transcript {
+	start := time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)
+	return &Transcript{
+		Model:        "sonar",
+		SystemPrompt: "Be brief.",
+		Started:      start,
+		Messages: []TranscriptMessage{
+			{Role: "user", Content: "What is Go?", Timestamp: start.Add(5 * time.Second)},
+			{
+				Role:      "assistant",
+				Content:   "Go is a programming language [1] designed at Google [2].",
+				Timestamp: start.Add(8 * time.Second),
+				Model:     "sonar",
+				Sources: []citations.Source{
+					{Title: "The Go Programming Language", URL: "https://go.dev"},
+					{URL: "https://en.wikipedia.org/wiki/Go_(programming_language)"},
+				},
+				Usage: &perplexity.Usage{PromptTokens: 12, CompletionTokens: 20, TotalTokens: 32},
+			},
+			{Role: "user", Content: "Who uses <generics>?", Timestamp: start.Add(time.Minute)},
+			{
+				Role:      "assistant",
+				Content:   "Many projects do [1].",
+				Timestamp: start.Add(time.Minute + 3*time.Second),
+				Model:     "sonar-pro",
+				Sources:   []citations.Source{{Title: "Generics", URL: "https://go.dev/doc/tutorial/generics"}},
+				Usage:     &perplexity.Usage{PromptTokens: 40, CompletionTokens: 8, TotalTokens: 48},
+			},
+		},
+	}
+}
+
+func TestTranscript_Write(t *testing.T) {
+	goldenFiles := map[Format]string{
+		FormatMarkdown: "transcript.md",
+		FormatJSON:     "transcript.json",
+		FormatHTML:     "transcript.html",
+	}
+	for format, name := range goldenFiles {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := testTranscript().Write(&buf, format); err != nil {
+				t.Fatalf("Write() error: %v", err)
+			}
+			golden := filepath.Join("testdata", name)
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("ReadFile() error: %v (run with -update to create it)", err)
+			}
+			if buf.String() != string(want) {
+				t.Errorf("%s transcript =\n%s\nwant\n%s", format, buf.String(), want)
+			}
+		})
+	}
+}
+
+func TestReadTranscript_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testTranscript().Write(&buf, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadTranscript(&buf)
+	if err != nil {
+		t.Fatalf("ReadTranscript() error: %v", err)
+	}
+	var md, want bytes.Buffer
+	_ = got.Write(&md, FormatMarkdown)
+	_ = testTranscript().Write(&want, FormatMarkdown)
+	if md.String() != want.String() {
+		t.Errorf("round trip changed the transcript:\n%s\nwant\n%s", md.String(), want.String())
+	}
+}
+
+func TestChat_TranscriptRecordsTimestamps(t *testing.T) {
+	c, _, _ := newCommandTestChat(t)
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	ask(t, c, "first")
+	ask(t, c, "second")
+	if err := c.PrepareRetry(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Send(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := c.Transcript()
+	if tr.SystemPrompt != "be brief" || tr.Model != "sonar" {
+		t.Errorf("header = %q, %q", tr.SystemPrompt, tr.Model)
+	}
+	if len(tr.Messages) != 4 {
+		t.Fatalf("messages = %d, want 4", len(tr.Messages))
+	}
+	for i, m := range tr.Messages {
+		if m.Timestamp.IsZero() {
+			t.Errorf("message %d has no timestamp", i)
+		}
+		if i > 0 && !m.Timestamp.After(tr.Messages[i-1].Timestamp) {
+			t.Errorf("message %d timestamp %v is not after the previous one", i, m.Timestamp)
+		}
+	}
+	last := tr.Messages[3]
+	if last.Usage == nil || last.Usage.TotalTokens != 15 || len(last.Sources) != 1 {
+		t.Errorf("answer details = usage %+v, sources %v", last.Usage, last.Sources)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"": FormatMarkdown, "md": FormatMarkdown, "HTML": FormatHTML, "json": FormatJSON} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("pdf"); !errors.Is(err, clerrors.ErrInvalidTranscriptFormat) {
+		t.Errorf("ParseFormat(pdf) error = %v", err)
+	}
+}
+
+func TestSaveArgs(t *testing.T) {
+	tests := []struct {
+		arg, path string
+		format    Format
+	}{
+		{"chat.md", "chat.md", FormatMarkdown},
+		{"chat.JSON", "chat.JSON", FormatJSON},
+		{"chat.htm", "chat.htm", FormatHTML},
+		{"notes.txt html", "notes.txt", FormatHTML},
+		{"my notes.txt", "my notes.txt", FormatMarkdown},
+	}
+	for _, tt := range tests {
+		path, format := saveArgs(tt.arg)
+		if path != tt.path || format != tt.format {
+			t.Errorf("saveArgs(%q) = %q, %q; want %q, %q", tt.arg, path, format, tt.path, tt.format)
+		}
+	}
+}
+
+func TestChat_TranscriptRecordsSearchQueries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"transcript-searches","model":"sonar",` +
+			`"choices":[{"message":{"role":"assistant","content":"Answer"}}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	c := NewChatWithOptions(client, "", Options{Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0})
+
+	// The API client's transport records the queries; see package searches.
+	searches.Record("transcript-searches", []string{"go release notes"})
+	if err := c.AddUserMessage("What is new in Go?"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Send(); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	msgs := c.Transcript().Messages
+	if len(msgs) != 2 || msgs[0].SearchQueries != nil ||
+		len(msgs[1].SearchQueries) != 1 || msgs[1].SearchQueries[0] != "go release notes" {
+		t.Errorf("transcript messages = %+v, want the searches on the answer", msgs)
+	}
+}
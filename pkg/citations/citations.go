@@ -0,0 +1,262 @@
+// Package citations controls how the numbered source markers in an answer,
+// such as [1], are presented.
+//
+// Four styles are supported:
+//   - list: markers are kept and the sources are printed after the answer
+//   - inline: markers become markdown links to their source
+//   - footnote: markers are kept and a references section mapping each cited
+//     marker to its source is appended to the answer
+//   - none: markers are removed and no sources are printed
+//
+// Marker [n] refers to the n-th source, matching how Perplexity numbers its
+// search results.
+package citations
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Style selects how citation markers are presented.
+type Style string
+
+// Supported citation styles.
+const (
+	StyleList     Style = "list"
+	StyleInline   Style = "inline"
+	StyleFootnote Style = "footnote"
+	StyleNone     Style = "none"
+)
+
+// referencesHeading introduces the footnote section.
+const referencesHeading = "References:"
+
+// markerPattern matches a citation marker candidate; isMarker decides
+// whether a match is a citation or, for example, a reference link.
+var markerPattern = regexp.MustCompile(`\[(\d{1,3})\]`)
+
+// codePattern matches code spans and fenced code blocks, whose brackets
+// are never citations.
+var codePattern = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+
+// Source is a document an answer cites.
+type Source struct {
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url"`
+	// Extra holds the URLs of the same domain a Filter grouped beneath
+	// this source.
+	Extra []string `json:"extra_urls,omitempty"`
+}
+
+// Styles returns the supported style names in display order.
+func Styles() []string {
+	return []string{string(StyleList), string(StyleInline), string(StyleFootnote), string(StyleNone)}
+}
+
+// ParseStyle validates a style name; empty selects StyleList.
+// Returns clerrors.ErrInvalidCitationStyle for unknown styles.
+func ParseStyle(s string) (Style, error) {
+	switch style := Style(strings.ToLower(strings.TrimSpace(s))); style {
+	case "":
+		return StyleList, nil
+	case StyleList, StyleInline, StyleFootnote, StyleNone:
+		return style, nil
+	default:
+		return "", fmt.Errorf("%w: %q. Must be one of: %s",
+			clerrors.ErrInvalidCitationStyle, s, strings.Join(Styles(), ", "))
+	}
+}
+
+// FromResponse returns the sources of a response in marker order. Search
+// results are preferred; older responses that only carry the deprecated
+// citations list yield sources without titles. The URLs a Filter grouped
+// beneath each source are set as its Extra.
+func FromResponse(response *perplexity.CompletionResponse) []Source {
+	if response == nil {
+		return nil
+	}
+	var sources []Source
+	for _, sr := range response.GetSearchResults() {
+		sources = append(sources, Source{Title: sr.Title, URL: sr.URL})
+	}
+	if len(sources) == 0 && response.Citations != nil { //nolint:staticcheck // fallback for older API responses
+		for _, url := range *response.Citations { //nolint:staticcheck // see above
+			sources = append(sources, Source{URL: url})
+		}
+	}
+	if extra := Grouped(response.ID); len(extra) == len(sources) {
+		for i := range sources {
+			sources[i].Extra = extra[i]
+		}
+	}
+	return sources
+}
+
+// Rewrite applies style to the markers in content. Inline turns each marker
+// with a known source into a markdown link and none removes every marker
+// along with the spaces before it; list and footnote leave content as is.
+func Rewrite(content string, style Style, sources []Source) string {
+	switch style {
+	case StyleInline:
+		return replaceMarkers(content, func(n int, marker string) (string, bool) {
+			if n < 1 || n > len(sources) || sources[n-1].URL == "" {
+				return marker, false
+			}
+			return "[" + strconv.Itoa(n) + "](" + sources[n-1].URL + ")", false
+		})
+	case StyleNone:
+		return replaceMarkers(content, func(int, string) (string, bool) {
+			return "", true
+		})
+	default:
+		return content
+	}
+}
+
+// Footnotes returns a references section listing the source of every marker
+// cited in content, in marker order, or "" when nothing is cited. Markers
+// without a known source are left out.
+func Footnotes(content string, sources []Source) string {
+	var cited []int
+	replaceMarkers(content, func(n int, marker string) (string, bool) {
+		if n >= 1 && n <= len(sources) && !slices.Contains(cited, n) {
+			cited = append(cited, n)
+		}
+		return marker, false
+	})
+	if len(cited) == 0 {
+		return ""
+	}
+	slices.Sort(cited)
+
+	var b strings.Builder
+	b.WriteString(referencesHeading + "\n\n")
+	for _, n := range cited {
+		src := sources[n-1]
+		if src.Title != "" {
+			fmt.Fprintf(&b, "- [%d] %s - %s\n", n, src.Title, src.URL)
+		} else {
+			fmt.Fprintf(&b, "- [%d] %s\n", n, src.URL)
+		}
+		for _, u := range src.Extra {
+			fmt.Fprintf(&b, "    - %s\n", u)
+		}
+	}
+	return b.String()
+}
+
+// MarkdownFootnotes turns every marker in content that has a known source
+// into a markdown footnote reference and appends a footnote definition for
+// each source. Footnotes are numbered from offset+1, so that the answers of
+// one document can share a numbering: with offset 2, [1] becomes [^3].
+// Returns content unchanged when there are no sources.
+func MarkdownFootnotes(content string, sources []Source, offset int) string {
+	if len(sources) == 0 {
+		return content
+	}
+	content = replaceMarkers(content, func(n int, marker string) (string, bool) {
+		if n < 1 || n > len(sources) {
+			return marker, false
+		}
+		return "[^" + strconv.Itoa(offset+n) + "]", false
+	})
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(content, "\n"))
+	b.WriteString("\n\n")
+	for i, src := range sources {
+		if src.Title != "" {
+			fmt.Fprintf(&b, "[^%d]: %s - %s\n", offset+i+1, src.Title, src.URL)
+		} else {
+			fmt.Fprintf(&b, "[^%d]: %s\n", offset+i+1, src.URL)
+		}
+		for _, u := range src.Extra {
+			fmt.Fprintf(&b, "    %s\n", u)
+		}
+	}
+	return b.String()
+}
+
+// replaceMarkers calls fn for every citation marker in content and replaces
+// the marker with its result. When fn reports trim, spaces and tabs directly
+// before the marker are removed too. Markers inside code are left alone.
+func replaceMarkers(content string, fn func(n int, marker string) (string, bool)) string {
+	return replaceRuns(content, func(n int, marker string, _ bool) (string, bool) {
+		return fn(n, marker)
+	})
+}
+
+// replaceRuns is replaceMarkers telling fn whether the marker directly
+// follows the previous one, as the second marker of [1][2] does.
+func replaceRuns(content string, fn func(n int, marker string, follows bool) (string, bool)) string {
+	matches := markerPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return content
+	}
+	code := codePattern.FindAllStringIndex(content, -1)
+
+	var b strings.Builder
+	// trimmed holds the spaces removed before a marker, given back to the
+	// marker directly following it unless that one is trimmed too.
+	var trimmed string
+	last, prevEnd := 0, -1
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if inCode(code, start) || !isMarker(content, start, end, prevEnd) {
+			continue
+		}
+		follows := start == prevEnd
+		prevEnd = end
+
+		n, _ := strconv.Atoi(content[m[2]:m[3]])
+		replacement, trim := fn(n, content[start:end], follows)
+		text := content[last:start]
+		if !follows {
+			trimmed = ""
+		}
+		switch {
+		case trim:
+			kept := strings.TrimRight(text, " \t")
+			trimmed += text[len(kept):]
+			text = kept
+		case replacement != "":
+			text = trimmed + text
+			trimmed = ""
+		}
+		b.WriteString(text)
+		b.WriteString(replacement)
+		last = end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// isMarker reports whether content[start:end] is a citation marker rather
+// than part of a reference link such as [text][1] or the text of an existing
+// link such as [1](url). Markers may follow a word or another marker
+// directly, as in "fast[1][2]"; prevEnd is the end of the previous marker.
+func isMarker(content string, start, end, prevEnd int) bool {
+	if end < len(content) && content[end] == '(' {
+		return false
+	}
+	if start == 0 || start == prevEnd {
+		return true
+	}
+	return content[start-1] != ']'
+}
+
+// inCode reports whether offset falls inside one of the code ranges.
+func inCode(code [][]int, offset int) bool {
+	for _, r := range code {
+		if offset >= r[0] && offset < r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package citations
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+var testSources = []Source{
+	{Title: "Go", URL: "https://go.dev"},
+	{Title: "Wiki", URL: "https://wikipedia.org"},
+	{URL: "https://example.com"},
+}
+
+func TestRewrite_Inline(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "single marker",
+			content: "Go is fast [1].",
+			want:    "Go is fast [1](https://go.dev).",
+		},
+		{
+			name:    "multiple indices",
+			content: "Go [1] and wikis [2] and more [3].",
+			want:    "Go [1](https://go.dev) and wikis [2](https://wikipedia.org) and more [3](https://example.com).",
+		},
+		{
+			name:    "repeated index",
+			content: "First [2], again [2].",
+			want:    "First [2](https://wikipedia.org), again [2](https://wikipedia.org).",
+		},
+		{
+			name:    "adjacent markers",
+			content: "Both agree[1][2].",
+			want:    "Both agree[1](https://go.dev)[2](https://wikipedia.org).",
+		},
+		{
+			name:    "out of range kept",
+			content: "Unknown [9].",
+			want:    "Unknown [9].",
+		},
+		{
+			name:    "code kept",
+			content: "Use `arr[1]` or\n```\nm[2] = 0\n```\n",
+			want:    "Use `arr[1]` or\n```\nm[2] = 0\n```\n",
+		},
+		{
+			name:    "existing links kept",
+			content: "See [1](https://other.example) and [docs][1].",
+			want:    "See [1](https://other.example) and [docs][1].",
+		},
+		{
+			name:    "marker at start",
+			content: "[3] says so.",
+			want:    "[3](https://example.com) says so.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rewrite(tt.content, StyleInline, testSources); got != tt.want {
+				t.Errorf("Rewrite() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewrite_None(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "single marker", content: "Go is fast [1].", want: "Go is fast."},
+		{name: "multiple indices", content: "Go [1] and wikis [2].", want: "Go and wikis."},
+		{name: "repeated and adjacent", content: "Yes [1][1] [2].\nNo [1].", want: "Yes.\nNo."},
+		{name: "unknown index removed", content: "Unknown [9].", want: "Unknown."},
+		{name: "code kept", content: "Use `arr[1]` [1].", want: "Use `arr[1]`."},
+		{name: "marker after word", content: "Go is fast[1][2].", want: "Go is fast."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rewrite(tt.content, StyleNone, testSources); got != tt.want {
+				t.Errorf("Rewrite() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewrite_ListAndFootnoteKeepContent(t *testing.T) {
+	content := "Go is fast [1][2]."
+	for _, style := range []Style{StyleList, StyleFootnote} {
+		if got := Rewrite(content, style, testSources); got != content {
+			t.Errorf("Rewrite(%s) = %q, want unchanged", style, got)
+		}
+	}
+}
+
+func TestFootnotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "repeated and unordered indices",
+			content: "B [2], A [1], B again [2], C [3].",
+			want: "References:\n\n" +
+				"- [1] Go - https://go.dev\n" +
+				"- [2] Wiki - https://wikipedia.org\n" +
+				"- [3] https://example.com\n",
+		},
+		{
+			name:    "only cited sources",
+			content: "Just [2].",
+			want:    "References:\n\n- [2] Wiki - https://wikipedia.org\n",
+		},
+		{
+			name:    "out of range ignored",
+			content: "Unknown [9].",
+			want:    "",
+		},
+		{
+			name:    "no markers",
+			content: "Nothing cited.",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Footnotes(tt.content, testSources); got != tt.want {
+				t.Errorf("Footnotes() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownFootnotes(t *testing.T) {
+	got := MarkdownFootnotes("Go [1] and `arr[2]` and [9].\n", testSources, 2)
+	want := "Go [^3] and `arr[2]` and [9].\n\n" +
+		"[^3]: Go - https://go.dev\n" +
+		"[^4]: Wiki - https://wikipedia.org\n" +
+		"[^5]: https://example.com\n"
+	if got != want {
+		t.Errorf("MarkdownFootnotes() =\n%q\nwant\n%q", got, want)
+	}
+	if got := MarkdownFootnotes("No sources [1].", nil, 0); got != "No sources [1]." {
+		t.Errorf("MarkdownFootnotes() without sources = %q", got)
+	}
+}
+
+func TestFromResponse(t *testing.T) {
+	results := []perplexity.SearchResult{
+		{Title: "Go", URL: "https://go.dev"},
+		{Title: "Wiki", URL: "https://wikipedia.org"},
+	}
+	deprecated := []string{"https://a.example", "https://b.example"}
+
+	tests := []struct {
+		name string
+		resp *perplexity.CompletionResponse
+		want []Source
+	}{
+		{name: "nil response", resp: nil, want: nil},
+		{
+			name: "search results",
+			resp: &perplexity.CompletionResponse{SearchResults: &results, Citations: &deprecated},
+			want: []Source{{Title: "Go", URL: "https://go.dev"}, {Title: "Wiki", URL: "https://wikipedia.org"}},
+		},
+		{
+			name: "deprecated citations only",
+			resp: &perplexity.CompletionResponse{Citations: &deprecated},
+			want: []Source{{URL: "https://a.example"}, {URL: "https://b.example"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromResponse(tt.resp); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromResponse_DeprecatedCitationsRewrite(t *testing.T) {
+	deprecated := []string{"https://a.example"}
+	sources := FromResponse(&perplexity.CompletionResponse{Citations: &deprecated})
+
+	if got, want := Rewrite("A [1].", StyleInline, sources), "A [1](https://a.example)."; got != want {
+		t.Errorf("Rewrite() = %q, want %q", got, want)
+	}
+	if got, want := Footnotes("A [1].", sources), "References:\n\n- [1] https://a.example\n"; got != want {
+		t.Errorf("Footnotes() = %q, want %q", got, want)
+	}
+}
+
+func TestParseStyle(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Style
+		wantErr bool
+	}{
+		{input: "", want: StyleList},
+		{input: "list", want: StyleList},
+		{input: "Inline", want: StyleInline},
+		{input: " footnote ", want: StyleFootnote},
+		{input: "none", want: StyleNone},
+		{input: "endnote", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseStyle(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, clerrors.ErrInvalidCitationStyle) {
+					t.Fatalf("ParseStyle(%q) error = %v, want ErrInvalidCitationStyle", tt.input, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseStyle(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseStyle(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package citations
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+// maxGrouped bounds how many responses keep their grouped URLs; the oldest
+// are forgotten first.
+const maxGrouped = 256
+
+// Filter hides and groups the sources of an answer by domain, so that the
+// sources do not cite one site five times or list unwanted aggregators.
+type Filter struct {
+	// MaxPerDomain is how many entries a domain keeps. The URLs of its later
+	// sources are listed beneath its last entry, and their markers point to
+	// it. Zero keeps every source.
+	MaxPerDomain int
+	// ExcludeDomains are the domains whose sources are dropped along with
+	// their markers. A domain also excludes its subdomains.
+	ExcludeDomains []string
+}
+
+// IsZero reports whether f keeps every source as is.
+func (f Filter) IsZero() bool {
+	return f.MaxPerDomain <= 0 && len(f.ExcludeDomains) == 0
+}
+
+// Plan is the outcome of a Filter on a list of sources: the sources kept
+// and the new number of every marker.
+type Plan struct {
+	// Sources are the kept sources in their original order, numbered from 1
+	// as before. Extra holds the URLs grouped beneath each.
+	Sources []Source
+	// kept holds the original index of each of Sources.
+	kept []int
+	// numbers maps marker n to numbers[n-1], its new number; 0 drops it.
+	numbers []int
+}
+
+// Plan returns what f does to sources. Sources without a URL are kept as is.
+func (f Filter) Plan(sources []Source) *Plan {
+	p := &Plan{numbers: make([]int, len(sources))}
+	count := make(map[string]int)
+	last := make(map[string]int)
+	for i, src := range sources {
+		domain := Domain(src.URL)
+		if domain != "" && f.excludes(domain) {
+			continue
+		}
+		if domain != "" && f.MaxPerDomain > 0 && count[domain] >= f.MaxPerDomain {
+			j := last[domain]
+			p.Sources[j].Extra = append(p.Sources[j].Extra, src.URL)
+			p.Sources[j].Extra = append(p.Sources[j].Extra, src.Extra...)
+			p.numbers[i] = j + 1
+			continue
+		}
+		count[domain]++
+		src.Extra = slices.Clone(src.Extra)
+		p.Sources = append(p.Sources, src)
+		p.kept = append(p.kept, i)
+		last[domain] = len(p.Sources) - 1
+		p.numbers[i] = len(p.Sources)
+	}
+	return p
+}
+
+// excludes reports whether domain is, or is a subdomain of, an excluded domain.
+func (f Filter) excludes(domain string) bool {
+	for _, excluded := range f.ExcludeDomains {
+		excluded = normalizeDomain(excluded)
+		if excluded != "" && (domain == excluded || strings.HasSuffix(domain, "."+excluded)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Renumber rewrites the markers of content for the sources of p. A marker of
+// a dropped source is removed along with the spaces before it, and a marker
+// repeating one of the markers it directly follows, as in [2][2] once two
+// sources are grouped, is removed. Markers without a source are kept.
+func (p *Plan) Renumber(content string) string {
+	var run []int
+	return replaceRuns(content, func(n int, marker string, follows bool) (string, bool) {
+		if !follows {
+			run = run[:0]
+		}
+		if n < 1 || n > len(p.numbers) {
+			return marker, false
+		}
+		m := p.numbers[n-1]
+		if m == 0 {
+			return "", true
+		}
+		if slices.Contains(run, m) {
+			return "", false
+		}
+		run = append(run, m)
+		return "[" + strconv.Itoa(m) + "]", false
+	})
+}
+
+// Apply filters resp in place: its search results, and its deprecated
+// citations list when it matches them, keep the sources of p, and the
+// markers of every choice are renumbered. The URLs grouped beneath each
+// source are recorded under the response ID, where FromResponse finds them.
+// p must be the plan of the sources of resp.
+func (p *Plan) Apply(resp *perplexity.CompletionResponse) {
+	if resp == nil {
+		return
+	}
+	for i := range resp.Choices {
+		resp.Choices[i].Message.Content = p.Renumber(resp.Choices[i].Message.Content)
+		resp.Choices[i].Delta.Content = p.Renumber(resp.Choices[i].Delta.Content)
+	}
+	if resp.SearchResults != nil && len(*resp.SearchResults) == len(p.numbers) {
+		resp.SearchResults = keep(*resp.SearchResults, p.kept)
+	}
+	//nolint:staticcheck // the deprecated list is filtered like the search results
+	if resp.Citations != nil && len(*resp.Citations) == len(p.numbers) {
+		resp.Citations = keep(*resp.Citations, p.kept) //nolint:staticcheck // see above
+	}
+
+	urls := make([][]string, len(p.Sources))
+	hasGrouped := false
+	for i, src := range p.Sources {
+		urls[i] = src.Extra
+		hasGrouped = hasGrouped || len(src.Extra) > 0
+	}
+	if hasGrouped {
+		recordGrouped(resp.ID, urls)
+	}
+}
+
+// Apply filters the sources of resp and renumbers its markers; see
+// Plan.Apply. A zero f leaves resp unchanged.
+func (f Filter) Apply(resp *perplexity.CompletionResponse) {
+	if f.IsZero() || resp == nil {
+		return
+	}
+	f.Plan(FromResponse(resp)).Apply(resp)
+}
+
+// keep returns a pointer to the elements of items at indexes.
+func keep[T any](items []T, indexes []int) *[]T {
+	kept := make([]T, 0, len(indexes))
+	for _, i := range indexes {
+		kept = append(kept, items[i])
+	}
+	return &kept
+}
+
+// Domain returns the host of rawURL in lower case without a leading "www.",
+// the key sources are grouped by, or "" when rawURL has no host.
+func Domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return normalizeDomain(u.Hostname())
+}
+
+// normalizeDomain lowers domain and strips a leading "www." and trailing dot.
+func normalizeDomain(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	return strings.TrimPrefix(domain, "www.")
+}
+
+// groupedRegistry maps response IDs to the URLs grouped beneath each of
+// their sources.
+type groupedRegistry struct {
+	mu    sync.Mutex
+	urls  map[string][][]string
+	order []string
+}
+
+//nolint:gochecknoglobals // shared by every response rendered in the run
+var grouped = &groupedRegistry{urls: make(map[string][][]string)}
+
+// recordGrouped stores the grouped URLs of the response id. Empty ids are ignored.
+func recordGrouped(id string, urls [][]string) {
+	if id == "" {
+		return
+	}
+	grouped.mu.Lock()
+	defer grouped.mu.Unlock()
+	if _, ok := grouped.urls[id]; !ok {
+		grouped.order = append(grouped.order, id)
+	}
+	grouped.urls[id] = urls
+	for len(grouped.order) > maxGrouped {
+		delete(grouped.urls, grouped.order[0])
+		grouped.order = grouped.order[1:]
+	}
+}
+
+// Grouped returns the URLs grouped beneath each source of the response id
+// by a Filter, in source order, or nil when none were.
+func Grouped(id string) [][]string {
+	grouped.mu.Lock()
+	defer grouped.mu.Unlock()
+	urls := grouped.urls[id]
+	out := make([][]string, len(urls))
+	for i, u := range urls {
+		out[i] = slices.Clone(u)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
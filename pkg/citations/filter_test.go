@@ -0,0 +1,273 @@
+package citations
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+// filterSources has three more sources from a.example after the first, one
+// written with www., and one from an excluded domain.
+var filterSources = []Source{
+	{Title: "A1", URL: "https://a.example/1"},
+	{Title: "B", URL: "https://b.example"},
+	{Title: "A2", URL: "https://a.example/2"},
+	{Title: "Spam", URL: "https://news.spam.example/x"},
+	{Title: "A3", URL: "https://A.example/3"},
+	{Title: "A4", URL: "https://www.a.example/4"},
+}
+
+func TestPlan_Renumber(t *testing.T) {
+	onePerDomain := Filter{MaxPerDomain: 1, ExcludeDomains: []string{"spam.example"}}
+	tests := []struct {
+		name    string
+		filter  Filter
+		content string
+		want    string
+	}{
+		{
+			name:    "grouped marker points to the kept entry",
+			filter:  onePerDomain,
+			content: "A [1] and B [2] and A again [3].",
+			want:    "A [1] and B [2] and A again [1].",
+		},
+		{
+			name:    "interleaved markers",
+			filter:  onePerDomain,
+			content: "a [3] b [2] c [5] d [4] e [6] f [1]",
+			want:    "a [1] b [2] c [1] d e [1] f [1]",
+		},
+		{
+			name:    "adjacent duplicates collapse",
+			filter:  onePerDomain,
+			content: "Both agree[1][3][5].",
+			want:    "Both agree[1].",
+		},
+		{
+			name:    "adjacent run keeps distinct markers in order",
+			filter:  onePerDomain,
+			content: "See [3][2][6].",
+			want:    "See [1][2].",
+		},
+		{
+			name:    "repeated markers apart are kept",
+			filter:  onePerDomain,
+			content: "First [3], again [5], and [3].",
+			want:    "First [1], again [1], and [1].",
+		},
+		{
+			name:    "excluded marker removed with its space",
+			filter:  onePerDomain,
+			content: "Spam says so [4].",
+			want:    "Spam says so.",
+		},
+		{
+			name:    "excluded marker before a kept one keeps the space",
+			filter:  onePerDomain,
+			content: "Spam and B [4][2].",
+			want:    "Spam and B [2].",
+		},
+		{
+			name:    "excluded marker inside a run",
+			filter:  onePerDomain,
+			content: "All [2][4][2][1].",
+			want:    "All [2][1].",
+		},
+		{
+			name:    "out of range kept",
+			filter:  onePerDomain,
+			content: "Unknown [9] and [0].",
+			want:    "Unknown [9] and [0].",
+		},
+		{
+			name:    "code and links kept",
+			filter:  onePerDomain,
+			content: "Use `m[3]` and [3](https://x.example) and [docs][4], not [3].",
+			want:    "Use `m[3]` and [3](https://x.example) and [docs][4], not [1].",
+		},
+		{
+			name:    "two per domain",
+			filter:  Filter{MaxPerDomain: 2},
+			content: "a [1] b [3] c [4] d [5] e [6][3]",
+			want:    "a [1] b [3] c [4] d [3] e [3]",
+		},
+		{
+			name:    "exclusion only shifts later markers",
+			filter:  Filter{ExcludeDomains: []string{"B.example."}},
+			content: "a [1] b [2] c [3] d [6]",
+			want:    "a [1] b c [2] d [5]",
+		},
+		{
+			name:    "zero filter keeps everything",
+			filter:  Filter{},
+			content: "a [3][5] b [4]",
+			want:    "a [3][5] b [4]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Plan(filterSources).Renumber(tt.content); got != tt.want {
+				t.Errorf("Renumber() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Plan(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []Source
+	}{
+		{
+			name:   "one per domain",
+			filter: Filter{MaxPerDomain: 1, ExcludeDomains: []string{"spam.example"}},
+			want: []Source{
+				{Title: "A1", URL: "https://a.example/1", Extra: []string{
+					"https://a.example/2", "https://A.example/3", "https://www.a.example/4",
+				}},
+				{Title: "B", URL: "https://b.example"},
+			},
+		},
+		{
+			name:   "two per domain",
+			filter: Filter{MaxPerDomain: 2},
+			want: []Source{
+				{Title: "A1", URL: "https://a.example/1"},
+				{Title: "B", URL: "https://b.example"},
+				{Title: "A2", URL: "https://a.example/2", Extra: []string{
+					"https://A.example/3", "https://www.a.example/4",
+				}},
+				{Title: "Spam", URL: "https://news.spam.example/x"},
+			},
+		},
+		{
+			name:   "exclusion does not match other domains ending alike",
+			filter: Filter{ExcludeDomains: []string{"m.example", "a.example"}},
+			want: []Source{
+				{Title: "B", URL: "https://b.example"},
+				{Title: "Spam", URL: "https://news.spam.example/x"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Plan(filterSources).Sources; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Plan().Sources = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_PlanKeepsSourcesWithoutURL(t *testing.T) {
+	sources := []Source{{Title: "no url"}, {Title: "no url either"}, {URL: "not a url\x7f"}}
+	plan := Filter{MaxPerDomain: 1}.Plan(sources)
+	if !reflect.DeepEqual(plan.Sources, sources) {
+		t.Errorf("Plan().Sources = %+v, want %+v", plan.Sources, sources)
+	}
+}
+
+// filterResponse returns a response citing filterSources under id.
+func filterResponse(id, content string) *perplexity.CompletionResponse {
+	results := make([]perplexity.SearchResult, len(filterSources))
+	urls := make([]string, len(filterSources))
+	for i, src := range filterSources {
+		results[i] = perplexity.SearchResult{Title: src.Title, URL: src.URL}
+		urls[i] = src.URL
+	}
+	return &perplexity.CompletionResponse{
+		ID: id,
+		Choices: []perplexity.Choice{
+			{Message: perplexity.Message{Role: "assistant", Content: content}},
+			{Delta: perplexity.Message{Content: content}},
+		},
+		SearchResults: &results,
+		Citations:     &urls,
+	}
+}
+
+func TestFilter_Apply(t *testing.T) {
+	filter := Filter{MaxPerDomain: 1, ExcludeDomains: []string{"spam.example"}}
+	resp := filterResponse("filter-apply", "A [3] B [2] spam [4][1].")
+	filter.Apply(resp)
+
+	const want = "A [1] B [2] spam [1]."
+	if got := resp.Choices[0].Message.Content; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+	if got := resp.Choices[1].Delta.Content; got != want {
+		t.Errorf("delta = %q, want %q", got, want)
+	}
+	wantResults := []perplexity.SearchResult{
+		{Title: "A1", URL: "https://a.example/1"},
+		{Title: "B", URL: "https://b.example"},
+	}
+	if got := resp.GetSearchResults(); !reflect.DeepEqual(got, wantResults) {
+		t.Errorf("search results = %+v, want %+v", got, wantResults)
+	}
+	//nolint:staticcheck // the deprecated list is filtered too
+	if got := *resp.Citations; !reflect.DeepEqual(got, []string{"https://a.example/1", "https://b.example"}) {
+		t.Errorf("citations = %v", got)
+	}
+	wantGrouped := [][]string{{"https://a.example/2", "https://A.example/3", "https://www.a.example/4"}, nil}
+	if got := Grouped("filter-apply"); !reflect.DeepEqual(got, wantGrouped) {
+		t.Errorf("Grouped() = %v, want %v", got, wantGrouped)
+	}
+	if got := FromResponse(resp)[0].Extra; !reflect.DeepEqual(got, wantGrouped[0]) {
+		t.Errorf("FromResponse() extra = %v, want %v", got, wantGrouped[0])
+	}
+
+	// A filtered response, replayed from the cache for instance, is left as is.
+	filter.Apply(resp)
+	if got := resp.Choices[0].Message.Content; got != want {
+		t.Errorf("second Apply() message = %q, want %q", got, want)
+	}
+	if got := len(resp.GetSearchResults()); got != 2 {
+		t.Errorf("second Apply() left %d search results, want 2", got)
+	}
+	if got := Grouped("filter-apply"); !reflect.DeepEqual(got, wantGrouped) {
+		t.Errorf("second Apply() Grouped() = %v, want %v", got, wantGrouped)
+	}
+}
+
+func TestFilter_ApplyZero(t *testing.T) {
+	resp := filterResponse("filter-zero", "A [3][3].")
+	Filter{}.Apply(resp)
+	if got := resp.Choices[0].Message.Content; got != "A [3][3]." {
+		t.Errorf("message = %q, want it unchanged", got)
+	}
+	if got := len(resp.GetSearchResults()); got != len(filterSources) {
+		t.Errorf("%d search results, want %d", got, len(filterSources))
+	}
+	if Grouped("filter-zero") != nil {
+		t.Error("a zero filter should record no grouped URLs")
+	}
+	Filter{MaxPerDomain: 1}.Apply(nil)
+}
+
+func TestGrouped_Bounded(t *testing.T) {
+	recordGrouped("", [][]string{{"ignored"}})
+	if Grouped("") != nil {
+		t.Error("an empty id should not be recorded")
+	}
+	recordGrouped("oldest", [][]string{{"u"}})
+	for i := range maxGrouped {
+		recordGrouped("bounded-"+string(rune('a'+i%26))+string(rune('a'+i/26)), [][]string{{"u"}})
+	}
+	if Grouped("oldest") != nil {
+		t.Error("the oldest response should be forgotten")
+	}
+}
+
+func TestFootnotes_Extra(t *testing.T) {
+	sources := []Source{{Title: "A", URL: "https://a.example/1", Extra: []string{"https://a.example/2"}}}
+	want := referencesHeading + "\n\n- [1] A - https://a.example/1\n    - https://a.example/2\n"
+	if got := Footnotes("A [1].", sources); got != want {
+		t.Errorf("Footnotes() = %q, want %q", got, want)
+	}
+	wantMD := "A [^1].\n\n[^1]: A - https://a.example/1\n    https://a.example/2\n"
+	if got := MarkdownFootnotes("A [1].", sources, 0); got != wantMD {
+		t.Errorf("MarkdownFootnotes() = %q, want %q", got, wantMD)
+	}
+}
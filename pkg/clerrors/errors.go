@@ -12,6 +12,10 @@ type ValidationError struct {
 	Field   string
 	Value   string
 	Message string
+	// Rule is the constraint the value breaks, when one is documented.
+	Rule string
+	// Err is the error the check failed with, when there is one.
+	Err error
 }
 
 // NewValidationError creates a new validation error.
@@ -46,6 +50,11 @@ func (e *ValidationError) Error() string {
 	return fmt.Sprintf("validation failed for %s=%s: %s", e.Field, safeValue, e.Message)
 }
 
+// Unwrap returns the wrapped error.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
 // APIError represents an error from the Perplexity API.
 type APIError struct {
 	StatusCode int
@@ -128,6 +137,31 @@ func (e *IOError) Unwrap() error {
 	return e.Err
 }
 
+// BudgetExceededError reports a request refused because its projected cost
+// would take the day's estimated spending over the daily budget.
+type BudgetExceededError struct {
+	// Budget is the daily budget in USD.
+	Budget float64
+	// Spent is the estimated cost of the requests sent today.
+	Spent float64
+	// Projected is the highest expected cost of the refused request.
+	Projected float64
+}
+
+// NewBudgetExceededError creates a new budget exceeded error.
+func NewBudgetExceededError(budget, spent, projected float64) *BudgetExceededError {
+	return &BudgetExceededError{
+		Budget:    budget,
+		Spent:     spent,
+		Projected: projected,
+	}
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("daily budget exceeded: $%.4f spent today plus $%.4f projected for this request "+
+		"is over the budget of $%.2f", e.Spent, e.Projected, e.Budget)
+}
+
 // ValidationErrors is a collection of validation errors.
 // It allows multiple validation errors to be collected and returned together,
 // providing comprehensive validation feedback in a single error.
@@ -197,6 +197,20 @@ func TestIOErrorUnwrap(t *testing.T) {
 	}
 }
 
+func TestBudgetExceededError(t *testing.T) {
+	err := NewBudgetExceededError(1, 0.98, 0.0315)
+	want := "daily budget exceeded: $0.9800 spent today plus $0.0315 projected for this request " +
+		"is over the budget of $1.00"
+	if err.Error() != want {
+		t.Errorf("BudgetExceededError.Error() = %q, want %q", err.Error(), want)
+	}
+
+	var budgetErr *BudgetExceededError
+	if !errors.As(fmt.Errorf("query refused: %w", err), &budgetErr) || budgetErr.Spent != 0.98 {
+		t.Errorf("errors.As should find BudgetExceededError, got %+v", budgetErr)
+	}
+}
+
 func TestErrorsAs(t *testing.T) {
 	// Test that errors.As works correctly with our custom error types
 	t.Run("ValidationError with errors.As", func(t *testing.T) {
@@ -0,0 +1,166 @@
+package clerrors
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/i18n"
+)
+
+// Categories of the failures Explain recognizes.
+const (
+	CategoryAuth          = "auth"
+	CategoryRateLimit     = "rate_limit"
+	CategoryInvalidModel  = "invalid_model"
+	CategoryContextLength = "context_length"
+	CategoryNetwork       = "network"
+	CategoryInvalidSchema = "invalid_schema"
+)
+
+// Explanation describes a failure in plain words, with the steps most
+// likely to fix it.
+type Explanation struct {
+	Category    string   `json:"category"`
+	Explanation string   `json:"explanation"`
+	Hints       []string `json:"hints"`
+}
+
+// explainer recognizes one category of failure. Its explanation is the
+// message "explain.<category>" of the i18n catalog, and its hints the
+// messages "explain.<category>.hint.1", "explain.<category>.hint.2", and so
+// on.
+type explainer struct {
+	category string
+	// statuses are the HTTP statuses of the category; any of them matches.
+	statuses []int
+	// sentinels match with errors.Is.
+	sentinels []error
+	// phrases match the lower-cased error message.
+	phrases []string
+}
+
+// explainers are tried in order; the first match wins. Specific API
+// complaints come before the generic network failures so that a 400 about
+// the model is not reported as a connection problem.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var explainers = []explainer{
+	{
+		category:  CategoryAuth,
+		statuses:  []int{http.StatusUnauthorized, http.StatusForbidden},
+		sentinels: []error{perplexity.ErrUnauthorized},
+		phrases:   []string{"unauthorized", "forbidden", "invalid api key"},
+	},
+	{
+		category: CategoryRateLimit,
+		statuses: []int{http.StatusTooManyRequests},
+		phrases:  []string{"rate limit", "too many requests"},
+	},
+	{
+		category:  CategoryInvalidModel,
+		sentinels: []error{ErrUnknownModel},
+		phrases:   []string{"invalid_model", "invalid model", "model not found"},
+	},
+	{
+		category: CategoryContextLength,
+		phrases:  []string{"context length", "context_length", "context window", "maximum context"},
+	},
+	{
+		category:  CategoryInvalidSchema,
+		sentinels: []error{ErrResponseFormatNotSupported},
+		phrases:   []string{"invalid json schema", "json_schema", "invalid schema"},
+	},
+	{
+		category: CategoryNetwork,
+		phrases: []string{
+			"connection refused", "no such host", "network is unreachable", "connection reset",
+			"timeout", "deadline exceeded", "tls handshake",
+		},
+	},
+}
+
+// Explain classifies err into one of the common failure categories and
+// returns its explanation. ok is false when err matches none of them.
+func Explain(err error) (Explanation, bool) {
+	if err == nil {
+		return Explanation{}, false
+	}
+	status := StatusCode(err)
+	message := strings.ToLower(err.Error())
+	for _, e := range explainers {
+		if e.matches(err, status, message) {
+			return Explanation{
+				Category:    e.category,
+				Explanation: i18n.T("explain." + e.category),
+				Hints:       explainHints(e.category),
+			}, true
+		}
+	}
+	return Explanation{}, false
+}
+
+// explainHints returns the hints of category in the selected language.
+func explainHints(category string) []string {
+	var hints []string
+	for n := 1; ; n++ {
+		hint, ok := i18n.Lookup("explain." + category + ".hint." + strconv.Itoa(n))
+		if !ok {
+			return hints
+		}
+		hints = append(hints, hint)
+	}
+}
+
+// matches reports whether the failure err, with HTTP status status and the
+// lower-cased message, belongs to the category of e.
+func (e explainer) matches(err error, status int, message string) bool {
+	for _, s := range e.statuses {
+		if status == s {
+			return true
+		}
+	}
+	for _, sentinel := range e.sentinels {
+		if errors.Is(err, sentinel) {
+			return true
+		}
+	}
+	for _, phrase := range e.phrases {
+		if strings.Contains(message, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusCode returns the first HTTP status recorded in the chain of err, or
+// 0 when there is none. Both APIError and the client's
+// perplexity.ResponseError are inspected; errors.As is not enough because a
+// wrapping APIError often has no status of its own.
+func StatusCode(err error) int {
+	switch e := err.(type) { //nolint:errorlint // the chain is walked below
+	case nil:
+		return 0
+	case *APIError:
+		if e.StatusCode > 0 {
+			return e.StatusCode
+		}
+	case *perplexity.ResponseError:
+		if e.ErrorData.Code > 0 {
+			return e.ErrorData.Code
+		}
+	}
+	switch u := err.(type) { //nolint:errorlint // the chain is walked by hand
+	case interface{ Unwrap() error }:
+		return StatusCode(u.Unwrap())
+	case interface{ Unwrap() []error }:
+		for _, inner := range u.Unwrap() {
+			if code := StatusCode(inner); code > 0 {
+				return code
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package clerrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/i18n"
+)
+
+// apiResponseError parses body as the client does for a failed request.
+func apiResponseError(t *testing.T, body string) error {
+	t.Helper()
+	err := perplexity.ParseErrorMessage([]byte(body))
+	var respErr *perplexity.ResponseError
+	if !errors.As(err, &respErr) {
+		t.Fatalf("fixture %s is not an API error body: %v", body, err)
+	}
+	return err
+}
+
+func TestExplain(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"client unauthorized", fmt.Errorf("error sending completion request: %w", perplexity.ErrUnauthorized),
+			CategoryAuth},
+		{"forbidden status", NewAPIError("failed to send completion request",
+			&APIError{StatusCode: 403, Message: "Forbidden"}), CategoryAuth},
+		{"forbidden body", apiResponseError(t,
+			`{"error":{"message":"Your API key does not have access","type":"forbidden","code":403}}`), CategoryAuth},
+		{"rate limit after retries", fmt.Errorf("%w after %d attempts: %w", ErrRetriesExhausted, 3,
+			&APIError{StatusCode: 429, Message: "Too Many Requests"}), CategoryRateLimit},
+		{"invalid model body", NewAPIError("failed to send completion request", apiResponseError(t,
+			`{"error":{"message":"Invalid model 'sonr'. Permitted models can be found in the documentation.",`+
+				`"type":"invalid_model","code":400}}`)), CategoryInvalidModel},
+		{"unknown model", fmt.Errorf("%w: sonr", ErrUnknownModel), CategoryInvalidModel},
+		{"context length", NewAPIError("failed to send completion request", apiResponseError(t,
+			`{"error":{"message":"This model's maximum context length is 127072 tokens.",`+
+				`"type":"invalid_request_error","code":400}}`)), CategoryContextLength},
+		{"invalid schema body", apiResponseError(t,
+			`{"error":{"message":"Invalid json_schema: schema must be an object","type":"bad_request","code":400}}`),
+			CategoryInvalidSchema},
+		{"schema not JSON", fmt.Errorf("invalid JSON schema: %w", errors.New("unexpected end of JSON input")),
+			CategoryInvalidSchema},
+		{"format not supported", ErrResponseFormatNotSupported, CategoryInvalidSchema},
+		{"connection refused", NewAPIError("failed to send completion request",
+			fmt.Errorf("failed to send request: %w", refused)), CategoryNetwork},
+		{"deadline", fmt.Errorf("failed to send request: %w", context.DeadlineExceeded), CategoryNetwork},
+		{"no such host", errors.New("dial tcp: lookup api.perplexity.ai: no such host"), CategoryNetwork},
+		{"server error", &APIError{StatusCode: 500, Message: "Internal Server Error"}, ""},
+		{"validation", NewValidationError("temperature", "3", "must be between 0 and 2"), ""},
+		{"nil", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Explain(tt.err)
+			if got.Category != tt.want || ok != (tt.want != "") {
+				t.Fatalf("Explain(%v) = %q, %v; want %q", tt.err, got.Category, ok, tt.want)
+			}
+			if ok && (got.Explanation == "" || len(got.Hints) == 0) {
+				t.Errorf("Explain(%v) = %+v, want an explanation and hints", tt.err, got)
+			}
+		})
+	}
+}
+
+func TestExplain_HintsAreCopies(t *testing.T) {
+	first, _ := Explain(perplexity.ErrUnauthorized)
+	first.Hints[0] = "changed"
+	second, _ := Explain(perplexity.ErrUnauthorized)
+	if second.Hints[0] == "changed" {
+		t.Error("Explain() returned hints shared with the lookup table")
+	}
+}
+
+func TestExplain_Translated(t *testing.T) {
+	i18n.SetLanguage("fr")
+	t.Cleanup(func() { i18n.SetLanguage(i18n.English) })
+
+	got, ok := Explain(perplexity.ErrUnauthorized)
+	if !ok || got.Category != CategoryAuth {
+		t.Fatalf("Explain() = %+v, %v, want the auth category", got, ok)
+	}
+	if want := "L'API a refusé la clé API"; !strings.HasPrefix(got.Explanation, want) {
+		t.Errorf("Explanation = %q, want the French message", got.Explanation)
+	}
+	if len(got.Hints) != 2 || got.Hints[1] != i18n.T("explain.auth.hint.2") {
+		t.Errorf("Hints = %q, want the two French hints", got.Hints)
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, 0},
+		{"api error", &APIError{StatusCode: 429}, 429},
+		{"wrapped without status", NewAPIError("failed", &APIError{StatusCode: 401}), 401},
+		{"response error", fmt.Errorf("x: %w", &perplexity.ResponseError{}), 0},
+		{"joined", errors.Join(errors.New("a"), &APIError{StatusCode: 503}), 503},
+		{"plain", errors.New("boom"), 0},
+	}
+	for _, tt := range tests {
+		if got := StatusCode(tt.err); got != tt.want {
+			t.Errorf("%s: StatusCode() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
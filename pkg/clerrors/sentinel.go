@@ -21,6 +21,42 @@ var (
 
 	// ErrUnknownSection is returned when an unknown configuration section is referenced.
 	ErrUnknownSection = errors.New("unknown section")
+
+	// ErrConfigNotMapping is returned when a config file's top level is not a YAML mapping.
+	ErrConfigNotMapping = errors.New("config file top level is not a mapping")
+
+	// ErrKeyringUnavailable is returned when the platform has no usable system keyring.
+	ErrKeyringUnavailable = errors.New("system keyring is not available")
+
+	// ErrKeyringKeyNotFound is returned when api.key is "keyring" but no key is stored there.
+	ErrKeyringKeyNotFound = errors.New("no API key stored in the system keyring")
+
+	// ErrPassphraseRequired is returned when api.key is encrypted and no passphrase is available.
+	ErrPassphraseRequired = errors.New("passphrase required to decrypt api.key")
+
+	// ErrWrongPassphrase is returned when an encrypted api.key fails its integrity check.
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted encrypted api.key")
+
+	// ErrInvalidEncryptedKey is returned when an encrypted api.key value is malformed.
+	ErrInvalidEncryptedKey = errors.New("malformed encrypted api.key")
+
+	// ErrConfigVersionTooNew is returned when a config file was written by a newer pplx.
+	ErrConfigVersionTooNew = errors.New("config file version is newer than supported")
+
+	// ErrIncludeNotFound is returned when a file listed under include: does not exist.
+	ErrIncludeNotFound = errors.New("included config file not found")
+
+	// ErrIncludeCycle is returned when config files include each other.
+	ErrIncludeCycle = errors.New("config include cycle")
+
+	// ErrIncludeInvalid is returned when include: is not a list of file paths.
+	ErrIncludeInvalid = errors.New("include must be a list of file paths")
+
+	// ErrBackupNotFound is returned when config restore names a backup that does not exist.
+	ErrBackupNotFound = errors.New("config backup not found")
+
+	// ErrInvalidBackup is returned when a config backup fails to parse or validate.
+	ErrInvalidBackup = errors.New("config backup is invalid")
 )
 
 // Profile errors relate to profile management operations.
@@ -45,6 +81,21 @@ var (
 
 	// ErrImportReservedName is returned when attempting to import a profile with a reserved name.
 	ErrImportReservedName = errors.New("cannot import profile with reserved name 'default'")
+
+	// ErrProfileCycle is returned when profile "extends" references form a cycle.
+	ErrProfileCycle = errors.New("profile inheritance cycle")
+
+	// ErrProfileChainTooDeep is returned when a profile "extends" chain exceeds the maximum depth.
+	ErrProfileChainTooDeep = errors.New("profile inheritance chain too deep")
+
+	// ErrProfileInUse is returned when deleting a profile that other profiles extend.
+	ErrProfileInUse = errors.New("profile is extended by other profiles")
+
+	// ErrProfileNameInvalid is returned when a profile name contains whitespace.
+	ErrProfileNameInvalid = errors.New("profile name must not contain whitespace")
+
+	// ErrDeleteActiveProfile is returned when deleting the active profile without forcing.
+	ErrDeleteActiveProfile = errors.New("cannot delete the active profile")
 )
 
 // Template errors relate to configuration template operations.
@@ -56,6 +107,21 @@ var (
 	ErrTemplateInvalid = errors.New("template is invalid")
 )
 
+// Prompt template errors relate to the prompts section and --prompt-template.
+var (
+	// ErrPromptNotFound is returned when a requested prompt template does not exist.
+	ErrPromptNotFound = errors.New("prompt template not found")
+
+	// ErrPromptInvalid is returned when a prompt template cannot be parsed.
+	ErrPromptInvalid = errors.New("prompt template is invalid")
+
+	// ErrMissingPromptVariables is returned when a prompt template is rendered without all its variables.
+	ErrMissingPromptVariables = errors.New("missing prompt template variables")
+
+	// ErrInvalidPromptVariable is returned when a --var value is not of the form key=value.
+	ErrInvalidPromptVariable = errors.New("invalid prompt variable")
+)
+
 // Metadata errors relate to configuration option metadata operations.
 var (
 	// ErrOptionNotFound is returned when a configuration option is not found.
@@ -89,6 +155,10 @@ var (
 	ErrResponseFormatNotSupported = errors.New(
 		"response formats (JSON schema and regex) are only supported by sonar models")
 
+	// ErrInvalidJSONSchema is returned when a response format JSON schema is not valid JSON
+	// or not a valid schema.
+	ErrInvalidJSONSchema = errors.New("invalid JSON schema")
+
 	// ErrInvalidSearchMode is returned when an invalid search mode is provided.
 	ErrInvalidSearchMode = errors.New("invalid search mode")
 
@@ -109,12 +179,135 @@ var (
 
 	// ErrInvalidReasoningEffort is returned when an invalid reasoning effort level is provided.
 	ErrInvalidReasoningEffort = errors.New("invalid reasoning effort")
+
+	// ErrInvalidDate is returned when a date filter matches none of the accepted date formats.
+	ErrInvalidDate = errors.New("invalid date format")
+
+	// ErrInvalidLanguage is returned when an answer language is not a known ISO 639-1 code.
+	ErrInvalidLanguage = errors.New("invalid language")
+
+	// ErrInvalidCountry is returned when a location country is not an ISO 3166-1 alpha-2 code.
+	ErrInvalidCountry = errors.New("invalid country code")
+
+	// ErrUnknownChatCommand is returned when a chat slash command is not recognized.
+	ErrUnknownChatCommand = errors.New("unknown chat command")
+
+	// ErrChatCommandUsage is returned when a chat slash command is missing a required argument.
+	ErrChatCommandUsage = errors.New("invalid chat command usage")
+
+	// ErrNothingToRetry is returned by /retry before any message has been sent.
+	ErrNothingToRetry = errors.New("no user message to retry")
+
+	// ErrRewindPastStart is returned by /rewind for more exchanges than the conversation has.
+	ErrRewindPastStart = errors.New("cannot rewind past the start of the conversation")
+
+	// ErrInvalidTranscriptFormat is returned for an unknown chat transcript export format.
+	ErrInvalidTranscriptFormat = errors.New("invalid transcript format")
+
+	// ErrInvalidDomain is returned for a domain filter entry with a scheme, path, or other non-host text.
+	ErrInvalidDomain = errors.New("invalid domain")
+
+	// ErrDomainConflict is returned when a domain is both included and excluded.
+	ErrDomainConflict = errors.New("domain both included and excluded")
+
+	// ErrImageRecencyConflict is returned when search recency and images are both requested
+	// under the "error" image conflict policy.
+	ErrImageRecencyConflict = errors.New("search recency cannot be combined with images")
+
+	// ErrStreamResponseFormat is returned when a streamed request asks for a
+	// JSON schema or regex response format with output.strict_options set.
+	ErrStreamResponseFormat = errors.New("streaming cannot be combined with a response format")
+
+	// ErrInvalidImageConflictPolicy is returned for an unknown image conflict policy.
+	ErrInvalidImageConflictPolicy = errors.New("invalid image conflict policy")
+
+	// ErrUnknownModel is returned for a model missing from the model registry when --strict-model is set.
+	ErrUnknownModel = errors.New("unknown model")
+
+	// ErrInvalidMaxTokens is returned for a --max-tokens value that is neither a number of tokens nor max.
+	ErrInvalidMaxTokens = errors.New("invalid max_tokens")
+
+	// ErrMaxTokensExceeded is returned when max_tokens is above the output limit of the model.
+	ErrMaxTokensExceeded = errors.New("max_tokens exceeds the output limit of the model")
+)
+
+// API errors relate to calls made against the Perplexity API.
+var (
+	// ErrRetriesExhausted is returned when a retryable API error persists after all retry attempts.
+	ErrRetriesExhausted = errors.New("retries exhausted")
+
+	// ErrRequestAborted wraps the error of a request hook that stopped a request before it was sent.
+	ErrRequestAborted = errors.New("request aborted by hook")
+
+	// ErrNoResponse is returned when a streamed request ends without any event.
+	ErrNoResponse = errors.New("no response received")
+
+	// ErrStreamStalled is returned when a streamed answer sends nothing for
+	// longer than the idle timeout of the request.
+	ErrStreamStalled = errors.New("stream stalled")
+
+	// ErrNoChoices is returned when a response contains no choices, as from a
+	// gateway returning a truncated body.
+	ErrNoChoices = errors.New("response contains no choices")
+
+	// ErrBlankAnswer is returned when the answer of a response is blank.
+	ErrBlankAnswer = errors.New("response answer is blank")
+)
+
+// MCP errors relate to the MCP server, its background research jobs and
+// its chat sessions.
+var (
+	// ErrJobNotFound is returned when a research job ID is unknown or has expired.
+	ErrJobNotFound = errors.New("research job not found")
+
+	// ErrTooManyJobs is returned when starting a research job would exceed the concurrent job limit.
+	ErrTooManyJobs = errors.New("too many concurrent research jobs")
+
+	// ErrSessionNotFound is returned when a chat session ID is unknown, deleted, or has expired.
+	ErrSessionNotFound = errors.New("chat session not found")
+
+	// ErrTooManySessions is returned when creating a chat session would exceed the session limit.
+	ErrTooManySessions = errors.New("too many chat sessions")
+)
+
+// Usage errors relate to the usage log and the usage command.
+var (
+	// ErrInvalidGroupBy is returned when --group-by is not a supported grouping.
+	ErrInvalidGroupBy = errors.New("invalid group-by value")
+
+	// ErrInvalidSince is returned when --since is neither a date nor a duration.
+	ErrInvalidSince = errors.New("invalid since value")
+
+	// ErrInvalidUsageFormat is returned when usage export --format is not a supported format.
+	ErrInvalidUsageFormat = errors.New("invalid usage export format")
+)
+
+// Batch errors relate to batch query mode.
+var (
+	// ErrInvalidBatchLine is returned when a batch file line is not a valid prompt object.
+	ErrInvalidBatchLine = errors.New("invalid batch line")
+
+	// ErrBatchValidationFailed is returned by a batch dry run when one or more lines are invalid.
+	ErrBatchValidationFailed = errors.New("batch validation failed")
+
+	// ErrBatchInterrupted is returned when a batch run is stopped before every line completed.
+	ErrBatchInterrupted = errors.New("batch run interrupted")
+
+	// ErrRepeatInterrupted is returned when --repeat runs are cancelled before every run completed.
+	ErrRepeatInterrupted = errors.New("repeated runs interrupted")
+
+	// ErrRequestInterrupted is returned when a request is cancelled by Ctrl-C
+	// before its answer completed.
+	ErrRequestInterrupted = errors.New("request interrupted")
 )
 
 // Doctor errors relate to the config doctor command.
 var (
 	// ErrHealthChecksFailed is returned when one or more health checks fail.
 	ErrHealthChecksFailed = errors.New("health checks failed")
+	// ErrModelsInaccessible is returned by models check when a probed model
+	// could not be used.
+	ErrModelsInaccessible = errors.New("models not accessible")
 )
 
 // Command errors relate to CLI command execution and parameter validation.
@@ -136,4 +329,47 @@ var (
 
 	// ErrNoShellEnv is returned when the SHELL environment variable is not set.
 	ErrNoShellEnv = errors.New("SHELL environment variable not set")
+
+	// ErrInvalidRenderMode is returned when --render is not markdown, plain, or raw.
+	ErrInvalidRenderMode = errors.New("invalid render mode")
+
+	// ErrUnknownOutputField is returned when --json-fields names a field the JSON output does not have.
+	ErrUnknownOutputField = errors.New("unknown output field")
+
+	// ErrInvalidCitationStyle is returned when --citations is not list, inline, footnote, or none.
+	ErrInvalidCitationStyle = errors.New("invalid citation style")
+
+	// ErrInvalidExtractPath is returned when an --extract path is malformed.
+	ErrInvalidExtractPath = errors.New("invalid extract path")
+
+	// ErrExtractPathNotFound is returned when an --extract path does not exist in the answer.
+	ErrExtractPathNotFound = errors.New("extract path not found")
+
+	// ErrExtractNotJSON is returned when --extract is applied to an answer that is not JSON.
+	ErrExtractNotJSON = errors.New("answer is not JSON")
+
+	// ErrFormatMismatch is returned with --strict-format when the answer does not match
+	// the requested response format.
+	ErrFormatMismatch = errors.New("answer does not match the response format")
+
+	// ErrWarnings is returned with --fail-on-warning when the run reported warnings.
+	ErrWarnings = errors.New("run reported warnings")
+
+	// ErrInvalidExportFormat is returned for an unknown --export-request format.
+	ErrInvalidExportFormat = errors.New("invalid export format")
+
+	// ErrInvalidStreamStyle is returned when --stream-style is not instant or smooth.
+	ErrInvalidStreamStyle = errors.New("invalid stream style")
+
+	// ErrInvalidColorMode is returned when --color is not auto, always, or never.
+	ErrInvalidColorMode = errors.New("invalid color mode")
+
+	// ErrInvalidTheme is returned when output.theme names no known theme.
+	ErrInvalidTheme = errors.New("invalid theme")
+
+	// ErrHistoryEntryNotFound is returned when no prompt history entry has the given ID.
+	ErrHistoryEntryNotFound = errors.New("history entry not found")
+
+	// ErrHistoryEmpty is returned by query --last when no prompt was recorded yet.
+	ErrHistoryEmpty = errors.New("prompt history is empty")
 )
@@ -16,6 +16,17 @@ func TestSentinelErrorsAreUnique(t *testing.T) {
 		ErrConfigFileExists,
 		ErrValidationFailed,
 		ErrUnknownSection,
+		ErrKeyringUnavailable,
+		ErrKeyringKeyNotFound,
+		ErrPassphraseRequired,
+		ErrWrongPassphrase,
+		ErrInvalidEncryptedKey,
+		ErrConfigVersionTooNew,
+		ErrIncludeNotFound,
+		ErrIncludeCycle,
+		ErrIncludeInvalid,
+		ErrBackupNotFound,
+		ErrInvalidBackup,
 
 		// Profile errors
 		ErrProfileNameEmpty,
@@ -25,11 +36,22 @@ func TestSentinelErrorsAreUnique(t *testing.T) {
 		ErrDeleteDefaultProfile,
 		ErrUpdateDefaultProfile,
 		ErrImportReservedName,
+		ErrProfileCycle,
+		ErrProfileChainTooDeep,
+		ErrProfileInUse,
+		ErrProfileNameInvalid,
+		ErrDeleteActiveProfile,
 
 		// Template errors
 		ErrTemplateNotFound,
 		ErrTemplateInvalid,
 
+		// Prompt template errors
+		ErrPromptNotFound,
+		ErrPromptInvalid,
+		ErrMissingPromptVariables,
+		ErrInvalidPromptVariable,
+
 		// Metadata errors
 		ErrOptionNotFound,
 		ErrUnsupportedFormat,
@@ -45,6 +67,48 @@ func TestSentinelErrorsAreUnique(t *testing.T) {
 		ErrInvalidLastUpdatedAfter,
 		ErrInvalidLastUpdatedBefore,
 		ErrInvalidReasoningEffort,
+		ErrInvalidDate,
+		ErrInvalidLanguage,
+		ErrInvalidCountry,
+		ErrUnknownChatCommand,
+		ErrChatCommandUsage,
+		ErrNothingToRetry,
+		ErrRewindPastStart,
+		ErrInvalidTranscriptFormat,
+		ErrInvalidDomain,
+		ErrDomainConflict,
+		ErrImageRecencyConflict,
+		ErrStreamResponseFormat,
+		ErrInvalidImageConflictPolicy,
+		ErrUnknownModel,
+		ErrInvalidMaxTokens,
+		ErrMaxTokensExceeded,
+
+		// API errors
+		ErrRetriesExhausted,
+		ErrRequestAborted,
+		ErrNoResponse,
+		ErrStreamStalled,
+		ErrNoChoices,
+		ErrBlankAnswer,
+
+		// MCP errors
+		ErrJobNotFound,
+		ErrTooManyJobs,
+		ErrSessionNotFound,
+		ErrTooManySessions,
+
+		// Usage errors
+		ErrInvalidGroupBy,
+		ErrInvalidSince,
+		ErrInvalidUsageFormat,
+
+		// Batch errors
+		ErrInvalidBatchLine,
+		ErrBatchValidationFailed,
+		ErrBatchInterrupted,
+		ErrRepeatInterrupted,
+		ErrRequestInterrupted,
 
 		// Command errors
 		ErrInvalidLogLevel,
@@ -53,6 +117,24 @@ func TestSentinelErrorsAreUnique(t *testing.T) {
 		ErrFailedToReadAPIKey,
 		ErrUnsupportedShell,
 		ErrNoShellEnv,
+		ErrInvalidRenderMode,
+		ErrUnknownOutputField,
+		ErrInvalidCitationStyle,
+		ErrInvalidExtractPath,
+		ErrExtractPathNotFound,
+		ErrExtractNotJSON,
+		ErrFormatMismatch,
+		ErrWarnings,
+		ErrInvalidExportFormat,
+		ErrInvalidStreamStyle,
+		ErrInvalidColorMode,
+		ErrInvalidTheme,
+		ErrHistoryEntryNotFound,
+		ErrHistoryEmpty,
+		ErrInvalidJSONSchema,
+
+		// Doctor errors
+		ErrModelsInaccessible,
 	}
 
 	// Check for duplicate error messages
@@ -66,7 +148,7 @@ func TestSentinelErrorsAreUnique(t *testing.T) {
 	}
 
 	// Verify we have all expected errors
-	expectedCount := 32
+	expectedCount := 102
 	if len(allErrors) != expectedCount {
 		t.Errorf("Expected %d sentinel errors, got %d", expectedCount, len(allErrors))
 	}
@@ -155,6 +237,9 @@ func TestProfileErrors(t *testing.T) {
 		{"ErrDeleteDefaultProfile", ErrDeleteDefaultProfile},
 		{"ErrUpdateDefaultProfile", ErrUpdateDefaultProfile},
 		{"ErrImportReservedName", ErrImportReservedName},
+		{"ErrProfileCycle", ErrProfileCycle},
+		{"ErrProfileChainTooDeep", ErrProfileChainTooDeep},
+		{"ErrProfileInUse", ErrProfileInUse},
 	}
 
 	for _, tt := range tests {
@@ -185,6 +270,22 @@ func TestChatErrors(t *testing.T) {
 		{"ErrInvalidLastUpdatedAfter", ErrInvalidLastUpdatedAfter},
 		{"ErrInvalidLastUpdatedBefore", ErrInvalidLastUpdatedBefore},
 		{"ErrInvalidReasoningEffort", ErrInvalidReasoningEffort},
+		{"ErrInvalidDate", ErrInvalidDate},
+		{"ErrInvalidLanguage", ErrInvalidLanguage},
+		{"ErrInvalidCountry", ErrInvalidCountry},
+		{"ErrUnknownChatCommand", ErrUnknownChatCommand},
+		{"ErrChatCommandUsage", ErrChatCommandUsage},
+		{"ErrNothingToRetry", ErrNothingToRetry},
+		{"ErrRewindPastStart", ErrRewindPastStart},
+		{"ErrInvalidTranscriptFormat", ErrInvalidTranscriptFormat},
+		{"ErrInvalidDomain", ErrInvalidDomain},
+		{"ErrDomainConflict", ErrDomainConflict},
+		{"ErrImageRecencyConflict", ErrImageRecencyConflict},
+		{"ErrStreamResponseFormat", ErrStreamResponseFormat},
+		{"ErrInvalidImageConflictPolicy", ErrInvalidImageConflictPolicy},
+		{"ErrUnknownModel", ErrUnknownModel},
+		{"ErrInvalidMaxTokens", ErrInvalidMaxTokens},
+		{"ErrMaxTokensExceeded", ErrMaxTokensExceeded},
 	}
 
 	for _, tt := range tests {
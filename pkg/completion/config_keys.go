@@ -1,16 +1,29 @@
 package completion
 
-import "github.com/sgaunet/pplx/pkg/config"
+import (
+	"maps"
+	"slices"
+
+	"github.com/sgaunet/pplx/pkg/citations"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/output/color"
+	"github.com/sgaunet/pplx/pkg/validation"
+)
 
 // ConfigKeys returns all valid dot-notation configuration keys.
 func ConfigKeys() []string {
 	return config.AllKeys()
 }
 
-// ConfigValues returns valid values for enum-type configuration keys.
-// For non-enum keys, returns nil (no completions).
+// ConfigValues returns valid values for enum-type and boolean configuration
+// keys, and the known models and aliases for defaults.model. For other keys,
+// returns nil (no completions).
 func ConfigValues(key string) []string {
 	switch key {
+	case "defaults.model":
+		return ModelNames()
+	case "defaults.language":
+		return Languages()
 	case "search.recency":
 		return RecencyValues()
 	case "search.mode":
@@ -19,19 +32,50 @@ func ConfigValues(key string) []string {
 		return ContextSizes()
 	case "output.reasoning_effort":
 		return ReasoningEfforts()
-	case "output.stream", "output.return_images", "output.return_related", "output.json":
+	case "output.image_formats":
+		return ImageFormats()
+	case "output.citations":
+		return citations.Styles()
+	case "output.image_conflict_policy":
+		return validation.ValidValues(validation.KindImageConflict)
+	case "output.theme":
+		return color.Themes()
+	}
+
+	if opt, err := config.NewMetadataRegistry().GetOption(key); err == nil && opt.Type == "bool" {
 		return []string{"true", "false"}
-	default:
-		return nil
 	}
+	return nil
 }
 
-// ProfileNames loads the config and returns all profile names.
+// ProfileNames loads the discovered config file and returns all profile names.
+// It returns only the default profile, without reporting an error, when no
+// config file exists or it cannot be read.
 func ProfileNames() []string {
 	loader := config.NewLoader()
 	if err := loader.Load(); err != nil {
 		return []string{config.DefaultProfileName}
 	}
-	pm := config.NewProfileManager(loader.Data())
-	return pm.ListProfiles()
+	names := config.NewProfileManager(loader.Data()).ListProfiles()
+	// The default profile stays first; the others come from a map.
+	slices.Sort(names[1:])
+	return names
+}
+
+// ModelNames returns the available models followed by the model aliases
+// defined in the discovered config file, sorted. Aliases are left out when
+// no config file exists or it cannot be read.
+func ModelNames() []string {
+	names := GetModels()
+	loader := config.NewLoader()
+	if err := loader.Load(); err != nil {
+		return names
+	}
+	aliases := slices.Sorted(maps.Keys(loader.Data().Models.Aliases))
+	for _, alias := range aliases {
+		if !slices.Contains(names, alias) {
+			names = append(names, alias)
+		}
+	}
+	return names
 }
@@ -7,6 +7,10 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/validation"
 )
 
 const (
@@ -24,20 +28,10 @@ type ModelCache struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// KnownModels returns the list of known Perplexity AI models.
-// This list is based on https://docs.perplexity.ai/guides/model-cards
+// KnownModels returns the list of known Perplexity AI models, from the
+// model registry.
 func KnownModels() []string {
-	return []string{
-		"sonar",                      // Default fast model
-		"sonar-pro",                  // Pro model with enhanced capabilities
-		"sonar-reasoning",            // Model with reasoning capabilities
-		"sonar-deep-research",        // Deep research model with reasoning_effort
-		"llama-3.1-sonar-small-128k-online",
-		"llama-3.1-sonar-large-128k-online",
-		"llama-3.1-sonar-huge-128k-online",
-		"llama-3.1-8b-instruct",
-		"llama-3.1-70b-instruct",
-	}
+	return models.IDs()
 }
 
 // GetCacheDir returns the cache directory path.
@@ -129,27 +123,32 @@ func GetModels() []string {
 
 // SearchModes returns valid search mode values.
 func SearchModes() []string {
-	return []string{"web", "academic"}
+	return validation.ValidValues(validation.KindSearchMode)
 }
 
 // RecencyValues returns valid recency filter values.
 func RecencyValues() []string {
-	return []string{"hour", "day", "week", "month", "year"}
+	return validation.ValidValues(validation.KindRecency)
 }
 
 // ContextSizes returns valid context size values.
 func ContextSizes() []string {
-	return []string{"low", "medium", "high"}
+	return validation.ValidValues(validation.KindContextSize)
 }
 
 // ReasoningEfforts returns valid reasoning effort values for sonar-deep-research.
 func ReasoningEfforts() []string {
-	return []string{"low", "medium", "high"}
+	return validation.ValidValues(validation.KindReasoningEffort)
 }
 
-// ImageFormats returns common image format values.
+// ImageFormats returns valid image format values.
 func ImageFormats() []string {
-	return []string{"jpg", "jpeg", "png", "gif", "webp", "bmp", "svg"}
+	return validation.ValidValues(validation.KindImageFormat)
+}
+
+// Languages returns the ISO 639-1 codes accepted by --language.
+func Languages() []string {
+	return validation.LanguageCodes()
 }
 
 // CommonDomains returns a list of common domains for suggestions.
@@ -166,14 +165,31 @@ func CommonDomains() []string {
 	}
 }
 
-// TemplateNames returns valid configuration template names.
+// TemplateNames returns valid configuration template names: the built-ins
+// followed by the user templates in ~/.config/pplx/templates.
 func TemplateNames() []string {
-	return []string{"research", "creative", "news", "full-example"}
+	templates := config.ListTemplates()
+	names := make([]string, 0, len(templates))
+	for _, tpl := range templates {
+		names = append(names, tpl.Name)
+	}
+	return names
+}
+
+// TemplateCompletions returns the template names with their descriptions,
+// in the "name\tdescription" form shells display next to each candidate.
+func TemplateCompletions() []string {
+	templates := config.ListTemplates()
+	completions := make([]string, 0, len(templates))
+	for _, tpl := range templates {
+		completions = append(completions, tpl.Name+"\t"+tpl.Description)
+	}
+	return completions
 }
 
 // ConfigSections returns valid configuration section names.
 func ConfigSections() []string {
-	return []string{"defaults", "search", "output", "api"}
+	return config.NewMetadataRegistry().ListSections()
 }
 
 // OutputFormats returns valid output format values for config commands.
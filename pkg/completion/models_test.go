@@ -286,11 +286,11 @@ func TestConfigSections(t *testing.T) {
 	t.Parallel()
 
 	sections := ConfigSections()
-	if len(sections) != 4 {
-		t.Errorf("ConfigSections() returned %d sections, want 4", len(sections))
+	if len(sections) != 6 {
+		t.Errorf("ConfigSections() returned %d sections, want 6", len(sections))
 	}
 
-	expected := []string{"defaults", "search", "output", "api"}
+	expected := []string{"defaults", "search", "output", "api", "models", "prompts"}
 	for i, section := range expected {
 		if sections[i] != section {
 			t.Errorf("ConfigSections()[%d] = %v, want %v", i, sections[i], section)
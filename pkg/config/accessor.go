@@ -20,6 +20,12 @@ const maxSplitParts = 2
 // tagSplitParts is the maximum number of parts when splitting a yaml tag value.
 const tagSplitParts = 2
 
+// keySuggestMaxDistance is the maximum edit distance for unknown-key suggestions.
+const keySuggestMaxDistance = 3
+
+// maxKeySuggestions caps how many keys are suggested for an unknown key.
+const maxKeySuggestions = 3
+
 // GetValue returns the Go value for the given dot-notation key from cfg.
 //
 // Accepted key forms:
@@ -52,57 +58,184 @@ func GetValue(cfg *ConfigData, key string) (any, error) {
 		return nil, unknownKeyError(key)
 	}
 
-	return fv.Interface(), nil
+	return fieldValue(fv), nil
+}
+
+// fieldValue returns the value of a config field. Pointer fields, which
+// tell an unset option from a zero one, return the value they point to, or
+// nil when unset.
+func fieldValue(fv reflect.Value) any {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil
+		}
+		return fv.Elem().Interface()
+	}
+	return fv.Interface()
 }
 
 // SetValue parses rawValue and sets it on the field identified by the dot-notation key.
 //
-// Type coercions applied based on the target field kind:
-//   - bool         — "true" / "false"
-//   - int          — decimal integer string
-//   - float64      — decimal float string
-//   - []string     — comma-separated values (each entry trimmed)
-//   - time.Duration — duration string parsed by [time.ParseDuration]
-//   - string       — used as-is
+// The value is coerced according to the option's Type in the MetadataRegistry
+// (see [CoerceValue]).
 //
 // Returns [clerrors.ErrOptionNotFound] for unknown keys, or a descriptive error on
 // type conversion failures.
 func SetValue(cfg *ConfigData, key string, rawValue string) error {
+	fv, err := settableField(key, func(section string) (any, error) {
+		return sectionStruct(cfg, section)
+	})
+	if err != nil {
+		return err
+	}
+
+	typed, err := CoerceValue(key, rawValue)
+	if err != nil {
+		return err
+	}
+
+	return assignValue(fv, key, typed)
+}
+
+// UnsetValue resets the field identified by the dot-notation key to its zero value.
+func UnsetValue(cfg *ConfigData, key string) error {
+	fv, err := settableField(key, func(section string) (any, error) {
+		return sectionStruct(cfg, section)
+	})
+	if err != nil {
+		return err
+	}
+	fv.SetZero()
+	return nil
+}
+
+// GetProfileValue returns the literal value stored in profile for the
+// dot-notation key, or nil when the profile does not override it.
+// Only the defaults, search, and output sections exist in profiles.
+func GetProfileValue(profile *Profile, key string) (any, error) {
 	parts := strings.SplitN(key, dotSeparator, maxSplitParts)
 	if len(parts) != maxSplitParts {
-		return unknownKeyError(key)
+		return nil, unknownKeyError(key)
 	}
 
-	section := strings.ToLower(parts[0])
-	fieldKey := parts[1]
+	sv, err := profileSectionStruct(profile, strings.ToLower(parts[0]))
+	if err != nil {
+		return nil, err
+	}
 
-	sv, err := sectionStruct(cfg, section)
+	fv, err := fieldByYAMLTag(reflect.ValueOf(sv), parts[1])
+	if err != nil {
+		return nil, unknownKeyError(key)
+	}
+
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil, nil //nolint:nilnil // nil means "not overridden by the profile"
+		}
+		return fv.Elem().Interface(), nil
+	}
+	return fv.Interface(), nil
+}
+
+// SetProfileValue coerces rawValue like [SetValue] and stores it as an
+// override in profile.
+func SetProfileValue(profile *Profile, key, rawValue string) error {
+	fv, err := settableField(key, func(section string) (any, error) {
+		return profileSectionStruct(profile, section)
+	})
 	if err != nil {
 		return err
 	}
 
-	// sectionStruct returns a pointer so we get the addressable Elem for setting.
-	rv := reflect.ValueOf(sv).Elem()
+	typed, err := CoerceValue(key, rawValue)
+	if err != nil {
+		return err
+	}
+
+	return assignValue(fv, key, typed)
+}
 
-	fv, err := fieldByYAMLTag(rv, fieldKey)
+// UnsetProfileValue removes the override for key from profile so the base
+// configuration value applies again.
+func UnsetProfileValue(profile *Profile, key string) error {
+	fv, err := settableField(key, func(section string) (any, error) {
+		return profileSectionStruct(profile, section)
+	})
 	if err != nil {
+		return err
+	}
+	fv.SetZero()
+	return nil
+}
+
+// CheckKey returns nil when key names a known option, otherwise an
+// [clerrors.ErrOptionNotFound] error suggesting the closest keys.
+func CheckKey(key string) error {
+	if _, ok := NewMetadataRegistry().options[key]; !ok {
 		return unknownKeyError(key)
 	}
+	return nil
+}
 
-	if !fv.CanSet() {
-		return fmt.Errorf("%w: %s", clerrors.ErrFieldNotSettable, key)
+// CoerceValue converts rawValue to the Go type declared for key in the MetadataRegistry:
+//   - bool          — "true" / "false"
+//   - int           — decimal integer string
+//   - float64       — decimal float string
+//   - []string      — comma-separated values (each entry trimmed, empties dropped)
+//   - duration      — duration string parsed by [time.ParseDuration]
+//   - string        — used as-is
+//
+// Returns [clerrors.ErrOptionNotFound] (with close matches) for unknown keys.
+func CoerceValue(key, rawValue string) (any, error) {
+	opt, ok := NewMetadataRegistry().options[key]
+	if !ok {
+		return nil, unknownKeyError(key)
 	}
 
-	return setFieldValue(fv, key, rawValue)
+	switch opt.Type {
+	case "bool":
+		b, err := strconv.ParseBool(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q as bool for %q: %w", rawValue, key, err)
+		}
+		return b, nil
+	case "int":
+		n, err := strconv.Atoi(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q as int for %q: %w", rawValue, key, err)
+		}
+		return n, nil
+	case "float64":
+		f, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q as float for %q: %w", rawValue, key, err)
+		}
+		return f, nil
+	case "duration":
+		d, err := time.ParseDuration(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q as duration for %q: %w", rawValue, key, err)
+		}
+		return d, nil
+	case "[]string":
+		return splitList(rawValue), nil
+	default:
+		return rawValue, nil
+	}
 }
 
 // AllKeys returns all valid dot-notation keys registered in the MetadataRegistry,
-// sorted alphabetically.
+// sorted alphabetically. Prompt template fields and maps such as
+// models.aliases are excluded: their names are chosen by the user, so they
+// cannot be addressed by a fixed key.
 func AllKeys() []string {
 	reg := NewMetadataRegistry()
 	opts := reg.GetAll()
 	keys := make([]string, 0, len(opts))
 	for _, opt := range opts {
+		if opt.Section == SectionPrompts || isMapOption(opt) {
+			continue
+		}
 		keys = append(keys, fmt.Sprintf("%s.%s", opt.Section, opt.Name))
 	}
 	sort.Strings(keys)
@@ -121,8 +254,10 @@ func sectionStruct(cfg *ConfigData, section string) (any, error) {
 		return &cfg.Output, nil
 	case SectionAPI:
 		return &cfg.API, nil
+	case SectionModels:
+		return &cfg.Models, nil
 	default:
-		return nil, fmt.Errorf("%w: %s (available: defaults, search, output, api)",
+		return nil, fmt.Errorf("%w: %s (available: defaults, search, output, api, models)",
 			clerrors.ErrUnknownSection, section)
 	}
 }
@@ -142,7 +277,7 @@ func structToMap(sv any) map[string]any {
 		if tag == "" || tag == "-" {
 			continue
 		}
-		result[tag] = rv.Field(i).Interface()
+		result[tag] = fieldValue(rv.Field(i))
 	}
 
 	return result
@@ -150,17 +285,26 @@ func structToMap(sv any) map[string]any {
 
 // fieldByYAMLTag searches a struct value for the field whose yaml tag matches name.
 // v may be a pointer or a struct Value; the function dereferences pointers automatically.
+// A dotted name such as "tls.ca_file" addresses a field of a nested struct.
 func fieldByYAMLTag(v reflect.Value, name string) (reflect.Value, error) {
 	if v.Kind() == reflect.Pointer {
 		v = v.Elem()
 	}
 
+	head, rest, nested := strings.Cut(name, dotSeparator)
 	t := v.Type()
 	for i := range v.NumField() {
 		tag := yamlTagName(t.Field(i))
-		if tag == name {
+		if tag != head {
+			continue
+		}
+		if !nested {
 			return v.Field(i), nil
 		}
+		if v.Field(i).Kind() == reflect.Struct {
+			return fieldByYAMLTag(v.Field(i), rest)
+		}
+		break
 	}
 
 	return reflect.Value{}, fmt.Errorf("%w: yaml tag %q", clerrors.ErrFieldNotFound, name)
@@ -175,91 +319,126 @@ func yamlTagName(f reflect.StructField) string {
 	return strings.SplitN(tag, ",", tagSplitParts)[0]
 }
 
-// setFieldValue converts rawValue to the kind required by fv and assigns it.
-// Duration is handled as a special case before dispatching on reflect.Kind.
-func setFieldValue(fv reflect.Value, key, rawValue string) error {
-	// Handle time.Duration before the generic kind switch.
-	if fv.Type() == reflect.TypeFor[time.Duration]() {
-		return setDuration(fv, key, rawValue)
+// settableField resolves a "section.name" key to an addressable field using
+// lookup to find the section struct.
+func settableField(key string, lookup func(section string) (any, error)) (reflect.Value, error) {
+	parts := strings.SplitN(key, dotSeparator, maxSplitParts)
+	if len(parts) != maxSplitParts {
+		return reflect.Value{}, unknownKeyError(key)
 	}
 
-	switch fv.Kind() { //nolint:exhaustive // remaining kinds are not valid config field types
-	case reflect.String:
-		fv.SetString(rawValue)
-
-	case reflect.Bool:
-		return setBool(fv, key, rawValue)
-
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return setInt(fv, key, rawValue)
-
-	case reflect.Float32, reflect.Float64:
-		return setFloat(fv, key, rawValue)
-
-	case reflect.Slice:
-		return setStringSlice(fv, key, rawValue)
+	sv, err := lookup(strings.ToLower(parts[0]))
+	if err != nil {
+		return reflect.Value{}, err
+	}
 
-	default:
-		return fmt.Errorf("%w: %s (kind %s)", clerrors.ErrUnsupportedFieldKind, key, fv.Kind())
+	// Section lookups return a pointer so we get the addressable Elem for setting.
+	fv, err := fieldByYAMLTag(reflect.ValueOf(sv).Elem(), parts[1])
+	if err != nil {
+		return reflect.Value{}, unknownKeyError(key)
 	}
 
-	return nil
+	if !fv.CanSet() {
+		return reflect.Value{}, fmt.Errorf("%w: %s", clerrors.ErrFieldNotSettable, key)
+	}
+	return fv, nil
 }
 
-func setDuration(fv reflect.Value, key, rawValue string) error {
-	d, err := time.ParseDuration(rawValue)
-	if err != nil {
-		return fmt.Errorf("cannot parse %q as duration for %q: %w", rawValue, key, err)
+// profileSectionStruct returns a pointer to the section struct within profile.
+func profileSectionStruct(profile *Profile, section string) (any, error) {
+	switch section {
+	case SectionDefaults:
+		return &profile.Defaults, nil
+	case SectionSearch:
+		return &profile.Search, nil
+	case SectionOutput:
+		return &profile.Output, nil
+	case SectionModels:
+		return &profile.Models, nil
+	default:
+		return nil, fmt.Errorf("%w: %s (profiles support: defaults, search, output, models)",
+			clerrors.ErrUnknownSection, section)
 	}
-	fv.Set(reflect.ValueOf(d))
-	return nil
 }
 
-func setBool(fv reflect.Value, key, rawValue string) error {
-	b, err := strconv.ParseBool(rawValue)
-	if err != nil {
-		return fmt.Errorf("cannot parse %q as bool for %q: %w", rawValue, key, err)
+// assignValue stores a coerced value into fv, allocating pointer fields used by
+// profile overrides and converting between compatible kinds (e.g. int → int64).
+func assignValue(fv reflect.Value, key string, typed any) error {
+	target := fv.Type()
+	if target.Kind() == reflect.Pointer {
+		target = target.Elem()
 	}
-	fv.SetBool(b)
-	return nil
-}
 
-func setInt(fv reflect.Value, key, rawValue string) error {
-	n, err := strconv.ParseInt(rawValue, 10, 64)
-	if err != nil {
-		return fmt.Errorf("cannot parse %q as int for %q: %w", rawValue, key, err)
+	v := reflect.ValueOf(typed)
+	switch {
+	case v.Type() == target:
+	case target.Kind() == reflect.String:
+		// Options declared as strings but holding durations (e.g. defaults.timeout).
+		v = reflect.ValueOf(fmt.Sprint(typed))
+	case v.Type().ConvertibleTo(target) && v.Kind() != reflect.String:
+		v = v.Convert(target)
+	default:
+		return fmt.Errorf("%w: %s (kind %s)", clerrors.ErrUnsupportedFieldKind, key, target.Kind())
 	}
-	fv.SetInt(n)
-	return nil
-}
 
-func setFloat(fv reflect.Value, key, rawValue string) error {
-	f, err := strconv.ParseFloat(rawValue, 64)
-	if err != nil {
-		return fmt.Errorf("cannot parse %q as float for %q: %w", rawValue, key, err)
+	if fv.Kind() == reflect.Pointer {
+		ptr := reflect.New(target)
+		ptr.Elem().Set(v)
+		fv.Set(ptr)
+		return nil
 	}
-	fv.SetFloat(f)
+	fv.Set(v)
 	return nil
 }
 
-func setStringSlice(fv reflect.Value, key, rawValue string) error {
-	if fv.Type().Elem().Kind() != reflect.String {
-		return fmt.Errorf("%w: %s", clerrors.ErrUnsupportedSliceType, key)
-	}
+// splitList splits a comma-separated value, trimming entries and dropping empties.
+func splitList(rawValue string) []string {
 	parts := strings.Split(rawValue, ",")
 	elems := make([]string, 0, len(parts))
 	for _, p := range parts {
-		trimmed := strings.TrimSpace(p)
-		if trimmed != "" {
+		if trimmed := strings.TrimSpace(p); trimmed != "" {
 			elems = append(elems, trimmed)
 		}
 	}
-	fv.Set(reflect.ValueOf(elems))
-	return nil
+	return elems
 }
 
-// unknownKeyError constructs a helpful error listing all valid keys.
+// unknownKeyError constructs a helpful error naming the closest valid keys,
+// or listing all valid keys when nothing is close.
 func unknownKeyError(key string) error {
+	if suggestions := SuggestKeys(key); len(suggestions) > 0 {
+		return fmt.Errorf("%w: %s (did you mean: %s?)",
+			clerrors.ErrOptionNotFound, key, strings.Join(suggestions, ", "))
+	}
 	available := strings.Join(AllKeys(), ", ")
 	return fmt.Errorf("%w: %s (available: %s)", clerrors.ErrOptionNotFound, key, available)
 }
+
+// SuggestKeys returns up to maxKeySuggestions valid keys closest to key.
+// Both the full "section.name" form and the bare option name are compared,
+// so "temprature" and "defaults.temprature" both suggest "defaults.temperature".
+func SuggestKeys(key string) []string {
+	lower := strings.ToLower(key)
+	type candidate struct {
+		key  string
+		dist int
+	}
+	var candidates []candidate
+	for _, k := range AllKeys() {
+		name := k[strings.Index(k, dotSeparator)+1:]
+		dist := min(LevenshteinDistance(lower, k), LevenshteinDistance(lower, name))
+		if dist <= keySuggestMaxDistance {
+			candidates = append(candidates, candidate{k, dist})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+
+	out := make([]string, 0, maxKeySuggestions)
+	for _, c := range candidates {
+		if len(out) == maxKeySuggestions {
+			break
+		}
+		out = append(out, c.key)
+	}
+	return out
+}
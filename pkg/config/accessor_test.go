@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sgaunet/pplx/pkg/clerrors"
 )
@@ -223,6 +224,28 @@ func TestSetValue(t *testing.T) {
 		}
 	})
 
+	t.Run("set pointer bool", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := NewConfigData()
+		if got, err := GetValue(cfg, "output.history"); err != nil || got != nil {
+			t.Errorf("GetValue(output.history) unset = %v, %v; want nil", got, err)
+		}
+		if !cfg.Output.HistoryEnabled() {
+			t.Error("HistoryEnabled() = false, want true when output.history is unset")
+		}
+
+		if err := SetValue(cfg, "output.history", "false"); err != nil {
+			t.Fatalf("SetValue(output.history) unexpected error: %v", err)
+		}
+		if got, err := GetValue(cfg, "output.history"); err != nil || got != false {
+			t.Errorf("GetValue(output.history) = %v, %v; want false", got, err)
+		}
+		if cfg.Output.HistoryEnabled() {
+			t.Error("HistoryEnabled() = true, want false")
+		}
+	})
+
 	t.Run("set bool true", func(t *testing.T) {
 		t.Parallel()
 
@@ -379,3 +402,209 @@ func TestAllKeys(t *testing.T) {
 		}
 	})
 }
+
+// TestCoerceValue tests registry-driven type coercion for each option type.
+func TestCoerceValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		key     string
+		raw     string
+		want    any
+		wantErr bool
+	}{
+		{"string", "defaults.model", "sonar", "sonar", false},
+		{"int", "defaults.max_tokens", "42", 42, false},
+		{"int invalid", "defaults.max_tokens", "4.2", nil, true},
+		{"float", "defaults.top_p", "0.5", 0.5, false},
+		{"bool", "output.json", "true", true, false},
+		{"bool invalid", "output.json", "yes please", nil, true},
+		{"duration", "api.timeout", "45s", 45 * time.Second, false},
+		{"duration invalid", "api.timeout", "45", nil, true},
+		{"unknown key", "defaults.nope", "x", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := CoerceValue(tt.key, tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CoerceValue(%q, %q) error = %v, wantErr %v", tt.key, tt.raw, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("CoerceValue(%q, %q) = %#v, want %#v", tt.key, tt.raw, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("list trims and drops empties", func(t *testing.T) {
+		t.Parallel()
+
+		got, err := CoerceValue("search.domains", " a.com, ,b.com ")
+		if err != nil {
+			t.Fatalf("CoerceValue(search.domains) unexpected error: %v", err)
+		}
+		list, ok := got.([]string)
+		if !ok || len(list) != 2 || list[0] != "a.com" || list[1] != "b.com" {
+			t.Errorf("CoerceValue(search.domains) = %#v, want [a.com b.com]", got)
+		}
+	})
+}
+
+// TestSetValue_Durations tests duration options, including the string-typed defaults.timeout.
+func TestSetValue_Durations(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfigData()
+	if err := SetValue(cfg, "api.timeout", "1m30s"); err != nil {
+		t.Fatalf("SetValue(api.timeout) unexpected error: %v", err)
+	}
+	if cfg.API.Timeout != 90*time.Second {
+		t.Errorf("cfg.API.Timeout = %v, want 1m30s", cfg.API.Timeout)
+	}
+
+	if err := SetValue(cfg, "defaults.timeout", "90s"); err != nil {
+		t.Fatalf("SetValue(defaults.timeout) unexpected error: %v", err)
+	}
+	if cfg.Defaults.Timeout != "90s" {
+		t.Errorf("cfg.Defaults.Timeout = %q, want %q", cfg.Defaults.Timeout, "90s")
+	}
+}
+
+// TestUnsetValue tests that UnsetValue resets fields of every kind to zero.
+func TestUnsetValue(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfigData()
+	cfg.Defaults.MaxTokens = 100
+	cfg.Search.Domains = []string{"a.com"}
+	cfg.Output.Stream = true
+
+	for _, key := range []string{"defaults.max_tokens", "search.domains", "output.stream"} {
+		if err := UnsetValue(cfg, key); err != nil {
+			t.Fatalf("UnsetValue(%s) unexpected error: %v", key, err)
+		}
+	}
+
+	if cfg.Defaults.MaxTokens != 0 || cfg.Search.Domains != nil || cfg.Output.Stream {
+		t.Errorf("fields not reset: max_tokens=%d domains=%v stream=%v",
+			cfg.Defaults.MaxTokens, cfg.Search.Domains, cfg.Output.Stream)
+	}
+}
+
+// TestNestedValue tests keys addressing a nested block, such as api.tls.
+func TestNestedValue(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfigData()
+	if err := SetValue(cfg, "api.tls.ca_file", "/etc/ssl/corp.pem"); err != nil {
+		t.Fatalf("SetValue() unexpected error: %v", err)
+	}
+	if err := SetValue(cfg, "api.tls.insecure_skip_verify", "true"); err != nil {
+		t.Fatalf("SetValue() unexpected error: %v", err)
+	}
+	if cfg.API.TLS.CAFile != "/etc/ssl/corp.pem" || !cfg.API.TLS.InsecureSkipVerify {
+		t.Errorf("api.tls = %+v, want both options set", cfg.API.TLS)
+	}
+	if got, err := GetValue(cfg, "api.tls.ca_file"); err != nil || got != "/etc/ssl/corp.pem" {
+		t.Errorf("GetValue() = %v, %v; want the CA file", got, err)
+	}
+	if err := UnsetValue(cfg, "api.tls.insecure_skip_verify"); err != nil || cfg.API.TLS.InsecureSkipVerify {
+		t.Errorf("UnsetValue() error = %v, insecure_skip_verify = %v", err, cfg.API.TLS.InsecureSkipVerify)
+	}
+	for _, key := range []string{"api.tls.nope", "api.key.nested"} {
+		if _, err := GetValue(cfg, key); !errors.Is(err, clerrors.ErrOptionNotFound) {
+			t.Errorf("GetValue(%s) error = %v, want ErrOptionNotFound", key, err)
+		}
+	}
+}
+
+// TestProfileValues tests setting, reading, and unsetting profile overrides.
+func TestProfileValues(t *testing.T) {
+	t.Parallel()
+
+	profile := &Profile{Name: "research"}
+
+	got, err := GetProfileValue(profile, "defaults.temperature")
+	if err != nil || got != nil {
+		t.Fatalf("GetProfileValue on empty profile = (%v, %v), want (nil, nil)", got, err)
+	}
+
+	if err := SetProfileValue(profile, "defaults.temperature", "0"); err != nil {
+		t.Fatalf("SetProfileValue unexpected error: %v", err)
+	}
+	if profile.Defaults.Temperature == nil || *profile.Defaults.Temperature != 0 {
+		t.Fatalf("profile temperature = %v, want pointer to 0", profile.Defaults.Temperature)
+	}
+
+	if err := SetProfileValue(profile, "search.domains", "a.com,b.com"); err != nil {
+		t.Fatalf("SetProfileValue(search.domains) unexpected error: %v", err)
+	}
+	got, err = GetProfileValue(profile, "search.domains")
+	if err != nil {
+		t.Fatalf("GetProfileValue(search.domains) unexpected error: %v", err)
+	}
+	if list, ok := got.([]string); !ok || len(list) != 2 {
+		t.Errorf("GetProfileValue(search.domains) = %#v, want two domains", got)
+	}
+
+	if err := UnsetProfileValue(profile, "defaults.temperature"); err != nil {
+		t.Fatalf("UnsetProfileValue unexpected error: %v", err)
+	}
+	if profile.Defaults.Temperature != nil {
+		t.Errorf("profile temperature = %v, want nil after unset", *profile.Defaults.Temperature)
+	}
+
+	err = SetProfileValue(profile, "api.key", "secret")
+	if !errors.Is(err, clerrors.ErrUnknownSection) {
+		t.Errorf("SetProfileValue(api.key) error = %v, want ErrUnknownSection", err)
+	}
+}
+
+// TestSuggestKeys tests closest-key suggestions for misspelled keys.
+func TestSuggestKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"defaults.temprature", "defaults.temperature"},
+		{"temprature", "defaults.temperature"},
+		{"search.recncy", "search.recency"},
+		{"api.max_retry", "api.max_retries"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+
+			got := SuggestKeys(tt.key)
+			if len(got) == 0 || got[0] != tt.want {
+				t.Errorf("SuggestKeys(%q) = %v, want first %q", tt.key, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("error message includes suggestion", func(t *testing.T) {
+		t.Parallel()
+
+		err := CheckKey("defaults.modle")
+		if !errors.Is(err, clerrors.ErrOptionNotFound) {
+			t.Fatalf("CheckKey error = %v, want ErrOptionNotFound", err)
+		}
+		if !strings.Contains(err.Error(), "did you mean: defaults.model") {
+			t.Errorf("CheckKey error = %q, want suggestion for defaults.model", err)
+		}
+	})
+
+	t.Run("no suggestions for unrelated key", func(t *testing.T) {
+		t.Parallel()
+
+		if got := SuggestKeys("completely.unrelated.thing"); len(got) != 0 {
+			t.Errorf("SuggestKeys(unrelated) = %v, want none", got)
+		}
+	})
+}
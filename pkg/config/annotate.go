@@ -2,6 +2,10 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -53,6 +57,11 @@ func generateFieldComment(opt *OptionMetadata, indent int) string {
 
 	var lines []string
 
+	// Flag deprecated options first
+	if opt.Deprecated != "" {
+		lines = append(lines, wrapComment("DEPRECATED: "+opt.Deprecated, indent)...)
+	}
+
 	// Add description
 	if opt.Description != "" {
 		lines = append(lines, wrapComment(opt.Description, indent)...)
@@ -275,6 +284,11 @@ func GenerateAnnotatedConfig(cfg *ConfigData, opts AnnotationOptions) (string, e
 	output.WriteString("# This file contains all available configuration options with descriptions.\n")
 	output.WriteString("#\n\n")
 
+	if cfg.Version > 0 {
+		output.WriteString("# Config schema version, upgraded by 'pplx config migrate'\n")
+		fmt.Fprintf(&output, "version: %d\n\n", cfg.Version)
+	}
+
 	// Generate Defaults section
 	if err := generateSection(&output, "Defaults", SectionDefaults, registry, cfg, opts); err != nil {
 		return "", fmt.Errorf("failed to generate defaults section: %w", err)
@@ -295,6 +309,16 @@ func GenerateAnnotatedConfig(cfg *ConfigData, opts AnnotationOptions) (string, e
 		return "", fmt.Errorf("failed to generate api section: %w", err)
 	}
 
+	// Generate Models section
+	if err := generateModelsSection(&output, registry, cfg, opts); err != nil {
+		return "", fmt.Errorf("failed to generate models section: %w", err)
+	}
+
+	// Generate Prompts section
+	if err := generatePromptsSection(&output, registry, cfg, opts); err != nil {
+		return "", fmt.Errorf("failed to generate prompts section: %w", err)
+	}
+
 	// Add profiles section if they exist
 	if len(cfg.Profiles) > 0 {
 		output.WriteString("\n")
@@ -303,7 +327,8 @@ func GenerateAnnotatedConfig(cfg *ConfigData, opts AnnotationOptions) (string, e
 		output.WriteString("# Named configuration profiles for different use cases\n")
 		output.WriteString("\nprofiles:\n")
 
-		for name, profile := range cfg.Profiles {
+		for _, name := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+			profile := cfg.Profiles[name]
 			fmt.Fprintf(&output, "  %s:\n", name)
 			profileYAML, err := yaml.Marshal(profile)
 			if err != nil {
@@ -357,30 +382,51 @@ func generateSection(
 	output.WriteString(sectionKey + ":\n")
 
 	// Add each option with comments
+	group := ""
 	for _, opt := range options {
-		if opts.IncludeDescriptions {
-			comment := generateFieldComment(opt, commentIndent)
-			if comment != "" {
-				output.WriteString(comment + "\n")
-			}
-		}
-
 		// Get the field name (remove section prefix)
 		fieldName := strings.TrimPrefix(opt.Name, section+".")
 
 		// Get value from config or use default
 		value := getConfigValue(cfg, section, fieldName, opt.Default)
 
+		// Deprecated options are written only when the config still sets them.
+		if opt.Deprecated != "" && reflect.DeepEqual(value, opt.Default) {
+			continue
+		}
+
+		// Options named "block.field", such as api.tls.ca_file, are written
+		// in a nested block, opened before its first option.
+		depth := commentIndent
+		if block, leaf, nested := strings.Cut(fieldName, "."); nested {
+			if block != group {
+				_, _ = fmt.Fprintf(output, "  %s:\n", block)
+				group = block
+			}
+			fieldName = leaf
+			depth += commentIndent
+		}
+		indent := strings.Repeat(" ", depth)
+
+		if opts.IncludeDescriptions {
+			comment := generateFieldComment(opt, depth)
+			if comment != "" {
+				output.WriteString(comment + "\n")
+			}
+		}
+
 		// Write the field with its value.
 		// Slices need special handling: YAML marshals them as multi-line "- item"
 		// sequences that must appear on subsequent indented lines, not inline.
 		if slice, ok := toStringSlice(value); ok {
-			_, _ = fmt.Fprintf(output, "  %s:\n", fieldName)
+			_, _ = fmt.Fprintf(output, "%s%s:\n", indent, fieldName)
 			for _, item := range slice {
-				_, _ = fmt.Fprintf(output, "    - %s\n", item)
+				_, _ = fmt.Fprintf(output, "%s  - %s\n", indent, item)
 			}
+		} else if isMapOption(opt) {
+			writeMapField(output, fieldName, value)
 		} else {
-			_, _ = fmt.Fprintf(output, "  %s: ", fieldName)
+			_, _ = fmt.Fprintf(output, "%s%s: ", indent, fieldName)
 			valueYAML, err := yaml.Marshal(value)
 			if err != nil {
 				return fmt.Errorf("failed to marshal value for %s: %w", opt.Name, err)
@@ -393,6 +439,118 @@ func generateSection(
 	return nil
 }
 
+// exampleModels is shown, commented out, when the config defines no aliases.
+const exampleModels = `models:
+  aliases:
+    fast: sonar
+    deep: sonar-deep-research
+`
+
+// generateModelsSection writes the model aliases of cfg, or a commented
+// example when there are none.
+func generateModelsSection(
+	output *strings.Builder,
+	registry *MetadataRegistry,
+	cfg *ConfigData,
+	opts AnnotationOptions,
+) error {
+	output.WriteString(generateSectionHeader("Model Aliases", opts.HeaderStyle, 0))
+	output.WriteString("\n")
+	output.WriteString("# Short model names used with: pplx query --model <alias>\n")
+
+	if opts.IncludeDescriptions {
+		for _, opt := range registry.GetBySection(SectionModels) {
+			output.WriteString("#\n")
+			output.WriteString("# " + opt.Name + ":\n")
+			output.WriteString(generateFieldComment(opt, 0) + "\n")
+		}
+	}
+	output.WriteString("\n")
+
+	if len(cfg.Models.Aliases) == 0 {
+		for line := range strings.SplitSeq(strings.TrimSuffix(exampleModels, "\n"), "\n") {
+			output.WriteString("# " + line + "\n")
+		}
+		output.WriteString("\n")
+		return nil
+	}
+
+	modelsYAML, err := yaml.Marshal(map[string]any{SectionModels: cfg.Models})
+	if err != nil {
+		return fmt.Errorf("failed to marshal models: %w", err)
+	}
+	output.Write(modelsYAML)
+	output.WriteString("\n")
+	return nil
+}
+
+// examplePrompts is shown, commented out, when the config defines no prompts.
+const examplePrompts = `prompts:
+  review:
+    description: Review code in a given language
+    system: You are an expert {{language}} reviewer. Be {{tone}}.
+    user: Review this code for bugs and style issues.
+`
+
+// generatePromptsSection writes the prompt templates of cfg, or a commented
+// example when there are none. Template names are user-defined, so the field
+// comments describe the fields of a single template.
+func generatePromptsSection(
+	output *strings.Builder,
+	registry *MetadataRegistry,
+	cfg *ConfigData,
+	opts AnnotationOptions,
+) error {
+	output.WriteString(generateSectionHeader("Prompt Templates", opts.HeaderStyle, 0))
+	output.WriteString("\n")
+	output.WriteString("# Named prompts used with: pplx query --prompt-template <name> --var key=value\n")
+
+	if opts.IncludeDescriptions {
+		fields := registry.GetBySection(SectionPrompts)
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		for _, opt := range fields {
+			output.WriteString("#\n")
+			output.WriteString("# " + opt.Name + ":\n")
+			output.WriteString(generateFieldComment(opt, 0) + "\n")
+		}
+	}
+	output.WriteString("\n")
+
+	if len(cfg.Prompts) == 0 {
+		for line := range strings.SplitSeq(strings.TrimSuffix(examplePrompts, "\n"), "\n") {
+			output.WriteString("# " + line + "\n")
+		}
+		output.WriteString("\n")
+		return nil
+	}
+
+	promptsYAML, err := yaml.Marshal(map[string]any{SectionPrompts: cfg.Prompts})
+	if err != nil {
+		return fmt.Errorf("failed to marshal prompts: %w", err)
+	}
+	output.Write(promptsYAML)
+	output.WriteString("\n")
+	return nil
+}
+
+// writeMapField writes a map option as a nested block, keys sorted, or as
+// an empty map when it is unset.
+func writeMapField(output *strings.Builder, fieldName string, value any) {
+	m, _ := value.(map[string]string)
+	if len(m) == 0 {
+		_, _ = fmt.Fprintf(output, "  %s: {}\n", fieldName)
+		return
+	}
+	_, _ = fmt.Fprintf(output, "  %s:\n", fieldName)
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		v, err := yaml.Marshal(m[k])
+		if err != nil {
+			v = []byte(m[k])
+		}
+		_, _ = fmt.Fprintf(output, "    %s: %s\n", k, strings.TrimSpace(string(v)))
+	}
+}
+
 // getConfigValue retrieves the value for a field from the config, or returns the default.
 //
 //nolint:gocognit,cyclop,gocyclo,funlen // Config field mapping requires many cases
@@ -432,6 +590,18 @@ func getConfigValue(cfg *ConfigData, section, fieldName string, defaultValue any
 			if cfg.Defaults.Timeout != "" {
 				return cfg.Defaults.Timeout
 			}
+		case "language":
+			if cfg.Defaults.Language != "" {
+				return cfg.Defaults.Language
+			}
+		case "system_prompt":
+			if cfg.Defaults.SystemPrompt != "" {
+				return cfg.Defaults.SystemPrompt
+			}
+		case "model_fallbacks":
+			if len(cfg.Defaults.ModelFallbacks) > 0 {
+				return cfg.Defaults.ModelFallbacks
+			}
 		}
 	case SectionSearch:
 		switch fieldName {
@@ -439,6 +609,10 @@ func getConfigValue(cfg *ConfigData, section, fieldName string, defaultValue any
 			if len(cfg.Search.Domains) > 0 {
 				return cfg.Search.Domains
 			}
+		case "exclude_domains":
+			if len(cfg.Search.ExcludeDomains) > 0 {
+				return cfg.Search.ExcludeDomains
+			}
 		case "recency":
 			if cfg.Search.Recency != "" {
 				return cfg.Search.Recency
@@ -510,6 +684,58 @@ func getConfigValue(cfg *ConfigData, section, fieldName string, defaultValue any
 			if cfg.Output.ReasoningEffort != "" {
 				return cfg.Output.ReasoningEffort
 			}
+		case "citation_max_per_domain":
+			if cfg.Output.CitationMaxPerDomain != 0 {
+				return cfg.Output.CitationMaxPerDomain
+			}
+		case "citation_exclude_domains":
+			if len(cfg.Output.CitationExcludeDomains) > 0 {
+				return cfg.Output.CitationExcludeDomains
+			}
+		case "citations":
+			if cfg.Output.Citations != "" {
+				return cfg.Output.Citations
+			}
+		case "image_conflict_policy":
+			if cfg.Output.ImageConflictPolicy != "" {
+				return cfg.Output.ImageConflictPolicy
+			}
+		case "strict_options":
+			if cfg.Output.StrictOptions {
+				return cfg.Output.StrictOptions
+			}
+		case "auto_continue":
+			if cfg.Output.AutoContinue != 0 {
+				return cfg.Output.AutoContinue
+			}
+		case "cache_ttl":
+			if cfg.Output.CacheTTL != 0 {
+				return cfg.Output.CacheTTL
+			}
+		case "pager":
+			if cfg.Output.Pager != "" {
+				return cfg.Output.Pager
+			}
+		case "stream_style":
+			if cfg.Output.StreamStyle != "" {
+				return cfg.Output.StreamStyle
+			}
+		case "stream_cps":
+			if cfg.Output.StreamCPS != 0 {
+				return cfg.Output.StreamCPS
+			}
+		case "theme":
+			if cfg.Output.Theme != "" {
+				return cfg.Output.Theme
+			}
+		case "history":
+			if cfg.Output.History != nil {
+				return *cfg.Output.History
+			}
+		case "config_backups":
+			if cfg.Output.ConfigBackups != 0 {
+				return cfg.Output.ConfigBackups
+			}
 		}
 	case SectionAPI:
 		switch fieldName {
@@ -517,14 +743,90 @@ func getConfigValue(cfg *ConfigData, section, fieldName string, defaultValue any
 			if cfg.API.Key != "" {
 				return cfg.API.Key
 			}
+		case "keys":
+			if len(cfg.API.Keys) > 0 {
+				return cfg.API.Keys
+			}
 		case "base_url":
 			if cfg.API.BaseURL != "" {
 				return cfg.API.BaseURL
 			}
+		case "proxy_url":
+			if cfg.API.ProxyURL != "" {
+				return cfg.API.ProxyURL
+			}
 		case "timeout":
 			if cfg.API.Timeout != 0 {
 				return cfg.API.Timeout
 			}
+		case "max_retries":
+			if cfg.API.MaxRetries != 0 {
+				return cfg.API.MaxRetries
+			}
+		case "retry_backoff":
+			if cfg.API.RetryBackoff != 0 {
+				return cfg.API.RetryBackoff
+			}
+		case "connect_timeout":
+			if cfg.API.ConnectTimeout != 0 {
+				return cfg.API.ConnectTimeout
+			}
+		case "response_header_timeout":
+			if cfg.API.ResponseHeaderTimeout != 0 {
+				return cfg.API.ResponseHeaderTimeout
+			}
+		case "total_timeout":
+			if cfg.API.TotalTimeout != 0 {
+				return cfg.API.TotalTimeout
+			}
+		case "stream_idle_timeout":
+			if cfg.API.StreamIdleTimeout != 0 {
+				return cfg.API.StreamIdleTimeout
+			}
+		case "mcp_auth_token":
+			if cfg.API.MCPAuthToken != "" {
+				return cfg.API.MCPAuthToken
+			}
+		case "audit_log":
+			if cfg.API.AuditLog != "" {
+				return cfg.API.AuditLog
+			}
+		case "system_prompt_prefix":
+			if cfg.API.SystemPromptPrefix != "" {
+				return cfg.API.SystemPromptPrefix
+			}
+		case "requests_per_minute":
+			if cfg.API.RequestsPerMinute != 0 {
+				return cfg.API.RequestsPerMinute
+			}
+		case "burst":
+			if cfg.API.Burst != 0 {
+				return cfg.API.Burst
+			}
+		case "daily_budget_usd":
+			if cfg.API.DailyBudgetUSD != 0 {
+				return cfg.API.DailyBudgetUSD
+			}
+		case "per_request_max_tokens":
+			if cfg.API.PerRequestMaxTokens != 0 {
+				return cfg.API.PerRequestMaxTokens
+			}
+		case "tls.ca_file":
+			if cfg.API.TLS.CAFile != "" {
+				return cfg.API.TLS.CAFile
+			}
+		case "tls.insecure_skip_verify":
+			if cfg.API.TLS.InsecureSkipVerify {
+				return cfg.API.TLS.InsecureSkipVerify
+			}
+		case "tls.client_cert":
+			if cfg.API.TLS.ClientCert != "" {
+				return cfg.API.TLS.ClientCert
+			}
+		case "tls.client_key":
+			if cfg.API.TLS.ClientKey != "" {
+				return cfg.API.TLS.ClientKey
+			}
 		}
 	}
 
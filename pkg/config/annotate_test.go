@@ -3,6 +3,7 @@ package config
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -620,6 +621,33 @@ func TestGenerateAnnotatedConfig_AllSections(t *testing.T) {
 	}
 }
 
+func TestGenerateAnnotatedConfig_Prompts(t *testing.T) {
+	cfg := NewConfigData()
+	result, err := GenerateAnnotatedConfig(cfg, DefaultAnnotationOptions())
+	if err != nil {
+		t.Fatalf("GenerateAnnotatedConfig() error = %v", err)
+	}
+	for _, want := range []string{"Prompt Templates", "# <name>.system:", "# prompts:", "#   review:"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+
+	cfg.Prompts = map[string]*PromptTemplate{"summary": {User: "Summarize {{topic}}"}}
+	result, err = GenerateAnnotatedConfig(cfg, DefaultAnnotationOptions())
+	if err != nil {
+		t.Fatalf("GenerateAnnotatedConfig() error = %v", err)
+	}
+
+	var parsed ConfigData
+	if err := yaml.Unmarshal([]byte(result), &parsed); err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	if p := parsed.Prompts["summary"]; p == nil || p.User != "Summarize {{topic}}" {
+		t.Errorf("prompts not preserved: %+v", parsed.Prompts)
+	}
+}
+
 func TestDefaultAnnotationOptions(t *testing.T) {
 	opts := DefaultAnnotationOptions()
 
@@ -645,3 +673,78 @@ func BenchmarkGenerateAnnotatedConfig(b *testing.B) {
 		_, _ = GenerateAnnotatedConfig(cfg, opts)
 	}
 }
+
+func TestGenerateAnnotatedConfig_Deterministic(t *testing.T) {
+	cfg := NewConfigData()
+	cfg.Defaults.Model = "sonar-pro"
+	cfg.Profiles = map[string]*Profile{
+		"research": {Name: "research"},
+		"creative": {Name: "creative"},
+		"news":     {Name: "news"},
+	}
+	opts := DefaultAnnotationOptions()
+
+	first, err := GenerateAnnotatedConfig(cfg, opts)
+	if err != nil {
+		t.Fatalf("GenerateAnnotatedConfig() error = %v", err)
+	}
+	for range 5 {
+		again, err := GenerateAnnotatedConfig(cfg, opts)
+		if err != nil {
+			t.Fatalf("GenerateAnnotatedConfig() error = %v", err)
+		}
+		if again != first {
+			t.Fatal("GenerateAnnotatedConfig() output differs between runs")
+		}
+	}
+
+	// Fields follow registration order.
+	if m, tmp := strings.Index(first, "  model:"), strings.Index(first, "  temperature:"); m < 0 || m > tmp {
+		t.Error("defaults.model should come before defaults.temperature")
+	}
+}
+
+func TestGenerateAnnotatedConfig_Deprecated(t *testing.T) {
+	opts := DefaultAnnotationOptions()
+
+	unset, err := GenerateAnnotatedConfig(NewConfigData(), opts)
+	if err != nil {
+		t.Fatalf("GenerateAnnotatedConfig() error = %v", err)
+	}
+	if strings.Contains(unset, "DEPRECATED") {
+		t.Error("unset deprecated options should be skipped")
+	}
+
+	cfg := NewConfigData()
+	cfg.API.Timeout = 45 * time.Second
+	set, err := GenerateAnnotatedConfig(cfg, opts)
+	if err != nil {
+		t.Fatalf("GenerateAnnotatedConfig() error = %v", err)
+	}
+	if !strings.Contains(set, "# DEPRECATED: use api.total_timeout") || !strings.Contains(set, "  timeout: 45s") {
+		t.Errorf("deprecated option still set should be kept with a DEPRECATED comment:\n%s", set)
+	}
+}
+
+func TestGenerateAnnotatedConfig_NestedBlock(t *testing.T) {
+	cfg := NewConfigData()
+	cfg.API.TLS = TLSConfig{CAFile: "/etc/ssl/corp.pem", InsecureSkipVerify: true}
+	result, err := GenerateAnnotatedConfig(cfg, DefaultAnnotationOptions())
+	if err != nil {
+		t.Fatalf("GenerateAnnotatedConfig() error = %v", err)
+	}
+	if strings.Count(result, "\n  tls:\n") != 1 || !strings.Contains(result, "\n    ca_file: /etc/ssl/corp.pem\n") {
+		t.Errorf("api.tls options should be written in one nested block:\n%s", result)
+	}
+	if !strings.Contains(result, "    # WARNING: INSECURE.") {
+		t.Error("the insecure option should carry its warning, indented with the block")
+	}
+
+	var parsed ConfigData
+	if err := yaml.Unmarshal([]byte(result), &parsed); err != nil {
+		t.Fatalf("failed to parse generated YAML: %v", err)
+	}
+	if parsed.API.TLS != cfg.API.TLS {
+		t.Errorf("parsed api.tls = %+v, want %+v", parsed.API.TLS, cfg.API.TLS)
+	}
+}
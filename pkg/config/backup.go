@@ -0,0 +1,292 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+const (
+	// BackupDirName is the directory, next to the config file, holding its
+	// backups.
+	BackupDirName = "backups"
+	// DefaultConfigBackups is how many backups are kept when
+	// output.config_backups is not set.
+	DefaultConfigBackups = 10
+	// LatestBackup names the most recent backup in RestoreBackup.
+	LatestBackup = "latest"
+
+	// backupPrefix and backupSuffix surround the timestamp in backup names.
+	backupPrefix = "config-"
+	backupSuffix = ".yaml"
+	// backupTimeFormat is the timestamp of backup names. It sorts in time
+	// order and is precise enough for several writes in one second.
+	backupTimeFormat = "20060102-150405.000"
+)
+
+// Backup is a copy of the config file saved before it was rewritten.
+type Backup struct {
+	// Timestamp identifies the backup in RestoreBackup.
+	Timestamp string
+	Path      string
+	Size      int64
+}
+
+// BackupDir returns the directory holding the backups of the config file
+// at configPath.
+func BackupDir(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), BackupDirName)
+}
+
+// BackupConfigFile copies the config file at configPath to
+// BackupDir/config-<timestamp>.yaml with the same file mode, then deletes
+// the oldest backups so that at most keep remain; keep <= 0 keeps
+// DefaultConfigBackups. It returns the path of the backup, or "" when there
+// is no file to back up.
+func BackupConfigFile(configPath string, keep int) (string, error) {
+	content, err := os.ReadFile(configPath) //nolint:gosec // path is the user's own config file
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read config file %s: %w", configPath, err)
+	}
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config file %s: %w", configPath, err)
+	}
+
+	dir := BackupDir(configPath)
+	if err := os.MkdirAll(dir, editedDirPermission); err != nil {
+		return "", fmt.Errorf("failed to create backup directory %s: %w", dir, err)
+	}
+	path, err := writeBackup(dir, content, info.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+	if err := pruneBackups(configPath, keep); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// writeBackup writes content to a new backup in dir with mode perm. A name
+// already taken moves the timestamp forward by a millisecond.
+func writeBackup(dir string, content []byte, perm fs.FileMode) (string, error) {
+	stamp := time.Now()
+	for {
+		path := filepath.Join(dir, backupPrefix+stamp.Format(backupTimeFormat)+backupSuffix)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm) //nolint:gosec // path is built above
+		if errors.Is(err, fs.ErrExist) {
+			stamp = stamp.Add(time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write backup %s: %w", path, err)
+		}
+		_, err = f.Write(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		// The umask may have narrowed perm on creation.
+		if err == nil {
+			err = os.Chmod(path, perm)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write backup %s: %w", path, err)
+		}
+		return path, nil
+	}
+}
+
+// pruneBackups deletes the oldest backups of configPath beyond keep.
+func pruneBackups(configPath string, keep int) error {
+	if keep <= 0 {
+		keep = DefaultConfigBackups
+	}
+	backups, err := ListBackups(configPath)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(b.Path); err != nil {
+			return fmt.Errorf("failed to remove old backup %s: %w", b.Path, err)
+		}
+	}
+	return nil
+}
+
+// ListBackups returns the backups of the config file at configPath, newest
+// first. A missing backup directory yields none.
+func ListBackups(configPath string) ([]Backup, error) {
+	dir := BackupDir(configPath)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory %s: %w", dir, err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		stamp, ok := backupTimestamp(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		backup := Backup{Timestamp: stamp, Path: filepath.Join(dir, entry.Name())}
+		if info, err := entry.Info(); err == nil {
+			backup.Size = info.Size()
+		}
+		backups = append(backups, backup)
+	}
+	slices.SortFunc(backups, func(a, b Backup) int { return strings.Compare(b.Timestamp, a.Timestamp) })
+	return backups, nil
+}
+
+// RedactBackups replaces secret with replacement in every backup of the
+// config file at configPath, keeping their file modes, so that a value
+// taken out of the config file does not remain in its backups. It returns
+// the number of backups changed; an empty secret changes none.
+func RedactBackups(configPath, secret, replacement string) (int, error) {
+	if secret == "" {
+		return 0, nil
+	}
+	backups, err := ListBackups(configPath)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for _, b := range backups {
+		content, err := os.ReadFile(b.Path) //nolint:gosec // path is a backup of the config file
+		if err != nil {
+			return changed, fmt.Errorf("failed to read backup %s: %w", b.Path, err)
+		}
+		if !bytes.Contains(content, []byte(secret)) {
+			continue
+		}
+		info, err := os.Stat(b.Path)
+		if err != nil {
+			return changed, fmt.Errorf("failed to read backup %s: %w", b.Path, err)
+		}
+		redacted := bytes.ReplaceAll(content, []byte(secret), []byte(replacement))
+		if err := os.WriteFile(b.Path, redacted, info.Mode().Perm()); err != nil {
+			return changed, fmt.Errorf("failed to redact backup %s: %w", b.Path, err)
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// backupTimestamp returns the timestamp of a backup file name.
+func backupTimestamp(name string) (string, bool) {
+	stamp, ok := strings.CutPrefix(name, backupPrefix)
+	if !ok {
+		return "", false
+	}
+	stamp, ok = strings.CutSuffix(stamp, backupSuffix)
+	if !ok {
+		return "", false
+	}
+	if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+		return "", false
+	}
+	return stamp, true
+}
+
+// FindBackup returns the backup of configPath with the timestamp ref, or
+// the newest one when ref is LatestBackup. It returns
+// clerrors.ErrBackupNotFound when there is none.
+func FindBackup(configPath, ref string) (Backup, error) {
+	backups, err := ListBackups(configPath)
+	if err != nil {
+		return Backup{}, err
+	}
+	if ref == LatestBackup && len(backups) > 0 {
+		return backups[0], nil
+	}
+	for _, b := range backups {
+		if b.Timestamp == ref {
+			return b, nil
+		}
+	}
+	return Backup{}, fmt.Errorf("%w: %q in %s", clerrors.ErrBackupNotFound, ref, BackupDir(configPath))
+}
+
+// RestoreBackup replaces the config file at configPath with its backup ref
+// (a timestamp or LatestBackup). The backup is loaded and validated first,
+// next to configPath so that relative includes resolve as they would; an
+// invalid backup returns clerrors.ErrInvalidBackup and leaves the config
+// file untouched. The displaced config file is backed up like any rewrite,
+// keeping keep backups, and its backup path is returned with the restored
+// backup.
+func RestoreBackup(configPath, ref string, keep int) (Backup, string, error) {
+	backup, err := FindBackup(configPath, ref)
+	if err != nil {
+		return Backup{}, "", err
+	}
+	content, err := os.ReadFile(backup.Path) //nolint:gosec // path is a backup of the config file
+	if err != nil {
+		return Backup{}, "", fmt.Errorf("failed to read backup %s: %w", backup.Path, err)
+	}
+	info, err := os.Stat(backup.Path)
+	if err != nil {
+		return Backup{}, "", fmt.Errorf("failed to read backup %s: %w", backup.Path, err)
+	}
+
+	staged, err := stageRestore(configPath, content, info.Mode().Perm())
+	if err != nil {
+		return Backup{}, "", err
+	}
+	defer func() { _ = os.Remove(staged) }()
+
+	loader := NewLoader()
+	if err := loader.LoadFrom(staged); err != nil {
+		return Backup{}, "", fmt.Errorf("%w: %s: %w", clerrors.ErrInvalidBackup, backup.Timestamp, err)
+	}
+	if err := NewValidator().WithKeys(loader.Origins()).Validate(loader.Data()); err != nil {
+		return Backup{}, "", fmt.Errorf("%w: %s: %w", clerrors.ErrInvalidBackup, backup.Timestamp, err)
+	}
+
+	displaced, err := BackupConfigFile(configPath, keep)
+	if err != nil {
+		return Backup{}, "", err
+	}
+	if err := os.Rename(staged, configPath); err != nil {
+		return Backup{}, "", fmt.Errorf("failed to restore config file %s: %w", configPath, err)
+	}
+	return backup, displaced, nil
+}
+
+// stageRestore writes content with mode perm to a temporary file in the
+// directory of configPath, from which it is renamed over configPath.
+func stageRestore(configPath string, content []byte, perm fs.FileMode) (string, error) {
+	dir := filepath.Dir(configPath)
+	if err := os.MkdirAll(dir, editedDirPermission); err != nil {
+		return "", fmt.Errorf("failed to create config directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, ".config-restore-*"+backupSuffix)
+	if err != nil {
+		return "", fmt.Errorf("failed to stage restore in %s: %w", dir, err)
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("failed to stage restore in %s: %w", dir, err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// writeBackupFile writes content as the backup of configPath with the
+// timestamp stamp.
+func writeBackupFile(t *testing.T, configPath, stamp, content string) {
+	t.Helper()
+	dir := BackupDir(configPath)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, backupPrefix+stamp+backupSuffix), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func backupTimestamps(t *testing.T, configPath string) []string {
+	t.Helper()
+	backups, err := ListBackups(configPath)
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	stamps := make([]string, 0, len(backups))
+	for _, b := range backups {
+		stamps = append(stamps, b.Timestamp)
+	}
+	return stamps
+}
+
+func TestBackupConfigFile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	path, err := BackupConfigFile(configPath, 3)
+	if err != nil || path != "" {
+		t.Fatalf("BackupConfigFile() of a missing file = %q, %v, want no backup", path, err)
+	}
+
+	if err := os.WriteFile(configPath, []byte("defaults:\n  model: sonar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(configPath, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	old := []string{"20240101-000000.000", "20240102-000000.000", "20240103-000000.000"}
+	for _, stamp := range old {
+		writeBackupFile(t, configPath, stamp, "defaults:\n  model: sonar-pro\n")
+	}
+	// Files that are not backups are left alone.
+	writeBackupFile(t, configPath, "notes", "")
+
+	path, err = BackupConfigFile(configPath, 3)
+	if err != nil {
+		t.Fatalf("BackupConfigFile() error = %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "defaults:\n  model: sonar\n" {
+		t.Errorf("backup content = %q, %v, want the config file", content, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("backup mode = %v, %v, want 0640 like the config file", info.Mode().Perm(), err)
+	}
+
+	stamps := backupTimestamps(t, configPath)
+	if len(stamps) != 3 || slices.Contains(stamps, old[0]) || !slices.Contains(stamps, old[2]) {
+		t.Errorf("backups after pruning = %q, want the 3 newest", stamps)
+	}
+	if _, err := os.Stat(filepath.Join(BackupDir(configPath), "config-notes.yaml")); err != nil {
+		t.Errorf("pruning removed a file that is not a backup: %v", err)
+	}
+
+	// Backups in the same millisecond get distinct names.
+	for range 2 {
+		if _, err := BackupConfigFile(configPath, 10); err != nil {
+			t.Fatalf("BackupConfigFile() error = %v", err)
+		}
+	}
+	if stamps := backupTimestamps(t, configPath); len(stamps) != 5 {
+		t.Errorf("backups = %q, want 5", stamps)
+	}
+}
+
+func TestRedactBackups(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if n, err := RedactBackups(configPath, "pplx-secret", "enc:v1:abc"); err != nil || n != 0 {
+		t.Fatalf("RedactBackups() without backups = %d, %v, want 0", n, err)
+	}
+
+	writeBackupFile(t, configPath, "20240101-000000.000", "api:\n  key: pplx-secret\n")
+	writeBackupFile(t, configPath, "20240102-000000.000", "defaults:\n  model: sonar\n")
+	// Files that are not backups are left alone.
+	writeBackupFile(t, configPath, "notes", "pplx-secret")
+
+	n, err := RedactBackups(configPath, "pplx-secret", "enc:v1:abc")
+	if err != nil || n != 1 {
+		t.Fatalf("RedactBackups() = %d, %v, want 1 backup changed", n, err)
+	}
+	dir := BackupDir(configPath)
+	content, _ := os.ReadFile(filepath.Join(dir, "config-20240101-000000.000.yaml"))
+	if string(content) != "api:\n  key: enc:v1:abc\n" {
+		t.Errorf("redacted backup = %q, want the replacement", content)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "config-20240101-000000.000.yaml")); err != nil ||
+		info.Mode().Perm() != 0o600 {
+		t.Errorf("redacted backup mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	content, _ = os.ReadFile(filepath.Join(dir, "config-notes.yaml"))
+	if string(content) != "pplx-secret" {
+		t.Errorf("file that is not a backup = %q, want it unchanged", content)
+	}
+	if n, err := RedactBackups(configPath, "", "enc:v1:abc"); err != nil || n != 0 {
+		t.Errorf("RedactBackups() of an empty secret = %d, %v, want 0", n, err)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	current := "defaults:\n  model: sonar\n"
+	if err := os.WriteFile(configPath, []byte(current), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	writeBackupFile(t, configPath, "20240101-000000.000", "defaults:\n  model: sonar-pro\n")
+	writeBackupFile(t, configPath, "20240102-000000.000", "defaults:\n  model: sonar-reasoning\n")
+
+	restored, displaced, err := RestoreBackup(configPath, "20240101-000000.000", 10)
+	if err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	if restored.Timestamp != "20240101-000000.000" {
+		t.Errorf("restored = %q, want 20240101-000000.000", restored.Timestamp)
+	}
+	if content, _ := os.ReadFile(configPath); string(content) != "defaults:\n  model: sonar-pro\n" {
+		t.Errorf("config file = %q, want the backup", content)
+	}
+	if content, _ := os.ReadFile(displaced); string(content) != current {
+		t.Errorf("displaced backup %s = %q, want the replaced config file", displaced, content)
+	}
+
+	// latest is now the displaced file.
+	if _, _, err := RestoreBackup(configPath, LatestBackup, 10); err != nil {
+		t.Fatalf("RestoreBackup(latest) error = %v", err)
+	}
+	if content, _ := os.ReadFile(configPath); string(content) != current {
+		t.Errorf("config file = %q, want the displaced file back", content)
+	}
+
+	if _, _, err := RestoreBackup(configPath, "20990101-000000.000", 10); !errors.Is(err, clerrors.ErrBackupNotFound) {
+		t.Errorf("RestoreBackup() of an unknown timestamp error = %v, want ErrBackupNotFound", err)
+	}
+}
+
+func TestRestoreBackup_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "corrupt YAML", content: "defaults: [model: sonar\n"},
+		{name: "fails validation", content: "defaults:\n  temperature: 5\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "config.yaml")
+			current := "defaults:\n  model: sonar\n"
+			if err := os.WriteFile(configPath, []byte(current), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			writeBackupFile(t, configPath, "20240101-000000.000", tt.content)
+
+			_, _, err := RestoreBackup(configPath, LatestBackup, 10)
+			if !errors.Is(err, clerrors.ErrInvalidBackup) {
+				t.Fatalf("RestoreBackup() error = %v, want ErrInvalidBackup", err)
+			}
+			if content, _ := os.ReadFile(configPath); string(content) != current {
+				t.Errorf("config file = %q, want it untouched", content)
+			}
+			if stamps := backupTimestamps(t, configPath); len(stamps) != 1 {
+				t.Errorf("backups = %q, want no new backup", stamps)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 2 {
+				t.Errorf("config directory holds %d entries, want the staged file removed", len(entries))
+			}
+		})
+	}
+}
@@ -51,6 +51,12 @@ type ConfigData struct {
 	// API contains API configuration
 	API APIConfig `json:"api" mapstructure:"api" yaml:"api"`
 
+	// Models contains model aliases accepted wherever a model name is
+	Models ModelsConfig `json:"models,omitzero" mapstructure:"models" yaml:"models,omitempty"`
+
+	// Prompts contains named prompt templates used with --prompt-template
+	Prompts map[string]*PromptTemplate `json:"prompts,omitempty" mapstructure:"prompts" yaml:"prompts,omitempty"`
+
 	// Profiles contains named configuration profiles
 	Profiles map[string]*Profile `json:"profiles,omitempty" mapstructure:"profiles" yaml:"profiles,omitempty"`
 
@@ -68,14 +74,23 @@ type DefaultsConfig struct {
 	FrequencyPenalty float64 `json:"frequency_penalty,omitempty" mapstructure:"frequency_penalty" yaml:"frequency_penalty,omitempty"` //nolint:lll
 	PresencePenalty  float64 `json:"presence_penalty,omitempty"  mapstructure:"presence_penalty"  yaml:"presence_penalty,omitempty"`  //nolint:lll
 	Timeout          string  `json:"timeout,omitempty"           mapstructure:"timeout"           yaml:"timeout,omitempty"`
+	// Language is the ISO 639-1 code of the language answers are requested in.
+	Language string `json:"language,omitempty" mapstructure:"language" yaml:"language,omitempty"`
+	// SystemPrompt is the system prompt of requests given none on the command
+	// line. A "file://path" value is replaced by the content of that file.
+	SystemPrompt string `json:"system_prompt,omitempty" mapstructure:"system_prompt" yaml:"system_prompt,omitempty"`
+	// ModelFallbacks are the models a request is sent to, in order, when the
+	// API reports that the model is unavailable or invalid.
+	ModelFallbacks []string `json:"model_fallbacks,omitempty" mapstructure:"model_fallbacks" yaml:"model_fallbacks,omitempty"` //nolint:lll
 }
 
 // SearchConfig contains search-related preferences.
 type SearchConfig struct {
-	Domains     []string `json:"domains,omitempty"      mapstructure:"domains"      yaml:"domains,omitempty"`
-	Recency     string   `json:"recency,omitempty"      mapstructure:"recency"      yaml:"recency,omitempty"`
-	Mode        string   `json:"mode,omitempty"         mapstructure:"mode"         yaml:"mode,omitempty"`
-	ContextSize string   `json:"context_size,omitempty" mapstructure:"context_size" yaml:"context_size,omitempty"`
+	Domains        []string `json:"domains,omitempty"         mapstructure:"domains"         yaml:"domains,omitempty"`
+	ExcludeDomains []string `json:"exclude_domains,omitempty" mapstructure:"exclude_domains" yaml:"exclude_domains,omitempty"` //nolint:lll
+	Recency        string   `json:"recency,omitempty"         mapstructure:"recency"         yaml:"recency,omitempty"`
+	Mode           string   `json:"mode,omitempty"            mapstructure:"mode"            yaml:"mode,omitempty"`
+	ContextSize    string   `json:"context_size,omitempty"    mapstructure:"context_size"    yaml:"context_size,omitempty"`
 
 	// Location preferences
 	LocationLat     float64 `json:"location_lat,omitempty"     mapstructure:"location_lat"     yaml:"location_lat,omitempty"`     //nolint:lll
@@ -106,24 +121,152 @@ type OutputConfig struct {
 
 	// Deep research
 	ReasoningEffort string `json:"reasoning_effort,omitempty" mapstructure:"reasoning_effort" yaml:"reasoning_effort,omitempty"` //nolint:lll
+
+	// Citations is the citation style: list, inline, footnote, or none.
+	Citations string `json:"citations,omitempty" mapstructure:"citations" yaml:"citations,omitempty"`
+	// CitationMaxPerDomain is how many sources of one domain are listed;
+	// the URLs of the others are grouped beneath the last. Zero is no limit.
+	CitationMaxPerDomain int `json:"citation_max_per_domain,omitempty" mapstructure:"citation_max_per_domain" yaml:"citation_max_per_domain,omitempty"` //nolint:lll
+	// CitationExcludeDomains are hidden from the sources, with their markers.
+	CitationExcludeDomains []string `json:"citation_exclude_domains,omitempty" mapstructure:"citation_exclude_domains" yaml:"citation_exclude_domains,omitempty"` //nolint:lll
+
+	// CacheTTL enables the response cache for queries and sets how long entries are served.
+	CacheTTL time.Duration `json:"cache_ttl,omitempty" mapstructure:"cache_ttl" yaml:"cache_ttl,omitempty"`
+
+	// ImageConflictPolicy decides which of search recency and images is kept
+	// when both are requested: prefer_images, prefer_recency, or error.
+	ImageConflictPolicy string `json:"image_conflict_policy,omitempty" mapstructure:"image_conflict_policy" yaml:"image_conflict_policy,omitempty"` //nolint:lll
+
+	// StrictOptions rejects option combinations the API ignores, such as
+	// streaming with a response format, instead of adjusting the request
+	// with a warning.
+	StrictOptions bool `json:"strict_options,omitempty" mapstructure:"strict_options" yaml:"strict_options,omitempty"`
+
+	// AutoContinue is how many follow-up requests complete an answer cut off
+	// at max_tokens; zero disables them.
+	AutoContinue int `json:"auto_continue,omitempty" mapstructure:"auto_continue" yaml:"auto_continue,omitempty"`
+
+	// Pager is the command that shows query answers taller than the terminal;
+	// empty uses $PAGER, then less -R.
+	Pager string `json:"pager,omitempty" mapstructure:"pager" yaml:"pager,omitempty"`
+
+	// StreamStyle is how streamed answers are written: instant, or smooth
+	// for a word-by-word typewriter effect.
+	StreamStyle string `json:"stream_style,omitempty" mapstructure:"stream_style" yaml:"stream_style,omitempty"`
+	// StreamCPS is the rate of smooth streaming in characters per second;
+	// zero uses the default.
+	StreamCPS int `json:"stream_cps,omitempty" mapstructure:"stream_cps" yaml:"stream_cps,omitempty"`
+
+	// Theme is the palette of colored output: default, or high-contrast.
+	Theme string `json:"theme,omitempty" mapstructure:"theme" yaml:"theme,omitempty"`
+
+	// History records the user prompt of each query in the prompt history;
+	// nil means true. See HistoryEnabled.
+	History *bool `json:"history,omitempty" mapstructure:"history" yaml:"history,omitempty"`
+
+	// ConfigBackups is how many backups of the config file are kept in
+	// backups/ next to it; zero uses the default.
+	ConfigBackups int `json:"config_backups,omitempty" mapstructure:"config_backups" yaml:"config_backups,omitempty"`
+}
+
+// HistoryEnabled reports whether queries are recorded in the prompt
+// history: unless output.history is false.
+func (o *OutputConfig) HistoryEnabled() bool {
+	return o.History == nil || *o.History
 }
 
 // APIConfig contains API-related configuration.
 type APIConfig struct {
-	Key     string        `json:"key,omitempty"      mapstructure:"key"      yaml:"key,omitempty"`
-	BaseURL string        `json:"base_url,omitempty" mapstructure:"base_url" yaml:"base_url,omitempty"`
-	Timeout time.Duration `json:"timeout,omitempty"  mapstructure:"timeout"  yaml:"timeout,omitempty"`
+	Key          string        `json:"key,omitempty"           mapstructure:"key"           yaml:"key,omitempty"`
+	// Keys are named API keys that MCP tool calls select with key_id.
+	Keys         map[string]string `json:"keys,omitempty"      mapstructure:"keys"          yaml:"keys,omitempty"`
+	BaseURL      string        `json:"base_url,omitempty"      mapstructure:"base_url"      yaml:"base_url,omitempty"`
+	// ProxyURL is the HTTP proxy API calls go through. Empty uses HTTPS_PROXY.
+	ProxyURL     string        `json:"proxy_url,omitempty"     mapstructure:"proxy_url"     yaml:"proxy_url,omitempty"`
+	Timeout      time.Duration `json:"timeout,omitempty"       mapstructure:"timeout"       yaml:"timeout,omitempty"`
+	MaxRetries   int           `json:"max_retries,omitempty"   mapstructure:"max_retries"   yaml:"max_retries,omitempty"`
+	RetryBackoff time.Duration `json:"retry_backoff,omitempty" mapstructure:"retry_backoff" yaml:"retry_backoff,omitempty"`
+	// ConnectTimeout bounds establishing the connection to the API.
+	ConnectTimeout time.Duration `json:"connect_timeout,omitempty" mapstructure:"connect_timeout" yaml:"connect_timeout,omitempty"`
+	// ResponseHeaderTimeout bounds the wait for response headers after the request is sent.
+	ResponseHeaderTimeout time.Duration `json:"response_header_timeout,omitempty" mapstructure:"response_header_timeout" yaml:"response_header_timeout,omitempty"` //nolint:lll
+	// TotalTimeout bounds one API call, retries included. defaults.timeout,
+	// which profiles and --timeout set, overrides it.
+	TotalTimeout time.Duration `json:"total_timeout,omitempty" mapstructure:"total_timeout" yaml:"total_timeout,omitempty"`
+	// StreamIdleTimeout aborts a streaming request of the MCP server that
+	// receives no data for that long. Zero uses the default of 60s.
+	StreamIdleTimeout time.Duration `json:"stream_idle_timeout,omitempty" mapstructure:"stream_idle_timeout" yaml:"stream_idle_timeout,omitempty"` //nolint:lll
+	// MCPAuthToken is the bearer token required by `pplx mcp-http`. Empty disables auth.
+	MCPAuthToken string `json:"mcp_auth_token,omitempty" mapstructure:"mcp_auth_token" yaml:"mcp_auth_token,omitempty"`
+	// AuditLog is a JSON lines file recording every request chat and the
+	// MCP server send, and their outcome. Empty disables the audit log.
+	AuditLog string `json:"audit_log,omitempty" mapstructure:"audit_log" yaml:"audit_log,omitempty"`
+	// SystemPromptPrefix is prepended to the system message of every request
+	// chat and the MCP server send.
+	SystemPromptPrefix string `json:"system_prompt_prefix,omitempty" mapstructure:"system_prompt_prefix" yaml:"system_prompt_prefix,omitempty"` //nolint:lll
+	// RequestsPerMinute caps the requests the MCP server and batch mode
+	// send, shared by all of their parallel requests. Zero disables limiting.
+	RequestsPerMinute int `json:"requests_per_minute,omitempty" mapstructure:"requests_per_minute" yaml:"requests_per_minute,omitempty"` //nolint:lll
+	// Burst is how many requests may be sent at once before the rate applies.
+	Burst int `json:"burst,omitempty" mapstructure:"burst" yaml:"burst,omitempty"`
+	// DailyBudgetUSD caps the estimated cost of the requests query and the
+	// MCP server send each day, by the usage log. Zero disables the budget.
+	DailyBudgetUSD float64 `json:"daily_budget_usd,omitempty" mapstructure:"daily_budget_usd" yaml:"daily_budget_usd,omitempty"` //nolint:lll
+	// PerRequestMaxTokens caps max_tokens of every request query and the
+	// MCP server send. Zero is no cap.
+	PerRequestMaxTokens int `json:"per_request_max_tokens,omitempty" mapstructure:"per_request_max_tokens" yaml:"per_request_max_tokens,omitempty"` //nolint:lll
+	// TLS configures certificate verification of the API connection.
+	TLS TLSConfig `json:"tls,omitzero" mapstructure:"tls" yaml:"tls,omitempty"`
+}
+
+// TLSConfig configures the TLS connection to the API, for corporate
+// proxies that re-sign traffic and gateways that require mutual TLS.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of certificate authorities trusted in addition
+	// to the system ones.
+	CAFile string `json:"ca_file,omitempty" mapstructure:"ca_file" yaml:"ca_file,omitempty"`
+	// InsecureSkipVerify disables certificate verification. For debugging only.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty" mapstructure:"insecure_skip_verify" yaml:"insecure_skip_verify,omitempty"` //nolint:lll
+	// ClientCert and ClientKey are the PEM certificate and key presented
+	// for mutual TLS. Both or neither must be set.
+	ClientCert string `json:"client_cert,omitempty" mapstructure:"client_cert" yaml:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"  mapstructure:"client_key"  yaml:"client_key,omitempty"`
+}
+
+// IsZero reports whether no TLS option is set.
+func (t TLSConfig) IsZero() bool {
+	return t == TLSConfig{}
+}
+
+// PromptTemplate is a named prompt whose system and user text may contain
+// {{variable}} placeholders filled from --var flags.
+type PromptTemplate struct {
+	Description string `json:"description,omitempty" mapstructure:"description" yaml:"description,omitempty"`
+	System      string `json:"system,omitempty"      mapstructure:"system"      yaml:"system,omitempty"`
+	User        string `json:"user,omitempty"        mapstructure:"user"        yaml:"user,omitempty"`
+}
+
+// ModelsConfig holds model aliases: short names such as "fast" that expand
+// to a model ID such as "sonar" in --model, defaults.model, and the MCP
+// model argument. Aliases of the active profile win over the global ones.
+type ModelsConfig struct {
+	Aliases map[string]string `json:"aliases,omitempty" mapstructure:"aliases" yaml:"aliases,omitempty"`
 }
 
 // Profile represents a named configuration profile.
 // Uses pointer-based override types so that absent fields (nil) preserve the base
 // config value while present fields (including zero/false) override it.
+//
+// Extends names a parent profile whose settings are inherited; the child's
+// non-nil fields win over the parent's, which win over the base config.
 type Profile struct {
 	Name        string          `json:"name"                  mapstructure:"name"        yaml:"name"`
 	Description string          `json:"description,omitempty" mapstructure:"description" yaml:"description,omitempty"`
+	Extends     string          `json:"extends,omitempty"     mapstructure:"extends"     yaml:"extends,omitempty"`
 	Defaults    ProfileDefaults `json:"defaults,omitzero"     mapstructure:"defaults"    yaml:"defaults,omitempty"`
 	Search      ProfileSearch   `json:"search,omitzero"       mapstructure:"search"      yaml:"search,omitempty"`
 	Output      ProfileOutput   `json:"output,omitzero"       mapstructure:"output"      yaml:"output,omitempty"`
+	Models      ModelsConfig    `json:"models,omitzero"       mapstructure:"models"      yaml:"models,omitempty"`
 }
 
 // ProfileDefaults uses pointers to distinguish "not set" (nil) from "set to zero".
@@ -136,11 +279,17 @@ type ProfileDefaults struct {
 	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty" mapstructure:"frequency_penalty" yaml:"frequency_penalty,omitempty"` //nolint:lll
 	PresencePenalty  *float64 `json:"presence_penalty,omitempty"  mapstructure:"presence_penalty"  yaml:"presence_penalty,omitempty"`  //nolint:lll
 	Timeout          *string  `json:"timeout,omitempty"           mapstructure:"timeout"           yaml:"timeout,omitempty"`
+	Language         *string  `json:"language,omitempty"          mapstructure:"language"          yaml:"language,omitempty"`
+	SystemPrompt     *string  `json:"system_prompt,omitempty"     mapstructure:"system_prompt"     yaml:"system_prompt,omitempty"`
+	// ModelFallbacks is a pointer to a slice so that a profile can clear the
+	// fallbacks with an empty list.
+	ModelFallbacks *[]string `json:"model_fallbacks,omitempty" mapstructure:"model_fallbacks" yaml:"model_fallbacks,omitempty"` //nolint:lll
 }
 
 // ProfileSearch uses pointers to distinguish "not set" from "set to empty/zero".
 type ProfileSearch struct {
 	Domains           *[]string `json:"domains,omitempty"             mapstructure:"domains"             yaml:"domains,omitempty"`
+	ExcludeDomains    *[]string `json:"exclude_domains,omitempty"     mapstructure:"exclude_domains"     yaml:"exclude_domains,omitempty"` //nolint:lll
 	Recency           *string   `json:"recency,omitempty"             mapstructure:"recency"             yaml:"recency,omitempty"`
 	Mode              *string   `json:"mode,omitempty"                mapstructure:"mode"                yaml:"mode,omitempty"`
 	ContextSize       *string   `json:"context_size,omitempty"        mapstructure:"context_size"        yaml:"context_size,omitempty"`        //nolint:lll
@@ -155,15 +304,26 @@ type ProfileSearch struct {
 
 // ProfileOutput uses pointers to distinguish "not set" from "set to false".
 type ProfileOutput struct {
-	Stream                   *bool     `json:"stream,omitempty"                      mapstructure:"stream"                      yaml:"stream,omitempty"`                      //nolint:lll
-	ReturnImages             *bool     `json:"return_images,omitempty"               mapstructure:"return_images"               yaml:"return_images,omitempty"`               //nolint:lll
-	ReturnRelated            *bool     `json:"return_related,omitempty"              mapstructure:"return_related"              yaml:"return_related,omitempty"`              //nolint:lll
-	JSON                     *bool     `json:"json,omitempty"                        mapstructure:"json"                        yaml:"json,omitempty"`
-	ImageDomains             *[]string `json:"image_domains,omitempty"               mapstructure:"image_domains"               yaml:"image_domains,omitempty"`               //nolint:lll
-	ImageFormats             *[]string `json:"image_formats,omitempty"               mapstructure:"image_formats"               yaml:"image_formats,omitempty"`               //nolint:lll
-	ResponseFormatJSONSchema *string   `json:"response_format_json_schema,omitempty" mapstructure:"response_format_json_schema" yaml:"response_format_json_schema,omitempty"` //nolint:lll
-	ResponseFormatRegex      *string   `json:"response_format_regex,omitempty"       mapstructure:"response_format_regex"       yaml:"response_format_regex,omitempty"`       //nolint:lll
-	ReasoningEffort          *string   `json:"reasoning_effort,omitempty"            mapstructure:"reasoning_effort"            yaml:"reasoning_effort,omitempty"`            //nolint:lll
+	Stream                   *bool          `json:"stream,omitempty"                      mapstructure:"stream"                      yaml:"stream,omitempty"                     ` //nolint:lll
+	ReturnImages             *bool          `json:"return_images,omitempty"               mapstructure:"return_images"               yaml:"return_images,omitempty"              ` //nolint:lll
+	ReturnRelated            *bool          `json:"return_related,omitempty"              mapstructure:"return_related"              yaml:"return_related,omitempty"             ` //nolint:lll
+	JSON                     *bool          `json:"json,omitempty"                        mapstructure:"json"                        yaml:"json,omitempty"`
+	ImageDomains             *[]string      `json:"image_domains,omitempty"               mapstructure:"image_domains"               yaml:"image_domains,omitempty"              ` //nolint:lll
+	ImageFormats             *[]string      `json:"image_formats,omitempty"               mapstructure:"image_formats"               yaml:"image_formats,omitempty"              ` //nolint:lll
+	ResponseFormatJSONSchema *string        `json:"response_format_json_schema,omitempty" mapstructure:"response_format_json_schema" yaml:"response_format_json_schema,omitempty"` //nolint:lll
+	ResponseFormatRegex      *string        `json:"response_format_regex,omitempty"       mapstructure:"response_format_regex"       yaml:"response_format_regex,omitempty"      ` //nolint:lll
+	ReasoningEffort          *string        `json:"reasoning_effort,omitempty"            mapstructure:"reasoning_effort"            yaml:"reasoning_effort,omitempty"           ` //nolint:lll
+	Citations                *string        `json:"citations,omitempty"                   mapstructure:"citations"                   yaml:"citations,omitempty"                  ` //nolint:lll
+	CitationMaxPerDomain     *int           `json:"citation_max_per_domain,omitempty"     mapstructure:"citation_max_per_domain"     yaml:"citation_max_per_domain,omitempty"    ` //nolint:lll
+	CitationExcludeDomains   *[]string      `json:"citation_exclude_domains,omitempty"    mapstructure:"citation_exclude_domains"    yaml:"citation_exclude_domains,omitempty"   ` //nolint:lll
+	CacheTTL                 *time.Duration `json:"cache_ttl,omitempty"                   mapstructure:"cache_ttl"                   yaml:"cache_ttl,omitempty"                  ` //nolint:lll
+	ImageConflictPolicy      *string        `json:"image_conflict_policy,omitempty"       mapstructure:"image_conflict_policy"       yaml:"image_conflict_policy,omitempty"      ` //nolint:lll
+	AutoContinue             *int           `json:"auto_continue,omitempty"               mapstructure:"auto_continue"               yaml:"auto_continue,omitempty"              ` //nolint:lll
+	Pager                    *string        `json:"pager,omitempty"                       mapstructure:"pager"                       yaml:"pager,omitempty"                      ` //nolint:lll
+	StreamStyle              *string        `json:"stream_style,omitempty"                mapstructure:"stream_style"                yaml:"stream_style,omitempty"               ` //nolint:lll
+	StreamCPS                *int           `json:"stream_cps,omitempty"                  mapstructure:"stream_cps"                  yaml:"stream_cps,omitempty"                 ` //nolint:lll
+	Theme                    *string        `json:"theme,omitempty"                       mapstructure:"theme"                       yaml:"theme,omitempty"                      ` //nolint:lll
+	History                  *bool          `json:"history,omitempty"                     mapstructure:"history"                     yaml:"history,omitempty"                    ` //nolint:lll
 }
 
 // ConfigFileInfo represents metadata about a configuration file.
@@ -1,19 +1,19 @@
 package config
 
+import "github.com/sgaunet/pplx/pkg/validation"
+
 // formatJSON is the JSON output format identifier used across format switch statements.
 const formatJSON = "json"
 
 // Validation maps for Perplexity API enum values.
 // These define the valid parameter values accepted by the API.
-// Centralized here to eliminate duplication across validator, chat, mcp, and cmd packages.
+// They are derived from pkg/validation, which holds the canonical lists.
 
 // ValidSearchRecency contains valid search recency time windows supported by the Perplexity API.
 // These values control how recent search results must be.
 // Valid values: "hour", "day", "week", "month", "year"
 // Used by: validator, chat builder, MCP handler, config wizard.
-var ValidSearchRecency = map[string]bool{
-	"hour": true, "day": true, "week": true, "month": true, "year": true,
-}
+var ValidSearchRecency = valueSet(validation.KindRecency)
 
 // ValidSearchModes contains valid search modes supported by the Perplexity API.
 // Valid values:
@@ -21,9 +21,7 @@ var ValidSearchRecency = map[string]bool{
 //   - "academic": scholarly sources only (research papers, journals)
 //
 // Used by: chat builder, MCP handler.
-var ValidSearchModes = map[string]bool{
-	"web": true, "academic": true,
-}
+var ValidSearchModes = valueSet(validation.KindSearchMode)
 
 // ValidContextSizes contains valid search context sizes supported by the Perplexity API.
 // These control how much search context to include in the model prompt.
@@ -33,9 +31,7 @@ var ValidSearchModes = map[string]bool{
 //   - "high": maximum context (slower, more comprehensive)
 //
 // Used by: validator, chat builder, MCP handler, config wizard.
-var ValidContextSizes = map[string]bool{
-	"low": true, "medium": true, "high": true,
-}
+var ValidContextSizes = valueSet(validation.KindContextSize)
 
 // ValidReasoningEfforts contains valid reasoning effort levels for deep-research models.
 // These control the depth of analysis for sonar-deep-research models.
@@ -45,16 +41,21 @@ var ValidContextSizes = map[string]bool{
 //   - "high": maximum depth (slower, comprehensive for complex research tasks)
 //
 // Used by: validator, chat builder, MCP handler.
-var ValidReasoningEfforts = map[string]bool{
-	"low": true, "medium": true, "high": true,
-}
+var ValidReasoningEfforts = valueSet(validation.KindReasoningEffort)
 
 // ValidImageFormats contains valid image format filters supported by the Perplexity API.
 // Valid formats: "jpg", "jpeg", "png", "gif", "webp", "svg", "bmp"
 // Used by: MCP handler (for warnings).
-var ValidImageFormats = map[string]bool{
-	"jpg": true, "jpeg": true, "png": true, "gif": true,
-	"webp": true, "svg": true, "bmp": true,
+var ValidImageFormats = valueSet(validation.KindImageFormat)
+
+// valueSet builds a lookup map from the canonical value list for kind.
+func valueSet(kind string) map[string]bool {
+	values := validation.ValidValues(kind)
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
 }
 
 // Helper functions for validation
@@ -94,29 +95,29 @@ func IsValidImageFormat(value string) bool {
 // GetValidSearchRecencyValues returns all valid search recency values as a slice.
 // Useful for iterating over valid options in CLI prompts or documentation.
 func GetValidSearchRecencyValues() []string {
-	return []string{"hour", "day", "week", "month", "year"}
+	return validation.ValidValues(validation.KindRecency)
 }
 
 // GetValidSearchModeValues returns all valid search mode values as a slice.
 // Useful for iterating over valid options in CLI prompts or documentation.
 func GetValidSearchModeValues() []string {
-	return []string{"web", "academic"}
+	return validation.ValidValues(validation.KindSearchMode)
 }
 
 // GetValidContextSizeValues returns all valid context size values as a slice.
 // Useful for iterating over valid options in CLI prompts or documentation.
 func GetValidContextSizeValues() []string {
-	return []string{"low", "medium", "high"}
+	return validation.ValidValues(validation.KindContextSize)
 }
 
 // GetValidReasoningEffortValues returns all valid reasoning effort values as a slice.
 // Useful for iterating over valid options in CLI prompts or documentation.
 func GetValidReasoningEffortValues() []string {
-	return []string{"low", "medium", "high"}
+	return validation.ValidValues(validation.KindReasoningEffort)
 }
 
 // GetValidImageFormatValues returns all valid image format values as a slice.
 // Useful for iterating over valid options in CLI prompts or documentation.
 func GetValidImageFormatValues() []string {
-	return []string{"jpg", "jpeg", "png", "gif", "webp", "svg", "bmp"}
+	return validation.ValidValues(validation.KindImageFormat)
 }
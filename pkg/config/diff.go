@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 // DiffEntry represents a single difference between two configurations.
@@ -73,3 +76,62 @@ func FormatDiff(entries []DiffEntry, format string) string {
 		return buf.String()
 	}
 }
+
+// ConfigChange describes one option compared between two configurations.
+type ConfigChange struct {
+	Section string `json:"section"`
+	Key     string `json:"key"` // dot-notation key (e.g., "search.mode")
+	// Old and New are the values in the first and second configuration,
+	// nil when the option is unset and takes its default.
+	Old     any  `json:"old"`
+	New     any  `json:"new"`
+	Changed bool `json:"changed"`
+	// Description is the option description, filled in on request.
+	Description string `json:"description,omitempty"`
+}
+
+// CompareConfigs compares every option of a and b, grouped by section in
+// the order of MetadataRegistry.ListSections and sorted by key within a
+// section. Zero values count as unset. Options with the same value on both
+// sides are only returned when all is true.
+func CompareConfigs(a, b *ConfigData, all bool) []ConfigChange {
+	keys := AllKeys()
+	bySection := make(map[string][]ConfigChange)
+	for _, key := range keys {
+		oldVal, newVal := optionValue(a, key), optionValue(b, key)
+		changed := fmt.Sprintf("%v", oldVal) != fmt.Sprintf("%v", newVal)
+		if !changed && !all {
+			continue
+		}
+		section, _, _ := strings.Cut(key, dotSeparator)
+		bySection[section] = append(bySection[section], ConfigChange{
+			Section: section,
+			Key:     key,
+			Old:     oldVal,
+			New:     newVal,
+			Changed: changed,
+		})
+	}
+
+	changes := make([]ConfigChange, 0, len(keys))
+	for _, section := range NewMetadataRegistry().ListSections() {
+		changes = append(changes, bySection[section]...)
+	}
+	return changes
+}
+
+// optionValue returns the value of key in cfg, or nil when it is unset.
+func optionValue(cfg *ConfigData, key string) any {
+	v, err := GetValue(cfg, key)
+	if err != nil || v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.IsZero() || (rv.Kind() == reflect.Slice && rv.Len() == 0) {
+		return nil
+	}
+	if d, ok := v.(time.Duration); ok {
+		return d.String()
+	}
+	return v
+}
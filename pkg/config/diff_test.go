@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCompareConfigs(t *testing.T) {
+	a := NewConfigData()
+	a.Defaults.Model = "sonar"
+	a.Defaults.Temperature = 0.5
+	a.API.TotalTimeout = 2 * time.Minute
+
+	b := NewConfigData()
+	b.Defaults.Model = "sonar"
+	b.Defaults.Temperature = 0.3
+	b.Search.Domains = []string{"arxiv.org"}
+	b.Output.ReturnRelated = true
+
+	changes := CompareConfigs(a, b, false)
+	want := []ConfigChange{
+		{Section: SectionDefaults, Key: "defaults.temperature", Old: 0.5, New: 0.3, Changed: true},
+		{Section: SectionSearch, Key: "search.domains", Old: nil, New: []string{"arxiv.org"}, Changed: true},
+		{Section: SectionOutput, Key: "output.return_related", Old: nil, New: true, Changed: true},
+		{Section: SectionAPI, Key: "api.total_timeout", Old: "2m0s", New: nil, Changed: true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %+v, want %+v", changes, want)
+	}
+	for i, w := range want {
+		c := changes[i]
+		if c.Section != w.Section || c.Key != w.Key || c.Changed != w.Changed ||
+			formatAny(c.Old) != formatAny(w.Old) || formatAny(c.New) != formatAny(w.New) {
+			t.Errorf("change %d = %+v, want %+v", i, c, w)
+		}
+	}
+
+	all := CompareConfigs(a, b, true)
+	if len(all) != len(AllKeys()) {
+		t.Errorf("with all, got %d entries, want one per option (%d)", len(all), len(AllKeys()))
+	}
+	for _, c := range all {
+		if c.Key == "defaults.model" && (c.Changed || c.Old != "sonar") {
+			t.Errorf("unchanged model entry = %+v", c)
+		}
+	}
+
+	if got := CompareConfigs(a, a, false); len(got) != 0 {
+		t.Errorf("identical configs differ: %+v", got)
+	}
+}
+
+func formatAny(v any) string {
+	if v == nil {
+		return "<unset>"
+	}
+	return fmt.Sprint(v)
+}
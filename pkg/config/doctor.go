@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 )
 
 // CheckStatus represents the result of a health check.
@@ -20,8 +21,6 @@ const (
 const (
 	// expectedHealthChecks is the number of health checks performed by RunHealthChecks.
 	expectedHealthChecks = 7
-	// expectedFilePermissions is the expected file permissions for the config file.
-	expectedFilePermissions = 0o600
 )
 
 // HealthCheck represents a single diagnostic check result.
@@ -63,15 +62,16 @@ func RunHealthChecks(configPath string) []HealthCheck {
 
 	// Load the config for remaining checks.
 	var data *ConfigData
+	var keys map[string]string
 	if yamlCheck.Status != CheckFail {
 		loader := NewLoader()
 		if err := loader.LoadFrom(path); err == nil {
-			data = loader.Data()
+			data, keys = loader.Data(), loader.Origins()
 		}
 	}
 
 	// Check 4: Field validation.
-	checks = append(checks, checkFieldValidation(data))
+	checks = append(checks, checkFieldValidation(data, keys))
 
 	// Check 5: Profile integrity.
 	checks = append(checks, checkProfileIntegrity(data))
@@ -116,7 +116,7 @@ func checkConfigFileExists(configPath string) (string, HealthCheck) {
 		return "", HealthCheck{
 			Name:   name,
 			Status: CheckFail,
-			Detail: "no config file found in ~/.config/pplx/ (run: pplx config init)",
+			Detail: "no config file found (run: pplx config init, or pplx config path to list the search paths)",
 		}
 	}
 	return found, HealthCheck{
@@ -126,7 +126,10 @@ func checkConfigFileExists(configPath string) (string, HealthCheck) {
 	}
 }
 
-// checkFilePermissions warns when the config file is not restricted to 0600.
+// checkFilePermissions warns when group or others can access the config
+// file, its directory or backups, or the data files of pplx, or when others
+// can read an API key in the project config: the findings of CheckSecurity,
+// which pplx config secure fixes.
 func checkFilePermissions(path string) HealthCheck {
 	name := "File Permissions"
 
@@ -138,17 +141,19 @@ func checkFilePermissions(path string) HealthCheck {
 			Detail: fmt.Sprintf("cannot stat file: %v", err),
 		}
 	}
-
-	mode := info.Mode().Perm()
-	if mode != expectedFilePermissions {
+	report, err := CheckSecurity(DefaultSecurityPaths(path))
+	if err != nil {
+		return HealthCheck{Name: name, Status: CheckFail, Detail: err.Error()}
+	}
+	if !report.Empty() {
 		return HealthCheck{
 			Name:   name,
 			Status: CheckWarn,
-			Detail: fmt.Sprintf("%04o (should be 0600 — run: chmod 600 %s)", mode, path),
+			Detail: strings.Join(report.Issues(), "; ") + " (run: pplx config secure)",
 		}
 	}
 
-	return HealthCheck{Name: name, Status: CheckPass, Detail: "0600"}
+	return HealthCheck{Name: name, Status: CheckPass, Detail: fmt.Sprintf("%04o", info.Mode().Perm())}
 }
 
 // checkYAMLSyntax validates the config file contains parseable YAML.
@@ -166,15 +171,16 @@ func checkYAMLSyntax(path string) HealthCheck {
 	return HealthCheck{Name: name, Status: CheckPass, Detail: "valid"}
 }
 
-// checkFieldValidation runs the config validator against the loaded data.
-func checkFieldValidation(data *ConfigData) HealthCheck {
+// checkFieldValidation runs the config validator against the loaded data,
+// whose file sets keys.
+func checkFieldValidation(data *ConfigData, keys map[string]string) HealthCheck {
 	name := "Field Validation"
 
 	if data == nil {
 		return HealthCheck{Name: name, Status: CheckFail, Detail: "skipped: config could not be loaded"}
 	}
 
-	v := NewValidator()
+	v := NewValidator().WithKeys(keys)
 	if err := v.Validate(data); err != nil {
 		return HealthCheck{
 			Name:   name,
@@ -236,6 +242,21 @@ func checkAPIKey(configKey string, _ *ConfigData) HealthCheck {
 		}
 	}
 
+	if IsKeyringRef(configKey) {
+		if _, err := LoadAPIKey(); err != nil {
+			return HealthCheck{Name: name, Status: CheckFail, Detail: err.Error()}
+		}
+		return HealthCheck{
+			Name:   name,
+			Status: CheckPass,
+			Detail: "set via system keyring",
+		}
+	}
+
+	if IsEncryptedKey(configKey) {
+		return checkEncryptedKey(name, configKey)
+	}
+
 	if configKey != "" {
 		return HealthCheck{
 			Name:   name,
@@ -251,7 +272,29 @@ func checkAPIKey(configKey string, _ *ConfigData) HealthCheck {
 	}
 }
 
-// checkConfigVersion warns when the Version field is absent or zero.
+// checkEncryptedKey checks an encrypted api.key. The passphrase is never
+// asked for: without PPLX_CONFIG_PASSPHRASE the key cannot be verified.
+func checkEncryptedKey(name, configKey string) HealthCheck {
+	passphrase := os.Getenv(PassphraseEnv)
+	if passphrase == "" {
+		return HealthCheck{
+			Name:   name,
+			Status: CheckWarn,
+			Detail: "set via encrypted config api.key; set " + PassphraseEnv + " to verify it",
+		}
+	}
+	if _, err := DecryptAPIKey(configKey, passphrase); err != nil {
+		return HealthCheck{Name: name, Status: CheckFail, Detail: err.Error()}
+	}
+	return HealthCheck{
+		Name:   name,
+		Status: CheckPass,
+		Detail: "set via encrypted config api.key",
+	}
+}
+
+// checkConfigVersion warns when the Version field is absent or older than
+// CurrentConfigVersion.
 func checkConfigVersion(data *ConfigData) HealthCheck {
 	name := "Config Version"
 
@@ -263,7 +306,16 @@ func checkConfigVersion(data *ConfigData) HealthCheck {
 		return HealthCheck{
 			Name:   name,
 			Status: CheckWarn,
-			Detail: "version field is 0 or missing (add 'version: 1' to your config)",
+			Detail: "version field is 0 or missing (run 'pplx config migrate')",
+		}
+	}
+
+	if data.Version < CurrentConfigVersion {
+		return HealthCheck{
+			Name:   name,
+			Status: CheckWarn,
+			Detail: fmt.Sprintf("version %d is older than %d (run 'pplx config migrate')",
+				data.Version, CurrentConfigVersion),
 		}
 	}
 
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// EncryptedKeyPrefix starts an api.key value encrypted with EncryptAPIKey.
+// The rest is the base64 encoding of the scrypt salt, the AES-GCM nonce and
+// the sealed key.
+const EncryptedKeyPrefix = "enc:v1:"
+
+// PassphraseEnv is the environment variable holding the passphrase of an
+// encrypted api.key, for non-interactive use.
+const PassphraseEnv = "PPLX_CONFIG_PASSPHRASE"
+
+// scrypt parameters for deriving the encryption key from the passphrase.
+// They are part of the enc:v1 format: changing them needs a new version.
+const (
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	saltSize      = 16
+	derivedKeyLen = 32
+)
+
+// PassphrasePrompt asks for the passphrase of an encrypted api.key when
+// PassphraseEnv is not set. It is nil when no one can be asked, as when
+// stdin is not a terminal; the command line sets it for interactive use.
+//
+//nolint:gochecknoglobals // set once by the command line before loading the config
+var PassphrasePrompt func() (string, error)
+
+// IsEncryptedKey reports whether an api.key value was encrypted with
+// EncryptAPIKey.
+func IsEncryptedKey(key string) bool {
+	return strings.HasPrefix(strings.TrimSpace(key), EncryptedKeyPrefix)
+}
+
+// EncryptAPIKey encrypts key with a key derived from passphrase and returns
+// the api.key value to store. AES-GCM authenticates the result, so a wrong
+// passphrase is detected on decryption.
+func EncryptAPIKey(key, passphrase string) (string, error) {
+	if passphrase == "" {
+		return "", clerrors.NewValidationError("passphrase", "", "passphrase cannot be empty")
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	aead, err := newKeyAEAD(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	payload := append(append(salt, nonce...), aead.Seal(nil, nonce, []byte(key), nil)...)
+	return EncryptedKeyPrefix + base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// DecryptAPIKey returns the API key of an encrypted api.key value. Returns a
+// ConfigError wrapping clerrors.ErrWrongPassphrase when the passphrase does
+// not match, or clerrors.ErrInvalidEncryptedKey when value is malformed.
+func DecryptAPIKey(value, passphrase string) (string, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(value), EncryptedKeyPrefix)
+	if !ok {
+		return "", clerrors.NewConfigError("cannot decrypt api.key",
+			fmt.Errorf("%w: missing %s prefix", clerrors.ErrInvalidEncryptedKey, EncryptedKeyPrefix))
+	}
+	payload, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", clerrors.NewConfigError("cannot decrypt api.key",
+			fmt.Errorf("%w: %w", clerrors.ErrInvalidEncryptedKey, err))
+	}
+	if len(payload) < saltSize {
+		return "", clerrors.NewConfigError("cannot decrypt api.key",
+			fmt.Errorf("%w: too short", clerrors.ErrInvalidEncryptedKey))
+	}
+	salt, rest := payload[:saltSize], payload[saltSize:]
+	aead, err := newKeyAEAD(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return "", clerrors.NewConfigError("cannot decrypt api.key",
+			fmt.Errorf("%w: too short", clerrors.ErrInvalidEncryptedKey))
+	}
+	nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	key, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", clerrors.NewConfigError("cannot decrypt api.key", clerrors.ErrWrongPassphrase)
+	}
+	return string(key), nil
+}
+
+// newKeyAEAD returns the AES-256-GCM cipher keyed by passphrase and salt.
+func newKeyAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	derived, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, derivedKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// ReadPassphrase returns the passphrase of an encrypted api.key: the value
+// of PassphraseEnv, or else the answer to PassphrasePrompt. Returns a
+// ConfigError wrapping clerrors.ErrPassphraseRequired when neither is
+// available.
+func ReadPassphrase() (string, error) {
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if PassphrasePrompt == nil {
+		return "", clerrors.NewConfigError("api.key is encrypted",
+			fmt.Errorf("%w: set %s, run pplx in a terminal to be asked, or set PPLX_API_KEY instead",
+				clerrors.ErrPassphraseRequired, PassphraseEnv))
+	}
+	passphrase, err := PassphrasePrompt()
+	if err != nil {
+		return "", clerrors.NewConfigError("cannot read the api.key passphrase", err)
+	}
+	if passphrase == "" {
+		return "", clerrors.NewConfigError("api.key is encrypted", clerrors.ErrPassphraseRequired)
+	}
+	return passphrase, nil
+}
+
+// resolveEncryptedKey replaces an encrypted api.key with the key it holds,
+// in memory only. It is not decrypted when PPLX_API_KEY is set, since the
+// environment takes precedence anyway.
+func resolveEncryptedKey(cfg *ConfigData) error {
+	if !IsEncryptedKey(cfg.API.Key) {
+		return nil
+	}
+	if os.Getenv("PPLX_API_KEY") != "" {
+		cfg.API.Key = ""
+		return nil
+	}
+	passphrase, err := ReadPassphrase()
+	if err != nil {
+		return err
+	}
+	key, err := DecryptAPIKey(cfg.API.Key, passphrase)
+	if err != nil {
+		return err
+	}
+	cfg.API.Key = key
+	return nil
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// PassphrasePrompt is process-wide, so these tests do not run in parallel.
+
+func TestEncryptAPIKeyRoundTrip(t *testing.T) {
+	encrypted, err := EncryptAPIKey("pplx-secret", "correct horse")
+	if err != nil {
+		t.Fatalf("EncryptAPIKey() error: %v", err)
+	}
+	if !IsEncryptedKey(encrypted) || strings.Contains(encrypted, "pplx-secret") {
+		t.Fatalf("EncryptAPIKey() = %q, want an enc:v1: value without the key", encrypted)
+	}
+	again, _ := EncryptAPIKey("pplx-secret", "correct horse")
+	if again == encrypted {
+		t.Error("EncryptAPIKey() twice gave the same value, want a fresh salt and nonce")
+	}
+	if key, err := DecryptAPIKey(encrypted, "correct horse"); err != nil || key != "pplx-secret" {
+		t.Errorf("DecryptAPIKey() = %q, %v; want pplx-secret", key, err)
+	}
+}
+
+func TestEncryptAPIKey_EmptyPassphrase(t *testing.T) {
+	var verr *clerrors.ValidationError
+	if _, err := EncryptAPIKey("pplx-secret", ""); !errors.As(err, &verr) {
+		t.Errorf("EncryptAPIKey() error = %v, want ValidationError", err)
+	}
+}
+
+func TestDecryptAPIKey_Errors(t *testing.T) {
+	encrypted, err := EncryptAPIKey("pplx-secret", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, EncryptedKeyPrefix))
+	payload[len(payload)-1] ^= 1
+	tampered := EncryptedKeyPrefix + base64.StdEncoding.EncodeToString(payload)
+
+	tests := []struct {
+		name       string
+		value      string
+		passphrase string
+		want       error
+	}{
+		{"wrong passphrase", encrypted, "battery staple", clerrors.ErrWrongPassphrase},
+		{"tampered", tampered, "correct horse", clerrors.ErrWrongPassphrase},
+		{"no prefix", "pplx-secret", "correct horse", clerrors.ErrInvalidEncryptedKey},
+		{"not base64", EncryptedKeyPrefix + "!!!", "correct horse", clerrors.ErrInvalidEncryptedKey},
+		{"too short", EncryptedKeyPrefix + "YWJj", "correct horse", clerrors.ErrInvalidEncryptedKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecryptAPIKey(tt.value, tt.passphrase)
+			var configErr *clerrors.ConfigError
+			if !errors.As(err, &configErr) || !errors.Is(err, tt.want) {
+				t.Errorf("DecryptAPIKey() error = %v, want ConfigError wrapping %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveEncryptedKey(t *testing.T) {
+	encrypted, err := EncryptAPIKey("pplx-secret", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCfg := func() *ConfigData {
+		cfg := NewConfigData()
+		cfg.API.Key = encrypted
+		return cfg
+	}
+	t.Cleanup(func() { PassphrasePrompt = nil })
+
+	t.Run("plain key untouched", func(t *testing.T) {
+		cfg := NewConfigData()
+		cfg.API.Key = "pplx-plain"
+		if err := resolveEncryptedKey(cfg); err != nil || cfg.API.Key != "pplx-plain" {
+			t.Errorf("key = %q, err = %v; want pplx-plain", cfg.API.Key, err)
+		}
+	})
+
+	t.Run("passphrase from environment", func(t *testing.T) {
+		t.Setenv("PPLX_API_KEY", "")
+		t.Setenv(PassphraseEnv, "correct horse")
+		cfg := newCfg()
+		if err := resolveEncryptedKey(cfg); err != nil || cfg.API.Key != "pplx-secret" {
+			t.Errorf("key = %q, err = %v; want pplx-secret", cfg.API.Key, err)
+		}
+	})
+
+	t.Run("passphrase from prompt", func(t *testing.T) {
+		t.Setenv("PPLX_API_KEY", "")
+		t.Setenv(PassphraseEnv, "")
+		PassphrasePrompt = func() (string, error) { return "correct horse", nil }
+		defer func() { PassphrasePrompt = nil }()
+		cfg := newCfg()
+		if err := resolveEncryptedKey(cfg); err != nil || cfg.API.Key != "pplx-secret" {
+			t.Errorf("key = %q, err = %v; want pplx-secret", cfg.API.Key, err)
+		}
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		t.Setenv("PPLX_API_KEY", "")
+		t.Setenv(PassphraseEnv, "battery staple")
+		if err := resolveEncryptedKey(newCfg()); !errors.Is(err, clerrors.ErrWrongPassphrase) {
+			t.Errorf("resolveEncryptedKey() error = %v, want ErrWrongPassphrase", err)
+		}
+	})
+
+	t.Run("non-interactive without passphrase", func(t *testing.T) {
+		t.Setenv("PPLX_API_KEY", "")
+		t.Setenv(PassphraseEnv, "")
+		err := resolveEncryptedKey(newCfg())
+		if !errors.Is(err, clerrors.ErrPassphraseRequired) || !strings.Contains(err.Error(), PassphraseEnv) {
+			t.Errorf("resolveEncryptedKey() error = %v, want ErrPassphraseRequired naming %s", err, PassphraseEnv)
+		}
+	})
+
+	t.Run("environment key skips decryption", func(t *testing.T) {
+		t.Setenv("PPLX_API_KEY", "pplx-env")
+		t.Setenv(PassphraseEnv, "")
+		cfg := newCfg()
+		if err := resolveEncryptedKey(cfg); err != nil || cfg.API.Key != "" {
+			t.Errorf("key = %q, err = %v; want empty without error", cfg.API.Key, err)
+		}
+	})
+}
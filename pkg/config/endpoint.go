@@ -0,0 +1,59 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// chatCompletionsPath is the path of the chat completions API under a base URL.
+const chatCompletionsPath = "/chat/completions"
+
+// EndpointURL returns the chat completions URL of the API at base, such as
+// https://api.perplexity.ai or a compatible gateway. A base that already ends
+// with /chat/completions is used as is. An empty base returns "", which keeps
+// the client's default endpoint.
+func EndpointURL(base string) string {
+	if base == "" {
+		return ""
+	}
+	base = strings.TrimRight(base, "/")
+	if strings.HasSuffix(base, chatCompletionsPath) {
+		return base
+	}
+	return base + chatCompletionsPath
+}
+
+// ValidateHTTPURL checks that raw is an absolute http or https URL with a
+// host. An empty raw is valid. The error is a ValidationError naming field
+// and the offending value.
+func ValidateHTTPURL(field, raw string) error {
+	_, err := parseHTTPURL(field, raw)
+	return err
+}
+
+// ParseProxyURL returns the proxy URL of raw, or nil when raw is empty so
+// that the HTTPS_PROXY environment variable applies.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	return parseHTTPURL("api.proxy_url", raw)
+}
+
+// parseHTTPURL parses raw as an http or https URL with a host; see
+// ValidateHTTPURL.
+func parseHTTPURL(field, raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, clerrors.NewValidationErrorSafe(field, raw, "invalid URL format: "+err.Error())
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, clerrors.NewValidationErrorSafe(field, raw, "must use http or https scheme")
+	}
+	if u.Host == "" {
+		return nil, clerrors.NewValidationErrorSafe(field, raw, "must specify a host")
+	}
+	return u, nil
+}
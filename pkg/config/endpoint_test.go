@@ -0,0 +1,61 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestEndpointURL(t *testing.T) {
+	tests := map[string]string{
+		"":                                       "",
+		"https://api.perplexity.ai":              "https://api.perplexity.ai/chat/completions",
+		"https://gw.corp.example/pplx/":          "https://gw.corp.example/pplx/chat/completions",
+		"http://localhost:8080/chat/completions": "http://localhost:8080/chat/completions",
+	}
+	for base, want := range tests {
+		if got := EndpointURL(base); got != want {
+			t.Errorf("EndpointURL(%q) = %q, want %q", base, got, want)
+		}
+	}
+}
+
+func TestParseProxyURL(t *testing.T) {
+	u, err := ParseProxyURL("")
+	if u != nil || err != nil {
+		t.Errorf("ParseProxyURL(\"\") = %v, %v, want nil so HTTPS_PROXY applies", u, err)
+	}
+
+	u, err = ParseProxyURL("http://proxy.corp.example:3128")
+	if err != nil || u.Host != "proxy.corp.example:3128" {
+		t.Errorf("ParseProxyURL() = %v, %v", u, err)
+	}
+
+	for _, raw := range []string{"proxy.corp.example:3128", "socks5://proxy", "http://", "http://bad host"} {
+		_, err := ParseProxyURL(raw)
+		var verr *clerrors.ValidationError
+		if !errors.As(err, &verr) || verr.Field != "api.proxy_url" || verr.Value != raw {
+			t.Errorf("ParseProxyURL(%q) error = %v, want a ValidationError naming the value", raw, err)
+		}
+	}
+}
+
+func TestValidator_APIURLs(t *testing.T) {
+	cfg := NewConfigData()
+	cfg.API.BaseURL = "ftp://gateway.example"
+	cfg.API.ProxyURL = "proxy.example:3128"
+
+	err := NewValidator().Validate(cfg)
+	var verrs clerrors.ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("Validate() error = %v, want ValidationErrors", err)
+	}
+	got := map[string]string{}
+	for _, verr := range verrs {
+		got[verr.Field] = verr.Value
+	}
+	if got["api.base_url"] != "ftp://gateway.example" || got["api.proxy_url"] != "proxy.example:3128" {
+		t.Errorf("errors = %v, want api.base_url and api.proxy_url with their values", verrs)
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// EnvPrefix starts the name of every environment variable that overrides a
+// config option.
+const EnvPrefix = "PPLX"
+
+// EnvVarName returns the environment variable overriding the option at the
+// dot-notation key: PPLX_<SECTION>_<NAME>, for example PPLX_SEARCH_RECENCY
+// for search.recency.
+func EnvVarName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, dotSeparator, "_"))
+}
+
+// applyEnvOverrides sets every option whose PPLX_* variable is set in the
+// environment, as read by lookup, and returns the keys it set. Values are
+// coerced like `config set` values: lists are comma-separated. An empty
+// variable is ignored. Errors are validation errors naming the variable.
+func applyEnvOverrides(cfg *ConfigData, lookup func(string) (string, bool)) ([]string, error) {
+	var keys []string
+	for _, key := range AllKeys() {
+		name := EnvVarName(key)
+		raw, ok := lookup(name)
+		if !ok || raw == "" {
+			continue
+		}
+		if err := SetValue(cfg, key, raw); err != nil {
+			return nil, clerrors.NewValidationErrorSafe(name, raw, envTypeMessage(key))
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	// Range and enum checks only report on options set from the environment;
+	// problems in the config file are left to `pplx config validate`.
+	if err := NewValidator().Validate(cfg); err != nil {
+		var verrs clerrors.ValidationErrors
+		if errors.As(err, &verrs) {
+			for _, verr := range verrs {
+				for _, key := range keys {
+					if verr.Field == key {
+						return nil, clerrors.NewValidationErrorSafe(EnvVarName(key), verr.Value, verr.Message)
+					}
+				}
+			}
+		}
+	}
+	return keys, nil
+}
+
+// envTypeMessage describes the values accepted for key, for variables
+// that cannot be parsed.
+func envTypeMessage(key string) string {
+	opt, err := NewMetadataRegistry().GetOption(key)
+	if err != nil {
+		return "invalid value"
+	}
+	switch opt.Type {
+	case "bool":
+		return "must be true or false"
+	case "int":
+		return "must be an integer"
+	case "float64":
+		return "must be a number"
+	case "duration":
+		return "must be a duration such as 30s or 2m"
+	default:
+		return "invalid value for " + key
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestEnvVarName(t *testing.T) {
+	tests := map[string]string{
+		"search.recency":              "PPLX_SEARCH_RECENCY",
+		"output.stream":               "PPLX_OUTPUT_STREAM",
+		"defaults.max_tokens":         "PPLX_DEFAULTS_MAX_TOKENS",
+		"api.response_header_timeout": "PPLX_API_RESPONSE_HEADER_TIMEOUT",
+	}
+	for key, want := range tests {
+		if got := EnvVarName(key); got != want {
+			t.Errorf("EnvVarName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestMetadataRegistry_EnvVarForEveryOption(t *testing.T) {
+	for _, opt := range NewMetadataRegistry().GetAll() {
+		if opt.Section == SectionPrompts || isMapOption(opt) {
+			continue
+		}
+		key := opt.Section + "." + opt.Name
+		if opt.EnvVar != EnvVarName(key) {
+			t.Errorf("%s: EnvVar = %q, want %q", key, opt.EnvVar, EnvVarName(key))
+		}
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	env := map[string]string{
+		"PPLX_DEFAULTS_MODEL":       "sonar-pro",
+		"PPLX_DEFAULTS_TOP_K":       "",
+		"PPLX_OUTPUT_IMAGE_FORMATS": "png,jpg",
+		"UNRELATED":                 "x",
+	}
+	cfg := NewConfigData()
+	cfg.Defaults.TopK = 5
+
+	keys, err := applyEnvOverrides(cfg, lookupIn(env))
+	if err != nil {
+		t.Fatalf("applyEnvOverrides() error = %v", err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("keys = %v, want defaults.model and output.image_formats", keys)
+	}
+	if cfg.Defaults.Model != "sonar-pro" {
+		t.Errorf("Model = %q, want sonar-pro", cfg.Defaults.Model)
+	}
+	if cfg.Defaults.TopK != 5 {
+		t.Errorf("empty variable should be ignored, TopK = %d", cfg.Defaults.TopK)
+	}
+	if strings.Join(cfg.Output.ImageFormats, ",") != "png,jpg" {
+		t.Errorf("ImageFormats = %v, want [png jpg]", cfg.Output.ImageFormats)
+	}
+}
+
+func TestApplyEnvOverrides_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantVar string
+		wantMsg string
+	}{
+		{"bad int", map[string]string{"PPLX_DEFAULTS_MAX_TOKENS": "many"}, "PPLX_DEFAULTS_MAX_TOKENS", "integer"},
+		{"bad bool", map[string]string{"PPLX_OUTPUT_STREAM": "sometimes"}, "PPLX_OUTPUT_STREAM", "true or false"},
+		{"bad duration", map[string]string{"PPLX_API_CONNECT_TIMEOUT": "soon"}, "PPLX_API_CONNECT_TIMEOUT", "duration"},
+		{"out of range", map[string]string{"PPLX_DEFAULTS_TEMPERATURE": "7"}, "PPLX_DEFAULTS_TEMPERATURE", ""},
+		{"bad enum", map[string]string{"PPLX_SEARCH_RECENCY": "decade"}, "PPLX_SEARCH_RECENCY", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := applyEnvOverrides(NewConfigData(), lookupIn(tt.env))
+			var verr *clerrors.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("error = %v, want a ValidationError", err)
+			}
+			if verr.Field != tt.wantVar {
+				t.Errorf("Field = %q, want %q", verr.Field, tt.wantVar)
+			}
+			if !strings.Contains(verr.Message, tt.wantMsg) {
+				t.Errorf("Message = %q, want it to contain %q", verr.Message, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
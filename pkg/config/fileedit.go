@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// editedFilePermission is applied to config files written by SetFileValue/UnsetFileValue.
+	editedFilePermission = 0o600
+	// editedDirPermission is applied to a config directory created on first write.
+	editedDirPermission = 0o700
+	// yamlIndent matches the indentation used by the config templates.
+	yamlIndent = 2
+)
+
+// SetFileValue sets the value at keyPath (e.g. ["defaults", "model"]) in the
+// YAML file at filePath, creating the file and intermediate mappings as needed.
+//
+// The file is edited as a YAML node tree rather than re-marshaled from
+// ConfigData, so unrelated keys, key order, and comments are preserved.
+// time.Duration values are written in their string form (e.g. "30s").
+func SetFileValue(filePath string, keyPath []string, value any) error {
+	if len(keyPath) == 0 {
+		return fmt.Errorf("%w: empty key path", clerrors.ErrOptionNotFound)
+	}
+
+	doc, err := readYAMLDocument(filePath)
+	if err != nil {
+		return err
+	}
+
+	if d, ok := value.(time.Duration); ok {
+		value = d.String()
+	}
+
+	var valueNode yaml.Node
+	if err := valueNode.Encode(value); err != nil {
+		return fmt.Errorf("failed to encode value for %v: %w", keyPath, err)
+	}
+
+	parent := doc.Content[0]
+	for _, key := range keyPath[:len(keyPath)-1] {
+		child := mappingValue(parent, key)
+		if child == nil || child.Kind != yaml.MappingNode {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(parent, key, child)
+		}
+		parent = child
+	}
+	setMappingValue(parent, keyPath[len(keyPath)-1], &valueNode)
+
+	return writeYAMLDocument(filePath, doc)
+}
+
+// UnsetFileValue removes the key at keyPath from the YAML file at filePath.
+// Missing files or keys are not an error. Other content is preserved.
+func UnsetFileValue(filePath string, keyPath []string) error {
+	if len(keyPath) == 0 {
+		return fmt.Errorf("%w: empty key path", clerrors.ErrOptionNotFound)
+	}
+
+	if _, err := os.Stat(filePath); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	doc, err := readYAMLDocument(filePath)
+	if err != nil {
+		return err
+	}
+
+	parent := doc.Content[0]
+	for _, key := range keyPath[:len(keyPath)-1] {
+		parent = mappingValue(parent, key)
+		if parent == nil || parent.Kind != yaml.MappingNode {
+			return nil
+		}
+	}
+
+	last := keyPath[len(keyPath)-1]
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == last {
+			parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+			break
+		}
+	}
+
+	return writeYAMLDocument(filePath, doc)
+}
+
+// SaveProfiles writes the profiles and active profile of data to the YAML
+// file at filePath, creating the file if needed. Only the "profiles" and
+// "active_profile" keys are rewritten; every other key is left as written,
+// so values such as ${VAR} references are not expanded into the file.
+func SaveProfiles(filePath string, data *ConfigData) error {
+	doc, err := readYAMLDocument(filePath)
+	if err != nil {
+		return err
+	}
+	root := doc.Content[0]
+
+	if data.ActiveProfile == "" {
+		removeMappingKey(root, "active_profile")
+	} else {
+		setMappingValue(root, "active_profile",
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: data.ActiveProfile})
+	}
+
+	if len(data.Profiles) == 0 {
+		removeMappingKey(root, "profiles")
+	} else {
+		var profiles yaml.Node
+		if err := profiles.Encode(data.Profiles); err != nil {
+			return fmt.Errorf("failed to encode profiles: %w", err)
+		}
+		for name, profile := range data.Profiles {
+			if profile.Output.CacheTTL == nil {
+				continue
+			}
+			// yaml encodes durations as nanoseconds; write them as "10m".
+			output := mappingValue(mappingValue(&profiles, name), "output")
+			setMappingValue(output, "cache_ttl",
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: profile.Output.CacheTTL.String()})
+		}
+		setMappingValue(root, "profiles", &profiles)
+	}
+
+	return writeYAMLDocument(filePath, doc)
+}
+
+// readYAMLDocument parses filePath into a document node whose root is a mapping.
+// A missing or empty file yields an empty mapping document.
+func readYAMLDocument(filePath string) (*yaml.Node, error) {
+	content, err := os.ReadFile(filePath) //nolint:gosec // path is the user's own config file
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read config file %s: %w", filePath, err)
+	}
+
+	var doc yaml.Node
+	if len(bytes.TrimSpace(content)) > 0 {
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", filePath, err)
+		}
+	}
+
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: %s", clerrors.ErrConfigNotMapping, filePath)
+	}
+
+	return &doc, nil
+}
+
+// writeYAMLDocument encodes doc to filePath with owner-only permissions.
+func writeYAMLDocument(filePath string, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(yamlIndent)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode config file %s: %w", filePath, err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode config file %s: %w", filePath, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), editedDirPermission); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(filePath, buf.Bytes(), editedFilePermission); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// mappingValue returns the value node for key in mapping node m, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue replaces or appends key in mapping node m.
+func setMappingValue(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			// Keep any comment attached to the old value.
+			value.LineComment = m.Content[i+1].LineComment
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		value,
+	)
+}
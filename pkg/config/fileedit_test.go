@@ -0,0 +1,191 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+const fileEditFixture = `# pplx configuration
+defaults:
+  model: sonar # preferred model
+  temperature: 0.2
+search:
+  recency: week
+custom_key: keep-me
+`
+
+func writeFixture(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	return path
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestSetFileValue_PreservesOtherContent(t *testing.T) {
+	t.Parallel()
+
+	path := writeFixture(t, fileEditFixture)
+	if err := SetFileValue(path, []string{"defaults", "model"}, "sonar-pro"); err != nil {
+		t.Fatalf("SetFileValue unexpected error: %v", err)
+	}
+
+	got := readFile(t, path)
+	for _, want := range []string{
+		"# pplx configuration",
+		"model: sonar-pro # preferred model",
+		"temperature: 0.2",
+		"recency: week",
+		"custom_key: keep-me",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("file missing %q after edit:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "defaults:") > strings.Index(got, "search:") {
+		t.Errorf("section order changed:\n%s", got)
+	}
+}
+
+func TestSetFileValue_TypedValues(t *testing.T) {
+	t.Parallel()
+
+	path := writeFixture(t, fileEditFixture)
+	edits := []struct {
+		keyPath []string
+		value   any
+	}{
+		{[]string{"api", "timeout"}, 45 * time.Second},
+		{[]string{"defaults", "max_tokens"}, 512},
+		{[]string{"output", "stream"}, true},
+		{[]string{"search", "domains"}, []string{"a.com", "b.com"}},
+		{[]string{"profiles", "research", "defaults", "temperature"}, 0.1},
+	}
+	for _, e := range edits {
+		if err := SetFileValue(path, e.keyPath, e.value); err != nil {
+			t.Fatalf("SetFileValue(%v) unexpected error: %v", e.keyPath, err)
+		}
+	}
+
+	loader := NewLoader()
+	if err := loader.LoadFrom(path); err != nil {
+		t.Fatalf("edited file does not load: %v", err)
+	}
+	data := loader.Data()
+	if data.API.Timeout != 45*time.Second {
+		t.Errorf("API.Timeout = %v, want 45s", data.API.Timeout)
+	}
+	if data.Defaults.MaxTokens != 512 || !data.Output.Stream || len(data.Search.Domains) != 2 {
+		t.Errorf("typed values not round-tripped: %+v %+v %+v", data.Defaults, data.Output, data.Search)
+	}
+	profile := data.Profiles["research"]
+	if profile == nil || profile.Defaults.Temperature == nil || *profile.Defaults.Temperature != 0.1 {
+		t.Errorf("profile override not written: %+v", profile)
+	}
+}
+
+func TestSetFileValue_CreatesFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", "config.yaml")
+	if err := SetFileValue(path, []string{"search", "mode"}, "academic"); err != nil {
+		t.Fatalf("SetFileValue unexpected error: %v", err)
+	}
+	if got := readFile(t, path); !strings.Contains(got, "mode: academic") {
+		t.Errorf("new file content = %q, want search.mode", got)
+	}
+}
+
+func TestSetFileValue_RejectsNonMapping(t *testing.T) {
+	t.Parallel()
+
+	path := writeFixture(t, "- just\n- a list\n")
+	err := SetFileValue(path, []string{"defaults", "model"}, "sonar")
+	if !errors.Is(err, clerrors.ErrConfigNotMapping) {
+		t.Errorf("SetFileValue error = %v, want ErrConfigNotMapping", err)
+	}
+}
+
+func TestUnsetFileValue(t *testing.T) {
+	t.Parallel()
+
+	path := writeFixture(t, fileEditFixture)
+	if err := UnsetFileValue(path, []string{"defaults", "temperature"}); err != nil {
+		t.Fatalf("UnsetFileValue unexpected error: %v", err)
+	}
+	got := readFile(t, path)
+	if strings.Contains(got, "temperature") {
+		t.Errorf("temperature still present:\n%s", got)
+	}
+	if !strings.Contains(got, "model: sonar") || !strings.Contains(got, "custom_key: keep-me") {
+		t.Errorf("unrelated keys lost:\n%s", got)
+	}
+
+	// Missing keys and files are not errors.
+	if err := UnsetFileValue(path, []string{"profiles", "ghost", "defaults", "model"}); err != nil {
+		t.Errorf("UnsetFileValue on missing key: %v", err)
+	}
+	if err := UnsetFileValue(filepath.Join(t.TempDir(), "none.yaml"), []string{"a", "b"}); err != nil {
+		t.Errorf("UnsetFileValue on missing file: %v", err)
+	}
+}
+
+func TestSaveProfiles_PreservesOtherKeys(t *testing.T) {
+	t.Parallel()
+
+	path := writeFixture(t, fileEditFixture+"api:\n  key: ${PPLX_TEST_KEY}\n")
+	ttl := 10 * time.Minute
+	data := &ConfigData{
+		ActiveProfile: "news",
+		Profiles: map[string]*Profile{
+			"news": {
+				Name:   "news",
+				Search: ProfileSearch{Recency: strPtr("day")},
+				Output: ProfileOutput{CacheTTL: &ttl},
+			},
+		},
+	}
+
+	if err := SaveProfiles(path, data); err != nil {
+		t.Fatalf("SaveProfiles() error: %v", err)
+	}
+
+	got := readFile(t, path)
+	for _, want := range []string{
+		"custom_key: keep-me",
+		"model: sonar # preferred model",
+		"key: ${PPLX_TEST_KEY}",
+		"active_profile: news",
+		"recency: day",
+		"cache_ttl: 10m0s",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("saved file missing %q:\n%s", want, got)
+		}
+	}
+
+	// Removing the last profile drops both keys.
+	if err := SaveProfiles(path, &ConfigData{}); err != nil {
+		t.Fatalf("SaveProfiles() error: %v", err)
+	}
+	got = readFile(t, path)
+	if strings.Contains(got, "profiles:") || strings.Contains(got, "active_profile:") {
+		t.Errorf("profiles not removed:\n%s", got)
+	}
+}
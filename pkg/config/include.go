@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// IncludeKey is the top-level key listing config files merged under a file.
+const IncludeKey = "include"
+
+// includeRef is an entry of an include: list and where it was written.
+type includeRef struct {
+	path string
+	line int
+}
+
+// resolvedDocument is a config file with its includes merged in.
+type resolvedDocument struct {
+	// values is the merged document, without the include key.
+	values map[string]any
+	// origins maps every leaf key, in dot notation, to the file that set it.
+	origins map[string]string
+	// includes lists the included files in merge order.
+	includes []string
+}
+
+// includeResolver expands include: lists depth-first.
+type includeResolver struct {
+	// stack holds the files being resolved, for cycle detection.
+	stack    []string
+	includes []string
+}
+
+// resolveIncludes reads the YAML file at path and deep-merges the files it
+// includes, in order, before the file's own values. Include paths are
+// relative to the including file unless absolute or starting with ~/;
+// later files win.
+func resolveIncludes(path string) (*resolvedDocument, error) {
+	r := &includeResolver{}
+	values, origins, err := r.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return &resolvedDocument{values: values, origins: origins, includes: r.includes}, nil
+}
+
+func (r *includeResolver) resolve(path string) (map[string]any, map[string]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	for i, p := range r.stack {
+		if p == abs {
+			chain := append(append([]string{}, r.stack[i:]...), abs)
+			return nil, nil, fmt.Errorf("%w: %s", clerrors.ErrIncludeCycle, strings.Join(chain, " -> "))
+		}
+	}
+	r.stack = append(r.stack, abs)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	content, err := os.ReadFile(abs) //nolint:gosec // config paths are chosen by the user
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	refs, err := includeRefs(&root, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merged := make(map[string]any)
+	origins := make(map[string]string)
+	for _, ref := range refs {
+		target := ref.path
+		if rest, ok := strings.CutPrefix(target, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				target = filepath.Join(home, rest)
+			}
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(abs), target)
+		}
+		if _, err := os.Stat(target); err != nil {
+			return nil, nil, fmt.Errorf("%w: %s (included from %s:%d)",
+				clerrors.ErrIncludeNotFound, ref.path, path, ref.line)
+		}
+		values, valueOrigins, err := r.resolve(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.includes = append(r.includes, target)
+		deepMerge(merged, values)
+		for key, file := range valueOrigins {
+			origins[key] = file
+		}
+	}
+
+	var own map[string]any
+	if err := root.Decode(&own); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", clerrors.ErrConfigNotMapping, path)
+	}
+	delete(own, IncludeKey)
+	deepMerge(merged, own)
+	recordOrigins(origins, "", own, abs)
+	return merged, origins, nil
+}
+
+// includeRefs returns the entries of the top-level include: list of root.
+func includeRefs(root *yaml.Node, path string) ([]includeRef, error) {
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	list := mappingValue(root.Content[0], IncludeKey)
+	if list == nil {
+		return nil, nil
+	}
+	if list.Kind == yaml.ScalarNode && list.Tag != "!!null" {
+		// A single file is accepted as shorthand for a one-entry list.
+		return []includeRef{{path: list.Value, line: list.Line}}, nil
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+	refs := make([]includeRef, 0, len(list.Content))
+	for _, item := range list.Content {
+		if item.Kind != yaml.ScalarNode || item.Value == "" {
+			return nil, fmt.Errorf("%w (%s:%d)", clerrors.ErrIncludeInvalid, path, item.Line)
+		}
+		refs = append(refs, includeRef{path: item.Value, line: item.Line})
+	}
+	return refs, nil
+}
+
+// deepMerge copies src into dst. Nested mappings are merged key by key;
+// any other value, lists included, replaces the one in dst.
+func deepMerge(dst, src map[string]any) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]any)
+		dstMap, dstIsMap := dst[key].(map[string]any)
+		if srcIsMap && dstIsMap {
+			deepMerge(dstMap, srcMap)
+			continue
+		}
+		if srcIsMap {
+			copied := make(map[string]any, len(srcMap))
+			deepMerge(copied, srcMap)
+			value = copied
+		}
+		dst[key] = value
+	}
+}
+
+// recordOrigins marks every leaf under values as set by file.
+func recordOrigins(origins map[string]string, prefix string, values map[string]any, file string) {
+	for key, value := range values {
+		full := key
+		if prefix != "" {
+			full = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+			recordOrigins(origins, full, nested, file)
+			continue
+		}
+		// A leaf replaces everything an earlier file set beneath it.
+		for existing := range origins {
+			if strings.HasPrefix(existing, full+".") {
+				delete(origins, existing)
+			}
+		}
+		origins[full] = file
+	}
+}
+
+// TraceDocument encodes values as YAML with a comment after every value
+// naming the file it came from, as returned by Loader.Origins.
+func TraceDocument(values map[string]any, origins map[string]string) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(values); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	annotateOrigins(&doc, "", origins)
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return out, nil
+}
+
+// annotateOrigins sets the line comment of each leaf value under node.
+func annotateOrigins(node *yaml.Node, prefix string, origins map[string]string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		full := key.Value
+		if prefix != "" {
+			full = prefix + "." + key.Value
+		}
+		if value.Kind == yaml.MappingNode && len(value.Content) > 0 {
+			annotateOrigins(value, full, origins)
+			continue
+		}
+		if file, ok := origins[full]; ok {
+			if value.Kind == yaml.ScalarNode {
+				value.LineComment = "from " + file
+			} else {
+				key.LineComment = "from " + file
+			}
+		}
+	}
+}
+
+// distinctFiles returns files without repeats, in first-seen order. A file
+// included by two others is merged twice but listed once.
+func distinctFiles(files []string) []string {
+	seen := make(map[string]bool, len(files))
+	out := make([]string, 0, len(files))
+	for _, f := range files {
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out
+}
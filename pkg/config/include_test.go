@@ -0,0 +1,186 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// writeConfigFiles writes name -> content under dir and returns dir.
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadFrom_Includes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"shared/base.yaml": `
+defaults:
+  model: sonar
+  temperature: 0.2
+search:
+  domains: [go.dev]
+  mode: web
+`,
+		"shared/team.yaml": `
+defaults:
+  temperature: 0.4
+search:
+  mode: academic
+`,
+		"config.yaml": `
+include:
+  - shared/base.yaml
+  - shared/team.yaml
+defaults:
+  max_tokens: 500
+search:
+  mode: web
+`,
+	})
+	configPath := filepath.Join(dir, "config.yaml")
+
+	loader := NewLoader()
+	if err := loader.LoadFrom(configPath); err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	cfg := loader.Data()
+	if cfg.Defaults.Model != "sonar" {
+		t.Errorf("model = %q, want sonar from base.yaml", cfg.Defaults.Model)
+	}
+	if cfg.Defaults.Temperature != 0.4 {
+		t.Errorf("temperature = %v, want 0.4 from team.yaml", cfg.Defaults.Temperature)
+	}
+	if cfg.Defaults.MaxTokens != 500 || cfg.Search.Mode != "web" {
+		t.Errorf("main file values should win: max_tokens=%d mode=%q", cfg.Defaults.MaxTokens, cfg.Search.Mode)
+	}
+	if len(cfg.Search.Domains) != 1 || cfg.Search.Domains[0] != "go.dev" {
+		t.Errorf("domains = %v, want [go.dev]", cfg.Search.Domains)
+	}
+
+	if got := loader.Includes(); len(got) != 2 || filepath.Base(got[1]) != "team.yaml" {
+		t.Errorf("Includes() = %v", got)
+	}
+	origins := loader.Origins()
+	wants := map[string]string{
+		"defaults.model":       "base.yaml",
+		"defaults.temperature": "team.yaml",
+		"defaults.max_tokens":  "config.yaml",
+		"search.mode":          "config.yaml",
+		"search.domains":       "base.yaml",
+	}
+	for key, file := range wants {
+		if filepath.Base(origins[key]) != file {
+			t.Errorf("origin of %s = %q, want %s", key, origins[key], file)
+		}
+	}
+	if _, ok := loader.Resolved()[IncludeKey]; ok {
+		t.Error("resolved document should not contain the include key")
+	}
+}
+
+func TestLoadFrom_IncludeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name: "missing file names including file and line",
+			files: map[string]string{
+				"config.yaml": "defaults:\n  model: sonar\ninclude:\n  - nope.yaml\n",
+			},
+			wantErr: clerrors.ErrIncludeNotFound,
+			wantMsg: "config.yaml:4",
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"config.yaml": "include: [a.yaml]\n",
+				"a.yaml":      "include: [b.yaml]\n",
+				"b.yaml":      "include: [a.yaml]\n",
+			},
+			wantErr: clerrors.ErrIncludeCycle,
+			wantMsg: "a.yaml -> ",
+		},
+		{
+			name: "self include",
+			files: map[string]string{
+				"config.yaml": "include: [config.yaml]\n",
+			},
+			wantErr: clerrors.ErrIncludeCycle,
+		},
+		{
+			name: "non-string entry",
+			files: map[string]string{
+				"config.yaml": "include:\n  - {file: a.yaml}\n",
+			},
+			wantErr: clerrors.ErrIncludeInvalid,
+			wantMsg: "config.yaml:2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigFiles(t, tt.files)
+			err := NewLoader().LoadFrom(filepath.Join(dir, "config.yaml"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("err = %q, want it to mention %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestLoadFrom_DiamondIncludeIsNotACycle(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"common.yaml": "defaults:\n  model: sonar\n",
+		"a.yaml":      "include: [common.yaml]\n",
+		"b.yaml":      "include: [common.yaml]\n",
+		"config.yaml": "include: [a.yaml, b.yaml]\n",
+	})
+	loader := NewLoader()
+	if err := loader.LoadFrom(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if got := loader.Includes(); len(got) != 3 {
+		t.Errorf("Includes() = %v, want common, a, b listed once", got)
+	}
+}
+
+func TestTraceDocument(t *testing.T) {
+	values := map[string]any{
+		"defaults": map[string]any{"model": "sonar"},
+		"search":   map[string]any{"domains": []any{"go.dev"}},
+	}
+	origins := map[string]string{
+		"defaults.model": "/etc/base.yaml",
+		"search.domains": "/home/me/config.yaml",
+	}
+	out, err := TraceDocument(values, origins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"model: sonar # from /etc/base.yaml", "domains: # from /home/me/config.yaml"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("trace missing %q:\n%s", want, out)
+		}
+	}
+}
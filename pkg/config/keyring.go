@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/zalando/go-keyring"
+)
+
+// KeyringValue is the api.key value that makes pplx read the API key from
+// the OS keyring (macOS Keychain, Windows Credential Manager, or the Secret
+// Service on Linux) instead of storing it in the config file.
+const KeyringValue = "keyring"
+
+// Keyring entry holding the API key.
+const (
+	KeyringService = "pplx"
+	keyringUser    = "api_key"
+)
+
+// IsKeyringRef reports whether an api.key value refers to the keyring.
+func IsKeyringRef(key string) bool {
+	return strings.EqualFold(strings.TrimSpace(key), KeyringValue)
+}
+
+// StoreAPIKey saves key in the OS keyring, replacing any stored key.
+func StoreAPIKey(key string) error {
+	if err := keyring.Set(KeyringService, keyringUser, key); err != nil {
+		return keyringError("store the API key in", err)
+	}
+	return nil
+}
+
+// LoadAPIKey reads the API key from the OS keyring.
+// Returns a ConfigError wrapping clerrors.ErrKeyringKeyNotFound when no key
+// is stored, or clerrors.ErrKeyringUnavailable when there is no keyring.
+func LoadAPIKey() (string, error) {
+	key, err := keyring.Get(KeyringService, keyringUser)
+	if err != nil {
+		return "", keyringError("read the API key from", err)
+	}
+	return key, nil
+}
+
+// DeleteAPIKey removes the API key from the OS keyring.
+// Returns a ConfigError wrapping clerrors.ErrKeyringKeyNotFound when no key
+// is stored.
+func DeleteAPIKey() error {
+	if err := keyring.Delete(KeyringService, keyringUser); err != nil {
+		return keyringError("remove the API key from", err)
+	}
+	return nil
+}
+
+// resolveKeyringKey replaces an api.key of "keyring" with the key stored in
+// the OS keyring. The keyring is not consulted when PPLX_API_KEY is set,
+// since the environment takes precedence anyway.
+func resolveKeyringKey(cfg *ConfigData) error {
+	if !IsKeyringRef(cfg.API.Key) {
+		return nil
+	}
+	if os.Getenv("PPLX_API_KEY") != "" {
+		cfg.API.Key = ""
+		return nil
+	}
+	key, err := LoadAPIKey()
+	if err != nil {
+		return err
+	}
+	cfg.API.Key = key
+	return nil
+}
+
+// keyringError converts a go-keyring error into a ConfigError. op describes
+// the failed operation, such as "read the API key from".
+func keyringError(op string, err error) error {
+	message := fmt.Sprintf("cannot %s the system keyring", op)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return clerrors.NewConfigError(message,
+			fmt.Errorf("%w (run 'pplx config set-key' to store one)", clerrors.ErrKeyringKeyNotFound))
+	}
+	return clerrors.NewConfigError(message,
+		fmt.Errorf("%w: %w (set PPLX_API_KEY instead)", clerrors.ErrKeyringUnavailable, err))
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/zalando/go-keyring"
+)
+
+// The keyring mock is process-wide, so these tests do not run in parallel.
+
+func TestIsKeyringRef(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"keyring", true},
+		{" Keyring ", true},
+		{"pplx-abc", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsKeyringRef(tt.key); got != tt.want {
+			t.Errorf("IsKeyringRef(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestAPIKeyRoundTrip(t *testing.T) {
+	keyring.MockInit()
+
+	if _, err := LoadAPIKey(); !errors.Is(err, clerrors.ErrKeyringKeyNotFound) {
+		t.Fatalf("LoadAPIKey() on empty keyring error = %v, want ErrKeyringKeyNotFound", err)
+	}
+	if err := StoreAPIKey("pplx-secret"); err != nil {
+		t.Fatalf("StoreAPIKey() error: %v", err)
+	}
+	if key, err := LoadAPIKey(); err != nil || key != "pplx-secret" {
+		t.Fatalf("LoadAPIKey() = %q, %v; want pplx-secret", key, err)
+	}
+	if err := DeleteAPIKey(); err != nil {
+		t.Fatalf("DeleteAPIKey() error: %v", err)
+	}
+	if err := DeleteAPIKey(); !errors.Is(err, clerrors.ErrKeyringKeyNotFound) {
+		t.Errorf("second DeleteAPIKey() error = %v, want ErrKeyringKeyNotFound", err)
+	}
+}
+
+func TestKeyringUnavailable(t *testing.T) {
+	keyring.MockInitWithError(errors.New("no secret service"))
+	t.Cleanup(keyring.MockInit)
+
+	err := StoreAPIKey("pplx-secret")
+	var configErr *clerrors.ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("StoreAPIKey() error = %v, want ConfigError", err)
+	}
+	if !errors.Is(err, clerrors.ErrKeyringUnavailable) {
+		t.Errorf("StoreAPIKey() error = %v, want ErrKeyringUnavailable", err)
+	}
+}
+
+func TestResolveKeyringKey(t *testing.T) {
+	keyring.MockInit()
+	if err := StoreAPIKey("pplx-secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("plain key untouched", func(t *testing.T) {
+		cfg := NewConfigData()
+		cfg.API.Key = "pplx-plain"
+		if err := resolveKeyringKey(cfg); err != nil || cfg.API.Key != "pplx-plain" {
+			t.Errorf("key = %q, err = %v; want pplx-plain", cfg.API.Key, err)
+		}
+	})
+
+	t.Run("keyring ref resolved", func(t *testing.T) {
+		t.Setenv("PPLX_API_KEY", "")
+		cfg := NewConfigData()
+		cfg.API.Key = KeyringValue
+		if err := resolveKeyringKey(cfg); err != nil || cfg.API.Key != "pplx-secret" {
+			t.Errorf("key = %q, err = %v; want pplx-secret", cfg.API.Key, err)
+		}
+	})
+
+	t.Run("environment skips keyring", func(t *testing.T) {
+		t.Setenv("PPLX_API_KEY", "pplx-env")
+		keyring.MockInitWithError(errors.New("no secret service"))
+		t.Cleanup(keyring.MockInit)
+		cfg := NewConfigData()
+		cfg.API.Key = KeyringValue
+		if err := resolveKeyringKey(cfg); err != nil || cfg.API.Key != "" {
+			t.Errorf("key = %q, err = %v; want empty without error", cfg.API.Key, err)
+		}
+	})
+}
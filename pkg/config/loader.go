@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -10,29 +11,32 @@ import (
 
 	"github.com/sgaunet/pplx/pkg/clerrors"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
-// ConfigPaths defines the standard location where config files are searched.
-var ConfigPaths = []string{
-	"$HOME/.config/pplx", // User config directory
-}
-
 // Loader handles loading configuration from files.
 type Loader struct {
 	viper *viper.Viper
 	data  *ConfigData
+	// watchDebounce is the quiet period Watch waits for before reloading.
+	watchDebounce time.Duration
+	// resolved is the loaded file with its includes merged in.
+	resolved *resolvedDocument
+	// unknown lists the keys of the loaded files that no option reads.
+	unknown []UnknownKey
 }
 
 // NewLoader creates a new configuration loader.
 func NewLoader() *Loader {
 	return &Loader{
-		viper: viper.New(),
-		data:  NewConfigData(),
+		viper:         viper.New(),
+		data:          NewConfigData(),
+		watchDebounce: DefaultWatchDebounce,
 	}
 }
 
-// Load loads configuration from the standard location ~/.config/pplx/
-// Searches for config.yaml, pplx.yaml, config.yml, or pplx.yml in that directory.
+// Load loads the user configuration found by FindConfigFile. No file found
+// is not an error: the defaults are kept.
 func (l *Loader) Load() error {
 	// Use FindConfigFile which implements the correct precedence logic,
 	// then delegate to LoadFrom. This avoids a viper limitation where
@@ -59,10 +63,93 @@ func (l *Loader) LoadFrom(path string) error {
 		return fmt.Errorf("error reading config file %s: %w", path, err)
 	}
 
+	resolved, err := resolveIncludes(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	l.resolved = resolved
+	if len(resolved.includes) > 0 {
+		merged, err := yaml.Marshal(resolved.values)
+		if err != nil {
+			return fmt.Errorf("error merging includes of %s: %w", path, err)
+		}
+		l.viper.SetConfigType("yaml")
+		if err := l.viper.ReadConfig(bytes.NewReader(merged)); err != nil {
+			return fmt.Errorf("error merging includes of %s: %w", path, err)
+		}
+	}
+
 	if err := l.viper.Unmarshal(l.data); err != nil {
 		return fmt.Errorf("error unmarshaling config from %s: %w", path, err)
 	}
 
+	l.unknown = nil
+	for _, file := range append([]string{path}, l.Includes()...) {
+		unknown, err := FindUnknownKeys(file)
+		if err != nil {
+			return fmt.Errorf("error checking keys of %s: %w", file, err)
+		}
+		l.unknown = append(l.unknown, unknown...)
+	}
+
+	return nil
+}
+
+// MergeFrom merges the config file at path, with its includes, on top of
+// the loaded configuration: keys it sets win, the others keep their loaded
+// value. It is how the per-project config overrides the user config.
+func (l *Loader) MergeFrom(path string) error {
+	resolved, err := resolveIncludes(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	return l.mergeResolved(path, resolved)
+}
+
+// mergeResolved merges resolved, read from the config file at path, on top
+// of the loaded configuration.
+func (l *Loader) mergeResolved(path string, resolved *resolvedDocument) error {
+	// Check the file on its own first, so that a broken one leaves the
+	// loaded configuration untouched.
+	check := viper.New()
+	if err := check.MergeConfigMap(resolved.values); err != nil {
+		return fmt.Errorf("error merging config file %s: %w", path, err)
+	}
+	if err := check.Unmarshal(NewConfigData()); err != nil {
+		return fmt.Errorf("error unmarshaling config from %s: %w", path, err)
+	}
+	var unknown []UnknownKey
+	for _, file := range append([]string{path}, distinctFiles(resolved.includes)...) {
+		fileUnknown, err := FindUnknownKeys(file)
+		if err != nil {
+			return fmt.Errorf("error checking keys of %s: %w", file, err)
+		}
+		unknown = append(unknown, fileUnknown...)
+	}
+
+	if err := l.viper.MergeConfigMap(resolved.values); err != nil {
+		return fmt.Errorf("error merging config file %s: %w", path, err)
+	}
+	data := NewConfigData()
+	if err := l.viper.Unmarshal(data); err != nil {
+		return fmt.Errorf("error unmarshaling config from %s: %w", path, err)
+	}
+	l.data = data
+
+	if l.resolved == nil {
+		l.resolved = &resolvedDocument{values: make(map[string]any), origins: make(map[string]string)}
+	}
+	deepMerge(l.resolved.values, resolved.values)
+	for key, file := range resolved.origins {
+		for existing := range l.resolved.origins {
+			if strings.HasPrefix(existing, key+".") {
+				delete(l.resolved.origins, existing)
+			}
+		}
+		l.resolved.origins[key] = file
+	}
+	l.resolved.includes = append(l.resolved.includes, resolved.includes...)
+	l.unknown = append(l.unknown, unknown...)
 	return nil
 }
 
@@ -71,39 +158,60 @@ func (l *Loader) Data() *ConfigData {
 	return l.data
 }
 
+// UnknownKeys returns the keys of the loaded file and its includes that no
+// option reads, such as misspelled option names.
+func (l *Loader) UnknownKeys() []UnknownKey {
+	return l.unknown
+}
+
+// Includes returns the files merged into the loaded file through include:,
+// in merge order.
+func (l *Loader) Includes() []string {
+	if l.resolved == nil {
+		return nil
+	}
+	return distinctFiles(l.resolved.includes)
+}
+
+// Resolved returns the loaded document after includes are merged, before
+// environment expansion and profile resolution. It is nil before a file is loaded.
+func (l *Loader) Resolved() map[string]any {
+	if l.resolved == nil {
+		return nil
+	}
+	return l.resolved.values
+}
+
+// Origins maps every key set in the resolved document, in dot notation, to
+// the file that set it.
+func (l *Loader) Origins() map[string]string {
+	if l.resolved == nil {
+		return nil
+	}
+	return l.resolved.origins
+}
+
 // Viper returns the underlying viper instance.
 func (l *Loader) Viper() *viper.Viper {
 	return l.viper
 }
 
-// FindConfigFile searches for a config file in ~/.config/pplx/.
-// Files are checked in precedence order: config.yaml, pplx.yaml, config.yml, pplx.yml.
+// FindConfigFile returns the user config file: the file named by
+// PPLX_CONFIG when set, otherwise the first file found in ConfigDirs. Each
+// directory is checked for config.yaml, pplx.yaml, config.yml, then pplx.yml.
 func FindConfigFile() (string, error) {
-	for _, basePath := range ConfigPaths {
-		expandedPath := os.ExpandEnv(basePath)
-
-		// Try config.yaml (highest precedence)
-		configPath := filepath.Join(expandedPath, "config.yaml")
-		if fileExists(configPath) {
-			return configPath, nil
-		}
-
-		// Try pplx.yaml
-		configPath = filepath.Join(expandedPath, "pplx.yaml")
-		if fileExists(configPath) {
-			return configPath, nil
+	if path := os.Getenv(ConfigPathEnv); path != "" {
+		if !fileExists(path) {
+			return "", fmt.Errorf("%w: %s=%s", clerrors.ErrNoConfigFound, ConfigPathEnv, path)
 		}
-
-		// Try config.yml
-		configPath = filepath.Join(expandedPath, "config.yml")
-		if fileExists(configPath) {
-			return configPath, nil
-		}
-
-		// Try pplx.yml
-		configPath = filepath.Join(expandedPath, "pplx.yml")
-		if fileExists(configPath) {
-			return configPath, nil
+		return path, nil
+	}
+	for _, dir := range ConfigDirs() {
+		for _, name := range configFileNames {
+			configPath := filepath.Join(dir, name)
+			if fileExists(configPath) {
+				return configPath, nil
+			}
 		}
 	}
 
@@ -119,21 +227,25 @@ func fileExists(path string) bool {
 	return !info.IsDir()
 }
 
-// GetDefaultConfigPath returns the default path where config should be created.
+// GetDefaultConfigPath returns the default path where config should be
+// created: PPLX_CONFIG when set, otherwise config.yaml in the first of
+// ConfigDirs.
 func GetDefaultConfigPath() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
+	if path := os.Getenv(ConfigPathEnv); path != "" {
+		return path
+	}
+	dirs := ConfigDirs()
+	if len(dirs) == 0 {
 		return "./pplx.yaml"
 	}
-
-	configDir := filepath.Join(home, ".config", "pplx")
-	return filepath.Join(configDir, "config.yaml")
+	return filepath.Join(dirs[0], "config.yaml")
 }
 
-// ListConfigFiles returns information about all configuration files in ~/.config/pplx/.
+// ListConfigFiles returns information about all configuration files in the
+// user config directory (see UserConfigDir).
 func ListConfigFiles() ([]ConfigFileInfo, error) {
 	// Get config directory
-	configDir := os.ExpandEnv(ConfigPaths[0])
+	configDir := UserConfigDir()
 
 	// Check if directory exists
 	if _, err := os.Stat(configDir); os.IsNotExist(err) {
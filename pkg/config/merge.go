@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/sgaunet/pplx/pkg/clerrors"
 	"github.com/sgaunet/pplx/pkg/logger"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -15,14 +16,17 @@ import (
 type Merger struct {
 	viper *viper.Viper
 	data  *ConfigData
+	// sources records where each config key's value came from; see Sources.
+	sources map[string]Source
 }
 
 // NewMerger creates a new configuration merger.
 func NewMerger(data *ConfigData) *Merger {
 	v := viper.New()
 	return &Merger{
-		viper: v,
-		data:  data,
+		viper:   v,
+		data:    data,
+		sources: make(map[string]Source),
 	}
 }
 
@@ -63,10 +67,14 @@ func (m *Merger) BindFlags(cmd *cobra.Command) error {
 // Design note: This function works with merged config (already includes env vars),
 // so precedence is: CLI flags > (env vars + config file) merged by viper.
 //
+// Duration flags are parsed here, and a value that is not a duration is
+// returned as a clerrors.ValidationError rather than merged as zero.
+//
 //nolint:cyclop,funlen // Function complexity is inherent - checks 29 different CLI flags for explicit user input.
-func (m *Merger) MergeWithFlags(cmd *cobra.Command) *ConfigData {
+func (m *Merger) MergeWithFlags(cmd *cobra.Command) (*ConfigData, error) {
 	// Start with config file data as base (already merged with env vars by viper)
 	merged := m.data
+	m.recordFlagSources(cmd)
 
 	// Defaults section: Model parameters and execution settings
 	// Pattern: Check if flag was explicitly set (Changed), then override config value
@@ -93,7 +101,20 @@ func (m *Merger) MergeWithFlags(cmd *cobra.Command) *ConfigData {
 		merged.Defaults.PresencePenalty = m.viper.GetFloat64("presence-penalty")
 	}
 	if cmd.Flags().Changed("timeout") {
-		merged.Defaults.Timeout = m.viper.GetDuration("timeout").String()
+		timeout, err := m.flagDuration("timeout")
+		if err != nil {
+			return nil, err
+		}
+		merged.Defaults.Timeout = timeout.String()
+	}
+	if cmd.Flags().Changed("language") {
+		merged.Defaults.Language = m.viper.GetString("language")
+	}
+	if cmd.Flags().Changed("sys-prompt") {
+		merged.Defaults.SystemPrompt = m.viper.GetString("sys-prompt")
+	}
+	if cmd.Flags().Changed("fallback-models") {
+		merged.Defaults.ModelFallbacks = m.viper.GetStringSlice("fallback-models")
 	}
 
 	// Search section: Search behavior and filtering options
@@ -101,6 +122,9 @@ func (m *Merger) MergeWithFlags(cmd *cobra.Command) *ConfigData {
 	if cmd.Flags().Changed("search-domains") {
 		merged.Search.Domains = m.viper.GetStringSlice("search-domains")
 	}
+	if cmd.Flags().Changed("exclude-domains") {
+		merged.Search.ExcludeDomains = m.viper.GetStringSlice("exclude-domains")
+	}
 	if cmd.Flags().Changed("search-recency") {
 		merged.Search.Recency = m.viper.GetString("search-recency")
 	}
@@ -163,8 +187,49 @@ func (m *Merger) MergeWithFlags(cmd *cobra.Command) *ConfigData {
 	if cmd.Flags().Changed("reasoning-effort") {
 		merged.Output.ReasoningEffort = m.viper.GetString("reasoning-effort")
 	}
+	if cmd.Flags().Changed("citations") {
+		merged.Output.Citations = m.viper.GetString("citations")
+	}
+	if cmd.Flags().Changed("citation-max-per-domain") {
+		merged.Output.CitationMaxPerDomain = m.viper.GetInt("citation-max-per-domain")
+	}
+	if cmd.Flags().Changed("citation-exclude-domains") {
+		merged.Output.CitationExcludeDomains = m.viper.GetStringSlice("citation-exclude-domains")
+	}
+	if cmd.Flags().Changed("stream-style") {
+		merged.Output.StreamStyle = m.viper.GetString("stream-style")
+	}
+	if cmd.Flags().Changed("auto-continue") {
+		merged.Output.AutoContinue = m.viper.GetInt("auto-continue")
+	}
 
-	return merged
+	// API section: connection behavior
+	if cmd.Flags().Changed("max-retries") {
+		merged.API.MaxRetries = m.viper.GetInt("max-retries")
+	}
+	if cmd.Flags().Changed("connect-timeout") {
+		connectTimeout, err := m.flagDuration("connect-timeout")
+		if err != nil {
+			return nil, err
+		}
+		merged.API.ConnectTimeout = connectTimeout
+	}
+	if cmd.Flags().Changed("base-url") {
+		merged.API.BaseURL = m.viper.GetString("base-url")
+	}
+
+	return merged, nil
+}
+
+// flagDuration returns the value of the duration flag name. viper reads a
+// value that does not parse as zero, so it is parsed again here to report it.
+func (m *Merger) flagDuration(name string) (time.Duration, error) {
+	value := m.viper.GetString(name)
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, clerrors.NewValidationError(name, value, "is not a duration (use a number and a unit, such as 30s or 5m)")
+	}
+	return d, nil
 }
 
 // ApplyToGlobals applies merged configuration to global command variables.
@@ -184,6 +249,7 @@ func ApplyToGlobals(cfg *ConfigData, opts *GlobalOptions) {
 	applyDefaults(cfg, opts)
 	applySearchOptions(cfg, opts)
 	applyOutputOptions(cfg, opts)
+	applyAPIOptions(cfg, opts)
 }
 
 // applyDefaults applies default configuration values to GlobalOptions.
@@ -194,6 +260,9 @@ func applyDefaults(cfg *ConfigData, opts *GlobalOptions) {
 	if cfg.Defaults.Model != "" {
 		opts.Model = cfg.Defaults.Model
 	}
+	if len(cfg.Models.Aliases) > 0 {
+		opts.ModelAliases = cfg.Models.Aliases
+	}
 	if cfg.Defaults.Temperature != 0 {
 		opts.Temperature = cfg.Defaults.Temperature
 	}
@@ -217,6 +286,12 @@ func applyDefaults(cfg *ConfigData, opts *GlobalOptions) {
 			opts.Timeout = d
 		}
 	}
+	if cfg.Defaults.Language != "" {
+		opts.Language = cfg.Defaults.Language
+	}
+	if len(cfg.Defaults.ModelFallbacks) > 0 {
+		opts.ModelFallbacks = cfg.Defaults.ModelFallbacks
+	}
 }
 
 // applySearchOptions applies search configuration values to GlobalOptions.
@@ -226,6 +301,9 @@ func applySearchOptions(cfg *ConfigData, opts *GlobalOptions) {
 	if len(cfg.Search.Domains) > 0 {
 		opts.SearchDomains = cfg.Search.Domains
 	}
+	if len(cfg.Search.ExcludeDomains) > 0 {
+		opts.ExcludeDomains = cfg.Search.ExcludeDomains
+	}
 	if cfg.Search.Recency != "" {
 		opts.SearchRecency = cfg.Search.Recency
 	}
@@ -259,6 +337,71 @@ func applyOutputOptions(cfg *ConfigData, opts *GlobalOptions) {
 	if cfg.Output.Stream {
 		opts.Stream = true
 	}
+	if cfg.Output.Citations != "" {
+		opts.Citations = cfg.Output.Citations
+	}
+	if cfg.Output.CitationMaxPerDomain > 0 {
+		opts.CitationMaxPerDomain = cfg.Output.CitationMaxPerDomain
+	}
+	if len(cfg.Output.CitationExcludeDomains) > 0 {
+		opts.CitationExcludeDomains = cfg.Output.CitationExcludeDomains
+	}
+	if cfg.Output.ImageConflictPolicy != "" {
+		opts.ImageConflictPolicy = cfg.Output.ImageConflictPolicy
+	}
+	if cfg.Output.StrictOptions {
+		opts.StrictOptions = true
+	}
+	if cfg.Output.AutoContinue > 0 {
+		opts.AutoContinue = cfg.Output.AutoContinue
+	}
+	if cfg.Output.Pager != "" {
+		opts.Pager = cfg.Output.Pager
+	}
+	if cfg.Output.StreamStyle != "" {
+		opts.StreamStyle = cfg.Output.StreamStyle
+	}
+	if cfg.Output.StreamCPS > 0 {
+		opts.StreamCPS = cfg.Output.StreamCPS
+	}
+	if cfg.Output.Theme != "" {
+		opts.Theme = cfg.Output.Theme
+	}
+}
+
+// applyAPIOptions applies API connection settings to GlobalOptions.
+// Only non-zero values are applied so the retry defaults survive an absent
+// config field; use --max-retries 0 to disable retries for a single run.
+func applyAPIOptions(cfg *ConfigData, opts *GlobalOptions) {
+	if cfg.API.MaxRetries > 0 {
+		opts.MaxRetries = cfg.API.MaxRetries
+	}
+	if cfg.API.RetryBackoff > 0 {
+		opts.RetryBackoff = cfg.API.RetryBackoff
+	}
+	if cfg.API.ConnectTimeout > 0 {
+		opts.ConnectTimeout = cfg.API.ConnectTimeout
+	}
+	if cfg.API.ResponseHeaderTimeout > 0 {
+		opts.ResponseHeaderTimeout = cfg.API.ResponseHeaderTimeout
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = cfg.API.BaseURL
+	}
+	if cfg.API.ProxyURL != "" {
+		opts.ProxyURL = cfg.API.ProxyURL
+	}
+	opts.TLS = cfg.API.TLS
+	// Total timeout: defaults.timeout (applied by applyDefaults, and set by
+	// --timeout or a profile) > api.total_timeout > the legacy api.timeout.
+	if cfg.Defaults.Timeout == "" {
+		switch {
+		case cfg.API.TotalTimeout > 0:
+			opts.Timeout = cfg.API.TotalTimeout
+		case cfg.API.Timeout > 0:
+			opts.Timeout = cfg.API.Timeout
+		}
+	}
 }
 
 // ExpandEnvVars expands environment variables in configuration values
@@ -270,16 +413,33 @@ func ExpandEnvVars(cfg *ConfigData) {
 
 	// Expand in API config
 	cfg.API.Key = expandString(cfg.API.Key)
+	for id, key := range cfg.API.Keys {
+		cfg.API.Keys[id] = expandString(key)
+	}
 	cfg.API.BaseURL = expandString(cfg.API.BaseURL)
+	cfg.API.ProxyURL = expandString(cfg.API.ProxyURL)
+	cfg.API.MCPAuthToken = expandString(cfg.API.MCPAuthToken)
+	cfg.API.AuditLog = expandString(cfg.API.AuditLog)
+	cfg.API.TLS.CAFile = expandString(cfg.API.TLS.CAFile)
+	cfg.API.TLS.ClientCert = expandString(cfg.API.TLS.ClientCert)
+	cfg.API.TLS.ClientKey = expandString(cfg.API.TLS.ClientKey)
 
 	// Expand in defaults
 	cfg.Defaults.Model = expandString(cfg.Defaults.Model)
 	cfg.Defaults.Timeout = expandString(cfg.Defaults.Timeout)
+	cfg.Defaults.Language = expandString(cfg.Defaults.Language)
+	cfg.Defaults.SystemPrompt = expandString(cfg.Defaults.SystemPrompt)
+	for i, model := range cfg.Defaults.ModelFallbacks {
+		cfg.Defaults.ModelFallbacks[i] = expandString(model)
+	}
 
 	// Expand in search config
 	for i, domain := range cfg.Search.Domains {
 		cfg.Search.Domains[i] = expandString(domain)
 	}
+	for i, domain := range cfg.Search.ExcludeDomains {
+		cfg.Search.ExcludeDomains[i] = expandString(domain)
+	}
 	cfg.Search.LocationCountry = expandString(cfg.Search.LocationCountry)
 
 	// Expand in output config
@@ -289,6 +449,9 @@ func ExpandEnvVars(cfg *ConfigData) {
 	for i, format := range cfg.Output.ImageFormats {
 		cfg.Output.ImageFormats[i] = expandString(format)
 	}
+	for i, domain := range cfg.Output.CitationExcludeDomains {
+		cfg.Output.CitationExcludeDomains[i] = expandString(domain)
+	}
 }
 
 // expandString expands environment variables in a string.
@@ -297,7 +460,12 @@ func expandString(s string) string {
 }
 
 // loadConfig loads configuration from an explicit path or auto-discovers it.
+// PPLX_CONFIG counts as an explicit path. A discovered user config is
+// followed by a second pass merging the per-project config on top of it.
 func loadConfig(loader *Loader, configPath string) error {
+	if configPath == "" {
+		configPath = os.Getenv(ConfigPathEnv)
+	}
 	if configPath != "" {
 		// Explicit path: hard error on failure.
 		return loader.LoadFrom(configPath)
@@ -310,23 +478,51 @@ func loadConfig(loader *Loader, configPath string) error {
 				"error", err)
 		}
 	}
+	if project, ok := FindProjectConfig(); ok {
+		if err := loader.MergeProject(project); err != nil {
+			logger.Warn("project config file has errors, ignoring it",
+				"file", project, "error", err)
+		}
+	}
 	return nil
 }
 
 // LoadAndMergeConfig loads configuration and merges with CLI flags.
 // If profileOverride is non-empty, it takes precedence over the active_profile in the config file.
 func LoadAndMergeConfig(cmd *cobra.Command, configPath, profileOverride string) (*ConfigData, error) {
+	cfg, _, err := LoadAndMergeConfigWithSources(cmd, configPath, profileOverride)
+	return cfg, err
+}
+
+// LoadAndMergeConfigWithSources is LoadAndMergeConfig that also reports
+// where the value of every config key came from (see Merger.Sources).
+func LoadAndMergeConfigWithSources(
+	cmd *cobra.Command, configPath, profileOverride string,
+) (*ConfigData, map[string]Source, error) {
 	loader := NewLoader()
 
 	if err := loadConfig(loader, configPath); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	cfg := loader.Data()
+	warnOutdatedVersion(loader.Viper().ConfigFileUsed(), cfg.Version)
+	warnUnknownKeys(loader.UnknownKeys())
+	merger := NewMerger(cfg)
+	merger.recordFileSources(loader.Viper())
 
 	// Expand environment variables
 	ExpandEnvVars(cfg)
 
+	// api.key: keyring reads the key from the OS keyring.
+	if err := resolveKeyringKey(cfg); err != nil {
+		return nil, nil, err
+	}
+	// api.key: enc:v1:... is decrypted with the config passphrase.
+	if err := resolveEncryptedKey(cfg); err != nil {
+		return nil, nil, err
+	}
+
 	// Determine which profile to apply: CLI flag > config file active_profile.
 	activeProfile := cfg.ActiveProfile
 	if profileOverride != "" {
@@ -340,21 +536,55 @@ func LoadAndMergeConfig(cmd *cobra.Command, configPath, profileOverride string)
 		if err != nil {
 			if profileOverride != "" {
 				// Explicit --profile flag: hard error if profile doesn't exist.
-				return nil, fmt.Errorf("failed to apply profile %q: %w", activeProfile, err)
+				return nil, nil, fmt.Errorf("failed to apply profile %q: %w", activeProfile, err)
 			}
 			logger.Warn("failed to apply profile, using base config",
 				"profile", activeProfile, "error", err)
 		} else {
-			cfg = merged
+			if profile, err := pm.LoadProfile(activeProfile); err == nil {
+				merger.recordProfileSources(profile)
+			}
+			merger.data = merged
 		}
 	}
 
+	// PPLX_* environment variables override the file and the profile.
+	envKeys, err := applyEnvOverrides(merger.data, os.LookupEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	merger.recordEnvSources(envKeys)
+
+	// defaults.system_prompt: file://path is replaced by the file content.
+	if err := resolveSystemPromptFile(merger.data, loader.Viper().ConfigFileUsed()); err != nil {
+		return nil, nil, err
+	}
+
 	// Merge with CLI flags
-	merger := NewMerger(cfg)
 	if err := merger.BindFlags(cmd); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	cfg = merger.MergeWithFlags(cmd)
+	if cfg, err = merger.MergeWithFlags(cmd); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, merger.Sources(), nil
+}
+
+// warnOutdatedVersion logs a warning when the loaded config file predates the
+// current schema. Older files still load; `pplx config migrate` upgrades them.
+func warnOutdatedVersion(path string, version int) {
+	if path == "" || version >= CurrentConfigVersion {
+		return
+	}
+	logger.Warn("config file uses an older schema version, run 'pplx config migrate' to upgrade it",
+		"file", path, "version", version, "current", CurrentConfigVersion)
+}
 
-	return cfg, nil
+// warnUnknownKeys logs a warning for every key of the config file that no
+// option reads. `pplx config validate` reports them as errors.
+func warnUnknownKeys(unknown []UnknownKey) {
+	for _, u := range unknown {
+		logger.Warn(u.Message(), "file", u.File, "line", u.Line)
+	}
 }
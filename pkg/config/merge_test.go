@@ -1,11 +1,17 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
 	"github.com/spf13/cobra"
 )
 
@@ -25,6 +31,8 @@ func createTestCommand() *cobra.Command {
 	cmd.Flags().Float64("frequency-penalty", 0, "Frequency penalty")
 	cmd.Flags().Float64("presence-penalty", 0, "Presence penalty")
 	cmd.Flags().Duration("timeout", 0, "Timeout")
+	cmd.Flags().String("sys-prompt", "", "System prompt")
+	cmd.Flags().StringSlice("fallback-models", nil, "Fallback models")
 
 	// Search flags
 	cmd.Flags().StringSlice("search-domains", nil, "Search domains")
@@ -57,6 +65,23 @@ func createTestCommand() *cobra.Command {
 // Category 1: MergeWithFlags Tests (8 tests)
 // =============================================================================
 
+func TestMergeWithFlags_InvalidDuration(t *testing.T) {
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().String("timeout", "", "Timeout")
+	_ = cmd.Flags().Set("timeout", "banana")
+
+	merger := NewMerger(&ConfigData{Defaults: DefaultsConfig{Timeout: "30s"}})
+	if err := merger.BindFlags(cmd); err != nil {
+		t.Fatalf("Failed to bind flags: %v", err)
+	}
+
+	merged, err := merger.MergeWithFlags(cmd)
+	var valErr *clerrors.ValidationError
+	if !errors.As(err, &valErr) || valErr.Field != "timeout" {
+		t.Fatalf("MergeWithFlags() = %v, %v; want a timeout ValidationError", merged, err)
+	}
+}
+
 func TestMergeWithFlags_NoFlagsChanged(t *testing.T) {
 	// Config file values
 	cfg := &ConfigData{
@@ -80,7 +105,10 @@ func TestMergeWithFlags_NoFlagsChanged(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	// Since no flags were changed, config values should be preserved
 	if merged.Defaults.Model != "config-model" {
@@ -120,7 +148,10 @@ func TestMergeWithFlags_AllFlagsChanged(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	// Flag values should override config
 	if merged.Defaults.Model != "flag-model" {
@@ -152,7 +183,10 @@ func TestMergeWithFlags_PartialFlagsChanged(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	// Changed flags should override
 	if merged.Defaults.Model != "flag-model" {
@@ -190,7 +224,10 @@ func TestMergeWithFlags_ExplicitZeroValues(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	// Explicit zero should override config
 	if merged.Defaults.Temperature != 0 {
@@ -225,7 +262,10 @@ func TestMergeWithFlags_BooleanHandling(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	// Changed flags should override
 	if merged.Output.Stream {
@@ -261,7 +301,10 @@ func TestMergeWithFlags_StringArrays(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	// Changed array should override
 	expectedDomains := []string{"flag1.com", "flag2.com", "flag3.com"}
@@ -280,6 +323,32 @@ func TestMergeWithFlags_StringArrays(t *testing.T) {
 	}
 }
 
+func TestMergeWithFlags_ModelFallbacks(t *testing.T) {
+	cfg := &ConfigData{Defaults: DefaultsConfig{ModelFallbacks: []string{"sonar"}}}
+
+	cmd := createTestCommand()
+	merger := NewMerger(cfg)
+	if err := merger.BindFlags(cmd); err != nil {
+		t.Fatalf("Failed to bind flags: %v", err)
+	}
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
+	if !slices.Equal(merged.Defaults.ModelFallbacks, []string{"sonar"}) {
+		t.Errorf("ModelFallbacks = %v, want the config value", merged.Defaults.ModelFallbacks)
+	}
+
+	_ = cmd.Flags().Set("fallback-models", "sonar-reasoning-pro,sonar-pro")
+	merged, err = merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
+	if want := []string{"sonar-reasoning-pro", "sonar-pro"}; !slices.Equal(merged.Defaults.ModelFallbacks, want) {
+		t.Errorf("ModelFallbacks = %v, want %v from --fallback-models", merged.Defaults.ModelFallbacks, want)
+	}
+}
+
 func TestMergeWithFlags_DurationParsing(t *testing.T) {
 	cfg := &ConfigData{
 		Defaults: DefaultsConfig{
@@ -297,7 +366,10 @@ func TestMergeWithFlags_DurationParsing(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	// Timeout should be overridden and formatted correctly
 	expected := (1*time.Minute + 30*time.Second).String()
@@ -334,7 +406,10 @@ func TestMergeWithFlags_FloatBoundaries(t *testing.T) {
 				t.Fatalf("Failed to bind flags: %v", err)
 			}
 
-			merged := merger.MergeWithFlags(cmd)
+			merged, err := merger.MergeWithFlags(cmd)
+			if err != nil {
+				t.Fatalf("MergeWithFlags() error = %v", err)
+			}
 
 			if merged.Defaults.Temperature != tc.expected {
 				t.Errorf("Expected temperature %f, got %f", tc.expected, merged.Defaults.Temperature)
@@ -362,7 +437,10 @@ func TestPrecedence_CLIOverridesConfig(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	if merged.Defaults.Model != "cli-model" {
 		t.Errorf("CLI flag should override config, got '%s'", merged.Defaults.Model)
@@ -387,7 +465,10 @@ func TestPrecedence_ConfigOverridesDefaults(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	// Config values should be used (they override zero defaults)
 	if merged.Defaults.Model != "config-model" {
@@ -431,7 +512,10 @@ func TestPrecedence_CLIOverridesEnvVar(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	// CLI should override env var (which was in config)
 	if merged.Defaults.Model != "cli-model" {
@@ -492,7 +576,10 @@ func TestPrecedence_FullChainIntegration(t *testing.T) {
 		t.Fatalf("Failed to bind flags: %v", err)
 	}
 
-	merged := merger.MergeWithFlags(cmd)
+	merged, err := merger.MergeWithFlags(cmd)
+	if err != nil {
+		t.Fatalf("MergeWithFlags() error = %v", err)
+	}
 
 	// Verify precedence: CLI > env var > config > defaults
 	if merged.Defaults.Temperature != 0.9 {
@@ -512,6 +599,86 @@ func TestPrecedence_FullChainIntegration(t *testing.T) {
 	}
 }
 
+func TestPrecedence_PPLXEnvOverridesConfig(t *testing.T) {
+	t.Setenv("PPLX_DEFAULTS_MAX_TOKENS", "3000")
+	t.Setenv("PPLX_OUTPUT_STREAM", "true")
+	t.Setenv("PPLX_API_TOTAL_TIMEOUT", "45s")
+	t.Setenv("PPLX_SEARCH_DOMAINS", "go.dev, pkg.go.dev")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `
+defaults:
+  max_tokens: 1000
+search:
+  domains: [example.com]
+output:
+  stream: false
+api:
+  total_timeout: 10s
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, sources, err := LoadAndMergeConfigWithSources(createTestCommand(), configPath, "")
+	if err != nil {
+		t.Fatalf("LoadAndMergeConfigWithSources failed: %v", err)
+	}
+
+	if cfg.Defaults.MaxTokens != 3000 {
+		t.Errorf("Env int should override config, got %d", cfg.Defaults.MaxTokens)
+	}
+	if !cfg.Output.Stream {
+		t.Error("Env bool should override config")
+	}
+	if cfg.API.TotalTimeout != 45*time.Second {
+		t.Errorf("Env duration should override config, got %v", cfg.API.TotalTimeout)
+	}
+	if len(cfg.Search.Domains) != 2 || cfg.Search.Domains[0] != "go.dev" || cfg.Search.Domains[1] != "pkg.go.dev" {
+		t.Errorf("Env list should override config, got %v", cfg.Search.Domains)
+	}
+	for _, key := range []string{"defaults.max_tokens", "output.stream", "api.total_timeout", "search.domains"} {
+		if got := sources[key]; got != SourceEnv {
+			t.Errorf("Source of %s = %v, want %v", key, got, SourceEnv)
+		}
+	}
+}
+
+func TestPrecedence_CLIOverridesPPLXEnv(t *testing.T) {
+	t.Setenv("PPLX_DEFAULTS_MAX_TOKENS", "3000")
+	t.Setenv("PPLX_OUTPUT_STREAM", "true")
+	t.Setenv("PPLX_DEFAULTS_TIMEOUT", "45s")
+	t.Setenv("PPLX_SEARCH_DOMAINS", "go.dev")
+
+	cmd := createTestCommand()
+	_ = cmd.Flags().Set("max-tokens", "500")
+	_ = cmd.Flags().Set("stream", "false")
+	_ = cmd.Flags().Set("timeout", "2m")
+	_ = cmd.Flags().Set("search-domains", "example.com,example.org")
+
+	t.Setenv("HOME", t.TempDir())
+	cfg, sources, err := LoadAndMergeConfigWithSources(cmd, "", "")
+	if err != nil {
+		t.Fatalf("LoadAndMergeConfigWithSources failed: %v", err)
+	}
+
+	if cfg.Defaults.MaxTokens != 500 {
+		t.Errorf("CLI int should override env, got %d", cfg.Defaults.MaxTokens)
+	}
+	if cfg.Output.Stream {
+		t.Error("CLI bool should override env")
+	}
+	if cfg.Defaults.Timeout != "2m0s" {
+		t.Errorf("CLI duration should override env, got %q", cfg.Defaults.Timeout)
+	}
+	if len(cfg.Search.Domains) != 2 || cfg.Search.Domains[0] != "example.com" {
+		t.Errorf("CLI list should override env, got %v", cfg.Search.Domains)
+	}
+	if got := sources["defaults.max_tokens"]; got != SourceFlag {
+		t.Errorf("Source of defaults.max_tokens = %v, want %v", got, SourceFlag)
+	}
+}
+
 // =============================================================================
 // Category 3: LoadAndMergeConfig Integration (6 tests)
 // =============================================================================
@@ -690,6 +857,54 @@ profiles:
 	}
 }
 
+func TestLoadAndMergeConfig_ModelAliasPrecedence(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `
+models:
+  aliases:
+    fast: sonar
+    deep: sonar-deep-research
+
+active_profile: work
+
+profiles:
+  base:
+    name: base
+    models:
+      aliases:
+        smart: sonar-reasoning
+  work:
+    name: work
+    extends: base
+    models:
+      aliases:
+        fast: sonar-pro
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := LoadAndMergeConfig(createTestCommand(), configPath, "")
+	if err != nil {
+		t.Fatalf("LoadAndMergeConfig failed: %v", err)
+	}
+
+	want := map[string]string{
+		"fast":  "sonar-pro",           // active profile beats the global alias
+		"deep":  "sonar-deep-research", // global alias kept
+		"smart": "sonar-reasoning",     // inherited through extends
+	}
+	if !reflect.DeepEqual(cfg.Models.Aliases, want) {
+		t.Errorf("Aliases = %v, want %v", cfg.Models.Aliases, want)
+	}
+
+	opts := NewGlobalOptions()
+	ApplyToGlobals(cfg, opts)
+	if opts.ModelAliases["fast"] != "sonar-pro" {
+		t.Errorf("ApplyToGlobals ModelAliases = %v, want the merged aliases", opts.ModelAliases)
+	}
+}
+
 func TestLoadAndMergeConfig_InvalidConfigPath(t *testing.T) {
 	cmd := createTestCommand()
 
@@ -699,3 +914,244 @@ func TestLoadAndMergeConfig_InvalidConfigPath(t *testing.T) {
 		t.Error("Expected error for invalid config path")
 	}
 }
+
+func TestLoadAndMergeConfigWithSources(t *testing.T) {
+	t.Setenv("PPLX_API_KEY", "")
+	t.Setenv("TEST_PPLX_COUNTRY", "FR")
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+defaults:
+  model: base-model
+  temperature: 0.5
+  max_tokens: 1000
+
+search:
+  location_country: ${TEST_PPLX_COUNTRY}
+
+active_profile: production
+
+profiles:
+  production:
+    name: production
+    defaults:
+      model: prod-model
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cmd := createTestCommand()
+	if err := cmd.Flags().Set("temperature", "0.9"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, sources, err := LoadAndMergeConfigWithSources(cmd, configPath, "")
+	if err != nil {
+		t.Fatalf("LoadAndMergeConfigWithSources failed: %v", err)
+	}
+	if cfg.Defaults.Temperature != 0.9 || cfg.Search.LocationCountry != "FR" {
+		t.Errorf("merged config = %+v", cfg.Defaults)
+	}
+
+	want := map[string]Source{
+		"defaults.model":          SourceProfile,
+		"defaults.temperature":    SourceFlag,
+		"defaults.max_tokens":     SourceConfig,
+		"search.location_country": SourceEnv,
+		"search.recency":          SourceDefault,
+	}
+	for key, source := range want {
+		if got := sources[key]; got != source {
+			t.Errorf("sources[%q] = %q, want %q", key, got, source)
+		}
+	}
+	if len(sources) != len(AllKeys()) {
+		t.Errorf("got %d sources, want one per key (%d)", len(sources), len(AllKeys()))
+	}
+}
+
+func TestApplyToGlobals_Timeouts(t *testing.T) {
+	tests := []struct {
+		name      string
+		api       APIConfig
+		defaults  string
+		wantTotal time.Duration
+	}{
+		{"built-in default", APIConfig{}, "", perplexity.DefaultTimeout},
+		{"legacy api.timeout", APIConfig{Timeout: time.Minute}, "", time.Minute},
+		{"total_timeout beats api.timeout", APIConfig{Timeout: time.Minute, TotalTimeout: 10 * time.Minute}, "", 10 * time.Minute},
+		{"defaults.timeout beats total_timeout", APIConfig{TotalTimeout: 10 * time.Minute}, "2m", 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfigData()
+			cfg.API = tt.api
+			cfg.API.ConnectTimeout = 5 * time.Second
+			cfg.Defaults.Timeout = tt.defaults
+			opts := NewGlobalOptions()
+
+			ApplyToGlobals(cfg, opts)
+
+			got := opts.Timeouts()
+			if got.Total != tt.wantTotal {
+				t.Errorf("total = %v, want %v", got.Total, tt.wantTotal)
+			}
+			if got.Connect != 5*time.Second {
+				t.Errorf("connect = %v, want 5s", got.Connect)
+			}
+		})
+	}
+}
+
+func TestLoadAndMergeConfig_ProjectConfigPrecedence(t *testing.T) {
+	home := isolateConfigSearch(t)
+	writeConfigFile(t, filepath.Join(home, ".config", "pplx", "config.yaml"), `
+defaults:
+  model: user-model
+  temperature: 0.5
+search:
+  mode: web
+  domains: [go.dev]
+`)
+	writeConfigFile(t, ProjectConfigName, `
+defaults:
+  model: project-model
+search:
+  mode: academic
+`)
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		flags      map[string]string
+		wantModel  string
+		wantMode   string
+		wantSource Source
+	}{
+		{name: "project over user", wantModel: "project-model", wantMode: "academic", wantSource: SourceConfig},
+		{name: "env over project", env: map[string]string{"PPLX_DEFAULTS_MODEL": "env-model"},
+			wantModel: "env-model", wantMode: "academic", wantSource: SourceEnv},
+		{name: "flag over project", flags: map[string]string{"model": "flag-model"},
+			wantModel: "flag-model", wantMode: "academic", wantSource: SourceFlag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cmd := createTestCommand()
+			for k, v := range tt.flags {
+				_ = cmd.Flags().Set(k, v)
+			}
+			cfg, sources, err := LoadAndMergeConfigWithSources(cmd, "", "")
+			if err != nil {
+				t.Fatalf("LoadAndMergeConfigWithSources failed: %v", err)
+			}
+			if cfg.Defaults.Model != tt.wantModel || cfg.Search.Mode != tt.wantMode {
+				t.Errorf("model, mode = %q, %q; want %q, %q",
+					cfg.Defaults.Model, cfg.Search.Mode, tt.wantModel, tt.wantMode)
+			}
+			// Keys the project file does not set keep the user value.
+			if cfg.Defaults.Temperature != 0.5 || len(cfg.Search.Domains) != 1 {
+				t.Errorf("temperature, domains = %v, %v; want the user values", cfg.Defaults.Temperature, cfg.Search.Domains)
+			}
+			if got := sources["defaults.model"]; got != tt.wantSource {
+				t.Errorf("Source of defaults.model = %v, want %v", got, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestLoadAndMergeConfig_ProjectConfigIgnored(t *testing.T) {
+	home := isolateConfigSearch(t)
+	userConfig := filepath.Join(home, ".config", "pplx", "config.yaml")
+	writeConfigFile(t, userConfig, "defaults:\n  model: user-model\n")
+	writeConfigFile(t, ProjectConfigName, "defaults:\n  model: project-model\n")
+
+	t.Run("explicit path", func(t *testing.T) {
+		cfg, err := LoadAndMergeConfig(createTestCommand(), userConfig, "")
+		if err != nil || cfg.Defaults.Model != "user-model" {
+			t.Errorf("model = %q, err = %v; want user-model", cfg.Defaults.Model, err)
+		}
+	})
+
+	t.Run("PPLX_CONFIG", func(t *testing.T) {
+		t.Setenv(ConfigPathEnv, userConfig)
+		cfg, err := LoadAndMergeConfig(createTestCommand(), "", "")
+		if err != nil || cfg.Defaults.Model != "user-model" {
+			t.Errorf("model = %q, err = %v; want user-model", cfg.Defaults.Model, err)
+		}
+	})
+
+	t.Run("missing PPLX_CONFIG", func(t *testing.T) {
+		t.Setenv(ConfigPathEnv, filepath.Join(home, "missing.yaml"))
+		if _, err := LoadAndMergeConfig(createTestCommand(), "", ""); err == nil {
+			t.Error("LoadAndMergeConfig() with a missing PPLX_CONFIG file succeeded, want an error")
+		}
+	})
+
+	t.Run("broken project file", func(t *testing.T) {
+		writeConfigFile(t, ProjectConfigName, "defaults:\n  max_tokens: [not, a, number]\n")
+		cfg, err := LoadAndMergeConfig(createTestCommand(), "", "")
+		if err != nil || cfg.Defaults.Model != "user-model" {
+			t.Errorf("model = %q, err = %v; want the user config alone", cfg.Defaults.Model, err)
+		}
+	})
+}
+
+func TestLoadAndMergeConfig_SystemPrompt(t *testing.T) {
+	home := isolateConfigSearch(t)
+	configDir := filepath.Join(home, ".config", "pplx")
+	writeConfigFile(t, filepath.Join(configDir, "prompts", "research.md"), "Cite peer-reviewed sources.\n")
+	writeConfigFile(t, filepath.Join(configDir, "config.yaml"), `
+defaults:
+  system_prompt: Be brief, ${PPLX_TEST_AUDIENCE}.
+profiles:
+  research:
+    defaults:
+      system_prompt: file://prompts/research.md
+  missing:
+    defaults:
+      system_prompt: file://prompts/missing.md
+`)
+	t.Setenv("PPLX_TEST_AUDIENCE", "engineers")
+
+	tests := []struct {
+		name    string
+		profile string
+		flag    string
+		want    string
+	}{
+		{name: "config file, env expanded", want: "Be brief, engineers."},
+		{name: "profile file relative to the config", profile: "research", want: "Cite peer-reviewed sources."},
+		{name: "flag over profile", profile: "research", flag: "Answer in haiku.", want: "Answer in haiku."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := createTestCommand()
+			if tt.flag != "" {
+				_ = cmd.Flags().Set("sys-prompt", tt.flag)
+			}
+			cfg, sources, err := LoadAndMergeConfigWithSources(cmd, "", tt.profile)
+			if err != nil {
+				t.Fatalf("LoadAndMergeConfigWithSources failed: %v", err)
+			}
+			if cfg.Defaults.SystemPrompt != tt.want {
+				t.Errorf("system_prompt = %q, want %q", cfg.Defaults.SystemPrompt, tt.want)
+			}
+			if tt.flag != "" && sources["defaults.system_prompt"] != SourceFlag {
+				t.Errorf("source = %v, want flag", sources["defaults.system_prompt"])
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, _, err := LoadAndMergeConfigWithSources(createTestCommand(), "", "missing")
+		var configErr *clerrors.ConfigError
+		if !errors.As(err, &configErr) || !strings.Contains(err.Error(), filepath.Join(configDir, "prompts", "missing.md")) {
+			t.Errorf("error = %v, want a ConfigError naming the file", err)
+		}
+	})
+}
@@ -2,13 +2,19 @@ package config
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"text/tabwriter"
 
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/citations"
 	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/validation"
 	"gopkg.in/yaml.v3"
 )
 
@@ -18,6 +24,8 @@ const (
 	SectionSearch   = "search"
 	SectionOutput   = "output"
 	SectionAPI      = "api"
+	SectionModels   = "models"
+	SectionPrompts  = "prompts"
 
 	// Default table formatting constants.
 	defaultMaxDescLength = 60
@@ -27,7 +35,7 @@ const (
 
 // OptionMetadata represents metadata for a single configuration option.
 type OptionMetadata struct {
-	// Section is the configuration section (defaults, search, output, api)
+	// Section is the configuration section (defaults, search, output, api, prompts)
 	Section string `json:"section" yaml:"section"`
 
 	// Name is the configuration option name
@@ -48,22 +56,34 @@ type OptionMetadata struct {
 	// Example provides an example value
 	Example string `json:"example,omitempty" yaml:"example,omitempty"`
 
-	// EnvVar is the environment variable name (if applicable)
+	// EnvVar is the PPLX_* environment variable overriding this option
+	// (see EnvVarName); empty for prompt template fields
 	EnvVar string `json:"env_var,omitempty" yaml:"env_var,omitempty"`
 
 	// Required indicates if this option must be set
 	Required bool `json:"required" yaml:"required"`
+
+	// Order is the position of the option within its section, in the order
+	// options are registered; listings and generated files follow it
+	Order int `json:"order" yaml:"order"`
+
+	// Deprecated, when set, explains what replaces this option
+	Deprecated string `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
 }
 
 // MetadataRegistry holds all configuration option metadata.
 type MetadataRegistry struct {
 	options map[string]*OptionMetadata // key is "section.name"
+	// counts holds the number of options registered per section, which
+	// gives the Order of the next one.
+	counts map[string]int
 }
 
 // NewMetadataRegistry creates a new metadata registry with all options.
 func NewMetadataRegistry() *MetadataRegistry {
 	registry := &MetadataRegistry{
 		options: make(map[string]*OptionMetadata),
+		counts:  make(map[string]int),
 	}
 	registry.initialize()
 	return registry
@@ -80,11 +100,12 @@ func NewMetadataRegistry() *MetadataRegistry {
 // config wizard prompts, and validation logic become inconsistent over time.
 // This single definition ensures all user-facing surfaces show identical information.
 //
-// Structure: 4 sections covering 31 total configuration options
+// Structure: 5 sections covering 38 total configuration options
 //   - Defaults: Model parameters and execution settings (8 options)
 //   - Search: Query behavior and filtering options (11 options)
-//   - Output: Response format and presentation (9 options)
-//   - API: Authentication and connection settings (3 options)
+//   - Output: Response format and presentation (10 options)
+//   - API: Authentication and connection settings (6 options)
+//   - Prompts: Fields of each named prompt template (3 options)
 //
 //nolint:funcorder,funlen,maintidx // Keep initialization near constructor; metadata registry initialization
 func (r *MetadataRegistry) initialize() {
@@ -124,8 +145,9 @@ func (r *MetadataRegistry) initialize() {
 		Default:     0,
 		Example:     "4096",
 		ValidationRules: []string{
-			"Must be positive integer",
-			"Model-dependent maximum (typically 4096-16384)",
+			"Must be a positive integer; 0 or unset leaves the model default",
+			"At most the output limit of the model (max_output_tokens in pplx models); larger values are rejected",
+			"--max-tokens max selects the output limit of the model",
 		},
 	})
 
@@ -182,11 +204,51 @@ func (r *MetadataRegistry) initialize() {
 		Section:     SectionDefaults,
 		Name:        "timeout",
 		Type:        "string",
-		Description: "Timeout for API requests",
+		Description: "Total timeout for API requests; overrides api.total_timeout",
 		Default:     "",
 		Example:     "30s",
 		ValidationRules: []string{
 			"Format: duration string (e.g., '30s', '5m')",
+			"Must not be negative",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionDefaults,
+		Name:        "language",
+		Type:        "string",
+		Description: "Language answers are requested in, added as an instruction to the system prompt",
+		Default:     "",
+		Example:     "fr",
+		ValidationRules: []string{
+			"ISO 639-1 code, e.g. 'fr', 'de', 'es', 'ja'",
+			"Not applied when the system prompt already names a language",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionDefaults,
+		Name:        "system_prompt",
+		Type:        "string",
+		Description: "System prompt used when none is given with --sys-prompt or --system-prompt-file",
+		Default:     "",
+		Example:     "file://~/.config/pplx/prompts/research.md",
+		ValidationRules: []string{
+			"Environment variables are expanded",
+			"file://path loads the prompt from a file; a relative path is relative to the config file",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionDefaults,
+		Name:        "model_fallbacks",
+		Type:        "[]string",
+		Description: "Models a request is sent to, in order, when the API reports the model unavailable or invalid",
+		Default:     nil,
+		Example:     "sonar-pro,sonar",
+		ValidationRules: []string{
+			"Model names or aliases",
+			"Authentication and other request errors are not retried with another model",
 		},
 	})
 
@@ -203,6 +265,21 @@ func (r *MetadataRegistry) initialize() {
 		Example:     "wikipedia.org,github.com",
 		ValidationRules: []string{
 			"List of domain names",
+			"Host names only: no scheme or path",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionSearch,
+		Name:        "exclude_domains",
+		Type:        "[]string",
+		Description: "Exclude specific domains from search",
+		Default:     nil,
+		Example:     "pinterest.com,quora.com",
+		ValidationRules: []string{
+			"Must not also be listed in domains",
+			"Host names only: no scheme or path",
+			"Sent to the API as -domain entries of the domain filter",
 		},
 	})
 
@@ -214,7 +291,7 @@ func (r *MetadataRegistry) initialize() {
 		Default:     "",
 		Example:     "week",
 		ValidationRules: []string{
-			"Valid values: hour, day, week, month, year",
+			"Valid values: " + validation.ValidList(validation.KindRecency),
 		},
 	})
 
@@ -226,7 +303,7 @@ func (r *MetadataRegistry) initialize() {
 		Default:     "web",
 		Example:     "academic",
 		ValidationRules: []string{
-			"Valid values: web, academic",
+			"Valid values: " + validation.ValidList(validation.KindSearchMode),
 		},
 	})
 
@@ -238,7 +315,7 @@ func (r *MetadataRegistry) initialize() {
 		Default:     "",
 		Example:     "medium",
 		ValidationRules: []string{
-			"Valid values: low, medium, high",
+			"Valid values: " + validation.ValidList(validation.KindContextSize),
 		},
 	})
 
@@ -251,6 +328,7 @@ func (r *MetadataRegistry) initialize() {
 		Example:     "37.7749",
 		ValidationRules: []string{
 			"Must be between -90.0 and 90.0",
+			ruleLocationPair,
 		},
 	})
 
@@ -263,6 +341,7 @@ func (r *MetadataRegistry) initialize() {
 		Example:     "-122.4194",
 		ValidationRules: []string{
 			"Must be between -180.0 and 180.0",
+			ruleLocationPair,
 		},
 	})
 
@@ -275,6 +354,7 @@ func (r *MetadataRegistry) initialize() {
 		Example:     "US",
 		ValidationRules: []string{
 			"Format: ISO 3166-1 alpha-2 code",
+			"Case-insensitive, e.g. US, GB, or FR",
 		},
 	})
 
@@ -286,7 +366,8 @@ func (r *MetadataRegistry) initialize() {
 		Default:     "",
 		Example:     "01/01/2024",
 		ValidationRules: []string{
-			"Format: MM/DD/YYYY",
+			"Format: " + validation.DateFormats,
+			ruleAfterBeforeDate,
 		},
 	})
 
@@ -298,7 +379,7 @@ func (r *MetadataRegistry) initialize() {
 		Default:     "",
 		Example:     "12/31/2024",
 		ValidationRules: []string{
-			"Format: MM/DD/YYYY",
+			"Format: " + validation.DateFormats,
 		},
 	})
 
@@ -310,7 +391,8 @@ func (r *MetadataRegistry) initialize() {
 		Default:     "",
 		Example:     "01/01/2024",
 		ValidationRules: []string{
-			"Format: MM/DD/YYYY",
+			"Format: " + validation.DateFormats,
+			ruleLastUpdatedOrder,
 		},
 	})
 
@@ -322,7 +404,7 @@ func (r *MetadataRegistry) initialize() {
 		Default:     "",
 		Example:     "12/31/2024",
 		ValidationRules: []string{
-			"Format: MM/DD/YYYY",
+			"Format: " + validation.DateFormats,
 		},
 	})
 
@@ -383,7 +465,7 @@ func (r *MetadataRegistry) initialize() {
 		Default:     nil,
 		Example:     "jpg,png",
 		ValidationRules: []string{
-			"Valid values: jpg, png, gif, webp",
+			"Valid values: " + validation.ValidList(validation.KindImageFormat),
 		},
 	})
 
@@ -394,6 +476,9 @@ func (r *MetadataRegistry) initialize() {
 		Description: "JSON schema for structured output (sonar model only)",
 		Default:     "",
 		Example:     `{"type":"object","properties":{"answer":{"type":"string"}}}`,
+		ValidationRules: []string{
+			ruleSingleResponseFormat,
+		},
 	})
 
 	r.addOption(&OptionMetadata{
@@ -413,7 +498,182 @@ func (r *MetadataRegistry) initialize() {
 		Default:     "",
 		Example:     "medium",
 		ValidationRules: []string{
-			"Valid values: low, medium, high",
+			"Valid values: " + validation.ValidList(validation.KindReasoningEffort),
+			ruleDeepResearchOnly,
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "citations",
+		Type:        "string",
+		Description: "How citation markers such as [1] are shown in answers",
+		Default:     string(citations.StyleList),
+		Example:     string(citations.StyleFootnote),
+		ValidationRules: []string{
+			"Valid values: " + strings.Join(citations.Styles(), ", "),
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "citation_max_per_domain",
+		Type:        "int",
+		Description: "How many sources of one domain are listed; the URLs of the others are grouped beneath the last",
+		Default:     0,
+		Example:     "1",
+		ValidationRules: []string{
+			"Must be positive",
+			"0 lists every source",
+			"Markers of grouped sources point to the entry they are grouped beneath",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "citation_exclude_domains",
+		Type:        "[]string",
+		Description: "Domains hidden from the sources of answers, along with their citation markers",
+		Default:     []string{},
+		Example:     "[pinterest.com, quora.com]",
+		ValidationRules: []string{
+			"Host names only: no scheme or path",
+			"A domain also hides its subdomains",
+			"The remaining markers are renumbered to match the sources shown",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "image_conflict_policy",
+		Type:        "string",
+		Description: "What to do when search recency and images are both requested, which the API cannot combine",
+		Default:     validation.ImageConflictPreferImages,
+		Example:     validation.ImageConflictError,
+		ValidationRules: []string{
+			"Valid values: " + validation.ValidList(validation.KindImageConflict),
+			"prefer_images drops the recency filter, prefer_recency drops the images, error rejects the request",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "strict_options",
+		Type:        "bool",
+		Description: "Reject option combinations the API ignores instead of adjusting the request with a warning",
+		Default:     false,
+		Example:     "true",
+		ValidationRules: []string{
+			"Streaming with response_format_json_schema or response_format_regex turns streaming off " +
+				"unless this is set, in which case the request is rejected",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "cache_ttl",
+		Type:        "duration",
+		Description: "Cache query responses on disk and serve identical queries for this long",
+		Default:     nil,
+		Example:     "1h",
+		ValidationRules: []string{
+			"Format: duration (e.g., 30m, 24h)",
+			"Must be positive",
+			"Empty disables the cache unless --cache is given",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "auto_continue",
+		Type:        "int",
+		Description: "Follow-up requests sent at most to complete an answer cut off at max_tokens",
+		Default:     0,
+		Example:     "2",
+		ValidationRules: []string{
+			"Range: 0-10",
+			"0 disables automatic continuation",
+			"Every continuation is billed as a request",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "pager",
+		Type:        "string",
+		Description: "Command showing query answers taller than the terminal, such as less -R",
+		Default:     "",
+		Example:     "less -RF",
+		ValidationRules: []string{
+			"Empty uses $PAGER, then less -R",
+			"Only used when stdout is a terminal, and never for streamed or JSON output",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "stream_style",
+		Type:        "string",
+		Description: "How streamed answers are written: instant, or smooth for a word-by-word typewriter effect",
+		Default:     "instant",
+		Example:     "smooth",
+		ValidationRules: []string{
+			"Must be one of: instant, smooth",
+			"Output that is not a terminal is always written instantly",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "stream_cps",
+		Type:        "int",
+		Description: "Characters per second written by smooth streaming",
+		Default:     0,
+		Example:     "200",
+		ValidationRules: []string{
+			"Must not be negative",
+			"0 uses 120 characters per second",
+			"Smooth streaming never falls more than 3 seconds behind the answer",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "theme",
+		Type:        "string",
+		Description: "Palette of colored output: headings, citation markers, warnings, and errors",
+		Default:     "default",
+		Example:     "high-contrast",
+		ValidationRules: []string{
+			"Must be one of: default, high-contrast",
+			"Only applies when output is colored; see --color and NO_COLOR",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "history",
+		Type:        "bool",
+		Description: "Record the user prompt of each query in ~/.local/share/pplx/prompt_history.jsonl",
+		Default:     true,
+		Example:     "false",
+		ValidationRules: []string{
+			"Prompts are stored as given; --no-history leaves out a single query",
+			"Batch queries are not recorded",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionOutput,
+		Name:        "config_backups",
+		Type:        "int",
+		Description: "Backups of the config file kept in backups/ next to it, saved before each rewrite",
+		Default:     0,
+		Example:     "20",
+		ValidationRules: []string{
+			"Must not be negative",
+			"0 keeps 10 backups",
+			"The oldest backups are deleted first",
 		},
 	})
 
@@ -427,31 +687,355 @@ func (r *MetadataRegistry) initialize() {
 		Type:        "string",
 		Description: "API key for authentication",
 		Default:     "",
-		EnvVar:      "PERPLEXITY_API_KEY",
 		Required:    true,
 		ValidationRules: []string{
 			"Required for API access",
 		},
 	})
 
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "keys",
+		Type:        "map[string]string",
+		Description: "Named API keys that MCP tool calls select with the key_id argument",
+		Default:     nil,
+		Example:     "{alice: ${ALICE_PPLX_KEY}, bob: ${BOB_PPLX_KEY}}",
+		ValidationRules: []string{
+			"Supports environment variable expansion",
+			"The MCP server refuses to start when a key is empty after expansion",
+			"Calls without key_id or api_key use api.key",
+		},
+	})
+
 	r.addOption(&OptionMetadata{
 		Section:     SectionAPI,
 		Name:        "base_url",
 		Type:        "string",
-		Description: "Custom API base URL (if using a proxy or custom endpoint)",
+		Description: "Base URL of a Perplexity-compatible API, such as an internal gateway",
 		Default:     "",
 		Example:     "https://api.perplexity.ai",
+		ValidationRules: []string{
+			"Must be an http or https URL with a host",
+			"/chat/completions is appended unless the URL already ends with it",
+			"Override per run with --base-url",
+			"Supports environment variable expansion",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "proxy_url",
+		Type:        "string",
+		Description: "HTTP proxy that API calls go through",
+		Default:     "",
+		Example:     "http://proxy.corp.example:3128",
+		ValidationRules: []string{
+			"Must be an http or https URL with a host",
+			"Empty uses the HTTPS_PROXY and NO_PROXY environment variables",
+			"Supports environment variable expansion",
+		},
 	})
 
 	r.addOption(&OptionMetadata{
 		Section:     SectionAPI,
 		Name:        "timeout",
 		Type:        "duration",
-		Description: "API request timeout",
+		Description: "Total timeout of one API call",
+		Deprecated:  "use api.total_timeout",
 		Default:     nil,
 		Example:     "30s",
 		ValidationRules: []string{
 			"Format: duration (e.g., 30s, 2m)",
+			"Used only when total_timeout and defaults.timeout are unset",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "connect_timeout",
+		Type:        "duration",
+		Description: "Timeout for connecting to the API, so an unreachable API fails fast",
+		Default:     nil,
+		Example:     "5s",
+		ValidationRules: []string{
+			"Format: duration (e.g., 5s, 500ms)",
+			"Must not be negative; unset leaves only the total timeout",
+			"Override per run with --connect-timeout",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "response_header_timeout",
+		Type:        "duration",
+		Description: "Timeout for the API to start responding once a request is sent",
+		Default:     nil,
+		Example:     "30s",
+		ValidationRules: []string{
+			"Format: duration (e.g., 30s, 2m)",
+			"Must not be negative; unset leaves only the total timeout",
+			"Keep it above the model's time to first token (minutes for sonar-deep-research without streaming)",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "total_timeout",
+		Type:        "duration",
+		Description: "Total timeout of one API call, retries included; applies per turn in chat",
+		Default:     perplexity.DefaultTimeout.String(),
+		Example:     "10m",
+		ValidationRules: []string{
+			"Format: duration (e.g., 2m, 10m)",
+			"Must not be negative",
+			"defaults.timeout, --timeout, and profiles override it",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "stream_idle_timeout",
+		Type:        "duration",
+		Description: "Time a streaming MCP request may go without data before it is aborted with the partial answer",
+		Default:     "1m0s",
+		Example:     "2m",
+		ValidationRules: []string{
+			"Format: duration (e.g., 30s, 2m)",
+			"Must not be negative; 0 uses the default",
+			"Counted from the request, then from each chunk received",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "max_retries",
+		Type:        "int",
+		Description: "Retries on rate limit (429) and server errors (5xx); 0 uses the default",
+		Default:     retry.DefaultMaxRetries,
+		Example:     "5",
+		ValidationRules: []string{
+			"Range: 0 to 10",
+			"Override per run with --max-retries (0 disables retries)",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "retry_backoff",
+		Type:        "duration",
+		Description: "Maximum delay between retries, including server Retry-After hints",
+		Default:     retry.DefaultMaxBackoff.String(),
+		Example:     "10s",
+		ValidationRules: []string{
+			"Format: duration (e.g., 500ms, 10s)",
+			"Must be positive",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "mcp_auth_token",
+		Type:        "string",
+		Description: "Bearer token clients must send to the pplx mcp-http server",
+		Default:     "",
+		Example:     "${PPLX_MCP_TOKEN}",
+		ValidationRules: []string{
+			"Empty disables authentication",
+			"Supports environment variable expansion",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "audit_log",
+		Type:        "string",
+		Description: "JSON lines file recording every request sent by chat and the MCP server, and its outcome",
+		Default:     "",
+		Example:     "${HOME}/.local/share/pplx/audit.jsonl",
+		ValidationRules: []string{
+			"Empty disables the audit log",
+			"A request that cannot be recorded is not sent",
+			"Supports environment variable expansion",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "system_prompt_prefix",
+		Type:        "string",
+		Description: "Text prepended to the system message of every request sent by chat and the MCP server",
+		Default:     "",
+		Example:     "Follow the ACME style guide.",
+		ValidationRules: []string{
+			"Added as the system message when a request has none",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "requests_per_minute",
+		Type:        "int",
+		Description: "Requests per minute the MCP server and batch mode may send, shared by parallel requests",
+		Default:     0,
+		Example:     "50",
+		ValidationRules: []string{
+			"Must be positive",
+			"0 disables rate limiting",
+			"--rate-limit overrides it in batch mode",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "burst",
+		Type:        "int",
+		Description: "Requests that may be sent at once before api.requests_per_minute applies",
+		Default:     1,
+		Example:     "5",
+		ValidationRules: []string{
+			"Must be positive",
+			"Ignored when api.requests_per_minute is 0",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "daily_budget_usd",
+		Type:        "float64",
+		Description: "Estimated USD that query and the MCP server may spend per day; requests projected over it are refused",
+		Default:     0.0,
+		Example:     "2.50",
+		ValidationRules: []string{
+			"Must not be negative",
+			"0 disables the budget",
+			"Spending is read from the usage log and resets at local midnight",
+			"pplx query --force bypasses it",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "per_request_max_tokens",
+		Type:        "int",
+		Description: "Upper bound on max_tokens of every request sent by query and the MCP server",
+		Default:     0,
+		Example:     "2000",
+		ValidationRules: []string{
+			"Must be positive",
+			"0 is no cap",
+			"Also bounds the cost projected for api.daily_budget_usd",
+		},
+	})
+
+	// API section: TLS
+	// Options of the nested tls block, for corporate proxies that re-sign
+	// traffic and gateways requiring client certificates.
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "tls.ca_file",
+		Type:        "string",
+		Description: "PEM bundle of certificate authorities trusted for the API connection, besides the system ones",
+		Default:     "",
+		Example:     "/etc/ssl/corp-proxy-ca.pem",
+		ValidationRules: []string{
+			"Must be a readable file holding at least one PEM certificate",
+			"Supports environment variable expansion",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section: SectionAPI,
+		Name:    "tls.insecure_skip_verify",
+		Type:    "bool",
+		Description: "WARNING: INSECURE. Disables TLS certificate verification of the API connection, " +
+			"exposing the API key and every answer to interception. For debugging only",
+		Default: false,
+		Example: "false",
+		ValidationRules: []string{
+			"Never enable outside a debugging session; prefer api.tls.ca_file",
+			"Each run prints a warning on stderr while it is enabled",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "tls.client_cert",
+		Type:        "string",
+		Description: "PEM client certificate presented to the API for mutual TLS",
+		Default:     "",
+		Example:     "${HOME}/.config/pplx/client.pem",
+		ValidationRules: []string{
+			"Must be set together with api.tls.client_key",
+			"Supports environment variable expansion",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionAPI,
+		Name:        "tls.client_key",
+		Type:        "string",
+		Description: "PEM private key of api.tls.client_cert",
+		Default:     "",
+		Example:     "${HOME}/.config/pplx/client-key.pem",
+		ValidationRules: []string{
+			"Must be set together with api.tls.client_cert",
+			"Supports environment variable expansion",
+		},
+	})
+
+	// Models section: Model aliases
+	// Aliases are short names for model IDs, expanded before a request is built.
+	// A profile's aliases override the global ones with the same name.
+	r.addOption(&OptionMetadata{
+		Section:     SectionModels,
+		Name:        "aliases",
+		Type:        "map[string]string",
+		Description: "Short names for model IDs, usable wherever a model is accepted",
+		Default:     nil,
+		Example:     "{fast: sonar, deep: sonar-deep-research}",
+		ValidationRules: []string{
+			"Targets must not be empty",
+			"Targets are not expanded again: aliases do not chain",
+			"Profiles can define aliases; they override global aliases with the same name",
+		},
+	})
+
+	// Prompts section: Named prompt templates
+	// Each entry under prompts is a template selected with --prompt-template and
+	// filled from --var flags. The names are chosen by the user, so the options
+	// below document the fields of a single template, shown as <name>.field.
+	r.addOption(&OptionMetadata{
+		Section:     SectionPrompts,
+		Name:        "<name>.description",
+		Type:        "string",
+		Description: "Short description shown by pplx prompts list",
+		Default:     "",
+		Example:     "Review code in a given language",
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionPrompts,
+		Name:        "<name>.system",
+		Type:        "string",
+		Description: "System prompt text with {{variable}} placeholders",
+		Default:     "",
+		Example:     "You are an expert {{language}} reviewer.",
+		ValidationRules: []string{
+			"Placeholders use text/template syntax; {{var}} is short for {{.var}}",
+			"Replaces --sys-prompt when set",
+		},
+	})
+
+	r.addOption(&OptionMetadata{
+		Section:     SectionPrompts,
+		Name:        "<name>.user",
+		Type:        "string",
+		Description: "User prompt text with {{variable}} placeholders",
+		Default:     "",
+		Example:     "Review {{file}} for bugs.",
+		ValidationRules: []string{
+			"Placeholders use text/template syntax; {{var}} is short for {{.var}}",
+			"Used when no prompt is given on the command line",
 		},
 	})
 }
@@ -461,6 +1045,13 @@ func (r *MetadataRegistry) initialize() {
 //nolint:funcorder // Keep helper near initialization for readability
 func (r *MetadataRegistry) addOption(opt *OptionMetadata) {
 	key := fmt.Sprintf("%s.%s", opt.Section, opt.Name)
+	if opt.Section != SectionPrompts && !isMapOption(opt) {
+		// Prompt template names and map keys are chosen by the user, so they
+		// have no variable.
+		opt.EnvVar = EnvVarName(key)
+	}
+	opt.Order = r.counts[opt.Section]
+	r.counts[opt.Section]++
 	r.options[key] = opt
 }
 
@@ -473,9 +1064,10 @@ func (r *MetadataRegistry) GetOption(name string) (*OptionMetadata, error) {
 		return opt, nil
 	}
 
-	// Try searching by name only
-	for key, opt := range r.options {
-		if strings.HasSuffix(key, "."+name) {
+	// Try searching by name only, in section order so that a name used in
+	// several sections always resolves to the same option
+	for _, opt := range r.GetAll() {
+		if opt.Name == name {
 			return opt, nil
 		}
 	}
@@ -483,7 +1075,7 @@ func (r *MetadataRegistry) GetOption(name string) (*OptionMetadata, error) {
 	return nil, fmt.Errorf("%w: %s", clerrors.ErrOptionNotFound, name)
 }
 
-// GetBySection returns all options for a specific section.
+// GetBySection returns all options for a specific section, in order.
 func (r *MetadataRegistry) GetBySection(section string) []*OptionMetadata {
 	var result []*OptionMetadata
 
@@ -496,26 +1088,51 @@ func (r *MetadataRegistry) GetBySection(section string) []*OptionMetadata {
 		}
 	}
 
+	SortOptions(result)
 	return result
 }
 
-// GetAll returns all registered option metadata.
+// GetAll returns all registered option metadata, sorted by section then order.
 func (r *MetadataRegistry) GetAll() []*OptionMetadata {
 	result := make([]*OptionMetadata, 0, len(r.options))
 	for _, opt := range r.options {
 		result = append(result, opt)
 	}
+	SortOptions(result)
 	return result
 }
 
+// SortOptions sorts options by section, in ListSections order, then by
+// Order within a section.
+func SortOptions(options []*OptionMetadata) {
+	slices.SortStableFunc(options, func(a, b *OptionMetadata) int {
+		ra, rb := slices.Index(sectionOrder, a.Section), slices.Index(sectionOrder, b.Section)
+		if c := cmp.Compare(ra, rb); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Order, b.Order)
+	})
+}
+
+// sectionOrder lists the sections in the order of the config file.
+var sectionOrder = []string{
+	SectionDefaults,
+	SectionSearch,
+	SectionOutput,
+	SectionAPI,
+	SectionModels,
+	SectionPrompts,
+}
+
 // ListSections returns all available section names.
 func (r *MetadataRegistry) ListSections() []string {
-	return []string{
-		SectionDefaults,
-		SectionSearch,
-		SectionOutput,
-		SectionAPI,
-	}
+	return slices.Clone(sectionOrder)
+}
+
+// isMapOption reports whether opt holds a map with user-chosen keys, which
+// `config set` and environment variables cannot address.
+func isMapOption(opt *OptionMetadata) bool {
+	return strings.HasPrefix(opt.Type, "map[")
 }
 
 // Count returns the total number of registered options.
@@ -555,10 +1172,10 @@ func (f *TableFormatter) Format(options []*OptionMetadata) (string, error) {
 	w := tabwriter.NewWriter(&buf, 0, 0, defaultTabPadding, ' ', 0)
 
 	// Write header
-	if _, err := fmt.Fprintf(w, "SECTION\tNAME\tTYPE\tDEFAULT\tDESCRIPTION\n"); err != nil {
+	if _, err := fmt.Fprintf(w, "SECTION\tNAME\tTYPE\tDEFAULT\tENV VAR\tDESCRIPTION\n"); err != nil {
 		return "", fmt.Errorf("failed to write header: %w", err)
 	}
-	if _, err := fmt.Fprintf(w, "-------\t----\t----\t-------\t-----------\n"); err != nil {
+	if _, err := fmt.Fprintf(w, "-------\t----\t----\t-------\t-------\t-----------\n"); err != nil {
 		return "", fmt.Errorf("failed to write separator: %w", err)
 	}
 
@@ -568,9 +1185,17 @@ func (f *TableFormatter) Format(options []*OptionMetadata) (string, error) {
 		name := opt.Name
 		typ := opt.Type
 		def := formatDefault(opt.Default)
-		desc := truncate(opt.Description, f.maxDescLength)
+		desc := opt.Description
+		if opt.Deprecated != "" {
+			desc = "[DEPRECATED: " + opt.Deprecated + "] " + desc
+		}
+		desc = truncate(desc, f.maxDescLength)
+		env := opt.EnvVar
+		if env == "" {
+			env = "(none)"
+		}
 
-		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", section, name, typ, def, desc); err != nil {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", section, name, typ, def, env, desc); err != nil {
 			return "", fmt.Errorf("failed to write row: %w", err)
 		}
 	}
@@ -635,11 +1260,14 @@ func (f *YAMLFormatter) Format(options []*OptionMetadata) (string, error) {
 	return string(data), nil
 }
 
-// FormatOptions formats option metadata using the specified format.
-// Supported formats: table, json, yaml.
+// FormatOptions formats option metadata using the specified format, in
+// section then option order. Supported formats: table, json, yaml.
 func FormatOptions(options []*OptionMetadata, format string) (string, error) {
 	var formatter Formatter
 
+	options = slices.Clone(options)
+	SortOptions(options)
+
 	switch strings.ToLower(format) {
 	case "table":
 		formatter = NewTableFormatter()
@@ -2,6 +2,8 @@ package config
 
 import (
 	"encoding/json"
+	"slices"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -33,8 +35,8 @@ func TestMetadataRegistry_Count(t *testing.T) {
 	registry := NewMetadataRegistry()
 	count := registry.Count()
 
-	// Should have 31 total options (8 defaults + 11 search + 9 output + 3 api)
-	expectedCount := 31
+	// Should have 71 total options (11 defaults + 12 search + 22 output + 22 api + 1 models + 3 prompts)
+	expectedCount := 71
 	if count != expectedCount {
 		t.Errorf("Count() = %d, want %d", count, expectedCount)
 	}
@@ -48,10 +50,12 @@ func TestMetadataRegistry_CountBySection(t *testing.T) {
 		section       string
 		expectedCount int
 	}{
-		{SectionDefaults, 8},
-		{SectionSearch, 11},
-		{SectionOutput, 9},
-		{SectionAPI, 3},
+		{SectionDefaults, 11},
+		{SectionSearch, 12},
+		{SectionOutput, 22},
+		{SectionAPI, 22},
+		{SectionModels, 1},
+		{SectionPrompts, 3},
 	}
 
 	registry := NewMetadataRegistry()
@@ -141,12 +145,12 @@ func TestMetadataRegistry_GetBySection(t *testing.T) {
 		section       string
 		expectedCount int
 	}{
-		{SectionDefaults, 8},
-		{SectionSearch, 11},
-		{SectionOutput, 9},
-		{SectionAPI, 3},
-		{"DEFAULTS", 8}, // Case insensitive
-		{"Search", 11},  // Case insensitive
+		{SectionDefaults, 11},
+		{SectionSearch, 12},
+		{SectionOutput, 22},
+		{SectionAPI, 22},
+		{"DEFAULTS", 11}, // Case insensitive
+		{"Search", 12},   // Case insensitive
 	}
 
 	for _, tt := range tests {
@@ -174,7 +178,7 @@ func TestMetadataRegistry_GetAll(t *testing.T) {
 	registry := NewMetadataRegistry()
 	allOptions := registry.GetAll()
 
-	expectedCount := 31 // 8 + 11 + 9 + 3
+	expectedCount := 71 // 11 + 12 + 22 + 22 + 1 + 3
 	if len(allOptions) != expectedCount {
 		t.Errorf("GetAll() returned %d options, want %d", len(allOptions), expectedCount)
 	}
@@ -196,6 +200,41 @@ func TestMetadataRegistry_GetAll(t *testing.T) {
 	}
 }
 
+// TestMetadataRegistry_Order tests that options are listed in section then
+// registration order.
+func TestMetadataRegistry_Order(t *testing.T) {
+	t.Parallel()
+
+	registry := NewMetadataRegistry()
+	all := registry.GetAll()
+	if all[0].Section != SectionDefaults || all[0].Name != "model" || all[0].Order != 0 {
+		t.Errorf("first option = %s.%s (order %d), want defaults.model (order 0)", all[0].Section, all[0].Name, all[0].Order)
+	}
+	sections := registry.ListSections()
+	for i := 1; i < len(all); i++ {
+		prev, cur := all[i-1], all[i]
+		ps, cs := slices.Index(sections, prev.Section), slices.Index(sections, cur.Section)
+		if ps > cs || (ps == cs && prev.Order >= cur.Order) {
+			t.Errorf("%s.%s listed before %s.%s", prev.Section, prev.Name, cur.Section, cur.Name)
+		}
+	}
+
+	for _, section := range sections {
+		for i, opt := range registry.GetBySection(section) {
+			if opt.Order != i {
+				t.Errorf("GetBySection(%s)[%d] = %s with order %d", section, i, opt.Name, opt.Order)
+			}
+		}
+	}
+
+	// A name used in several sections resolves to the first section's option.
+	for range 10 {
+		if opt, err := registry.GetOption("timeout"); err != nil || opt.Section != SectionDefaults {
+			t.Fatalf("GetOption(timeout) = %+v, %v, want defaults.timeout", opt, err)
+		}
+	}
+}
+
 // TestMetadataRegistry_ListSections tests section listing.
 func TestMetadataRegistry_ListSections(t *testing.T) {
 	t.Parallel()
@@ -203,7 +242,7 @@ func TestMetadataRegistry_ListSections(t *testing.T) {
 	registry := NewMetadataRegistry()
 	sections := registry.ListSections()
 
-	expectedSections := []string{SectionDefaults, SectionSearch, SectionOutput, SectionAPI}
+	expectedSections := []string{SectionDefaults, SectionSearch, SectionOutput, SectionAPI, SectionModels, SectionPrompts}
 	if len(sections) != len(expectedSections) {
 		t.Errorf("ListSections() returned %d sections, want %d", len(sections), len(expectedSections))
 	}
@@ -251,6 +290,8 @@ func TestOptionMetadata_RequiredFields(t *testing.T) {
 				"bool":     true,
 				"[]string": true,
 				"duration": true,
+
+				"map[string]string": true,
 			}
 			if !validTypes[opt.Type] {
 				t.Errorf("Option has invalid Type: %s", opt.Type)
@@ -307,8 +348,8 @@ func TestOptionMetadata_APIKeyRequired(t *testing.T) {
 		t.Error("API key should be marked as required")
 	}
 
-	if opt.EnvVar != "PERPLEXITY_API_KEY" {
-		t.Errorf("API key EnvVar = %s, want PERPLEXITY_API_KEY", opt.EnvVar)
+	if opt.EnvVar != "PPLX_API_KEY" {
+		t.Errorf("API key EnvVar = %s, want PPLX_API_KEY", opt.EnvVar)
 	}
 }
 
@@ -409,6 +450,33 @@ func TestTableFormatter(t *testing.T) {
 		}
 	})
 
+	t.Run("format_deprecated_option", func(t *testing.T) {
+		result, err := formatter.Format([]*OptionMetadata{
+			{Section: "test", Name: "old", Type: "int", Description: "Old option", Deprecated: "use new"},
+		})
+		if err != nil {
+			t.Errorf("Format() error = %v", err)
+		}
+		if !containsAll(result, "[DEPRECATED: use new] Old option") {
+			t.Errorf("Format() does not flag the deprecated option:\n%s", result)
+		}
+	})
+
+	t.Run("format_sorts_options", func(t *testing.T) {
+		result, err := FormatOptions([]*OptionMetadata{
+			{Section: SectionAPI, Name: "second", Type: "int", Description: "d", Order: 1},
+			{Section: SectionAPI, Name: "first", Type: "int", Description: "d", Order: 0},
+			{Section: SectionDefaults, Name: "zeroth", Type: "int", Description: "d", Order: 5},
+		}, "table")
+		if err != nil {
+			t.Fatalf("FormatOptions() error = %v", err)
+		}
+		z, f, s := strings.Index(result, "zeroth"), strings.Index(result, "first"), strings.Index(result, "second")
+		if z > f || f > s {
+			t.Errorf("FormatOptions() did not sort by section then order:\n%s", result)
+		}
+	})
+
 	t.Run("format_registry_options", func(t *testing.T) {
 		registry := NewMetadataRegistry()
 		options := registry.GetBySection(SectionDefaults)
@@ -1,15 +1,323 @@
 package config
 
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"gopkg.in/yaml.v3"
+)
+
 // CurrentConfigVersion is the latest supported config schema version.
-const CurrentConfigVersion = 1
+const CurrentConfigVersion = 2
+
+// BackupSuffix is appended to the config file name for the copy written
+// before a migration.
+const BackupSuffix = ".bak"
+
+// Migration upgrades a config document from version From to From+1.
+//
+// Apply edits the YAML node tree in place, so comments and key order of the
+// file survive the migration. It receives the root mapping of the document
+// and returns a description of every change it made.
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(root *yaml.Node) []string
+}
+
+// migrations lists the registered steps in version order. Step i upgrades
+// version i to version i+1.
+var migrations = []Migration{
+	{
+		From:        0,
+		Description: "rename legacy keys",
+		Apply:       renameLegacyKeys,
+	},
+	{
+		From:        1,
+		Description: "normalize legacy recency and timeout values",
+		Apply:       normalizeLegacyValues,
+	},
+}
+
+// MigrationResult reports what a migration did.
+type MigrationResult struct {
+	FromVersion int
+	ToVersion   int
+	// Changes describes each edit, e.g. "search.recency_filter → search.recency".
+	Changes []string
+	// BackupPath is the copy of the original file; empty when nothing was written.
+	BackupPath string
+}
+
+// Migrated reports whether the document was upgraded.
+func (r *MigrationResult) Migrated() bool {
+	return r.ToVersion > r.FromVersion
+}
+
+// legacyKeyRenames maps keys of version 0 files to their current names,
+// per section.
+var legacyKeyRenames = []struct {
+	section, from, to string
+}{
+	{"search", "recency_filter", "recency"},
+	{"search", "domain_filter", "domains"},
+	{"output", "return_related_questions", "return_related"},
+	{"api", "api_key", "key"},
+}
+
+// legacyRecencyValues maps recency spellings accepted by old releases to the
+// values the API takes.
+var legacyRecencyValues = map[string]string{
+	"hourly":  "hour",
+	"daily":   "day",
+	"weekly":  "week",
+	"monthly": "month",
+	"yearly":  "year",
+}
+
+// legacyTimeoutKeys are duration options that old releases read as a plain
+// number of seconds.
+var legacyTimeoutKeys = []struct {
+	section, key string
+}{
+	{"defaults", "timeout"},
+	{"api", "timeout"},
+	{"api", "retry_backoff"},
+	{"api", "connect_timeout"},
+	{"api", "response_header_timeout"},
+	{"api", "total_timeout"},
+}
+
+// DetectVersion returns the schema version of a config document's root
+// mapping. A missing or unreadable version field is version 0.
+func DetectVersion(root *yaml.Node) int {
+	node := mappingValue(root, "version")
+	if node == nil {
+		return 0
+	}
+	version, err := strconv.Atoi(node.Value)
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+// FileVersion returns the schema version of the config file at path.
+func FileVersion(path string) (int, error) {
+	doc, err := readYAMLDocument(path)
+	if err != nil {
+		return 0, err
+	}
+	return DetectVersion(doc.Content[0]), nil
+}
+
+// MigrateDocument applies every pending migration to doc and sets its
+// version field to CurrentConfigVersion.
+func MigrateDocument(doc *yaml.Node) (*MigrationResult, error) {
+	root := doc.Content[0]
+	from := DetectVersion(root)
+	result := &MigrationResult{FromVersion: from, ToVersion: from}
+	if from > CurrentConfigVersion {
+		return nil, fmt.Errorf("%w: version %d, this pplx supports up to %d",
+			clerrors.ErrConfigVersionTooNew, from, CurrentConfigVersion)
+	}
+	if from == CurrentConfigVersion {
+		return result, nil
+	}
+
+	for _, step := range migrations[from:] {
+		result.Changes = append(result.Changes, step.Apply(root)...)
+	}
+	setVersion(root, CurrentConfigVersion)
+	result.ToVersion = CurrentConfigVersion
+	result.Changes = append(result.Changes,
+		fmt.Sprintf("version: %d → %d", from, CurrentConfigVersion))
+	return result, nil
+}
+
+// MigrateYAML migrates config file content and returns the new content.
+// Content that is already current is returned unchanged.
+func MigrateYAML(content []byte) ([]byte, *MigrationResult, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, clerrors.ErrConfigNotMapping
+	}
+
+	result, err := MigrateDocument(&doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !result.Migrated() {
+		return content, result, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(yamlIndent)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return buf.Bytes(), result, nil
+}
+
+// MigrateFile migrates the config file at path in place. Before writing, the
+// original content is copied to path + BackupSuffix. A file that is already
+// current is left untouched and no backup is written.
+func MigrateFile(path string) (*MigrationResult, error) {
+	content, err := os.ReadFile(path) //nolint:gosec // path is the user's own config file
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	migrated, result, err := MigrateYAML(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+	if !result.Migrated() {
+		return result, nil
+	}
+
+	backup := path + BackupSuffix
+	if err := os.WriteFile(backup, content, editedFilePermission); err != nil {
+		return nil, fmt.Errorf("failed to write backup %s: %w", backup, err)
+	}
+	result.BackupPath = backup
+
+	if err := os.WriteFile(path, migrated, editedFilePermission); err != nil {
+		return nil, fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// renameLegacyKeys is migration 0 → 1. When both the old and the new key are
+// set, the new key wins and the old one is removed.
+func renameLegacyKeys(root *yaml.Node) []string {
+	var changes []string
+	forEachScope(root, func(prefix string, scope *yaml.Node) {
+		for _, r := range legacyKeyRenames {
+			section := mappingValue(scope, r.section)
+			if section == nil || section.Kind != yaml.MappingNode {
+				continue
+			}
+			keyNode := mappingKey(section, r.from)
+			if keyNode == nil {
+				continue
+			}
+			oldPath := prefix + r.section + "." + r.from
+			newPath := prefix + r.section + "." + r.to
+			if mappingValue(section, r.to) != nil {
+				removeMappingKey(section, r.from)
+				changes = append(changes, fmt.Sprintf("removed %s (%s is already set)", oldPath, newPath))
+				continue
+			}
+			keyNode.Value = r.to
+			changes = append(changes, fmt.Sprintf("%s → %s", oldPath, newPath))
+		}
+	})
+	return changes
+}
+
+// normalizeLegacyValues is migration 1 → 2. It rewrites recency values such
+// as "daily" to "day" and timeouts given as bare seconds (30 or "30") to
+// duration strings ("30s").
+func normalizeLegacyValues(root *yaml.Node) []string {
+	var changes []string
+	forEachScope(root, func(prefix string, scope *yaml.Node) {
+		if search := mappingValue(scope, "search"); search != nil {
+			if node := mappingValue(search, "recency"); node != nil && node.Kind == yaml.ScalarNode {
+				if value, ok := legacyRecencyValues[node.Value]; ok {
+					changes = append(changes, fmt.Sprintf("%ssearch.recency: %q → %q", prefix, node.Value, value))
+					setScalarString(node, value)
+				}
+			}
+		}
+		for _, t := range legacyTimeoutKeys {
+			section := mappingValue(scope, t.section)
+			if section == nil {
+				continue
+			}
+			node := mappingValue(section, t.key)
+			if node == nil || node.Kind != yaml.ScalarNode {
+				continue
+			}
+			seconds, err := strconv.Atoi(node.Value)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			value := strconv.Itoa(seconds) + "s"
+			changes = append(changes, fmt.Sprintf("%s%s.%s: %s → %q", prefix, t.section, t.key, node.Value, value))
+			setScalarString(node, value)
+		}
+	})
+	return changes
+}
+
+// forEachScope calls fn for the top-level sections and for each profile,
+// with the key prefix that locates the scope in the file.
+func forEachScope(root *yaml.Node, fn func(prefix string, scope *yaml.Node)) {
+	fn("", root)
+	profiles := mappingValue(root, "profiles")
+	if profiles == nil || profiles.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		if profile := profiles.Content[i+1]; profile.Kind == yaml.MappingNode {
+			fn("profiles."+profiles.Content[i].Value+".", profile)
+		}
+	}
+}
+
+// setVersion sets the version field of root, adding it as the first key
+// when it is missing.
+func setVersion(root *yaml.Node, version int) {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+	if mappingValue(root, "version") != nil {
+		setMappingValue(root, "version", value)
+		return
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	root.Content = append([]*yaml.Node{key, value}, root.Content...)
+}
+
+// setScalarString replaces the value of scalar node n with the string s.
+func setScalarString(n *yaml.Node, s string) {
+	n.Tag = "!!str"
+	n.Value = s
+	n.Style = 0
+}
+
+// mappingKey returns the key node for key in mapping node m, or nil.
+func mappingKey(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i]
+		}
+	}
+	return nil
+}
 
-// MigrateConfig checks the config version and applies any needed migrations.
-// Returns (migrated bool, summary string, err error).
-func MigrateConfig(cfg *ConfigData) (bool, string, error) {
-	if cfg.Version >= CurrentConfigVersion {
-		return false, "already at latest version", nil
+// removeMappingKey deletes key and its value from mapping node m.
+func removeMappingKey(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
 	}
-	// Version 0 (unset) → 1: just set the version field.
-	cfg.Version = CurrentConfigVersion
-	return true, "migrated from version 0 to version 1 (added version field)", nil
 }
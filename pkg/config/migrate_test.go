@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestMigrateYAML_Golden(t *testing.T) {
+	tests := []struct {
+		name        string
+		fromVersion int
+		wantChanges []string
+	}{
+		{
+			name:        "v0",
+			fromVersion: 0,
+			wantChanges: []string{
+				"search.recency_filter → search.recency",
+				"search.domain_filter → search.domains",
+				"output.return_related_questions → output.return_related",
+				"api.api_key → api.key",
+				`removed profiles.news.search.recency_filter (profiles.news.search.recency is already set)`,
+				`search.recency: "daily" → "day"`,
+				`defaults.timeout: 60 → "60s"`,
+				`api.timeout: 30 → "30s"`,
+				`api.retry_backoff: 2 → "2s"`,
+				`profiles.news.api.timeout: 120 → "120s"`,
+				"version: 0 → 2",
+			},
+		},
+		{
+			name:        "v1",
+			fromVersion: 1,
+			wantChanges: []string{
+				`search.recency: "monthly" → "month"`,
+				`api.retry_backoff: 5 → "5s"`,
+				"version: 1 → 2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := filepath.Join("testdata", "migrate", tt.name+".yaml")
+			golden := filepath.Join("testdata", "migrate", tt.name+".golden.yaml")
+
+			content, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, result, err := MigrateYAML(content)
+			if err != nil {
+				t.Fatalf("MigrateYAML() error: %v", err)
+			}
+
+			if result.FromVersion != tt.fromVersion || result.ToVersion != CurrentConfigVersion {
+				t.Errorf("versions = %d → %d, want %d → %d",
+					result.FromVersion, result.ToVersion, tt.fromVersion, CurrentConfigVersion)
+			}
+			if !slices.Equal(result.Changes, tt.wantChanges) {
+				t.Errorf("changes:\n got %q\nwant %q", result.Changes, tt.wantChanges)
+			}
+
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("migrated YAML does not match %s:\n--- got ---\n%s\n--- want ---\n%s", golden, got, want)
+			}
+
+			// The migrated file loads and passes validation.
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, got, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			loader := NewLoader()
+			if err := loader.LoadFrom(path); err != nil {
+				t.Fatalf("loading migrated config: %v", err)
+			}
+			if err := NewValidator().Validate(loader.Data()); err != nil {
+				t.Errorf("migrated config is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateYAML_Current(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "migrate", "v2.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, result, err := MigrateYAML(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Migrated() || len(result.Changes) != 0 {
+		t.Errorf("current config was migrated: %+v", result)
+	}
+	if string(got) != string(content) {
+		t.Error("current config content changed")
+	}
+}
+
+func TestMigrateYAML_TooNew(t *testing.T) {
+	_, _, err := MigrateYAML([]byte("version: 99\n"))
+	if !errors.Is(err, clerrors.ErrConfigVersionTooNew) {
+		t.Errorf("error = %v, want ErrConfigVersionTooNew", err)
+	}
+}
+
+func TestMigrateFile_WritesBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	original := []byte("search:\n  recency_filter: hourly\n")
+	if err := os.WriteFile(path, original, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := MigrateFile(path)
+	if err != nil {
+		t.Fatalf("MigrateFile() error: %v", err)
+	}
+	if result.BackupPath != path+BackupSuffix {
+		t.Errorf("BackupPath = %q", result.BackupPath)
+	}
+	backup, err := os.ReadFile(result.BackupPath)
+	if err != nil || string(backup) != string(original) {
+		t.Errorf("backup = %q, %v; want original content", backup, err)
+	}
+
+	version, err := FileVersion(path)
+	if err != nil || version != CurrentConfigVersion {
+		t.Errorf("FileVersion() = %d, %v; want %d", version, err, CurrentConfigVersion)
+	}
+
+	// A second run finds nothing to do and leaves the backup alone.
+	result, err = MigrateFile(path)
+	if err != nil || result.Migrated() || result.BackupPath != "" {
+		t.Errorf("second MigrateFile() = %+v, %v; want no-op", result, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/retry"
 )
 
 // GlobalOptions contains all global flag values for the application.
@@ -17,14 +18,47 @@ type GlobalOptions struct {
 	Temperature      float64
 	TopK             int
 	TopP             float64
-	Timeout          time.Duration
+	// ModelAliases expand Model before the request is built (models.aliases).
+	ModelAliases map[string]string
+	// StrictModel turns unknown models into errors instead of warnings.
+	StrictModel bool
+	// ModelFallbacks are the models tried, in order, when the API reports
+	// that Model is unavailable or invalid.
+	ModelFallbacks []string
+	// Language is the ISO 639-1 code of the answer language; empty lets the
+	// model choose.
+	Language string
+	// Timeout is the total timeout of one API call, retries included.
+	Timeout time.Duration
+	// ConnectTimeout and ResponseHeaderTimeout bound the connection and the
+	// wait for response headers. Zero leaves them bounded by Timeout only.
+	ConnectTimeout        time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// Retry options
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// BaseURL is the --base-url or api.base_url of the API; empty uses the
+	// Perplexity API.
+	BaseURL string
+	// ProxyURL is the api.proxy_url; empty uses HTTPS_PROXY.
+	ProxyURL string
+	// TLS is the api.tls block; see LoadTLSConfig.
+	TLS TLSConfig
 
 	// Prompts (query command only)
 	SystemPrompt string
 	UserPrompt   string
+	// SystemPromptFile and UserPromptFile load the prompts from files.
+	SystemPromptFile string
+	UserPromptFile   string
+	// MaxPromptSize caps the size in bytes of each prompt read from stdin or a file.
+	MaxPromptSize int
 
 	// Search options
 	SearchDomains   []string
+	ExcludeDomains  []string
 	SearchRecency   string
 	LocationLat     float64
 	LocationLon     float64
@@ -45,6 +79,8 @@ type GlobalOptions struct {
 	// Response format options
 	ResponseFormatJSONSchema string
 	ResponseFormatRegex      string
+	// ResponseFormatJSONSchemaFile loads ResponseFormatJSONSchema from a file.
+	ResponseFormatJSONSchemaFile string
 
 	// Search mode options
 	SearchMode        string
@@ -61,10 +97,60 @@ type GlobalOptions struct {
 
 	// Output options
 	OutputJSON bool
+	// JSONFields is the --json-fields selection; empty emits every field.
+	JSONFields string
+	// Render is the --render mode (markdown, plain, raw); empty selects by TTY.
+	Render string
+	// Citations is the --citations style (list, inline, footnote, none); empty means list.
+	Citations string
+	// CitationMaxPerDomain and CitationExcludeDomains filter the sources of
+	// answers; see citations.Filter.
+	CitationMaxPerDomain   int
+	CitationExcludeDomains []string
+	// ImageConflictPolicy is the output.image_conflict_policy; empty means
+	// prefer_images.
+	ImageConflictPolicy string
+	// StrictOptions is output.strict_options: option combinations the API
+	// ignores, such as streaming with a response format, are rejected
+	// instead of adjusted with a warning.
+	StrictOptions bool
+	// AutoContinue is --auto-continue, the most follow-up requests sent to
+	// complete an answer cut off at max_tokens; zero disables them.
+	AutoContinue int
+	// Pager is output.pager, the command showing long answers; empty uses
+	// $PAGER, then less -R.
+	Pager string
+	// StreamStyle is the --stream-style (instant, smooth); empty means instant.
+	StreamStyle string
+	// StreamCPS is output.stream_cps, the rate of smooth streaming in
+	// characters per second; zero uses the default.
+	StreamCPS int
+	// Theme is output.theme, the palette of colored output; empty means
+	// the default theme.
+	Theme string
 
 	// Logging options
 	LogLevel  string
 	LogFormat string
+	// Verbose is --verbose; it enables debug logging regardless of LogLevel.
+	Verbose bool
+	// LogFile is --log-file; logs are appended to it instead of stderr.
+	LogFile string
+	// Quiet is --quiet; it silences the spinner, notes, and non-error logs.
+	Quiet bool
+}
+
+// Timeouts returns the per-phase API timeouts and the proxy. An invalid
+// ProxyURL, rejected by ValidateHTTPURL, is ignored. The TLS configuration,
+// whose files may fail to load, is set by the caller from LoadTLSConfig.
+func (o *GlobalOptions) Timeouts() retry.Timeouts {
+	proxy, _ := ParseProxyURL(o.ProxyURL)
+	return retry.Timeouts{
+		Connect:        o.ConnectTimeout,
+		ResponseHeader: o.ResponseHeaderTimeout,
+		Total:          o.Timeout,
+		Proxy:          proxy,
+	}
 }
 
 // NewGlobalOptions creates a new GlobalOptions instance with default values.
@@ -72,12 +158,13 @@ func NewGlobalOptions() *GlobalOptions {
 	return &GlobalOptions{
 		Model:            perplexity.DefaultModel,
 		FrequencyPenalty: perplexity.DefaultFrequencyPenalty,
-		MaxTokens:        perplexity.DefaultMaxTokens,
 		PresencePenalty:  perplexity.DefaultPresencePenalty,
 		Temperature:      perplexity.DefaultTemperature,
 		TopK:             perplexity.DefaultTopK,
 		TopP:             perplexity.DefaultTopP,
 		Timeout:          perplexity.DefaultTimeout,
+		MaxRetries:       retry.DefaultMaxRetries,
+		RetryBackoff:     retry.DefaultMaxBackoff,
 		LogLevel:         "info",
 		LogFormat:        "text",
 	}
@@ -16,8 +16,8 @@ func TestNewGlobalOptions(t *testing.T) {
 	if opts.FrequencyPenalty != perplexity.DefaultFrequencyPenalty {
 		t.Errorf("Expected default frequency penalty %f, got %f", perplexity.DefaultFrequencyPenalty, opts.FrequencyPenalty)
 	}
-	if opts.MaxTokens != perplexity.DefaultMaxTokens {
-		t.Errorf("Expected default max tokens %d, got %d", perplexity.DefaultMaxTokens, opts.MaxTokens)
+	if opts.MaxTokens != 0 {
+		t.Errorf("Expected max tokens 0, the model default, got %d", opts.MaxTokens)
 	}
 	if opts.PresencePenalty != perplexity.DefaultPresencePenalty {
 		t.Errorf("Expected default presence penalty %f, got %f", perplexity.DefaultPresencePenalty, opts.PresencePenalty)
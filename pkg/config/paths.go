@@ -0,0 +1,169 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+)
+
+// ConfigPathEnv names a config file that replaces the search: when it is
+// set, pplx reads that file only and merges no project config.
+const ConfigPathEnv = "PPLX_CONFIG"
+
+// ProjectConfigName is the per-project config file looked up in the
+// current directory and its parents, up to the root of the git repository,
+// and merged on top of the user config.
+const ProjectConfigName = ".pplx.yaml"
+
+// Kinds of the paths returned by SearchPaths.
+const (
+	SearchPathEnv     = "env"
+	SearchPathUser    = "user"
+	SearchPathProject = "project"
+)
+
+// configFileNames are the file names looked up in every config directory,
+// in precedence order.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var configFileNames = []string{"config.yaml", "pplx.yaml", "config.yml", "pplx.yml"}
+
+// ConfigPaths lists the directories searched for the user config, in order.
+// Environment variables are expanded when searching; an entry using an
+// unset variable, such as $XDG_CONFIG_HOME, is skipped.
+//
+//nolint:gochecknoglobals // overridden by tests
+var ConfigPaths = defaultConfigPaths(runtime.GOOS)
+
+// defaultConfigPaths returns the config directories of the platform goos:
+// $XDG_CONFIG_HOME/pplx, then ~/.config/pplx, then the platform's own
+// application data directory.
+func defaultConfigPaths(goos string) []string {
+	paths := []string{
+		"$XDG_CONFIG_HOME/pplx",
+		"$HOME/.config/pplx",
+	}
+	switch goos {
+	case "darwin":
+		paths = append(paths, "$HOME/Library/Application Support/pplx")
+	case "windows":
+		paths = append(paths, "$APPDATA/pplx")
+	}
+	return paths
+}
+
+// ConfigFileNames returns the file names looked up in every config
+// directory, in precedence order.
+func ConfigFileNames() []string {
+	return slices.Clone(configFileNames)
+}
+
+// ConfigDirs returns the entries of ConfigPaths that can be searched,
+// expanded, without duplicates.
+func ConfigDirs() []string {
+	dirs := make([]string, 0, len(ConfigPaths))
+	for _, path := range ConfigPaths {
+		dir, ok := expandConfigDir(path)
+		if ok && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// expandConfigDir expands the environment variables of a ConfigPaths entry.
+// ok is false when one of them is unset or empty.
+func expandConfigDir(path string) (string, bool) {
+	ok := true
+	expanded := os.Expand(path, func(name string) string {
+		value := os.Getenv(name)
+		if value == "" {
+			ok = false
+		}
+		return value
+	})
+	return filepath.Clean(expanded), ok
+}
+
+// UserConfigDir returns the directory of the user config and templates: the
+// first of ConfigDirs that exists, or else the first of them.
+func UserConfigDir() string {
+	dirs := ConfigDirs()
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	if len(dirs) == 0 {
+		return "."
+	}
+	return dirs[0]
+}
+
+// FindProjectConfig returns the per-project config of the current
+// directory: the nearest ProjectConfigName in it or one of its parents (see
+// projectDirs). ok is false when there is none, or when ConfigPathEnv is set.
+func FindProjectConfig() (string, bool) {
+	if os.Getenv(ConfigPathEnv) != "" {
+		return "", false
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	for _, dir := range projectDirs(cwd) {
+		if path := filepath.Join(dir, ProjectConfigName); fileExists(path) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// projectDirs returns dir and its parents, nearest first, up to the root of
+// the git repository holding dir, the first directory with a .git entry, or
+// the filesystem root outside a repository.
+func projectDirs(dir string) []string {
+	var dirs []string
+	for {
+		dirs = append(dirs, dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dirs
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dirs
+		}
+		dir = parent
+	}
+}
+
+// SearchPath is a file pplx looks for its configuration in.
+type SearchPath struct {
+	Path string
+	// Kind is SearchPathEnv, SearchPathUser, or SearchPathProject.
+	Kind   string
+	Exists bool
+}
+
+// SearchPaths returns every file FindConfigFile considers, in the order
+// they are searched, followed by the project config: the one
+// FindProjectConfig found, or else the one of the current directory.
+func SearchPaths() []SearchPath {
+	if path := os.Getenv(ConfigPathEnv); path != "" {
+		return []SearchPath{{Path: path, Kind: SearchPathEnv, Exists: fileExists(path)}}
+	}
+	var paths []SearchPath
+	for _, dir := range ConfigDirs() {
+		for _, name := range configFileNames {
+			path := filepath.Join(dir, name)
+			paths = append(paths, SearchPath{Path: path, Kind: SearchPathUser, Exists: fileExists(path)})
+		}
+	}
+	if project, ok := FindProjectConfig(); ok {
+		paths = append(paths, SearchPath{Path: project, Kind: SearchPathProject, Exists: true})
+	} else if project, err := filepath.Abs(ProjectConfigName); err == nil {
+		paths = append(paths, SearchPath{Path: project, Kind: SearchPathProject, Exists: fileExists(project)})
+	}
+	return paths
+}
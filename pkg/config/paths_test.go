@@ -0,0 +1,144 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// writeConfigFile writes content to path, creating its directory.
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// isolateConfigSearch points the search at a fresh home directory and an
+// empty working directory, and returns the home directory.
+func isolateConfigSearch(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv(ConfigPathEnv, "")
+	t.Chdir(t.TempDir())
+	return home
+}
+
+func TestDefaultConfigPaths(t *testing.T) {
+	tests := []struct {
+		goos string
+		want string
+	}{
+		{"linux", ""},
+		{"darwin", "$HOME/Library/Application Support/pplx"},
+		{"windows", "$APPDATA/pplx"},
+	}
+	for _, tt := range tests {
+		paths := defaultConfigPaths(tt.goos)
+		if paths[0] != "$XDG_CONFIG_HOME/pplx" || paths[1] != "$HOME/.config/pplx" {
+			t.Errorf("defaultConfigPaths(%s) = %v, want XDG then ~/.config first", tt.goos, paths)
+		}
+		if tt.want != "" && !slices.Contains(paths, tt.want) {
+			t.Errorf("defaultConfigPaths(%s) = %v, missing %s", tt.goos, paths, tt.want)
+		}
+		if tt.want == "" && len(paths) != 2 {
+			t.Errorf("defaultConfigPaths(%s) = %v, want only the XDG paths", tt.goos, paths)
+		}
+	}
+}
+
+func TestConfigDirs(t *testing.T) {
+	home := isolateConfigSearch(t)
+	if got, want := ConfigDirs(), filepath.Join(home, ".config", "pplx"); len(got) == 0 || got[0] != want {
+		t.Errorf("ConfigDirs() without XDG_CONFIG_HOME = %v, want %s first", got, want)
+	}
+
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	if got := ConfigDirs(); got[0] != filepath.Join(xdg, "pplx") || got[1] != filepath.Join(home, ".config", "pplx") {
+		t.Errorf("ConfigDirs() = %v, want XDG_CONFIG_HOME first", got)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	got := ConfigDirs()
+	if got[0] != filepath.Join(home, ".config", "pplx") || slices.Contains(got[1:], got[0]) {
+		t.Errorf("ConfigDirs() = %v, want ~/.config/pplx once", got)
+	}
+}
+
+func TestFindConfigFile_XDG(t *testing.T) {
+	home := isolateConfigSearch(t)
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	writeConfigFile(t, filepath.Join(home, ".config", "pplx", "config.yaml"), "defaults:\n  model: sonar\n")
+
+	if got, err := FindConfigFile(); err != nil || got != filepath.Join(home, ".config", "pplx", "config.yaml") {
+		t.Errorf("FindConfigFile() = %q, %v; want the ~/.config file while the XDG one is missing", got, err)
+	}
+	xdgConfig := filepath.Join(xdg, "pplx", "pplx.yml")
+	writeConfigFile(t, xdgConfig, "defaults:\n  model: sonar-pro\n")
+	if got, err := FindConfigFile(); err != nil || got != xdgConfig {
+		t.Errorf("FindConfigFile() = %q, %v; want %s", got, err, xdgConfig)
+	}
+	if got := GetDefaultConfigPath(); got != filepath.Join(xdg, "pplx", "config.yaml") {
+		t.Errorf("GetDefaultConfigPath() = %q, want it under XDG_CONFIG_HOME", got)
+	}
+}
+
+func TestFindConfigFile_EnvShortCircuits(t *testing.T) {
+	home := isolateConfigSearch(t)
+	writeConfigFile(t, filepath.Join(home, ".config", "pplx", "config.yaml"), "defaults:\n  model: sonar\n")
+	writeConfigFile(t, ProjectConfigName, "defaults:\n  model: sonar-pro\n")
+	explicit := filepath.Join(t.TempDir(), "custom.yaml")
+	t.Setenv(ConfigPathEnv, explicit)
+
+	if _, err := FindConfigFile(); !errors.Is(err, clerrors.ErrNoConfigFound) {
+		t.Errorf("FindConfigFile() with a missing PPLX_CONFIG error = %v, want ErrNoConfigFound", err)
+	}
+	writeConfigFile(t, explicit, "defaults:\n  model: sonar-reasoning\n")
+	if got, err := FindConfigFile(); err != nil || got != explicit {
+		t.Errorf("FindConfigFile() = %q, %v; want %s", got, err, explicit)
+	}
+	if _, ok := FindProjectConfig(); ok {
+		t.Error("FindProjectConfig() found the project config while PPLX_CONFIG is set")
+	}
+	if got := SearchPaths(); len(got) != 1 || got[0].Path != explicit || got[0].Kind != SearchPathEnv {
+		t.Errorf("SearchPaths() = %+v, want only PPLX_CONFIG", got)
+	}
+	if got := GetDefaultConfigPath(); got != explicit {
+		t.Errorf("GetDefaultConfigPath() = %q, want %s", got, explicit)
+	}
+}
+
+func TestSearchPaths(t *testing.T) {
+	home := isolateConfigSearch(t)
+	userConfig := filepath.Join(home, ".config", "pplx", "pplx.yaml")
+	writeConfigFile(t, userConfig, "defaults:\n  model: sonar\n")
+	writeConfigFile(t, ProjectConfigName, "defaults:\n  model: sonar-pro\n")
+
+	paths := SearchPaths()
+	if len(paths) != len(ConfigDirs())*len(configFileNames)+1 {
+		t.Fatalf("SearchPaths() returned %d paths, want every file of every directory and the project", len(paths))
+	}
+	var existing []string
+	for _, p := range paths {
+		if p.Exists {
+			existing = append(existing, p.Kind+":"+filepath.Base(p.Path))
+		}
+	}
+	if want := []string{"user:pplx.yaml", "project:" + ProjectConfigName}; !slices.Equal(existing, want) {
+		t.Errorf("existing search paths = %v, want %v", existing, want)
+	}
+	if last := paths[len(paths)-1]; last.Kind != SearchPathProject {
+		t.Errorf("last search path = %+v, want the project config", last)
+	}
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// PresetsFileName is the file of the user config directory holding the
+// query presets.
+const PresetsFileName = "presets.yaml"
+
+// PresetAnnotation marks the flags ApplyPreset set, so that their config
+// keys are reported as SourcePreset rather than SourceFlag.
+const PresetAnnotation = "pplx_preset"
+
+// mutuallyExclusiveAnnotation is the flag annotation cobra stores the
+// groups of MarkFlagsMutuallyExclusive under.
+const mutuallyExclusiveAnnotation = "cobra_annotation_mutually_exclusive"
+
+// FlagValues are the values of one flag of a preset: a single value, or
+// every value of a list flag. A single value is written as a YAML scalar.
+type FlagValues []string
+
+// MarshalYAML writes a single value as a scalar and several as a list.
+func (v FlagValues) MarshalYAML() (any, error) {
+	if len(v) == 1 {
+		return v[0], nil
+	}
+	return []string(v), nil
+}
+
+// UnmarshalYAML reads a scalar or a list of scalars.
+func (v *FlagValues) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*v = FlagValues{node.Value}
+		return nil
+	}
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return fmt.Errorf("line %d: a preset flag must be a value or a list of values: %w", node.Line, err)
+	}
+	*v = values
+	return nil
+}
+
+// Preset is a named set of query flags, by long flag name, replayed with
+// pplx query --preset.
+type Preset map[string]FlagValues
+
+// Flags returns the names of the flags of p, sorted.
+func (p Preset) Flags() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Args returns p as command-line arguments, one --flag=value per value,
+// in flag name order.
+func (p Preset) Args() []string {
+	var args []string
+	for _, name := range p.Flags() {
+		for _, value := range p[name] {
+			args = append(args, "--"+name+"="+value)
+		}
+	}
+	return args
+}
+
+// PresetsPath returns the presets file of the user config directory.
+func PresetsPath() string {
+	return filepath.Join(UserConfigDir(), PresetsFileName)
+}
+
+// ValidatePresetName checks that name can be used for a preset: it must be
+// non-empty and contain no whitespace.
+func ValidatePresetName(name string) error {
+	if name == "" || strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return clerrors.NewValidationError("name", name, "a preset name must be non-empty and contain no whitespace")
+	}
+	return nil
+}
+
+// LoadPresets reads the presets file at path. A missing file has no
+// presets.
+func LoadPresets(path string) (map[string]Preset, error) {
+	content, err := os.ReadFile(path) //nolint:gosec // path is the user's own presets file
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]Preset{}, nil
+	}
+	if err != nil {
+		return nil, clerrors.NewIOError("failed to read presets file "+path, err)
+	}
+	presets := map[string]Preset{}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return presets, nil
+	}
+	if err := yaml.Unmarshal(content, &presets); err != nil {
+		return nil, clerrors.NewConfigError("failed to parse presets file "+path, err)
+	}
+	return presets, nil
+}
+
+// SavePresets writes presets to path with owner-only permissions.
+func SavePresets(path string, presets map[string]Preset) error {
+	content, err := yaml.Marshal(presets)
+	if err != nil {
+		return fmt.Errorf("failed to encode presets: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), editedDirPermission); err != nil {
+		return clerrors.NewIOError("failed to create config directory", err)
+	}
+	if err := os.WriteFile(path, content, editedFilePermission); err != nil {
+		return clerrors.NewIOError("failed to write presets file "+path, err)
+	}
+	return nil
+}
+
+// FindPreset returns the preset called name from the presets file at path.
+func FindPreset(path, name string) (Preset, error) {
+	presets, err := LoadPresets(path)
+	if err != nil {
+		return nil, err
+	}
+	preset, ok := presets[name]
+	if !ok {
+		return nil, presetNotFound(name, presets)
+	}
+	return preset, nil
+}
+
+// SavePreset stores preset as name in the presets file at path, replacing
+// a preset of the same name, and reports whether it did.
+func SavePreset(path, name string, preset Preset) (bool, error) {
+	if err := ValidatePresetName(name); err != nil {
+		return false, err
+	}
+	presets, err := LoadPresets(path)
+	if err != nil {
+		return false, err
+	}
+	_, replaced := presets[name]
+	presets[name] = preset
+	return replaced, SavePresets(path, presets)
+}
+
+// DeletePreset removes the preset called name from the presets file at path.
+func DeletePreset(path, name string) error {
+	presets, err := LoadPresets(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := presets[name]; !ok {
+		return presetNotFound(name, presets)
+	}
+	delete(presets, name)
+	return SavePresets(path, presets)
+}
+
+// presetNotFound returns the error of an unknown preset, listing the
+// defined ones.
+func presetNotFound(name string, presets map[string]Preset) error {
+	names := make([]string, 0, len(presets))
+	for n := range presets {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	available := "none defined; create one with pplx preset save"
+	if len(names) > 0 {
+		available = "available: " + strings.Join(names, ", ")
+	}
+	return clerrors.NewValidationError("preset", name, "no such preset ("+available+")")
+}
+
+// RecordPreset returns the flags explicitly set in flags as a preset. List
+// flags keep each of their values.
+func RecordPreset(flags *pflag.FlagSet) Preset {
+	preset := Preset{}
+	flags.VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			preset[f.Name] = slice.GetSlice()
+			return
+		}
+		preset[f.Name] = FlagValues{f.Value.String()}
+	})
+	return preset
+}
+
+// ApplyPreset sets the flags of preset in flags, except those already given
+// on the command line and those that cannot be combined with a flag given
+// on the command line, so the command line overrides the preset. The flags
+// set are annotated with PresetAnnotation and returned, sorted.
+func ApplyPreset(flags *pflag.FlagSet, name string, preset Preset) ([]string, error) {
+	var applied []string
+	for _, flagName := range preset.Flags() {
+		f := flags.Lookup(flagName)
+		if f == nil {
+			return nil, clerrors.NewConfigError(
+				fmt.Sprintf("preset %q sets --%s, which this command does not have", name, flagName), nil)
+		}
+		if f.Changed || excludedByChangedFlag(flags, f) {
+			continue
+		}
+		if err := setFlagValues(flags, f, preset[flagName]); err != nil {
+			return nil, err
+		}
+		if f.Annotations == nil {
+			f.Annotations = map[string][]string{}
+		}
+		f.Annotations[PresetAnnotation] = []string{name}
+		applied = append(applied, flagName)
+	}
+	return applied, nil
+}
+
+// setFlagValues sets f to values and marks it changed. No values set a
+// list flag to an empty list.
+func setFlagValues(flags *pflag.FlagSet, f *pflag.Flag, values []string) error {
+	first := ""
+	if len(values) > 0 {
+		first = values[0]
+	}
+	if err := flags.Set(f.Name, first); err != nil {
+		return clerrors.NewValidationError(f.Name, first, "invalid preset value: "+err.Error())
+	}
+	slice, ok := f.Value.(pflag.SliceValue)
+	if !ok {
+		return nil
+	}
+	if err := slice.Replace(values); err != nil {
+		return clerrors.NewValidationError(f.Name, strings.Join(values, ","), "invalid preset value: "+err.Error())
+	}
+	return nil
+}
+
+// excludedByChangedFlag reports whether f is in a mutually exclusive group
+// with a flag given on the command line.
+func excludedByChangedFlag(flags *pflag.FlagSet, f *pflag.Flag) bool {
+	for _, group := range f.Annotations[mutuallyExclusiveAnnotation] {
+		for _, other := range strings.Fields(group) {
+			if other == f.Name {
+				continue
+			}
+			if o := flags.Lookup(other); o != nil && o.Changed && o.Annotations[PresetAnnotation] == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
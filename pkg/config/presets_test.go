@@ -0,0 +1,272 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/spf13/pflag"
+)
+
+// presetFlags returns a flag set with a string, a list and two mutually
+// exclusive flags, as cobra annotates them.
+func presetFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("query", pflag.ContinueOnError)
+	flags.String("model", "", "Model")
+	flags.Int("max-tokens", 0, "Max tokens")
+	flags.StringSlice("search-domains", nil, "Search domains")
+	flags.String("sys-prompt", "", "System prompt")
+	flags.String("sys-prompt-file", "", "System prompt file")
+	group := []string{"sys-prompt sys-prompt-file"}
+	for _, name := range []string{"sys-prompt", "sys-prompt-file"} {
+		flags.Lookup(name).Annotations = map[string][]string{mutuallyExclusiveAnnotation: group}
+	}
+	return flags
+}
+
+func TestPresets_SaveFindDelete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pplx", PresetsFileName)
+
+	presets, err := LoadPresets(path)
+	if err != nil || len(presets) != 0 {
+		t.Fatalf("LoadPresets() on a missing file = %v, %v; want no presets", presets, err)
+	}
+
+	papers := Preset{"model": {"sonar-pro"}, "search-domains": {"arxiv.org", "nature.com"}}
+	replaced, err := SavePreset(path, "papers", papers)
+	if err != nil || replaced {
+		t.Fatalf("SavePreset() = %v, %v; want a new preset", replaced, err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "papers:\n    model: sonar-pro\n    search-domains:\n        - arxiv.org\n        - nature.com\n"
+	if string(content) != want {
+		t.Errorf("presets file =\n%s\nwant\n%s", content, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !OwnerOnly(info.Mode()) {
+		t.Errorf("presets file mode = %04o, want owner-only", info.Mode().Perm())
+	}
+
+	got, err := FindPreset(path, "papers")
+	if err != nil {
+		t.Fatalf("FindPreset() error = %v", err)
+	}
+	wantArgs := []string{"--model=sonar-pro", "--search-domains=arxiv.org", "--search-domains=nature.com"}
+	if !slices.Equal(got.Args(), wantArgs) {
+		t.Errorf("Args() = %v", got.Args())
+	}
+
+	if replaced, err := SavePreset(path, "papers", Preset{"model": {"sonar"}}); err != nil || !replaced {
+		t.Errorf("SavePreset() again = %v, %v; want the preset replaced", replaced, err)
+	}
+
+	_, err = FindPreset(path, "news")
+	var validationErr *clerrors.ValidationError
+	if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), "available: papers") {
+		t.Errorf("FindPreset() of an unknown preset = %v, want a validation error listing papers", err)
+	}
+
+	if err := DeletePreset(path, "papers"); err != nil {
+		t.Fatalf("DeletePreset() error = %v", err)
+	}
+	if err := DeletePreset(path, "papers"); err == nil || !strings.Contains(err.Error(), "none defined") {
+		t.Errorf("DeletePreset() of a deleted preset = %v, want none defined", err)
+	}
+}
+
+func TestLoadPresets_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), PresetsFileName)
+	if err := os.WriteFile(path, []byte("papers:\n  model: {a: b}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadPresets(path)
+	var configErr *clerrors.ConfigError
+	if !errors.As(err, &configErr) {
+		t.Errorf("LoadPresets() error = %v, want a config error", err)
+	}
+}
+
+func TestValidatePresetName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"papers", false},
+		{"news-fr", false},
+		{"", true},
+		{"my preset", true},
+		{"tab\tname", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePresetName(tt.name); (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePresetName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecordPreset(t *testing.T) {
+	flags := presetFlags()
+	if err := flags.Parse([]string{"--model=sonar-pro", "--search-domains=go.dev,pkg.go.dev"}); err != nil {
+		t.Fatal(err)
+	}
+	got := RecordPreset(flags)
+	if len(got) != 2 || got["model"][0] != "sonar-pro" ||
+		!slices.Equal(got["search-domains"], FlagValues{"go.dev", "pkg.go.dev"}) {
+		t.Errorf("RecordPreset() = %v, want only the flags given", got)
+	}
+}
+
+func TestApplyPreset(t *testing.T) {
+	preset := Preset{
+		"model":           {"sonar-pro"},
+		"max-tokens":      {"2000"},
+		"search-domains":  {"arxiv.org", "nature.com"},
+		"sys-prompt-file": {"papers.txt"},
+	}
+	tests := []struct {
+		name        string
+		args        []string
+		wantApplied []string
+		wantModel   string
+		wantPrompt  string
+	}{
+		{
+			name:        "no flags given",
+			wantApplied: []string{"max-tokens", "model", "search-domains", "sys-prompt-file"},
+			wantModel:   "sonar-pro",
+		},
+		{
+			name:        "flag given overrides the preset",
+			args:        []string{"--model=sonar"},
+			wantApplied: []string{"max-tokens", "search-domains", "sys-prompt-file"},
+			wantModel:   "sonar",
+		},
+		{
+			name:        "flag given excludes the preset flag",
+			args:        []string{"--sys-prompt=Be brief"},
+			wantApplied: []string{"max-tokens", "model", "search-domains"},
+			wantModel:   "sonar-pro",
+			wantPrompt:  "Be brief",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := presetFlags()
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			applied, err := ApplyPreset(flags, "papers", preset)
+			if err != nil {
+				t.Fatalf("ApplyPreset() error = %v", err)
+			}
+			if !slices.Equal(applied, tt.wantApplied) {
+				t.Errorf("applied = %v, want %v", applied, tt.wantApplied)
+			}
+			if got, _ := flags.GetString("model"); got != tt.wantModel {
+				t.Errorf("model = %q, want %q", got, tt.wantModel)
+			}
+			if got, _ := flags.GetString("sys-prompt"); got != tt.wantPrompt {
+				t.Errorf("sys-prompt = %q, want %q", got, tt.wantPrompt)
+			}
+			if got, _ := flags.GetStringSlice("search-domains"); !slices.Equal(got, []string{"arxiv.org", "nature.com"}) {
+				t.Errorf("search-domains = %v, want the preset's list", got)
+			}
+			for _, name := range applied {
+				f := flags.Lookup(name)
+				if !f.Changed || f.Annotations[PresetAnnotation] == nil {
+					t.Errorf("--%s not marked as set by the preset", name)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPreset_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset Preset
+		target any
+	}{
+		{"unknown flag", Preset{"no-such-flag": {"x"}}, new(*clerrors.ConfigError)},
+		{"invalid value", Preset{"max-tokens": {"many"}}, new(*clerrors.ValidationError)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ApplyPreset(presetFlags(), "papers", tt.preset)
+			if err == nil || !errors.As(err, tt.target) {
+				t.Errorf("ApplyPreset() error = %v, want %T", err, tt.target)
+			}
+		})
+	}
+}
+
+func TestPrecedence_PresetBetweenEnvAndFlags(t *testing.T) {
+	t.Setenv("PPLX_DEFAULTS_MAX_TOKENS", "3000")
+	t.Setenv("PPLX_DEFAULTS_MODEL", "env-model")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `
+version: 2
+defaults:
+  model: base-model
+  max_tokens: 1000
+  temperature: 0.5
+active_profile: research
+profiles:
+  research:
+    name: research
+    defaults:
+      model: profile-model
+      temperature: 0.7
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cmd := createTestCommand()
+	if err := cmd.Flags().Set("max-tokens", "500"); err != nil {
+		t.Fatal(err)
+	}
+	preset := Preset{"model": {"preset-model"}, "max-tokens": {"2000"}, "search-mode": {"academic"}}
+	if _, err := ApplyPreset(cmd.Flags(), "papers", preset); err != nil {
+		t.Fatalf("ApplyPreset() error = %v", err)
+	}
+
+	cfg, sources, err := LoadAndMergeConfigWithSources(cmd, configPath, "")
+	if err != nil {
+		t.Fatalf("LoadAndMergeConfigWithSources failed: %v", err)
+	}
+	if cfg.Defaults.Model != "preset-model" {
+		t.Errorf("Preset should override env and profile, got model %q", cfg.Defaults.Model)
+	}
+	if cfg.Defaults.MaxTokens != 500 {
+		t.Errorf("Flag should override preset, got max_tokens %d", cfg.Defaults.MaxTokens)
+	}
+	if cfg.Defaults.Temperature != 0.7 || cfg.Search.Mode != "academic" {
+		t.Errorf("merged config = %+v, %+v", cfg.Defaults, cfg.Search)
+	}
+
+	want := map[string]Source{
+		"defaults.model":       SourcePreset,
+		"search.mode":          SourcePreset,
+		"defaults.max_tokens":  SourceFlag,
+		"defaults.temperature": SourceProfile,
+	}
+	for key, source := range want {
+		if got := sources[key]; got != source {
+			t.Errorf("sources[%q] = %q, want %q", key, got, source)
+		}
+	}
+}
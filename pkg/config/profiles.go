@@ -2,6 +2,12 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/sgaunet/pplx/pkg/clerrors"
 )
@@ -23,16 +29,8 @@ func NewProfileManager(data *ConfigData) *ProfileManager {
 
 // CreateProfile creates a new profile.
 func (pm *ProfileManager) CreateProfile(name, description string) (*Profile, error) {
-	if name == "" {
-		return nil, clerrors.ErrProfileNameEmpty
-	}
-
-	if name == DefaultProfileName {
-		return nil, clerrors.ErrProfileNameReserved
-	}
-
-	if _, exists := pm.data.Profiles[name]; exists {
-		return nil, fmt.Errorf("%w: '%s'", clerrors.ErrProfileAlreadyExists, name)
+	if err := pm.validateNewName(name); err != nil {
+		return nil, err
 	}
 
 	profile := &Profile{
@@ -44,8 +42,73 @@ func (pm *ProfileManager) CreateProfile(name, description string) (*Profile, err
 	return profile, nil
 }
 
-// LoadProfile retrieves a profile by name.
+// ValidateProfileName checks that name can be used for a profile: it must
+// be non-empty, contain no whitespace, and not be the reserved "default".
+func ValidateProfileName(name string) error {
+	if name == "" {
+		return clerrors.ErrProfileNameEmpty
+	}
+	if strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("%w: '%s'", clerrors.ErrProfileNameInvalid, name)
+	}
+	if name == DefaultProfileName {
+		return clerrors.ErrProfileNameReserved
+	}
+	return nil
+}
+
+// validateNewName checks that name is a valid profile name not already in use.
+func (pm *ProfileManager) validateNewName(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	if _, exists := pm.data.Profiles[name]; exists {
+		return fmt.Errorf("%w: '%s'", clerrors.ErrProfileAlreadyExists, name)
+	}
+	return nil
+}
+
+// maxProfileDepth is the maximum number of profiles in an "extends" chain,
+// counting the profile itself.
+const maxProfileDepth = 10
+
+// LoadProfile retrieves a profile by name with its "extends" chain resolved.
+// Fields set on the profile win over those inherited from its parent, which in
+// turn win over the parent's own ancestors. The returned profile is a copy when
+// the profile extends another; use RawProfile to edit a profile as written.
 func (pm *ProfileManager) LoadProfile(name string) (*Profile, error) {
+	profile, err := pm.RawProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	if !extendsProfile(profile) {
+		return profile, nil
+	}
+
+	chain, err := pm.profileChain(name)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &Profile{
+		Name:        profile.Name,
+		Description: profile.Description,
+		Extends:     profile.Extends,
+	}
+	// Apply the root ancestor first so each descendant overrides it.
+	for i := len(chain) - 1; i >= 0; i-- {
+		overlayPointerFields(reflect.ValueOf(&resolved.Defaults).Elem(), reflect.ValueOf(chain[i].Defaults))
+		overlayPointerFields(reflect.ValueOf(&resolved.Search).Elem(), reflect.ValueOf(chain[i].Search))
+		overlayPointerFields(reflect.ValueOf(&resolved.Output).Elem(), reflect.ValueOf(chain[i].Output))
+		resolved.Models.Aliases = mergeAliases(resolved.Models.Aliases, chain[i].Models.Aliases)
+	}
+
+	return resolved, nil
+}
+
+// RawProfile retrieves a profile by name exactly as stored, without resolving
+// "extends". Use it when the profile is going to be modified and saved.
+func (pm *ProfileManager) RawProfile(name string) (*Profile, error) {
 	// Handle default profile
 	if name == DefaultProfileName || name == "" {
 		return pm.getDefaultProfile(), nil
@@ -59,6 +122,71 @@ func (pm *ProfileManager) LoadProfile(name string) (*Profile, error) {
 	return profile, nil
 }
 
+// profileChain returns the named profile followed by its ancestors, nearest first.
+// It fails on missing parents, cycles, and chains longer than maxProfileDepth.
+func (pm *ProfileManager) profileChain(name string) ([]*Profile, error) {
+	var chain []*Profile
+	var path []string
+	seen := make(map[string]bool)
+
+	for current := name; current != "" && current != DefaultProfileName; {
+		if seen[current] {
+			return nil, fmt.Errorf("%w: %s", clerrors.ErrProfileCycle,
+				strings.Join(append(path, current), " -> "))
+		}
+		if len(chain) == maxProfileDepth {
+			return nil, fmt.Errorf("%w: '%s' exceeds %d levels", clerrors.ErrProfileChainTooDeep,
+				name, maxProfileDepth)
+		}
+
+		profile, exists := pm.data.Profiles[current]
+		if !exists {
+			if len(path) == 0 {
+				return nil, fmt.Errorf("%w: '%s'", clerrors.ErrProfileNotFound, current)
+			}
+			return nil, fmt.Errorf("%w: '%s' (extended by '%s')", clerrors.ErrProfileNotFound,
+				current, path[len(path)-1])
+		}
+
+		seen[current] = true
+		path = append(path, current)
+		chain = append(chain, profile)
+		current = profile.Extends
+	}
+
+	return chain, nil
+}
+
+// ValidateExtends checks that the named profile's "extends" chain resolves.
+func (pm *ProfileManager) ValidateExtends(name string) error {
+	_, err := pm.profileChain(name)
+	return err
+}
+
+// extendsProfile reports whether a profile inherits from a named parent.
+func extendsProfile(profile *Profile) bool {
+	return profile.Extends != "" && profile.Extends != DefaultProfileName
+}
+
+// overlayPointerFields copies every non-nil pointer field of src onto dst.
+// Both values must be the same struct type made only of pointer fields; copied
+// values are duplicated so dst never aliases src. Slices are replaced wholesale.
+func overlayPointerFields(dst, src reflect.Value) {
+	for i := range src.NumField() {
+		field := src.Field(i)
+		if field.IsNil() {
+			continue
+		}
+		value := field.Elem()
+		if value.Kind() == reflect.Slice {
+			value = reflect.AppendSlice(reflect.MakeSlice(value.Type(), 0, value.Len()), value)
+		}
+		ptr := reflect.New(value.Type())
+		ptr.Elem().Set(value)
+		dst.Field(i).Set(ptr)
+	}
+}
+
 // ListProfiles returns all available profiles.
 func (pm *ProfileManager) ListProfiles() []string {
 	profiles := []string{DefaultProfileName}
@@ -82,6 +210,11 @@ func (pm *ProfileManager) DeleteProfile(name string) error {
 		return fmt.Errorf("%w: '%s'", clerrors.ErrProfileNotFound, name)
 	}
 
+	if children := pm.extendedBy(name); len(children) > 0 {
+		return fmt.Errorf("%w: '%s' is extended by %s", clerrors.ErrProfileInUse,
+			name, strings.Join(children, ", "))
+	}
+
 	// If deleting the active profile, switch to default
 	if pm.data.ActiveProfile == name {
 		pm.data.ActiveProfile = DefaultProfileName
@@ -91,6 +224,47 @@ func (pm *ProfileManager) DeleteProfile(name string) error {
 	return nil
 }
 
+// RenameProfile renames a profile, updating the active profile and the
+// "extends" of profiles that inherit from it.
+func (pm *ProfileManager) RenameProfile(oldName, newName string) error {
+	if oldName == DefaultProfileName {
+		return clerrors.ErrUpdateDefaultProfile
+	}
+
+	profile, exists := pm.data.Profiles[oldName]
+	if !exists {
+		return fmt.Errorf("%w: '%s'", clerrors.ErrProfileNotFound, oldName)
+	}
+
+	if err := pm.validateNewName(newName); err != nil {
+		return err
+	}
+
+	for _, child := range pm.extendedBy(oldName) {
+		pm.data.Profiles[child].Extends = newName
+	}
+	if pm.data.ActiveProfile == oldName {
+		pm.data.ActiveProfile = newName
+	}
+
+	delete(pm.data.Profiles, oldName)
+	profile.Name = newName
+	pm.data.Profiles[newName] = profile
+	return nil
+}
+
+// extendedBy returns the sorted names of profiles whose "extends" is name.
+func (pm *ProfileManager) extendedBy(name string) []string {
+	var children []string
+	for childName, profile := range pm.data.Profiles {
+		if profile.Extends == name {
+			children = append(children, childName)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
 // SetActiveProfile sets the active profile.
 func (pm *ProfileManager) SetActiveProfile(name string) error {
 	if name != DefaultProfileName {
@@ -187,6 +361,8 @@ func (pm *ProfileManager) MergeProfile(profileName string) (*ConfigData, error)
 		Search:        pm.data.Search,
 		Output:        pm.data.Output,
 		API:           pm.data.API,
+		Models:        pm.data.Models,
+		Prompts:       pm.data.Prompts,
 		Profiles:      pm.data.Profiles,
 		ActiveProfile: pm.data.ActiveProfile,
 	}
@@ -194,10 +370,23 @@ func (pm *ProfileManager) MergeProfile(profileName string) (*ConfigData, error)
 	mergeProfileDefaults(&merged.Defaults, &profile.Defaults)
 	mergeProfileSearch(&merged.Search, &profile.Search)
 	mergeProfileOutput(&merged.Output, &profile.Output)
+	merged.Models.Aliases = mergeAliases(merged.Models.Aliases, profile.Models.Aliases)
 
 	return merged, nil
 }
 
+// mergeAliases returns the aliases of base overridden by those of override,
+// as a new map. Returns nil when both are empty.
+func mergeAliases(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	maps.Copy(merged, base)
+	maps.Copy(merged, override)
+	return merged
+}
+
 // mergeProfileDefaults applies non-nil ProfileDefaults fields onto a DefaultsConfig.
 func mergeProfileDefaults(dst *DefaultsConfig, src *ProfileDefaults) {
 	if src.Model != nil {
@@ -224,6 +413,15 @@ func mergeProfileDefaults(dst *DefaultsConfig, src *ProfileDefaults) {
 	if src.Timeout != nil {
 		dst.Timeout = *src.Timeout
 	}
+	if src.Language != nil {
+		dst.Language = *src.Language
+	}
+	if src.SystemPrompt != nil {
+		dst.SystemPrompt = *src.SystemPrompt
+	}
+	if src.ModelFallbacks != nil {
+		dst.ModelFallbacks = *src.ModelFallbacks
+	}
 }
 
 // mergeProfileSearch applies non-nil ProfileSearch fields onto a SearchConfig.
@@ -231,6 +429,9 @@ func mergeProfileSearch(dst *SearchConfig, src *ProfileSearch) {
 	if src.Domains != nil {
 		dst.Domains = *src.Domains
 	}
+	if src.ExcludeDomains != nil {
+		dst.ExcludeDomains = *src.ExcludeDomains
+	}
 	if src.Recency != nil {
 		dst.Recency = *src.Recency
 	}
@@ -303,23 +504,48 @@ func mergeProfileOutput(dst *OutputConfig, src *ProfileOutput) {
 	if src.ReasoningEffort != nil {
 		dst.ReasoningEffort = *src.ReasoningEffort
 	}
+	if src.Citations != nil {
+		dst.Citations = *src.Citations
+	}
+	if src.CitationMaxPerDomain != nil {
+		dst.CitationMaxPerDomain = *src.CitationMaxPerDomain
+	}
+	if src.CitationExcludeDomains != nil {
+		dst.CitationExcludeDomains = *src.CitationExcludeDomains
+	}
+	if src.ImageConflictPolicy != nil {
+		dst.ImageConflictPolicy = *src.ImageConflictPolicy
+	}
+	if src.CacheTTL != nil {
+		dst.CacheTTL = *src.CacheTTL
+	}
+	if src.AutoContinue != nil {
+		dst.AutoContinue = *src.AutoContinue
+	}
+	if src.Pager != nil {
+		dst.Pager = *src.Pager
+	}
+	if src.StreamStyle != nil {
+		dst.StreamStyle = *src.StreamStyle
+	}
+	if src.StreamCPS != nil {
+		dst.StreamCPS = *src.StreamCPS
+	}
+	if src.Theme != nil {
+		dst.Theme = *src.Theme
+	}
+	if src.History != nil {
+		dst.History = copyBoolPtr(src.History)
+	}
 }
 
 // CloneProfile creates a new profile as a deep copy of an existing profile.
 func (pm *ProfileManager) CloneProfile(name, sourceName string) (*Profile, error) {
-	if name == "" {
-		return nil, clerrors.ErrProfileNameEmpty
-	}
-
-	if name == DefaultProfileName {
-		return nil, clerrors.ErrProfileNameReserved
-	}
-
-	if _, exists := pm.data.Profiles[name]; exists {
-		return nil, fmt.Errorf("%w: '%s'", clerrors.ErrProfileAlreadyExists, name)
+	if err := pm.validateNewName(name); err != nil {
+		return nil, err
 	}
 
-	src, err := pm.LoadProfile(sourceName)
+	src, err := pm.RawProfile(sourceName)
 	if err != nil {
 		return nil, err
 	}
@@ -327,6 +553,7 @@ func (pm *ProfileManager) CloneProfile(name, sourceName string) (*Profile, error
 	clone := &Profile{
 		Name:        name,
 		Description: src.Description,
+		Extends:     src.Extends,
 		Defaults: ProfileDefaults{
 			Model:            copyStringPtr(src.Defaults.Model),
 			Temperature:      copyFloat64Ptr(src.Defaults.Temperature),
@@ -336,9 +563,13 @@ func (pm *ProfileManager) CloneProfile(name, sourceName string) (*Profile, error
 			FrequencyPenalty: copyFloat64Ptr(src.Defaults.FrequencyPenalty),
 			PresencePenalty:  copyFloat64Ptr(src.Defaults.PresencePenalty),
 			Timeout:          copyStringPtr(src.Defaults.Timeout),
+			Language:         copyStringPtr(src.Defaults.Language),
+			SystemPrompt:     copyStringPtr(src.Defaults.SystemPrompt),
+			ModelFallbacks:   copyStringSlicePtr(src.Defaults.ModelFallbacks),
 		},
 		Search: ProfileSearch{
 			Domains:           copyStringSlicePtr(src.Search.Domains),
+			ExcludeDomains:    copyStringSlicePtr(src.Search.ExcludeDomains),
 			Recency:           copyStringPtr(src.Search.Recency),
 			Mode:              copyStringPtr(src.Search.Mode),
 			ContextSize:       copyStringPtr(src.Search.ContextSize),
@@ -360,7 +591,19 @@ func (pm *ProfileManager) CloneProfile(name, sourceName string) (*Profile, error
 			ResponseFormatJSONSchema: copyStringPtr(src.Output.ResponseFormatJSONSchema),
 			ResponseFormatRegex:      copyStringPtr(src.Output.ResponseFormatRegex),
 			ReasoningEffort:          copyStringPtr(src.Output.ReasoningEffort),
+			Citations:                copyStringPtr(src.Output.Citations),
+			CitationMaxPerDomain:     copyIntPtr(src.Output.CitationMaxPerDomain),
+			CitationExcludeDomains:   copyStringSlicePtr(src.Output.CitationExcludeDomains),
+			ImageConflictPolicy:      copyStringPtr(src.Output.ImageConflictPolicy),
+			CacheTTL:                 copyDurationPtr(src.Output.CacheTTL),
+			AutoContinue:             copyIntPtr(src.Output.AutoContinue),
+			Pager:                    copyStringPtr(src.Output.Pager),
+			StreamStyle:              copyStringPtr(src.Output.StreamStyle),
+			StreamCPS:                copyIntPtr(src.Output.StreamCPS),
+			Theme:                    copyStringPtr(src.Output.Theme),
+			History:                  copyBoolPtr(src.Output.History),
 		},
+		Models: ModelsConfig{Aliases: maps.Clone(src.Models.Aliases)},
 	}
 
 	pm.data.Profiles[name] = clone
@@ -413,6 +656,15 @@ func copyBoolPtr(p *bool) *bool {
 	return &v
 }
 
+// copyDurationPtr returns a new *time.Duration with the same value, or nil if p is nil.
+func copyDurationPtr(p *time.Duration) *time.Duration {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
 // copyStringSlicePtr returns a new *[]string backed by a fresh slice copy, or nil if p is nil.
 func copyStringSlicePtr(p *[]string) *[]string {
 	if p == nil {
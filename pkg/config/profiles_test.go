@@ -1,8 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
 )
 
 // Test helpers for creating pointer values.
@@ -408,6 +412,20 @@ func TestMergeProfile_AllFieldsZero(t *testing.T) {
 	}
 }
 
+func TestMergeProfile_KeepsPrompts(t *testing.T) {
+	data := &ConfigData{
+		Prompts:  map[string]*PromptTemplate{"review": {User: "Review {{code}}"}},
+		Profiles: map[string]*Profile{"work": {Name: "work"}},
+	}
+	merged, err := NewProfileManager(data).MergeProfile("work")
+	if err != nil {
+		t.Fatalf("MergeProfile failed: %v", err)
+	}
+	if _, err := FindPrompt(merged, "review"); err != nil {
+		t.Errorf("prompt templates lost by the profile merge: %v", err)
+	}
+}
+
 func TestMergeProfile_ArrayMerging(t *testing.T) {
 	data := &ConfigData{
 		Search: SearchConfig{
@@ -563,3 +581,328 @@ func TestMergeProfile_InvalidProfileName(t *testing.T) {
 		t.Error("Error should have descriptive message")
 	}
 }
+
+// newExtendsTestData builds base <- research <- deep-research, where each level
+// overrides some of its parent's settings.
+func newExtendsTestData() *ConfigData {
+	data := NewConfigData()
+	data.Defaults.Model = "sonar"
+	data.Defaults.Temperature = 0.2
+	data.Defaults.MaxTokens = 1000
+	data.Search.Mode = "web"
+	data.Profiles = map[string]*Profile{
+		"base": {
+			Name: "base",
+			Defaults: ProfileDefaults{
+				Model:       strPtr("sonar-pro"),
+				Temperature: float64Ptr(0.5),
+				MaxTokens:   intPtr(2000),
+			},
+			Search: ProfileSearch{Domains: strSlicePtr([]string{"example.com"})},
+			Output: ProfileOutput{Stream: boolPtr(true)},
+		},
+		"research": {
+			Name:     "research",
+			Extends:  "base",
+			Defaults: ProfileDefaults{Temperature: float64Ptr(0.3)},
+			Search:   ProfileSearch{Mode: strPtr("academic")},
+			Output:   ProfileOutput{Stream: boolPtr(false)},
+		},
+		"deep-research": {
+			Name:     "deep-research",
+			Extends:  "research",
+			Defaults: ProfileDefaults{Model: strPtr("sonar-deep-research")},
+			Search:   ProfileSearch{Domains: strSlicePtr([]string{"arxiv.org", "nature.com"})},
+		},
+	}
+	return data
+}
+
+func TestLoadProfile_MultiLevelExtends(t *testing.T) {
+	pm := NewProfileManager(newExtendsTestData())
+
+	profile, err := pm.LoadProfile("deep-research")
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+
+	if got := *profile.Defaults.Model; got != "sonar-deep-research" {
+		t.Errorf("Model = %q, want child value sonar-deep-research", got)
+	}
+	if got := *profile.Defaults.Temperature; got != 0.3 {
+		t.Errorf("Temperature = %v, want parent value 0.3", got)
+	}
+	if got := *profile.Defaults.MaxTokens; got != 2000 {
+		t.Errorf("MaxTokens = %d, want grandparent value 2000", got)
+	}
+	if got := *profile.Search.Mode; got != "academic" {
+		t.Errorf("Search.Mode = %q, want parent value academic", got)
+	}
+	if got := *profile.Search.Domains; len(got) != 2 || got[0] != "arxiv.org" {
+		t.Errorf("Search.Domains = %v, want child list to replace ancestors'", got)
+	}
+	if profile.Output.Stream == nil || *profile.Output.Stream {
+		t.Error("Output.Stream should be the parent's explicit false, not the grandparent's true")
+	}
+	if profile.Extends != "research" {
+		t.Errorf("Extends = %q, want research", profile.Extends)
+	}
+}
+
+func TestLoadProfile_ExtendsDoesNotMutateParents(t *testing.T) {
+	data := newExtendsTestData()
+	pm := NewProfileManager(data)
+
+	profile, err := pm.LoadProfile("research")
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	*profile.Defaults.Model = "changed"
+	(*profile.Search.Domains)[0] = "changed.com"
+
+	base := data.Profiles["base"]
+	if *base.Defaults.Model != "sonar-pro" || (*base.Search.Domains)[0] != "example.com" {
+		t.Error("Resolved profile must not alias its ancestors' values")
+	}
+	if data.Profiles["research"].Defaults.Model != nil {
+		t.Error("Resolving must not write inherited values into the raw profile")
+	}
+}
+
+func TestRawProfile_IgnoresExtends(t *testing.T) {
+	pm := NewProfileManager(newExtendsTestData())
+
+	profile, err := pm.RawProfile("deep-research")
+	if err != nil {
+		t.Fatalf("RawProfile failed: %v", err)
+	}
+	if profile.Defaults.Temperature != nil {
+		t.Error("RawProfile should not include inherited Temperature")
+	}
+}
+
+func TestMergeProfile_ExtendsPrecedence(t *testing.T) {
+	pm := NewProfileManager(newExtendsTestData())
+
+	merged, err := pm.MergeProfile("deep-research")
+	if err != nil {
+		t.Fatalf("MergeProfile failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"child wins", merged.Defaults.Model, "sonar-deep-research"},
+		{"parent wins over grandparent", merged.Defaults.Temperature, 0.3},
+		{"grandparent wins over base", merged.Defaults.MaxTokens, 2000},
+		{"parent wins over base", merged.Search.Mode, "academic"},
+		{"explicit false inherited", merged.Output.Stream, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadProfile_ExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles map[string]*Profile
+		load     string
+		wantErr  error
+	}{
+		{
+			name:     "self cycle",
+			profiles: map[string]*Profile{"a": {Name: "a", Extends: "a"}},
+			load:     "a",
+			wantErr:  clerrors.ErrProfileCycle,
+		},
+		{
+			name: "indirect cycle",
+			profiles: map[string]*Profile{
+				"a": {Name: "a", Extends: "b"},
+				"b": {Name: "b", Extends: "c"},
+				"c": {Name: "c", Extends: "a"},
+			},
+			load:    "a",
+			wantErr: clerrors.ErrProfileCycle,
+		},
+		{
+			name:     "missing parent",
+			profiles: map[string]*Profile{"a": {Name: "a", Extends: "ghost"}},
+			load:     "a",
+			wantErr:  clerrors.ErrProfileNotFound,
+		},
+		{
+			name:     "too deep",
+			profiles: profileChainOfLength(maxProfileDepth + 1),
+			load:     "p0",
+			wantErr:  clerrors.ErrProfileChainTooDeep,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := NewConfigData()
+			data.Profiles = tt.profiles
+			_, err := NewProfileManager(data).LoadProfile(tt.load)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("LoadProfile error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadProfile_MaxDepthAllowed(t *testing.T) {
+	data := NewConfigData()
+	data.Profiles = profileChainOfLength(maxProfileDepth)
+
+	if _, err := NewProfileManager(data).LoadProfile("p0"); err != nil {
+		t.Errorf("Chain of %d profiles should resolve, got %v", maxProfileDepth, err)
+	}
+}
+
+func TestLoadProfile_ExtendsDefault(t *testing.T) {
+	data := NewConfigData()
+	data.Profiles = map[string]*Profile{
+		"a": {Name: "a", Extends: DefaultProfileName, Defaults: ProfileDefaults{Model: strPtr("sonar-pro")}},
+	}
+
+	profile, err := NewProfileManager(data).LoadProfile("a")
+	if err != nil {
+		t.Fatalf("Extending the default profile should be a no-op, got %v", err)
+	}
+	if *profile.Defaults.Model != "sonar-pro" {
+		t.Errorf("Model = %q, want sonar-pro", *profile.Defaults.Model)
+	}
+}
+
+func TestProfileManagerDeleteExtended(t *testing.T) {
+	data := newExtendsTestData()
+	pm := NewProfileManager(data)
+
+	err := pm.DeleteProfile("research")
+	if !errors.Is(err, clerrors.ErrProfileInUse) {
+		t.Fatalf("DeleteProfile error = %v, want ErrProfileInUse", err)
+	}
+	if _, exists := data.Profiles["research"]; !exists {
+		t.Error("Profile should not be deleted while other profiles extend it")
+	}
+
+	if err := pm.DeleteProfile("deep-research"); err != nil {
+		t.Fatalf("Deleting a leaf profile failed: %v", err)
+	}
+	if err := pm.DeleteProfile("research"); err != nil {
+		t.Errorf("Deleting research after its child is gone failed: %v", err)
+	}
+}
+
+func TestCloneProfile_KeepsExtends(t *testing.T) {
+	pm := NewProfileManager(newExtendsTestData())
+
+	clone, err := pm.CloneProfile("copy", "deep-research")
+	if err != nil {
+		t.Fatalf("CloneProfile failed: %v", err)
+	}
+	if clone.Extends != "research" {
+		t.Errorf("Extends = %q, want research", clone.Extends)
+	}
+	if clone.Defaults.Temperature != nil {
+		t.Error("Clone should copy the raw profile, not inherited values")
+	}
+}
+
+// profileChainOfLength returns profiles p0 <- p1 <- ... where p0 is the
+// deepest descendant and the chain contains n profiles.
+func profileChainOfLength(n int) map[string]*Profile {
+	profiles := make(map[string]*Profile, n)
+	for i := range n {
+		name := fmt.Sprintf("p%d", i)
+		profile := &Profile{Name: name}
+		if i < n-1 {
+			profile.Extends = fmt.Sprintf("p%d", i+1)
+		}
+		profiles[name] = profile
+	}
+	return profiles
+}
+
+func TestValidateProfileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		wantErr error
+	}{
+		{name: "valid", profile: "deep-research", wantErr: nil},
+		{name: "empty", profile: "", wantErr: clerrors.ErrProfileNameEmpty},
+		{name: "space", profile: "my profile", wantErr: clerrors.ErrProfileNameInvalid},
+		{name: "tab", profile: "my\tprofile", wantErr: clerrors.ErrProfileNameInvalid},
+		{name: "reserved", profile: DefaultProfileName, wantErr: clerrors.ErrProfileNameReserved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProfileName(tt.profile)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateProfileName(%q) = %v, want %v", tt.profile, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfileManagerRename(t *testing.T) {
+	data := newExtendsTestData()
+	data.ActiveProfile = "research"
+	pm := NewProfileManager(data)
+
+	if err := pm.RenameProfile("research", "academic"); err != nil {
+		t.Fatalf("RenameProfile failed: %v", err)
+	}
+
+	if _, exists := data.Profiles["research"]; exists {
+		t.Error("Old profile name should be removed")
+	}
+	renamed, exists := data.Profiles["academic"]
+	if !exists || renamed.Name != "academic" {
+		t.Fatalf("Renamed profile = %+v, want name academic", renamed)
+	}
+	if data.ActiveProfile != "academic" {
+		t.Errorf("ActiveProfile = %q, want academic", data.ActiveProfile)
+	}
+	if got := data.Profiles["deep-research"].Extends; got != "academic" {
+		t.Errorf("Child extends = %q, want academic", got)
+	}
+}
+
+func TestProfileManagerRenameErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldName string
+		newName string
+		wantErr error
+	}{
+		{name: "missing source", oldName: "nope", newName: "other", wantErr: clerrors.ErrProfileNotFound},
+		{name: "existing target", oldName: "research", newName: "base", wantErr: clerrors.ErrProfileAlreadyExists},
+		{name: "whitespace", oldName: "research", newName: "re search", wantErr: clerrors.ErrProfileNameInvalid},
+		{name: "reserved target", oldName: "research", newName: "default", wantErr: clerrors.ErrProfileNameReserved},
+		{name: "default source", oldName: "default", newName: "other", wantErr: clerrors.ErrUpdateDefaultProfile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := newExtendsTestData()
+			err := NewProfileManager(data).RenameProfile(tt.oldName, tt.newName)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RenameProfile(%q, %q) = %v, want %v", tt.oldName, tt.newName, err, tt.wantErr)
+			}
+			if len(data.Profiles) != 3 {
+				t.Errorf("Profiles changed on error: %v", NewProfileManager(data).ListProfiles())
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/logger"
+)
+
+// ProjectFilePermission is the mode of the project config written by
+// config init --project. The file holds no secret and is meant to be
+// committed, so it is readable by everyone.
+const ProjectFilePermission = 0o644
+
+// projectForbiddenKeys are the keys a project config cannot set. Project
+// files are shared with everyone who checks out the repository, so API keys
+// must come from the user config or the environment, and a repository must
+// not change where the key is sent (the endpoint and the proxy) or how the
+// certificate of the API is verified.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var projectForbiddenKeys = []string{"api.key", "api.keys", "api.base_url", "api.proxy_url", "api.tls"}
+
+// ProjectConfigScaffold is the project config written by
+// config init --project: the options commonly set per project, commented
+// out so that the file changes nothing until one is uncommented.
+const ProjectConfigScaffold = `# pplx project configuration.
+#
+# pplx reads this file when run in this directory or below it, up to the
+# root of the git repository, and merges it on top of the user config:
+# a key set here wins over the user config, environment variables and
+# flags win over this file. Uncomment the options this project needs.
+#
+# API keys, the API endpoint and proxy, and TLS settings are never read
+# from this file: keep them in the user config or in PPLX_API_KEY.
+
+# Profile of the user config to use in this project.
+# active_profile: research
+
+# defaults:
+#   model: sonar-pro
+#   system_prompt: Answer for engineers working on this project.
+#   # ISO 639-1 code of the language of the answers.
+#   language: en
+
+# search:
+#   # web or academic.
+#   mode: web
+#   # hour, day, week, month or year.
+#   recency: month
+#   # Domains to search.
+#   domains:
+#     - go.dev
+#     - pkg.go.dev
+
+# output:
+#   # Citation style: list, inline, footnote, or none.
+#   citations: footnote
+`
+
+// MergeProject merges the project config at path like MergeFrom, except
+// that the keys of projectForbiddenKeys, which a project file cannot set,
+// are dropped with a warning.
+func (l *Loader) MergeProject(path string) error {
+	resolved, err := resolveIncludes(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	for _, key := range projectForbiddenKeys {
+		if file, ok := dropKey(resolved, key); ok {
+			logger.Warn("project config cannot set "+key+", ignoring it", "file", file)
+		}
+	}
+	return l.mergeResolved(path, resolved)
+}
+
+// dropKey removes the dot-notation key from doc, with its origins, and
+// returns the file that set it. A section left empty is removed too, so
+// that it does not replace the section of the user config.
+func dropKey(doc *resolvedDocument, key string) (string, bool) {
+	section, name, _ := strings.Cut(key, ".")
+	values, ok := doc.values[section].(map[string]any)
+	if !ok {
+		return "", false
+	}
+	if _, ok := values[name]; !ok {
+		return "", false
+	}
+	delete(values, name)
+	if len(values) == 0 {
+		delete(doc.values, section)
+	}
+
+	file := doc.origins[key]
+	for origin, originFile := range doc.origins {
+		if origin == key || strings.HasPrefix(origin, key+".") {
+			if file == "" {
+				file = originFile
+			}
+			delete(doc.origins, origin)
+		}
+	}
+	return file, true
+}
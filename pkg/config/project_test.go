@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdirProject creates a repository with a .git directory and a nested
+// directory in it, makes the nested directory the working directory, and
+// returns the repository root.
+func chdirProject(t *testing.T) string {
+	t.Helper()
+	isolateConfigSearch(t)
+	root := filepath.Join(t.TempDir(), "repo")
+	nested := filepath.Join(root, "services", "api")
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(nested, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(nested)
+	return root
+}
+
+func TestFindProjectConfig_WalksUp(t *testing.T) {
+	root := chdirProject(t)
+	if _, ok := FindProjectConfig(); ok {
+		t.Fatal("FindProjectConfig() found a project config in an empty repository")
+	}
+
+	rootConfig := filepath.Join(root, ProjectConfigName)
+	writeConfigFile(t, rootConfig, "defaults:\n  model: sonar-pro\n")
+	if got, ok := FindProjectConfig(); !ok || got != rootConfig {
+		t.Errorf("FindProjectConfig() = %q, %v; want the config at the git root %s", got, ok, rootConfig)
+	}
+	if paths := SearchPaths(); paths[len(paths)-1].Path != rootConfig || !paths[len(paths)-1].Exists {
+		t.Errorf("last search path = %+v, want %s", paths[len(paths)-1], rootConfig)
+	}
+
+	nearest := filepath.Join(root, "services", ProjectConfigName)
+	writeConfigFile(t, nearest, "defaults:\n  model: sonar\n")
+	if got, ok := FindProjectConfig(); !ok || got != nearest {
+		t.Errorf("FindProjectConfig() = %q, %v; want the nearest config %s", got, ok, nearest)
+	}
+}
+
+func TestFindProjectConfig_StopsAtGitRoot(t *testing.T) {
+	root := chdirProject(t)
+	writeConfigFile(t, filepath.Join(filepath.Dir(root), ProjectConfigName), "defaults:\n  model: sonar-pro\n")
+
+	if got, ok := FindProjectConfig(); ok {
+		t.Errorf("FindProjectConfig() = %q, want none above the git root", got)
+	}
+}
+
+func TestProjectDirs(t *testing.T) {
+	root := chdirProject(t)
+	nested := filepath.Join(root, "services", "api")
+
+	got := projectDirs(nested)
+	want := []string{nested, filepath.Join(root, "services"), root}
+	if len(got) != len(want) {
+		t.Fatalf("projectDirs() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("projectDirs()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	// Outside a repository, the walk ends at the filesystem root.
+	outside := projectDirs(t.TempDir())
+	if last := outside[len(outside)-1]; filepath.Dir(last) != last {
+		t.Errorf("projectDirs() ends at %q, want the filesystem root", last)
+	}
+}
+
+func TestMergeProject_IgnoresAPIKeys(t *testing.T) {
+	root := chdirProject(t)
+	userConfig := filepath.Join(os.Getenv("HOME"), ".config", "pplx", "config.yaml")
+	writeConfigFile(t, userConfig, "api:\n  key: user-key\n  timeout: 30s\n")
+	project := filepath.Join(root, ProjectConfigName)
+	writeConfigFile(t, project, `
+api:
+  key: project-key
+  keys:
+    work: project-work-key
+  tls:
+    insecure_skip_verify: true
+defaults:
+  model: project-model
+`)
+
+	loader := NewLoader()
+	if err := loader.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := loader.MergeProject(project); err != nil {
+		t.Fatalf("MergeProject() error = %v", err)
+	}
+	cfg := loader.Data()
+	if cfg.API.Key != "user-key" || len(cfg.API.Keys) != 0 {
+		t.Errorf("api.key, api.keys = %q, %v; want the user key only", cfg.API.Key, cfg.API.Keys)
+	}
+	if !cfg.API.TLS.IsZero() {
+		t.Errorf("api.tls = %+v, want the project block ignored", cfg.API.TLS)
+	}
+	if cfg.Defaults.Model != "project-model" {
+		t.Errorf("defaults.model = %q, want project-model", cfg.Defaults.Model)
+	}
+	origins := loader.Origins()
+	if origins["api.key"] != userConfig || origins["api.timeout"] != userConfig {
+		t.Errorf("origins of api = %q, %q; want the user config", origins["api.key"], origins["api.timeout"])
+	}
+	if origins["defaults.model"] != project {
+		t.Errorf("origin of defaults.model = %q, want %s", origins["defaults.model"], project)
+	}
+}
+
+func TestMergeProject_IgnoresEndpoint(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		got   func(*ConfigData) string
+	}{
+		{"base_url", "http://127.0.0.1:9/", func(cfg *ConfigData) string { return cfg.API.BaseURL }},
+		{"proxy_url", "http://127.0.0.1:9", func(cfg *ConfigData) string { return cfg.API.ProxyURL }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			root := chdirProject(t)
+			userConfig := filepath.Join(os.Getenv("HOME"), ".config", "pplx", "config.yaml")
+			writeConfigFile(t, userConfig, "api:\n  "+tt.key+": https://user.example.com\n")
+			project := filepath.Join(root, ProjectConfigName)
+			writeConfigFile(t, project, "api:\n  "+tt.key+": "+tt.value+"\n  timeout: 10s\n")
+
+			loader := NewLoader()
+			if err := loader.Load(); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if err := loader.MergeProject(project); err != nil {
+				t.Fatalf("MergeProject() error = %v", err)
+			}
+			if got := tt.got(loader.Data()); got != "https://user.example.com" {
+				t.Errorf("api.%s = %q, want the user value", tt.key, got)
+			}
+			origins := loader.Origins()
+			if origins["api."+tt.key] != userConfig || origins["api.timeout"] != project {
+				t.Errorf("origins of api.%s, api.timeout = %q, %q; want the user config, then the project",
+					tt.key, origins["api."+tt.key], origins["api.timeout"])
+			}
+		})
+	}
+}
+
+func TestMergeProject_EmptyAPISection(t *testing.T) {
+	root := chdirProject(t)
+	userConfig := filepath.Join(os.Getenv("HOME"), ".config", "pplx", "config.yaml")
+	writeConfigFile(t, userConfig, "api:\n  timeout: 30s\n")
+	project := filepath.Join(root, ProjectConfigName)
+	writeConfigFile(t, project, "api:\n  key: project-key\n")
+
+	loader := NewLoader()
+	if err := loader.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := loader.MergeProject(project); err != nil {
+		t.Fatalf("MergeProject() error = %v", err)
+	}
+	if got := loader.Origins()["api.timeout"]; got != userConfig {
+		t.Errorf("origin of api.timeout = %q, want the user config to keep it", got)
+	}
+}
+
+func TestProjectConfigScaffold(t *testing.T) {
+	root := chdirProject(t)
+	userConfig := filepath.Join(os.Getenv("HOME"), ".config", "pplx", "config.yaml")
+	writeConfigFile(t, userConfig, "defaults:\n  model: user-model\nsearch:\n  mode: academic\n")
+	writeConfigFile(t, filepath.Join(root, ProjectConfigName), ProjectConfigScaffold)
+
+	cfg, err := LoadAndMergeConfig(createTestCommand(), "", "")
+	if err != nil {
+		t.Fatalf("LoadAndMergeConfig() with the scaffold error = %v", err)
+	}
+	if cfg.Defaults.Model != "user-model" || cfg.Search.Mode != "academic" {
+		t.Errorf("model, mode = %q, %q; want the scaffold to change nothing", cfg.Defaults.Model, cfg.Search.Mode)
+	}
+}
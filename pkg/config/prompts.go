@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/prompts"
+)
+
+// FindPrompt returns the prompt template called name. Names are matched
+// case-insensitively because the config loader lowercases map keys.
+func FindPrompt(cfg *ConfigData, name string) (*PromptTemplate, error) {
+	if cfg != nil {
+		if p, ok := cfg.Prompts[name]; ok && p != nil {
+			return p, nil
+		}
+		for key, p := range cfg.Prompts {
+			if p != nil && strings.EqualFold(key, name) {
+				return p, nil
+			}
+		}
+	}
+
+	names := PromptNames(cfg)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: %s (no prompts are defined in the config file)", clerrors.ErrPromptNotFound, name)
+	}
+	return nil, fmt.Errorf("%w: %s (available: %s)", clerrors.ErrPromptNotFound, name, strings.Join(names, ", "))
+}
+
+// PromptNames returns the names of the prompt templates in cfg, sorted.
+func PromptNames(cfg *ConfigData) []string {
+	if cfg == nil {
+		return nil
+	}
+	names := make([]string, 0, len(cfg.Prompts))
+	for name, p := range cfg.Prompts {
+		if p != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Variables returns the sorted names of the variables used by the system and
+// user text of the template.
+func (p *PromptTemplate) Variables(name string) ([]string, error) {
+	system, user, err := p.parse(name)
+	if err != nil {
+		return nil, err
+	}
+	vars := append(system.Variables(), user.Variables()...)
+	sort.Strings(vars)
+	return slices.Compact(vars), nil
+}
+
+// Render fills the placeholders of the system and user text with vars.
+// All variables missing from either text are reported in a single error
+// wrapping clerrors.ErrMissingPromptVariables.
+func (p *PromptTemplate) Render(name string, vars map[string]string) (string, string, error) {
+	system, user, err := p.parse(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	missing := append(system.Missing(vars), user.Missing(vars)...)
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", "", prompts.MissingError(name, slices.Compact(missing))
+	}
+
+	systemText, err := system.Render(vars)
+	if err != nil {
+		return "", "", err //nolint:wrapcheck // prompts errors already name the template
+	}
+	userText, err := user.Render(vars)
+	if err != nil {
+		return "", "", err //nolint:wrapcheck // prompts errors already name the template
+	}
+	return systemText, userText, nil
+}
+
+// parse parses the system and user text of the template.
+func (p *PromptTemplate) parse(name string) (*prompts.Template, *prompts.Template, error) {
+	system, err := prompts.Parse(name+".system", p.System)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // prompts errors already name the template
+	}
+	user, err := prompts.Parse(name+".user", p.User)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // prompts errors already name the template
+	}
+	return system, user, nil
+}
+
+// SystemPromptFilePrefix marks a defaults.system_prompt value naming a file
+// to load the prompt from, such as "file://prompts/research.md".
+const SystemPromptFilePrefix = "file://"
+
+// resolveSystemPromptFile replaces a file:// defaults.system_prompt with the
+// content of the file. A relative path is relative to the directory of
+// configFile, or to the working directory when no config file was loaded.
+func resolveSystemPromptFile(cfg *ConfigData, configFile string) error {
+	path, ok := strings.CutPrefix(cfg.Defaults.SystemPrompt, SystemPromptFilePrefix)
+	if !ok {
+		return nil
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(path) && configFile != "" {
+		path = filepath.Join(filepath.Dir(configFile), path)
+	}
+	content, err := os.ReadFile(path) //nolint:gosec // the path is chosen by the user in the config
+	if err != nil {
+		return clerrors.NewConfigError("cannot read defaults.system_prompt from "+path, err)
+	}
+	cfg.Defaults.SystemPrompt = strings.TrimSpace(string(content))
+	return nil
+}
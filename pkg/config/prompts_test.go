@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestFindPrompt(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfigData()
+	cfg.Prompts = map[string]*PromptTemplate{
+		"review":  {System: "Review {{language}}"},
+		"summary": {User: "Summarize"},
+	}
+
+	if p, err := FindPrompt(cfg, "Review"); err != nil || p != cfg.Prompts["review"] {
+		t.Errorf("FindPrompt(Review) = %v, %v; want the review template", p, err)
+	}
+
+	_, err := FindPrompt(cfg, "missing")
+	if !errors.Is(err, clerrors.ErrPromptNotFound) {
+		t.Fatalf("FindPrompt(missing) error = %v, want ErrPromptNotFound", err)
+	}
+	if !strings.Contains(err.Error(), "review, summary") {
+		t.Errorf("error %q should list the available prompts", err)
+	}
+}
+
+func TestPromptTemplateRender(t *testing.T) {
+	t.Parallel()
+
+	p := &PromptTemplate{
+		System: "You are a {{language}} expert. Be {{tone}}.",
+		User:   "Review {{file}} in {{language}}.",
+	}
+
+	vars, err := p.Variables("review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(vars, ","); got != "file,language,tone" {
+		t.Errorf("Variables() = %s, want file,language,tone", got)
+	}
+
+	_, _, err = p.Render("review", map[string]string{"language": "Go"})
+	if !errors.Is(err, clerrors.ErrMissingPromptVariables) {
+		t.Fatalf("Render() error = %v, want ErrMissingPromptVariables", err)
+	}
+	if !strings.Contains(err.Error(), "file, tone") {
+		t.Errorf("error %q should list both missing variables", err)
+	}
+
+	system, user, err := p.Render("review", map[string]string{"language": "Go", "tone": "brief", "file": "main.go"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if system != "You are a Go expert. Be brief." || user != "Review main.go in Go." {
+		t.Errorf("Render() = %q, %q", system, user)
+	}
+}
+
+func TestValidatorInvalidPrompts(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfigData()
+	cfg.Prompts = map[string]*PromptTemplate{
+		"empty":  {Description: "no text"},
+		"broken": {System: "Hello {{if .x}}"},
+	}
+
+	err := NewValidator().Validate(cfg)
+	if err == nil {
+		t.Fatal("Validate() should reject empty and unparsable prompts")
+	}
+	for _, want := range []string{"prompts.empty", "prompts.broken"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadFrom_Prompts(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+prompts:
+  review:
+    description: Code review
+    system: You are a {{language}} expert.
+`
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := NewLoader()
+	if err := loader.LoadFrom(configPath); err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+	p, err := FindPrompt(loader.Data(), "review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Description != "Code review" || p.System != "You are a {{language}} expert." {
+		t.Errorf("loaded prompt = %+v", p)
+	}
+}
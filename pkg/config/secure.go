@@ -0,0 +1,322 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SecureFileMode is the mode pplx config secure gives the files of pplx.
+	SecureFileMode os.FileMode = 0o600
+	// SecureDirMode is the mode pplx config secure gives the directories of pplx.
+	SecureDirMode os.FileMode = 0o700
+	// groupOtherPermissions are the permission bits of group and others.
+	groupOtherPermissions = 0o077
+	// worldReadable is the read bit of others.
+	worldReadable = 0o004
+)
+
+// Kinds of the paths reported by CheckSecurity.
+const (
+	KindConfigFile = "config file"
+	KindConfigDir  = "config directory"
+	KindBackup     = "backup"
+	KindBackupDir  = "backup directory"
+	KindDataFile   = "data file"
+	KindDataDir    = "data directory"
+)
+
+// OwnerOnly reports whether mode grants no access to group or others, as a
+// config file that may hold an API key should: 0600, or 0400 when read-only.
+func OwnerOnly(mode os.FileMode) bool {
+	return mode.Perm()&groupOtherPermissions == 0
+}
+
+// PermissionIssue is a file or directory of pplx that group or others can
+// access.
+type PermissionIssue struct {
+	// Kind tells what Path is, one of the Kind constants.
+	Kind string
+	Path string
+	// Mode is the current permission of Path and Want the one Fix applies.
+	Mode os.FileMode
+	Want os.FileMode
+}
+
+// String describes the issue, as pplx doctor and pplx config secure print it.
+func (p PermissionIssue) String() string {
+	return fmt.Sprintf("%s %s is %04o, should be %04o", p.Kind, p.Path, p.Mode, p.Want)
+}
+
+// Change describes what Fix does.
+func (p PermissionIssue) Change() string {
+	return fmt.Sprintf("chmod %04o %s (was %04o)", p.Want, p.Path, p.Mode)
+}
+
+// Fix gives Path its wanted mode.
+func (p PermissionIssue) Fix() error {
+	if err := os.Chmod(p.Path, p.Want); err != nil {
+		return clerrors.NewIOError("failed to secure "+p.Kind, err)
+	}
+	return nil
+}
+
+// CheckPermission returns the issue of the file or directory at path when
+// group or others can access it, or nil. A missing path has no issue.
+func CheckPermission(path, kind string, want os.FileMode) (*PermissionIssue, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check file permissions for %s: %w", path, err)
+	}
+	mode := info.Mode().Perm()
+	if OwnerOnly(mode) {
+		return nil, nil
+	}
+	return &PermissionIssue{Kind: kind, Path: path, Mode: mode, Want: want}, nil
+}
+
+// ProjectKey is an api.key found in a project config that others can read.
+// pplx never reads it from there, but everyone who can read the file can.
+type ProjectKey struct {
+	Path string
+	Mode os.FileMode
+}
+
+// String describes the issue, as pplx doctor and pplx config secure print it.
+func (k ProjectKey) String() string {
+	return fmt.Sprintf("project config %s (%04o) holds api.key, readable by everyone", k.Path, k.Mode)
+}
+
+// SecurityPaths are the locations CheckSecurity inspects. An empty field
+// is skipped.
+type SecurityPaths struct {
+	// ConfigFile is the user config. Its backups are checked too, and so is
+	// its directory when it is a pplx config directory (see ConfigDirs).
+	ConfigFile string
+	// DataDir holds the usage log, chat history and autosave: every file
+	// and directory below it is checked.
+	DataDir string
+	// ProjectFile is the project config of the current directory.
+	ProjectFile string
+}
+
+// DefaultSecurityPaths returns the paths of configFile, or of the user
+// config found when it is empty, the data directory, and the project
+// config of the current directory.
+func DefaultSecurityPaths(configFile string) SecurityPaths {
+	if configFile == "" {
+		configFile, _ = FindConfigFile()
+	}
+	project, _ := FindProjectConfig()
+	return SecurityPaths{ConfigFile: configFile, DataDir: DataDir(), ProjectFile: project}
+}
+
+// DataDir returns the directory of the usage log, chat history and chat
+// autosave, ~/.local/share/pplx, or "" without a home directory.
+func DataDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share", "pplx")
+}
+
+// SecurityReport lists what CheckSecurity found.
+type SecurityReport struct {
+	Permissions []PermissionIssue
+	// ProjectKey is set when a project config others can read holds api.key.
+	ProjectKey *ProjectKey
+}
+
+// Empty reports whether nothing was found.
+func (r SecurityReport) Empty() bool {
+	return len(r.Permissions) == 0 && r.ProjectKey == nil
+}
+
+// Issues describes every finding, one per line.
+func (r SecurityReport) Issues() []string {
+	issues := make([]string, 0, len(r.Permissions)+1)
+	for _, p := range r.Permissions {
+		issues = append(issues, p.String())
+	}
+	if r.ProjectKey != nil {
+		issues = append(issues, r.ProjectKey.String())
+	}
+	return issues
+}
+
+// CheckSecurity inspects paths: the config file, its backups and its
+// directory, and everything in the data directory, must grant group and
+// others no access, and a project config others can read must not hold an
+// API key. Missing paths are skipped. Symbolic links are not followed in the
+// data and backup directories.
+func CheckSecurity(paths SecurityPaths) (SecurityReport, error) {
+	var report SecurityReport
+	add := func(issue *PermissionIssue, err error) error {
+		if issue != nil {
+			report.Permissions = append(report.Permissions, *issue)
+		}
+		return err
+	}
+
+	if paths.ConfigFile != "" {
+		if err := add(CheckPermission(paths.ConfigFile, KindConfigFile, SecureFileMode)); err != nil {
+			return report, err
+		}
+		if dir := filepath.Dir(paths.ConfigFile); isPplxConfigDir(dir) {
+			if err := add(CheckPermission(dir, KindConfigDir, SecureDirMode)); err != nil {
+				return report, err
+			}
+		}
+		if err := checkTree(BackupDir(paths.ConfigFile), KindBackupDir, KindBackup, add); err != nil {
+			return report, err
+		}
+	}
+	if paths.DataDir != "" {
+		if err := checkTree(paths.DataDir, KindDataDir, KindDataFile, add); err != nil {
+			return report, err
+		}
+	}
+	if paths.ProjectFile != "" {
+		key, err := checkProjectKey(paths.ProjectFile)
+		if err != nil {
+			return report, err
+		}
+		report.ProjectKey = key
+	}
+	return report, nil
+}
+
+// isPplxConfigDir reports whether dir holds the config of pplx only, so
+// that securing it cannot lock out other programs: one of ConfigDirs, or a
+// directory named pplx.
+func isPplxConfigDir(dir string) bool {
+	return slices.Contains(ConfigDirs(), filepath.Clean(dir)) || filepath.Base(dir) == "pplx"
+}
+
+// checkTree passes to add the issues of root and of every file and
+// directory below it.
+func checkTree(root, dirKind, fileKind string, add func(*PermissionIssue, error) error) error {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			return nil
+		case d.IsDir():
+			return add(CheckPermission(path, dirKind, SecureDirMode))
+		default:
+			return add(CheckPermission(path, fileKind, SecureFileMode))
+		}
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check file permissions in %s: %w", root, err)
+	}
+	return nil
+}
+
+// checkProjectKey returns the ProjectKey of the project config at path
+// when others can read it and it sets api.key.
+func checkProjectKey(path string) (*ProjectKey, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check file permissions for %s: %w", path, err)
+	}
+	if info.Mode().Perm()&worldReadable == 0 {
+		return nil, nil
+	}
+	key, err := fileAPIKey(path)
+	if err != nil || key == "" {
+		return nil, err
+	}
+	return &ProjectKey{Path: path, Mode: info.Mode().Perm()}, nil
+}
+
+// fileAPIKey returns api.key as written in the YAML file at path.
+func fileAPIKey(path string) (string, error) {
+	doc, err := readYAMLDocument(path)
+	if err != nil {
+		return "", err
+	}
+	api := mappingValue(doc.Content[0], "api")
+	if api == nil || api.Kind != yaml.MappingNode {
+		return "", nil
+	}
+	if key := mappingValue(api, "key"); key != nil && key.Kind == yaml.ScalarNode {
+		return key.Value, nil
+	}
+	return "", nil
+}
+
+// MoveProjectKey moves api.key from the project config at projectFile to
+// the user config at configFile, and removes the api section of the project
+// config when it is left empty. It fails without changing anything when the
+// user config already sets another api.key.
+func MoveProjectKey(projectFile, configFile string) error {
+	key, err := fileAPIKey(projectFile)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return nil
+	}
+	existing, err := fileAPIKey(configFile)
+	if err != nil {
+		return err
+	}
+	if existing != "" && existing != key {
+		return clerrors.NewConfigError(fmt.Sprintf(
+			"api.key of %s differs from the one already set in %s; remove it by hand", projectFile, configFile), nil)
+	}
+	if existing == "" {
+		if err := SetFileValue(configFile, []string{"api", "key"}, key); err != nil {
+			return err
+		}
+	}
+
+	doc, err := readYAMLDocument(projectFile)
+	if err != nil {
+		return err
+	}
+	root := doc.Content[0]
+	api := mappingValue(root, "api")
+	deleteMappingKey(api, "key")
+	if len(api.Content) == 0 {
+		deleteMappingKey(root, "api")
+	}
+	return writeYAMLDocument(projectFile, doc)
+}
+
+// deleteMappingKey removes key and its value from mapping node m. A comment
+// above key is kept above the key that follows it.
+func deleteMappingKey(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != key {
+			continue
+		}
+		if comment := m.Content[i].HeadComment; comment != "" && i+2 < len(m.Content) {
+			next := m.Content[i+2]
+			next.HeadComment = strings.TrimSuffix(comment+"\n"+next.HeadComment, "\n")
+		}
+		m.Content = slices.Delete(m.Content, i, i+2)
+		return
+	}
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeMode writes content to path, creating its directory, and sets mode
+// regardless of the umask.
+func writeMode(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chmod sets the mode of path.
+func chmod(t *testing.T, path string, mode os.FileMode) {
+	t.Helper()
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckPermission(t *testing.T) {
+	tests := []struct {
+		mode      os.FileMode
+		wantIssue bool
+	}{
+		{0o600, false},
+		{0o400, false},
+		{0o700, false},
+		{0o640, true},
+		{0o604, true},
+		{0o644, true},
+		{0o666, true},
+		{0o777, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			writeMode(t, path, "version: 2\n", tt.mode)
+			issue, err := CheckPermission(path, KindConfigFile, SecureFileMode)
+			if err != nil {
+				t.Fatalf("CheckPermission() error = %v", err)
+			}
+			if (issue != nil) != tt.wantIssue {
+				t.Fatalf("CheckPermission() = %+v, want an issue: %v", issue, tt.wantIssue)
+			}
+			if issue == nil {
+				return
+			}
+			if issue.Mode != tt.mode || issue.Want != SecureFileMode {
+				t.Errorf("issue = %+v, want mode %04o and want 0600", issue, tt.mode)
+			}
+			if err := issue.Fix(); err != nil {
+				t.Fatalf("Fix() error = %v", err)
+			}
+			if again, _ := CheckPermission(path, KindConfigFile, SecureFileMode); again != nil {
+				t.Errorf("issue left after Fix(): %+v", again)
+			}
+		})
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	if issue, err := CheckPermission(missing, KindConfigFile, SecureFileMode); issue != nil || err != nil {
+		t.Errorf("missing file = %+v, %v; want no issue", issue, err)
+	}
+}
+
+func TestCheckSecurity(t *testing.T) {
+	oldPaths := ConfigPaths
+	t.Cleanup(func() { ConfigPaths = oldPaths })
+
+	root := t.TempDir()
+	configDir := filepath.Join(root, "config", "pplx")
+	ConfigPaths = []string{configDir}
+	configFile := filepath.Join(configDir, "config.yaml")
+	writeMode(t, configFile, "version: 2\n", 0o644)
+	chmod(t, configDir, 0o755)
+	backup := filepath.Join(BackupDir(configFile), "config-20260101-000000.000.yaml")
+	writeMode(t, backup, "version: 2\n", 0o640)
+	secureBackup := filepath.Join(BackupDir(configFile), "config-20260102-000000.000.yaml")
+	writeMode(t, secureBackup, "version: 2\n", 0o600)
+
+	dataDir := filepath.Join(root, "data", "pplx")
+	writeMode(t, filepath.Join(dataDir, "usage.jsonl"), "{}\n", 0o644)
+	writeMode(t, filepath.Join(dataDir, "chat_history"), "hi\n", 0o600)
+	writeMode(t, filepath.Join(dataDir, "autosave.json"), "{}\n", 0o604)
+	chmod(t, dataDir, 0o750)
+	if err := os.Symlink("/etc/hosts", filepath.Join(dataDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	project := filepath.Join(root, "repo", ProjectConfigName)
+	writeMode(t, project, "api:\n  key: pplx-secret\n  timeout: 30s\n", 0o644)
+
+	report, err := CheckSecurity(SecurityPaths{ConfigFile: configFile, DataDir: dataDir, ProjectFile: project})
+	if err != nil {
+		t.Fatalf("CheckSecurity() error = %v", err)
+	}
+	var got []string
+	for _, p := range report.Permissions {
+		got = append(got, p.Kind+" "+filepath.Base(p.Path))
+	}
+	slices.Sort(got)
+	want := []string{
+		"backup config-20260101-000000.000.yaml",
+		"config directory pplx",
+		"config file config.yaml",
+		"data directory pplx",
+		"data file autosave.json",
+		"data file usage.jsonl",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("issues = %v, want %v", got, want)
+	}
+	if report.ProjectKey == nil || report.ProjectKey.Path != project {
+		t.Errorf("ProjectKey = %+v, want %s", report.ProjectKey, project)
+	}
+	if n := len(report.Issues()); n != len(want)+1 {
+		t.Errorf("Issues() has %d lines, want %d", n, len(want)+1)
+	}
+
+	for _, p := range report.Permissions {
+		if err := p.Fix(); err != nil {
+			t.Fatalf("Fix() error = %v", err)
+		}
+	}
+	chmod(t, project, 0o640)
+	report, err = CheckSecurity(SecurityPaths{ConfigFile: configFile, DataDir: dataDir, ProjectFile: project})
+	if err != nil || !report.Empty() {
+		t.Errorf("after fixing: %v, %v; want nothing left", report.Issues(), err)
+	}
+}
+
+func TestCheckSecurity_ConfigDirOutsidePplx(t *testing.T) {
+	dir := t.TempDir()
+	chmod(t, dir, 0o755)
+	configFile := filepath.Join(dir, "custom.yaml")
+	writeMode(t, configFile, "version: 2\n", 0o600)
+
+	report, err := CheckSecurity(SecurityPaths{ConfigFile: configFile})
+	if err != nil || !report.Empty() {
+		t.Errorf("CheckSecurity() = %v, %v; a directory not owned by pplx must be left alone", report.Issues(), err)
+	}
+}
+
+func TestCheckSecurity_ProjectKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+		want    bool
+	}{
+		{"world-readable key", "api:\n  key: pplx-secret\n", 0o644, true},
+		{"owner-only key", "api:\n  key: pplx-secret\n", 0o600, false},
+		{"group-readable key", "api:\n  key: pplx-secret\n", 0o640, false},
+		{"no key", "defaults:\n  model: sonar\n", 0o644, false},
+		{"empty key", "api:\n  key: \"\"\n", 0o644, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := filepath.Join(t.TempDir(), ProjectConfigName)
+			writeMode(t, project, tt.content, tt.mode)
+			report, err := CheckSecurity(SecurityPaths{ProjectFile: project})
+			if err != nil {
+				t.Fatalf("CheckSecurity() error = %v", err)
+			}
+			if got := report.ProjectKey != nil; got != tt.want {
+				t.Errorf("ProjectKey = %+v, want one: %v", report.ProjectKey, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveProjectKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		project     string
+		config      string
+		wantErr     bool
+		wantProject string
+	}{
+		{
+			name:        "new user config",
+			project:     "# project\napi:\n  key: pplx-secret\ndefaults:\n  model: sonar\n",
+			wantProject: "# project\ndefaults:\n  model: sonar\n",
+		},
+		{
+			name:        "keeps other api options",
+			project:     "api:\n  key: pplx-secret\n  timeout: 30s\n",
+			config:      "version: 2\n",
+			wantProject: "api:\n  timeout: 30s\n",
+		},
+		{
+			name:        "same key already set",
+			project:     "api:\n  key: pplx-secret\n",
+			config:      "api:\n  key: pplx-secret\n",
+			wantProject: "{}\n",
+		},
+		{
+			name:    "another key already set",
+			project: "api:\n  key: pplx-secret\n",
+			config:  "api:\n  key: pplx-other\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			project := filepath.Join(dir, "repo", ProjectConfigName)
+			writeMode(t, project, tt.project, 0o644)
+			configFile := filepath.Join(dir, "pplx", "config.yaml")
+			if tt.config != "" {
+				writeMode(t, configFile, tt.config, 0o600)
+			}
+
+			err := MoveProjectKey(project, configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("MoveProjectKey() should fail")
+				}
+				if data, _ := os.ReadFile(project); string(data) != tt.project {
+					t.Errorf("project config changed on failure:\n%s", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MoveProjectKey() error = %v", err)
+			}
+			if key, _ := fileAPIKey(configFile); key != "pplx-secret" {
+				t.Errorf("user config api.key = %q, want the moved key", key)
+			}
+			data, err := os.ReadFile(project)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.wantProject {
+				t.Errorf("project config =\n%s\nwant\n%s", data, tt.wantProject)
+			}
+			info, err := os.Stat(configFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !OwnerOnly(info.Mode()) {
+				t.Errorf("user config mode = %04o, want owner-only", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestCheckFilePermissions_ReportsSecurityIssues(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeMode(t, path, "version: 2\n", 0o644)
+
+	check := checkFilePermissions(path)
+	if check.Status != CheckWarn {
+		t.Fatalf("status = %v, want warn", check.Status)
+	}
+	if !strings.Contains(check.Detail, "config file "+path+" is 0644, should be 0600") ||
+		!strings.Contains(check.Detail, "pplx config secure") {
+		t.Errorf("detail = %q", check.Detail)
+	}
+
+	chmod(t, path, 0o600)
+	if check := checkFilePermissions(path); check.Status != CheckPass || check.Detail != "0600" {
+		t.Errorf("owner-only file = %+v, want pass", check)
+	}
+}
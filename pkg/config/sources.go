@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Source identifies where the effective value of an option came from.
+type Source string
+
+// Option sources, from lowest to highest precedence.
+const (
+	// SourceDefault means no file, profile, or flag set the option.
+	SourceDefault Source = "default"
+	// SourceConfig means the config file set the option.
+	SourceConfig Source = "config"
+	// SourceEnv means the value came from an environment variable, either
+	// through ${VAR} interpolation in the config file or a PPLX_* variable.
+	SourceEnv Source = "env"
+	// SourceProfile means the active profile overrode the option.
+	SourceProfile Source = "profile"
+	// SourcePreset means a flag of the query preset set the option.
+	SourcePreset Source = "preset"
+	// SourceFlag means a command-line flag set the option.
+	SourceFlag Source = "flag"
+)
+
+// flagConfigKeys maps the flags merged by MergeWithFlags to their config keys.
+var flagConfigKeys = map[string]string{
+	"model":                       "defaults.model",
+	"temperature":                 "defaults.temperature",
+	"max-tokens":                  "defaults.max_tokens",
+	"top-k":                       "defaults.top_k",
+	"top-p":                       "defaults.top_p",
+	"frequency-penalty":           "defaults.frequency_penalty",
+	"presence-penalty":            "defaults.presence_penalty",
+	"timeout":                     "defaults.timeout",
+	"language":                    "defaults.language",
+	"sys-prompt":                  "defaults.system_prompt",
+	"fallback-models":             "defaults.model_fallbacks",
+	"search-domains":              "search.domains",
+	"exclude-domains":             "search.exclude_domains",
+	"search-recency":              "search.recency",
+	"search-mode":                 "search.mode",
+	"search-context-size":         "search.context_size",
+	"location-lat":                "search.location_lat",
+	"location-lon":                "search.location_lon",
+	"location-country":            "search.location_country",
+	"search-after-date":           "search.after_date",
+	"search-before-date":          "search.before_date",
+	"last-updated-after":          "search.last_updated_after",
+	"last-updated-before":         "search.last_updated_before",
+	"stream":                      "output.stream",
+	"return-images":               "output.return_images",
+	"return-related":              "output.return_related",
+	"json":                        "output.json",
+	"image-domains":               "output.image_domains",
+	"image-formats":               "output.image_formats",
+	"response-format-json-schema": "output.response_format_json_schema",
+	"response-format-regex":       "output.response_format_regex",
+	"reasoning-effort":            "output.reasoning_effort",
+	"citations":                   "output.citations",
+	"citation-max-per-domain":     "output.citation_max_per_domain",
+	"citation-exclude-domains":    "output.citation_exclude_domains",
+	"stream-style":                "output.stream_style",
+	"max-retries":                 "api.max_retries",
+	"connect-timeout":             "api.connect_timeout",
+	"base-url":                    "api.base_url",
+}
+
+// Sources returns the source of every config key, in dot notation. Keys not
+// set by the config file, a profile, or a flag are SourceDefault.
+func (m *Merger) Sources() map[string]Source {
+	sources := make(map[string]Source, len(m.sources))
+	for _, key := range AllKeys() {
+		sources[key] = SourceDefault
+	}
+	for key, source := range m.sources {
+		sources[key] = source
+	}
+	return sources
+}
+
+// recordFileSources marks the keys set in the config file read by v. Values
+// that interpolate environment variables are marked SourceEnv.
+func (m *Merger) recordFileSources(v *viper.Viper) {
+	for _, key := range AllKeys() {
+		if !v.InConfig(key) {
+			continue
+		}
+		m.sources[key] = SourceConfig
+		if strings.Contains(fmt.Sprint(v.Get(key)), "$") {
+			m.sources[key] = SourceEnv
+		}
+	}
+	if os.Getenv("PPLX_API_KEY") != "" {
+		m.sources["api.key"] = SourceEnv
+	}
+}
+
+// recordEnvSources marks the keys set by PPLX_* environment variables.
+func (m *Merger) recordEnvSources(keys []string) {
+	for _, key := range keys {
+		m.sources[key] = SourceEnv
+	}
+}
+
+// recordProfileSources marks the keys overridden by profile.
+func (m *Merger) recordProfileSources(profile *Profile) {
+	for _, key := range AllKeys() {
+		if value, err := GetProfileValue(profile, key); err == nil && value != nil {
+			m.sources[key] = SourceProfile
+		}
+	}
+}
+
+// recordFlagSources marks the keys set by flags explicitly given on cmd,
+// or set by the preset (see ApplyPreset).
+func (m *Merger) recordFlagSources(cmd *cobra.Command) {
+	for flag, key := range flagConfigKeys {
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+		m.sources[key] = SourceFlag
+		if f := cmd.Flags().Lookup(flag); f.Annotations[PresetAnnotation] != nil {
+			m.sources[key] = SourcePreset
+		}
+	}
+}
@@ -1,11 +1,18 @@
 package config
 
 import (
+	"bufio"
+	"bytes"
 	"embed"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/logger"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,6 +29,14 @@ const (
 	TemplateFullExample = "full-example"
 )
 
+// templatesDirName is the subdirectory of the config directory holding
+// user-defined templates.
+const templatesDirName = "templates"
+
+// descriptionComment prefixes the top-of-file comment describing a user
+// template.
+const descriptionComment = "# description:"
+
 // templateFileMap maps template names to their file paths in the embedded filesystem.
 var templateFileMap = map[string]string{
 	TemplateResearch:    "templates/research.yaml",
@@ -40,13 +55,38 @@ type TemplateInfo struct {
 
 	// UseCase describes the primary use case for this template
 	UseCase string
+
+	// Path is the file of a user-defined template, empty for built-ins
+	Path string
+}
+
+// userTemplateHeader holds the optional description field of a user
+// template. It is not part of ConfigData, so it is not copied into the
+// configuration created from the template.
+type userTemplateHeader struct {
+	Description string `yaml:"description"`
 }
 
 // LoadTemplate loads a configuration template by name.
-// Valid template names are: research, creative, news, full-example.
+// Built-in template names are: research, creative, news, full-example.
+// User templates in ~/.config/pplx/templates/ are looked up first, so they
+// shadow built-ins of the same name.
 // Returns a ConfigData struct populated with the template's configuration,
 // or an error if the template name is invalid or the template cannot be parsed.
 func LoadTemplate(name string) (*ConfigData, error) {
+	return loadTemplate(UserTemplatesDir(), name)
+}
+
+// loadTemplate loads the template called name, looking in dir first.
+func loadTemplate(dir, name string) (*ConfigData, error) {
+	if path, ok := findUserTemplate(dir, name); ok {
+		data, err := os.ReadFile(path) //nolint:gosec // user template directory
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read template %s: %w", clerrors.ErrTemplateInvalid, name, err)
+		}
+		return parseTemplate(name, data)
+	}
+
 	// Check if template exists
 	filePath, exists := templateFileMap[name]
 	if !exists {
@@ -58,41 +98,179 @@ func LoadTemplate(name string) (*ConfigData, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%w: failed to read template %s: %w", clerrors.ErrTemplateInvalid, name, err)
 	}
+	return parseTemplate(name, data)
+}
 
-	// Parse YAML into ConfigData
+// parseTemplate parses the YAML of the template called name into ConfigData.
+func parseTemplate(name string, data []byte) (*ConfigData, error) {
 	var config ConfigData
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("%w: failed to parse template %s: %w", clerrors.ErrTemplateInvalid, name, err)
 	}
-
 	return &config, nil
 }
 
-// ListTemplates returns a list of all available configuration templates.
+// UserTemplatesDir returns the directory searched for user-defined
+// templates, templates/ in the user config directory.
+func UserTemplatesDir() string {
+	return filepath.Join(UserConfigDir(), templatesDirName)
+}
+
+// findUserTemplate returns the path of the user template called name in dir.
+func findUserTemplate(dir, name string) (string, bool) {
+	if dir == "" || name == "" || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	for _, ext := range []string{".yaml", ".yml"} {
+		path := filepath.Join(dir, name+ext)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// ListTemplates returns a list of all available configuration templates:
+// the built-ins followed by the user templates, sorted by name. A user
+// template replaces the built-in of the same name.
 // Each template includes metadata describing its name, purpose, and use case.
 func ListTemplates() []TemplateInfo {
-	return []TemplateInfo{
-		{
-			Name:        TemplateResearch,
-			Description: "Optimized for academic and scholarly research with authoritative sources",
-			UseCase:     "Academic research, literature reviews, and scholarly inquiries requiring peer-reviewed sources",
-		},
-		{
-			Name:        TemplateCreative,
-			Description: "High-temperature creative configuration with streaming enabled",
-			UseCase:     "Creative writing, brainstorming, content generation, and exploratory queries",
-		},
-		{
-			Name:        TemplateNews,
-			Description: "News-focused configuration with reputable news sources and weekly recency filter",
-			UseCase:     "Current events tracking, news analysis, and recent developments research",
-		},
-		{
-			Name:        TemplateFullExample,
-			Description: "Comprehensive example showing all 29+ configuration options with detailed comments",
-			UseCase:     "Learning available options, creating custom configurations, and understanding configuration structure",
-		},
+	return listTemplates(UserTemplatesDir())
+}
+
+// listTemplates lists the built-in templates and the user templates in dir.
+func listTemplates(dir string) []TemplateInfo {
+	user := listUserTemplates(dir)
+	templates := make([]TemplateInfo, 0, len(builtinTemplates)+len(user))
+	shadowed := make(map[string]TemplateInfo, len(user))
+	for _, info := range user {
+		shadowed[info.Name] = info
 	}
+	for _, info := range builtinTemplates {
+		if u, ok := shadowed[info.Name]; ok {
+			info = u
+			delete(shadowed, info.Name)
+		}
+		templates = append(templates, info)
+	}
+	for _, info := range user {
+		if _, ok := shadowed[info.Name]; ok {
+			templates = append(templates, info)
+		}
+	}
+	return templates
+}
+
+// listUserTemplates returns the valid templates in dir, sorted by name.
+// Files that cannot be read or parsed are skipped with a warning.
+func listUserTemplates(dir string) []TemplateInfo {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Warn("failed to read user templates directory", "path", dir, "error", err)
+		}
+		return nil
+	}
+
+	seen := make(map[string]bool, len(entries))
+	templates := make([]TemplateInfo, 0, len(entries))
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if seen[name] {
+			continue // name.yaml takes precedence over name.yml
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := readUserTemplate(name, path)
+		if err != nil {
+			logger.Warn("skipping invalid user template", "path", path, "error", err)
+			continue
+		}
+		seen[name] = true
+		templates = append(templates, info)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates
+}
+
+// readUserTemplate parses the template file at path and returns its
+// metadata. The description is the description field, or else a
+// "# description:" comment at the top of the file.
+func readUserTemplate(name, path string) (TemplateInfo, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // user template directory
+	if err != nil {
+		return TemplateInfo{}, fmt.Errorf("failed to read template: %w", err)
+	}
+	if _, err := parseTemplate(name, data); err != nil {
+		return TemplateInfo{}, err
+	}
+	var header userTemplateHeader
+	_ = yaml.Unmarshal(data, &header) // the document parsed above
+
+	description := strings.TrimSpace(header.Description)
+	if description == "" {
+		description = descriptionFromComment(data)
+	}
+	if description == "" {
+		description = "User-defined template"
+	}
+	return TemplateInfo{
+		Name:        name,
+		Description: description,
+		UseCase:     "User-defined template from " + path,
+		Path:        path,
+	}, nil
+}
+
+// descriptionFromComment returns the text of the "# description:" comment
+// among the comment lines at the top of data.
+func descriptionFromComment(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		if len(line) >= len(descriptionComment) && strings.EqualFold(line[:len(descriptionComment)], descriptionComment) {
+			return strings.TrimSpace(line[len(descriptionComment):])
+		}
+	}
+	return ""
+}
+
+// builtinTemplates describes the embedded templates.
+var builtinTemplates = []TemplateInfo{
+	{
+		Name:        TemplateResearch,
+		Description: "Optimized for academic and scholarly research with authoritative sources",
+		UseCase:     "Academic research, literature reviews, and scholarly inquiries requiring peer-reviewed sources",
+	},
+	{
+		Name:        TemplateCreative,
+		Description: "High-temperature creative configuration with streaming enabled",
+		UseCase:     "Creative writing, brainstorming, content generation, and exploratory queries",
+	},
+	{
+		Name:        TemplateNews,
+		Description: "News-focused configuration with reputable news sources and weekly recency filter",
+		UseCase:     "Current events tracking, news analysis, and recent developments research",
+	},
+	{
+		Name:        TemplateFullExample,
+		Description: "Comprehensive example showing all 29+ configuration options with detailed comments",
+		UseCase:     "Learning available options, creating custom configurations, and understanding configuration structure",
+	},
+}
+
+// UserTemplates returns the valid user-defined templates, sorted by name.
+func UserTemplates() []TemplateInfo {
+	return listUserTemplates(UserTemplatesDir())
 }
 
 // GetTemplateDescription returns detailed information about a specific template.
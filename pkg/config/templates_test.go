@@ -1,8 +1,14 @@
 package config
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
 	"sync"
 	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
 )
 
 // TestLoadTemplate_ValidTemplates tests loading all valid templates.
@@ -335,3 +341,98 @@ func BenchmarkListTemplates(b *testing.B) {
 		_ = ListTemplates()
 	}
 }
+
+// writeUserTemplates creates a templates directory with a valid template
+// described by a comment, one described by a field, an invalid one, and one
+// shadowing the research built-in.
+func writeUserTemplates(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"team.yaml":     "# Team defaults\n# description: Shared team settings\n\ndefaults:\n  model: sonar-pro\n",
+		"quick.yml":     "description: Fast answers\ndefaults:\n  model: sonar\n  max_tokens: 200\n",
+		"broken.yaml":   "defaults: [not, a, mapping\n",
+		"research.yaml": "# description: Our research setup\ndefaults:\n  model: sonar-reasoning-pro\n",
+		"notes.txt":     "not a template",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestListTemplates_UserTemplates(t *testing.T) {
+	t.Parallel()
+	dir := writeUserTemplates(t)
+
+	templates := listTemplates(dir)
+	got := make(map[string]TemplateInfo, len(templates))
+	var names []string
+	for _, tpl := range templates {
+		got[tpl.Name] = tpl
+		names = append(names, tpl.Name)
+	}
+	want := []string{TemplateResearch, TemplateCreative, TemplateNews, TemplateFullExample, "quick", "team"}
+	if !slices.Equal(names, want) {
+		t.Errorf("template names = %v, want %v", names, want)
+	}
+	if d := got["team"].Description; d != "Shared team settings" {
+		t.Errorf("team description = %q, want the comment", d)
+	}
+	if d := got["quick"].Description; d != "Fast answers" {
+		t.Errorf("quick description = %q, want the description field", d)
+	}
+	if r := got[TemplateResearch]; r.Description != "Our research setup" || r.Path == "" {
+		t.Errorf("research = %+v, want the user template shadowing the built-in", r)
+	}
+	if got[TemplateCreative].Path != "" {
+		t.Error("built-in template has a path")
+	}
+}
+
+func TestLoadTemplate_UserTemplates(t *testing.T) {
+	t.Parallel()
+	dir := writeUserTemplates(t)
+
+	tests := []struct {
+		name      string
+		template  string
+		wantModel string
+		wantErr   error
+	}{
+		{"user template", "team", "sonar-pro", nil},
+		{"yml extension", "quick", "sonar", nil},
+		{"shadows built-in", TemplateResearch, "sonar-reasoning-pro", nil},
+		{"built-in", TemplateCreative, "", nil},
+		{"invalid", "broken", "", clerrors.ErrTemplateInvalid},
+		{"missing", "nothing", "", clerrors.ErrTemplateNotFound},
+		{"path", "../team", "", clerrors.ErrTemplateNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg, err := loadTemplate(dir, tt.template)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("loadTemplate(%q) error = %v, want %v", tt.template, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadTemplate(%q) error = %v", tt.template, err)
+			}
+			if tt.wantModel != "" && cfg.Defaults.Model != tt.wantModel {
+				t.Errorf("model = %q, want %q", cfg.Defaults.Model, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestListTemplates_MissingUserDir(t *testing.T) {
+	t.Parallel()
+	if n := len(listTemplates(filepath.Join(t.TempDir(), "absent"))); n != len(builtinTemplates) {
+		t.Errorf("listTemplates() returned %d templates, want the %d built-ins", n, len(builtinTemplates))
+	}
+}
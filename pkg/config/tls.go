@@ -0,0 +1,118 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// insecureTLSWarning is printed once per run while
+// api.tls.insecure_skip_verify is enabled.
+const insecureTLSWarning = "WARNING: api.tls.insecure_skip_verify is enabled: the certificate of the API " +
+	"is not verified, so the API key and every answer can be intercepted. Use it for debugging only."
+
+var (
+	insecureTLSOnce sync.Once
+	// insecureTLSOutput receives the insecure warning; tests replace it.
+	insecureTLSOutput io.Writer = os.Stderr
+)
+
+// LoadTLSConfig returns the TLS configuration of the API connection set by
+// t, or nil when t sets no option so that the system roots apply. The CA
+// file adds to the system roots. A disabled verification prints a warning
+// on stderr, once per run. Errors are ValidationErrors naming the option.
+func LoadTLSConfig(t TLSConfig) (*tls.Config, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	if errs := checkTLS(t); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		data, err := os.ReadFile(t.CAFile) // #nosec G304 -- path from the user's config
+		if err != nil {
+			return nil, clerrors.NewValidationErrorSafe("api.tls.ca_file", t.CAFile, "cannot read file: "+err.Error())
+		}
+		pool.AppendCertsFromPEM(data)
+		conf.RootCAs = pool
+	}
+	if t.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, clerrors.NewValidationErrorSafe("api.tls.client_cert", t.ClientCert, err.Error())
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if t.InsecureSkipVerify {
+		conf.InsecureSkipVerify = true //nolint:gosec // opted in with api.tls.insecure_skip_verify
+		insecureTLSOnce.Do(func() {
+			_, _ = fmt.Fprintln(insecureTLSOutput, insecureTLSWarning)
+		})
+	}
+	return conf, nil
+}
+
+// checkTLS returns the problems of the TLS options of t: files that cannot
+// be read or hold no PEM data, a CA file without certificates, a client
+// certificate without its key or the reverse, and a key that does not
+// match the certificate.
+func checkTLS(t TLSConfig) []*clerrors.ValidationError {
+	var errs []*clerrors.ValidationError
+	if t.CAFile != "" {
+		if blocks, err := readPEMFile("api.tls.ca_file", t.CAFile); err != nil {
+			errs = append(errs, err)
+		} else if !x509.NewCertPool().AppendCertsFromPEM(blocks) {
+			errs = append(errs, clerrors.NewValidationErrorSafe("api.tls.ca_file", t.CAFile,
+				"holds no valid PEM certificate"))
+		}
+	}
+
+	switch {
+	case t.ClientCert != "" && t.ClientKey == "":
+		errs = append(errs, clerrors.NewValidationErrorSafe("api.tls.client_key", "",
+			"must be set with api.tls.client_cert"))
+	case t.ClientKey != "" && t.ClientCert == "":
+		errs = append(errs, clerrors.NewValidationErrorSafe("api.tls.client_cert", "",
+			"must be set with api.tls.client_key"))
+	case t.ClientCert != "":
+		_, certErr := readPEMFile("api.tls.client_cert", t.ClientCert)
+		_, keyErr := readPEMFile("api.tls.client_key", t.ClientKey)
+		for _, err := range []*clerrors.ValidationError{certErr, keyErr} {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if certErr == nil && keyErr == nil {
+			if _, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey); err != nil {
+				errs = append(errs, clerrors.NewValidationErrorSafe("api.tls.client_cert", t.ClientCert,
+					"invalid key pair: "+err.Error()))
+			}
+		}
+	}
+	return errs
+}
+
+// readPEMFile returns the content of path when it holds at least one PEM
+// block, or a ValidationError for field.
+func readPEMFile(field, path string) ([]byte, *clerrors.ValidationError) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path from the user's config
+	if err != nil {
+		return nil, clerrors.NewValidationErrorSafe(field, path, "cannot read file: "+err.Error())
+	}
+	if block, _ := pem.Decode(data); block == nil {
+		return nil, clerrors.NewValidationErrorSafe(field, path, "holds no PEM data")
+	}
+	return data, nil
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/retry"
+)
+
+// writePEM writes one PEM block of type typ to a file in dir and returns its path.
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeClientPair writes a self-signed client certificate and its key to
+// dir and returns their paths.
+func writeClientPair(t *testing.T, dir, prefix string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, dir, prefix+"cert.pem", "CERTIFICATE", der),
+		writePEM(t, dir, prefix+"key.pem", "PRIVATE KEY", keyDER)
+}
+
+// get requests url through a transport configured with conf.
+func get(conf *tls.Config, url string) error {
+	client := &http.Client{Transport: retry.Timeouts{TLS: conf}.Transport(), Timeout: 5 * time.Second}
+	resp, err := client.Get(url) //nolint:noctx // test request
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestLoadTLSConfig_Unset(t *testing.T) {
+	conf, err := LoadTLSConfig(TLSConfig{})
+	if conf != nil || err != nil {
+		t.Errorf("LoadTLSConfig() = %v, %v; want nil, nil", conf, err)
+	}
+}
+
+func TestLoadTLSConfig_CAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(srv.Close)
+
+	if err := get(nil, srv.URL); err == nil {
+		t.Fatal("the test server should not be trusted by the system roots")
+	}
+
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+	conf, err := LoadTLSConfig(TLSConfig{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("LoadTLSConfig() error = %v", err)
+	}
+	if err := get(conf, srv.URL); err != nil {
+		t.Errorf("request with api.tls.ca_file error = %v", err)
+	}
+}
+
+func TestLoadTLSConfig_ClientCert(t *testing.T) {
+	var presented int
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		presented = len(r.TLS.PeerCertificates)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	cert, key := writeClientPair(t, dir, "client-")
+	conf, err := LoadTLSConfig(TLSConfig{
+		CAFile:     writePEM(t, dir, "ca.pem", "CERTIFICATE", srv.Certificate().Raw),
+		ClientCert: cert,
+		ClientKey:  key,
+	})
+	if err != nil {
+		t.Fatalf("LoadTLSConfig() error = %v", err)
+	}
+	if err := get(conf, srv.URL); err != nil {
+		t.Fatalf("request with a client certificate error = %v", err)
+	}
+	if presented != 1 {
+		t.Errorf("server saw %d client certificates, want 1", presented)
+	}
+}
+
+func TestLoadTLSConfig_InsecureWarnsOnce(t *testing.T) {
+	var out bytes.Buffer
+	oldOut := insecureTLSOutput
+	t.Cleanup(func() { insecureTLSOutput, insecureTLSOnce = oldOut, sync.Once{} })
+	insecureTLSOutput, insecureTLSOnce = &out, sync.Once{}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(srv.Close)
+	for range 2 {
+		conf, err := LoadTLSConfig(TLSConfig{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("LoadTLSConfig() error = %v", err)
+		}
+		if err := get(conf, srv.URL); err != nil {
+			t.Errorf("request without verification error = %v", err)
+		}
+	}
+	if got := strings.Count(out.String(), "insecure_skip_verify"); got != 1 {
+		t.Errorf("warning printed %d times, want once:\n%s", got, out.String())
+	}
+}
+
+func TestValidator_TLS(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := writeClientPair(t, dir, "a-")
+	otherCert, _ := writeClientPair(t, dir, "b-")
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		tls       TLSConfig
+		wantField string
+	}{
+		{name: "valid", tls: TLSConfig{CAFile: cert, ClientCert: cert, ClientKey: key}},
+		{name: "missing CA file", tls: TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}, wantField: "api.tls.ca_file"},
+		{name: "CA file not PEM", tls: TLSConfig{CAFile: notPEM}, wantField: "api.tls.ca_file"},
+		{name: "CA file holds a key", tls: TLSConfig{CAFile: key}, wantField: "api.tls.ca_file"},
+		{name: "cert without key", tls: TLSConfig{ClientCert: cert}, wantField: "api.tls.client_key"},
+		{name: "key without cert", tls: TLSConfig{ClientKey: key}, wantField: "api.tls.client_cert"},
+		{name: "key not PEM", tls: TLSConfig{ClientCert: cert, ClientKey: notPEM}, wantField: "api.tls.client_key"},
+		{name: "mismatched pair", tls: TLSConfig{ClientCert: otherCert, ClientKey: key}, wantField: "api.tls.client_cert"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfigData()
+			cfg.API.TLS = tt.tls
+			err := NewValidator().Validate(cfg)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantField) {
+				t.Errorf("Validate() error = %v, want one for %s", err, tt.wantField)
+			}
+			if _, err := LoadTLSConfig(tt.tls); err == nil {
+				t.Error("LoadTLSConfig() should reject the options")
+			}
+		})
+	}
+}
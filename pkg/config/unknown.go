@@ -0,0 +1,203 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownKey is a key of a config file that no option reads, such as a
+// misspelled option name, which unmarshaling would silently drop.
+type UnknownKey struct {
+	// File is the config file the key is written in.
+	File string
+	// Line is the line of the key in File.
+	Line int
+	// Path is the key in dot notation, e.g. defaults.temprature or
+	// profiles.research.defaults.temprature.
+	Path string
+	// Key is the unknown key itself, e.g. temprature.
+	Key string
+	// Profile names the profile the key is in, if any.
+	Profile string
+	// Suggestion is the closest valid key, or "" when none is close.
+	Suggestion string
+}
+
+// Message describes the key as "unknown option 'temprature' in profile
+// 'research' (did you mean 'temperature'?)".
+func (u UnknownKey) Message() string {
+	msg := fmt.Sprintf("unknown option '%s'", u.Key)
+	if u.Profile != "" {
+		msg += fmt.Sprintf(" in profile '%s'", u.Profile)
+	}
+	if u.Suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean '%s'?)", u.Suggestion)
+	}
+	return msg
+}
+
+// unknownFieldPattern matches the errors of a yaml.v3 decoder in KnownFields
+// mode for keys that are not struct fields.
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (.+) not found in type (\S+)$`)
+
+// FindUnknownKeys returns the keys of the config file at path that no option
+// reads, in file order. The file is decoded a second time with unknown
+// fields rejected; other decoding problems are left to the loader and the
+// validator. The top-level include: key is known.
+func FindUnknownKeys(path string) ([]UnknownKey, error) {
+	content, err := os.ReadFile(path) //nolint:gosec // config paths are chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return findUnknownKeys(path, content)
+}
+
+func findUnknownKeys(path string, content []byte) ([]UnknownKey, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	paths := keyPaths(&root)
+
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	err := dec.Decode(NewConfigData())
+	var typeErr *yaml.TypeError
+	if err == nil || errors.Is(err, io.EOF) || !errors.As(err, &typeErr) {
+		return nil, nil //nolint:nilerr // only unknown fields are reported here
+	}
+
+	var unknown []UnknownKey
+	for _, msg := range typeErr.Errors {
+		m := unknownFieldPattern.FindStringSubmatch(msg)
+		if m == nil {
+			continue
+		}
+		line, _ := strconv.Atoi(m[1])
+		key := m[2]
+		keyPath := paths[keyPosition{line, key}]
+		if keyPath == "" {
+			keyPath = key
+		}
+		if keyPath == IncludeKey {
+			continue
+		}
+		u := UnknownKey{File: path, Line: line, Path: keyPath, Key: key}
+		parent := strings.TrimSuffix(strings.TrimSuffix(keyPath, key), dotSeparator)
+		section := parent
+		if rest, ok := strings.CutPrefix(parent, "profiles"+dotSeparator); ok {
+			u.Profile, section, _ = strings.Cut(rest, dotSeparator)
+		}
+		u.Suggestion = suggestKnownKey(key, parent, section, m[3])
+		unknown = append(unknown, u)
+	}
+	return unknown, nil
+}
+
+// keyPosition identifies a mapping key by its line and name.
+type keyPosition struct {
+	line int
+	key  string
+}
+
+// keyPaths maps every mapping key of root to its path in dot notation.
+func keyPaths(root *yaml.Node) map[keyPosition]string {
+	paths := make(map[keyPosition]string)
+	var walk func(n *yaml.Node, prefix string)
+	walk = func(n *yaml.Node, prefix string) {
+		switch n.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, c := range n.Content {
+				walk(c, prefix)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k := n.Content[i]
+				p := k.Value
+				if prefix != "" {
+					p = prefix + dotSeparator + k.Value
+				}
+				paths[keyPosition{k.Line, k.Value}] = p
+				walk(n.Content[i+1], p)
+			}
+		case yaml.ScalarNode, yaml.AliasNode:
+		}
+	}
+	walk(root, "")
+	return paths
+}
+
+// suggestKnownKey returns the valid key closest to key, which is unknown in
+// the struct named typeName at parent. section is parent within its profile,
+// if any. Option names of the metadata registry are suggested in the option
+// sections, field names elsewhere; a key closer to an option of another
+// section is suggested in dot notation.
+func suggestKnownKey(key, parent, section, typeName string) string {
+	var candidates []string
+	registry := NewMetadataRegistry()
+	if opts := registry.GetBySection(section); section != "" && len(opts) > 0 {
+		for _, opt := range opts {
+			candidates = append(candidates, opt.Name)
+		}
+	} else {
+		candidates = yamlFieldNames(typeName)
+		if parent == "" {
+			candidates = append(candidates, IncludeKey)
+		}
+	}
+	if s := SuggestEnum(key, candidates, keySuggestMaxDistance); s != "" {
+		return s
+	}
+	if section != "" {
+		if s := SuggestKeys(key); len(s) > 0 {
+			return s[0]
+		}
+	}
+	return ""
+}
+
+// yamlFieldNames returns the YAML keys of the config struct named typeName,
+// e.g. "config.Profile", as yaml.v3 names it in decoding errors.
+func yamlFieldNames(typeName string) []string {
+	t := findStructType(reflect.TypeFor[ConfigData](), typeName, map[reflect.Type]bool{})
+	if t == nil {
+		return nil
+	}
+	names := make([]string, 0, t.NumField())
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// findStructType returns the struct type named name reachable from t.
+func findStructType(t reflect.Type, name string, seen map[reflect.Type]bool) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	if t.String() == name {
+		return t
+	}
+	seen[t] = true
+	for i := range t.NumField() {
+		if found := findStructType(t.Field(i).Type, name, seen); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindUnknownKeys(t *testing.T) {
+	t.Parallel()
+
+	content := `version: 2
+include: shared.yaml
+defaults:
+  temprature: 0.5
+  model: sonar
+serch:
+  mode: web
+output:
+  retun_images: true
+  stream: true
+profiles:
+  research:
+    name: research
+    descripton: deep dives
+    defaults:
+      modle: sonar-pro
+    search:
+      recency: week
+models:
+  aliases:
+    fast: sonar
+`
+	want := []UnknownKey{
+		{Line: 4, Path: "defaults.temprature", Key: "temprature", Suggestion: "temperature"},
+		{Line: 6, Path: "serch", Key: "serch", Suggestion: "search"},
+		{Line: 9, Path: "output.retun_images", Key: "retun_images", Suggestion: "return_images"},
+		{Line: 14, Path: "profiles.research.descripton", Key: "descripton", Profile: "research",
+			Suggestion: "description"},
+		{Line: 16, Path: "profiles.research.defaults.modle", Key: "modle", Profile: "research",
+			Suggestion: "model"},
+	}
+
+	got, err := findUnknownKeys("config.yaml", []byte(content))
+	if err != nil {
+		t.Fatalf("findUnknownKeys() error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("findUnknownKeys() = %+v, want %d keys", got, len(want))
+	}
+	for i, w := range want {
+		w.File = "config.yaml"
+		if got[i] != w {
+			t.Errorf("key %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+}
+
+func TestFindUnknownKeys_None(t *testing.T) {
+	t.Parallel()
+
+	for name, content := range map[string]string{
+		"empty":      "",
+		"comments":   "# nothing here\n",
+		"valid":      "defaults:\n  model: sonar\nsearch:\n  mode: web\n",
+		"type error": "defaults:\n  max_tokens: many\n",
+	} {
+		got, err := findUnknownKeys("config.yaml", []byte(content))
+		if err != nil || len(got) != 0 {
+			t.Errorf("%s: findUnknownKeys() = %+v, %v, want none", name, got, err)
+		}
+	}
+}
+
+// The annotated config written by `pplx config init` must only use known keys.
+func TestFindUnknownKeys_AnnotatedConfig(t *testing.T) {
+	t.Parallel()
+
+	content, err := GenerateAnnotatedConfig(NewConfigData(), DefaultAnnotationOptions())
+	if err != nil {
+		t.Fatalf("GenerateAnnotatedConfig() error = %v", err)
+	}
+	got, err := findUnknownKeys("config.yaml", []byte(content))
+	if err != nil || len(got) != 0 {
+		t.Errorf("annotated config has unknown keys: %+v, %v", got, err)
+	}
+}
+
+func TestUnknownKey_Message(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key  UnknownKey
+		want string
+	}{
+		{UnknownKey{Key: "temprature", Suggestion: "temperature"},
+			"unknown option 'temprature' (did you mean 'temperature'?)"},
+		{UnknownKey{Key: "modle", Profile: "research", Suggestion: "model"},
+			"unknown option 'modle' in profile 'research' (did you mean 'model'?)"},
+		{UnknownKey{Key: "zzzzzzzz"}, "unknown option 'zzzzzzzz'"},
+	}
+	for _, tt := range tests {
+		if got := tt.key.Message(); got != tt.want {
+			t.Errorf("Message() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestLoader_UnknownKeysIncludes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared.yaml")
+	main := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(shared, []byte("search:\n  recenncy: week\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(main, []byte("include: shared.yaml\ndefaults:\n  modell: sonar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := NewLoader()
+	if err := loader.LoadFrom(main); err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+	got := loader.UnknownKeys()
+	if len(got) != 2 {
+		t.Fatalf("UnknownKeys() = %+v, want 2", got)
+	}
+	if got[0].File != main || got[0].Path != "defaults.modell" || got[0].Suggestion != "model" {
+		t.Errorf("main file key = %+v", got[0])
+	}
+	if got[1].Path != "search.recenncy" || got[1].Suggestion != "recency" || got[1].Line != 2 {
+		t.Errorf("included file key = %+v", got[1])
+	}
+}
@@ -1,13 +1,19 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sgaunet/pplx/pkg/citations"
 	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/output/color"
+	"github.com/sgaunet/pplx/pkg/security"
+	"github.com/sgaunet/pplx/pkg/validation"
 )
 
 const (
@@ -15,21 +21,62 @@ const (
 	maxPenalty     = 2.0
 	// enumSuggestMaxDistance is the maximum edit distance for enum suggestions.
 	enumSuggestMaxDistance = 2
+	// maxRetriesLimit bounds api.max_retries to keep worst-case latency reasonable.
+	maxRetriesLimit = 10
+	// maxTopK is the largest accepted defaults.top_k.
+	maxTopK = 100
+	// maxAutoContinue is the largest accepted output.auto_continue.
+	maxAutoContinue = 10
+	// deepResearchModelMarker identifies models that accept reasoning_effort.
+	deepResearchModelMarker = "deep-research"
 )
 
-// Validator validates configuration data.
+// Rules of the cross-field checks. They are also listed in the
+// ValidationRules of the options they apply to.
+const (
+	ruleAfterBeforeDate      = "Must be before before_date"
+	ruleLastUpdatedOrder     = "Must be before last_updated_before"
+	ruleSingleResponseFormat = "Cannot be combined with response_format_regex"
+	ruleDeepResearchOnly     = "Only valid with a deep-research model"
+	ruleLocationPair         = "location_lat and location_lon must be set together"
+)
+
+// Validator validates configuration data. It checks every field instead of
+// stopping at the first problem, so a single run reports all violations.
 type Validator struct {
 	errors clerrors.ValidationErrors
+	rules  map[string]string
+	// keys holds the keys the validated config sets, when known; see WithKeys.
+	keys map[string]string
 }
 
 // NewValidator creates a new validator.
 func NewValidator() *Validator {
+	rules := make(map[string]string)
+	for _, opt := range NewMetadataRegistry().GetAll() {
+		if len(opt.ValidationRules) > 0 {
+			rules[opt.Section+"."+opt.Name] = opt.ValidationRules[0]
+		}
+	}
 	return &Validator{
 		errors: make(clerrors.ValidationErrors, 0),
+		rules:  rules,
 	}
 }
 
-// Validate validates the configuration data.
+// WithKeys gives the validator the keys the validated config sets, in dot
+// notation, as Loader.Origins returns them, and returns it. Rules about keys
+// being set together need them: a zero coordinate, on the equator or the
+// prime meridian, cannot be told from an unset one in ConfigData, so without
+// keys location_lat and location_lon are not checked as a pair.
+func (v *Validator) WithKeys(keys map[string]string) *Validator {
+	v.keys = keys
+	return v
+}
+
+// Validate validates the configuration data. It returns a
+// clerrors.ValidationErrors holding one entry per violation, each naming the
+// field, its value, and the rule it breaks.
 func (v *Validator) Validate(data *ConfigData) error {
 	v.errors = make(clerrors.ValidationErrors, 0)
 
@@ -41,17 +88,27 @@ func (v *Validator) Validate(data *ConfigData) error {
 
 	// Validate output config
 	v.validateOutput(&data.Output)
+	v.validateCacheTTL(data.Output.CacheTTL)
 
 	// Validate API config
 	v.validateAPI(&data.API)
 
+	// Validate rules spanning several fields
+	v.validateCrossField(data)
+
+	// Validate model aliases
+	v.validateAliases("models.aliases", data.Models.Aliases)
+
+	// Validate prompt templates
+	v.validatePrompts(data.Prompts)
+
 	// Validate profiles
 	v.validateProfiles(data.Profiles)
 
 	// Validate active profile exists
 	if data.ActiveProfile != "" {
 		if _, ok := data.Profiles[data.ActiveProfile]; !ok && data.ActiveProfile != DefaultProfileName {
-			v.addError("active_profile", fmt.Sprintf("profile '%s' does not exist", data.ActiveProfile))
+			v.addError("active_profile", data.ActiveProfile, fmt.Sprintf("profile '%s' does not exist", data.ActiveProfile))
 		}
 	}
 
@@ -70,14 +127,14 @@ func (v *Validator) Errors() clerrors.ValidationErrors {
 // validateRange checks if a value is within a range (0 to maxVal) and adds an error if not.
 func (v *Validator) validateRange(field string, value, maxVal float64) {
 	if value < 0 || value > maxVal {
-		v.addError(field, fmt.Sprintf("%g is out of range (must be between 0.0 and %.1f)", value, maxVal))
+		v.addError(field, strconv.FormatFloat(value, 'g', -1, 64), fmt.Sprintf("%g is out of range (must be between 0.0 and %.1f)", value, maxVal))
 	}
 }
 
 // validatePositive checks if an integer value is positive and adds an error if not.
 func (v *Validator) validatePositive(field string, value int) {
 	if value < 0 {
-		v.addError(field, "must be a positive integer")
+		v.addError(field, strconv.Itoa(value), "must be a positive integer")
 	}
 }
 
@@ -86,9 +143,36 @@ func (v *Validator) validateDefaults(defaults *DefaultsConfig) {
 	v.validateRange("defaults.temperature", defaults.Temperature, maxTemperature)
 	v.validatePositive("defaults.max_tokens", defaults.MaxTokens)
 	v.validatePositive("defaults.top_k", defaults.TopK)
+	if defaults.TopK > maxTopK {
+		v.addError("defaults.top_k", strconv.Itoa(defaults.TopK), fmt.Sprintf("must be between 0 and %d", maxTopK))
+	}
 	v.validateRange("defaults.top_p", defaults.TopP, 1.0)
 	v.validateRange("defaults.frequency_penalty", defaults.FrequencyPenalty, maxPenalty)
 	v.validateRange("defaults.presence_penalty", defaults.PresencePenalty, maxPenalty)
+	if err := validation.ValidateLanguage(defaults.Language); err != nil {
+		v.addError("defaults.language", defaults.Language, err.Error())
+	}
+	v.validateDuration("defaults.timeout", defaults.Timeout)
+	for i, model := range defaults.ModelFallbacks {
+		if strings.TrimSpace(model) == "" {
+			v.addError(fmt.Sprintf("defaults.model_fallbacks[%d]", i), model, "must not be empty")
+		}
+	}
+}
+
+// validateDuration checks that value, when set, is a duration string that
+// time.ParseDuration accepts and is not negative.
+func (v *Validator) validateDuration(field, value string) {
+	if value == "" {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	switch {
+	case err != nil:
+		v.addError(field, value, fmt.Sprintf("%q is not a duration (use a number and a unit, such as 30s or 5m)", value))
+	case d < 0:
+		v.addError(field, value, "must not be negative")
+	}
 }
 
 // validateSearch validates search configuration.
@@ -97,7 +181,38 @@ func (v *Validator) validateSearch(search *SearchConfig) {
 	v.validateSearchMode(search.Mode)
 	v.validateSearchContextSize(search.ContextSize)
 	v.validateCoordinates(search.LocationLat, search.LocationLon)
+	if err := validation.ValidateCountry(search.LocationCountry); err != nil {
+		v.addError("search.location_country", search.LocationCountry, err.Error())
+	}
 	v.validateSearchDates(search)
+	v.validateDomains(search)
+}
+
+// validateDomains checks the syntax of the domain lists and that no domain
+// is both included and excluded.
+func (v *Validator) validateDomains(search *SearchConfig) {
+	valid := true
+	for _, list := range []struct {
+		field   string
+		domains []string
+	}{
+		{"search.domains", search.Domains},
+		{"search.exclude_domains", search.ExcludeDomains},
+	} {
+		for _, domain := range list.domains {
+			domain = strings.TrimPrefix(strings.TrimSpace(domain), validation.ExcludePrefix)
+			if err := validation.ValidateDomain(domain); err != nil {
+				v.addRuleError(list.field, domain, "Host names only: no scheme or path", err.Error())
+				valid = false
+			}
+		}
+	}
+	if !valid {
+		return
+	}
+	if _, err := validation.DomainFilter(search.Domains, search.ExcludeDomains); err != nil {
+		v.addError("search.exclude_domains", strings.Join(search.ExcludeDomains, ","), err.Error())
+	}
 }
 
 // validateSearchRecency validates the recency field.
@@ -111,7 +226,7 @@ func (v *Validator) validateSearchRecency(recency string) {
 		if suggestion := SuggestEnum(recency, valid, enumSuggestMaxDistance); suggestion != "" {
 			msg += fmt.Sprintf(`. Did you mean %q?`, suggestion)
 		}
-		v.addError("search.recency", msg)
+		v.addError("search.recency", recency, msg)
 	}
 }
 
@@ -126,7 +241,7 @@ func (v *Validator) validateSearchMode(mode string) {
 		if suggestion := SuggestEnum(mode, valid, enumSuggestMaxDistance); suggestion != "" {
 			msg += fmt.Sprintf(`. Did you mean %q?`, suggestion)
 		}
-		v.addError("search.mode", msg)
+		v.addError("search.mode", mode, msg)
 	}
 }
 
@@ -141,51 +256,92 @@ func (v *Validator) validateSearchContextSize(contextSize string) {
 		if suggestion := SuggestEnum(contextSize, valid, enumSuggestMaxDistance); suggestion != "" {
 			msg += fmt.Sprintf(`. Did you mean %q?`, suggestion)
 		}
-		v.addError("search.context_size", msg)
+		v.addError("search.context_size", contextSize, msg)
 	}
 }
 
 // validateCoordinates validates location coordinates.
 func (v *Validator) validateCoordinates(lat, lon float64) {
 	if lat < -90 || lat > 90 {
-		v.addError("search.location_lat", "must be between -90 and 90")
+		v.addError("search.location_lat", strconv.FormatFloat(lat, 'g', -1, 64), "must be between -90 and 90")
 	}
 	if lon < -180 || lon > 180 {
-		v.addError("search.location_lon", "must be between -180 and 180")
+		v.addError("search.location_lon", strconv.FormatFloat(lon, 'g', -1, 64), "must be between -180 and 180")
 	}
 }
 
-// isValidDate reports whether s is a valid date in either YYYY-MM-DD (ISO 8601)
-// or MM/DD/YYYY format.
+// isValidDate reports whether s is a date filter accepted by
+// validation.ParseDate.
 func isValidDate(s string) bool {
-	if _, err := time.Parse("2006-01-02", s); err == nil {
-		return true
-	}
-	_, err := time.Parse("01/02/2006", s)
-	return err == nil
+	_, ok := parseDate(s)
+	return ok
+}
+
+// parseDate parses s with validation.ParseDate, resolving relative dates
+// against today. Ambiguity notes are left to the commands that use the date.
+func parseDate(s string) (time.Time, bool) {
+	t, _, err := validation.ParseDate(s, time.Now())
+	return t, err == nil
 }
 
 // validateSearchDates validates date format fields.
-// Accepted formats: YYYY-MM-DD (ISO 8601) and MM/DD/YYYY.
+// Accepted formats are those of validation.ParseDate.
 func (v *Validator) validateSearchDates(search *SearchConfig) {
-	const dateErrMsg = "must be in YYYY-MM-DD or MM/DD/YYYY format"
+	const dateErrMsg = "must be " + validation.DateFormats
 
 	if search.AfterDate != "" && !isValidDate(search.AfterDate) {
-		v.addError("search.after_date", dateErrMsg)
+		v.addError("search.after_date", search.AfterDate, dateErrMsg)
 	}
 	if search.BeforeDate != "" && !isValidDate(search.BeforeDate) {
-		v.addError("search.before_date", dateErrMsg)
+		v.addError("search.before_date", search.BeforeDate, dateErrMsg)
 	}
 	if search.LastUpdatedAfter != "" && !isValidDate(search.LastUpdatedAfter) {
-		v.addError("search.last_updated_after", dateErrMsg)
+		v.addError("search.last_updated_after", search.LastUpdatedAfter, dateErrMsg)
 	}
 	if search.LastUpdatedBefore != "" && !isValidDate(search.LastUpdatedBefore) {
-		v.addError("search.last_updated_before", dateErrMsg)
+		v.addError("search.last_updated_before", search.LastUpdatedBefore, dateErrMsg)
+	}
+}
+
+// validateStreaming validates the stream style and rate.
+func (v *Validator) validateStreaming(cfg *OutputConfig) {
+	if _, err := output.ParseStreamStyle(cfg.StreamStyle); err != nil {
+		v.addError("output.stream_style", cfg.StreamStyle, fmt.Sprintf("%q is not valid (must be one of: %s)",
+			cfg.StreamStyle, strings.Join(output.StreamStyles(), ", ")))
+	}
+	if cfg.StreamCPS < 0 {
+		v.addError("output.stream_cps", strconv.Itoa(cfg.StreamCPS), "must not be negative")
 	}
 }
 
 // validateOutput validates output configuration.
 func (v *Validator) validateOutput(output *OutputConfig) {
+	if _, err := citations.ParseStyle(output.Citations); err != nil {
+		v.addError("output.citations", output.Citations, fmt.Sprintf("%q is not valid (must be one of: %s)",
+			output.Citations, strings.Join(citations.Styles(), ", ")))
+	}
+	v.validatePositive("output.citation_max_per_domain", output.CitationMaxPerDomain)
+	for _, domain := range output.CitationExcludeDomains {
+		if err := validation.ValidateDomain(strings.TrimSpace(domain)); err != nil {
+			v.addRuleError("output.citation_exclude_domains", domain, "Host names only: no scheme or path", err.Error())
+		}
+	}
+	if _, err := validation.ResolveImageConflict("", false, output.ImageConflictPolicy); err != nil {
+		v.addError("output.image_conflict_policy", output.ImageConflictPolicy, err.Error())
+	}
+	if output.AutoContinue < 0 || output.AutoContinue > maxAutoContinue {
+		v.addError("output.auto_continue", strconv.Itoa(output.AutoContinue),
+			fmt.Sprintf("must be between 0 and %d", maxAutoContinue))
+	}
+	v.validateStreaming(output)
+	if _, err := color.ParseTheme(output.Theme); err != nil {
+		v.addError("output.theme", output.Theme, fmt.Sprintf("%q is not valid (must be one of: %s)",
+			output.Theme, strings.Join(color.Themes(), ", ")))
+	}
+	if output.ConfigBackups < 0 {
+		v.addError("output.config_backups", strconv.Itoa(output.ConfigBackups), "must not be negative")
+	}
+
 	// Validate reasoning effort
 	if output.ReasoningEffort == "" {
 		return
@@ -196,25 +352,137 @@ func (v *Validator) validateOutput(output *OutputConfig) {
 		if suggestion := SuggestEnum(output.ReasoningEffort, valid, enumSuggestMaxDistance); suggestion != "" {
 			msg += fmt.Sprintf(`. Did you mean %q?`, suggestion)
 		}
-		v.addError("output.reasoning_effort", msg)
+		v.addError("output.reasoning_effort", output.ReasoningEffort, msg)
+	}
+}
+
+// validateCacheTTL validates the response cache lifetime.
+func (v *Validator) validateCacheTTL(ttl time.Duration) {
+	if ttl < 0 {
+		v.addRuleError("output.cache_ttl", ttl.String(), "Must be positive", "must be positive")
 	}
 }
 
 // validateAPI validates API configuration.
 func (v *Validator) validateAPI(api *APIConfig) {
-	// Validate base URL format if provided
-	if api.BaseURL != "" {
-		u, err := url.Parse(api.BaseURL)
-		if err != nil {
-			v.addError("api.base_url", fmt.Sprintf("invalid URL format: %v", err))
-			return
+	// Validate the base and proxy URL formats if provided
+	for _, u := range []struct{ key, value string }{
+		{"api.base_url", api.BaseURL},
+		{"api.proxy_url", api.ProxyURL},
+	} {
+		var verr *clerrors.ValidationError
+		if err := ValidateHTTPURL(u.key, u.value); errors.As(err, &verr) {
+			v.addError(u.key, u.value, verr.Message)
 		}
-		if u.Scheme != "http" && u.Scheme != "https" {
-			v.addError("api.base_url", "must use http or https scheme")
-			return
+	}
+
+	if api.MaxRetries < 0 || api.MaxRetries > maxRetriesLimit {
+		v.addError("api.max_retries", strconv.Itoa(api.MaxRetries), fmt.Sprintf("must be between 0 and %d", maxRetriesLimit))
+	}
+	if api.RetryBackoff < 0 {
+		v.addError("api.retry_backoff", api.RetryBackoff.String(), "must be positive")
+	}
+	for _, err := range checkTLS(api.TLS) {
+		v.addError(err.Field, err.Value, err.Message)
+	}
+	v.validatePositive("api.requests_per_minute", api.RequestsPerMinute)
+	v.validatePositive("api.burst", api.Burst)
+	v.validatePositive("api.per_request_max_tokens", api.PerRequestMaxTokens)
+	if api.DailyBudgetUSD < 0 {
+		v.addError("api.daily_budget_usd", strconv.FormatFloat(api.DailyBudgetUSD, 'g', -1, 64), "must not be negative")
+	}
+	for _, t := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"api.connect_timeout", api.ConnectTimeout},
+		{"api.response_header_timeout", api.ResponseHeaderTimeout},
+		{"api.total_timeout", api.TotalTimeout},
+		{"api.stream_idle_timeout", api.StreamIdleTimeout},
+	} {
+		if t.value < 0 {
+			v.addError(t.key, t.value.String(), "must not be negative")
 		}
-		if u.Host == "" {
-			v.addError("api.base_url", "must specify a host")
+	}
+	if api.TotalTimeout > 0 && api.ConnectTimeout > api.TotalTimeout {
+		v.addError("api.connect_timeout", api.ConnectTimeout.String(),
+			"must not exceed api.total_timeout ("+api.TotalTimeout.String()+")")
+	}
+}
+
+// validateCrossField checks constraints between fields that are each valid
+// on their own.
+func (v *Validator) validateCrossField(data *ConfigData) {
+	search := &data.Search
+	v.validateDateOrder("search.after_date", search.AfterDate, search.BeforeDate,
+		ruleAfterBeforeDate, "must be before search.before_date")
+	v.validateDateOrder("search.last_updated_after", search.LastUpdatedAfter, search.LastUpdatedBefore,
+		ruleLastUpdatedOrder, "must be before search.last_updated_before")
+
+	// Zero is a valid coordinate, so the pair is checked on the keys set.
+	_, latSet := v.keys["search.location_lat"]
+	_, lonSet := v.keys["search.location_lon"]
+	if latSet != lonSet {
+		field, value := "search.location_lat", search.LocationLat
+		if lonSet {
+			field, value = "search.location_lon", search.LocationLon
+		}
+		v.addRuleError(field, strconv.FormatFloat(value, 'g', -1, 64), ruleLocationPair,
+			"location_lat and location_lon must be set together")
+	}
+
+	output := &data.Output
+	if output.ResponseFormatJSONSchema != "" && output.ResponseFormatRegex != "" {
+		v.addRuleError("output.response_format_json_schema", output.ResponseFormatJSONSchema,
+			ruleSingleResponseFormat, "cannot be set together with output.response_format_regex")
+	}
+
+	// The model may still be chosen on the command line, so reasoning_effort
+	// is only checked against a model set in the same config.
+	model := data.Defaults.Model
+	if output.ReasoningEffort != "" && model != "" && !strings.Contains(model, deepResearchModelMarker) {
+		v.addRuleError("output.reasoning_effort", output.ReasoningEffort, ruleDeepResearchOnly,
+			fmt.Sprintf("is only supported by deep-research models, not %q", model))
+	}
+}
+
+// validateDateOrder checks that the after date is strictly before the before
+// date when both are set and well formed.
+func (v *Validator) validateDateOrder(field, after, before, rule, message string) {
+	if after == "" || before == "" {
+		return
+	}
+	afterTime, okAfter := parseDate(after)
+	beforeTime, okBefore := parseDate(before)
+	if okAfter && okBefore && !afterTime.Before(beforeTime) {
+		v.addRuleError(field, after, rule, fmt.Sprintf("%s (%s)", message, before))
+	}
+}
+
+// validateAliases checks that every model alias has a target. Whether the
+// target is a known model is left to the commands, which only warn about
+// unknown models.
+func (v *Validator) validateAliases(field string, aliases map[string]string) {
+	rule := v.rules["models.aliases"]
+	for name, target := range aliases {
+		if strings.TrimSpace(name) == "" {
+			v.addRuleError(field, target, rule, "alias names must not be empty")
+		} else if strings.TrimSpace(target) == "" {
+			v.addRuleError(field, name, rule, fmt.Sprintf("alias %q must name a model", name))
+		}
+	}
+}
+
+// validatePrompts checks that every prompt template has text that parses.
+func (v *Validator) validatePrompts(prompts map[string]*PromptTemplate) {
+	for name, prompt := range prompts {
+		field := "prompts." + name
+		if prompt == nil || (prompt.System == "" && prompt.User == "") {
+			v.addError(field, "", "must define system or user text")
+			continue
+		}
+		if _, err := prompt.Variables(name); err != nil {
+			v.addError(field, "", err.Error())
 		}
 	}
 }
@@ -222,20 +490,29 @@ func (v *Validator) validateAPI(api *APIConfig) {
 // validateProfiles validates all profiles.
 func (v *Validator) validateProfiles(profiles map[string]*Profile) {
 	profileNamePattern := regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	pm := &ProfileManager{data: &ConfigData{Profiles: profiles}}
 
 	for name, profile := range profiles {
 		// Validate profile name
 		if !profileNamePattern.MatchString(name) {
-			v.addError("profiles."+name,
+			v.addError("profiles."+name, name,
 				"profile name must contain only alphanumeric characters, hyphens, and underscores")
 		}
 
 		// Validate profile fields
 		if profile.Name != name {
-			v.addError(fmt.Sprintf("profiles.%s.name", name),
+			v.addError(fmt.Sprintf("profiles.%s.name", name), profile.Name,
 				fmt.Sprintf("profile name mismatch: key is '%s' but name field is '%s'", name, profile.Name))
 		}
 
+		v.validateAliases(fmt.Sprintf("profiles.%s.models.aliases", name), profile.Models.Aliases)
+
+		if extendsProfile(profile) {
+			if err := pm.ValidateExtends(name); err != nil {
+				v.addError(fmt.Sprintf("profiles.%s.extends", name), profile.Extends, err.Error())
+			}
+		}
+
 		// Profile field values use pointer-based types (ProfileDefaults, ProfileSearch,
 		// ProfileOutput) which are incompatible with the concrete config validators.
 		// Field-level validation happens after the profile is merged into ConfigData
@@ -243,11 +520,18 @@ func (v *Validator) validateProfiles(profiles map[string]*Profile) {
 	}
 }
 
-// addError adds a validation error.
-func (v *Validator) addError(field, message string) {
+// addError adds a validation error for field, citing the first validation
+// rule the metadata registry lists for it.
+func (v *Validator) addError(field, value, message string) {
+	v.addRuleError(field, value, v.rules[field], message)
+}
+
+// addRuleError adds a validation error that breaks rule.
+func (v *Validator) addRuleError(field, value, rule, message string) {
 	v.errors = append(v.errors, clerrors.ValidationError{
 		Field:   field,
-		Value:   "", // Config validation doesn't use Value field
+		Value:   security.SanitizeString(value),
 		Message: message,
+		Rule:    rule,
 	})
 }
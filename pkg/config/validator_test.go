@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sgaunet/pplx/pkg/clerrors"
 )
@@ -10,7 +13,7 @@ import (
 func TestValidatorValidConfig(t *testing.T) {
 	cfg := &ConfigData{
 		Defaults: DefaultsConfig{
-			Model:            "sonar",
+			Model:            "sonar-deep-research",
 			Temperature:      0.5,
 			MaxTokens:        1000,
 			TopK:             10,
@@ -18,6 +21,7 @@ func TestValidatorValidConfig(t *testing.T) {
 			FrequencyPenalty: 0.5,
 			PresencePenalty:  0.5,
 			Timeout:          "30s",
+			Language:         "fr",
 		},
 		Search: SearchConfig{
 			Recency:     "week",
@@ -54,6 +58,33 @@ func TestValidatorInvalidTemperature(t *testing.T) {
 	}
 }
 
+func TestValidatorInvalidLanguage(t *testing.T) {
+	cfg := &ConfigData{
+		Defaults: DefaultsConfig{
+			Language: "french", // Invalid: not an ISO 639-1 code
+		},
+	}
+
+	validator := NewValidator()
+	err := validator.Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "defaults.language") {
+		t.Errorf("Validate() error = %v, want an error on defaults.language", err)
+	}
+}
+
+func TestValidatorEmptyModelFallback(t *testing.T) {
+	cfg := &ConfigData{
+		Defaults: DefaultsConfig{
+			ModelFallbacks: []string{"sonar", " "},
+		},
+	}
+
+	err := NewValidator().Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "defaults.model_fallbacks[1]") {
+		t.Errorf("Validate() error = %v, want an error on defaults.model_fallbacks[1]", err)
+	}
+}
+
 func TestValidatorInvalidRecency(t *testing.T) {
 	cfg := &ConfigData{
 		Search: SearchConfig{
@@ -68,6 +99,85 @@ func TestValidatorInvalidRecency(t *testing.T) {
 	}
 }
 
+func TestValidatorInvalidCitationStyle(t *testing.T) {
+	cfg := &ConfigData{
+		Output: OutputConfig{
+			Citations: "endnote",
+		},
+	}
+
+	validator := NewValidator()
+	err := validator.Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "output.citations") {
+		t.Errorf("Expected validation error for output.citations, got %v", err)
+	}
+}
+
+func TestValidatorStreaming(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  OutputConfig
+		wantKey string
+	}{
+		{name: "smooth", output: OutputConfig{StreamStyle: "smooth", StreamCPS: 200}},
+		{name: "unknown style", output: OutputConfig{StreamStyle: "typewriter"}, wantKey: "output.stream_style"},
+		{name: "negative rate", output: OutputConfig{StreamCPS: -1}, wantKey: "output.stream_cps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewValidator().Validate(&ConfigData{Output: tt.output})
+			if tt.wantKey == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantKey) {
+				t.Errorf("Validate() error = %v, want an error on %s", err, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestValidatorTheme(t *testing.T) {
+	for _, theme := range []string{"", "default", "high-contrast", "High-Contrast"} {
+		if err := NewValidator().Validate(&ConfigData{Output: OutputConfig{Theme: theme}}); err != nil {
+			t.Errorf("Validate() of theme %q error = %v, want none", theme, err)
+		}
+	}
+	err := NewValidator().Validate(&ConfigData{Output: OutputConfig{Theme: "solarized"}})
+	if err == nil || !strings.Contains(err.Error(), "output.theme") {
+		t.Errorf("Validate() error = %v, want an error on output.theme", err)
+	}
+}
+
+func TestValidatorCitationFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  OutputConfig
+		wantKey string
+	}{
+		{name: "valid", output: OutputConfig{CitationMaxPerDomain: 2, CitationExcludeDomains: []string{"pinterest.com"}}},
+		{name: "negative max", output: OutputConfig{CitationMaxPerDomain: -1}, wantKey: "output.citation_max_per_domain"},
+		{name: "scheme", output: OutputConfig{CitationExcludeDomains: []string{"https://quora.com"}},
+			wantKey: "output.citation_exclude_domains"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewValidator().Validate(&ConfigData{Output: tt.output})
+			if tt.wantKey == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantKey) {
+				t.Errorf("Validate() error = %v, want an error on %s", err, tt.wantKey)
+			}
+		})
+	}
+}
+
 func TestValidatorInvalidMode(t *testing.T) {
 	cfg := &ConfigData{
 		Search: SearchConfig{
@@ -166,6 +276,92 @@ func TestValidatorInvalidAPIURL(t *testing.T) {
 	}
 }
 
+func TestValidatorAPITimeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		api     APIConfig
+		wantKey string
+	}{
+		{"valid", APIConfig{ConnectTimeout: 5 * time.Second, TotalTimeout: 10 * time.Minute}, ""},
+		{"negative connect", APIConfig{ConnectTimeout: -time.Second}, "api.connect_timeout"},
+		{"negative header", APIConfig{ResponseHeaderTimeout: -time.Second}, "api.response_header_timeout"},
+		{"negative total", APIConfig{TotalTimeout: -time.Second}, "api.total_timeout"},
+		{"negative stream idle", APIConfig{StreamIdleTimeout: -time.Second}, "api.stream_idle_timeout"},
+		{"connect above total", APIConfig{ConnectTimeout: time.Minute, TotalTimeout: time.Second}, "api.connect_timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewValidator().Validate(&ConfigData{API: tt.api})
+			if tt.wantKey == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantKey) {
+				t.Errorf("err = %v, want it to mention %s", err, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestValidatorSearchDomains(t *testing.T) {
+	tests := []struct {
+		name    string
+		search  SearchConfig
+		wantKey string
+	}{
+		{"valid", SearchConfig{Domains: []string{"go.dev"}, ExcludeDomains: []string{"pinterest.com"}}, ""},
+		{"legacy exclusion in domains", SearchConfig{Domains: []string{"go.dev", "-pinterest.com"}}, ""},
+		{"scheme", SearchConfig{Domains: []string{"https://go.dev"}}, "search.domains"},
+		{"path", SearchConfig{ExcludeDomains: []string{"quora.com/answers"}}, "search.exclude_domains"},
+		{"overlap", SearchConfig{Domains: []string{"go.dev"}, ExcludeDomains: []string{"GO.dev"}}, "search.exclude_domains"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewValidator().Validate(&ConfigData{Search: tt.search})
+			if tt.wantKey == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantKey) {
+				t.Errorf("err = %v, want it to mention %s", err, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestValidatorModelAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ConfigData
+		wantKey string
+	}{
+		{"valid", ConfigData{Models: ModelsConfig{Aliases: map[string]string{"fast": "sonar"}}}, ""},
+		{"empty target", ConfigData{Models: ModelsConfig{Aliases: map[string]string{"fast": ""}}}, `alias "fast"`},
+		{"empty name", ConfigData{Models: ModelsConfig{Aliases: map[string]string{"": "sonar"}}}, "models.aliases"},
+		{"profile", ConfigData{Profiles: map[string]*Profile{
+			"work": {Name: "work", Models: ModelsConfig{Aliases: map[string]string{"deep": " "}}},
+		}}, "profiles.work.models.aliases"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewValidator().Validate(&tt.cfg)
+			if tt.wantKey == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantKey) {
+				t.Errorf("err = %v, want it to mention %s", err, tt.wantKey)
+			}
+		})
+	}
+}
+
 func TestValidatorAPIURLWithoutHost(t *testing.T) {
 	cfg := &ConfigData{
 		API: APIConfig{
@@ -1052,3 +1248,207 @@ func TestValidator_AllDateFieldsAcceptISO(t *testing.T) {
 func hasSubstr(s, substr string) bool {
 	return strings.Contains(s, substr)
 }
+
+func TestValidator_ProfileExtends(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles map[string]*Profile
+		wantErr  string
+	}{
+		{
+			name: "valid chain",
+			profiles: map[string]*Profile{
+				"base":     {Name: "base"},
+				"research": {Name: "research", Extends: "base"},
+			},
+		},
+		{
+			name:     "missing parent",
+			profiles: map[string]*Profile{"research": {Name: "research", Extends: "ghost"}},
+			wantErr:  "profiles.research.extends",
+		},
+		{
+			name: "cycle",
+			profiles: map[string]*Profile{
+				"a": {Name: "a", Extends: "b"},
+				"b": {Name: "b", Extends: "a"},
+			},
+			wantErr: "inheritance cycle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewValidator().Validate(&ConfigData{Profiles: tt.profiles})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidator_CrossFieldRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   ConfigData
+		keys  map[string]string
+		field string
+		rule  string
+	}{
+		{
+			name:  "after_date not before before_date",
+			cfg:   ConfigData{Search: SearchConfig{AfterDate: "2024-06-01", BeforeDate: "01/01/2024"}},
+			field: "search.after_date",
+			rule:  ruleAfterBeforeDate,
+		},
+		{
+			name:  "equal last_updated dates",
+			cfg:   ConfigData{Search: SearchConfig{LastUpdatedAfter: "2024-01-01", LastUpdatedBefore: "2024-01-01"}},
+			field: "search.last_updated_after",
+			rule:  ruleLastUpdatedOrder,
+		},
+		{
+			name:  "both response formats",
+			cfg:   ConfigData{Output: OutputConfig{ResponseFormatJSONSchema: `{"type":"object"}`, ResponseFormatRegex: "^a$"}},
+			field: "output.response_format_json_schema",
+			rule:  ruleSingleResponseFormat,
+		},
+		{
+			name: "reasoning_effort without deep-research model",
+			cfg: ConfigData{
+				Defaults: DefaultsConfig{Model: "sonar-pro"},
+				Output:   OutputConfig{ReasoningEffort: "high"},
+			},
+			field: "output.reasoning_effort",
+			rule:  ruleDeepResearchOnly,
+		},
+		{
+			name:  "latitude without longitude",
+			cfg:   ConfigData{Search: SearchConfig{LocationLat: 48.85}},
+			keys:  map[string]string{"search.location_lat": "config.yaml"},
+			field: "search.location_lat",
+			rule:  ruleLocationPair,
+		},
+		{
+			name:  "longitude without latitude",
+			cfg:   ConfigData{Search: SearchConfig{LocationLon: 2.35}},
+			keys:  map[string]string{"search.location_lon": "config.yaml"},
+			field: "search.location_lon",
+			rule:  ruleLocationPair,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewValidator().WithKeys(tt.keys)
+			if err := v.Validate(&tt.cfg); err == nil {
+				t.Fatal("expected a validation error")
+			}
+			errs := v.Errors()
+			if len(errs) != 1 || errs[0].Field != tt.field || errs[0].Rule != tt.rule {
+				t.Errorf("errors = %+v, want one %s error with rule %q", errs, tt.field, tt.rule)
+			}
+		})
+	}
+}
+
+func TestValidator_CrossFieldRulesPass(t *testing.T) {
+	cfg := &ConfigData{
+		Defaults: DefaultsConfig{Model: "sonar-deep-research"},
+		Search:   SearchConfig{AfterDate: "2024-01-01", BeforeDate: "06/01/2024", LocationLat: 48.85, LocationLon: 2.35},
+		Output:   OutputConfig{ReasoningEffort: "high", ResponseFormatRegex: "^a$"},
+	}
+	if err := NewValidator().Validate(cfg); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	// Without a model in the config, the model may come from the command line.
+	cfg = &ConfigData{Output: OutputConfig{ReasoningEffort: "high"}}
+	if err := NewValidator().Validate(cfg); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	// Zero is a coordinate: Greenwich is on the prime meridian.
+	cfg = &ConfigData{Search: SearchConfig{LocationLat: 51.48, LocationLon: 0}}
+	keys := map[string]string{"search.location_lat": "config.yaml", "search.location_lon": "config.yaml"}
+	if err := NewValidator().WithKeys(keys).Validate(cfg); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	// Without the keys set, a zero coordinate is not taken for a missing one.
+	if err := NewValidator().Validate(cfg); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestValidator_CollectsAllViolationsWithRules(t *testing.T) {
+	cfg := &ConfigData{
+		Defaults: DefaultsConfig{Temperature: 3, TopK: 500},
+		Search:   SearchConfig{Recency: "dayly", AfterDate: "2024-13-01"},
+	}
+	v := NewValidator()
+	if err := v.Validate(cfg); err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	registry := NewMetadataRegistry()
+	errs := v.Errors()
+	if len(errs) != 4 {
+		t.Fatalf("got %d errors, want 4: %v", len(errs), errs)
+	}
+	for _, e := range errs {
+		if e.Value == "" {
+			t.Errorf("%s: missing value", e.Field)
+		}
+		opt, err := registry.GetOption(e.Field)
+		if err != nil {
+			t.Fatalf("%s: %v", e.Field, err)
+		}
+		if e.Rule != opt.ValidationRules[0] {
+			t.Errorf("%s: rule = %q, want %q", e.Field, e.Rule, opt.ValidationRules[0])
+		}
+	}
+}
+
+// TestValidatorInvalidFixtures loads each config of testdata/invalid and
+// checks that validation reports its broken field.
+func TestValidatorInvalidFixtures(t *testing.T) {
+	tests := []struct {
+		file      string
+		wantField string
+		wantMsg   string
+	}{
+		{"timeout", "defaults.timeout", "is not a duration"},
+		{"negative_timeout", "defaults.timeout", "must not be negative"},
+		{"base_url", "api.base_url", "must use http or https scheme"},
+		{"base_url_scheme", "api.base_url", "must use http or https scheme"},
+		{"country", "search.location_country", "ISO 3166-1 alpha-2"},
+		{"latitude", "search.location_lat", "must be between -90 and 90"},
+		{"longitude", "search.location_lon", "must be between -180 and 180"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			loader := NewLoader()
+			if err := loader.LoadFrom(filepath.Join("testdata", "invalid", tt.file+".yaml")); err != nil {
+				t.Fatalf("LoadFrom() error = %v", err)
+			}
+
+			err := NewValidator().Validate(loader.Data())
+			var verrs clerrors.ValidationErrors
+			if !errors.As(err, &verrs) || len(verrs) != 1 {
+				t.Fatalf("Validate() error = %v, want one violation", err)
+			}
+			if verrs[0].Field != tt.wantField || !strings.Contains(verrs[0].Message, tt.wantMsg) {
+				t.Errorf("violation = %s: %s, want %s: %s", verrs[0].Field, verrs[0].Message, tt.wantField, tt.wantMsg)
+			}
+			if verrs[0].Rule == "" {
+				t.Errorf("violation of %s cites no rule from the metadata registry", verrs[0].Field)
+			}
+		})
+	}
+}
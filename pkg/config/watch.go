@@ -0,0 +1,95 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sgaunet/pplx/pkg/logger"
+)
+
+// DefaultWatchDebounce is how long Watch waits after the last change event
+// before reloading. Editors often save with several events (truncate, write,
+// rename, chmod); the debounce turns them into a single reload.
+const DefaultWatchDebounce = 250 * time.Millisecond
+
+// Watch watches the config file at path until ctx is cancelled. After changes
+// settle, the file is re-read, environment variables are expanded and the
+// result is validated; valid configs are passed to onChange. A file that
+// cannot be read or fails validation is logged and skipped, so whatever the
+// caller applied last stays active.
+//
+// The parent directory is watched rather than the file itself so that
+// editors which save by writing a new file and renaming it over the old one
+// keep being followed.
+func (l *Loader) Watch(ctx context.Context, path string, onChange func(*ConfigData)) error {
+	path = filepath.Clean(path)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	defer func() { _ = watcher.Close() }()
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", filepath.Dir(path), err)
+	}
+
+	debounce := l.watchDebounce
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != path || event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Warn("config watcher error", "path", path, "error", err)
+		case <-timer.C:
+			data, err := reloadConfig(path)
+			if err != nil {
+				logger.Error("config reload rejected, keeping previous configuration", "path", path, "error", err)
+				continue
+			}
+			logger.Info("config reloaded", "path", path)
+			onChange(data)
+		}
+	}
+}
+
+// reloadConfig reads and validates the config file at path with a fresh loader.
+func reloadConfig(path string) (*ConfigData, error) {
+	loader := NewLoader()
+	if err := loader.LoadFrom(path); err != nil {
+		return nil, err
+	}
+	data := loader.Data()
+	ExpandEnvVars(data)
+	// PPLX_* variables keep overriding the file across reloads.
+	if _, err := applyEnvOverrides(data, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	if err := NewValidator().Validate(data); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// watchRecorder collects the configs passed to a Watch callback.
+type watchRecorder struct {
+	mu      sync.Mutex
+	configs []*ConfigData
+	changed chan struct{}
+}
+
+func newWatchRecorder() *watchRecorder {
+	return &watchRecorder{changed: make(chan struct{}, 16)}
+}
+
+func (r *watchRecorder) onChange(cfg *ConfigData) {
+	r.mu.Lock()
+	r.configs = append(r.configs, cfg)
+	r.mu.Unlock()
+	r.changed <- struct{}{}
+}
+
+func (r *watchRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.configs)
+}
+
+func (r *watchRecorder) last() *ConfigData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.configs[len(r.configs)-1]
+}
+
+// startWatch writes initial to a temp config file and watches it with a short
+// debounce. The watcher stops when the test ends.
+func startWatch(t *testing.T, initial string) (string, *watchRecorder) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, initial)
+
+	loader := NewLoader()
+	loader.watchDebounce = 50 * time.Millisecond
+	rec := newWatchRecorder()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- loader.Watch(ctx, path, rec.onChange) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Watch() error: %v", err)
+		}
+	})
+
+	// Give the watcher time to register before the test modifies the file.
+	time.Sleep(50 * time.Millisecond)
+	return path, rec
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+}
+
+func waitForChange(t *testing.T, rec *watchRecorder) {
+	t.Helper()
+	select {
+	case <-rec.changed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for config reload")
+	}
+}
+
+func TestWatch_ReloadsOnChange(t *testing.T) {
+	path, rec := startWatch(t, "defaults:\n  model: sonar\n")
+
+	writeConfig(t, path, "defaults:\n  model: sonar-pro\n")
+	waitForChange(t, rec)
+
+	if got := rec.last().Defaults.Model; got != "sonar-pro" {
+		t.Errorf("reloaded model = %q, want sonar-pro", got)
+	}
+}
+
+func TestWatch_DebouncesRapidWrites(t *testing.T) {
+	path, rec := startWatch(t, "defaults:\n  model: sonar\n")
+
+	for _, model := range []string{"a", "b", "c", "sonar-reasoning"} {
+		writeConfig(t, path, "defaults:\n  model: "+model+"\n")
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitForChange(t, rec)
+
+	// Wait past another debounce window to catch any extra reloads.
+	time.Sleep(200 * time.Millisecond)
+	if n := rec.count(); n != 1 {
+		t.Errorf("got %d reloads for a burst of writes, want 1", n)
+	}
+	if got := rec.last().Defaults.Model; got != "sonar-reasoning" {
+		t.Errorf("reloaded model = %q, want the last write", got)
+	}
+}
+
+func TestWatch_RejectsInvalidConfig(t *testing.T) {
+	path, rec := startWatch(t, "defaults:\n  model: sonar\n")
+
+	// Out of range temperature fails validation.
+	writeConfig(t, path, "defaults:\n  model: bad\n  temperature: 5\n")
+	time.Sleep(300 * time.Millisecond)
+	if n := rec.count(); n != 0 {
+		t.Fatalf("invalid config was applied (%d reloads)", n)
+	}
+
+	// A later valid write is picked up again.
+	writeConfig(t, path, "defaults:\n  model: sonar-pro\n")
+	waitForChange(t, rec)
+	if got := rec.last().Defaults.Model; got != "sonar-pro" {
+		t.Errorf("reloaded model = %q, want sonar-pro", got)
+	}
+}
+
+func TestWatch_FollowsAtomicRename(t *testing.T) {
+	path, rec := startWatch(t, "defaults:\n  model: sonar\n")
+
+	// Editors commonly save by writing a temp file and renaming it over the original.
+	tmp := path + ".tmp"
+	writeConfig(t, tmp, "defaults:\n  model: sonar-pro\n")
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Rename() error: %v", err)
+	}
+	waitForChange(t, rec)
+
+	if got := rec.last().Defaults.Model; got != "sonar-pro" {
+		t.Errorf("reloaded model = %q, want sonar-pro", got)
+	}
+}
+
+func TestWatch_MissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "config.yaml")
+	if err := NewLoader().Watch(context.Background(), path, func(*ConfigData) {}); err == nil {
+		t.Fatal("expected error watching a missing directory")
+	}
+}
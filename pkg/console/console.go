@@ -3,21 +3,23 @@ package console
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 
-	markdown "github.com/MichaelMure/go-term-markdown"
 	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/citations"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/searches"
 )
 
 // DefaultLineLength is the default line length for markdown rendering.
-const DefaultLineLength = 80
+const DefaultLineLength = render.DefaultWidth
 
 // DefaultLeftMargin is the default left margin for markdown rendering.
-const DefaultLeftMargin = 6
+const DefaultLeftMargin = render.DefaultLeftMargin
 
 // Input prompts the user for input and returns the entered text.
 func Input(label string) (string, error) {
@@ -45,14 +47,68 @@ func Input(label string) (string, error) {
 
 // RenderAsMarkdown renders the response content as markdown.
 func RenderAsMarkdown(pplxResponse *perplexity.CompletionResponse, output io.Writer) error {
-	result := markdown.Render(pplxResponse.GetLastContent(), DefaultLineLength, DefaultLeftMargin)
-	_, err := fmt.Fprintln(output, string(result))
+	return RenderContent(pplxResponse, output, render.New(render.ModeMarkdown, render.Options{}))
+}
+
+// RenderContent renders the response content with r, linking citation
+// markers to the response's search results.
+func RenderContent(pplxResponse *perplexity.CompletionResponse, output io.Writer, r *render.Renderer) error {
+	return renderContent(pplxResponse, output, r, citations.StyleList)
+}
+
+// renderContent renders the response content with r in the given citation
+// style. The footnote style appends the references section to the content.
+func renderContent(pplxResponse *perplexity.CompletionResponse, output io.Writer, r *render.Renderer,
+	style citations.Style,
+) error {
+	return renderText(pplxResponse, firstContent(pplxResponse), output, r, style)
+}
+
+// renderText renders content, one answer of the response, like renderContent.
+func renderText(pplxResponse *perplexity.CompletionResponse, content string, output io.Writer, r *render.Renderer,
+	style citations.Style,
+) error {
+	PrepareRenderer(r, pplxResponse, style)
+	if style == citations.StyleFootnote {
+		if refs := citations.Footnotes(content, citations.FromResponse(pplxResponse)); refs != "" {
+			content = strings.TrimRight(content, "\n") + "\n\n" + refs
+		}
+	}
+	_, err := fmt.Fprintln(output, r.Render(content))
 	if err != nil {
-		return fmt.Errorf("error writing markdown to output: %w", err)
+		return fmt.Errorf("error writing %s content to output: %w", r.Mode(), err)
 	}
 	return nil
 }
 
+// firstContent returns the answer of the first choice of the response, or ""
+// when it has none.
+func firstContent(pplxResponse *perplexity.CompletionResponse) string {
+	content, _ := output.FirstContent(pplxResponse)
+	return content
+}
+
+// PrepareRenderer links r's citation markers to the response's sources and
+// makes r apply style to the answer text it renders.
+func PrepareRenderer(r *render.Renderer, pplxResponse *perplexity.CompletionResponse, style citations.Style) {
+	r.SetCitations(CitationURLs(pplxResponse))
+	sources := citations.FromResponse(pplxResponse)
+	r.SetRewrite(func(content string) string {
+		return citations.Rewrite(content, style, sources)
+	})
+}
+
+// CitationURLs returns the URLs of the response's sources in marker order,
+// falling back to the deprecated citations list for older responses.
+func CitationURLs(pplxResponse *perplexity.CompletionResponse) []string {
+	sources := citations.FromResponse(pplxResponse)
+	urls := make([]string, len(sources))
+	for i, src := range sources {
+		urls[i] = src.URL
+	}
+	return urls
+}
+
 // RenderCitations renders the citations from the response.
 func RenderCitations(pplxResponse *perplexity.CompletionResponse, output io.Writer) error {
 	searchResults := pplxResponse.GetSearchResults()
@@ -60,6 +116,8 @@ func RenderCitations(pplxResponse *perplexity.CompletionResponse, output io.Writ
 		return nil
 	}
 
+	// URLs a citations.Filter grouped beneath an entry are listed under it.
+	grouped := citations.Grouped(pplxResponse.ID)
 	for i, sr := range searchResults {
 		dateInfo := ""
 		if sr.Date != nil {
@@ -71,6 +129,14 @@ func RenderCitations(pplxResponse *perplexity.CompletionResponse, output io.Writ
 		if err != nil {
 			return fmt.Errorf("error writing search results to output: %w", err)
 		}
+		if len(grouped) != len(searchResults) {
+			continue
+		}
+		for _, u := range grouped[i] {
+			if _, err := fmt.Fprintf(output, "    %s\n", u); err != nil {
+				return fmt.Errorf("error writing search results to output: %w", err)
+			}
+		}
 	}
 	return nil
 }
@@ -97,30 +163,46 @@ func RenderImages(pplxResponse *perplexity.CompletionResponse, output io.Writer)
 	return nil
 }
 
-// RenderRelatedQuestions renders the related questions from the response.
-// Currently not implemented as the Perplexity API does not provide related questions functionality.
-// This function exists for future compatibility when the API feature becomes available.
-func RenderRelatedQuestions(_ *perplexity.CompletionResponse, _ io.Writer) error {
-	// NOTE: Related questions functionality is not available in the current Perplexity API.
-	// Implementation will be added when the API supports this feature.
-	//
-	// Expected implementation when available:
-	// related := pplxResponse.GetRelatedQuestions()
-	// if len(related) == 0 {
-	// 	return nil
-	// }
-	//
-	// _, err := fmt.Fprintf(output, "\n❓ Related Questions:\n")
-	// if err != nil {
-	// 	return fmt.Errorf("error writing related questions header to output: %w", err)
-	// }
-	//
-	// for i, question := range related {
-	// 	_, err := fmt.Fprintf(output, "%d. %s\n", i+1, question)
-	// 	if err != nil {
-	// 		return fmt.Errorf("error writing related question to output: %w", err)
-	// 	}
-	// }
+// RenderRelatedQuestions renders the related questions from the response,
+// requested with --return-related, as a numbered list.
+func RenderRelatedQuestions(pplxResponse *perplexity.CompletionResponse, output io.Writer) error {
+	if pplxResponse.RelatedQuestions == nil || len(*pplxResponse.RelatedQuestions) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(output, "\n❓ Related Questions:\n")
+	if err != nil {
+		return fmt.Errorf("error writing related questions header to output: %w", err)
+	}
+
+	for i, question := range *pplxResponse.RelatedQuestions {
+		_, err := fmt.Fprintf(output, "%d. %s\n", i+1, question)
+		if err != nil {
+			return fmt.Errorf("error writing related question to output: %w", err)
+		}
+	}
+	return nil
+}
+
+// RenderSearchQueries renders the web searches the API ran for the response,
+// when it reported them.
+func RenderSearchQueries(pplxResponse *perplexity.CompletionResponse, output io.Writer) error {
+	queries := searches.Lookup(pplxResponse.ID)
+	if len(queries) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(output, "\n🔎 Searches performed:\n")
+	if err != nil {
+		return fmt.Errorf("error writing search queries header to output: %w", err)
+	}
+
+	for i, query := range queries {
+		_, err := fmt.Fprintf(output, "%d. %s\n", i+1, query)
+		if err != nil {
+			return fmt.Errorf("error writing search query to output: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -167,7 +249,7 @@ func NewStreamingRenderer(output io.Writer) *StreamingRenderer {
 // - Content shorter than lastContentLength: No-op (should never happen, but handled safely)
 // - First render (lastContentLength = 0): Prints entire content.
 func (sr *StreamingRenderer) RenderIncremental(pplxResponse *perplexity.CompletionResponse) error {
-	content := pplxResponse.GetLastContent()
+	content := firstContent(pplxResponse)
 	if content == "" {
 		return nil
 	}
@@ -194,7 +276,7 @@ func (sr *StreamingRenderer) RenderIncremental(pplxResponse *perplexity.Completi
 func RenderStreamingContent(pplxResponse *perplexity.CompletionResponse, output io.Writer) error {
 	// This function is kept for backward compatibility but shouldn't be used directly
 	// Use StreamingRenderer instead
-	content := pplxResponse.GetLastContent()
+	content := firstContent(pplxResponse)
 	if content == "" {
 		return nil
 	}
@@ -207,85 +289,72 @@ func RenderStreamingContent(pplxResponse *perplexity.CompletionResponse, output
 	return nil
 }
 
-// RenderJSON formats and outputs the response as JSON.
-func RenderJSON(pplxResponse *perplexity.CompletionResponse, output io.Writer) error {
-	result := buildJSONResponse(pplxResponse)
-
-	// Convert to JSON with indentation for readability
-	jsonData, err := json.MarshalIndent(result, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to format JSON output: %w", err)
-	}
-
-	_, err = fmt.Fprintln(output, string(jsonData))
-	if err != nil {
-		return fmt.Errorf("error writing JSON to output: %w", err)
-	}
-
-	return nil
-}
-
-// buildJSONResponse creates a structured JSON response from the Perplexity API response.
-// Handles optional fields with nil-safety checks to produce clean JSON output.
-//
-// Field handling pattern: Conditional inclusion of optional fields
-// Core fields (content, model, usage) are always included.
-// Optional fields (search_results, images, related_questions) are only included if:
-//   1. The pointer is non-nil (field was present in API response)
-//   2. The dereferenced slice has length > 0 (not empty)
-//
-// Rationale for this pattern:
-// - Cleaner JSON: Omitting empty optional fields makes output more readable
-// - API compatibility: Matches common API design where absent fields mean "not applicable"
-// - Backward compatibility: If API adds new optional fields, old clients work fine
-// - JSON marshaling: json.Marshal honors omitempty tags, but we do explicit checks
-//   for clarity and to ensure consistency regardless of struct tags
-//
-// The repeated pointer-check pattern (if field != nil && len(*field) > 0) is intentional:
-// - First check: Prevents nil pointer dereference
-// - Second check: Prevents including empty arrays in output
-// - Could be abstracted into a helper, but the 3 instances are simple enough
-//   that inline code is more readable than indirection
-func buildJSONResponse(pplxResponse *perplexity.CompletionResponse) map[string]any {
-	// Core fields: always included
-	result := map[string]any{
-		"content": pplxResponse.Choices[0].Message.Content,
-		"model":   pplxResponse.Model,
-		"usage":   pplxResponse.Usage,
-	}
-
-	// Optional field 1: Search results (citations with metadata)
-	// Only include if present and non-empty to keep JSON clean
-	if pplxResponse.SearchResults != nil && len(*pplxResponse.SearchResults) > 0 {
-		result["search_results"] = *pplxResponse.SearchResults
-	}
-
-	// Optional field 2: Images (URLs and dimensions)
-	if pplxResponse.Images != nil && len(*pplxResponse.Images) > 0 {
-		result["images"] = *pplxResponse.Images
-	}
-
-	// Optional field 3: Related questions (suggested follow-ups)
-	if pplxResponse.RelatedQuestions != nil && len(*pplxResponse.RelatedQuestions) > 0 {
-		result["related_questions"] = *pplxResponse.RelatedQuestions
-	}
-
-	return result
+// RenderJSON formats and outputs the response as the stable JSON document
+// defined by pkg/output, with every field included.
+func RenderJSON(pplxResponse *perplexity.CompletionResponse, out io.Writer) error {
+	return output.FromResponse(pplxResponse, 0).Write(out, nil) //nolint:wrapcheck // already descriptive
 }
 
 // RenderResponse renders the response in the specified format (JSON or console).
 // This is a unified rendering function that handles both output formats.
+// Console output uses markdown rendering; see RenderAnswer to choose the mode.
 func RenderResponse(pplxResponse *perplexity.CompletionResponse, output io.Writer, asJSON bool) error {
 	if asJSON {
 		return RenderJSON(pplxResponse, output)
 	}
+	return RenderAnswer(pplxResponse, output, render.New(render.ModeMarkdown, render.Options{}))
+}
 
-	// Render as console output (markdown + citations + images + related questions)
-	if err := RenderAsMarkdown(pplxResponse, output); err != nil {
+// RenderAnswer renders the response content with r followed by its metadata
+// (citations, images, related questions).
+func RenderAnswer(pplxResponse *perplexity.CompletionResponse, output io.Writer, r *render.Renderer) error {
+	return RenderAnswerWithCitations(pplxResponse, output, r, citations.StyleList)
+}
+
+// RenderAnswerWithCitations renders the response like RenderAnswer, presenting
+// citation markers in the given style.
+func RenderAnswerWithCitations(pplxResponse *perplexity.CompletionResponse, output io.Writer, r *render.Renderer,
+	style citations.Style,
+) error {
+	if err := renderContent(pplxResponse, output, r, style); err != nil {
 		return err
 	}
-	if err := RenderCitations(pplxResponse, output); err != nil {
-		return err
+	return RenderMetadataWithCitations(pplxResponse, output, style)
+}
+
+// RenderChoicesWithCitations renders every choice of the response, as
+// requested with n > 1, each under a divider naming it, followed by the
+// metadata once. Blank choices are rendered as such.
+func RenderChoicesWithCitations(pplxResponse *perplexity.CompletionResponse, output io.Writer, r *render.Renderer,
+	style citations.Style,
+) error {
+	for i, choice := range pplxResponse.Choices {
+		if _, err := fmt.Fprintf(output, "\n──── Choice %d of %d ────\n\n", i+1, len(pplxResponse.Choices)); err != nil {
+			return fmt.Errorf("error writing choice divider to output: %w", err)
+		}
+		if err := renderText(pplxResponse, choice.Message.Content, output, r, style); err != nil {
+			return err
+		}
+	}
+	return RenderMetadataWithCitations(pplxResponse, output, style)
+}
+
+// RenderMetadata renders citations, images, and related questions.
+// Used on its own after streaming, where the content has already been printed.
+func RenderMetadata(pplxResponse *perplexity.CompletionResponse, output io.Writer) error {
+	return RenderMetadataWithCitations(pplxResponse, output, citations.StyleList)
+}
+
+// RenderMetadataWithCitations renders images and related questions, preceded
+// by the citation list when style is citations.StyleList. The other styles
+// present sources within the answer text, or not at all.
+func RenderMetadataWithCitations(pplxResponse *perplexity.CompletionResponse, output io.Writer,
+	style citations.Style,
+) error {
+	if style == citations.StyleList {
+		if err := RenderCitations(pplxResponse, output); err != nil {
+			return err
+		}
 	}
 	if err := RenderImages(pplxResponse, output); err != nil {
 		return err
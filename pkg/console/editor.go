@@ -0,0 +1,183 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	// editorPrompt is shown before the first line of an entry, and
+	// editorContinuationPrompt before the next lines of a multi-line entry.
+	editorPrompt             = "> "
+	editorContinuationPrompt = "… "
+
+	// keyCtrlR is the key searching the history.
+	keyCtrlR = 0x12
+
+	// codeFence opens and closes a multi-line code block.
+	codeFence = "```"
+)
+
+// ErrNotTerminal is returned by NewEditor when standard input is not a
+// terminal.
+var ErrNotTerminal = errors.New("standard input is not a terminal")
+
+// Reader reads the entries of an interactive session, such as the
+// questions of pplx chat. An empty entry ends the session.
+type Reader interface {
+	// Read prints label and returns the next entry.
+	Read(label string) (string, error)
+}
+
+// PlainReader reads entries with Input, without line editing: lines are
+// joined until an empty one. It suits dumb terminals and piped input.
+type PlainReader struct{}
+
+// Read prints label and returns the lines entered up to an empty one.
+func (PlainReader) Read(label string) (string, error) {
+	return Input(label)
+}
+
+// Editor reads entries from a terminal with line editing: the arrow keys
+// move in the line and through the history, and Ctrl-R replaces the line
+// with the most recent history line containing its text, then with older
+// ones when pressed again.
+//
+// An entry is a single line, unless a line ends with a backslash, which
+// continues the entry on the next line, or opens a ``` code block, which
+// extends the entry to the closing ```. Pasted lines also continue the
+// entry, up to a line ended by typing Enter. An empty first line, Ctrl-D
+// or Ctrl-C end the session.
+type Editor struct {
+	term    *term.Terminal
+	history *History
+	// fd is the terminal put in raw mode while reading, or -1 to read
+	// without changing modes.
+	fd     int
+	search historySearch
+	hinted bool
+}
+
+// historySearch is the state of Ctrl-R.
+type historySearch struct {
+	active bool
+	query  string
+	// idx and match are the history entry the last search found, so that
+	// Ctrl-R on it looks further back.
+	idx   int
+	match string
+}
+
+// NewEditor returns an editor reading the terminal of standard input,
+// recording entered lines in history. Returns ErrNotTerminal when standard
+// input is not a terminal.
+func NewEditor(history *History) (*Editor, error) {
+	fd := int(os.Stdin.Fd()) //nolint:gosec // file descriptors fit in int
+	if !term.IsTerminal(fd) {
+		return nil, ErrNotTerminal
+	}
+	e := newEditor(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, history)
+	e.fd = fd
+	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil { //nolint:gosec // fits in int
+		_ = e.term.SetSize(width, height)
+	}
+	return e, nil
+}
+
+// newEditor returns an editor reading rw as a terminal already in raw mode.
+func newEditor(rw io.ReadWriter, history *History) *Editor {
+	e := &Editor{term: term.NewTerminal(rw, editorPrompt), history: history, fd: -1}
+	e.term.History = history
+	e.term.AutoCompleteCallback = e.complete
+	return e
+}
+
+// Read prints label and returns the next entry, or "" when the session
+// ends.
+func (e *Editor) Read(label string) (string, error) {
+	hint := ""
+	if !e.hinted {
+		hint = " (end a line with \\ or open a ``` block for multi-line input)"
+		e.hinted = true
+	}
+	if _, err := fmt.Fprintf(e.term, "%s:%s\n", label, hint); err != nil {
+		return "", fmt.Errorf("error writing prompt: %w", err)
+	}
+	if e.fd >= 0 {
+		state, err := term.MakeRaw(e.fd)
+		if err != nil {
+			return "", fmt.Errorf("failed to set the terminal to raw mode: %w", err)
+		}
+		defer func() { _ = term.Restore(e.fd, state) }()
+		e.term.SetBracketedPasteMode(true)
+		defer e.term.SetBracketedPasteMode(false)
+	}
+	return e.readEntry()
+}
+
+// readEntry reads the lines of an entry.
+func (e *Editor) readEntry() (string, error) {
+	e.term.SetPrompt(editorPrompt)
+	var lines []string
+	fenced := false
+	for {
+		line, err := e.term.ReadLine()
+		pasted := errors.Is(err, term.ErrPasteIndicator)
+		if errors.Is(err, io.EOF) {
+			return "", nil
+		}
+		if err != nil && !pasted {
+			return "", fmt.Errorf("error reading input: %w", err)
+		}
+		if len(lines) == 0 && !pasted && line == "" {
+			return "", nil
+		}
+
+		trimmed := strings.TrimSpace(line)
+		more := pasted
+		switch {
+		case fenced:
+			fenced = trimmed != codeFence
+		case strings.HasPrefix(trimmed, codeFence) && strings.Count(trimmed, codeFence) == 1:
+			fenced = true
+		case strings.HasSuffix(line, `\`):
+			line = strings.TrimSuffix(line, `\`)
+			more = true
+		}
+		lines = append(lines, line)
+		if !fenced && !more {
+			return strings.TrimRight(strings.Join(lines, "\n"), "\n"), nil
+		}
+		e.term.SetPrompt(editorContinuationPrompt)
+	}
+}
+
+// complete implements Ctrl-R: it replaces line with the next history entry
+// containing the search query, which is the line when the search starts.
+func (e *Editor) complete(line string, _ int, key rune) (string, int, bool) {
+	if key != keyCtrlR {
+		e.search.active = false
+		return "", 0, false
+	}
+	from := 0
+	if e.search.active && line == e.search.match {
+		from = e.search.idx + 1
+	} else {
+		e.search.query = line
+	}
+	idx, ok := e.history.Search(e.search.query, from)
+	if !ok {
+		return "", 0, false
+	}
+	match := e.history.At(idx)
+	e.search = historySearch{active: true, query: e.search.query, idx: idx, match: match}
+	return match, len(match), true
+}
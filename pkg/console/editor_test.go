@@ -0,0 +1,130 @@
+package console
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestEditor returns an editor reading input, with history holding
+// lines, oldest first.
+func newTestEditor(t *testing.T, input string, lines ...string) *Editor {
+	t.Helper()
+	history, err := LoadHistory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range lines {
+		history.Add(line)
+	}
+	return newEditor(struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(input), &bytes.Buffer{}}, history)
+}
+
+func TestEditor_Read(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"single line", "hello\r", "hello"},
+		{"empty line quits", "\r", ""},
+		{"ctrl-d quits", "\x04", ""},
+		{"ctrl-c quits", "first\\\r\x03", ""},
+		{"backslash continues", "line one\\\rline two\r", "line one\nline two"},
+		{"code block", "```go\rfmt.Println()\r\r```\rignored\r", "```go\nfmt.Println()\n\n```"},
+		{"inline code is one line", "use ```go``` here\r", "use ```go``` here"},
+		{"pasted lines", "\x1b[200~a\rb\x1b[201~\r", "a\nb"},
+		{"edited line", "helo\x1b[D\x1b[Dl\r", "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTestEditor(t, tt.input).Read("Ask")
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditor_HistorySearch(t *testing.T) {
+	history := []string{"go build ./...", "ls", "go test ./...", "git status"}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"latest match", "go\x12\r", "go test ./..."},
+		{"older match", "go\x12\x12\r", "go build ./..."},
+		{"no older match keeps the line", "go\x12\x12\x12\r", "go build ./..."},
+		{"case insensitive", "STATUS\x12\r", "git status"},
+		{"up arrow", "\x1b[A\x1b[A\r", "go test ./..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTestEditor(t, tt.input, history...).Read("Ask")
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistory_Persisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pplx", "chat_history")
+	h, err := LoadHistory(path)
+	if err != nil || h.Len() != 0 {
+		t.Fatalf("LoadHistory() of a missing file = %d entries, %v; want an empty history", h.Len(), err)
+	}
+	for _, line := range []string{"first", "", "second", "second", "third"} {
+		h.Add(line)
+	}
+
+	reloaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+	if reloaded.Len() != 3 || reloaded.At(0) != "third" || reloaded.At(2) != "first" {
+		t.Errorf("reloaded history = %d entries, latest %q; want first, second, third", reloaded.Len(), reloaded.At(0))
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != historyFilePerms {
+		t.Errorf("history file mode = %v, %v; want %v", info.Mode().Perm(), err, os.FileMode(historyFilePerms))
+	}
+}
+
+func TestHistory_Trimmed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat_history")
+	var content strings.Builder
+	for i := range 2 * MaxHistory {
+		content.WriteString(strings.Repeat("x", i%7+1) + "\n")
+	}
+	if err := os.WriteFile(path, []byte(content.String()), historyFilePerms); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory() error = %v", err)
+	}
+	if h.Len() != MaxHistory {
+		t.Errorf("history holds %d entries, want %d", h.Len(), MaxHistory)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != MaxHistory {
+		t.Errorf("trimmed history file holds %d lines, want %d", lines, MaxHistory)
+	}
+}
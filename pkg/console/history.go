@@ -0,0 +1,144 @@
+package console
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// MaxHistory is the number of lines a History keeps.
+	MaxHistory = 1000
+
+	// File permissions for the history file: entered lines may be private.
+	historyDirPerms  = 0o700
+	historyFilePerms = 0o600
+)
+
+// History is the list of lines entered in an Editor, most recent first. It
+// implements term.History. Lines are appended to a file, when it has one,
+// so that they are offered again in later sessions.
+type History struct {
+	mu sync.Mutex
+	// lines holds the entries, oldest first.
+	lines []string
+	path  string
+}
+
+// DefaultHistoryPath returns the history file of pplx chat,
+// ~/.local/share/pplx/chat_history.
+func DefaultHistoryPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory for chat history: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "pplx", "chat_history"), nil
+}
+
+// LoadHistory returns the history kept in the file at path, holding its
+// last MaxHistory lines; a missing file is an empty history. An empty path
+// returns a history kept in memory only.
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path}
+	if path == "" {
+		return h, nil
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // the history path is chosen by pplx
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return nil, fmt.Errorf("failed to read history %s: %w", path, err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.lines = append(h.lines, line)
+		}
+	}
+	if len(h.lines) > MaxHistory {
+		h.lines = h.lines[len(h.lines)-MaxHistory:]
+		// Rewrite the file once it holds twice the lines kept, so that it
+		// does not grow forever.
+		if len(h.lines)*2 <= strings.Count(string(data), "\n") {
+			content := strings.Join(h.lines, "\n") + "\n"
+			if err := os.WriteFile(path, []byte(content), historyFilePerms); err != nil {
+				return nil, fmt.Errorf("failed to trim history %s: %w", path, err)
+			}
+		}
+	}
+	return h, nil
+}
+
+// Add appends entry to the history and its file. Empty entries and repeats
+// of the last entry are skipped. Failing to write the file leaves the
+// entry in memory: the history of the session keeps working.
+func (h *History) Add(entry string) {
+	if strings.TrimSpace(entry) == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n := len(h.lines); n > 0 && h.lines[n-1] == entry {
+		return
+	}
+	h.lines = append(h.lines, entry)
+	if len(h.lines) > MaxHistory {
+		h.lines = h.lines[len(h.lines)-MaxHistory:]
+	}
+	if h.path != "" {
+		_ = h.appendFile(entry)
+	}
+}
+
+// appendFile appends entry to the history file.
+func (h *History) appendFile(entry string) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), historyDirPerms); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, historyFilePerms) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("failed to open history %s: %w", h.path, err)
+	}
+	if _, err := f.WriteString(entry + "\n"); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write history %s: %w", h.path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close history %s: %w", h.path, err)
+	}
+	return nil
+}
+
+// Len returns the number of entries.
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.lines)
+}
+
+// At returns the entry idx, 0 being the most recent. It panics when idx is
+// out of range.
+func (h *History) At(idx int) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lines[len(h.lines)-1-idx]
+}
+
+// Search returns the index of the most recent entry at or after from, in
+// At order, that contains query, ignoring case.
+func (h *History) Search(query string, from int) (int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	query = strings.ToLower(query)
+	for idx := max(from, 0); idx < len(h.lines); idx++ {
+		if strings.Contains(strings.ToLower(h.lines[len(h.lines)-1-idx]), query) {
+			return idx, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,180 @@
+// Package export translates a built Perplexity request into the wire format
+// of another API, so that it can be replayed against a proxy or a local
+// model without sending it to Perplexity.
+//
+// Translation is deterministic: the same request always gives the same
+// bytes, which keeps exported payloads diffable.
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Wire formats accepted by Request.
+const (
+	// FormatOpenAI is the OpenAI chat/completions request body.
+	FormatOpenAI = "openai"
+	// FormatPerplexity is the Perplexity request body, as pplx sends it.
+	FormatPerplexity = "perplexity"
+)
+
+// formats lists the wire formats in help order.
+var formats = []string{FormatOpenAI, FormatPerplexity}
+
+// openAIFields are the request fields kept in the OpenAI format; every
+// other field set in the request is dropped.
+var openAIFields = []string{"messages", "model", "temperature", "max_tokens", "top_p", "stream"}
+
+// Formats returns the accepted wire formats.
+func Formats() []string {
+	return slices.Clone(formats)
+}
+
+// Payload is a request translated to a wire format.
+type Payload struct {
+	Format string
+	// Body is the indented JSON request body.
+	Body []byte
+	// Dropped lists, sorted, the fields set in the request that the format
+	// cannot carry and were left out.
+	Dropped []string
+}
+
+// Request translates req to format. Returns an error wrapping
+// clerrors.ErrInvalidExportFormat for unknown formats.
+func Request(req *perplexity.CompletionRequest, format string) (*Payload, error) {
+	var body any = req
+	var dropped []string
+	switch format {
+	case FormatPerplexity:
+	case FormatOpenAI:
+		var err error
+		if dropped, err = droppedFields(req); err != nil {
+			return nil, err
+		}
+		var lost []string
+		body, lost = toOpenAI(req)
+		dropped = append(dropped, lost...)
+		slices.Sort(dropped)
+	default:
+		return nil, fmt.Errorf("%w: %q (valid: %s)", clerrors.ErrInvalidExportFormat,
+			format, strings.Join(formats, ", "))
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s request: %w", format, err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return nil, fmt.Errorf("failed to encode %s request: %w", format, err)
+	}
+	return &Payload{Format: format, Body: indented.Bytes(), Dropped: dropped}, nil
+}
+
+// openAIRequest is the OpenAI chat/completions request body.
+type openAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Temperature float64         `json:"temperature"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	TopP        float64         `json:"top_p,omitempty"`
+	Stream      bool            `json:"stream,omitempty"`
+}
+
+// openAIMessage is a message whose content is a string, or a list of
+// openAIPart for messages with attachments.
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+// openAIPart is a part of the content of a message with attachments.
+type openAIPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+// openAIImageURL is the image of an image_url part.
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// toOpenAI returns req as an OpenAI request, and the parts of its messages
+// that the format cannot carry: file attachments.
+func toOpenAI(req *perplexity.CompletionRequest) (openAIRequest, []string) {
+	out := openAIRequest{
+		Model:       req.Model,
+		Messages:    []openAIMessage{},
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		TopP:        req.TopP,
+		Stream:      req.Stream,
+	}
+	if len(req.MultimodalMessages) == 0 {
+		for _, msg := range req.Messages {
+			out.Messages = append(out.Messages, openAIMessage{Role: msg.Role, Content: msg.Content})
+		}
+		return out, nil
+	}
+
+	var lost []string
+	for _, msg := range req.MultimodalMessages {
+		parts := []openAIPart{}
+		for _, content := range msg.Content {
+			switch {
+			case content.Text != nil:
+				parts = append(parts, openAIPart{Type: "text", Text: *content.Text})
+			case content.ImageURL != nil:
+				parts = append(parts, openAIPart{Type: "image_url", ImageURL: &openAIImageURL{URL: content.ImageURL.URL}})
+			case content.FileURL != nil && !slices.Contains(lost, "messages.file_url"):
+				lost = append(lost, "messages.file_url")
+			}
+		}
+		out.Messages = append(out.Messages, openAIMessage{Role: msg.Role, Content: parts})
+	}
+	return out, lost
+}
+
+// droppedFields returns the fields of req, by their Perplexity JSON names,
+// that the OpenAI format does not carry and that differ from the defaults of
+// a new request: the options the user set.
+func droppedFields(req *perplexity.CompletionRequest) ([]string, error) {
+	fields, err := decodeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := decodeRequest(perplexity.DefaultCompletionRequest())
+	if err != nil {
+		return nil, err
+	}
+	var dropped []string
+	for name, value := range fields {
+		if !slices.Contains(openAIFields, name) && !reflect.DeepEqual(value, defaults[name]) {
+			dropped = append(dropped, name)
+		}
+	}
+	return dropped, nil
+}
+
+// decodeRequest returns the JSON fields of req.
+func decodeRequest(req *perplexity.CompletionRequest) (map[string]any, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode request: %w", err)
+	}
+	return fields, nil
+}
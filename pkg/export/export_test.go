@@ -0,0 +1,147 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// newRequest builds a request from options, as the query command does.
+func newRequest(t *testing.T, opts ...perplexity.CompletionRequestOption) *perplexity.CompletionRequest {
+	t.Helper()
+	msg := perplexity.NewMessages(perplexity.WithSystemMessage("Be brief"))
+	if err := msg.AddUserMessage("What is Go?"); err != nil {
+		t.Fatal(err)
+	}
+	base := []perplexity.CompletionRequestOption{
+		perplexity.WithMessages(msg.GetMessages()),
+		perplexity.WithModel("sonar-pro"),
+		perplexity.WithMaxTokens(300),
+		perplexity.WithTemperature(0.2),
+	}
+	return perplexity.NewCompletionRequest(append(base, opts...)...)
+}
+
+func TestRequest_OpenAI(t *testing.T) {
+	req := newRequest(t,
+		perplexity.WithSearchDomainFilter([]string{"go.dev"}),
+		perplexity.WithSearchRecencyFilter("week"),
+		perplexity.WithReturnImages(true),
+	)
+
+	payload, err := Request(req, FormatOpenAI)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	want := `{
+  "model": "sonar-pro",
+  "messages": [
+    {
+      "role": "system",
+      "content": "Be brief"
+    },
+    {
+      "role": "user",
+      "content": "What is Go?"
+    }
+  ],
+  "temperature": 0.2,
+  "max_tokens": 300,
+  "top_p": 0.9
+}`
+	if string(payload.Body) != want {
+		t.Errorf("Body =\n%s\nwant\n%s", payload.Body, want)
+	}
+	wantDropped := []string{"return_images", "search_domain_filter", "search_recency_filter"}
+	if !slices.Equal(payload.Dropped, wantDropped) {
+		t.Errorf("Dropped = %v, want %v", payload.Dropped, wantDropped)
+	}
+}
+
+func TestRequest_OpenAIAttachments(t *testing.T) {
+	req := newRequest(t)
+	text := "Describe this"
+	req.MultimodalMessages = []perplexity.MultimodalMessage{{
+		Role: "user",
+		Content: []perplexity.Content{
+			{Type: perplexity.ContentTypeText, Text: &text},
+			{Type: perplexity.ContentTypeImageURL, ImageURL: &perplexity.ImageURL{URL: "https://img/a.png"}},
+			{Type: perplexity.ContentTypeFileURL, FileURL: &perplexity.FileURL{URL: "https://doc/a.pdf"}},
+		},
+	}}
+
+	payload, err := Request(req, FormatOpenAI)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	var body struct {
+		Messages []struct {
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(payload.Body, &body); err != nil {
+		t.Fatalf("Body is not JSON: %v", err)
+	}
+	if len(body.Messages) != 1 || len(body.Messages[0].Content) != 2 {
+		t.Fatalf("messages = %+v, want one message with a text and an image part", body.Messages)
+	}
+	if !slices.Contains(payload.Dropped, "messages.file_url") {
+		t.Errorf("Dropped = %v, want messages.file_url", payload.Dropped)
+	}
+}
+
+func TestRequest_Perplexity(t *testing.T) {
+	req := newRequest(t, perplexity.WithSearchRecencyFilter("week"))
+
+	payload, err := Request(req, FormatPerplexity)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(payload.Body, &body); err != nil {
+		t.Fatalf("Body is not JSON: %v", err)
+	}
+	if body["search_recency_filter"] != "week" || len(payload.Dropped) != 0 {
+		t.Errorf("Body = %s, Dropped = %v; want every field kept", payload.Body, payload.Dropped)
+	}
+}
+
+// TestRequest_Deterministic builds the same request twice and checks that
+// both formats encode it to the same bytes.
+func TestRequest_Deterministic(t *testing.T) {
+	opts := []perplexity.CompletionRequestOption{
+		perplexity.WithSearchDomainFilter([]string{"go.dev", "-reddit.com"}),
+		perplexity.WithImageFormatFilter([]string{"png"}),
+		perplexity.WithSearchMode("academic"),
+		perplexity.WithReasoningEffort("high"),
+	}
+	for _, format := range Formats() {
+		t.Run(format, func(t *testing.T) {
+			first, err := Request(newRequest(t, opts...), format)
+			if err != nil {
+				t.Fatalf("Request() error = %v", err)
+			}
+			for range 10 {
+				again, err := Request(newRequest(t, opts...), format)
+				if err != nil {
+					t.Fatalf("Request() error = %v", err)
+				}
+				if !bytes.Equal(first.Body, again.Body) || !slices.Equal(first.Dropped, again.Dropped) {
+					t.Fatalf("Request() differs between runs:\n%s\n%v\n---\n%s\n%v",
+						first.Body, first.Dropped, again.Body, again.Dropped)
+				}
+			}
+		})
+	}
+}
+
+func TestRequest_UnknownFormat(t *testing.T) {
+	if _, err := Request(newRequest(t), "anthropic"); !errors.Is(err, clerrors.ErrInvalidExportFormat) {
+		t.Errorf("Request() error = %v, want ErrInvalidExportFormat", err)
+	}
+}
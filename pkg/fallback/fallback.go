@@ -0,0 +1,145 @@
+// Package fallback sends completion requests through a chain of models: when
+// the API reports that the model of a request is unavailable or invalid, the
+// request is sent again with the next model of the chain.
+//
+// Only model failures move down the chain. Authentication errors, rate
+// limits, invalid parameters, and network failures are returned as they are,
+// since another model would fail the same way.
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/logger"
+)
+
+// SendFunc sends one completion request.
+type SendFunc func(ctx context.Context, req *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error)
+
+// Result is the response of the first model of the chain that answered.
+type Result struct {
+	Response *perplexity.CompletionResponse
+	// Model is the model that answered.
+	Model string
+	// Skipped are the models tried before Model, in order.
+	Skipped []string
+}
+
+// FellBack reports whether another model than the requested one answered.
+func (r *Result) FellBack() bool {
+	return len(r.Skipped) > 0
+}
+
+// Note describes a fallback for the user, as in "sonar-pro unavailable,
+// answered by sonar".
+func (r *Result) Note() string {
+	return fmt.Sprintf("%s unavailable, answered by %s", strings.Join(r.Skipped, ", "), r.Model)
+}
+
+// modelPhrases are the lower-cased phrases of API error messages and types
+// saying that the model of the request cannot be used.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var modelPhrases = []string{
+	"invalid_model", "invalid model",
+	"model_not_found", "model not found", "unknown model",
+	"model_unavailable", "model unavailable", "model is unavailable", "model is currently unavailable",
+	"model is not available", "model not available",
+	"model is overloaded", "model overloaded",
+}
+
+// Chain returns model followed by fallbacks, without empty fallbacks and
+// without repeating a model. An empty model, which lets the API choose, is
+// kept.
+func Chain(model string, fallbacks []string) []string {
+	chain := make([]string, 0, len(fallbacks)+1)
+	chain = append(chain, model)
+	for _, m := range fallbacks {
+		m = strings.TrimSpace(m)
+		if m != "" && !slices.Contains(chain, m) {
+			chain = append(chain, m)
+		}
+	}
+	return chain
+}
+
+// IsModelUnavailable reports whether err is an API error response saying
+// that the model of the request is unavailable or invalid. Authentication
+// errors never are, whatever their message.
+func IsModelUnavailable(err error) bool {
+	if err == nil || errors.Is(err, perplexity.ErrUnauthorized) {
+		return false
+	}
+	switch clerrors.StatusCode(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false
+	}
+	var valErr *clerrors.ValidationError
+	if errors.As(err, &valErr) {
+		return false
+	}
+
+	var text string
+	var respErr *perplexity.ResponseError
+	var apiErr *clerrors.APIError
+	switch {
+	case errors.As(err, &respErr):
+		text = respErr.ErrorData.Type + " " + respErr.ErrorData.Message
+	case errors.As(err, &apiErr) && apiErr.StatusCode >= http.StatusBadRequest:
+		text = apiErr.Message
+	default:
+		return false
+	}
+	text = strings.ToLower(text)
+	for _, phrase := range modelPhrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// Send sends req with send and, while the API answers that the model is
+// unavailable or invalid, sends it again with the next model of fallbacks.
+// req itself is not modified: the retries send copies naming the other
+// models. A failure after part of an answer was received, as with an
+// interrupted stream, is returned without trying another model. A response
+// that does not name its model is given the one that answered. When no
+// model answers, the error of the last one tried is returned, and
+// Result.Skipped lists the models tried before it.
+func Send(ctx context.Context, req *perplexity.CompletionRequest, fallbacks []string, send SendFunc) (*Result, error) {
+	chain := Chain(req.Model, fallbacks)
+	result := &Result{}
+	for i, model := range chain {
+		next := req
+		if model != req.Model {
+			copied := *req
+			copied.Model = model
+			next = &copied
+		}
+		res, err := send(ctx, next)
+		if err == nil {
+			if res != nil && res.Model == "" {
+				res.Model = model
+			}
+			result.Response, result.Model = res, model
+			return result, nil
+		}
+		last := i == len(chain)-1
+		if last || res != nil || ctx.Err() != nil || !IsModelUnavailable(err) {
+			result.Response = res
+			return result, err
+		}
+		logger.Debug("model unavailable, trying the next fallback model",
+			"model", model, "next", chain[i+1], "error", err)
+		result.Skipped = append(result.Skipped, model)
+	}
+	return result, nil // unreachable: the last model of the chain returns
+}
@@ -0,0 +1,221 @@
+package fallback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func newTestRequest(model string) *perplexity.CompletionRequest {
+	msg := perplexity.NewMessages()
+	_ = msg.AddUserMessage("hello")
+	return perplexity.NewCompletionRequest(
+		perplexity.WithMessages(msg.GetMessages()),
+		perplexity.WithModel(model),
+	)
+}
+
+// newModelServer answers requests for the models in failing with status and
+// message, and the other requests with a completion. It records the model of
+// every request.
+func newModelServer(t *testing.T, failing map[string]bool, status int, message string,
+	models *[]string,
+) *perplexity.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		*models = append(*models, body.Model)
+		w.Header().Set("Content-Type", "application/json")
+		if failing[body.Model] {
+			w.WriteHeader(status)
+			_, _ = fmt.Fprintf(w, `{"error":{"message":%q,"type":"error","code":%d}}`, message, status)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"id":"x","model":%q,"created":1,"object":"chat.completion",`+
+			`"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2},`+
+			`"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`, body.Model)
+	}))
+	t.Cleanup(srv.Close)
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	return client
+}
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     string
+		fallbacks []string
+		want      []string
+	}{
+		{name: "no fallbacks", model: "sonar-pro", want: []string{"sonar-pro"}},
+		{name: "fallbacks", model: "sonar-pro", fallbacks: []string{"sonar"}, want: []string{"sonar-pro", "sonar"}},
+		{
+			name: "duplicates and blanks dropped", model: "sonar-pro",
+			fallbacks: []string{"sonar-pro", " ", "sonar", "sonar"},
+			want:      []string{"sonar-pro", "sonar"},
+		},
+		{name: "empty model kept", fallbacks: []string{"sonar"}, want: []string{"", "sonar"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Chain(tt.model, tt.fallbacks); !slices.Equal(got, tt.want) {
+				t.Errorf("Chain() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsModelUnavailable(t *testing.T) {
+	responseError := func(typ, message string, code int) error {
+		e := &perplexity.ResponseError{}
+		e.ErrorData.Type, e.ErrorData.Message, e.ErrorData.Code = typ, message, code
+		return e
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "invalid model", err: responseError("invalid_model", "Invalid model 'sonar-x'", 400), want: true},
+		{
+			name: "unavailable model wrapped",
+			err:  fmt.Errorf("send: %w", responseError("error", "The model is currently unavailable", 503)),
+			want: true,
+		},
+		{
+			name: "retries exhausted on an unavailable model",
+			err: fmt.Errorf("%w after 4 attempts: %w", clerrors.ErrRetriesExhausted,
+				&clerrors.APIError{StatusCode: 503, Message: "Model unavailable, try again later"}),
+			want: true,
+		},
+		{name: "other bad request", err: responseError("invalid_request", "max_tokens is too large", 400), want: false},
+		{name: "unauthorized", err: perplexity.ErrUnauthorized, want: false},
+		{name: "forbidden model", err: responseError("error", "model not available on your plan", 403), want: false},
+		{name: "validation error", err: clerrors.NewValidationError("model", "x", "invalid model name"), want: false},
+		{name: "server error", err: &clerrors.APIError{StatusCode: 500, Message: "Internal Server Error"}, want: false},
+		{name: "network error", err: errors.New("dial tcp: connection refused"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsModelUnavailable(tt.err); got != tt.want {
+				t.Errorf("IsModelUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		failing     map[string]bool
+		status      int
+		message     string
+		fallbacks   []string
+		wantModel   string
+		wantSent    []string
+		wantErr     bool
+		wantSkipped []string
+	}{
+		{
+			name: "first model answers", failing: map[string]bool{},
+			fallbacks: []string{"sonar"},
+			wantModel: "sonar-pro", wantSent: []string{"sonar-pro"},
+		},
+		{
+			name: "falls back on an unavailable model", failing: map[string]bool{"sonar-pro": true},
+			status: http.StatusServiceUnavailable, message: "The model is currently unavailable",
+			fallbacks: []string{"sonar"},
+			wantModel: "sonar", wantSent: []string{"sonar-pro", "sonar"}, wantSkipped: []string{"sonar-pro"},
+		},
+		{
+			name: "falls back on an invalid model", failing: map[string]bool{"sonar-x": true, "sonar-pro": true},
+			status: http.StatusBadRequest, message: "Invalid model 'sonar-x'",
+			fallbacks: []string{"sonar-pro", "sonar"},
+			wantModel: "sonar", wantSent: []string{"sonar-x", "sonar-pro", "sonar"},
+			wantSkipped: []string{"sonar-x", "sonar-pro"},
+		},
+		{
+			name: "no fallback on other errors", failing: map[string]bool{"sonar-pro": true},
+			status: http.StatusBadRequest, message: "max_tokens is too large",
+			fallbacks: []string{"sonar"},
+			wantSent:  []string{"sonar-pro"}, wantErr: true,
+		},
+		{
+			name: "no fallback on authentication errors", failing: map[string]bool{"sonar-pro": true},
+			status: http.StatusUnauthorized, message: "invalid model access",
+			fallbacks: []string{"sonar"},
+			wantSent:  []string{"sonar-pro"}, wantErr: true,
+		},
+		{
+			name: "every model unavailable", failing: map[string]bool{"sonar-pro": true, "sonar": true},
+			status: http.StatusServiceUnavailable, message: "model unavailable",
+			fallbacks: []string{"sonar"},
+			wantSent:  []string{"sonar-pro", "sonar"}, wantErr: true, wantSkipped: []string{"sonar-pro"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []string
+			client := newModelServer(t, tt.failing, tt.status, tt.message, &sent)
+			req := newTestRequest(requested(tt.wantSent))
+
+			result, err := Send(context.Background(), req, tt.fallbacks, client.SendCompletionRequestWithContext)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(sent, tt.wantSent) {
+				t.Errorf("models sent = %q, want %q", sent, tt.wantSent)
+			}
+			if !slices.Equal(result.Skipped, tt.wantSkipped) {
+				t.Errorf("Skipped = %q, want %q", result.Skipped, tt.wantSkipped)
+			}
+			if !tt.wantErr && (result.Model != tt.wantModel || result.Response == nil) {
+				t.Errorf("result = %+v, want an answer from %s", result, tt.wantModel)
+			}
+			if req.Model != requested(tt.wantSent) {
+				t.Errorf("req.Model = %q, want the request left unchanged", req.Model)
+			}
+		})
+	}
+}
+
+func TestSend_PartialAnswerNotRetried(t *testing.T) {
+	calls := 0
+	partial := &perplexity.CompletionResponse{}
+	send := func(_ context.Context, _ *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error) {
+		calls++
+		e := &perplexity.ResponseError{}
+		e.ErrorData.Message = "model unavailable"
+		return partial, e
+	}
+	result, err := Send(context.Background(), newTestRequest("sonar-pro"), []string{"sonar"}, send)
+	if err == nil || calls != 1 || result.Response != partial {
+		t.Errorf("Send() = %+v, %v after %d calls, want the partial answer and its error after 1 call",
+			result, err, calls)
+	}
+}
+
+func TestResult_Note(t *testing.T) {
+	result := &Result{Model: "sonar", Skipped: []string{"sonar-x", "sonar-pro"}}
+	if got, want := result.Note(), "sonar-x, sonar-pro unavailable, answered by sonar"; got != want {
+		t.Errorf("Note() = %q, want %q", got, want)
+	}
+}
+
+// requested returns the model of the request: the first one sent.
+func requested(sent []string) string {
+	return sent[0]
+}
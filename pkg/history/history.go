@@ -0,0 +1,338 @@
+// Package history records the user prompts of queries for `pplx history`
+// and `pplx query --last`.
+//
+// Entries are appended as JSON lines to
+// ~/.local/share/pplx/prompt_history.jsonl, each with a sequence number,
+// the time, and the model it was sent to. Prompts are stored as given:
+// queries with sensitive prompts are left out with --no-history.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// FileName is the name of the history file in the data directory.
+const FileName = "prompt_history.jsonl"
+
+// DefaultKeep is how many entries `pplx history prune` keeps by default.
+const DefaultKeep = 500
+
+const (
+	// File permissions for the history file.
+	historyDirPerms  = 0o700
+	historyFilePerms = 0o600
+)
+
+// Scores of fuzzy matching: every matched character counts, characters
+// following the previous match or starting a word count more, and each
+// character skipped between two matches costs gapPenalty.
+const (
+	matchScore       = 1
+	consecutiveBonus = 5
+	wordStartBonus   = 3
+	gapPenalty       = 1
+)
+
+// Entry is one recorded prompt.
+type Entry struct {
+	// ID numbers the entries in the order they were recorded, from 1. IDs
+	// are not reused after pruning.
+	ID     int       `json:"id"`
+	Time   time.Time `json:"time"`
+	Model  string    `json:"model,omitempty"`
+	Prompt string    `json:"prompt"`
+}
+
+// Store appends entries to a JSON lines file and reads them back.
+//
+// Each entry is written with a single append-mode write, so a partial line
+// is never interleaved with another; a mutex serializes writers within a
+// process.
+type Store struct {
+	path string
+	mu   sync.Mutex
+	// now returns the current time; replaced in tests.
+	now func() time.Time
+}
+
+// NewStore returns a store of the history file at path.
+func NewStore(path string) *Store {
+	return &Store{path: path, now: time.Now}
+}
+
+// DefaultPath returns the history file location,
+// ~/.local/share/pplx/prompt_history.jsonl.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory for prompt history: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "pplx", FileName), nil
+}
+
+// Path returns the file the store reads and writes.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Add records prompt, sent to model, and returns the new entry. Blank
+// prompts are not recorded and return a zero Entry.
+func (s *Store) Add(model, prompt string) (Entry, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return Entry{}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{ID: nextID(entries), Time: s.now().UTC(), Model: model, Prompt: prompt}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to encode history entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(s.path), historyDirPerms); err != nil {
+		return Entry{}, fmt.Errorf("failed to create prompt history directory: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, historyFilePerms) // #nosec G304
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to open prompt history %s: %w", s.path, err)
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return Entry{}, fmt.Errorf("failed to write prompt history %s: %w", s.path, err)
+	}
+	if err := f.Close(); err != nil {
+		return Entry{}, fmt.Errorf("failed to close prompt history %s: %w", s.path, err)
+	}
+	return entry, nil
+}
+
+// Entries returns the recorded entries, oldest first. A missing file has
+// none.
+func (s *Store) Entries() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+// Find returns the entry numbered id. Returns clerrors.ErrHistoryEntryNotFound
+// when there is none.
+func (s *Store) Find(id int) (Entry, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("%w: %d", clerrors.ErrHistoryEntryNotFound, id)
+}
+
+// Last returns the most recent entry. Returns clerrors.ErrHistoryEmpty when
+// nothing was recorded.
+func (s *Store) Last() (Entry, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(entries) == 0 {
+		return Entry{}, clerrors.ErrHistoryEmpty
+	}
+	return entries[len(entries)-1], nil
+}
+
+// Prune keeps the keep most recent entries and returns how many it removed.
+// The file is replaced atomically, so a reader never sees it half written.
+func (s *Store) Prune(keep int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+	keep = max(keep, 0)
+	if len(entries) <= keep {
+		return 0, nil
+	}
+	removed := len(entries) - keep
+	if err := s.write(entries[removed:]); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// read decodes the history file; the caller holds the mutex.
+func (s *Store) read() ([]Entry, error) {
+	f, err := os.Open(s.path) // #nosec G304
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open prompt history %s: %w", s.path, err)
+	}
+	defer func() { _ = f.Close() }()
+	return Read(f)
+}
+
+// write replaces the history file with entries; the caller holds the mutex.
+func (s *Store) write(entries []Entry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", s.path, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("failed to encode history entry: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	if err := os.Chmod(tmp.Name(), historyFilePerms); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Read decodes JSON lines entries from r, skipping malformed lines such as
+// a partially written final line.
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, bufio.MaxScanTokenSize*64) //nolint:mnd // prompts may be long
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prompt history: %w", err)
+	}
+	return entries, nil
+}
+
+// nextID returns the ID following the highest of entries.
+func nextID(entries []Entry) int {
+	id := 0
+	for _, entry := range entries {
+		id = max(id, entry.ID)
+	}
+	return id + 1
+}
+
+// Match is an entry found by Search, with its score; higher is better.
+type Match struct {
+	Entry
+	Score int `json:"score"`
+}
+
+// Search returns the entries whose prompt matches query, best first.
+//
+// Without fuzzy, a prompt matches when it contains query, ignoring case,
+// and matches are listed newest first. With fuzzy, a prompt matches when
+// it contains the characters of query in order, spaces in query aside, and
+// matches are ranked by FuzzyScore, newest first among equal scores. An
+// empty query matches every entry.
+func Search(entries []Entry, query string, fuzzy bool) []Match {
+	var matches []Match
+	needle := strings.ToLower(query)
+	for _, entry := range entries {
+		if !fuzzy {
+			if strings.Contains(strings.ToLower(entry.Prompt), needle) {
+				matches = append(matches, Match{Entry: entry})
+			}
+			continue
+		}
+		if score, ok := FuzzyScore(entry.Prompt, query); ok {
+			matches = append(matches, Match{Entry: entry, Score: score})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		if a.Score != b.Score {
+			return b.Score - a.Score
+		}
+		return b.ID - a.ID
+	})
+	return matches
+}
+
+// FuzzyScore reports whether the characters of pattern appear in text in
+// order, ignoring case and the spaces of pattern, and scores the match:
+// each matched character scores matchScore, plus consecutiveBonus when it
+// follows the previous match and wordStartBonus when it starts a word, and
+// each character skipped between two matches costs gapPenalty. Characters
+// are matched as early as possible.
+func FuzzyScore(text, pattern string) (int, bool) {
+	haystack := []rune(strings.ToLower(text))
+	score, last := 0, -1
+	for _, r := range strings.ToLower(pattern) {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		i := last + 1
+		for i < len(haystack) && haystack[i] != r {
+			i++
+		}
+		if i == len(haystack) {
+			return 0, false
+		}
+		score += matchScore
+		switch {
+		case last >= 0 && i == last+1:
+			score += consecutiveBonus
+		case last >= 0:
+			score -= (i - last - 1) * gapPenalty
+		}
+		if i == 0 || !isWordRune(haystack[i-1]) {
+			score += wordStartBonus
+		}
+		last = i
+	}
+	return score, true
+}
+
+// isWordRune reports whether r is part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,169 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// newTestStore returns a store in a temporary directory with a clock
+// advancing a minute per entry.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s := NewStore(filepath.Join(t.TempDir(), "pplx", FileName))
+	clock := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+	return s
+}
+
+func TestStore_AddFindLast(t *testing.T) {
+	s := newTestStore(t)
+
+	if _, err := s.Last(); !errors.Is(err, clerrors.ErrHistoryEmpty) {
+		t.Errorf("Last() on an empty history error = %v, want ErrHistoryEmpty", err)
+	}
+	for _, prompt := range []string{"first", "  ", "second"} {
+		if _, err := s.Add("sonar", prompt); err != nil {
+			t.Fatalf("Add(%q) error = %v", prompt, err)
+		}
+	}
+
+	entries, err := s.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Entries() = %d entries, want 2 (blank prompts are skipped)", len(entries))
+	}
+	last, err := s.Last()
+	if err != nil || last.ID != 2 || last.Prompt != "second" || last.Model != "sonar" {
+		t.Errorf("Last() = %+v, %v", last, err)
+	}
+	first, err := s.Find(1)
+	if err != nil || first.Prompt != "first" || !first.Time.Before(last.Time) {
+		t.Errorf("Find(1) = %+v, %v", first, err)
+	}
+	if _, err := s.Find(3); !errors.Is(err, clerrors.ErrHistoryEntryNotFound) {
+		t.Errorf("Find(3) error = %v, want ErrHistoryEntryNotFound", err)
+	}
+
+	info, err := os.Stat(s.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != historyFilePerms {
+		t.Errorf("history file permissions = %o, want %o", perm, historyFilePerms)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	s := newTestStore(t)
+	for _, prompt := range []string{"a", "b", "c", "d"} {
+		if _, err := s.Add("", prompt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := s.Prune(2)
+	if err != nil || removed != 2 {
+		t.Fatalf("Prune(2) = %d, %v; want 2 removed", removed, err)
+	}
+	if removed, err := s.Prune(5); err != nil || removed != 0 {
+		t.Errorf("Prune(5) = %d, %v; want nothing removed", removed, err)
+	}
+	entries, err := s.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Prompt != "c" || entries[1].Prompt != "d" {
+		t.Errorf("Entries() after Prune = %+v, want c and d", entries)
+	}
+
+	entry, err := s.Add("", "e")
+	if err != nil || entry.ID != 5 {
+		t.Errorf("Add() after Prune = %+v, %v; want ID 5, IDs are not reused", entry, err)
+	}
+}
+
+func TestRead_SkipsMalformedLines(t *testing.T) {
+	in := `{"id":1,"time":"2026-01-02T03:04:00Z","prompt":"one"}
+
+not json
+{"id":2,"time":"2026-01-02T03:05:00Z","model":"sonar","prompt":"two"}
+{"id":3,"prom`
+	entries, err := Read(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != 1 || entries[1].Model != "sonar" {
+		t.Errorf("Read() = %+v, want entries 1 and 2", entries)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	entries := []Entry{
+		{ID: 1, Prompt: "Explain Kubernetes ingress controllers"},
+		{ID: 2, Prompt: "What is a kernel?"},
+		{ID: 3, Prompt: "kubernetes pod lifecycle"},
+		{ID: 4, Prompt: "Best pizza in Naples"},
+	}
+	tests := []struct {
+		name  string
+		query string
+		fuzzy bool
+		want  []int
+	}{
+		{"empty query lists all, newest first", "", false, []int{4, 3, 2, 1}},
+		{"substring ignores case", "KUBERNETES", false, []int{3, 1}},
+		{"substring needs contiguous text", "k8s", false, nil},
+		{"fuzzy ranks the closest match first", "kube ingr", true, []int{1}},
+		{"fuzzy prefers word starts and runs", "ker", true, []int{2, 3, 1}},
+		{"fuzzy without a match", "zzz", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := Search(entries, tt.query, tt.fuzzy)
+			var got []int
+			for _, m := range matches {
+				got = append(got, m.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search(%q) IDs = %v, want %v", tt.query, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Search(%q) IDs = %v, want %v", tt.query, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		text    string
+		pattern string
+		want    int
+		wantOK  bool
+	}{
+		{"kubernetes", "kub", 1 + 3 + 1 + 5 + 1 + 5, true},
+		{"kubernetes", "KB", 1 + 3 + 1 - 1, true},
+		{"go test", "g t", 1 + 3 + 1 + 3 - 2, true},
+		{"kubernetes", "bk", 0, false},
+		{"anything", "", 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := FuzzyScore(tt.text, tt.pattern)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("FuzzyScore(%q, %q) = %d, %v; want %d, %v", tt.text, tt.pattern, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
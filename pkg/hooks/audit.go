@@ -0,0 +1,110 @@
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/logger"
+)
+
+// Audit log events.
+const (
+	EventRequest  = "request"
+	EventResponse = "response"
+)
+
+const (
+	// File permissions for the audit log.
+	auditDirPerms  = 0o700
+	auditFilePerms = 0o600
+)
+
+// AuditEntry is one line of the audit log.
+type AuditEntry struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	// Request is the request body as sent, for request events.
+	Request *perplexity.CompletionRequest `json:"request,omitempty"`
+	// ID, Model, and Usage describe the response of response events.
+	ID    string            `json:"id,omitempty"`
+	Model string            `json:"model,omitempty"`
+	Usage *perplexity.Usage `json:"usage,omitempty"`
+	// Error is the error of a failed request, for response events.
+	Error string `json:"error,omitempty"`
+}
+
+// AuditLog appends every request body and the outcome of every request to
+// a JSON lines file. The API key is not part of the request body and is
+// never written.
+//
+// Like the usage log, each entry is a single append-mode write and a mutex
+// serializes writers within a process, so parallel MCP requests never
+// interleave partial lines.
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+	// now returns the current time; replaced in tests.
+	now func() time.Time
+}
+
+// NewAuditLog returns a hook appending to the file at path, created with
+// mode 0600 when missing.
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path, now: time.Now}
+}
+
+// BeforeRequest records req. A request that cannot be recorded is not sent.
+func (a *AuditLog) BeforeRequest(req *perplexity.CompletionRequest) error {
+	return a.write(AuditEntry{Event: EventRequest, Request: req})
+}
+
+// AfterResponse records the response ID, model, and usage, or the error.
+// Failures to write are logged, since the request has already been made.
+func (a *AuditLog) AfterResponse(res *perplexity.CompletionResponse, err error) {
+	entry := AuditEntry{Event: EventResponse}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if res != nil {
+		entry.ID = res.ID
+		entry.Model = res.Model
+		entry.Usage = &res.Usage
+	}
+	if err := a.write(entry); err != nil {
+		logger.Warn("failed to write audit log", "path", a.path, "error", err)
+	}
+}
+
+// write appends entry as one line.
+func (a *AuditLog) write(entry AuditEntry) error {
+	entry.Time = a.now().UTC()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), auditDirPerms); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, auditFilePerms) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", a.path, err)
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write audit log %s: %w", a.path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log %s: %w", a.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Package hooks runs middleware around Perplexity API requests.
+//
+// A Hook sees every request right before it is sent and every result once
+// the call returns, for both streaming and non-streaming execution. Chat
+// sessions (chat.Chat.Use) and the MCP query handler (mcp.QueryHandler.Use)
+// accept hooks. Two hooks are built in: AuditLog records requests and
+// responses as JSON lines, and PromptPrefix prepends text to the system
+// message.
+package hooks
+
+import (
+	"fmt"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Hook intercepts API requests.
+type Hook interface {
+	// BeforeRequest is called with the validated request before it is sent
+	// and may modify it. An error aborts the request.
+	BeforeRequest(req *perplexity.CompletionRequest) error
+	// AfterResponse is called once the request returned, with its response
+	// or error. Streaming requests pass the last, complete response.
+	AfterResponse(res *perplexity.CompletionResponse, err error)
+}
+
+// Chain is an ordered list of hooks.
+type Chain []Hook
+
+// Before calls BeforeRequest of every hook in order and stops at the first
+// error, which is returned wrapping clerrors.ErrRequestAborted.
+func (c Chain) Before(req *perplexity.CompletionRequest) error {
+	for _, h := range c {
+		if err := h.BeforeRequest(req); err != nil {
+			return fmt.Errorf("%w: %w", clerrors.ErrRequestAborted, err)
+		}
+	}
+	return nil
+}
+
+// After calls AfterResponse of every hook in reverse order, so that the
+// first hook registered sees the request first and the result last.
+func (c Chain) After(res *perplexity.CompletionResponse, err error) {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i].AfterResponse(res, err)
+	}
+}
@@ -0,0 +1,237 @@
+package hooks
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// recordHook records its calls in a shared log and fails when err is set.
+type recordHook struct {
+	name string
+	log  *[]string
+	err  error
+}
+
+func (h *recordHook) BeforeRequest(*perplexity.CompletionRequest) error {
+	*h.log = append(*h.log, "before "+h.name)
+	return h.err
+}
+
+func (h *recordHook) AfterResponse(*perplexity.CompletionResponse, error) {
+	*h.log = append(*h.log, "after "+h.name)
+}
+
+func TestChain(t *testing.T) {
+	var log []string
+	chain := Chain{&recordHook{name: "a", log: &log}, &recordHook{name: "b", log: &log}}
+
+	if err := chain.Before(&perplexity.CompletionRequest{}); err != nil {
+		t.Fatalf("Before() error = %v", err)
+	}
+	chain.After(nil, nil)
+
+	want := []string{"before a", "before b", "after b", "after a"}
+	if len(log) != len(want) {
+		t.Fatalf("calls = %v, want %v", log, want)
+	}
+	for i := range want {
+		if log[i] != want[i] {
+			t.Errorf("calls = %v, want %v", log, want)
+			break
+		}
+	}
+}
+
+func TestChain_Abort(t *testing.T) {
+	var log []string
+	errDenied := errors.New("denied")
+	chain := Chain{
+		&recordHook{name: "a", log: &log, err: errDenied},
+		&recordHook{name: "b", log: &log},
+	}
+
+	err := chain.Before(&perplexity.CompletionRequest{})
+	if !errors.Is(err, clerrors.ErrRequestAborted) || !errors.Is(err, errDenied) {
+		t.Fatalf("Before() error = %v, want ErrRequestAborted wrapping the hook error", err)
+	}
+	if len(log) != 1 {
+		t.Errorf("calls = %v, want the chain to stop at the failing hook", log)
+	}
+}
+
+func TestPromptPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		messages []perplexity.Message
+		want     []perplexity.Message
+	}{
+		{
+			name: "existing system message",
+			text: "Be brief.",
+			messages: []perplexity.Message{
+				{Role: "system", Content: "You are helpful."},
+				{Role: "user", Content: "hi"},
+			},
+			want: []perplexity.Message{
+				{Role: "system", Content: "Be brief.\n\nYou are helpful."},
+				{Role: "user", Content: "hi"},
+			},
+		},
+		{
+			name:     "no system message",
+			text:     "Be brief.",
+			messages: []perplexity.Message{{Role: "user", Content: "hi"}},
+			want: []perplexity.Message{
+				{Role: "system", Content: "Be brief."},
+				{Role: "user", Content: "hi"},
+			},
+		},
+		{
+			name:     "empty prefix",
+			text:     "",
+			messages: []perplexity.Message{{Role: "user", Content: "hi"}},
+			want:     []perplexity.Message{{Role: "user", Content: "hi"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]perplexity.Message(nil), tt.messages...)
+			req := &perplexity.CompletionRequest{Messages: tt.messages}
+
+			if err := NewPromptPrefix(tt.text).BeforeRequest(req); err != nil {
+				t.Fatalf("BeforeRequest() error = %v", err)
+			}
+			if len(req.Messages) != len(tt.want) {
+				t.Fatalf("Messages = %+v, want %+v", req.Messages, tt.want)
+			}
+			for i := range tt.want {
+				if req.Messages[i] != tt.want[i] {
+					t.Errorf("Messages[%d] = %+v, want %+v", i, req.Messages[i], tt.want[i])
+				}
+			}
+			for i := range original {
+				if tt.messages[i] != original[i] {
+					t.Errorf("caller message %d modified: %+v", i, tt.messages[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPromptPrefix_Multimodal(t *testing.T) {
+	system := "You are helpful."
+	req := &perplexity.CompletionRequest{MultimodalMessages: []perplexity.MultimodalMessage{
+		{Role: "system", Content: []perplexity.Content{perplexity.NewTextContent(system)}},
+		{Role: "user", Content: []perplexity.Content{perplexity.NewTextContent("hi")}},
+	}}
+	callerContent := req.MultimodalMessages[0].Content
+
+	if err := NewPromptPrefix("Be brief.").BeforeRequest(req); err != nil {
+		t.Fatalf("BeforeRequest() error = %v", err)
+	}
+	if got := *req.MultimodalMessages[0].Content[0].Text; got != "Be brief.\n\nYou are helpful." {
+		t.Errorf("system text = %q", got)
+	}
+	if *callerContent[0].Text != system {
+		t.Errorf("caller content modified: %q", *callerContent[0].Text)
+	}
+
+	req = &perplexity.CompletionRequest{MultimodalMessages: []perplexity.MultimodalMessage{
+		{Role: "user", Content: []perplexity.Content{perplexity.NewTextContent("hi")}},
+	}}
+	if err := NewPromptPrefix("Be brief.").BeforeRequest(req); err != nil {
+		t.Fatalf("BeforeRequest() error = %v", err)
+	}
+	if len(req.MultimodalMessages) != 2 || req.MultimodalMessages[0].Role != "system" {
+		t.Errorf("MultimodalMessages = %+v, want a system message first", req.MultimodalMessages)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "requests.jsonl")
+	audit := NewAuditLog(path)
+	audit.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	req := &perplexity.CompletionRequest{
+		Model:    "sonar",
+		Messages: []perplexity.Message{{Role: "user", Content: "hi"}},
+	}
+	if err := audit.BeforeRequest(req); err != nil {
+		t.Fatalf("BeforeRequest() error = %v", err)
+	}
+	audit.AfterResponse(&perplexity.CompletionResponse{
+		ID:    "resp-1",
+		Model: "sonar",
+		Usage: perplexity.Usage{TotalTokens: 12},
+	}, nil)
+	audit.AfterResponse(nil, errors.New("boom"))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("audit log not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != auditFilePerms {
+		t.Errorf("permissions = %o, want %o", perm, auditFilePerms)
+	}
+
+	entries := readEntries(t, path)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	if e := entries[0]; e.Event != EventRequest || e.Request == nil || e.Request.Messages[0].Content != "hi" {
+		t.Errorf("request entry = %+v", e)
+	}
+	if e := entries[1]; e.Event != EventResponse || e.ID != "resp-1" || e.Usage == nil || e.Usage.TotalTokens != 12 {
+		t.Errorf("response entry = %+v", e)
+	}
+	if e := entries[2]; e.Event != EventResponse || e.Error != "boom" || e.Usage != nil {
+		t.Errorf("error entry = %+v", e)
+	}
+	if !entries[0].Time.Equal(audit.now()) {
+		t.Errorf("Time = %v, want %v", entries[0].Time, audit.now())
+	}
+}
+
+func TestAuditLog_WriteFailureAborts(t *testing.T) {
+	dir := t.TempDir()
+	// A regular file where the log directory should be cannot be written into.
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	chain := Chain{NewAuditLog(filepath.Join(blocker, "audit.jsonl"))}
+
+	err := chain.Before(&perplexity.CompletionRequest{})
+	if !errors.Is(err, clerrors.ErrRequestAborted) {
+		t.Errorf("Before() error = %v, want ErrRequestAborted", err)
+	}
+}
+
+func readEntries(t *testing.T, path string) []AuditEntry {
+	t.Helper()
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
@@ -0,0 +1,77 @@
+package hooks
+
+import (
+	"slices"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+// systemRole is the role of the system message.
+const systemRole = "system"
+
+// PromptPrefix prepends fixed text to the system message of every request,
+// adding a system message when the request has none.
+type PromptPrefix struct {
+	text string
+}
+
+// NewPromptPrefix returns a hook prepending text to the system message.
+// An empty text leaves requests unchanged.
+func NewPromptPrefix(text string) *PromptPrefix {
+	return &PromptPrefix{text: text}
+}
+
+// BeforeRequest prepends the prefix to the system message of req. The
+// message slices are copied, so the caller's conversation is not modified.
+func (p *PromptPrefix) BeforeRequest(req *perplexity.CompletionRequest) error {
+	if p.text == "" {
+		return nil
+	}
+	if len(req.MultimodalMessages) > 0 {
+		req.MultimodalMessages = p.prefixMultimodal(req.MultimodalMessages)
+		return nil
+	}
+
+	msgs := slices.Clone(req.Messages)
+	if len(msgs) > 0 && msgs[0].Role == systemRole {
+		msgs[0].Content = p.join(msgs[0].Content)
+	} else {
+		msgs = slices.Insert(msgs, 0, perplexity.Message{Role: systemRole, Content: p.text})
+	}
+	req.Messages = msgs
+	return nil
+}
+
+// AfterResponse does nothing.
+func (p *PromptPrefix) AfterResponse(*perplexity.CompletionResponse, error) {}
+
+// prefixMultimodal prepends the prefix to the first text part of the system
+// message of msgs.
+func (p *PromptPrefix) prefixMultimodal(msgs []perplexity.MultimodalMessage) []perplexity.MultimodalMessage {
+	msgs = slices.Clone(msgs)
+	if len(msgs) > 0 && msgs[0].Role == systemRole {
+		content := slices.Clone(msgs[0].Content)
+		for i := range content {
+			if content[i].Type == perplexity.ContentTypeText && content[i].Text != nil {
+				text := p.join(*content[i].Text)
+				content[i].Text = &text
+				msgs[0].Content = content
+				return msgs
+			}
+		}
+		msgs[0].Content = slices.Insert(content, 0, perplexity.NewTextContent(p.text))
+		return msgs
+	}
+	return slices.Insert(msgs, 0, perplexity.MultimodalMessage{
+		Role:    systemRole,
+		Content: []perplexity.Content{perplexity.NewTextContent(p.text)},
+	})
+}
+
+// join puts the prefix before system, separated by a blank line.
+func (p *PromptPrefix) join(system string) string {
+	if system == "" {
+		return p.text
+	}
+	return p.text + "\n\n" + system
+}
@@ -0,0 +1,159 @@
+// Package i18n translates the messages pplx shows to people: the config
+// wizard, the summaries of the config commands, the warning block, doctor
+// check names, and error explanations.
+//
+// Catalogs are JSON files embedded in the binary, one per language, mapping
+// a message key to its text. English is complete; a message missing from
+// another catalog falls back to English, and a key missing from English is
+// shown as is.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// English is the language of the reference catalog, used when no other
+// language is selected or a message is not translated.
+const English = "en"
+
+// EnvLang selects the language of messages, before the locale variables.
+const EnvLang = "PPLX_LANG"
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+//nolint:gochecknoglobals // catalogs are loaded once; the language is set once per run
+var (
+	loadOnce sync.Once
+	catalogs map[string]map[string]string
+
+	mu      sync.RWMutex
+	current = English
+)
+
+// load parses the embedded catalogs. They are part of the binary, so a
+// catalog that does not parse is a build defect.
+func load() {
+	loadOnce.Do(func() {
+		entries, err := localeFS.ReadDir("locales")
+		if err != nil {
+			panic(fmt.Sprintf("i18n: reading embedded catalogs: %v", err))
+		}
+		catalogs = make(map[string]map[string]string, len(entries))
+		for _, entry := range entries {
+			lang := strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))
+			content, err := localeFS.ReadFile("locales/" + entry.Name())
+			if err != nil {
+				panic(fmt.Sprintf("i18n: reading catalog %s: %v", entry.Name(), err))
+			}
+			messages := map[string]string{}
+			if err := json.Unmarshal(content, &messages); err != nil {
+				panic(fmt.Sprintf("i18n: parsing catalog %s: %v", entry.Name(), err))
+			}
+			catalogs[lang] = messages
+		}
+	})
+}
+
+// Languages returns the languages with a catalog, sorted.
+func Languages() []string {
+	load()
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// Supported reports whether lang, normalized, has a catalog.
+func Supported(lang string) bool {
+	load()
+	_, ok := catalogs[Normalize(lang)]
+	return ok
+}
+
+// Normalize reduces a language tag or locale such as "fr_FR.UTF-8" or
+// "fr-CA" to its lower-case language code.
+func Normalize(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if i := strings.IndexAny(tag, ".@"); i >= 0 {
+		tag = tag[:i]
+	}
+	if i := strings.IndexAny(tag, "_-"); i >= 0 {
+		tag = tag[:i]
+	}
+	return strings.ToLower(tag)
+}
+
+// Detect returns the language of messages: flag when set, else $PPLX_LANG,
+// else the locale from $LC_ALL, $LC_MESSAGES, or $LANG. The first one set
+// wins even when it is not supported, as with locale resolution; the C and
+// POSIX locales and unsupported languages select English.
+func Detect(flag string) string {
+	candidates := []string{flag}
+	for _, name := range []string{EnvLang, "LC_ALL", "LC_MESSAGES", "LANG"} {
+		candidates = append(candidates, os.Getenv(name))
+	}
+	for _, c := range candidates {
+		if strings.TrimSpace(c) == "" {
+			continue
+		}
+		if lang := Normalize(c); Supported(lang) {
+			return lang
+		}
+		return English
+	}
+	return English
+}
+
+// SetLanguage selects the language of messages and returns it. An
+// unsupported language selects English.
+func SetLanguage(lang string) string {
+	lang = Normalize(lang)
+	if !Supported(lang) {
+		lang = English
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current = lang
+	return lang
+}
+
+// Language returns the selected language of messages.
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Lookup returns the message key in the selected language, falling back to
+// English. ok is false when English has no such message either.
+func Lookup(key string) (string, bool) {
+	load()
+	if message, ok := catalogs[Language()][key]; ok {
+		return message, true
+	}
+	message, ok := catalogs[English][key]
+	return message, ok
+}
+
+// T returns the message key in the selected language, formatted with args
+// as by fmt.Sprintf when there are any. An unknown key is returned as is.
+func T(key string, args ...any) string {
+	message, ok := Lookup(key)
+	if !ok {
+		message = key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
@@ -0,0 +1,128 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+// setLanguage selects lang for the test and restores English after it.
+func setLanguage(t *testing.T, lang string) {
+	t.Helper()
+	SetLanguage(lang)
+	t.Cleanup(func() { SetLanguage(English) })
+}
+
+func TestCatalogs_CoverEnglish(t *testing.T) {
+	load()
+	english := catalogs[English]
+	if len(english) == 0 {
+		t.Fatal("the English catalog is empty")
+	}
+	if len(Languages()) < 2 {
+		t.Fatalf("Languages() = %v, want English and at least one translation", Languages())
+	}
+	for _, lang := range Languages() {
+		if lang == English {
+			continue
+		}
+		t.Run(lang, func(t *testing.T) {
+			for key, message := range english {
+				translated, ok := catalogs[lang][key]
+				if !ok {
+					t.Errorf("key %q is missing", key)
+					continue
+				}
+				if got, want := strings.Count(translated, "%"), strings.Count(message, "%"); got != want {
+					t.Errorf("key %q has %d format verbs, want %d like English", key, got, want)
+				}
+			}
+			for key := range catalogs[lang] {
+				if _, ok := english[key]; !ok {
+					t.Errorf("key %q is not in the English catalog", key)
+				}
+			}
+		})
+	}
+}
+
+func TestT(t *testing.T) {
+	setLanguage(t, "fr")
+	catalogs[English]["test.only.english"] = "only in English"
+	t.Cleanup(func() { delete(catalogs[English], "test.only.english") })
+
+	tests := []struct {
+		name string
+		key  string
+		args []any
+		want string
+	}{
+		{name: "translated", key: "wizard.yes", want: "Oui"},
+		{name: "formatted", key: "warnings.header.many", args: []any{3}, want: "⚠ 3 avertissements :"},
+		{name: "falls back to English", key: "test.only.english", want: "only in English"},
+		{name: "unknown key", key: "no.such.key", want: "no.such.key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := T(tt.key, tt.args...); got != tt.want {
+				t.Errorf("T(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{tag: "fr", want: "fr"},
+		{tag: "fr_FR.UTF-8", want: "fr"},
+		{tag: "FR-ca", want: "fr"},
+		{tag: "en_US@euro", want: "en"},
+		{tag: "C", want: "c"},
+		{tag: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.tag); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		flag string
+		env  map[string]string
+		want string
+	}{
+		{name: "nothing set", want: English},
+		{name: "LANG", env: map[string]string{"LANG": "fr_FR.UTF-8"}, want: "fr"},
+		{
+			name: "LC_ALL before LANG",
+			env:  map[string]string{"LC_ALL": "en_GB.UTF-8", "LANG": "fr_FR.UTF-8"},
+			want: English,
+		},
+		{name: "PPLX_LANG before LC_ALL", env: map[string]string{EnvLang: "fr", "LC_ALL": "en_US"}, want: "fr"},
+		{name: "flag first", flag: "en", env: map[string]string{EnvLang: "fr"}, want: English},
+		{name: "C locale", env: map[string]string{"LANG": "C"}, want: English},
+		{name: "unsupported", env: map[string]string{"LANG": "ja_JP.UTF-8"}, want: English},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{EnvLang, "LC_ALL", "LC_MESSAGES", "LANG"} {
+				t.Setenv(name, tt.env[name])
+			}
+			if got := Detect(tt.flag); got != tt.want {
+				t.Errorf("Detect(%q) = %q, want %q", tt.flag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetLanguage_Unsupported(t *testing.T) {
+	setLanguage(t, "de")
+	if Language() != English {
+		t.Errorf("Language() = %q after selecting an unsupported language, want English", Language())
+	}
+}
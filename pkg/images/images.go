@@ -0,0 +1,255 @@
+// Package images downloads the images returned with an answer, whose URLs
+// often expire soon after the response.
+//
+// Images are fetched concurrently by a bounded number of workers, each with
+// its own timeout. A failed or skipped image is reported in its Result and
+// never stops the others.
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// DefaultConcurrency is the number of images downloaded at once.
+	DefaultConcurrency = 4
+
+	// DefaultTimeout bounds the download of one image.
+	DefaultTimeout = 30 * time.Second
+
+	// MaxImageBytes is the largest image saved; larger ones fail.
+	MaxImageBytes = 20 << 20
+
+	// maxSlugLen bounds the part of file names derived from the query.
+	maxSlugLen = 40
+
+	// sniffLen is the number of bytes http.DetectContentType looks at.
+	sniffLen = 512
+
+	// maxNameAttempts bounds the search for a free file name.
+	maxNameAttempts = 100
+
+	dirPerms  = 0o755
+	filePerms = 0o644
+)
+
+// ErrFormatSkipped is the Result error of an image whose content type is not
+// one of Options.Formats.
+var ErrFormatSkipped = errors.New("format not in image formats")
+
+// Options configures Save.
+type Options struct {
+	// Dir is the directory images are saved in; it is created if needed.
+	Dir string
+	// Name is the file name prefix, usually Slug of the query. Files are
+	// named <Name>-<n>.<ext>, n being the position of the image from 1.
+	Name string
+	// Formats, when set, lists the accepted formats (jpg, png, ...), as
+	// --image-formats; other images are skipped.
+	Formats []string
+	// Max caps the number of images downloaded; zero is no cap.
+	Max int
+	// Concurrency is the number of workers; zero is DefaultConcurrency.
+	Concurrency int
+	// Timeout bounds each download; zero is DefaultTimeout.
+	Timeout time.Duration
+	// Client sends the requests; nil is http.DefaultClient.
+	Client *http.Client
+}
+
+// Result is the outcome of one image.
+type Result struct {
+	URL string
+	// Path is the file the image was saved to, empty when Err is set.
+	Path string
+	// Err is why the image was not saved. It wraps ErrFormatSkipped for
+	// skipped formats.
+	Err error
+}
+
+// Save downloads urls, at most opts.Max of them, into opts.Dir and returns
+// one Result per downloaded URL, in input order. The error is only for a
+// directory that cannot be created; failures of single images are in their
+// Result.
+func Save(ctx context.Context, urls []string, opts Options) ([]Result, error) {
+	if opts.Max > 0 && len(urls) > opts.Max {
+		urls = urls[:opts.Max]
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	if err := os.MkdirAll(opts.Dir, dirPerms); err != nil {
+		return nil, fmt.Errorf("failed to create image directory: %w", err)
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Name == "" {
+		opts.Name = "image"
+	}
+
+	results := make([]Result, len(urls))
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			path, err := download(ctx, u, i+1, opts)
+			results[i] = Result{URL: u, Path: path, Err: err}
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// download saves the image at u as the index-th image and returns its path.
+func download(ctx context.Context, u string, index int, opts Options) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // read-only body
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxImageBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+	if len(data) > MaxImageBytes {
+		return "", fmt.Errorf("image larger than %d MiB", MaxImageBytes>>20)
+	}
+
+	format, err := detectFormat(resp.Header.Get("Content-Type"), data)
+	if err != nil {
+		return "", err
+	}
+	if len(opts.Formats) > 0 && !acceptsFormat(opts.Formats, format) {
+		return "", fmt.Errorf("%w: %s", ErrFormatSkipped, format)
+	}
+
+	return writeFile(opts.Dir, opts.Name+"-"+strconv.Itoa(index), format, data)
+}
+
+// extensions maps image content types to file extensions, which are also
+// the format names of --image-formats.
+var extensions = map[string]string{
+	"image/jpeg":    "jpg",
+	"image/png":     "png",
+	"image/gif":     "gif",
+	"image/webp":    "webp",
+	"image/svg+xml": "svg",
+	"image/bmp":     "bmp",
+	"image/avif":    "avif",
+}
+
+// detectFormat returns the format of an image from its Content-Type header,
+// or from its first bytes when the header is missing or generic.
+func detectFormat(contentType string, data []byte) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || extensions[mediaType] == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data[:min(len(data), sniffLen)]))
+	}
+	format, ok := extensions[mediaType]
+	if !ok {
+		return "", fmt.Errorf("not an image: content type %q", contentType)
+	}
+	return format, nil
+}
+
+// acceptsFormat reports whether format is one of formats, jpg and jpeg
+// being the same format.
+func acceptsFormat(formats []string, format string) bool {
+	return slices.ContainsFunc(formats, func(f string) bool {
+		f = strings.ToLower(f)
+		return f == format || (f == "jpeg" && format == "jpg")
+	})
+}
+
+// writeFile writes data to dir/base.ext, or to dir/base-2.ext and so on
+// when the name is taken, and returns the path. Files are created
+// exclusively, so concurrent calls never share a name.
+func writeFile(dir, base, ext string, data []byte) (string, error) {
+	for attempt := 1; attempt <= maxNameAttempts; attempt++ {
+		name := base
+		if attempt > 1 {
+			name += "-" + strconv.Itoa(attempt)
+		}
+		path := filepath.Join(dir, name+"."+ext)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, filePerms)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to save image: %w", err)
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(path)
+			return "", fmt.Errorf("failed to save image: %w", err)
+		}
+		return path, nil
+	}
+	return "", fmt.Errorf("failed to save image: no free name for %s.%s in %s", base, ext, dir)
+}
+
+// Slug returns a file name prefix for query: its letters and digits,
+// lowercased, with words joined by dashes and cut to a reasonable length.
+// It is "image" when query has no letters or digits.
+func Slug(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	slug := ""
+	for _, w := range words {
+		next := w
+		if slug != "" {
+			next = slug + "-" + w
+		}
+		if len(next) > maxSlugLen {
+			if slug == "" {
+				slug = string([]rune(w)[:min(len([]rune(w)), maxSlugLen)])
+			}
+			break
+		}
+		slug = next
+	}
+	if slug == "" {
+		return "image"
+	}
+	return slug
+}
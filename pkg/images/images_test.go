@@ -0,0 +1,152 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	fakePNG  = []byte("\x89PNG\r\n\x1a\n fake png data")
+	fakeJPEG = []byte("\xff\xd8\xff\xe0 fake jpeg data")
+)
+
+func newImageServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(fakePNG)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, _ *http.Request) {
+		// No useful Content-Type: the format is sniffed.
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(fakeJPEG)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html></html>"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSave_PartialFailure(t *testing.T) {
+	srv := newImageServer(t)
+	dir := filepath.Join(t.TempDir(), "out")
+	urls := []string{srv.URL + "/a.png", srv.URL + "/missing", srv.URL + "/b", srv.URL + "/page"}
+
+	results, err := Save(context.Background(), urls, Options{Dir: dir, Name: "cats"})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if len(results) != len(urls) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(urls))
+	}
+
+	wantPaths := []string{filepath.Join(dir, "cats-1.png"), "", filepath.Join(dir, "cats-3.jpg"), ""}
+	for i, r := range results {
+		if r.URL != urls[i] {
+			t.Errorf("results[%d].URL = %q, want %q", i, r.URL, urls[i])
+		}
+		if r.Path != wantPaths[i] {
+			t.Errorf("results[%d].Path = %q, want %q", i, r.Path, wantPaths[i])
+		}
+		if (r.Err != nil) != (wantPaths[i] == "") {
+			t.Errorf("results[%d].Err = %v", i, r.Err)
+		}
+	}
+	if !strings.Contains(results[1].Err.Error(), "404") {
+		t.Errorf("404 error = %v, want status in message", results[1].Err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "cats-1.png"))
+	if err != nil || string(data) != string(fakePNG) {
+		t.Errorf("saved png = %q, %v", data, err)
+	}
+}
+
+func TestSave_Formats(t *testing.T) {
+	srv := newImageServer(t)
+	dir := t.TempDir()
+	urls := []string{srv.URL + "/a.png", srv.URL + "/b"}
+
+	results, err := Save(context.Background(), urls, Options{Dir: dir, Name: "x", Formats: []string{"JPEG"}})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if !errors.Is(results[0].Err, ErrFormatSkipped) {
+		t.Errorf("png error = %v, want ErrFormatSkipped", results[0].Err)
+	}
+	if results[1].Err != nil || filepath.Ext(results[1].Path) != ".jpg" {
+		t.Errorf("jpeg result = %+v, want a .jpg file", results[1])
+	}
+}
+
+func TestSave_Max(t *testing.T) {
+	srv := newImageServer(t)
+	urls := []string{srv.URL + "/a.png", srv.URL + "/b", srv.URL + "/a.png"}
+
+	results, err := Save(context.Background(), urls, Options{Dir: t.TempDir(), Max: 2, Concurrency: 1})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("len(results) = %d, want 2", len(results))
+	}
+}
+
+func TestSave_NoURLs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "never")
+	results, err := Save(context.Background(), nil, Options{Dir: dir})
+	if err != nil || results != nil {
+		t.Fatalf("Save(nil) = %v, %v", results, err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("directory created without images: %v", err)
+	}
+}
+
+func TestSave_NameConflict(t *testing.T) {
+	srv := newImageServer(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "q-1.png"), []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := Save(context.Background(), []string{srv.URL + "/a.png"}, Options{Dir: dir, Name: "q"})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if want := filepath.Join(dir, "q-1-2.png"); results[0].Path != want {
+		t.Errorf("Path = %q, want %q", results[0].Path, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "q-1.png")); string(data) != "old" {
+		t.Error("existing file was overwritten")
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"What is Go?", "what-is-go"},
+		{"  Café   au lait!! ", "café-au-lait"},
+		{"???", "image"},
+		{"", "image"},
+		{strings.Repeat("word ", 20), "word-word-word-word-word-word-word-word"},
+		{strings.Repeat("a", 50), strings.Repeat("a", 40)},
+	}
+	for _, tt := range tests {
+		if got := Slug(tt.query); got != tt.want {
+			t.Errorf("Slug(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
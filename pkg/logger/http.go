@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/security"
+)
+
+// MaxBodyBytes is the size above which logged request and response bodies
+// are truncated.
+const MaxBodyBytes = 4096
+
+// bearerPrefix starts the Authorization header sent to the API.
+const bearerPrefix = "Bearer "
+
+// HTTPTransport is an http.RoundTripper that logs every request and response
+// at debug level. The API key in the Authorization header is masked and bodies
+// larger than MaxBodyBytes are truncated. Streaming (text/event-stream)
+// response bodies are not read, so streaming is unaffected. When debug logging
+// is off, requests pass through untouched.
+type HTTPTransport struct {
+	Base http.RoundTripper
+}
+
+// NewHTTPTransport wraps base (http.DefaultTransport when nil).
+func NewHTTPTransport(base http.RoundTripper) *HTTPTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &HTTPTransport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *HTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !defaultLogger.Enabled(req.Context(), slog.LevelDebug) {
+		return t.Base.RoundTrip(req) //nolint:wrapcheck // transport errors are wrapped by http.Client
+	}
+
+	var body []byte
+	if req.Body != nil && req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(rc)
+			_ = rc.Close()
+		}
+	}
+	defaultLogger.Debug("API request",
+		"method", req.Method,
+		"url", req.URL.String(),
+		"authorization", redactAuthorization(req.Header.Get("Authorization")),
+		"body", TruncateBody(body))
+
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		defaultLogger.Debug("API request failed", "duration", time.Since(start), "error", err)
+		return nil, err //nolint:wrapcheck // transport errors are wrapped by http.Client
+	}
+
+	attrs := []any{"status", resp.StatusCode, "duration", time.Since(start)}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		defaultLogger.Debug("API response", append(attrs, "body", "(stream)")...)
+		return resp, nil
+	}
+	respBody, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	if readErr != nil {
+		attrs = append(attrs, "read_error", readErr)
+	}
+	defaultLogger.Debug("API response", append(attrs, "body", TruncateBody(respBody))...)
+	return resp, nil
+}
+
+// TruncateBody returns body as a string with potential API keys masked,
+// cut to MaxBodyBytes with a note of how much was dropped.
+func TruncateBody(body []byte) string {
+	s := string(body)
+	if len(body) > MaxBodyBytes {
+		s = fmt.Sprintf("%s… (%d more bytes)", strings.ToValidUTF8(s[:MaxBodyBytes], ""), len(body)-MaxBodyBytes)
+	}
+	return security.SanitizeString(s)
+}
+
+// redactAuthorization masks the token of a bearer Authorization header.
+func redactAuthorization(value string) string {
+	if value == "" {
+		return ""
+	}
+	return bearerPrefix + security.MaskAPIKey(strings.TrimPrefix(value, bearerPrefix))
+}
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureDebug routes debug logs to a buffer for the duration of the test.
+func captureDebug(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	Init(LevelDebug, FormatJSON, &buf)
+	t.Cleanup(func() { Init(LevelInfo, FormatText, os.Stderr) })
+	return &buf
+}
+
+func TestHTTPTransport_LogsRedactedRequest(t *testing.T) {
+	const apiKey = "pplx-abcdefghijklmnopqrstuvwxyz0123456789"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"1","answer":"`+strings.Repeat("x", 2*MaxBodyBytes)+`"}`)
+	}))
+	defer srv.Close()
+	buf := captureDebug(t)
+
+	client := &http.Client{Transport: NewHTTPTransport(nil)}
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"model":"sonar"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if len(body) <= MaxBodyBytes {
+		t.Errorf("caller got a truncated body (%d bytes)", len(body))
+	}
+	logs := buf.String()
+	if strings.Contains(logs, apiKey) {
+		t.Errorf("API key leaked into logs: %s", logs)
+	}
+	for _, want := range []string{`"msg":"API request"`, `"msg":"API response"`, `{\"model\":\"sonar\"}`, "more bytes"} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs missing %q:\n%s", want, logs)
+		}
+	}
+}
+
+func TestHTTPTransport_SkipsStreamBodies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: secret-chunk\n\n")
+	}))
+	defer srv.Close()
+	buf := captureDebug(t)
+
+	client := &http.Client{Transport: NewHTTPTransport(nil)}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if string(body) != "data: secret-chunk\n\n" {
+		t.Errorf("stream body = %q", body)
+	}
+	if logs := buf.String(); strings.Contains(logs, "secret-chunk") || !strings.Contains(logs, "(stream)") {
+		t.Errorf("stream body should not be logged:\n%s", logs)
+	}
+}
+
+func TestTruncateBody(t *testing.T) {
+	if got := TruncateBody([]byte("short")); got != "short" {
+		t.Errorf("TruncateBody(short) = %q", got)
+	}
+	got := TruncateBody([]byte(strings.Repeat("a", MaxBodyBytes+10)))
+	if !strings.HasSuffix(got, "(10 more bytes)") {
+		t.Errorf("TruncateBody should note the dropped bytes, got suffix %q", got[len(got)-20:])
+	}
+}
@@ -0,0 +1,329 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// chatCreatePrompt stands in for the user_prompt the parameter extractor and
+// the validation of chat_create require; the messages of a session come with
+// chat_send.
+const chatCreatePrompt = "(chat session)"
+
+// ChatCreateResult is the result of the chat_create tool.
+type ChatCreateResult struct {
+	SessionID string             `json:"session_id"`
+	Model     string             `json:"model"`
+	Warnings  []warnings.Warning `json:"warnings"`
+}
+
+// ChatSendResult is the result of the chat_send tool.
+type ChatSendResult struct {
+	SessionID string `json:"session_id"`
+	// Turns is the number of answered messages in the session, this one
+	// included.
+	Turns int `json:"turns"`
+	// Reply is the answer, in the payload of the query tool.
+	Reply *output.Result `json:"reply"`
+	// SessionUsage is the cumulative usage of the session.
+	SessionUsage ChatUsage `json:"session_usage"`
+}
+
+// ChatUsage is the cumulative token usage of a chat session.
+type ChatUsage struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// ChatDeleteResult is the result of the chat_delete tool.
+type ChatDeleteResult struct {
+	SessionID string `json:"session_id"`
+	Deleted   bool   `json:"deleted"`
+}
+
+// BuildChatCreateTool creates the MCP tool definition that starts a chat
+// session. It accepts the parameters of the query tool except user_prompt,
+// the messages coming with chat_send, and stream, answers being returned
+// whole.
+func BuildChatCreateTool() *mcp.Tool {
+	opts := append([]mcp.ToolOption{
+		mcp.WithDescription("Create a conversation kept by the server and return its session_id. " +
+			"Send messages with chat_send: the server keeps the history, so each call sends only the " +
+			"new message. Accepts the options of the query tool, which apply to every message; " +
+			"system_prompt starts the conversation. Idle sessions expire; call chat_delete when done."),
+	}, queryParameterOptions("Model to use (default: the server's default model)")...)
+	tool := mcp.NewTool("chat_create", opts...)
+	delete(tool.InputSchema.Properties, "user_prompt")
+	delete(tool.InputSchema.Properties, "stream")
+	tool.InputSchema.Required = slices.DeleteFunc(tool.InputSchema.Required, func(name string) bool {
+		return name == "user_prompt"
+	})
+	return &tool
+}
+
+// BuildChatSendTool creates the MCP tool definition that sends a message in
+// a chat session.
+func BuildChatSendTool() *mcp.Tool {
+	tool := mcp.NewTool("chat_send",
+		mcp.WithDescription("Send a message in a session created with chat_create and return the answer, "+
+			"in the payload of the query tool, with the usage of the session so far. The message and "+
+			"the answer are added to the session history. Messages sent to the same session at once "+
+			"are answered one after the other."),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("Session ID returned by chat_create"),
+		),
+		mcp.WithString("message",
+			mcp.Required(),
+			mcp.Description("The user message"),
+		),
+	)
+	return &tool
+}
+
+// BuildChatDeleteTool creates the MCP tool definition that ends a chat
+// session.
+func BuildChatDeleteTool() *mcp.Tool {
+	tool := mcp.NewTool("chat_delete",
+		mcp.WithDescription("Delete a session created with chat_create and free its history. "+
+			"Does not call the Perplexity API."),
+		mcp.WithDestructiveHintAnnotation(true),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("Session ID returned by chat_create"),
+		),
+	)
+	return &tool
+}
+
+// AddChatTools registers the chat_create, chat_send and chat_delete tools,
+// which keep conversations on the server so that clients do not resend them
+// with every message.
+func (s *MCPServer) AddChatTools() error {
+	s.server.AddTool(*BuildChatCreateTool(), s.handleChatCreate)
+	s.server.AddTool(*BuildChatSendTool(), s.handleChatSend)
+	s.server.AddTool(*BuildChatDeleteTool(), s.handleChatDelete)
+	return nil
+}
+
+// handleChatCreate validates the options of the arguments and starts a
+// session with them.
+func (s *MCPServer) handleChatCreate(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := maps.Clone(request.GetArguments())
+	if args == nil {
+		args = map[string]any{}
+	}
+	args["user_prompt"] = chatCreatePrompt
+	args["stream"] = false
+	start := time.Now()
+	name := request.Params.Name
+
+	params, err := s.extract(args)
+	if err != nil {
+		logToolCall(name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	warns := warnings.New()
+	session, err := s.handler.newChat(s.apiKey, *params, warns)
+	if err != nil {
+		logToolCall(name, params.Model, start, nil, err)
+		return toolErrorResult(err), nil
+	}
+	id, err := s.sessions.create(session)
+	logToolCall(name, params.Model, start, nil, err)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return s.formatter.marshal(ChatCreateResult{
+		SessionID: id,
+		Model:     session.chat.Model(),
+		Warnings:  warns.All(),
+	})
+}
+
+// handleChatSend sends the message of the arguments in their session.
+func (s *MCPServer) handleChatSend(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	start := time.Now()
+	name := request.Params.Name
+
+	id, ok := args["session_id"].(string)
+	if !ok || id == "" {
+		err := NewParameterError("session_id", args["session_id"], "must be a non-empty string")
+		logToolCall(name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	message, ok := args["message"].(string)
+	if !ok || message == "" {
+		err := NewParameterError("message", args["message"], "must be a non-empty string")
+		logToolCall(name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var result ChatSendResult
+	err := s.sessions.use(id, func(session *chatSession) error {
+		warns := warnings.New()
+		response, err := s.handler.sendChat(warnings.NewContext(ctx, warns), session, message)
+		logToolCall(name, session.chat.Model(), start, response, err)
+		if err != nil {
+			return err
+		}
+		reply := output.FromResponse(response, time.Since(start))
+		reply.Warnings = append(reply.Warnings, warns.All()...)
+		totals := session.chat.Totals()
+		result = ChatSendResult{
+			SessionID: id,
+			Turns:     answered(session.chat),
+			Reply:     reply,
+			SessionUsage: ChatUsage{
+				Requests:         totals.Requests,
+				PromptTokens:     totals.PromptTokens,
+				CompletionTokens: totals.CompletionTokens,
+				TotalTokens:      totals.TotalTokens,
+				CostUSD:          totals.Cost,
+			},
+		}
+		return nil
+	})
+	if errors.Is(err, clerrors.ErrSessionNotFound) {
+		logToolCall(name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err != nil {
+		// Already logged with the model of the session.
+		return toolErrorResult(err), nil
+	}
+
+	return s.formatter.marshal(result)
+}
+
+// handleChatDelete drops the session of the arguments.
+func (s *MCPServer) handleChatDelete(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	start := time.Now()
+	name := request.Params.Name
+
+	id, ok := args["session_id"].(string)
+	if !ok || id == "" {
+		err := NewParameterError("session_id", args["session_id"], "must be a non-empty string")
+		logToolCall(name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	err := s.sessions.remove(id)
+	logToolCall(name, "", start, nil, err)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return s.formatter.marshal(ChatDeleteResult{SessionID: id, Deleted: true})
+}
+
+// newChat starts the conversation of a chat session with the options of
+// params, sent with the key of its api_key or key_id argument or else with
+// apiKey. The options are validated as for a query; params.UserPrompt only
+// takes part in the validation.
+func (h *QueryHandler) newChat(apiKey string, params QueryParams, warns *warnings.Collector) (*chatSession, error) {
+	if err := h.validateParameters(params, warns); err != nil {
+		return nil, err
+	}
+	apiKey, err := h.resolveAPIKey(apiKey, params)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &chatSession{keyID: params.KeyID, budget: &budgetHook{handler: h}}
+	if params.APIKey != "" {
+		session.keyID = usageKeyIDCaller
+	}
+	session.chat = chat.NewChatWithOptions(h.newClient(apiKey, params.Timeout), params.SystemPrompt,
+		params.chatOptions())
+	// The budget is checked before the hooks run, as for a query.
+	session.chat.Use(session.budget)
+	for _, hook := range h.hooks {
+		session.chat.Use(hook)
+	}
+	return session, nil
+}
+
+// sendChat adds message to the conversation of session and sends it once
+// the rate limit allows. The answer is added to the conversation and
+// returned, and its usage tracked as for a query. When the request fails,
+// message is removed again so that the conversation can go on. Warnings go
+// to the collector of ctx.
+func (h *QueryHandler) sendChat(
+	ctx context.Context,
+	session *chatSession,
+	message string,
+) (*perplexity.CompletionResponse, error) {
+	c := session.chat
+	if err := c.AddUserMessage(message); err != nil {
+		return nil, err //nolint:wrapcheck // already wrapped by chat
+	}
+	if err := h.limiter.Wait(ctx); err != nil {
+		dropPending(c)
+		return nil, err //nolint:wrapcheck // wraps the context error
+	}
+
+	warns := warnings.FromContext(ctx)
+	session.budget.warns = warns
+	response, err := c.SendContext(ctx, func(o *chat.Options) { o.Warnings = warns })
+	// An interrupted answer is kept, and its usage tracked, with the error.
+	usage.TrackKey(usage.SourceMCP, session.keyID, response)
+	if err != nil {
+		dropPending(c)
+		return nil, err //nolint:wrapcheck // already wrapped by chat
+	}
+	return response, nil
+}
+
+// budgetHook checks each request of a chat session against the budget of
+// handler, as Handle does for a query. Its warnings go to warns, which is
+// set for each call; calls on a session run one at a time.
+type budgetHook struct {
+	handler *QueryHandler
+	warns   *warnings.Collector
+}
+
+// BeforeRequest implements hooks.Hook.
+func (b *budgetHook) BeforeRequest(req *perplexity.CompletionRequest) error {
+	return b.handler.checkBudget(req, b.warns)
+}
+
+// AfterResponse implements hooks.Hook.
+func (b *budgetHook) AfterResponse(*perplexity.CompletionResponse, error) {}
+
+// dropPending removes the last message of c when it is a question without
+// an answer.
+func dropPending(c *chat.Chat) {
+	messages := c.Messages.GetMessages()
+	if len(messages) > 0 && messages[len(messages)-1].Role == "user" {
+		_ = c.TruncateLast(1)
+	}
+}
+
+// answered returns the number of answers in the conversation of c.
+func answered(c *chat.Chat) int {
+	n := 0
+	for _, msg := range c.Messages.GetMessages() {
+		if msg.Role == "assistant" {
+			n++
+		}
+	}
+	return n
+}
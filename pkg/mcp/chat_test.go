@@ -0,0 +1,267 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/pplx/pkg/retry"
+)
+
+// chatAPI is a Perplexity endpoint answering each request with the number
+// of messages it received, and failing the requests whose last message is
+// "fail".
+type chatAPI struct {
+	mu       sync.Mutex
+	requests [][]map[string]string
+}
+
+func (a *chatAPI) serve(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Messages []map[string]string `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	a.requests = append(a.requests, body.Messages)
+	a.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if body.Messages[len(body.Messages)-1]["content"] == "fail" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"message":"bad request","type":"invalid_request","code":400}}`))
+		return
+	}
+	_, _ = fmt.Fprintf(w, `{"id":"1","model":"sonar",`+
+		`"choices":[{"message":{"role":"assistant","content":"answer to %d messages"}}],`+
+		`"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`, len(body.Messages))
+}
+
+// sent returns the messages of the requests received so far.
+func (a *chatAPI) sent() [][]map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([][]map[string]string(nil), a.requests...)
+}
+
+// newChatServer returns a server sending to a chatAPI, without retries.
+func newChatServer(t *testing.T) (*MCPServer, *chatAPI) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	api := &chatAPI{}
+	srv := httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(srv.Close)
+
+	policy := retry.NewPolicy(0, 0)
+	s, err := NewServer(ServerConfig{APIKey: "test-key", Endpoint: srv.URL, RetryPolicy: &policy})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	t.Cleanup(s.jobs.Shutdown)
+	if err := s.AddChatTools(); err != nil {
+		t.Fatalf("AddChatTools() error: %v", err)
+	}
+	return s, api
+}
+
+// callChatTool calls handler with args and decodes the JSON of a successful
+// result into payload. It returns the text of the result and whether it is an
+// error.
+func callChatTool(t *testing.T, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error),
+	args map[string]any, payload any,
+) (string, bool) {
+	t.Helper()
+	var req mcp.CallToolRequest
+	req.Params.Arguments = args
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+	if !result.IsError && payload != nil {
+		if err := json.Unmarshal([]byte(text), payload); err != nil {
+			t.Fatalf("invalid JSON %q: %v", text, err)
+		}
+	}
+	return text, result.IsError
+}
+
+func TestMCPServer_ChatConversation(t *testing.T) {
+	s, api := newChatServer(t)
+
+	var created ChatCreateResult
+	if text, isErr := callChatTool(t, s.handleChatCreate, map[string]any{
+		"model":          "sonar",
+		"system_prompt":  "Be brief.",
+		"search_recency": "week",
+	}, &created); isErr {
+		t.Fatalf("chat_create returned an error result: %s", text)
+	}
+	if created.SessionID == "" || created.Model != "sonar" {
+		t.Fatalf("chat_create payload = %+v", created)
+	}
+	if n := len(api.sent()); n != 0 {
+		t.Errorf("chat_create sent %d requests, want none", n)
+	}
+
+	for turn := 1; turn <= 3; turn++ {
+		var sent ChatSendResult
+		text, isErr := callChatTool(t, s.handleChatSend, map[string]any{
+			"session_id": created.SessionID,
+			"message":    fmt.Sprintf("question %d", turn),
+		}, &sent)
+		if isErr {
+			t.Fatalf("chat_send #%d returned an error result: %s", turn, text)
+		}
+		// The system message, the earlier exchanges, and the new message.
+		messages := 2 * turn
+		if want := fmt.Sprintf("answer to %d messages", messages); sent.Reply == nil || sent.Reply.Content != want {
+			t.Errorf("chat_send #%d reply = %+v, want %q", turn, sent.Reply, want)
+		}
+		if sent.Turns != turn || sent.SessionUsage.Requests != turn || sent.SessionUsage.TotalTokens != 15*turn {
+			t.Errorf("chat_send #%d payload = %+v, want %d turns and their usage", turn, sent, turn)
+		}
+		if sent.Reply != nil && sent.Reply.Usage.TotalTokens != 15 {
+			t.Errorf("chat_send #%d reply usage = %+v, want the usage of the request", turn, sent.Reply.Usage)
+		}
+	}
+
+	requests := api.sent()
+	if len(requests) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(requests))
+	}
+	last := requests[2]
+	var roles, contents []string
+	for _, msg := range last {
+		roles = append(roles, msg["role"])
+		contents = append(contents, msg["content"])
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,user,assistant,user" {
+		t.Errorf("roles of the third request = %s, want the whole conversation", got)
+	}
+	if contents[0] != "Be brief." || contents[1] != "question 1" || contents[2] != "answer to 2 messages" ||
+		contents[5] != "question 3" {
+		t.Errorf("messages of the third request = %q", contents)
+	}
+
+	var deleted ChatDeleteResult
+	if text, isErr := callChatTool(t, s.handleChatDelete,
+		map[string]any{"session_id": created.SessionID}, &deleted); isErr || !deleted.Deleted {
+		t.Fatalf("chat_delete = %s, want the session deleted", text)
+	}
+	text, isErr := callChatTool(t, s.handleChatSend,
+		map[string]any{"session_id": created.SessionID, "message": "still there?"}, nil)
+	if !isErr || !strings.Contains(text, "chat session not found") {
+		t.Errorf("chat_send after chat_delete = %s, want session not found", text)
+	}
+	if s.sessions.Len() != 0 {
+		t.Errorf("%d sessions kept after chat_delete, want none", s.sessions.Len())
+	}
+}
+
+func TestMCPServer_ChatFailedSend(t *testing.T) {
+	s, api := newChatServer(t)
+
+	var created ChatCreateResult
+	if text, isErr := callChatTool(t, s.handleChatCreate, nil, &created); isErr {
+		t.Fatalf("chat_create returned an error result: %s", text)
+	}
+	send := func(message string) (ChatSendResult, bool) {
+		var sent ChatSendResult
+		_, isErr := callChatTool(t, s.handleChatSend,
+			map[string]any{"session_id": created.SessionID, "message": message}, &sent)
+		return sent, isErr
+	}
+
+	if _, isErr := send("question"); isErr {
+		t.Fatal("chat_send returned an error result")
+	}
+	if _, isErr := send("fail"); !isErr {
+		t.Fatal("chat_send of a failed request should return an error result")
+	}
+	// The failed message is not part of the conversation.
+	sent, isErr := send("again")
+	if isErr || sent.Turns != 2 {
+		t.Fatalf("chat_send after a failure = %+v, want the second turn", sent)
+	}
+	if last := api.sent()[2]; len(last) != 3 || last[2]["content"] != "again" {
+		t.Errorf("messages after a failure = %v, want the first exchange and the new message", last)
+	}
+}
+
+func TestMCPServer_ChatSessionExpires(t *testing.T) {
+	s, api := newChatServer(t)
+	now, advance := fakeClock()
+	s.sessions.now = now
+
+	var created ChatCreateResult
+	if text, isErr := callChatTool(t, s.handleChatCreate, map[string]any{"model": "sonar"}, &created); isErr {
+		t.Fatalf("chat_create returned an error result: %s", text)
+	}
+	args := map[string]any{"session_id": created.SessionID, "message": "question"}
+	if text, isErr := callChatTool(t, s.handleChatSend, args, nil); isErr {
+		t.Fatalf("chat_send returned an error result: %s", text)
+	}
+
+	advance(DefaultSessionTTL + time.Second)
+	text, isErr := callChatTool(t, s.handleChatSend, args, nil)
+	if !isErr || !strings.Contains(text, "chat session not found") {
+		t.Errorf("chat_send after the TTL = %s, want session not found", text)
+	}
+	if n := len(api.sent()); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestMCPServer_ChatInvalidArguments(t *testing.T) {
+	s, _ := newChatServer(t)
+
+	tests := []struct {
+		name    string
+		handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)
+		args    map[string]any
+	}{
+		{"create with an invalid option", s.handleChatCreate, map[string]any{"search_recency": "decade"}},
+		{"create with an unknown key_id", s.handleChatCreate, map[string]any{"key_id": "nope"}},
+		{"send without a session", s.handleChatSend, map[string]any{"message": "q"}},
+		{"send without a message", s.handleChatSend, map[string]any{"session_id": "abc"}},
+		{"send to an unknown session", s.handleChatSend, map[string]any{"session_id": "abc", "message": "q"}},
+		{"delete an unknown session", s.handleChatDelete, map[string]any{"session_id": "abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if text, isErr := callChatTool(t, tt.handler, tt.args, nil); !isErr {
+				t.Errorf("result = %s, want an error result", text)
+			}
+		})
+	}
+	if s.sessions.Len() != 0 {
+		t.Errorf("%d sessions created, want none", s.sessions.Len())
+	}
+}
+
+func TestBuildChatCreateTool(t *testing.T) {
+	tool := BuildChatCreateTool()
+	for _, name := range []string{"user_prompt", "stream"} {
+		if _, ok := tool.InputSchema.Properties[name]; ok {
+			t.Errorf("chat_create has a %s parameter", name)
+		}
+	}
+	if len(tool.InputSchema.Required) != 0 {
+		t.Errorf("chat_create requires %v, want nothing", tool.InputSchema.Required)
+	}
+	for _, name := range []string{"system_prompt", "model", "search_recency", "api_key"} {
+		if _, ok := tool.InputSchema.Properties[name]; !ok {
+			t.Errorf("chat_create has no %s parameter", name)
+		}
+	}
+}
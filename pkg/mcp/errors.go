@@ -4,6 +4,8 @@ package mcp
 
 import (
 	"fmt"
+	"time"
+	"unicode/utf8"
 
 	"github.com/sgaunet/pplx/pkg/clerrors"
 	"github.com/sgaunet/pplx/pkg/security"
@@ -41,6 +43,21 @@ func (e *ParameterError) Error() string {
 type StreamError struct {
 	Message string
 	Err     error
+	// Partial is the answer received before the stream failed, if any.
+	Partial string
+	// Idle is how long the stream went without data before it was
+	// aborted; zero when it did not stall.
+	Idle time.Duration
+}
+
+// newStallError returns the StreamError of a stream aborted after idle
+// without data, keeping the content received before it.
+func newStallError(idle time.Duration, partial string) *StreamError {
+	return &StreamError{
+		Message: fmt.Sprintf("no data received for %s, aborted after %d characters", idle, utf8.RuneCountInString(partial)),
+		Partial: partial,
+		Idle:    idle,
+	}
 }
 
 // NewStreamError creates a new stream error.
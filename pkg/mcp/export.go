@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/pplx/pkg/export"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// ExportResult is the result of the export_request tool.
+type ExportResult struct {
+	Format string `json:"format"`
+	// Request is the request body in Format.
+	Request json.RawMessage `json:"request"`
+	// Dropped lists the request fields Format cannot carry.
+	Dropped  []string           `json:"dropped"`
+	Warnings []warnings.Warning `json:"warnings"`
+}
+
+// BuildExportRequestTool creates the MCP tool definition that builds a query
+// request without sending it and returns it in a chosen wire format. It
+// accepts the parameters of the query tool and format.
+func BuildExportRequestTool() *mcp.Tool {
+	opts := append([]mcp.ToolOption{
+		mcp.WithDescription("Build the request a query call would send, without sending it, and return " +
+			"it in the chosen wire format: openai for an OpenAI-compatible chat/completions body, which " +
+			"drops the Perplexity-only fields and lists them, or perplexity. Does not call the Perplexity API."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("format",
+			mcp.Description("Wire format of the request (default: "+export.FormatOpenAI+")"),
+			mcp.Enum(export.Formats()...),
+		),
+	}, queryParameterOptions("Model to use (default: the server's default model)")...)
+	tool := mcp.NewTool("export_request", opts...)
+	return &tool
+}
+
+// AddExportTool registers the export_request tool.
+func (s *MCPServer) AddExportTool() error {
+	s.server.AddTool(*BuildExportRequestTool(), s.handleExportRequest)
+	return nil
+}
+
+// handleExportRequest builds the request of the arguments and returns it
+// translated to their format.
+func (s *MCPServer) handleExportRequest(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	start := time.Now()
+	name := request.Params.Name
+
+	params, err := s.extract(args)
+	if err != nil {
+		logToolCall(name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	format := export.FormatOpenAI
+	if value, ok := args["format"].(string); ok && value != "" {
+		format = value
+	}
+
+	warns := warnings.New()
+	req, err := s.handler.buildRequest(*params, warns)
+	if err != nil {
+		logToolCall(name, params.Model, start, nil, err)
+		return toolErrorResult(err), nil
+	}
+	payload, err := export.Request(req, format)
+	logToolCall(name, params.Model, start, nil, err)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	dropped := payload.Dropped
+	if dropped == nil {
+		dropped = []string{}
+	}
+	return s.formatter.marshal(ExportResult{
+		Format:   payload.Format,
+		Request:  payload.Body,
+		Dropped:  dropped,
+		Warnings: warns.All(),
+	})
+}
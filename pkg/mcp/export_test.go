@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/pplx/pkg/export"
+)
+
+func TestMCPServer_ExportRequest(t *testing.T) {
+	server, err := NewServer(ServerConfig{APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	call := func(args map[string]any) (ExportResult, bool) {
+		t.Helper()
+		var req mcp.CallToolRequest
+		req.Params.Arguments = args
+		result, err := server.handleExportRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		var payload ExportResult
+		if !result.IsError {
+			text := result.Content[0].(mcp.TextContent).Text
+			if err := json.Unmarshal([]byte(text), &payload); err != nil {
+				t.Fatalf("invalid JSON %q: %v", text, err)
+			}
+		}
+		return payload, result.IsError
+	}
+
+	got, isErr := call(map[string]any{"user_prompt": "What is Go?", "search_recency": "week"})
+	if isErr {
+		t.Fatal("export_request returned an error result")
+	}
+	if got.Format != export.FormatOpenAI || !slices.Contains(got.Dropped, "search_recency_filter") {
+		t.Errorf("result = %+v, want the openai format dropping search_recency_filter", got)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(got.Request, &body); err != nil || body["messages"] == nil {
+		t.Errorf("request = %s, want an OpenAI body with messages (%v)", got.Request, err)
+	}
+
+	got, isErr = call(map[string]any{"user_prompt": "What is Go?", "format": export.FormatPerplexity})
+	if isErr || got.Format != export.FormatPerplexity || len(got.Dropped) != 0 {
+		t.Errorf("perplexity export = %+v (error %v), want nothing dropped", got, isErr)
+	}
+
+	if _, isErr := call(map[string]any{"user_prompt": "What is Go?", "format": "xml"}); !isErr {
+		t.Error("an unknown format should return an error result")
+	}
+}
@@ -2,397 +2,278 @@ package mcp
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"strings"
 	"time"
 
 	"github.com/sgaunet/perplexity-go/v2"
-	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/hooks"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/pplx"
+	"github.com/sgaunet/pplx/pkg/ratelimit"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/sgaunet/pplx/pkg/warnings"
 )
 
 // QueryHandler handles Perplexity query execution.
 type QueryHandler struct {
 	clientFactory func(apiKey string) *perplexity.Client
+	retryPolicy   retry.Policy
+	// timeouts holds the connect and response-header limits; the total
+	// timeout comes from each call's QueryParams.Timeout.
+	timeouts retry.Timeouts
+	// endpoint overrides the API endpoint of every client when set.
+	endpoint string
+	// strictModel rejects models missing from the model registry instead
+	// of logging a warning.
+	strictModel bool
+	// hooks run around every request, streaming or not.
+	hooks hooks.Chain
+	// limiter caps the rate of requests across all calls; nil is unlimited.
+	limiter *ratelimit.Limiter
+	// keys are the API keys selectable with the key_id argument (api.keys).
+	keys map[string]string
+	// dailyBudget is api.daily_budget_usd; zero is unlimited.
+	dailyBudget float64
+	// maxTokens is api.per_request_max_tokens; zero is no cap.
+	maxTokens int
+	// tracker holds the usage log the budget is checked against; nil is
+	// the default usage log.
+	tracker *usage.Tracker
+	// streamIdleTimeout aborts a streaming request that receives no data
+	// for that long (api.stream_idle_timeout); zero uses
+	// DefaultStreamIdleTimeout.
+	streamIdleTimeout time.Duration
 }
 
+// DefaultStreamIdleTimeout is how long a streaming request may go without
+// data before it is aborted, when api.stream_idle_timeout is unset.
+const DefaultStreamIdleTimeout = 60 * time.Second
+
+// usageKeyIDCaller is the key ID recorded in the usage log for calls made
+// with the api_key argument.
+const usageKeyIDCaller = "(api_key)"
+
 // NewQueryHandler creates a new query handler.
+// API calls are retried on 429/5xx responses using retry.DefaultPolicy.
 func NewQueryHandler() *QueryHandler {
 	return &QueryHandler{
 		clientFactory: perplexity.NewClient,
+		retryPolicy:   retry.DefaultPolicy(),
 	}
 }
 
-// Handle processes a query tool request.
+// Use registers hook to run around every request the handler sends, after
+// the hooks registered before it. Hooks must be registered before requests
+// are served.
+func (h *QueryHandler) Use(hook hooks.Hook) {
+	h.hooks = append(h.hooks, hook)
+}
+
+// Handle processes a query tool request. The request is sent with the key of
+// the api_key or key_id argument, or else with apiKey, the server's key. When
+// a rate limit is configured, the request waits for its turn until ctx ends.
+// Cancelling ctx, when the client cancels the call or the server shuts down,
+// aborts the request in flight.
 func (h *QueryHandler) Handle(
-	_ context.Context,
+	ctx context.Context,
 	apiKey string,
 	params QueryParams,
 ) (*perplexity.CompletionResponse, error) {
-	// Create Perplexity client
-	client := h.clientFactory(apiKey)
-	client.SetHTTPTimeout(params.Timeout)
-
-	// Build messages
-	msg := perplexity.NewMessages(perplexity.WithSystemMessage(params.SystemPrompt))
-	if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-		return nil, fmt.Errorf("failed to add user message: %w", err)
-	}
-
-	// Build request options
-	opts, err := h.buildRequestOptions(params, msg)
+	apiKey, err := h.resolveAPIKey(apiKey, params)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create and validate request
-	req := perplexity.NewCompletionRequest(opts...)
-	err = req.Validate()
-	if err != nil {
-		return nil, fmt.Errorf("request validation failed: %w", err)
-	}
-
-	// Execute request (streaming or non-streaming)
-	var response *perplexity.CompletionResponse
-	if params.Stream {
-		response, err = h.executeStreaming(client, req)
-	} else {
-		response, err = h.executeNonStreaming(client, req)
-	}
+	// Create Perplexity client
+	client := h.newClient(apiKey, params.Timeout)
 
+	warns := warnings.FromContext(ctx)
+	req, err := h.buildRequest(params, warns)
 	if err != nil {
 		return nil, err
 	}
 
-	if response == nil {
-		return nil, NewStreamError("no response received", nil)
-	}
-
-	return response, nil
-}
-
-// buildRequestOptions converts QueryParams to Perplexity request options.
-// This function handles the complex task of translating MCP tool parameters into the format
-// expected by the Perplexity API client, with parameter validation and compatibility handling.
-//
-// Design rationale: Centralized option building with validation
-// Rather than building options directly in the Handle method, this separation allows for:
-// - Early parameter validation before any API calls
-// - Explicit handling of parameter incompatibilities (e.g., search recency vs images)
-// - Date parsing with user-friendly MM/DD/YYYY format conversion
-// - Warning-level validation for unsupported features vs hard errors for invalid input
-//
-// Complexity sources (148 lines, cyclomatic 20+):
-// - 30+ optional parameters, each with conditional inclusion logic
-// - 4 date fields requiring MM/DD/YYYY parsing
-// - Image format validation with warning emission
-// - JSON schema parsing and validation
-// - Parameter incompatibility handling (images + search recency conflict)
-// - Model compatibility warnings (response formats, reasoning effort)
-//
-// Parameter incompatibility patterns:
-// - search_recency + return_images: API constraint - when images are requested,
-//   search recency filter must be explicitly disabled (empty string) to avoid API error.
-//   Rationale: Image search uses different indexing that doesn't support time filtering.
-// - response_format_json_schema + response_format_regex: Logical conflict - can only
-//   constrain output format with one schema type at a time.
-// - response formats + non-sonar models: API constraint - structured output formats
-//   only work with sonar model family.
-//
-// Warning vs error strategy:
-// - Hard errors: Invalid enum values, conflicting parameters, invalid JSON schema, date parse failures
-// - Warnings: Unsupported image formats (user might know better than our list),
-//   reasoning effort on non-deep-research models (degrades gracefully)
-//
-//nolint:gocognit,cyclop,funlen // Complexity inherent to building 30+ request options with validation
-func (h *QueryHandler) buildRequestOptions(
-	params QueryParams,
-	msg perplexity.Messages,
-) ([]perplexity.CompletionRequestOption, error) {
-	// Validate parameters first to fail fast before building options
-	// This catches invalid enum values and parameter conflicts early
-	if err := h.validateParameters(params); err != nil {
+	if err := h.checkBudget(req, warns); err != nil {
 		return nil, err
 	}
 
-	opts := []perplexity.CompletionRequestOption{
-		perplexity.WithMessages(msg.GetMessages()),
-		perplexity.WithModel(params.Model),
-		perplexity.WithFrequencyPenalty(params.FrequencyPenalty),
-		perplexity.WithMaxTokens(params.MaxTokens),
-		perplexity.WithPresencePenalty(params.PresencePenalty),
-		perplexity.WithTemperature(params.Temperature),
-		perplexity.WithTopK(params.TopK),
-		perplexity.WithTopP(params.TopP),
-	}
-
-	// Add search/web options
-	if len(params.SearchDomains) > 0 {
-		opts = append(opts, perplexity.WithSearchDomainFilter(params.SearchDomains))
+	if err := h.limiter.Wait(ctx); err != nil {
+		return nil, err //nolint:wrapcheck // wraps the context error
 	}
 
-	if params.SearchRecency != "" {
-		// API incompatibility: Search recency filter conflicts with image search
-		// Image indexing uses different backend that doesn't support temporal filtering
-		if params.ReturnImages {
-			// When images are requested, explicitly disable search recency filter (empty string)
-			// to prevent API error. User's time filter preference is ignored in this case.
-			opts = append(opts, perplexity.WithSearchRecencyFilter(""))
-		} else {
-			opts = append(opts, perplexity.WithSearchRecencyFilter(params.SearchRecency))
-		}
-	}
-
-	if params.LocationLat != 0 || params.LocationLon != 0 || params.LocationCountry != "" {
-		opts = append(opts, perplexity.WithUserLocation(params.LocationLat, params.LocationLon, params.LocationCountry))
+	if err := h.hooks.Before(req); err != nil {
+		return nil, err //nolint:wrapcheck // wraps clerrors.ErrRequestAborted
 	}
 
-	// Add response enhancement options
-	if params.ReturnImages {
-		opts = append(opts, perplexity.WithReturnImages(params.ReturnImages))
-		// Defensive: Explicitly disable search recency filter when images requested
-		// This ensures no time filter is applied even if one was set earlier,
-		// preventing API incompatibility. Redundant with check above but provides
-		// safety if parameter checking order changes in future refactoring.
-		opts = append(opts, perplexity.WithSearchRecencyFilter(""))
+	// Execute request (streaming or non-streaming), falling back on the
+	// next model while the model is unavailable
+	keyID := params.KeyID
+	if params.APIKey != "" {
+		keyID = usageKeyIDCaller
 	}
-
-	if params.ReturnRelated {
-		opts = append(opts, perplexity.WithReturnRelatedQuestions(params.ReturnRelated))
-	}
-
-	if params.Stream {
-		opts = append(opts, perplexity.WithStream(params.Stream))
-	}
-
-	// Add image filtering options
-	if len(params.ImageDomains) > 0 {
-		opts = append(opts, perplexity.WithImageDomainFilter(params.ImageDomains))
-	}
-
-	if len(params.ImageFormats) > 0 {
-		// Validate image formats with warnings, not errors
-		// Rationale: API format support may expand over time, and user may know about
-		// newly supported formats we haven't updated. Warning allows experimentation
-		// while guiding users toward known-good formats. This is a "be liberal in
-		// what you accept" strategy - let the API be the final validator.
-		for _, format := range params.ImageFormats {
-			if !config.ValidImageFormats[format] {
-				log.Printf("Warning: Image format '%s' may not be supported. "+
-					"Common formats are: jpg, jpeg, png, gif, webp, svg, bmp", format)
-			}
-		}
-		opts = append(opts, perplexity.WithImageFormatFilter(params.ImageFormats))
+	result, err := pplx.Send(ctx, client, req, pplx.SendOptions{
+		Fallbacks:   params.ModelFallbacks,
+		IdleTimeout: h.idleTimeout(),
+		Source:      usage.SourceMCP,
+		KeyID:       keyID,
+	})
+	response := result.Response
+	if err != nil {
+		response = nil
+		err = h.sendError(ctx, req, result.Response, err)
+	} else if result.FellBack() {
+		warns.Add(warnings.CodeModelFallback, result.Note(), "")
 	}
+	h.hooks.After(response, err)
 
-	// Add response format options
-	if params.ResponseFormatJSONSchema != "" {
-		// Parse JSON schema
-		var schema any
-		err := json.Unmarshal([]byte(params.ResponseFormatJSONSchema), &schema)
-		if err != nil {
-			return nil, NewValidationError("response_format_json_schema", params.ResponseFormatJSONSchema,
-				fmt.Sprintf("invalid JSON schema: %v", err))
-		}
-		opts = append(opts, perplexity.WithJSONSchemaResponseFormat(schema))
+	if err != nil {
+		return nil, err
 	}
 
-	if params.ResponseFormatRegex != "" {
-		opts = append(opts, perplexity.WithRegexResponseFormat(params.ResponseFormatRegex))
+	if response == nil {
+		return nil, NewStreamError("no response received", nil)
 	}
 
-	// Add search mode options
-	if params.SearchMode != "" {
-		opts = append(opts, perplexity.WithSearchMode(params.SearchMode))
-	}
+	return response, nil
+}
 
-	if params.SearchContextSize != "" {
-		opts = append(opts, perplexity.WithSearchContextSize(params.SearchContextSize))
+// newClient returns a client sending with apiKey to the endpoint of the
+// handler, with its retry policy and timeouts; timeout is the total timeout
+// of each request.
+func (h *QueryHandler) newClient(apiKey string, timeout time.Duration) *perplexity.Client {
+	client := h.clientFactory(apiKey)
+	if h.endpoint != "" {
+		client.SetEndpoint(h.endpoint)
 	}
+	timeouts := h.timeouts
+	timeouts.Total = timeout
+	retry.Configure(client, h.retryPolicy, timeouts)
+	return client
+}
 
-	// Add date filtering options
-	// Date format: MM/DD/YYYY chosen for user-friendliness
-	// Rationale: While ISO 8601 (YYYY-MM-DD) is technically superior, MM/DD/YYYY
-	// is more familiar to US users and matches common date picker formats.
-	// The API accepts time.Time so the string format is purely for UX.
-	if params.SearchAfterDate != "" {
-		date, err := time.Parse("01/02/2006", params.SearchAfterDate)
-		if err != nil {
-			return nil, NewValidationError("search_after_date", params.SearchAfterDate,
-				"invalid format, use MM/DD/YYYY")
-		}
-		opts = append(opts, perplexity.WithPublishedAfter(date))
+// buildRequest builds and validates the request of a query tool call with
+// pplx.BuildRequest, the request builder shared with the query command and
+// chat sessions.
+func (h *QueryHandler) buildRequest(params QueryParams, warns *warnings.Collector) (*perplexity.CompletionRequest, error) {
+	if err := h.validateParameters(params, warns); err != nil {
+		return nil, err
 	}
+	return pplx.BuildRequest(params.request(), warns) //nolint:wrapcheck // a *clerrors.ValidationError
+}
 
-	if params.SearchBeforeDate != "" {
-		date, err := time.Parse("01/02/2006", params.SearchBeforeDate)
-		if err != nil {
-			return nil, NewValidationError("search_before_date", params.SearchBeforeDate,
-				"invalid format, use MM/DD/YYYY")
-		}
-		opts = append(opts, perplexity.WithPublishedBefore(date))
+// checkBudget caps the max_tokens of req at api.per_request_max_tokens, then
+// returns a *clerrors.BudgetExceededError when the projected cost of req
+// would take today's spending over api.daily_budget_usd.
+func (h *QueryHandler) checkBudget(req *perplexity.CompletionRequest, warns *warnings.Collector) error {
+	if h.maxTokens > 0 && req.MaxTokens > h.maxTokens {
+		req.MaxTokens = h.maxTokens
 	}
-
-	if params.LastUpdatedAfter != "" {
-		date, err := time.Parse("01/02/2006", params.LastUpdatedAfter)
-		if err != nil {
-			return nil, NewValidationError("last_updated_after", params.LastUpdatedAfter,
-				"invalid format, use MM/DD/YYYY")
-		}
-		opts = append(opts, perplexity.WithLastUpdatedAfterFilter(date))
+	if h.dailyBudget <= 0 {
+		return nil
 	}
-
-	if params.LastUpdatedBefore != "" {
-		date, err := time.Parse("01/02/2006", params.LastUpdatedBefore)
-		if err != nil {
-			return nil, NewValidationError("last_updated_before", params.LastUpdatedBefore,
-				"invalid format, use MM/DD/YYYY")
-		}
-		opts = append(opts, perplexity.WithLastUpdatedBeforeFilter(date))
+	projected, _ := usage.ProjectCost(req.Model, chat.EstimateMessages(req.Messages), req.MaxTokens)
+	var err error
+	if h.tracker != nil {
+		err = h.tracker.CheckBudget(h.dailyBudget, projected)
+	} else {
+		err = usage.CheckBudget(h.dailyBudget, projected)
 	}
-
-	// Add deep research options
-	if params.ReasoningEffort != "" {
-		// Check if the model supports reasoning effort
-		if !strings.Contains(params.Model, "deep-research") {
-			log.Printf("Warning: reasoning-effort is only supported by sonar-deep-research model")
-		}
-		opts = append(opts, perplexity.WithReasoningEffort(params.ReasoningEffort))
+	var budgetErr *clerrors.BudgetExceededError
+	if err != nil && !errors.As(err, &budgetErr) {
+		// An unreadable usage log must not block requests.
+		warns.Add(warnings.CodeBudgetUnchecked, fmt.Sprintf("daily budget not checked: %v", err),
+			"check that the usage log ~/.local/share/pplx/usage.jsonl is readable")
+		return nil
 	}
-
-	return opts, nil
+	return err //nolint:wrapcheck // typed budget error
 }
 
-// validateParameters validates query parameters before option building.
-// Performs early validation of 5 distinct categories to fail fast with clear error messages.
-//
-// Validation categories and their relationships:
-// 1. Search recency enum validation (day, week, month, year, hour)
-//    - Independent validation, no cross-parameter dependencies
-//
-// 2. Response format conflict detection (json_schema vs regex)
-//    - Mutual exclusivity constraint: only one structured format type allowed
-//    - Rationale: API can't simultaneously validate against JSON schema AND regex pattern
-//
-// 3. Response format model compatibility (requires sonar models)
-//    - Dependency: response formats depend on model capability
-//    - Rationale: Only sonar model family implements structured output parsing
-//
-// 4. Search mode enum validation (web, academic)
-//    - Independent validation, affects search backend selection
-//
-// 5. Search context size and reasoning effort enum validation (low, medium, high)
-//    - Independent validations, control resource allocation for query processing
-//
-// Design choice: Map-based enum validation vs switch statements
-// Maps provide O(1) lookup and make valid values self-documenting in code.
-// Alternative switch/case approach would be more verbose and harder to maintain
-// as valid values change (requires modifying multiple case labels).
-//
-//nolint:cyclop // Complexity inherent to validating multiple parameter constraints
-func (h *QueryHandler) validateParameters(params QueryParams) error {
-	// Category 1: Search recency enum validation
-	if params.SearchRecency != "" {
-		if !config.ValidSearchRecency[params.SearchRecency] {
-			return NewValidationError("search_recency", params.SearchRecency,
-				"must be one of: day, week, month, year, hour")
+// resolveAPIKey returns the key a call is sent with: the api_key argument,
+// the api.keys entry named by the key_id argument, or defaultKey when the
+// call sets neither. An unknown key_id, or both arguments, is a
+// ParameterError. The key is never part of an error.
+func (h *QueryHandler) resolveAPIKey(defaultKey string, params QueryParams) (string, error) {
+	switch {
+	case params.APIKey != "" && params.KeyID != "":
+		return "", NewParameterError("key_id", params.KeyID, "cannot be combined with api_key")
+	case params.APIKey != "":
+		return params.APIKey, nil
+	case params.KeyID != "":
+		key, ok := h.keys[params.KeyID]
+		if !ok {
+			return "", NewParameterError("key_id", params.KeyID, "unknown key_id: not in the server's api.keys")
 		}
+		return key, nil
 	}
+	return defaultKey, nil
+}
 
-	// Category 2: Response format conflict detection
-	// Ensure user didn't specify both json_schema AND regex (mutual exclusivity)
-	if params.ResponseFormatJSONSchema != "" && params.ResponseFormatRegex != "" {
-		return NewValidationError("response_format", "",
-			"cannot use both json_schema and regex")
-	}
-
-	// Category 3: Response format model compatibility
-	// Structured output formats only work with sonar model family
-	if (params.ResponseFormatJSONSchema != "" || params.ResponseFormatRegex != "") &&
-		!strings.HasPrefix(params.Model, "sonar") {
-		return NewValidationError("response_format", "",
-			"only supported by sonar models")
-	}
-
-	// Category 4: Search mode enum validation
-	// Controls whether search uses web or academic (scholarly) backend
-	if params.SearchMode != "" {
-		if !config.ValidSearchModes[params.SearchMode] {
-			return NewValidationError("search_mode", params.SearchMode,
-				"must be one of: web, academic")
+// validateParameters validates query parameters before the request is built.
+// Beyond the checks of pplx.QueryRequest.Validate, which the CLI shares, it
+// checks what only the server knows: that key_id names a configured key
+// and excludes api_key, and that the model is known (a warning, or an error
+// with --strict-model).
+func (h *QueryHandler) validateParameters(params QueryParams, warns *warnings.Collector) error {
+	if _, err := h.resolveAPIKey("", params); err != nil {
+		return err
+	}
+
+	// The API may know models this build does not, so unknown models only
+	// warn unless the server runs with --strict-model.
+	if err := models.Check(params.Model); err != nil {
+		if h.strictModel {
+			return NewValidationError("model", params.Model, err.Error())
 		}
+		warns.Add(warnings.CodeUnknownModel, err.Error(), "run pplx models to list the known models")
 	}
 
-	// Category 5a: Search context size enum validation
-	// Controls how much context from search results is included in the query
-	// (low = less context/faster, high = more context/slower but potentially better answers)
-	if params.SearchContextSize != "" {
-		if !config.ValidContextSizes[params.SearchContextSize] {
-			return NewValidationError("search_context_size", params.SearchContextSize,
-				"must be one of: low, medium, high")
-		}
-	}
+	return params.request().Validate() //nolint:wrapcheck // a *clerrors.ValidationError
+}
 
-	// Category 5b: Reasoning effort enum validation
-	// Controls computational resources for deep-research model
-	// (low = faster/cheaper, high = slower/more thorough reasoning)
-	if params.ReasoningEffort != "" {
-		if !config.ValidReasoningEfforts[params.ReasoningEffort] {
-			return NewValidationError("reasoning_effort", params.ReasoningEffort,
-				"must be one of: low, medium, high")
-		}
+// idleTimeout returns how long a streamed answer may send nothing before
+// it is aborted.
+func (h *QueryHandler) idleTimeout() time.Duration {
+	if h.streamIdleTimeout <= 0 {
+		return DefaultStreamIdleTimeout
 	}
-
-	return nil
+	return h.streamIdleTimeout
 }
 
-// executeStreaming handles streaming response execution.
-//
-// Design rationale: Return last response, not concatenated content.
-// Perplexity's SSE stream sends cumulative responses where each event contains the
-// COMPLETE response so far, not just incremental deltas. Therefore:
-//   - The last response contains the final, complete answer + metadata (citations, etc.)
-//   - Earlier responses are intermediate states useful for UI rendering but not for the final result
+// sendError returns the error of req, which failed with err after partial,
+// the last event of a streamed answer, was received.
 //
-// Concurrency model:
-//   - Producer goroutine: runs perplexity.Client.StreamCompletion, closes the channel on return
-//   - Main goroutine: drains the channel, keeping only the last response, then checks the error
-//
-// Consuming in the main goroutine guarantees we never return while the producer is still running.
-func (h *QueryHandler) executeStreaming(
-	client *perplexity.Client,
-	req *perplexity.CompletionRequest,
-) (*perplexity.CompletionResponse, error) {
-	responseChannel := make(chan perplexity.CompletionResponse)
-	streamErrCh := make(chan error, 1)
-
-	go func() {
-		streamErrCh <- client.StreamCompletion(req, responseChannel)
-	}()
-
-	var lastResponse *perplexity.CompletionResponse
-	for res := range responseChannel {
-		lastResponse = &res
-	}
-
-	if err := <-streamErrCh; err != nil {
-		return nil, NewStreamError("streaming request failed", err)
-	}
-	if lastResponse == nil {
-		return nil, NewStreamError("no response received from stream", nil)
-	}
-	return lastResponse, nil
+// A streamed answer is read by pplx.Send in the calling goroutine, which
+// waits for the producer goroutine to return, so that nothing runs on once
+// Handle has returned. When ctx ends, or when no event arrives for the idle
+// timeout (a connection can stay open while the API stops sending), the
+// request is cancelled; a stall is a StreamError carrying the content
+// received so far.
+func (h *QueryHandler) sendError(ctx context.Context, req *perplexity.CompletionRequest,
+	partial *perplexity.CompletionResponse, err error,
+) error {
+	if !req.Stream {
+		return fmt.Errorf("request failed: %w", retry.ClassifyTimeout(err))
+	}
+	switch {
+	case errors.Is(err, clerrors.ErrStreamStalled):
+		return newStallError(h.idleTimeout(), streamedContent(partial))
+	case ctx.Err() != nil:
+		return NewStreamError("streaming request cancelled", ctx.Err())
+	case errors.Is(err, clerrors.ErrNoResponse):
+		return NewStreamError("no response received from stream", nil)
+	}
+	return NewStreamError("streaming request failed", retry.ClassifyTimeout(err))
 }
 
-// executeNonStreaming handles non-streaming response execution.
-func (h *QueryHandler) executeNonStreaming(
-	client *perplexity.Client,
-	req *perplexity.CompletionRequest,
-) (*perplexity.CompletionResponse, error) {
-	response, err := client.SendCompletionRequest(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+// streamedContent returns the answer of the first choice of a streamed
+// response, or "" when there is none yet.
+func streamedContent(response *perplexity.CompletionResponse) string {
+	if response == nil || len(response.Choices) == 0 {
+		return ""
 	}
-	return response, nil
+	return response.Choices[0].Message.Content
 }
@@ -1,14 +1,39 @@
 package mcp
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sgaunet/perplexity-go/v2"
 	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/ratelimit"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/sgaunet/pplx/pkg/warnings"
+	"go.uber.org/goleak"
 )
 
+// withLibraryDefaults fills the zero core parameters of p from
+// LibraryDefaults, as the parameter extractor does.
+func withLibraryDefaults(p QueryParams) QueryParams {
+	d := LibraryDefaults()
+	p.Model = cmp.Or(p.Model, d.Model)
+	p.FrequencyPenalty = cmp.Or(p.FrequencyPenalty, d.FrequencyPenalty)
+	p.PresencePenalty = cmp.Or(p.PresencePenalty, d.PresencePenalty)
+	p.Temperature = cmp.Or(p.Temperature, d.Temperature)
+	p.TopK = cmp.Or(p.TopK, d.TopK)
+	p.TopP = cmp.Or(p.TopP, d.TopP)
+	return p
+}
+
 func TestQueryHandler_ValidateParameters(t *testing.T) {
 	handler := NewQueryHandler()
 
@@ -72,10 +97,19 @@ func TestQueryHandler_ValidateParameters(t *testing.T) {
 			params: QueryParams{
 				UserPrompt:               "test",
 				Model:                    "sonar-pro",
-				ResponseFormatJSONSchema: "{}",
+				ResponseFormatJSONSchema: `{"type": "object"}`,
 			},
 			shouldErr: false,
 		},
+		{
+			name: "invalid location_country",
+			params: QueryParams{
+				UserPrompt:      "test",
+				LocationCountry: "USA!!!",
+			},
+			shouldErr: true,
+			errField:  "location_country",
+		},
 		{
 			name: "invalid search_mode",
 			params: QueryParams{
@@ -135,11 +169,48 @@ func TestQueryHandler_ValidateParameters(t *testing.T) {
 			},
 			shouldErr: false,
 		},
+		{
+			name: "invalid language",
+			params: QueryParams{
+				UserPrompt: "test",
+				Language:   "french",
+			},
+			shouldErr: true,
+			errField:  "language",
+		},
+		{
+			name: "included and excluded domains",
+			params: QueryParams{
+				UserPrompt:     "test",
+				SearchDomains:  []string{"example.com"},
+				ExcludeDomains: []string{"pinterest.com"},
+			},
+			shouldErr: false,
+		},
+		{
+			name: "domain both included and excluded",
+			params: QueryParams{
+				UserPrompt:     "test",
+				SearchDomains:  []string{"example.com"},
+				ExcludeDomains: []string{"Example.com"},
+			},
+			shouldErr: true,
+			errField:  "exclude_domains",
+		},
+		{
+			name: "excluded domain with scheme",
+			params: QueryParams{
+				UserPrompt:     "test",
+				ExcludeDomains: []string{"https://example.com"},
+			},
+			shouldErr: true,
+			errField:  "exclude_domains",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := handler.validateParameters(tt.params)
+			err := handler.validateParameters(tt.params, nil)
 
 			if tt.shouldErr {
 				if err == nil {
@@ -163,7 +234,48 @@ func TestQueryHandler_ValidateParameters(t *testing.T) {
 	}
 }
 
-func TestQueryHandler_BuildRequestOptions(t *testing.T) {
+func TestQueryHandler_ValidateParameters_StrictModel(t *testing.T) {
+	handler := NewQueryHandler()
+	params := QueryParams{UserPrompt: "test", Model: "sonar-prp"}
+
+	if err := handler.validateParameters(params, nil); err != nil {
+		t.Errorf("unknown model should only warn, got %v", err)
+	}
+
+	handler.strictModel = true
+	err := handler.validateParameters(params, nil)
+	var valErr *clerrors.ValidationError
+	if !errors.As(err, &valErr) || valErr.Field != "model" {
+		t.Fatalf("error = %v, want a ValidationError on model", err)
+	}
+	if !strings.Contains(valErr.Message, `"sonar-pro"`) {
+		t.Errorf("Message = %q, want a suggestion of sonar-pro", valErr.Message)
+	}
+	if err := handler.validateParameters(QueryParams{UserPrompt: "test", Model: "sonar"}, nil); err != nil {
+		t.Errorf("known model rejected: %v", err)
+	}
+}
+
+func TestQueryHandler_CollectsWarnings(t *testing.T) {
+	handler := NewQueryHandler()
+	params := QueryParams{UserPrompt: "test", Model: "sonar-prp", ImageFormats: []string{"tiff"}}
+
+	warns := warnings.New()
+	if _, err := handler.buildRequest(withLibraryDefaults(params), warns); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var codes []string
+	for _, w := range warns.All() {
+		codes = append(codes, w.Code)
+	}
+	want := []string{warnings.CodeUnknownModel, warnings.CodeImageFormat}
+	if !slices.Equal(codes, want) {
+		t.Errorf("warning codes = %v, want %v", codes, want)
+	}
+}
+
+func TestQueryHandler_BuildRequest(t *testing.T) {
 	handler := NewQueryHandler()
 
 	t.Run("builds basic options", func(t *testing.T) {
@@ -176,18 +288,14 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 			FrequencyPenalty: 0.5,
 		}
 
-		msg := perplexity.NewMessages(perplexity.WithSystemMessage(params.SystemPrompt))
-		if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
-		}
-
-		opts, err := handler.buildRequestOptions(params, msg)
+		req, err := handler.buildRequest(withLibraryDefaults(params), nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
 
-		if len(opts) < 8 {
-			t.Errorf("Expected at least 8 basic options, got %d", len(opts))
+		if req.Model != "sonar" || req.Temperature != 0.7 || req.MaxTokens != 100 || req.FrequencyPenalty != 0.5 ||
+			len(req.Messages) != 2 || req.Messages[0].Content != "system" {
+			t.Errorf("request = %+v, want the basic parameters", req)
 		}
 	})
 
@@ -198,19 +306,13 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 			SearchDomains: []string{"example.com", "test.com"},
 		}
 
-		msg := perplexity.NewMessages()
-		if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
-		}
-
-		opts, err := handler.buildRequestOptions(params, msg)
+		req, err := handler.buildRequest(withLibraryDefaults(params), nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
 
-		// Should have basic opts + search domains
-		if len(opts) < 9 {
-			t.Errorf("Expected at least 9 options with search domains, got %d", len(opts))
+		if !slices.Equal(req.SearchDomainFilter, []string{"example.com", "test.com"}) {
+			t.Errorf("search_domain_filter = %v", req.SearchDomainFilter)
 		}
 	})
 
@@ -222,19 +324,14 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 			SearchRecency: "week",
 		}
 
-		msg := perplexity.NewMessages()
-		if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
-		}
-
-		opts, err := handler.buildRequestOptions(params, msg)
+		req, err := handler.buildRequest(withLibraryDefaults(params), nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
 
 		// Should handle the conflict by disabling search recency
-		if len(opts) == 0 {
-			t.Error("Expected options to be built")
+		if req.SearchRecencyFilter != "" || !req.ReturnImages {
+			t.Errorf("recency %q, images %v; want images only", req.SearchRecencyFilter, req.ReturnImages)
 		}
 	})
 
@@ -242,22 +339,17 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 		params := QueryParams{
 			UserPrompt:   "test",
 			Model:        "sonar",
-			ImageDomains: []string{"img.example.com"},
-			ImageFormats: []string{"jpg", "png", "unsupported-format"},
+			ImageDomains: []string{"example.com"},
+			ImageFormats: []string{"jpg", "png", "tiff"},
 		}
 
-		msg := perplexity.NewMessages()
-		if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
-		}
-
-		opts, err := handler.buildRequestOptions(params, msg)
+		req, err := handler.buildRequest(withLibraryDefaults(params), nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
 
-		if len(opts) == 0 {
-			t.Error("Expected options to be built")
+		if !slices.Equal(req.ImageDomainFilter, []string{"example.com"}) || len(req.ImageFormatFilter) != 3 {
+			t.Errorf("image filters = %v, %v", req.ImageDomainFilter, req.ImageFormatFilter)
 		}
 	})
 
@@ -268,7 +360,7 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 			dateValue string
 		}{
 			{"search_after_date", "SearchAfterDate", "invalid-date"},
-			{"search_before_date", "SearchBeforeDate", "2024-01-01"},
+			{"search_before_date", "SearchBeforeDate", "2024-13-01"},
 			{"last_updated_after", "LastUpdatedAfter", "01/32/2024"},
 			{"last_updated_before", "LastUpdatedBefore", "13/01/2024"},
 		}
@@ -291,12 +383,7 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 					params.LastUpdatedBefore = tc.dateValue
 				}
 
-				msg := perplexity.NewMessages()
-				if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
-		}
-
-				_, err := handler.buildRequestOptions(params, msg)
+				_, err := handler.buildRequest(withLibraryDefaults(params), nil)
 				if err == nil {
 					t.Error("Expected date validation error")
 				}
@@ -319,19 +406,15 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 			LastUpdatedBefore: "06/30/2024",
 		}
 
-		msg := perplexity.NewMessages()
-		if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
-		}
-
-		opts, err := handler.buildRequestOptions(params, msg)
+		req, err := handler.buildRequest(withLibraryDefaults(params), nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
 
-		// Should have basic opts + 4 date filters
-		if len(opts) < 12 {
-			t.Errorf("Expected at least 12 options with date filters, got %d", len(opts))
+		if req.PublishedAfter != "1/15/2024" || req.PublishedBefore != "12/31/2024" ||
+			req.LastUpdatedAfterFilter != "6/1/2024" || req.LastUpdatedBeforeFilter != "6/30/2024" {
+			t.Errorf("date filters = %q, %q, %q, %q", req.PublishedAfter, req.PublishedBefore,
+				req.LastUpdatedAfterFilter, req.LastUpdatedBeforeFilter)
 		}
 	})
 
@@ -342,12 +425,7 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 			ResponseFormatJSONSchema: "invalid json",
 		}
 
-		msg := perplexity.NewMessages()
-		if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
-		}
-
-		_, err := handler.buildRequestOptions(params, msg)
+		_, err := handler.buildRequest(withLibraryDefaults(params), nil)
 		if err == nil {
 			t.Error("Expected JSON schema validation error")
 		}
@@ -358,25 +436,51 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 		}
 	})
 
-	t.Run("accepts valid JSON schema", func(t *testing.T) {
+	t.Run("rejects schema problems", func(t *testing.T) {
 		params := QueryParams{
 			UserPrompt:               "test",
 			Model:                    "sonar",
-			ResponseFormatJSONSchema: `{"type": "object", "properties": {"name": {"type": "string"}}}`,
+			ResponseFormatJSONSchema: `{"properties": {"name": "string"}}`,
 		}
 
-		msg := perplexity.NewMessages()
-		if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
+		_, err := handler.buildRequest(withLibraryDefaults(params), nil)
+		var valErr *clerrors.ValidationError
+		if !errors.As(err, &valErr) ||
+			!strings.Contains(err.Error(), "type is required; properties.name must be an object") {
+			t.Errorf("Expected both schema problems, got %v", err)
 		}
+	})
 
-		opts, err := handler.buildRequestOptions(params, msg)
+	t.Run("warns about unknown schema keywords", func(t *testing.T) {
+		params := QueryParams{
+			UserPrompt:               "test",
+			Model:                    "sonar",
+			ResponseFormatJSONSchema: `{"type": "object", "propertees": {}}`,
+		}
+
+		warns := warnings.New()
+		if _, err := handler.buildRequest(withLibraryDefaults(params), warns); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if all := warns.All(); len(all) != 1 || all[0].Code != warnings.CodeJSONSchema {
+			t.Errorf("warnings = %+v, want one json_schema warning", all)
+		}
+	})
+
+	t.Run("accepts valid JSON schema", func(t *testing.T) {
+		params := QueryParams{
+			UserPrompt:               "test",
+			Model:                    "sonar",
+			ResponseFormatJSONSchema: `{"type": "object", "properties": {"name": {"type": "string"}}}`,
+		}
+
+		req, err := handler.buildRequest(withLibraryDefaults(params), nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
 
-		if len(opts) == 0 {
-			t.Error("Expected options to be built")
+		if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_schema" {
+			t.Errorf("response_format = %+v, want a json_schema", req.ResponseFormat)
 		}
 	})
 
@@ -387,18 +491,14 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 			ResponseFormatRegex: `\d{3}-\d{3}-\d{4}`,
 		}
 
-		msg := perplexity.NewMessages()
-		if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
-		}
-
-		opts, err := handler.buildRequestOptions(params, msg)
+		req, err := handler.buildRequest(withLibraryDefaults(params), nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
 
-		if len(opts) == 0 {
-			t.Error("Expected options to be built")
+		if req.ResponseFormat == nil || req.ResponseFormat.Regex == nil ||
+			req.ResponseFormat.Regex.Regex != `\d{3}-\d{3}-\d{4}` {
+			t.Errorf("response_format = %+v, want the regex", req.ResponseFormat)
 		}
 	})
 
@@ -421,19 +521,16 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 			LocationCountry:   "US",
 		}
 
-		msg := perplexity.NewMessages(perplexity.WithSystemMessage(params.SystemPrompt))
-		if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
-		}
-
-		opts, err := handler.buildRequestOptions(params, msg)
+		req, err := handler.buildRequest(withLibraryDefaults(params), nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
 
-		// Should have many options with all parameters
-		if len(opts) < 15 {
-			t.Errorf("Expected at least 15 options with all parameters, got %d", len(opts))
+		if req.SearchRecencyFilter != "week" || !req.ReturnRelatedQuestions || req.SearchMode != "web" ||
+			req.ReasoningEffort != "medium" || req.WebSearchOptions == nil ||
+			req.WebSearchOptions.SearchContextSize != "high" || req.WebSearchOptions.UserLocation == nil ||
+			req.WebSearchOptions.UserLocation.Country != "US" {
+			t.Errorf("request = %+v, want all the parameters", req)
 		}
 	})
 
@@ -444,12 +541,7 @@ func TestQueryHandler_BuildRequestOptions(t *testing.T) {
 			SearchRecency: "invalid-recency",
 		}
 
-		msg := perplexity.NewMessages()
-		if err := msg.AddUserMessage(params.UserPrompt); err != nil {
-			t.Fatalf("Failed to add user message: %v", err)
-		}
-
-		_, err := handler.buildRequestOptions(params, msg)
+		_, err := handler.buildRequest(withLibraryDefaults(params), nil)
 		if err == nil {
 			t.Error("Expected validation error")
 		}
@@ -500,3 +592,575 @@ func TestQueryHandler_Handle_ValidationFlow(t *testing.T) {
 		}
 	})
 }
+
+// stubHook records the calls it receives and fails BeforeRequest with err.
+type stubHook struct {
+	err      error
+	model    string
+	afterErr error
+	after    bool
+}
+
+func (h *stubHook) BeforeRequest(req *perplexity.CompletionRequest) error {
+	h.model = req.Model
+	return h.err
+}
+
+func (h *stubHook) AfterResponse(_ *perplexity.CompletionResponse, err error) {
+	h.after = true
+	h.afterErr = err
+}
+
+func TestQueryHandler_Handle_Hooks(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		calls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		handler := NewQueryHandler()
+		handler.retryPolicy.MaxRetries = 0
+		handler.clientFactory = func(apiKey string) *perplexity.Client {
+			client := perplexity.NewClient(apiKey)
+			client.SetEndpoint(srv.URL)
+			return client
+		}
+		params := QueryParams{
+			UserPrompt: "test", Model: "sonar", Stream: stream,
+			MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+		}
+
+		// A failing hook aborts the request before it is sent.
+		errDenied := errors.New("denied")
+		aborting := &stubHook{err: errDenied}
+		handler.Use(aborting)
+		_, err := handler.Handle(context.Background(), "test-api-key", params)
+		if !errors.Is(err, clerrors.ErrRequestAborted) || !errors.Is(err, errDenied) {
+			t.Errorf("stream=%v: error = %v, want ErrRequestAborted wrapping the hook error", stream, err)
+		}
+		if calls != 0 || aborting.after {
+			t.Errorf("stream=%v: aborted request was sent", stream)
+		}
+
+		// A passing hook sees the request and its failure.
+		passing := &stubHook{}
+		handler.hooks = nil
+		handler.Use(passing)
+		if _, err := handler.Handle(context.Background(), "test-api-key", params); err == nil {
+			t.Errorf("stream=%v: expected the API error", stream)
+		}
+		if passing.model != "sonar" || !passing.after || passing.afterErr == nil {
+			t.Errorf("stream=%v: hook = %+v, want it to see the request and the error", stream, passing)
+		}
+		srv.Close()
+	}
+}
+
+func TestQueryHandler_Handle_RateLimit(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	handler := NewQueryHandler()
+	handler.retryPolicy.MaxRetries = 0
+	handler.clientFactory = func(apiKey string) *perplexity.Client {
+		client := perplexity.NewClient(apiKey)
+		client.SetEndpoint(srv.URL)
+		return client
+	}
+	handler.limiter = ratelimit.New(1, 1)
+	params := QueryParams{
+		UserPrompt: "test", Model: "sonar",
+		MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+	}
+
+	// The first request takes the only token.
+	if _, err := handler.Handle(context.Background(), "test-api-key", params); err == nil {
+		t.Fatal("expected the API error")
+	}
+
+	// The second waits for the next token and gives up with its context.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := handler.Handle(ctx, "test-api-key", params)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if calls != 1 {
+		t.Errorf("API called %d times, want 1", calls)
+	}
+	if s := handler.limiter.Stats(); s.Allowed != 1 || s.Cancelled != 1 {
+		t.Errorf("limiter stats = %+v, want 1 allowed and 1 cancelled", s)
+	}
+}
+
+func TestNewServer_Endpoint(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","model":"sonar","choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	s, err := NewServer(ServerConfig{APIKey: "test-key", Endpoint: srv.URL + "/gateway/chat/completions"})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	t.Cleanup(s.jobs.Shutdown)
+
+	params := QueryParams{
+		UserPrompt: "test", Model: "sonar",
+		MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+	}
+	if _, err := s.handler.Handle(context.Background(), "test-key", params); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if path != "/gateway/chat/completions" {
+		t.Errorf("server saw %q, want /gateway/chat/completions", path)
+	}
+}
+
+func TestQueryHandler_Handle_Language(t *testing.T) {
+	var system string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []perplexity.Message `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		system = ""
+		if len(req.Messages) > 0 && req.Messages[0].Role == "system" {
+			system = req.Messages[0].Content
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","model":"sonar","choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+	handler := NewQueryHandler()
+	handler.clientFactory = func(apiKey string) *perplexity.Client {
+		client := perplexity.NewClient(apiKey)
+		client.SetEndpoint(srv.URL)
+		return client
+	}
+
+	tests := []struct {
+		name   string
+		system string
+		want   string
+	}{
+		{"instruction added", "Be brief.", "Be brief.\n\nAlways answer in French."},
+		{"explicit language kept", "Réponds en français.", "Réponds en français."},
+	}
+	for _, tt := range tests {
+		params := QueryParams{
+			UserPrompt: "test", SystemPrompt: tt.system, Model: "sonar", Language: "fr",
+			MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+		}
+		if _, err := handler.Handle(context.Background(), "test-api-key", params); err != nil {
+			t.Fatalf("%s: Handle() error = %v", tt.name, err)
+		}
+		if system != tt.want {
+			t.Errorf("%s: system message = %q, want %q", tt.name, system, tt.want)
+		}
+	}
+}
+
+func TestQueryHandler_BuildRequest_ImageConflict(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantRecency string
+		wantImages  bool
+		wantErr     bool
+	}{
+		{"", "", true, false},
+		{"prefer_images", "", true, false},
+		{"prefer_recency", "week", false, false},
+		{"error", "", false, true},
+	}
+	handler := NewQueryHandler()
+	for _, tt := range tests {
+		params := QueryParams{
+			UserPrompt: "test", Model: "sonar", SearchRecency: "week", ReturnImages: true,
+			ImageConflictPolicy: tt.policy,
+		}
+		req, err := handler.buildRequest(withLibraryDefaults(params), nil)
+		if tt.wantErr {
+			var valErr *clerrors.ValidationError
+			if !errors.As(err, &valErr) || valErr.Field != "search_recency" {
+				t.Errorf("policy %q: error = %v, want a search_recency ValidationError", tt.policy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("policy %q: buildRequest() error = %v", tt.policy, err)
+		}
+		if req.SearchRecencyFilter != tt.wantRecency || req.ReturnImages != tt.wantImages {
+			t.Errorf("policy %q: recency %q, images %v; want %q, %v",
+				tt.policy, req.SearchRecencyFilter, req.ReturnImages, tt.wantRecency, tt.wantImages)
+		}
+	}
+}
+
+func TestQueryHandler_BuildRequest_MaxTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     string
+		maxTokens int
+		want      int
+		wantErr   bool
+	}{
+		{"model default", "sonar", 0, perplexity.DefaultMaxTokens, false},
+		{"within the limit", "sonar-deep-research", 32_000, 32_000, false},
+		{"above the limit", "sonar", 200_000, 0, true},
+		{"unknown model not checked", "sonar-next", 200_000, 200_000, false},
+	}
+	handler := NewQueryHandler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := QueryParams{UserPrompt: "test", Model: tt.model, MaxTokens: tt.maxTokens}
+			req, err := handler.buildRequest(withLibraryDefaults(params), warnings.New())
+			if tt.wantErr {
+				var valErr *clerrors.ValidationError
+				if !errors.As(err, &valErr) || valErr.Field != "max_tokens" ||
+					!strings.Contains(valErr.Message, "sonar writes at most 8000 tokens") {
+					t.Errorf("error = %v, want a max_tokens ValidationError giving the limit", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildRequest() error = %v", err)
+			}
+			if req.MaxTokens != tt.want {
+				t.Errorf("max_tokens = %d, want %d", req.MaxTokens, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryHandler_StreamWithResponseFormat(t *testing.T) {
+	handler := NewQueryHandler()
+	params := QueryParams{UserPrompt: "test", Model: "sonar", Stream: true, ResponseFormatRegex: `\d+`}
+
+	if err := handler.validateParameters(params, nil); err != nil {
+		t.Fatalf("validateParameters() error = %v", err)
+	}
+	warns := warnings.New()
+	req, err := handler.buildRequest(withLibraryDefaults(params), warns)
+	if err != nil {
+		t.Fatalf("buildRequest() error = %v", err)
+	}
+	if req.Stream {
+		t.Error("streaming was not turned off for a request with a response format")
+	}
+	if all := warns.All(); len(all) != 1 || all[0].Code != warnings.CodeStreamFormat {
+		t.Errorf("warnings = %+v, want one %s warning", all, warnings.CodeStreamFormat)
+	}
+
+	params.StrictOptions = true
+	var valErr *clerrors.ValidationError
+	if err := handler.validateParameters(params, nil); !errors.As(err, &valErr) || valErr.Field != "stream" {
+		t.Errorf("strict: validateParameters() error = %v, want a stream ValidationError", err)
+	}
+}
+
+func TestQueryHandler_ResolveAPIKey(t *testing.T) {
+	handler := NewQueryHandler()
+	handler.keys = map[string]string{"alice": "alice-key"}
+
+	tests := []struct {
+		name    string
+		params  QueryParams
+		want    string
+		wantErr string
+	}{
+		{"default key", QueryParams{}, "server-key", ""},
+		{"api_key", QueryParams{APIKey: "caller-key"}, "caller-key", ""},
+		{"key_id", QueryParams{KeyID: "alice"}, "alice-key", ""},
+		{"unknown key_id", QueryParams{KeyID: "bob"}, "", "unknown key_id"},
+		{"api_key and key_id", QueryParams{APIKey: "caller-key", KeyID: "alice"}, "", "cannot be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := handler.resolveAPIKey("server-key", tt.params)
+			if tt.wantErr == "" {
+				if err != nil || got != tt.want {
+					t.Errorf("resolveAPIKey() = %q, %v, want %q", got, err, tt.want)
+				}
+				return
+			}
+			var paramErr *ParameterError
+			if !errors.As(err, &paramErr) || paramErr.Parameter != "key_id" {
+				t.Fatalf("error = %v, want a key_id ParameterError", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if strings.Contains(err.Error(), "caller-key") {
+				t.Errorf("error %q leaks the api_key", err)
+			}
+		})
+	}
+}
+
+func TestQueryHandler_Handle_APIKeys(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","model":"sonar","choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+	t.Setenv("HOME", t.TempDir())
+	handler := NewQueryHandler()
+	handler.keys = map[string]string{"alice": "alice-key"}
+	handler.clientFactory = func(apiKey string) *perplexity.Client {
+		client := perplexity.NewClient(apiKey)
+		client.SetEndpoint(srv.URL)
+		return client
+	}
+
+	tests := []struct {
+		name   string
+		params QueryParams
+		want   string
+	}{
+		{"default key", QueryParams{}, "Bearer server-key"},
+		{"key_id", QueryParams{KeyID: "alice"}, "Bearer alice-key"},
+		{"api_key", QueryParams{APIKey: "caller-key"}, "Bearer caller-key"},
+	}
+	for _, tt := range tests {
+		params := tt.params
+		params.UserPrompt, params.Model = "test", "sonar"
+		params.MaxTokens, params.TopP, params.FrequencyPenalty = 100, 0.9, 1.0
+		if _, err := handler.Handle(context.Background(), "server-key", params); err != nil {
+			t.Fatalf("%s: Handle() error = %v", tt.name, err)
+		}
+		if auth != tt.want {
+			t.Errorf("%s: Authorization = %q, want %q", tt.name, auth, tt.want)
+		}
+	}
+}
+
+func TestQueryHandler_Handle_Budget(t *testing.T) {
+	var maxTokens float64
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		maxTokens, _ = body["max_tokens"].(float64)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","model":"sonar","choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+	t.Setenv("HOME", t.TempDir())
+
+	// The usage log holds $0.992 spent today.
+	tracker := usage.NewTracker(filepath.Join(t.TempDir(), "usage.jsonl"))
+	spent := &perplexity.CompletionResponse{Model: "sonar", Usage: perplexity.Usage{Cost: &perplexity.Cost{
+		TotalCost: func() *float64 { c := 0.992; return &c }(),
+	}}}
+	if err := tracker.Record(usage.SourceMCP, spent); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewQueryHandler()
+	handler.tracker = tracker
+	handler.clientFactory = func(apiKey string) *perplexity.Client {
+		client := perplexity.NewClient(apiKey)
+		client.SetEndpoint(srv.URL)
+		return client
+	}
+	params := QueryParams{UserPrompt: "test", Model: "sonar", MaxTokens: 4000, TopP: 0.9, FrequencyPenalty: 1.0}
+
+	// sonar at 4000 completion tokens projects $0.009, over the $1 budget.
+	handler.dailyBudget = 1
+	_, err := handler.Handle(context.Background(), "key", params)
+	var budgetErr *clerrors.BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("Handle() error = %v, want BudgetExceededError", err)
+	}
+	if requests != 0 {
+		t.Errorf("requests sent = %d, want 0", requests)
+	}
+
+	// Capping max_tokens brings the projection under the budget.
+	handler.maxTokens = 1000
+	if _, err := handler.Handle(context.Background(), "key", params); err != nil {
+		t.Fatalf("Handle() with capped max_tokens error = %v", err)
+	}
+	if maxTokens != 1000 {
+		t.Errorf("max_tokens sent = %v, want 1000", maxTokens)
+	}
+}
+
+func TestQueryHandler_Handle_SearchQueries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"sq-1","model":"sonar","choices":[{"message":{"role":"assistant",` +
+			`"content":"ok"}}],"search_queries":["go 1.25 release","go generics"]}`))
+	}))
+	defer srv.Close()
+	handler := NewQueryHandler()
+	handler.clientFactory = func(apiKey string) *perplexity.Client {
+		client := perplexity.NewClient(apiKey)
+		client.SetEndpoint(srv.URL)
+		return client
+	}
+
+	params := QueryParams{UserPrompt: "test", Model: "sonar", MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0}
+	response, err := handler.Handle(context.Background(), "test-api-key", params)
+	if err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	result := NewResponseFormatter().buildResponse(response, 0)
+	if want := []string{"go 1.25 release", "go generics"}; !slices.Equal(result.SearchQueries, want) {
+		t.Errorf("search_queries = %q, want %q", result.SearchQueries, want)
+	}
+}
+
+func TestQueryHandler_Handle_Cancelled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	for _, stream := range []bool{false, true} {
+		running := goleak.IgnoreCurrent()
+		// The server never responds: it holds every request until the test
+		// releases it.
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			<-release
+		}))
+		handler := NewQueryHandler()
+		handler.retryPolicy.MaxRetries = 0
+		handler.clientFactory = func(apiKey string) *perplexity.Client {
+			client := perplexity.NewClient(apiKey)
+			client.SetEndpoint(srv.URL)
+			return client
+		}
+		params := QueryParams{
+			UserPrompt: "test", Model: "sonar", Stream: stream,
+			MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		start := time.Now()
+		_, err := handler.Handle(ctx, "test-api-key", params)
+		elapsed := time.Since(start)
+		cancel()
+		close(release)
+		srv.Close()
+
+		if elapsed > time.Second {
+			t.Errorf("stream=%v: Handle() returned after %v, want soon after the deadline", stream, elapsed)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("stream=%v: Handle() error = %v, want context.DeadlineExceeded", stream, err)
+		}
+		var streamErr *StreamError
+		if stream && !errors.As(err, &streamErr) {
+			t.Errorf("Handle() error = %v, want a StreamError", err)
+		}
+		goleak.VerifyNone(t, running)
+	}
+}
+
+func TestQueryHandler_Handle_StreamStalled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	running := goleak.IgnoreCurrent()
+
+	// The server sends two chunks, then keeps the connection open without
+	// sending anything until the request is cancelled.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, content := range []string{"Partial", "Partial answer"} {
+			event, _ := json.Marshal(map[string]any{
+				"id": "stream-id", "model": "sonar",
+				"choices": []map[string]any{{"index": 0,
+					"message": map[string]string{"role": "assistant", "content": content}}},
+			})
+			_, _ = w.Write([]byte("data: " + string(event) + "\n\n"))
+			w.(http.Flusher).Flush()
+		}
+		<-r.Context().Done()
+	}))
+
+	handler := NewQueryHandler()
+	handler.retryPolicy.MaxRetries = 0
+	handler.streamIdleTimeout = 100 * time.Millisecond
+	handler.clientFactory = func(apiKey string) *perplexity.Client {
+		client := perplexity.NewClient(apiKey)
+		client.SetEndpoint(srv.URL)
+		return client
+	}
+	params := QueryParams{
+		UserPrompt: "test", Model: "sonar", Stream: true,
+		MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+	}
+
+	start := time.Now()
+	_, err := handler.Handle(context.Background(), "test-api-key", params)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Handle() returned after %v, want soon after the idle timeout", elapsed)
+	}
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) {
+		t.Fatalf("Handle() error = %v, want a StreamError", err)
+	}
+	if streamErr.Partial != "Partial answer" || streamErr.Idle != 100*time.Millisecond {
+		t.Errorf("StreamError = %+v, want the partial answer and the idle timeout", streamErr)
+	}
+	if !strings.Contains(err.Error(), "no data received for 100ms, aborted after 14 characters") {
+		t.Errorf("error = %q", err)
+	}
+	srv.Close()
+	goleak.VerifyNone(t, running)
+}
+
+func TestQueryHandler_Handle_ModelFallback(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sent = append(sent, req.Model)
+		w.Header().Set("Content-Type", "application/json")
+		if req.Model == "sonar-pro" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"Invalid model 'sonar-pro'","type":"invalid_model","code":400}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"1","model":"` + req.Model +
+			`","choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+	handler := NewQueryHandler()
+	handler.clientFactory = func(apiKey string) *perplexity.Client {
+		client := perplexity.NewClient(apiKey)
+		client.SetEndpoint(srv.URL)
+		return client
+	}
+
+	warns := warnings.New()
+	params := QueryParams{
+		UserPrompt: "test", Model: "sonar-pro", ModelFallbacks: []string{"sonar"},
+		MaxTokens: 100, TopP: 0.9, FrequencyPenalty: 1.0,
+	}
+	response, err := handler.Handle(warnings.NewContext(context.Background(), warns), "test-api-key", params)
+	if err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if want := []string{"sonar-pro", "sonar"}; !slices.Equal(sent, want) {
+		t.Errorf("models sent = %q, want %q", sent, want)
+	}
+
+	if got := NewResponseFormatter().buildResponse(response, time.Second).ModelUsed; got != "sonar" {
+		t.Errorf("model_used = %q, want sonar", got)
+	}
+	if all := warns.All(); len(all) != 1 || all[0].Code != warnings.CodeModelFallback {
+		t.Errorf("warnings = %+v, want one %s warning", all, warnings.CodeModelFallback)
+	}
+}
@@ -0,0 +1,103 @@
+package mcp
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+// PingResult is the result of the ping tool.
+type PingResult struct {
+	Status string `json:"status"`
+	// UptimeSeconds is how long the server has been running, in whole seconds.
+	UptimeSeconds int64 `json:"uptime_s"`
+}
+
+// ServerInfo is the result of the server_info tool. It never includes the
+// API key itself.
+type ServerInfo struct {
+	Version       string         `json:"version"`
+	GoVersion     string         `json:"go_version"`
+	DefaultModel  string         `json:"default_model"`
+	APIKeyPresent bool           `json:"api_key_present"`
+	Features      ServerFeatures `json:"features"`
+}
+
+// ServerFeatures reports the optional behaviours enabled on the server.
+type ServerFeatures struct {
+	// Streaming is whether the query tool accepts the stream argument.
+	Streaming bool `json:"streaming"`
+	// Budget is whether a daily budget (api.daily_budget_usd) is enforced.
+	Budget bool `json:"budget"`
+	// RateLimit is whether API requests are rate limited.
+	RateLimit bool `json:"rate_limit"`
+}
+
+// BuildPingTool creates the MCP tool definition for the liveness check.
+func BuildPingTool() *mcp.Tool {
+	tool := mcp.NewTool("ping",
+		mcp.WithDescription("Check that the pplx MCP server is alive. Returns its status and uptime "+
+			"without calling the Perplexity API."),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+	return &tool
+}
+
+// BuildServerInfoTool creates the MCP tool definition for the build and
+// configuration metadata of the server.
+func BuildServerInfoTool() *mcp.Tool {
+	tool := mcp.NewTool("server_info",
+		mcp.WithDescription("Describe the pplx MCP server: version, Go version, default model, whether an "+
+			"API key is configured, and the enabled features. Does not call the Perplexity API."),
+		mcp.WithReadOnlyHintAnnotation(true),
+	)
+	return &tool
+}
+
+// AddHealthTools registers the ping and server_info tools, which answer
+// without calling the API and cost nothing.
+func (s *MCPServer) AddHealthTools() error {
+	s.server.AddTool(*BuildPingTool(), s.handlePing)
+	s.server.AddTool(*BuildServerInfoTool(), s.handleServerInfo)
+	return nil
+}
+
+// handlePing reports the server as alive, with its uptime.
+func (s *MCPServer) handlePing(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return s.formatter.marshal(s.ping())
+}
+
+// handleServerInfo reports the build and configuration metadata.
+func (s *MCPServer) handleServerInfo(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return s.formatter.marshal(s.info())
+}
+
+// ping returns the result of the ping tool.
+func (s *MCPServer) ping() PingResult {
+	return PingResult{
+		Status:        "ok",
+		UptimeSeconds: int64(s.now().Sub(s.startedAt) / time.Second),
+	}
+}
+
+// info returns the result of the server_info tool.
+func (s *MCPServer) info() ServerInfo {
+	model := perplexity.DefaultModel
+	if cfg := s.defaults.Load(); cfg != nil && cfg.Defaults.Model != "" {
+		model = cfg.Defaults.Model
+	}
+	return ServerInfo{
+		Version:       s.version,
+		GoVersion:     runtime.Version(),
+		DefaultModel:  model,
+		APIKeyPresent: s.apiKey != "",
+		Features: ServerFeatures{
+			Streaming: true,
+			Budget:    s.handler.dailyBudget > 0,
+			RateLimit: s.handler.limiter != nil,
+		},
+	}
+}
@@ -0,0 +1,99 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/ratelimit"
+)
+
+// callTool sends a tools/call request for name without arguments and
+// decodes the JSON text of the result into v.
+func callTool(t *testing.T, s *MCPServer, name string, v any) {
+	t.Helper()
+	msg := `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"` + name + `"}}`
+	data, err := json.Marshal(s.server.HandleMessage(context.Background(), json.RawMessage(msg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Result struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			IsError bool `json:"isError"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("invalid response %s: %v", data, err)
+	}
+	if decoded.Result.IsError || len(decoded.Result.Content) != 1 {
+		t.Fatalf("tools/call %s = %s, want one text result", name, data)
+	}
+	if err := json.Unmarshal([]byte(decoded.Result.Content[0].Text), v); err != nil {
+		t.Fatalf("invalid %s result %q: %v", name, decoded.Result.Content[0].Text, err)
+	}
+}
+
+func TestMCPServer_Ping(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s, err := NewServer(ServerConfig{APIKey: "test-key", Clock: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.jobs.Shutdown)
+	if err := s.RegisterTools(); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(90*time.Second + 500*time.Millisecond)
+	var got PingResult
+	callTool(t, s, "ping", &got)
+	if got != (PingResult{Status: "ok", UptimeSeconds: 90}) {
+		t.Errorf("ping = %+v, want ok after 90s", got)
+	}
+}
+
+func TestMCPServer_ServerInfo(t *testing.T) {
+	cfg := config.NewConfigData()
+	cfg.Defaults.Model = "sonar-pro"
+	s, err := NewServer(ServerConfig{
+		APIKey:         "pplx-secret",
+		Version:        "2.3.4",
+		Defaults:       cfg,
+		DailyBudgetUSD: 5,
+		RateLimiter:    ratelimit.New(60, 1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.jobs.Shutdown)
+	if err := s.RegisterTools(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got ServerInfo
+	callTool(t, s, "server_info", &got)
+	want := ServerInfo{
+		Version:       "2.3.4",
+		GoVersion:     runtime.Version(),
+		DefaultModel:  "sonar-pro",
+		APIKeyPresent: true,
+		Features:      ServerFeatures{Streaming: true, Budget: true, RateLimit: true},
+	}
+	if got != want {
+		t.Errorf("server_info = %+v, want %+v", got, want)
+	}
+
+	s.SetDefaults(nil)
+	s.handler.limiter = nil
+	s.handler.dailyBudget = 0
+	info := s.info()
+	if info.DefaultModel == "" || info.Features.Budget || info.Features.RateLimit {
+		t.Errorf("info() without config = %+v, want the library model and no budget or rate limit", info)
+	}
+}
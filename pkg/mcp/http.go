@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// DefaultHTTPAddr is the default listen address of the HTTP transport.
+	// It binds to loopback so the server is not exposed by accident.
+	DefaultHTTPAddr = "127.0.0.1:8080"
+	// DefaultDrainTimeout is how long in-flight requests may run after a
+	// shutdown signal before their connections are closed.
+	DefaultDrainTimeout = 10 * time.Second
+	// HTTPEndpoint is the path the MCP endpoint is served on.
+	HTTPEndpoint = "/mcp"
+	// MetricsEndpoint is the path usage metrics are served on when enabled.
+	MetricsEndpoint = "/metrics"
+
+	// readHeaderTimeout bounds how long a client may take to send headers.
+	readHeaderTimeout = 10 * time.Second
+)
+
+// HTTPConfig configures the streamable HTTP transport.
+type HTTPConfig struct {
+	// Addr is the host:port to listen on. Empty uses DefaultHTTPAddr.
+	Addr string
+	// AuthToken, when set, must be sent by clients as "Authorization: Bearer <token>".
+	AuthToken string
+	// DrainTimeout bounds graceful shutdown. Zero uses DefaultDrainTimeout.
+	DrainTimeout time.Duration
+	// Metrics, when set, is served on MetricsEndpoint behind the same auth.
+	Metrics http.Handler
+}
+
+// HTTPHandler returns an http.Handler serving the MCP streamable HTTP
+// transport on HTTPEndpoint. A non-empty token enables bearer-token auth.
+func (s *MCPServer) HTTPHandler(token string) http.Handler {
+	return s.httpHandler(token, nil)
+}
+
+// httpHandler is HTTPHandler, also serving metrics on MetricsEndpoint when
+// metrics is not nil.
+func (s *MCPServer) httpHandler(token string, metrics http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(HTTPEndpoint, server.NewStreamableHTTPServer(s.server))
+	if metrics != nil {
+		mux.Handle(MetricsEndpoint, metrics)
+	}
+	if token == "" {
+		return mux
+	}
+	return requireBearerToken(token, mux)
+}
+
+// ServeHTTP serves the MCP tools over streamable HTTP until ctx is cancelled,
+// then stops accepting connections and gives in-flight requests up to
+// cfg.DrainTimeout to finish. Running research jobs are cancelled last.
+func (s *MCPServer) ServeHTTP(ctx context.Context, cfg HTTPConfig) error {
+	defer s.jobs.Shutdown()
+
+	if cfg.Addr == "" {
+		cfg.Addr = DefaultHTTPAddr
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = DefaultDrainTimeout
+	}
+
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", cfg.Addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.httpHandler(cfg.AuthToken, cfg.Metrics),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(listener) }()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("failed to serve HTTP: %w", err)
+	case <-ctx.Done():
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		_ = srv.Close()
+		return fmt.Errorf("failed to drain HTTP server within %s: %w", cfg.DrainTimeout, err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve HTTP: %w", err)
+	}
+	return nil
+}
+
+// requireBearerToken rejects requests whose Authorization header does not
+// carry token, comparing in constant time.
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	want := []byte(token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pplx"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,204 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const initializeRequest = `{"jsonrpc":"2.0","id":1,"method":"initialize",` +
+	`"params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`
+
+func newHTTPTestServer(t *testing.T) *MCPServer {
+	t.Helper()
+	s, err := NewServer(ServerConfig{APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	if err := s.RegisterTools(); err != nil {
+		t.Fatalf("RegisterTools() error: %v", err)
+	}
+	t.Cleanup(s.jobs.Shutdown)
+	return s
+}
+
+func postMCP(t *testing.T, url, token, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, url+HTTPEndpoint, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest() error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST error: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestHTTPHandler_Auth(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		sent       string
+		wantStatus int
+	}{
+		{name: "no auth configured", configured: "", sent: "", wantStatus: http.StatusOK},
+		{name: "valid token", configured: "secret", sent: "secret", wantStatus: http.StatusOK},
+		{name: "missing token", configured: "secret", sent: "", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", configured: "secret", sent: "secreT", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(newHTTPTestServer(t).HTTPHandler(tt.configured))
+			defer ts.Close()
+
+			resp := postMCP(t, ts.URL, tt.sent, initializeRequest)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header on 401")
+			}
+		})
+	}
+}
+
+func TestHTTPHandler_ListsSharedTools(t *testing.T) {
+	ts := httptest.NewServer(newHTTPTestServer(t).HTTPHandler(""))
+	defer ts.Close()
+
+	initResp := postMCP(t, ts.URL, "", initializeRequest)
+	session := initResp.Header.Get("Mcp-Session-Id")
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, ts.URL+HTTPEndpoint,
+		strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	if err != nil {
+		t.Fatalf("NewRequest() error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("Mcp-Session-Id", session)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST error: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var payload struct {
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode tools/list: %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, tool := range payload.Result.Tools {
+		names[tool.Name] = true
+	}
+	for _, want := range []string{"query", "research_start", "research_status", "export_request", "set_profile"} {
+		if !names[want] {
+			t.Errorf("tools/list is missing %q (got %v)", want, names)
+		}
+	}
+}
+
+func TestServeHTTP_ShutsDownOnCancel(t *testing.T) {
+	s := newHTTPTestServer(t)
+
+	// Reserve a free port, then release it for ServeHTTP.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- s.ServeHTTP(ctx, HTTPConfig{Addr: addr, DrainTimeout: time.Second}) }()
+
+	// Wait until the server accepts connections.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			_ = conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ServeHTTP() error = %v, want nil", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("ServeHTTP did not return after cancel")
+	}
+}
+
+func TestServeHTTP_ListenError(t *testing.T) {
+	s := newHTTPTestServer(t)
+	if err := s.ServeHTTP(t.Context(), HTTPConfig{Addr: "not-an-address"}); err == nil {
+		t.Fatal("expected listen error, got nil")
+	}
+}
+
+func TestHTTPHandler_Metrics(t *testing.T) {
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("pplx_requests_total{model=\"sonar\"} 1\n"))
+	})
+	tests := []struct {
+		name       string
+		metrics    http.Handler
+		sent       string
+		wantStatus int
+	}{
+		{name: "disabled", sent: "secret", wantStatus: http.StatusNotFound},
+		{name: "enabled", metrics: metrics, sent: "secret", wantStatus: http.StatusOK},
+		{name: "missing token", metrics: metrics, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(newHTTPTestServer(t).httpHandler("secret", tt.metrics))
+			defer ts.Close()
+
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL+MetricsEndpoint, nil)
+			if err != nil {
+				t.Fatalf("NewRequest() error: %v", err)
+			}
+			if tt.sent != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.sent)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("GET error: %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
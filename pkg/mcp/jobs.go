@@ -0,0 +1,218 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Research job defaults.
+const (
+	// DefaultJobTTL is how long a finished job's result is kept for polling.
+	DefaultJobTTL = time.Hour
+	// DefaultMaxConcurrentJobs caps the number of pending or running jobs.
+	DefaultMaxConcurrentJobs = 4
+	// DefaultResearchModel is used by research_start when no model is given.
+	DefaultResearchModel = "sonar-deep-research"
+	// DefaultResearchTimeout is the HTTP timeout for research jobs when none is given.
+	// Deep research routinely takes several minutes.
+	DefaultResearchTimeout = 10 * time.Minute
+)
+
+// jobIDBytes is the number of random bytes in a job or chat session ID
+// (hex-encoded to twice the length).
+const jobIDBytes = 8
+
+// JobState is the lifecycle state of a research job.
+type JobState string
+
+// Job states reported by research_status.
+const (
+	JobPending JobState = "pending"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	JobError   JobState = "error"
+)
+
+// RunFunc executes a query for a job. QueryHandler.Handle bound to an API key satisfies it.
+type RunFunc func(ctx context.Context, params QueryParams) (*perplexity.CompletionResponse, error)
+
+// JobStatus is a snapshot of a research job.
+type JobStatus struct {
+	ID      string
+	State   JobState
+	Model   string
+	Elapsed time.Duration
+	// Response is set when State is JobDone.
+	Response *perplexity.CompletionResponse
+	// Err is set when State is JobError.
+	Err error
+}
+
+// job is the mutable record behind a JobStatus. Guarded by JobManager.mu.
+type job struct {
+	id         string
+	state      JobState
+	model      string
+	createdAt  time.Time
+	finishedAt time.Time
+	response   *perplexity.CompletionResponse
+	err        error
+}
+
+// JobManager runs queries in the background and keeps their results for polling.
+//
+// Finished jobs are dropped TTL after completion; expiry is checked lazily on
+// every Start and Status call, so no janitor goroutine is needed. Jobs still
+// pending or running never expire and count against the concurrency limit.
+type JobManager struct {
+	mu            sync.Mutex
+	jobs          map[string]*job
+	run           RunFunc
+	ttl           time.Duration
+	maxConcurrent int
+
+	// now returns the current time; replaced in tests.
+	now func() time.Time
+	// ctx is the parent context of all jobs; cancelled by Shutdown.
+	ctx    context.Context //nolint:containedctx // jobs outlive the tool call that started them
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewJobManager creates a job manager that executes jobs with run.
+// Non-positive ttl or maxConcurrent fall back to DefaultJobTTL and DefaultMaxConcurrentJobs.
+func NewJobManager(run RunFunc, ttl time.Duration, maxConcurrent int) *JobManager {
+	if ttl <= 0 {
+		ttl = DefaultJobTTL
+	}
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultMaxConcurrentJobs
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobManager{
+		jobs:          make(map[string]*job),
+		run:           run,
+		ttl:           ttl,
+		maxConcurrent: maxConcurrent,
+		now:           time.Now,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// Start launches params in a background goroutine and returns the new job ID.
+// Returns clerrors.ErrTooManyJobs when the concurrency limit is reached.
+func (m *JobManager) Start(params QueryParams) (string, error) {
+	id, err := newID("job")
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	if active := m.activeLocked(); active >= m.maxConcurrent {
+		m.mu.Unlock()
+		return "", fmt.Errorf("%w: %d of %d running, poll research_status and retry later",
+			clerrors.ErrTooManyJobs, active, m.maxConcurrent)
+	}
+	j := &job{id: id, state: JobPending, model: params.Model, createdAt: m.now()}
+	m.jobs[id] = j
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.execute(j, params)
+
+	return id, nil
+}
+
+// Status returns a snapshot of the job with the given ID.
+// Returns clerrors.ErrJobNotFound for unknown or expired jobs.
+func (m *JobManager) Status(id string) (JobStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pruneLocked()
+	j, ok := m.jobs[id]
+	if !ok {
+		return JobStatus{}, fmt.Errorf("%w: %s", clerrors.ErrJobNotFound, id)
+	}
+
+	end := j.finishedAt
+	if end.IsZero() {
+		end = m.now()
+	}
+	return JobStatus{
+		ID:       j.id,
+		State:    j.state,
+		Model:    j.model,
+		Elapsed:  end.Sub(j.createdAt),
+		Response: j.response,
+		Err:      j.err,
+	}, nil
+}
+
+// Shutdown cancels running jobs and waits for their goroutines to exit.
+func (m *JobManager) Shutdown() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// execute runs a job and records its outcome.
+func (m *JobManager) execute(j *job, params QueryParams) {
+	defer m.wg.Done()
+
+	m.mu.Lock()
+	j.state = JobRunning
+	m.mu.Unlock()
+
+	response, err := m.run(m.ctx, params)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j.finishedAt = m.now()
+	if err != nil {
+		j.state = JobError
+		j.err = err
+		return
+	}
+	j.state = JobDone
+	j.response = response
+}
+
+// pruneLocked drops finished jobs older than the TTL. Caller must hold m.mu.
+func (m *JobManager) pruneLocked() {
+	cutoff := m.now().Add(-m.ttl)
+	for id, j := range m.jobs {
+		if !j.finishedAt.IsZero() && j.finishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// activeLocked counts pending and running jobs. Caller must hold m.mu.
+func (m *JobManager) activeLocked() int {
+	n := 0
+	for _, j := range m.jobs {
+		if j.state == JobPending || j.state == JobRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// newID returns a random hex identifier for a job or session; kind names it
+// in the error.
+func newID(kind string) (string, error) {
+	b := make([]byte, jobIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate %s ID: %w", kind, err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,180 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// blockingRun returns a RunFunc that waits for release before returning response/err.
+func blockingRun(release <-chan struct{}, response *perplexity.CompletionResponse, err error) RunFunc {
+	return func(ctx context.Context, _ QueryParams) (*perplexity.CompletionResponse, error) {
+		select {
+		case <-release:
+			return response, err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// waitForState polls until the job reaches want or the test times out.
+func waitForState(t *testing.T, m *JobManager, id string, want JobState) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, err := m.Status(id)
+		if err != nil {
+			t.Fatalf("Status(%s) unexpected error: %v", id, err)
+		}
+		if status.State == want {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s state = %s, want %s", id, status.State, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestJobManager_Lifecycle(t *testing.T) {
+	response := &perplexity.CompletionResponse{Model: "sonar-deep-research"}
+	release := make(chan struct{})
+	m := NewJobManager(blockingRun(release, response, nil), time.Hour, 2)
+	defer m.Shutdown()
+
+	id, err := m.Start(QueryParams{UserPrompt: "q", Model: "sonar-deep-research"})
+	if err != nil {
+		t.Fatalf("Start unexpected error: %v", err)
+	}
+	if len(id) != 2*jobIDBytes {
+		t.Errorf("job ID %q has length %d, want %d", id, len(id), 2*jobIDBytes)
+	}
+
+	waitForState(t, m, id, JobRunning)
+	close(release)
+	status := waitForState(t, m, id, JobDone)
+
+	if status.Response != response {
+		t.Error("done job should carry the response")
+	}
+	if status.Model != "sonar-deep-research" {
+		t.Errorf("Model = %q, want sonar-deep-research", status.Model)
+	}
+	if status.Err != nil {
+		t.Errorf("Err = %v, want nil", status.Err)
+	}
+}
+
+func TestJobManager_Error(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	wantErr := errors.New("boom")
+	m := NewJobManager(blockingRun(release, nil, wantErr), time.Hour, 1)
+	defer m.Shutdown()
+
+	id, err := m.Start(QueryParams{UserPrompt: "q"})
+	if err != nil {
+		t.Fatalf("Start unexpected error: %v", err)
+	}
+	status := waitForState(t, m, id, JobError)
+	if !errors.Is(status.Err, wantErr) {
+		t.Errorf("Err = %v, want %v", status.Err, wantErr)
+	}
+}
+
+func TestJobManager_ConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	m := NewJobManager(blockingRun(release, &perplexity.CompletionResponse{}, nil), time.Hour, 2)
+	defer m.Shutdown()
+
+	first, err := m.Start(QueryParams{})
+	if err != nil {
+		t.Fatalf("Start #1 unexpected error: %v", err)
+	}
+	if _, err := m.Start(QueryParams{}); err != nil {
+		t.Fatalf("Start #2 unexpected error: %v", err)
+	}
+	if _, err := m.Start(QueryParams{}); !errors.Is(err, clerrors.ErrTooManyJobs) {
+		t.Fatalf("Start #3 error = %v, want ErrTooManyJobs", err)
+	}
+
+	// Finished jobs free their slot.
+	close(release)
+	waitForState(t, m, first, JobDone)
+	m.wg.Wait()
+	if _, err := m.Start(QueryParams{}); err != nil {
+		t.Errorf("Start after completion unexpected error: %v", err)
+	}
+}
+
+func TestJobManager_TTLExpiry(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	m := NewJobManager(blockingRun(release, &perplexity.CompletionResponse{}, nil), time.Minute, 1)
+	defer m.Shutdown()
+
+	var mu sync.Mutex
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		now = now.Add(d)
+		mu.Unlock()
+	}
+
+	id, err := m.Start(QueryParams{})
+	if err != nil {
+		t.Fatalf("Start unexpected error: %v", err)
+	}
+	waitForState(t, m, id, JobDone)
+
+	advance(30 * time.Second)
+	if _, err := m.Status(id); err != nil {
+		t.Fatalf("job expired before TTL: %v", err)
+	}
+
+	advance(time.Minute)
+	if _, err := m.Status(id); !errors.Is(err, clerrors.ErrJobNotFound) {
+		t.Errorf("Status after TTL error = %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestJobManager_UnknownJob(t *testing.T) {
+	m := NewJobManager(nil, 0, 0)
+	defer m.Shutdown()
+
+	if _, err := m.Status("nope"); !errors.Is(err, clerrors.ErrJobNotFound) {
+		t.Errorf("Status(nope) error = %v, want ErrJobNotFound", err)
+	}
+	if m.ttl != DefaultJobTTL || m.maxConcurrent != DefaultMaxConcurrentJobs {
+		t.Errorf("defaults not applied: ttl=%v max=%d", m.ttl, m.maxConcurrent)
+	}
+}
+
+func TestJobManager_ShutdownCancelsJobs(t *testing.T) {
+	m := NewJobManager(blockingRun(make(chan struct{}), nil, nil), time.Hour, 1)
+
+	id, err := m.Start(QueryParams{})
+	if err != nil {
+		t.Fatalf("Start unexpected error: %v", err)
+	}
+	m.Shutdown()
+
+	status, err := m.Status(id)
+	if err != nil {
+		t.Fatalf("Status unexpected error: %v", err)
+	}
+	if status.State != JobError || !errors.Is(status.Err, context.Canceled) {
+		t.Errorf("state = %s err = %v, want error/context.Canceled", status.State, status.Err)
+	}
+}
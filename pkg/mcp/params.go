@@ -1,9 +1,18 @@
 package mcp
 
 import (
+	"cmp"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/pplx"
 )
 
 // QueryParams contains all parameters for a Perplexity query.
@@ -22,8 +31,13 @@ type QueryParams struct {
 	TopP             float64
 	Timeout          time.Duration
 
+	// ModelFallbacks are the models the query is sent again with, in
+	// order, when the API reports Model unavailable or invalid.
+	ModelFallbacks []string
+
 	// Search/Web options
 	SearchDomains   []string
+	ExcludeDomains  []string
 	SearchRecency   string
 	LocationLat     float64
 	LocationLon     float64
@@ -46,7 +60,7 @@ type QueryParams struct {
 	SearchMode        string
 	SearchContextSize string
 
-	// Date filtering options (MM/DD/YYYY format)
+	// Date filtering options (any format accepted by validation.ParseDate)
 	SearchAfterDate   string
 	SearchBeforeDate  string
 	LastUpdatedAfter  string
@@ -54,85 +68,334 @@ type QueryParams struct {
 
 	// Deep research options
 	ReasoningEffort string
+
+	// Language is the ISO 639-1 code of the answer language.
+	Language string
+
+	// ImageConflictPolicy is the output.image_conflict_policy of the server
+	// config; it is not a tool parameter.
+	ImageConflictPolicy string
+	// StrictOptions is the output.strict_options of the server config; it
+	// is not a tool parameter.
+	StrictOptions bool
+
+	// Credentials. APIKey replaces the server's key for this call and must
+	// never be logged; KeyID selects a key of the server's api.keys instead.
+	APIKey string
+	KeyID  string
+}
+
+// request returns the pplx.QueryRequest that p describes.
+func (p QueryParams) request() pplx.QueryRequest {
+	return pplx.QueryRequest{
+		Prompt:                   p.UserPrompt,
+		SystemPrompt:             p.SystemPrompt,
+		Model:                    p.Model,
+		ModelFallbacks:           p.ModelFallbacks,
+		FrequencyPenalty:         p.FrequencyPenalty,
+		MaxTokens:                p.MaxTokens,
+		PresencePenalty:          p.PresencePenalty,
+		Temperature:              p.Temperature,
+		TopK:                     p.TopK,
+		TopP:                     p.TopP,
+		Timeout:                  p.Timeout,
+		SearchDomains:            p.SearchDomains,
+		ExcludeDomains:           p.ExcludeDomains,
+		SearchRecency:            p.SearchRecency,
+		LocationLat:              p.LocationLat,
+		LocationLon:              p.LocationLon,
+		LocationCountry:          p.LocationCountry,
+		ReturnImages:             p.ReturnImages,
+		ReturnRelated:            p.ReturnRelated,
+		Stream:                   p.Stream,
+		ImageDomains:             p.ImageDomains,
+		ImageFormats:             p.ImageFormats,
+		ResponseFormatJSONSchema: p.ResponseFormatJSONSchema,
+		ResponseFormatRegex:      p.ResponseFormatRegex,
+		SearchMode:               p.SearchMode,
+		SearchContextSize:        p.SearchContextSize,
+		SearchAfterDate:          p.SearchAfterDate,
+		SearchBeforeDate:         p.SearchBeforeDate,
+		LastUpdatedAfter:         p.LastUpdatedAfter,
+		LastUpdatedBefore:        p.LastUpdatedBefore,
+		ReasoningEffort:          p.ReasoningEffort,
+		Language:                 p.Language,
+		ImageConflictPolicy:      p.ImageConflictPolicy,
+		StrictOptions:            p.StrictOptions,
+	}
+}
+
+// chatOptions returns the options of a chat session sending the requests p
+// describes. Answers are returned whole, so they are never streamed.
+func (p QueryParams) chatOptions() chat.Options {
+	return chat.Options{
+		Model:                    p.Model,
+		ModelFallbacks:           p.ModelFallbacks,
+		FrequencyPenalty:         p.FrequencyPenalty,
+		MaxTokens:                p.MaxTokens,
+		PresencePenalty:          p.PresencePenalty,
+		Temperature:              p.Temperature,
+		TopK:                     p.TopK,
+		TopP:                     p.TopP,
+		SearchDomains:            p.SearchDomains,
+		ExcludeDomains:           p.ExcludeDomains,
+		SearchRecency:            p.SearchRecency,
+		LocationLat:              p.LocationLat,
+		LocationLon:              p.LocationLon,
+		LocationCountry:          p.LocationCountry,
+		ReturnImages:             p.ReturnImages,
+		ReturnRelated:            p.ReturnRelated,
+		ImageDomains:             p.ImageDomains,
+		ImageFormats:             p.ImageFormats,
+		ImageConflictPolicy:      p.ImageConflictPolicy,
+		StrictOptions:            p.StrictOptions,
+		ResponseFormatJSONSchema: p.ResponseFormatJSONSchema,
+		ResponseFormatRegex:      p.ResponseFormatRegex,
+		SearchMode:               p.SearchMode,
+		SearchContextSize:        p.SearchContextSize,
+		SearchAfterDate:          p.SearchAfterDate,
+		SearchBeforeDate:         p.SearchBeforeDate,
+		LastUpdatedAfter:         p.LastUpdatedAfter,
+		LastUpdatedBefore:        p.LastUpdatedBefore,
+		ReasoningEffort:          p.ReasoningEffort,
+		Language:                 p.Language,
+	}
 }
 
 // ParameterExtractor extracts and validates MCP tool parameters.
-type ParameterExtractor struct{}
+type ParameterExtractor struct {
+	// fallback supplies the core parameters that neither the arguments
+	// nor the config set.
+	fallback QueryParams
+}
 
-// NewParameterExtractor creates a new parameter extractor.
+// NewParameterExtractor creates a new parameter extractor that falls back on
+// the perplexity-go library defaults.
 func NewParameterExtractor() *ParameterExtractor {
-	return &ParameterExtractor{}
+	return NewParameterExtractorWithDefaults(LibraryDefaults())
+}
+
+// NewParameterExtractorWithDefaults creates a parameter extractor that falls
+// back on the model, penalties, max_tokens, temperature, top_k, top_p and
+// timeout of defaults. Zero fields of defaults are left unset.
+func NewParameterExtractorWithDefaults(defaults QueryParams) *ParameterExtractor {
+	return &ParameterExtractor{fallback: defaults}
+}
+
+// LibraryDefaults returns the core query parameters of the perplexity-go
+// library. max_tokens is left zero: the handler sends the default of the
+// model (see models.DefaultMaxTokens).
+func LibraryDefaults() QueryParams {
+	return QueryParams{
+		Model:            perplexity.DefaultModel,
+		FrequencyPenalty: perplexity.DefaultFrequencyPenalty,
+		PresencePenalty:  perplexity.DefaultPresencePenalty,
+		Temperature:      perplexity.DefaultTemperature,
+		TopK:             perplexity.DefaultTopK,
+		TopP:             perplexity.DefaultTopP,
+		Timeout:          perplexity.DefaultTimeout,
+	}
 }
 
 // Extract converts raw MCP arguments to typed QueryParams.
 func (e *ParameterExtractor) Extract(args map[string]any) (*QueryParams, error) {
+	return e.ExtractWithDefaults(args, nil)
+}
+
+// ExtractWithDefaults converts raw MCP arguments to typed QueryParams.
+// Arguments the caller omits take their value from cfg (the defaults, search
+// and output sections of the config file) and then from the defaults of the
+// extractor. A nil cfg skips the config layer. The model, given or
+// defaulted, is expanded if it is an alias in cfg.
+func (e *ParameterExtractor) ExtractWithDefaults(args map[string]any, cfg *config.ConfigData) (*QueryParams, error) {
 	// Extract required user_prompt
 	userPrompt, ok := args["user_prompt"].(string)
 	if !ok || userPrompt == "" {
 		return nil, NewParameterError("user_prompt", args["user_prompt"], "must be a non-empty string")
 	}
 
+	d := defaultsFromConfig(cfg)
 	params := &QueryParams{
 		UserPrompt: userPrompt,
 	}
 
 	// Extract optional parameters
 	params.SystemPrompt = e.extractString(args, "system_prompt", "")
-	params.Model = e.extractString(args, "model", "")
-	params.FrequencyPenalty = e.extractFloat(args, "frequency_penalty", 0)
-	params.MaxTokens = e.extractInt(args, "max_tokens", 0)
-	params.PresencePenalty = e.extractFloat(args, "presence_penalty", 0)
-	params.Temperature = e.extractFloat(args, "temperature", 0)
-	params.TopK = e.extractInt(args, "top_k", 0)
-	params.TopP = e.extractFloat(args, "top_p", 0)
+	params.Model = e.extractString(args, "model", d.Model)
+	if cfg != nil {
+		params.Model = models.Resolve(params.Model, cfg.Models.Aliases)
+	}
+	fallbacks := e.extractStringSlice(args, "model_fallbacks", d.ModelFallbacks)
+	for _, m := range fallbacks {
+		if cfg != nil {
+			m = models.Resolve(m, cfg.Models.Aliases)
+		}
+		params.ModelFallbacks = append(params.ModelFallbacks, m)
+	}
+
+	// Numeric and boolean options: wrong-typed values are coerced or
+	// rejected, never dropped.
+	var timeoutSeconds float64
+	floats := []struct {
+		key    string
+		dst    *float64
+		defVal float64
+	}{
+		{"frequency_penalty", &params.FrequencyPenalty, d.FrequencyPenalty},
+		{"presence_penalty", &params.PresencePenalty, d.PresencePenalty},
+		{"temperature", &params.Temperature, d.Temperature},
+		{"top_p", &params.TopP, d.TopP},
+		{"timeout", &timeoutSeconds, 0},
+		{"location_lat", &params.LocationLat, d.LocationLat},
+		{"location_lon", &params.LocationLon, d.LocationLon},
+	}
+	for _, f := range floats {
+		v, err := e.extractFloat(args, f.key, f.defVal)
+		if err != nil {
+			return nil, err
+		}
+		*f.dst = v
+	}
+	ints := []struct {
+		key    string
+		dst    *int
+		defVal int
+	}{
+		{"max_tokens", &params.MaxTokens, cmp.Or(d.MaxTokens, e.fallback.MaxTokens)},
+		{"top_k", &params.TopK, d.TopK},
+	}
+	for _, i := range ints {
+		v, err := e.extractInt(args, i.key, i.defVal)
+		if err != nil {
+			return nil, err
+		}
+		*i.dst = v
+	}
+	bools := []struct {
+		key    string
+		dst    *bool
+		defVal bool
+	}{
+		{"return_images", &params.ReturnImages, d.ReturnImages},
+		{"return_related", &params.ReturnRelated, d.ReturnRelated},
+		{"stream", &params.Stream, false},
+	}
+	for _, b := range bools {
+		v, err := e.extractBool(args, b.key, b.defVal)
+		if err != nil {
+			return nil, err
+		}
+		*b.dst = v
+	}
 
 	// Timeout is special - convert from seconds to Duration
-	timeoutSeconds := e.extractFloat(args, "timeout", 0)
+	params.Timeout = d.Timeout
 	if timeoutSeconds > 0 {
 		params.Timeout = time.Duration(timeoutSeconds) * time.Second
 	}
 
 	// Search/Web options
-	params.SearchDomains = e.extractStringSlice(args, "search_domains")
-	params.SearchRecency = e.extractString(args, "search_recency", "")
-	params.LocationLat = e.extractFloat(args, "location_lat", 0)
-	params.LocationLon = e.extractFloat(args, "location_lon", 0)
-	params.LocationCountry = e.extractString(args, "location_country", "")
-
-	// Response enhancement options
-	params.ReturnImages = e.extractBool(args, "return_images", false)
-	params.ReturnRelated = e.extractBool(args, "return_related", false)
-	params.Stream = e.extractBool(args, "stream", false)
+	params.SearchDomains = e.extractStringSlice(args, "search_domains", d.SearchDomains)
+	params.ExcludeDomains = e.extractStringSlice(args, "exclude_domains", d.ExcludeDomains)
+	params.SearchRecency = e.extractString(args, "search_recency", d.SearchRecency)
+	params.LocationCountry = e.extractString(args, "location_country", d.LocationCountry)
 
 	// Image filtering options
-	params.ImageDomains = e.extractStringSlice(args, "image_domains")
-	params.ImageFormats = e.extractStringSlice(args, "image_formats")
+	params.ImageDomains = e.extractStringSlice(args, "image_domains", d.ImageDomains)
+	params.ImageFormats = e.extractStringSlice(args, "image_formats", d.ImageFormats)
 
 	// Response format options
-	params.ResponseFormatJSONSchema = e.extractString(args, "response_format_json_schema", "")
-	params.ResponseFormatRegex = e.extractString(args, "response_format_regex", "")
+	params.ResponseFormatJSONSchema = e.extractString(args, "response_format_json_schema", d.ResponseFormatJSONSchema)
+	params.ResponseFormatRegex = e.extractString(args, "response_format_regex", d.ResponseFormatRegex)
 
 	// Search mode options
-	params.SearchMode = e.extractString(args, "search_mode", "")
-	params.SearchContextSize = e.extractString(args, "search_context_size", "")
+	params.SearchMode = e.extractString(args, "search_mode", d.SearchMode)
+	params.SearchContextSize = e.extractString(args, "search_context_size", d.SearchContextSize)
 
 	// Date filtering options
-	params.SearchAfterDate = e.extractString(args, "search_after_date", "")
-	params.SearchBeforeDate = e.extractString(args, "search_before_date", "")
-	params.LastUpdatedAfter = e.extractString(args, "last_updated_after", "")
-	params.LastUpdatedBefore = e.extractString(args, "last_updated_before", "")
+	params.SearchAfterDate = e.extractString(args, "search_after_date", d.SearchAfterDate)
+	params.SearchBeforeDate = e.extractString(args, "search_before_date", d.SearchBeforeDate)
+	params.LastUpdatedAfter = e.extractString(args, "last_updated_after", d.LastUpdatedAfter)
+	params.LastUpdatedBefore = e.extractString(args, "last_updated_before", d.LastUpdatedBefore)
 
 	// Deep research options
-	params.ReasoningEffort = e.extractString(args, "reasoning_effort", "")
+	params.ReasoningEffort = e.extractString(args, "reasoning_effort", d.ReasoningEffort)
+
+	// Answer language
+	params.Language = e.extractString(args, "language", d.Language)
+	params.ImageConflictPolicy = d.ImageConflictPolicy
+	params.StrictOptions = d.StrictOptions
+
+	// Credentials
+	if v, ok := args["api_key"]; ok {
+		if params.APIKey, ok = v.(string); !ok {
+			// The value may be a secret: it is not echoed back.
+			return nil, NewParameterError("api_key", nil, "must be a string")
+		}
+	}
+	params.KeyID = e.extractString(args, "key_id", "")
 
-	// Apply default values from perplexity-go library
+	// Apply the defaults of the extractor
 	e.applyDefaults(params)
 
 	return params, nil
 }
 
+// defaultsFromConfig returns the query defaults configured in cfg.
+// A nil cfg yields zero values, which applyDefaults later fills in.
+func defaultsFromConfig(cfg *config.ConfigData) QueryParams {
+	if cfg == nil {
+		return QueryParams{}
+	}
+	d := QueryParams{
+		Model:            cfg.Defaults.Model,
+		ModelFallbacks:   cfg.Defaults.ModelFallbacks,
+		FrequencyPenalty: cfg.Defaults.FrequencyPenalty,
+		MaxTokens:        cfg.Defaults.MaxTokens,
+		PresencePenalty:  cfg.Defaults.PresencePenalty,
+		Temperature:      cfg.Defaults.Temperature,
+		TopK:             cfg.Defaults.TopK,
+		TopP:             cfg.Defaults.TopP,
+
+		SearchDomains:   cfg.Search.Domains,
+		ExcludeDomains:  cfg.Search.ExcludeDomains,
+		SearchRecency:   cfg.Search.Recency,
+		LocationLat:     cfg.Search.LocationLat,
+		LocationLon:     cfg.Search.LocationLon,
+		LocationCountry: cfg.Search.LocationCountry,
+
+		ReturnImages:  cfg.Output.ReturnImages,
+		ReturnRelated: cfg.Output.ReturnRelated,
+		ImageDomains:  cfg.Output.ImageDomains,
+		ImageFormats:  cfg.Output.ImageFormats,
+
+		ResponseFormatJSONSchema: cfg.Output.ResponseFormatJSONSchema,
+		ResponseFormatRegex:      cfg.Output.ResponseFormatRegex,
+
+		SearchMode:        cfg.Search.Mode,
+		SearchContextSize: cfg.Search.ContextSize,
+
+		SearchAfterDate:   cfg.Search.AfterDate,
+		SearchBeforeDate:  cfg.Search.BeforeDate,
+		LastUpdatedAfter:  cfg.Search.LastUpdatedAfter,
+		LastUpdatedBefore: cfg.Search.LastUpdatedBefore,
+
+		ReasoningEffort: cfg.Output.ReasoningEffort,
+
+		Language: cfg.Defaults.Language,
+
+		ImageConflictPolicy: cfg.Output.ImageConflictPolicy,
+		StrictOptions:       cfg.Output.StrictOptions,
+	}
+	if timeout, err := time.ParseDuration(cfg.Defaults.Timeout); err == nil && timeout > 0 {
+		d.Timeout = timeout
+	} else if cfg.API.TotalTimeout > 0 {
+		d.Timeout = cfg.API.TotalTimeout
+	}
+	return d
+}
+
 // extractString safely extracts a string parameter with a default value.
-//nolint:unparam // defaultVal is kept for API consistency even though currently always ""
 func (e *ParameterExtractor) extractString(args map[string]any, key string, defaultVal string) string {
 	if val, ok := args[key].(string); ok {
 		return val
@@ -140,33 +403,152 @@ func (e *ParameterExtractor) extractString(args map[string]any, key string, defa
 	return defaultVal
 }
 
-// extractFloat safely extracts a float64 parameter with a default value.
-//nolint:unparam // defaultVal is kept for API consistency even though currently always 0
-func (e *ParameterExtractor) extractFloat(args map[string]any, key string, defaultVal float64) float64 {
-	if val, ok := args[key].(float64); ok {
-		return val
+// paramRange is the range of a numeric parameter. max is +Inf when the
+// parameter has no upper bound.
+type paramRange struct {
+	min, max float64
+}
+
+// paramRanges are the ranges of the numeric parameters, as advertised by
+// the tool schema (see queryParameterOptions).
+var paramRanges = map[string]paramRange{
+	"frequency_penalty": {0, maxPenaltyParam},
+	"presence_penalty":  {0, maxPenaltyParam},
+	"temperature":       {0, maxTemperatureParam},
+	"top_p":             {0, 1},
+	"top_k":             {0, maxTopKParam},
+	"max_tokens":        {0, math.Inf(1)},
+	"timeout":           {1, math.Inf(1)},
+	"location_lat":      {-maxLatitude, maxLatitude},
+	"location_lon":      {-maxLongitude, maxLongitude},
+}
+
+// checkRange returns a ParameterError when val is outside the range of key.
+func checkRange(key string, val float64) error {
+	r, ok := paramRanges[key]
+	if !ok || (val >= r.min && val <= r.max) {
+		return nil
 	}
-	return defaultVal
+	if math.IsInf(r.max, 1) {
+		return NewParameterError(key, val, fmt.Sprintf("must be at least %g", r.min))
+	}
+	return NewParameterError(key, val, fmt.Sprintf("must be between %g and %g", r.min, r.max))
 }
 
-// extractInt safely extracts an int parameter (converting from float64) with a default value.
-func (e *ParameterExtractor) extractInt(args map[string]any, key string, defaultVal int) int {
-	if val, ok := args[key].(float64); ok {
-		return int(val)
+// jsonType names the JSON type of a decoded argument, for error messages.
+func jsonType(val any) string {
+	switch val.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, float32, int, int64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", val)
 	}
-	return defaultVal
 }
 
-// extractBool safely extracts a boolean parameter with a default value.
-func (e *ParameterExtractor) extractBool(args map[string]any, key string, defaultVal bool) bool {
-	if val, ok := args[key].(bool); ok {
-		return val
+// toFloat converts a number, or a string holding one, to a finite float64.
+func toFloat(key string, val any) (float64, error) {
+	var f float64
+	switch v := val.(type) {
+	case float64:
+		f = v
+	case float32:
+		f = float64(v)
+	case int:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, NewParameterError(key, val, "must be a number, got a non-numeric string")
+		}
+		f = parsed
+	default:
+		return 0, NewParameterError(key, val, "must be a number, got "+jsonType(val))
 	}
-	return defaultVal
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, NewParameterError(key, val, "must be a finite number")
+	}
+	return f, nil
 }
 
-// extractStringSlice safely extracts a string slice parameter.
-func (e *ParameterExtractor) extractStringSlice(args map[string]any, key string) []string {
+// extractFloat extracts a float64 parameter with a default value. Integers
+// and numeric strings are accepted; other values, and values outside the
+// range of the parameter, are a ParameterError.
+func (e *ParameterExtractor) extractFloat(args map[string]any, key string, defaultVal float64) (float64, error) {
+	val, ok := args[key]
+	if !ok || val == nil {
+		return defaultVal, nil
+	}
+	f, err := toFloat(key, val)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkRange(key, f); err != nil {
+		return 0, err
+	}
+	return f, nil
+}
+
+// extractInt extracts an int parameter with a default value. Numbers with
+// an integral value, such as the float64 of decoded JSON, and numeric
+// strings are accepted; other values, and values outside the range of the
+// parameter, are a ParameterError.
+func (e *ParameterExtractor) extractInt(args map[string]any, key string, defaultVal int) (int, error) {
+	val, ok := args[key]
+	if !ok || val == nil {
+		return defaultVal, nil
+	}
+	f, err := toFloat(key, val)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) {
+		return 0, NewParameterError(key, val, "must be an integer")
+	}
+	if f < math.MinInt32 || f > math.MaxInt32 {
+		return 0, NewParameterError(key, val, "integer out of range")
+	}
+	if err := checkRange(key, f); err != nil {
+		return 0, err
+	}
+	return int(f), nil
+}
+
+// extractBool extracts a boolean parameter with a default value. The
+// strings "true" and "false" are accepted; other values are a
+// ParameterError.
+func (e *ParameterExtractor) extractBool(args map[string]any, key string, defaultVal bool) (bool, error) {
+	val, ok := args[key]
+	if !ok || val == nil {
+		return defaultVal, nil
+	}
+	switch v := val.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return false, NewParameterError(key, val, `must be a boolean, got a string other than "true" or "false"`)
+	default:
+		return false, NewParameterError(key, val, "must be a boolean, got "+jsonType(val))
+	}
+}
+
+// extractStringSlice safely extracts a string slice parameter with a default value.
+func (e *ParameterExtractor) extractStringSlice(args map[string]any, key string, defaultVal []string) []string {
 	if val, ok := args[key].([]any); ok {
 		result := make([]string, 0, len(val))
 		for _, item := range val {
@@ -176,33 +558,33 @@ func (e *ParameterExtractor) extractStringSlice(args map[string]any, key string)
 		}
 		return result
 	}
-	return nil
+	return defaultVal
 }
 
-// applyDefaults applies default values from the perplexity-go library.
+// applyDefaults fills the zero core parameters from the defaults of the
+// extractor. max_tokens is left out: zero is the model default, and the
+// default of the extractor only applies when max_tokens is omitted.
 func (e *ParameterExtractor) applyDefaults(params *QueryParams) {
+	d := e.fallback
 	if params.Model == "" {
-		params.Model = perplexity.DefaultModel
+		params.Model = d.Model
 	}
 	if params.FrequencyPenalty == 0 {
-		params.FrequencyPenalty = perplexity.DefaultFrequencyPenalty
-	}
-	if params.MaxTokens == 0 {
-		params.MaxTokens = perplexity.DefaultMaxTokens
+		params.FrequencyPenalty = d.FrequencyPenalty
 	}
 	if params.PresencePenalty == 0 {
-		params.PresencePenalty = perplexity.DefaultPresencePenalty
+		params.PresencePenalty = d.PresencePenalty
 	}
 	if params.Temperature == 0 {
-		params.Temperature = perplexity.DefaultTemperature
+		params.Temperature = d.Temperature
 	}
 	if params.TopK == 0 {
-		params.TopK = perplexity.DefaultTopK
+		params.TopK = d.TopK
 	}
 	if params.TopP == 0 {
-		params.TopP = perplexity.DefaultTopP
+		params.TopP = d.TopP
 	}
 	if params.Timeout == 0 {
-		params.Timeout = perplexity.DefaultTimeout
+		params.Timeout = d.Timeout
 	}
 }
@@ -2,10 +2,12 @@ package mcp
 
 import (
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/config"
 )
 
 func TestParameterExtractor_Extract(t *testing.T) {
@@ -91,7 +93,6 @@ func TestParameterExtractor_Extract(t *testing.T) {
 				UserPrompt:       "test query",
 				Model:            perplexity.DefaultModel,
 				FrequencyPenalty: perplexity.DefaultFrequencyPenalty,
-				MaxTokens:        perplexity.DefaultMaxTokens,
 				PresencePenalty:  perplexity.DefaultPresencePenalty,
 				Temperature:      perplexity.DefaultTemperature,
 				TopK:             perplexity.DefaultTopK,
@@ -122,6 +123,64 @@ func TestParameterExtractor_Extract(t *testing.T) {
 			shouldErr: true,
 			errMsg:    "must be a non-empty string",
 		},
+		{
+			name: "wrong-typed values coerced",
+			args: map[string]any{
+				"user_prompt":   "test query",
+				"temperature":   "0.7",
+				"max_tokens":    "500",
+				"top_k":         float64(20),
+				"timeout":       "90",
+				"return_images": "true",
+				"stream":        "false",
+			},
+			expected: &QueryParams{
+				UserPrompt:       "test query",
+				Model:            perplexity.DefaultModel,
+				FrequencyPenalty: perplexity.DefaultFrequencyPenalty,
+				MaxTokens:        500,
+				Temperature:      0.7,
+				TopK:             20,
+				Timeout:          90 * time.Second,
+				ReturnImages:     true,
+			},
+		},
+		{
+			name: "invalid temperature",
+			args: map[string]any{
+				"user_prompt": "test query",
+				"temperature": "abc",
+			},
+			shouldErr: true,
+			errMsg:    "parameter error for temperature=abc: must be a number",
+		},
+		{
+			name: "temperature out of range",
+			args: map[string]any{
+				"user_prompt": "test query",
+				"temperature": 3,
+			},
+			shouldErr: true,
+			errMsg:    "must be between 0 and 2",
+		},
+		{
+			name: "max_tokens negative",
+			args: map[string]any{
+				"user_prompt": "test query",
+				"max_tokens":  "-1",
+			},
+			shouldErr: true,
+			errMsg:    "max_tokens=-1: must be at least 0",
+		},
+		{
+			name: "stream not a boolean",
+			args: map[string]any{
+				"user_prompt": "test query",
+				"stream":      "yes",
+			},
+			shouldErr: true,
+			errMsg:    "must be a boolean",
+		},
 	}
 
 	for _, tt := range tests {
@@ -185,6 +244,81 @@ func TestParameterExtractor_Extract(t *testing.T) {
 	}
 }
 
+func TestParameterExtractor_ExtractWithDefaults(t *testing.T) {
+	cfg := config.NewConfigData()
+	cfg.Defaults.Model = "sonar-pro"
+	cfg.Defaults.Temperature = 0.5
+	cfg.Defaults.Timeout = "45s"
+	cfg.Search.Domains = []string{"go.dev"}
+	cfg.Search.Mode = "academic"
+	cfg.Output.ReturnRelated = true
+
+	extractor := NewParameterExtractor()
+
+	t.Run("omitted arguments use config", func(t *testing.T) {
+		params, err := extractor.ExtractWithDefaults(map[string]any{"user_prompt": "q"}, cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if params.Model != "sonar-pro" || params.Temperature != 0.5 || params.Timeout != 45*time.Second {
+			t.Errorf("core defaults not applied: %+v", params)
+		}
+		if len(params.SearchDomains) != 1 || params.SearchMode != "academic" || !params.ReturnRelated {
+			t.Errorf("search/output defaults not applied: %+v", params)
+		}
+		// max_tokens the config leaves empty stays zero, the model default.
+		if params.MaxTokens != 0 {
+			t.Errorf("MaxTokens = %d, want 0 for the model default", params.MaxTokens)
+		}
+	})
+
+	t.Run("arguments win over config", func(t *testing.T) {
+		params, err := extractor.ExtractWithDefaults(map[string]any{
+			"user_prompt":    "q",
+			"model":          "sonar",
+			"search_domains": []any{},
+			"return_related": false,
+		}, cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if params.Model != "sonar" || len(params.SearchDomains) != 0 || params.ReturnRelated {
+			t.Errorf("explicit arguments overridden by config: %+v", params)
+		}
+	})
+
+	t.Run("model aliases expand", func(t *testing.T) {
+		aliased := config.NewConfigData()
+		aliased.Defaults.Model = "deep"
+		aliased.Models.Aliases = map[string]string{"fast": "sonar", "deep": "sonar-deep-research"}
+
+		params, err := extractor.ExtractWithDefaults(map[string]any{"user_prompt": "q", "model": "fast"}, aliased)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if params.Model != "sonar" {
+			t.Errorf("Model = %q, want alias target sonar", params.Model)
+		}
+		params, err = extractor.ExtractWithDefaults(map[string]any{"user_prompt": "q"}, aliased)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if params.Model != "sonar-deep-research" {
+			t.Errorf("Model = %q, want the default alias expanded", params.Model)
+		}
+	})
+
+	t.Run("nil config matches Extract", func(t *testing.T) {
+		params, err := extractor.ExtractWithDefaults(map[string]any{"user_prompt": "q"}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if params.Model != perplexity.DefaultModel {
+			t.Errorf("Model = %q, want %q", params.Model, perplexity.DefaultModel)
+		}
+	})
+}
+
 func TestParameterExtractor_ExtractString(t *testing.T) {
 	extractor := NewParameterExtractor()
 
@@ -237,6 +371,7 @@ func TestParameterExtractor_ExtractFloat(t *testing.T) {
 		key      string
 		defVal   float64
 		expected float64
+		errMsg   string
 	}{
 		{
 			name:     "float present",
@@ -253,17 +388,107 @@ func TestParameterExtractor_ExtractFloat(t *testing.T) {
 			expected: 1.0,
 		},
 		{
-			name:     "wrong type",
-			args:     map[string]any{"temperature": "not a float"},
+			name:     "null uses default",
+			args:     map[string]any{"temperature": nil},
 			key:      "temperature",
 			defVal:   1.0,
 			expected: 1.0,
 		},
+		{
+			name:     "numeric string",
+			args:     map[string]any{"temperature": "0.7"},
+			key:      "temperature",
+			defVal:   1.0,
+			expected: 0.7,
+		},
+		{
+			name:     "numeric string with spaces",
+			args:     map[string]any{"top_p": " 0.5 "},
+			key:      "top_p",
+			expected: 0.5,
+		},
+		{
+			name:     "integer",
+			args:     map[string]any{"temperature": 1},
+			key:      "temperature",
+			expected: 1,
+		},
+		{
+			name:     "negative latitude",
+			args:     map[string]any{"location_lat": "-33.86"},
+			key:      "location_lat",
+			expected: -33.86,
+		},
+		{
+			name:   "non-numeric string",
+			args:   map[string]any{"temperature": "abc"},
+			key:    "temperature",
+			defVal: 1.0,
+			errMsg: "temperature=abc: must be a number, got a non-numeric string",
+		},
+		{
+			name:   "boolean",
+			args:   map[string]any{"temperature": true},
+			key:    "temperature",
+			errMsg: "must be a number, got boolean",
+		},
+		{
+			name:   "array",
+			args:   map[string]any{"top_p": []any{0.5}},
+			key:    "top_p",
+			errMsg: "must be a number, got array",
+		},
+		{
+			name:   "NaN string",
+			args:   map[string]any{"temperature": "NaN"},
+			key:    "temperature",
+			errMsg: "must be a finite number",
+		},
+		{
+			name:   "temperature above range",
+			args:   map[string]any{"temperature": 2.5},
+			key:    "temperature",
+			errMsg: "must be between 0 and 2",
+		},
+		{
+			name:   "negative temperature string",
+			args:   map[string]any{"temperature": "-0.1"},
+			key:    "temperature",
+			errMsg: "must be between 0 and 2",
+		},
+		{
+			name:   "top_p above range",
+			args:   map[string]any{"top_p": 1.5},
+			key:    "top_p",
+			errMsg: "must be between 0 and 1",
+		},
+		{
+			name:   "longitude out of range",
+			args:   map[string]any{"location_lon": 200.0},
+			key:    "location_lon",
+			errMsg: "must be between -180 and 180",
+		},
+		{
+			name:   "timeout below range",
+			args:   map[string]any{"timeout": 0.5},
+			key:    "timeout",
+			errMsg: "must be at least 1",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := extractor.extractFloat(tt.args, tt.key, tt.defVal)
+			result, err := extractor.extractFloat(tt.args, tt.key, tt.defVal)
+			if tt.errMsg != "" {
+				var paramErr *ParameterError
+				if !errors.As(err, &paramErr) || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("Expected ParameterError containing %q, got %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
 			if result != tt.expected {
 				t.Errorf("Expected %v, got %v", tt.expected, result)
 			}
@@ -280,6 +505,7 @@ func TestParameterExtractor_ExtractInt(t *testing.T) {
 		key      string
 		defVal   int
 		expected int
+		errMsg   string
 	}{
 		{
 			name:     "float converted to int",
@@ -296,17 +522,83 @@ func TestParameterExtractor_ExtractInt(t *testing.T) {
 			expected: 50,
 		},
 		{
-			name:     "wrong type",
-			args:     map[string]any{"max_tokens": "not a number"},
+			name:     "integral float",
+			args:     map[string]any{"top_k": 40.0},
+			key:      "top_k",
+			expected: 40,
+		},
+		{
+			name:     "numeric string",
+			args:     map[string]any{"max_tokens": "2000"},
 			key:      "max_tokens",
 			defVal:   50,
-			expected: 50,
+			expected: 2000,
+		},
+		{
+			name:     "integral float string",
+			args:     map[string]any{"max_tokens": "2000.0"},
+			key:      "max_tokens",
+			expected: 2000,
+		},
+		{
+			name:     "zero top_k",
+			args:     map[string]any{"top_k": 0},
+			key:      "top_k",
+			defVal:   10,
+			expected: 0,
+		},
+		{
+			name:   "non-numeric string",
+			args:   map[string]any{"max_tokens": "not a number"},
+			key:    "max_tokens",
+			defVal: 50,
+			errMsg: "must be a number, got a non-numeric string",
+		},
+		{
+			name:   "object",
+			args:   map[string]any{"max_tokens": map[string]any{"value": 1}},
+			key:    "max_tokens",
+			errMsg: "must be a number, got object",
+		},
+		{
+			name:   "fractional float",
+			args:   map[string]any{"max_tokens": 100.5},
+			key:    "max_tokens",
+			errMsg: "must be an integer",
+		},
+		{
+			name:   "negative max_tokens string",
+			args:   map[string]any{"max_tokens": "-5"},
+			key:    "max_tokens",
+			errMsg: "must be at least 0",
+		},
+		{
+			name:   "huge max_tokens",
+			args:   map[string]any{"max_tokens": 1e20},
+			key:    "max_tokens",
+			errMsg: "integer out of range",
+		},
+		{
+			name:   "top_k above range",
+			args:   map[string]any{"top_k": 101},
+			key:    "top_k",
+			errMsg: "must be between 0 and 100",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := extractor.extractInt(tt.args, tt.key, tt.defVal)
+			result, err := extractor.extractInt(tt.args, tt.key, tt.defVal)
+			if tt.errMsg != "" {
+				var paramErr *ParameterError
+				if !errors.As(err, &paramErr) || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("Expected ParameterError containing %q, got %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
 			if result != tt.expected {
 				t.Errorf("Expected %d, got %d", tt.expected, result)
 			}
@@ -323,6 +615,7 @@ func TestParameterExtractor_ExtractBool(t *testing.T) {
 		key      string
 		defVal   bool
 		expected bool
+		errMsg   string
 	}{
 		{
 			name:     "bool true",
@@ -346,17 +639,46 @@ func TestParameterExtractor_ExtractBool(t *testing.T) {
 			expected: true,
 		},
 		{
-			name:     "wrong type",
-			args:     map[string]any{"stream": "not a bool"},
+			name:     "string true",
+			args:     map[string]any{"stream": "true"},
 			key:      "stream",
-			defVal:   false,
+			expected: true,
+		},
+		{
+			name:     "string FALSE",
+			args:     map[string]any{"stream": "FALSE"},
+			key:      "stream",
+			defVal:   true,
 			expected: false,
 		},
+		{
+			name:   "other string",
+			args:   map[string]any{"stream": "not a bool"},
+			key:    "stream",
+			defVal: false,
+			errMsg: `must be a boolean, got a string other than "true" or "false"`,
+		},
+		{
+			name:   "number",
+			args:   map[string]any{"return_images": float64(1)},
+			key:    "return_images",
+			errMsg: "must be a boolean, got number",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := extractor.extractBool(tt.args, tt.key, tt.defVal)
+			result, err := extractor.extractBool(tt.args, tt.key, tt.defVal)
+			if tt.errMsg != "" {
+				var paramErr *ParameterError
+				if !errors.As(err, &paramErr) || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("Expected ParameterError containing %q, got %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
 			if result != tt.expected {
 				t.Errorf("Expected %v, got %v", tt.expected, result)
 			}
@@ -407,7 +729,7 @@ func TestParameterExtractor_ExtractStringSlice(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := extractor.extractStringSlice(tt.args, tt.key)
+			result := extractor.extractStringSlice(tt.args, tt.key, nil)
 			if !stringSliceEqual(result, tt.expected) {
 				t.Errorf("Expected %v, got %v", tt.expected, result)
 			}
@@ -431,9 +753,6 @@ func TestParameterExtractor_ApplyDefaults(t *testing.T) {
 		if params.FrequencyPenalty != perplexity.DefaultFrequencyPenalty {
 			t.Errorf("Expected frequency_penalty %v, got %v", perplexity.DefaultFrequencyPenalty, params.FrequencyPenalty)
 		}
-		if params.MaxTokens != perplexity.DefaultMaxTokens {
-			t.Errorf("Expected max_tokens %d, got %d", perplexity.DefaultMaxTokens, params.MaxTokens)
-		}
 		if params.PresencePenalty != perplexity.DefaultPresencePenalty {
 			t.Errorf("Expected presence_penalty %v, got %v", perplexity.DefaultPresencePenalty, params.PresencePenalty)
 		}
@@ -477,6 +796,30 @@ func TestParameterExtractor_ApplyDefaults(t *testing.T) {
 	})
 }
 
+func TestNewParameterExtractorWithDefaults(t *testing.T) {
+	extractor := NewParameterExtractorWithDefaults(QueryParams{Model: "sonar-pro", Temperature: 0.6})
+
+	params, err := extractor.Extract(map[string]any{"user_prompt": "q"})
+	if err != nil {
+		t.Fatalf("Extract() error: %v", err)
+	}
+	if params.Model != "sonar-pro" || params.Temperature != 0.6 {
+		t.Errorf("Model, Temperature = %q, %v, want sonar-pro, 0.6", params.Model, params.Temperature)
+	}
+	// Parameters the defaults leave zero stay unset.
+	if params.MaxTokens != 0 {
+		t.Errorf("MaxTokens = %d, want 0", params.MaxTokens)
+	}
+
+	params, err = extractor.Extract(map[string]any{"user_prompt": "q", "temperature": 0.1})
+	if err != nil {
+		t.Fatalf("Extract() error: %v", err)
+	}
+	if params.Temperature != 0.1 {
+		t.Errorf("Temperature = %v, want 0.1 from the arguments", params.Temperature)
+	}
+}
+
 // Helper functions
 
 func contains(s, substr string) bool {
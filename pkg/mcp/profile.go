@@ -0,0 +1,95 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+// ProfileResult is the result of the set_profile tool.
+type ProfileResult struct {
+	// Profile is the profile now applied, "default" for none.
+	Profile string `json:"profile"`
+	// DefaultModel is the model of tool calls that omit it.
+	DefaultModel string `json:"default_model"`
+}
+
+// BuildSetProfileTool creates the MCP tool definition that switches the
+// config profile of the server session.
+func BuildSetProfileTool() *mcp.Tool {
+	tool := mcp.NewTool("set_profile",
+		mcp.WithDescription("Switch the config profile whose defaults (model, temperature, search and output "+
+			"options) apply to the arguments later tool calls omit, for the rest of this server session. "+
+			"The config file is not changed. Use \"default\" to return to the server's startup config. "+
+			"Does not call the Perplexity API."),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(false),
+		mcp.WithString("profile",
+			mcp.Required(),
+			mcp.Description("Name of a profile of the config file, or \"default\""),
+		),
+	)
+	return &tool
+}
+
+// AddProfileTool registers the set_profile tool.
+func (s *MCPServer) AddProfileTool() error {
+	s.server.AddTool(*BuildSetProfileTool(), s.handleSetProfile)
+	return nil
+}
+
+// handleSetProfile applies the profile of the arguments to the session.
+func (s *MCPServer) handleSetProfile(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	start := time.Now()
+	name, ok := request.GetArguments()["profile"].(string)
+	if !ok || name == "" {
+		err := NewParameterError("profile", request.GetArguments()["profile"], "must be a non-empty string")
+		logToolCall(request.Params.Name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	err := s.SetProfile(name)
+	logToolCall(request.Params.Name, "", start, nil, err)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return s.formatter.marshal(ProfileResult{Profile: name, DefaultModel: s.info().DefaultModel})
+}
+
+// SetProfile applies the named profile over the config of SetDefaults for
+// the tool calls that follow, until SetProfile is called again. The profile
+// is kept across config reloads and is never written to the config file.
+// "default" removes the profile. Returns an error wrapping
+// clerrors.ErrProfileNotFound when the config has no such profile.
+func (s *MCPServer) SetProfile(name string) error {
+	if name == config.DefaultProfileName {
+		name = ""
+	}
+
+	s.profileMu.Lock()
+	defer s.profileMu.Unlock()
+	merged, err := mergeProfile(s.base, name)
+	if err != nil {
+		return fmt.Errorf("failed to apply profile %q: %w", name, err)
+	}
+	s.profile = name
+	s.defaults.Store(merged)
+	return nil
+}
+
+// mergeProfile returns cfg with the named profile applied, or cfg itself
+// when name is empty.
+func mergeProfile(cfg *config.ConfigData, name string) (*config.ConfigData, error) {
+	if name == "" {
+		return cfg, nil
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("%w: '%s'", clerrors.ErrProfileNotFound, name)
+	}
+	return config.NewProfileManager(cfg).MergeProfile(name) //nolint:wrapcheck // names the profile
+}
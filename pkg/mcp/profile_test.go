@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// newProfileConfig returns a config with a base temperature of 0.3 and a
+// research profile that sets the model and a temperature of temperature.
+func newProfileConfig(temperature float64) *config.ConfigData {
+	cfg := config.NewConfigData()
+	cfg.Defaults.Temperature = 0.3
+	model := "sonar-pro"
+	cfg.Profiles = map[string]*config.Profile{
+		"research": {
+			Name:     "research",
+			Defaults: config.ProfileDefaults{Model: &model, Temperature: &temperature},
+		},
+	}
+	return cfg
+}
+
+// setProfile calls the set_profile tool and returns its result.
+func setProfile(t *testing.T, s *MCPServer, profile any) (ProfileResult, bool) {
+	t.Helper()
+	var req mcp.CallToolRequest
+	req.Params.Arguments = map[string]any{"profile": profile}
+	result, err := s.handleSetProfile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	var payload ProfileResult
+	if !result.IsError {
+		text := result.Content[0].(mcp.TextContent).Text
+		if err := json.Unmarshal([]byte(text), &payload); err != nil {
+			t.Fatalf("invalid JSON %q: %v", text, err)
+		}
+	}
+	return payload, result.IsError
+}
+
+func TestSetProfile_Temperature(t *testing.T) {
+	s, err := NewServer(ServerConfig{APIKey: "test-key", Defaults: newProfileConfig(0.7)})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	got, isErr := setProfile(t, s, "research")
+	if isErr {
+		t.Fatal("set_profile research returned an error result")
+	}
+	if got.Profile != "research" || got.DefaultModel != "sonar-pro" {
+		t.Errorf("result = %+v, want the research profile and its model", got)
+	}
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want float64
+	}{
+		{"omitted takes the profile", map[string]any{"user_prompt": "q"}, 0.7},
+		{"supplied overrides the profile", map[string]any{"user_prompt": "q", "temperature": 0.1}, 0.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := s.extract(tt.args)
+			if err != nil {
+				t.Fatalf("extract() error: %v", err)
+			}
+			if params.Temperature != tt.want {
+				t.Errorf("Temperature = %v, want %v", params.Temperature, tt.want)
+			}
+			if params.Model != "sonar-pro" {
+				t.Errorf("Model = %q, want sonar-pro from the profile", params.Model)
+			}
+		})
+	}
+
+	// "default" returns to the startup config.
+	if _, isErr := setProfile(t, s, "default"); isErr {
+		t.Fatal("set_profile default returned an error result")
+	}
+	params, err := s.extract(map[string]any{"user_prompt": "q"})
+	if err != nil {
+		t.Fatalf("extract() error: %v", err)
+	}
+	if params.Temperature != 0.3 {
+		t.Errorf("Temperature = %v, want 0.3 from the base config", params.Temperature)
+	}
+}
+
+func TestSetProfile_Invalid(t *testing.T) {
+	s, err := NewServer(ServerConfig{APIKey: "test-key", Defaults: newProfileConfig(0.7)})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	for _, profile := range []any{"missing", "", 42} {
+		if _, isErr := setProfile(t, s, profile); !isErr {
+			t.Errorf("set_profile %v should return an error result", profile)
+		}
+	}
+	params, err := s.extract(map[string]any{"user_prompt": "q"})
+	if err != nil {
+		t.Fatalf("extract() error: %v", err)
+	}
+	if params.Temperature != 0.3 {
+		t.Errorf("Temperature = %v, want 0.3: a failed set_profile must not change the defaults", params.Temperature)
+	}
+}
+
+func TestSetProfile_KeptOnReload(t *testing.T) {
+	warnings.Default.Reset()
+	t.Cleanup(warnings.Default.Reset)
+	s, err := NewServer(ServerConfig{APIKey: "test-key", Defaults: newProfileConfig(0.7)})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	if _, isErr := setProfile(t, s, "research"); isErr {
+		t.Fatal("set_profile research returned an error result")
+	}
+
+	temperature := func() float64 {
+		t.Helper()
+		params, err := s.extract(map[string]any{"user_prompt": "q"})
+		if err != nil {
+			t.Fatalf("extract() error: %v", err)
+		}
+		return params.Temperature
+	}
+
+	s.SetDefaults(newProfileConfig(0.9))
+	if got := temperature(); got != 0.9 {
+		t.Errorf("Temperature = %v, want 0.9 from the reloaded profile", got)
+	}
+
+	// A reloaded file without the profile falls back on its base config.
+	s.SetDefaults(config.NewConfigData())
+	if got := temperature(); got == 0.9 {
+		t.Errorf("Temperature = %v, want the base config once the profile is gone", got)
+	}
+	if all := warnings.Default.All(); len(all) != 1 || all[0].Code != warnings.CodeConfig {
+		t.Errorf("warnings = %+v, want one config warning", all)
+	}
+}
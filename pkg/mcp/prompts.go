@@ -0,0 +1,190 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/logger"
+)
+
+// Names of the built-in prompts.
+const (
+	PromptSummarizeURL     = "summarize_url"
+	PromptCompareSources   = "compare_sources"
+	PromptFactCheck        = "fact_check"
+	PromptLiteratureReview = "literature_review"
+)
+
+// builtinPrompt is a prompt shipped with the server: a template in the
+// syntax of the prompts config section and descriptions of its arguments.
+type builtinPrompt struct {
+	template  config.PromptTemplate
+	arguments map[string]string
+}
+
+// builtinPrompts are the prompts every server offers. A prompt of the same
+// name in the prompts config section replaces the built-in one.
+var builtinPrompts = map[string]builtinPrompt{
+	PromptSummarizeURL: {
+		template: config.PromptTemplate{
+			Description: "Summarize the content of a web page",
+			System: "You are a precise summarizer. Read the page the user names and summarize its key points " +
+				"as a short bulleted list, followed by one sentence on why it matters. Cite the page.",
+			User: "Summarize {{url}}",
+		},
+		arguments: map[string]string{"url": "URL of the page to summarize"},
+	},
+	PromptCompareSources: {
+		template: config.PromptTemplate{
+			Description: "Compare how two sources cover a topic",
+			System: "You are a careful analyst. Compare how the two sources cover the topic: where they agree, " +
+				"where they differ, and what each leaves out. Cite both sources.",
+			User: "Compare how {{source_a}} and {{source_b}} cover {{topic}}.",
+		},
+		arguments: map[string]string{
+			"topic":    "Topic to compare the coverage of",
+			"source_a": "First source: a publication, site, or URL",
+			"source_b": "Second source: a publication, site, or URL",
+		},
+	},
+	PromptFactCheck: {
+		template: config.PromptTemplate{
+			Description: "Check a claim against current sources",
+			System: "You are a fact checker. Rate the claim as true, mostly true, misleading, mostly false, " +
+				"or false, then explain the rating with evidence from reliable sources, citing each one.",
+			User: "Fact-check this claim: {{claim}}",
+		},
+		arguments: map[string]string{"claim": "Claim to check"},
+	},
+	PromptLiteratureReview: {
+		template: config.PromptTemplate{
+			Description: "Review the research literature on a topic (best with search_mode academic)",
+			System: "You are a research assistant. Write a structured literature review from peer-reviewed " +
+				"sources: main findings, points of disagreement, methods, and open questions. " +
+				"Cite every source.",
+			User: "Write a literature review of research on {{topic}}.",
+		},
+		arguments: map[string]string{"topic": "Research topic to review"},
+	},
+}
+
+// AddPrompts enables the prompts capability with the built-in prompts and
+// those of the prompts config section of the current defaults, which
+// override built-ins of the same name. SetDefaults keeps them in sync with
+// the config afterwards.
+func (s *MCPServer) AddPrompts() error {
+	s.promptsEnabled.Store(true)
+	s.syncPrompts()
+	return nil
+}
+
+// syncPrompts replaces the served prompts with those of the current
+// defaults. Invalid user prompts are logged and skipped.
+func (s *MCPServer) syncPrompts() {
+	templates := make(map[string]*config.PromptTemplate, len(builtinPrompts))
+	descriptions := make(map[string]map[string]string, len(builtinPrompts))
+	for name, p := range builtinPrompts {
+		templates[name] = &p.template
+		descriptions[name] = p.arguments
+	}
+	if cfg := s.defaults.Load(); cfg != nil {
+		for name, tpl := range cfg.Prompts {
+			if tpl != nil {
+				templates[name] = tpl
+				delete(descriptions, name)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	prompts := make([]server.ServerPrompt, 0, len(names))
+	for _, name := range names {
+		prompt, err := BuildPrompt(name, templates[name], descriptions[name])
+		if err != nil {
+			logger.Warn("skipping invalid prompt template", "prompt", name, "error", err)
+			continue
+		}
+		prompts = append(prompts, server.ServerPrompt{Prompt: prompt, Handler: promptHandler(templates[name])})
+	}
+	s.server.SetPrompts(prompts...)
+}
+
+// BuildPrompt describes the template tpl as an MCP prompt called name. Every
+// variable of the template is a required argument, described by
+// argDescriptions when it has an entry.
+func BuildPrompt(name string, tpl *config.PromptTemplate, argDescriptions map[string]string) (mcp.Prompt, error) {
+	vars, err := tpl.Variables(name)
+	if err != nil {
+		return mcp.Prompt{}, err //nolint:wrapcheck // prompts errors already name the template
+	}
+	description := tpl.Description
+	if description == "" {
+		description = "Prompt template " + name
+	}
+	opts := []mcp.PromptOption{mcp.WithPromptDescription(description +
+		". Expands to the system_prompt and user_prompt arguments of the query tool.")}
+	for _, v := range vars {
+		argDescription := argDescriptions[v]
+		if argDescription == "" {
+			argDescription = "Value of {{" + v + "}}"
+		}
+		opts = append(opts, mcp.WithArgument(v, mcp.ArgumentDescription(argDescription), mcp.RequiredArgument()))
+	}
+	return mcp.NewPrompt(name, opts...), nil
+}
+
+// ExpandPrompt renders tpl with args into the messages of a prompts/get
+// result. The first message holds the system text, addressed to the
+// assistant, and the second the user text; a template without system text
+// yields only the user message.
+func ExpandPrompt(name string, tpl *config.PromptTemplate, args map[string]string) (*mcp.GetPromptResult, error) {
+	vars, err := tpl.Variables(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // prompts errors already name the template
+	}
+	var missing []string
+	for _, v := range vars {
+		if _, ok := args[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, NewParameterError("arguments", nil,
+			fmt.Sprintf("prompt %s requires: %s", name, strings.Join(missing, ", ")))
+	}
+
+	system, user, err := tpl.Render(name, args)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // prompts errors already name the template
+	}
+	var messages []mcp.PromptMessage
+	if system != "" {
+		content := mcp.NewTextContent(system)
+		content.Annotations = &mcp.Annotations{Audience: []mcp.Role{mcp.RoleAssistant}}
+		messages = append(messages, mcp.NewPromptMessage(mcp.RoleUser, content))
+	}
+	messages = append(messages, mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(user)))
+
+	description := tpl.Description
+	if description == "" {
+		description = "Prompt template " + name
+	}
+	return mcp.NewGetPromptResult(description, messages), nil
+}
+
+// promptHandler serves prompts/get requests for tpl.
+func promptHandler(tpl *config.PromptTemplate) server.PromptHandlerFunc {
+	return func(_ context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return ExpandPrompt(request.Params.Name, tpl, request.Params.Arguments)
+	}
+}
@@ -0,0 +1,194 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+// handlePrompts sends a prompts request with params to s and decodes the
+// result into result, returning the JSON-RPC error message, if any.
+func handlePrompts(t *testing.T, s *MCPServer, method, params string, result any) string {
+	t.Helper()
+	msg := `{"jsonrpc":"2.0","id":3,"method":"` + method + `","params":` + params + `}`
+	data, err := json.Marshal(s.server.HandleMessage(context.Background(), json.RawMessage(msg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("invalid response %s: %v", data, err)
+	}
+	if decoded.Error != nil {
+		return decoded.Error.Message
+	}
+	if err := json.Unmarshal(decoded.Result, result); err != nil {
+		t.Fatalf("invalid %s result %s: %v", method, decoded.Result, err)
+	}
+	return ""
+}
+
+type promptList struct {
+	Prompts []struct {
+		Name      string `json:"name"`
+		Arguments []struct {
+			Name     string `json:"name"`
+			Required bool   `json:"required"`
+		} `json:"arguments"`
+	} `json:"prompts"`
+}
+
+type promptResult struct {
+	Messages []struct {
+		Role    string `json:"role"`
+		Content struct {
+			Text        string `json:"text"`
+			Annotations *struct {
+				Audience []string `json:"audience"`
+			} `json:"annotations"`
+		} `json:"content"`
+	} `json:"messages"`
+}
+
+func TestExpandPrompt(t *testing.T) {
+	tpl := &config.PromptTemplate{System: "Answer in {{lang}}.", User: "Explain {{topic}} to {{lang}} speakers."}
+	tests := []struct {
+		name      string
+		tpl       *config.PromptTemplate
+		args      map[string]string
+		want      []string
+		wantError bool
+	}{
+		{"system and user", tpl, map[string]string{"lang": "French", "topic": "tides"},
+			[]string{"Answer in French.", "Explain tides to French speakers."}, false},
+		{"user only", &config.PromptTemplate{User: "Define {{word}}"}, map[string]string{"word": "entropy"},
+			[]string{"Define entropy"}, false},
+		{"extra arguments ignored", &config.PromptTemplate{User: "Hi"}, map[string]string{"x": "y"},
+			[]string{"Hi"}, false},
+		{"missing argument", tpl, map[string]string{"topic": "tides"}, nil, true},
+		{"invalid template", &config.PromptTemplate{User: "{{if .x}}"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExpandPrompt("test", tt.tpl, tt.args)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("ExpandPrompt() = %+v, want an error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandPrompt() error = %v", err)
+			}
+			var got []string
+			for _, msg := range result.Messages {
+				text, ok := msg.Content.(mcp.TextContent)
+				if !ok || msg.Role != mcp.RoleUser {
+					t.Fatalf("message = %+v, want user text", msg)
+				}
+				got = append(got, text.Text)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("messages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, err := ExpandPrompt("test", tpl, map[string]string{})
+	var paramErr *ParameterError
+	if !errors.As(err, &paramErr) || paramErr.Reason != "prompt test requires: lang, topic" {
+		t.Errorf("error = %v, want a ParameterError listing lang and topic", err)
+	}
+}
+
+func TestMCPServer_Prompts(t *testing.T) {
+	s := newHTTPTestServer(t)
+
+	var list promptList
+	if msg := handlePrompts(t, s, "prompts/list", `{}`, &list); msg != "" {
+		t.Fatalf("prompts/list error: %s", msg)
+	}
+	var names []string
+	for _, p := range list.Prompts {
+		names = append(names, p.Name)
+		if p.Name == PromptCompareSources {
+			if len(p.Arguments) != 3 || !p.Arguments[0].Required {
+				t.Errorf("%s arguments = %+v, want 3 required", p.Name, p.Arguments)
+			}
+		}
+	}
+	want := []string{PromptCompareSources, PromptFactCheck, PromptLiteratureReview, PromptSummarizeURL}
+	if !slices.Equal(names, want) {
+		t.Errorf("prompts = %v, want %v", names, want)
+	}
+
+	var result promptResult
+	params := `{"name":"fact_check","arguments":{"claim":"The Moon is made of cheese"}}`
+	if msg := handlePrompts(t, s, "prompts/get", params, &result); msg != "" {
+		t.Fatalf("prompts/get error: %s", msg)
+	}
+	if len(result.Messages) != 2 {
+		t.Fatalf("got %d messages, want system and user", len(result.Messages))
+	}
+	system, user := result.Messages[0].Content, result.Messages[1].Content
+	if system.Annotations == nil || !slices.Equal(system.Annotations.Audience, []string{"assistant"}) {
+		t.Errorf("system message annotations = %+v, want the assistant audience", system.Annotations)
+	}
+	if user.Text != "Fact-check this claim: The Moon is made of cheese" || result.Messages[1].Role != "user" {
+		t.Errorf("user message = %+v", result.Messages[1])
+	}
+
+	if msg := handlePrompts(t, s, "prompts/get", `{"name":"fact_check"}`, &result); msg == "" {
+		t.Error("prompts/get without arguments succeeded, want an error")
+	}
+}
+
+func TestMCPServer_UserPrompts(t *testing.T) {
+	s := newHTTPTestServer(t)
+	cfg := config.NewConfigData()
+	cfg.Prompts = map[string]*config.PromptTemplate{
+		"review":     {Description: "Review code", System: "You review {{language}} code.", User: "{{code}}"},
+		"fact_check": {User: "Is it true that {{claim}}?"},
+		"broken":     {User: "{{if .x}}"},
+	}
+	s.SetDefaults(cfg)
+
+	var list promptList
+	if msg := handlePrompts(t, s, "prompts/list", `{}`, &list); msg != "" {
+		t.Fatalf("prompts/list error: %s", msg)
+	}
+	var names []string
+	for _, p := range list.Prompts {
+		names = append(names, p.Name)
+	}
+	want := []string{PromptCompareSources, PromptFactCheck, PromptLiteratureReview, "review", PromptSummarizeURL}
+	if !slices.Equal(names, want) {
+		t.Errorf("prompts = %v, want %v without the invalid one", names, want)
+	}
+
+	var result promptResult
+	params := `{"name":"fact_check","arguments":{"claim":"water is wet"}}`
+	if msg := handlePrompts(t, s, "prompts/get", params, &result); msg != "" {
+		t.Fatalf("prompts/get error: %s", msg)
+	}
+	if len(result.Messages) != 1 || result.Messages[0].Content.Text != "Is it true that water is wet?" {
+		t.Errorf("messages = %+v, want the config prompt to replace the built-in", result.Messages)
+	}
+
+	// A config reload without prompts restores the built-ins.
+	s.SetDefaults(nil)
+	msg := handlePrompts(t, s, "prompts/list", `{}`, &list)
+	if msg != "" || len(list.Prompts) != len(builtinPrompts) {
+		t.Errorf("prompts after reload = %+v (%s), want the built-ins", list.Prompts, msg)
+	}
+}
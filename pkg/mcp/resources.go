@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/ratelimit"
+)
+
+// Resource URIs served by the MCP server.
+const (
+	// ModelsResourceURI lists the supported models and their capabilities.
+	ModelsResourceURI = "pplx://models"
+	// ConfigOptionsResourceURI lists the tunable configuration options.
+	ConfigOptionsResourceURI = "pplx://config-options"
+	// StatsResourceURI reports the state of the rate limiter.
+	StatsResourceURI = "pplx://stats"
+)
+
+// jsonMIMEType is the MIME type of the JSON resources.
+const jsonMIMEType = "application/json"
+
+// ServerStats is the content of the pplx://stats resource.
+type ServerStats struct {
+	RateLimit ratelimit.Stats `json:"rate_limit"`
+}
+
+// AddResources registers the pplx://models, pplx://config-options, and
+// pplx://stats resources.
+func (s *MCPServer) AddResources() error {
+	s.server.AddResource(
+		mcp.NewResource(ModelsResourceURI, "models",
+			mcp.WithResourceDescription(
+				"Supported Perplexity models: context window, response_format and reasoning_effort support, "+
+					"and relative cost tier"),
+			mcp.WithMIMEType(jsonMIMEType),
+		),
+		jsonResource(func() any { return models.Catalog{Models: models.All()} }),
+	)
+	s.server.AddResource(
+		mcp.NewResource(ConfigOptionsResourceURI, "config-options",
+			mcp.WithResourceDescription(
+				"Configuration options with their section, type, default, and validation rules"),
+			mcp.WithMIMEType(jsonMIMEType),
+		),
+		jsonResource(func() any { return config.NewMetadataRegistry().GetAll() }),
+	)
+	s.server.AddResource(
+		mcp.NewResource(StatsResourceURI, "stats",
+			mcp.WithResourceDescription(
+				"Rate limiter state: configured rate and burst, available tokens, queued requests, "+
+					"and counts of allowed, delayed, and cancelled requests"),
+			mcp.WithMIMEType(jsonMIMEType),
+		),
+		jsonResource(func() any { return ServerStats{RateLimit: s.handler.limiter.Stats()} }),
+	)
+	return nil
+}
+
+// jsonResource returns a resource handler serving the JSON encoding of the
+// value built by build.
+func jsonResource(build func() any) func(context.Context, mcp.ReadResourceRequest) (
+	[]mcp.ResourceContents, error,
+) {
+	return func(_ context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		data, err := json.MarshalIndent(build(), "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", request.Params.URI, err)
+		}
+		return []mcp.ResourceContents{mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: jsonMIMEType,
+			Text:     string(data),
+		}}, nil
+	}
+}
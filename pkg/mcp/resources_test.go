@@ -0,0 +1,97 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/ratelimit"
+)
+
+// readResource sends a resources/read request for uri and returns the text
+// of the single content item.
+func readResource(t *testing.T, s *MCPServer, uri string) string {
+	t.Helper()
+	msg := `{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"` + uri + `"}}`
+	resp := s.server.HandleMessage(context.Background(), json.RawMessage(msg))
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Result struct {
+			Contents []mcp.TextResourceContents `json:"contents"`
+		} `json:"result"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("invalid response %s: %v", data, err)
+	}
+	if decoded.Error != nil {
+		t.Fatalf("resources/read %s error: %s", uri, decoded.Error.Message)
+	}
+	if len(decoded.Result.Contents) != 1 {
+		t.Fatalf("resources/read %s returned %d contents", uri, len(decoded.Result.Contents))
+	}
+	content := decoded.Result.Contents[0]
+	if content.URI != uri || content.MIMEType != jsonMIMEType {
+		t.Errorf("content = %s (%s), want %s (%s)", content.URI, content.MIMEType, uri, jsonMIMEType)
+	}
+	return content.Text
+}
+
+func TestMCPServer_Resources(t *testing.T) {
+	s := newHTTPTestServer(t)
+
+	t.Run("models", func(t *testing.T) {
+		var catalog models.Catalog
+		if err := json.Unmarshal([]byte(readResource(t, s, ModelsResourceURI)), &catalog); err != nil {
+			t.Fatal(err)
+		}
+		if len(catalog.Models) != len(models.All()) {
+			t.Fatalf("got %d models, want %d", len(catalog.Models), len(models.All()))
+		}
+		deep, ok := models.Lookup(DefaultResearchModel)
+		if !ok || !deep.SupportsReasoningEffort {
+			t.Errorf("%s should support reasoning_effort: %+v", DefaultResearchModel, deep)
+		}
+	})
+
+	t.Run("config options", func(t *testing.T) {
+		var options []config.OptionMetadata
+		if err := json.Unmarshal([]byte(readResource(t, s, ConfigOptionsResourceURI)), &options); err != nil {
+			t.Fatal(err)
+		}
+		if want := config.NewMetadataRegistry().Count(); len(options) != want {
+			t.Errorf("got %d options, want %d", len(options), want)
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		var stats ServerStats
+		if err := json.Unmarshal([]byte(readResource(t, s, StatsResourceURI)), &stats); err != nil {
+			t.Fatal(err)
+		}
+		if stats.RateLimit.Enabled {
+			t.Errorf("rate limit = %+v, want disabled when not configured", stats.RateLimit)
+		}
+
+		s.handler.limiter = ratelimit.New(60, 2)
+		if err := s.handler.limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(readResource(t, s, StatsResourceURI)), &stats); err != nil {
+			t.Fatal(err)
+		}
+		got := stats.RateLimit
+		if !got.Enabled || got.RequestsPerMinute != 60 || got.Burst != 2 || got.Allowed != 1 {
+			t.Errorf("rate limit = %+v, want 60/min, burst 2, 1 allowed", got)
+		}
+	})
+}
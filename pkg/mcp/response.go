@@ -2,10 +2,16 @@ package mcp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
+	"unicode/utf8"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/warnings"
 )
 
 // ResponseFormatter formats Perplexity API responses for MCP.
@@ -17,17 +23,22 @@ func NewResponseFormatter() *ResponseFormatter {
 }
 
 // Format converts a Perplexity response to an MCP tool result.
-func (f *ResponseFormatter) Format(response *perplexity.CompletionResponse) (*mcp.CallToolResult, error) {
+// elapsed is the request duration reported as elapsed_ms, and warns the
+// non-fatal issues of the call, reported as warnings.
+func (f *ResponseFormatter) Format(
+	response *perplexity.CompletionResponse, elapsed time.Duration, warns ...warnings.Warning,
+) (*mcp.CallToolResult, error) {
 	if response == nil {
 		return mcp.NewToolResultError("No response received"), nil
 	}
 
-	if len(response.Choices) == 0 {
-		return mcp.NewToolResultError("Response contains no choices"), nil
+	if _, err := output.FirstContent(response); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	// Build response object
-	result := f.buildResponse(response)
+	result := f.buildResponse(response, elapsed)
+	result.Warnings = append(result.Warnings, warns...)
 
 	// Convert to JSON
 	jsonData, err := json.Marshal(result)
@@ -38,32 +49,114 @@ func (f *ResponseFormatter) Format(response *perplexity.CompletionResponse) (*mc
 	return mcp.NewToolResultText(string(jsonData)), nil
 }
 
-// buildResponse creates the response map with all available data.
-func (f *ResponseFormatter) buildResponse(response *perplexity.CompletionResponse) map[string]any {
+// buildResponse creates the tool payload, the same stable document that
+// `pplx query --json` prints.
+func (f *ResponseFormatter) buildResponse(
+	response *perplexity.CompletionResponse, elapsed time.Duration,
+) *output.Result {
+	return output.FromResponse(response, elapsed)
+}
+
+// FormatJobStarted returns the research_start result for a newly created job.
+func (f *ResponseFormatter) FormatJobStarted(id string, model string) (*mcp.CallToolResult, error) {
+	return f.marshal(map[string]any{
+		"job_id": id,
+		"state":  JobPending,
+		"model":  model,
+	})
+}
+
+// FormatJobStatus converts a research job snapshot to an MCP tool result.
+// Finished jobs include the same payload as the query tool under "result".
+func (f *ResponseFormatter) FormatJobStatus(status JobStatus) (*mcp.CallToolResult, error) {
 	result := map[string]any{
-		"content": response.Choices[0].Message.Content,
-		"model":   response.Model,
-		"usage":   response.Usage,
+		"job_id":          status.ID,
+		"state":           status.State,
+		"model":           status.Model,
+		"elapsed_seconds": status.Elapsed.Seconds(),
 	}
 
-	// Add search results if available
-	if response.SearchResults != nil && len(*response.SearchResults) > 0 {
-		result["search_results"] = *response.SearchResults
+	switch status.State {
+	case JobDone:
+		if _, err := output.FirstContent(status.Response); err != nil {
+			result["state"] = JobError
+			result["error"] = err.Error()
+			break
+		}
+		result["result"] = f.buildResponse(status.Response, status.Elapsed)
+	case JobError:
+		if status.Err != nil {
+			result["error"] = status.Err.Error()
+			if details := budgetDetails(status.Err); details != nil {
+				result["budget"] = details
+			}
+			if details := stallDetails(status.Err); details != nil {
+				result["stream"] = details
+			}
+		}
+	case JobPending, JobRunning:
 	}
 
-	// Add images if available
-	if response.Images != nil && len(*response.Images) > 0 {
-		result["images"] = *response.Images
-	}
+	return f.marshal(result)
+}
 
-	// Add related questions if available
-	if response.RelatedQuestions != nil && len(*response.RelatedQuestions) > 0 {
-		result["related_questions"] = *response.RelatedQuestions
+// marshal encodes v as the JSON text of a tool result.
+func (f *ResponseFormatter) marshal(v any) (*mcp.CallToolResult, error) {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to format response: %v", err)), nil
 	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
 
+// toolErrorResult returns the error result of a failed tool call. A refused
+// budget also carries its figures as structured content, so that clients can
+// tell it from other failures and show what was spent, and a stalled stream
+// the answer received before it stalled.
+func toolErrorResult(err error) *mcp.CallToolResult {
+	result := mcp.NewToolResultError(err.Error())
+	if details := budgetDetails(err); details != nil {
+		result.StructuredContent = details
+	}
+	if details := stallDetails(err); details != nil {
+		result.StructuredContent = details
+		if partial, _ := details["partial_content"].(string); partial != "" {
+			result.Content = append(result.Content, mcp.NewTextContent(partial))
+		}
+	}
 	return result
 }
 
+// stallDetails describes a *StreamError in err aborted for going idle, or
+// returns nil when there is none.
+func stallDetails(err error) map[string]any {
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) || streamErr.Idle <= 0 {
+		return nil
+	}
+	return map[string]any{
+		"error":           "stream_stalled",
+		"idle_seconds":    streamErr.Idle.Seconds(),
+		"received_chars":  utf8.RuneCountInString(streamErr.Partial),
+		"partial_content": streamErr.Partial,
+	}
+}
+
+// budgetDetails describes a *clerrors.BudgetExceededError in err, or returns
+// nil when there is none.
+func budgetDetails(err error) map[string]any {
+	var budgetErr *clerrors.BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		return nil
+	}
+	return map[string]any{
+		"error":           "budget_exceeded",
+		"budget_usd":      budgetErr.Budget,
+		"spent_today_usd": budgetErr.Spent,
+		"projected_usd":   budgetErr.Projected,
+	}
+}
+
 // FormatError creates an MCP error result from a Go error.
 func FormatError(err error) *mcp.CallToolResult {
 	return mcp.NewToolResultError(fmt.Sprintf("Request failed: %v", err))
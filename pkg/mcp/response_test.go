@@ -1,10 +1,15 @@
 package mcp
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/warnings"
 )
 
 func TestResponseFormatter_Format(t *testing.T) {
@@ -26,7 +31,7 @@ func TestResponseFormatter_Format(t *testing.T) {
 			RelatedQuestions: &relatedQuestions,
 		}
 
-		result, err := formatter.Format(response)
+		result, err := formatter.Format(response, 0)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -36,6 +41,28 @@ func TestResponseFormatter_Format(t *testing.T) {
 		}
 	})
 
+	t.Run("reports warnings", func(t *testing.T) {
+		response := &perplexity.CompletionResponse{
+			Choices: []perplexity.Choice{{Message: perplexity.Message{Content: "answer"}}},
+		}
+		warn := warnings.Warning{Code: warnings.CodeImageFormat, Message: `image format "tiff" may not be supported`}
+
+		result, err := formatter.Format(response, 0, warn)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var payload struct {
+			Warnings []warnings.Warning `json:"warnings"`
+		}
+		text, _ := result.Content[0].(mcp.TextContent)
+		if err := json.Unmarshal([]byte(text.Text), &payload); err != nil {
+			t.Fatalf("invalid JSON payload: %v", err)
+		}
+		if len(payload.Warnings) != 1 || payload.Warnings[0] != warn {
+			t.Errorf("warnings = %+v, want [%+v]", payload.Warnings, warn)
+		}
+	})
+
 	t.Run("formats minimal response", func(t *testing.T) {
 		response := &perplexity.CompletionResponse{
 			Choices: []perplexity.Choice{
@@ -45,7 +72,7 @@ func TestResponseFormatter_Format(t *testing.T) {
 			Usage: perplexity.Usage{TotalTokens: 50},
 		}
 
-		result, err := formatter.Format(response)
+		result, err := formatter.Format(response, 0)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -65,7 +92,7 @@ func TestResponseFormatter_Format(t *testing.T) {
 			SearchResults: &searchResults,
 		}
 
-		result, err := formatter.Format(response)
+		result, err := formatter.Format(response, 0)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -76,7 +103,7 @@ func TestResponseFormatter_Format(t *testing.T) {
 	})
 
 	t.Run("handles nil response", func(t *testing.T) {
-		result, err := formatter.Format(nil)
+		result, err := formatter.Format(nil, 0)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -92,7 +119,7 @@ func TestResponseFormatter_Format(t *testing.T) {
 			Model:   "sonar",
 		}
 
-		result, err := formatter.Format(response)
+		result, err := formatter.Format(response, 0)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -101,17 +128,34 @@ func TestResponseFormatter_Format(t *testing.T) {
 			t.Error("Expected error result for empty choices")
 		}
 	})
+
+	t.Run("handles a blank answer", func(t *testing.T) {
+		response := &perplexity.CompletionResponse{
+			Choices: []perplexity.Choice{{Message: perplexity.Message{Content: " \n"}}},
+			Model:   "sonar",
+		}
+
+		result, err := formatter.Format(response, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !result.IsError {
+			t.Error("Expected error result for a blank answer")
+		}
+	})
 }
 
 func TestResponseFormatter_BuildResponse(t *testing.T) {
 	formatter := NewResponseFormatter()
 
 	t.Run("builds response with all fields", func(t *testing.T) {
-		searchResults := []perplexity.SearchResult{{}}
-		images := []perplexity.Image{{}}
+		searchResults := []perplexity.SearchResult{{URL: "https://example.com"}}
+		images := []perplexity.Image{{ImageURL: "https://example.com/a.png"}}
 		relatedQuestions := []string{"Question 1"}
 
 		response := &perplexity.CompletionResponse{
+			ID: "req-1",
 			Choices: []perplexity.Choice{
 				{Message: perplexity.Message{Content: "content"}},
 			},
@@ -122,45 +166,33 @@ func TestResponseFormatter_BuildResponse(t *testing.T) {
 			RelatedQuestions: &relatedQuestions,
 		}
 
-		result := formatter.buildResponse(response)
+		result := formatter.buildResponse(response, 1500*time.Millisecond)
 
-		if result["content"] != "content" {
-			t.Errorf("Expected content %q, got %v", "content", result["content"])
+		if result.Content != "content" {
+			t.Errorf("Expected content %q, got %q", "content", result.Content)
 		}
-		if result["model"] != "sonar" {
-			t.Errorf("Expected model %q, got %v", "sonar", result["model"])
+		if result.Model != "sonar" {
+			t.Errorf("Expected model %q, got %q", "sonar", result.Model)
 		}
-		if result["usage"] == nil {
-			t.Error("Expected usage to be present")
+		if result.Usage.TotalTokens != 100 {
+			t.Errorf("Expected total tokens 100, got %d", result.Usage.TotalTokens)
 		}
-		if result["search_results"] == nil {
-			t.Error("Expected search_results to be present")
+		if len(result.SearchResults) != 1 || len(result.Citations) != 1 {
+			t.Error("Expected search_results and citations to be present")
 		}
-		if result["images"] == nil {
+		if len(result.Images) != 1 {
 			t.Error("Expected images to be present")
 		}
-		if result["related_questions"] == nil {
+		if len(result.RelatedQuestions) != 1 {
 			t.Error("Expected related_questions to be present")
 		}
-	})
-
-	t.Run("builds minimal response", func(t *testing.T) {
-		response := &perplexity.CompletionResponse{
-			Choices: []perplexity.Choice{
-				{Message: perplexity.Message{Content: "content"}},
-			},
-			Model: "sonar",
-			Usage: perplexity.Usage{TotalTokens: 50},
-		}
-
-		result := formatter.buildResponse(response)
-
-		if len(result) != 3 {
-			t.Errorf("Expected 3 fields (content, model, usage), got %d", len(result))
+		if result.ElapsedMS != 1500 || result.RequestID != "req-1" {
+			t.Errorf("Expected elapsed_ms 1500 and request_id req-1, got %d and %q",
+				result.ElapsedMS, result.RequestID)
 		}
 	})
 
-	t.Run("includes empty slices as nil", func(t *testing.T) {
+	t.Run("emits empty lists for missing fields", func(t *testing.T) {
 		emptySearchResults := []perplexity.SearchResult{}
 		response := &perplexity.CompletionResponse{
 			Choices: []perplexity.Choice{
@@ -170,10 +202,20 @@ func TestResponseFormatter_BuildResponse(t *testing.T) {
 			SearchResults: &emptySearchResults,
 		}
 
-		result := formatter.buildResponse(response)
+		data, err := json.Marshal(formatter.buildResponse(response, 0))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
 
-		if result["search_results"] != nil {
-			t.Error("Expected search_results to be nil for empty slice")
+		var payload map[string]any
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		for _, key := range []string{"citations", "search_results", "images", "related_questions", "search_queries"} {
+			list, ok := payload[key].([]any)
+			if !ok || len(list) != 0 {
+				t.Errorf("Expected %s to be [], got %v", key, payload[key])
+			}
 		}
 	})
 }
@@ -188,3 +230,75 @@ func TestFormatError(t *testing.T) {
 		}
 	})
 }
+
+func TestToolErrorResult(t *testing.T) {
+	plain := toolErrorResult(errors.New("boom"))
+	if !plain.IsError || plain.StructuredContent != nil {
+		t.Errorf("plain error result = %+v, want an error without structured content", plain)
+	}
+
+	result := toolErrorResult(clerrors.NewBudgetExceededError(1, 0.99, 0.05))
+	if !result.IsError {
+		t.Error("budget result is not an error")
+	}
+	details, ok := result.StructuredContent.(map[string]any)
+	if !ok || details["error"] != "budget_exceeded" || details["budget_usd"] != 1.0 ||
+		details["spent_today_usd"] != 0.99 || details["projected_usd"] != 0.05 {
+		t.Errorf("structured content = %#v", result.StructuredContent)
+	}
+
+	stalled := toolErrorResult(newStallError(time.Minute, "Partial answer"))
+	details, ok = stalled.StructuredContent.(map[string]any)
+	if !ok || details["error"] != "stream_stalled" || details["idle_seconds"] != 60.0 ||
+		details["received_chars"] != 14 || details["partial_content"] != "Partial answer" {
+		t.Errorf("structured content = %#v", stalled.StructuredContent)
+	}
+	if len(stalled.Content) != 2 {
+		t.Fatalf("stalled result has %d contents, want the error and the partial answer", len(stalled.Content))
+	}
+	if text, ok := stalled.Content[1].(mcp.TextContent); !ok || text.Text != "Partial answer" {
+		t.Errorf("second content = %#v, want the partial answer", stalled.Content[1])
+	}
+}
+
+func TestResponseFormatter_FormatJobStatus(t *testing.T) {
+	formatter := NewResponseFormatter()
+
+	tests := []struct {
+		name      string
+		status    JobStatus
+		wantState JobState
+		wantKey   string
+	}{
+		{"running", JobStatus{ID: "a", State: JobRunning}, JobRunning, ""},
+		{"done", JobStatus{ID: "b", State: JobDone, Response: &perplexity.CompletionResponse{
+			Choices: []perplexity.Choice{{Message: perplexity.Message{Content: "ok"}}},
+		}}, JobDone, "result"},
+		{"done without choices", JobStatus{ID: "c", State: JobDone,
+			Response: &perplexity.CompletionResponse{}}, JobError, "error"},
+		{"error", JobStatus{ID: "d", State: JobError, Err: errors.New("boom")}, JobError, "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := formatter.FormatJobStatus(tt.status)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var payload map[string]any
+			text := result.Content[0].(mcp.TextContent).Text
+			if err := json.Unmarshal([]byte(text), &payload); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if payload["state"] != string(tt.wantState) {
+				t.Errorf("state = %v, want %s", payload["state"], tt.wantState)
+			}
+			if payload["job_id"] != tt.status.ID {
+				t.Errorf("job_id = %v, want %s", payload["job_id"], tt.status.ID)
+			}
+			if tt.wantKey != "" && payload[tt.wantKey] == nil {
+				t.Errorf("payload missing %q: %v", tt.wantKey, payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package mcp
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/pplx/pkg/output"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// searchMaxTokens caps the max_tokens of a search call: only the search
+// results are returned, so the generated answer is kept as short as possible.
+const searchMaxTokens = 16
+
+// searchArguments are the arguments of the search tool passed on to the
+// parameter extractor; any other argument is ignored.
+var searchArguments = []string{
+	"model", "timeout",
+	"search_domains", "exclude_domains", "search_recency",
+	"location_lat", "location_lon", "location_country",
+	"search_mode", "search_context_size",
+	"search_after_date", "search_before_date", "last_updated_after", "last_updated_before",
+	"api_key", "key_id",
+}
+
+// SearchToolResult is the result of the search tool. It holds the search
+// results of the call and none of the generated content.
+type SearchToolResult struct {
+	SearchResults []output.SearchResult `json:"search_results"`
+	Warnings      []warnings.Warning    `json:"warnings"`
+}
+
+// BuildSearchTool creates the MCP tool definition that runs a web search and
+// returns only its results. It accepts the query text and the search
+// filtering parameters of the query tool.
+func BuildSearchTool() *mcp.Tool {
+	opts := []mcp.ToolOption{
+		mcp.WithDescription("Search the web with Perplexity and return only the search results " +
+			"(url, title, and dates when known) as JSON, without a generated answer. Accepts the " +
+			"search filters of the query tool: domains, recency, mode, dates, and location."),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The text to search for"),
+		),
+		mcp.WithString("model",
+			mcp.Description("Model to use (default: the server's default model)"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("HTTP timeout in seconds"),
+			mcp.Min(1),
+		),
+	}
+	opts = append(opts, searchParameterOptions()...)
+	opts = append(opts, credentialOptions()...)
+	tool := mcp.NewTool("search", opts...)
+	return &tool
+}
+
+// AddSearchTool registers the search tool.
+func (s *MCPServer) AddSearchTool() error {
+	s.server.AddTool(*BuildSearchTool(), s.handleSearch)
+	return nil
+}
+
+// handleSearch runs a minimal completion with the search filters of the
+// arguments and returns its search results.
+func (s *MCPServer) handleSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	start := time.Now()
+	name := request.Params.Name
+
+	query, _ := args["query"].(string)
+	if query == "" {
+		err := NewParameterError("query", args["query"], "must be a non-empty string")
+		logToolCall(name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	searchArgs := map[string]any{"user_prompt": query}
+	for _, key := range searchArguments {
+		if value, ok := args[key]; ok {
+			searchArgs[key] = value
+		}
+	}
+
+	params, err := s.extract(searchArgs)
+	if err != nil {
+		logToolCall(name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	// Drop whatever the server defaults add beyond the search itself.
+	params.MaxTokens = min(params.MaxTokens, searchMaxTokens)
+	if params.MaxTokens <= 0 {
+		params.MaxTokens = searchMaxTokens
+	}
+	params.ReturnRelated = false
+	params.ReturnImages = false
+	params.Stream = false
+	params.ImageDomains = nil
+	params.ImageFormats = nil
+	params.ResponseFormatJSONSchema = ""
+	params.ResponseFormatRegex = ""
+	params.ReasoningEffort = ""
+
+	warns := warnings.New()
+	response, err := s.handler.Handle(warnings.NewContext(ctx, warns), s.apiKey, *params)
+	logToolCall(name, params.Model, start, response, err)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+
+	return s.formatter.marshal(SearchToolResult{
+		SearchResults: output.FromResponse(response, time.Since(start)).SearchResults,
+		Warnings:      warns.All(),
+	})
+}
@@ -0,0 +1,84 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestMCPServer_Search(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","model":"sonar",` +
+			`"choices":[{"message":{"role":"assistant","content":"A generated answer"}}],` +
+			`"search_results":[{"title":"The Go Programming Language","url":"https://go.dev","date":"2024-01-02"}]}`))
+	}))
+	defer srv.Close()
+
+	s, err := NewServer(ServerConfig{APIKey: "test-key", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	t.Cleanup(s.jobs.Shutdown)
+
+	call := func(args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		var req mcp.CallToolRequest
+		req.Params.Name = "search"
+		req.Params.Arguments = args
+		result, err := s.handleSearch(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result
+	}
+
+	result := call(map[string]any{
+		"query":          "golang",
+		"search_recency": "week",
+		// Not a search tool parameter: ignored.
+		"return_related": true,
+	})
+	if result.IsError {
+		t.Fatalf("search returned an error result: %v", result.Content)
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &payload); err != nil {
+		t.Fatalf("invalid JSON %q: %v", text, err)
+	}
+	if _, ok := payload["content"]; ok {
+		t.Errorf("payload = %s, want no content field", text)
+	}
+	var results []map[string]string
+	if err := json.Unmarshal(payload["search_results"], &results); err != nil || len(results) != 1 {
+		t.Fatalf("search_results = %s, want one result (%v)", payload["search_results"], err)
+	}
+	if results[0]["url"] != "https://go.dev" || results[0]["date"] != "2024-01-02" {
+		t.Errorf("search result = %v, want the go.dev result with its date", results[0])
+	}
+
+	if maxTokens, _ := body["max_tokens"].(float64); maxTokens <= 0 || maxTokens > searchMaxTokens {
+		t.Errorf("max_tokens = %v, want at most %d", body["max_tokens"], searchMaxTokens)
+	}
+	if related, _ := body["return_related_questions"].(bool); related {
+		t.Error("return_related_questions = true, want false")
+	}
+	if body["search_recency_filter"] != "week" {
+		t.Errorf("search_recency_filter = %v, want week", body["search_recency_filter"])
+	}
+	if messages, _ := body["messages"].([]any); len(messages) == 0 {
+		t.Errorf("messages = %v, want the query as the user message", body["messages"])
+	}
+
+	if result := call(map[string]any{"search_recency": "week"}); !result.IsError {
+		t.Error("a missing query should return an error result")
+	}
+}
@@ -3,9 +3,22 @@ package mcp
 import (
 	"context"
 	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/hooks"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/ratelimit"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/security"
+	"github.com/sgaunet/pplx/pkg/warnings"
 )
 
 // MCPServer wraps the MCP server with Perplexity query functionality.
@@ -15,8 +28,26 @@ type MCPServer struct {
 	handler   *QueryHandler
 	extractor *ParameterExtractor
 	formatter *ResponseFormatter
-	apiKey    string
-	version   string
+	jobs      *JobManager
+	sessions  *SessionStore
+	// defaults holds the config applied to arguments a tool call omits.
+	// It is swapped atomically when the config file is reloaded.
+	defaults atomic.Pointer[config.ConfigData]
+	// profileMu guards base and profile. base is the config SetDefaults was
+	// given; profile is the profile set_profile applies over it for the
+	// session, empty for none.
+	profileMu sync.Mutex
+	base      *config.ConfigData
+	profile   string
+	// promptsEnabled is set once AddPrompts has run; SetDefaults then
+	// reloads the prompts of the config.
+	promptsEnabled atomic.Bool
+	apiKey         string
+	version        string
+	// now is the clock of the ping tool; startedAt is read from it when
+	// the server is created.
+	now       func() time.Time
+	startedAt time.Time
 }
 
 // ServerConfig contains configuration for the MCP server.
@@ -24,6 +55,50 @@ type ServerConfig struct {
 	APIKey  string
 	Version string
 	Name    string
+	// Keys are named API keys that tool calls select with the key_id
+	// argument (api.keys). Every key must be non-empty.
+	Keys map[string]string
+	// RetryPolicy controls retries on 429/5xx responses. Nil uses retry.DefaultPolicy.
+	RetryPolicy *retry.Policy
+	// Timeouts sets the connect and response-header limits of API calls.
+	// The total timeout is the timeout tool argument.
+	Timeouts retry.Timeouts
+	// Endpoint is the chat completions URL of the API. Empty uses the
+	// Perplexity API.
+	Endpoint string
+	// JobTTL is how long finished research jobs are kept. Zero uses DefaultJobTTL.
+	JobTTL time.Duration
+	// MaxConcurrentJobs caps pending and running research jobs. Zero uses DefaultMaxConcurrentJobs.
+	MaxConcurrentJobs int
+	// SessionTTL is how long an idle chat session is kept. Zero uses DefaultSessionTTL.
+	SessionTTL time.Duration
+	// MaxSessions caps the chat sessions kept at once. Zero uses DefaultMaxSessions.
+	MaxSessions int
+	// Defaults supplies values for arguments a tool call omits, and the model
+	// aliases. Nil uses the library defaults.
+	Defaults *config.ConfigData
+	// StrictModel rejects tool calls whose model is not in the model
+	// registry; by default they are logged as a warning and sent as is.
+	StrictModel bool
+	// Hooks run around every API request of the query and research tools.
+	Hooks []hooks.Hook
+	// RateLimiter is shared by every API request of the query and research
+	// tools. Nil disables rate limiting.
+	RateLimiter *ratelimit.Limiter
+	// DailyBudgetUSD refuses requests whose projected cost would take the
+	// day's spending in the usage log over it (api.daily_budget_usd). Zero
+	// is unlimited.
+	DailyBudgetUSD float64
+	// PerRequestMaxTokens caps the max_tokens of every request
+	// (api.per_request_max_tokens). Zero is no cap.
+	PerRequestMaxTokens int
+	// StreamIdleTimeout aborts a streaming request that receives no data
+	// for that long (api.stream_idle_timeout). Zero uses
+	// DefaultStreamIdleTimeout.
+	StreamIdleTimeout time.Duration
+	// Clock returns the current time for the uptime the ping tool reports.
+	// Nil uses time.Now.
+	Clock func() time.Time
 }
 
 // NewServer creates a new MCP server instance.
@@ -31,6 +106,11 @@ func NewServer(config ServerConfig) (*MCPServer, error) {
 	if config.APIKey == "" {
 		return nil, NewParameterError("api_key", nil, "API key is required")
 	}
+	for id, key := range config.Keys {
+		if strings.TrimSpace(key) == "" {
+			return nil, NewParameterError("api.keys", id, "key is empty after environment variable expansion")
+		}
+	}
 
 	if config.Name == "" {
 		config.Name = "Perplexity MCP Server"
@@ -46,16 +126,110 @@ func NewServer(config ServerConfig) (*MCPServer, error) {
 		config.Version,
 		server.WithToolCapabilities(true),
 		server.WithResourceCapabilities(false, false),
+		server.WithPromptCapabilities(true),
 	)
 
-	return &MCPServer{
+	handler := NewQueryHandler()
+	if config.RetryPolicy != nil {
+		handler.retryPolicy = *config.RetryPolicy
+	}
+	handler.timeouts = config.Timeouts
+	handler.endpoint = config.Endpoint
+	handler.strictModel = config.StrictModel
+	handler.limiter = config.RateLimiter
+	handler.keys = maps.Clone(config.Keys)
+	handler.dailyBudget = config.DailyBudgetUSD
+	handler.maxTokens = config.PerRequestMaxTokens
+	handler.streamIdleTimeout = config.StreamIdleTimeout
+	for _, hook := range config.Hooks {
+		handler.Use(hook)
+	}
+
+	run := func(ctx context.Context, params QueryParams) (*perplexity.CompletionResponse, error) {
+		return handler.Handle(ctx, config.APIKey, params)
+	}
+
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+
+	mcpServer := &MCPServer{
 		server:    s,
-		handler:   NewQueryHandler(),
+		handler:   handler,
 		extractor: NewParameterExtractor(),
 		formatter: NewResponseFormatter(),
+		jobs:      NewJobManager(run, config.JobTTL, config.MaxConcurrentJobs),
+		sessions:  NewSessionStore(config.SessionTTL, config.MaxSessions),
 		apiKey:    config.APIKey,
 		version:   config.Version,
-	}, nil
+		now:       config.Clock,
+		startedAt: config.Clock(),
+	}
+	mcpServer.SetDefaults(config.Defaults)
+	return mcpServer, nil
+}
+
+// SetDefaults replaces the config used for omitted tool arguments. It is safe
+// to call while requests are being served; each request uses the config that
+// was current when its arguments were extracted. Nil restores library defaults.
+// The profile selected with set_profile, if any, is applied over cfg; when cfg
+// no longer has it, cfg is used as is and a config warning is recorded.
+// Once prompts are registered, those of the prompts section are reloaded too.
+func (s *MCPServer) SetDefaults(cfg *config.ConfigData) {
+	s.profileMu.Lock()
+	s.base = cfg
+	merged, err := mergeProfile(cfg, s.profile)
+	if err != nil {
+		warnings.Add(warnings.CodeConfig,
+			fmt.Sprintf("failed to apply profile %s, using base config: %v", s.profile, err),
+			"call set_profile to select another profile")
+		merged = cfg
+	}
+	s.defaults.Store(merged)
+	s.profileMu.Unlock()
+	if s.promptsEnabled.Load() {
+		s.syncPrompts()
+	}
+}
+
+// extract converts tool arguments to QueryParams using the current defaults.
+func (s *MCPServer) extract(args map[string]any) (*QueryParams, error) {
+	return s.extractor.ExtractWithDefaults(args, s.defaults.Load())
+}
+
+// RegisterTools registers every pplx tool (query, search, research_start,
+// research_status, chat_create, chat_send, chat_delete, export_request,
+// set_profile, ping and server_info), the pplx:// resources, and the prompts. Both the stdio and HTTP transports
+// serve this set.
+func (s *MCPServer) RegisterTools() error {
+	if err := s.AddQueryTool(); err != nil {
+		return fmt.Errorf("failed to add query tool: %w", err)
+	}
+	if err := s.AddSearchTool(); err != nil {
+		return fmt.Errorf("failed to add search tool: %w", err)
+	}
+	if err := s.AddResearchTools(); err != nil {
+		return fmt.Errorf("failed to add research tools: %w", err)
+	}
+	if err := s.AddChatTools(); err != nil {
+		return fmt.Errorf("failed to add chat tools: %w", err)
+	}
+	if err := s.AddExportTool(); err != nil {
+		return fmt.Errorf("failed to add export tool: %w", err)
+	}
+	if err := s.AddProfileTool(); err != nil {
+		return fmt.Errorf("failed to add profile tool: %w", err)
+	}
+	if err := s.AddHealthTools(); err != nil {
+		return fmt.Errorf("failed to add health tools: %w", err)
+	}
+	if err := s.AddResources(); err != nil {
+		return fmt.Errorf("failed to add resources: %w", err)
+	}
+	if err := s.AddPrompts(); err != nil {
+		return fmt.Errorf("failed to add prompts: %w", err)
+	}
+	return nil
 }
 
 // AddQueryTool registers the query tool with the server.
@@ -64,28 +238,123 @@ func (s *MCPServer) AddQueryTool() error {
 
 	s.server.AddTool(*tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
+		start := time.Now()
 
 		// Extract parameters
-		params, err := s.extractor.Extract(args)
+		params, err := s.extract(args)
 		if err != nil {
+			logToolCall(tool.Name, "", start, nil, err)
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		// Handle query
-		response, err := s.handler.Handle(ctx, s.apiKey, *params)
+		// Handle query, collecting its warnings for the result
+		warns := warnings.New()
+		response, err := s.handler.Handle(warnings.NewContext(ctx, warns), s.apiKey, *params)
+		logToolCall(tool.Name, params.Model, start, response, err)
 		if err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
+			return toolErrorResult(err), nil
 		}
 
 		// Format response
-		return s.formatter.Format(response)
+		return s.formatter.Format(response, time.Since(start), warns.All()...)
 	})
 
 	return nil
 }
 
+// AddResearchTools registers the research_start and research_status tools,
+// which run queries as background jobs so slow deep-research calls do not
+// hit the MCP client's request timeout.
+func (s *MCPServer) AddResearchTools() error {
+	s.server.AddTool(*BuildResearchStartTool(), s.handleResearchStart)
+	s.server.AddTool(*BuildResearchStatusTool(), s.handleResearchStatus)
+	return nil
+}
+
+// handleResearchStart extracts query parameters and starts a background job.
+func (s *MCPServer) handleResearchStart(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := withResearchDefaults(request.GetArguments())
+	start := time.Now()
+
+	params, err := s.extract(args)
+	if err != nil {
+		logToolCall(request.Params.Name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Validate up front so bad parameters fail the call instead of the job.
+	if err := s.handler.validateParameters(*params, nil); err != nil {
+		logToolCall(request.Params.Name, params.Model, start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	id, err := s.jobs.Start(*params)
+	logToolCall(request.Params.Name, params.Model, start, nil, err)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return s.formatter.FormatJobStarted(id, params.Model)
+}
+
+// handleResearchStatus reports the state of a research job.
+func (s *MCPServer) handleResearchStatus(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	start := time.Now()
+	id, ok := request.GetArguments()["job_id"].(string)
+	if !ok || id == "" {
+		err := NewParameterError("job_id", request.GetArguments()["job_id"], "must be a non-empty string")
+		logToolCall(request.Params.Name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	status, err := s.jobs.Status(id)
+	if err != nil {
+		logToolCall(request.Params.Name, "", start, nil, err)
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	// Token usage is reported once the job is done; a failed job is an
+	// error outcome even though the status call itself succeeded.
+	logToolCall(request.Params.Name, status.Model, start, status.Response, status.Err)
+
+	return s.formatter.FormatJobStatus(status)
+}
+
+// logToolCall writes one structured record for a tool call: the tool, model,
+// duration, token usage when a response is available, and the outcome.
+func logToolCall(tool, model string, start time.Time, response *perplexity.CompletionResponse, err error) {
+	attrs := []any{"tool", tool, "model", model, "duration", time.Since(start)}
+	if response != nil {
+		attrs = append(attrs,
+			"prompt_tokens", response.Usage.PromptTokens,
+			"completion_tokens", response.Usage.CompletionTokens,
+			"total_tokens", response.Usage.TotalTokens)
+	}
+	if err != nil {
+		logger.Warn("tool call", append(attrs, "outcome", "error", "error", security.SanitizeString(err.Error()))...)
+		return
+	}
+	logger.Info("tool call", append(attrs, "outcome", "ok")...)
+}
+
+// withResearchDefaults returns a copy of args with the research model and
+// timeout filled in when the caller did not provide them.
+func withResearchDefaults(args map[string]any) map[string]any {
+	out := make(map[string]any, len(args)+2) //nolint:mnd // model and timeout
+	for k, v := range args {
+		out[k] = v
+	}
+	if model, ok := out["model"].(string); !ok || model == "" {
+		out["model"] = DefaultResearchModel
+	}
+	if timeout, ok := out["timeout"]; !ok || timeout == nil {
+		out["timeout"] = DefaultResearchTimeout.Seconds()
+	}
+	return out
+}
+
 // Start begins serving stdio requests.
 func (s *MCPServer) Start() error {
+	defer s.jobs.Shutdown()
 	if err := server.ServeStdio(s.server); err != nil {
 		return fmt.Errorf("failed to serve stdio: %w", err)
 	}
@@ -1,8 +1,15 @@
 package mcp
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/config"
 )
 
 func TestNewServer(t *testing.T) {
@@ -97,6 +104,19 @@ func TestNewServer(t *testing.T) {
 		}
 	})
 
+	t.Run("rejects an empty api.keys entry", func(t *testing.T) {
+		config := ServerConfig{
+			APIKey: "test-key",
+			Keys:   map[string]string{"alice": "alice-key", "bob": ""},
+		}
+
+		_, err := NewServer(config)
+		var paramErr *ParameterError
+		if !errors.As(err, &paramErr) || paramErr.Value != "bob" {
+			t.Errorf("Expected a ParameterError naming bob, got %v", err)
+		}
+	})
+
 	t.Run("creates all components", func(t *testing.T) {
 		config := ServerConfig{
 			APIKey:  "test-key",
@@ -171,3 +191,133 @@ func TestMCPServer_AddQueryTool(t *testing.T) {
 		}
 	})
 }
+
+func TestMCPServer_ResearchTools(t *testing.T) {
+	server, err := NewServer(ServerConfig{APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := server.AddResearchTools(); err != nil {
+		t.Fatalf("Unexpected error adding research tools: %v", err)
+	}
+
+	var gotParams QueryParams
+	server.jobs = NewJobManager(func(_ context.Context, params QueryParams) (*perplexity.CompletionResponse, error) {
+		gotParams = params
+		return &perplexity.CompletionResponse{
+			Model:   params.Model,
+			Choices: []perplexity.Choice{{Message: perplexity.Message{Content: "findings"}}},
+		}, nil
+	}, time.Hour, 1)
+	defer server.jobs.Shutdown()
+
+	call := func(handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error),
+		args map[string]any,
+	) (map[string]any, bool) {
+		t.Helper()
+		var req mcp.CallToolRequest
+		req.Params.Arguments = args
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		text := result.Content[0].(mcp.TextContent).Text
+		var payload map[string]any
+		if !result.IsError {
+			if err := json.Unmarshal([]byte(text), &payload); err != nil {
+				t.Fatalf("invalid JSON %q: %v", text, err)
+			}
+		}
+		return payload, result.IsError
+	}
+
+	started, isErr := call(server.handleResearchStart, map[string]any{"user_prompt": "survey"})
+	if isErr {
+		t.Fatal("research_start returned an error result")
+	}
+	if started["model"] != DefaultResearchModel || started["state"] != string(JobPending) {
+		t.Errorf("research_start payload = %v", started)
+	}
+	id, _ := started["job_id"].(string)
+
+	server.jobs.wg.Wait()
+	if gotParams.Timeout != DefaultResearchTimeout {
+		t.Errorf("job timeout = %v, want %v", gotParams.Timeout, DefaultResearchTimeout)
+	}
+
+	status, isErr := call(server.handleResearchStatus, map[string]any{"job_id": id})
+	if isErr {
+		t.Fatal("research_status returned an error result")
+	}
+	if status["state"] != string(JobDone) {
+		t.Fatalf("state = %v, want done", status["state"])
+	}
+	result, _ := status["result"].(map[string]any)
+	if result["content"] != "findings" {
+		t.Errorf("result content = %v, want findings", result["content"])
+	}
+
+	if _, isErr := call(server.handleResearchStatus, map[string]any{"job_id": "missing"}); !isErr {
+		t.Error("research_status for unknown job should return an error result")
+	}
+	if _, isErr := call(server.handleResearchStart, map[string]any{
+		"user_prompt": "survey", "search_mode": "bogus",
+	}); !isErr {
+		t.Error("research_start with invalid search_mode should return an error result")
+	}
+}
+
+func TestMCPServer_SetDefaults(t *testing.T) {
+	initial := config.NewConfigData()
+	initial.Defaults.Model = "sonar-pro"
+
+	s, err := NewServer(ServerConfig{APIKey: "test-key", Defaults: initial})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	args := map[string]any{"user_prompt": "q"}
+
+	params, err := s.extract(args)
+	if err != nil {
+		t.Fatalf("extract() error: %v", err)
+	}
+	if params.Model != "sonar-pro" {
+		t.Errorf("Model = %q, want sonar-pro from initial defaults", params.Model)
+	}
+
+	reloaded := config.NewConfigData()
+	reloaded.Defaults.Model = "sonar-reasoning"
+	s.SetDefaults(reloaded)
+
+	params, err = s.extract(args)
+	if err != nil {
+		t.Fatalf("extract() error: %v", err)
+	}
+	if params.Model != "sonar-reasoning" {
+		t.Errorf("Model = %q, want sonar-reasoning after SetDefaults", params.Model)
+	}
+}
+
+func TestWithResearchDefaults_Timeout(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]any
+		want time.Duration
+	}{
+		{"omitted", map[string]any{"user_prompt": "q"}, DefaultResearchTimeout},
+		{"null", map[string]any{"user_prompt": "q", "timeout": nil}, DefaultResearchTimeout},
+		{"number", map[string]any{"user_prompt": "q", "timeout": float64(120)}, 2 * time.Minute},
+		{"numeric string", map[string]any{"user_prompt": "q", "timeout": "120"}, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := NewParameterExtractor().Extract(withResearchDefaults(tt.args))
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if params.Timeout != tt.want {
+				t.Errorf("Timeout = %v, want %v", params.Timeout, tt.want)
+			}
+		})
+	}
+}
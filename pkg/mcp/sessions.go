@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/chat"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Chat session defaults.
+const (
+	// DefaultSessionTTL is how long a chat session is kept without being used.
+	DefaultSessionTTL = 30 * time.Minute
+	// DefaultMaxSessions caps the number of chat sessions kept at once.
+	DefaultMaxSessions = 16
+)
+
+// chatSession is a conversation of the chat tools.
+type chatSession struct {
+	// mu serializes the calls on the session, so that concurrent chat_send
+	// calls add their exchanges one after the other.
+	mu   sync.Mutex
+	id   string
+	chat *chat.Chat
+	// keyID is recorded with the usage of the session's requests.
+	keyID string
+	// budget checks each request of the session against the daily budget.
+	budget *budgetHook
+
+	// lastUsed and inUse are guarded by SessionStore.mu.
+	lastUsed time.Time
+	inUse    int
+}
+
+// SessionStore keeps the conversations of the chat tools in memory, keyed by
+// session ID.
+//
+// Sessions idle for longer than the TTL are dropped; expiry is checked lazily
+// on every call, as for research jobs, so no janitor goroutine is needed. A
+// session in use never expires.
+type SessionStore struct {
+	mu          sync.Mutex
+	sessions    map[string]*chatSession
+	ttl         time.Duration
+	maxSessions int
+
+	// now returns the current time; replaced in tests.
+	now func() time.Time
+}
+
+// NewSessionStore creates an empty session store.
+// Non-positive ttl or maxSessions fall back to DefaultSessionTTL and DefaultMaxSessions.
+func NewSessionStore(ttl time.Duration, maxSessions int) *SessionStore {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	if maxSessions <= 0 {
+		maxSessions = DefaultMaxSessions
+	}
+	return &SessionStore{
+		sessions:    make(map[string]*chatSession),
+		ttl:         ttl,
+		maxSessions: maxSessions,
+		now:         time.Now,
+	}
+}
+
+// Len returns the number of sessions kept, expired ones excluded.
+func (s *SessionStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	return len(s.sessions)
+}
+
+// create adds session under a new ID, which it returns.
+// Returns clerrors.ErrTooManySessions when the store is full.
+func (s *SessionStore) create(session *chatSession) (string, error) {
+	id, err := newID("session")
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	if len(s.sessions) >= s.maxSessions {
+		return "", fmt.Errorf("%w: %d of %d in use, call chat_delete on a session you no longer need",
+			clerrors.ErrTooManySessions, len(s.sessions), s.maxSessions)
+	}
+	session.id = id
+	session.lastUsed = s.now()
+	s.sessions[id] = session
+	return id, nil
+}
+
+// use runs fn on the session with the given ID, after the calls on it that
+// are already running. Returns clerrors.ErrSessionNotFound for unknown or
+// expired sessions, or else the error of fn.
+func (s *SessionStore) use(id string, fn func(*chatSession) error) error {
+	s.mu.Lock()
+	s.pruneLocked()
+	session, ok := s.sessions[id]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", clerrors.ErrSessionNotFound, id)
+	}
+	session.inUse++
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		session.inUse--
+		session.lastUsed = s.now()
+		s.mu.Unlock()
+	}()
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return fn(session)
+}
+
+// remove drops the session with the given ID. A call still running on it
+// completes. Returns clerrors.ErrSessionNotFound for unknown or expired
+// sessions.
+func (s *SessionStore) remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	if _, ok := s.sessions[id]; !ok {
+		return fmt.Errorf("%w: %s", clerrors.ErrSessionNotFound, id)
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+// pruneLocked drops the sessions idle for longer than the TTL. Caller must
+// hold s.mu.
+func (s *SessionStore) pruneLocked() {
+	cutoff := s.now().Add(-s.ttl)
+	for id, session := range s.sessions {
+		if session.inUse == 0 && session.lastUsed.Before(cutoff) {
+			delete(s.sessions, id)
+		}
+	}
+}
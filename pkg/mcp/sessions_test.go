@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// fakeClock returns a clock starting at a fixed time and a function moving
+// it forward.
+func fakeClock() (now func() time.Time, advance func(time.Duration)) {
+	var mu sync.Mutex
+	t := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return t
+	}
+	advance = func(d time.Duration) {
+		mu.Lock()
+		t = t.Add(d)
+		mu.Unlock()
+	}
+	return now, advance
+}
+
+func TestSessionStore_Lifecycle(t *testing.T) {
+	s := NewSessionStore(time.Hour, 2)
+
+	id, err := s.create(&chatSession{})
+	if err != nil {
+		t.Fatalf("create unexpected error: %v", err)
+	}
+	if len(id) != 2*jobIDBytes {
+		t.Errorf("session ID %q has length %d, want %d", id, len(id), 2*jobIDBytes)
+	}
+	if _, err := s.create(&chatSession{}); err != nil {
+		t.Fatalf("create #2 unexpected error: %v", err)
+	}
+	if _, err := s.create(&chatSession{}); !errors.Is(err, clerrors.ErrTooManySessions) {
+		t.Fatalf("create #3 error = %v, want ErrTooManySessions", err)
+	}
+
+	called := false
+	if err := s.use(id, func(session *chatSession) error {
+		called = session.id == id
+		return nil
+	}); err != nil || !called {
+		t.Errorf("use(%s) = %v, called with the session: %v", id, err, called)
+	}
+	want := errors.New("send failed")
+	if err := s.use(id, func(*chatSession) error { return want }); !errors.Is(err, want) {
+		t.Errorf("use error = %v, want the error of fn", err)
+	}
+
+	if err := s.remove(id); err != nil {
+		t.Fatalf("remove unexpected error: %v", err)
+	}
+	if err := s.remove(id); !errors.Is(err, clerrors.ErrSessionNotFound) {
+		t.Errorf("second remove error = %v, want ErrSessionNotFound", err)
+	}
+	if err := s.use(id, func(*chatSession) error { return nil }); !errors.Is(err, clerrors.ErrSessionNotFound) {
+		t.Errorf("use after remove error = %v, want ErrSessionNotFound", err)
+	}
+	if _, err := s.create(&chatSession{}); err != nil {
+		t.Errorf("create after remove unexpected error: %v", err)
+	}
+}
+
+func TestSessionStore_Expiry(t *testing.T) {
+	s := NewSessionStore(time.Minute, 1)
+	now, advance := fakeClock()
+	s.now = now
+
+	id, err := s.create(&chatSession{})
+	if err != nil {
+		t.Fatalf("create unexpected error: %v", err)
+	}
+
+	// Each use restarts the idle time.
+	advance(45 * time.Second)
+	if err := s.use(id, func(*chatSession) error { return nil }); err != nil {
+		t.Fatalf("session expired before TTL: %v", err)
+	}
+	advance(45 * time.Second)
+	if s.Len() != 1 {
+		t.Fatal("session expired less than TTL after its last use")
+	}
+
+	// A session in use does not expire, however long the call takes.
+	if err := s.use(id, func(*chatSession) error {
+		advance(time.Hour)
+		if s.Len() != 1 {
+			t.Error("session expired while in use")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("use unexpected error: %v", err)
+	}
+
+	advance(2 * time.Minute)
+	if err := s.use(id, func(*chatSession) error { return nil }); !errors.Is(err, clerrors.ErrSessionNotFound) {
+		t.Errorf("use after TTL error = %v, want ErrSessionNotFound", err)
+	}
+	// The expired session no longer counts against the limit.
+	if _, err := s.create(&chatSession{}); err != nil {
+		t.Errorf("create after expiry unexpected error: %v", err)
+	}
+}
+
+func TestSessionStore_SerializesUse(t *testing.T) {
+	s := NewSessionStore(0, 0)
+	if s.ttl != DefaultSessionTTL || s.maxSessions != DefaultMaxSessions {
+		t.Errorf("defaults not applied: ttl=%v max=%d", s.ttl, s.maxSessions)
+	}
+	id, err := s.create(&chatSession{})
+	if err != nil {
+		t.Fatalf("create unexpected error: %v", err)
+	}
+
+	const calls = 8
+	var mu sync.Mutex
+	running, overlapped := 0, false
+	var wg sync.WaitGroup
+	for range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.use(id, func(*chatSession) error {
+				mu.Lock()
+				running++
+				overlapped = overlapped || running > 1
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if overlapped {
+		t.Error("calls on the same session ran at the same time")
+	}
+}
@@ -3,13 +3,62 @@ package mcp
 import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/validation"
+)
+
+// Bounds of the numeric query parameters, matching the config validator.
+const (
+	maxTemperatureParam = 2.0
+	maxPenaltyParam     = 2.0
+	maxTopKParam        = 100
+	maxLatitude         = 90.0
+	maxLongitude        = 180.0
 )
 
 // BuildQueryTool creates the MCP tool definition for Perplexity queries.
-//nolint:funlen // Function length appropriate for defining 30+ parameters
 func BuildQueryTool() *mcp.Tool {
-	tool := mcp.NewTool("query",
+	opts := append([]mcp.ToolOption{
 		mcp.WithDescription("Query Perplexity AI with extensive search and filtering options"),
+	}, queryParameterOptions("Model to use (default: "+perplexity.DefaultModel+")")...)
+	tool := mcp.NewTool("query", opts...)
+	return &tool
+}
+
+// BuildResearchStartTool creates the MCP tool definition that starts a
+// background research job. It accepts the same parameters as the query tool.
+func BuildResearchStartTool() *mcp.Tool {
+	opts := append([]mcp.ToolOption{
+		mcp.WithDescription("Start a long-running Perplexity research query in the background " +
+			"and return a job_id immediately. Poll research_status with the job_id until state is " +
+			"done or error. Use this instead of query for sonar-deep-research, which can take minutes."),
+	}, queryParameterOptions("Model to use (default: "+DefaultResearchModel+")")...)
+	tool := mcp.NewTool("research_start", opts...)
+	return &tool
+}
+
+// BuildResearchStatusTool creates the MCP tool definition for polling a research job.
+func BuildResearchStatusTool() *mcp.Tool {
+	tool := mcp.NewTool("research_status",
+		mcp.WithDescription("Get the state of a research job started with research_start: "+
+			"pending, running, done, or error. When done, the full result is included."),
+		mcp.WithString("job_id",
+			mcp.Required(),
+			mcp.Description("Job ID returned by research_start"),
+		),
+	)
+	return &tool
+}
+
+// queryParameterOptions returns the parameter definitions shared by the query
+// and research_start tools. modelDescription documents the tool's default model.
+//
+// Constrained strings declare their values as enums from the validation
+// package, arrays declare their item type, and numbers declare the range the
+// config validator accepts, so clients can validate before calling. Defaults
+// are those of the API; the server config may override them.
+//nolint:funlen // Function length appropriate for defining 30+ parameters
+func queryParameterOptions(modelDescription string) []mcp.ToolOption {
+	opts := []mcp.ToolOption{
 		// Required parameters
 		mcp.WithString("user_prompt",
 			mcp.Required(),
@@ -20,61 +69,73 @@ func BuildQueryTool() *mcp.Tool {
 			mcp.Description("System prompt to guide the AI response"),
 		),
 		mcp.WithString("model",
-			mcp.Description("Model to use (default: "+perplexity.DefaultModel+")"),
+			mcp.Description(modelDescription),
+		),
+		mcp.WithArray("model_fallbacks",
+			mcp.Description("Models to try, in order, when the API reports the model unavailable or invalid; "+
+				"the result's model_used names the model that answered"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("language",
+			mcp.Description("ISO 639-1 code of the answer language (e.g. fr, de, ja); "+
+				"ignored when system_prompt already names a language"),
+			mcp.Enum(validation.LanguageCodes()...),
 		),
 		mcp.WithNumber("frequency_penalty",
 			mcp.Description("Frequency penalty for response generation"),
+			mcp.Min(0.0), mcp.Max(maxPenaltyParam),
+			mcp.DefaultNumber(perplexity.DefaultFrequencyPenalty),
 		),
 		mcp.WithNumber("max_tokens",
-			mcp.Description("Maximum number of tokens in response"),
+			mcp.Description("Maximum number of tokens in response; 0 or omitted for the model default. "+
+				"At most the max_output_tokens of the model in pplx://models"),
+			mcp.Min(0),
 		),
 		mcp.WithNumber("presence_penalty",
 			mcp.Description("Presence penalty for response generation"),
+			mcp.Min(0.0), mcp.Max(maxPenaltyParam),
+			mcp.DefaultNumber(perplexity.DefaultPresencePenalty),
 		),
 		mcp.WithNumber("temperature",
 			mcp.Description("Temperature for response generation"),
+			mcp.Min(0.0), mcp.Max(maxTemperatureParam),
+			mcp.DefaultNumber(perplexity.DefaultTemperature),
 		),
 		mcp.WithNumber("top_k",
 			mcp.Description("Top-K sampling parameter"),
+			mcp.Min(0), mcp.Max(maxTopKParam),
+			mcp.DefaultNumber(perplexity.DefaultTopK),
 		),
 		mcp.WithNumber("top_p",
 			mcp.Description("Top-P sampling parameter"),
+			mcp.Min(0.0), mcp.Max(1.0),
+			mcp.DefaultNumber(perplexity.DefaultTopP),
 		),
 		mcp.WithNumber("timeout",
 			mcp.Description("HTTP timeout in seconds"),
-		),
-		// Search/Web options
-		mcp.WithArray("search_domains",
-			mcp.Description("Filter search results to specific domains"),
-		),
-		mcp.WithString("search_recency",
-			mcp.Description("Filter by time: day, week, month, year, hour"),
-		),
-		mcp.WithNumber("location_lat",
-			mcp.Description("User location latitude"),
-		),
-		mcp.WithNumber("location_lon",
-			mcp.Description("User location longitude"),
-		),
-		mcp.WithString("location_country",
-			mcp.Description("User location country code"),
+			mcp.Min(1),
 		),
 		// Response enhancement options
 		mcp.WithBoolean("return_images",
 			mcp.Description("Include images in response"),
+			mcp.DefaultBool(false),
 		),
 		mcp.WithBoolean("return_related",
 			mcp.Description("Include related questions"),
+			mcp.DefaultBool(false),
 		),
 		mcp.WithBoolean("stream",
 			mcp.Description("Enable streaming responses (will be collected and returned as complete response)"),
+			mcp.DefaultBool(false),
 		),
 		// Image filtering options
 		mcp.WithArray("image_domains",
 			mcp.Description("Filter images by domains"),
+			mcp.WithStringItems(),
 		),
 		mcp.WithArray("image_formats",
 			mcp.Description("Filter images by formats (jpg, png, etc.)"),
+			mcp.WithStringItems(mcp.Enum(validation.ValidValues(validation.KindImageFormat)...)),
 		),
 		// Response format options
 		mcp.WithString("response_format_json_schema",
@@ -83,30 +144,91 @@ func BuildQueryTool() *mcp.Tool {
 		mcp.WithString("response_format_regex",
 			mcp.Description("Regex pattern for structured output (sonar model only)"),
 		),
+		// Deep research options
+		mcp.WithString("reasoning_effort",
+			mcp.Description("Reasoning effort for sonar-deep-research: low, medium, or high"),
+			mcp.Enum(validation.ValidValues(validation.KindReasoningEffort)...),
+			mcp.DefaultString(string(perplexity.DefaultReasoningEffort)),
+		),
+	}
+	opts = append(opts, searchParameterOptions()...)
+	return append(opts, credentialOptions()...)
+}
+
+// searchParameterOptions returns the definitions of the search filtering
+// parameters: domains, recency, location, search mode, and dates. The
+// search tool accepts them too.
+func searchParameterOptions() []mcp.ToolOption {
+	return []mcp.ToolOption{
+		// Search/Web options
+		mcp.WithArray("search_domains",
+			mcp.Description("Filter search results to specific domains"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithArray("exclude_domains",
+			mcp.Description("Exclude specific domains from search results; must not overlap search_domains"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("search_recency",
+			mcp.Description("Filter by time: "+validation.ValidList(validation.KindRecency)),
+			mcp.Enum(validation.ValidValues(validation.KindRecency)...),
+		),
+		mcp.WithNumber("location_lat",
+			mcp.Description("User location latitude"),
+			mcp.Min(-maxLatitude), mcp.Max(maxLatitude),
+		),
+		mcp.WithNumber("location_lon",
+			mcp.Description("User location longitude"),
+			mcp.Min(-maxLongitude), mcp.Max(maxLongitude),
+		),
+		mcp.WithString("location_country",
+			mcp.Description("User location country code"),
+		),
 		// Search mode options
 		mcp.WithString("search_mode",
 			mcp.Description("Search mode: web (default) or academic"),
+			mcp.Enum(validation.ValidValues(validation.KindSearchMode)...),
+			mcp.DefaultString(perplexity.DefaultSearchMode),
 		),
 		mcp.WithString("search_context_size",
 			mcp.Description("Search context size: low, medium, or high"),
+			mcp.Enum(validation.ValidValues(validation.KindContextSize)...),
 		),
 		// Date filtering options
 		mcp.WithString("search_after_date",
-			mcp.Description("Filter results published after date (MM/DD/YYYY)"),
+			mcp.Description("Filter results published after date (YYYY-MM-DD, MM/DD/YYYY, relative like 7d/3w/6m/1y, or quarter like 2024-Q1)"),
 		),
 		mcp.WithString("search_before_date",
-			mcp.Description("Filter results published before date (MM/DD/YYYY)"),
+			mcp.Description("Filter results published before date (YYYY-MM-DD, MM/DD/YYYY, relative like 7d/3w/6m/1y, or quarter like 2024-Q1)"),
 		),
 		mcp.WithString("last_updated_after",
-			mcp.Description("Filter results last updated after date (MM/DD/YYYY)"),
+			mcp.Description("Filter results last updated after date (YYYY-MM-DD, MM/DD/YYYY, relative like 7d/3w/6m/1y, or quarter like 2024-Q1)"),
 		),
 		mcp.WithString("last_updated_before",
-			mcp.Description("Filter results last updated before date (MM/DD/YYYY)"),
-		),
-		// Deep research options
-		mcp.WithString("reasoning_effort",
-			mcp.Description("Reasoning effort for sonar-deep-research: low, medium, or high"),
+			mcp.Description("Filter results last updated before date (YYYY-MM-DD, MM/DD/YYYY, relative like 7d/3w/6m/1y, or quarter like 2024-Q1)"),
 		),
-	)
-	return &tool
+	}
+}
+
+// credentialOptions returns the definitions of the api_key and key_id
+// parameters, which every tool calling the API accepts.
+func credentialOptions() []mcp.ToolOption {
+	return []mcp.ToolOption{
+		// Credentials
+		mcp.WithString("api_key",
+			mcp.Description("Perplexity API key to use instead of the server's key; never logged"),
+			sensitive(),
+		),
+		mcp.WithString("key_id",
+			mcp.Description("Name of a key in the server's api.keys config to use instead of the server's key"),
+		),
+	}
+}
+
+// sensitive marks a property as a secret (JSON Schema writeOnly), which
+// clients should neither display nor store.
+func sensitive() mcp.PropertyOption {
+	return func(schema map[string]any) {
+		schema["writeOnly"] = true
+	}
 }
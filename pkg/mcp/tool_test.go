@@ -1,7 +1,12 @@
 package mcp
 
 import (
+	"encoding/json"
+	"math"
+	"slices"
 	"testing"
+
+	"github.com/sgaunet/pplx/pkg/validation"
 )
 
 func TestBuildQueryTool(t *testing.T) {
@@ -110,3 +115,114 @@ func TestBuildQueryTool(t *testing.T) {
 		}
 	})
 }
+
+func TestBuildResearchTools(t *testing.T) {
+	start := BuildResearchStartTool()
+	if start.Name != "research_start" {
+		t.Errorf("Expected tool name %q, got %q", "research_start", start.Name)
+	}
+
+	// research_start accepts the same parameters as query.
+	query := BuildQueryTool()
+	for name := range query.InputSchema.Properties {
+		if _, ok := start.InputSchema.Properties[name]; !ok {
+			t.Errorf("research_start missing query parameter %q", name)
+		}
+	}
+	if len(start.InputSchema.Required) != 1 || start.InputSchema.Required[0] != "user_prompt" {
+		t.Errorf("research_start required = %v, want [user_prompt]", start.InputSchema.Required)
+	}
+
+	status := BuildResearchStatusTool()
+	if status.Name != "research_status" {
+		t.Errorf("Expected tool name %q, got %q", "research_status", status.Name)
+	}
+	if len(status.InputSchema.Required) != 1 || status.InputSchema.Required[0] != "job_id" {
+		t.Errorf("research_status required = %v, want [job_id]", status.InputSchema.Required)
+	}
+}
+
+func TestBuildQueryTool_SchemaConstraints(t *testing.T) {
+	data, err := json.Marshal(BuildQueryTool())
+	if err != nil {
+		t.Fatalf("failed to marshal tool: %v", err)
+	}
+	var tool struct {
+		InputSchema struct {
+			Properties map[string]struct {
+				Type      string          `json:"type"`
+				Enum      []string        `json:"enum"`
+				Minimum   *float64        `json:"minimum"`
+				Maximum   *float64        `json:"maximum"`
+				Default   json.RawMessage `json:"default"`
+				WriteOnly bool            `json:"writeOnly"`
+				Items     *struct {
+					Type string   `json:"type"`
+					Enum []string `json:"enum"`
+				} `json:"items"`
+			} `json:"properties"`
+		} `json:"inputSchema"`
+	}
+	if err := json.Unmarshal(data, &tool); err != nil {
+		t.Fatalf("failed to unmarshal tool: %v", err)
+	}
+	props := tool.InputSchema.Properties
+
+	enums := map[string]string{
+		"search_recency":      validation.KindRecency,
+		"search_mode":         validation.KindSearchMode,
+		"search_context_size": validation.KindContextSize,
+		"reasoning_effort":    validation.KindReasoningEffort,
+	}
+	for name, kind := range enums {
+		if got := props[name].Enum; !slices.Equal(got, validation.ValidValues(kind)) {
+			t.Errorf("%s enum = %v, want %v", name, got, validation.ValidValues(kind))
+		}
+	}
+
+	for _, name := range []string{"search_domains", "exclude_domains", "image_domains", "image_formats"} {
+		items := props[name].Items
+		if props[name].Type != "array" || items == nil || items.Type != "string" {
+			t.Errorf("%s should be an array of strings, got %+v", name, props[name])
+		}
+	}
+	if got := props["image_formats"].Items.Enum; !slices.Equal(got, validation.ValidValues(validation.KindImageFormat)) {
+		t.Errorf("image_formats item enum = %v", got)
+	}
+
+	if !props["api_key"].WriteOnly || props["key_id"].WriteOnly {
+		t.Errorf("only api_key should be writeOnly: api_key=%v key_id=%v",
+			props["api_key"].WriteOnly, props["key_id"].WriteOnly)
+	}
+
+	ranges := map[string][2]float64{
+		"temperature":  {0, 2},
+		"top_p":        {0, 1},
+		"location_lat": {-90, 90},
+		"location_lon": {-180, 180},
+	}
+	for name, want := range ranges {
+		p := props[name]
+		if p.Minimum == nil || p.Maximum == nil || *p.Minimum != want[0] || *p.Maximum != want[1] {
+			t.Errorf("%s range = [%v, %v], want %v", name, p.Minimum, p.Maximum, want)
+		}
+	}
+
+	// The extractor enforces the ranges the schema advertises.
+	for name, r := range paramRanges {
+		p := props[name]
+		if p.Minimum == nil || *p.Minimum != r.min {
+			t.Errorf("%s schema minimum = %v, extractor minimum %v", name, p.Minimum, r.min)
+		}
+		if math.IsInf(r.max, 1) != (p.Maximum == nil) || (p.Maximum != nil && *p.Maximum != r.max) {
+			t.Errorf("%s schema maximum = %v, extractor maximum %v", name, p.Maximum, r.max)
+		}
+	}
+
+	if got := string(props["search_mode"].Default); got != `"web"` {
+		t.Errorf("search_mode default = %s, want \"web\"", got)
+	}
+	if got := string(props["stream"].Default); got != "false" {
+		t.Errorf("stream default = %s, want false", got)
+	}
+}
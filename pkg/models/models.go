@@ -0,0 +1,207 @@
+// Package models is the registry of Perplexity models: the static model
+// table printed by `pplx models` and served as pplx://models, model aliases,
+// the known-model check shared by query, chat, the MCP handler, the config
+// wizard, and shell completion, and the output limits max_tokens is checked
+// against.
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+)
+
+// suggestMaxDistance is the largest edit distance for "did you mean"
+// suggestions of unknown model names.
+const suggestMaxDistance = 3
+
+// MaxTokensModelLimit is the max_tokens of --max-tokens max, standing for
+// the output limit of the model until ResolveMaxTokens replaces it.
+const MaxTokensModelLimit = -1
+
+// CostTier is the relative price of a model compared to the other models.
+type CostTier string
+
+// Cost tiers, from cheapest to most expensive.
+const (
+	CostLow    CostTier = "low"
+	CostMedium CostTier = "medium"
+	CostHigh   CostTier = "high"
+)
+
+// Info describes a Perplexity model and the options it supports.
+type Info struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// ContextWindow is the maximum number of tokens of prompt and completion.
+	ContextWindow int `json:"context_window"`
+	// MaxOutputTokens is the largest max_tokens the model accepts.
+	MaxOutputTokens int `json:"max_output_tokens"`
+	// SupportsStreaming reports whether the answer can be streamed.
+	SupportsStreaming bool `json:"supports_streaming"`
+	// SupportsResponseFormat reports whether response_format (JSON schema or
+	// regex) is accepted.
+	SupportsResponseFormat bool `json:"supports_response_format"`
+	// SupportsReasoningEffort reports whether reasoning_effort is accepted.
+	SupportsReasoningEffort bool     `json:"supports_reasoning_effort"`
+	CostTier                CostTier `json:"cost_tier"`
+}
+
+// Catalog is the document served by the pplx://models resource and
+// printed by `pplx models --json`.
+type Catalog struct {
+	Models []Info `json:"models"`
+}
+
+// table is the static model table, based on
+// https://docs.perplexity.ai/guides/model-cards.
+var table = []Info{
+	{
+		Name:                   "sonar",
+		Description:            "Fast, lightweight search model",
+		ContextWindow:          128_000,
+		MaxOutputTokens:        8_000,
+		SupportsStreaming:      true,
+		SupportsResponseFormat: true,
+		CostTier:               CostLow,
+	},
+	{
+		Name:                   "sonar-pro",
+		Description:            "Search model for complex queries with more citations",
+		ContextWindow:          200_000,
+		MaxOutputTokens:        8_000,
+		SupportsStreaming:      true,
+		SupportsResponseFormat: true,
+		CostTier:               CostHigh,
+	},
+	{
+		Name:                   "sonar-reasoning",
+		Description:            "Reasoning model with step-by-step answers",
+		ContextWindow:          128_000,
+		MaxOutputTokens:        32_000,
+		SupportsStreaming:      true,
+		SupportsResponseFormat: true,
+		CostTier:               CostMedium,
+	},
+	{
+		Name:                   "sonar-reasoning-pro",
+		Description:            "Precise reasoning model for multi-step problems",
+		ContextWindow:          128_000,
+		MaxOutputTokens:        32_000,
+		SupportsStreaming:      true,
+		SupportsResponseFormat: true,
+		CostTier:               CostMedium,
+	},
+	{
+		Name:                    "sonar-deep-research",
+		Description:             "Exhaustive research across many sources; slow, run it with research_start",
+		ContextWindow:           128_000,
+		MaxOutputTokens:         64_000,
+		SupportsStreaming:       true,
+		SupportsResponseFormat:  true,
+		SupportsReasoningEffort: true,
+		CostTier:                CostHigh,
+	},
+}
+
+// All returns a copy of the model table.
+func All() []Info {
+	out := make([]Info, len(table))
+	copy(out, table)
+	return out
+}
+
+// IDs returns the model IDs of the table, in table order.
+func IDs() []string {
+	ids := make([]string, len(table))
+	for i, m := range table {
+		ids[i] = m.Name
+	}
+	return ids
+}
+
+// Lookup returns the table entry for name, ignoring case. The boolean is
+// false for models missing from the table.
+func Lookup(name string) (Info, bool) {
+	for _, m := range table {
+		if strings.EqualFold(m.Name, name) {
+			return m, true
+		}
+	}
+	return Info{}, false
+}
+
+// Resolve expands name when it is a key of aliases and returns it unchanged
+// otherwise. Aliases are not chained: the target is used as is.
+func Resolve(name string, aliases map[string]string) string {
+	if target, ok := aliases[name]; ok && target != "" {
+		return target
+	}
+	return name
+}
+
+// Check reports whether name is a known model. Empty names, which select the
+// API default, are accepted. Unknown names return an error wrapping
+// clerrors.ErrUnknownModel that suggests the closest known model. New
+// models appear upstream before this table knows them, so callers usually
+// only warn.
+func Check(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := Lookup(name); ok {
+		return nil
+	}
+	msg := fmt.Sprintf("%q. Known models: %s", name, strings.Join(IDs(), ", "))
+	if suggestion := config.SuggestEnum(name, IDs(), suggestMaxDistance); suggestion != "" {
+		msg = fmt.Sprintf("%q. Did you mean %q?", name, suggestion)
+	}
+	return fmt.Errorf("%w: %s", clerrors.ErrUnknownModel, msg)
+}
+
+// DefaultMaxTokens returns the max_tokens of a request to model that sets
+// none: perplexity.DefaultMaxTokens, lowered to the output limit of the
+// model.
+func DefaultMaxTokens(name string) int {
+	if m, ok := Lookup(name); ok && m.MaxOutputTokens > 0 {
+		return min(perplexity.DefaultMaxTokens, m.MaxOutputTokens)
+	}
+	return perplexity.DefaultMaxTokens
+}
+
+// ResolveMaxTokens returns the max_tokens of a request to model: zero
+// becomes DefaultMaxTokens, MaxTokensModelLimit the output limit of the
+// model, and other values are checked against the limit with
+// CheckMaxTokens. MaxTokensModelLimit for a model missing from the table
+// returns an error wrapping clerrors.ErrUnknownModel.
+func ResolveMaxTokens(name string, maxTokens int) (int, error) {
+	switch maxTokens {
+	case 0:
+		return DefaultMaxTokens(name), nil
+	case MaxTokensModelLimit:
+	default:
+		return maxTokens, CheckMaxTokens(name, maxTokens)
+	}
+	m, ok := Lookup(name)
+	if !ok || m.MaxOutputTokens == 0 {
+		return 0, fmt.Errorf("%w: %q has no known output limit; give --max-tokens a number",
+			clerrors.ErrUnknownModel, name)
+	}
+	return m.MaxOutputTokens, nil
+}
+
+// CheckMaxTokens reports whether maxTokens is within the output limit of
+// model. Values above it return an error wrapping
+// clerrors.ErrMaxTokensExceeded that gives the limit. Models missing from
+// the table are not checked.
+func CheckMaxTokens(name string, maxTokens int) error {
+	m, ok := Lookup(name)
+	if !ok || m.MaxOutputTokens == 0 || maxTokens <= m.MaxOutputTokens {
+		return nil
+	}
+	return fmt.Errorf("%w: %d requested, %s writes at most %d tokens",
+		clerrors.ErrMaxTokensExceeded, maxTokens, m.Name, m.MaxOutputTokens)
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestAll(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, m := range All() {
+		if seen[m.Name] {
+			t.Errorf("duplicate model %q", m.Name)
+		}
+		seen[m.Name] = true
+		if m.ContextWindow <= 0 || m.MaxOutputTokens <= 0 || m.MaxOutputTokens > m.ContextWindow || m.Description == "" {
+			t.Errorf("incomplete entry %+v", m)
+		}
+		switch m.CostTier {
+		case CostLow, CostMedium, CostHigh:
+		default:
+			t.Errorf("%s: unknown cost tier %q", m.Name, m.CostTier)
+		}
+	}
+
+	if _, ok := Lookup("SONAR-PRO"); !ok {
+		t.Error("Lookup should ignore case")
+	}
+	if _, ok := Lookup("gpt-4"); ok {
+		t.Error("Lookup(gpt-4) should not be found")
+	}
+
+	got := All()
+	got[0].Name = "mutated"
+	if All()[0].Name == "mutated" {
+		t.Error("All() should return a copy")
+	}
+	if ids := IDs(); len(ids) != len(All()) || ids[0] != All()[0].Name {
+		t.Errorf("IDs() = %v, want the names of All()", ids)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	aliases := map[string]string{"fast": "sonar", "deep": "sonar-deep-research", "empty": ""}
+	tests := map[string]string{
+		"fast":      "sonar",
+		"deep":      "sonar-deep-research",
+		"sonar-pro": "sonar-pro",
+		"empty":     "empty",
+		"":          "",
+	}
+	for name, want := range tests {
+		if got := Resolve(name, aliases); got != want {
+			t.Errorf("Resolve(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if got := Resolve("fast", nil); got != "fast" {
+		t.Errorf("Resolve with no aliases = %q, want fast", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		wantErr  bool
+		contains string
+	}{
+		{"", false, ""},
+		{"sonar", false, ""},
+		{"Sonar-Pro", false, ""},
+		{"sonar-prp", true, `Did you mean "sonar-pro"?`},
+		{"gpt-4", true, "Known models: sonar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			if !errors.Is(err, clerrors.ErrUnknownModel) {
+				t.Errorf("error %v does not wrap ErrUnknownModel", err)
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("error %q does not contain %q", err, tt.contains)
+			}
+		})
+	}
+}
+
+func TestResolveMaxTokens(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     string
+		maxTokens int
+		want      int
+		wantErr   error
+	}{
+		{"zero is the model default", "sonar", 0, 4000, nil},
+		{"zero for an unknown model", "gpt-4", 0, 4000, nil},
+		{"max is the model limit", "sonar-deep-research", MaxTokensModelLimit, 64_000, nil},
+		{"max for an unknown model", "gpt-4", MaxTokensModelLimit, 0, clerrors.ErrUnknownModel},
+		{"at the limit", "Sonar", 8_000, 8_000, nil},
+		{"above the limit", "sonar", 200_000, 0, clerrors.ErrMaxTokensExceeded},
+		{"unknown models are not checked", "gpt-4", 200_000, 200_000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveMaxTokens(tt.model, tt.maxTokens)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ResolveMaxTokens() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ResolveMaxTokens(%q, %d) = %d, %v; want %d", tt.model, tt.maxTokens, got, err, tt.want)
+			}
+		})
+	}
+
+	err := CheckMaxTokens("sonar", 200_000)
+	if err == nil || !strings.Contains(err.Error(), "200000 requested, sonar writes at most 8000 tokens") {
+		t.Errorf("CheckMaxTokens() error = %v, want the limit of sonar", err)
+	}
+}
@@ -0,0 +1,51 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// emptyResponse is the message of the API error for a response without an
+// answer.
+const emptyResponse = "empty response"
+
+// FirstContent returns the answer of the first choice of response. It
+// returns a *clerrors.APIError wrapping clerrors.ErrNoChoices when response
+// is nil or has no choices, and clerrors.ErrBlankAnswer when the answer is
+// blank.
+func FirstContent(response *perplexity.CompletionResponse) (string, error) {
+	if response == nil || len(response.Choices) == 0 {
+		return "", clerrors.NewAPIError(emptyResponse, clerrors.ErrNoChoices)
+	}
+	content := response.Choices[0].Message.Content
+	if strings.TrimSpace(content) == "" {
+		return "", clerrors.NewAPIError(emptyResponse, clerrors.ErrBlankAnswer)
+	}
+	return content, nil
+}
+
+// Contents returns the answers of every choice of response, in choice
+// order, as requested with n > 1. Blank candidates are kept so that indices
+// match the choices; the errors are those of FirstContent, with
+// clerrors.ErrBlankAnswer returned only when every answer is blank.
+func Contents(response *perplexity.CompletionResponse) ([]string, error) {
+	if response == nil || len(response.Choices) == 0 {
+		return nil, clerrors.NewAPIError(emptyResponse, clerrors.ErrNoChoices)
+	}
+	contents := make([]string, len(response.Choices))
+	blank := true
+	for i, choice := range response.Choices {
+		contents[i] = choice.Message.Content
+		if strings.TrimSpace(choice.Message.Content) != "" {
+			blank = false
+		}
+	}
+	if blank {
+		return nil, clerrors.NewAPIError(emptyResponse,
+			fmt.Errorf("%w: all %d choices", clerrors.ErrBlankAnswer, len(contents)))
+	}
+	return contents, nil
+}
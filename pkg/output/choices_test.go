@@ -0,0 +1,66 @@
+package output
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func choicesResponse(contents ...string) *perplexity.CompletionResponse {
+	resp := &perplexity.CompletionResponse{Model: "sonar", Choices: []perplexity.Choice{}}
+	for i, c := range contents {
+		resp.Choices = append(resp.Choices, perplexity.Choice{Index: i, Message: perplexity.Message{Content: c}})
+	}
+	return resp
+}
+
+func TestFirstContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    *perplexity.CompletionResponse
+		want    string
+		wantErr error
+	}{
+		{"nil response", nil, "", clerrors.ErrNoChoices},
+		{"zero choices", choicesResponse(), "", clerrors.ErrNoChoices},
+		{"blank answer", choicesResponse(" \n"), "", clerrors.ErrBlankAnswer},
+		{"single choice", choicesResponse("answer"), "answer", nil},
+		{"first of many", choicesResponse("first", "second"), "first", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FirstContent(tt.resp)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("FirstContent() error = %v, want %v", err, tt.wantErr)
+			}
+			var apiErr *clerrors.APIError
+			if tt.wantErr != nil && !errors.As(err, &apiErr) {
+				t.Errorf("FirstContent() error = %T, want *clerrors.APIError", err)
+			}
+			if got != tt.want {
+				t.Errorf("FirstContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContents(t *testing.T) {
+	got, err := Contents(choicesResponse("one", "", "three"))
+	if err != nil || !slices.Equal(got, []string{"one", "", "three"}) {
+		t.Errorf("Contents() = %q, %v", got, err)
+	}
+	if _, err := Contents(choicesResponse()); !errors.Is(err, clerrors.ErrNoChoices) {
+		t.Errorf("Contents() of zero choices error = %v, want ErrNoChoices", err)
+	}
+	if _, err := Contents(choicesResponse("", " ")); !errors.Is(err, clerrors.ErrBlankAnswer) {
+		t.Errorf("Contents() of blank choices error = %v, want ErrBlankAnswer", err)
+	}
+
+	result := FromResponse(choicesResponse("one", "two"), 0)
+	if result.Content != "one" || !slices.Equal(result.Choices, []string{"one", "two"}) {
+		t.Errorf("FromResponse() content = %q, choices = %q", result.Content, result.Choices)
+	}
+}
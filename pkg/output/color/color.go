@@ -0,0 +1,232 @@
+// Package color decides whether pplx writes ANSI colors and which ones.
+//
+// Whether output is colored is decided once per writer, from the --color
+// mode, the NO_COLOR and CLICOLOR_FORCE conventions, and whether the writer
+// is a terminal (see Decide). Printing code names the semantic role of the
+// text it colors, such as a heading or a warning, and the theme selected
+// with output.theme maps each role to a color.
+package color
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pterm/pterm"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"golang.org/x/term"
+)
+
+// Mode is the --color setting.
+type Mode string
+
+// Color modes.
+const (
+	// ModeAuto colors output written to a terminal, unless the environment
+	// says otherwise.
+	ModeAuto Mode = "auto"
+	// ModeAlways colors all output, even piped.
+	ModeAlways Mode = "always"
+	// ModeNever writes no color.
+	ModeNever Mode = "never"
+)
+
+// Modes returns the supported color mode names.
+func Modes() []string {
+	return []string{string(ModeAuto), string(ModeAlways), string(ModeNever)}
+}
+
+// ParseMode validates a color mode name; empty selects ModeAuto. Returns
+// clerrors.ErrInvalidColorMode for unknown modes.
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return ModeAuto, nil
+	case ModeAuto, ModeAlways, ModeNever:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: %q. Must be one of: %s",
+			clerrors.ErrInvalidColorMode, s, strings.Join(Modes(), ", "))
+	}
+}
+
+// Role is the meaning of a piece of colored text.
+type Role string
+
+// Roles colored by the themes.
+const (
+	// Heading is a section title, such as a config diff section.
+	Heading Role = "heading"
+	// Citation is a citation marker such as [1] in a rendered answer.
+	Citation Role = "citation"
+	// Warning is the header of the warnings block.
+	Warning Role = "warning"
+	// Error is an error message, or a removed or mismatching value.
+	Error Role = "error"
+	// Success is an added or matching value.
+	Success Role = "success"
+)
+
+// Theme is a palette mapping each Role to a color.
+type Theme string
+
+// Themes of output.theme.
+const (
+	// ThemeDefault uses the standard terminal colors.
+	ThemeDefault Theme = "default"
+	// ThemeHighContrast uses bold, bright colors, readable on dim displays
+	// and for low vision.
+	ThemeHighContrast Theme = "high-contrast"
+)
+
+// palettes holds the SGR parameters of each role, per theme.
+var palettes = map[Theme]map[Role]string{
+	ThemeDefault: {
+		Heading:  "1",
+		Citation: "36",
+		Warning:  "33",
+		Error:    "31",
+		Success:  "32",
+	},
+	ThemeHighContrast: {
+		Heading:  "1;4;97",
+		Citation: "1;96",
+		Warning:  "1;93",
+		Error:    "1;91",
+		Success:  "1;92",
+	},
+}
+
+// Themes returns the supported theme names.
+func Themes() []string {
+	return []string{string(ThemeDefault), string(ThemeHighContrast)}
+}
+
+// ParseTheme validates a theme name; empty selects ThemeDefault. Returns
+// clerrors.ErrInvalidTheme for unknown themes.
+func ParseTheme(s string) (Theme, error) {
+	theme := Theme(strings.ToLower(strings.TrimSpace(s)))
+	if theme == "" {
+		return ThemeDefault, nil
+	}
+	if _, ok := palettes[theme]; !ok {
+		return "", fmt.Errorf("%w: %q. Must be one of: %s",
+			clerrors.ErrInvalidTheme, s, strings.Join(Themes(), ", "))
+	}
+	return theme, nil
+}
+
+// Paint returns s in the color of role, each line wrapped on its own so
+// that pagers keep the color across lines. An unknown theme paints with
+// ThemeDefault; empty lines are left as is.
+func (t Theme) Paint(role Role, s string) string {
+	palette, ok := palettes[t]
+	if !ok {
+		palette = palettes[ThemeDefault]
+	}
+	code := palette[role]
+	if code == "" {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "\x1b[" + code + "m" + line + "\x1b[0m"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// settings are the mode and theme in effect, set by Configure and SetTheme.
+var settings = struct {
+	sync.RWMutex
+	mode  Mode
+	theme Theme
+}{mode: ModeAuto, theme: ThemeDefault}
+
+// Configure sets the color mode in effect and enables or disables the
+// colors of pterm, which prints the spinner and info messages to stdout
+// and stderr, accordingly.
+func Configure(mode Mode) {
+	settings.Lock()
+	settings.mode = mode
+	settings.Unlock()
+	if Enabled(os.Stdout) && Enabled(os.Stderr) {
+		pterm.EnableColor()
+	} else {
+		pterm.DisableColor()
+	}
+}
+
+// CurrentMode returns the color mode in effect.
+func CurrentMode() Mode {
+	settings.RLock()
+	defer settings.RUnlock()
+	return settings.mode
+}
+
+// SetTheme sets the theme in effect.
+func SetTheme(theme Theme) {
+	settings.Lock()
+	defer settings.Unlock()
+	settings.theme = theme
+}
+
+// CurrentTheme returns the theme in effect.
+func CurrentTheme() Theme {
+	settings.RLock()
+	defer settings.RUnlock()
+	return settings.theme
+}
+
+// Decide reports whether output is colored in mode, for a writer that is a
+// terminal when tty is set. always and never are final; in auto mode a
+// non-empty NO_COLOR disables color, a CLICOLOR_FORCE other than 0 forces
+// it, and CLICOLOR=0 or TERM=dumb disable it, else terminals are colored.
+func Decide(mode Mode, tty bool, getenv func(string) string) bool {
+	switch mode {
+	case ModeAlways:
+		return true
+	case ModeNever:
+		return false
+	}
+	if getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	if getenv("CLICOLOR") == "0" || getenv("TERM") == "dumb" {
+		return false
+	}
+	return tty
+}
+
+// Enabled reports whether output written to w is colored. In auto mode,
+// only an *os.File attached to a terminal can be.
+func Enabled(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	tty := ok && term.IsTerminal(int(f.Fd())) //nolint:gosec // file descriptors fit in int
+	return Decide(CurrentMode(), tty, os.Getenv)
+}
+
+// Sprint returns s in the color of role in the current theme when output
+// written to w is colored, and s unchanged otherwise.
+func Sprint(w io.Writer, role Role, s string) string {
+	if !Enabled(w) {
+		return s
+	}
+	return CurrentTheme().Paint(role, s)
+}
+
+// escapePattern matches the CSI and OSC 8 escape sequences pplx writes:
+// colors, cursor control, and hyperlinks.
+var escapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]|\x1b\]8;;[^\x1b\x07]*(?:\x1b\\|\x07)`)
+
+// Strip removes escape sequences from s.
+func Strip(s string) string {
+	return escapePattern.ReplaceAllString(s, "")
+}
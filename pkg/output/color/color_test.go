@@ -0,0 +1,140 @@
+package color
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// env returns a getenv reading from vars.
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name string
+		mode Mode
+		tty  bool
+		env  map[string]string
+		want bool
+	}{
+		{"auto on a terminal", ModeAuto, true, nil, true},
+		{"auto piped", ModeAuto, false, nil, false},
+		{"NO_COLOR", ModeAuto, true, map[string]string{"NO_COLOR": "1"}, false},
+		{"empty NO_COLOR", ModeAuto, true, map[string]string{"NO_COLOR": ""}, true},
+		{"NO_COLOR beats CLICOLOR_FORCE", ModeAuto, false, map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}, false},
+		{"CLICOLOR_FORCE piped", ModeAuto, false, map[string]string{"CLICOLOR_FORCE": "1"}, true},
+		{"CLICOLOR_FORCE=0", ModeAuto, false, map[string]string{"CLICOLOR_FORCE": "0"}, false},
+		{"CLICOLOR=0", ModeAuto, true, map[string]string{"CLICOLOR": "0"}, false},
+		{"TERM=dumb", ModeAuto, true, map[string]string{"TERM": "dumb"}, false},
+		{"always beats NO_COLOR", ModeAlways, false, map[string]string{"NO_COLOR": "1"}, true},
+		{"never beats CLICOLOR_FORCE", ModeNever, true, map[string]string{"CLICOLOR_FORCE": "1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Decide(tt.mode, tt.tty, env(tt.env)); got != tt.want {
+				t.Errorf("Decide() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Mode
+		wantErr bool
+	}{
+		{"", ModeAuto, false},
+		{"auto", ModeAuto, false},
+		{"Always", ModeAlways, false},
+		{" never ", ModeNever, false},
+		{"sometimes", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMode(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, clerrors.ErrInvalidColorMode) {
+					t.Errorf("ParseMode(%q) error = %v, want ErrInvalidColorMode", tt.in, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseMode(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	if got, err := ParseTheme(""); err != nil || got != ThemeDefault {
+		t.Errorf("ParseTheme(\"\") = %q, %v; want the default theme", got, err)
+	}
+	if got, err := ParseTheme("High-Contrast"); err != nil || got != ThemeHighContrast {
+		t.Errorf("ParseTheme(High-Contrast) = %q, %v", got, err)
+	}
+	if _, err := ParseTheme("solarized"); !errors.Is(err, clerrors.ErrInvalidTheme) {
+		t.Errorf("ParseTheme(solarized) error = %v, want ErrInvalidTheme", err)
+	}
+}
+
+func TestTheme_Paint(t *testing.T) {
+	tests := []struct {
+		theme Theme
+		role  Role
+		in    string
+		want  string
+	}{
+		{ThemeDefault, Warning, "⚠ Warning:", "\x1b[33m⚠ Warning:\x1b[0m"},
+		{ThemeDefault, Citation, "[1]", "\x1b[36m[1]\x1b[0m"},
+		{ThemeHighContrast, Error, "old", "\x1b[1;91mold\x1b[0m"},
+		{ThemeHighContrast, Heading, "defaults", "\x1b[1;4;97mdefaults\x1b[0m"},
+		{"unknown", Success, "new", "\x1b[32mnew\x1b[0m"},
+		{ThemeDefault, Error, "a\n\nb", "\x1b[31ma\x1b[0m\n\n\x1b[31mb\x1b[0m"},
+		{ThemeDefault, "unknown", "text", "text"},
+	}
+	for _, tt := range tests {
+		if got := tt.theme.Paint(tt.role, tt.in); got != tt.want {
+			t.Errorf("%s.Paint(%s, %q) = %q, want %q", tt.theme, tt.role, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPalettes_CoverRoles(t *testing.T) {
+	for _, name := range Themes() {
+		for _, role := range []Role{Heading, Citation, Warning, Error, Success} {
+			if palettes[Theme(name)][role] == "" {
+				t.Errorf("theme %s has no color for %s", name, role)
+			}
+		}
+	}
+}
+
+func TestSprint(t *testing.T) {
+	t.Cleanup(func() { Configure(ModeAuto) })
+	var buf bytes.Buffer
+
+	Configure(ModeNever)
+	if got := Sprint(&buf, Error, "boom"); got != "boom" {
+		t.Errorf("Sprint() with --color=never = %q", got)
+	}
+	Configure(ModeAlways)
+	if got := Sprint(&buf, Error, "boom"); got != "\x1b[31mboom\x1b[0m" {
+		t.Errorf("Sprint() with --color=always = %q", got)
+	}
+}
+
+func TestStrip(t *testing.T) {
+	in := "\x1b[1;96m[1]\x1b[0m see \x1b]8;;https://go.dev\x1b\\docs\x1b]8;;\x1b\\\x1b[K"
+	if got := Strip(in); got != "[1] see docs" {
+		t.Errorf("Strip() = %q", got)
+	}
+	if strings.Contains(Strip(ThemeHighContrast.Paint(Heading, "a\nb")), "\x1b") {
+		t.Error("Strip() left an escape sequence")
+	}
+}
@@ -0,0 +1,144 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// pathCount is the path component returning the length of an array, or,
+// followed by more components, applying them to each element.
+const pathCount = "#"
+
+// ParsePath splits an --extract path into its components. Components are
+// separated by dots; `\.` is a literal dot and `\\` a literal backslash.
+// A component is an object key, an array index, or "#" (see Extract).
+// Returns an error wrapping clerrors.ErrInvalidExtractPath for an empty path,
+// an empty component, or a dangling backslash.
+func ParsePath(path string) ([]string, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: path is empty", clerrors.ErrInvalidExtractPath)
+	}
+
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			if i+1 == len(path) {
+				return nil, fmt.Errorf("%w: %q ends with a backslash", clerrors.ErrInvalidExtractPath, path)
+			}
+			i++
+			cur.WriteByte(path[i])
+		case '.':
+			if cur.Len() == 0 {
+				return nil, fmt.Errorf("%w: %q has an empty component", clerrors.ErrInvalidExtractPath, path)
+			}
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	if cur.Len() == 0 {
+		return nil, fmt.Errorf("%w: %q has an empty component", clerrors.ErrInvalidExtractPath, path)
+	}
+	return append(parts, cur.String()), nil
+}
+
+// Extract returns the value at path in the JSON document data, in the
+// syntax of gjson paths: "a.b" is key b of object a, "items.0" the first
+// element of array items, "items.#" its length, and "items.#.name" the list
+// of the name keys of its elements (elements without one are skipped).
+//
+// Strings are returned as is, other values as JSON, indented when they are
+// objects or arrays. Content wrapped in a Markdown code fence, as models
+// often answer, is unwrapped first. Returns an error wrapping
+// clerrors.ErrExtractNotJSON when data is not JSON,
+// clerrors.ErrInvalidExtractPath for a malformed path, and
+// clerrors.ErrExtractPathNotFound when the path does not exist.
+func Extract(data []byte, path string) (string, error) {
+	parts, err := ParsePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(unfence(data)))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return "", fmt.Errorf("%w: %w", clerrors.ErrExtractNotJSON, err)
+	}
+	if dec.More() {
+		return "", fmt.Errorf("%w: trailing data after the JSON value", clerrors.ErrExtractNotJSON)
+	}
+
+	value, ok := walk(doc, parts)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", clerrors.ErrExtractPathNotFound, path)
+	}
+	if s, isString := value.(string); isString {
+		return s, nil
+	}
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode extracted value: %w", err)
+	}
+	return string(out), nil
+}
+
+// walk follows parts from v. The boolean is false when a component does not
+// exist.
+func walk(v any, parts []string) (any, bool) {
+	if len(parts) == 0 {
+		return v, true
+	}
+	part, rest := parts[0], parts[1:]
+
+	switch node := v.(type) {
+	case map[string]any:
+		child, ok := node[part]
+		if !ok {
+			return nil, false
+		}
+		return walk(child, rest)
+	case []any:
+		if part == pathCount {
+			if len(rest) == 0 {
+				return json.Number(strconv.Itoa(len(node))), true
+			}
+			values := []any{}
+			for _, elem := range node {
+				if value, ok := walk(elem, rest); ok {
+					values = append(values, value)
+				}
+			}
+			return values, true
+		}
+		index, err := strconv.Atoi(part)
+		if err != nil || index < 0 || index >= len(node) {
+			return nil, false
+		}
+		return walk(node[index], rest)
+	}
+	return nil, false
+}
+
+// unfence returns the body of data when it is a Markdown code block, such as
+// ```json ... ```, and data otherwise.
+func unfence(data []byte) []byte {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("```")) || !bytes.HasSuffix(trimmed, []byte("```")) {
+		return data
+	}
+	body := bytes.TrimSuffix(trimmed, []byte("```"))
+	newline := bytes.IndexByte(body, '\n')
+	if newline < 0 {
+		return data
+	}
+	return body[newline+1:]
+}
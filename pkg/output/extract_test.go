@@ -0,0 +1,82 @@
+package output
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+const extractDoc = `{
+  "name": "Go",
+  "version": {"major": 1, "minor": 25},
+  "tags": ["fast", "simple"],
+  "authors": [{"name": "Rob"}, {"name": "Ken", "born": 1943}, {"team": "go"}],
+  "a.b": true,
+  "empty": null
+}`
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"top-level string", "name", "Go"},
+		{"nested object", "version.minor", "25"},
+		{"object", "version", "{\n  \"major\": 1,\n  \"minor\": 25\n}"},
+		{"array index", "tags.1", "simple"},
+		{"object in array", "authors.1.born", "1943"},
+		{"array length", "authors.#", "3"},
+		{"array projection", "authors.#.name", "[\n  \"Rob\",\n  \"Ken\"\n]"},
+		{"escaped dot", `a\.b`, "true"},
+		{"null", "empty", "null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract([]byte(extractDoc), tt.path)
+			if err != nil {
+				t.Fatalf("Extract(%q) error = %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("Extract(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtract_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		path    string
+		wantErr error
+	}{
+		{"missing key", extractDoc, "version.patch", clerrors.ErrExtractPathNotFound},
+		{"index out of range", extractDoc, "tags.2", clerrors.ErrExtractPathNotFound},
+		{"negative index", extractDoc, "tags.-1", clerrors.ErrExtractPathNotFound},
+		{"key on array", extractDoc, "tags.first", clerrors.ErrExtractPathNotFound},
+		{"key on string", extractDoc, "name.first", clerrors.ErrExtractPathNotFound},
+		{"empty path", extractDoc, "", clerrors.ErrInvalidExtractPath},
+		{"empty component", extractDoc, "version..major", clerrors.ErrInvalidExtractPath},
+		{"trailing dot", extractDoc, "version.", clerrors.ErrInvalidExtractPath},
+		{"dangling backslash", extractDoc, `name\`, clerrors.ErrInvalidExtractPath},
+		{"prose", "Go is a programming language.", "name", clerrors.ErrExtractNotJSON},
+		{"trailing data", `{"name": "Go"} and more`, "name", clerrors.ErrExtractNotJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Extract([]byte(tt.data), tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Extract(%q) error = %v, want %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtract_CodeFence(t *testing.T) {
+	got, err := Extract([]byte("```json\n{\"answer\": 42}\n```\n"), "answer")
+	if err != nil || got != "42" {
+		t.Errorf("Extract() = %q, %v; want 42", got, err)
+	}
+}
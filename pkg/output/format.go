@@ -0,0 +1,166 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/output/color"
+)
+
+// Characters of the mismatched line shown by WriteMismatch before and after
+// the first character that does not match.
+const (
+	mismatchBefore = 60
+	mismatchAfter  = 20
+)
+
+// FormatMatch is the outcome of checking an answer against the pattern of a
+// regex response format.
+type FormatMatch struct {
+	// Matched reports whether the whole answer matches the pattern.
+	Matched bool
+	// Offset is the length in bytes of the longest prefix of the answer that
+	// some match of the pattern starts with: the position of the first
+	// character that does not fit, or the length of an answer that ended
+	// too early. It is the length of the answer when Matched is set.
+	Offset int
+}
+
+// MatchRegex checks content against pattern, the regex of a response
+// format. Like the API, which constrains the whole answer to the pattern,
+// it requires the pattern to match all of content, whether or not the
+// pattern is anchored with ^ and $. Returns an error when pattern is not a
+// valid Go regular expression; some patterns the API accepts, such as
+// lookaheads, are not.
+func MatchRegex(pattern, content string) (FormatMatch, error) {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return FormatMatch{}, fmt.Errorf("cannot check the response format regex: %w", err)
+	}
+	re, err := syntax.Parse(`^(?:`+pattern+`)$`, syntax.Perl)
+	if err != nil {
+		return FormatMatch{}, fmt.Errorf("cannot check the response format regex: %w", err)
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return FormatMatch{}, fmt.Errorf("cannot check the response format regex: %w", err)
+	}
+	return matchProg(prog, content), nil
+}
+
+// matchProg runs prog over content one rune at a time, keeping every state
+// a match could be in, and stops at the first rune that leaves none.
+func matchProg(prog *syntax.Prog, content string) FormatMatch {
+	pcs := []uint32{uint32(prog.Start)} //nolint:gosec // instruction indexes fit in uint32
+	prev := rune(-1)
+	for i, r := range content {
+		states := follow(prog, pcs, syntax.EmptyOpContext(prev, r))
+		var next []uint32
+		for _, pc := range states {
+			if inst := &prog.Inst[pc]; consumes(inst, r) {
+				next = append(next, inst.Out)
+			}
+		}
+		if len(next) == 0 {
+			return FormatMatch{Offset: i}
+		}
+		pcs, prev = next, r
+	}
+
+	for _, pc := range follow(prog, pcs, syntax.EmptyOpContext(prev, -1)) {
+		if prog.Inst[pc].Op == syntax.InstMatch {
+			return FormatMatch{Matched: true, Offset: len(content)}
+		}
+	}
+	return FormatMatch{Offset: len(content)}
+}
+
+// follow returns the instructions that consume a rune or match, reachable
+// from pcs without consuming one, where the empty-width assertions of flag
+// hold.
+func follow(prog *syntax.Prog, pcs []uint32, flag syntax.EmptyOp) []uint32 {
+	seen := make(map[uint32]bool)
+	var states []uint32
+	var visit func(pc uint32)
+	visit = func(pc uint32) {
+		if seen[pc] {
+			return
+		}
+		seen[pc] = true
+		inst := &prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			visit(inst.Out)
+			visit(inst.Arg)
+		case syntax.InstCapture, syntax.InstNop:
+			visit(inst.Out)
+		case syntax.InstEmptyWidth:
+			if syntax.EmptyOp(inst.Arg)&^flag == 0 {
+				visit(inst.Out)
+			}
+		case syntax.InstFail:
+		default:
+			states = append(states, pc)
+		}
+	}
+	for _, pc := range pcs {
+		visit(pc)
+	}
+	return states
+}
+
+// consumes reports whether inst consumes r.
+func consumes(inst *syntax.Inst, r rune) bool {
+	switch inst.Op { //nolint:exhaustive // other instructions consume nothing
+	case syntax.InstRune, syntax.InstRune1:
+		return inst.MatchRune(r)
+	case syntax.InstRuneAny:
+		return true
+	case syntax.InstRuneAnyNotNL:
+		return r != '\n'
+	}
+	return false
+}
+
+// WriteMismatch prints where content stops matching the pattern m was
+// checked against: the line holding m.Offset, its matching part in the
+// success color and the rest in the error color of the current theme when
+// colored is set, and a caret under the first character that does not
+// match. It prints nothing when m.Matched is set.
+func WriteMismatch(w io.Writer, content string, m FormatMatch, colored bool) error {
+	if m.Matched {
+		return nil
+	}
+	lineStart := strings.LastIndexByte(content[:m.Offset], '\n') + 1
+	lineEnd := len(content)
+	if i := strings.IndexByte(content[m.Offset:], '\n'); i >= 0 {
+		lineEnd = m.Offset + i
+	}
+	before := []rune(content[lineStart:m.Offset])
+	after := []rune(content[m.Offset:lineEnd])
+	line, column := strings.Count(content[:m.Offset], "\n")+1, len(before)+1
+
+	header := fmt.Sprintf("Answer does not match the response format regex at line %d, column %d:", line, column)
+	if m.Offset == len(content) {
+		header = fmt.Sprintf("Answer ends at line %d, column %d, before the response format regex is complete:",
+			line, column)
+	}
+	if len(before) > mismatchBefore {
+		before = append([]rune("…"), before[len(before)-mismatchBefore:]...)
+	}
+	if len(after) > mismatchAfter {
+		after = append(after[:mismatchAfter], '…')
+	}
+	matched, rest := string(before), string(after)
+	if colored {
+		theme := color.CurrentTheme()
+		matched, rest = theme.Paint(color.Success, matched), theme.Paint(color.Error, rest)
+	}
+	if _, err := fmt.Fprintf(w, "%s\n  %s%s\n  %s^\n", header, matched, rest,
+		strings.Repeat(" ", len(before))); err != nil {
+		return fmt.Errorf("error writing format mismatch: %w", err)
+	}
+	return nil
+}
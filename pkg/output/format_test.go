@@ -0,0 +1,101 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchRegex(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		content     string
+		wantMatched bool
+		wantOffset  int
+	}{
+		{"unanchored match", `\d{3}-\d{4}`, "555-1234", true, 8},
+		{"anchored match", `^\d{3}-\d{4}$`, "555-1234", true, 8},
+		{"unanchored requires the whole answer", `\d{3}`, "1234", false, 3},
+		{"anchored requires the whole answer", `^\d{3}$`, "1234", false, 3},
+		{"leading text", `\d+`, "The answer is 42", false, 0},
+		{"diverges in the middle", `(yes|no): [a-z ]+\.`, "yes: it is 42.", false, 11},
+		{"ends too early", `[A-Z]{2}-\d{4}`, "AB-12", false, 5},
+		{"alternation backtracks", `abc|abd`, "abd", true, 3},
+		{"multi-line", `(?s)\w+\n\d+`, "name\n12x", false, 7},
+		{"word boundary", `\w+\b`, "hello", true, 5},
+		{"unicode", `é+!`, "ééx", false, 4},
+		{"empty answer", `a*`, "", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MatchRegex(tt.pattern, tt.content)
+			if err != nil {
+				t.Fatalf("MatchRegex() error = %v", err)
+			}
+			if got.Matched != tt.wantMatched || got.Offset != tt.wantOffset {
+				t.Errorf("MatchRegex(%q, %q) = %+v, want matched %v at %d",
+					tt.pattern, tt.content, got, tt.wantMatched, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestMatchRegex_Invalid(t *testing.T) {
+	for _, pattern := range []string{`a)(b`, `(?=a)b`} {
+		if _, err := MatchRegex(pattern, "ab"); err == nil {
+			t.Errorf("MatchRegex(%q) error = nil, want an error", pattern)
+		}
+	}
+}
+
+func TestWriteMismatch(t *testing.T) {
+	content := "first line\nyes: it is 42."
+	m, err := MatchRegex(`(?s)first line\n(yes|no): [a-z ]+\.`, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := WriteMismatch(&out, content, m, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "Answer does not match the response format regex at line 2, column 12:\n" +
+		"  yes: it is 42.\n" +
+		"             ^\n"
+	if out.String() != want {
+		t.Errorf("WriteMismatch() =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := WriteMismatch(&out, content, m, true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("WriteMismatch() with color has no escape sequence:\n%q", out.String())
+	}
+
+	out.Reset()
+	m, _ = MatchRegex(`[A-Z]{2}-\d{4}`, "AB-12")
+	_ = WriteMismatch(&out, "AB-12", m, false)
+	if !strings.HasPrefix(out.String(), "Answer ends at line 1, column 6") {
+		t.Errorf("WriteMismatch() of a short answer =\n%s", out.String())
+	}
+
+	out.Reset()
+	long := strings.Repeat("a", 100) + "!" + strings.Repeat("b", 100)
+	m, _ = MatchRegex(`a+`, long)
+	_ = WriteMismatch(&out, long, m, false)
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[1], "  …a") || !strings.HasSuffix(lines[1], "b…") {
+		t.Errorf("WriteMismatch() of a long line =\n%s", out.String())
+	}
+	if caret := strings.Index(lines[2], "^"); caret != len([]rune(lines[1][:strings.Index(lines[1], "!")])) {
+		t.Errorf("caret at %d, want under the mismatch:\n%s", caret, out.String())
+	}
+
+	out.Reset()
+	_ = WriteMismatch(&out, "ok", FormatMatch{Matched: true, Offset: 2}, false)
+	if out.Len() != 0 {
+		t.Errorf("WriteMismatch() of a match printed %q", out.String())
+	}
+}
@@ -0,0 +1,267 @@
+// Package output defines the stable JSON document that pplx emits for a
+// completion, shared by `pplx query --json` and the MCP tools.
+//
+// The field set is a contract with scripts: fields are never removed or
+// renamed, list fields are always present (empty lists encode as []), and
+// upstream API changes are absorbed here rather than leaking into the output.
+package output
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/citations"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/searches"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// Result is the stable JSON representation of a completion.
+type Result struct {
+	Content          string         `json:"content"`
+	Model            string         `json:"model"`
+	Usage            Usage          `json:"usage"`
+	Citations        []string       `json:"citations"`
+	SearchResults    []SearchResult `json:"search_results"`
+	Images           []Image        `json:"images"`
+	RelatedQuestions []string       `json:"related_questions"`
+	ElapsedMS        int64          `json:"elapsed_ms"`
+	RequestID        string         `json:"request_id"`
+	// Choices holds every candidate answer, in order, when more than one was
+	// requested (--choices); otherwise it is just Content.
+	Choices []string `json:"choices"`
+	// SearchQueries holds the web searches the API ran for the answer, when
+	// it reports them.
+	SearchQueries []string `json:"search_queries"`
+	// FormatMatch reports whether the answer matches the regex response
+	// format of the request; null when none was requested or it could not
+	// be checked.
+	FormatMatch *bool `json:"format_match"`
+	// Warnings holds the non-fatal issues met while answering, such as an
+	// image format the API may not support.
+	Warnings []warnings.Warning `json:"warnings"`
+	// ModelUsed is the model that answered: the requested one, or the
+	// fallback model it was sent to when the requested one was unavailable
+	// (defaults.model_fallbacks). Model is the name the API reports.
+	ModelUsed string `json:"model_used"`
+}
+
+// Usage reports token consumption for a completion.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// SearchResult is a source consulted for the answer.
+// Date and LastUpdated are empty when the API does not report them.
+type SearchResult struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Date        string `json:"date"`
+	LastUpdated string `json:"last_updated"`
+	// ExtraURLs are the URLs of the same domain a citations.Filter grouped
+	// beneath this result.
+	ExtraURLs []string `json:"extra_urls,omitempty"`
+}
+
+// Image is an image returned alongside the answer.
+type Image struct {
+	ImageURL  string `json:"image_url"`
+	OriginURL string `json:"origin_url"`
+	Height    int    `json:"height"`
+	Width     int    `json:"width"`
+}
+
+// fieldNames lists the top-level fields of Result in output order.
+var fieldNames = []string{
+	"content",
+	"model",
+	"usage",
+	"citations",
+	"search_results",
+	"images",
+	"related_questions",
+	"elapsed_ms",
+	"request_id",
+	"choices",
+	"search_queries",
+	"format_match",
+	"warnings",
+	"model_used",
+}
+
+//go:embed schema.json
+var schema []byte
+
+// Fields returns the top-level field names of Result in output order.
+func Fields() []string {
+	return slices.Clone(fieldNames)
+}
+
+// Schema returns the JSON Schema (draft 2020-12) of Result.
+func Schema() []byte {
+	return slices.Clone(schema)
+}
+
+// FromResponse converts an API response into a Result.
+// elapsed is the wall-clock time of the request, reported as elapsed_ms.
+func FromResponse(response *perplexity.CompletionResponse, elapsed time.Duration) *Result {
+	result := &Result{
+		Citations:        []string{},
+		SearchResults:    []SearchResult{},
+		Images:           []Image{},
+		RelatedQuestions: []string{},
+		ElapsedMS:        elapsed.Milliseconds(),
+		Choices:          []string{},
+		SearchQueries:    []string{},
+		Warnings:         []warnings.Warning{},
+	}
+	if response == nil {
+		return result
+	}
+
+	for _, choice := range response.Choices {
+		result.Choices = append(result.Choices, choice.Message.Content)
+	}
+	if len(result.Choices) > 0 {
+		result.Content = result.Choices[0]
+	}
+	result.Model = response.Model
+	result.ModelUsed = response.Model
+	result.RequestID = response.ID
+	result.Usage = Usage{
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		TotalTokens:      response.Usage.TotalTokens,
+	}
+
+	searchResults := response.GetSearchResults()
+	grouped := citations.Grouped(response.ID)
+	for i, sr := range searchResults {
+		result.SearchResults = append(result.SearchResults, SearchResult{
+			Title:       sr.Title,
+			URL:         sr.URL,
+			Date:        deref(sr.Date),
+			LastUpdated: deref(sr.LastUpdated),
+		})
+		if len(grouped) == len(searchResults) {
+			result.SearchResults[i].ExtraURLs = grouped[i]
+		}
+		result.Citations = append(result.Citations, sr.URL)
+	}
+	// Older responses carry only the deprecated citations list.
+	if len(result.Citations) == 0 && response.Citations != nil { //nolint:staticcheck // fallback for older API responses
+		result.Citations = append(result.Citations, *response.Citations...) //nolint:staticcheck // see above
+	}
+
+	for _, img := range response.GetImages() {
+		result.Images = append(result.Images, Image(img))
+	}
+	if response.RelatedQuestions != nil {
+		result.RelatedQuestions = append(result.RelatedQuestions, *response.RelatedQuestions...)
+	}
+	result.SearchQueries = append(result.SearchQueries, searches.Lookup(response.ID)...)
+
+	return result
+}
+
+// ParseFields parses a comma-separated --json-fields value.
+// An empty spec selects every field. Returns clerrors.ErrUnknownOutputField
+// for names that are not top-level fields of Result.
+func ParseFields(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for name := range strings.SplitSeq(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(fieldNames, name) {
+			return nil, fmt.Errorf("%w: %q (valid: %s)", clerrors.ErrUnknownOutputField,
+				name, strings.Join(fieldNames, ", "))
+		}
+		if !slices.Contains(fields, name) {
+			fields = append(fields, name)
+		}
+	}
+	return fields, nil
+}
+
+// Marshal encodes r as indented JSON. When fields is non-empty only those
+// top-level fields are included; fields keep Result's order either way.
+func (r *Result) Marshal(fields []string) ([]byte, error) {
+	full, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode output: %w", err)
+	}
+	if len(fields) > 0 {
+		if full, err = selectFields(full, fields); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, full, "", "  "); err != nil {
+		return nil, fmt.Errorf("failed to indent output: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Write encodes r as indented JSON followed by a newline.
+func (r *Result) Write(w io.Writer, fields []string) error {
+	data, err := r.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, string(data)); err != nil {
+		return fmt.Errorf("error writing JSON to output: %w", err)
+	}
+	return nil
+}
+
+// selectFields re-encodes the object in data keeping only fields, in
+// fieldNames order.
+func selectFields(data []byte, fields []string) ([]byte, error) {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to select output fields: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	for _, name := range fieldNames {
+		if !slices.Contains(fields, name) {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(values[name])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// deref returns *p, or "" when p is nil.
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
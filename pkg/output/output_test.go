@@ -0,0 +1,189 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/searches"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+func strPtr(s string) *string { return &s }
+
+func fullResponse() *perplexity.CompletionResponse {
+	searchResults := []perplexity.SearchResult{
+		{Title: "Go", URL: "https://go.dev", Date: strPtr("2024-01-02")},
+		{Title: "Wiki", URL: "https://wikipedia.org", LastUpdated: strPtr("2024-03-04")},
+	}
+	images := []perplexity.Image{{ImageURL: "https://img/a.png", OriginURL: "https://go.dev", Height: 10, Width: 20}}
+	related := []string{"What is Rust?"}
+	return &perplexity.CompletionResponse{
+		ID:    "req-123",
+		Model: "sonar",
+		Usage: perplexity.Usage{PromptTokens: 5, CompletionTokens: 7, TotalTokens: 12},
+		Choices: []perplexity.Choice{
+			{Message: perplexity.Message{Content: "Go is a language [1]."}},
+		},
+		SearchResults:    &searchResults,
+		Images:           &images,
+		RelatedQuestions: &related,
+	}
+}
+
+func TestFromResponse(t *testing.T) {
+	searches.Record("req-123", []string{"go language", "go history"})
+	got := FromResponse(fullResponse(), 1234*time.Millisecond)
+
+	want := &Result{
+		Content:   "Go is a language [1].",
+		Model:     "sonar",
+		Usage:     Usage{PromptTokens: 5, CompletionTokens: 7, TotalTokens: 12},
+		Citations: []string{"https://go.dev", "https://wikipedia.org"},
+		SearchResults: []SearchResult{
+			{Title: "Go", URL: "https://go.dev", Date: "2024-01-02"},
+			{Title: "Wiki", URL: "https://wikipedia.org", LastUpdated: "2024-03-04"},
+		},
+		Images:           []Image{{ImageURL: "https://img/a.png", OriginURL: "https://go.dev", Height: 10, Width: 20}},
+		RelatedQuestions: []string{"What is Rust?"},
+		ElapsedMS:        1234,
+		RequestID:        "req-123",
+		Choices:          []string{"Go is a language [1]."},
+		SearchQueries:    []string{"go language", "go history"},
+		Warnings:         []warnings.Warning{},
+		ModelUsed:        "sonar",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromResponse() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestFromResponse_DeprecatedCitations(t *testing.T) {
+	citations := []string{"https://a.example", "https://b.example"}
+	resp := &perplexity.CompletionResponse{Citations: &citations}
+
+	got := FromResponse(resp, 0)
+	if !slices.Equal(got.Citations, citations) {
+		t.Errorf("Citations = %v, want %v", got.Citations, citations)
+	}
+}
+
+func TestMarshal_EmptyListsAreArrays(t *testing.T) {
+	for name, resp := range map[string]*perplexity.CompletionResponse{
+		"nil response":   nil,
+		"no extras":      {Model: "sonar", Choices: []perplexity.Choice{{}}},
+		"empty pointers": {SearchResults: &[]perplexity.SearchResult{}, RelatedQuestions: &[]string{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := FromResponse(resp, 0).Marshal(nil)
+			if err != nil {
+				t.Fatalf("Marshal() error: %v", err)
+			}
+			var payload map[string]any
+			if err := json.Unmarshal(data, &payload); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if len(payload) != len(Fields()) {
+				t.Errorf("got %d fields, want %d: %s", len(payload), len(Fields()), data)
+			}
+			for _, key := range []string{"citations", "search_results", "images", "related_questions", "search_queries", "warnings"} {
+				if list, ok := payload[key].([]any); !ok || len(list) != 0 {
+					t.Errorf("%s = %v, want []", key, payload[key])
+				}
+			}
+		})
+	}
+}
+
+func TestMarshal_SelectedFields(t *testing.T) {
+	result := FromResponse(fullResponse(), time.Second)
+
+	// Requested order does not matter; output follows Result's field order.
+	data, err := result.Marshal([]string{"usage", "content"})
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+
+	want := `{
+  "content": "Go is a language [1].",
+  "usage": {
+    "prompt_tokens": 5,
+    "completion_tokens": 7,
+    "total_tokens": 12
+  }
+}`
+	if string(data) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty selects all", spec: "", want: nil},
+		{name: "single", spec: "content", want: []string{"content"}},
+		{name: "spaces and duplicates", spec: " content , usage,content,", want: []string{"content", "usage"}},
+		{name: "unknown", spec: "content,answer", wantErr: true},
+		{name: "nested path not supported", spec: "usage.prompt_tokens", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFields(tt.spec)
+			if tt.wantErr {
+				if !errors.Is(err, clerrors.ErrUnknownOutputField) {
+					t.Fatalf("ParseFields(%q) error = %v, want ErrUnknownOutputField", tt.spec, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFields(%q) unexpected error: %v", tt.spec, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseFields(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSchemaMatchesResult keeps schema.json in sync with the Result struct.
+func TestSchemaMatchesResult(t *testing.T) {
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+	}
+	if err := json.Unmarshal(Schema(), &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	var tags []string
+	resultType := reflect.TypeFor[Result]()
+	for i := range resultType.NumField() {
+		tags = append(tags, strings.Split(resultType.Field(i).Tag.Get("json"), ",")[0])
+	}
+
+	if !slices.Equal(tags, Fields()) {
+		t.Errorf("Result json tags %v do not match Fields() %v", tags, Fields())
+	}
+	if !slices.Equal(schema.Required, Fields()) {
+		t.Errorf("schema required %v, want %v", schema.Required, Fields())
+	}
+	for _, name := range Fields() {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("schema is missing property %q", name)
+		}
+	}
+	if len(schema.Properties) != len(Fields()) {
+		t.Errorf("schema has %d properties, want %d", len(schema.Properties), len(Fields()))
+	}
+}
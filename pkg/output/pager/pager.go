@@ -0,0 +1,85 @@
+// Package pager shows long answers through a pager such as less, and
+// truncates them to a number of lines.
+package pager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/logger"
+)
+
+// DefaultCommand is the pager run when neither output.pager nor $PAGER is
+// set. -R lets the colors of rendered markdown through.
+const DefaultCommand = "less -R"
+
+// Command returns the pager command line: configured, the output.pager
+// setting, when set, else $PAGER, else DefaultCommand.
+func Command(configured string, getenv func(string) string) string {
+	if command := strings.TrimSpace(configured); command != "" {
+		return command
+	}
+	if command := strings.TrimSpace(getenv("PAGER")); command != "" {
+		return command
+	}
+	return DefaultCommand
+}
+
+// Lines returns the number of lines of text. A final newline does not start
+// another line.
+func Lines(text string) int {
+	if text == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(text, "\n"), "\n") + 1
+}
+
+// Truncate returns the first maxLines lines of text and how many lines were
+// cut. A maxLines of zero or less keeps the whole text.
+func Truncate(text string, maxLines int) (string, int) {
+	total := Lines(text)
+	if maxLines <= 0 || total <= maxLines {
+		return text, 0
+	}
+	end := 0
+	for range maxLines {
+		end += strings.IndexByte(text[end:], '\n') + 1
+	}
+	return text[:end], total - maxLines
+}
+
+// Page runs command, a program and its arguments separated by spaces, with
+// text on its standard input and out and errOut as its output. When the
+// pager cannot be started, text is written to out instead, so the answer is
+// never lost to a wrong $PAGER. A pager that exits with an error, such as
+// less interrupted by Ctrl-C, is not reported since the user has seen the
+// text.
+func Page(ctx context.Context, command, text string, out, errOut io.Writer) error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return write(out, text)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec // the pager is chosen by the user
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = out
+	cmd.Stderr = errOut
+	if err := cmd.Start(); err != nil {
+		logger.Warn("pager not started, printing the answer directly", "pager", command, "error", err)
+		return write(out, text)
+	}
+	if err := cmd.Wait(); err != nil {
+		logger.Debug("pager exited with an error", "pager", command, "error", err)
+	}
+	return nil
+}
+
+// write writes text to out.
+func write(out io.Writer, text string) error {
+	if _, err := io.WriteString(out, text); err != nil {
+		return fmt.Errorf("failed to write answer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package pager
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	env := func(pager string) func(string) string {
+		return func(key string) string {
+			if key == "PAGER" {
+				return pager
+			}
+			return ""
+		}
+	}
+	tests := []struct {
+		name       string
+		configured string
+		env        string
+		want       string
+	}{
+		{"default", "", "", DefaultCommand},
+		{"environment", "", "more", "more"},
+		{"config over environment", "most -s", "more", "most -s"},
+		{"blank config", "  ", "more", "more"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Command(tt.configured, env(tt.env)); got != tt.want {
+				t.Errorf("Command() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxLines int
+		want     string
+		wantCut  int
+	}{
+		{"no limit", "a\nb\nc\n", 0, "a\nb\nc\n", 0},
+		{"under the limit", "a\nb\n", 3, "a\nb\n", 0},
+		{"at the limit", "a\nb\nc", 3, "a\nb\nc", 0},
+		{"over the limit", "a\nb\nc\nd\n", 2, "a\nb\n", 2},
+		{"no final newline", "a\nb\nc", 1, "a\n", 2},
+		{"empty", "", 1, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cut := Truncate(tt.text, tt.maxLines)
+			if got != tt.want || cut != tt.wantCut {
+				t.Errorf("Truncate() = %q, %d; want %q, %d", got, cut, tt.want, tt.wantCut)
+			}
+		})
+	}
+}
+
+// fakePager writes a pager script that records its arguments and input in
+// the returned files.
+func fakePager(t *testing.T) (command, argsFile, inputFile string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake pager is a shell script")
+	}
+	dir := t.TempDir()
+	argsFile, inputFile = filepath.Join(dir, "args"), filepath.Join(dir, "input")
+	script := filepath.Join(dir, "pager")
+	body := "#!/bin/sh\necho \"$@\" > " + argsFile + "\ncat > " + inputFile + "\n"
+	if err := os.WriteFile(script, []byte(body), 0o700); err != nil { //nolint:gosec // the script must be executable
+		t.Fatal(err)
+	}
+	return script + " -R", argsFile, inputFile
+}
+
+func TestPage(t *testing.T) {
+	command, argsFile, inputFile := fakePager(t)
+	text := "line 1\nline 2\n"
+
+	var out bytes.Buffer
+	if err := Page(context.Background(), command, text, &out, &out); err != nil {
+		t.Fatalf("Page() error = %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("out = %q, want the text to go to the pager only", out.String())
+	}
+	if got, _ := os.ReadFile(inputFile); string(got) != text {
+		t.Errorf("pager input = %q, want %q", got, text)
+	}
+	if got, _ := os.ReadFile(argsFile); string(got) != "-R\n" {
+		t.Errorf("pager arguments = %q, want -R", got)
+	}
+}
+
+func TestPage_MissingPager(t *testing.T) {
+	var out bytes.Buffer
+	missing := filepath.Join(t.TempDir(), "no-such-pager")
+	if err := Page(context.Background(), missing, "answer\n", &out, &out); err != nil {
+		t.Fatalf("Page() error = %v", err)
+	}
+	if out.String() != "answer\n" {
+		t.Errorf("out = %q, want the text printed directly", out.String())
+	}
+}
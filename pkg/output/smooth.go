@@ -0,0 +1,210 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// StreamStyle is how a streamed answer is written as it arrives.
+type StreamStyle string
+
+// Stream styles.
+const (
+	// StreamInstant writes each piece of the answer as soon as it arrives.
+	StreamInstant StreamStyle = "instant"
+	// StreamSmooth writes the answer word by word at a steady rate.
+	StreamSmooth StreamStyle = "smooth"
+)
+
+const (
+	// DefaultStreamCPS is the rate of smooth streaming, in characters per
+	// second, when output.stream_cps is not set.
+	DefaultStreamCPS = 120
+	// MaxStreamLag is how far behind the stream smooth streaming may fall:
+	// when the text waiting to be written would take longer than this, it is
+	// written at once.
+	MaxStreamLag = 3 * time.Second
+)
+
+// StreamStyles returns the supported stream style names.
+func StreamStyles() []string {
+	return []string{string(StreamInstant), string(StreamSmooth)}
+}
+
+// ParseStreamStyle validates a stream style name; empty selects
+// StreamInstant. Returns clerrors.ErrInvalidStreamStyle for unknown styles.
+func ParseStreamStyle(s string) (StreamStyle, error) {
+	switch style := StreamStyle(strings.ToLower(strings.TrimSpace(s))); style {
+	case "":
+		return StreamInstant, nil
+	case StreamInstant, StreamSmooth:
+		return style, nil
+	default:
+		return "", fmt.Errorf("%w: %q. Must be one of: %s",
+			clerrors.ErrInvalidStreamStyle, s, strings.Join(StreamStyles(), ", "))
+	}
+}
+
+// SmoothWriter writes text to an underlying writer word by word at a steady
+// number of characters per second, for a typewriter effect on bursty
+// streams. Write only queues the text; a goroutine writes it out. When the
+// queue would take longer than MaxStreamLag to write, it is written at once,
+// so the output never falls far behind the stream.
+//
+// Close must be called once the stream ends: it writes the rest of the
+// queue at the same pace and stops the goroutine.
+type SmoothWriter struct {
+	out io.Writer
+	// perChar is the time one character takes.
+	perChar time.Duration
+	// maxPending is the queue length, in bytes, written at once.
+	maxPending int
+	sleep      func(time.Duration)
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []byte
+	closed  bool
+	err     error
+	done    chan struct{}
+}
+
+// NewSmoothWriter starts a SmoothWriter writing cps characters per second
+// to out. A cps of zero or less selects DefaultStreamCPS.
+func NewSmoothWriter(out io.Writer, cps int) *SmoothWriter {
+	return newSmoothWriter(out, cps, time.Sleep)
+}
+
+// newSmoothWriter is NewSmoothWriter pausing between words with sleep.
+func newSmoothWriter(out io.Writer, cps int, sleep func(time.Duration)) *SmoothWriter {
+	if cps <= 0 {
+		cps = DefaultStreamCPS
+	}
+	w := &SmoothWriter{
+		out:        out,
+		perChar:    time.Second / time.Duration(cps),
+		maxPending: cps * int(MaxStreamLag/time.Second),
+		sleep:      sleep,
+		done:       make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// Write queues p. It returns the error of an earlier write to the
+// underlying writer, if any.
+func (w *SmoothWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	w.pending = append(w.pending, p...)
+	w.cond.Signal()
+	return len(p), nil
+}
+
+// Flush writes the queued text at once, as when the stream is interrupted
+// and pacing the rest would only delay the user.
+func (w *SmoothWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeLocked(len(w.pending))
+	return w.err
+}
+
+// Close writes the rest of the queue at the usual pace and stops the
+// writer. It returns the first error of the underlying writer.
+func (w *SmoothWriter) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Signal()
+	w.mu.Unlock()
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// run writes the queue a word at a time until the writer is closed.
+func (w *SmoothWriter) run() {
+	defer close(w.done)
+	for {
+		w.mu.Lock()
+		for len(w.pending) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.pending) == 0 {
+			w.mu.Unlock()
+			return
+		}
+		n := len(w.pending)
+		catchUp := n > w.maxPending
+		if !catchUp {
+			n = nextWord(w.pending)
+		}
+		chars := utf8.RuneCount(w.pending[:n])
+		w.writeLocked(n)
+		w.mu.Unlock()
+
+		if !catchUp {
+			w.sleep(time.Duration(chars) * w.perChar)
+		}
+	}
+}
+
+// writeLocked writes the first n bytes of the queue. After a write error
+// the queue is dropped. w.mu must be held.
+func (w *SmoothWriter) writeLocked(n int) {
+	if n == 0 || w.err != nil {
+		return
+	}
+	if _, err := w.out.Write(w.pending[:n]); err != nil {
+		w.err = fmt.Errorf("error writing streaming content to output: %w", err)
+		w.pending = nil
+		return
+	}
+	w.pending = w.pending[n:]
+}
+
+// nextWord returns the length of the first word of p with the spaces around
+// it: leading spaces, the word, and the spaces after it. A word at the end of
+// p is returned whole even if more of it may follow, so that a slow stream
+// is not held back.
+func nextWord(p []byte) int {
+	i := 0
+	for i < len(p) {
+		r, size := utf8.DecodeRune(p[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	for i < len(p) {
+		r, size := utf8.DecodeRune(p[i:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	for i < len(p) {
+		r, size := utf8.DecodeRune(p[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
@@ -0,0 +1,184 @@
+package output
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// fakeClock records the pauses of a SmoothWriter instead of sleeping.
+type fakeClock struct {
+	mu      sync.Mutex
+	elapsed time.Duration
+	pauses  []time.Duration
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.elapsed += d
+	c.pauses = append(c.pauses, d)
+}
+
+// writeRecorder records every write it receives.
+type writeRecorder struct {
+	writes []string
+	err    error
+}
+
+func (r *writeRecorder) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	r.writes = append(r.writes, string(p))
+	return len(p), nil
+}
+
+func TestParseStreamStyle(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    StreamStyle
+		wantErr bool
+	}{
+		{in: "", want: StreamInstant},
+		{in: "instant", want: StreamInstant},
+		{in: " Smooth ", want: StreamSmooth},
+		{in: "typewriter", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseStreamStyle(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, clerrors.ErrInvalidStreamStyle) {
+					t.Errorf("ParseStreamStyle(%q) error = %v, want ErrInvalidStreamStyle", tt.in, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseStreamStyle(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSmoothWriter_WordByWord(t *testing.T) {
+	clock := &fakeClock{}
+	out := &writeRecorder{}
+	w := newSmoothWriter(out, 10, clock.sleep)
+
+	if _, err := w.Write([]byte("Hello brave  new\nwörld")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := []string{"Hello ", "brave  ", "new\n", "wörld"}
+	if !slices.Equal(out.writes, want) {
+		t.Errorf("writes = %q, want %q", out.writes, want)
+	}
+	// 22 characters at 10 per second; the ö counts once.
+	if clock.elapsed != 2200*time.Millisecond {
+		t.Errorf("elapsed = %v, want 2.2s", clock.elapsed)
+	}
+	if clock.pauses[0] != 600*time.Millisecond {
+		t.Errorf("first pause = %v, want 600ms for 6 characters", clock.pauses[0])
+	}
+}
+
+func TestSmoothWriter_CatchesUp(t *testing.T) {
+	clock := &fakeClock{}
+	out := &writeRecorder{}
+	w := newSmoothWriter(out, 10, clock.sleep)
+
+	// 10 characters per second may lag 3 seconds: 30 characters.
+	text := strings.Repeat("word ", 10)
+	if _, err := w.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if len(out.writes) != 1 || out.writes[0] != text {
+		t.Errorf("writes = %q, want the whole text at once", out.writes)
+	}
+	if clock.elapsed != 0 {
+		t.Errorf("elapsed = %v, want no pause when catching up", clock.elapsed)
+	}
+}
+
+func TestSmoothWriter_Flush(t *testing.T) {
+	// The clock blocks the writer after its first word until released.
+	release := make(chan struct{})
+	var once sync.Once
+	out := &writeRecorder{}
+	w := newSmoothWriter(out, 10, func(time.Duration) {
+		once.Do(func() { <-release })
+	})
+
+	if _, err := w.Write([]byte("one two three")); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the first word, written under w.mu, then flush the rest.
+	for {
+		w.mu.Lock()
+		n := len(out.writes)
+		w.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	close(release)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := []string{"one ", "two three"}
+	if !slices.Equal(out.writes, want) {
+		t.Errorf("writes = %q, want %q", out.writes, want)
+	}
+}
+
+func TestSmoothWriter_WriteError(t *testing.T) {
+	out := &writeRecorder{err: errors.New("broken pipe")}
+	w := newSmoothWriter(out, 10, (&fakeClock{}).sleep)
+
+	if _, err := w.Write([]byte("lost")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil {
+		t.Fatal("Close() error = nil, want the write error")
+	}
+	if _, err := w.Write([]byte("more")); err == nil {
+		t.Error("Write() after a failed write succeeded")
+	}
+}
+
+func TestNextWord(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: ""},
+		{in: "word", want: "word"},
+		{in: "word rest", want: "word "},
+		{in: "  lead tail", want: "  lead "},
+		{in: "line\n\nnext", want: "line\n\n"},
+		{in: "\x1b[1mbold\x1b[0m text", want: "\x1b[1mbold\x1b[0m "},
+	}
+	for _, tt := range tests {
+		if got := tt.in[:nextWord([]byte(tt.in))]; got != tt.want {
+			t.Errorf("nextWord(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package pplx_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/pplx"
+	"github.com/sgaunet/pplx/pkg/usage"
+)
+
+// exampleAPI starts a stand-in for the Perplexity API answering every
+// request with the same completion.
+func exampleAPI() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id": "1", "model": "sonar", "choices": [{"index": 0,
+			"message": {"role": "assistant", "content": "Go was released in 2009."}}]}`)
+	}))
+}
+
+// exampleUsage returns the usage log of the examples, in a temporary
+// directory rather than the usage log of the user.
+func exampleUsage() *usage.Tracker {
+	dir, _ := os.MkdirTemp("", "pplx-example")
+	return usage.NewTracker(filepath.Join(dir, "usage.jsonl"))
+}
+
+func ExampleClient_Query() {
+	api := exampleAPI()
+	defer api.Close()
+
+	cfg := config.NewConfigData()
+	cfg.Defaults.Model = "sonar"
+	cfg.Search.Recency = "month"
+
+	client, err := pplx.New(cfg,
+		pplx.WithAPIKey("pplx-example"),
+		pplx.WithEndpoint(api.URL),
+		pplx.WithUsage("example", exampleUsage()))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	res, err := client.Query(context.Background(), pplx.QueryRequest{Prompt: "When was Go released?"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(res.Model+":", res.Content)
+	// Output: sonar: Go was released in 2009.
+}
+
+func ExampleClient_Stream() {
+	api := exampleAPI()
+	defer api.Close()
+
+	client, err := pplx.New(nil,
+		pplx.WithAPIKey("pplx-example"),
+		pplx.WithEndpoint(api.URL),
+		pplx.WithUsage("example", exampleUsage()))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	err = client.Stream(context.Background(), pplx.QueryRequest{Prompt: "When was Go released?"},
+		func(d pplx.Delta) {
+			if d.Reset {
+				fmt.Print("\r")
+			}
+			fmt.Print(d.Text)
+			if d.Done {
+				fmt.Println()
+			}
+		})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func ExampleQueryRequest_Validate() {
+	r := pplx.QueryRequest{Prompt: "What changed in Go?", SearchRecency: "decade"}
+	fmt.Println(r.Validate())
+	// Output: validation failed for search_recency=decade: must be one of: hour, day, week, month, year
+}
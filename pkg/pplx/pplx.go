@@ -0,0 +1,401 @@
+// Package pplx is the library face of pplx, for Go programs that want its
+// option merging, validation, retries and usage tracking without running
+// the CLI.
+//
+// A Client is created from a config, usually loaded with the config
+// package, and sends QueryRequests:
+//
+//	client, err := pplx.New(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	res, err := client.Query(ctx, pplx.QueryRequest{Prompt: "What is Go?"})
+//
+// Options the request leaves unset take the defaults, search and output
+// settings of the config, as they do for the CLI and the MCP server. Requests
+// are checked before anything is sent: invalid options are a
+// *clerrors.ValidationError naming the option. Failed calls are retried on
+// 429 and 5xx responses, and every answer is recorded in the usage log that
+// pplx usage reports.
+//
+// BuildRequest builds the completion request of a QueryRequest without
+// sending it, and Send sends a request through the fallback models; the
+// query command, the MCP server and chat sessions build and send theirs
+// with them.
+package pplx
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/render"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// apiKeyEnv is the environment variable holding the API key, as for the CLI.
+const apiKeyEnv = "PPLX_API_KEY"
+
+// Client sends queries to the Perplexity API. It is safe for concurrent use.
+type Client struct {
+	apiKey   string
+	endpoint string
+	policy   retry.Policy
+	// timeouts holds the connect and response-header limits, the proxy
+	// and TLS settings; the total timeout is the Timeout of each request.
+	timeouts retry.Timeouts
+	// defaults fill the zero fields of every request.
+	defaults QueryRequest
+	aliases  map[string]string
+	// strictModel rejects models missing from the model registry instead
+	// of warning about them.
+	strictModel bool
+	// source is the source recorded in the usage log.
+	source string
+	// tracker holds the usage log; nil is the default usage log.
+	tracker *usage.Tracker
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIKey sets the API key, instead of PPLX_API_KEY or api.key.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithEndpoint sends requests to the chat completions URL of the API at
+// baseURL, such as a compatible gateway, instead of api.base_url.
+func WithEndpoint(baseURL string) Option {
+	return func(c *Client) {
+		c.endpoint = config.EndpointURL(baseURL)
+	}
+}
+
+// WithRetryPolicy replaces the retry policy of api.max_retries and
+// api.retry_backoff.
+func WithRetryPolicy(p retry.Policy) Option {
+	return func(c *Client) {
+		c.policy = p
+	}
+}
+
+// WithStrictModel rejects models missing from the model registry with a
+// validation error instead of a warning, like --strict-model.
+func WithStrictModel() Option {
+	return func(c *Client) {
+		c.strictModel = true
+	}
+}
+
+// WithUsage records the usage of every answer under source in tracker. A
+// nil tracker keeps the default usage log. The default source is
+// usage.SourceLibrary.
+func WithUsage(source string, tracker *usage.Tracker) Option {
+	return func(c *Client) {
+		c.source = source
+		c.tracker = tracker
+	}
+}
+
+// New returns a client set up from cfg: the API key (PPLX_API_KEY, else
+// api.key), endpoint, proxy, TLS, timeouts and retries of the api section,
+// the model aliases, and the query defaults of the defaults, search and
+// output sections. A nil cfg uses the built-in defaults. opts are applied
+// last. Invalid URLs and TLS files are a validation or config error, and a
+// missing API key a *clerrors.ConfigError.
+func New(cfg *config.ConfigData, opts ...Option) (*Client, error) {
+	if cfg == nil {
+		cfg = config.NewConfigData()
+	}
+	if err := config.ValidateHTTPURL("api.base_url", cfg.API.BaseURL); err != nil {
+		return nil, err //nolint:wrapcheck // names the offending value
+	}
+	proxy, err := config.ParseProxyURL(cfg.API.ProxyURL)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // names the offending value
+	}
+	tlsConf, err := config.LoadTLSConfig(cfg.API.TLS)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // names the offending option
+	}
+
+	maxRetries := retry.DefaultMaxRetries
+	if cfg.API.MaxRetries > 0 {
+		maxRetries = cfg.API.MaxRetries
+	}
+	c := &Client{
+		endpoint: config.EndpointURL(cfg.API.BaseURL),
+		policy:   retry.NewPolicy(maxRetries, cfg.API.RetryBackoff),
+		timeouts: retry.Timeouts{
+			Connect:        cfg.API.ConnectTimeout,
+			ResponseHeader: cfg.API.ResponseHeaderTimeout,
+			Proxy:          proxy,
+			TLS:            tlsConf,
+		},
+		defaults: Defaults(cfg),
+		aliases:  cfg.Models.Aliases,
+		source:   usage.SourceLibrary,
+	}
+	if key := os.Getenv(apiKeyEnv); key != "" {
+		c.apiKey = key
+	} else if !config.IsKeyringRef(cfg.API.Key) {
+		c.apiKey = cfg.API.Key
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.apiKey == "" {
+		return nil, clerrors.NewConfigError(
+			"no API key: set PPLX_API_KEY or api.key, or use pplx.WithAPIKey", nil)
+	}
+	return c, nil
+}
+
+// Defaults returns the query defaults of cfg: the model, sampling and
+// timeout of the defaults section, the search section, and the output
+// options that shape requests. Unset sampling options and timeout take the
+// defaults of the perplexity-go library.
+func Defaults(cfg *config.ConfigData) QueryRequest {
+	d := QueryRequest{
+		Model:            cmp.Or(cfg.Defaults.Model, perplexity.DefaultModel),
+		ModelFallbacks:   cfg.Defaults.ModelFallbacks,
+		FrequencyPenalty: cmp.Or(cfg.Defaults.FrequencyPenalty, perplexity.DefaultFrequencyPenalty),
+		MaxTokens:        cfg.Defaults.MaxTokens,
+		PresencePenalty:  cmp.Or(cfg.Defaults.PresencePenalty, perplexity.DefaultPresencePenalty),
+		Temperature:      cmp.Or(cfg.Defaults.Temperature, perplexity.DefaultTemperature),
+		TopK:             cmp.Or(cfg.Defaults.TopK, perplexity.DefaultTopK),
+		TopP:             cmp.Or(cfg.Defaults.TopP, perplexity.DefaultTopP),
+		Timeout:          cmp.Or(cfg.API.TotalTimeout, perplexity.DefaultTimeout),
+		Language:         cfg.Defaults.Language,
+
+		SearchDomains:     cfg.Search.Domains,
+		ExcludeDomains:    cfg.Search.ExcludeDomains,
+		SearchRecency:     cfg.Search.Recency,
+		LocationLat:       cfg.Search.LocationLat,
+		LocationLon:       cfg.Search.LocationLon,
+		LocationCountry:   cfg.Search.LocationCountry,
+		SearchMode:        cfg.Search.Mode,
+		SearchContextSize: cfg.Search.ContextSize,
+		SearchAfterDate:   cfg.Search.AfterDate,
+		SearchBeforeDate:  cfg.Search.BeforeDate,
+		LastUpdatedAfter:  cfg.Search.LastUpdatedAfter,
+		LastUpdatedBefore: cfg.Search.LastUpdatedBefore,
+
+		ReturnImages:             cfg.Output.ReturnImages,
+		ReturnRelated:            cfg.Output.ReturnRelated,
+		ImageDomains:             cfg.Output.ImageDomains,
+		ImageFormats:             cfg.Output.ImageFormats,
+		ResponseFormatJSONSchema: cfg.Output.ResponseFormatJSONSchema,
+		ResponseFormatRegex:      cfg.Output.ResponseFormatRegex,
+		ReasoningEffort:          cfg.Output.ReasoningEffort,
+		ImageConflictPolicy:      cfg.Output.ImageConflictPolicy,
+		StrictOptions:            cfg.Output.StrictOptions,
+	}
+	if timeout, err := time.ParseDuration(cfg.Defaults.Timeout); err == nil && timeout > 0 {
+		d.Timeout = timeout
+	}
+	return d
+}
+
+// QueryResult is the answer to a query.
+type QueryResult struct {
+	// Response is the response of the API, with the sources, images,
+	// related questions and usage of the answer.
+	Response *perplexity.CompletionResponse
+	// Content is the answer.
+	Content string
+	// Model is the model that answered; it differs from the request when
+	// a fallback model answered.
+	Model string
+	// Warnings are the non-fatal issues of the request, such as an option
+	// dropped because the API cannot combine it with another.
+	Warnings []warnings.Warning
+}
+
+// Delta is a piece of a streamed answer.
+type Delta struct {
+	// Text is the answer added since the previous Delta. When the API
+	// revised text it had already sent, Reset is set and Text is the whole
+	// answer, which replaces what was received before.
+	Text  string
+	Reset bool
+	// Content is the whole answer so far.
+	Content string
+	// Done is set on the last Delta, whose Result holds the complete
+	// answer.
+	Done   bool
+	Result *QueryResult
+}
+
+// Query sends r and returns the answer. The zero fields of r take the
+// defaults of the client, and the model is expanded if it is an alias. A
+// streamed request is read to its end. Cancelling ctx aborts the request.
+func (c *Client) Query(ctx context.Context, r QueryRequest) (QueryResult, error) {
+	return c.send(ctx, r, nil)
+}
+
+// Stream sends r as a streamed request and passes the answer to fn as it
+// arrives, ending with a Delta whose Done is set. fn is called from the
+// goroutine of Stream. A response format turns streaming off, as the API
+// ignores formats in streamed answers: fn then receives the whole answer
+// at once.
+func (c *Client) Stream(ctx context.Context, r QueryRequest, fn func(Delta)) error {
+	r.Stream = true
+	var streamed string
+	res, err := c.send(ctx, r, func(d Delta) {
+		streamed = d.Content
+		fn(d)
+	})
+	if err != nil {
+		return err
+	}
+	// An answer that was not streamed, or not to its end, is passed whole.
+	if streamed != res.Content {
+		text, ok := render.Delta(streamed, res.Content)
+		if !ok {
+			text = res.Content
+		}
+		fn(Delta{Text: text, Reset: !ok, Content: res.Content})
+	}
+	fn(Delta{Content: res.Content, Done: true, Result: &res})
+	return nil
+}
+
+// send builds r with the defaults of the client and sends it, through the
+// fallback models, with a client for its timeout. A streamed answer is
+// passed to fn.
+func (c *Client) send(ctx context.Context, r QueryRequest, fn func(Delta)) (QueryResult, error) {
+	r = c.withDefaults(r)
+	warns := warnings.New()
+	if err := models.Check(r.Model); err != nil {
+		if c.strictModel {
+			return QueryResult{}, clerrors.NewValidationError("model", r.Model, err.Error())
+		}
+		warns.Add(warnings.CodeUnknownModel, err.Error(), "run pplx models to list the known models")
+	}
+	req, err := BuildRequest(r, warns)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	client := perplexity.NewClient(c.apiKey)
+	if c.endpoint != "" {
+		client.SetEndpoint(c.endpoint)
+	}
+	timeouts := c.timeouts
+	timeouts.Total = r.Timeout
+	retry.Configure(client, c.policy, timeouts)
+
+	// Each event of a streamed answer is passed on as the text it adds.
+	var onEvent func(*perplexity.CompletionResponse)
+	if fn != nil {
+		var answer string
+		onEvent = func(event *perplexity.CompletionResponse) {
+			content := event.GetLastContent()
+			if content == answer {
+				return
+			}
+			text, ok := render.Delta(answer, content)
+			answer = content
+			if !ok {
+				fn(Delta{Text: content, Reset: true, Content: content})
+				return
+			}
+			fn(Delta{Text: text, Content: content})
+		}
+	}
+
+	start := time.Now()
+	result, err := Send(ctx, client, req, SendOptions{
+		Fallbacks: r.ModelFallbacks,
+		OnEvent:   onEvent,
+		Source:    c.source,
+		Tracker:   c.tracker,
+	})
+	if err != nil {
+		logger.Debug("query failed", "model", req.Model, "duration", time.Since(start), "error", err)
+		return QueryResult{}, clerrors.NewAPIError("request failed", retry.ClassifyTimeout(err))
+	}
+	if result.FellBack() {
+		warns.Add(warnings.CodeModelFallback, result.Note(), "")
+	}
+
+	return QueryResult{
+		Response: result.Response,
+		Content:  result.Response.GetLastContent(),
+		Model:    result.Model,
+		Warnings: warns.All(),
+	}, nil
+}
+
+// withDefaults returns r with its zero fields taken from the defaults of
+// the client and its models expanded if they are aliases. Booleans set in
+// the config cannot be turned off per request.
+//
+//nolint:cyclop // one fallback per field
+func (c *Client) withDefaults(r QueryRequest) QueryRequest {
+	d := c.defaults
+	r.Model = models.Resolve(cmp.Or(r.Model, d.Model), c.aliases)
+	fallbacks := r.ModelFallbacks
+	if fallbacks == nil {
+		fallbacks = d.ModelFallbacks
+	}
+	r.ModelFallbacks = nil
+	for _, m := range fallbacks {
+		r.ModelFallbacks = append(r.ModelFallbacks, models.Resolve(strings.TrimSpace(m), c.aliases))
+	}
+	r.FrequencyPenalty = cmp.Or(r.FrequencyPenalty, d.FrequencyPenalty)
+	r.MaxTokens = cmp.Or(r.MaxTokens, d.MaxTokens)
+	r.PresencePenalty = cmp.Or(r.PresencePenalty, d.PresencePenalty)
+	r.Temperature = cmp.Or(r.Temperature, d.Temperature)
+	r.TopK = cmp.Or(r.TopK, d.TopK)
+	r.TopP = cmp.Or(r.TopP, d.TopP)
+	r.Timeout = cmp.Or(r.Timeout, d.Timeout)
+	r.Language = cmp.Or(r.Language, d.Language)
+
+	if r.SearchDomains == nil {
+		r.SearchDomains = d.SearchDomains
+	}
+	if r.ExcludeDomains == nil {
+		r.ExcludeDomains = d.ExcludeDomains
+	}
+	r.SearchRecency = cmp.Or(r.SearchRecency, d.SearchRecency)
+	if r.LocationLat == 0 && r.LocationLon == 0 && r.LocationCountry == "" {
+		r.LocationLat, r.LocationLon, r.LocationCountry = d.LocationLat, d.LocationLon, d.LocationCountry
+	}
+	r.SearchMode = cmp.Or(r.SearchMode, d.SearchMode)
+	r.SearchContextSize = cmp.Or(r.SearchContextSize, d.SearchContextSize)
+	r.SearchAfterDate = cmp.Or(r.SearchAfterDate, d.SearchAfterDate)
+	r.SearchBeforeDate = cmp.Or(r.SearchBeforeDate, d.SearchBeforeDate)
+	r.LastUpdatedAfter = cmp.Or(r.LastUpdatedAfter, d.LastUpdatedAfter)
+	r.LastUpdatedBefore = cmp.Or(r.LastUpdatedBefore, d.LastUpdatedBefore)
+
+	r.ReturnImages = r.ReturnImages || d.ReturnImages
+	r.ReturnRelated = r.ReturnRelated || d.ReturnRelated
+	if r.ImageDomains == nil {
+		r.ImageDomains = d.ImageDomains
+	}
+	if r.ImageFormats == nil {
+		r.ImageFormats = d.ImageFormats
+	}
+	// A request with a format of its own keeps it alone.
+	if r.ResponseFormatJSONSchema == "" && r.ResponseFormatRegex == "" {
+		r.ResponseFormatJSONSchema, r.ResponseFormatRegex = d.ResponseFormatJSONSchema, d.ResponseFormatRegex
+	}
+	r.ReasoningEffort = cmp.Or(r.ReasoningEffort, d.ReasoningEffort)
+	r.ImageConflictPolicy = cmp.Or(r.ImageConflictPolicy, d.ImageConflictPolicy)
+	r.StrictOptions = r.StrictOptions || d.StrictOptions
+	return r
+}
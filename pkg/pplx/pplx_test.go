@@ -0,0 +1,295 @@
+package pplx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/config"
+	"github.com/sgaunet/pplx/pkg/retry"
+	"github.com/sgaunet/pplx/pkg/usage"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// answer is the content of the completions served by fakeAPI.
+const answer = "Go was released in 2009."
+
+// fakeAPI is a Perplexity endpoint answering every request with answer,
+// streamed word by word when the request asks for it. Models in invalid
+// are answered with the error of an unknown model.
+type fakeAPI struct {
+	url     string
+	invalid []string
+
+	mu       sync.Mutex
+	requests []map[string]any
+}
+
+func newFakeAPI(t *testing.T, invalid ...string) *fakeAPI {
+	t.Helper()
+	api := &fakeAPI{invalid: invalid}
+	srv := httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(srv.Close)
+	api.url = srv.URL
+	return api
+}
+
+// sent returns the decoded bodies of the requests received so far.
+func (a *fakeAPI) sent() []map[string]any {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]map[string]any(nil), a.requests...)
+}
+
+func (a *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	data, _ := io.ReadAll(r.Body)
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.mu.Lock()
+	a.requests = append(a.requests, body)
+	a.mu.Unlock()
+
+	model, _ := body["model"].(string)
+	if slices.Contains(a.invalid, model) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":{"message":"Invalid model '`+model+`'","type":"invalid_model","code":400}}`)
+		return
+	}
+	event := func(content string) string {
+		data, _ := json.Marshal(map[string]any{
+			"id": "test-id", "model": model,
+			"choices": []map[string]any{{"index": 0, "message": map[string]string{
+				"role": "assistant", "content": content,
+			}}},
+			"usage": map[string]int{"prompt_tokens": 10, "completion_tokens": 20, "total_tokens": 30},
+		})
+		return string(data)
+	}
+	if stream, _ := body["stream"].(bool); !stream {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, event(answer))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	words := strings.SplitAfter(answer, " ")
+	for i := range words {
+		_, _ = io.WriteString(w, "data: "+event(strings.Join(words[:i+1], ""))+"\n\n")
+	}
+	_, _ = io.WriteString(w, "data: [DONE]\n\n")
+}
+
+// newTestClient returns a client of cfg sending to api without retries and
+// recording usage in a temporary log, whose path it returns.
+func newTestClient(t *testing.T, cfg *config.ConfigData, api *fakeAPI, opts ...Option) (*Client, string) {
+	t.Helper()
+	log := filepath.Join(t.TempDir(), "usage.jsonl")
+	opts = append([]Option{
+		WithAPIKey("test-key"),
+		WithEndpoint(api.url),
+		WithRetryPolicy(retry.NewPolicy(0, 0)),
+		WithUsage("test", usage.NewTracker(log)),
+	}, opts...)
+	client, err := New(cfg, opts...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return client, log
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv(apiKeyEnv, "")
+
+	var configErr *clerrors.ConfigError
+	if _, err := New(nil); !errors.As(err, &configErr) {
+		t.Errorf("New() without a key error = %v, want a ConfigError", err)
+	}
+
+	cfg := config.NewConfigData()
+	cfg.API.Key = "config-key"
+	client, err := New(cfg)
+	if err != nil || client.apiKey != "config-key" {
+		t.Fatalf("New() = %+v, %v; want the key of api.key", client, err)
+	}
+	t.Setenv(apiKeyEnv, "env-key")
+	if client, err := New(cfg); err != nil || client.apiKey != "env-key" {
+		t.Errorf("New() = %+v, %v; want PPLX_API_KEY before api.key", client, err)
+	}
+
+	cfg.API.BaseURL = "ftp://example.com"
+	if _, err := New(cfg); err == nil {
+		t.Error("New() accepted an ftp base_url")
+	}
+}
+
+func TestClient_Query(t *testing.T) {
+	api := newFakeAPI(t)
+	cfg := config.NewConfigData()
+	cfg.Models.Aliases = map[string]string{"fast": "sonar"}
+	cfg.Search.Recency = "week"
+	cfg.Defaults.Language = "fr"
+	client, log := newTestClient(t, cfg, api)
+
+	res, err := client.Query(context.Background(), QueryRequest{Prompt: "When was Go released?", Model: "fast"})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if res.Content != answer || res.Model != "sonar" || len(res.Warnings) != 0 {
+		t.Errorf("Query() = %+v, want the answer of sonar", res)
+	}
+
+	sent := api.sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(sent))
+	}
+	body := sent[0]
+	if body["model"] != "sonar" || body["search_recency_filter"] != "week" || body["max_tokens"] != float64(4000) {
+		t.Errorf("request = %v, want the alias expanded and the config defaults", body)
+	}
+	messages, _ := body["messages"].([]any)
+	if system, _ := messages[0].(map[string]any); !strings.Contains(system["content"].(string), "French") {
+		t.Errorf("system message = %v, want the language instruction", messages[0])
+	}
+
+	records, err := usage.ReadFile(log)
+	if err != nil || len(records) != 1 || records[0].Source != "test" || records[0].TotalTokens != 30 {
+		t.Errorf("usage records = %+v, %v; want one record of source test", records, err)
+	}
+}
+
+func TestClient_Query_Invalid(t *testing.T) {
+	api := newFakeAPI(t)
+	client, _ := newTestClient(t, nil, api)
+
+	tests := []struct {
+		name  string
+		req   QueryRequest
+		field string
+	}{
+		{"no prompt", QueryRequest{}, "user_prompt"},
+		{"search recency", QueryRequest{Prompt: "q", SearchRecency: "fortnight"}, "search_recency"},
+		{"max tokens", QueryRequest{Prompt: "q", Model: "sonar", MaxTokens: 200_000}, "max_tokens"},
+		{"date", QueryRequest{Prompt: "q", SearchAfterDate: "someday"}, "search_after_date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.Query(context.Background(), tt.req)
+			var valErr *clerrors.ValidationError
+			if !errors.As(err, &valErr) || valErr.Field != tt.field {
+				t.Errorf("Query() error = %v, want a %s ValidationError", err, tt.field)
+			}
+		})
+	}
+	if n := len(api.sent()); n != 0 {
+		t.Errorf("sent %d requests, want none", n)
+	}
+}
+
+func TestClient_Query_UnknownModel(t *testing.T) {
+	api := newFakeAPI(t)
+	req := QueryRequest{Prompt: "q", Model: "sonar-prp"}
+
+	client, _ := newTestClient(t, nil, api)
+	res, err := client.Query(context.Background(), req)
+	if err != nil || len(res.Warnings) != 1 || res.Warnings[0].Code != warnings.CodeUnknownModel {
+		t.Errorf("Query() = %+v, %v; want an unknown_model warning", res.Warnings, err)
+	}
+
+	strict, _ := newTestClient(t, nil, api, WithStrictModel())
+	var valErr *clerrors.ValidationError
+	if _, err := strict.Query(context.Background(), req); !errors.As(err, &valErr) || valErr.Field != "model" {
+		t.Errorf("strict Query() error = %v, want a model ValidationError", err)
+	}
+	if n := len(api.sent()); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestClient_Query_Fallback(t *testing.T) {
+	api := newFakeAPI(t, "sonar-pro")
+	cfg := config.NewConfigData()
+	cfg.Defaults.ModelFallbacks = []string{"sonar"}
+	client, _ := newTestClient(t, cfg, api)
+
+	res, err := client.Query(context.Background(), QueryRequest{Prompt: "q", Model: "sonar-pro"})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if res.Model != "sonar" || len(res.Warnings) != 1 || res.Warnings[0].Code != warnings.CodeModelFallback {
+		t.Errorf("Query() = %+v, want the answer of sonar and a model_fallback warning", res)
+	}
+
+	api = newFakeAPI(t, "sonar")
+	client, _ = newTestClient(t, nil, api)
+	var apiErr *clerrors.APIError
+	if _, err := client.Query(context.Background(), QueryRequest{Prompt: "q"}); !errors.As(err, &apiErr) {
+		t.Errorf("Query() error = %v, want an APIError", err)
+	}
+}
+
+func TestClient_Stream(t *testing.T) {
+	api := newFakeAPI(t)
+	client, log := newTestClient(t, nil, api)
+
+	var text strings.Builder
+	var deltas []Delta
+	err := client.Stream(context.Background(), QueryRequest{Prompt: "q"}, func(d Delta) {
+		deltas = append(deltas, d)
+		text.WriteString(d.Text)
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if body := api.sent()[0]; body["stream"] != true {
+		t.Errorf("request = %v, want a streamed request", body)
+	}
+	if len(deltas) != len(strings.Fields(answer))+1 || text.String() != answer {
+		t.Errorf("received %d deltas, text %q; want one per word and %q", len(deltas), text.String(), answer)
+	}
+	last := deltas[len(deltas)-1]
+	if !last.Done || last.Result == nil || last.Result.Content != answer || last.Content != answer {
+		t.Errorf("last delta = %+v, want Done with the answer", last)
+	}
+	if records, err := usage.ReadFile(log); err != nil || len(records) != 1 {
+		t.Errorf("usage records = %+v, %v; want one", records, err)
+	}
+}
+
+func TestClient_Stream_ResponseFormat(t *testing.T) {
+	api := newFakeAPI(t)
+	client, _ := newTestClient(t, nil, api)
+
+	var deltas []Delta
+	req := QueryRequest{Prompt: "q", Model: "sonar", ResponseFormatRegex: `\d+`}
+	if err := client.Stream(context.Background(), req, func(d Delta) { deltas = append(deltas, d) }); err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if body := api.sent()[0]; body["stream"] != false {
+		t.Errorf("request = %v, want streaming turned off", body)
+	}
+	if len(deltas) != 2 || deltas[0].Text != answer || !deltas[1].Done {
+		t.Fatalf("deltas = %+v, want the whole answer then Done", deltas)
+	}
+	if w := deltas[1].Result.Warnings; len(w) != 1 || w[0].Code != warnings.CodeStreamFormat {
+		t.Errorf("warnings = %+v, want a stream_format warning", w)
+	}
+
+	req.StrictOptions = true
+	var valErr *clerrors.ValidationError
+	if err := client.Stream(context.Background(), req, func(Delta) {}); !errors.As(err, &valErr) ||
+		valErr.Field != "stream" {
+		t.Errorf("strict Stream() error = %v, want a stream ValidationError", err)
+	}
+}
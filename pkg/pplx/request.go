@@ -0,0 +1,360 @@
+package pplx
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/models"
+	"github.com/sgaunet/pplx/pkg/prompts"
+	"github.com/sgaunet/pplx/pkg/validation"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// QueryRequest is a question to the Perplexity API and the options it is
+// sent with. Field names follow the arguments of the MCP query tool and the
+// keys of the config file, which validation errors name.
+//
+// Client.Query fills the zero fields from the config of the client;
+// BuildRequest takes them as they are.
+type QueryRequest struct {
+	// Prompt is the question. It is required unless Messages is set.
+	Prompt string
+	// SystemPrompt is the system message.
+	SystemPrompt string
+	// Messages, when set, is the conversation sent instead of SystemPrompt
+	// and Prompt, for multi-turn or multimodal requests. Language is not
+	// applied to it.
+	Messages *perplexity.Messages
+	// Conversation, when set, is sent as is instead of Messages,
+	// SystemPrompt and Prompt: the history of a chat, already trimmed to
+	// the context window. Language is not applied to it.
+	Conversation []perplexity.Message
+
+	// Model is the model asked; ModelFallbacks are the models the request
+	// is sent again with, in order, when the API reports it unavailable.
+	Model          string
+	ModelFallbacks []string
+
+	FrequencyPenalty float64
+	// MaxTokens is the longest answer, in tokens. Zero is the default of
+	// the model; values above its output limit are rejected.
+	MaxTokens       int
+	PresencePenalty float64
+	Temperature     float64
+	TopK            int
+	TopP            float64
+	// Timeout bounds the call, retries included.
+	Timeout time.Duration
+
+	// Search options
+	SearchDomains   []string
+	ExcludeDomains  []string
+	SearchRecency   string
+	LocationLat     float64
+	LocationLon     float64
+	LocationCountry string
+
+	// Response enhancement options
+	ReturnImages  bool
+	ReturnRelated bool
+	// Stream asks for a streamed answer. A response format turns it off;
+	// see StrictOptions.
+	Stream bool
+
+	// Image filtering options
+	ImageDomains []string
+	ImageFormats []string
+
+	// Response format options: a JSON schema or a regular expression.
+	ResponseFormatJSONSchema string
+	ResponseFormatRegex      string
+
+	// Search mode options
+	SearchMode        string
+	SearchContextSize string
+
+	// Date filters, in any format validation.ValidateDate accepts.
+	SearchAfterDate   string
+	SearchBeforeDate  string
+	LastUpdatedAfter  string
+	LastUpdatedBefore string
+
+	// ReasoningEffort is the effort of sonar-deep-research.
+	ReasoningEffort string
+
+	// Language is the ISO 639-1 code of the answer language.
+	Language string
+
+	// ImageConflictPolicy decides which of SearchRecency and ReturnImages
+	// is sent when both are set (output.image_conflict_policy).
+	ImageConflictPolicy string
+	// StrictOptions rejects a streamed request with a response format
+	// instead of sending it unstreamed (output.strict_options).
+	StrictOptions bool
+}
+
+// Validate checks r before anything is sent: the values of the enumerated
+// options, the language and country codes, the domain filters, the
+// response format and its model, the date filters, the combinations the
+// API rejects, and max_tokens against the output limit of the model.
+// Failures are a *clerrors.ValidationError naming the option, which wraps
+// the clerrors sentinel of the check.
+//
+//nolint:cyclop,funlen // one check per option, in a fixed order
+func (r QueryRequest) Validate() error {
+	if r.Prompt == "" && r.Messages == nil && r.Conversation == nil {
+		return clerrors.NewValidationError("user_prompt", "", "must be a non-empty string")
+	}
+
+	enums := []struct {
+		field, kind, value string
+		check              func(string) error
+	}{
+		{"search_recency", validation.KindRecency, r.SearchRecency, validation.ValidateRecency},
+		{"search_mode", validation.KindSearchMode, r.SearchMode, validation.ValidateSearchMode},
+		{"search_context_size", validation.KindContextSize, r.SearchContextSize, validation.ValidateContextSize},
+		{"reasoning_effort", validation.KindReasoningEffort, r.ReasoningEffort, validation.ValidateReasoningEffort},
+	}
+	for _, e := range enums {
+		if e.value == "" {
+			continue
+		}
+		if err := e.check(e.value); err != nil {
+			return invalid(e.field, e.value, "must be one of: "+validation.ValidList(e.kind), err)
+		}
+	}
+
+	// Only one structured format at a time, and only sonar models follow one.
+	hasFormat := r.ResponseFormatJSONSchema != "" || r.ResponseFormatRegex != ""
+	if r.ResponseFormatJSONSchema != "" && r.ResponseFormatRegex != "" {
+		return invalid("response_format", "", "cannot use both json_schema and regex",
+			clerrors.ErrConflictingResponseFormats)
+	}
+	if hasFormat && !strings.HasPrefix(r.Model, "sonar") {
+		return invalid("response_format", "", "only supported by sonar models", clerrors.ErrResponseFormatNotSupported)
+	}
+	if r.ResponseFormatJSONSchema != "" {
+		if _, _, err := validation.ValidateJSONSchema(r.ResponseFormatJSONSchema); err != nil {
+			return invalid("response_format_json_schema", r.ResponseFormatJSONSchema, err.Error(), err)
+		}
+	}
+
+	if err := validation.ValidateLanguage(r.Language); err != nil {
+		return invalid("language", r.Language, err.Error(), err)
+	}
+	if err := validation.ValidateCountry(r.LocationCountry); err != nil {
+		return invalid("location_country", r.LocationCountry, err.Error(), err)
+	}
+
+	// Search recency with images is rejected under the "error" policy; the
+	// other policies drop one of the two when the request is built.
+	if _, err := validation.ResolveImageConflict(r.SearchRecency, r.ReturnImages, r.ImageConflictPolicy); err != nil {
+		field := "search_recency"
+		if errors.Is(err, clerrors.ErrInvalidImageConflictPolicy) {
+			field = "image_conflict_policy"
+		}
+		return invalid(field, r.SearchRecency, err.Error(), err)
+	}
+	if _, err := validation.ResolveStreamFormat(r.Stream, r.ResponseFormatJSONSchema, r.ResponseFormatRegex,
+		r.StrictOptions); err != nil {
+		return invalid("stream", "true", err.Error(), err)
+	}
+
+	if _, err := validation.DomainFilter(r.SearchDomains, r.ExcludeDomains); err != nil {
+		return invalid("exclude_domains", strings.Join(r.ExcludeDomains, ","), err.Error(), err)
+	}
+
+	for _, d := range r.dateFilters() {
+		if d.value == "" {
+			continue
+		}
+		if _, err := validation.ValidateDate(d.value); err != nil {
+			return invalid(d.field, d.value, err.Error(), fmt.Errorf("%w: %w", d.sentinel, err))
+		}
+	}
+
+	// Zero is the model default; unknown models are not checked.
+	if err := models.CheckMaxTokens(r.Model, r.MaxTokens); err != nil {
+		return invalid("max_tokens", strconv.Itoa(r.MaxTokens), err.Error(), err)
+	}
+	return nil
+}
+
+// invalid returns the validation error of field, wrapping err so that
+// callers can tell which check failed with errors.Is.
+func invalid(field, value, message string, err error) *clerrors.ValidationError {
+	verr := clerrors.NewValidationError(field, value, message)
+	verr.Err = err
+	return verr
+}
+
+// dateFilter is a date filter of a request, the sentinel its invalid values
+// wrap, and the option sending it.
+type dateFilter struct {
+	field, value string
+	sentinel     error
+	option       func(time.Time) perplexity.CompletionRequestOption
+}
+
+// dateFilters returns the four date filters of r.
+func (r QueryRequest) dateFilters() []dateFilter {
+	return []dateFilter{
+		{"search_after_date", r.SearchAfterDate, clerrors.ErrInvalidSearchAfterDate, perplexity.WithPublishedAfter},
+		{"search_before_date", r.SearchBeforeDate, clerrors.ErrInvalidSearchBeforeDate, perplexity.WithPublishedBefore},
+		{"last_updated_after", r.LastUpdatedAfter, clerrors.ErrInvalidLastUpdatedAfter,
+			perplexity.WithLastUpdatedAfterFilter},
+		{"last_updated_before", r.LastUpdatedBefore, clerrors.ErrInvalidLastUpdatedBefore,
+			perplexity.WithLastUpdatedBeforeFilter},
+	}
+}
+
+// messages returns the option sending the conversation of r: Conversation,
+// Messages, or the system prompt, with the instruction of Language,
+// followed by Prompt.
+func (r QueryRequest) messages() (perplexity.CompletionRequestOption, error) {
+	if r.Conversation != nil {
+		return perplexity.WithMessages(r.Conversation), nil
+	}
+	if r.Messages != nil {
+		return perplexity.WithMessagesFromMessages(r.Messages), nil
+	}
+	msg := perplexity.NewMessages(perplexity.WithSystemMessage(prompts.WithLanguage(r.SystemPrompt, r.Language)))
+	if err := msg.AddUserMessage(r.Prompt); err != nil {
+		return nil, fmt.Errorf("failed to add user message: %w", err)
+	}
+	return perplexity.WithMessagesFromMessages(&msg), nil
+}
+
+// BuildRequest validates r and builds the completion request it describes.
+// It is the one place requests are built, shared by the query command, the
+// MCP server, chat sessions, and Client. Non-fatal issues, such as an image format the API
+// may not support or the recency filter dropped for images, are added to
+// warns; a nil warns logs them.
+//
+// Parameter incompatibility patterns:
+//   - search_recency + return_images: the API cannot combine them, as image
+//     search does not support time filtering. ImageConflictPolicy drops one
+//     of them with a warning, or rejects the request.
+//   - stream + response formats: the API ignores formats in streamed
+//     answers. Streaming is turned off with a warning, or the request is
+//     rejected under StrictOptions.
+//
+// Warnings rather than errors are used where the API may know better than
+// pplx: unlisted image formats, and reasoning_effort on a model other than
+// sonar-deep-research, which the API ignores.
+//
+//nolint:cyclop,funlen // one block per optional option
+func BuildRequest(r QueryRequest, warns *warnings.Collector) (*perplexity.CompletionRequest, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	msg, err := r.messages()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []perplexity.CompletionRequestOption{
+		msg,
+		perplexity.WithModel(r.Model),
+		perplexity.WithFrequencyPenalty(r.FrequencyPenalty),
+		perplexity.WithMaxTokens(cmp.Or(r.MaxTokens, models.DefaultMaxTokens(r.Model))),
+		perplexity.WithPresencePenalty(r.PresencePenalty),
+		perplexity.WithTemperature(r.Temperature),
+		perplexity.WithTopK(r.TopK),
+		perplexity.WithTopP(r.TopP),
+	}
+
+	// Search options. Validate has already rejected invalid domain lists
+	// and a conflict under the error policy.
+	if filter, err := validation.DomainFilter(r.SearchDomains, r.ExcludeDomains); err == nil && filter != nil {
+		opts = append(opts, perplexity.WithSearchDomainFilter(filter))
+	}
+	conflict, _ := validation.ResolveImageConflict(r.SearchRecency, r.ReturnImages, r.ImageConflictPolicy)
+	if conflict.Warning != "" {
+		warns.Add(warnings.CodeImageConflict, conflict.Warning,
+			"set output.image_conflict_policy to choose which option is kept")
+	}
+	if conflict.Recency != "" {
+		opts = append(opts, perplexity.WithSearchRecencyFilter(conflict.Recency))
+	}
+	if r.LocationLat != 0 || r.LocationLon != 0 || r.LocationCountry != "" {
+		opts = append(opts, perplexity.WithUserLocation(r.LocationLat, r.LocationLon, r.LocationCountry))
+	}
+	if r.SearchMode != "" {
+		opts = append(opts, perplexity.WithSearchMode(r.SearchMode))
+	}
+	if r.SearchContextSize != "" {
+		opts = append(opts, perplexity.WithSearchContextSize(r.SearchContextSize))
+	}
+
+	// Response enhancement options
+	if conflict.ReturnImages {
+		opts = append(opts, perplexity.WithReturnImages(true))
+	}
+	if r.ReturnRelated {
+		opts = append(opts, perplexity.WithReturnRelatedQuestions(true))
+	}
+	stream, _ := validation.ResolveStreamFormat(r.Stream, r.ResponseFormatJSONSchema, r.ResponseFormatRegex,
+		r.StrictOptions)
+	if stream.Warning != "" {
+		warns.Add(warnings.CodeStreamFormat, stream.Warning,
+			"set output.strict_options: true to reject such requests instead")
+	}
+	if stream.Stream {
+		opts = append(opts, perplexity.WithStream(true))
+	}
+
+	// Image filtering options
+	if len(r.ImageDomains) > 0 {
+		opts = append(opts, perplexity.WithImageDomainFilter(r.ImageDomains))
+	}
+	if len(r.ImageFormats) > 0 {
+		for _, format := range validation.ValidateImageFormats(r.ImageFormats) {
+			warns.Add(warnings.CodeImageFormat, fmt.Sprintf("image format %q may not be supported", format),
+				"common formats: "+validation.ValidList(validation.KindImageFormat))
+		}
+		opts = append(opts, perplexity.WithImageFormatFilter(r.ImageFormats))
+	}
+
+	// Response format options; Validate has already checked the schema.
+	if r.ResponseFormatJSONSchema != "" {
+		schema, schemaWarnings, _ := validation.ValidateJSONSchema(r.ResponseFormatJSONSchema)
+		for _, warning := range schemaWarnings {
+			warns.Add(warnings.CodeJSONSchema, warning, "")
+		}
+		opts = append(opts, perplexity.WithJSONSchemaResponseFormat(schema))
+	}
+	if r.ResponseFormatRegex != "" {
+		opts = append(opts, perplexity.WithRegexResponseFormat(r.ResponseFormatRegex))
+	}
+
+	// Date filters; Validate has already parsed them.
+	for _, d := range r.dateFilters() {
+		if d.value == "" {
+			continue
+		}
+		date, _ := validation.ValidateDate(d.value)
+		opts = append(opts, d.option(date))
+	}
+
+	if r.ReasoningEffort != "" {
+		if !strings.Contains(r.Model, "deep-research") {
+			warns.Add(warnings.CodeReasoningEffort,
+				fmt.Sprintf("reasoning_effort is only supported by sonar-deep-research, not %s", r.Model),
+				"use model sonar-deep-research or drop reasoning_effort")
+		}
+		opts = append(opts, perplexity.WithReasoningEffort(r.ReasoningEffort))
+	}
+
+	req := perplexity.NewCompletionRequest(opts...)
+	if err := req.Validate(); err != nil {
+		return nil, clerrors.NewValidationError("request", "", err.Error())
+	}
+	return req, nil
+}
@@ -0,0 +1,125 @@
+package pplx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/warnings"
+)
+
+// valid returns a request that passes Validate, with the sampling options of
+// the perplexity-go library.
+func valid() QueryRequest {
+	return QueryRequest{
+		Prompt:           "test",
+		Model:            "sonar",
+		FrequencyPenalty: perplexity.DefaultFrequencyPenalty,
+		TopP:             perplexity.DefaultTopP,
+	}
+}
+
+func TestQueryRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*QueryRequest)
+		field  string
+	}{
+		{"valid", func(*QueryRequest) {}, ""},
+		{"messages replace the prompt", func(r *QueryRequest) {
+			r.Prompt = ""
+			r.Messages = &perplexity.Messages{}
+		}, ""},
+		{"no prompt", func(r *QueryRequest) { r.Prompt = "" }, "user_prompt"},
+		{"search mode", func(r *QueryRequest) { r.SearchMode = "news" }, "search_mode"},
+		{"reasoning effort", func(r *QueryRequest) { r.ReasoningEffort = "extreme" }, "reasoning_effort"},
+		{"both response formats", func(r *QueryRequest) {
+			r.ResponseFormatJSONSchema = `{"type": "object"}`
+			r.ResponseFormatRegex = `\d+`
+		}, "response_format"},
+		{"response format of another model", func(r *QueryRequest) {
+			r.Model = "r1-1776"
+			r.ResponseFormatRegex = `\d+`
+		}, "response_format"},
+		{"invalid schema", func(r *QueryRequest) { r.ResponseFormatJSONSchema = "{" }, "response_format_json_schema"},
+		{"language", func(r *QueryRequest) { r.Language = "french" }, "language"},
+		{"country", func(r *QueryRequest) { r.LocationCountry = "USA" }, "location_country"},
+		{"image conflict under the error policy", func(r *QueryRequest) {
+			r.SearchRecency, r.ReturnImages, r.ImageConflictPolicy = "week", true, "error"
+		}, "search_recency"},
+		{"image conflict policy", func(r *QueryRequest) {
+			r.SearchRecency, r.ReturnImages, r.ImageConflictPolicy = "week", true, "coin_flip"
+		}, "image_conflict_policy"},
+		{"strict stream with a format", func(r *QueryRequest) {
+			r.Stream, r.ResponseFormatRegex, r.StrictOptions = true, `\d+`, true
+		}, "stream"},
+		{"domain in both lists", func(r *QueryRequest) {
+			r.SearchDomains, r.ExcludeDomains = []string{"go.dev"}, []string{"go.dev"}
+		}, "exclude_domains"},
+		{"date", func(r *QueryRequest) { r.LastUpdatedBefore = "13/45/2024" }, "last_updated_before"},
+		{"max tokens", func(r *QueryRequest) { r.MaxTokens = 9000 }, "max_tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.modify(&r)
+			err := r.Validate()
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			var valErr *clerrors.ValidationError
+			if !errors.As(err, &valErr) || valErr.Field != tt.field {
+				t.Errorf("Validate() error = %v, want a %s ValidationError", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestBuildRequest(t *testing.T) {
+	r := valid()
+	r.SystemPrompt = "Be brief."
+	r.Language = "de"
+	r.SearchRecency = "week"
+	r.ReturnImages = true
+	r.ImageFormats = []string{"png", "tiff"}
+	r.SearchAfterDate = "2024-03-01"
+
+	warns := warnings.New()
+	req, err := BuildRequest(r, warns)
+	if err != nil {
+		t.Fatalf("BuildRequest() error = %v", err)
+	}
+	if req.Model != "sonar" || req.MaxTokens != perplexity.DefaultMaxTokens || req.PublishedAfter != "3/1/2024" {
+		t.Errorf("request = %+v", req)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Content != "Be brief.\n\nAlways answer in German." ||
+		req.Messages[1].Content != "test" {
+		t.Errorf("messages = %+v, want the system prompt with the language, then the prompt", req.Messages)
+	}
+	// The default image conflict policy keeps the images.
+	if req.SearchRecencyFilter != "" || !req.ReturnImages {
+		t.Errorf("recency %q, images %v; want images only", req.SearchRecencyFilter, req.ReturnImages)
+	}
+	var codes []string
+	for _, w := range warns.All() {
+		codes = append(codes, w.Code)
+	}
+	if len(codes) != 2 || codes[0] != warnings.CodeImageConflict || codes[1] != warnings.CodeImageFormat {
+		t.Errorf("warning codes = %v, want image_conflict and image_format", codes)
+	}
+
+	msg := perplexity.NewMessages()
+	if err := msg.AddUserMessage("from a transcript"); err != nil {
+		t.Fatal(err)
+	}
+	r = valid()
+	r.Prompt, r.Messages, r.Language = "", &msg, "de"
+	req, err = BuildRequest(r, nil)
+	if err != nil || len(req.Messages) != 1 || req.Messages[0].Content != "from a transcript" {
+		t.Errorf("BuildRequest() = %+v, %v; want the messages as given", req, err)
+	}
+}
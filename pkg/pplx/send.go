@@ -0,0 +1,142 @@
+package pplx
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/fallback"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/usage"
+)
+
+// SendOptions are the options of Send.
+type SendOptions struct {
+	// Fallbacks are the models the request is sent again with, in order,
+	// when the API reports its model unavailable.
+	Fallbacks []string
+	// OnEvent, when set, receives every event of a streamed answer, each
+	// holding the whole answer so far.
+	OnEvent func(*perplexity.CompletionResponse)
+	// IdleTimeout, when positive, aborts a streamed answer that sends
+	// nothing for that long, counted from the request and then from each
+	// event.
+	IdleTimeout time.Duration
+
+	// Source, when set, records the usage of the answer in the usage log
+	// under that source, with KeyID naming the API key of the request.
+	// Tracker is the usage log; nil is the default one. A log that cannot
+	// be written is logged: it never fails a request.
+	Source  string
+	KeyID   string
+	Tracker *usage.Tracker
+}
+
+// Send sends req with client through the fallback models of opts. It is
+// the one place requests are sent, shared by the query command, the MCP
+// server, chat sessions, and Client. A streamed request (req.Stream) is
+// read to its end with StreamCompletion. When another model answers, req
+// is switched to it, so that continuations of the answer use the same
+// model; the result names it. The usage of the answer is recorded when
+// opts name a source.
+//
+// When a stream fails, the result holds the last event received, if any,
+// with the error, so that the answer received so far can be kept.
+func Send(ctx context.Context, client *perplexity.Client, req *perplexity.CompletionRequest,
+	opts SendOptions,
+) (*fallback.Result, error) {
+	result, err := fallback.Send(ctx, req, opts.Fallbacks,
+		func(ctx context.Context, next *perplexity.CompletionRequest) (*perplexity.CompletionResponse, error) {
+			if next.Stream {
+				return streamEvents(ctx, client, next, opts.IdleTimeout, opts.OnEvent)
+			}
+			return client.SendCompletionRequestWithContext(ctx, next)
+		})
+	if err != nil {
+		return result, err //nolint:wrapcheck // callers wrap the error for their output
+	}
+	if result.FellBack() {
+		req.Model = result.Model
+	}
+	if opts.Source != "" {
+		track(opts, result.Response)
+	}
+	return result, nil
+}
+
+// track records the usage of response as opts ask.
+func track(opts SendOptions, response *perplexity.CompletionResponse) {
+	if opts.Tracker == nil {
+		usage.TrackKey(opts.Source, opts.KeyID, response)
+		return
+	}
+	if err := opts.Tracker.RecordKey(opts.Source, opts.KeyID, response); err != nil {
+		logger.Warn("failed to record usage", "source", opts.Source, "error", err)
+	}
+}
+
+// StreamCompletion sends req as a streaming request with client and returns
+// its last, complete event: the API sends the whole answer so far with each
+// event, and only the last one carries the complete metadata (citations,
+// images, related questions). onEvent, when set, receives every event.
+//
+// The stream is consumed in the calling goroutine, so onEvent has returned
+// for every event before StreamCompletion does. When the stream fails or
+// ctx ends, the last event received, if any, is returned with the error; a
+// stream without any event fails with clerrors.ErrNoResponse.
+func StreamCompletion(ctx context.Context, client *perplexity.Client, req *perplexity.CompletionRequest,
+	onEvent func(*perplexity.CompletionResponse),
+) (*perplexity.CompletionResponse, error) {
+	return streamEvents(ctx, client, req, 0, onEvent)
+}
+
+// streamEvents is StreamCompletion, aborting the stream with an error
+// wrapping clerrors.ErrStreamStalled when idle is positive and no event
+// arrives for that long.
+func streamEvents(ctx context.Context, client *perplexity.Client, req *perplexity.CompletionRequest,
+	idle time.Duration, onEvent func(*perplexity.CompletionResponse),
+) (*perplexity.CompletionResponse, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stalled atomic.Bool
+	var timer *time.Timer
+	if idle > 0 {
+		timer = time.AfterFunc(idle, func() {
+			stalled.Store(true)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+
+	// The producer closes events when it returns, after the request ended
+	// or was cancelled.
+	events := make(chan perplexity.CompletionResponse)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.StreamCompletionWithContext(streamCtx, req, events)
+	}()
+
+	var last *perplexity.CompletionResponse
+	for event := range events {
+		last = &event
+		if timer != nil {
+			timer.Reset(idle)
+		}
+		if onEvent != nil {
+			onEvent(last)
+		}
+	}
+	if err := <-errCh; err != nil {
+		if stalled.Load() && ctx.Err() == nil {
+			return last, fmt.Errorf("%w: no data received for %s: %w", clerrors.ErrStreamStalled, idle, err)
+		}
+		return last, err //nolint:wrapcheck // wrapped by the caller
+	}
+	if last == nil {
+		return nil, clerrors.ErrNoResponse
+	}
+	return last, nil
+}
@@ -0,0 +1,99 @@
+package pplx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/usage"
+)
+
+// newRequest returns a request for model, streamed when stream is set.
+func newRequest(model string, stream bool) *perplexity.CompletionRequest {
+	return perplexity.NewCompletionRequest(
+		perplexity.WithMessages([]perplexity.Message{{Role: "user", Content: "q"}}),
+		perplexity.WithModel(model),
+		perplexity.WithStream(stream),
+	)
+}
+
+func TestSend_Fallback(t *testing.T) {
+	api := newFakeAPI(t, "sonar-pro")
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(api.url)
+	log := filepath.Join(t.TempDir(), "usage.jsonl")
+
+	req := newRequest("sonar-pro", false)
+	result, err := Send(context.Background(), client, req, SendOptions{
+		Fallbacks: []string{"sonar"},
+		Source:    "test",
+		KeyID:     "work",
+		Tracker:   usage.NewTracker(log),
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !result.FellBack() || result.Model != "sonar" || req.Model != "sonar" {
+		t.Errorf("Send() answered by %q, request model %q; want both switched to sonar", result.Model, req.Model)
+	}
+	records, err := usage.ReadFile(log)
+	if err != nil || len(records) != 1 || records[0].Source != "test" || records[0].KeyID != "work" {
+		t.Errorf("usage records = %+v, %v; want one record of source test and key work", records, err)
+	}
+}
+
+func TestSend_Stream(t *testing.T) {
+	api := newFakeAPI(t)
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(api.url)
+
+	var events int
+	result, err := Send(context.Background(), client, newRequest("sonar", true), SendOptions{
+		OnEvent: func(*perplexity.CompletionResponse) { events++ },
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if events == 0 || result.Response.GetLastContent() != answer {
+		t.Errorf("received %d events, answer %q; want the streamed answer", events, result.Response.GetLastContent())
+	}
+}
+
+func TestStreamCompletion_NoEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	_, err := StreamCompletion(context.Background(), client, newRequest("sonar", true), nil)
+	if !errors.Is(err, clerrors.ErrNoResponse) {
+		t.Errorf("StreamCompletion() error = %v, want ErrNoResponse", err)
+	}
+}
+
+func TestSend_StreamStalled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+
+	_, err := Send(context.Background(), client, newRequest("sonar", true),
+		SendOptions{IdleTimeout: 50 * time.Millisecond})
+	if !errors.Is(err, clerrors.ErrStreamStalled) {
+		t.Errorf("Send() error = %v, want ErrStreamStalled", err)
+	}
+}
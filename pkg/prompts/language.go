@@ -0,0 +1,72 @@
+package prompts
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/sgaunet/pplx/pkg/validation"
+)
+
+// LanguageInstruction returns the sentence asking the model to answer in the
+// language with the ISO 639-1 code, or "" for an unknown or empty code.
+func LanguageInstruction(code string) string {
+	lang, ok := validation.LookupLanguage(code)
+	if !ok {
+		return ""
+	}
+	return "Always answer in " + lang.Name + "."
+}
+
+// WithLanguage appends the instruction of LanguageInstruction(code) to the
+// system prompt. The prompt is returned unchanged when code is empty or
+// unknown, or when the prompt already names a language (see
+// HasLanguageInstruction), so that an explicit instruction from the user wins.
+func WithLanguage(system, code string) string {
+	instruction := LanguageInstruction(code)
+	if instruction == "" || HasLanguageInstruction(system) {
+		return system
+	}
+	if system == "" {
+		return instruction
+	}
+	return strings.TrimRight(system, " \t\n") + "\n\n" + instruction
+}
+
+// HasLanguageInstruction reports whether system mentions a language by its
+// English or native name, such as "Answer in German" or "Réponds en
+// français". It is a heuristic: a prompt naming a language for another
+// reason also counts.
+func HasLanguageInstruction(system string) bool {
+	if system == "" {
+		return false
+	}
+	lower := strings.ToLower(system)
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		words[w] = true
+	}
+	for _, lang := range validation.Languages() {
+		for _, name := range []string{lang.Name, lang.Native} {
+			name = strings.ToLower(name)
+			if isLatin(name) {
+				// Whole words only, so that "Thai" does not match "Thailand".
+				if words[name] || (strings.Contains(name, " ") && strings.Contains(lower, name)) {
+					return true
+				}
+			} else if strings.Contains(lower, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isLatin reports whether every letter of s is in the Latin script.
+func isLatin(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package prompts
+
+import "testing"
+
+func TestWithLanguage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		system string
+		code   string
+		want   string
+	}{
+		{name: "no language", system: "Be brief.", code: "", want: "Be brief."},
+		{name: "empty prompt", system: "", code: "fr", want: "Always answer in French."},
+		{name: "suffix", system: "Be brief.\n", code: "fr", want: "Be brief.\n\nAlways answer in French."},
+		{name: "code case", system: "", code: "DE", want: "Always answer in German."},
+		{name: "unknown code", system: "Be brief.", code: "xx", want: "Be brief."},
+		{name: "english name", system: "Answer in Spanish.", code: "fr", want: "Answer in Spanish."},
+		{name: "native name", system: "Réponds en français.", code: "fr", want: "Réponds en français."},
+		{name: "native script", system: "日本語で答えてください", code: "fr", want: "日本語で答えてください"},
+		{name: "multi-word name", system: "Jawab dalam Bahasa Indonesia.", code: "fr",
+			want: "Jawab dalam Bahasa Indonesia."},
+		{name: "name inside word", system: "Plan a trip to Thailand.", code: "fr",
+			want: "Plan a trip to Thailand.\n\nAlways answer in French."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := WithLanguage(tt.system, tt.code); got != tt.want {
+				t.Errorf("WithLanguage(%q, %q) = %q, want %q", tt.system, tt.code, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Package prompts renders prompt templates with {{variable}} placeholders.
+//
+// Templates use text/template syntax. A bare placeholder such as {{topic}} is
+// shorthand for {{.topic}}, so simple templates read naturally while
+// conditionals like {{if .audience}}...{{end}} remain available. Every
+// variable referenced by a template is required: rendering fails with the
+// list of missing variables instead of sending a prompt with unexpanded
+// placeholders.
+package prompts
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// varSeparator separates the name and value of a --var argument.
+const varSeparator = "="
+
+// barePlaceholder matches {{name}} placeholders written without a leading dot.
+var barePlaceholder = regexp.MustCompile(`\{\{(-?\s*)([A-Za-z_][A-Za-z0-9_]*)(\s*-?)\}\}`)
+
+// keywords are template actions that must not be rewritten into fields.
+var keywords = map[string]bool{
+	"break": true, "continue": true, "else": true, "end": true, "nil": true,
+}
+
+// Template is a parsed prompt template.
+type Template struct {
+	tmpl *template.Template
+	vars []string
+}
+
+// Parse parses text as a prompt template. name identifies the template in
+// error messages.
+func Parse(name, text string) (*Template, error) {
+	normalized := barePlaceholder.ReplaceAllStringFunc(text, func(match string) string {
+		parts := barePlaceholder.FindStringSubmatch(match)
+		if keywords[parts[2]] {
+			return match
+		}
+		return "{{" + parts[1] + "." + parts[2] + parts[3] + "}}"
+	})
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", clerrors.ErrPromptInvalid, name, err)
+	}
+
+	seen := make(map[string]bool)
+	if tmpl.Tree != nil {
+		collectFields(tmpl.Tree.Root, seen)
+	}
+	vars := make([]string, 0, len(seen))
+	for v := range seen {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+
+	return &Template{tmpl: tmpl, vars: vars}, nil
+}
+
+// Variables returns the sorted names of the variables the template uses.
+func (t *Template) Variables() []string {
+	return slices.Clone(t.vars)
+}
+
+// Missing returns the sorted names of the template variables absent from vars.
+func (t *Template) Missing(vars map[string]string) []string {
+	var missing []string
+	for _, name := range t.vars {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Render executes the template with vars. It returns an error wrapping
+// clerrors.ErrMissingPromptVariables that lists every missing variable
+// when vars is incomplete.
+func (t *Template) Render(vars map[string]string) (string, error) {
+	if missing := t.Missing(vars); len(missing) > 0 {
+		return "", MissingError(t.tmpl.Name(), missing)
+	}
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("%w: %s: %w", clerrors.ErrPromptInvalid, t.tmpl.Name(), err)
+	}
+	return b.String(), nil
+}
+
+// MissingError reports the variables missing to render the template name.
+func MissingError(name string, missing []string) error {
+	return fmt.Errorf("%w for %q: %s (set them with --var key=value)",
+		clerrors.ErrMissingPromptVariables, name, strings.Join(missing, ", "))
+}
+
+// ParseVars parses key=value pairs, as given by repeated --var flags, into a
+// map. Later pairs override earlier ones.
+func ParseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, varSeparator)
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: %q (want key=value)", clerrors.ErrInvalidPromptVariable, pair)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// collectFields records the first identifier of every field node under node,
+// which is the variable name since templates execute against a flat map.
+func collectFields(node parse.Node, seen map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectFields(child, seen)
+		}
+	case *parse.ActionNode:
+		collectFields(n.Pipe, seen)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectFields(cmd, seen)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectFields(arg, seen)
+		}
+	case *parse.FieldNode:
+		seen[n.Ident[0]] = true
+	case *parse.IfNode:
+		collectBranch(&n.BranchNode, seen)
+	case *parse.RangeNode:
+		collectBranch(&n.BranchNode, seen)
+	case *parse.WithNode:
+		collectBranch(&n.BranchNode, seen)
+	}
+}
+
+// collectBranch collects the fields of an if, range, or with action.
+func collectBranch(n *parse.BranchNode, seen map[string]bool) {
+	collectFields(n.Pipe, seen)
+	collectFields(n.List, seen)
+	collectFields(n.ElseList, seen)
+}
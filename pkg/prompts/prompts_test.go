@@ -0,0 +1,89 @@
+package prompts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestParseVariables(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "none", text: "plain text", want: ""},
+		{name: "bare", text: "Hi {{name}}, {{ name }}!", want: "name"},
+		{name: "dotted", text: "{{.topic}} in {{.lang}}", want: "lang,topic"},
+		{name: "trim markers", text: "a {{- who -}} b", want: "who"},
+		{name: "conditional", text: "{{if .extra}}{{extra}}{{else}}none{{end}} {{base}}", want: "base,extra"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpl, err := Parse(tt.name, tt.text)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := strings.Join(tmpl.Variables(), ","); got != tt.want {
+				t.Errorf("Variables() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Parse("bad", "{{if .x}}unterminated"); !errors.Is(err, clerrors.ErrPromptInvalid) {
+		t.Errorf("Parse() error = %v, want ErrPromptInvalid", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	tmpl, err := Parse("greet", "Hello {{name}} from {{place}}{{if .note}} ({{note}}){{end}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tmpl.Render(map[string]string{"name": "Ada"})
+	if !errors.Is(err, clerrors.ErrMissingPromptVariables) {
+		t.Fatalf("Render() error = %v, want ErrMissingPromptVariables", err)
+	}
+	if !strings.Contains(err.Error(), "note, place") {
+		t.Errorf("error %q should list every missing variable", err)
+	}
+
+	got, err := tmpl.Render(map[string]string{"name": "Ada", "place": "London", "note": ""})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got != "Hello Ada from London" {
+		t.Errorf("Render() = %q", got)
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	t.Parallel()
+
+	vars, err := ParseVars([]string{"a=1", "b=x=y", "a=2", "empty="})
+	if err != nil {
+		t.Fatalf("ParseVars() error = %v", err)
+	}
+	if vars["a"] != "2" || vars["b"] != "x=y" || vars["empty"] != "" || len(vars) != 3 {
+		t.Errorf("ParseVars() = %v", vars)
+	}
+
+	for _, bad := range []string{"novalue", "=value"} {
+		if _, err := ParseVars([]string{bad}); !errors.Is(err, clerrors.ErrInvalidPromptVariable) {
+			t.Errorf("ParseVars(%q) error = %v, want ErrInvalidPromptVariable", bad, err)
+		}
+	}
+}
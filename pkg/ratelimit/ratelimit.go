@@ -0,0 +1,158 @@
+// Package ratelimit provides a token bucket limiting how fast requests are
+// sent to the Perplexity API.
+//
+// One Limiter is shared by every request of a process (all MCP tool calls,
+// or all workers of a batch), so parallel callers cannot exceed the
+// configured rate together. A nil *Limiter never waits, which is how an
+// unset rate disables limiting.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/logger"
+)
+
+// DefaultWarnAfter is the wait beyond which a warning is logged.
+const DefaultWarnAfter = 5 * time.Second
+
+// Limiter is a token bucket refilled at a steady rate up to its burst size.
+// Each request takes one token; requests arriving on an empty bucket wait,
+// in arrival order, for their token.
+type Limiter struct {
+	perMinute int
+	burst     int
+	// interval is the time to refill one token.
+	interval time.Duration
+	// warnAfter is the wait beyond which a warning is logged.
+	warnAfter time.Duration
+	// now returns the current time; replaced in tests.
+	now func() time.Time
+
+	mu sync.Mutex
+	// tokens is the bucket level at last; negative when waiting requests
+	// have reserved tokens that are not refilled yet.
+	tokens  float64
+	last    time.Time
+	waiting int
+	stats   Stats
+}
+
+// Stats describes the limiter configuration and what it has done so far.
+type Stats struct {
+	Enabled           bool    `json:"enabled"`
+	RequestsPerMinute int     `json:"requests_per_minute,omitempty"`
+	Burst             int     `json:"burst,omitempty"`
+	Available         float64 `json:"available_tokens"`
+	// Waiting is the number of requests currently queued for a token.
+	Waiting int `json:"waiting"`
+	// Allowed counts requests that got a token; Delayed those of them that
+	// had to wait, and Cancelled the requests whose context ended first.
+	Allowed   int64 `json:"allowed"`
+	Delayed   int64 `json:"delayed"`
+	Cancelled int64 `json:"cancelled"`
+	// TotalWait is the time allowed requests spent waiting, in seconds.
+	TotalWait float64 `json:"total_wait_seconds"`
+}
+
+// New returns a limiter allowing perMinute requests per minute with bursts
+// of up to burst requests. It returns nil, which never waits, when perMinute
+// is not positive. A burst below 1 is 1, which spaces requests evenly.
+func New(perMinute, burst int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	burst = max(burst, 1)
+	return &Limiter{
+		perMinute: perMinute,
+		burst:     burst,
+		interval:  time.Minute / time.Duration(perMinute),
+		warnAfter: DefaultWarnAfter,
+		now:       time.Now,
+		tokens:    float64(burst),
+	}
+}
+
+// Wait blocks until a token is available and takes it. When ctx ends first,
+// the reserved token is given back and the context error is returned. A
+// wait longer than DefaultWarnAfter is logged with the queue depth.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	l.refill(now)
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens * float64(l.interval))
+	}
+	if delay == 0 {
+		l.stats.Allowed++
+		l.mu.Unlock()
+		return nil
+	}
+	l.waiting++
+	queued := l.waiting
+	l.mu.Unlock()
+
+	if delay > l.warnAfter {
+		logger.Warn("rate limit reached, delaying request",
+			"wait", delay.Round(time.Millisecond), "queue_depth", queued,
+			"requests_per_minute", l.perMinute)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.waiting--
+		l.tokens++
+		l.stats.Cancelled++
+		l.mu.Unlock()
+		return fmt.Errorf("rate limit wait cancelled: %w", ctx.Err())
+	case <-timer.C:
+	}
+
+	l.mu.Lock()
+	l.waiting--
+	l.stats.Allowed++
+	l.stats.Delayed++
+	l.stats.TotalWait += delay.Seconds()
+	l.mu.Unlock()
+	return nil
+}
+
+// Stats returns a snapshot of the limiter. A nil limiter reports itself
+// disabled.
+func (l *Limiter) Stats() Stats {
+	if l == nil {
+		return Stats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(l.now())
+	s := l.stats
+	s.Enabled = true
+	s.RequestsPerMinute = l.perMinute
+	s.Burst = l.burst
+	s.Available = max(l.tokens, 0)
+	s.Waiting = l.waiting
+	return s
+}
+
+// refill adds the tokens earned since the last refill, up to the burst
+// size. The caller holds l.mu.
+func (l *Limiter) refill(now time.Time) {
+	if !l.last.IsZero() {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+		l.tokens = min(l.tokens, float64(l.burst))
+	}
+	l.last = now
+}
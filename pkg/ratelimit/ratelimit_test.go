@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNew_Disabled(t *testing.T) {
+	l := New(0, 10)
+	if l != nil {
+		t.Fatal("New(0, 10) should return nil")
+	}
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("nil limiter Wait() error = %v", err)
+	}
+	if s := l.Stats(); s.Enabled {
+		t.Errorf("nil limiter Stats() = %+v, want disabled", s)
+	}
+}
+
+func TestLimiter_SpacesRequests(t *testing.T) {
+	l := New(60*50, 0) // one request every 20ms, burst 1
+	start := time.Now()
+	for range 3 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 40ms of spacing", elapsed)
+	}
+	s := l.Stats()
+	if s.Allowed != 3 || s.Delayed != 2 || s.Burst != 1 {
+		t.Errorf("Stats() = %+v, want 3 allowed, 2 delayed, burst 1", s)
+	}
+}
+
+func TestLimiter_Burst(t *testing.T) {
+	l := New(1, 3)
+	start := time.Now()
+	for range 3 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("burst of 3 took %v, want no wait", elapsed)
+	}
+	if s := l.Stats(); s.Available >= 1 || s.Delayed != 0 {
+		t.Errorf("Stats() = %+v, want an empty bucket and no delays", s)
+	}
+}
+
+func TestLimiter_Cancel(t *testing.T) {
+	l := New(1, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- l.Wait(ctx)
+		}()
+	}
+	deadline := time.Now().Add(time.Second)
+	for l.Stats().Waiting != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if w := l.Stats().Waiting; w != 2 {
+		t.Fatalf("Waiting = %d, want 2", w)
+	}
+	cancel()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Wait() error = %v, want context.Canceled", err)
+		}
+	}
+
+	s := l.Stats()
+	if s.Waiting != 0 || s.Cancelled != 2 || s.Allowed != 1 {
+		t.Errorf("Stats() = %+v, want 0 waiting, 2 cancelled, 1 allowed", s)
+	}
+	// Cancelled requests give their tokens back, so the next one does not
+	// wait behind them.
+	if s.Available > 1 || l.tokens < -0.5 {
+		t.Errorf("tokens = %v after cancellation, want reservations released", l.tokens)
+	}
+}
@@ -0,0 +1,237 @@
+// Package render converts Perplexity answers, which are markdown, into text
+// for the terminal.
+//
+// Three modes are supported:
+//   - markdown: styled output (bold headings, indented lists, highlighted code
+//     blocks) with clickable citation links on terminals that support OSC 8
+//   - plain: markdown syntax removed, no escape sequences; suited to pipes
+//   - raw: the answer exactly as returned by the API
+//
+// When no mode is requested, markdown is used if stdout is a terminal and
+// plain otherwise (see Resolve).
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	markdown "github.com/MichaelMure/go-term-markdown"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/output/color"
+)
+
+// Mode selects how an answer is rendered.
+type Mode string
+
+// Supported render modes.
+const (
+	ModeMarkdown Mode = "markdown"
+	ModePlain    Mode = "plain"
+	ModeRaw      Mode = "raw"
+)
+
+// Layout defaults for markdown mode.
+const (
+	// DefaultWidth is the line width used when the terminal width is unknown.
+	DefaultWidth = 80
+	// MaxWidth caps the line width on very wide terminals to keep prose readable.
+	MaxWidth = 120
+	// DefaultLeftMargin is the left margin of rendered markdown.
+	DefaultLeftMargin = 6
+)
+
+var (
+	// citationPattern matches Perplexity citation markers such as [1] that are
+	// not part of an identifier or a markdown link (e.g. arr[1], [a][1]).
+	citationPattern = regexp.MustCompile(`(^|[^\w\]])\[(\d{1,3})\]`)
+	// linkPattern matches inline markdown links [text](url).
+	linkPattern = regexp.MustCompile(`\[([^\]\n]+)\]\((https?://[^)\s]+)\)`)
+	// headingPattern matches ATX headings.
+	headingPattern = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	// emphasisPattern matches strong emphasis (**x** or __x__).
+	emphasisPattern = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	// inlineCodePattern matches inline code spans.
+	inlineCodePattern = regexp.MustCompile("`([^`\n]+)`")
+)
+
+// Modes returns the supported mode names in display order.
+func Modes() []string {
+	return []string{string(ModeMarkdown), string(ModePlain), string(ModeRaw)}
+}
+
+// ParseMode validates a mode name. Returns clerrors.ErrInvalidRenderMode for unknown modes.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModeMarkdown, ModePlain, ModeRaw:
+		return m, nil
+	default:
+		return "", fmt.Errorf("%w: %q. Must be one of: %s",
+			clerrors.ErrInvalidRenderMode, s, strings.Join(Modes(), ", "))
+	}
+}
+
+// Resolve returns the mode named by s, or the default for the output when s
+// is empty: markdown for a terminal, plain otherwise.
+func Resolve(s string, isTTY bool) (Mode, error) {
+	if s != "" {
+		return ParseMode(s)
+	}
+	if isTTY {
+		return ModeMarkdown, nil
+	}
+	return ModePlain, nil
+}
+
+// Options tunes a Renderer.
+type Options struct {
+	// Width is the line width for markdown mode. Zero uses DefaultWidth.
+	Width int
+	// Hyperlinks enables OSC 8 links for citations and markdown links.
+	Hyperlinks bool
+	// NoColor removes the escape sequences of markdown mode, keeping its
+	// layout, for output that must not be colored.
+	NoColor bool
+	// Theme colors the citation markers of markdown mode; empty leaves
+	// them as is.
+	Theme color.Theme
+}
+
+// Renderer renders answer text in a given mode.
+type Renderer struct {
+	mode      Mode
+	opts      Options
+	citations []string
+	rewrite   func(string) string
+}
+
+// New creates a renderer for mode.
+func New(mode Mode, opts Options) *Renderer {
+	if opts.Width <= 0 {
+		opts.Width = DefaultWidth
+	}
+	return &Renderer{mode: mode, opts: opts}
+}
+
+// Mode returns the renderer's mode.
+func (r *Renderer) Mode() Mode {
+	return r.mode
+}
+
+// Options returns the renderer's options.
+func (r *Renderer) Options() Options {
+	return r.opts
+}
+
+// SetCitations sets the URLs that citation markers point to; marker [n]
+// links to urls[n-1], matching how Perplexity numbers its search results.
+func (r *Renderer) SetCitations(urls []string) {
+	r.citations = urls
+}
+
+// SetRewrite sets a function applied to content before it is rendered, such
+// as a citation style. Nil disables rewriting. Streamed raw output is written
+// as it arrives and is not rewritten.
+func (r *Renderer) SetRewrite(fn func(string) string) {
+	r.rewrite = fn
+}
+
+// Render converts content according to the renderer's mode.
+// The result ends with exactly one newline unless content is empty.
+func (r *Renderer) Render(content string) string {
+	if r.rewrite != nil {
+		content = r.rewrite(content)
+	}
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+
+	var out string
+	switch r.mode {
+	case ModeRaw:
+		return ensureNewline(content)
+	case ModePlain:
+		out = plain(content)
+	default:
+		out = string(markdown.Render(content, r.opts.Width, DefaultLeftMargin))
+		if r.opts.NoColor {
+			out = color.Strip(out)
+		} else {
+			out = r.linkify(out)
+		}
+	}
+	return ensureNewline(strings.TrimRight(out, "\n"))
+}
+
+// linkify turns citation markers and markdown links into OSC 8 hyperlinks
+// when hyperlinks are enabled, and colors citation markers when a theme is
+// set.
+func (r *Renderer) linkify(s string) string {
+	if r.opts.Hyperlinks {
+		s = linkPattern.ReplaceAllStringFunc(s, func(m string) string {
+			parts := linkPattern.FindStringSubmatch(m)
+			return Hyperlink(parts[2], parts[1])
+		})
+	}
+	linkCitations := r.opts.Hyperlinks && len(r.citations) > 0
+	if !linkCitations && r.opts.Theme == "" {
+		return s
+	}
+	return citationPattern.ReplaceAllStringFunc(s, func(m string) string {
+		parts := citationPattern.FindStringSubmatch(m)
+		marker := "[" + parts[2] + "]"
+		if r.opts.Theme != "" {
+			marker = r.opts.Theme.Paint(color.Citation, marker)
+		}
+		n, _ := strconv.Atoi(parts[2])
+		if !linkCitations || n < 1 || n > len(r.citations) || r.citations[n-1] == "" {
+			return parts[1] + marker
+		}
+		return parts[1] + Hyperlink(r.citations[n-1], marker)
+	})
+}
+
+// Hyperlink wraps text in an OSC 8 escape sequence pointing to url.
+func Hyperlink(url, text string) string {
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// plain strips markdown syntax while keeping the text layout: heading
+// markers, strong emphasis, inline code ticks, and code fences are removed,
+// and links become "text (url)". Code block contents are left untouched.
+func plain(content string) string {
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	inFence := false
+	for _, line := range lines {
+		if isFence(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+		line = headingPattern.ReplaceAllString(line, "")
+		line = linkPattern.ReplaceAllString(line, "$1 ($2)")
+		line = emphasisPattern.ReplaceAllString(line, "$2")
+		line = inlineCodePattern.ReplaceAllString(line, "$1")
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// isFence reports whether line opens or closes a fenced code block.
+func isFence(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// ensureNewline appends a newline to s when it lacks one.
+func ensureNewline(s string) string {
+	if strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
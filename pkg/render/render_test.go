@@ -0,0 +1,185 @@
+package render
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/output/color"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Mode
+		wantErr bool
+	}{
+		{"markdown", ModeMarkdown, false},
+		{"PLAIN", ModePlain, false},
+		{" raw ", ModeRaw, false},
+		{"html", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMode(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMode(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, clerrors.ErrInvalidRenderMode) {
+				t.Errorf("ParseMode(%q) error = %v, want ErrInvalidRenderMode", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMode(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  string
+		isTTY bool
+		want  Mode
+	}{
+		{"tty default", "", true, ModeMarkdown},
+		{"pipe default", "", false, ModePlain},
+		{"explicit overrides tty", "raw", true, ModeRaw},
+		{"explicit markdown when piped", "markdown", false, ModeMarkdown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.flag, tt.isTTY)
+			if err != nil {
+				t.Fatalf("Resolve unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q, %v) = %q, want %q", tt.flag, tt.isTTY, got, tt.want)
+			}
+		})
+	}
+}
+
+const sample = "## Findings\n\nGo is **fast** [1] and `simple` [2]. See [the docs](https://go.dev/doc).\n\n" +
+	"- one\n- two\n\n```go\nx := arr[1]\n**not bold**\n```\n"
+
+func TestRender_Raw(t *testing.T) {
+	r := New(ModeRaw, Options{})
+	if got := r.Render(sample); got != sample {
+		t.Errorf("raw render changed content:\n%q", got)
+	}
+}
+
+func TestRender_Plain(t *testing.T) {
+	got := New(ModePlain, Options{}).Render(sample)
+
+	for _, want := range []string{
+		"Findings\n",
+		"Go is fast [1] and simple [2]. See the docs (https://go.dev/doc).",
+		"- one\n- two",
+		"x := arr[1]\n**not bold**", // code block contents untouched
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("plain output missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"##", "```", "\x1b"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("plain output contains %q:\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestRender_MarkdownHyperlinks(t *testing.T) {
+	r := New(ModeMarkdown, Options{Hyperlinks: true})
+	r.SetCitations([]string{"https://a.example", "https://b.example"})
+	got := r.Render(sample)
+
+	if !strings.Contains(got, Hyperlink("https://a.example", "[1]")) {
+		t.Errorf("citation [1] not linked:\n%q", got)
+	}
+	if !strings.Contains(got, Hyperlink("https://b.example", "[2]")) {
+		t.Errorf("citation [2] not linked:\n%q", got)
+	}
+	if !strings.Contains(got, Hyperlink("https://go.dev/doc", "the docs")) {
+		t.Errorf("markdown link not linked:\n%q", got)
+	}
+	if strings.Contains(got, Hyperlink("https://a.example", "[1]")+"\n**") ||
+		strings.Count(got, "https://a.example") != 1 {
+		t.Errorf("arr[1] inside code should not be linked:\n%q", got)
+	}
+}
+
+func TestRender_MarkdownWithoutHyperlinks(t *testing.T) {
+	r := New(ModeMarkdown, Options{})
+	r.SetCitations([]string{"https://a.example"})
+	got := r.Render(sample)
+	if strings.Contains(got, "\x1b]8;;") {
+		t.Errorf("hyperlinks emitted when disabled:\n%q", got)
+	}
+	if !strings.Contains(got, "Findings") || !strings.Contains(got, "\x1b[1mfast") {
+		t.Errorf("markdown styling missing:\n%q", got)
+	}
+}
+
+func TestRender_MarkdownNoColor(t *testing.T) {
+	r := New(ModeMarkdown, Options{Hyperlinks: true, NoColor: true, Theme: color.ThemeDefault})
+	r.SetCitations([]string{"https://a.example"})
+	got := r.Render(sample)
+	if strings.Contains(got, "\x1b") {
+		t.Errorf("escape sequences written without color:\n%q", got)
+	}
+	if !strings.Contains(got, "Findings") || !strings.Contains(got, "[1]") {
+		t.Errorf("markdown layout missing:\n%q", got)
+	}
+}
+
+func TestRender_MarkdownTheme(t *testing.T) {
+	r := New(ModeMarkdown, Options{Theme: color.ThemeHighContrast})
+	got := r.Render("Go is fast [1].")
+	if want := color.ThemeHighContrast.Paint(color.Citation, "[1]"); !strings.Contains(got, want) {
+		t.Errorf("citation marker not colored:\n%q", got)
+	}
+
+	r = New(ModeMarkdown, Options{Hyperlinks: true, Theme: color.ThemeDefault})
+	r.SetCitations([]string{"https://a.example"})
+	got = r.Render("Go is fast [1].")
+	want := Hyperlink("https://a.example", color.ThemeDefault.Paint(color.Citation, "[1]"))
+	if !strings.Contains(got, want) {
+		t.Errorf("citation marker not linked and colored:\n%q", got)
+	}
+}
+
+func TestRender_Empty(t *testing.T) {
+	for _, m := range []Mode{ModeMarkdown, ModePlain, ModeRaw} {
+		if got := New(m, Options{}).Render("  \n"); got != "" {
+			t.Errorf("%s: Render(blank) = %q, want empty", m, got)
+		}
+	}
+}
+
+func TestSupportsHyperlinks(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{"unknown", map[string]string{"TERM": "xterm-256color"}, false},
+		{"dumb", map[string]string{"TERM": "dumb", "TERM_PROGRAM": "iTerm.app"}, false},
+		{"iterm", map[string]string{"TERM_PROGRAM": "iTerm.app"}, true},
+		{"windows terminal", map[string]string{"WT_SESSION": "abc"}, true},
+		{"new vte", map[string]string{"VTE_VERSION": "6003"}, true},
+		{"old vte", map[string]string{"VTE_VERSION": "4200"}, false},
+		{"kitty", map[string]string{"TERM": "xterm-kitty"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			if got := SupportsHyperlinks(getenv); got != tt.want {
+				t.Errorf("SupportsHyperlinks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// paragraphBreak separates markdown blocks.
+	paragraphBreak = "\n\n"
+	// clearLine erases the terminal line from the cursor to its end.
+	clearLine = "\x1b[K"
+)
+
+// Delta returns the text next adds to prev when next extends prev, which is
+// how Perplexity streams answers: every event carries the whole answer so
+// far. It reports false when next revises text prev already had.
+func Delta(prev, next string) (string, bool) {
+	if !strings.HasPrefix(next, prev) {
+		return "", false
+	}
+	return next[len(prev):], true
+}
+
+// StreamRenderer renders a streaming answer as it arrives.
+//
+// Perplexity streams cumulative content (every event carries the whole answer
+// so far); only the text each event adds is written. In raw mode new text is
+// written immediately. In markdown and plain modes text is buffered until a
+// paragraph is complete — a blank line outside a fenced code block — so that
+// each block is rendered with its full markup. Flush renders whatever remains
+// once the stream ends.
+//
+// When an event revises text that was already written, raw mode returns to
+// the start of the line with a carriage return and writes the line again;
+// revised lines above it cannot be redrawn and are written again below. The
+// other modes render the revised paragraph again.
+type StreamRenderer struct {
+	r       *Renderer
+	out     io.Writer
+	content string
+	emitted int
+	// tty enables erasing the current line before a redraw.
+	tty bool
+}
+
+// NewStreamRenderer creates a stream renderer writing to out.
+func NewStreamRenderer(r *Renderer, out io.Writer) *StreamRenderer {
+	f, ok := out.(*os.File)
+	return &StreamRenderer{r: r, out: out, tty: ok && IsTerminal(f)}
+}
+
+// Update receives the cumulative content so far and writes any newly completed output.
+// Content that is only a prefix of what was already received is ignored.
+func (s *StreamRenderer) Update(content string) error {
+	if strings.HasPrefix(s.content, content) {
+		return nil
+	}
+	if _, ok := Delta(s.content, content); !ok {
+		return s.revise(content)
+	}
+	s.content = content
+
+	if s.r.Mode() == ModeRaw {
+		return s.write(s.content[s.emitted:], len(s.content))
+	}
+
+	pending := s.content[s.emitted:]
+	end := completeBlocks(pending)
+	if end < 0 {
+		return nil
+	}
+	return s.writeBlock(pending[:end], s.emitted+end+len(paragraphBreak))
+}
+
+// Flush renders any buffered text. Call once the stream has ended.
+// In raw mode it only terminates the last line.
+func (s *StreamRenderer) Flush() error {
+	if s.r.Mode() == ModeRaw {
+		if s.content == "" || strings.HasSuffix(s.content, "\n") {
+			return nil
+		}
+		s.content += "\n"
+		return s.write("\n", len(s.content))
+	}
+	if s.emitted >= len(s.content) {
+		return nil
+	}
+	return s.writeBlock(s.content[s.emitted:], len(s.content))
+}
+
+// revise handles content that changes text of the previous update.
+func (s *StreamRenderer) revise(content string) error {
+	written := s.content[:s.emitted]
+	// changed is where content and the previous content diverge.
+	changed := commonPrefix(s.content, content)
+	s.content = content
+	if changed >= len(written) {
+		// Only buffered text changed.
+		return nil
+	}
+
+	if s.r.Mode() == ModeRaw {
+		// Redraw from the start of the line holding the first change.
+		lineStart := strings.LastIndex(content[:changed], "\n") + 1
+		var redraw string
+		switch {
+		case lineStart == strings.LastIndex(written, "\n")+1:
+			redraw = "\r"
+			if s.tty {
+				redraw += clearLine
+			}
+		case !strings.HasSuffix(written, "\n"):
+			// The change is above the current line: continue on a new one.
+			redraw = "\n"
+		}
+		return s.write(redraw+content[lineStart:], len(content))
+	}
+
+	// Render again from the paragraph holding the first change.
+	start := strings.LastIndex(content[:changed], paragraphBreak)
+	if start < 0 {
+		start = 0
+	} else {
+		start += len(paragraphBreak)
+	}
+	s.emitted = start
+	pending := content[start:]
+	if end := completeBlocks(pending); end >= 0 {
+		return s.writeBlock(pending[:end], start+end+len(paragraphBreak))
+	}
+	return nil
+}
+
+// commonPrefix returns the length of the longest common prefix of a and b.
+func commonPrefix(a, b string) int {
+	n := min(len(a), len(b))
+	for i := range n {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// writeBlock renders text and advances the emitted offset to next.
+func (s *StreamRenderer) writeBlock(text string, next int) error {
+	rendered := s.r.Render(text)
+	if rendered != "" && s.emitted > 0 {
+		rendered = "\n" + rendered
+	}
+	return s.write(rendered, next)
+}
+
+// write outputs text and advances the emitted offset to next.
+func (s *StreamRenderer) write(text string, next int) error {
+	if _, err := io.WriteString(s.out, text); err != nil {
+		return fmt.Errorf("error writing streaming content to output: %w", err)
+	}
+	s.emitted = next
+	return nil
+}
+
+// completeBlocks returns the end offset of the last paragraph break in
+// pending that is not inside a fenced code block, or -1 if there is none.
+func completeBlocks(pending string) int {
+	end := -1
+	inFence := false
+	offset := 0
+	for _, line := range strings.SplitAfter(pending, "\n") {
+		if !strings.HasSuffix(line, "\n") {
+			break // incomplete last line
+		}
+		if isFence(line) {
+			inFence = !inFence
+		}
+		offset += len(line)
+		if !inFence && line == "\n" && offset >= len(paragraphBreak) &&
+			pending[offset-len(paragraphBreak):offset] == paragraphBreak {
+			end = offset - len(paragraphBreak)
+		}
+	}
+	return end
+}
@@ -0,0 +1,192 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+// feed sends content to a stream renderer in cumulative chunks of size n and
+// records the output length after each update.
+func feed(t *testing.T, s *StreamRenderer, content string, n int, out *strings.Builder) []int {
+	t.Helper()
+	var lengths []int
+	for end := n; ; end += n {
+		if end > len(content) {
+			end = len(content)
+		}
+		if err := s.Update(content[:end]); err != nil {
+			t.Fatalf("Update unexpected error: %v", err)
+		}
+		lengths = append(lengths, out.Len())
+		if end == len(content) {
+			break
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush unexpected error: %v", err)
+	}
+	return lengths
+}
+
+func TestStreamRenderer_RawPassesThrough(t *testing.T) {
+	var out strings.Builder
+	s := NewStreamRenderer(New(ModeRaw, Options{}), &out)
+	lengths := feed(t, s, "Hello **world**", 3, &out)
+
+	if lengths[0] != 3 {
+		t.Errorf("raw mode should write immediately, wrote %d bytes after first chunk", lengths[0])
+	}
+	if out.String() != "Hello **world**\n" {
+		t.Errorf("raw output = %q", out.String())
+	}
+}
+
+func TestStreamRenderer_BuffersParagraphs(t *testing.T) {
+	content := "First **para**.\n\nSecond para.\n\nThird"
+	var out strings.Builder
+	s := NewStreamRenderer(New(ModePlain, Options{}), &out)
+
+	if err := s.Update("First **pa"); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("incomplete paragraph was written: %q", out.String())
+	}
+	if err := s.Update("First **para**.\n\nSec"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "First para.\n" {
+		t.Fatalf("after first paragraph output = %q", out.String())
+	}
+	if err := s.Update(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "First para.\n\nSecond para.\n\nThird\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestStreamRenderer_KeepsCodeBlocksTogether(t *testing.T) {
+	content := "Intro.\n\n```go\nfunc a() {}\n\nfunc b() {}\n```\n\nOutro."
+	var out strings.Builder
+	s := NewStreamRenderer(New(ModePlain, Options{}), &out)
+
+	if err := s.Update(content[:strings.Index(content, "func b")]); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Intro.\n" {
+		t.Fatalf("code block split at inner blank line: %q", out.String())
+	}
+
+	feed(t, s, content, 4, &out)
+	want := "Intro.\n\nfunc a() {}\n\nfunc b() {}\n\nOutro.\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestStreamRenderer_MatchesWholeRender(t *testing.T) {
+	// Per-paragraph streaming of plain text must equal rendering the whole answer.
+	content := "# Title\n\nSome `code` here.\n\n- a\n- b\n\nEnd."
+	var out strings.Builder
+	s := NewStreamRenderer(New(ModePlain, Options{}), &out)
+	feed(t, s, content, 5, &out)
+
+	if want := New(ModePlain, Options{}).Render(content); out.String() != want {
+		t.Errorf("streamed = %q, whole = %q", out.String(), want)
+	}
+}
+
+func TestDelta(t *testing.T) {
+	tests := []struct {
+		prev, next string
+		want       string
+		wantOK     bool
+	}{
+		{"", "Hello", "Hello", true},
+		{"Hello", "Hello world", " world", true},
+		{"Hello", "Hello", "", true},
+		{"Hello world", "Hello World", "", false},
+		{"Hello world", "Hello", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Delta(tt.prev, tt.next)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Delta(%q, %q) = %q, %v; want %q, %v", tt.prev, tt.next, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestStreamRenderer_RawWritesOnlyDeltas(t *testing.T) {
+	var out strings.Builder
+	s := NewStreamRenderer(New(ModeRaw, Options{}), &out)
+	// Overlapping cumulative chunks, some repeated.
+	for _, chunk := range []string{"Hel", "Hello", "Hello", "Hello wor", "Hello world"} {
+		if err := s.Update(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.String() != "Hello world" {
+		t.Errorf("output = %q, want each character written once", out.String())
+	}
+}
+
+func TestStreamRenderer_RawRevision(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{
+			name:   "current line is redrawn",
+			chunks: []string{"First line\nHello wor", "First line\nHello World", "First line\nHello World!"},
+			want:   "First line\nHello wor\rHello World!",
+		},
+		{
+			name:   "earlier line continues on a new line",
+			chunks: []string{"First line\nSecond", "Fixed line\nSecond"},
+			want:   "First line\nSecond\nFixed line\nSecond",
+		},
+		{
+			name:   "single line",
+			chunks: []string{"Hello", "Hello!", "Hello?"},
+			want:   "Hello!\rHello?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			s := NewStreamRenderer(New(ModeRaw, Options{}), &out)
+			for _, chunk := range tt.chunks {
+				if err := s.Update(chunk); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamRenderer_PlainRevision(t *testing.T) {
+	var out strings.Builder
+	s := NewStreamRenderer(New(ModePlain, Options{}), &out)
+	for _, chunk := range []string{"One.\n\nTwo.\n\nThr", "One.\n\nTwo!\n\nThree."} {
+		if err := s.Update(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// The revised paragraph is rendered again; the first one is not.
+	if got := out.String(); strings.Count(got, "One.") != 1 || !strings.Contains(got, "Two!") ||
+		!strings.HasSuffix(strings.TrimSpace(got), "Three.") {
+		t.Errorf("output = %q", got)
+	}
+}
@@ -0,0 +1,80 @@
+package render
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/output/color"
+	"golang.org/x/term"
+)
+
+// minVTEHyperlinkVersion is the first VTE release (0.50) with OSC 8 support.
+const minVTEHyperlinkVersion = 5000
+
+// IsTerminal reports whether f is attached to a terminal.
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd())) //nolint:gosec // file descriptors fit in int
+}
+
+// Width returns the line width to render at for f: the terminal width capped
+// at MaxWidth, or DefaultWidth when f is not a terminal.
+func Width(f *os.File) int {
+	w, _, err := term.GetSize(int(f.Fd())) //nolint:gosec // file descriptors fit in int
+	if err != nil || w <= 0 {
+		return DefaultWidth
+	}
+	return min(w, MaxWidth)
+}
+
+// Height returns the number of lines of the terminal f is attached to, or 0
+// when f is not a terminal.
+func Height(f *os.File) int {
+	_, h, err := term.GetSize(int(f.Fd())) //nolint:gosec // file descriptors fit in int
+	if err != nil || h <= 0 {
+		return 0
+	}
+	return h
+}
+
+// SupportsHyperlinks guesses from the environment whether the terminal
+// renders OSC 8 hyperlinks. Unknown terminals are assumed not to, since
+// unsupported escape sequences show up as garbage.
+func SupportsHyperlinks(getenv func(string) string) bool {
+	if getenv("TERM") == "dumb" {
+		return false
+	}
+	switch getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper":
+		return true
+	}
+	if getenv("WT_SESSION") != "" || getenv("KITTY_WINDOW_ID") != "" || getenv("KONSOLE_VERSION") != "" {
+		return true
+	}
+	if v, err := strconv.Atoi(getenv("VTE_VERSION")); err == nil && v >= minVTEHyperlinkVersion {
+		return true
+	}
+	return strings.Contains(getenv("TERM"), "kitty") || strings.Contains(getenv("TERM"), "alacritty")
+}
+
+// ForStdout builds a renderer for os.Stdout from a --render flag value,
+// applying the TTY-based default and detecting width and hyperlink support.
+// Colors follow color.Enabled: --color=always selects markdown for piped
+// output too, and markdown written without color keeps only its layout.
+func ForStdout(mode string) (*Renderer, error) {
+	tty := IsTerminal(os.Stdout)
+	colored := color.Enabled(os.Stdout)
+	m, err := Resolve(mode, tty || colored)
+	if err != nil {
+		return nil, err
+	}
+	opts := Options{
+		Width:      Width(os.Stdout),
+		Hyperlinks: tty && colored && SupportsHyperlinks(os.Getenv),
+		NoColor:    !colored,
+	}
+	if colored {
+		opts.Theme = color.CurrentTheme()
+	}
+	return New(m, opts), nil
+}
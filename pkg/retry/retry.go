@@ -0,0 +1,247 @@
+// Package retry provides an HTTP transport that retries Perplexity API calls
+// failing with transient status codes (429, 500, 502, 503, 504).
+//
+// Retries happen below the perplexity-go client, so every call path (chat,
+// query, streaming, MCP) gets the same behavior without duplicating loops.
+// Delays use exponential backoff with full jitter, capped at Policy.MaxBackoff,
+// and a Retry-After header from the server takes precedence when present.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/searches"
+)
+
+// Default retry settings.
+const (
+	// DefaultMaxRetries is the number of retries after the initial attempt.
+	DefaultMaxRetries = 3
+	// DefaultMaxBackoff caps the delay between two attempts.
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultBaseDelay is the delay before the first retry, doubled on each attempt.
+	DefaultBaseDelay = 500 * time.Millisecond
+)
+
+// maxErrorBodyBytes bounds how much of an error response body is read.
+const maxErrorBodyBytes = 64 << 10
+
+// Policy controls how many times and how long to wait between retries.
+type Policy struct {
+	// MaxRetries is the number of retries after the first attempt. Zero disables retries.
+	MaxRetries int
+	// MaxBackoff caps the delay between attempts, including Retry-After values.
+	MaxBackoff time.Duration
+	// BaseDelay is the initial backoff delay.
+	BaseDelay time.Duration
+}
+
+// DefaultPolicy returns the policy used when nothing is configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxRetries: DefaultMaxRetries,
+		MaxBackoff: DefaultMaxBackoff,
+		BaseDelay:  DefaultBaseDelay,
+	}
+}
+
+// NewPolicy builds a policy from user settings, falling back to defaults
+// for a non-positive maxBackoff. A negative maxRetries is treated as zero.
+func NewPolicy(maxRetries int, maxBackoff time.Duration) Policy {
+	p := DefaultPolicy()
+	p.MaxRetries = max(maxRetries, 0)
+	if maxBackoff > 0 {
+		p.MaxBackoff = maxBackoff
+	}
+	if p.BaseDelay > p.MaxBackoff {
+		p.BaseDelay = p.MaxBackoff
+	}
+	return p
+}
+
+// Backoff returns the jittered delay before retry number attempt (0-based).
+// The upper bound grows as BaseDelay*2^attempt and never exceeds MaxBackoff.
+func (p Policy) Backoff(attempt int) time.Duration {
+	ceiling := p.MaxBackoff
+	if p.BaseDelay > 0 && attempt < 32 { //nolint:mnd // avoid shift overflow
+		if d := p.BaseDelay << attempt; d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1)) //nolint:gosec // jitter does not need crypto randomness
+}
+
+// IsRetryableStatus reports whether an HTTP status code is worth retrying.
+func IsRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// ParseRetryAfter parses a Retry-After header value, either delay-seconds or
+// an HTTP date. Returns false when the header is empty or malformed.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// Transport is an http.RoundTripper that retries transient API failures.
+//
+// Non-retryable responses (e.g. 400, 401) are returned untouched so the
+// perplexity-go client reports them as usual. When retries are exhausted the
+// transport returns an error wrapping clerrors.ErrRetriesExhausted and a
+// clerrors.APIError carrying the last status code and API message.
+type Transport struct {
+	Base   http.RoundTripper
+	Policy Policy
+
+	// sleep waits for d or until ctx is done; replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewTransport wraps base (http.DefaultTransport when nil) with policy p.
+func NewTransport(base http.RoundTripper, p Policy) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base, Policy: p, sleep: sleepContext}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.Base.RoundTrip(req)
+		if err != nil || !IsRetryableStatus(resp.StatusCode) || t.Policy.MaxRetries <= 0 {
+			return resp, err //nolint:wrapcheck // transport errors are wrapped by http.Client
+		}
+
+		// A request whose body cannot be replayed must not be retried.
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+
+		if attempt >= t.Policy.MaxRetries {
+			return nil, exhaustedError(resp, attempt+1)
+		}
+
+		delay := t.Policy.Backoff(attempt)
+		if ra, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			delay = min(ra, t.Policy.MaxBackoff)
+		}
+		drainAndClose(resp)
+
+		logger.Debug("retrying API request",
+			"status", resp.StatusCode,
+			"attempt", attempt+1,
+			"max_retries", t.Policy.MaxRetries,
+			"delay", delay)
+
+		if err := t.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Configure installs a retrying transport on client with the phase limits
+// of t. t.Total bounds the whole call, retries included; when zero the
+// client's current timeout is kept. Every attempt is logged at debug level
+// by logger.HTTPTransport, and the search queries of completions are
+// recorded by searches.Transport. Each of wrap, in order, wraps the retrying
+// transport, so that it sees every request once before any retry.
+func Configure(client *perplexity.Client, p Policy, t Timeouts, wrap ...func(http.RoundTripper) http.RoundTripper) {
+	if t.Total <= 0 {
+		t.Total = client.GetHTTPTimeout()
+	}
+	var transport http.RoundTripper = searches.NewTransport(NewTransport(logger.NewHTTPTransport(t.Transport()), p))
+	for _, w := range wrap {
+		transport = w(transport)
+	}
+	client.SetHTTPClient(&http.Client{
+		Timeout:   t.Total,
+		Transport: transport,
+	})
+}
+
+// exhaustedError builds the final error from the last retryable response.
+func exhaustedError(resp *http.Response, attempts int) error {
+	defer resp.Body.Close() //nolint:errcheck // best-effort close of error body
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+
+	message := http.StatusText(resp.StatusCode)
+	var respErr *perplexity.ResponseError
+	if errors.As(perplexity.ParseErrorMessage(body), &respErr) && respErr.ErrorData.Message != "" {
+		message = respErr.ErrorData.Message
+	}
+
+	apiErr := &clerrors.APIError{StatusCode: resp.StatusCode, Message: message}
+	return fmt.Errorf("%w after %d attempts: %w", clerrors.ErrRetriesExhausted, attempts, apiErr)
+}
+
+// rewind returns a copy of req with a fresh body for the next attempt.
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	next := req.Clone(req.Context())
+	next.Body = body
+	return next, nil
+}
+
+// drainAndClose discards a bounded amount of the body so the connection can be reused.
+func drainAndClose(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodyBytes))
+	_ = resp.Body.Close()
+}
+
+// sleepContext waits for d or until ctx is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("retry wait cancelled: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,265 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+const completionJSON = `{"id":"x","model":"sonar","created":1,"object":"chat.completion",
+	"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2},
+	"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"},
+	"delta":{"role":"","content":""}}]}`
+
+// newTestClient returns a perplexity client pointed at srv with a retrying
+// transport whose sleeps are recorded instead of performed.
+func newTestClient(t *testing.T, srv *httptest.Server, p Policy) (*perplexity.Client, *[]time.Duration) {
+	t.Helper()
+	var slept []time.Duration
+	tr := NewTransport(nil, p)
+	tr.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	client.SetHTTPClient(&http.Client{Transport: tr, Timeout: 5 * time.Second})
+	return client, &slept
+}
+
+func newTestRequest() *perplexity.CompletionRequest {
+	msg := perplexity.NewMessages()
+	_ = msg.AddUserMessage("hello")
+	return perplexity.NewCompletionRequest(
+		perplexity.WithMessages(msg.GetMessages()),
+		perplexity.WithModel("sonar"),
+	)
+}
+
+// failingServer answers the first failures requests with status, then succeeds.
+func failingServer(status, failures int, header http.Header, calls *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "hello") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if int(n) <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"error":{"message":"slow down","type":"rate_limit","code":429}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(completionJSON))
+	}))
+}
+
+func TestTransport_RetriesUntilSuccess(t *testing.T) {
+	for _, status := range []int{429, 500, 502, 503, 504} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			var calls atomic.Int32
+			srv := failingServer(status, 2, nil, &calls)
+			defer srv.Close()
+
+			client, slept := newTestClient(t, srv, DefaultPolicy())
+			res, err := client.SendCompletionRequest(newTestRequest())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.GetLastContent() != "ok" {
+				t.Errorf("content = %q, want %q", res.GetLastContent(), "ok")
+			}
+			if got := calls.Load(); got != 3 {
+				t.Errorf("calls = %d, want 3", got)
+			}
+			if len(*slept) != 2 {
+				t.Errorf("sleeps = %d, want 2", len(*slept))
+			}
+		})
+	}
+}
+
+func TestTransport_NonRetryableFailsImmediately(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			var calls atomic.Int32
+			srv := failingServer(status, 10, nil, &calls)
+			defer srv.Close()
+
+			client, _ := newTestClient(t, srv, DefaultPolicy())
+			_, err := client.SendCompletionRequest(newTestRequest())
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if errors.Is(err, clerrors.ErrRetriesExhausted) {
+				t.Errorf("non-retryable status should not report exhausted retries: %v", err)
+			}
+			if got := calls.Load(); got != 1 {
+				t.Errorf("calls = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestTransport_ExhaustedWrapsAPIError(t *testing.T) {
+	var calls atomic.Int32
+	srv := failingServer(http.StatusTooManyRequests, 100, nil, &calls)
+	defer srv.Close()
+
+	client, _ := newTestClient(t, srv, NewPolicy(2, time.Second))
+	_, err := client.SendCompletionRequest(newTestRequest())
+	if !errors.Is(err, clerrors.ErrRetriesExhausted) {
+		t.Fatalf("error = %v, want ErrRetriesExhausted", err)
+	}
+	var apiErr *clerrors.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected clerrors.APIError in chain, got %T", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("StatusCode = %d, want 429", apiErr.StatusCode)
+	}
+	if apiErr.Message != "slow down" {
+		t.Errorf("Message = %q, want %q", apiErr.Message, "slow down")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want 3 (1 attempt + 2 retries)", got)
+	}
+}
+
+func TestTransport_ZeroRetriesPassesThrough(t *testing.T) {
+	var calls atomic.Int32
+	srv := failingServer(http.StatusServiceUnavailable, 10, nil, &calls)
+	defer srv.Close()
+
+	client, _ := newTestClient(t, srv, NewPolicy(0, 0))
+	_, err := client.SendCompletionRequest(newTestRequest())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if errors.Is(err, clerrors.ErrRetriesExhausted) {
+		t.Errorf("retries disabled should not report exhausted retries: %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestTransport_HonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	header := http.Header{"Retry-After": []string{"7"}}
+	srv := failingServer(http.StatusTooManyRequests, 1, header, &calls)
+	defer srv.Close()
+
+	client, slept := newTestClient(t, srv, NewPolicy(3, 10*time.Second))
+	if _, err := client.SendCompletionRequest(newTestRequest()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 7*time.Second {
+		t.Errorf("sleeps = %v, want [7s]", *slept)
+	}
+}
+
+func TestTransport_RetryAfterCappedByMaxBackoff(t *testing.T) {
+	var calls atomic.Int32
+	header := http.Header{"Retry-After": []string{"120"}}
+	srv := failingServer(http.StatusTooManyRequests, 1, header, &calls)
+	defer srv.Close()
+
+	client, slept := newTestClient(t, srv, NewPolicy(3, 2*time.Second))
+	if _, err := client.SendCompletionRequest(newTestRequest()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 2*time.Second {
+		t.Errorf("sleeps = %v, want [2s]", *slept)
+	}
+}
+
+func TestTransport_ContextCancelledDuringWait(t *testing.T) {
+	var calls atomic.Int32
+	srv := failingServer(http.StatusTooManyRequests, 10, http.Header{"Retry-After": []string{"30"}}, &calls)
+	defer srv.Close()
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	client.SetHTTPClient(&http.Client{Transport: NewTransport(nil, NewPolicy(3, time.Minute))})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.SendCompletionRequestWithContext(ctx, newTestRequest())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := Policy{MaxRetries: 5, BaseDelay: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{40, time.Second},
+	}
+	for _, tt := range tests {
+		for range 50 {
+			if d := p.Backoff(tt.attempt); d < 0 || d > tt.ceiling {
+				t.Fatalf("Backoff(%d) = %v, want within [0, %v]", tt.attempt, d, tt.ceiling)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "5", 5 * time.Second, true},
+		{"negative", "-1", 0, false},
+		{"http date", now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second, true},
+		{"past date", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"garbage", "soon", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseRetryAfter(%q) = (%v, %v), want (%v, %v)", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	p := NewPolicy(-1, 0)
+	if p.MaxRetries != 0 {
+		t.Errorf("MaxRetries = %d, want 0", p.MaxRetries)
+	}
+	if p.MaxBackoff != DefaultMaxBackoff {
+		t.Errorf("MaxBackoff = %v, want default %v", p.MaxBackoff, DefaultMaxBackoff)
+	}
+	p = NewPolicy(2, 100*time.Millisecond)
+	if p.BaseDelay > p.MaxBackoff {
+		t.Errorf("BaseDelay %v should not exceed MaxBackoff %v", p.BaseDelay, p.MaxBackoff)
+	}
+}
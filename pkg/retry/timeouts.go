@@ -0,0 +1,131 @@
+package retry
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Phase names the part of an API call a timeout applies to.
+type Phase string
+
+// Timeout phases, in the order they occur.
+const (
+	// PhaseConnect covers DNS resolution and the TCP connection.
+	PhaseConnect Phase = "connect"
+	// PhaseResponseHeader is the wait for response headers once the request is sent.
+	PhaseResponseHeader Phase = "response header"
+	// PhaseTotal bounds the whole call, retries and body included.
+	PhaseTotal Phase = "total"
+)
+
+// responseHeaderTimeoutMsg is how net/http reports an exceeded
+// Transport.ResponseHeaderTimeout; the error has no exported type.
+const responseHeaderTimeoutMsg = "timeout awaiting response headers"
+
+// Timeouts bounds the phases of an API call. A zero Connect or
+// ResponseHeader leaves that phase limited only by Total.
+type Timeouts struct {
+	Connect        time.Duration
+	ResponseHeader time.Duration
+	// Total bounds one call, retries included. In chat it applies per turn.
+	Total time.Duration
+	// Proxy routes API calls through an HTTP proxy. Nil uses the
+	// HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy *url.URL
+	// TLS configures certificate verification and client certificates of
+	// the API connection. Nil uses the system roots.
+	TLS *tls.Config
+}
+
+// Transport returns a clone of http.DefaultTransport with the connect and
+// response-header limits, the proxy and the TLS configuration of t.
+func (t Timeouts) Transport() *http.Transport {
+	base, _ := http.DefaultTransport.(*http.Transport)
+	tr := base.Clone()
+	tr.ResponseHeaderTimeout = t.ResponseHeader
+	if t.Proxy != nil {
+		tr.Proxy = http.ProxyURL(t.Proxy)
+	}
+	if t.TLS != nil {
+		tr.TLSClientConfig = t.TLS.Clone()
+	}
+	if t.Connect > 0 {
+		dialer := &net.Dialer{Timeout: t.Connect, KeepAlive: 30 * time.Second} //nolint:mnd // net/http default
+		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			// Only the dialer's own limit is a connect timeout; a cancelled
+			// ctx means the total timeout fired first.
+			if err != nil && ctx.Err() == nil && isTimeout(err) {
+				return nil, &connectTimeoutError{err: err}
+			}
+			return conn, err //nolint:wrapcheck // dial errors are wrapped by http.Client
+		}
+	}
+	return tr
+}
+
+// connectTimeoutError marks a dial that exceeded Timeouts.Connect.
+type connectTimeoutError struct {
+	err error
+}
+
+func (e *connectTimeoutError) Error() string { return e.err.Error() }
+func (e *connectTimeoutError) Unwrap() error { return e.err }
+func (e *connectTimeoutError) Timeout() bool { return true }
+
+// TimeoutError reports which phase of an API call ran out of time.
+type TimeoutError struct {
+	Phase Phase
+	Err   error
+}
+
+func (e *TimeoutError) Error() string {
+	var reason string
+	switch e.Phase {
+	case PhaseConnect:
+		reason = "could not connect to the API"
+	case PhaseResponseHeader:
+		reason = "the API accepted the request but did not start responding"
+	default:
+		reason = "the request did not complete in time"
+	}
+	return fmt.Sprintf("%s timeout: %s: %v", e.Phase, reason, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// ClassifyTimeout returns err wrapped in a *TimeoutError naming the phase
+// that timed out, or err unchanged when it is not a timeout.
+func ClassifyTimeout(err error) error {
+	if err == nil {
+		return nil
+	}
+	var te *TimeoutError
+	if errors.As(err, &te) {
+		return err
+	}
+	var connectErr *connectTimeoutError
+	switch {
+	case errors.As(err, &connectErr):
+		return &TimeoutError{Phase: PhaseConnect, Err: err}
+	case strings.Contains(err.Error(), responseHeaderTimeoutMsg):
+		return &TimeoutError{Phase: PhaseResponseHeader, Err: err}
+	case errors.Is(err, context.DeadlineExceeded) || isTimeout(err):
+		return &TimeoutError{Phase: PhaseTotal, Err: err}
+	}
+	return err
+}
+
+// isTimeout reports whether err, or an error it wraps, is a network timeout.
+func isTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
@@ -0,0 +1,133 @@
+package retry
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+// newHangingServer returns a server that holds every request until the test ends.
+func newHangingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-done
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(done) })
+	return srv
+}
+
+func TestConfigure_ResponseHeaderTimeout(t *testing.T) {
+	srv := newHangingServer(t)
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	Configure(client, Policy{}, Timeouts{ResponseHeader: 20 * time.Millisecond, Total: 5 * time.Second})
+
+	_, err := client.SendCompletionRequest(newTestRequest())
+	var te *TimeoutError
+	if !errors.As(ClassifyTimeout(err), &te) || te.Phase != PhaseResponseHeader {
+		t.Fatalf("err = %v, want a %s timeout", ClassifyTimeout(err), PhaseResponseHeader)
+	}
+}
+
+func TestConfigure_TotalTimeout(t *testing.T) {
+	srv := newHangingServer(t)
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint(srv.URL)
+	Configure(client, Policy{}, Timeouts{Total: 20 * time.Millisecond})
+
+	_, err := client.SendCompletionRequest(newTestRequest())
+	var te *TimeoutError
+	if !errors.As(ClassifyTimeout(err), &te) || te.Phase != PhaseTotal {
+		t.Fatalf("err = %v, want a %s timeout", ClassifyTimeout(err), PhaseTotal)
+	}
+	if !strings.Contains(te.Error(), "total timeout") {
+		t.Errorf("message %q should name the phase", te.Error())
+	}
+}
+
+func TestConfigure_KeepsClientTimeoutWhenTotalUnset(t *testing.T) {
+	client := perplexity.NewClient("test-key")
+	client.SetHTTPTimeout(42 * time.Second)
+	Configure(client, Policy{}, Timeouts{Connect: time.Second})
+	if got := client.GetHTTPTimeout(); got != 42*time.Second {
+		t.Errorf("timeout = %v, want 42s", got)
+	}
+}
+
+func TestConfigure_Proxy(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A proxied request carries the target in its absolute URL.
+		proxiedHost = r.URL.Host
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","model":"sonar","choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := perplexity.NewClient("test-key")
+	client.SetEndpoint("http://gateway.example.test/chat/completions")
+	Configure(client, Policy{}, Timeouts{Total: 5 * time.Second, Proxy: proxyURL})
+
+	if _, err := client.SendCompletionRequest(newTestRequest()); err != nil {
+		t.Fatalf("request through proxy failed: %v", err)
+	}
+	if proxiedHost != "gateway.example.test" {
+		t.Errorf("proxy saw host %q, want gateway.example.test", proxiedHost)
+	}
+}
+
+func TestClassifyTimeout(t *testing.T) {
+	dialTimeout := &net.OpError{Op: "dial", Err: timeoutErr{}}
+	tests := []struct {
+		name  string
+		err   error
+		phase Phase
+	}{
+		{"connect", &connectTimeoutError{err: dialTimeout}, PhaseConnect},
+		{"dial timeout from the total deadline", dialTimeout, PhaseTotal},
+		{"response header", errors.New("net/http: timeout awaiting response headers"), PhaseResponseHeader},
+		{"other", errors.New("connection refused"), ""},
+		{"nil", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyTimeout(tt.err)
+			var te *TimeoutError
+			if !errors.As(got, &te) {
+				if tt.phase != "" {
+					t.Fatalf("ClassifyTimeout(%v) = %v, want a %s timeout", tt.err, got, tt.phase)
+				}
+				if got != tt.err { //nolint:errorlint // non-timeouts must be returned unchanged
+					t.Errorf("ClassifyTimeout(%v) = %v, want it unchanged", tt.err, got)
+				}
+				return
+			}
+			if te.Phase != tt.phase {
+				t.Errorf("phase = %q, want %q", te.Phase, tt.phase)
+			}
+			if ClassifyTimeout(got) != got { //nolint:errorlint // classifying twice must not re-wrap
+				t.Error("ClassifyTimeout should not wrap a TimeoutError twice")
+			}
+		})
+	}
+}
+
+// timeoutErr is a net.Error that reports a timeout.
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
@@ -0,0 +1,170 @@
+// Package searches keeps the search queries the API reports it ran for a
+// completion. The client does not decode the search_queries field of
+// responses, so Transport reads it from the response body and records it
+// under the response ID, where Lookup finds it.
+package searches
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// maxRecorded bounds how many responses keep their queries; the oldest are
+// forgotten first.
+const maxRecorded = 256
+
+// registry maps response IDs to their search queries.
+type registry struct {
+	mu      sync.Mutex
+	queries map[string][]string
+	order   []string
+}
+
+//nolint:gochecknoglobals // shared by every client, like the default HTTP transport
+var recorded = &registry{queries: make(map[string][]string)}
+
+// Record stores queries as the searches of the response id. Empty ids or
+// query lists are ignored.
+func Record(id string, queries []string) {
+	if id == "" || len(queries) == 0 {
+		return
+	}
+	recorded.mu.Lock()
+	defer recorded.mu.Unlock()
+	if _, ok := recorded.queries[id]; !ok {
+		recorded.order = append(recorded.order, id)
+	}
+	recorded.queries[id] = slices.Clone(queries)
+	for len(recorded.order) > maxRecorded {
+		delete(recorded.queries, recorded.order[0])
+		recorded.order = recorded.order[1:]
+	}
+}
+
+// Lookup returns the search queries of the response id, or nil when the API
+// reported none.
+func Lookup(id string) []string {
+	recorded.mu.Lock()
+	defer recorded.mu.Unlock()
+	return slices.Clone(recorded.queries[id])
+}
+
+// Transport is an http.RoundTripper that records the search queries of the
+// completions passing through it. Response bodies are read by the caller as
+// usual; JSON bodies are inspected once read to the end and streamed
+// (text/event-stream) bodies event by event.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// NewTransport wraps base (http.DefaultTransport when nil).
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Body == nil {
+		return resp, err //nolint:wrapcheck // transparent transport
+	}
+	resp.Body = &captureBody{
+		ReadCloser: resp.Body,
+		stream:     strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"),
+	}
+	return resp, nil
+}
+
+// captureBody passes a response body through, recording the search queries
+// it carries.
+type captureBody struct {
+	io.ReadCloser
+	stream bool
+	buf    bytes.Buffer
+	done   bool
+}
+
+// Read implements io.Reader.
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.done {
+		return n, err //nolint:wrapcheck // transparent body
+	}
+	b.buf.Write(p[:n])
+	if b.stream {
+		for {
+			i := bytes.IndexByte(b.buf.Bytes(), '\n')
+			if i < 0 {
+				break
+			}
+			recordEvent(b.buf.Next(i + 1))
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		b.done = true
+		if b.stream {
+			recordEvent(b.buf.Bytes())
+		} else {
+			recordJSON(b.buf.Bytes())
+		}
+		b.buf.Reset()
+	}
+	return n, err //nolint:wrapcheck // transparent body
+}
+
+// recordEvent records the queries of one server-sent event line.
+func recordEvent(line []byte) {
+	if data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:")); ok {
+		recordJSON(bytes.TrimSpace(data))
+	}
+}
+
+// recordJSON records the queries of a completion response body.
+func recordJSON(data []byte) {
+	if !bytes.Contains(data, []byte(`"search_queries"`)) {
+		return
+	}
+	var body struct {
+		ID            string          `json:"id"`
+		SearchQueries json.RawMessage `json:"search_queries"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		return
+	}
+	Record(body.ID, Parse(body.SearchQueries))
+}
+
+// Parse decodes a search_queries value: a list of strings, or of objects
+// with a query field. Blank and malformed entries are skipped.
+func Parse(raw json.RawMessage) []string {
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) != nil {
+		return nil
+	}
+	var queries []string
+	for _, item := range items {
+		var query string
+		if json.Unmarshal(item, &query) != nil {
+			var obj struct {
+				Query string `json:"query"`
+			}
+			if json.Unmarshal(item, &obj) != nil {
+				continue
+			}
+			query = obj.Query
+		}
+		if query = strings.TrimSpace(query); query != "" {
+			queries = append(queries, query)
+		}
+	}
+	return queries
+}
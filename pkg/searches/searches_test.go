@@ -0,0 +1,91 @@
+package searches
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"strings", `["go generics", " go 1.25 "]`, []string{"go generics", "go 1.25"}},
+		{"objects", `[{"query":"go generics"},{"query":""},{"other":1}]`, []string{"go generics"}},
+		{"mixed", `["a", 3, {"query":"b"}]`, []string{"a", "b"}},
+		{"not a list", `"go"`, nil},
+		{"null", `null`, nil},
+	}
+	for _, tt := range tests {
+		if got := Parse(json.RawMessage(tt.raw)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Parse(%s) = %q, want %q", tt.name, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestRecord_Bounded(t *testing.T) {
+	for i := range maxRecorded + 1 {
+		Record(fmt.Sprintf("bounded-%d", i), []string{"q"})
+	}
+	if got := Lookup("bounded-0"); got != nil {
+		t.Errorf("oldest response kept %q, want it forgotten", got)
+	}
+	if got := Lookup(fmt.Sprintf("bounded-%d", maxRecorded)); !slices.Equal(got, []string{"q"}) {
+		t.Errorf("newest response = %q, want [q]", got)
+	}
+
+	Record("", []string{"q"})
+	Record("empty", nil)
+	if Lookup("") != nil || Lookup("empty") != nil {
+		t.Error("empty ids or query lists were recorded")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		id          string
+		want        []string
+	}{
+		{"json", "application/json",
+			`{"id":"tr-json","choices":[],"search_queries":["go release"]}`, "tr-json", []string{"go release"}},
+		{"stream", "text/event-stream",
+			"data: {\"id\":\"tr-sse\",\"choices\":[]}\n\n" +
+				"data: {\"id\":\"tr-sse\",\"search_queries\":[{\"query\":\"go news\"}]}\n\ndata: [DONE]\n",
+			"tr-sse", []string{"go news"}},
+		{"stream without final newline", "text/event-stream",
+			`data: {"id":"tr-tail","search_queries":["tail"]}`, "tr-tail", []string{"tail"}},
+		{"absent", "application/json", `{"id":"tr-none","choices":[]}`, "tr-none", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: NewTransport(nil)}
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if err != nil || string(body) != tt.body {
+				t.Fatalf("body = %q, %v; want it passed through unchanged", body, err)
+			}
+			if got := Lookup(tt.id); !slices.Equal(got, tt.want) {
+				t.Errorf("Lookup(%s) = %q, want %q", tt.id, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package usage
+
+import (
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// SpentToday returns the estimated cost of the requests in the usage log
+// since midnight, in the local time zone. Unpriced requests count as free.
+func (t *Tracker) SpentToday() (float64, error) {
+	records, err := ReadFile(t.path)
+	if err != nil {
+		return 0, err
+	}
+	now := t.now()
+	year, month, day := now.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	tomorrow := midnight.AddDate(0, 0, 1)
+
+	var spent float64
+	for _, rec := range records {
+		if !rec.Time.Before(midnight) && rec.Time.Before(tomorrow) {
+			spent += rec.Cost
+		}
+	}
+	return spent, nil
+}
+
+// ProjectCost returns the highest expected cost of a request to model with
+// about promptTokens prompt tokens and at most maxTokens completion tokens.
+// The boolean is false for models missing from the price table.
+func ProjectCost(model string, promptTokens, maxTokens int) (float64, bool) {
+	return EstimateCost(model, perplexity.Usage{PromptTokens: promptTokens, CompletionTokens: maxTokens})
+}
+
+// CheckBudget returns a *clerrors.BudgetExceededError when a request
+// projected to cost projected would take today's spending over budget, in
+// USD. A budget of zero or less is unlimited.
+func (t *Tracker) CheckBudget(budget, projected float64) error {
+	if budget <= 0 {
+		return nil
+	}
+	spent, err := t.SpentToday()
+	if err != nil {
+		return err
+	}
+	if spent+projected > budget {
+		return clerrors.NewBudgetExceededError(budget, spent, projected)
+	}
+	return nil
+}
+
+// CheckBudget is Tracker.CheckBudget with the default tracker.
+func CheckBudget(budget, projected float64) error {
+	if budget <= 0 {
+		return nil
+	}
+	tracker, err := Default()
+	if err != nil {
+		return err
+	}
+	return tracker.CheckBudget(budget, projected)
+}
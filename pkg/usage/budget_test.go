@@ -0,0 +1,116 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// writeLog writes records as the usage log at path.
+func writeLog(t *testing.T, path string, records ...Record) {
+	t.Helper()
+	var b strings.Builder
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrackerSpentToday_DayBoundary(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	writeLog(t, path,
+		// 23:30 local on May 5th, the previous day.
+		Record{Time: time.Date(2025, 5, 5, 21, 30, 0, 0, time.UTC), Cost: 0.50, Priced: true},
+		// 00:00 local on May 6th, exactly midnight.
+		Record{Time: time.Date(2025, 5, 5, 22, 0, 0, 0, time.UTC), Cost: 0.25, Priced: true},
+		Record{Time: time.Date(2025, 5, 6, 9, 0, 0, 0, time.UTC), Cost: 0.10, Priced: true},
+		Record{Time: time.Date(2025, 5, 6, 9, 5, 0, 0, time.UTC), Model: "custom"},
+	)
+	tracker := NewTracker(path)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want float64
+	}{
+		{"before midnight", time.Date(2025, 5, 5, 23, 45, 0, 0, loc), 0.50},
+		{"after midnight", time.Date(2025, 5, 6, 12, 0, 0, 0, loc), 0.35},
+		{"next day", time.Date(2025, 5, 7, 0, 1, 0, 0, loc), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker.now = func() time.Time { return tt.now }
+			spent, err := tracker.SpentToday()
+			if err != nil {
+				t.Fatalf("SpentToday() error = %v", err)
+			}
+			if math.Abs(spent-tt.want) > 1e-9 {
+				t.Errorf("SpentToday() = %v, want %v", spent, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackerSpentToday_NoLog(t *testing.T) {
+	tracker := NewTracker(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if spent, err := tracker.SpentToday(); err != nil || spent != 0 {
+		t.Errorf("SpentToday() = %v, %v, want 0, nil", spent, err)
+	}
+}
+
+func TestTrackerCheckBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, time.UTC)
+	writeLog(t, path, Record{Time: now.Add(-time.Hour), Cost: 0.90, Priced: true})
+	tracker := NewTracker(path)
+	tracker.now = func() time.Time { return now }
+
+	if err := tracker.CheckBudget(1, 0.05); err != nil {
+		t.Errorf("CheckBudget() under budget error = %v", err)
+	}
+	if err := tracker.CheckBudget(0, 100); err != nil {
+		t.Errorf("CheckBudget() with no budget error = %v", err)
+	}
+
+	err := tracker.CheckBudget(1, 0.2)
+	var budgetErr *clerrors.BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("CheckBudget() error = %v, want BudgetExceededError", err)
+	}
+	if budgetErr.Budget != 1 || math.Abs(budgetErr.Spent-0.9) > 1e-9 || budgetErr.Projected != 0.2 {
+		t.Errorf("BudgetExceededError = %+v", budgetErr)
+	}
+
+	// The next day starts afresh.
+	tracker.now = func() time.Time { return now.Add(24 * time.Hour) }
+	if err := tracker.CheckBudget(1, 0.2); err != nil {
+		t.Errorf("CheckBudget() on the next day error = %v", err)
+	}
+}
+
+func TestProjectCost(t *testing.T) {
+	// sonar-pro: $3 per million prompt tokens, $15 per million completion
+	// tokens, and $0.006 per request.
+	cost, ok := ProjectCost("sonar-pro", 1000, 2000)
+	if !ok || math.Abs(cost-(0.003+0.03+0.006)) > 1e-12 {
+		t.Errorf("ProjectCost() = %v, %v", cost, ok)
+	}
+	if _, ok := ProjectCost("custom-model", 1000, 2000); ok {
+		t.Error("ProjectCost() priced an unknown model")
+	}
+}
@@ -0,0 +1,192 @@
+package usage
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Export formats for `pplx usage export`.
+const (
+	FormatCSV        = "csv"
+	FormatPrometheus = "prometheus"
+)
+
+const (
+	// prometheusContentType is the content type of the Prometheus text format.
+	prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+	// exportFilePerms lets a collector running as another user read exports.
+	exportFilePerms = 0o644
+)
+
+// Daily aggregates the records of one model on one day.
+type Daily struct {
+	// Date is the local day, YYYY-MM-DD.
+	Date             string
+	Model            string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+// ValidExportFormats returns the accepted export formats.
+func ValidExportFormats() []string {
+	return []string{FormatCSV, FormatPrometheus}
+}
+
+// SummarizeDaily aggregates the records matching filter per day and model,
+// sorted by day then model. Days use the local time zone, like GroupDay.
+func SummarizeDaily(records []Record, filter Filter) []Daily {
+	type key struct{ date, model string }
+	groups := make(map[key]*Daily)
+	for _, rec := range records {
+		if !filter.Match(rec) {
+			continue
+		}
+		k := key{rec.Time.Local().Format(dayLayout), rec.Model}
+		d, ok := groups[k]
+		if !ok {
+			d = &Daily{Date: k.date, Model: k.model}
+			groups[k] = d
+		}
+		d.Requests++
+		d.PromptTokens += rec.PromptTokens
+		d.CompletionTokens += rec.CompletionTokens
+		d.Cost += rec.Cost
+	}
+
+	days := make([]Daily, 0, len(groups))
+	for _, d := range groups {
+		days = append(days, *d)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		if days[i].Date != days[j].Date {
+			return days[i].Date < days[j].Date
+		}
+		return days[i].Model < days[j].Model
+	})
+	return days
+}
+
+// WriteCSV writes days as CSV with a header row.
+func WriteCSV(w io.Writer, days []Daily) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"date", "model", "requests", "prompt_tokens", "completion_tokens", "est_cost_usd"})
+	for _, d := range days {
+		_ = cw.Write([]string{
+			d.Date,
+			d.Model,
+			strconv.Itoa(d.Requests),
+			strconv.Itoa(d.PromptTokens),
+			strconv.Itoa(d.CompletionTokens),
+			strconv.FormatFloat(d.Cost, 'f', 6, 64),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write usage CSV: %w", err)
+	}
+	return nil
+}
+
+// WritePrometheus writes per-model counters in the Prometheus text format,
+// as read by the node_exporter textfile collector. byModel holds one summary
+// per model, as returned by Summarize with GroupModel.
+func WritePrometheus(w io.Writer, byModel []Summary) error {
+	var b strings.Builder
+	b.WriteString("# HELP pplx_requests_total Requests recorded in the pplx usage log.\n")
+	b.WriteString("# TYPE pplx_requests_total counter\n")
+	for _, s := range byModel {
+		fmt.Fprintf(&b, "pplx_requests_total{model=%s} %d\n", labelValue(s.Key), s.Requests)
+	}
+	b.WriteString("# HELP pplx_tokens_total Tokens recorded in the pplx usage log, by kind.\n")
+	b.WriteString("# TYPE pplx_tokens_total counter\n")
+	for _, s := range byModel {
+		fmt.Fprintf(&b, "pplx_tokens_total{model=%s,kind=\"prompt\"} %d\n", labelValue(s.Key), s.PromptTokens)
+		fmt.Fprintf(&b, "pplx_tokens_total{model=%s,kind=\"completion\"} %d\n", labelValue(s.Key), s.CompletionTokens)
+	}
+	b.WriteString("# HELP pplx_cost_usd_total Estimated cost in USD recorded in the pplx usage log.\n")
+	b.WriteString("# TYPE pplx_cost_usd_total counter\n")
+	for _, s := range byModel {
+		fmt.Fprintf(&b, "pplx_cost_usd_total{model=%s} %s\n", labelValue(s.Key),
+			strconv.FormatFloat(s.Cost, 'g', -1, 64))
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write usage metrics: %w", err)
+	}
+	return nil
+}
+
+// labelValue quotes v as a Prometheus label value.
+func labelValue(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+	return `"` + v + `"`
+}
+
+// Export writes the records matching filter to w in format.
+func Export(w io.Writer, records []Record, filter Filter, format string) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, SummarizeDaily(records, filter))
+	case FormatPrometheus:
+		byModel, _, err := Summarize(records, filter, GroupModel)
+		if err != nil {
+			return err
+		}
+		return WritePrometheus(w, byModel)
+	default:
+		return fmt.Errorf("%w: %q (valid: %s)", clerrors.ErrInvalidUsageFormat,
+			format, strings.Join(ValidExportFormats(), ", "))
+	}
+}
+
+// ExportFile writes the export to path through a temporary file renamed
+// into place, so a textfile collector never reads a partial file.
+func ExportFile(path string, records []Record, filter Filter, format string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := Export(tmp, records, filter, format); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), exportFilePerms); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// MetricsHandler serves the counters of the usage log at path in the
+// Prometheus text format. The log is read on every scrape, so the counters
+// include requests recorded by other pplx processes.
+func MetricsHandler(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		records, err := ReadFile(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		byModel, _, _ := Summarize(records, Filter{}, GroupModel)
+		w.Header().Set("Content-Type", prometheusContentType)
+		_ = WritePrometheus(w, byModel)
+	})
+}
@@ -0,0 +1,193 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// multiDayLog writes a usage log spanning three days and three models, with
+// a malformed line the reader must skip, and returns its path.
+func multiDayLog(t *testing.T) string {
+	t.Helper()
+	at := func(day, hour int) time.Time { return time.Date(2025, 3, day, hour, 0, 0, 0, time.Local) }
+	records := []Record{
+		{Time: at(1, 9), Model: "sonar", PromptTokens: 100, CompletionTokens: 200, Cost: 0.0053, Priced: true},
+		{Time: at(1, 17), Model: "sonar", PromptTokens: 50, CompletionTokens: 50, Cost: 0.0051, Priced: true},
+		{Time: at(1, 18), Model: "sonar-pro", PromptTokens: 10, CompletionTokens: 90, Cost: 0.0074, Priced: true},
+		{Time: at(2, 8), Model: "mystery", PromptTokens: 7, CompletionTokens: 3},
+		{Time: at(3, 23), Model: "sonar-pro", PromptTokens: 40, CompletionTokens: 60, Cost: 0.0070, Priced: true},
+		{Time: at(3, 1), Model: "sonar", PromptTokens: 1, CompletionTokens: 2, Cost: 0.005, Priced: true},
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range records {
+		rec.TotalTokens = rec.PromptTokens + rec.CompletionTokens
+		line, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("{not json\n")
+
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSummarizeDaily(t *testing.T) {
+	records, err := ReadFile(multiDayLog(t))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []Daily
+	}{
+		{
+			name: "all",
+			want: []Daily{
+				{Date: "2025-03-01", Model: "sonar", Requests: 2, PromptTokens: 150, CompletionTokens: 250, Cost: 0.0104},
+				{Date: "2025-03-01", Model: "sonar-pro", Requests: 1, PromptTokens: 10, CompletionTokens: 90, Cost: 0.0074},
+				{Date: "2025-03-02", Model: "mystery", Requests: 1, PromptTokens: 7, CompletionTokens: 3},
+				{Date: "2025-03-03", Model: "sonar", Requests: 1, PromptTokens: 1, CompletionTokens: 2, Cost: 0.005},
+				{Date: "2025-03-03", Model: "sonar-pro", Requests: 1, PromptTokens: 40, CompletionTokens: 60, Cost: 0.0070},
+			},
+		},
+		{
+			name:   "since and model",
+			filter: Filter{Since: time.Date(2025, 3, 2, 0, 0, 0, 0, time.Local), Model: "SONAR-PRO"},
+			want: []Daily{
+				{Date: "2025-03-03", Model: "sonar-pro", Requests: 1, PromptTokens: 40, CompletionTokens: 60, Cost: 0.0070},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SummarizeDaily(records, tt.filter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("SummarizeDaily() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if g.Date != w.Date || g.Model != w.Model || g.Requests != w.Requests ||
+					g.PromptTokens != w.PromptTokens || g.CompletionTokens != w.CompletionTokens ||
+					!approxEqual(g.Cost, w.Cost) {
+					t.Errorf("row %d = %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestExport_CSV(t *testing.T) {
+	records, _ := ReadFile(multiDayLog(t))
+	var buf bytes.Buffer
+	if err := Export(&buf, records, Filter{Model: "sonar"}, FormatCSV); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	want := "date,model,requests,prompt_tokens,completion_tokens,est_cost_usd\n" +
+		"2025-03-01,sonar,2,150,250,0.010400\n" +
+		"2025-03-03,sonar,1,1,2,0.005000\n"
+	if buf.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestExport_Prometheus(t *testing.T) {
+	records, _ := ReadFile(multiDayLog(t))
+	var buf bytes.Buffer
+	if err := Export(&buf, records, Filter{}, FormatPrometheus); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE pplx_requests_total counter\n",
+		`pplx_requests_total{model="sonar"} 3` + "\n",
+		`pplx_requests_total{model="sonar-pro"} 2` + "\n",
+		`pplx_requests_total{model="mystery"} 1` + "\n",
+		"# TYPE pplx_tokens_total counter\n",
+		`pplx_tokens_total{model="sonar",kind="prompt"} 151` + "\n",
+		`pplx_tokens_total{model="sonar",kind="completion"} 252` + "\n",
+		`pplx_tokens_total{model="sonar-pro",kind="completion"} 150` + "\n",
+		"# TYPE pplx_cost_usd_total counter\n",
+		`pplx_cost_usd_total{model="mystery"} 0` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestExport_UnknownFormat(t *testing.T) {
+	err := Export(&bytes.Buffer{}, nil, Filter{}, "xml")
+	if !errors.Is(err, clerrors.ErrInvalidUsageFormat) {
+		t.Errorf("Export() error = %v, want ErrInvalidUsageFormat", err)
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	if got, want := labelValue("a\"b\\c\nd"), `"a\"b\\c\nd"`; got != want {
+		t.Errorf("labelValue() = %s, want %s", got, want)
+	}
+}
+
+func TestExportFile(t *testing.T) {
+	records, _ := ReadFile(multiDayLog(t))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pplx.prom")
+	if err := os.WriteFile(path, []byte("stale"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExportFile(path, records, Filter{}, FormatPrometheus); err != nil {
+		t.Fatalf("ExportFile() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# HELP pplx_requests_total") {
+		t.Errorf("file = %q, want the metrics", data)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want the export only", len(entries))
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	MetricsHandler(multiDayLog(t)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	if !strings.Contains(rec.Body.String(), `pplx_requests_total{model="sonar"} 3`) {
+		t.Errorf("body = %s, want the sonar request counter", rec.Body.String())
+	}
+}
+
+// approxEqual compares costs, which are sums of floats.
+func approxEqual(a, b float64) bool {
+	const epsilon = 1e-9
+	return a-b < epsilon && b-a < epsilon
+}
@@ -0,0 +1,54 @@
+package usage
+
+import (
+	"strings"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+// tokensPerMillion is the unit that token prices are quoted in.
+const tokensPerMillion = 1_000_000
+
+// Price is the list price of a model in USD.
+type Price struct {
+	// InputPerMillion is the cost of one million prompt tokens.
+	InputPerMillion float64
+	// OutputPerMillion is the cost of one million completion tokens.
+	OutputPerMillion float64
+	// PerRequest is the flat search fee charged per request (low search context).
+	PerRequest float64
+}
+
+// prices holds the published Perplexity prices used for estimates.
+// Unknown models are not priced; see EstimateCost.
+var prices = map[string]Price{
+	"sonar":               {InputPerMillion: 1, OutputPerMillion: 1, PerRequest: 0.005},
+	"sonar-pro":           {InputPerMillion: 3, OutputPerMillion: 15, PerRequest: 0.006},
+	"sonar-reasoning":     {InputPerMillion: 1, OutputPerMillion: 5, PerRequest: 0.005},
+	"sonar-reasoning-pro": {InputPerMillion: 2, OutputPerMillion: 8, PerRequest: 0.006},
+	"sonar-deep-research": {InputPerMillion: 2, OutputPerMillion: 8, PerRequest: 0.005},
+}
+
+// PriceFor returns the price of model, ignoring case. The boolean is false for
+// models missing from the built-in table.
+func PriceFor(model string) (Price, bool) {
+	p, ok := prices[strings.ToLower(model)]
+	return p, ok
+}
+
+// EstimateCost returns the cost in USD of a request.
+// The API-reported total cost is used when present; otherwise the cost is
+// computed from the price table. The boolean is false when neither is available.
+func EstimateCost(model string, u perplexity.Usage) (float64, bool) {
+	if u.Cost != nil && u.Cost.TotalCost != nil {
+		return *u.Cost.TotalCost, true
+	}
+
+	p, ok := PriceFor(model)
+	if !ok {
+		return 0, false
+	}
+	return float64(u.PromptTokens)*p.InputPerMillion/tokensPerMillion +
+		float64(u.CompletionTokens)*p.OutputPerMillion/tokensPerMillion +
+		p.PerRequest, true
+}
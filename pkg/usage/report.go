@@ -0,0 +1,174 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+	"github.com/sgaunet/pplx/pkg/validation"
+)
+
+// Grouping keys for Summarize.
+const (
+	GroupNone  = ""
+	GroupDay   = "day"
+	GroupModel = "model"
+)
+
+// dayLayout formats the day grouping key.
+const dayLayout = "2006-01-02"
+
+// Summary aggregates the records sharing a grouping key.
+type Summary struct {
+	Key              string  `json:"key"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost_usd"`
+	// Unpriced counts requests whose model had no known price.
+	Unpriced int `json:"unpriced_requests"`
+}
+
+// Filter selects records for a report. Zero fields match everything.
+type Filter struct {
+	Since time.Time
+	Model string
+}
+
+// Match reports whether rec passes the filter.
+func (f Filter) Match(rec Record) bool {
+	if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+		return false
+	}
+	return f.Model == "" || strings.EqualFold(rec.Model, f.Model)
+}
+
+// ReadFile reads every record from the usage log at path.
+// A missing file yields no records; malformed lines are skipped.
+func ReadFile(path string) ([]Record, error) {
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open usage log %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return Read(f)
+}
+
+// Read decodes JSON lines records from r, skipping malformed lines such as a
+// partially written final line.
+func Read(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage log: %w", err)
+	}
+	return records, nil
+}
+
+// ValidGroupings returns the accepted --group-by values.
+func ValidGroupings() []string {
+	return []string{GroupDay, GroupModel}
+}
+
+// Summarize aggregates the records matching filter by groupBy (GroupNone,
+// GroupDay or GroupModel). Groups are sorted by key. The second result is the
+// total across all matching records.
+func Summarize(records []Record, filter Filter, groupBy string) ([]Summary, Summary, error) {
+	var keyOf func(Record) string
+	switch groupBy {
+	case GroupNone:
+		keyOf = func(Record) string { return "total" }
+	case GroupDay:
+		keyOf = func(r Record) string { return r.Time.Local().Format(dayLayout) }
+	case GroupModel:
+		keyOf = func(r Record) string { return r.Model }
+	default:
+		return nil, Summary{}, fmt.Errorf("%w: %q (valid: %s)", clerrors.ErrInvalidGroupBy,
+			groupBy, strings.Join(ValidGroupings(), ", "))
+	}
+
+	groups := make(map[string]*Summary)
+	total := Summary{Key: "total"}
+	for _, rec := range records {
+		if !filter.Match(rec) {
+			continue
+		}
+		key := keyOf(rec)
+		s, ok := groups[key]
+		if !ok {
+			s = &Summary{Key: key}
+			groups[key] = s
+		}
+		s.add(rec)
+		total.add(rec)
+	}
+
+	summaries := make([]Summary, 0, len(groups))
+	for _, s := range groups {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Key < summaries[j].Key })
+	return summaries, total, nil
+}
+
+// add accumulates rec into s.
+func (s *Summary) add(rec Record) {
+	s.Requests++
+	s.PromptTokens += rec.PromptTokens
+	s.CompletionTokens += rec.CompletionTokens
+	s.TotalTokens += rec.TotalTokens
+	s.Cost += rec.Cost
+	if !rec.Priced {
+		s.Unpriced++
+	}
+}
+
+// ParseSince parses a --since value relative to now. It accepts a date
+// (YYYY-MM-DD or MM/DD/YYYY, local midnight), a number of days such as "7d",
+// or a Go duration such as "12h".
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if date, err := validation.ValidateDate(value); err == nil {
+		return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, now.Location()), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q (use a date like 2025-01-31, days like 7d, or a duration like 12h)",
+		clerrors.ErrInvalidSince, value)
+}
@@ -0,0 +1,162 @@
+package usage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func sampleRecords() []Record {
+	day1 := time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local)
+	day2 := time.Date(2025, 1, 11, 12, 0, 0, 0, time.Local)
+	return []Record{
+		{Time: day1, Model: "sonar", PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30, Cost: 0.01, Priced: true},
+		{Time: day1, Model: "sonar-pro", PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3, Cost: 0.02, Priced: true},
+		{Time: day2, Model: "sonar", PromptTokens: 5, CompletionTokens: 5, TotalTokens: 10, Cost: 0.03, Priced: true},
+		{Time: day2, Model: "mystery", TotalTokens: 1},
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    Filter
+		groupBy   string
+		wantKeys  []string
+		wantReqs  []int
+		wantTotal int
+	}{
+		{name: "no grouping", groupBy: GroupNone, wantKeys: []string{"total"}, wantReqs: []int{4}, wantTotal: 4},
+		{
+			name:     "by day",
+			groupBy:  GroupDay,
+			wantKeys: []string{"2025-01-10", "2025-01-11"}, wantReqs: []int{2, 2}, wantTotal: 4,
+		},
+		{
+			name:     "by model",
+			groupBy:  GroupModel,
+			wantKeys: []string{"mystery", "sonar", "sonar-pro"}, wantReqs: []int{1, 2, 1}, wantTotal: 4,
+		},
+		{
+			name:     "model filter",
+			filter:   Filter{Model: "SONAR"},
+			groupBy:  GroupDay,
+			wantKeys: []string{"2025-01-10", "2025-01-11"}, wantReqs: []int{1, 1}, wantTotal: 2,
+		},
+		{
+			name:     "since filter",
+			filter:   Filter{Since: time.Date(2025, 1, 11, 0, 0, 0, 0, time.Local)},
+			groupBy:  GroupModel,
+			wantKeys: []string{"mystery", "sonar"}, wantReqs: []int{1, 1}, wantTotal: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, total, err := Summarize(sampleRecords(), tt.filter, tt.groupBy)
+			if err != nil {
+				t.Fatalf("Summarize failed: %v", err)
+			}
+			if len(groups) != len(tt.wantKeys) {
+				t.Fatalf("got %d groups, want %d: %+v", len(groups), len(tt.wantKeys), groups)
+			}
+			for i, g := range groups {
+				if g.Key != tt.wantKeys[i] || g.Requests != tt.wantReqs[i] {
+					t.Errorf("group %d = %s/%d, want %s/%d", i, g.Key, g.Requests, tt.wantKeys[i], tt.wantReqs[i])
+				}
+			}
+			if total.Requests != tt.wantTotal {
+				t.Errorf("total requests = %d, want %d", total.Requests, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestSummarize_Totals(t *testing.T) {
+	_, total, err := Summarize(sampleRecords(), Filter{}, GroupModel)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if total.PromptTokens != 16 || total.CompletionTokens != 27 || total.TotalTokens != 44 {
+		t.Errorf("unexpected token totals: %+v", total)
+	}
+	if total.Unpriced != 1 {
+		t.Errorf("Unpriced = %d, want 1", total.Unpriced)
+	}
+	if diff := total.Cost - 0.06; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Cost = %v, want 0.06", total.Cost)
+	}
+}
+
+func TestSummarize_InvalidGroupBy(t *testing.T) {
+	_, _, err := Summarize(nil, Filter{}, "week")
+	if !errors.Is(err, clerrors.ErrInvalidGroupBy) {
+		t.Errorf("error = %v, want ErrInvalidGroupBy", err)
+	}
+}
+
+func TestRead_SkipsMalformedLines(t *testing.T) {
+	input := `{"time":"2025-01-10T12:00:00Z","model":"sonar","total_tokens":5}
+
+not json
+{"time":"2025-01-11T12:00:00Z","model":"sonar-pro","total_tokens":7}
+{"time":"2025-01-12T12:00:00Z","model":"son`
+
+	records, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if records[1].Model != "sonar-pro" || records[1].TotalTokens != 7 {
+		t.Errorf("unexpected record: %+v", records[1])
+	}
+}
+
+func TestReadFile_Missing(t *testing.T) {
+	records, err := ReadFile(t.TempDir() + "/missing.jsonl")
+	if err != nil || records != nil {
+		t.Errorf("ReadFile(missing) = %v, %v; want nil, nil", records, err)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 3, 15, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "", want: time.Time{}},
+		{value: "7d", want: now.AddDate(0, 0, -7)},
+		{value: "0d", want: now},
+		{value: "12h", want: now.Add(-12 * time.Hour)},
+		{value: "2025-03-01", want: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "03/01/2025", want: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "-3d", wantErr: true},
+		{value: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSince(tt.value, now)
+			if tt.wantErr {
+				if !errors.Is(err, clerrors.ErrInvalidSince) {
+					t.Fatalf("ParseSince(%q) error = %v, want ErrInvalidSince", tt.value, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSince(%q) unexpected error: %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseSince(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Package usage records token consumption and estimated cost per API request
+// and aggregates the log for the `pplx usage` command.
+//
+// Records are appended as JSON lines to ~/.local/share/pplx/usage.jsonl.
+// Recording is best-effort: callers use Track, which logs failures instead of
+// returning them, so a broken log never fails a query.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+	"github.com/sgaunet/pplx/pkg/logger"
+)
+
+// Sources identify which entry point made a request.
+const (
+	SourceQuery = "query"
+	SourceChat  = "chat"
+	SourceMCP   = "mcp"
+	SourceBatch = "batch"
+	SourceBrief = "brief"
+	// SourceLibrary is the default source of requests sent with pkg/pplx.
+	SourceLibrary = "library"
+)
+
+const (
+	// File permissions for the usage log.
+	logDirPerms  = 0o700
+	logFilePerms = 0o600
+)
+
+// Record is one line of the usage log.
+type Record struct {
+	Time             time.Time `json:"time"`
+	Source           string    `json:"source"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	// KeyID names the API key of an MCP call made with key_id or api_key;
+	// empty for the default key. The key itself is never recorded.
+	KeyID string `json:"key_id,omitempty"`
+	// Cost is the estimated cost in USD; zero when the model is not priced.
+	Cost float64 `json:"cost_usd"`
+	// Priced is false when no price was known for Model.
+	Priced bool `json:"priced"`
+	// Partial is set for a request interrupted before its answer completed;
+	// its tokens may be estimates.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// Tracker appends usage records to a JSON lines file.
+//
+// Each record is written with a single append-mode write, so concurrent
+// writers (parallel MCP requests, several pplx processes) never interleave
+// partial lines. A mutex additionally serializes writers within a process.
+type Tracker struct {
+	path string
+	mu   sync.Mutex
+	// now returns the current time; replaced in tests.
+	now func() time.Time
+}
+
+// NewTracker returns a tracker writing to path.
+func NewTracker(path string) *Tracker {
+	return &Tracker{path: path, now: time.Now}
+}
+
+// DefaultPath returns the usage log location, ~/.local/share/pplx/usage.jsonl.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory for usage log: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "pplx", "usage.jsonl"), nil
+}
+
+// Path returns the file the tracker writes to.
+func (t *Tracker) Path() string {
+	return t.path
+}
+
+// Record appends a record for response, made from source.
+func (t *Tracker) Record(source string, response *perplexity.CompletionResponse) error {
+	return t.RecordKey(source, "", response)
+}
+
+// RecordKey appends a record for response, made from source with the API
+// key named keyID.
+func (t *Tracker) RecordKey(source, keyID string, response *perplexity.CompletionResponse) error {
+	return t.record(source, keyID, response, false)
+}
+
+// RecordPartial appends a record for the partial response of an
+// interrupted request, made from source.
+func (t *Tracker) RecordPartial(source string, response *perplexity.CompletionResponse) error {
+	return t.record(source, "", response, true)
+}
+
+// record appends a record for response.
+func (t *Tracker) record(source, keyID string, response *perplexity.CompletionResponse, partial bool) error {
+	cost, priced := EstimateCost(response.Model, response.Usage)
+	rec := Record{
+		Partial:          partial,
+		Time:             t.now().UTC(),
+		Source:           source,
+		KeyID:            keyID,
+		Model:            response.Model,
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		TotalTokens:      response.Usage.TotalTokens,
+		Cost:             cost,
+		Priced:           priced,
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage record: %w", err)
+	}
+	line = append(line, '\n')
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(t.path), logDirPerms); err != nil {
+		return fmt.Errorf("failed to create usage log directory: %w", err)
+	}
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, logFilePerms) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("failed to open usage log %s: %w", t.path, err)
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write usage log %s: %w", t.path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close usage log %s: %w", t.path, err)
+	}
+	return nil
+}
+
+var (
+	defaultTracker     *Tracker
+	defaultTrackerErr  error
+	defaultTrackerOnce sync.Once
+)
+
+// Default returns the process-wide tracker writing to DefaultPath.
+func Default() (*Tracker, error) {
+	defaultTrackerOnce.Do(func() {
+		path, err := DefaultPath()
+		if err != nil {
+			defaultTrackerErr = err
+			return
+		}
+		defaultTracker = NewTracker(path)
+	})
+	return defaultTracker, defaultTrackerErr
+}
+
+// Track records response with the default tracker. Failures are logged at
+// warn level and otherwise ignored. Nil responses are skipped.
+func Track(source string, response *perplexity.CompletionResponse) {
+	TrackKey(source, "", response)
+}
+
+// TrackPartial is Track for the partial response of an interrupted
+// request.
+func TrackPartial(source string, response *perplexity.CompletionResponse) {
+	if response == nil {
+		return
+	}
+	tracker, err := Default()
+	if err == nil {
+		err = tracker.RecordPartial(source, response)
+	}
+	if err != nil {
+		logger.Warn("failed to record usage", "source", source, "error", err)
+	}
+}
+
+// TrackKey is Track for a request sent with the API key named keyID.
+func TrackKey(source, keyID string, response *perplexity.CompletionResponse) {
+	if response == nil {
+		return
+	}
+	tracker, err := Default()
+	if err == nil {
+		err = tracker.RecordKey(source, keyID, response)
+	}
+	if err != nil {
+		logger.Warn("failed to record usage", "source", source, "error", err)
+	}
+}
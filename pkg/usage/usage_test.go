@@ -0,0 +1,171 @@
+package usage
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/perplexity-go/v2"
+)
+
+func float64Ptr(f float64) *float64 { return &f }
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		name       string
+		model      string
+		usage      perplexity.Usage
+		wantCost   float64
+		wantPriced bool
+	}{
+		{
+			name:       "sonar from price table",
+			model:      "sonar",
+			usage:      perplexity.Usage{PromptTokens: 1_000_000, CompletionTokens: 1_000_000},
+			wantCost:   1 + 1 + 0.005,
+			wantPriced: true,
+		},
+		{
+			name:       "model name is case insensitive",
+			model:      "Sonar-Pro",
+			usage:      perplexity.Usage{PromptTokens: 1000, CompletionTokens: 2000},
+			wantCost:   1000*3.0/1e6 + 2000*15.0/1e6 + 0.006,
+			wantPriced: true,
+		},
+		{
+			name:       "API reported cost wins",
+			model:      "sonar",
+			usage:      perplexity.Usage{PromptTokens: 10, Cost: &perplexity.Cost{TotalCost: float64Ptr(0.42)}},
+			wantCost:   0.42,
+			wantPriced: true,
+		},
+		{
+			name:       "unknown model",
+			model:      "mystery",
+			usage:      perplexity.Usage{PromptTokens: 10},
+			wantCost:   0,
+			wantPriced: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost, priced := EstimateCost(tt.model, tt.usage)
+			if priced != tt.wantPriced {
+				t.Errorf("priced = %v, want %v", priced, tt.wantPriced)
+			}
+			if math.Abs(cost-tt.wantCost) > 1e-12 {
+				t.Errorf("cost = %v, want %v", cost, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestTrackerRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "usage.jsonl")
+	tracker := NewTracker(path)
+	fixed := time.Date(2025, 5, 6, 7, 8, 9, 0, time.UTC)
+	tracker.now = func() time.Time { return fixed }
+
+	resp := &perplexity.CompletionResponse{
+		Model: "sonar",
+		Usage: perplexity.Usage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7},
+	}
+	if err := tracker.Record(SourceQuery, resp); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := tracker.RecordKey(SourceMCP, "alice", resp); err != nil {
+		t.Fatalf("RecordKey failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("usage log not created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != logFilePerms {
+		t.Errorf("usage log permissions = %o, want %o", perm, logFilePerms)
+	}
+
+	records, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	got := records[0]
+	if !got.Time.Equal(fixed) || got.Source != SourceQuery || got.Model != "sonar" ||
+		got.PromptTokens != 3 || got.CompletionTokens != 4 || got.TotalTokens != 7 || !got.Priced {
+		t.Errorf("unexpected record: %+v", got)
+	}
+	if records[1].Source != SourceMCP || records[1].KeyID != "alice" {
+		t.Errorf("second record = %+v, want source %q and key_id alice", records[1], SourceMCP)
+	}
+	if got.KeyID != "" {
+		t.Errorf("first record key_id = %q, want empty", got.KeyID)
+	}
+}
+
+func TestTrackerRecordPartial(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	tracker := NewTracker(path)
+	resp := &perplexity.CompletionResponse{
+		Model: "sonar",
+		Usage: perplexity.Usage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7},
+	}
+	if err := tracker.Record(SourceQuery, resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.RecordPartial(SourceChat, resp); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadFile(path)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("ReadFile() = %d records, %v; want 2", len(records), err)
+	}
+	if records[0].Partial || !records[1].Partial || records[1].TotalTokens != 7 {
+		t.Errorf("records = %+v, want only the second partial", records)
+	}
+}
+
+func TestTrackerRecord_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	tracker := NewTracker(path)
+	resp := &perplexity.CompletionResponse{Model: "sonar", Usage: perplexity.Usage{TotalTokens: 1}}
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Go(func() {
+			if err := tracker.Record(SourceMCP, resp); err != nil {
+				t.Errorf("Record failed: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	records, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(records) != writers {
+		t.Errorf("got %d records, want %d", len(records), writers)
+	}
+}
+
+func TestTrackerRecord_UnwritablePath(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	// The parent "directory" is a regular file, so the log cannot be created.
+	tracker := NewTracker(filepath.Join(blocker, "usage.jsonl"))
+	if err := tracker.Record(SourceQuery, &perplexity.CompletionResponse{}); err == nil {
+		t.Error("expected error when the log directory cannot be created")
+	}
+}
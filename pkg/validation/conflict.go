@@ -0,0 +1,97 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Policies for requests asking for both a search recency filter and images,
+// which the API cannot combine (output.image_conflict_policy).
+const (
+	// ImageConflictPreferImages drops the recency filter. It is the default.
+	ImageConflictPreferImages = "prefer_images"
+	// ImageConflictPreferRecency drops the images.
+	ImageConflictPreferRecency = "prefer_recency"
+	// ImageConflictError rejects the request.
+	ImageConflictError = "error"
+)
+
+// ImageConflict is the outcome of ResolveImageConflict: the recency filter
+// and image flag to send, and the warning to show when one was dropped.
+type ImageConflict struct {
+	Recency      string
+	ReturnImages bool
+	// Warning names the dropped option and the policy; empty when nothing
+	// was dropped.
+	Warning string
+}
+
+// ResolveImageConflict applies policy to a request for the search recency
+// filter recency and, when returnImages is set, images. An empty policy is
+// ImageConflictPreferImages. Without a conflict, both values are returned
+// unchanged. Under ImageConflictError, a conflict returns an error wrapping
+// clerrors.ErrImageRecencyConflict; an unknown policy returns one listing the
+// accepted policies.
+func ResolveImageConflict(recency string, returnImages bool, policy string) (ImageConflict, error) {
+	if err := validateEnum(KindImageConflict, policy, clerrors.ErrInvalidImageConflictPolicy); err != nil {
+		return ImageConflict{}, err
+	}
+	if policy == "" {
+		policy = ImageConflictPreferImages
+	}
+	res := ImageConflict{Recency: recency, ReturnImages: returnImages}
+	if recency == "" || !returnImages {
+		return res, nil
+	}
+
+	switch policy {
+	case ImageConflictPreferRecency:
+		res.ReturnImages = false
+		res.Warning = fmt.Sprintf("search_recency %q and return_images cannot be combined: "+
+			"return_images dropped (image_conflict_policy: %s)", recency, policy)
+	case ImageConflictError:
+		return ImageConflict{}, fmt.Errorf("%w: search_recency %q and return_images "+
+			"(image_conflict_policy: %s; drop one of them or change the policy)",
+			clerrors.ErrImageRecencyConflict, recency, policy)
+	default:
+		res.Recency = ""
+		res.Warning = fmt.Sprintf("search_recency %q and return_images cannot be combined: "+
+			"search_recency dropped (image_conflict_policy: %s)", recency, policy)
+	}
+	return res, nil
+}
+
+// StreamConflict is the outcome of ResolveStreamFormat: whether to stream
+// the request, and the warning to show when streaming was turned off.
+type StreamConflict struct {
+	Stream bool
+	// Warning says streaming was turned off and why; empty when the request
+	// is sent as asked.
+	Warning string
+}
+
+// ResolveStreamFormat checks a request streamed when stream is set against
+// its JSON schema and regex response formats, which the API ignores in
+// streamed answers. By default a conflict turns streaming off for the
+// request with a warning; when strict is set (output.strict_options) it
+// returns an error wrapping clerrors.ErrStreamResponseFormat instead.
+// Without a conflict, stream is returned unchanged.
+func ResolveStreamFormat(stream bool, jsonSchema, regex string, strict bool) (StreamConflict, error) {
+	res := StreamConflict{Stream: stream}
+	if !stream || (jsonSchema == "" && regex == "") {
+		return res, nil
+	}
+	format := "response_format_json_schema"
+	if jsonSchema == "" {
+		format = "response_format_regex"
+	}
+	if strict {
+		return StreamConflict{}, fmt.Errorf("%w: stream and %s (strict_options: true; "+
+			"drop stream to get structured output)", clerrors.ErrStreamResponseFormat, format)
+	}
+	res.Stream = false
+	res.Warning = fmt.Sprintf("stream and %s cannot be combined: the API ignores response formats "+
+		"in streamed answers, so streaming was turned off for this request", format)
+	return res, nil
+}
@@ -0,0 +1,87 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestResolveImageConflict(t *testing.T) {
+	tests := []struct {
+		name        string
+		recency     string
+		images      bool
+		policy      string
+		wantRecency string
+		wantImages  bool
+		wantWarning string
+		wantErr     error
+	}{
+		{"no conflict", "week", false, ImageConflictError, "week", false, "", nil},
+		{"images only", "", true, ImageConflictPreferRecency, "", true, "", nil},
+		{"default policy", "week", true, "", "", true, "search_recency dropped", nil},
+		{"prefer images", "week", true, ImageConflictPreferImages, "", true, "search_recency dropped", nil},
+		{"prefer recency", "week", true, ImageConflictPreferRecency, "week", false, "return_images dropped", nil},
+		{"error", "week", true, ImageConflictError, "", false, "", clerrors.ErrImageRecencyConflict},
+		{"unknown policy", "", false, "prefer_both", "", false, "", clerrors.ErrInvalidImageConflictPolicy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveImageConflict(tt.recency, tt.images, tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Recency != tt.wantRecency || got.ReturnImages != tt.wantImages {
+				t.Errorf("got recency %q, images %v; want %q, %v",
+					got.Recency, got.ReturnImages, tt.wantRecency, tt.wantImages)
+			}
+			if (tt.wantWarning == "") != (got.Warning == "") || !strings.Contains(got.Warning, tt.wantWarning) {
+				t.Errorf("Warning = %q, want it to contain %q", got.Warning, tt.wantWarning)
+			}
+			if got.Warning != "" && !strings.Contains(got.Warning, "image_conflict_policy") {
+				t.Errorf("Warning = %q does not name the policy", got.Warning)
+			}
+		})
+	}
+}
+
+func TestResolveStreamFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		stream      bool
+		jsonSchema  string
+		regex       string
+		strict      bool
+		wantStream  bool
+		wantWarning string
+		wantErr     error
+	}{
+		{"no format", true, "", "", false, true, "", nil},
+		{"no stream", false, `{"type":"object"}`, "", true, false, "", nil},
+		{"json schema", true, `{"type":"object"}`, "", false, false, "response_format_json_schema", nil},
+		{"regex", true, "", `\d+`, false, false, "response_format_regex", nil},
+		{"strict", true, "", `\d+`, true, false, "", clerrors.ErrStreamResponseFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveStreamFormat(tt.stream, tt.jsonSchema, tt.regex, tt.strict)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Stream != tt.wantStream {
+				t.Errorf("Stream = %v, want %v", got.Stream, tt.wantStream)
+			}
+			if (tt.wantWarning == "") != (got.Warning == "") || !strings.Contains(got.Warning, tt.wantWarning) {
+				t.Errorf("Warning = %q, want it to contain %q", got.Warning, tt.wantWarning)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package validation
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+//go:embed countries.txt
+var countriesFile string
+
+// countries returns the set of ISO 3166-1 alpha-2 codes of countriesFile,
+// parsed on first use.
+var countries = sync.OnceValue(func() map[string]bool {
+	set := make(map[string]bool)
+	for _, line := range strings.Split(countriesFile, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			set[line] = true
+		}
+	}
+	return set
+})
+
+// ValidateCountry validates a location country code, such as US or fr, as
+// sent in the user location of a request. Empty values are accepted (no
+// country). Returns an error wrapping clerrors.ErrInvalidCountry otherwise.
+func ValidateCountry(code string) error {
+	if code == "" || countries()[strings.ToUpper(code)] {
+		return nil
+	}
+	return fmt.Errorf("%w: %q. Must be an ISO 3166-1 alpha-2 code, such as US or FR",
+		clerrors.ErrInvalidCountry, code)
+}
@@ -0,0 +1,36 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestValidateCountry(t *testing.T) {
+	tests := []struct {
+		code    string
+		wantErr bool
+	}{
+		{"", false},
+		{"US", false},
+		{"fr", false},
+		{"GB", false},
+		{"USA", true},
+		{"USA!!!", true},
+		{"UK", true},
+		{"U", true},
+		{" US", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			err := ValidateCountry(tt.code)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("ValidateCountry(%q) error = %v, wantErr %v", tt.code, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, clerrors.ErrInvalidCountry) {
+				t.Errorf("error = %v, want ErrInvalidCountry", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package validation
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Date layouts accepted by ParseDate, in the order they are tried.
+const (
+	// DateLayoutISO is the ISO 8601 calendar date layout (YYYY-MM-DD).
+	DateLayoutISO = "2006-01-02"
+	// DateLayoutUS is the US date layout (MM/DD/YYYY).
+	DateLayoutUS = "01/02/2006"
+	// dateLayoutEU is only tried to explain why a DD/MM/YYYY date was rejected.
+	dateLayoutEU = "02/01/2006"
+)
+
+// DateFormats lists the accepted date filter formats for help text and errors.
+const DateFormats = "YYYY-MM-DD, MM/DD/YYYY, a relative date like 7d, 3w, 6m or 1y, or a quarter like 2024-Q1"
+
+const (
+	monthsPerYear    = 12
+	monthsPerQuarter = 3
+	daysPerWeek      = 7
+)
+
+var (
+	// relativeDatePattern matches "<n><unit>" with unit d, w, m (months), or y.
+	relativeDatePattern = regexp.MustCompile(`^(\d{1,4})([dwmy])$`)
+	// quarterDatePattern matches "YYYY-Qn".
+	quarterDatePattern = regexp.MustCompile(`^(\d{4})-[Qq]([1-4])$`)
+)
+
+// DateNotes receives notes about how ambiguous dates were interpreted. It is
+// stderr so that notes never mix with query or MCP protocol output.
+var DateNotes io.Writer = os.Stderr
+
+// notedDates holds the values already reported to DateNotes, so that a date
+// re-parsed for every chat message is only explained once.
+var notedDates sync.Map
+
+// now returns the date relative expressions are resolved against; tests
+// replace it.
+var now = time.Now
+
+// ValidateDate parses a date filter against the current date; see ParseDate
+// for the accepted formats. When the value is ambiguous, the interpretation
+// is written once to DateNotes. Returns an error wrapping
+// clerrors.ErrInvalidDate when no format matches.
+func ValidateDate(value string) (time.Time, error) {
+	date, note, err := ParseDate(value, now())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if note != "" {
+		if _, seen := notedDates.LoadOrStore(value, true); !seen {
+			fmt.Fprintf(DateNotes, "Note: %s\n", note)
+		}
+	}
+	return date, nil
+}
+
+// ParseDate parses a date filter value. Accepted formats are:
+//
+//   - ISO 8601 dates (2024-06-01) and timestamps (2024-06-01T09:00:00+02:00),
+//     reduced to their calendar date in their own offset
+//   - MM/DD/YYYY (06/01/2024)
+//   - relative dates: 7d, 3w, 6m, or 1y before the calendar date of ref
+//   - quarters: 2024-Q1, resolved to the first day of the quarter
+//
+// The result is midnight UTC of the calendar date, which is what the API
+// filters on. A non-empty note explains how an ambiguous MM/DD/YYYY value,
+// such as 03/04/2024, was read.
+func ParseDate(value string, ref time.Time) (time.Time, string, error) {
+	if date, err := time.Parse(DateLayoutISO, value); err == nil {
+		return date, "", nil
+	}
+	if ts, err := time.Parse(time.RFC3339, value); err == nil {
+		return calendarDate(ts), "", nil
+	}
+	if date, err := time.Parse(DateLayoutUS, value); err == nil {
+		return date, ambiguityNote(value, date), nil
+	}
+	if date, ok := parseRelativeDate(value, ref); ok {
+		return date, "", nil
+	}
+	if date, ok := parseQuarter(value); ok {
+		return date, "", nil
+	}
+
+	if date, err := time.Parse(dateLayoutEU, value); err == nil {
+		return time.Time{}, "", fmt.Errorf("%w: '%s' looks like DD/MM/YYYY, which is not supported. Use %s",
+			clerrors.ErrInvalidDate, value, date.Format(DateLayoutISO))
+	}
+	return time.Time{}, "", fmt.Errorf("%w: '%s'. Use %s", clerrors.ErrInvalidDate, value, DateFormats)
+}
+
+// ambiguityNote returns a note when a MM/DD/YYYY date would also be valid
+// as DD/MM/YYYY with a different meaning.
+func ambiguityNote(value string, date time.Time) string {
+	if date.Day() > monthsPerYear || date.Day() == int(date.Month()) {
+		return ""
+	}
+	return fmt.Sprintf("read date %q as MM/DD/YYYY (%s); use YYYY-MM-DD to avoid ambiguity",
+		value, date.Format(DateLayoutISO))
+}
+
+// parseRelativeDate resolves "<n><unit>" against the calendar date of ref in
+// ref's location. Months and years are clamped to the end of shorter months,
+// so 1m before March 31 is the last day of February.
+func parseRelativeDate(value string, ref time.Time) (time.Time, bool) {
+	m := relativeDatePattern.FindStringSubmatch(value)
+	if m == nil {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	today := calendarDate(ref)
+	switch m[2] {
+	case "d":
+		return today.AddDate(0, 0, -n), true
+	case "w":
+		return today.AddDate(0, 0, -n*daysPerWeek), true
+	case "m":
+		return monthsBefore(today, n), true
+	default: // "y"
+		return monthsBefore(today, n*monthsPerYear), true
+	}
+}
+
+// parseQuarter resolves "YYYY-Qn" to the first day of the quarter.
+func parseQuarter(value string) (time.Time, bool) {
+	m := quarterDatePattern.FindStringSubmatch(value)
+	if m == nil {
+		return time.Time{}, false
+	}
+	year, _ := strconv.Atoi(m[1])
+	quarter, _ := strconv.Atoi(m[2])
+	month := time.Month((quarter-1)*monthsPerQuarter + 1)
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), true
+}
+
+// calendarDate returns midnight UTC of t's calendar date in t's location.
+func calendarDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// monthsBefore returns the date n months before date, clamping the day to
+// the length of the target month.
+func monthsBefore(date time.Time, n int) time.Time {
+	first := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -n, 0)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(date.Day(), lastDay)-1)
+}
@@ -0,0 +1,112 @@
+package validation
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseDate(t *testing.T) {
+	ref := time.Date(2024, 3, 31, 15, 0, 0, 0, time.UTC)
+	tokyo := time.FixedZone("JST", 9*60*60)
+	newYork := time.FixedZone("EST", -5*60*60)
+
+	tests := []struct {
+		name     string
+		value    string
+		ref      time.Time
+		want     time.Time
+		wantNote bool
+		wantErr  string
+	}{
+		// ISO 8601
+		{name: "ISO date", value: "2024-06-01", ref: ref, want: day(2024, 6, 1)},
+		{name: "ISO leap day", value: "2024-02-29", ref: ref, want: day(2024, 2, 29)},
+		{name: "ISO leap day in common year", value: "2023-02-29", ref: ref, wantErr: "2023-02-29"},
+		{name: "ISO timestamp UTC", value: "2024-06-01T23:30:00Z", ref: ref, want: day(2024, 6, 1)},
+		{name: "ISO timestamp keeps its own offset", value: "2024-06-01T01:00:00+09:00", ref: ref,
+			want: day(2024, 6, 1)},
+		{name: "ISO timestamp west of UTC", value: "2024-06-01T22:00:00-05:00", ref: ref, want: day(2024, 6, 1)},
+
+		// MM/DD/YYYY
+		{name: "US date", value: "06/15/2024", ref: ref, want: day(2024, 6, 15)},
+		{name: "US leap day", value: "02/29/2024", ref: ref, want: day(2024, 2, 29)},
+		{name: "US ambiguous", value: "03/04/2024", ref: ref, want: day(2024, 3, 4), wantNote: true},
+		{name: "US same day and month", value: "04/04/2024", ref: ref, want: day(2024, 4, 4)},
+		{name: "European date", value: "15/01/2024", ref: ref, wantErr: "looks like DD/MM/YYYY"},
+		{name: "single digits", value: "1/1/2024", ref: ref, wantErr: "1/1/2024"},
+
+		// Relative
+		{name: "days", value: "7d", ref: ref, want: day(2024, 3, 24)},
+		{name: "today", value: "0d", ref: ref, want: day(2024, 3, 31)},
+		{name: "weeks", value: "3w", ref: ref, want: day(2024, 3, 10)},
+		{name: "month clamps to leap February", value: "1m", ref: ref, want: day(2024, 2, 29)},
+		{name: "months across a year", value: "6m", ref: ref, want: day(2023, 9, 30)},
+		{name: "year from leap day", value: "1y", ref: day(2024, 2, 29), want: day(2023, 2, 28)},
+		{name: "days across leap day", value: "2d", ref: day(2024, 3, 1), want: day(2024, 2, 28)},
+		{name: "relative uses the local calendar date", value: "1d",
+			ref: time.Date(2024, 3, 1, 0, 30, 0, 0, tokyo), want: day(2024, 2, 29)},
+		{name: "relative west of UTC", value: "1d",
+			ref: time.Date(2024, 2, 29, 22, 0, 0, 0, newYork), want: day(2024, 2, 28)},
+		{name: "unknown unit", value: "7h", ref: ref, wantErr: "7h"},
+
+		// Quarters
+		{name: "first quarter", value: "2024-Q1", ref: ref, want: day(2024, 1, 1)},
+		{name: "fourth quarter lowercase", value: "2023-q4", ref: ref, want: day(2023, 10, 1)},
+		{name: "quarter out of range", value: "2024-Q5", ref: ref, wantErr: "2024-Q5"},
+
+		{name: "empty", value: "", ref: ref, wantErr: "Use YYYY-MM-DD"},
+		{name: "garbage", value: "yesterday", ref: ref, wantErr: "yesterday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, note, err := ParseDate(tt.value, tt.ref)
+			if tt.wantErr != "" {
+				if !errors.Is(err, clerrors.ErrInvalidDate) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseDate(%q) error = %v, want ErrInvalidDate mentioning %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDate(%q) unexpected error: %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("ParseDate(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+			if (note != "") != tt.wantNote {
+				t.Errorf("ParseDate(%q) note = %q, wantNote %v", tt.value, note, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestValidateDate_NotesAmbiguityOnce(t *testing.T) {
+	var buf bytes.Buffer
+	oldNotes, oldNow := DateNotes, now
+	DateNotes = &buf
+	now = func() time.Time { return day(2024, 5, 1) }
+	t.Cleanup(func() { DateNotes, now = oldNotes, oldNow })
+
+	for range 2 {
+		if _, err := ValidateDate("05/06/2024"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ValidateDate("2w"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Note: read date "05/06/2024" as MM/DD/YYYY (2024-05-06); use YYYY-MM-DD to avoid ambiguity` + "\n"
+	if buf.String() != want {
+		t.Errorf("notes = %q, want %q", buf.String(), want)
+	}
+}
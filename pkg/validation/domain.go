@@ -0,0 +1,88 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// ExcludePrefix marks a domain excluded from search in the API domain filter.
+const ExcludePrefix = "-"
+
+// ValidateDomain checks that domain is a bare host name such as
+// "example.com". The check is deliberately loose: it rejects URLs,
+// paths and whitespace, not hosts the API may still understand.
+// Returns an error wrapping clerrors.ErrInvalidDomain.
+func ValidateDomain(domain string) error {
+	switch {
+	case domain == "":
+		return fmt.Errorf("%w: empty domain", clerrors.ErrInvalidDomain)
+	case strings.Contains(domain, "://"):
+		return fmt.Errorf("%w: %q has a scheme; use the host only, e.g. %q",
+			clerrors.ErrInvalidDomain, domain, hostOf(domain))
+	case strings.ContainsAny(domain, "/?#"):
+		return fmt.Errorf("%w: %q has a path; use the host only, e.g. %q",
+			clerrors.ErrInvalidDomain, domain, hostOf(domain))
+	case strings.ContainsAny(domain, " \t\n@"):
+		return fmt.Errorf("%w: %q is not a host name", clerrors.ErrInvalidDomain, domain)
+	}
+	return nil
+}
+
+// DomainFilter combines the domains to search and the domains to exclude
+// into the single search_domain_filter list the API expects, in which
+// exclusions are prefixed with "-". An included entry already prefixed
+// with "-" is kept as an exclusion, and a "-" on an excluded entry is
+// optional. Domains are compared case-insensitively; a domain in both lists
+// is an error wrapping clerrors.ErrDomainConflict. Returns nil when both
+// lists are empty.
+func DomainFilter(include, exclude []string) ([]string, error) {
+	var allowed, denied []string
+	for _, d := range include {
+		if rest, ok := strings.CutPrefix(d, ExcludePrefix); ok {
+			denied = append(denied, rest)
+			continue
+		}
+		allowed = append(allowed, d)
+	}
+	for _, d := range exclude {
+		denied = append(denied, strings.TrimPrefix(d, ExcludePrefix))
+	}
+
+	filter := make([]string, 0, len(allowed)+len(denied))
+	seen := make(map[string]bool, len(allowed))
+	for _, d := range allowed {
+		d = strings.TrimSpace(d)
+		if err := ValidateDomain(d); err != nil {
+			return nil, err
+		}
+		seen[strings.ToLower(d)] = true
+		filter = append(filter, d)
+	}
+	for _, d := range denied {
+		d = strings.TrimSpace(d)
+		if err := ValidateDomain(d); err != nil {
+			return nil, err
+		}
+		if seen[strings.ToLower(d)] {
+			return nil, fmt.Errorf("%w: %s", clerrors.ErrDomainConflict, d)
+		}
+		filter = append(filter, ExcludePrefix+d)
+	}
+	if len(filter) == 0 {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// hostOf strips the scheme and path of a URL-like domain for suggestions.
+func hostOf(s string) string {
+	if _, rest, ok := strings.Cut(s, "://"); ok {
+		s = rest
+	}
+	if i := strings.IndexAny(s, "/?#"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
@@ -0,0 +1,89 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestValidateDomain(t *testing.T) {
+	tests := []struct {
+		domain  string
+		wantErr bool
+	}{
+		{"example.com", false},
+		{"docs.python.org", false},
+		{"localhost", false},
+		{"", true},
+		{"https://example.com", true},
+		{"example.com/path", true},
+		{"example.com?q=1", true},
+		{"exa mple.com", true},
+		{"user@example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			err := ValidateDomain(tt.domain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateDomain(%q) error = %v, wantErr %v", tt.domain, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, clerrors.ErrInvalidDomain) {
+				t.Errorf("error %v does not wrap ErrInvalidDomain", err)
+			}
+		})
+	}
+}
+
+func TestValidateDomain_SuggestsHost(t *testing.T) {
+	err := ValidateDomain("https://go.dev/doc")
+	if err == nil || !strings.Contains(err.Error(), `"go.dev"`) {
+		t.Errorf("error = %v, want it to suggest go.dev", err)
+	}
+}
+
+func TestDomainFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+		wantErr error
+	}{
+		{"empty", nil, nil, nil, nil},
+		{"include only", []string{"go.dev", "github.com"}, nil, []string{"go.dev", "github.com"}, nil},
+		{"exclude only", nil, []string{"pinterest.com"}, []string{"-pinterest.com"}, nil},
+		{
+			name:    "include and exclude",
+			include: []string{"go.dev"},
+			exclude: []string{"pinterest.com", "quora.com"},
+			want:    []string{"go.dev", "-pinterest.com", "-quora.com"},
+		},
+		{"prefixed exclusion kept", nil, []string{"-quora.com"}, []string{"-quora.com"}, nil},
+		{"prefixed include becomes exclusion", []string{"go.dev", "-quora.com"}, nil, []string{"go.dev", "-quora.com"}, nil},
+		{"spaces trimmed", []string{" go.dev "}, []string{" quora.com"}, []string{"go.dev", "-quora.com"}, nil},
+		{"conflict", []string{"go.dev"}, []string{"go.dev"}, nil, clerrors.ErrDomainConflict},
+		{"conflict ignores case", []string{"Go.dev"}, []string{"go.DEV"}, nil, clerrors.ErrDomainConflict},
+		{"invalid include", []string{"https://go.dev"}, nil, nil, clerrors.ErrInvalidDomain},
+		{"invalid exclude", nil, []string{"quora.com/x"}, nil, clerrors.ErrInvalidDomain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DomainFilter(tt.include, tt.exclude)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DomainFilter() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DomainFilter() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DomainFilter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package validation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Language is an answer language accepted by defaults.language and --language.
+type Language struct {
+	// Code is the ISO 639-1 code.
+	Code string
+	// Name is the English name, used in the instruction sent to the model.
+	Name string
+	// Native is the name of the language in itself.
+	Native string
+}
+
+// languages holds the accepted answer languages, ordered by code.
+var languages = []Language{
+	{"ar", "Arabic", "العربية"},
+	{"bg", "Bulgarian", "български"},
+	{"ca", "Catalan", "català"},
+	{"cs", "Czech", "čeština"},
+	{"da", "Danish", "dansk"},
+	{"de", "German", "Deutsch"},
+	{"el", "Greek", "ελληνικά"},
+	{"en", "English", "English"},
+	{"es", "Spanish", "español"},
+	{"et", "Estonian", "eesti"},
+	{"fa", "Persian", "فارسی"},
+	{"fi", "Finnish", "suomi"},
+	{"fr", "French", "français"},
+	{"he", "Hebrew", "עברית"},
+	{"hi", "Hindi", "हिन्दी"},
+	{"hr", "Croatian", "hrvatski"},
+	{"hu", "Hungarian", "magyar"},
+	{"id", "Indonesian", "Bahasa Indonesia"},
+	{"it", "Italian", "italiano"},
+	{"ja", "Japanese", "日本語"},
+	{"ko", "Korean", "한국어"},
+	{"lt", "Lithuanian", "lietuvių"},
+	{"lv", "Latvian", "latviešu"},
+	{"nl", "Dutch", "Nederlands"},
+	{"no", "Norwegian", "norsk"},
+	{"pl", "Polish", "polski"},
+	{"pt", "Portuguese", "português"},
+	{"ro", "Romanian", "română"},
+	{"ru", "Russian", "русский"},
+	{"sk", "Slovak", "slovenčina"},
+	{"sl", "Slovenian", "slovenščina"},
+	{"sr", "Serbian", "српски"},
+	{"sv", "Swedish", "svenska"},
+	{"th", "Thai", "ไทย"},
+	{"tr", "Turkish", "Türkçe"},
+	{"uk", "Ukrainian", "українська"},
+	{"vi", "Vietnamese", "Tiếng Việt"},
+	{"zh", "Chinese", "中文"},
+}
+
+// Languages returns the accepted answer languages, ordered by code.
+func Languages() []Language {
+	return slices.Clone(languages)
+}
+
+// LanguageCodes returns the ISO 639-1 codes of the accepted answer languages.
+func LanguageCodes() []string {
+	codes := make([]string, len(languages))
+	for i, l := range languages {
+		codes[i] = l.Code
+	}
+	return codes
+}
+
+// LookupLanguage returns the language with the ISO 639-1 code, ignoring case.
+func LookupLanguage(code string) (Language, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	for _, l := range languages {
+		if l.Code == code {
+			return l, true
+		}
+	}
+	return Language{}, false
+}
+
+// ValidateLanguage validates an answer language code.
+// Empty values are accepted (no language instruction). Returns an error
+// wrapping clerrors.ErrInvalidLanguage otherwise.
+func ValidateLanguage(code string) error {
+	if code == "" {
+		return nil
+	}
+	if _, ok := LookupLanguage(code); ok {
+		return nil
+	}
+	return fmt.Errorf("%w: '%s'. Must be an ISO 639-1 code: %s",
+		clerrors.ErrInvalidLanguage, code, strings.Join(LanguageCodes(), ", "))
+}
@@ -0,0 +1,43 @@
+package validation
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestValidateLanguage(t *testing.T) {
+	tests := []struct {
+		code    string
+		wantErr bool
+	}{
+		{"", false},
+		{"fr", false},
+		{"FR", false},
+		{"zh", false},
+		{"french", true},
+		{"fr-FR", true},
+		{"xx", true},
+	}
+	for _, tt := range tests {
+		err := ValidateLanguage(tt.code)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateLanguage(%q) error = %v, wantErr %v", tt.code, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, clerrors.ErrInvalidLanguage) {
+			t.Errorf("ValidateLanguage(%q) error = %v, want ErrInvalidLanguage", tt.code, err)
+		}
+	}
+}
+
+func TestLanguageCodes_Sorted(t *testing.T) {
+	codes := LanguageCodes()
+	if !slices.IsSorted(codes) {
+		t.Errorf("LanguageCodes() = %v, want sorted", codes)
+	}
+	if len(slices.Compact(slices.Clone(codes))) != len(codes) {
+		t.Errorf("LanguageCodes() has duplicates: %v", codes)
+	}
+}
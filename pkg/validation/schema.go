@@ -0,0 +1,161 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// schemaTypes are the values of the type keyword.
+var schemaTypes = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+// schemaKeywords are the keywords of JSON Schema draft 2020-12, plus the
+// definitions keyword of earlier drafts, which is still widely used.
+var schemaKeywords = []string{
+	// Core
+	"$schema", "$id", "$ref", "$defs", "$comment", "$anchor", "$dynamicRef", "$dynamicAnchor", "$vocabulary",
+	"definitions",
+	// Applicators
+	"properties", "patternProperties", "additionalProperties", "propertyNames", "items", "prefixItems",
+	"contains", "allOf", "anyOf", "oneOf", "not", "if", "then", "else", "dependentSchemas",
+	"unevaluatedItems", "unevaluatedProperties",
+	// Validation
+	"type", "enum", "const", "multipleOf", "maximum", "exclusiveMaximum", "minimum", "exclusiveMinimum",
+	"maxLength", "minLength", "pattern", "maxItems", "minItems", "uniqueItems", "maxContains", "minContains",
+	"maxProperties", "minProperties", "required", "dependentRequired",
+	// Format, content and metadata
+	"format", "contentEncoding", "contentMediaType", "contentSchema",
+	"title", "description", "default", "deprecated", "readOnly", "writeOnly", "examples",
+}
+
+// ValidateJSONSchema parses schema, the JSON Schema of a structured answer,
+// and checks it against a subset of JSON Schema draft 2020-12: the root is
+// an object with a valid type, properties map names to schemas, and every
+// required entry is a property. Nested property and items schemas are
+// checked the same way, except that their type may be left out.
+//
+// It returns the parsed schema, ready for the response format of a request,
+// and a warning for each unknown top-level keyword, which the API ignores.
+// Problems are reported together in one error wrapping
+// clerrors.ErrInvalidJSONSchema.
+func ValidateJSONSchema(schema string) (map[string]any, []string, error) {
+	var root map[string]any
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", clerrors.ErrInvalidJSONSchema, err)
+	}
+	if root == nil {
+		return nil, nil, fmt.Errorf("%w: must be a JSON object", clerrors.ErrInvalidJSONSchema)
+	}
+
+	if problems := checkSchema("", root, true); len(problems) > 0 {
+		return nil, nil, fmt.Errorf("%w: %s", clerrors.ErrInvalidJSONSchema, strings.Join(problems, "; "))
+	}
+
+	var warnings []string
+	for _, keyword := range sortedKeys(root) {
+		if !slices.Contains(schemaKeywords, keyword) {
+			warnings = append(warnings,
+				fmt.Sprintf("JSON schema keyword %q is not part of JSON Schema 2020-12 and is ignored", keyword))
+		}
+	}
+	return root, warnings, nil
+}
+
+// checkSchema returns the problems of the schema at path, "" for the root.
+// The root must have a type.
+func checkSchema(path string, schema map[string]any, root bool) []string {
+	var problems []string
+	report := func(format string, args ...any) {
+		problems = append(problems, path+fmt.Sprintf(format, args...))
+	}
+
+	if value, ok := schema["type"]; ok {
+		if !validSchemaType(value) {
+			report("type %v is not one of: %s", jsonText(value), strings.Join(schemaTypes, ", "))
+		}
+	} else if root {
+		report("type is required")
+	}
+
+	var names []string
+	if value, ok := schema["properties"]; ok {
+		properties, isObject := value.(map[string]any)
+		if !isObject {
+			report("properties must be an object")
+		}
+		names = sortedKeys(properties)
+		for _, name := range names {
+			sub, isObject := properties[name].(map[string]any)
+			if !isObject {
+				report("properties.%s must be an object", name)
+				continue
+			}
+			problems = append(problems, checkSchema(path+"properties."+name+": ", sub, false)...)
+		}
+	}
+
+	if value, ok := schema["required"]; ok {
+		required, isArray := value.([]any)
+		if !isArray {
+			report("required must be an array of property names")
+		}
+		for _, entry := range required {
+			name, isString := entry.(string)
+			switch {
+			case !isString:
+				report("required entry %s is not a string", jsonText(entry))
+			case !slices.Contains(names, name):
+				report("required entry %q is not in properties", name)
+			}
+		}
+	}
+
+	if items, ok := schema["items"].(map[string]any); ok {
+		problems = append(problems, checkSchema(path+"items: ", items, false)...)
+	}
+	return problems
+}
+
+// validSchemaType reports whether value is a type name or a non-empty array
+// of type names.
+func validSchemaType(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return slices.Contains(schemaTypes, v)
+	case []any:
+		if len(v) == 0 {
+			return false
+		}
+		for _, item := range v {
+			if name, ok := item.(string); !ok || !slices.Contains(schemaTypes, name) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonText returns value as JSON, for messages.
+func jsonText(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
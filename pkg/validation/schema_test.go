@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	tests := []struct {
+		name         string
+		schema       string
+		wantErr      []string
+		wantWarnings int
+	}{
+		{"object", `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`, nil, 0},
+		{"type list", `{"type":["object","null"]}`, nil, 0},
+		{"nested without type", `{"type":"object","properties":{"tags":{"items":{"type":"string"}}}}`, nil, 0},
+		{"misspelled type", `{"tpye":"object"}`, []string{"type is required"}, 0},
+		{"invalid type", `{"type":"int"}`, []string{`type "int" is not one of`}, 0},
+		{"empty type list", `{"type":[]}`, []string{"type [] is not one of"}, 0},
+		{"property not an object", `{"type":"object","properties":{"age":"integer"}}`,
+			[]string{"properties.age must be an object"}, 0},
+		{"properties not an object", `{"type":"object","properties":[]}`, []string{"properties must be an object"}, 0},
+		{"required not in properties", `{"type":"object","properties":{"a":{}},"required":["a","b"]}`,
+			[]string{`required entry "b" is not in properties`}, 0},
+		{"required not an array", `{"type":"object","required":"a"}`, []string{"required must be an array"}, 0},
+		{"nested problems", `{"type":"object","properties":{"user":{"type":"obj","required":["id"]}}}`,
+			[]string{`properties.user: type "obj"`, `properties.user: required entry "id"`}, 0},
+		{"items problem", `{"type":"array","items":{"type":"text"}}`, []string{`items: type "text"`}, 0},
+		{"invalid JSON", `{"type":`, []string{"invalid JSON schema"}, 0},
+		{"not an object", `["object"]`, []string{"invalid JSON schema"}, 0},
+		{"null", `null`, []string{"must be a JSON object"}, 0},
+		{"unknown keyword", `{"type":"object","propertees":{},"additionalProperties":false}`, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, warnings, err := ValidateJSONSchema(tt.schema)
+			if tt.wantErr != nil {
+				if !errors.Is(err, clerrors.ErrInvalidJSONSchema) {
+					t.Fatalf("ValidateJSONSchema() error = %v, want ErrInvalidJSONSchema", err)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateJSONSchema() error = %v", err)
+			}
+			if schema == nil {
+				t.Error("schema = nil, want the parsed schema")
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %q, want %d", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
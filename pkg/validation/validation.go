@@ -0,0 +1,111 @@
+// Package validation provides typed validators for Perplexity API parameters.
+// It is the single source of truth for enum values (recency, search mode, context
+// size, reasoning effort, image formats) and date filter parsing, shared by the
+// query command, the chat package, the MCP handler, and the config package.
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+// Kinds accepted by ValidValues.
+const (
+	KindRecency         = "recency"
+	KindSearchMode      = "search_mode"
+	KindContextSize     = "context_size"
+	KindReasoningEffort = "reasoning_effort"
+	KindImageFormat     = "image_format"
+	KindImageConflict   = "image_conflict_policy"
+)
+
+// validValues holds the canonical, ordered list of values for each kind.
+var validValues = map[string][]string{
+	KindRecency:         {"hour", "day", "week", "month", "year"},
+	KindSearchMode:      {"web", "academic"},
+	KindContextSize:     {"low", "medium", "high"},
+	KindReasoningEffort: {"low", "medium", "high"},
+	KindImageFormat:     {"jpg", "jpeg", "png", "gif", "webp", "svg", "bmp"},
+	KindImageConflict:   {ImageConflictPreferImages, ImageConflictPreferRecency, ImageConflictError},
+}
+
+// ValidValues returns a copy of the canonical list of values for kind.
+// Returns nil for unknown kinds.
+func ValidValues(kind string) []string {
+	values, ok := validValues[kind]
+	if !ok {
+		return nil
+	}
+	out := make([]string, len(values))
+	copy(out, values)
+	return out
+}
+
+// ValidList returns the canonical values for kind joined with ", ",
+// suitable for "must be one of: ..." messages.
+func ValidList(kind string) string {
+	return strings.Join(validValues[kind], ", ")
+}
+
+// IsValid reports whether value belongs to the canonical list for kind.
+func IsValid(kind, value string) bool {
+	for _, v := range validValues[kind] {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRecency validates a search recency value.
+// Empty values are accepted (no filter). Returns an error wrapping
+// clerrors.ErrInvalidSearchRecency otherwise.
+func ValidateRecency(value string) error {
+	return validateEnum(KindRecency, value, clerrors.ErrInvalidSearchRecency)
+}
+
+// ValidateSearchMode validates a search mode value.
+// Empty values are accepted (API default). Returns an error wrapping
+// clerrors.ErrInvalidSearchMode otherwise.
+func ValidateSearchMode(value string) error {
+	return validateEnum(KindSearchMode, value, clerrors.ErrInvalidSearchMode)
+}
+
+// ValidateContextSize validates a search context size value.
+// Empty values are accepted (API default). Returns an error wrapping
+// clerrors.ErrInvalidSearchContextSize otherwise.
+func ValidateContextSize(value string) error {
+	return validateEnum(KindContextSize, value, clerrors.ErrInvalidSearchContextSize)
+}
+
+// ValidateReasoningEffort validates a reasoning effort value.
+// Empty values are accepted (API default). Returns an error wrapping
+// clerrors.ErrInvalidReasoningEffort otherwise.
+func ValidateReasoningEffort(value string) error {
+	return validateEnum(KindReasoningEffort, value, clerrors.ErrInvalidReasoningEffort)
+}
+
+// ValidateImageFormats returns the entries of formats that are not in the
+// known image format list. A nil result means every format is supported.
+// Unknown formats are reported rather than rejected because the API may
+// accept formats this list does not know about yet.
+func ValidateImageFormats(formats []string) []string {
+	var unsupported []string
+	for _, format := range formats {
+		if !IsValid(KindImageFormat, format) {
+			unsupported = append(unsupported, format)
+		}
+	}
+	return unsupported
+}
+
+// validateEnum checks value against the canonical list for kind and wraps
+// sentinel with a message listing the accepted values.
+func validateEnum(kind, value string, sentinel error) error {
+	if value == "" || IsValid(kind, value) {
+		return nil
+	}
+	return fmt.Errorf("%w: '%s'. Must be one of: %s", sentinel, value, ValidList(kind))
+}
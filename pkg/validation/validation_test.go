@@ -0,0 +1,135 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/pplx/pkg/clerrors"
+)
+
+func TestEnumValidators(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate func(string) error
+		value    string
+		errIs    error
+	}{
+		{"recency empty", ValidateRecency, "", nil},
+		{"recency hour", ValidateRecency, "hour", nil},
+		{"recency year", ValidateRecency, "year", nil},
+		{"recency invalid", ValidateRecency, "decade", clerrors.ErrInvalidSearchRecency},
+		{"recency case sensitive", ValidateRecency, "Day", clerrors.ErrInvalidSearchRecency},
+		{"search mode web", ValidateSearchMode, "web", nil},
+		{"search mode academic", ValidateSearchMode, "academic", nil},
+		{"search mode invalid", ValidateSearchMode, "news", clerrors.ErrInvalidSearchMode},
+		{"context size medium", ValidateContextSize, "medium", nil},
+		{"context size invalid", ValidateContextSize, "huge", clerrors.ErrInvalidSearchContextSize},
+		{"reasoning effort high", ValidateReasoningEffort, "high", nil},
+		{"reasoning effort invalid", ValidateReasoningEffort, "max", clerrors.ErrInvalidReasoningEffort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validate(tt.value)
+			if tt.errIs == nil {
+				if err != nil {
+					t.Errorf("unexpected error for %q: %v", tt.value, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.errIs) {
+				t.Errorf("error = %v, want errors.Is %v", err, tt.errIs)
+			}
+		})
+	}
+}
+
+func TestValidateImageFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		formats []string
+		want    []string
+	}{
+		{"nil input", nil, nil},
+		{"all supported", []string{"jpg", "png", "webp"}, nil},
+		{"some unsupported", []string{"png", "tiff", "gif", "heic"}, []string{"tiff", "heic"}},
+		{"case sensitive", []string{"PNG"}, []string{"PNG"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateImageFormats(tt.formats)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateImageFormats(%v) = %v, want %v", tt.formats, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"ISO 8601", "2024-01-15", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), false},
+		{"US format", "01/15/2024", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), false},
+		{"European format", "15/01/2024", time.Time{}, true},
+		{"invalid ISO month", "2024-13-01", time.Time{}, true},
+		{"garbage", "not-a-date", time.Time{}, true},
+		{"empty", "", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateDate(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, clerrors.ErrInvalidDate) {
+					t.Errorf("ValidateDate(%q) error = %v, want ErrInvalidDate", tt.value, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateDate(%q) unexpected error: %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ValidateDate(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidValues(t *testing.T) {
+	t.Run("returns canonical order", func(t *testing.T) {
+		want := []string{"hour", "day", "week", "month", "year"}
+		if got := ValidValues(KindRecency); !reflect.DeepEqual(got, want) {
+			t.Errorf("ValidValues(KindRecency) = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("returns a copy", func(t *testing.T) {
+		got := ValidValues(KindSearchMode)
+		got[0] = "mutated"
+		if !IsValid(KindSearchMode, "web") {
+			t.Error("mutating the returned slice changed the canonical list")
+		}
+	})
+
+	t.Run("unknown kind", func(t *testing.T) {
+		if got := ValidValues("unknown"); got != nil {
+			t.Errorf("ValidValues(unknown) = %v, want nil", got)
+		}
+		if IsValid("unknown", "web") {
+			t.Error("IsValid(unknown, web) = true, want false")
+		}
+	})
+
+	t.Run("valid list", func(t *testing.T) {
+		if got := ValidList(KindContextSize); got != "low, medium, high" {
+			t.Errorf("ValidList(KindContextSize) = %q", got)
+		}
+	})
+}
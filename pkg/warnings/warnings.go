@@ -0,0 +1,199 @@
+// Package warnings collects the non-fatal issues of a run, such as an image
+// format the API may not support, so that they are reported together once
+// the answer is out instead of interleaving with it.
+//
+// The CLI collects into Default and reports it when the command ends.
+// Components serving several callers, such as the MCP query handler, take
+// a Collector per call from the context, and log the warnings of calls
+// without one.
+package warnings
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/sgaunet/pplx/pkg/i18n"
+	"github.com/sgaunet/pplx/pkg/logger"
+	"github.com/sgaunet/pplx/pkg/output/color"
+)
+
+// Codes identify the kind of a warning, for scripts reading them from JSON.
+const (
+	// CodeImageFormat is an image format filter the API may not support.
+	CodeImageFormat = "image_format"
+	// CodeImageConflict is an image or recency option dropped because the
+	// API cannot combine them.
+	CodeImageConflict = "image_conflict"
+	// CodeReasoningEffort is a reasoning effort sent to a model that ignores it.
+	CodeReasoningEffort = "reasoning_effort"
+	// CodeUnknownModel is a model pplx does not know, sent anyway.
+	CodeUnknownModel = "unknown_model"
+	// CodeTruncated is an answer left cut off at max_tokens.
+	CodeTruncated = "truncated"
+	// CodeBudgetUnchecked is a daily budget that could not be checked.
+	CodeBudgetUnchecked = "budget_unchecked"
+	// CodeExportDropped is a request field left out of an exported request
+	// because its wire format cannot carry it.
+	CodeExportDropped = "export_dropped"
+	// CodeJSONSchema is a response format JSON schema keyword the API
+	// ignores.
+	CodeJSONSchema = "json_schema"
+	// CodeConfig is a configuration problem worked around, such as a
+	// profile that cannot be applied.
+	CodeConfig = "config"
+	// CodeModelFallback is an answer from a fallback model, sent the
+	// request because the requested model was unavailable.
+	CodeModelFallback = "model_fallback"
+	// CodeStreamFormat is streaming turned off because the request has a
+	// response format, which the API ignores in streamed answers.
+	CodeStreamFormat = "stream_format"
+	// CodeAutosave is a chat autosave that could not be read and was
+	// ignored.
+	CodeAutosave = "autosave"
+)
+
+// Warning is a non-fatal issue.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Hint suggests how to address the issue; empty when there is nothing
+	// to suggest.
+	Hint string `json:"hint,omitempty"`
+}
+
+// Collector accumulates warnings. It is safe for concurrent use. A nil
+// *Collector logs the warnings added to it instead.
+type Collector struct {
+	mu       sync.Mutex
+	warnings []Warning
+	// reported counts the warnings already returned by Take.
+	reported int
+}
+
+// Default is the collector of the running command.
+//
+//nolint:gochecknoglobals // one collector per process, reported when the command ends
+var Default = &Collector{}
+
+// New returns an empty collector.
+func New() *Collector {
+	return &Collector{}
+}
+
+// Add records a warning. Repeats of a recorded warning are dropped, so that
+// an issue found on every request of a session is reported once.
+func (c *Collector) Add(code, message, hint string) {
+	w := Warning{Code: code, Message: message, Hint: hint}
+	if c == nil {
+		attrs := []any{"code", code}
+		if hint != "" {
+			attrs = append(attrs, "hint", hint)
+		}
+		logger.Warn(message, attrs...)
+		return
+	}
+	logger.Debug("warning", "code", code, "message", message)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !slices.Contains(c.warnings, w) {
+		c.warnings = append(c.warnings, w)
+	}
+}
+
+// Len returns the number of warnings recorded, reported or not.
+func (c *Collector) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.warnings)
+}
+
+// All returns every warning recorded, in order.
+func (c *Collector) All() []Warning {
+	if c == nil {
+		return []Warning{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.warnings)
+}
+
+// Take returns the warnings recorded since the last call, in order, and
+// marks them reported. It never returns nil.
+func (c *Collector) Take() []Warning {
+	if c == nil {
+		return []Warning{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	taken := slices.Clone(c.warnings[c.reported:])
+	c.reported = len(c.warnings)
+	if taken == nil {
+		taken = []Warning{}
+	}
+	return taken
+}
+
+// Reset forgets every warning.
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.warnings, c.reported = nil, 0
+}
+
+// Add records a warning in Default.
+func Add(code, message, hint string) {
+	Default.Add(code, message, hint)
+}
+
+// collectorKey is the context key of the collector of a call.
+type collectorKey struct{}
+
+// NewContext returns a copy of ctx carrying c.
+func NewContext(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, collectorKey{}, c)
+}
+
+// FromContext returns the collector carried by ctx, or nil, which logs
+// warnings, when there is none.
+func FromContext(ctx context.Context) *Collector {
+	c, _ := ctx.Value(collectorKey{}).(*Collector)
+	return c
+}
+
+// Write prints warnings as a block, each with its code and hint, the header
+// in the warning color of the current theme when colored is set. The header
+// is in the language selected with i18n; the messages are not translated.
+// It prints nothing when there are none.
+func Write(w io.Writer, warnings []Warning, colored bool) error {
+	if len(warnings) == 0 {
+		return nil
+	}
+	header := i18n.T("warnings.header.one")
+	if len(warnings) > 1 {
+		header = i18n.T("warnings.header.many", len(warnings))
+	}
+	if colored {
+		header = color.CurrentTheme().Paint(color.Warning, header)
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		return fmt.Errorf("error writing warnings: %w", err)
+	}
+	for _, warning := range warnings {
+		if _, err := fmt.Fprintf(w, "  [%s] %s\n", warning.Code, warning.Message); err != nil {
+			return fmt.Errorf("error writing warnings: %w", err)
+		}
+		if warning.Hint == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "    %s: %s\n", i18n.T("warnings.hint"), warning.Hint); err != nil {
+			return fmt.Errorf("error writing warnings: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package warnings
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestCollector_AddDeduplicates(t *testing.T) {
+	c := New()
+	c.Add(CodeUnknownModel, `unknown model "sonar-prp"`, "run pplx models")
+	c.Add(CodeImageFormat, `image format "tiff" may not be supported`, "")
+	c.Add(CodeUnknownModel, `unknown model "sonar-prp"`, "run pplx models")
+
+	want := []Warning{
+		{Code: CodeUnknownModel, Message: `unknown model "sonar-prp"`, Hint: "run pplx models"},
+		{Code: CodeImageFormat, Message: `image format "tiff" may not be supported`},
+	}
+	if got := c.All(); !slices.Equal(got, want) {
+		t.Errorf("All() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCollector_Take(t *testing.T) {
+	c := New()
+	if got := c.Take(); got == nil || len(got) != 0 {
+		t.Errorf("Take() of an empty collector = %#v, want an empty slice", got)
+	}
+
+	c.Add(CodeTruncated, "first", "")
+	if got := c.Take(); len(got) != 1 || got[0].Message != "first" {
+		t.Errorf("Take() = %+v, want the first warning", got)
+	}
+	c.Add(CodeTruncated, "second", "")
+	if got := c.Take(); len(got) != 1 || got[0].Message != "second" {
+		t.Errorf("Take() = %+v, want only the warning added since the last Take", got)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2: reported warnings still count", c.Len())
+	}
+
+	c.Reset()
+	if c.Len() != 0 || len(c.Take()) != 0 {
+		t.Errorf("Reset() left %d warnings", c.Len())
+	}
+}
+
+func TestCollector_Nil(t *testing.T) {
+	var c *Collector
+	c.Add(CodeConfig, "logged instead", "")
+	if c.Len() != 0 || len(c.All()) != 0 || len(c.Take()) != 0 {
+		t.Error("a nil collector should hold no warnings")
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Error("FromContext() of a bare context should be nil")
+	}
+	c := New()
+	if FromContext(NewContext(context.Background(), c)) != c {
+		t.Error("FromContext() should return the collector of NewContext")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		warnings []Warning
+		want     string
+	}{
+		{"none", nil, ""},
+		{
+			"one",
+			[]Warning{{Code: CodeUnknownModel, Message: `unknown model "x"`, Hint: "run pplx models"}},
+			"⚠ Warning:\n  [unknown_model] unknown model \"x\"\n    hint: run pplx models\n",
+		},
+		{
+			"several",
+			[]Warning{{Code: CodeTruncated, Message: "cut off"}, {Code: CodeConfig, Message: "no profile"}},
+			"⚠ 2 warnings:\n  [truncated] cut off\n  [config] no profile\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.warnings, false); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Write() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}